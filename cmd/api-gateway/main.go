@@ -2,31 +2,75 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/pavilion-trust/core-broker/internal/config"
 	"github.com/pavilion-trust/core-broker/internal/server"
+	"github.com/pavilion-trust/core-broker/internal/services"
 )
 
 func main() {
+	validate := flag.Bool("validate", false, "validate the configuration, print a report and exit")
+	format := flag.String("format", "text", "report format for --validate: text or json")
+	flag.Parse()
+
+	// "api-gateway doctor" is the same as --validate
+	if *validate || flag.Arg(0) == "doctor" {
+		os.Exit(runDoctor(*format))
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// Reject configuration that the crypto profile does not allow
+	if err := services.ValidateCryptoConfig(cfg); err != nil {
+		log.Fatalf("Invalid crypto configuration: %v", err)
+	}
+	log.Printf("Using crypto profile %s", cfg.CryptoProfile)
+
 	// Create API Gateway server
 	srv := server.NewAPIGateway(cfg)
 
+	// Pick up rotated secrets and certificates without a restart
+	cfg.Secrets.Start(cfg.SecretsRefreshInterval)
+
+	// Reload timeouts, retries, rate limits and authorization rules when the
+	// runtime configuration file changes or on SIGHUP
+	cfg.Runtime.Start(cfg.RuntimeConfigPollInterval)
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			change, err := cfg.Runtime.Reload(config.ReloadSourceSignal)
+			if err != nil {
+				log.Printf("Runtime configuration not reloaded: %v", err)
+				continue
+			}
+			log.Printf("Runtime configuration reloaded; changed: %v", change.Changed)
+		}
+	}()
+
 	// Start server in a goroutine
 	go func() {
 		log.Printf("Starting API Gateway server on port %s", cfg.APIGatewayPort)
-		if err := srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil && err != http.ErrServerClosed {
+		certFile, keyFile := cfg.TLSCertFile, cfg.TLSKeyFile
+		if srv.TLSConfig.GetCertificate != nil {
+			// The reloadable certificate is already loaded
+			certFile, keyFile = "", ""
+		}
+		if err := srv.ListenAndServeTLS(certFile, keyFile); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
@@ -47,4 +91,50 @@ func main() {
 	}
 
 	log.Println("API Gateway server exited gracefully")
-} 
\ No newline at end of file
+} 
+
+// runDoctor validates the configuration and the services the gateway depends
+// on, prints the report and returns the exit code: 0 when every check passed
+// or only warned, 1 otherwise
+func runDoctor(format string) int {
+	cfg, err := config.Load()
+	var report *services.DiagnosticReport
+	if err != nil {
+		// Nothing else can be checked without a configuration
+		report = services.RunDiagnostics("api-gateway", []services.Diagnostic{{
+			Name: "config_load",
+			Run:  func(context.Context) (string, error) { return "", err },
+		}})
+	} else {
+		report = services.RunDiagnostics("api-gateway", services.GatewayDiagnostics(cfg))
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		printReport(report)
+	}
+	if !report.Passed {
+		return 1
+	}
+	return 0
+}
+
+// printReport writes the report as one line per check and a summary
+func printReport(report *services.DiagnosticReport) {
+	fmt.Printf("API Gateway configuration report (%s)\n", report.CompletedAt.Format(time.RFC3339))
+	counts := make(map[string]int)
+	for _, result := range report.Results {
+		counts[result.Status]++
+		fmt.Printf("  %-5s %-16s %s (%s)\n", strings.ToUpper(result.Status), result.Name, result.Detail, result.Duration)
+	}
+
+	outcome := "PASSED"
+	if !report.Passed {
+		outcome = "FAILED"
+	}
+	fmt.Printf("%s: %d passed, %d warnings, %d failed, %d skipped\n", outcome,
+		counts[services.DiagnosticPass], counts[services.DiagnosticWarn], counts[services.DiagnosticFail], counts[services.DiagnosticSkip])
+}
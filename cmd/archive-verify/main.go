@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// archive-verify checks an audit and proof archive offline, using only the
+// archive itself: the manifest signature, and each segment's record count and
+// Merkle root. Pass the signer fingerprint published when the archive was
+// made with -fingerprint to also check who signed it.
+func main() {
+	fingerprint := flag.String("fingerprint", "", "expected SHA-256 fingerprint of the archive signing key (hex)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-fingerprint hex] <archive.json>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	data, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to read archive: %v", err)
+	}
+
+	result, err := services.VerifyArchive(data)
+	if err != nil {
+		log.Fatalf("Archive verification failed: %v", err)
+	}
+	if *fingerprint != "" && *fingerprint != result.SignerFingerprint {
+		log.Fatalf("Archive verification failed: signed by %s, expected %s", result.SignerFingerprint, *fingerprint)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(result)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// backup exports the broker's durable state (the DP registry, policies,
+// audit and proof archives, and the public parts of its keys) and restores
+// it. Audit entries older than AUDIT_RETENTION and expired proofs are left
+// out of backups, and again out of restores. Every restore verifies the
+// backup's manifest first; pass the digest printed when the backup was made
+// with -digest to also check it is the same backup.
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "create":
+		create(os.Args[2:])
+	case "verify":
+		verify(os.Args[2:])
+	case "restore":
+		restore(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s create|verify|restore [flags]\n", os.Args[0])
+	os.Exit(2)
+}
+
+func create(args []string) {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	out := flags.String("out", "", "file to write the backup to")
+	archives := flags.String("archives", "", "directory of audit and proof archives to include")
+	policies := flags.Bool("policies", true, "include policies from DATABASE_URL")
+	backupID := flags.String("id", "", "backup ID (default backup-<timestamp>)")
+	flags.Parse(args)
+	if *out == "" {
+		log.Fatalf("-out is required")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if *backupID == "" {
+		*backupID = "backup-" + time.Now().UTC().Format("20060102T150405Z")
+	}
+	builder, err := services.NewBackupBuilder(*backupID, cfg.Issuer, services.BackupRetention{AuditRetention: cfg.AuditRetention})
+	if err != nil {
+		log.Fatalf("Failed to start backup: %v", err)
+	}
+
+	// Without a registry file the registry is the single DP_CONNECTOR_URL,
+	// which is configuration rather than state
+	if cfg.DPRegistryFile != "" {
+		data, err := os.ReadFile(cfg.DPRegistryFile)
+		if err != nil {
+			log.Fatalf("Failed to read DP registry file: %v", err)
+		}
+		if err := builder.AddRegistry(data); err != nil {
+			log.Fatalf("Failed to back up DP registry: %v", err)
+		}
+	}
+
+	if *policies {
+		storage, err := services.NewPolicyStorage(cfg)
+		if err != nil {
+			log.Fatalf("Failed to open policy storage: %v", err)
+		}
+		list, err := storage.ListPolicies(context.Background(), map[string]interface{}{})
+		storage.Close()
+		if err != nil {
+			log.Fatalf("Failed to list policies: %v", err)
+		}
+		if err := builder.AddPolicies(list); err != nil {
+			log.Fatalf("Failed to back up policies: %v", err)
+		}
+	}
+
+	if *archives != "" {
+		paths, err := filepath.Glob(filepath.Join(*archives, "*.json"))
+		if err != nil {
+			log.Fatalf("Failed to list archives: %v", err)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				log.Fatalf("Failed to read archive %s: %v", path, err)
+			}
+			if err := builder.AddArchive(data); err != nil {
+				log.Fatalf("Failed to back up archive %s: %v", path, err)
+			}
+		}
+	}
+
+	// A generated attestation key does not outlive the process, so only a
+	// key kept in ATTESTATION_KEY_FILE is worth backing up
+	if cfg.AttestationKeyFile != "" {
+		key, err := services.NewJWSAttestationService(cfg).BackupKey()
+		if err != nil {
+			log.Fatalf("Failed to back up attestation key: %v", err)
+		}
+		builder.AddKey(key)
+	}
+
+	data, digest, err := builder.Build()
+	if err != nil {
+		log.Fatalf("Failed to build backup: %v", err)
+	}
+	if err := os.WriteFile(*out, data, 0o600); err != nil {
+		log.Fatalf("Failed to write backup: %v", err)
+	}
+	container, err := services.VerifyBackup(data)
+	if err != nil {
+		log.Fatalf("Backup verification failed: %v", err)
+	}
+	printJSON(map[string]interface{}{
+		"backup_id":       container.Manifest.BackupID,
+		"manifest_digest": digest,
+		"sections":        container.Manifest.Sections,
+		"excluded":        container.Manifest.Excluded,
+	})
+}
+
+func verify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	digest := flags.String("digest", "", "expected manifest digest (hex)")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatalf("Usage: verify [-digest hex] <backup.json>")
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to read backup: %v", err)
+	}
+	container, err := services.VerifyBackup(data)
+	if err != nil {
+		log.Fatalf("Backup verification failed: %v", err)
+	}
+	if *digest != "" && *digest != container.ManifestDigest {
+		log.Fatalf("Backup verification failed: digest is %s, expected %s", container.ManifestDigest, *digest)
+	}
+	printJSON(container.Manifest)
+}
+
+func restore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	digest := flags.String("digest", "", "expected manifest digest (hex)")
+	registryFile := flags.String("registry-file", "", "file to restore the DP registry to")
+	policies := flags.Bool("policies", false, "restore policies into DATABASE_URL")
+	dir := flags.String("dir", "", "directory to restore audit and proof segments and keys to")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatalf("Usage: restore [-digest hex] [-registry-file path] [-policies] [-dir path] <backup.json>")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to read backup: %v", err)
+	}
+
+	opts := services.BackupRestoreOptions{
+		ExpectedDigest: *digest,
+		RegistryFile:   *registryFile,
+		Dir:            *dir,
+		Retention:      services.BackupRetention{AuditRetention: cfg.AuditRetention},
+	}
+	if *policies {
+		storage, err := services.NewPolicyStorage(cfg)
+		if err != nil {
+			log.Fatalf("Failed to open policy storage: %v", err)
+		}
+		defer storage.Close()
+		opts.Policies = storage
+	}
+
+	result, err := services.RestoreBackup(context.Background(), data, opts)
+	if err != nil {
+		log.Fatalf("Restore failed: %v", err)
+	}
+	printJSON(result)
+}
+
+func printJSON(value interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
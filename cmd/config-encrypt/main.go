@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	// Registers the aws-kms and vault-transit key providers
+	_ "github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// config-encrypt reads a secret from stdin and prints it as an enc:v1 value
+// using the master key configured by CONFIG_MASTER_KEY or CONFIG_MASTER_KEY_FILE,
+// or the KMS key selected with CONFIG_KEY_PROVIDER
+func main() {
+	provider, err := config.KeyProviderFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load master key: %v", err)
+	}
+	if provider == nil {
+		log.Fatalf("CONFIG_MASTER_KEY, CONFIG_MASTER_KEY_FILE or CONFIG_KEY_PROVIDER must be set")
+	}
+
+	plaintext, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && plaintext == "" {
+		log.Fatalf("Failed to read value from stdin: %v", err)
+	}
+	plaintext = strings.TrimRight(plaintext, "\r\n")
+
+	keyID := os.Getenv("CONFIG_MASTER_KEY_ID")
+	if keyID == "" {
+		keyID = "default"
+	}
+
+	value, err := config.EncryptValue(provider, keyID, plaintext)
+	if err != nil {
+		log.Fatalf("Failed to encrypt value: %v", err)
+	}
+	fmt.Println(value)
+}
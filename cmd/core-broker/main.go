@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/pavilion-trust/core-broker/internal/server"
+	"github.com/pavilion-trust/core-broker/internal/services"
 	"github.com/pavilion-trust/core-broker/internal/config"
 )
 
@@ -20,9 +21,67 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// Reject configuration that the crypto profile does not allow
+	if err := services.ValidateCryptoConfig(cfg); err != nil {
+		log.Fatalf("Invalid crypto configuration: %v", err)
+	}
+	log.Printf("Using crypto profile %s", cfg.CryptoProfile)
+
+	// Refuse to start on a DP registry that does not load, rather than route
+	// every claim to a DP it was not meant for
+	if _, err := services.LoadDPRegistry(cfg); err != nil {
+		log.Fatalf("Invalid DP registry: %v", err)
+	}
+
 	// Create HTTP server
 	srv := server.New(cfg)
 
+	// Pick up rotated secrets without a restart
+	cfg.Secrets.Start(cfg.SecretsRefreshInterval)
+
+	// Reload timeouts, retries, rate limits and authorization rules when the
+	// runtime configuration file changes or on SIGHUP
+	cfg.Runtime.Start(cfg.RuntimeConfigPollInterval)
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			change, err := cfg.Runtime.Reload(config.ReloadSourceSignal)
+			if err != nil {
+				log.Printf("Runtime configuration not reloaded: %v", err)
+				continue
+			}
+			log.Printf("Runtime configuration reloaded; changed: %v", change.Changed)
+		}
+	}()
+
+	// Log cryptographic self-test results; traffic is refused if any failed
+	report := srv.SelfTestReport()
+	for _, result := range report.Results {
+		if result.Passed {
+			log.Printf("Crypto self-test %s passed (%s)", result.Name, result.Duration)
+		} else {
+			log.Printf("Crypto self-test %s FAILED: %s", result.Name, result.Error)
+		}
+	}
+	if !report.Passed {
+		log.Printf("Cryptographic self-tests failed; refusing traffic until restarted with working primitives")
+	}
+
+	// Check the system clock before serving; drift beyond the skew tolerance
+	// makes valid tokens and proofs fail validation
+	if timeSync := srv.TimeSync(); timeSync.Enabled() {
+		clock := timeSync.Check(context.Background())
+		log.Printf("System clock %s (offset %.0fms from trusted time sources)", clock.Status, clock.OffsetMs)
+		timeSync.Start()
+	}
+
+	// Roll stored verifications up into the reporting tables
+	srv.Reporting().Start()
+
+	// Deliver audit entries and webhook notifications committed to the outbox
+	srv.Outbox().Start()
+
 	// Start server in a goroutine
 	go func() {
 		log.Printf("Starting Core Broker server on port %s", cfg.Port)
@@ -31,11 +90,32 @@ func main() {
 		}
 	}()
 
+	// Serve operational controls on the admin port
+	if admin := srv.Admin(); admin != nil {
+		go func() {
+			log.Printf("Starting admin API on port %s", cfg.AdminPort)
+			if err := admin.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to start admin API: %v", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal to gracefully shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	// Finish in-flight work while the load balancer moves traffic away
+	log.Printf("Draining server (timeout %s)...", cfg.DrainTimeout)
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), cfg.DrainTimeout)
+	status := srv.Drain(drainCtx)
+	drainCancel()
+	if status.TimedOut {
+		log.Printf("Drain timed out with %d requests in flight and pending work %v", status.InFlight, status.Pending)
+	} else {
+		log.Println("Drain complete")
+	}
+
 	log.Println("Shutting down server...")
 
 	// Create context with timeout for graceful shutdown
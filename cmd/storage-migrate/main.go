@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// storage-migrate manages the schema of the SQL storage backend selected by
+// STORAGE_BACKEND and STORAGE_URL. status lists each migration and whether
+// it has been applied; up applies the pending ones in order. The broker
+// applies them itself on startup unless STORAGE_AUTO_MIGRATE is false.
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "status":
+		status(os.Args[2:])
+	case "up":
+		up(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s status|up\n", os.Args[0])
+	os.Exit(2)
+}
+
+func open() *services.SQLStorage {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	store, err := services.OpenSQLStorage(cfg.StorageBackend, cfg.StorageURL)
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	return store
+}
+
+func status(args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	flags.Parse(args)
+
+	store := open()
+	defer store.Close()
+	applied, err := store.AppliedMigrations(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to read migrations: %v", err)
+	}
+	for _, migration := range services.StorageMigrations() {
+		state := "pending"
+		if appliedAt, done := applied[migration.Version]; done {
+			state = "applied " + appliedAt.UTC().Format("2006-01-02T15:04:05Z")
+		}
+		fmt.Printf("%04d_%s\t%s\n", migration.Version, migration.Name, state)
+	}
+}
+
+func up(args []string) {
+	flags := flag.NewFlagSet("up", flag.ExitOnError)
+	flags.Parse(args)
+
+	store := open()
+	defer store.Close()
+	applied, err := store.Migrate(context.Background())
+	for _, migration := range applied {
+		fmt.Printf("Applied %04d_%s\n", migration.Version, migration.Name)
+	}
+	if err != nil {
+		store.Close()
+		log.Fatalf("Migration failed: %v", err)
+	}
+	if len(applied) == 0 {
+		fmt.Println("Storage is up to date")
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// test-vectors prints the deterministic test vectors for identifier and
+// privacy hashes, response hashes, commitments and SD-JWT disclosures. The
+// published copy is testdata/test-vectors.json; regenerate it with
+// -out testdata/test-vectors.json when an algorithm version is added.
+func main() {
+	out := flag.String("out", "", "file to write the vectors to (default stdout)")
+	flag.Parse()
+
+	vectors, err := services.GenerateTestVectors()
+	if err != nil {
+		log.Fatalf("Failed to generate test vectors: %v", err)
+	}
+	data, err := json.MarshalIndent(vectors, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode test vectors: %v", err)
+	}
+	data = append(data, '\n')
+
+	if *out == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		log.Fatalf("Failed to write test vectors: %v", err)
+	}
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -21,12 +23,53 @@ type Config struct {
 	Port string
 	Env  string
 
+	// DrainTimeout bounds a drain for a deploy: how long in-flight
+	// verifications and jobs get to finish before shutdown
+	DrainTimeout time.Duration
+
+	// Kubernetes probes: each dependency check is bounded by
+	// HealthProbeTimeout and a report is reused for HealthProbeCacheTTL
+	HealthProbeTimeout  time.Duration
+	HealthProbeCacheTTL time.Duration
+
+	// Admin API Configuration. The admin listener serves operational
+	// controls on its own port; ADMIN_API_TOKEN is a break-glass bearer token
+	// accepted alongside Keycloak admins.
+	AdminPort     string
+	AdminAPIToken string
+
 	// API Gateway Configuration
 	APIGatewayPort string
 	TLSCertFile    string
 	TLSKeyFile     string
 	CoreBrokerURL  string
 
+	// Gateway Request Bodies: bodies over GatewayMaxBodyBytes (or an
+	// endpoint's path=bytes override in GatewayBodyLimits) and JSON nested
+	// deeper than GatewayMaxJSONDepth are refused; endpoints listed in
+	// GatewayStrictJSON also refuse unknown fields
+	GatewayMaxBodyBytes int64
+	GatewayMaxJSONDepth int
+	GatewayBodyLimits   []string
+	GatewayStrictJSON   []string
+
+	// GraphQL API: queries nested deeper than GraphQLMaxDepth or costing
+	// more than GraphQLMaxComplexity are refused before they run
+	GraphQLMaxDepth      int
+	GraphQLMaxComplexity int
+
+	// Request Deadlines: a request's X-Request-Timeout, or
+	// RequestTimeoutDefault when it sends none, becomes its deadline, capped
+	// at RequestTimeoutMax; zero leaves requests without one
+	RequestTimeoutDefault time.Duration
+	RequestTimeoutMax     time.Duration
+
+	// Inbound TLS Configuration
+	TLSMinVersion       string
+	TLSMaxVersion       string
+	TLSCipherSuites     []string
+	TLSCurvePreferences []string
+
 	// Authentication
 	KeycloakURL   string
 	KeycloakRealm string
@@ -39,8 +82,69 @@ type Config struct {
 	// DP Communication
 	DPConnectorURL   string
 	DPConnectorToken string
+	DPRegistryFile   string
 	DPTimeout        time.Duration
 
+	// DP Hedging: claim types ("*" for all) whose verifications are also sent
+	// to a second DP when the first is slower than the latency percentile
+	DPHedgeClaims     []string
+	DPHedgePercentile float64
+	DPHedgeDelay      time.Duration
+	// DP Consensus: claim types requiring K of N DPs to agree
+	// (claim_type=K/N, "*" for all) and how the agreeing DPs' confidence
+	// is combined (mean or min)
+	DPConsensusClaims     []string
+	DPConsensusConfidence string
+	// DPComparisonsFile starts comparisons between incumbent and candidate
+	// DPs on a sample of live verifications at startup
+	DPComparisonsFile string
+	// Bulkheads bound concurrent DP calls per DP and concurrent
+	// verifications per tenant; a call waits up to BulkheadMaxWait for a slot
+	DPMaxConcurrent     int
+	TenantMaxConcurrent int
+	BulkheadMaxWait     time.Duration
+	// Adaptive concurrency limits per DP host: the limit grows while the
+	// host's latency holds, and is cut when it passes the tolerance times
+	// its baseline or a call times out; a max of 0 disables them
+	DPAdaptiveLimitInitial   int
+	DPAdaptiveLimitMin       int
+	DPAdaptiveLimitMax       int
+	DPAdaptiveLimitTolerance float64
+	DPAdaptiveLimitBackoff   float64
+	// DP responses larger than DPMaxResponseBytes, as sent or once
+	// decompressed, are refused; providers may set their own limit
+	DPMaxResponseBytes int
+
+	// DP Circuit Breaker Notifications: transitions are posted to these
+	// webhooks, signed with the secret when one is set
+	DPBreakerWebhookURLs   []string
+	DPBreakerWebhookSecret string
+
+	// DPoP: the key proving possession of sender-constrained DP tokens is
+	// kept in DPoPKeyFile wrapped by the KeyProvider, or only in memory
+	DPoPKeyFile string
+	DPoPKeyID   string
+
+	// Attestation signing key, kept in AttestationKeyFile wrapped by the
+	// KeyProvider, or generated at each start
+	AttestationKeyFile string
+	AttestationKeyID   string
+
+	// ResponseTemplatesFile registers custom response templates, and the
+	// RPs using them, at startup
+	ResponseTemplatesFile string
+
+	// CustomMetricsFile registers operator-defined metrics at startup
+	CustomMetricsFile string
+
+	// ValidationSchemasFile publishes versioned claim type schemas, which DP
+	// responses are validated against, at startup
+	ValidationSchemasFile string
+
+	// TransformationPipelinesFile registers named transformation pipelines,
+	// which DP providers map their responses with, at startup
+	TransformationPipelinesFile string
+
 	// Cache Configuration
 	RedisURL string
 	CacheTTL time.Duration
@@ -50,6 +154,34 @@ type Config struct {
 	DatabaseURL    string
 	AuditDBURL     string
 	AuditBatchSize int
+	// Storage keeps audit entries, pull-job state and consent revocations in
+	// StorageBackend (memory, postgres or sqlite) at StorageURL; with
+	// StorageAutoMigrate pending schema migrations are applied at startup
+	StorageBackend     string
+	StorageURL         string
+	StorageAutoMigrate bool
+
+	// Outbox: audit entries and webhook notifications are committed to
+	// storage with the state they describe and delivered every
+	// OutboxPollInterval, OutboxBatchSize at a time; failed audit writes are
+	// retried backing off exponentially from OutboxRetryBackoff
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+	OutboxRetryBackoff time.Duration
+	// Audit field encryption: sensitive metadata fields are envelope
+	// encrypted under AuditEncryptionKeyID before entries are written, when
+	// a KeyProvider is configured
+	AuditEncryption      bool
+	AuditEncryptedFields []string
+	AuditEncryptionKeyID string
+	// AuditRetention is how long audit entries are kept; older entries are
+	// left out of backups and restores
+	AuditRetention time.Duration
+	// Reporting: verification rollups are flushed every
+	// ReportingFlushInterval to tables in ReportingDatabaseURL, kept apart
+	// from the transactional database
+	ReportingDatabaseURL   string
+	ReportingFlushInterval time.Duration
 
 	// Privacy/PPRL Configuration
 	BloomFilterSize              int
@@ -57,6 +189,124 @@ type Config struct {
 	BloomFilterFalsePositiveRate float64
 	PhoneticEncodingEnabled      bool
 
+	// Identifier Quality Configuration
+	IdentifierMinEntropyBits    float64
+	IdentifierDisposableDomains []string
+	IdentifierPolicyFile        string
+
+	// Duplicate Subject Detection Configuration
+	DuplicateDetectionRPs    []string
+	DuplicateDetectionWindow time.Duration
+
+	// Evidence Weighting Configuration
+	EvidenceWeightingFile string
+
+	// Claim Type Lifecycle Configuration
+	ClaimLifecycleFile string
+
+	// OpenID4VP Configuration
+	OpenID4VPClientID           string
+	OpenID4VPResponseURI        string
+	OpenID4VPRequestTTL         time.Duration
+	OpenID4VPTrustedIssuersFile string
+	OpenID4VPTrustedDIDs        []string
+
+	// DID Resolution Configuration
+	DIDResolutionTimeout time.Duration
+	DIDCacheTTL          time.Duration
+
+	// Credential Status Configuration
+	StatusListCacheTTL     time.Duration
+	StatusListMaxStaleness time.Duration
+	StatusListTimeout      time.Duration
+
+	// Broker Manifest Configuration: the public URL the manifest's endpoints
+	// are under, the policy profiles the broker declares and how long a
+	// signed manifest is valid
+	BrokerPublicURL      string
+	BrokerPolicyProfiles []string
+	BrokerManifestTTL    time.Duration
+
+	// Federation Configuration: the peer brokers in other trust networks
+	// verifications are forwarded to, and how long a forwarded one may take
+	FederationFile    string
+	FederationTimeout time.Duration
+
+	// Verification Orchestration: stage=duration timeouts of verification
+	// stages
+	VerificationStageTimeouts []string
+
+	// Export Configuration
+	ExportSigningKey string
+	ExportURLTTL     time.Duration
+	ExportBaseURL    string
+	// AnonymizationFile holds the anonymization profiles analytics and
+	// report exports run through, per export type
+	AnonymizationFile string
+
+	// Batch Verification Configuration
+	BatchMaxItems    int
+	BatchConcurrency int
+
+	// RP Notification Configuration: each webhook delivery is attempted up
+	// to NotificationMaxAttempts times, backing off exponentially from
+	// NotificationRetryBackoff
+	NotificationMaxAttempts  int
+	NotificationRetryBackoff time.Duration
+	NotificationTimeout      time.Duration
+
+	// Tenant Metrics Configuration
+	TenantMetricsTopN       int
+	TenantMetricsMaxTracked int
+	TenantLabelMaxLength    int
+
+	// Crypto Configuration
+	CryptoProfile string
+	HashSalt      string
+
+	// Identifier Hashing Configuration
+	HashScheme           string
+	HashKey              string
+	HashKeyVersion       string
+	HashPreviousKeys     []string
+	HashLowEntropyFields []string
+	HashArgon2Time       int
+	HashArgon2MemoryKB   int
+
+	// Clock Configuration
+	ClockSkewTolerance time.Duration
+	TimeSources        []string
+	TimeCheckInterval  time.Duration
+	TimeDriftWarning   time.Duration
+
+	// Zero-Knowledge Proof Configuration
+	ZKPBackend      string
+	ZKPScheme       string
+	ZKPSetupDir     string
+	ZKPRangeBackend string
+	ZKPProofTimeout time.Duration
+	ZKPProofTTL     time.Duration
+	ZKPProofMaxTTL  time.Duration
+	ZKPCircuitsFile string
+
+	// KeyProvider decrypts enc:v1 values at load time; nil when no master
+	// key is configured
+	KeyProvider KeyProvider
+
+	// Secrets resolves secret: references through SECRETS_PROVIDER and
+	// refreshes rotated secrets every SecretsRefreshInterval
+	Secrets                *SecretStore
+	SecretsRefreshInterval time.Duration
+
+	// Runtime holds the settings reloaded from RuntimeConfigFile on SIGHUP,
+	// or when the file changes, without a restart
+	Runtime                   *RuntimeConfig
+	RuntimeConfigFile         string
+	RuntimeConfigPollInterval time.Duration
+
+	// Sandbox Configuration
+	SandboxEnabled bool
+
 	// Logging
 	LogLevel string
 }
@@ -68,12 +318,41 @@ func Load() (*Config, error) {
 		Port: getEnv("PAVILION_PORT", "8080"),
 		Env:  getEnv("PAVILION_ENV", "development"),
 
+		DrainTimeout: getDurationEnv("DRAIN_TIMEOUT", 30*time.Second),
+
+		HealthProbeTimeout:  getDurationEnv("HEALTH_PROBE_TIMEOUT", 2*time.Second),
+		HealthProbeCacheTTL: getDurationEnv("HEALTH_PROBE_CACHE_TTL", 5*time.Second),
+
+		// Admin API Configuration
+		AdminPort:     getEnv("ADMIN_PORT", "9090"),
+		AdminAPIToken: getEnv("ADMIN_API_TOKEN", ""),
+
 		// API Gateway Configuration
 		APIGatewayPort: getEnv("API_GATEWAY_PORT", "8443"),
 		TLSCertFile:    getEnv("TLS_CERT_FILE", "certs/server.crt"),
 		TLSKeyFile:     getEnv("TLS_KEY_FILE", "certs/server.key"),
 		CoreBrokerURL:  getEnv("CORE_BROKER_URL", "http://core-broker:8080"),
 
+		// Gateway Request Bodies
+		GatewayMaxBodyBytes: int64(getIntEnv("GATEWAY_MAX_BODY_BYTES", 1<<20)),
+		GatewayMaxJSONDepth: getIntEnv("GATEWAY_MAX_JSON_DEPTH", 32),
+		GatewayBodyLimits:   getSliceEnv("GATEWAY_BODY_LIMITS"),
+		GatewayStrictJSON:   getSliceEnv("GATEWAY_STRICT_JSON"),
+
+		// GraphQL API
+		GraphQLMaxDepth:      getIntEnv("GRAPHQL_MAX_DEPTH", 6),
+		GraphQLMaxComplexity: getIntEnv("GRAPHQL_MAX_COMPLEXITY", 1000),
+
+		// Request Deadlines
+		RequestTimeoutDefault: getDurationEnv("REQUEST_TIMEOUT_DEFAULT", 0),
+		RequestTimeoutMax:     getDurationEnv("REQUEST_TIMEOUT_MAX", 60*time.Second),
+
+		// Inbound TLS Configuration
+		TLSMinVersion:       getEnv("TLS_MIN_VERSION", "1.2"),
+		TLSMaxVersion:       getEnv("TLS_MAX_VERSION", ""),
+		TLSCipherSuites:     getSliceEnv("TLS_CIPHER_SUITES"),
+		TLSCurvePreferences: getSliceEnv("TLS_CURVE_PREFERENCES"),
+
 		// Authentication
 		KeycloakURL:   getEnv("KEYCLOAK_URL", "http://keycloak:8080"),
 		KeycloakRealm: getEnv("KEYCLOAK_REALM", "pavilion"),
@@ -86,8 +365,58 @@ func Load() (*Config, error) {
 		// DP Communication
 		DPConnectorURL:   getEnv("DP_CONNECTOR_URL", "http://dp-connector:8080"),
 		DPConnectorToken: getEnv("DP_CONNECTOR_TOKEN", ""), // Default empty string
+		DPRegistryFile:   getEnv("DP_REGISTRY_FILE", ""),
 		DPTimeout:        getDurationEnv("DP_TIMEOUT", 30*time.Second),
 
+		// DP Hedging
+		DPHedgeClaims:     getSliceEnv("DP_HEDGE_CLAIMS"),
+		DPHedgePercentile: getFloat64Env("DP_HEDGE_PERCENTILE", 0.95),
+		DPHedgeDelay:      getDurationEnv("DP_HEDGE_DELAY", 250*time.Millisecond),
+
+		// DP Consensus
+		DPConsensusClaims:     getSliceEnv("DP_CONSENSUS_CLAIMS"),
+		DPConsensusConfidence: getEnv("DP_CONSENSUS_CONFIDENCE", "mean"),
+
+		// DP Comparisons
+		DPComparisonsFile: getEnv("DP_COMPARISONS_FILE", ""),
+
+		DPMaxConcurrent:     getIntEnv("DP_MAX_CONCURRENT", 100),
+		TenantMaxConcurrent: getIntEnv("TENANT_MAX_CONCURRENT", 50),
+		BulkheadMaxWait:     getDurationEnv("BULKHEAD_MAX_WAIT", 100*time.Millisecond),
+
+		// DP Adaptive Concurrency Limits
+		DPAdaptiveLimitInitial:   getIntEnv("DP_ADAPTIVE_LIMIT_INITIAL", 20),
+		DPAdaptiveLimitMin:       getIntEnv("DP_ADAPTIVE_LIMIT_MIN", 2),
+		DPAdaptiveLimitMax:       getIntEnv("DP_ADAPTIVE_LIMIT_MAX", 100),
+		DPAdaptiveLimitTolerance: getFloat64Env("DP_ADAPTIVE_LIMIT_TOLERANCE", 2),
+		DPAdaptiveLimitBackoff:   getFloat64Env("DP_ADAPTIVE_LIMIT_BACKOFF", 0.9),
+
+		DPMaxResponseBytes: getIntEnv("DP_MAX_RESPONSE_BYTES", 10<<20),
+
+		// DP Circuit Breaker Notifications
+		DPBreakerWebhookURLs:   getSliceEnv("DP_BREAKER_WEBHOOK_URLS"),
+		DPBreakerWebhookSecret: getEnv("DP_BREAKER_WEBHOOK_SECRET", ""),
+
+		// DPoP
+		DPoPKeyFile: getEnv("DPOP_KEY_FILE", ""),
+		DPoPKeyID:   getEnv("DPOP_KEY_ID", getEnv("CONFIG_MASTER_KEY_ID", "default")),
+
+		// Attestation signing key
+		AttestationKeyFile: getEnv("ATTESTATION_KEY_FILE", ""),
+		AttestationKeyID:   getEnv("ATTESTATION_KEY_ID", getEnv("CONFIG_MASTER_KEY_ID", "default")),
+
+		// Response templates
+		ResponseTemplatesFile: getEnv("RESPONSE_TEMPLATES_FILE", ""),
+
+		// Custom metrics
+		CustomMetricsFile: getEnv("CUSTOM_METRICS_FILE", ""),
+
+		// Validation schemas
+		ValidationSchemasFile: getEnv("VALIDATION_SCHEMAS_FILE", ""),
+
+		// Transformation pipelines
+		TransformationPipelinesFile: getEnv("TRANSFORMATION_PIPELINES_FILE", ""),
+
 		// Cache Configuration
 		RedisURL: getEnv("REDIS_URL", "redis://redis:6379"),
 		CacheTTL: getDurationEnv("CACHE_TTL", 90*24*time.Hour), // 90 days
@@ -104,16 +433,164 @@ func Load() (*Config, error) {
 		AuditDBURL:     getEnv("AUDIT_DB_URL", "postgres://audit:5432"),
 		AuditBatchSize: getIntEnv("AUDIT_BATCH_SIZE", 100),
 
+		// Storage
+		StorageBackend:     getEnv("STORAGE_BACKEND", "memory"),
+		StorageURL:         getEnv("STORAGE_URL", ""),
+		StorageAutoMigrate: getBoolEnv("STORAGE_AUTO_MIGRATE", true),
+
+		// Outbox
+		OutboxPollInterval: getDurationEnv("OUTBOX_POLL_INTERVAL", time.Second),
+		OutboxBatchSize:    getIntEnv("OUTBOX_BATCH_SIZE", 100),
+		OutboxRetryBackoff: getDurationEnv("OUTBOX_RETRY_BACKOFF", time.Second),
+
+		// Audit field encryption
+		AuditEncryption:      getBoolEnv("AUDIT_ENCRYPTION_ENABLED", true),
+		AuditEncryptedFields: getSliceEnv("AUDIT_ENCRYPTED_FIELDS"),
+		AuditEncryptionKeyID: getEnv("AUDIT_ENCRYPTION_KEY_ID", getEnv("CONFIG_MASTER_KEY_ID", "default")),
+		AuditRetention:       getDurationEnv("AUDIT_RETENTION", 365*24*time.Hour),
+
+		// Reporting
+		ReportingDatabaseURL:   getEnv("REPORTING_DATABASE_URL", ""),
+		ReportingFlushInterval: getDurationEnv("REPORTING_FLUSH_INTERVAL", time.Minute),
+
 		// Privacy/PPRL Configuration
 		BloomFilterSize:              getIntEnv("BLOOM_FILTER_SIZE", 1000000),
 		BloomFilterHashCount:         getIntEnv("BLOOM_FILTER_HASH_COUNT", 7),
 		BloomFilterFalsePositiveRate: getFloat64Env("BLOOM_FILTER_FALSE_POSITIVE_RATE", 0.01),
 		PhoneticEncodingEnabled:      getBoolEnv("PHONETIC_ENCODING_ENABLED", false),
 
+		// Identifier Quality Configuration
+		IdentifierMinEntropyBits:    getFloat64Env("IDENTIFIER_MIN_ENTROPY_BITS", 8),
+		IdentifierDisposableDomains: getSliceEnv("IDENTIFIER_DISPOSABLE_EMAIL_DOMAINS"),
+		IdentifierPolicyFile:        getEnv("IDENTIFIER_POLICY_FILE", ""),
+
+		// Duplicate Subject Detection Configuration
+		DuplicateDetectionRPs:    getSliceEnv("DUPLICATE_DETECTION_RPS"),
+		DuplicateDetectionWindow: getDurationEnv("DUPLICATE_DETECTION_WINDOW", 30*24*time.Hour),
+
+		// Evidence Weighting Configuration
+		EvidenceWeightingFile: getEnv("EVIDENCE_WEIGHTING_FILE", ""),
+
+		// Claim Type Lifecycle Configuration
+		ClaimLifecycleFile: getEnv("CLAIM_LIFECYCLE_FILE", ""),
+
+		// OpenID4VP Configuration
+		OpenID4VPClientID:           getEnv("OPENID4VP_CLIENT_ID", ""),
+		OpenID4VPResponseURI:        getEnv("OPENID4VP_RESPONSE_URI", ""),
+		OpenID4VPRequestTTL:         getDurationEnv("OPENID4VP_REQUEST_TTL", 5*time.Minute),
+		OpenID4VPTrustedIssuersFile: getEnv("OPENID4VP_TRUSTED_ISSUERS_FILE", ""),
+		OpenID4VPTrustedDIDs:        getSliceEnv("OPENID4VP_TRUSTED_DIDS"),
+
+		// DID Resolution Configuration
+		DIDResolutionTimeout: getDurationEnv("DID_RESOLUTION_TIMEOUT", 5*time.Second),
+		DIDCacheTTL:          getDurationEnv("DID_CACHE_TTL", time.Hour),
+
+		// Credential Status Configuration
+		StatusListCacheTTL:     getDurationEnv("STATUS_LIST_CACHE_TTL", 5*time.Minute),
+		StatusListMaxStaleness: getDurationEnv("STATUS_LIST_MAX_STALENESS", time.Hour),
+		StatusListTimeout:      getDurationEnv("STATUS_LIST_TIMEOUT", 5*time.Second),
+
+		// Broker Manifest Configuration
+		BrokerPublicURL:      getEnv("BROKER_PUBLIC_URL", ""),
+		BrokerPolicyProfiles: getSliceEnv("BROKER_POLICY_PROFILES"),
+		BrokerManifestTTL:    getDurationEnv("BROKER_MANIFEST_TTL", 24*time.Hour),
+
+		// Federation Configuration
+		FederationFile:    getEnv("FEDERATION_FILE", ""),
+		FederationTimeout: getDurationEnv("FEDERATION_TIMEOUT", 10*time.Second),
+
+		// Verification Orchestration
+		VerificationStageTimeouts: getSliceEnv("VERIFICATION_STAGE_TIMEOUTS"),
+
+		// Export Configuration
+		ExportSigningKey:  getEnv("EXPORT_SIGNING_KEY", ""),
+		ExportURLTTL:      getDurationEnv("EXPORT_URL_TTL", 24*time.Hour),
+		ExportBaseURL:     getEnv("EXPORT_BASE_URL", ""),
+		AnonymizationFile: getEnv("ANONYMIZATION_FILE", ""),
+
+		// Batch Verification Configuration
+		BatchMaxItems:    getIntEnv("BATCH_MAX_ITEMS", 500),
+		BatchConcurrency: getIntEnv("BATCH_CONCURRENCY", 4),
+
+		// RP Notification Configuration
+		NotificationMaxAttempts:  getIntEnv("NOTIFICATION_MAX_ATTEMPTS", 5),
+		NotificationRetryBackoff: getDurationEnv("NOTIFICATION_RETRY_BACKOFF", time.Second),
+		NotificationTimeout:      getDurationEnv("NOTIFICATION_TIMEOUT", 5*time.Second),
+
+		// Tenant Metrics Configuration
+		TenantMetricsTopN:       getIntEnv("TENANT_METRICS_TOP_N", 20),
+		TenantMetricsMaxTracked: getIntEnv("TENANT_METRICS_MAX_TRACKED", 10000),
+		TenantLabelMaxLength:    getIntEnv("TENANT_LABEL_MAX_LENGTH", 32),
+
+		// Crypto Configuration
+		CryptoProfile: getEnv("CRYPTO_PROFILE", DefaultCryptoProfile),
+		HashSalt:      getEnv("HASH_SALT", ""),
+
+		// Identifier Hashing Configuration
+		HashScheme:           getEnv("HASH_SCHEME", "sha256"),
+		HashKey:              getEnv("HASH_KEY", ""),
+		HashKeyVersion:       getEnv("HASH_KEY_VERSION", "1"),
+		HashPreviousKeys:     getSliceEnv("HASH_PREVIOUS_KEYS"),
+		HashLowEntropyFields: getSliceEnv("HASH_LOW_ENTROPY_FIELDS"),
+		HashArgon2Time:       getIntEnv("HASH_ARGON2_TIME", 2),
+		HashArgon2MemoryKB:   getIntEnv("HASH_ARGON2_MEMORY_KB", 19456),
+
+		// Clock Configuration
+		ClockSkewTolerance: getDurationEnv("CLOCK_SKEW_TOLERANCE", 60*time.Second),
+		TimeSources:        getSliceEnv("TIME_SOURCES"),
+		TimeCheckInterval:  getDurationEnv("TIME_CHECK_INTERVAL", 15*time.Minute),
+		TimeDriftWarning:   getDurationEnv("TIME_DRIFT_WARNING", 0),
+
+		// Zero-Knowledge Proof Configuration
+		ZKPBackend:      getEnv("ZKP_BACKEND", "hash"),
+		ZKPScheme:       getEnv("ZKP_SCHEME", "groth16"),
+		ZKPSetupDir:     getEnv("ZKP_SETUP_DIR", ""),
+		ZKPRangeBackend: getEnv("ZKP_RANGE_BACKEND", ""),
+		ZKPProofTimeout: getDurationEnv("ZKP_PROOF_TIMEOUT", 5*time.Second),
+		ZKPProofTTL:     getDurationEnv("ZKP_PROOF_TTL", 24*time.Hour),
+		ZKPProofMaxTTL:  getDurationEnv("ZKP_PROOF_MAX_TTL", 30*24*time.Hour),
+		ZKPCircuitsFile: getEnv("ZKP_CIRCUITS_FILE", ""),
+
+		// Sandbox Configuration
+		SandboxEnabled: getBoolEnv("SANDBOX_ENABLED", false),
+
 		// Logging
 		LogLevel: getEnv("LOG_LEVEL", "info"),
 	}
 
+	// Decrypt enc:v1 values so secrets can be committed to config management
+	keyProvider, err := KeyProviderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	cfg.KeyProvider = keyProvider
+	if err := DecryptFields(keyProvider, cfg); err != nil {
+		return nil, fmt.Errorf("failed to decrypt configuration: %w", err)
+	}
+
+	// Secret references stay in the configuration and are resolved, and
+	// watched for rotation, where they are used
+	secretsProvider, err := SecretsProviderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Secrets = NewSecretStore(secretsProvider)
+	cfg.SecretsRefreshInterval = getDurationEnv("SECRETS_REFRESH_INTERVAL", 5*time.Minute)
+
+	// Timeouts, retries, rate limits and authorization rules can be reloaded
+	cfg.RuntimeConfigFile = getEnv("RUNTIME_CONFIG_FILE", "")
+	cfg.RuntimeConfigPollInterval = getDurationEnv("RUNTIME_CONFIG_POLL_INTERVAL", 10*time.Second)
+	base := RuntimeSettings{DPTimeout: Duration(cfg.DPTimeout), OPATimeout: Duration(cfg.OPATimeout)}
+	if requestsPerMinute := getIntEnv("RATE_LIMIT_REQUESTS_PER_MINUTE", 0); requestsPerMinute > 0 {
+		base.RateLimit = &RateLimitSettings{RequestsPerMinute: requestsPerMinute, Burst: getIntEnv("RATE_LIMIT_BURST", 0)}
+	}
+	cfg.Runtime = NewRuntimeConfig(cfg.RuntimeConfigFile, base)
+	if cfg.RuntimeConfigFile != "" {
+		if _, err := cfg.Runtime.Reload(ReloadSourceStartup); err != nil {
+			return nil, err
+		}
+	}
+
 	return cfg, nil
 }
 
@@ -155,6 +632,17 @@ func getFloat64Env(key string, defaultValue float64) float64 {
 	return defaultValue
 }
 
+// getSliceEnv gets a comma-separated environment variable as a list
+func getSliceEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // getBoolEnv gets a boolean environment variable or returns a default value
 func getBoolEnv(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
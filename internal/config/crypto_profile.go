@@ -0,0 +1,9 @@
+//go:build !fips
+
+package config
+
+// DefaultCryptoProfile is the crypto profile used when CRYPTO_PROFILE is unset
+const DefaultCryptoProfile = "standard"
+
+// FIPSBuild reports whether the binary was built with the fips build tag
+const FIPSBuild = false
@@ -0,0 +1,10 @@
+//go:build fips
+
+package config
+
+// DefaultCryptoProfile is the crypto profile used when CRYPTO_PROFILE is unset.
+// FIPS builds default to, and only accept, the FIPS profile.
+const DefaultCryptoProfile = "fips"
+
+// FIPSBuild reports whether the binary was built with the fips build tag
+const FIPSBuild = true
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// EncryptedValuePrefix marks a configuration value encrypted with EncryptValue.
+// The full format is enc:v1:<key-id>:<wrapped data key>:<nonce+ciphertext>,
+// with both binary parts base64url-encoded.
+const EncryptedValuePrefix = "enc:v1:"
+
+// dataKeySize is the AES-256 data key size used for envelope encryption
+const dataKeySize = 32
+
+// KeyProvider wraps and unwraps the data keys protecting encrypted values.
+// The key encryption key never leaves the provider.
+type KeyProvider interface {
+	WrapKey(keyID string, dataKey []byte) ([]byte, error)
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// LocalKeyProvider wraps data keys with AES-256-GCM master keys held in memory
+type LocalKeyProvider struct {
+	keys map[string][]byte
+}
+
+// NewLocalKeyProvider creates a provider from 32-byte master keys by key ID
+func NewLocalKeyProvider(keys map[string][]byte) (*LocalKeyProvider, error) {
+	for keyID, key := range keys {
+		if len(key) != dataKeySize {
+			return nil, fmt.Errorf("master key %s must be %d bytes, got %d", keyID, dataKeySize, len(key))
+		}
+	}
+	return &LocalKeyProvider{keys: keys}, nil
+}
+
+// WrapKey encrypts a data key under the named master key
+func (p *LocalKeyProvider) WrapKey(keyID string, dataKey []byte) ([]byte, error) {
+	key, exists := p.keys[keyID]
+	if !exists {
+		return nil, fmt.Errorf("unknown master key: %s", keyID)
+	}
+	return seal(key, dataKey, []byte(keyID))
+}
+
+// UnwrapKey decrypts a data key wrapped under the named master key
+func (p *LocalKeyProvider) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	key, exists := p.keys[keyID]
+	if !exists {
+		return nil, fmt.Errorf("unknown master key: %s", keyID)
+	}
+	return open(key, wrapped, []byte(keyID))
+}
+
+// KeyProviderFactory builds a key provider backed by an external KMS or HSM
+// from its own environment settings
+type KeyProviderFactory func() (KeyProvider, error)
+
+// keyProviderFactories holds the backends selectable with CONFIG_KEY_PROVIDER
+var keyProviderFactories = map[string]KeyProviderFactory{}
+
+// RegisterKeyProvider makes a key provider backend selectable by name with
+// CONFIG_KEY_PROVIDER. Backends register themselves at init time.
+func RegisterKeyProvider(name string, factory KeyProviderFactory) {
+	keyProviderFactories[name] = factory
+}
+
+// KeyProviderFromEnv returns the key provider selected by CONFIG_KEY_PROVIDER.
+// The default, "local", uses CONFIG_MASTER_KEY, or the file named by
+// CONFIG_MASTER_KEY_FILE, as the base64 master key with ID
+// CONFIG_MASTER_KEY_ID. Retired keys listed in CONFIG_MASTER_PREVIOUS_KEYS
+// can still unwrap. It returns nil when no master key is configured.
+func KeyProviderFromEnv() (KeyProvider, error) {
+	if name := getEnv("CONFIG_KEY_PROVIDER", "local"); name != "local" {
+		factory, exists := keyProviderFactories[name]
+		if !exists {
+			return nil, fmt.Errorf("unknown key provider: %s", name)
+		}
+		return factory()
+	}
+
+	encoded := os.Getenv("CONFIG_MASTER_KEY")
+	if path := os.Getenv("CONFIG_MASTER_KEY_FILE"); encoded == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master key file: %w", err)
+		}
+		encoded = strings.TrimSpace(string(data))
+	}
+	if encoded == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("master key must be base64: %w", err)
+	}
+	keys := map[string][]byte{}
+
+	// Retired master keys stay available for unwrapping while values
+	// encrypted under them are rotated to the current key
+	for _, entry := range getSliceEnv("CONFIG_MASTER_PREVIOUS_KEYS") {
+		keyID, encodedKey, found := strings.Cut(entry, "=")
+		if !found || keyID == "" {
+			return nil, fmt.Errorf("CONFIG_MASTER_PREVIOUS_KEYS entries must be key-id=base64-key")
+		}
+		previous, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, fmt.Errorf("previous master key %s must be base64: %w", keyID, err)
+		}
+		keys[keyID] = previous
+	}
+	keys[getEnv("CONFIG_MASTER_KEY_ID", "default")] = key
+	return NewLocalKeyProvider(keys)
+}
+
+// IsEncryptedValue reports whether a value carries the enc:v1 marker
+func IsEncryptedValue(value string) bool {
+	return strings.HasPrefix(value, EncryptedValuePrefix)
+}
+
+// EncryptedValueKeyID returns the ID of the master key an enc:v1 value's data
+// key is wrapped under
+func EncryptedValueKeyID(value string) (string, bool) {
+	if !IsEncryptedValue(value) {
+		return "", false
+	}
+	keyID, _, found := strings.Cut(strings.TrimPrefix(value, EncryptedValuePrefix), ":")
+	return keyID, found
+}
+
+// EncryptValue encrypts a value under a fresh data key wrapped by keyID
+func EncryptValue(provider KeyProvider, keyID, plaintext string) (string, error) {
+	if strings.Contains(keyID, ":") {
+		return "", fmt.Errorf("key ID must not contain ':'")
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrapped, err := provider.WrapKey(keyID, dataKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	ciphertext, err := seal(dataKey, []byte(plaintext), []byte(EncryptedValuePrefix+keyID))
+	if err != nil {
+		return "", err
+	}
+
+	return EncryptedValuePrefix + keyID + ":" +
+		base64.RawURLEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptValue returns plaintext for an enc:v1 value and leaves any other
+// value unchanged
+func DecryptValue(provider KeyProvider, value string) (string, error) {
+	if !IsEncryptedValue(value) {
+		return value, nil
+	}
+	if provider == nil {
+		return "", fmt.Errorf("encrypted value found but no key provider is configured (set CONFIG_MASTER_KEY)")
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, EncryptedValuePrefix), ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	keyID := parts[0]
+
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed wrapped data key")
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed ciphertext")
+	}
+
+	dataKey, err := provider.UnwrapKey(keyID, wrapped)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	plaintext, err := open(dataKey, ciphertext, []byte(EncryptedValuePrefix+keyID))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// DecryptFields decrypts every enc:v1 string, including strings in slices and
+// nested structs or slices of structs, in the struct pointed to by target. Errors name the field
+// but never include the value.
+func DecryptFields(provider KeyProvider, target interface{}) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("decrypt target must be a pointer to a struct")
+	}
+	return decryptStruct(provider, value.Elem(), "")
+}
+
+func decryptStruct(provider KeyProvider, value reflect.Value, prefix string) error {
+	valueType := value.Type()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		name := prefix + valueType.Field(i).Name
+
+		switch field.Kind() {
+		case reflect.String:
+			plaintext, err := DecryptValue(provider, field.String())
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			field.SetString(plaintext)
+		case reflect.Slice:
+			if field.Type().Elem().Kind() == reflect.Struct {
+				for j := 0; j < field.Len(); j++ {
+					if err := decryptStruct(provider, field.Index(j), fmt.Sprintf("%s[%d].", name, j)); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if field.Type().Elem().Kind() != reflect.String {
+				continue
+			}
+			for j := 0; j < field.Len(); j++ {
+				plaintext, err := DecryptValue(provider, field.Index(j).String())
+				if err != nil {
+					return fmt.Errorf("%s[%d]: %w", name, j, err)
+				}
+				field.Index(j).SetString(plaintext)
+			}
+		case reflect.Struct:
+			if err := decryptStruct(provider, field, name+"."); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// seal encrypts with AES-GCM, prefixing the random nonce
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts a nonce-prefixed AES-GCM ciphertext
+func open(key, ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: authentication failed")
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// redactedValue replaces secrets in the configuration shown to operators
+const redactedValue = "[REDACTED]"
+
+// sensitiveFields hold secrets or key material
+var sensitiveFields = map[string]bool{
+	"AdminAPIToken":          true,
+	"DPConnectorToken":       true,
+	"DPBreakerWebhookSecret": true,
+	"ExportSigningKey":       true,
+	"HashSalt":               true,
+	"HashKey":                true,
+	"HashPreviousKeys":       true,
+	"Password":               true,
+}
+
+// Redacted returns the configuration keyed by field name for display, with
+// secrets replaced and passwords removed from URLs. secret: references are
+// shown since they name a secret rather than hold it.
+func (c *Config) Redacted() map[string]interface{} {
+	return redactStruct(reflect.ValueOf(c).Elem())
+}
+
+func redactStruct(value reflect.Value) map[string]interface{} {
+	fields := make(map[string]interface{}, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		field, name := value.Field(i), value.Type().Field(i).Name
+		switch {
+		case field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface:
+			// Key providers and stores are not settings
+		case field.Kind() == reflect.Struct:
+			fields[name] = redactStruct(field)
+		case sensitiveFields[name]:
+			switch {
+			case field.Kind() == reflect.String && IsSecretRef(field.String()):
+				fields[name] = field.String()
+			case field.IsZero():
+				fields[name] = ""
+			default:
+				fields[name] = redactedValue
+			}
+		case field.Type() == reflect.TypeOf(time.Duration(0)):
+			fields[name] = time.Duration(field.Int()).String()
+		case field.Kind() == reflect.String && strings.HasSuffix(name, "URL"):
+			fields[name] = redactURL(field.String())
+		default:
+			fields[name] = field.Interface()
+		}
+	}
+	return fields
+}
+
+// redactURL removes the password from a URL; values that do not parse may
+// be connection strings and are redacted whole
+func redactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return redactedValue
+	}
+	if parsed.Scheme == "" && strings.Contains(raw, "password=") {
+		return redactedValue
+	}
+	if query := parsed.Query(); query.Has("password") {
+		query.Set("password", "xxxxx")
+		parsed.RawQuery = query.Encode()
+	}
+	return parsed.Redacted()
+}
@@ -0,0 +1,334 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sources of a runtime configuration reload
+const (
+	ReloadSourceStartup   = "startup"
+	ReloadSourceSignal    = "sighup"
+	ReloadSourceFileWatch = "file_watch"
+	ReloadSourceAdmin     = "admin_api"
+)
+
+// Duration is a time.Duration written as a string such as "30s" in JSON
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\"")
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// RetrySettings replace the built-in DP retry policy
+type RetrySettings struct {
+	MaxRetries        int      `json:"max_retries"`
+	BaseDelay         Duration `json:"base_delay"`
+	MaxDelay          Duration `json:"max_delay"`
+	BackoffMultiplier float64  `json:"backoff_multiplier"`
+	Jitter            bool     `json:"jitter"`
+}
+
+// RateLimitSettings limit the requests each client may make through the
+// gateway. A zero RequestsPerMinute disables rate limiting.
+type RateLimitSettings struct {
+	RequestsPerMinute int `json:"requests_per_minute"`
+	// Burst is the most requests allowed at once; defaults to
+	// RequestsPerMinute
+	Burst int `json:"burst,omitempty"`
+}
+
+// BrownoutSettings make the gateway reject a share of its non-critical
+// traffic ahead of planned maintenance. The share rises linearly from zero to
+// Percent over Ramp, starting when the settings are loaded. Requests from
+// ExemptRPs and to paths under CriticalPaths are never rejected.
+type BrownoutSettings struct {
+	Percent       float64  `json:"percent"`
+	Ramp          Duration `json:"ramp,omitempty"`
+	ExemptRPs     []string `json:"exempt_rps,omitempty"`
+	CriticalPaths []string `json:"critical_paths,omitempty"`
+	// RetryAfter is sent to rejected clients; defaults to 60s
+	RetryAfter Duration `json:"retry_after,omitempty"`
+	// Message is the detail of the maintenance problem
+	Message string `json:"message,omitempty"`
+}
+
+// PolicyRule is an RP permission or DP access rule
+type PolicyRule struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	RPID        string `json:"rp_id,omitempty"`
+	ClaimType   string `json:"claim_type,omitempty"`
+	DPID        string `json:"dp_id,omitempty"`
+	Action      string `json:"action"`
+	Priority    int    `json:"priority,omitempty"`
+}
+
+// RuntimeSettings are the settings that can change without a restart. Unset
+// fields keep their values from the environment, or the built-in defaults.
+type RuntimeSettings struct {
+	DPTimeout  Duration           `json:"dp_timeout,omitempty"`
+	OPATimeout Duration           `json:"opa_timeout,omitempty"`
+	Retry      *RetrySettings     `json:"retry,omitempty"`
+	RateLimit  *RateLimitSettings `json:"rate_limit,omitempty"`
+	Brownout   *BrownoutSettings  `json:"brownout,omitempty"`
+	// Rules replace the built-in authorization rules when set
+	RPPermissionRules []PolicyRule `json:"rp_permission_rules,omitempty"`
+	DPAccessRules     []PolicyRule `json:"dp_access_rules,omitempty"`
+}
+
+// Validate checks the settings before they are swapped in
+func (s *RuntimeSettings) Validate() error {
+	if s.DPTimeout < 0 || s.OPATimeout < 0 {
+		return fmt.Errorf("timeouts must not be negative")
+	}
+	if retry := s.Retry; retry != nil {
+		if retry.MaxRetries < 0 || retry.MaxRetries > 10 {
+			return fmt.Errorf("retry: max_retries must be between 0 and 10")
+		}
+		if retry.BaseDelay <= 0 || retry.MaxDelay < retry.BaseDelay {
+			return fmt.Errorf("retry: base_delay must be positive and no more than max_delay")
+		}
+		if retry.BackoffMultiplier < 1 {
+			return fmt.Errorf("retry: backoff_multiplier must be at least 1")
+		}
+	}
+	if limit := s.RateLimit; limit != nil && (limit.RequestsPerMinute < 0 || limit.Burst < 0) {
+		return fmt.Errorf("rate_limit: requests_per_minute and burst must not be negative")
+	}
+	if brownout := s.Brownout; brownout != nil {
+		if brownout.Percent < 0 || brownout.Percent > 100 {
+			return fmt.Errorf("brownout: percent must be between 0 and 100")
+		}
+		if brownout.Ramp < 0 || brownout.RetryAfter < 0 {
+			return fmt.Errorf("brownout: ramp and retry_after must not be negative")
+		}
+	}
+	for name, rules := range map[string][]PolicyRule{"rp_permission_rules": s.RPPermissionRules, "dp_access_rules": s.DPAccessRules} {
+		seen := make(map[string]bool)
+		for _, rule := range rules {
+			if rule.ID == "" {
+				return fmt.Errorf("%s: every rule needs an id", name)
+			}
+			if seen[rule.ID] {
+				return fmt.Errorf("%s: duplicate rule id %s", name, rule.ID)
+			}
+			seen[rule.ID] = true
+			if rule.Action != "allow" && rule.Action != "deny" {
+				return fmt.Errorf("%s: rule %s action must be allow or deny", name, rule.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// RuntimeChange records a reload that changed the runtime settings
+type RuntimeChange struct {
+	Source    string
+	Changed   []string
+	Previous  *RuntimeSettings
+	Current   *RuntimeSettings
+	Timestamp time.Time
+}
+
+// RuntimeConfig holds the runtime settings, reloading them from
+// RUNTIME_CONFIG_FILE on SIGHUP or when the file changes. The file is a JSON
+// RuntimeSettings; settings that need a restart, such as ports and keys,
+// are rejected.
+type RuntimeConfig struct {
+	path string
+	base RuntimeSettings
+
+	mu       sync.RWMutex
+	current  *RuntimeSettings
+	modTime  time.Time
+	handlers []func(RuntimeChange)
+	once     sync.Once
+	stop     chan struct{}
+}
+
+// NewRuntimeConfig creates runtime settings starting from base, reloaded
+// from path when it is set
+func NewRuntimeConfig(path string, base RuntimeSettings) *RuntimeConfig {
+	current := base
+	return &RuntimeConfig{path: path, base: base, current: &current}
+}
+
+// Current returns the settings in effect; callers must not modify them
+func (r *RuntimeConfig) Current() *RuntimeSettings {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// OnChange registers a handler called after each reload that changes a
+// setting
+func (r *RuntimeConfig) OnChange(handler func(RuntimeChange)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, handler)
+}
+
+// Reload reads and validates the file, then swaps in the new settings. An
+// invalid file leaves the current settings in effect.
+func (r *RuntimeConfig) Reload(source string) (*RuntimeChange, error) {
+	if r == nil || r.path == "" {
+		return nil, fmt.Errorf("no runtime configuration file is set (RUNTIME_CONFIG_FILE)")
+	}
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runtime configuration: %w", err)
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runtime configuration: %w", err)
+	}
+
+	next := r.base
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&next); err != nil {
+		return nil, fmt.Errorf("invalid runtime configuration: %w", err)
+	}
+	if err := next.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid runtime configuration: %w", err)
+	}
+
+	r.mu.Lock()
+	r.modTime = info.ModTime()
+	change := &RuntimeChange{
+		Source:    source,
+		Changed:   changedSettings(r.current, &next),
+		Previous:  r.current,
+		Current:   &next,
+		Timestamp: time.Now(),
+	}
+	if len(change.Changed) > 0 {
+		r.current = &next
+	}
+	handlers := append(([]func(RuntimeChange))(nil), r.handlers...)
+	r.mu.Unlock()
+
+	if len(change.Changed) > 0 {
+		for _, handler := range handlers {
+			handler(*change)
+		}
+	}
+	return change, nil
+}
+
+// Start reloads the file whenever its modification time changes, checking
+// every interval until Stop is called
+func (r *RuntimeConfig) Start(interval time.Duration) {
+	if r == nil || r.path == "" || interval <= 0 {
+		return
+	}
+	r.once.Do(func() {
+		r.stop = make(chan struct{})
+		go r.run(interval, r.stop)
+	})
+}
+
+// Stop ends file watching
+func (r *RuntimeConfig) Stop() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Prevent watching from starting after stop
+	r.once.Do(func() {})
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+}
+
+func (r *RuntimeConfig) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(r.path)
+			if err != nil {
+				continue
+			}
+			r.mu.RLock()
+			modified := !info.ModTime().Equal(r.modTime)
+			r.mu.RUnlock()
+			if !modified {
+				continue
+			}
+			if _, err := r.Reload(ReloadSourceFileWatch); err != nil {
+				fmt.Printf("CONFIG WARNING: keeping the current runtime settings: %v\n", err)
+				// Do not retry the same broken file every interval
+				r.mu.Lock()
+				r.modTime = info.ModTime()
+				r.mu.Unlock()
+			}
+		}
+	}
+}
+
+// RuntimeSettings returns the runtime settings in effect, or those from the
+// environment when no runtime configuration is loaded
+func (c *Config) RuntimeSettings() *RuntimeSettings {
+	if c.Runtime == nil {
+		return &RuntimeSettings{DPTimeout: Duration(c.DPTimeout), OPATimeout: Duration(c.OPATimeout)}
+	}
+	return c.Runtime.Current()
+}
+
+// changedSettings returns the JSON names of the settings that differ
+func changedSettings(previous, next *RuntimeSettings) []string {
+	before, after := settingsFields(previous), settingsFields(next)
+	var changed []string
+	for name, value := range after {
+		if !bytes.Equal(before[name], value) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, exists := after[name]; !exists {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func settingsFields(settings *RuntimeSettings) map[string]json.RawMessage {
+	fields := make(map[string]json.RawMessage)
+	data, _ := json.Marshal(settings)
+	json.Unmarshal(data, &fields)
+	return fields
+}
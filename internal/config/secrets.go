@@ -0,0 +1,309 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretRefPrefix marks a value fetched from the secrets provider, e.g.
+// DP_CONNECTOR_TOKEN=secret:dp-connector-token. The reference is kept in the
+// configuration and resolved where the secret is used, so rotated secrets
+// are picked up without a restart.
+const SecretRefPrefix = "secret:"
+
+// SecretsProvider fetches secrets by name
+type SecretsProvider interface {
+	GetSecret(name string) (string, error)
+}
+
+// IsSecretRef reports whether a value is a secret: reference
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, SecretRefPrefix)
+}
+
+// FileSecretsProvider reads secrets from files in a directory, as mounted by
+// Docker and Kubernetes secrets. Mounted files are updated in place when the
+// secret rotates.
+type FileSecretsProvider struct {
+	dir string
+}
+
+// NewFileSecretsProvider creates a provider reading from dir
+func NewFileSecretsProvider(dir string) *FileSecretsProvider {
+	return &FileSecretsProvider{dir: dir}
+}
+
+// GetSecret returns the trimmed contents of the named file
+func (p *FileSecretsProvider) GetSecret(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || name == ".." {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(p.dir, name))
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %w", name, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// VaultSecretsProvider reads secrets from a HashiCorp Vault KV version 2
+// engine. Names are keys of the default path, or path#key for another one.
+type VaultSecretsProvider struct {
+	address   string
+	token     string
+	namespace string
+	mount     string
+	path      string
+	client    *http.Client
+}
+
+// NewVaultSecretsProvider creates a provider for the KV engine at mount
+func NewVaultSecretsProvider(address, token, namespace, mount, path string) *VaultSecretsProvider {
+	return &VaultSecretsProvider{
+		address:   strings.TrimRight(address, "/"),
+		token:     token,
+		namespace: namespace,
+		mount:     strings.Trim(mount, "/"),
+		path:      strings.Trim(path, "/"),
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GetSecret returns a key of the latest version of a KV secret
+func (p *VaultSecretsProvider) GetSecret(name string) (string, error) {
+	path, key, found := strings.Cut(name, "#")
+	if !found {
+		path, key = p.path, name
+	}
+	endpoint := fmt.Sprintf("%s/v1/%s/data/%s", p.address, p.mount, (&url.URL{Path: strings.Trim(path, "/")}).EscapedPath())
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s from vault: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned status %d for secret %s", resp.StatusCode, name)
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("malformed vault response for secret %s: %w", name, err)
+	}
+	value, ok := secret.Data.Data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("secret %s not found in vault", name)
+	}
+	return value, nil
+}
+
+// SecretsProviderFromEnv returns the provider selected by SECRETS_PROVIDER:
+// "file" reads SECRETS_DIR, "vault" reads the KV engine at
+// SECRETS_VAULT_MOUNT under SECRETS_VAULT_PATH. It returns nil when none is
+// configured.
+func SecretsProviderFromEnv() (SecretsProvider, error) {
+	switch name := getEnv("SECRETS_PROVIDER", ""); name {
+	case "":
+		return nil, nil
+	case "file":
+		return NewFileSecretsProvider(getEnv("SECRETS_DIR", "/run/secrets")), nil
+	case "vault":
+		address := getEnv("VAULT_ADDR", "")
+		if address == "" {
+			return nil, fmt.Errorf("vault secrets provider requires VAULT_ADDR")
+		}
+		token := getEnv("VAULT_TOKEN", "")
+		if path := getEnv("VAULT_TOKEN_FILE", ""); token == "" && path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read Vault token file: %w", err)
+			}
+			token = strings.TrimSpace(string(data))
+		}
+		if token == "" {
+			return nil, fmt.Errorf("vault secrets provider requires VAULT_TOKEN or VAULT_TOKEN_FILE")
+		}
+		return NewVaultSecretsProvider(address, token, getEnv("VAULT_NAMESPACE", ""),
+			getEnv("SECRETS_VAULT_MOUNT", "secret"), getEnv("SECRETS_VAULT_PATH", "pavilion")), nil
+	default:
+		return nil, fmt.Errorf("unknown secrets provider: %s", name)
+	}
+}
+
+// secretWatch re-fetches a secret and applies it when it changes
+type secretWatch struct {
+	fetch func() (string, error)
+	apply func(string)
+	value string
+}
+
+// SecretStore resolves secret: references and keeps watched secrets fresh.
+// A nil store resolves plain values only.
+type SecretStore struct {
+	provider SecretsProvider
+
+	mu      sync.Mutex
+	watches map[string]*secretWatch
+	once    sync.Once
+	stop    chan struct{}
+}
+
+// NewSecretStore creates a store over a provider, which may be nil
+func NewSecretStore(provider SecretsProvider) *SecretStore {
+	return &SecretStore{
+		provider: provider,
+		watches:  make(map[string]*secretWatch),
+	}
+}
+
+// Resolve returns the secret a secret: reference names, or any other value
+// unchanged. With a key and apply function, apply is called with the new
+// value whenever the secret rotates; a later watch with the same key
+// replaces the earlier one.
+func (s *SecretStore) Resolve(value, key string, apply func(string)) (string, error) {
+	if !IsSecretRef(value) {
+		return value, nil
+	}
+	if s == nil || s.provider == nil {
+		return "", fmt.Errorf("secret reference found but no secrets provider is configured (set SECRETS_PROVIDER)")
+	}
+
+	name := strings.TrimPrefix(value, SecretRefPrefix)
+	fetch := func() (string, error) { return s.provider.GetSecret(name) }
+	secret, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	if apply != nil {
+		s.watch(key, fetch, apply, secret)
+	}
+	return secret, nil
+}
+
+// Watch fetches a value now and applies it again whenever it changes, for
+// secrets that are not references, such as mounted key files
+func (s *SecretStore) Watch(key string, fetch func() (string, error), apply func(string)) error {
+	value, err := fetch()
+	if err != nil {
+		return err
+	}
+	apply(value)
+	if s != nil {
+		s.watch(key, fetch, apply, value)
+	}
+	return nil
+}
+
+func (s *SecretStore) watch(key string, fetch func() (string, error), apply func(string), value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watches[key] = &secretWatch{fetch: fetch, apply: apply, value: value}
+}
+
+// Refresh re-fetches every watched secret, applying those that changed. A
+// secret that cannot be fetched keeps its current value.
+func (s *SecretStore) Refresh() (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+	s.mu.Lock()
+	watches := make(map[string]*secretWatch, len(s.watches))
+	for key, watch := range s.watches {
+		watches[key] = watch
+	}
+	s.mu.Unlock()
+
+	changed := 0
+	var errs []string
+	for key, watch := range watches {
+		value, err := watch.fetch()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		if value == watch.value {
+			continue
+		}
+
+		s.mu.Lock()
+		// A newer watch for the key supersedes this one
+		current := s.watches[key] == watch
+		if current {
+			watch.value = value
+		}
+		s.mu.Unlock()
+		if current {
+			watch.apply(value)
+			changed++
+		}
+	}
+	if len(errs) > 0 {
+		return changed, fmt.Errorf("failed to refresh secrets: %s", strings.Join(errs, "; "))
+	}
+	return changed, nil
+}
+
+// Start refreshes watched secrets every interval until Stop is called
+func (s *SecretStore) Start(interval time.Duration) {
+	if s == nil || interval <= 0 {
+		return
+	}
+	s.once.Do(func() {
+		s.stop = make(chan struct{})
+		go s.run(interval, s.stop)
+	})
+}
+
+// Stop ends periodic refreshes
+func (s *SecretStore) Stop() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Prevent refreshes from starting after stop
+	s.once.Do(func() {})
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+func (s *SecretStore) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			changed, err := s.Refresh()
+			if err != nil {
+				fmt.Printf("SECRETS WARNING: %v\n", err)
+			}
+			if changed > 0 {
+				fmt.Printf("Secrets: %d rotated secrets applied\n", changed)
+			}
+		}
+	}
+}
@@ -0,0 +1,745 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// AdminHandler serves the operational controls on the admin listener. Every
+// action is audited with the operator who took it.
+type AdminHandler struct {
+	config          *config.Config
+	dpService       *services.DPConnectorService
+	cacheService    *services.CacheService
+	auditService    *services.AuditService
+	drainer         *services.Drainer
+	reporting       *services.ReportingService
+	formatter       *services.ResponseFormatterService
+	customMetrics   *services.CustomMetricsService
+	supportBundles  *services.SupportBundleStore
+	claimSchemas    *services.ClaimSchemaRegistry
+	registryChanges *services.RegistryChangeLog
+	credentialHooks *services.CredentialHookService
+	federation      *services.FederationService
+	orchestrator    *services.VerificationOrchestrator
+}
+
+// NewAdminHandler creates a new admin handler over the services serving
+// verifications
+func NewAdminHandler(cfg *config.Config, dpService *services.DPConnectorService, cacheService *services.CacheService, auditService *services.AuditService) *AdminHandler {
+	return &AdminHandler{
+		config:       cfg,
+		dpService:    dpService,
+		cacheService: cacheService,
+		auditService: auditService,
+	}
+}
+
+// SetDrainer enables the drain controls
+func (h *AdminHandler) SetDrainer(drainer *services.Drainer) {
+	h.drainer = drainer
+}
+
+// SetReportingService enables the verification reports
+func (h *AdminHandler) SetReportingService(reporting *services.ReportingService) {
+	h.reporting = reporting
+}
+
+// SetResponseFormatter enables the response template controls
+func (h *AdminHandler) SetResponseFormatter(formatter *services.ResponseFormatterService) {
+	h.formatter = formatter
+}
+
+// SetCustomMetrics enables the custom metric controls
+func (h *AdminHandler) SetCustomMetrics(customMetrics *services.CustomMetricsService) {
+	h.customMetrics = customMetrics
+}
+
+// SetSupportBundles enables the support bundles of failed verifications
+func (h *AdminHandler) SetSupportBundles(supportBundles *services.SupportBundleStore) {
+	h.supportBundles = supportBundles
+}
+
+// SetClaimSchemas enables updating claim schemas
+func (h *AdminHandler) SetClaimSchemas(claimSchemas *services.ClaimSchemaRegistry) {
+	h.claimSchemas = claimSchemas
+}
+
+// SetRegistryChanges records DP registry and claim schema changes in a
+// change history
+func (h *AdminHandler) SetRegistryChanges(registryChanges *services.RegistryChangeLog) {
+	h.registryChanges = registryChanges
+}
+
+// SetCredentialHooks enables inspecting credential post-processing hooks
+func (h *AdminHandler) SetCredentialHooks(credentialHooks *services.CredentialHookService) {
+	h.credentialHooks = credentialHooks
+}
+
+// SetFederation enables inspecting the federation peer brokers
+func (h *AdminHandler) SetFederation(federation *services.FederationService) {
+	h.federation = federation
+}
+
+// SetOrchestrator enables inspecting the verification stages
+func (h *AdminHandler) SetOrchestrator(orchestrator *services.VerificationOrchestrator) {
+	h.orchestrator = orchestrator
+}
+
+// HandleGetBreakers handles GET /admin/v1/breakers
+func (h *AdminHandler) HandleGetBreakers(w http.ResponseWriter, r *http.Request) {
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"breakers": h.dpService.GetBreakerStats(),
+	})
+}
+
+// HandleResetBreaker handles POST /admin/v1/breakers/{dp_id}/reset
+func (h *AdminHandler) HandleResetBreaker(w http.ResponseWriter, r *http.Request) {
+	dpID := mux.Vars(r)["dp_id"]
+	if !h.dpService.ResetBreaker(dpID) {
+		writeError(w, "DP_NOT_FOUND", fmt.Sprintf("Unknown DP: %s", dpID), http.StatusNotFound)
+		return
+	}
+
+	h.audit(r, "breaker_reset", map[string]interface{}{"dp_id": dpID})
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"dp_id":           dpID,
+		"circuit_breaker": h.dpService.GetBreakerStats()[dpID],
+	})
+}
+
+// HandleResetBreakers handles POST /admin/v1/breakers/reset, closing every
+// DP's breaker
+func (h *AdminHandler) HandleResetBreakers(w http.ResponseWriter, r *http.Request) {
+	reset := h.dpService.ResetBreakers()
+
+	h.audit(r, "breaker_reset", map[string]interface{}{"dp_ids": reset})
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{"reset": reset})
+}
+
+// HandleDrainConnections handles POST /admin/v1/connections/drain
+func (h *AdminHandler) HandleDrainConnections(w http.ResponseWriter, r *http.Request) {
+	drained := h.dpService.DrainConnections()
+
+	h.audit(r, "connections_drained", map[string]interface{}{"clients": drained})
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{"clients_drained": drained})
+}
+
+// HandleFlushCache handles POST /admin/v1/cache/flush, dropping cached
+// verification results
+func (h *AdminHandler) HandleFlushCache(w http.ResponseWriter, r *http.Request) {
+	deleted, err := h.cacheService.FlushVerificationCache(r.Context())
+	if err != nil {
+		h.audit(r, "cache_flush_failed", map[string]interface{}{"deleted": deleted, "error": err.Error()})
+		writeError(w, "CACHE_UNAVAILABLE", fmt.Sprintf("Cache flush failed after %d entries: %v", deleted, err), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.audit(r, "cache_flushed", map[string]interface{}{"deleted": deleted})
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{"deleted": deleted})
+}
+
+// registryChangeRequest carries the reason every registry change needs
+type registryChangeRequest struct {
+	Reason string                    `json:"reason"`
+	Schema *services.ClaimTypeSchema `json:"schema,omitempty"`
+}
+
+// decodeRegistryChange reads a registry change request, writing the error
+// response when it is invalid or has no reason
+func decodeRegistryChange(w http.ResponseWriter, r *http.Request) (*registryChangeRequest, bool) {
+	var req registryChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "INVALID_REQUEST", fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		writeError(w, "CHANGE_REASON_REQUIRED", "Registry changes require a reason", http.StatusBadRequest)
+		return nil, false
+	}
+	return &req, true
+}
+
+// recordRegistryChanges adds changes to the change history, if one is kept
+func (h *AdminHandler) recordRegistryChanges(r *http.Request, reason string, changes ...*services.RegistryChange) {
+	if h.registryChanges == nil || len(changes) == 0 {
+		return
+	}
+	h.registryChanges.Record(reason, adminOperator(r), changes...)
+}
+
+// HandleReloadRegistry handles POST /admin/v1/dp-registry/reload. The body
+// gives the reason for the change, {"reason": "..."}.
+func (h *AdminHandler) HandleReloadRegistry(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRegistryChange(w, r)
+	if !ok {
+		return
+	}
+
+	reload, err := h.dpService.ReloadRegistry()
+	if err != nil {
+		h.audit(r, "dp_registry_reload_failed", map[string]interface{}{"error": err.Error(), "reason": req.Reason})
+		writeError(w, "INVALID_DP_REGISTRY", fmt.Sprintf("DP registry not reloaded: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+	h.recordRegistryChanges(r, req.Reason, reload.Changes...)
+
+	h.audit(r, "dp_registry_reloaded", map[string]interface{}{
+		"added":   reload.Added,
+		"removed": reload.Removed,
+		"updated": reload.Updated,
+		"reason":  req.Reason,
+	})
+	writeAdminResponse(w, http.StatusOK, reload)
+}
+
+// HandleUpdateClaimSchema handles PUT /admin/v1/claim-schemas/{claim_type},
+// adding or replacing a claim type's schema. The body is
+// {"reason": "...", "schema": {...}}.
+func (h *AdminHandler) HandleUpdateClaimSchema(w http.ResponseWriter, r *http.Request) {
+	if h.claimSchemas == nil {
+		writeError(w, "CLAIM_SCHEMAS_UNAVAILABLE", "Claim schema updates are not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	req, ok := decodeRegistryChange(w, r)
+	if !ok {
+		return
+	}
+	if req.Schema == nil {
+		writeError(w, "INVALID_REQUEST", "schema is required", http.StatusBadRequest)
+		return
+	}
+	req.Schema.ClaimType = mux.Vars(r)["claim_type"]
+
+	previous, err := h.claimSchemas.Update(req.Schema)
+	if err != nil {
+		writeError(w, "INVALID_CLAIM_SCHEMA", err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	change := services.DiffClaimSchemas(previous, req.Schema)
+	if change != nil {
+		h.recordRegistryChanges(r, req.Reason, change)
+	}
+
+	h.audit(r, "claim_schema_updated", map[string]interface{}{
+		"claim_type": req.Schema.ClaimType,
+		"changed":    change != nil,
+		"reason":     req.Reason,
+	})
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"schema": req.Schema,
+		"change": change,
+	})
+}
+
+// HandleListRegistryChanges handles GET /admin/v1/registry-changes, listing
+// DP registry and claim schema changes newest first, optionally filtered by
+// kind, subject and claim_type
+func (h *AdminHandler) HandleListRegistryChanges(w http.ResponseWriter, r *http.Request) {
+	if h.registryChanges == nil {
+		writeError(w, "REGISTRY_CHANGES_UNAVAILABLE", "Registry change history is not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	query := r.URL.Query()
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"changes": h.registryChanges.List(services.RegistryChangeQuery{
+			Kind:      query.Get("kind"),
+			Subject:   query.Get("subject"),
+			ClaimType: query.Get("claim_type"),
+		}),
+	})
+}
+
+// HandleGetRegistryChange handles GET /admin/v1/registry-changes/{id}
+func (h *AdminHandler) HandleGetRegistryChange(w http.ResponseWriter, r *http.Request) {
+	if h.registryChanges == nil {
+		writeError(w, "REGISTRY_CHANGES_UNAVAILABLE", "Registry change history is not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	change, exists := h.registryChanges.Get(id)
+	if !exists {
+		writeError(w, "REGISTRY_CHANGE_NOT_FOUND", fmt.Sprintf("Unknown registry change: %s", id), http.StatusNotFound)
+		return
+	}
+	writeAdminResponse(w, http.StatusOK, change)
+}
+
+// HandleGetCredentialHooks handles GET /admin/v1/credential-hooks, listing
+// the registered hooks and their recent runs, optionally filtered by
+// credential_id and status
+func (h *AdminHandler) HandleGetCredentialHooks(w http.ResponseWriter, r *http.Request) {
+	if h.credentialHooks == nil {
+		writeError(w, "CREDENTIAL_HOOKS_UNAVAILABLE", "Credential hooks are not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	query := r.URL.Query()
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"hooks":   h.credentialHooks.Hooks(),
+		"pending": h.credentialHooks.Pending(),
+		"runs":    h.credentialHooks.Runs(query.Get("credential_id"), query.Get("status")),
+	})
+}
+
+// HandleListFederationPeers handles GET /admin/v1/federation/peers, listing
+// the peer brokers with their manifest state and forwarding counts
+func (h *AdminHandler) HandleListFederationPeers(w http.ResponseWriter, r *http.Request) {
+	if h.federation == nil {
+		writeError(w, "FEDERATION_UNAVAILABLE", "Federation is not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"peers": h.federation.Peers(),
+	})
+}
+
+// HandleListVerificationStages handles GET /admin/v1/verification/stages,
+// listing the verification stages in the order they run
+func (h *AdminHandler) HandleListVerificationStages(w http.ResponseWriter, r *http.Request) {
+	if h.orchestrator == nil {
+		writeError(w, "ORCHESTRATION_UNAVAILABLE", "Verification orchestration is not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	stages := make([]map[string]interface{}, 0)
+	for _, stage := range h.orchestrator.Stages() {
+		entry := map[string]interface{}{"name": stage.Name}
+		if stage.Timeout > 0 {
+			entry["timeout"] = stage.Timeout.String()
+		}
+		stages = append(stages, entry)
+	}
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"stages": stages,
+	})
+}
+
+// HandleStartDrain handles POST /admin/v1/drain. The drain runs in the
+// background for up to DRAIN_TIMEOUT; its progress is reported by
+// GET /admin/v1/drain.
+func (h *AdminHandler) HandleStartDrain(w http.ResponseWriter, r *http.Request) {
+	if h.drainer == nil {
+		writeError(w, "DRAIN_UNAVAILABLE", "Drain is not supported by this server", http.StatusNotImplemented)
+		return
+	}
+
+	if h.drainer.Start(h.config.DrainTimeout) {
+		h.audit(r, "drain_started", map[string]interface{}{"timeout": h.config.DrainTimeout.String()})
+	}
+	writeAdminResponse(w, http.StatusAccepted, h.drainer.Status())
+}
+
+// HandleGetDrain handles GET /admin/v1/drain
+func (h *AdminHandler) HandleGetDrain(w http.ResponseWriter, r *http.Request) {
+	if h.drainer == nil {
+		writeError(w, "DRAIN_UNAVAILABLE", "Drain is not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	writeAdminResponse(w, http.StatusOK, h.drainer.Status())
+}
+
+// HandleGetVerificationReport handles GET /admin/v1/reports/verifications,
+// returning the daily rollups between the optional from and to dates
+func (h *AdminHandler) HandleGetVerificationReport(w http.ResponseWriter, r *http.Request) {
+	if h.reporting == nil {
+		writeError(w, "REPORTING_UNAVAILABLE", "Reporting is not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	query := r.URL.Query()
+	for _, param := range []string{"from", "to"} {
+		if value := query.Get(param); value != "" {
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				writeError(w, "INVALID_REQUEST", fmt.Sprintf("%s must be a YYYY-MM-DD date", param), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	rows, anonymization, err := h.reporting.AnonymizedRows(query.Get("from"), query.Get("to"))
+	if err != nil {
+		writeError(w, "ANONYMIZATION_FAILED", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"rows":   rows,
+		"status": h.reporting.GetReportingStats(),
+	}
+	if anonymization != nil {
+		response["anonymization"] = anonymization
+	}
+	writeAdminResponse(w, http.StatusOK, response)
+}
+
+// HandleListResponseTemplates handles GET /admin/v1/response-templates
+func (h *AdminHandler) HandleListResponseTemplates(w http.ResponseWriter, r *http.Request) {
+	if h.formatter == nil {
+		writeError(w, "TEMPLATES_UNAVAILABLE", "Response templates are not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"templates": h.formatter.ResponseTemplates(),
+	})
+}
+
+// HandlePutResponseTemplate handles PUT /admin/v1/response-templates/{name},
+// registering or replacing a custom template and assigning it to its RPs
+func (h *AdminHandler) HandlePutResponseTemplate(w http.ResponseWriter, r *http.Request) {
+	if h.formatter == nil {
+		writeError(w, "TEMPLATES_UNAVAILABLE", "Response templates are not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	var template services.ResponseTemplate
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		writeError(w, "INVALID_REQUEST", fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	template.TemplateName = mux.Vars(r)["name"]
+
+	registered, err := h.formatter.RegisterTemplate(&template)
+	if err != nil {
+		writeError(w, "INVALID_TEMPLATE", err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	h.audit(r, "response_template_registered", map[string]interface{}{
+		"template": registered.TemplateName,
+		"rp_ids":   registered.RPIDs,
+	})
+	writeAdminResponse(w, http.StatusOK, registered)
+}
+
+// HandleDeleteResponseTemplate handles DELETE
+// /admin/v1/response-templates/{name}; the template's RPs go back to the
+// built-in template
+func (h *AdminHandler) HandleDeleteResponseTemplate(w http.ResponseWriter, r *http.Request) {
+	if h.formatter == nil {
+		writeError(w, "TEMPLATES_UNAVAILABLE", "Response templates are not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	name := mux.Vars(r)["name"]
+	template, err := h.formatter.GetResponseTemplate(name)
+	if err != nil {
+		writeError(w, "TEMPLATE_NOT_FOUND", fmt.Sprintf("Unknown response template: %s", name), http.StatusNotFound)
+		return
+	}
+	if err := h.formatter.UnregisterTemplate(name); err != nil {
+		writeError(w, "INVALID_TEMPLATE", err.Error(), http.StatusConflict)
+		return
+	}
+	h.audit(r, "response_template_removed", map[string]interface{}{
+		"template": name,
+		"rp_ids":   template.RPIDs,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetConfig handles GET /admin/v1/config, showing the configuration
+// with secrets redacted and the runtime settings in effect
+func (h *AdminHandler) HandleGetConfig(w http.ResponseWriter, r *http.Request) {
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"config":         h.config.Redacted(),
+		"runtime":        h.config.RuntimeSettings(),
+		"crypto_profile": services.ActiveCryptoProfile(h.config).Name,
+	})
+}
+
+// HandleReloadConfig handles POST /admin/v1/config/reload, rereading
+// RUNTIME_CONFIG_FILE as SIGHUP does
+func (h *AdminHandler) HandleReloadConfig(w http.ResponseWriter, r *http.Request) {
+	change, err := h.config.Runtime.Reload(config.ReloadSourceAdmin)
+	if err != nil {
+		h.audit(r, "config_reload_failed", map[string]interface{}{"error": err.Error()})
+		writeError(w, "INVALID_RUNTIME_CONFIG", err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Applied changes are also audited as CONFIG_RELOAD entries
+	h.audit(r, "config_reloaded", map[string]interface{}{"changed": change.Changed})
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"changed": change.Changed,
+		"runtime": change.Current,
+	})
+}
+
+// audit records an admin action with the operator who took it
+func (h *AdminHandler) audit(r *http.Request, action string, metadata map[string]interface{}) {
+	h.auditService.LogAdminAction(r.Context(), action, adminOperator(r), metadata)
+}
+
+// adminOperator returns the subject of the operator making a request
+func adminOperator(r *http.Request) string {
+	if userInfo, ok := r.Context().Value("user").(*services.UserInfo); ok {
+		return userInfo.Subject
+	}
+	return ""
+}
+
+// writeAdminResponse writes an uncached JSON response
+func writeAdminResponse(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
+
+// HandleListCustomMetrics handles GET /admin/v1/custom-metrics
+func (h *AdminHandler) HandleListCustomMetrics(w http.ResponseWriter, r *http.Request) {
+	if h.customMetrics == nil {
+		writeError(w, "CUSTOM_METRICS_UNAVAILABLE", "Custom metrics are not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"metrics": h.customMetrics.Statuses(),
+	})
+}
+
+// HandlePutCustomMetric handles PUT /admin/v1/custom-metrics/{name},
+// registering or replacing a custom metric. A replaced metric starts from
+// zero.
+func (h *AdminHandler) HandlePutCustomMetric(w http.ResponseWriter, r *http.Request) {
+	if h.customMetrics == nil {
+		writeError(w, "CUSTOM_METRICS_UNAVAILABLE", "Custom metrics are not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	var definition services.CustomMetricDefinition
+	if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+		writeError(w, "INVALID_REQUEST", fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	definition.Name = mux.Vars(r)["name"]
+
+	registered, err := h.customMetrics.Register(&definition)
+	if err != nil {
+		writeError(w, "INVALID_CUSTOM_METRIC", err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	h.audit(r, "custom_metric_registered", map[string]interface{}{
+		"metric": registered.Name,
+		"hook":   registered.Hook,
+	})
+	writeAdminResponse(w, http.StatusOK, registered)
+}
+
+// HandleDeleteCustomMetric handles DELETE /admin/v1/custom-metrics/{name}
+func (h *AdminHandler) HandleDeleteCustomMetric(w http.ResponseWriter, r *http.Request) {
+	if h.customMetrics == nil {
+		writeError(w, "CUSTOM_METRICS_UNAVAILABLE", "Custom metrics are not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if err := h.customMetrics.Unregister(name); err != nil {
+		writeError(w, "CUSTOM_METRIC_NOT_FOUND", fmt.Sprintf("Unknown custom metric: %s", name), http.StatusNotFound)
+		return
+	}
+	h.audit(r, "custom_metric_removed", map[string]interface{}{
+		"metric": name,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListDPComparisons handles GET /admin/v1/dp-comparisons, reporting on
+// the running comparisons between incumbent and candidate DPs
+func (h *AdminHandler) HandleListDPComparisons(w http.ResponseWriter, r *http.Request) {
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"comparisons": h.dpService.Comparisons().Reports(),
+	})
+}
+
+// HandleGetDPComparison handles GET /admin/v1/dp-comparisons/{claim_type}
+func (h *AdminHandler) HandleGetDPComparison(w http.ResponseWriter, r *http.Request) {
+	claimType := mux.Vars(r)["claim_type"]
+	report, exists := h.dpService.Comparisons().Report(claimType)
+	if !exists {
+		writeError(w, "DP_COMPARISON_NOT_FOUND", fmt.Sprintf("No DP comparison running for claim type: %s", claimType), http.StatusNotFound)
+		return
+	}
+	writeAdminResponse(w, http.StatusOK, report)
+}
+
+// HandlePutDPComparison handles PUT /admin/v1/dp-comparisons/{claim_type},
+// starting a comparison or replacing the running one. A replaced comparison
+// starts from zero.
+func (h *AdminHandler) HandlePutDPComparison(w http.ResponseWriter, r *http.Request) {
+	var comparison services.DPComparison
+	if err := json.NewDecoder(r.Body).Decode(&comparison); err != nil {
+		writeError(w, "INVALID_REQUEST", fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	comparison.ClaimType = mux.Vars(r)["claim_type"]
+
+	for _, dpID := range []string{comparison.Incumbent, comparison.Candidate} {
+		if _, exists := h.dpService.Registry().Get(dpID); dpID != "" && !exists {
+			writeError(w, "INVALID_DP_COMPARISON", fmt.Sprintf("Unknown DP: %s", dpID), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	if err := h.dpService.Comparisons().Start(&comparison); err != nil {
+		writeError(w, "INVALID_DP_COMPARISON", err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	h.audit(r, "dp_comparison_started", map[string]interface{}{
+		"claim_type":  comparison.ClaimType,
+		"incumbent":   comparison.Incumbent,
+		"candidate":   comparison.Candidate,
+		"sample_rate": comparison.SampleRate,
+	})
+	writeAdminResponse(w, http.StatusOK, &comparison)
+}
+
+// HandleDeleteDPComparison handles DELETE
+// /admin/v1/dp-comparisons/{claim_type}, stopping the comparison and
+// returning its final report
+func (h *AdminHandler) HandleDeleteDPComparison(w http.ResponseWriter, r *http.Request) {
+	claimType := mux.Vars(r)["claim_type"]
+	report, err := h.dpService.Comparisons().Stop(claimType)
+	if err != nil {
+		writeError(w, "DP_COMPARISON_NOT_FOUND", fmt.Sprintf("No DP comparison running for claim type: %s", claimType), http.StatusNotFound)
+		return
+	}
+	h.audit(r, "dp_comparison_stopped", map[string]interface{}{
+		"claim_type":     claimType,
+		"samples":        report.Samples,
+		"agreement_rate": report.AgreementRate,
+	})
+	writeAdminResponse(w, http.StatusOK, report)
+}
+
+// HandleListValidationSchemas handles GET /admin/v1/schemas, listing the
+// latest version of each validation schema
+func (h *AdminHandler) HandleListValidationSchemas(w http.ResponseWriter, r *http.Request) {
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"schemas": h.dpService.ValidationSchemas().List(),
+	})
+}
+
+// HandleGetValidationSchema handles GET /admin/v1/schemas/{ref}, where ref is
+// name@version, or a name for the latest version
+func (h *AdminHandler) HandleGetValidationSchema(w http.ResponseWriter, r *http.Request) {
+	schema, err := h.dpService.ValidationSchemas().Get(mux.Vars(r)["ref"])
+	if err != nil {
+		writeError(w, "SCHEMA_NOT_FOUND", err.Error(), http.StatusNotFound)
+		return
+	}
+	writeAdminResponse(w, http.StatusOK, schema)
+}
+
+// HandleListValidationSchemaVersions handles GET
+// /admin/v1/schemas/{name}/versions
+func (h *AdminHandler) HandleListValidationSchemaVersions(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	versions := h.dpService.ValidationSchemas().Versions(name)
+	if len(versions) == 0 {
+		writeError(w, "SCHEMA_NOT_FOUND", fmt.Sprintf("Unknown schema: %s", name), http.StatusNotFound)
+		return
+	}
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{"versions": versions})
+}
+
+// HandlePublishValidationSchema handles POST
+// /admin/v1/schemas/{name}/versions, publishing the next version of a schema.
+// DP responses are validated against it from then on.
+func (h *AdminHandler) HandlePublishValidationSchema(w http.ResponseWriter, r *http.Request) {
+	var schema services.PublishedSchema
+	if err := json.NewDecoder(r.Body).Decode(&schema); err != nil {
+		writeError(w, "INVALID_REQUEST", fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	schema.Name = mux.Vars(r)["name"]
+
+	published, err := h.dpService.ValidationSchemas().Publish(&schema)
+	if err != nil {
+		writeError(w, "INVALID_SCHEMA", err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	h.audit(r, "validation_schema_published", map[string]interface{}{
+		"schema":     published.Ref(),
+		"claim_type": published.ClaimType,
+		"kind":       published.Kind,
+	})
+	writeAdminResponse(w, http.StatusCreated, published)
+}
+
+// HandleListTransformationPipelines handles GET
+// /admin/v1/transformation-pipelines
+func (h *AdminHandler) HandleListTransformationPipelines(w http.ResponseWriter, r *http.Request) {
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"pipelines": h.dpService.TransformationPipelines().List(),
+	})
+}
+
+// HandlePutTransformationPipeline handles PUT
+// /admin/v1/transformation-pipelines/{name}, registering or replacing a
+// named pipeline. Providers referring to it use the new rules from their
+// next response.
+func (h *AdminHandler) HandlePutTransformationPipeline(w http.ResponseWriter, r *http.Request) {
+	var pipeline services.TransformationPipeline
+	if err := json.NewDecoder(r.Body).Decode(&pipeline); err != nil {
+		writeError(w, "INVALID_REQUEST", fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	pipeline.Name = mux.Vars(r)["name"]
+
+	registered, err := h.dpService.TransformationPipelines().Register(&pipeline)
+	if err != nil {
+		writeError(w, "INVALID_PIPELINE", err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	h.audit(r, "transformation_pipeline_registered", map[string]interface{}{
+		"pipeline": registered.Name,
+		"version":  registered.Version,
+	})
+	writeAdminResponse(w, http.StatusOK, registered)
+}
+
+// HandleDeleteTransformationPipeline handles DELETE
+// /admin/v1/transformation-pipelines/{name}
+func (h *AdminHandler) HandleDeleteTransformationPipeline(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := h.dpService.TransformationPipelines().Delete(name); err != nil {
+		writeError(w, "PIPELINE_NOT_FOUND", fmt.Sprintf("Unknown transformation pipeline: %s", name), http.StatusNotFound)
+		return
+	}
+	h.audit(r, "transformation_pipeline_removed", map[string]interface{}{
+		"pipeline": name,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListSupportBundles handles GET /admin/v1/support-bundles, listing
+// the failed verifications a support bundle is kept for, newest first
+func (h *AdminHandler) HandleListSupportBundles(w http.ResponseWriter, r *http.Request) {
+	if h.supportBundles == nil {
+		writeError(w, "SUPPORT_BUNDLES_UNAVAILABLE", "Support bundles are not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	writeAdminResponse(w, http.StatusOK, map[string]interface{}{
+		"bundles": h.supportBundles.List(),
+	})
+}
+
+// HandleGetSupportBundle handles GET /admin/v1/support-bundles/{request_id},
+// downloading the redacted support bundle of a failed verification
+func (h *AdminHandler) HandleGetSupportBundle(w http.ResponseWriter, r *http.Request) {
+	if h.supportBundles == nil {
+		writeError(w, "SUPPORT_BUNDLES_UNAVAILABLE", "Support bundles are not supported by this server", http.StatusNotImplemented)
+		return
+	}
+	requestID := mux.Vars(r)["request_id"]
+	bundle, exists := h.supportBundles.Get(requestID)
+	if !exists {
+		writeError(w, "SUPPORT_BUNDLE_NOT_FOUND", fmt.Sprintf("No support bundle for request: %s", requestID), http.StatusNotFound)
+		return
+	}
+	h.audit(r, "support_bundle_downloaded", map[string]interface{}{
+		"request_id": requestID,
+		"rp_id":      bundle.RPID,
+	})
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "support-bundle-"+requestID+".json"))
+	writeAdminResponse(w, http.StatusOK, bundle)
+}
@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestAdminHandler_Controls(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	os.WriteFile(path, []byte(`{"providers": [{"dp_id": "dp_1", "endpoint": "http://dp1.example.com", "supported_claims": ["age_verification"]}]}`), 0600)
+	cfg := &config.Config{DPConnectorURL: "http://localhost:8080", DPConnectorToken: "dp-token-value", DPRegistryFile: path, DPTimeout: 5 * time.Second}
+	dpService := services.NewDPConnectorService(cfg)
+	handler := NewAdminHandler(cfg, dpService, services.NewCacheService(cfg), services.NewAuditService(cfg))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/v1/breakers/{dp_id}/reset", handler.HandleResetBreaker).Methods("POST")
+	router.HandleFunc("/admin/v1/dp-registry/reload", handler.HandleReloadRegistry).Methods("POST")
+	router.HandleFunc("/admin/v1/config", handler.HandleGetConfig).Methods("GET")
+	router.HandleFunc("/admin/v1/config/reload", handler.HandleReloadConfig).Methods("POST")
+	call := func(method, target string, body ...string) *httptest.ResponseRecorder {
+		user := &services.UserInfo{Subject: "operator-1", Roles: []string{"admin"}}
+		req := httptest.NewRequest(method, target, strings.NewReader(strings.Join(body, "")))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), "user", user)))
+		return w
+	}
+
+	if w := call("POST", "/admin/v1/breakers/dp_1/reset"); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 resetting a registered DP, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := call("POST", "/admin/v1/breakers/dp_unknown/reset"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown DP, got %d", w.Code)
+	}
+
+	os.WriteFile(path, []byte(`{"providers": [
+		{"dp_id": "dp_1", "endpoint": "http://dp1.example.com", "supported_claims": ["age_verification"]},
+		{"dp_id": "dp_2", "endpoint": "http://dp2.example.com", "supported_claims": ["student_verification"]}
+	]}`), 0600)
+	if w := call("POST", "/admin/v1/dp-registry/reload", `{}`); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "CHANGE_REASON_REQUIRED") {
+		t.Errorf("Expected a reload without a reason to be refused, got %d: %s", w.Code, w.Body.String())
+	}
+	w := call("POST", "/admin/v1/dp-registry/reload", `{"reason": "onboard dp_2"}`)
+	var reload services.RegistryReload
+	json.NewDecoder(w.Body).Decode(&reload)
+	if w.Code != http.StatusOK || len(reload.Added) != 1 || reload.Added[0] != "dp_2" {
+		t.Errorf("Expected dp_2 to be added, got %d: %+v", w.Code, reload)
+	}
+	os.WriteFile(path, []byte(`not json`), 0600)
+	if w := call("POST", "/admin/v1/dp-registry/reload", `{"reason": "broken file"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for an invalid registry, got %d", w.Code)
+	}
+
+	w = call("GET", "/admin/v1/config")
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("Expected an uncached config response, got %d", w.Code)
+	}
+	if body := w.Body.String(); strings.Contains(body, "dp-token-value") || !strings.Contains(body, `"DPRegistryFile"`) {
+		t.Errorf("Expected the config with secrets redacted, got %s", body)
+	}
+
+	// Without RUNTIME_CONFIG_FILE there is nothing to reload
+	if w := call("POST", "/admin/v1/config/reload"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 without a runtime configuration file, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_Drain(t *testing.T) {
+	cfg := &config.Config{DPConnectorURL: "http://localhost:8080", DPTimeout: 5 * time.Second, DrainTimeout: time.Second}
+	drainer := services.NewDrainer()
+	release, _ := drainer.Track()
+	handler := NewAdminHandler(cfg, services.NewDPConnectorService(cfg), services.NewCacheService(cfg), services.NewAuditService(cfg))
+	handler.SetDrainer(drainer)
+	health := &HealthHandler{config: cfg, selfTestReport: &services.SelfTestReport{Passed: true}}
+	health.SetDrainer(drainer)
+
+	call := func(method string, serve http.HandlerFunc) (*httptest.ResponseRecorder, services.DrainStatus) {
+		user := &services.UserInfo{Subject: "operator-1", Roles: []string{"admin"}}
+		req := httptest.NewRequest(method, "/admin/v1/drain", nil)
+		w := httptest.NewRecorder()
+		serve(w, req.WithContext(context.WithValue(req.Context(), "user", user)))
+		var status services.DrainStatus
+		json.Unmarshal(w.Body.Bytes(), &status)
+		return w, status
+	}
+
+	w := httptest.NewRecorder()
+	health.HandleReadiness(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the broker to be ready before the drain, got %d", w.Code)
+	}
+
+	w, status := call("POST", handler.HandleStartDrain)
+	if w.Code != http.StatusAccepted || status.State != services.DrainDraining || status.InFlight != 1 {
+		t.Fatalf("Expected the drain to wait for the request in flight, got %d: %+v", w.Code, status)
+	}
+	w = httptest.NewRecorder()
+	health.HandleReadiness(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"drain"`) {
+		t.Errorf("Expected readiness to fail while draining, got %d: %s", w.Code, w.Body.String())
+	}
+
+	release()
+	for i := 0; i < 100 && drainer.Status().State != services.DrainDrained; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, status := call("GET", handler.HandleGetDrain); status.State != services.DrainDrained || status.TimedOut || len(status.Flushed) != 0 {
+		t.Errorf("Expected the drain to complete, got %+v", status)
+	}
+}
+
+func TestAdminHandler_VerificationReport(t *testing.T) {
+	cfg := &config.Config{DPConnectorURL: "http://localhost:8080", DPTimeout: 5 * time.Second}
+	handler := NewAdminHandler(cfg, services.NewDPConnectorService(cfg), services.NewCacheService(cfg), services.NewAuditService(cfg))
+
+	w := httptest.NewRecorder()
+	handler.HandleGetVerificationReport(w, httptest.NewRequest("GET", "/admin/v1/reports/verifications", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Fatalf("Expected 501 without a reporting service, got %d", w.Code)
+	}
+
+	reporting := services.NewReportingService(cfg)
+	handler.SetReportingService(reporting)
+	now := time.Now().UTC()
+	reporting.Observe(&services.VerificationRecord{RPID: "rp-1", UserID: "user-1", ClaimType: "student_verification", DPID: "dp-1", Status: "completed", Verified: true, ConfidenceScore: 0.9, CreatedAt: now})
+	reporting.Flush(context.Background())
+
+	w = httptest.NewRecorder()
+	handler.HandleGetVerificationReport(w, httptest.NewRequest("GET", "/admin/v1/reports/verifications?from=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid date, got %d", w.Code)
+	}
+
+	today := now.Format("2006-01-02")
+	w = httptest.NewRecorder()
+	handler.HandleGetVerificationReport(w, httptest.NewRequest("GET", "/admin/v1/reports/verifications?from="+today+"&to="+today, nil))
+	var report struct {
+		Rows []services.ReportingRow `json:"rows"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if w.Code != http.StatusOK || len(report.Rows) != 1 || report.Rows[0].Verified != 1 {
+		t.Fatalf("Expected today's rollup, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "rp-1") || strings.Contains(w.Body.String(), "user-1") {
+		t.Errorf("Expected the report to leave out RP and user identifiers, got %s", w.Body.String())
+	}
+
+	anonymization := services.NewAnonymizationService()
+	anonymization.SetProfile(&services.AnonymizationProfile{
+		ExportType: services.ExportTypeVerificationReport,
+		Steps:      []services.AnonymizationStep{{Type: services.AnonymizeSuppressSmallGroups, CountField: "verifications", MinGroupSize: 2}},
+	})
+	reporting.SetAnonymization(anonymization)
+	w = httptest.NewRecorder()
+	handler.HandleGetVerificationReport(w, httptest.NewRequest("GET", "/admin/v1/reports/verifications", nil))
+	var anonymized struct {
+		Rows          []services.ReportingRow        `json:"rows"`
+		Anonymization services.AnonymizationManifest `json:"anonymization"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &anonymized)
+	if w.Code != http.StatusOK || len(anonymized.Rows) != 0 || anonymized.Anonymization.RowsSuppressed != 1 {
+		t.Errorf("Expected the single verification suppressed and the anonymization reported, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminHandler_ResponseTemplates(t *testing.T) {
+	cfg := &config.Config{DPConnectorURL: "http://localhost:8080", DPTimeout: 5 * time.Second}
+	handler := NewAdminHandler(cfg, services.NewDPConnectorService(cfg), services.NewCacheService(cfg), services.NewAuditService(cfg))
+	formatter := services.NewResponseFormatterService(cfg)
+	handler.SetResponseFormatter(formatter)
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/v1/response-templates", handler.HandleListResponseTemplates).Methods("GET")
+	router.HandleFunc("/admin/v1/response-templates/{name}", handler.HandlePutResponseTemplate).Methods("PUT")
+	router.HandleFunc("/admin/v1/response-templates/{name}", handler.HandleDeleteResponseTemplate).Methods("DELETE")
+
+	w := httptest.NewRecorder()
+	body := `{"include": ["verified", "confidence_score"], "rename": {"confidence_score": "score"}, "rp_ids": ["rp-1"]}`
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/admin/v1/response-templates/minimal", strings.NewReader(body)))
+	if w.Code != http.StatusOK || formatter.TemplateForRP("rp-1").TemplateName != "minimal" {
+		t.Fatalf("Expected the template registered for rp-1, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/admin/v1/response-templates/broken", strings.NewReader(`{"include": ["ssn"]}`)))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected an unknown field to be rejected, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/v1/response-templates", nil))
+	if !strings.Contains(w.Body.String(), `"template_name":"minimal"`) || !strings.Contains(w.Body.String(), `"template_name":"verification"`) {
+		t.Errorf("Expected the built-in and custom templates, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/admin/v1/response-templates/verification", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected the built-in template to be kept, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/admin/v1/response-templates/minimal", nil))
+	if w.Code != http.StatusNoContent || formatter.TemplateForRP("rp-1").TemplateName != "verification" {
+		t.Errorf("Expected rp-1 back on the built-in template, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_CustomMetrics(t *testing.T) {
+	cfg := &config.Config{DPConnectorURL: "http://localhost:8080", DPTimeout: 5 * time.Second}
+	handler := NewAdminHandler(cfg, services.NewDPConnectorService(cfg), services.NewCacheService(cfg), services.NewAuditService(cfg))
+	customMetrics := services.NewCustomMetricsService()
+	handler.SetCustomMetrics(customMetrics)
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/v1/custom-metrics", handler.HandleListCustomMetrics).Methods("GET")
+	router.HandleFunc("/admin/v1/custom-metrics/{name}", handler.HandlePutCustomMetric).Methods("PUT")
+	router.HandleFunc("/admin/v1/custom-metrics/{name}", handler.HandleDeleteCustomMetric).Methods("DELETE")
+
+	w := httptest.NewRecorder()
+	body := `{"type": "counter", "hook": "response", "filter": "response.confidence_score < 0.5", "labels": {"claim_type": "request.claim_type"}}`
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/admin/v1/custom-metrics/low_confidence_total", strings.NewReader(body)))
+	if w.Code != http.StatusOK || len(customMetrics.Statuses()) != 1 {
+		t.Fatalf("Expected the metric registered, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/admin/v1/custom-metrics/broken", strings.NewReader(`{"type": "counter", "hook": "request", "filter": "user.email != ''"}`)))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected an undeclared variable to be rejected, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/v1/custom-metrics", nil))
+	if !strings.Contains(w.Body.String(), `"name":"low_confidence_total"`) {
+		t.Errorf("Expected the registered metric listed, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/admin/v1/custom-metrics/low_confidence_total", nil))
+	if w.Code != http.StatusNoContent || len(customMetrics.Statuses()) != 0 {
+		t.Errorf("Expected the metric removed, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/admin/v1/custom-metrics/low_confidence_total", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown metric to be reported, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_DPComparisons(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	os.WriteFile(path, []byte(`{"providers": [
+		{"dp_id": "dp_1", "endpoint": "http://dp1.example.com", "supported_claims": ["age_verification"]},
+		{"dp_id": "dp_2", "endpoint": "http://dp2.example.com", "supported_claims": ["age_verification"], "disabled": true}
+	]}`), 0600)
+	cfg := &config.Config{DPConnectorURL: "http://localhost:8080", DPRegistryFile: path, DPTimeout: 5 * time.Second}
+	handler := NewAdminHandler(cfg, services.NewDPConnectorService(cfg), services.NewCacheService(cfg), services.NewAuditService(cfg))
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/v1/dp-comparisons", handler.HandleListDPComparisons).Methods("GET")
+	router.HandleFunc("/admin/v1/dp-comparisons/{claim_type}", handler.HandleGetDPComparison).Methods("GET")
+	router.HandleFunc("/admin/v1/dp-comparisons/{claim_type}", handler.HandlePutDPComparison).Methods("PUT")
+	router.HandleFunc("/admin/v1/dp-comparisons/{claim_type}", handler.HandleDeleteDPComparison).Methods("DELETE")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/admin/v1/dp-comparisons/age_verification", strings.NewReader(`{"incumbent": "dp_1", "candidate": "dp_3", "sample_rate": 0.1}`)))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected an unknown candidate to be rejected, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/admin/v1/dp-comparisons/age_verification", strings.NewReader(`{"incumbent": "dp_1", "candidate": "dp_2", "sample_rate": 0.1}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the comparison started, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/v1/dp-comparisons", nil))
+	if !strings.Contains(w.Body.String(), `"candidate":"dp_2"`) {
+		t.Errorf("Expected the comparison listed, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/admin/v1/dp-comparisons/age_verification", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"samples":0`) {
+		t.Errorf("Expected the final report, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/v1/dp-comparisons/age_verification", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected the stopped comparison to be gone, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_ValidationSchemas(t *testing.T) {
+	cfg := &config.Config{DPConnectorURL: "http://localhost:8080", DPTimeout: 5 * time.Second}
+	handler := NewAdminHandler(cfg, services.NewDPConnectorService(cfg), services.NewCacheService(cfg), services.NewAuditService(cfg))
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/v1/schemas", handler.HandleListValidationSchemas).Methods("GET")
+	router.HandleFunc("/admin/v1/schemas/{ref}", handler.HandleGetValidationSchema).Methods("GET")
+	router.HandleFunc("/admin/v1/schemas/{name}/versions", handler.HandleListValidationSchemaVersions).Methods("GET")
+	router.HandleFunc("/admin/v1/schemas/{name}/versions", handler.HandlePublishValidationSchema).Methods("POST")
+
+	body := `{"claim_type": "age_verification", "kind": "response", "schema": {"type": "object", "required": ["status"]}}`
+	for version := 1; version <= 2; version++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/admin/v1/schemas/age_response/versions", strings.NewReader(body)))
+		if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"version":`+strconv.Itoa(version)) {
+			t.Fatalf("Expected version %d published, got %d: %s", version, w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/admin/v1/schemas/age_response/versions", strings.NewReader(`{"claim_type": "age_verification", "kind": "response", "schema": {"type": "record"}}`)))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected an invalid schema to be rejected, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/v1/schemas/age_response@1", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"version":1`) {
+		t.Errorf("Expected version 1, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/v1/schemas/age_response/versions", nil))
+	var versions struct {
+		Versions []services.PublishedSchema `json:"versions"`
+	}
+	json.NewDecoder(w.Body).Decode(&versions)
+	if len(versions.Versions) != 2 {
+		t.Errorf("Expected two versions, got %+v", versions)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/v1/schemas/age_response@9", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown version to be reported, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_SupportBundles(t *testing.T) {
+	cfg := &config.Config{DPConnectorURL: "http://localhost:8080", DPTimeout: 5 * time.Second}
+	dpService := services.NewDPConnectorService(cfg)
+	handler := NewAdminHandler(cfg, dpService, services.NewCacheService(cfg), services.NewAuditService(cfg))
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/v1/support-bundles", handler.HandleListSupportBundles).Methods("GET")
+	router.HandleFunc("/admin/v1/support-bundles/{request_id}", handler.HandleGetSupportBundle).Methods("GET")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/v1/support-bundles", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status 501 without a bundle store, got %d", w.Code)
+	}
+
+	store := services.NewSupportBundleStore()
+	handler.SetSupportBundles(store)
+	trace := services.NewVerificationTrace("req-42", models.VerificationRequest{RPID: "rp_1", UserID: "user_1", ClaimType: "age_verification"})
+	trace.Begin("authorization")
+	store.Record(trace.Bundle(services.SupportBundleFailure{Code: "AUTHORIZATION_ERROR", Message: "Authorization service error", StatusCode: http.StatusInternalServerError}, cfg, dpService))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/v1/support-bundles", nil))
+	if !strings.Contains(w.Body.String(), `"request_id":"req-42"`) {
+		t.Errorf("Expected the bundle listed, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/v1/support-bundles/req-42", nil))
+	var bundle services.SupportBundle
+	json.NewDecoder(w.Body).Decode(&bundle)
+	if w.Code != http.StatusOK || bundle.Failure.Stage != "authorization" {
+		t.Errorf("Expected the bundle of req-42, got %d: %+v", w.Code, bundle)
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.Contains(disposition, "support-bundle-req-42.json") {
+		t.Errorf("Expected the bundle as a download, got %q", disposition)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/v1/support-bundles/req-unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown request, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_RegistryChanges(t *testing.T) {
+	cfg := &config.Config{DPConnectorURL: "http://localhost:8080", DPTimeout: 5 * time.Second}
+	handler := NewAdminHandler(cfg, services.NewDPConnectorService(cfg), services.NewCacheService(cfg), services.NewAuditService(cfg))
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/v1/claim-schemas/{claim_type}", handler.HandleUpdateClaimSchema).Methods("PUT")
+	router.HandleFunc("/admin/v1/registry-changes", handler.HandleListRegistryChanges).Methods("GET")
+	router.HandleFunc("/admin/v1/registry-changes/{id}", handler.HandleGetRegistryChange).Methods("GET")
+	call := func(method, target, body string) *httptest.ResponseRecorder {
+		user := &services.UserInfo{Subject: "operator-1", Roles: []string{"admin"}}
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), "user", user)))
+		return w
+	}
+
+	update := `{"reason": "require a phone number", "schema": {"description": "Age", "required_identifiers": ["email", "phone"], "dp_category": "government"}}`
+	if w := call("PUT", "/admin/v1/claim-schemas/age_verification", update); w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status 501 without claim schemas, got %d", w.Code)
+	}
+
+	schemas := services.NewClaimSchemaRegistry()
+	changes := services.NewRegistryChangeLog()
+	handler.SetClaimSchemas(schemas)
+	handler.SetRegistryChanges(changes)
+
+	if w := call("PUT", "/admin/v1/claim-schemas/age_verification", `{"schema": {"required_identifiers": ["email"]}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a reason, got %d", w.Code)
+	}
+	if w := call("PUT", "/admin/v1/claim-schemas/age_verification", `{"reason": "drop identifiers", "schema": {"required_identifiers": []}}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for an invalid schema, got %d", w.Code)
+	}
+
+	w := call("PUT", "/admin/v1/claim-schemas/age_verification", update)
+	var updated struct {
+		Change *services.RegistryChange `json:"change"`
+	}
+	json.NewDecoder(w.Body).Decode(&updated)
+	if w.Code != http.StatusOK || updated.Change == nil || updated.Change.Reason != "require a phone number" || updated.Change.Actor != "operator-1" {
+		t.Fatalf("Expected the update recorded with its reason, got %d: %+v", w.Code, updated.Change)
+	}
+	if schema, _ := schemas.Get("age_verification"); len(schema.RequiredIdentifiers) != 2 {
+		t.Errorf("Expected the schema replaced, got %+v", schema)
+	}
+
+	w = call("GET", "/admin/v1/registry-changes?claim_type=age_verification", "")
+	var history struct {
+		Changes []services.RegistryChange `json:"changes"`
+	}
+	json.NewDecoder(w.Body).Decode(&history)
+	if len(history.Changes) != 1 || history.Changes[0].ID != updated.Change.ID || !history.Changes[0].Behavioral {
+		t.Errorf("Expected the change in the history, got %+v", history.Changes)
+	}
+	if w := call("GET", "/admin/v1/registry-changes/"+updated.Change.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for a recorded change, got %d", w.Code)
+	}
+	if w := call("GET", "/admin/v1/registry-changes/chg_unknown", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown change, got %d", w.Code)
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httputil"
@@ -9,6 +10,9 @@ import (
 	"time"
 
 	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/middleware"
+	"github.com/pavilion-trust/core-broker/internal/models"
+	"github.com/pavilion-trust/core-broker/internal/services"
 )
 
 // APIGatewayHandler handles API Gateway requests
@@ -35,6 +39,8 @@ func NewAPIGatewayHandler(cfg *config.Config) *APIGatewayHandler {
 		// Add any additional headers or modifications here
 		req.Header.Set("X-Forwarded-Proto", "https")
 		req.Header.Set("X-Forwarded-Host", req.Host)
+		// The broker gets what is left of the request's deadline
+		services.SetRequestTimeout(req.Context(), req.Header)
 	}
 
 	// Customize the proxy transport
@@ -43,6 +49,7 @@ func NewAPIGatewayHandler(cfg *config.Config) *APIGatewayHandler {
 		MaxIdleConnsPerHost: 10,
 		IdleConnTimeout:     30 * time.Second,
 	}
+	proxy.ErrorHandler = writeProxyProblem
 
 	return &APIGatewayHandler{
 		config: cfg,
@@ -50,6 +57,19 @@ func NewAPIGatewayHandler(cfg *config.Config) *APIGatewayHandler {
 	}
 }
 
+// writeProxyProblem reports a request the Core Broker could not be reached
+// for: BROKER_TIMEOUT (504) when the broker did not answer in time and
+// BROKER_UNAVAILABLE (502) otherwise
+func writeProxyProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := models.NewProblem(models.ProblemBrokerUnavailable, "Core Broker unreachable", http.StatusBadGateway)
+	var timeout interface{ Timeout() bool }
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &timeout) && timeout.Timeout()) {
+		problem = models.NewProblem(models.ProblemBrokerTimeout, "Core Broker did not respond in time", http.StatusGatewayTimeout)
+	}
+	problem.Instance = r.URL.Path
+	middleware.WriteProblem(w, problem)
+}
+
 // HandleAPIRequest handles all API requests by proxying them to the Core Broker
 func (h *APIGatewayHandler) HandleAPIRequest(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -76,7 +96,7 @@ func (h *APIGatewayHandler) HandleHealth(w http.ResponseWriter, r *http.Request)
 	coreBrokerHealthURL := h.config.CoreBrokerURL + "/health"
 	resp, err := client.Get(coreBrokerHealthURL)
 	if err != nil {
-		http.Error(w, "Core Broker unreachable", http.StatusServiceUnavailable)
+		middleware.WriteProblem(w, models.NewProblem(models.ProblemBrokerUnavailable, "Core Broker unreachable", http.StatusServiceUnavailable))
 		return
 	}
 	defer resp.Body.Close()
@@ -84,7 +104,7 @@ func (h *APIGatewayHandler) HandleHealth(w http.ResponseWriter, r *http.Request)
 	// Read and forward the response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		http.Error(w, "Failed to read Core Broker response", http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", "Failed to read Core Broker response", http.StatusInternalServerError)
 		return
 	}
 
@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
 )
 
 func TestNewAPIGatewayHandler(t *testing.T) {
@@ -159,9 +164,54 @@ func TestAPIGatewayHandler_HandleHealth_CoreBrokerUnreachable(t *testing.T) {
 		t.Errorf("Expected status 503, got %d", w.Code)
 	}
 
-	expectedBody := "Core Broker unreachable\n"
-	if w.Body.String() != expectedBody {
-		t.Errorf("Expected body %s, got %s", expectedBody, w.Body.String())
+	var problem models.Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to decode problem: %v", err)
+	}
+	if w.Header().Get("Content-Type") != models.ProblemContentType || problem.Code != models.ProblemBrokerUnavailable {
+		t.Errorf("Expected a %s problem, got %s %+v", models.ProblemBrokerUnavailable, w.Header().Get("Content-Type"), problem)
+	}
+}
+
+func TestAPIGatewayHandler_ProxyErrorIsProblem(t *testing.T) {
+	handler := NewAPIGatewayHandler(&config.Config{CoreBrokerURL: "http://localhost:9999"})
+
+	req := httptest.NewRequest("POST", "/api/v1/verify", nil)
+	w := httptest.NewRecorder()
+	handler.HandleAPIRequest(w, req)
+
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("Expected status 502, got %d", w.Code)
+	}
+	var problem map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to decode problem: %v", err)
+	}
+	if problem["type"] != models.ProblemType(models.ProblemBrokerUnavailable) || problem["instance"] != "/api/v1/verify" {
+		t.Errorf("Unexpected problem: %v", problem)
+	}
+	// The earlier error body is kept alongside the problem members
+	if legacy, ok := problem["error"].(map[string]interface{}); !ok || legacy["code"] != models.ProblemBrokerUnavailable {
+		t.Errorf("Expected the legacy error member, got %v", problem["error"])
+	}
+}
+
+func TestAPIGatewayHandler_ForwardsDeadline(t *testing.T) {
+	received := make(chan string, 1)
+	broker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Request-Timeout")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer broker.Close()
+	handler := NewAPIGatewayHandler(&config.Config{CoreBrokerURL: broker.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	req := httptest.NewRequest("POST", "/api/v1/verify", nil).WithContext(ctx)
+	handler.HandleAPIRequest(httptest.NewRecorder(), req)
+
+	if ms, err := strconv.Atoi(<-received); err != nil || ms <= 2000 || ms > 3000 {
+		t.Errorf("Expected the broker to be sent the time left, got %d (%v)", ms, err)
 	}
 }
 
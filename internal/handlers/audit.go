@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// maxAuditEntriesPerRequest bounds the entries decrypted or rewrapped per call
+const maxAuditEntriesPerRequest = 1000
+
+// AuditHandler gives admins access to encrypted audit metadata. Entries are
+// read from wherever the audit trail is persisted and posted here; the broker
+// holds the keys, so this is the only place they can be decrypted.
+type AuditHandler struct {
+	config       *config.Config
+	auditService *services.AuditService
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(cfg *config.Config, auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		config:       cfg,
+		auditService: auditService,
+	}
+}
+
+// AuditEntriesRequest carries audit entries as they were persisted
+type AuditEntriesRequest struct {
+	Entries []*models.AuditEntry `json:"entries"`
+}
+
+// AuditEntriesResponse returns processed audit entries
+type AuditEntriesResponse struct {
+	Entries   []*models.AuditEntry `json:"entries"`
+	Rewrapped int                  `json:"rewrapped,omitempty"`
+}
+
+// HandleDecryptEntries handles POST /audit/decrypt. Each decryption is itself
+// audited with the caller's identity.
+func (h *AuditHandler) HandleDecryptEntries(w http.ResponseWriter, r *http.Request) {
+	entries, ok := h.readEntries(w, r)
+	if !ok {
+		return
+	}
+
+	accessedBy := ""
+	if userInfo, ok := r.Context().Value("user").(*services.UserInfo); ok {
+		accessedBy = userInfo.Subject
+	}
+	response := AuditEntriesResponse{Entries: make([]*models.AuditEntry, 0, len(entries))}
+	for i, entry := range entries {
+		decrypted, err := h.auditService.DecryptAuditEntry(r.Context(), entry, accessedBy)
+		if err != nil {
+			writeError(w, "DECRYPTION_FAILED", fmt.Sprintf("entry %d: %v", i, err), http.StatusUnprocessableEntity)
+			return
+		}
+		response.Entries = append(response.Entries, decrypted)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleRewrapEntries handles POST /audit/rewrap, re-encrypting entries under
+// the current master key so a retired key can be removed
+func (h *AuditHandler) HandleRewrapEntries(w http.ResponseWriter, r *http.Request) {
+	entries, ok := h.readEntries(w, r)
+	if !ok {
+		return
+	}
+
+	response := AuditEntriesResponse{Entries: make([]*models.AuditEntry, 0, len(entries))}
+	for i, entry := range entries {
+		rewrapped, count, err := h.auditService.RewrapAuditEntry(entry)
+		if err != nil {
+			writeError(w, "REWRAP_FAILED", fmt.Sprintf("entry %d: %v", i, err), http.StatusUnprocessableEntity)
+			return
+		}
+		response.Entries = append(response.Entries, rewrapped)
+		response.Rewrapped += count
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// readEntries decodes and bounds the posted entries
+func (h *AuditHandler) readEntries(w http.ResponseWriter, r *http.Request) ([]*models.AuditEntry, bool) {
+	var req AuditEntriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "INVALID_REQUEST", "Invalid JSON format", http.StatusBadRequest)
+		return nil, false
+	}
+	if len(req.Entries) == 0 || len(req.Entries) > maxAuditEntriesPerRequest {
+		writeError(w, "INVALID_REQUEST", fmt.Sprintf("entries must hold between 1 and %d audit entries", maxAuditEntriesPerRequest), http.StatusBadRequest)
+		return nil, false
+	}
+	for i, entry := range req.Entries {
+		if entry == nil {
+			writeError(w, "INVALID_REQUEST", fmt.Sprintf("entry %d is null", i), http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	return req.Entries, true
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestAuditHandler_DecryptEntries(t *testing.T) {
+	provider, _ := config.NewLocalKeyProvider(map[string][]byte{"k1": make([]byte, 32)})
+	cfg := &config.Config{KeyProvider: provider, AuditEncryption: true, AuditEncryptionKeyID: "k1"}
+	encryptor, _ := services.NewAuditFieldEncryptor(cfg)
+	entry := &models.AuditEntry{RequestID: "req-1", Metadata: map[string]interface{}{"user_id": "user-42"}}
+	if err := encryptor.EncryptEntry(entry); err != nil {
+		t.Fatalf("EncryptEntry failed: %v", err)
+	}
+	handler := NewAuditHandler(cfg, services.NewAuditService(cfg))
+
+	asAdmin := func(req *http.Request) *http.Request {
+		user := &services.UserInfo{Subject: "admin-1", Roles: []string{"admin"}}
+		return req.WithContext(context.WithValue(req.Context(), "user", user))
+	}
+	body, _ := json.Marshal(AuditEntriesRequest{Entries: []*models.AuditEntry{entry}})
+
+	w := httptest.NewRecorder()
+	handler.HandleDecryptEntries(w, asAdmin(httptest.NewRequest("POST", "/audit/decrypt", bytes.NewReader(body))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response AuditEntriesResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if len(response.Entries) != 1 || response.Entries[0].Metadata["user_id"] != "user-42" {
+		t.Errorf("Unexpected decrypted entries: %+v", response.Entries)
+	}
+
+	w = httptest.NewRecorder()
+	handler.HandleDecryptEntries(w, asAdmin(httptest.NewRequest("POST", "/audit/decrypt", strings.NewReader(`{"entries":[]}`))))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for no entries, got %d", w.Code)
+	}
+
+	// A broker without the key cannot decrypt
+	other := NewAuditHandler(&config.Config{}, services.NewAuditService(&config.Config{}))
+	w = httptest.NewRecorder()
+	other.HandleDecryptEntries(w, asAdmin(httptest.NewRequest("POST", "/audit/decrypt", bytes.NewReader(body))))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 without a key provider, got %d", w.Code)
+	}
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pavilion-trust/core-broker/internal/models"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// sseHeartbeatInterval controls how often idle SSE streams receive a keep-alive comment
+var sseHeartbeatInterval = 15 * time.Second
+
+// BatchVerificationRequest represents a batch of verification requests
+type BatchVerificationRequest struct {
+	Requests []models.VerificationRequest `json:"requests"`
+}
+
+// BatchVerificationResponse is returned when a batch is accepted
+type BatchVerificationResponse struct {
+	Batch     *services.BatchProgress `json:"batch"`
+	StatusURL string                  `json:"status_url"`
+	EventsURL string                  `json:"events_url"`
+}
+
+// BatchStatusResponse represents the current state of a batch
+type BatchStatusResponse struct {
+	Batch   *services.BatchProgress     `json:"batch"`
+	Results []*services.BatchItemResult `json:"results"`
+}
+
+// HandleBatchVerification handles POST /verify/batch
+func (h *VerificationHandler) HandleBatchVerification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var batchReq BatchVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&batchReq); err != nil {
+		writeError(w, "INVALID_JSON", "Failed to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	rpID := getCallerRPID(ctx)
+	progress, err := h.batchTracker.CreateBatch(rpID, len(batchReq.Requests))
+	if err != nil {
+		writeError(w, "INVALID_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Process items detached from the request so the batch outlives the POST
+	go h.processBatch(context.WithoutCancel(ctx), progress.BatchID, batchReq.Requests)
+
+	basePath := "/api/v1/verify/batch/" + progress.BatchID
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", basePath)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(BatchVerificationResponse{
+		Batch:     progress,
+		StatusURL: basePath,
+		EventsURL: basePath + "/events",
+	})
+}
+
+// processBatch verifies batch items with bounded concurrency
+func (h *VerificationHandler) processBatch(ctx context.Context, batchID string, requests []models.VerificationRequest) {
+	concurrency := h.config.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i := range requests {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(index int, req models.VerificationRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			h.batchTracker.RecordResult(batchID, h.verifyBatchItem(ctx, batchID, index, &req))
+		}(i, requests[i])
+	}
+
+	wg.Wait()
+}
+
+// verifyBatchItem validates and verifies a single batch item
+func (h *VerificationHandler) verifyBatchItem(ctx context.Context, batchID string, index int, req *models.VerificationRequest) (result *services.BatchItemResult) {
+	requestID := fmt.Sprintf("%s_%d", batchID, index)
+	result = &services.BatchItemResult{Index: index}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result.Status = "error"
+			result.Response = nil
+			result.Error = models.NewError("INTERNAL_ERROR", "Batch item processing failed", requestID)
+		}
+	}()
+
+	if err := req.Validate(); err != nil {
+		result.Status = "error"
+		result.Error = models.NewError("VALIDATION_FAILED", err.Error(), requestID)
+		return result
+	}
+
+	itemCtx := context.WithValue(ctx, "request_id", requestID)
+	itemCtx = context.WithValue(itemCtx, "start_time", time.Now())
+	itemCtx = context.WithValue(itemCtx, "request_hash", fmt.Sprintf("hash_batch_%s", requestID))
+
+	response, verr := h.verify(itemCtx, req)
+	if verr != nil {
+		result.Status = "error"
+		result.Error = models.NewError(verr.Code, verr.Message, requestID)
+		result.Error.Details = verr.Details
+		return result
+	}
+
+	result.Status = response.Status
+	result.Response = response
+	return result
+}
+
+// HandleBatchStatus handles GET /verify/batch/{id}
+func (h *VerificationHandler) HandleBatchStatus(w http.ResponseWriter, r *http.Request) {
+	batchID := mux.Vars(r)["id"]
+	if !h.callerOwnsBatch(r.Context(), batchID) {
+		writeError(w, "NOT_FOUND", "Batch not found", http.StatusNotFound)
+		return
+	}
+
+	progress, results, err := h.batchTracker.GetBatch(batchID)
+	if err != nil {
+		writeError(w, "NOT_FOUND", "Batch not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(BatchStatusResponse{Batch: progress, Results: results})
+}
+
+// HandleBatchEvents handles GET /verify/batch/{id}/events as a Server-Sent Events stream
+func (h *VerificationHandler) HandleBatchEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	batchID := mux.Vars(r)["id"]
+	if !h.callerOwnsBatch(ctx, batchID) {
+		writeError(w, "NOT_FOUND", "Batch not found", http.StatusNotFound)
+		return
+	}
+
+	events, cancel, err := h.batchTracker.Subscribe(batchID)
+	if err != nil {
+		writeError(w, "NOT_FOUND", "Batch not found", http.StatusNotFound)
+		return
+	}
+	defer cancel()
+
+	// Resume after the last event the client saw
+	lastEventID, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+
+	controller := http.NewResponseController(w)
+	// Streams may outlive the server write timeout
+	controller.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	controller.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			controller.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.ID <= lastEventID {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			controller.Flush()
+		}
+	}
+}
+
+// callerOwnsBatch checks that the authenticated RP submitted the batch
+func (h *VerificationHandler) callerOwnsBatch(ctx context.Context, batchID string) bool {
+	owner, err := h.batchTracker.GetBatchOwner(batchID)
+	if err != nil {
+		return false
+	}
+	rpID := getCallerRPID(ctx)
+	return rpID == "" || owner == "" || owner == rpID
+}
+
+// getCallerRPID returns the RP identifier of the authenticated caller, if any
+func getCallerRPID(ctx context.Context) string {
+	if userInfo, ok := ctx.Value("user").(*services.UserInfo); ok {
+		return userInfo.ResourceID
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestBatchVerification_StreamsEvents(t *testing.T) {
+	handler := NewVerificationHandler(&config.Config{BatchMaxItems: 10, BatchConcurrency: 2})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/verify/batch", handler.HandleBatchVerification).Methods("POST")
+	router.HandleFunc("/api/v1/verify/batch/{id}", handler.HandleBatchStatus).Methods("GET")
+	router.HandleFunc("/api/v1/verify/batch/{id}/events", handler.HandleBatchEvents).Methods("GET")
+
+	rpUser := &services.UserInfo{Subject: "user-rp-1", ResourceID: "rp_1", Roles: []string{"rp"}}
+	withUser := func(r *http.Request, user *services.UserInfo) *http.Request {
+		return r.WithContext(context.WithValue(r.Context(), "user", user))
+	}
+
+	// Items missing required fields fail validation without reaching a DP
+	body, _ := json.Marshal(BatchVerificationRequest{Requests: []models.VerificationRequest{
+		{RPID: "rp_1"},
+		{RPID: "rp_1", ClaimType: "age_verification"},
+	}})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, withUser(httptest.NewRequest("POST", "/api/v1/verify/batch", bytes.NewBuffer(body)), rpUser))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var accepted BatchVerificationResponse
+	json.NewDecoder(w.Body).Decode(&accepted)
+	if accepted.Batch == nil || accepted.EventsURL == "" {
+		t.Fatal("Expected batch and events URL in response")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(w, withUser(r, rpUser))
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + accepted.EventsURL)
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %s", ct)
+	}
+
+	var eventTypes []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if eventType, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+
+	if len(eventTypes) != 3 || eventTypes[2] != services.BatchEventComplete {
+		t.Errorf("Expected two item events and a completion event, got %v", eventTypes)
+	}
+
+	// Other RPs cannot observe the batch
+	otherRP := &services.UserInfo{Subject: "user-rp-2", ResourceID: "rp_2", Roles: []string{"rp"}}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, withUser(httptest.NewRequest("GET", accepted.StatusURL, nil), otherRP))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another RP, got %d", w.Code)
+	}
+}
+
+func TestBatchVerification_RejectsEmptyBatch(t *testing.T) {
+	handler := NewVerificationHandler(&config.Config{})
+
+	body, _ := json.Marshal(BatchVerificationRequest{})
+	w := httptest.NewRecorder()
+	handler.HandleBatchVerification(w, httptest.NewRequest("POST", "/api/v1/verify/batch", bytes.NewBuffer(body)))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// CatalogHandler exposes the claim type catalog to RP developer portals
+type CatalogHandler struct {
+	config         *config.Config
+	catalogService *services.ClaimCatalogService
+	deprecations   *services.ClaimDeprecationTracker
+}
+
+// NewCatalogHandler creates a new catalog handler
+func NewCatalogHandler(cfg *config.Config, catalogService *services.ClaimCatalogService) *CatalogHandler {
+	return &CatalogHandler{
+		config:         cfg,
+		catalogService: catalogService,
+	}
+}
+
+// SetDeprecationTracker sets the tracker of RPs using deprecated claim types
+func (h *CatalogHandler) SetDeprecationTracker(tracker *services.ClaimDeprecationTracker) {
+	h.deprecations = tracker
+}
+
+// HandleGetCatalog handles GET /catalog, listing the claim types available to the caller
+func (h *CatalogHandler) HandleGetCatalog(w http.ResponseWriter, r *http.Request) {
+	rpID := getCallerRPID(r.Context())
+	if rpID == "" {
+		writeError(w, "AUTHORIZATION_DENIED", "No tenant associated with caller", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.catalogService.Catalog(rpID))
+}
+
+// HandleGetDeprecations handles GET /catalog/deprecations, listing the
+// deprecated and retired claim types the caller has used
+func (h *CatalogHandler) HandleGetDeprecations(w http.ResponseWriter, r *http.Request) {
+	rpID := getCallerRPID(r.Context())
+	if rpID == "" {
+		writeError(w, "AUTHORIZATION_DENIED", "No tenant associated with caller", http.StatusForbidden)
+		return
+	}
+
+	events := make([]services.ClaimDeprecationEvent, 0)
+	if h.deprecations != nil {
+		events = h.deprecations.EventsForRP(rpID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tenant_id": rpID,
+		"events":    events,
+	})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestCatalogHandler_HandleGetCatalog(t *testing.T) {
+	cfg := &config.Config{OPAURL: "http://invalid-opa-url:8181", DPConnectorURL: "http://localhost:8081"}
+	verificationHandler := NewVerificationHandler(cfg)
+	catalogService := services.NewClaimCatalogService(services.NewClaimSchemaRegistry(), verificationHandler.DPService(), verificationHandler.AuthorizationService())
+	handler := NewCatalogHandler(cfg, catalogService)
+
+	t.Run("caller tenant", func(t *testing.T) {
+		rpUser := &services.UserInfo{Subject: "user-rp-1", ResourceID: "rp_1", Roles: []string{"rp"}}
+		req := httptest.NewRequest("GET", "/api/v1/catalog", nil)
+		req = req.WithContext(context.WithValue(req.Context(), "user", rpUser))
+		w := httptest.NewRecorder()
+
+		handler.HandleGetCatalog(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var catalog services.ClaimCatalog
+		if err := json.NewDecoder(w.Body).Decode(&catalog); err != nil {
+			t.Fatalf("Failed to decode catalog: %v", err)
+		}
+		if catalog.TenantID != "rp_1" || len(catalog.ClaimTypes) == 0 {
+			t.Errorf("Unexpected catalog: %+v", catalog)
+		}
+	})
+
+	t.Run("no tenant", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.HandleGetCatalog(w, httptest.NewRequest("GET", "/api/v1/catalog", nil))
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", w.Code)
+		}
+	})
+}
+
+func TestCatalogHandler_HandleGetDeprecations(t *testing.T) {
+	cfg := &config.Config{OPAURL: "http://invalid-opa-url:8181", DPConnectorURL: "http://localhost:8081"}
+	verificationHandler := NewVerificationHandler(cfg)
+	catalogService := services.NewClaimCatalogService(verificationHandler.SchemaRegistry(), verificationHandler.DPService(), verificationHandler.AuthorizationService())
+	handler := NewCatalogHandler(cfg, catalogService)
+	handler.SetDeprecationTracker(verificationHandler.ClaimDeprecations())
+
+	verificationHandler.ClaimDeprecations().Record("rp_1", &services.ClaimDeprecationNotice{ClaimType: "age_verification", State: services.ClaimStateDeprecated})
+	verificationHandler.ClaimDeprecations().Record("rp_2", &services.ClaimDeprecationNotice{ClaimType: "age_verification", State: services.ClaimStateDeprecated})
+
+	rpUser := &services.UserInfo{Subject: "user-rp-1", ResourceID: "rp_1", Roles: []string{"rp"}}
+	req := httptest.NewRequest("GET", "/api/v1/catalog/deprecations", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "user", rpUser))
+	w := httptest.NewRecorder()
+
+	handler.HandleGetDeprecations(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var body struct {
+		Events []services.ClaimDeprecationEvent `json:"events"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(body.Events) != 1 || body.Events[0].RPID != "rp_1" {
+		t.Errorf("Expected only the caller's events, got %+v", body.Events)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// ConsentHandler records consent revocations relayed by RPs
+type ConsentHandler struct {
+	config         *config.Config
+	consentService *services.ConsentService
+}
+
+// NewConsentHandler creates a new consent handler
+func NewConsentHandler(cfg *config.Config, consentService *services.ConsentService) *ConsentHandler {
+	return &ConsentHandler{
+		config:         cfg,
+		consentService: consentService,
+	}
+}
+
+// RevokeConsentRequest withdraws a user's consent from the calling RP, for
+// the listed claim types or all of them
+type RevokeConsentRequest struct {
+	UserID     string   `json:"user_id"`
+	ClaimTypes []string `json:"claim_types,omitempty"`
+	Reason     string   `json:"reason,omitempty"`
+}
+
+// RevokeConsentResponse reports a recorded revocation. CacheInvalidated is
+// false when cached results could not be deleted; they are still never
+// served.
+type RevokeConsentResponse struct {
+	Revocation       services.ConsentRevocation `json:"revocation"`
+	CacheInvalidated bool                       `json:"cache_invalidated"`
+}
+
+// HandleRevokeConsent handles POST /consents/revoke
+func (h *ConsentHandler) HandleRevokeConsent(w http.ResponseWriter, r *http.Request) {
+	rpID := getCallerRPID(r.Context())
+	if rpID == "" {
+		writeError(w, "AUTHORIZATION_DENIED", "No tenant associated with caller", http.StatusForbidden)
+		return
+	}
+
+	var req RevokeConsentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "INVALID_REQUEST", fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.UserID == "" {
+		writeError(w, "INVALID_REQUEST", "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	revocation, err := h.consentService.Revoke(r.Context(), services.ConsentRevocation{
+		RPID:       rpID,
+		UserID:     req.UserID,
+		ClaimTypes: req.ClaimTypes,
+		Reason:     req.Reason,
+	})
+	if err != nil {
+		fmt.Printf("CONSENT WARNING: revocation for RP %s recorded but not fully applied: %v\n", rpID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(RevokeConsentResponse{
+		Revocation:       revocation,
+		CacheInvalidated: err == nil,
+	})
+}
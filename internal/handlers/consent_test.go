@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestConsentHandler_HandleRevokeConsent(t *testing.T) {
+	consentService := services.NewConsentService()
+	var revoked []services.ConsentRevocation
+	consentService.OnRevoke(func(ctx context.Context, revocation services.ConsentRevocation) error {
+		revoked = append(revoked, revocation)
+		return nil
+	})
+	handler := NewConsentHandler(&config.Config{}, consentService)
+	rpUser := &services.UserInfo{Subject: "user-rp-1", ResourceID: "rp_1", Roles: []string{"rp"}}
+
+	t.Run("caller tenant", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/consents/revoke", strings.NewReader(`{"user_id": "user_1", "claim_types": ["age_verification"]}`))
+		req = req.WithContext(context.WithValue(req.Context(), "user", rpUser))
+		w := httptest.NewRecorder()
+
+		handler.HandleRevokeConsent(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var response RevokeConsentResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		if !response.CacheInvalidated || response.Revocation.RPID != "rp_1" || response.Revocation.RevokedAt.IsZero() {
+			t.Errorf("Unexpected response: %+v", response)
+		}
+		if len(revoked) != 1 || revoked[0].UserID != "user_1" {
+			t.Errorf("Expected the revocation published, got %+v", revoked)
+		}
+	})
+
+	t.Run("missing user", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/consents/revoke", strings.NewReader(`{}`))
+		req = req.WithContext(context.WithValue(req.Context(), "user", rpUser))
+		w := httptest.NewRecorder()
+
+		handler.HandleRevokeConsent(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("no tenant", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.HandleRevokeConsent(w, httptest.NewRequest("POST", "/api/v1/consents/revoke", strings.NewReader(`{"user_id": "user_1"}`)))
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", w.Code)
+		}
+	})
+}
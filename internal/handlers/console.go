@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// ConsoleHandler serves the sandbox developer console data endpoints
+type ConsoleHandler struct {
+	config         *config.Config
+	consoleService *services.ConsoleService
+}
+
+// NewConsoleHandler creates a new console handler
+func NewConsoleHandler(cfg *config.Config, consoleService *services.ConsoleService) *ConsoleHandler {
+	return &ConsoleHandler{
+		config:         cfg,
+		consoleService: consoleService,
+	}
+}
+
+// HandleListExamples handles GET /sandbox/console/examples
+func (h *ConsoleHandler) HandleListExamples(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"examples": h.consoleService.Examples(),
+	})
+}
+
+// HandleGetExample handles GET /sandbox/console/examples/{claim_type}
+func (h *ConsoleHandler) HandleGetExample(w http.ResponseWriter, r *http.Request) {
+	example, err := h.consoleService.Example(mux.Vars(r)["claim_type"])
+	if err != nil {
+		writeError(w, "NOT_FOUND", err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(example)
+}
+
+// HandleValidateDraft handles POST /sandbox/console/validate. Drafts that fail
+// validation still return 200; the findings are in the response body.
+func (h *ConsoleHandler) HandleValidateDraft(w http.ResponseWriter, r *http.Request) {
+	var draft map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&draft); err != nil || draft == nil {
+		writeError(w, "INVALID_JSON", "Request body must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.consoleService.ValidateDraft(draft))
+}
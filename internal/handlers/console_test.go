@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestConsoleHandler(t *testing.T) {
+	cfg := &config.Config{SandboxEnabled: true}
+	handler := NewConsoleHandler(cfg, services.NewConsoleService(services.NewClaimSchemaRegistry()))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/examples", handler.HandleListExamples).Methods("GET")
+	router.HandleFunc("/examples/{claim_type}", handler.HandleGetExample).Methods("GET")
+	router.HandleFunc("/validate", handler.HandleValidateDraft).Methods("POST")
+
+	t.Run("get example", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/examples/age_verification", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var example services.ConsoleExample
+		if err := json.NewDecoder(w.Body).Decode(&example); err != nil {
+			t.Fatalf("Failed to decode example: %v", err)
+		}
+		if example.ClaimType != "age_verification" || len(example.Request.Annotations) == 0 {
+			t.Errorf("Unexpected example: %+v", example)
+		}
+	})
+
+	t.Run("unknown claim type", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/examples/unknown", nil))
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("validate draft", func(t *testing.T) {
+		body := `{"rp_id":"rp_1","user_id":"user_1","claim_type":"age_verification","identifiers":{}}`
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/validate", strings.NewReader(body)))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var validation services.DraftValidation
+		if err := json.NewDecoder(w.Body).Decode(&validation); err != nil {
+			t.Fatalf("Failed to decode validation: %v", err)
+		}
+		if validation.Valid || len(validation.Feedback) == 0 {
+			t.Errorf("Expected invalid draft with feedback, got %+v", validation)
+		}
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/validate", strings.NewReader("[1")))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", w.Code)
+		}
+	})
+}
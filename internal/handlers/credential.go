@@ -12,6 +12,23 @@ import (
 	"github.com/pavilion-trust/core-broker/internal/services"
 )
 
+// credentialListSpec pages GET /credentials, newest first
+var credentialListSpec = ListSpec[*models.Credential]{
+	Name: "credentials",
+	Sorts: map[string]func(a, b *models.Credential) int{
+		"issuance_date": compareStrings(func(c *models.Credential) string { return c.IssuanceDate }),
+		"type":          compareStrings(func(c *models.Credential) string { return c.Type }),
+	},
+	DefaultSort: "-issuance_date",
+	Filters: map[string]func(*models.Credential, string) bool{
+		"status":  equalsFilter(func(c *models.Credential) string { return c.Status }),
+		"type":    equalsFilter(func(c *models.Credential) string { return c.Type }),
+		"issuer":  equalsFilter(func(c *models.Credential) string { return c.Issuer }),
+		"subject": equalsFilter(func(c *models.Credential) string { return c.Subject }),
+	},
+	Key: func(c *models.Credential) string { return c.ID },
+}
+
 // CredentialHandler handles credential-related API requests
 type CredentialHandler struct {
 	config *config.Config
@@ -19,6 +36,10 @@ type CredentialHandler struct {
 	signingService *services.CredentialSigningService
 	// Credential storage (in-memory for MVP, would be database in production)
 	credentials map[string]*models.Credential
+	// Status lists checked for credentials that carry a status entry
+	statusLists *services.StatusListService
+	// Post-processing hooks run after each issuance
+	hooks *services.CredentialHookService
 }
 
 // NewCredentialHandler creates a new credential handler
@@ -30,6 +51,17 @@ func NewCredentialHandler(cfg *config.Config, signingService *services.Credentia
 	}
 }
 
+// SetStatusListService enables status list checks on verification
+func (h *CredentialHandler) SetStatusListService(statusLists *services.StatusListService) {
+	h.statusLists = statusLists
+}
+
+// SetCredentialHooks runs the registered post-processing hooks after each
+// issuance, in the background
+func (h *CredentialHandler) SetCredentialHooks(hooks *services.CredentialHookService) {
+	h.hooks = hooks
+}
+
 // CreateCredentialRequest represents a request to create a new credential
 type CreateCredentialRequest struct {
 	Type         string                 `json:"type" validate:"required"`
@@ -38,6 +70,8 @@ type CreateCredentialRequest struct {
 	SigningMethod string                `json:"signing_method" validate:"required"`
 	ExpirationDate string               `json:"expiration_date,omitempty"`
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	// CredentialStatus is the status list entry the credential is revoked through
+	CredentialStatus *models.CredentialStatus `json:"credential_status,omitempty"`
 }
 
 // CreateCredentialResponse represents the response from creating a credential
@@ -84,13 +118,13 @@ type CredentialStatusResponse struct {
 func (h *CredentialHandler) HandleCreateCredential(w http.ResponseWriter, r *http.Request) {
 	var req CreateCredentialRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, "INVALID_REQUEST", "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
 	// Validate request
 	if req.Type == "" || req.Subject == "" || req.SigningMethod == "" {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		writeError(w, "VALIDATION_FAILED", "Missing required fields", http.StatusBadRequest)
 		return
 	}
 
@@ -105,6 +139,7 @@ func (h *CredentialHandler) HandleCreateCredential(w http.ResponseWriter, r *htt
 		Version:      "1.0",
 		Claims:       req.Claims,
 		Status:       "valid",
+		CredentialStatus: req.CredentialStatus,
 		Metadata:     req.Metadata,
 		Proof: models.CredentialProof{
 			Type:               "JwtProof2020",
@@ -118,13 +153,18 @@ func (h *CredentialHandler) HandleCreateCredential(w http.ResponseWriter, r *htt
 	signingMethod := services.SigningMethod(req.SigningMethod)
 	signature, err := h.signingService.SignCredential(credential, signingMethod)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to sign credential: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Failed to sign credential: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// Store the credential
 	h.credentials[credential.ID] = credential
 
+	// Hooks run in the background; their failures do not affect the response
+	if h.hooks != nil {
+		h.hooks.Dispatch(credential, signature)
+	}
+
 	// Return response
 	response := CreateCredentialResponse{
 		Credential: credential,
@@ -145,7 +185,7 @@ func (h *CredentialHandler) HandleGetCredential(w http.ResponseWriter, r *http.R
 
 	credential, exists := h.credentials[credentialID]
 	if !exists {
-		http.Error(w, "Credential not found", http.StatusNotFound)
+		writeError(w, "NOT_FOUND", "Credential not found", http.StatusNotFound)
 		return
 	}
 
@@ -161,20 +201,22 @@ func (h *CredentialHandler) HandleGetCredential(w http.ResponseWriter, r *http.R
 
 // HandleListCredentials handles GET /credentials endpoint
 func (h *CredentialHandler) HandleListCredentials(w http.ResponseWriter, r *http.Request) {
+	query, ok := parseList(w, r, credentialListSpec)
+	if !ok {
+		return
+	}
+
 	credentials := make([]*models.Credential, 0, len(h.credentials))
 	for _, cred := range h.credentials {
 		credentials = append(credentials, cred)
 	}
 
-	response := map[string]interface{}{
-		"credentials": credentials,
-		"count":       len(credentials),
-		"status":      "success",
-		"message":     "Credentials retrieved successfully",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	page := credentialListSpec.Paginate(credentials, query)
+	writePage(w, r, "credentials", page, map[string]interface{}{
+		"count":   len(page.Items),
+		"status":  "success",
+		"message": "Credentials retrieved successfully",
+	})
 }
 
 // HandleRevokeCredential handles POST /credentials/{id}/revoke endpoint
@@ -184,13 +226,13 @@ func (h *CredentialHandler) HandleRevokeCredential(w http.ResponseWriter, r *htt
 
 	credential, exists := h.credentials[credentialID]
 	if !exists {
-		http.Error(w, "Credential not found", http.StatusNotFound)
+		writeError(w, "NOT_FOUND", "Credential not found", http.StatusNotFound)
 		return
 	}
 
 	var req RevokeCredentialRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, "INVALID_REQUEST", "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
@@ -218,7 +260,7 @@ func (h *CredentialHandler) HandleGetCredentialStatus(w http.ResponseWriter, r *
 
 	credential, exists := h.credentials[credentialID]
 	if !exists {
-		http.Error(w, "Credential not found", http.StatusNotFound)
+		writeError(w, "NOT_FOUND", "Credential not found", http.StatusNotFound)
 		return
 	}
 
@@ -255,19 +297,36 @@ func (h *CredentialHandler) HandleVerifyCredential(w http.ResponseWriter, r *htt
 
 	credential, exists := h.credentials[credentialID]
 	if !exists {
-		http.Error(w, "Credential not found", http.StatusNotFound)
+		writeError(w, "NOT_FOUND", "Credential not found", http.StatusNotFound)
 		return
 	}
 
 	// For MVP, we'll do basic validation
 	// In production, you would verify the signature and check against a blockchain
 	valid := credential.Status == "valid"
+	status := credential.Status
+	message := "Credential verification completed"
+
+	// The issuer's status list is authoritative; if it cannot be checked the
+	// credential is not reported valid
+	if valid && credential.CredentialStatus != nil {
+		if h.statusLists == nil {
+			valid = false
+			message = "Credential status checking is not configured"
+		} else if result, err := h.statusLists.Check(r.Context(), credential.ID, credential.Issuer, credential.CredentialStatus); err != nil {
+			valid = false
+			message = fmt.Sprintf("Credential status could not be checked: %v", err)
+		} else if result.Revoked() {
+			valid = false
+			status = result.Status
+		}
+	}
 
 	response := map[string]interface{}{
 		"credential_id": credentialID,
 		"valid":         valid,
-		"status":        credential.Status,
-		"message":       "Credential verification completed",
+		"status":        status,
+		"message":       message,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -2,14 +2,17 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pavilion-trust/core-broker/internal/config"
@@ -306,4 +309,36 @@ func TestCredentialHandler(t *testing.T) {
 			})
 		}
 	})
-} 
\ No newline at end of file
+} 
+func TestCredentialHandler_HookFailuresDoNotAffectIssuance(t *testing.T) {
+	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	handler := NewCredentialHandler(&config.Config{Issuer: "https://test-issuer.com"}, services.NewCredentialSigningService(nil, ecdsaKey, "test-key-1", "test-issuer"))
+	hooks := services.NewCredentialHookService()
+	handler.SetCredentialHooks(hooks)
+
+	issued := make(chan string, 1)
+	services.RegisterCredentialHook("test_wallet", func(ctx context.Context, event services.CredentialIssuedEvent) error {
+		issued <- event.Credential.ID
+		return fmt.Errorf("wallet rejected credential: %w", services.ErrCredentialHookPermanent)
+	}, services.CredentialHookOptions{})
+	defer services.UnregisterCredentialHook("test_wallet")
+
+	body, _ := json.Marshal(CreateCredentialRequest{Type: "StudentCredential", Subject: "student-123", SigningMethod: "ECDSA"})
+	w := httptest.NewRecorder()
+	handler.HandleCreateCredential(w, httptest.NewRequest("POST", "/credentials", bytes.NewBuffer(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 despite the failing hook, got %d", w.Code)
+	}
+
+	select {
+	case id := <-issued:
+		for i := 0; i < 200 && hooks.Pending() > 0; i++ {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if runs := hooks.Runs(id, services.HookRunFailed); len(runs) != 1 {
+			t.Errorf("Expected the failed hook run to be recorded, got %+v", runs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the hook to run after issuance")
+	}
+}
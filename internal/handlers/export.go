@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// ExportHandler handles historical export requests from RPs
+type ExportHandler struct {
+	config        *config.Config
+	exportService *services.ExportService
+}
+
+// NewExportHandler creates a new export handler
+func NewExportHandler(cfg *config.Config, exportService *services.ExportService) *ExportHandler {
+	return &ExportHandler{
+		config:        cfg,
+		exportService: exportService,
+	}
+}
+
+// CreateExportRequest represents a request to export verification records
+type CreateExportRequest struct {
+	RPID           string `json:"rp_id"`
+	From           string `json:"from"`
+	To             string `json:"to"`
+	ClaimType      string `json:"claim_type,omitempty"`
+	RedactionLevel string `json:"redaction_level,omitempty"`
+}
+
+// HandleCreateExport handles POST /exports
+func (h *ExportHandler) HandleCreateExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req CreateExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "INVALID_REQUEST", fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	from, err := time.Parse(time.RFC3339, req.From)
+	if err != nil {
+		writeError(w, "INVALID_REQUEST", "from must be an RFC3339 timestamp", http.StatusBadRequest)
+		return
+	}
+	to, err := time.Parse(time.RFC3339, req.To)
+	if err != nil {
+		writeError(w, "INVALID_REQUEST", "to must be an RFC3339 timestamp", http.StatusBadRequest)
+		return
+	}
+
+	// RPs may only export their own records
+	requestedBy := ""
+	if userInfo, ok := ctx.Value("user").(*services.UserInfo); ok {
+		requestedBy = userInfo.Subject
+		if userInfo.ResourceID != "" {
+			if req.RPID == "" {
+				req.RPID = userInfo.ResourceID
+			}
+			if req.RPID != userInfo.ResourceID {
+				writeError(w, "AUTHORIZATION_DENIED", "RPs may only export their own records", http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	job, err := h.exportService.RequestExport(ctx, services.ExportRequest{
+		RPID:           req.RPID,
+		From:           from,
+		To:             to,
+		ClaimType:      req.ClaimType,
+		RedactionLevel: req.RedactionLevel,
+		RequestedBy:    requestedBy,
+	})
+	if err != nil {
+		writeError(w, "INVALID_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/exports/"+job.ExportID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// HandleGetExport handles GET /exports/{id}
+func (h *ExportHandler) HandleGetExport(w http.ResponseWriter, r *http.Request) {
+	exportID := mux.Vars(r)["id"]
+
+	job, err := h.exportService.GetExport(exportID)
+	if err != nil {
+		writeError(w, "NOT_FOUND", "Export not found", http.StatusNotFound)
+		return
+	}
+
+	if userInfo, ok := r.Context().Value("user").(*services.UserInfo); ok {
+		if userInfo.ResourceID != "" && userInfo.ResourceID != job.RPID {
+			writeError(w, "NOT_FOUND", "Export not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(job)
+}
+
+// HandleDownloadExport handles GET /exports/{id}/download via a signed URL
+func (h *ExportHandler) HandleDownloadExport(w http.ResponseWriter, r *http.Request) {
+	exportID := mux.Vars(r)["id"]
+	query := r.URL.Query()
+
+	archive, err := h.exportService.DownloadArchive(r.Context(), exportID, query.Get("expires"), query.Get("signature"))
+	if err != nil {
+		writeError(w, "EXPORT_UNAVAILABLE", err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exportID+".json.gz.enc"))
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
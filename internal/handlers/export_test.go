@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestExportHandler(t *testing.T) {
+	cfg := &config.Config{ExportSigningKey: "test-key", ExportURLTTL: time.Hour}
+	store := services.NewVerificationRecordStore()
+	store.Append(&services.VerificationRecord{VerificationID: "v1", RPID: "rp_1", UserID: "user_1", ClaimType: "age_verification", CreatedAt: time.Now().Add(-time.Minute)})
+
+	exportService := services.NewExportService(cfg, store, services.NewAuditService(cfg))
+	handler := NewExportHandler(cfg, exportService)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/exports", handler.HandleCreateExport).Methods("POST")
+	router.HandleFunc("/exports/{id}", handler.HandleGetExport).Methods("GET")
+	router.HandleFunc("/api/v1/exports/{id}/download", handler.HandleDownloadExport).Methods("GET")
+
+	rpUser := &services.UserInfo{Subject: "user-rp-1", ResourceID: "rp_1", Roles: []string{"rp"}}
+
+	t.Run("RejectsOtherRP", func(t *testing.T) {
+		body, _ := json.Marshal(CreateExportRequest{
+			RPID: "rp_2",
+			From: time.Now().Add(-time.Hour).Format(time.RFC3339),
+			To:   time.Now().Format(time.RFC3339),
+		})
+		req := httptest.NewRequest("POST", "/exports", bytes.NewBuffer(body))
+		req = req.WithContext(context.WithValue(req.Context(), "user", rpUser))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", w.Code)
+		}
+	})
+
+	t.Run("CreateAndDownload", func(t *testing.T) {
+		body, _ := json.Marshal(CreateExportRequest{
+			From: time.Now().Add(-time.Hour).Format(time.RFC3339),
+			To:   time.Now().Format(time.RFC3339),
+		})
+		req := httptest.NewRequest("POST", "/exports", bytes.NewBuffer(body))
+		req = req.WithContext(context.WithValue(req.Context(), "user", rpUser))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var job services.ExportJob
+		json.NewDecoder(w.Body).Decode(&job)
+		if job.RPID != "rp_1" {
+			t.Errorf("Expected RP to default to the caller, got %s", job.RPID)
+		}
+
+		// Wait for completion
+		var completed services.ExportJob
+		for i := 0; i < 100; i++ {
+			req = httptest.NewRequest("GET", "/exports/"+job.ExportID, nil)
+			req = req.WithContext(context.WithValue(req.Context(), "user", rpUser))
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			json.NewDecoder(w.Body).Decode(&completed)
+			if completed.Status == services.ExportCompleted {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if completed.Status != services.ExportCompleted {
+			t.Fatalf("Expected export to complete, got %s", completed.Status)
+		}
+
+		downloadURL, _ := url.Parse(completed.DownloadURL)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", downloadURL.RequestURI(), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		if w.Body.Len() == 0 {
+			t.Error("Expected archive body")
+		}
+
+		// Tampered signature is rejected
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/exports/"+job.ExportID+"/download?expires=9999999999&signature=bad", nil))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for bad signature, got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// Page sizes of the verifications query
+const (
+	defaultGraphQLVerifications = 10
+	maxGraphQLVerifications     = 100
+)
+
+// GraphQLHandler serves the RP-facing GraphQL API, which answers in one
+// request what the REST endpoints answer separately: verification results,
+// audit references, claim types and ZKP circuits. Every query is scoped to
+// the caller's tenant.
+type GraphQLHandler struct {
+	config         *config.Config
+	auditService   *services.AuditService
+	catalogService *services.ClaimCatalogService
+	zkpService     *services.ZKPService
+	schema         *services.GraphQLSchema
+}
+
+// NewGraphQLHandler creates a new GraphQL handler
+func NewGraphQLHandler(cfg *config.Config, auditService *services.AuditService, catalogService *services.ClaimCatalogService, zkpService *services.ZKPService) *GraphQLHandler {
+	h := &GraphQLHandler{
+		config:         cfg,
+		auditService:   auditService,
+		catalogService: catalogService,
+		zkpService:     zkpService,
+	}
+	h.schema = &services.GraphQLSchema{
+		Query: services.GraphQLObject{
+			"verification": {
+				Type:    "Verification",
+				Args:    map[string]string{"requestId": "String!"},
+				Resolve: h.resolveVerification,
+			},
+			"verifications": {
+				Type:    "[Verification!]!",
+				Args:    map[string]string{"claimType": "String", "since": "String", "limit": "Int"},
+				Resolve: h.resolveVerifications,
+			},
+			"auditReference": {
+				Type:    "AuditReference",
+				Args:    map[string]string{"auditEntryId": "ID!"},
+				Resolve: h.resolveAuditReference,
+			},
+			"claimTypes": {Type: "[ClaimType!]!", Resolve: h.resolveClaimTypes},
+			"circuits":   {Type: "[Circuit!]!", Resolve: h.resolveCircuits},
+		},
+		Types: map[string]services.GraphQLObject{
+			"Verification": {
+				"requestId":       {Type: "String!"},
+				"auditEntryId":    {Type: "ID"},
+				"claimType":       {Type: "String!"},
+				"status":          {Type: "String!"},
+				"policyDecision":  {Type: "String!"},
+				"dpId":            {Type: "String"},
+				"verificationId":  {Type: "String"},
+				"verified":        {Type: "Boolean"},
+				"confidenceScore": {Type: "Float"},
+				"timestamp":       {Type: "String!"},
+				"problem":         {Type: "Problem"},
+				"auditReference":  {Type: "AuditReference"},
+			},
+			"Problem": {
+				"code":   {Type: "String!"},
+				"status": {Type: "Int!"},
+				"title":  {Type: "String!"},
+				"detail": {Type: "String"},
+			},
+			"AuditReference": {
+				"auditEntryId": {Type: "ID!"},
+				"merkleProof":  {Type: "String!"},
+				"timestamp":    {Type: "String!"},
+				"hash":         {Type: "String!"},
+			},
+			"ClaimType": {
+				"claimType":           {Type: "String!"},
+				"description":         {Type: "String"},
+				"requiredIdentifiers": {Type: "[String!]!"},
+				"optionalIdentifiers": {Type: "[String!]"},
+				"dpCategories":        {Type: "[String!]"},
+				"providerCount":       {Type: "Int!"},
+				"typicalLatencyMs":    {Type: "Int"},
+				"deprecated":          {Type: "Boolean!"},
+			},
+			"Circuit": {
+				"name":        {Type: "String!"},
+				"description": {Type: "String"},
+				"inputs":      {Type: "[String!]!"},
+				"outputs":     {Type: "[String!]!"},
+				"builtin":     {Type: "Boolean!"},
+			},
+		},
+		MaxDepth:      cfg.GraphQLMaxDepth,
+		MaxComplexity: cfg.GraphQLMaxComplexity,
+	}
+	return h
+}
+
+// HandleQuery handles GET and POST /graphql. Requests refused before they
+// ran, for failing to parse or validate or for exceeding the depth and
+// complexity limits, are answered with 400; others with 200, any resolver
+// errors listed alongside the data.
+func (h *GraphQLHandler) HandleQuery(w http.ResponseWriter, r *http.Request) {
+	if getCallerRPID(r.Context()) == "" {
+		writeError(w, "AUTHORIZATION_DENIED", "No tenant associated with caller", http.StatusForbidden)
+		return
+	}
+
+	var req services.GraphQLRequest
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			decoder := json.NewDecoder(strings.NewReader(variables))
+			decoder.UseNumber()
+			if err := decoder.Decode(&req.Variables); err != nil {
+				writeError(w, "INVALID_REQUEST", "variables must be a JSON object", http.StatusBadRequest)
+				return
+			}
+		}
+	} else {
+		decoder := json.NewDecoder(r.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&req); err != nil {
+			writeError(w, "INVALID_JSON", "Failed to parse request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeError(w, "INVALID_REQUEST", "query is required", http.StatusBadRequest)
+		return
+	}
+
+	response := h.schema.Execute(r.Context(), req)
+	statusCode := http.StatusOK
+	if response.Data == nil {
+		statusCode = http.StatusBadRequest
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *GraphQLHandler) resolveVerification(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+	entry, err := h.auditService.VerificationEntry(ctx, getCallerRPID(ctx), args["requestId"].(string))
+	if errors.Is(err, services.ErrStorageNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h.verificationObject(entry), nil
+}
+
+func (h *GraphQLHandler) resolveVerifications(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+	var since time.Time
+	if value, ok := args["since"].(string); ok {
+		var err error
+		if since, err = time.Parse(time.RFC3339, value); err != nil {
+			return nil, fmt.Errorf("since must be an RFC 3339 timestamp")
+		}
+	}
+	limit := defaultGraphQLVerifications
+	if value, ok := args["limit"].(int); ok {
+		if value < 1 || value > maxGraphQLVerifications {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxGraphQLVerifications)
+		}
+		limit = value
+	}
+	claimType, _ := args["claimType"].(string)
+
+	entries, err := h.auditService.VerificationEntries(ctx, getCallerRPID(ctx), since, 0)
+	if err != nil {
+		return nil, err
+	}
+	verifications := make([]interface{}, 0, limit)
+	for _, entry := range entries {
+		if claimType != "" && entry.ClaimType != claimType {
+			continue
+		}
+		verifications = append(verifications, h.verificationObject(entry))
+		if len(verifications) == limit {
+			break
+		}
+	}
+	return verifications, nil
+}
+
+func (h *GraphQLHandler) resolveAuditReference(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+	reference, err := h.auditService.AuditReferenceForRP(ctx, getCallerRPID(ctx), args["auditEntryId"].(string))
+	if errors.Is(err, services.ErrStorageNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return auditReferenceObject(reference), nil
+}
+
+func (h *GraphQLHandler) resolveClaimTypes(ctx context.Context, _ interface{}, _ map[string]interface{}) (interface{}, error) {
+	entries := h.catalogService.Catalog(getCallerRPID(ctx)).ClaimTypes
+	claimTypes := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		claimTypes[i] = map[string]interface{}{
+			"claimType":           entry.ClaimType,
+			"description":         entry.Description,
+			"requiredIdentifiers": entry.RequiredIdentifiers,
+			"optionalIdentifiers": entry.OptionalIdentifiers,
+			"dpCategories":        entry.DPCategories,
+			"providerCount":       entry.ProviderCount,
+			"typicalLatencyMs":    entry.TypicalLatencyMs,
+			"deprecated":          entry.Lifecycle != nil,
+		}
+	}
+	return claimTypes, nil
+}
+
+func (h *GraphQLHandler) resolveCircuits(_ context.Context, _ interface{}, _ map[string]interface{}) (interface{}, error) {
+	supported := h.zkpService.GetSupportedCircuits()
+	circuits := make([]interface{}, len(supported))
+	for i, circuit := range supported {
+		circuits[i] = map[string]interface{}{
+			"name":        circuit.Name,
+			"description": circuit.Description,
+			"inputs":      circuit.Inputs,
+			"outputs":     circuit.Outputs,
+			"builtin":     circuit.Builtin,
+		}
+	}
+	return circuits, nil
+}
+
+// verificationObject presents a verification's audit entry. The verdict
+// fields are only set when the entry's metadata still holds them.
+func (h *GraphQLHandler) verificationObject(entry *models.AuditEntry) map[string]interface{} {
+	verification := map[string]interface{}{
+		"requestId":       entry.RequestID,
+		"auditEntryId":    entry.Metadata["audit_entry_id"],
+		"claimType":       entry.ClaimType,
+		"status":          entry.Status,
+		"policyDecision":  entry.PolicyDecision,
+		"dpId":            entry.DPID,
+		"verificationId":  entry.Metadata["verification_id"],
+		"verified":        entry.Metadata["verified"],
+		"confidenceScore": entry.Metadata["confidence_score"],
+		"timestamp":       entry.Timestamp,
+	}
+	if entry.DPID == "" {
+		verification["dpId"] = nil
+	}
+	if entry.Problem != nil {
+		verification["problem"] = map[string]interface{}{
+			"code":   entry.Problem.Code,
+			"status": entry.Problem.Status,
+			"title":  entry.Problem.Title,
+			"detail": entry.Problem.Detail,
+		}
+	}
+	if reference, err := h.auditService.AuditReferenceOf(entry); err == nil {
+		verification["auditReference"] = auditReferenceObject(reference)
+	}
+	return verification
+}
+
+func auditReferenceObject(reference *services.AuditReference) map[string]interface{} {
+	return map[string]interface{}{
+		"auditEntryId": reference.AuditEntryID,
+		"merkleProof":  reference.MerkleProof,
+		"timestamp":    reference.Timestamp,
+		"hash":         reference.Hash,
+	}
+}
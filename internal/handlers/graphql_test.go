@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestGraphQLHandler_HandleQuery(t *testing.T) {
+	cfg := &config.Config{OPAURL: "http://invalid-opa-url:8181", DPConnectorURL: "http://localhost:8081", GraphQLMaxDepth: 4, GraphQLMaxComplexity: 200}
+	verificationHandler := NewVerificationHandler(cfg)
+	auditService := services.NewAuditService(cfg)
+	auditService.SetStorage(services.NewMemoryStorage())
+	catalogService := services.NewClaimCatalogService(verificationHandler.SchemaRegistry(), verificationHandler.DPService(), verificationHandler.AuthorizationService())
+	zkpService := services.NewZKPService(services.ZKPConfigFromConfig(cfg))
+	handler := NewGraphQLHandler(cfg, auditService, catalogService, zkpService)
+
+	for _, entry := range []struct{ rpID, requestID string }{{"rp_1", "req-1"}, {"rp_2", "req-2"}} {
+		ctx := context.WithValue(context.Background(), services.RequestIDKey, entry.requestID)
+		req := models.VerificationRequest{RPID: entry.rpID, UserID: "user-1", ClaimType: "age_verification"}
+		auditService.LogVerification(ctx, req, &models.VerificationResponse{Verified: true, DPID: "dp-1"}, "SUCCESS")
+	}
+
+	query := func(body string, user *services.UserInfo) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req := httptest.NewRequest("POST", "/api/v1/graphql", bytes.NewBufferString(body))
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), "user", user))
+		}
+		w := httptest.NewRecorder()
+		handler.HandleQuery(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	rpUser := &services.UserInfo{Subject: "user-rp-1", ResourceID: "rp_1", Roles: []string{"rp"}}
+
+	t.Run("one request across resources", func(t *testing.T) {
+		w, response := query(`{"query":"{ verifications { requestId verified auditReference { auditEntryId } } claimTypes { claimType } circuits { name } }"}`, rpUser)
+		if w.Code != http.StatusOK || response["errors"] != nil {
+			t.Fatalf("Expected data, got %d %v", w.Code, response)
+		}
+		data := response["data"].(map[string]interface{})
+		verifications := data["verifications"].([]interface{})
+		if len(verifications) != 1 {
+			t.Fatalf("Expected only the caller's verification, got %v", verifications)
+		}
+		verification := verifications[0].(map[string]interface{})
+		if verification["requestId"] != "req-1" || verification["verified"] != true || verification["auditReference"] == nil {
+			t.Errorf("Unexpected verification: %v", verification)
+		}
+		if len(data["claimTypes"].([]interface{})) == 0 || len(data["circuits"].([]interface{})) == 0 {
+			t.Errorf("Expected claim types and circuits, got %v", data)
+		}
+	})
+
+	t.Run("other tenants' records are not found", func(t *testing.T) {
+		_, response := query(`{"query":"query($id: String!) { verification(requestId: $id) { status } }","variables":{"id":"req-2"}}`, rpUser)
+		data := response["data"].(map[string]interface{})
+		if data["verification"] != nil {
+			t.Errorf("Expected no verification, got %v", data["verification"])
+		}
+	})
+
+	t.Run("complexity limit", func(t *testing.T) {
+		w, response := query(`{"query":"{ verifications(limit: 100) { requestId status claimType } }"}`, rpUser)
+		errors, _ := response["errors"].([]interface{})
+		if w.Code != http.StatusBadRequest || len(errors) != 1 || response["data"] != nil {
+			t.Fatalf("Expected the query to be refused, got %d %v", w.Code, response)
+		}
+		if code := errors[0].(map[string]interface{})["extensions"].(map[string]interface{})["code"]; code != services.GraphQLQueryTooComplex {
+			t.Errorf("Expected %s, got %v", services.GraphQLQueryTooComplex, code)
+		}
+	})
+
+	t.Run("no tenant", func(t *testing.T) {
+		if w, _ := query(`{"query":"{ circuits { name } }"}`, nil); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", w.Code)
+		}
+	})
+}
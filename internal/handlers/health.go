@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -21,6 +22,9 @@ type HealthHandler struct {
 	keycloakService          *services.KeycloakService
 	privacyService           *services.PrivacyService
 	privacyGuaranteesService *services.PrivacyGuaranteesService
+	selfTestReport           *services.SelfTestReport
+	timeSync                 *services.TimeSyncChecker
+	drainer                  *services.Drainer
 	// Performance metrics
 	startTime    time.Time
 	requestCount int64
@@ -198,6 +202,30 @@ func (h *HealthHandler) HandleHealth(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Check the system clock against trusted time sources. Drift beyond the
+	// skew tolerance breaks token and proof validation.
+	if h.timeSync != nil {
+		if report := h.timeSync.LastReport(); report != nil {
+			switch report.Status {
+			case services.TimeSyncCritical:
+				health.Dependencies["clock"] = DependencyStatus{
+					Status: "unhealthy",
+					Error:  fmt.Sprintf("clock offset %.0fms exceeds skew tolerance", report.OffsetMs),
+				}
+			case services.TimeSyncWarning, services.TimeSyncUnknown:
+				health.Dependencies["clock"] = DependencyStatus{
+					Status: "degraded",
+					Error:  fmt.Sprintf("clock offset %.0fms (%s)", report.OffsetMs, report.Status),
+				}
+				health.Status = "degraded"
+			default:
+				health.Dependencies["clock"] = DependencyStatus{
+					Status: "healthy",
+				}
+			}
+		}
+	}
+
 	// If any dependency is unhealthy, mark overall status as unhealthy
 	for _, dep := range health.Dependencies {
 		if dep.Status == "unhealthy" {
@@ -220,6 +248,61 @@ func (h *HealthHandler) HandleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(health)
 }
 
+// SetSelfTestReport records the startup cryptographic self-test results
+func (h *HealthHandler) SetSelfTestReport(report *services.SelfTestReport) {
+	h.selfTestReport = report
+}
+
+// SetTimeSyncChecker reports clock drift as the "clock" dependency
+func (h *HealthHandler) SetTimeSyncChecker(checker *services.TimeSyncChecker) {
+	h.timeSync = checker
+}
+
+// SetDrainer reports the broker as not ready once a drain starts, so the load
+// balancer stops routing to it
+func (h *HealthHandler) SetDrainer(drainer *services.Drainer) {
+	h.drainer = drainer
+}
+
+// HandleReadiness reports whether the broker may receive traffic. The broker
+// is not ready until the cryptographic self-tests have run and passed, nor
+// while it drains for a deploy.
+func (h *HealthHandler) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	readiness := &ReadinessResponse{
+		Status:    "ready",
+		Timestamp: time.Now().Format(time.RFC3339),
+		SelfTest:  h.selfTestReport,
+	}
+
+	statusCode := http.StatusOK
+	if h.selfTestReport == nil || !h.selfTestReport.Passed {
+		readiness.Status = "not_ready"
+		statusCode = http.StatusServiceUnavailable
+	}
+	if h.drainer != nil && h.drainer.Draining() {
+		status := h.drainer.Status()
+		readiness.Status = "not_ready"
+		readiness.Drain = &status
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(readiness)
+}
+
+// DependencyProbes returns the checks of the services the handler reports
+// on, for the gateway's Kubernetes probes. The audit check runs in process,
+// so it also gates liveness.
+func (h *HealthHandler) DependencyProbes() []services.HealthProbe {
+	return []services.HealthProbe{
+		{Name: "dp_connector", Check: h.dpService.HealthCheck},
+		{Name: "audit", Liveness: true, Check: h.auditService.HealthCheck},
+		{Name: "cache", Check: h.cacheService.HealthCheck},
+		{Name: "policy", Check: h.policyService.HealthCheck},
+	}
+}
+
 // calculateErrorRate calculates the error rate as a percentage
 func (h *HealthHandler) calculateErrorRate() float64 {
 	if h.requestCount == 0 {
@@ -238,6 +321,14 @@ type HealthResponse struct {
 	Performance  PerformanceMetrics          `json:"performance"`
 }
 
+// ReadinessResponse represents the readiness check response
+type ReadinessResponse struct {
+	Status    string                   `json:"status"`
+	Timestamp string                   `json:"timestamp"`
+	SelfTest  *services.SelfTestReport `json:"self_test,omitempty"`
+	Drain     *services.DrainStatus    `json:"drain,omitempty"`
+}
+
 // DependencyStatus represents the status of a dependency
 type DependencyStatus struct {
 	Status  string                 `json:"status"`
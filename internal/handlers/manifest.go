@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// ManifestHandler serves the broker's signed capability manifest
+type ManifestHandler struct {
+	manifests *services.BrokerManifestService
+}
+
+// NewManifestHandler creates a new manifest handler
+func NewManifestHandler(manifests *services.BrokerManifestService) *ManifestHandler {
+	return &ManifestHandler{manifests: manifests}
+}
+
+// HandleGetManifest handles GET /.well-known/pavilion-broker. The manifest
+// is returned with its signed form, or only the signed JWT when the client
+// accepts application/jwt.
+func (h *ManifestHandler) HandleGetManifest(w http.ResponseWriter, r *http.Request) {
+	signed, err := h.manifests.Manifest()
+	if err != nil {
+		writeError(w, "MANIFEST_UNAVAILABLE", "The broker manifest could not be signed", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	if strings.Contains(r.Header.Get("Accept"), "application/jwt") {
+		w.Header().Set("Content-Type", "application/jwt")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(signed.SignedManifest))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(signed)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestManifestHandler(t *testing.T) {
+	cfg := &config.Config{Issuer: "https://broker-a.example.com"}
+	handler := NewManifestHandler(services.NewBrokerManifestService(cfg, services.NewJWSAttestationService(cfg)))
+
+	w := httptest.NewRecorder()
+	handler.HandleGetManifest(w, httptest.NewRequest("GET", services.BrokerManifestPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var signed services.SignedBrokerManifest
+	if err := json.Unmarshal(w.Body.Bytes(), &signed); err != nil || signed.Manifest.Issuer != cfg.Issuer || signed.SignedManifest == "" {
+		t.Errorf("Expected the manifest with its signed form, got %s", w.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", services.BrokerManifestPath, nil)
+	req.Header.Set("Accept", "application/jwt")
+	w = httptest.NewRecorder()
+	handler.HandleGetManifest(w, req)
+	if w.Header().Get("Content-Type") != "application/jwt" || strings.Count(w.Body.String(), ".") != 2 {
+		t.Errorf("Expected the signed manifest as a JWT, got %q", w.Body.String())
+	}
+
+	unsigned := NewManifestHandler(services.NewBrokerManifestService(cfg, nil))
+	w = httptest.NewRecorder()
+	unsigned.HandleGetManifest(w, httptest.NewRequest("GET", services.BrokerManifestPath, nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without a signing key, got %d", w.Code)
+	}
+}
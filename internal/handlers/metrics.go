@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// MetricsHandler exposes service metrics and per-tenant metric views
+type MetricsHandler struct {
+	config         *config.Config
+	metricsService *services.MetricsService
+}
+
+// NewMetricsHandler creates a new metrics handler
+func NewMetricsHandler(cfg *config.Config, metricsService *services.MetricsService) *MetricsHandler {
+	return &MetricsHandler{
+		config:         cfg,
+		metricsService: metricsService,
+	}
+}
+
+// TenantOverviewResponse lists the busiest tenants
+type TenantOverviewResponse struct {
+	Tenants []services.TenantStats `json:"tenants"`
+}
+
+// HandlePrometheus handles GET /metrics in Prometheus text format
+func (h *MetricsHandler) HandlePrometheus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(h.metricsService.GetPrometheusMetrics()))
+}
+
+// HandleTenantMetrics handles GET /metrics/tenant, returning only the caller's own metrics
+func (h *MetricsHandler) HandleTenantMetrics(w http.ResponseWriter, r *http.Request) {
+	rpID := getCallerRPID(r.Context())
+	if rpID == "" {
+		writeError(w, "AUTHORIZATION_DENIED", "No tenant associated with caller", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.metricsService.GetTenantStats(rpID))
+}
+
+// HandleTenantOverview handles GET /metrics/tenants, listing the top-N tenants plus "other"
+func (h *MetricsHandler) HandleTenantOverview(w http.ResponseWriter, r *http.Request) {
+	top := 0
+	if value := r.URL.Query().Get("top"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 100 {
+			writeError(w, "INVALID_REQUEST", "top must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		top = parsed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TenantOverviewResponse{Tenants: h.metricsService.GetTopTenants(top)})
+}
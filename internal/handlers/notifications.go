@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// webhookListSpec pages GET /notifications/webhooks
+var webhookListSpec = ListSpec[services.WebhookSubscription]{
+	Name: "webhooks",
+	Sorts: map[string]func(a, b services.WebhookSubscription) int{
+		"created_at": compareTimes(func(s services.WebhookSubscription) time.Time { return s.CreatedAt }),
+		"url":        compareStrings(func(s services.WebhookSubscription) string { return s.URL }),
+	},
+	DefaultSort: "created_at",
+	Key:         func(s services.WebhookSubscription) string { return s.ID },
+}
+
+// deliveryListSpec pages GET /notifications/deliveries, newest first
+var deliveryListSpec = ListSpec[services.NotificationDelivery]{
+	Name: "deliveries",
+	Sorts: map[string]func(a, b services.NotificationDelivery) int{
+		"created_at": compareTimes(func(d services.NotificationDelivery) time.Time { return d.CreatedAt }),
+		"attempts":   compareInts(func(d services.NotificationDelivery) int { return d.Attempts }),
+	},
+	DefaultSort: "-created_at",
+	Filters: map[string]func(services.NotificationDelivery, string) bool{
+		"status":          equalsFilter(func(d services.NotificationDelivery) string { return d.Status }),
+		"event":           equalsFilter(func(d services.NotificationDelivery) string { return d.Event }),
+		"subscription_id": equalsFilter(func(d services.NotificationDelivery) string { return d.SubscriptionID }),
+	},
+	Key: func(d services.NotificationDelivery) string { return d.ID },
+}
+
+// NotificationHandler lets RPs register webhooks and open WebSocket streams
+// for notifications about their asynchronous verifications
+type NotificationHandler struct {
+	notifications *services.NotificationService
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(notifications *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notifications: notifications}
+}
+
+// RegisterWebhookRequest represents a request to register a webhook
+type RegisterWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+}
+
+// RegisterWebhookResponse returns a new webhook and the secret its
+// deliveries are signed with, which is not shown again
+type RegisterWebhookResponse struct {
+	Webhook *services.WebhookSubscription `json:"webhook"`
+	Secret  string                        `json:"secret"`
+}
+
+// HandleRegisterWebhook handles POST /notifications/webhooks
+func (h *NotificationHandler) HandleRegisterWebhook(w http.ResponseWriter, r *http.Request) {
+	rpID := getCallerRPID(r.Context())
+	if rpID == "" {
+		writeError(w, "AUTHORIZATION_DENIED", "Notifications are only available to RPs", http.StatusForbidden)
+		return
+	}
+
+	var req RegisterWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "INVALID_REQUEST", fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	webhook, secret, err := h.notifications.RegisterWebhook(rpID, req.URL, req.Events)
+	if err != nil {
+		writeError(w, "INVALID_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Location", "/api/v1/notifications/webhooks/"+webhook.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(RegisterWebhookResponse{Webhook: webhook, Secret: secret})
+}
+
+// HandleListWebhooks handles GET /notifications/webhooks
+func (h *NotificationHandler) HandleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	query, ok := parseList(w, r, webhookListSpec)
+	if !ok {
+		return
+	}
+	page := webhookListSpec.Paginate(h.notifications.Webhooks(getCallerRPID(r.Context())), query)
+	writePage(w, r, "webhooks", page, nil)
+}
+
+// HandleDeleteWebhook handles DELETE /notifications/webhooks/{id}
+func (h *NotificationHandler) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := h.notifications.DeleteWebhook(getCallerRPID(r.Context()), mux.Vars(r)["id"]); err != nil {
+		writeError(w, "NOT_FOUND", "Webhook not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListDeliveries handles GET /notifications/deliveries
+func (h *NotificationHandler) HandleListDeliveries(w http.ResponseWriter, r *http.Request) {
+	query, ok := parseList(w, r, deliveryListSpec)
+	if !ok {
+		return
+	}
+	page := deliveryListSpec.Paginate(h.notifications.Deliveries(getCallerRPID(r.Context())), query)
+	writePage(w, r, "deliveries", page, nil)
+}
+
+// HandleStream handles GET /notifications/ws, pushing the RP's
+// notifications as JSON text messages over a WebSocket
+func (h *NotificationHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
+	rpID := getCallerRPID(r.Context())
+	if rpID == "" {
+		writeError(w, "AUTHORIZATION_DENIED", "Notifications are only available to RPs", http.StatusForbidden)
+		return
+	}
+	if err := checkWebSocketRequest(r); err != nil {
+		w.Header().Set("Upgrade", "websocket")
+		writeError(w, "WEBSOCKET_REQUIRED", err.Error(), http.StatusUpgradeRequired)
+		return
+	}
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		writeError(w, "INTERNAL_ERROR", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	notifications, cancel := h.notifications.Stream(rpID)
+	defer cancel()
+
+	closed := make(chan struct{})
+	go func() {
+		conn.readLoop()
+		close(closed)
+	}()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case notification, ok := <-notifications:
+			if !ok {
+				// The stream fell behind; the RP reconnects and checks its batches
+				conn.Close(wsCloseTryAgain, "stream fell behind")
+				return
+			}
+			data, _ := json.Marshal(notification)
+			if err := conn.WriteText(data); err != nil {
+				conn.conn.Close()
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.Ping(); err != nil {
+				conn.conn.Close()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func withRP(handler http.HandlerFunc, rpID string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := &services.UserInfo{Subject: "client-1", ResourceID: rpID, Roles: []string{"rp"}}
+		handler(w, r.WithContext(context.WithValue(r.Context(), "user", user)))
+	})
+}
+
+func TestNotificationHandler_Webhooks(t *testing.T) {
+	handler := NewNotificationHandler(services.NewNotificationService(&config.Config{}, nil))
+
+	w := httptest.NewRecorder()
+	body := `{"url": "https://rp.example.com/hooks", "events": ["batch.completed"]}`
+	withRP(handler.HandleRegisterWebhook, "rp-1").ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/notifications/webhooks", strings.NewReader(body)))
+	var registered RegisterWebhookResponse
+	json.Unmarshal(w.Body.Bytes(), &registered)
+	if w.Code != http.StatusCreated || registered.Webhook == nil || !strings.HasPrefix(registered.Secret, "whsec_") {
+		t.Fatalf("Expected the webhook and its secret, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	withRP(handler.HandleListWebhooks, "rp-1").ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/notifications/webhooks", nil))
+	if !strings.Contains(w.Body.String(), registered.Webhook.ID) || strings.Contains(w.Body.String(), registered.Secret) {
+		t.Errorf("Expected the webhook listed without its secret, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	withRP(handler.HandleRegisterWebhook, "rp-1").ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/notifications/webhooks", strings.NewReader(`{"url": "http://rp.example.com/hooks"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a plain HTTP webhook to be rejected, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.HandleRegisterWebhook(w, httptest.NewRequest("POST", "/api/v1/notifications/webhooks", strings.NewReader(body)))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected callers without an RP to be refused, got %d", w.Code)
+	}
+}
+
+func TestNotificationHandler_WebSocketStream(t *testing.T) {
+	notifications := services.NewNotificationService(&config.Config{}, nil)
+	handler := NewNotificationHandler(notifications)
+	server := httptest.NewServer(withRP(handler.HandleStream, "rp-1"))
+	defer server.Close()
+
+	w := httptest.NewRecorder()
+	withRP(handler.HandleStream, "rp-1").ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/notifications/ws", nil))
+	if w.Code != http.StatusUpgradeRequired {
+		t.Errorf("Expected plain requests to be told to upgrade, got %d", w.Code)
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET /api/v1/notifications/ws HTTP/1.1\r\nHost: broker\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Failed to read handshake: %v", err)
+	}
+	// The accept value for the RFC 6455 sample key
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Unexpected handshake: %d %v", resp.StatusCode, resp.Header)
+	}
+
+	// The stream is registered once the handshake completes
+	for i := 0; i < 100; i++ {
+		notifications.NotifyBatchCompleted("rp-1", services.BatchProgress{BatchID: "batch_1", Total: 1, Succeeded: 1})
+		header := make([]byte, 2)
+		conn.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+		if _, err := io.ReadFull(reader, header); err != nil {
+			continue
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if header[0] != 0x80|wsOpText || header[1]&0x80 != 0 {
+			t.Fatalf("Expected an unmasked text frame, got %x", header)
+		}
+		length := int(header[1] & 0x7F)
+		if length == 126 {
+			extended := make([]byte, 2)
+			io.ReadFull(reader, extended)
+			length = int(binary.BigEndian.Uint16(extended))
+		}
+		payload := make([]byte, length)
+		io.ReadFull(reader, payload)
+		var notification services.RPNotification
+		if err := json.Unmarshal(payload, &notification); err != nil || notification.ResourceID != "batch_1" {
+			t.Fatalf("Unexpected notification %s: %v", payload, err)
+		}
+		return
+	}
+	t.Fatal("Expected a notification over the WebSocket")
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page sizes of list endpoints that do not set their own
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// ListSpec describes how a list endpoint pages, filters and sorts. List
+// endpoints share the query parameters limit, cursor and sort, so clients
+// page through every list the same way:
+//
+//   - limit is the page size, capped at MaxLimit
+//   - sort names a field in Sorts, prefixed with "-" to sort descending
+//   - cursor is the next_cursor of the previous page
+//
+// Filters are matched exactly against the query parameter of their name.
+type ListSpec[T any] struct {
+	// Name identifies the list in its cursors, so that a cursor of one list
+	// is refused by another
+	Name         string
+	DefaultLimit int
+	MaxLimit     int
+	// Sorts are the fields the list can be sorted by. Items sorting equal
+	// are ordered by Key.
+	Sorts       map[string]func(a, b T) int
+	DefaultSort string
+	Filters     map[string]func(item T, value string) bool
+	// Key identifies an item; cursors point past the last item of a page
+	Key func(T) string
+}
+
+// ListQuery is a parsed list request
+type ListQuery struct {
+	Limit   int
+	Sort    string
+	Filters map[string]string
+	cursor  *listCursor
+}
+
+// Page is one page of a list
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+}
+
+// listCursor is the content of a cursor token: the list and sort it was
+// issued for, and the key and position of the last item it returned. A page
+// resumes after that item, or at its position if the item is gone.
+type listCursor struct {
+	List     string `json:"l"`
+	Sort     string `json:"s"`
+	After    string `json:"a"`
+	Position int    `json:"p"`
+}
+
+// ListError is a list request the endpoint does not accept
+type ListError struct {
+	Code    string
+	Message string
+}
+
+func (e *ListError) Error() string {
+	return e.Message
+}
+
+// ParseListQuery parses the paging, sorting and filter parameters of a
+// list request
+func (spec ListSpec[T]) ParseListQuery(values url.Values) (*ListQuery, error) {
+	query := &ListQuery{Limit: spec.DefaultLimit, Sort: spec.DefaultSort, Filters: make(map[string]string)}
+	if query.Limit <= 0 {
+		query.Limit = defaultListLimit
+	}
+	maxLimit := spec.MaxLimit
+	if maxLimit <= 0 {
+		maxLimit = maxListLimit
+	}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return nil, &ListError{Code: "INVALID_REQUEST", Message: "limit must be a positive integer"}
+		}
+		query.Limit = limit
+	}
+	if query.Limit > maxLimit {
+		query.Limit = maxLimit
+	}
+
+	if value := values.Get("sort"); value != "" {
+		if _, exists := spec.Sorts[strings.TrimPrefix(value, "-")]; !exists {
+			return nil, &ListError{Code: "INVALID_SORT", Message: fmt.Sprintf("cannot sort by %q; sortable fields are %s", value, strings.Join(spec.sortFields(), ", "))}
+		}
+		query.Sort = value
+	}
+
+	if value := values.Get("cursor"); value != "" {
+		cursor, err := decodeListCursor(value)
+		if err != nil || cursor.List != spec.Name || cursor.Position < 0 {
+			return nil, &ListError{Code: "INVALID_CURSOR", Message: "cursor is not valid for this list"}
+		}
+		if cursor.Sort != query.Sort {
+			return nil, &ListError{Code: "INVALID_CURSOR", Message: fmt.Sprintf("cursor was issued for sort %q", cursor.Sort)}
+		}
+		query.cursor = cursor
+	}
+
+	for name := range spec.Filters {
+		if value := values.Get(name); value != "" {
+			query.Filters[name] = value
+		}
+	}
+	return query, nil
+}
+
+// StorageFilters returns the query's filters in the form storage list
+// methods take
+func (q *ListQuery) StorageFilters() map[string]interface{} {
+	filters := make(map[string]interface{}, len(q.Filters))
+	for name, value := range q.Filters {
+		filters[name] = value
+	}
+	return filters
+}
+
+// Paginate filters and sorts items and returns the page the query asks for
+func (spec ListSpec[T]) Paginate(items []T, query *ListQuery) Page[T] {
+	matching := make([]T, 0, len(items))
+	for _, item := range items {
+		if spec.matches(item, query.Filters) {
+			matching = append(matching, item)
+		}
+	}
+
+	field, descending := strings.TrimPrefix(query.Sort, "-"), strings.HasPrefix(query.Sort, "-")
+	compare := spec.Sorts[field]
+	sort.SliceStable(matching, func(i, j int) bool {
+		order := 0
+		if compare != nil {
+			order = compare(matching[i], matching[j])
+		}
+		if order == 0 {
+			order = strings.Compare(spec.Key(matching[i]), spec.Key(matching[j]))
+		}
+		if descending {
+			return order > 0
+		}
+		return order < 0
+	})
+
+	start := 0
+	if query.cursor != nil {
+		start = query.cursor.Position
+		for i, item := range matching {
+			if spec.Key(item) == query.cursor.After {
+				start = i + 1
+				break
+			}
+		}
+		if start > len(matching) {
+			start = len(matching)
+		}
+	}
+	end := start + query.Limit
+	if end > len(matching) {
+		end = len(matching)
+	}
+
+	page := Page[T]{Items: matching[start:end]}
+	if end < len(matching) {
+		page.NextCursor = encodeListCursor(&listCursor{List: spec.Name, Sort: query.Sort, After: spec.Key(matching[end-1]), Position: end})
+	}
+	return page
+}
+
+func (spec ListSpec[T]) matches(item T, filters map[string]string) bool {
+	for name, value := range filters {
+		if !spec.Filters[name](item, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (spec ListSpec[T]) sortFields() []string {
+	fields := make([]string, 0, len(spec.Sorts))
+	for field := range spec.Sorts {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func encodeListCursor(cursor *listCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListCursor(token string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var cursor listCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// parseList parses a list request, answering 400 when the endpoint does not
+// accept it
+func parseList[T any](w http.ResponseWriter, r *http.Request, spec ListSpec[T]) (*ListQuery, bool) {
+	query, err := spec.ParseListQuery(r.URL.Query())
+	if err != nil {
+		listErr := err.(*ListError)
+		writeError(w, listErr.Code, listErr.Message, http.StatusBadRequest)
+		return nil, false
+	}
+	return query, true
+}
+
+// writePage writes a page of a list under its member name, with the cursor
+// of the next page in next_cursor and in a Link header. Other members of the
+// response are passed in extra.
+func writePage[T any](w http.ResponseWriter, r *http.Request, member string, page Page[T], extra map[string]interface{}) {
+	response := map[string]interface{}{member: page.Items}
+	for name, value := range extra {
+		response[name] = value
+	}
+	if page.NextCursor != "" {
+		response["next_cursor"] = page.NextCursor
+		next := *r.URL
+		values := next.Query()
+		values.Set("cursor", page.NextCursor)
+		next.RawQuery = values.Encode()
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Comparisons for list sorts
+
+func compareStrings[T any](field func(T) string) func(a, b T) int {
+	return func(a, b T) int {
+		return strings.Compare(field(a), field(b))
+	}
+}
+
+func compareInts[T any](field func(T) int) func(a, b T) int {
+	return func(a, b T) int {
+		return field(a) - field(b)
+	}
+}
+
+func compareTimes[T any](field func(T) time.Time) func(a, b T) int {
+	return func(a, b T) int {
+		return field(a).Compare(field(b))
+	}
+}
+
+// equalsFilter matches items whose field equals the parameter
+func equalsFilter[T any](field func(T) string) func(T, string) bool {
+	return func(item T, value string) bool {
+		return field(item) == value
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+type testListItem struct {
+	ID    string
+	Name  string
+	Score int
+	Group string
+}
+
+var testListSpec = ListSpec[testListItem]{
+	Name:         "items",
+	DefaultLimit: 2,
+	MaxLimit:     3,
+	Sorts: map[string]func(a, b testListItem) int{
+		"name":  compareStrings(func(i testListItem) string { return i.Name }),
+		"score": compareInts(func(i testListItem) int { return i.Score }),
+	},
+	DefaultSort: "name",
+	Filters: map[string]func(testListItem, string) bool{
+		"group": equalsFilter(func(i testListItem) string { return i.Group }),
+	},
+	Key: func(i testListItem) string { return i.ID },
+}
+
+func testListItems() []testListItem {
+	return []testListItem{
+		{ID: "1", Name: "delta", Score: 3, Group: "a"},
+		{ID: "2", Name: "alpha", Score: 5, Group: "b"},
+		{ID: "3", Name: "echo", Score: 1, Group: "a"},
+		{ID: "4", Name: "bravo", Score: 5, Group: "a"},
+		{ID: "5", Name: "charlie", Score: 2, Group: "b"},
+	}
+}
+
+func listIDs(items []testListItem) string {
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestListSpec_Paginate(t *testing.T) {
+	pages := func(params string) []string {
+		values, _ := url.ParseQuery(params)
+		var result []string
+		for {
+			query, err := testListSpec.ParseListQuery(values)
+			if err != nil {
+				t.Fatalf("ParseListQuery(%q) failed: %v", params, err)
+			}
+			page := testListSpec.Paginate(testListItems(), query)
+			result = append(result, listIDs(page.Items))
+			if page.NextCursor == "" {
+				return result
+			}
+			values.Set("cursor", page.NextCursor)
+		}
+	}
+
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{"", "2,4|5,1|3"},
+		{"limit=3", "2,4,5|1,3"},
+		{"limit=100", "2,4,5|1,3"},
+		{"sort=-score", "4,2|1,5|3"},
+		{"sort=score&group=a", "3,1|4"},
+		{"group=c", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(pages(tt.params), "|"); got != tt.want {
+			t.Errorf("Pages of %q: expected %s, got %s", tt.params, tt.want, got)
+		}
+	}
+}
+
+func TestListSpec_CursorSurvivesChanges(t *testing.T) {
+	query, _ := testListSpec.ParseListQuery(url.Values{})
+	first := testListSpec.Paginate(testListItems(), query)
+
+	values := url.Values{"cursor": {first.NextCursor}}
+	query, _ = testListSpec.ParseListQuery(values)
+	// An item added before the cursor does not repeat the last item seen
+	items := append(testListItems(), testListItem{ID: "6", Name: "able"})
+	if got := listIDs(testListSpec.Paginate(items, query).Items); got != "5,1" {
+		t.Errorf("Expected the page after bravo, got %s", got)
+	}
+	// Nor is the page lost when the last item seen is gone
+	items = testListItems()[:3]
+	if got := listIDs(testListSpec.Paginate(append(items, testListItems()[4]), query).Items); got != "1,3" {
+		t.Errorf("Expected the page at bravo's position, got %s", got)
+	}
+}
+
+func TestListSpec_ParseListQuery(t *testing.T) {
+	other := testListSpec
+	other.Name = "others"
+	query, _ := other.ParseListQuery(url.Values{})
+	otherCursor := other.Paginate(testListItems(), query).NextCursor
+	query, _ = testListSpec.ParseListQuery(url.Values{})
+	nameCursor := testListSpec.Paginate(testListItems(), query).NextCursor
+
+	tests := []struct {
+		params url.Values
+		code   string
+	}{
+		{url.Values{"limit": {"0"}}, "INVALID_REQUEST"},
+		{url.Values{"limit": {"ten"}}, "INVALID_REQUEST"},
+		{url.Values{"sort": {"id"}}, "INVALID_SORT"},
+		{url.Values{"cursor": {"not a cursor"}}, "INVALID_CURSOR"},
+		{url.Values{"cursor": {otherCursor}}, "INVALID_CURSOR"},
+		{url.Values{"cursor": {nameCursor}, "sort": {"-name"}}, "INVALID_CURSOR"},
+	}
+	for _, tt := range tests {
+		_, err := testListSpec.ParseListQuery(tt.params)
+		if listErr, ok := err.(*ListError); !ok || listErr.Code != tt.code {
+			t.Errorf("ParseListQuery(%v): expected %s, got %v", tt.params, tt.code, err)
+		}
+	}
+}
+
+func TestCredentialHandler_ListPages(t *testing.T) {
+	handler := NewCredentialHandler(nil, nil)
+	for i := 1; i <= 3; i++ {
+		id := fmt.Sprintf("cred-%d", i)
+		handler.credentials[id] = &models.Credential{ID: id, Type: "age", Status: "valid", IssuanceDate: fmt.Sprintf("2026-01-0%dT00:00:00Z", i)}
+	}
+
+	list := func(target string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		handler.HandleListCredentials(w, httptest.NewRequest("GET", target, nil))
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	w, response := list("/api/v1/credentials?limit=2")
+	credentials, _ := response["credentials"].([]interface{})
+	if w.Code != http.StatusOK || len(credentials) != 2 || credentials[0].(map[string]interface{})["id"] != "cred-3" {
+		t.Fatalf("Expected the two newest credentials, got %d %v", w.Code, response)
+	}
+	cursor, _ := response["next_cursor"].(string)
+	if cursor == "" || !strings.Contains(w.Header().Get("Link"), "cursor="+cursor) {
+		t.Fatalf("Expected a next cursor and Link header, got %v %q", response, w.Header().Get("Link"))
+	}
+
+	_, response = list("/api/v1/credentials?limit=2&cursor=" + cursor)
+	credentials, _ = response["credentials"].([]interface{})
+	if len(credentials) != 1 || credentials[0].(map[string]interface{})["id"] != "cred-1" || response["next_cursor"] != nil {
+		t.Errorf("Expected the last credential, got %v", response)
+	}
+
+	if w, _ := list("/api/v1/credentials?sort=subject"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unsortable field to be refused, got %d", w.Code)
+	}
+}
@@ -12,10 +12,46 @@ import (
 	"github.com/pavilion-trust/core-broker/internal/services"
 )
 
+// policyListSpec pages GET /policies, newest first
+var policyListSpec = ListSpec[*models.Policy]{
+	Name: "policies",
+	Sorts: map[string]func(a, b *models.Policy) int{
+		"created_at": compareStrings(func(p *models.Policy) string { return p.CreatedAt }),
+		"updated_at": compareStrings(func(p *models.Policy) string { return p.UpdatedAt }),
+		"name":       compareStrings(func(p *models.Policy) string { return p.Name }),
+	},
+	DefaultSort: "-created_at",
+	Filters: map[string]func(*models.Policy, string) bool{
+		"status":     equalsFilter(func(p *models.Policy) string { return p.Status }),
+		"created_by": equalsFilter(func(p *models.Policy) string { return p.CreatedBy }),
+	},
+	Key: func(p *models.Policy) string { return p.ID },
+}
+
+// auditLogListSpec pages GET /policies/audit, newest first
+var auditLogListSpec = ListSpec[*models.AuditEntry]{
+	Name: "audit_logs",
+	Sorts: map[string]func(a, b *models.AuditEntry) int{
+		"timestamp": compareStrings(func(e *models.AuditEntry) string { return e.Timestamp }),
+	},
+	DefaultSort: "-timestamp",
+	Filters: map[string]func(*models.AuditEntry, string) bool{
+		"rp_id":      equalsFilter(func(e *models.AuditEntry) string { return e.RPID }),
+		"dp_id":      equalsFilter(func(e *models.AuditEntry) string { return e.DPID }),
+		"claim_type": equalsFilter(func(e *models.AuditEntry) string { return e.ClaimType }),
+		"status":     equalsFilter(func(e *models.AuditEntry) string { return e.Status }),
+	},
+	Key: func(e *models.AuditEntry) string { return e.RequestID },
+}
+
 // PolicyHandler handles policy-related HTTP requests
 type PolicyHandler struct {
 	config  *config.Config
 	storage models.PolicyStorage
+	// statusLists checks evaluated credentials for revocation
+	statusLists *services.StatusListService
+	// didResolver verifies the proofs of DID issuers and binds DID holders
+	didResolver *services.DIDResolver
 }
 
 // NewPolicyHandler creates a new policy handler
@@ -26,6 +62,17 @@ func NewPolicyHandler(cfg *config.Config, storage models.PolicyStorage) *PolicyH
 	}
 }
 
+// SetStatusListService enables revocation checks of evaluated credentials
+func (h *PolicyHandler) SetStatusListService(statusLists *services.StatusListService) {
+	h.statusLists = statusLists
+}
+
+// SetDIDResolver resolves the issuer and holder DIDs of evaluated
+// credentials
+func (h *PolicyHandler) SetDIDResolver(resolver *services.DIDResolver) {
+	h.didResolver = resolver
+}
+
 // HandleCreatePolicy handles POST /policies
 func (h *PolicyHandler) HandleCreatePolicy(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -33,13 +80,13 @@ func (h *PolicyHandler) HandleCreatePolicy(w http.ResponseWriter, r *http.Reques
 	// Parse request body
 	var policy models.Policy
 	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		writeError(w, "INVALID_REQUEST", fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
 		return
 	}
 
 	// Validate policy
 	if err := policy.Validate(); err != nil {
-		http.Error(w, fmt.Sprintf("Policy validation failed: %v", err), http.StatusBadRequest)
+		writeError(w, "VALIDATION_FAILED", fmt.Sprintf("Policy validation failed: %v", err), http.StatusBadRequest)
 		return
 	}
 
@@ -50,7 +97,7 @@ func (h *PolicyHandler) HandleCreatePolicy(w http.ResponseWriter, r *http.Reques
 
 	// Create policy
 	if err := h.storage.CreatePolicy(ctx, &policy); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create policy: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Failed to create policy: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -77,10 +124,10 @@ func (h *PolicyHandler) HandleGetPolicy(w http.ResponseWriter, r *http.Request)
 	policy, err := h.storage.GetPolicy(ctx, policyID)
 	if err != nil {
 		if err.Error() == fmt.Sprintf("policy not found: %s", policyID) {
-			http.Error(w, "Policy not found", http.StatusNotFound)
+			writeError(w, "NOT_FOUND", "Policy not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, fmt.Sprintf("Failed to get policy: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Failed to get policy: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -99,7 +146,7 @@ func (h *PolicyHandler) HandleUpdatePolicy(w http.ResponseWriter, r *http.Reques
 	// Parse request body
 	var policy models.Policy
 	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		writeError(w, "INVALID_REQUEST", fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
 		return
 	}
 
@@ -108,17 +155,17 @@ func (h *PolicyHandler) HandleUpdatePolicy(w http.ResponseWriter, r *http.Reques
 
 	// Validate policy
 	if err := policy.Validate(); err != nil {
-		http.Error(w, fmt.Sprintf("Policy validation failed: %v", err), http.StatusBadRequest)
+		writeError(w, "VALIDATION_FAILED", fmt.Sprintf("Policy validation failed: %v", err), http.StatusBadRequest)
 		return
 	}
 
 	// Update policy
 	if err := h.storage.UpdatePolicy(ctx, &policy); err != nil {
 		if err.Error() == fmt.Sprintf("policy not found: %s", policyID) {
-			http.Error(w, "Policy not found", http.StatusNotFound)
+			writeError(w, "NOT_FOUND", "Policy not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, fmt.Sprintf("Failed to update policy: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Failed to update policy: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -144,10 +191,10 @@ func (h *PolicyHandler) HandleDeletePolicy(w http.ResponseWriter, r *http.Reques
 	// Delete policy
 	if err := h.storage.DeletePolicy(ctx, policyID); err != nil {
 		if err.Error() == fmt.Sprintf("policy not found: %s", policyID) {
-			http.Error(w, "Policy not found", http.StatusNotFound)
+			writeError(w, "NOT_FOUND", "Policy not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, fmt.Sprintf("Failed to delete policy: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Failed to delete policy: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -169,34 +216,24 @@ func (h *PolicyHandler) HandleListPolicies(w http.ResponseWriter, r *http.Reques
 	ctx := r.Context()
 
 	// Parse query parameters
-	filters := make(map[string]interface{})
-
-	if status := r.URL.Query().Get("status"); status != "" {
-		filters["status"] = status
-	}
-
-	if createdBy := r.URL.Query().Get("created_by"); createdBy != "" {
-		filters["created_by"] = createdBy
+	query, ok := parseList(w, r, policyListSpec)
+	if !ok {
+		return
 	}
 
 	// Get policies from storage
-	policies, err := h.storage.ListPolicies(ctx, filters)
+	policies, err := h.storage.ListPolicies(ctx, query.StorageFilters())
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to list policies: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Failed to list policies: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// Return policies
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
-	response := map[string]interface{}{
-		"policies": policies,
-		"count":    len(policies),
-		"status":   "success",
-	}
-
-	json.NewEncoder(w).Encode(response)
+	page := policyListSpec.Paginate(policies, query)
+	writePage(w, r, "policies", page, map[string]interface{}{
+		"count":  len(page.Items),
+		"status": "success",
+	})
 }
 
 // HandleCreateTemplate handles POST /policies/templates
@@ -206,13 +243,13 @@ func (h *PolicyHandler) HandleCreateTemplate(w http.ResponseWriter, r *http.Requ
 	// Parse request body
 	var template models.PolicyTemplate
 	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		writeError(w, "INVALID_REQUEST", fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
 		return
 	}
 
 	// Validate template
 	if err := template.Validate(); err != nil {
-		http.Error(w, fmt.Sprintf("Template validation failed: %v", err), http.StatusBadRequest)
+		writeError(w, "VALIDATION_FAILED", fmt.Sprintf("Template validation failed: %v", err), http.StatusBadRequest)
 		return
 	}
 
@@ -223,7 +260,7 @@ func (h *PolicyHandler) HandleCreateTemplate(w http.ResponseWriter, r *http.Requ
 
 	// Create template
 	if err := h.storage.CreateTemplate(ctx, &template); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create template: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Failed to create template: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -250,10 +287,10 @@ func (h *PolicyHandler) HandleGetTemplate(w http.ResponseWriter, r *http.Request
 	template, err := h.storage.GetTemplate(ctx, templateID)
 	if err != nil {
 		if err.Error() == fmt.Sprintf("template not found: %s", templateID) {
-			http.Error(w, "Template not found", http.StatusNotFound)
+			writeError(w, "NOT_FOUND", "Template not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, fmt.Sprintf("Failed to get template: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Failed to get template: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -281,7 +318,7 @@ func (h *PolicyHandler) HandleListTemplates(w http.ResponseWriter, r *http.Reque
 	// Get templates from storage
 	templates, err := h.storage.ListTemplates(ctx, filters)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to list templates: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Failed to list templates: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -305,13 +342,13 @@ func (h *PolicyHandler) HandleEvaluatePolicy(w http.ResponseWriter, r *http.Requ
 	// Parse request body
 	var request models.PolicyEvaluationRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		writeError(w, "INVALID_REQUEST", fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
 		return
 	}
 
 	// Validate request
 	if err := request.Validate(); err != nil {
-		http.Error(w, fmt.Sprintf("Request validation failed: %v", err), http.StatusBadRequest)
+		writeError(w, "VALIDATION_FAILED", fmt.Sprintf("Request validation failed: %v", err), http.StatusBadRequest)
 		return
 	}
 
@@ -319,21 +356,23 @@ func (h *PolicyHandler) HandleEvaluatePolicy(w http.ResponseWriter, r *http.Requ
 	policy, err := h.storage.GetPolicy(ctx, request.PolicyID)
 	if err != nil {
 		if err.Error() == fmt.Sprintf("policy not found: %s", request.PolicyID) {
-			http.Error(w, "Policy not found", http.StatusNotFound)
+			writeError(w, "NOT_FOUND", "Policy not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, fmt.Sprintf("Failed to get policy: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Failed to get policy: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// Create rule engine and credential validator
 	ruleEngine := services.NewRuleEngine()
 	credentialValidator := services.NewCredentialValidator()
+	credentialValidator.SetStatusListService(h.statusLists)
+	credentialValidator.SetDIDResolver(h.didResolver)
 
 	// Validate credentials
 	validationResults, err := credentialValidator.ValidateCredentials(ctx, request.Credentials)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Credential validation failed: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Credential validation failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -357,7 +396,7 @@ func (h *PolicyHandler) HandleEvaluatePolicy(w http.ResponseWriter, r *http.Requ
 	// Evaluate policy using rule engine
 	response, err := ruleEngine.EvaluatePolicy(ctx, policy, request.Credentials)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Policy evaluation failed: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Policy evaluation failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -377,7 +416,7 @@ func (h *PolicyHandler) HandleHealth(w http.ResponseWriter, r *http.Request) {
 	// Check storage health by listing policies with limit
 	_, err := h.storage.ListPolicies(ctx, map[string]interface{}{})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Storage health check failed: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Storage health check failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -399,22 +438,9 @@ func (h *PolicyHandler) HandleGetAuditLogs(w http.ResponseWriter, r *http.Reques
 	ctx := r.Context()
 
 	// Parse query parameters
-	filters := make(map[string]interface{})
-
-	if rpID := r.URL.Query().Get("rp_id"); rpID != "" {
-		filters["rp_id"] = rpID
-	}
-
-	if dpID := r.URL.Query().Get("dp_id"); dpID != "" {
-		filters["dp_id"] = dpID
-	}
-
-	if claimType := r.URL.Query().Get("claim_type"); claimType != "" {
-		filters["claim_type"] = claimType
-	}
-
-	if status := r.URL.Query().Get("status"); status != "" {
-		filters["status"] = status
+	query, ok := parseList(w, r, auditLogListSpec)
+	if !ok {
+		return
 	}
 
 	// Create audit logger
@@ -422,23 +448,18 @@ func (h *PolicyHandler) HandleGetAuditLogs(w http.ResponseWriter, r *http.Reques
 	auditLogger := services.NewAuditLogger(auditStorage)
 
 	// Get audit logs
-	logs, err := auditLogger.GetAuditLogs(ctx, filters)
+	logs, err := auditLogger.GetAuditLogs(ctx, query.StorageFilters())
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get audit logs: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Failed to get audit logs: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// Return audit logs
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
-	response := map[string]interface{}{
-		"logs":   logs,
-		"count":  len(logs),
+	page := auditLogListSpec.Paginate(logs, query)
+	writePage(w, r, "logs", page, map[string]interface{}{
+		"count":  len(page.Items),
 		"status": "success",
-	}
-
-	json.NewEncoder(w).Encode(response)
+	})
 }
 
 // HandleGetAuditLog handles GET /policies/audit/{request_id}
@@ -455,10 +476,10 @@ func (h *PolicyHandler) HandleGetAuditLog(w http.ResponseWriter, r *http.Request
 	log, err := auditLogger.GetAuditLog(ctx, requestID)
 	if err != nil {
 		if err.Error() == fmt.Sprintf("audit log not found: %s", requestID) {
-			http.Error(w, "Audit log not found", http.StatusNotFound)
+			writeError(w, "NOT_FOUND", "Audit log not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, fmt.Sprintf("Failed to get audit log: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Failed to get audit log: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -479,7 +500,7 @@ func (h *PolicyHandler) HandleGetAuditStats(w http.ResponseWriter, r *http.Reque
 	// Get audit statistics
 	stats, err := auditLogger.GetAuditStats(ctx)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get audit stats: %v", err), http.StatusInternalServerError)
+		writeError(w, "INTERNAL_ERROR", fmt.Sprintf("Failed to get audit stats: %v", err), http.StatusInternalServerError)
 		return
 	}
 
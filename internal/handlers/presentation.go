@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// PresentationHandler serves OpenID4VP presentation requests: RPs create
+// them and collect results, wallets post their vp_token to the response URI
+type PresentationHandler struct {
+	config    *config.Config
+	openID4VP *services.OpenID4VPService
+}
+
+// NewPresentationHandler creates a new presentation handler
+func NewPresentationHandler(cfg *config.Config, openID4VP *services.OpenID4VPService) *PresentationHandler {
+	return &PresentationHandler{
+		config:    cfg,
+		openID4VP: openID4VP,
+	}
+}
+
+// HandleCreatePresentationRequest handles POST /presentations, returning the
+// authorization request to hand to the holder's wallet
+func (h *PresentationHandler) HandleCreatePresentationRequest(w http.ResponseWriter, r *http.Request) {
+	rpID := getCallerRPID(r.Context())
+	if rpID == "" {
+		writeError(w, "AUTHORIZATION_DENIED", "No tenant associated with caller", http.StatusForbidden)
+		return
+	}
+
+	var req services.PresentationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "INVALID_JSON", "Failed to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.openID4VP.CreateRequest(rpID, req)
+	if err != nil {
+		writeError(w, "INVALID_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/presentations/%s", session.ID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+// HandleGetPresentationRequest handles GET /presentations/{id}; RPs poll it
+// for the verified claims
+func (h *PresentationHandler) HandleGetPresentationRequest(w http.ResponseWriter, r *http.Request) {
+	session, err := h.openID4VP.GetRequest(getCallerRPID(r.Context()), mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "NOT_FOUND", "Presentation request not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(session)
+}
+
+// HandleDirectPost handles the wallet's form-encoded direct_post response.
+// Wallets expect OAuth 2.0 error responses here rather than broker errors.
+func (h *PresentationHandler) HandleDirectPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, "invalid_request", "Failed to parse form body")
+		return
+	}
+
+	var submission *services.PresentationSubmission
+	if raw := r.PostForm.Get("presentation_submission"); raw != "" {
+		submission = &services.PresentationSubmission{}
+		if err := json.Unmarshal([]byte(raw), submission); err != nil {
+			writeOAuthError(w, "invalid_request", "Failed to parse presentation_submission")
+			return
+		}
+	}
+
+	_, err := h.openID4VP.SubmitResponse(r.Context(), r.PostForm.Get("state"), r.PostForm.Get("vp_token"), submission)
+	if errors.Is(err, services.ErrPresentationNotFound) {
+		writeOAuthError(w, "invalid_request", "Unknown state")
+		return
+	}
+	if err != nil {
+		writeOAuthError(w, "invalid_request", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{})
+}
+
+func writeOAuthError(w http.ResponseWriter, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
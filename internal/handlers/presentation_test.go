@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestPresentationHandler_DirectPost(t *testing.T) {
+	issuerKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	holderKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cfg := &config.Config{Issuer: "https://broker.example", CoreBrokerURL: "https://broker.example"}
+	openID4VP := services.NewOpenID4VPService(cfg, services.NewClaimSchemaRegistry(), nil)
+	openID4VP.AddTrustedIssuer("https://issuer.example", &issuerKey.PublicKey)
+	handler := NewPresentationHandler(cfg, openID4VP)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/presentations", handler.HandleCreatePresentationRequest).Methods("POST")
+	router.HandleFunc("/presentations/{id}", handler.HandleGetPresentationRequest).Methods("GET")
+	router.HandleFunc("/openid4vp/response", handler.HandleDirectPost).Methods("POST")
+
+	asRP := func(req *http.Request) *http.Request {
+		user := &services.UserInfo{Subject: "user-rp_1", ResourceID: "rp_1", Roles: []string{"rp"}}
+		return req.WithContext(context.WithValue(req.Context(), "user", user))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, asRP(httptest.NewRequest("POST", "/presentations", strings.NewReader(`{"claim_type":"student_verification","attributes":["enrollment_status"]}`))))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var session services.PresentationSession
+	json.NewDecoder(w.Body).Decode(&session)
+	auth := session.AuthorizationRequest
+
+	// The wallet holds an SD-JWT from a trusted issuer and presents it
+	issuer := services.NewSelectiveDisclosureService(services.NewSelectiveDisclosureConfig(true, false, "test"))
+	issuer.SetSDJWTSigner(&services.SDJWTSigner{Key: issuerKey, KeyID: "i-1", Issuer: "https://issuer.example"})
+	issued, err := issuer.ExtractClaims(map[string]interface{}{"enrollment_status": "enrolled"}, services.SelectiveDisclosureRequest{
+		CredentialID: "cred-1",
+		Purpose:      "issuance",
+		RequesterID:  "wallet",
+		Format:       services.DisclosureFormatSDJWT,
+		HolderJWK:    services.ECDSAPublicJWK(&holderKey.PublicKey),
+		Claims:       map[string]services.Claim{"enrollment_status": {Name: "enrollment_status", Disclosure: services.DisclosureLevelFull}},
+	})
+	if err != nil {
+		t.Fatalf("ExtractClaims failed: %v", err)
+	}
+	vpToken, _ := services.PresentSDJWT(issued.SDJWT, []string{"enrollment_status"}, holderKey, auth.ClientID, auth.Nonce)
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/openid4vp/response", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = post(url.Values{"state": {"unknown"}, "vp_token": {vpToken}})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_request") {
+		t.Errorf("Expected an OAuth error for an unknown state, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = post(url.Values{
+		"state":                   {auth.State},
+		"vp_token":                {vpToken},
+		"presentation_submission": {`{"id":"s","definition_id":"student_verification","descriptor_map":[{"id":"student_verification","format":"dc+sd-jwt","path":"$"}]}`},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, asRP(httptest.NewRequest("GET", "/presentations/"+session.ID, nil)))
+	var result services.PresentationSession
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.State != services.PresentationVerified || result.Claims["enrollment_status"] != "enrolled" || result.Issuer != "https://issuer.example" {
+		t.Errorf("Unexpected presentation result: %+v", result)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// ProbeHandler serves Kubernetes liveness and readiness probes from the
+// dependency checks. Both report every dependency; liveness fails only on
+// checks of the process itself, so an outage of a downstream service takes
+// the pod out of rotation without restarting it.
+type ProbeHandler struct {
+	prober *services.HealthProber
+}
+
+// NewProbeHandler creates a probe handler
+func NewProbeHandler(prober *services.HealthProber) *ProbeHandler {
+	return &ProbeHandler{prober: prober}
+}
+
+// ProbeResponse represents a liveness or readiness probe response
+type ProbeResponse struct {
+	Status       string                          `json:"status"`
+	Timestamp    string                          `json:"timestamp"`
+	CheckedAt    string                          `json:"checked_at"`
+	Dependencies map[string]services.ProbeResult `json:"dependencies"`
+}
+
+// HandleLiveness handles GET /healthz
+func (h *ProbeHandler) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	report := h.prober.Check(r.Context())
+	status, statusCode := "ok", http.StatusOK
+	if !report.Live() {
+		status, statusCode = "unhealthy", http.StatusServiceUnavailable
+	} else if !report.Ready() {
+		status = "degraded"
+	}
+	writeProbeResponse(w, statusCode, status, report)
+}
+
+// HandleReadiness handles GET /readyz
+func (h *ProbeHandler) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	report := h.prober.Check(r.Context())
+	status, statusCode := "ready", http.StatusOK
+	if !report.Ready() {
+		status, statusCode = "not_ready", http.StatusServiceUnavailable
+	}
+	writeProbeResponse(w, statusCode, status, report)
+}
+
+func writeProbeResponse(w http.ResponseWriter, statusCode int, status string, report *services.ProbeReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(&ProbeResponse{
+		Status:       status,
+		Timestamp:    time.Now().Format(time.RFC3339),
+		CheckedAt:    report.CheckedAt.Format(time.RFC3339),
+		Dependencies: report.Dependencies,
+	})
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestProbeHandler(t *testing.T) {
+	probe := func(auditErr, cacheErr error) (*httptest.ResponseRecorder, *httptest.ResponseRecorder) {
+		handler := NewProbeHandler(services.NewHealthProber([]services.HealthProbe{
+			{Name: "audit", Liveness: true, Check: func(context.Context) error { return auditErr }},
+			{Name: "cache", Check: func(context.Context) error { return cacheErr }},
+		}, 0, 0))
+		live, ready := httptest.NewRecorder(), httptest.NewRecorder()
+		handler.HandleLiveness(live, httptest.NewRequest("GET", "/healthz", nil))
+		handler.HandleReadiness(ready, httptest.NewRequest("GET", "/readyz", nil))
+		return live, ready
+	}
+
+	live, ready := probe(nil, nil)
+	if live.Code != http.StatusOK || ready.Code != http.StatusOK {
+		t.Errorf("Expected both probes to pass, got %d and %d", live.Code, ready.Code)
+	}
+
+	// A downstream outage takes the gateway out of rotation without a restart
+	live, ready = probe(nil, errors.New("redis connection failed"))
+	var response ProbeResponse
+	json.NewDecoder(ready.Body).Decode(&response)
+	if live.Code != http.StatusOK || ready.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected live but not ready, got %d and %d", live.Code, ready.Code)
+	}
+	if response.Status != "not_ready" || response.Dependencies["cache"].Error != "redis connection failed" {
+		t.Errorf("Expected the failed dependency to be reported, got %+v", response)
+	}
+
+	live, _ = probe(errors.New("privacy hash generation failed"), nil)
+	if live.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected liveness to fail on a process check, got %d", live.Code)
+	}
+}
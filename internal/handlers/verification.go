@@ -3,10 +3,13 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/middleware"
 	"github.com/pavilion-trust/core-broker/internal/models"
 	"github.com/pavilion-trust/core-broker/internal/services"
 )
@@ -17,6 +20,10 @@ type VerificationHandler struct {
 	authorizationService     *services.AuthorizationService
 	policyService            *services.PolicyService
 	privacyService           *services.PrivacyService
+	identifierService        *services.IdentifierService
+	duplicateDetector        *services.DuplicateSubjectDetector
+	schemaRegistry           *services.ClaimSchemaRegistry
+	deprecations             *services.ClaimDeprecationTracker
 	dpService                *services.DPConnectorService
 	pullJobService           *services.PullJobService
 	responseParserService    *services.ResponseParserService
@@ -24,26 +31,169 @@ type VerificationHandler struct {
 	jwsAttestationService    *services.JWSAttestationService
 	auditService             *services.AuditService
 	cacheService             *services.CacheService
+	recordStore              *services.VerificationRecordStore
+	batchTracker             *services.BatchTracker
+	customMetrics            *services.CustomMetricsService
+	supportBundles           *services.SupportBundleStore
+	federation               *services.FederationService
+	orchestrator             *services.VerificationOrchestrator
 }
 
 // NewVerificationHandler creates a new verification handler
 func NewVerificationHandler(cfg *config.Config) *VerificationHandler {
 	policyService := services.NewPolicyService(cfg)
 	dpService := services.NewDPConnectorService(cfg)
+	auditService := services.NewAuditService(cfg)
+	dpService.SetAuditService(auditService)
 
-	return &VerificationHandler{
+	schemaRegistry := services.NewClaimSchemaRegistry()
+	if cfg.EvidenceWeightingFile != "" {
+		if err := schemaRegistry.LoadEvidenceWeightingFile(cfg.EvidenceWeightingFile); err != nil {
+			fmt.Printf("EVIDENCE WARNING: %v; DP results are not weighted\n", err)
+		}
+	}
+	if cfg.ClaimLifecycleFile != "" {
+		if err := schemaRegistry.LoadClaimLifecycleFile(cfg.ClaimLifecycleFile); err != nil {
+			fmt.Printf("CLAIM LIFECYCLE WARNING: %v; all claim types stay active\n", err)
+		}
+	}
+
+	// Negotiated JWT and COSE responses are signed with the attestation key
+	jwsAttestationService := services.NewJWSAttestationService(cfg)
+	responseFormatterService := services.NewResponseFormatterService(cfg)
+	responseFormatterService.SetSigner(jwsAttestationService)
+
+	customMetrics := services.NewCustomMetricsService()
+	if cfg.CustomMetricsFile != "" {
+		if err := customMetrics.LoadFile(cfg.CustomMetricsFile); err != nil {
+			fmt.Printf("CUSTOM METRICS WARNING: %v\n", err)
+		}
+	}
+
+	federation := services.NewFederationService(cfg)
+	if cfg.FederationFile != "" {
+		if err := federation.LoadFile(cfg.FederationFile); err != nil {
+			fmt.Printf("FEDERATION WARNING: %v; verifications are not forwarded to peer brokers\n", err)
+		}
+	}
+
+	handler := &VerificationHandler{
 		config:                   cfg,
 		authorizationService:     services.NewAuthorizationService(cfg, policyService),
 		policyService:            policyService,
 		privacyService:           services.NewPrivacyService(cfg),
+		identifierService:        services.NewIdentifierService(cfg),
+		duplicateDetector:        services.NewDuplicateSubjectDetector(cfg),
+		schemaRegistry:           schemaRegistry,
+		deprecations:             services.NewClaimDeprecationTracker(),
 		dpService:                dpService,
 		pullJobService:           services.NewPullJobService(cfg, dpService),
 		responseParserService:    services.NewResponseParserService(cfg),
-		responseFormatterService: services.NewResponseFormatterService(cfg),
-		jwsAttestationService:    services.NewJWSAttestationService(cfg),
-		auditService:             services.NewAuditService(cfg),
+		responseFormatterService: responseFormatterService,
+		jwsAttestationService:    jwsAttestationService,
+		auditService:             auditService,
 		cacheService:             services.NewCacheService(cfg),
+		recordStore:              services.NewVerificationRecordStore(),
+		batchTracker:             services.NewBatchTracker(cfg.BatchMaxItems),
+		customMetrics:            customMetrics,
+		supportBundles:           services.NewSupportBundleStore(),
+		federation:               federation,
 	}
+
+	// Results are cached for their claim type's freshness
+	handler.cacheService.SetDPRegistry(dpService.Registry())
+
+	// The built-in stages are fixed, so only the configured timeouts can fail
+	handler.orchestrator, _ = services.NewVerificationOrchestrator(handler.verificationStages()...)
+	if err := handler.orchestrator.ApplyTimeouts(cfg.VerificationStageTimeouts); err != nil {
+		fmt.Printf("ORCHESTRATION WARNING: %v; remaining stage timeouts are not applied\n", err)
+	}
+	return handler
+}
+
+// RecordStore returns the store of completed verifications
+func (h *VerificationHandler) RecordStore() *services.VerificationRecordStore {
+	return h.recordStore
+}
+
+// DPService returns the DP connector used for verifications
+func (h *VerificationHandler) DPService() *services.DPConnectorService {
+	return h.dpService
+}
+
+// ResponseFormatter returns the service shaping verification responses
+func (h *VerificationHandler) ResponseFormatter() *services.ResponseFormatterService {
+	return h.responseFormatterService
+}
+
+// CacheService returns the cache of verification results
+func (h *VerificationHandler) CacheService() *services.CacheService {
+	return h.cacheService
+}
+
+// PullJobService returns the service running DP pull jobs
+func (h *VerificationHandler) PullJobService() *services.PullJobService {
+	return h.pullJobService
+}
+
+// AuditService returns the audit logger for verifications
+func (h *VerificationHandler) AuditService() *services.AuditService {
+	return h.auditService
+}
+
+// SetStorage keeps the verification audit log and pull job state in a store
+func (h *VerificationHandler) SetStorage(store services.Storage) {
+	h.auditService.SetStorage(store)
+	h.pullJobService.SetStorage(store)
+}
+
+// BatchTracker returns the tracker of batch verifications
+func (h *VerificationHandler) BatchTracker() *services.BatchTracker {
+	return h.batchTracker
+}
+
+// SchemaRegistry returns the claim type schemas, which carry the evidence
+// weighting applied to DP results
+func (h *VerificationHandler) SchemaRegistry() *services.ClaimSchemaRegistry {
+	return h.schemaRegistry
+}
+
+// ClaimDeprecations returns the tracker of RPs using deprecated claim types
+func (h *VerificationHandler) ClaimDeprecations() *services.ClaimDeprecationTracker {
+	return h.deprecations
+}
+
+// AuthorizationService returns the service authorizing verification requests
+func (h *VerificationHandler) AuthorizationService() *services.AuthorizationService {
+	return h.authorizationService
+}
+
+// CustomMetrics returns the operator-defined metrics evaluated for each
+// verification
+func (h *VerificationHandler) CustomMetrics() *services.CustomMetricsService {
+	return h.customMetrics
+}
+
+// AttestationService returns the service signing attestations and
+// negotiated JWT and COSE responses
+func (h *VerificationHandler) AttestationService() *services.JWSAttestationService {
+	return h.jwsAttestationService
+}
+
+// SupportBundles returns the support bundles of failed verifications
+func (h *VerificationHandler) SupportBundles() *services.SupportBundleStore {
+	return h.supportBundles
+}
+
+// Federation returns the service forwarding verifications to peer brokers
+func (h *VerificationHandler) Federation() *services.FederationService {
+	return h.federation
+}
+
+// Orchestrator returns the orchestrator running verifications' stages, for
+// inserting stages and hooks
+func (h *VerificationHandler) Orchestrator() *services.VerificationOrchestrator {
+	return h.orchestrator
 }
 
 // HandleVerification processes verification requests
@@ -57,81 +207,346 @@ func (h *VerificationHandler) HandleVerification(w http.ResponseWriter, r *http.
 		return
 	}
 
-	// Get request ID from context
-	requestID := getRequestID(ctx)
+	// The format is settled before verifying so an unacceptable one costs no DP call
+	format, err := services.NegotiateResponseFormat(r.Header.Get("Accept"))
+	if err != nil {
+		writeError(w, "NOT_ACCEPTABLE", "Accept must allow application/json, application/jwt, application/cbor or application/cose", http.StatusNotAcceptable)
+		return
+	}
 
-	// Check cache first
-	if cachedResult := h.cacheService.GetVerificationResult(*req); cachedResult != nil {
-		auditRef := h.auditService.LogVerification(ctx, *req, cachedResult, "CACHE_HIT")
-		// Add audit reference to cached result
-		if auditRef != nil {
-			cachedResult.AuditReference = auditRef.AuditEntryID
-		}
-		writeResponse(w, cachedResult)
+	response, verr := h.verify(ctx, req)
+	if verr != nil {
+		middleware.WriteProblem(w, verr.Problem())
 		return
 	}
 
-	// Perform authorization checks
-	authDecision, err := h.authorizationService.AuthorizeRequest(ctx, *req)
+	if notice, ok := response.Metadata["claim_type_lifecycle"].(*services.ClaimDeprecationNotice); ok {
+		setDeprecationHeaders(w, notice)
+	}
+
+	// Return response
+	body, err := h.responseFormatterService.EncodeResponse(response, format, req.RPID)
 	if err != nil {
-		h.auditService.LogVerification(ctx, *req, nil, "AUTHORIZATION_ERROR")
-		writeError(w, "AUTHORIZATION_ERROR", "Authorization service error", http.StatusInternalServerError)
+		writeError(w, "RESPONSE_ENCODING_ERROR", fmt.Sprintf("Failed to encode %s response", format), http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
 
-	if !authDecision.Allowed {
-		h.auditService.LogVerification(ctx, *req, nil, "AUTHORIZATION_DENIED")
-		writeError(w, "AUTHORIZATION_DENIED", authDecision.Reason, http.StatusForbidden)
+// HandlePolicySimulation handles POST /policy/simulate. It reports the policy
+// decision, disclosure levels and required consents for a hypothetical request
+// without calling a DP or writing audit entries.
+func (h *VerificationHandler) HandlePolicySimulation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Get validated request from context (set by validation middleware)
+	req := getValidatedRequestFromContext(ctx)
+	if req == nil {
+		writeError(w, "INVALID_REQUEST", "Request validation failed", http.StatusBadRequest)
 		return
 	}
 
-	// Apply privacy-preserving transformations
-	privacyReq, err := h.privacyService.TransformRequest(ctx, *req)
+	simulation, err := h.authorizationService.SimulateRequest(ctx, *req)
 	if err != nil {
-		h.auditService.LogVerification(ctx, *req, nil, "PRIVACY_ERROR")
-		writeError(w, "PRIVACY_ERROR", "Failed to apply privacy transformations", http.StatusInternalServerError)
+		writeError(w, "SIMULATION_ERROR", "Failed to simulate policy decision", http.StatusInternalServerError)
 		return
 	}
 
-	// Submit pull-job request (T-011)
-	jobStatus, err := h.pullJobService.SubmitJob(ctx, privacyReq)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(simulation)
+}
+
+// verify runs a validated request through the verification pipeline and
+// evaluates the custom metrics of its hook points. The pipeline is traced,
+// and a failure keeps a support bundle for its request ID.
+func (h *VerificationHandler) verify(ctx context.Context, req *models.VerificationRequest) (*models.VerificationResponse, *services.StageError) {
+	start := time.Now()
+	request := customMetricRequest(req)
+	h.customMetrics.Observe(services.MetricHookRequest, map[string]interface{}{"request": request})
+
+	trace := services.NewVerificationTrace(getRequestID(ctx), *req)
+	response, verr := h.processVerification(services.WithVerificationTrace(ctx, trace), req)
+	durationMs := float64(time.Since(start).Microseconds()) / 1000.0
+	if verr != nil {
+		h.supportBundles.Record(trace.Bundle(services.SupportBundleFailure{
+			Code:       verr.Code,
+			Message:    verr.Message,
+			StatusCode: verr.StatusCode,
+		}, h.config, h.dpService))
+		h.customMetrics.Observe(services.MetricHookError, map[string]interface{}{
+			"request":     request,
+			"error":       map[string]interface{}{"code": verr.Code, "status_code": verr.StatusCode},
+			"duration_ms": durationMs,
+		})
+		return nil, verr
+	}
+
+	h.customMetrics.Observe(services.MetricHookResponse, map[string]interface{}{
+		"request": request,
+		"response": map[string]interface{}{
+			"status":           response.Status,
+			"verified":         response.Verified,
+			"confidence_score": response.ConfidenceScore,
+			"reason":           response.Reason,
+			"evidence":         response.Evidence,
+			"dp_id":            response.DPID,
+			// A cached result keeps the request ID it was verified under
+			"cached":  response.RequestID != getRequestID(ctx),
+			"partial": response.Metadata["partial_results"] != nil,
+			"stale":   response.Stale,
+		},
+		"duration_ms": durationMs,
+	})
+	return response, nil
+}
+
+// customMetricRequest exposes a request to custom metric expressions without
+// its identifier values or user ID
+func customMetricRequest(req *models.VerificationRequest) map[string]interface{} {
+	names := make([]string, 0, len(req.Identifiers))
+	for name := range req.Identifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	identifierTypes := make([]interface{}, len(names))
+	for i, name := range names {
+		identifierTypes[i] = name
+	}
+	return map[string]interface{}{
+		"rp_id":            req.RPID,
+		"claim_type":       req.ClaimType,
+		"identifier_types": identifierTypes,
+	}
+}
+
+// verificationRun holds what the built-in verification stages pass on to
+// each other
+type verificationRun struct {
+	// ctx is the verification's context; pull jobs run under it rather than
+	// under their submission stage's
+	ctx        context.Context
+	requestID  string
+	notice     *services.ClaimDeprecationNotice
+	stale      *models.VerificationResponse
+	validators *services.DPValidators
+	privacyReq *models.PrivacyRequest
+	jobID      string
+	jobResult  *models.DPResponse
+	dpResponse *models.DPResponse
+}
+
+// verificationRunKey is the state key of the built-in stages' run
+const verificationRunKey = "verification_run"
+
+func runOf(state *services.VerificationState) *verificationRun {
+	run, _ := state.Get(verificationRunKey)
+	return run.(*verificationRun)
+}
+
+// stageFailure reports a verification stage failure to the RP
+func stageFailure(code, message string, statusCode int, details map[string]interface{}) *services.StageError {
+	return &services.StageError{Code: code, Message: message, StatusCode: statusCode, Details: details}
+}
+
+// failVerification audits a failed verification under status, embedding
+// the problem reported to the RP, and returns the failure
+func (h *VerificationHandler) failVerification(ctx context.Context, req *models.VerificationRequest, status string, failure *services.StageError) *services.StageError {
+	h.auditService.LogVerificationFailure(ctx, *req, status, failure.Problem())
+	return failure
+}
+
+// jobFailure reports a failed pull job by its DP error's problem code, when
+// the error has one
+func jobFailure(job *services.JobStatus) *services.StageError {
+	switch job.ErrorCode {
+	case models.ProblemCircuitOpen:
+		return stageFailure(models.ProblemCircuitOpen, "Data provider is unavailable", http.StatusServiceUnavailable, nil)
+	case models.ProblemDPTimeout:
+		return stageFailure(models.ProblemDPTimeout, "Data provider timed out", http.StatusGatewayTimeout, nil)
+	}
+	return stageFailure("JOB_FAILED", "Verification job failed", http.StatusInternalServerError, nil)
+}
+
+// verificationStages returns the built-in stages of a verification, in
+// order. Further stages are inserted around them by name.
+func (h *VerificationHandler) verificationStages() []services.VerificationStage {
+	return []services.VerificationStage{
+		{Name: "claim_lifecycle", Run: h.checkClaimLifecycle},
+		{Name: "identifier_validation", Run: h.validateIdentifiers},
+		{Name: "cache", Run: h.lookupCache},
+		{Name: "authorization", Run: h.authorize},
+		{Name: "federation", Run: h.routeFederation},
+		{Name: "privacy", Run: h.applyPrivacy},
+		{Name: "job_submission", Run: h.submitJob},
+		{Name: "dp_job", Run: h.awaitJob, Timeout: 30 * time.Second},
+		{Name: "response_formatting", Run: h.formatResponse},
+		{Name: "audit", Run: h.auditResponse},
+		{Name: "record", Run: h.recordResponse},
+	}
+}
+
+// processVerification runs a validated request through the verification
+// orchestrator's stages
+func (h *VerificationHandler) processVerification(ctx context.Context, req *models.VerificationRequest) (*models.VerificationResponse, *services.StageError) {
+	state := services.NewVerificationState(req)
+	state.Set(verificationRunKey, &verificationRun{ctx: ctx, requestID: getRequestID(ctx)})
+	if stageErr := h.orchestrator.Run(ctx, state); stageErr != nil {
+		return nil, stageErr
+	}
+	if state.Response == nil {
+		return nil, stageFailure("VERIFICATION_INCOMPLETE", "No verification stage produced a response", http.StatusInternalServerError, nil)
+	}
+	services.VerificationTraceFrom(ctx).Finish("")
+	return state.Response, nil
+}
+
+// checkClaimLifecycle rejects retired claim types with migration guidance;
+// deprecated ones keep working but are recorded against the RP
+func (h *VerificationHandler) checkClaimLifecycle(ctx context.Context, state *services.VerificationState) error {
+	req := state.Request
+	notice := h.deprecationNotice(req)
+	if notice == nil {
+		return nil
+	}
+	runOf(state).notice = notice
+	h.deprecations.Record(req.RPID, notice)
+	if notice.State == services.ClaimStateRetired {
+		return h.failVerification(ctx, req, "CLAIM_TYPE_RETIRED", stageFailure("CLAIM_TYPE_RETIRED", fmt.Sprintf("Claim type %s has been retired", req.ClaimType), http.StatusGone, map[string]interface{}{"lifecycle": notice}))
+	}
+	return nil
+}
+
+// validateIdentifiers rejects placeholder and malformed identifiers before
+// they reach the cache or a DP
+func (h *VerificationHandler) validateIdentifiers(ctx context.Context, state *services.VerificationState) error {
+	req := state.Request
+	if err := h.identifierService.ValidateIdentifiers(req.RPID, req.Identifiers); err != nil {
+		failure := stageFailure("INVALID_IDENTIFIER", "One or more identifiers failed quality checks", http.StatusBadRequest, nil)
+		if identifierErr, ok := err.(*services.IdentifierValidationError); ok {
+			failure.Details = map[string]interface{}{"issues": identifierErr.Issues}
+		}
+		return h.failVerification(ctx, req, "IDENTIFIER_REJECTED", failure)
+	}
+	return nil
+}
+
+// lookupCache finishes the verification with a cached result
+func (h *VerificationHandler) lookupCache(ctx context.Context, state *services.VerificationState) error {
+	req := state.Request
+	cachedResult := h.cacheService.GetVerificationResult(*req)
+	if cachedResult == nil {
+		return nil
+	}
+	auditRef := h.auditService.LogVerification(ctx, *req, cachedResult, "CACHE_HIT")
+	// Add audit reference to cached result
+	if auditRef != nil {
+		cachedResult.AuditReference = auditRef.AuditEntryID
+	}
+	h.annotateDuplicateSubject(req, cachedResult)
+	annotateDeprecation(cachedResult, runOf(state).notice)
+	state.Finish(cachedResult)
+	return nil
+}
+
+// authorize checks the request against the RP's policies
+func (h *VerificationHandler) authorize(ctx context.Context, state *services.VerificationState) error {
+	req := state.Request
+	authDecision, err := h.authorizationService.AuthorizeRequest(ctx, *req)
 	if err != nil {
-		h.auditService.LogVerification(ctx, *req, nil, "JOB_SUBMISSION_ERROR")
-		writeError(w, "JOB_SUBMISSION_ERROR", "Failed to submit verification job", http.StatusInternalServerError)
-		return
+		return h.failVerification(ctx, req, "AUTHORIZATION_ERROR", stageFailure("AUTHORIZATION_ERROR", "Authorization service error", http.StatusInternalServerError, nil))
+	}
+	if !authDecision.Allowed {
+		return h.failVerification(ctx, req, "AUTHORIZATION_DENIED", stageFailure(models.ProblemPolicyDenied, authDecision.Reason, http.StatusForbidden, nil))
 	}
+	return nil
+}
 
-	// Wait for job completion (with timeout)
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
+// routeFederation finishes verifications of claim types federated to a peer
+// broker in its trust network
+func (h *VerificationHandler) routeFederation(ctx context.Context, state *services.VerificationState) error {
+	peerID, federated := h.federation.Route(state.Request.ClaimType, h.dpService.Registry())
+	if !federated {
+		return nil
+	}
+	response, stageErr := h.federatedVerification(ctx, state.Request, peerID, runOf(state).notice)
+	if stageErr != nil {
+		return stageErr
+	}
+	state.Finish(response)
+	return nil
+}
 
-	// Poll for job completion
-	var dpResponse *models.DPResponse
+// applyPrivacy applies the privacy-preserving transformations. An expired
+// result with DP validators is refreshed with a conditional request; a 304
+// extends it rather than verifying again.
+func (h *VerificationHandler) applyPrivacy(ctx context.Context, state *services.VerificationState) error {
+	run := runOf(state)
+	run.stale, run.validators = h.cacheService.GetRevalidationCandidate(*state.Request)
+	if run.stale != nil {
+		ctx = services.WithDPValidators(ctx, run.validators)
+	}
+
+	privacyReq, err := h.privacyService.TransformRequest(ctx, *state.Request)
+	if err != nil {
+		return h.failVerification(ctx, state.Request, "PRIVACY_ERROR", stageFailure("PRIVACY_ERROR", "Failed to apply privacy transformations", http.StatusInternalServerError, nil))
+	}
+	run.privacyReq = privacyReq
+	return nil
+}
+
+// submitJob submits the pull job verifying the request with a DP (T-011)
+func (h *VerificationHandler) submitJob(ctx context.Context, state *services.VerificationState) error {
+	run := runOf(state)
+	jobCtx := run.ctx
+	if run.stale != nil {
+		jobCtx = services.WithDPValidators(jobCtx, run.validators)
+	}
+	jobStatus, err := h.pullJobService.SubmitJob(jobCtx, run.privacyReq)
+	if err != nil {
+		return h.failVerification(ctx, state.Request, "JOB_SUBMISSION_ERROR", stageFailure("JOB_SUBMISSION_ERROR", "Failed to submit verification job", http.StatusInternalServerError, nil))
+	}
+	run.jobID = jobStatus.JobID
+	return nil
+}
+
+// awaitJob polls the pull job until it completes and parses its DP
+// response (T-012). A 304 for a stale result finishes the verification.
+func (h *VerificationHandler) awaitJob(ctx context.Context, state *services.VerificationState) error {
+	req := state.Request
+	run := runOf(state)
 	for {
 		select {
 		case <-ctx.Done():
-			h.auditService.LogVerification(ctx, *req, nil, "JOB_TIMEOUT")
-			writeError(w, "JOB_TIMEOUT", "Verification job timed out", http.StatusRequestTimeout)
-			return
+			return h.failVerification(ctx, req, "JOB_TIMEOUT", stageFailure(models.ProblemDPTimeout, "Verification job timed out", http.StatusGatewayTimeout, nil))
 		default:
 			// Check job status
-			updatedJobStatus, err := h.pullJobService.GetJobStatus(jobStatus.JobID)
+			updatedJobStatus, err := h.pullJobService.GetJobStatus(run.jobID)
 			if err != nil {
-				h.auditService.LogVerification(ctx, *req, nil, "JOB_STATUS_ERROR")
-				writeError(w, "JOB_STATUS_ERROR", "Failed to get job status", http.StatusInternalServerError)
-				return
+				return h.failVerification(ctx, req, "JOB_STATUS_ERROR", stageFailure("JOB_STATUS_ERROR", "Failed to get job status", http.StatusInternalServerError, nil))
 			}
 
 			if updatedJobStatus.Status == services.JobCompleted {
+				run.jobResult = updatedJobStatus.Result
+				if run.jobResult.NotModified && run.stale != nil {
+					state.Finish(h.extendStaleResult(ctx, req, run.stale, run.validators, run.jobResult, run.notice))
+					return nil
+				}
+
 				// Convert models.DPResponse to services.DPResponse for parsing
 				servicesDPResponse := &services.DPResponse{
-					JobID:     updatedJobStatus.JobID,
-					Status:    updatedJobStatus.Result.Status,
-					Timestamp: updatedJobStatus.Result.Timestamp,
+					JobID:          updatedJobStatus.JobID,
+					DPID:           updatedJobStatus.Result.DPID,
+					Status:         services.DPStatus(updatedJobStatus.Result.Status),
+					Timestamp:      updatedJobStatus.Result.Timestamp,
+					PartialResults: updatedJobStatus.Result.PartialResults,
 				}
 
-				// Create verification result if verified
-				if updatedJobStatus.Result.Verified {
+				// Create verification result if verified or partly determined
+				if updatedJobStatus.Result.Verified || updatedJobStatus.Result.PartialResults != nil {
 					servicesDPResponse.VerificationResult = &services.VerificationResult{
 						Verified:   updatedJobStatus.Result.Verified,
 						Confidence: updatedJobStatus.Result.ConfidenceScore,
@@ -144,58 +559,323 @@ func (h *VerificationHandler) HandleVerification(w http.ResponseWriter, r *http.
 				// Parse DP response (T-012)
 				parsedResponse, err := h.responseParserService.ParseAndValidateResponse(servicesDPResponse)
 				if err != nil {
-					h.auditService.LogVerification(ctx, *req, nil, "RESPONSE_PARSE_ERROR")
-					writeError(w, "RESPONSE_PARSE_ERROR", "Failed to parse response", http.StatusInternalServerError)
-					return
+					return h.failVerification(ctx, req, "RESPONSE_PARSE_ERROR", stageFailure("RESPONSE_PARSE_ERROR", "Failed to parse response", http.StatusInternalServerError, nil))
 				}
 
 				// Convert to models.DPResponse for compatibility
-				dpResponse = h.responseParserService.ConvertToDPResponse(parsedResponse)
-				break
+				run.dpResponse = h.responseParserService.ConvertToDPResponse(parsedResponse)
+				run.dpResponse.Claim = run.jobResult.Claim
+				run.dpResponse.Consensus = run.jobResult.Consensus
+				run.dpResponse.Scoring = run.jobResult.Scoring
+				return nil
 			} else if updatedJobStatus.Status == services.JobFailed {
-				h.auditService.LogVerification(ctx, *req, nil, "JOB_FAILED")
-				writeError(w, "JOB_FAILED", "Verification job failed", http.StatusInternalServerError)
-				return
+				// A job can fail with the error of the call that opened a
+				// breaker, or of a fallback DP, so the outage is judged by
+				// the breakers rather than by the job's error
+				failure := jobFailure(updatedJobStatus)
+				if updatedJobStatus.ErrorCode == models.ProblemCircuitOpen || h.dpService.ClaimCircuitOpen(req.ClaimType) {
+					if stale := h.serveStaleResult(ctx, req, failure.Code, run.notice); stale != nil {
+						state.Finish(stale)
+						return nil
+					}
+				}
+				return h.failVerification(ctx, req, "JOB_FAILED", failure)
 			}
 
 			// Wait before polling again
 			time.Sleep(100 * time.Millisecond)
 		}
 	}
+}
+
+// formatResponse builds the signed response from the DP result (T-013) and
+// annotates it
+func (h *VerificationHandler) formatResponse(ctx context.Context, state *services.VerificationState) error {
+	req := state.Request
+	run := runOf(state)
+	response := h.generateFormattedResponse(*req, run.dpResponse, run.requestID, ctx)
+
+	// Annotate the response with the crypto profile it was produced under
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["crypto_profile"] = services.ActiveCryptoProfile(h.config).Name
+
+	annotatePartialResults(response, run.dpResponse.PartialResults)
+	annotateClaim(response, run.dpResponse.Claim)
+	if run.dpResponse.Consensus != nil {
+		response.Metadata["consensus"] = run.dpResponse.Consensus
+	}
+	if run.dpResponse.Scoring != nil {
+		response.Metadata["confidence_scoring"] = run.dpResponse.Scoring
+	}
+	h.annotateEvidenceWeighting(req, response, run.dpResponse.PartialResults)
+	h.annotateSchemaDrift(response)
+	annotateDeprecation(response, run.notice)
+	state.Response = response
+	return nil
+}
+
+// auditResponse adds the response's audit reference (T-015). The entry is
+// committed with the final state of the pull job that produced the result.
+func (h *VerificationHandler) auditResponse(ctx context.Context, state *services.VerificationState) error {
+	response := state.Response
+	job, _ := h.pullJobService.JobSnapshot(runOf(state).jobID)
+	auditRef := h.auditService.CommitVerification(ctx, *state.Request, response, "SUCCESS", job)
+	if auditRef != nil {
+		response.AuditReference = auditRef.AuditEntryID
+		// Add audit metadata
+		response.Metadata["audit_merkle_proof"] = auditRef.MerkleProof
+		response.Metadata["audit_timestamp"] = auditRef.Timestamp
+		response.Metadata["audit_hash"] = auditRef.Hash
+	}
+	return nil
+}
+
+// recordResponse caches the result and keeps it in the verification history
+// for RP exports
+func (h *VerificationHandler) recordResponse(ctx context.Context, state *services.VerificationState) error {
+	req := state.Request
+	h.cacheService.CacheVerificationValidators(*req, jobValidators(runOf(state).jobResult))
+	h.cacheService.CacheVerificationResult(*req, state.Response)
+	h.recordStore.Append(services.NewVerificationRecord(*req, state.Response))
+	h.annotateDuplicateSubject(req, state.Response)
+	return nil
+}
+
+// federatedVerification verifies a request with a peer broker. The response
+// is built and signed here like a DP result, and its audit entry records the
+// peer's verification ID and audit reference.
+func (h *VerificationHandler) federatedVerification(ctx context.Context, req *models.VerificationRequest, peerID string, notice *services.ClaimDeprecationNotice) (*models.VerificationResponse, *services.StageError) {
+	requestID := getRequestID(ctx)
+	trace := services.VerificationTraceFrom(ctx)
+
+	dpResponse, record, err := h.federation.Forward(ctx, peerID, *req, requestID)
+	if err != nil {
+		return nil, h.failVerification(ctx, req, "FEDERATION_ERROR", stageFailure("FEDERATION_ERROR", "Peer broker verification failed", http.StatusBadGateway, map[string]interface{}{"peer_id": peerID}))
+	}
 
-	// Generate formatted response (T-013)
+	trace.Begin("response_formatting")
 	response := h.generateFormattedResponse(*req, dpResponse, requestID, ctx)
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["crypto_profile"] = services.ActiveCryptoProfile(h.config).Name
+	response.Metadata["federation"] = record
+	annotateDeprecation(response, notice)
 
-	// Add audit reference to response (T-015)
 	auditRef := h.auditService.LogVerification(ctx, *req, response, "SUCCESS")
 	if auditRef != nil {
 		response.AuditReference = auditRef.AuditEntryID
-		// Add audit metadata
-		if response.Metadata == nil {
-			response.Metadata = make(map[string]interface{})
-		}
 		response.Metadata["audit_merkle_proof"] = auditRef.MerkleProof
 		response.Metadata["audit_timestamp"] = auditRef.Timestamp
 		response.Metadata["audit_hash"] = auditRef.Hash
 	}
 
-	// Cache successful result
 	h.cacheService.CacheVerificationResult(*req, response)
+	h.recordStore.Append(services.NewVerificationRecord(*req, response))
+	h.annotateDuplicateSubject(req, response)
 
-	// Return response
-	writeResponse(w, response)
+	return response, nil
+}
+
+// extendStaleResult serves a cached result the DP confirmed unchanged with a
+// 304, renewing it in the cache
+func (h *VerificationHandler) extendStaleResult(ctx context.Context, req *models.VerificationRequest, stale *models.VerificationResponse, validators *services.DPValidators, jobResult *models.DPResponse, notice *services.ClaimDeprecationNotice) *models.VerificationResponse {
+	refreshed := *validators
+	if jobResult.ETag != "" {
+		refreshed.ETag = jobResult.ETag
+	}
+	if jobResult.LastModified != "" {
+		refreshed.LastModified = jobResult.LastModified
+	}
+
+	auditRef := h.auditService.LogVerification(ctx, *req, stale, "CACHE_REVALIDATED")
+	if auditRef != nil {
+		stale.AuditReference = auditRef.AuditEntryID
+	}
+	h.cacheService.ExtendVerificationResult(*req, stale, &refreshed)
+
+	h.annotateDuplicateSubject(req, stale)
+	annotateDeprecation(stale, notice)
+	return stale
+}
+
+// serveStaleResult serves a cached result, expired or not, in place of a
+// failure while the circuit of one of the claim type's DPs is open or
+// half-open, if the claim type's stale_if_outage allows it. The result
+// keeps its original timestamp and is marked stale and degraded, with the
+// problem code of the failure it replaces, so the RP can decide whether to
+// rely on it.
+func (h *VerificationHandler) serveStaleResult(ctx context.Context, req *models.VerificationRequest, reason string, notice *services.ClaimDeprecationNotice) *models.VerificationResponse {
+	stale := h.cacheService.GetStaleResult(*req)
+	if stale == nil {
+		return nil
+	}
+	stale.Stale = true
+	stale.Degraded = true
+	if stale.Metadata == nil {
+		stale.Metadata = make(map[string]interface{})
+	}
+	staleInfo := map[string]interface{}{
+		"reason":      reason,
+		"verified_at": stale.Timestamp,
+	}
+	if verifiedAt, err := time.Parse(time.RFC3339, stale.Timestamp); err == nil {
+		staleInfo["age_seconds"] = int64(time.Since(verifiedAt).Seconds())
+	}
+	stale.Metadata["stale"] = staleInfo
+
+	auditRef := h.auditService.LogVerification(ctx, *req, stale, "STALE_SERVED")
+	if auditRef != nil {
+		stale.AuditReference = auditRef.AuditEntryID
+	}
+	h.annotateDuplicateSubject(req, stale)
+	annotateDeprecation(stale, notice)
+	return stale
+}
+
+// jobValidators returns the DP validators of a job result, if it had any
+func jobValidators(result *models.DPResponse) *services.DPValidators {
+	if result == nil || (result.ETag == "" && result.LastModified == "") {
+		return nil
+	}
+	return &services.DPValidators{DPID: result.DPID, ETag: result.ETag, LastModified: result.LastModified}
+}
+
+// annotateDuplicateSubject tells RPs that opted in whether they verified the
+// same subject before. It is added after caching so a cached response never
+// carries a stale signal.
+func (h *VerificationHandler) annotateDuplicateSubject(req *models.VerificationRequest, response *models.VerificationResponse) {
+	signal := h.duplicateDetector.Observe(req.RPID, req.Identifiers)
+	if signal == nil {
+		return
+	}
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["duplicate_subject"] = signal
+}
+
+// annotateClaim adds the DP's result in the canonical claim model, for
+// policies and RPs reading claim attributes without DP-specific names
+func annotateClaim(response *models.VerificationResponse, claim *models.Claim) {
+	if claim == nil {
+		return
+	}
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["claim"] = claim
+}
+
+// annotatePartialResults tells the RP which identifiers a partial result
+// matched and which the DP could not determine
+func annotatePartialResults(response *models.VerificationResponse, partial *models.PartialResults) {
+	if partial == nil {
+		return
+	}
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["partial_results"] = services.PartialResultsSummary(partial)
+}
+
+// annotateEvidenceWeighting weighs the DP result with the claim type's
+// weighting table and references the table version in the response. A
+// partial result is weighed by its coverage.
+func (h *VerificationHandler) annotateEvidenceWeighting(req *models.VerificationRequest, response *models.VerificationResponse, partial *models.PartialResults) {
+	schema, exists := h.schemaRegistry.Get(req.ClaimType)
+	if !exists || schema.EvidenceWeighting == nil {
+		return
+	}
+
+	result := services.EvidenceResult{
+		DPID:       response.DPID,
+		Verified:   response.Verified,
+		Confidence: response.ConfidenceScore,
+		Evidence:   response.Evidence,
+	}
+	if partial != nil {
+		result.Partial = true
+		result.Coverage = partial.Coverage()
+	}
+	aggregate := schema.EvidenceWeighting.Aggregate([]services.EvidenceResult{result})
+	response.Metadata["evidence_weighting"] = map[string]interface{}{
+		"version":             aggregate.Version,
+		"weighted_confidence": aggregate.Confidence,
+		"weight":              aggregate.Results[0].Weight,
+	}
+}
+
+// deprecationNotice returns the notice for a deprecated or retired claim type
+func (h *VerificationHandler) deprecationNotice(req *models.VerificationRequest) *services.ClaimDeprecationNotice {
+	schema, exists := h.schemaRegistry.Get(req.ClaimType)
+	if !exists {
+		return nil
+	}
+	return schema.DeprecationNotice(time.Now())
+}
+
+// annotateDeprecation adds a deprecation notice to a response's metadata
+func annotateDeprecation(response *models.VerificationResponse, notice *services.ClaimDeprecationNotice) {
+	if notice == nil {
+		return
+	}
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["claim_type_lifecycle"] = notice
+}
+
+// setDeprecationHeaders sets the Deprecation (RFC 9745) and Sunset (RFC 8594)
+// headers, and a Warning pointing RPs to the replacement claim type
+func setDeprecationHeaders(w http.ResponseWriter, notice *services.ClaimDeprecationNotice) {
+	if notice.DeprecatedAt != nil {
+		w.Header().Set("Deprecation", fmt.Sprintf("@%d", notice.DeprecatedAt.Unix()))
+	} else {
+		w.Header().Set("Deprecation", "true")
+	}
+	if notice.SunsetDate != nil {
+		w.Header().Set("Sunset", notice.SunsetDate.UTC().Format(http.TimeFormat))
+	}
+
+	warning := fmt.Sprintf("claim type %s is deprecated", notice.ClaimType)
+	if notice.ReplacedBy != "" {
+		warning += fmt.Sprintf("; use %s instead", notice.ReplacedBy)
+	}
+	w.Header().Set("Warning", fmt.Sprintf("299 - %q", warning))
+}
+
+// annotateSchemaDrift flags results from a DP whose responses no longer
+// match its registered schema
+func (h *VerificationHandler) annotateSchemaDrift(response *models.VerificationResponse) {
+	changes := h.dpService.SchemaDrift().CurrentDrift(response.DPID)
+	if len(changes) == 0 {
+		return
+	}
+
+	response.Metadata["schema_drift"] = map[string]interface{}{
+		"dp_id":   response.DPID,
+		"changes": changes,
+	}
 }
 
 // generateFormattedResponse creates a formatted verification response using T-013 and T-014
 func (h *VerificationHandler) generateFormattedResponse(req models.VerificationRequest, dpResponse *models.DPResponse, requestID string, ctx context.Context) *models.VerificationResponse {
 	// Convert models.DPResponse to services.DPResponse for parsing
 	servicesDPResponse := &services.DPResponse{
-		Status:    dpResponse.Status,
-		Timestamp: dpResponse.Timestamp,
+		DPID:           dpResponse.DPID,
+		Status:         services.DPStatus(dpResponse.Status),
+		Timestamp:      dpResponse.Timestamp,
+		PartialResults: dpResponse.PartialResults,
+	}
+	if policy, exists := h.dpService.Registry().Freshness(req.ClaimType); exists {
+		servicesDPResponse.Freshness = &policy
 	}
 
-	// Create verification result if verified
-	if dpResponse.Verified {
+	// Create verification result if verified or partly determined
+	if dpResponse.Verified || dpResponse.PartialResults != nil {
 		servicesDPResponse.VerificationResult = &services.VerificationResult{
 			Verified:   dpResponse.Verified,
 			Confidence: dpResponse.ConfidenceScore,
@@ -278,7 +958,7 @@ func (h *VerificationHandler) generateFormattedResponse(req models.VerificationR
 func (h *VerificationHandler) generateResponse(req models.VerificationRequest, dpResponse *services.DPResponse, requestID string) *models.VerificationResponse {
 	// Convert services.DPResponse to models.DPResponse
 	modelsDPResponse := &models.DPResponse{
-		Status:          dpResponse.Status,
+		Status:          string(dpResponse.Status),
 		Verified:        false, // Will be set from VerificationResult if available
 		ConfidenceScore: 0.0,   // Will be set from VerificationResult if available
 		DPID:            "dp-connector",
@@ -312,29 +992,23 @@ func getRequestID(ctx context.Context) string {
 	if requestID, ok := ctx.Value("request_id").(string); ok {
 		return requestID
 	}
+	// Set by the RequestID middleware and returned to the RP as X-Request-ID
+	if requestID, ok := ctx.Value(middleware.RequestIDKey{}).(string); ok {
+		return requestID
+	}
 	return "unknown"
 }
 
-// writeResponse writes a JSON response
-func writeResponse(w http.ResponseWriter, response *models.VerificationResponse) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
-}
-
 // writeError writes a structured error response
 func writeError(w http.ResponseWriter, code, message string, statusCode int) {
-	errorResponse := models.NewErrorResponse(code, message, "unknown")
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+	writeErrorWithDetails(w, code, message, nil, statusCode)
+}
 
-	if data, err := errorResponse.ToJSON(); err == nil {
-		w.Write(data)
-	} else {
-		// Fallback error response
-		w.Write([]byte(`{"error":{"code":"INTERNAL_ERROR","message":"Failed to format error response"}}`))
-	}
+// writeErrorWithDetails writes a structured error response carrying details
+func writeErrorWithDetails(w http.ResponseWriter, code, message string, details map[string]interface{}, statusCode int) {
+	problem := models.NewProblem(code, message, statusCode)
+	problem.Details = details
+	middleware.WriteProblem(w, problem)
 }
 
 // getValidatedRequestFromContext retrieves the validated request from context
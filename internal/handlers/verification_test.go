@@ -1,15 +1,25 @@
 package handlers
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pavilion-trust/core-broker/internal/config"
 	"github.com/pavilion-trust/core-broker/internal/models"
+	"github.com/pavilion-trust/core-broker/internal/services"
 )
 
 func TestVerificationHandler_HandleVerification(t *testing.T) {
@@ -69,8 +79,8 @@ func TestVerificationHandler_HandleVerification(t *testing.T) {
 	if errorResponse.Error == nil {
 		t.Error("Expected error response")
 	}
-	if errorResponse.Error.Code != "AUTHORIZATION_DENIED" {
-		t.Errorf("Expected error code 'AUTHORIZATION_DENIED', got %s", errorResponse.Error.Code)
+	if errorResponse.Error.Code != "POLICY_DENIED" {
+		t.Errorf("Expected error code 'POLICY_DENIED', got %s", errorResponse.Error.Code)
 	}
 }
 
@@ -113,4 +123,483 @@ func TestVerificationHandler_HandleVerification_InvalidRequest(t *testing.T) {
 	if w.Code != http.StatusBadRequest {
 		t.Errorf("Expected status 400, got %d", w.Code)
 	}
-} 
\ No newline at end of file
+} 
+func TestVerificationHandler_HandlePolicySimulation(t *testing.T) {
+	cfg := &config.Config{
+		Port: "8080",
+		Env:  "test",
+		// Use invalid OPA URL to prevent actual OPA calls in tests
+		OPAURL: "http://invalid-opa-url:8181",
+	}
+
+	handler := NewVerificationHandler(cfg)
+
+	req := models.VerificationRequest{
+		RPID:      "test-rp",
+		UserID:    "test-user",
+		ClaimType: "age_verification",
+		Identifiers: map[string]string{
+			"email": "test@example.com",
+		},
+	}
+
+	reqBody, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest("POST", "/api/v1/policy/simulate", bytes.NewBuffer(reqBody))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq = httpReq.WithContext(context.WithValue(httpReq.Context(), "validated_request", &req))
+
+	w := httptest.NewRecorder()
+	handler.HandlePolicySimulation(w, httpReq)
+
+	// Denied simulations are still successful responses
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var simulation map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&simulation); err != nil {
+		t.Fatalf("Failed to decode simulation response: %v", err)
+	}
+	if simulation["simulation"] != true {
+		t.Error("Expected response to be marked as a simulation")
+	}
+	if _, ok := simulation["disclosure_levels"]; !ok {
+		t.Error("Expected disclosure levels in response")
+	}
+	if _, ok := simulation["required_consents"]; !ok {
+		t.Error("Expected required consents in response")
+	}
+
+	// No verification is recorded for simulations
+	if handler.RecordStore().Count() != 0 {
+		t.Error("Expected simulation not to record a verification")
+	}
+}
+
+func TestVerificationHandler_HandleVerification_RejectsPlaceholderIdentifiers(t *testing.T) {
+	cfg := &config.Config{
+		Port:                        "8080",
+		Env:                         "test",
+		OPAURL:                      "http://invalid-opa-url:8181",
+		IdentifierMinEntropyBits:    8,
+		IdentifierDisposableDomains: []string{"mailinator.com"},
+	}
+
+	handler := NewVerificationHandler(cfg)
+
+	req := models.VerificationRequest{
+		RPID:      "test-rp",
+		UserID:    "test-user",
+		ClaimType: "student_verification",
+		Identifiers: map[string]string{
+			"email": "someone@mailinator.com",
+			"phone": "000-000-0000",
+		},
+	}
+
+	reqBody, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest("POST", "/api/v1/verify", bytes.NewBuffer(reqBody))
+	httpReq = httpReq.WithContext(context.WithValue(httpReq.Context(), "validated_request", &req))
+
+	w := httptest.NewRecorder()
+	handler.HandleVerification(w, httpReq)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+
+	var errorResponse models.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&errorResponse); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	if errorResponse.Error.Code != "INVALID_IDENTIFIER" {
+		t.Errorf("Expected error code 'INVALID_IDENTIFIER', got %s", errorResponse.Error.Code)
+	}
+	issues, _ := errorResponse.Error.Details["issues"].([]interface{})
+	if len(issues) != 2 {
+		t.Errorf("Expected an issue per identifier, got %v", errorResponse.Error.Details)
+	}
+}
+
+func TestVerificationHandler_AnnotatesEvidenceWeighting(t *testing.T) {
+	cfg := &config.Config{
+		Port:   "8080",
+		Env:    "test",
+		OPAURL: "http://invalid-opa-url:8181",
+	}
+
+	handler := NewVerificationHandler(cfg)
+	schema, _ := handler.SchemaRegistry().Get("student_verification")
+	weighted := *schema
+	weighted.EvidenceWeighting = &services.EvidenceWeighting{
+		Version:       "v3",
+		EvidenceCodes: map[string]float64{"self_asserted": 0.5},
+	}
+	if err := handler.SchemaRegistry().Register(&weighted); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	req := &models.VerificationRequest{RPID: "test-rp", ClaimType: "student_verification"}
+	response := &models.VerificationResponse{
+		Verified:        true,
+		ConfidenceScore: 0.9,
+		Evidence:        []string{"self_asserted"},
+		DPID:            "dp-1",
+		Metadata:        map[string]interface{}{},
+	}
+	handler.annotateEvidenceWeighting(req, response, nil)
+
+	annotation, ok := response.Metadata["evidence_weighting"].(map[string]interface{})
+	if !ok || annotation["version"] != "v3" || annotation["weight"] != 0.5 {
+		t.Errorf("Expected the v3 weighting to be referenced, got %v", response.Metadata["evidence_weighting"])
+	}
+
+	// A partial result counts for the share of identifiers the DP determined
+	partial := &models.PartialResults{Identifiers: map[string]string{"email": models.IdentifierMatched, "student_id": models.IdentifierUnknown}}
+	annotatePartialResults(response, partial)
+	handler.annotateEvidenceWeighting(req, response, partial)
+	annotation = response.Metadata["evidence_weighting"].(map[string]interface{})
+	if annotation["weight"] != 0.25 {
+		t.Errorf("Expected the partial result to be weighed by its coverage, got %v", annotation["weight"])
+	}
+	if summary, ok := response.Metadata["partial_results"].(map[string]interface{}); !ok || summary["unknown"] != 1 {
+		t.Errorf("Expected the partial results to be reported, got %v", response.Metadata["partial_results"])
+	}
+
+	unweighted := &models.VerificationResponse{Metadata: map[string]interface{}{}}
+	handler.annotateEvidenceWeighting(&models.VerificationRequest{ClaimType: "age_verification"}, unweighted, nil)
+	if _, exists := unweighted.Metadata["evidence_weighting"]; exists {
+		t.Error("Claim types without a weighting table should not be annotated")
+	}
+}
+
+func TestVerificationHandler_HandleVerification_RetiredClaimType(t *testing.T) {
+	cfg := &config.Config{
+		Port:   "8080",
+		Env:    "test",
+		OPAURL: "http://invalid-opa-url:8181",
+	}
+
+	handler := NewVerificationHandler(cfg)
+	schema, _ := handler.SchemaRegistry().Get("student_verification")
+	retired := *schema
+	retired.Lifecycle = &services.ClaimLifecycle{
+		State:          services.ClaimStateRetired,
+		ReplacedBy:     "enrollment_verification",
+		MigrationGuide: "https://docs.example/enrollment",
+	}
+	if err := handler.SchemaRegistry().Register(&retired); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	req := models.VerificationRequest{
+		RPID:        "test-rp",
+		UserID:      "test-user",
+		ClaimType:   "student_verification",
+		Identifiers: map[string]string{"email": "test@example.com"},
+	}
+	reqBody, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest("POST", "/api/v1/verify", bytes.NewBuffer(reqBody))
+	httpReq = httpReq.WithContext(context.WithValue(httpReq.Context(), "validated_request", &req))
+
+	w := httptest.NewRecorder()
+	handler.HandleVerification(w, httpReq)
+
+	if w.Code != http.StatusGone {
+		t.Fatalf("Expected status 410, got %d", w.Code)
+	}
+	var errorResponse models.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&errorResponse); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	lifecycle, _ := errorResponse.Error.Details["lifecycle"].(map[string]interface{})
+	if errorResponse.Error.Code != "CLAIM_TYPE_RETIRED" || lifecycle["replaced_by"] != "enrollment_verification" {
+		t.Errorf("Expected migration guidance, got %+v", errorResponse.Error)
+	}
+	if events := handler.ClaimDeprecations().EventsForRP("test-rp"); len(events) != 1 {
+		t.Errorf("Expected the RP's use to be recorded, got %v", events)
+	}
+}
+
+func TestSetDeprecationHeaders(t *testing.T) {
+	deprecatedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	w := httptest.NewRecorder()
+	setDeprecationHeaders(w, &services.ClaimDeprecationNotice{
+		ClaimType:    "student_verification",
+		State:        services.ClaimStateDeprecated,
+		DeprecatedAt: &deprecatedAt,
+		SunsetDate:   &sunset,
+		ReplacedBy:   "enrollment_verification",
+	})
+
+	if got := w.Header().Get("Deprecation"); got != "@1767225600" {
+		t.Errorf("Unexpected Deprecation header %q", got)
+	}
+	if got := w.Header().Get("Sunset"); got != "Wed, 01 Jul 2026 00:00:00 GMT" {
+		t.Errorf("Unexpected Sunset header %q", got)
+	}
+	if got := w.Header().Get("Warning"); got != `299 - "claim type student_verification is deprecated; use enrollment_verification instead"` {
+		t.Errorf("Unexpected Warning header %q", got)
+	}
+}
+
+func TestVerificationHandler_HandleVerification_NotAcceptable(t *testing.T) {
+	handler := NewVerificationHandler(&config.Config{Port: "8080", Env: "test", OPAURL: "http://invalid-opa-url:8181"})
+
+	req := models.VerificationRequest{RPID: "test-rp", UserID: "test-user", ClaimType: "student_verification"}
+	httpReq := httptest.NewRequest("POST", "/api/v1/verify", nil)
+	httpReq.Header.Set("Accept", "text/html, application/xml;q=0.9")
+	httpReq = httpReq.WithContext(context.WithValue(httpReq.Context(), "validated_request", &req))
+
+	w := httptest.NewRecorder()
+	handler.HandleVerification(w, httpReq)
+	if w.Code != http.StatusNotAcceptable || !strings.Contains(w.Body.String(), "NOT_ACCEPTABLE") {
+		t.Errorf("Expected 406 before any verification, got %d: %s", w.Code, w.Body.String())
+	}
+	if handler.RecordStore().Count() != 0 {
+		t.Error("Expected no verification to be recorded")
+	}
+}
+
+func TestVerificationHandler_FederatedVerificationFailure(t *testing.T) {
+	peer := httptest.NewServer(http.NotFoundHandler())
+	peer.Close()
+
+	path := t.TempDir() + "/federation.json"
+	data, _ := json.Marshal(services.FederationFile{Peers: []services.FederationPeer{{
+		ID:            "network-b",
+		ManifestURL:   peer.URL + services.BrokerManifestPath,
+		RPID:          "broker-a",
+		ClaimMappings: map[string]services.FederationClaimMapping{"age_verification": {}},
+	}}})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Env: "test", FederationFile: path}
+	handler := NewVerificationHandler(cfg)
+
+	peerID, federated := handler.Federation().Route("age_verification", handler.DPService().Registry())
+	if !federated {
+		t.Fatal("Expected age verifications to be federated")
+	}
+	req := &models.VerificationRequest{RPID: "test-rp", UserID: "user-1", ClaimType: "age_verification", Identifiers: map[string]string{"email": "a@example.com"}}
+	_, verr := handler.federatedVerification(context.Background(), req, peerID, nil)
+	if verr == nil || verr.Code != "FEDERATION_ERROR" || verr.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected an unreachable peer to fail with FEDERATION_ERROR, got %+v", verr)
+	}
+	if peers := handler.Federation().Peers(); peers[0].Failed != 1 {
+		t.Errorf("Expected the failure to be counted, got %+v", peers)
+	}
+}
+
+func TestVerificationHandler_ServesStaleResultDuringOutage(t *testing.T) {
+	redisHost, redisPort, cache := startFakeRedis(t)
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer dp.Close()
+
+	// One failure opens the DP's breaker, so the call that fails the job
+	// reports the DP's 503 rather than CIRCUIT_OPEN
+	path := t.TempDir() + "/dp-registry.json"
+	registry := fmt.Sprintf(`{
+		"providers": [{"dp_id": "dp_hr", "endpoint": %q, "supported_claims": ["employee_verification"], "adapter_type": "rest",
+			"breaker": {"mode": "error_rate", "window_size": 1, "minimum_calls": 1}}],
+		"freshness": {"employee_verification": {"max_age": "24h", "stale_if_outage": "72h"}}
+	}`, dp.URL)
+	if err := os.WriteFile(path, []byte(registry), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Env:            "test",
+		DPRegistryFile: path,
+		Redis:          config.RedisConfig{Host: redisHost, Port: redisPort},
+		Runtime:        config.NewRuntimeConfig("", config.RuntimeSettings{Retry: &config.RetrySettings{MaxRetries: 0}}),
+	}
+	handler := NewVerificationHandler(cfg)
+	handler.Orchestrator().Remove("authorization")
+	store := services.NewMemoryStorage()
+	handler.AuditService().SetStorage(store)
+
+	// The cached result expired six hours ago
+	verifiedAt := time.Now().Add(-30 * time.Hour).UTC().Format(time.RFC3339)
+	cached, _ := json.Marshal(models.VerificationResponse{
+		Verified:  true,
+		Status:    "verified",
+		DPID:      "dp_hr",
+		Timestamp: verifiedAt,
+		ExpiresAt: time.Now().Add(-6 * time.Hour).UTC().Format(time.RFC3339),
+	})
+	cache.set("verification:test-rp:user-1:employee_verification", string(cached))
+
+	req := models.VerificationRequest{
+		RPID:        "test-rp",
+		UserID:      "user-1",
+		ClaimType:   "employee_verification",
+		Identifiers: map[string]string{"email": "jordan.lee@acme-corp.com"},
+	}
+	verify := func(requestID string) *httptest.ResponseRecorder {
+		req.Metadata = map[string]interface{}{"request_id": requestID}
+		reqBody, _ := json.Marshal(req)
+		httpReq := httptest.NewRequest("POST", "/api/v1/verify", bytes.NewBuffer(reqBody))
+		httpReq = httpReq.WithContext(context.WithValue(httpReq.Context(), "validated_request", &req))
+		w := httptest.NewRecorder()
+		handler.HandleVerification(w, httpReq)
+		return w
+	}
+
+	w := verify("req-outage-1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the stale result to be served, got %d: %s", w.Code, w.Body.String())
+	}
+	var response models.VerificationResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !response.Stale || !response.Degraded || response.Timestamp != verifiedAt {
+		t.Errorf("Expected a stale, degraded result keeping its timestamp, got %+v", response)
+	}
+	stale, _ := response.Metadata["stale"].(map[string]interface{})
+	// The DP's 503 failed the job as it opened the breaker
+	if stale["verified_at"] != verifiedAt || stale["reason"] != "JOB_FAILED" {
+		t.Errorf("Expected metadata.stale to give the original verification time and the failure, got %v", response.Metadata["stale"])
+	}
+	if stats := handler.DPService().GetBreakerStats()["dp_hr"].(map[string]interface{}); stats["state"] != services.CircuitOpen {
+		t.Errorf("Expected the DP's breaker to be open, got %v", stats["state"])
+	}
+	entries, _ := store.ListAuditEntries(context.Background(), services.AuditEntryFilter{RPID: "test-rp"})
+	if len(entries) != 1 || entries[0].Status != "STALE_SERVED" || response.AuditReference == "" {
+		t.Errorf("Expected the verification to be audited as STALE_SERVED, got %+v", entries)
+	}
+
+	// Without stale_if_outage the outage fails the verification
+	if err := handler.DPService().Registry().SetFreshness(map[string]services.FreshnessPolicy{
+		"employee_verification": {MaxAge: config.Duration(24 * time.Hour)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if w := verify("req-outage-2"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected the verification to fail without stale_if_outage, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// fakeRedis is a Redis server holding string keys in memory, speaking just
+// enough of the protocol for the verification cache
+type fakeRedis struct {
+	mu   sync.Mutex
+	keys map[string]string
+}
+
+func (f *fakeRedis) set(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys[key] = value
+}
+
+// startFakeRedis serves a fakeRedis until the test ends, returning its
+// host and port
+func startFakeRedis(t *testing.T) (string, int, *fakeRedis) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeRedis{keys: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, server
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readRedisCommand(reader)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write([]byte(f.execute(args))); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) execute(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		value, exists := f.keys[args[1]]
+		if !exists {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		// Expiry is not simulated
+		f.keys[args[1]] = args[2]
+		return "+OK\r\n"
+	case "EXISTS", "DEL":
+		count := 0
+		for _, key := range args[1:] {
+			if _, exists := f.keys[key]; exists {
+				count++
+				if strings.EqualFold(args[0], "DEL") {
+					delete(f.keys, key)
+				}
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", count)
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+// readRedisCommand reads a command sent as an array of bulk strings
+func readRedisCommand(reader *bufio.Reader) ([]string, error) {
+	count, err := readRedisLength(reader, '*')
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		length, err := readRedisLength(reader, '$')
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:length])
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return args, nil
+}
+
+func readRedisLength(reader *bufio.Reader, prefix byte) (int, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) < 2 || line[0] != prefix {
+		return 0, fmt.Errorf("unexpected line %q", line)
+	}
+	return strconv.Atoi(line[1:])
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocketGUID is appended to the client key to form the handshake accept
+// value (RFC 6455 section 4.2.2)
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketFrame bounds frames read from clients, which only send control
+// frames to push-only streams
+const maxWebSocketFrame = 4096
+
+// WebSocket opcodes
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+)
+
+// WebSocket close codes
+const (
+	wsCloseNormal   = 1000
+	wsCloseTooBig   = 1009
+	wsCloseTryAgain = 1013
+)
+
+// wsConn is the server side of a WebSocket used to push messages. Writes
+// are serialized so the reader can answer pings while messages are sent.
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex
+}
+
+// checkWebSocketRequest reports why a request is not a WebSocket handshake
+func checkWebSocketRequest(r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return fmt.Errorf("WebSocket handshakes use GET")
+	}
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || !headerHasToken(r.Header, "Connection", "upgrade") {
+		return fmt.Errorf("request is not a WebSocket upgrade")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return fmt.Errorf("unsupported WebSocket version")
+	}
+	if r.Header.Get("Sec-WebSocket-Key") == "" {
+		return fmt.Errorf("missing Sec-WebSocket-Key")
+	}
+	return nil
+}
+
+// headerHasToken reports whether a comma-separated header lists a token
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket completes the handshake for a request that passed
+// checkWebSocketRequest and takes over the connection
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to take over connection: %w", err)
+	}
+	// The server's read and write timeouts do not apply to streams
+	conn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to complete WebSocket handshake: %w", err)
+	}
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+// writeFrame sends one unfragmented, unmasked frame
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// WriteText sends a text message
+func (c *wsConn) WriteText(payload []byte) error {
+	return c.writeFrame(wsOpText, payload)
+}
+
+// Ping sends a ping to keep idle connections open through proxies
+func (c *wsConn) Ping() error {
+	return c.writeFrame(wsOpPing, nil)
+}
+
+// Close sends a close frame with a code and closes the connection
+func (c *wsConn) Close(code int, reason string) {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	c.writeFrame(wsOpClose, append(payload, reason...))
+	c.conn.Close()
+}
+
+// readFrame reads one frame from the client, unmasking its payload
+func (c *wsConn) readFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0F
+	if header[1]&0x80 == 0 {
+		return 0, nil, fmt.Errorf("client frames must be masked")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.rw, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.rw, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > maxWebSocketFrame {
+		return 0, nil, errWebSocketFrameTooBig
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+var errWebSocketFrameTooBig = fmt.Errorf("WebSocket frame exceeds %d bytes", maxWebSocketFrame)
+
+// readLoop answers pings and returns when the client closes the connection
+// or breaks the protocol; messages from the client are ignored
+func (c *wsConn) readLoop() {
+	for {
+		opcode, payload, err := c.readFrame()
+		switch {
+		case err == errWebSocketFrameTooBig:
+			c.Close(wsCloseTooBig, "frame too large")
+			return
+		case err != nil:
+			c.conn.Close()
+			return
+		case opcode == wsOpPing:
+			c.writeFrame(wsOpPong, payload)
+		case opcode == wsOpClose:
+			c.Close(wsCloseNormal, "")
+			return
+		}
+	}
+}
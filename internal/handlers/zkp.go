@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// ZKPHandler generates zero-knowledge proofs and serves stored proofs to verifiers
+type ZKPHandler struct {
+	config     *config.Config
+	zkpService *services.ZKPService
+	proofStore *services.ZKPProofStore
+}
+
+// NewZKPHandler creates a new ZKP handler
+func NewZKPHandler(cfg *config.Config, zkpService *services.ZKPService, proofStore *services.ZKPProofStore) *ZKPHandler {
+	return &ZKPHandler{
+		config:     cfg,
+		zkpService: zkpService,
+		proofStore: proofStore,
+	}
+}
+
+// CreateProofRequest is a proof generation request with an optional retention period
+type CreateProofRequest struct {
+	services.ZKPRequest
+	TTLSeconds int64 `json:"ttl_seconds,omitempty"`
+}
+
+// HandleCreateProof handles POST /zkp/proofs, generating a proof and storing it
+// for later retrieval. The witness is never stored.
+func (h *ZKPHandler) HandleCreateProof(w http.ResponseWriter, r *http.Request) {
+	rpID := getCallerRPID(r.Context())
+	if rpID == "" {
+		writeError(w, "AUTHORIZATION_DENIED", "No tenant associated with caller", http.StatusForbidden)
+		return
+	}
+
+	var req CreateProofRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "INVALID_JSON", "Failed to parse request body", http.StatusBadRequest)
+		return
+	}
+	if req.TTLSeconds < 0 {
+		writeError(w, "INVALID_REQUEST", "ttl_seconds cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	proof, err := h.zkpService.GenerateProof(req.ZKPRequest)
+	if err != nil {
+		writeError(w, "PROOF_GENERATION_FAILED", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stored, err := h.proofStore.Save(rpID, proof, time.Duration(req.TTLSeconds)*time.Second)
+	if err != nil {
+		writeError(w, "INVALID_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/zkp/proofs/%s", stored.ProofID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(stored)
+}
+
+// HandleGetProof handles GET /zkp/proofs/{id}. Proof IDs are unguessable and
+// proofs reveal only public inputs, so any RP holding an ID may retrieve it.
+func (h *ZKPHandler) HandleGetProof(w http.ResponseWriter, r *http.Request) {
+	stored, err := h.proofStore.GetProof(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "NOT_FOUND", "Proof not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stored)
+}
+
+// HandleRevokeProof handles DELETE /zkp/proofs/{id}; only the RP that
+// generated a proof may revoke it
+func (h *ZKPHandler) HandleRevokeProof(w http.ResponseWriter, r *http.Request) {
+	stored, err := h.proofStore.Revoke(mux.Vars(r)["id"], getCallerRPID(r.Context()))
+	if err != nil {
+		writeError(w, "NOT_FOUND", "Proof not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stored)
+}
+
+// HandleListCircuits handles GET /zkp/circuits, listing built-in and
+// registered circuits
+func (h *ZKPHandler) HandleListCircuits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"circuits": h.zkpService.GetSupportedCircuits(),
+	})
+}
+
+// HandleRegisterCircuit handles POST /zkp/circuits, registering a circuit
+// derived from a built-in one
+func (h *ZKPHandler) HandleRegisterCircuit(w http.ResponseWriter, r *http.Request) {
+	var circuit services.ZKPCircuit
+	if err := json.NewDecoder(r.Body).Decode(&circuit); err != nil {
+		writeError(w, "INVALID_JSON", "Failed to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	registered, err := h.zkpService.Circuits().Register(circuit)
+	if err != nil {
+		writeError(w, "INVALID_CIRCUIT", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/zkp/circuits/%s", registered.Name))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(registered)
+}
+
+// HandleUnregisterCircuit handles DELETE /zkp/circuits/{name}. Built-in
+// circuits cannot be removed.
+func (h *ZKPHandler) HandleUnregisterCircuit(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	circuit, _, exists := h.zkpService.Circuits().Get(name)
+	if !exists {
+		writeError(w, "NOT_FOUND", "Circuit not found", http.StatusNotFound)
+		return
+	}
+	if circuit.Builtin {
+		writeError(w, "INVALID_REQUEST", "Built-in circuits cannot be removed", http.StatusConflict)
+		return
+	}
+	if err := h.zkpService.Circuits().Unregister(name); err != nil {
+		writeError(w, "NOT_FOUND", "Circuit not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
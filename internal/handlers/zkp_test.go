@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestZKPHandler_ProofLifecycle(t *testing.T) {
+	cfg := &config.Config{}
+	store := services.NewZKPProofStore(time.Hour, 24*time.Hour)
+	zkpService := services.NewZKPService(services.NewZKPConfig(5*time.Second, 1024*1024, "test", false))
+	zkpService.SetProofStore(store)
+	handler := NewZKPHandler(cfg, zkpService, store)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/zkp/proofs", handler.HandleCreateProof).Methods("POST")
+	router.HandleFunc("/zkp/proofs/{id}", handler.HandleGetProof).Methods("GET")
+	router.HandleFunc("/zkp/proofs/{id}", handler.HandleRevokeProof).Methods("DELETE")
+
+	asRP := func(req *http.Request, rpID string) *http.Request {
+		user := &services.UserInfo{Subject: "user-" + rpID, ResourceID: rpID, Roles: []string{"rp"}}
+		return req.WithContext(context.WithValue(req.Context(), "user", user))
+	}
+
+	body := `{"proof_type":"age_verification","statement":"age >= 18","witness":{"age":30},"public_inputs":{"minimum_age":18},"ttl_seconds":600}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, asRP(httptest.NewRequest("POST", "/zkp/proofs", strings.NewReader(body)), "rp_1"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created services.StoredProof
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode proof: %v", err)
+	}
+	if created.ProofID == "" || created.OwnerID != "rp_1" || strings.Contains(w.Body.String(), "witness") {
+		t.Fatalf("Unexpected stored proof: %+v", created)
+	}
+
+	t.Run("get", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, asRP(httptest.NewRequest("GET", "/zkp/proofs/"+created.ProofID, nil), "rp_2"))
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("revoke by other tenant", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, asRP(httptest.NewRequest("DELETE", "/zkp/proofs/"+created.ProofID, nil), "rp_2"))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, asRP(httptest.NewRequest("DELETE", "/zkp/proofs/"+created.ProofID, nil), "rp_1"))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var revoked services.StoredProof
+		json.NewDecoder(w.Body).Decode(&revoked)
+		if revoked.Status != services.ProofStatusRevoked {
+			t.Errorf("Expected revoked status, got %s", revoked.Status)
+		}
+	})
+
+	t.Run("unknown proof", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, asRP(httptest.NewRequest("GET", "/zkp/proofs/missing", nil), "rp_1"))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("no tenant", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/zkp/proofs", strings.NewReader(body)))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", w.Code)
+		}
+	})
+}
+
+func TestZKPHandler_CircuitRegistration(t *testing.T) {
+	store := services.NewZKPProofStore(time.Hour, 24*time.Hour)
+	zkpService := services.NewZKPService(services.NewZKPConfig(5*time.Second, 1024*1024, "test", false))
+	handler := NewZKPHandler(&config.Config{}, zkpService, store)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/zkp/circuits", handler.HandleListCircuits).Methods("GET")
+	router.HandleFunc("/zkp/circuits", handler.HandleRegisterCircuit).Methods("POST")
+	router.HandleFunc("/zkp/circuits/{name}", handler.HandleUnregisterCircuit).Methods("DELETE")
+
+	body := `{"name":"over_21","description":"Prove age is at least 21","base_proof_type":"age_verification","fixed_public_inputs":{"minimum_age":21}}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/zkp/circuits", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/zkp/circuits", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for duplicate circuit, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/zkp/circuits", nil))
+	var listed struct {
+		Circuits []services.ZKPCircuit `json:"circuits"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&listed); err != nil {
+		t.Fatalf("Failed to decode circuits: %v", err)
+	}
+	if len(listed.Circuits) != 5 || listed.Circuits[4].Name != "over_21" {
+		t.Errorf("Expected built-in circuits followed by over_21, got %+v", listed.Circuits)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/zkp/circuits/age_verification", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for built-in circuit, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/zkp/circuits/over_21", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+}
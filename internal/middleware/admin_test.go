@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestAdminAuthentication(t *testing.T) {
+	var subject string
+	handler := AdminAuthentication(&config.Config{AdminAPIToken: "break-glass"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject = r.Context().Value("user").(*services.UserInfo).Subject
+	}))
+
+	call := func(authorization string) int {
+		req := httptest.NewRequest("POST", "/admin/v1/cache/flush", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := call("Bearer break-glass"); code != http.StatusOK || subject != AdminTokenSubject {
+		t.Errorf("Expected the admin token to be accepted, got %d for %q", code, subject)
+	}
+	// Other tokens are validated with Keycloak
+	if code := call("Bearer wrong"); code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a wrong token, got %d", code)
+	}
+	if code := call(""); code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, got %d", code)
+	}
+
+	// Without ADMIN_API_TOKEN only Keycloak admins are accepted
+	handler = AdminAuthentication(&config.Config{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if code := call("Bearer "); code != http.StatusUnauthorized {
+		t.Errorf("Expected an empty token to be rejected, got %d", code)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/pavilion-trust/core-broker/internal/config"
 )
@@ -173,10 +174,31 @@ func TestGetClientID(t *testing.T) {
 }
 
 func TestIsRateLimited(t *testing.T) {
-	// This is a simplified implementation that always returns false
-	// In production, this would check against Redis or another storage
-	result := isRateLimited("test-client")
-	if result {
-		t.Error("Expected rate limiting to be disabled in test implementation")
+	limiter := newRateLimiter()
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	// Without a limit every request is allowed
+	for i := 0; i < 100; i++ {
+		if limiter.isRateLimited("test-client", nil) {
+			t.Fatal("Expected rate limiting to be disabled without a limit")
+		}
+	}
+
+	limit := &config.RateLimitSettings{RequestsPerMinute: 60, Burst: 2}
+	if limiter.isRateLimited("limited-client", limit) || limiter.isRateLimited("limited-client", limit) {
+		t.Fatal("Expected the burst to be allowed")
+	}
+	if !limiter.isRateLimited("limited-client", limit) {
+		t.Error("Expected the request after the burst to be limited")
 	}
-} 
\ No newline at end of file
+	if limiter.isRateLimited("other-client", limit) {
+		t.Error("Expected clients to be limited separately")
+	}
+
+	// One request per second refills
+	now = now.Add(time.Second)
+	if limiter.isRateLimited("limited-client", limit) {
+		t.Error("Expected a refilled token to be allowed")
+	}
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"math/rand"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+const (
+	// defaultBrownoutRetryAfter is sent when the settings give no retry_after
+	defaultBrownoutRetryAfter = 60 * time.Second
+	// maintenanceProblemType identifies the problem returned to shed requests
+	maintenanceProblemType = "urn:pavilion:problem:maintenance"
+)
+
+// brownout tracks when the brownout settings in effect were loaded, so the
+// rejected share can ramp up from then
+type brownout struct {
+	mu       sync.Mutex
+	settings config.BrownoutSettings
+	active   bool
+	since    time.Time
+	now      func() time.Time
+	random   func() float64
+}
+
+func newBrownout() *brownout {
+	return &brownout{now: time.Now, random: rand.Float64}
+}
+
+// share returns the fraction (0-1) of non-critical requests to reject now.
+// A reload that changes the settings restarts the ramp; one that leaves them
+// unchanged does not.
+func (b *brownout) share(settings *config.BrownoutSettings) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if settings == nil || settings.Percent <= 0 {
+		b.active = false
+		return 0
+	}
+	now := b.now()
+	if !b.active || !reflect.DeepEqual(b.settings, *settings) {
+		b.settings = *settings
+		b.active = true
+		b.since = now
+	}
+
+	share := settings.Percent / 100
+	if ramp := time.Duration(settings.Ramp); ramp > 0 {
+		if elapsed := now.Sub(b.since); elapsed < ramp {
+			share *= float64(elapsed) / float64(ramp)
+		}
+	}
+	return share
+}
+
+// exempt reports whether a request is never rejected: it comes from a
+// priority RP or is for a critical path
+func (b *brownout) exempt(r *http.Request, settings *config.BrownoutSettings) bool {
+	rpID := RequestTenant(r.Context())
+	for _, exempt := range settings.ExemptRPs {
+		if rpID != "" && rpID == exempt {
+			return true
+		}
+	}
+	for _, prefix := range settings.CriticalPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Brownout rejects the share of non-critical traffic set by the brownout
+// runtime settings with a 503 maintenance problem, so load can be reduced in
+// steps before planned maintenance. It must run after Authentication so the
+// caller's RP is known.
+func Brownout(cfg *config.Config) func(http.Handler) http.Handler {
+	state := newBrownout()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Brownout is a runtime setting, so it is read on each request
+			settings := cfg.RuntimeSettings().Brownout
+			share := state.share(settings)
+			if share <= 0 || state.exempt(r, settings) || state.random() >= share {
+				next.ServeHTTP(w, r)
+				return
+			}
+			writeMaintenanceProblem(w, r, settings)
+		})
+	}
+}
+
+// writeMaintenanceProblem writes an RFC 7807 problem telling the client the
+// service is shedding load for maintenance
+func writeMaintenanceProblem(w http.ResponseWriter, r *http.Request, settings *config.BrownoutSettings) {
+	retryAfter := time.Duration(settings.RetryAfter)
+	if retryAfter <= 0 {
+		retryAfter = defaultBrownoutRetryAfter
+	}
+	detail := settings.Message
+	if detail == "" {
+		detail = "The service is reducing load ahead of planned maintenance; retry later"
+	}
+
+	problem := models.NewProblem(models.ProblemServiceBrownout, detail, http.StatusServiceUnavailable)
+	problem.Type = maintenanceProblemType
+	problem.Instance = r.URL.Path
+	problem.RetryAfter = int(retryAfter.Seconds())
+
+	w.Header().Set("Retry-After", strconv.Itoa(problem.RetryAfter))
+	WriteProblem(w, problem)
+}
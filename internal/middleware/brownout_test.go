@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestBrownout_RejectsNonCriticalTraffic(t *testing.T) {
+	cfg := &config.Config{Runtime: config.NewRuntimeConfig("", config.RuntimeSettings{
+		Brownout: &config.BrownoutSettings{
+			Percent:       100,
+			ExemptRPs:     []string{"rp-priority"},
+			CriticalPaths: []string{"/api/v1/verify/"},
+			RetryAfter:    config.Duration(2 * time.Minute),
+		},
+	})}
+	handler := Brownout(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	call := func(path, rpID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		user := &services.UserInfo{Subject: "user-1", ResourceID: rpID}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), "user", user)))
+		return w
+	}
+
+	w := call("/api/v1/catalog", "rp-1")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("Expected a maintenance problem, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("Retry-After") != "120" {
+		t.Errorf("Expected Retry-After 120, got %s", w.Header().Get("Retry-After"))
+	}
+	var problem map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&problem)
+	if problem["type"] != maintenanceProblemType || problem["status"] != float64(http.StatusServiceUnavailable) {
+		t.Errorf("Unexpected problem: %v", problem)
+	}
+
+	if w := call("/api/v1/catalog", "rp-priority"); w.Code != http.StatusOK {
+		t.Errorf("Expected a priority RP to pass, got %d", w.Code)
+	}
+	if w := call("/api/v1/verify/status", "rp-1"); w.Code != http.StatusOK {
+		t.Errorf("Expected a critical path to pass, got %d", w.Code)
+	}
+}
+
+func TestBrownout_Ramp(t *testing.T) {
+	settings := &config.BrownoutSettings{Percent: 40, Ramp: config.Duration(10 * time.Minute)}
+	state := newBrownout()
+	now := time.Date(2025, 8, 2, 7, 0, 0, 0, time.UTC)
+	state.now = func() time.Time { return now }
+
+	if share := state.share(settings); share != 0 {
+		t.Errorf("Expected the ramp to start at zero, got %v", share)
+	}
+	now = now.Add(5 * time.Minute)
+	if share := state.share(settings); share < 0.19 || share > 0.21 {
+		t.Errorf("Expected half the target halfway through the ramp, got %v", share)
+	}
+
+	// Reloading the same settings keeps the ramp going
+	same := *settings
+	now = now.Add(10 * time.Minute)
+	if share := state.share(&same); share != 0.4 {
+		t.Errorf("Expected the full target after the ramp, got %v", share)
+	}
+
+	// Changed settings restart it
+	changed := same
+	changed.Percent = 80
+	if share := state.share(&changed); share != 0 {
+		t.Errorf("Expected changed settings to restart the ramp, got %v", share)
+	}
+	if share := state.share(nil); share != 0 {
+		t.Errorf("Expected no shedding without a brownout, got %v", share)
+	}
+}
+
+func TestBrownout_PartialShare(t *testing.T) {
+	cfg := &config.Config{Runtime: config.NewRuntimeConfig("", config.RuntimeSettings{
+		Brownout: &config.BrownoutSettings{Percent: 30},
+	})}
+	passed := 0
+	handler := Brownout(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		passed++
+	}))
+
+	for i := 0; i < 2000; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/catalog", nil))
+	}
+	if passed < 1250 || passed > 1550 {
+		t.Errorf("Expected about 70%% of requests to pass, %d of 2000 did", passed)
+	}
+}
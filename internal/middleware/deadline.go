@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// RequestDeadline gives each request a deadline from its X-Request-Timeout,
+// capped at REQUEST_TIMEOUT_MAX, or REQUEST_TIMEOUT_DEFAULT when it sends
+// none. Handlers and the DP connector stop at the deadline, and pass the
+// time remaining on in X-Request-Timeout. A timeout that does not parse is
+// refused with 400. WebSocket and event streams only get a deadline they
+// ask for.
+func RequestDeadline(cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var timeout time.Duration
+			if value := r.Header.Get(services.RequestTimeoutHeader); value != "" {
+				var err error
+				if timeout, err = services.ParseRequestTimeout(value); err != nil {
+					WriteProblem(w, models.NewProblem("INVALID_REQUEST_TIMEOUT", fmt.Sprintf("Invalid %s: %v", services.RequestTimeoutHeader, err), http.StatusBadRequest))
+					return
+				}
+				if cfg.RequestTimeoutMax > 0 && timeout > cfg.RequestTimeoutMax {
+					timeout = cfg.RequestTimeoutMax
+				}
+			} else if !isStreamRequest(r) {
+				timeout = cfg.RequestTimeoutDefault
+			}
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// isStreamRequest reports whether a request opens a WebSocket or an event
+// stream, which last as long as the client wants
+func isStreamRequest(r *http.Request) bool {
+	return r.Header.Get("Upgrade") != "" || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
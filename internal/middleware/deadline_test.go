@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestRequestDeadline(t *testing.T) {
+	cfg := &config.Config{RequestTimeoutDefault: 5 * time.Second, RequestTimeoutMax: 10 * time.Second}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration // zero for no deadline
+	}{
+		{"hint", map[string]string{"X-Request-Timeout": "2000"}, 2 * time.Second},
+		{"hint over the cap", map[string]string{"X-Request-Timeout": "1m"}, 10 * time.Second},
+		{"default", nil, 5 * time.Second},
+		{"event stream", map[string]string{"Accept": "text/event-stream"}, 0},
+		{"websocket with a hint", map[string]string{"Upgrade": "websocket", "X-Request-Timeout": "1s"}, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var remaining time.Duration
+			var hasDeadline bool
+			handler := RequestDeadline(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if deadline, ok := r.Context().Deadline(); ok {
+					remaining, hasDeadline = time.Until(deadline), true
+				}
+			}))
+			req := httptest.NewRequest("GET", "/api/v1/verify", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if hasDeadline != (tt.want > 0) {
+				t.Fatalf("Expected deadline %v, got one: %v", tt.want, hasDeadline)
+			}
+			if hasDeadline && (remaining > tt.want || remaining < tt.want-time.Second) {
+				t.Errorf("Expected a deadline in %v, got %v", tt.want, remaining)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/v1/verify", nil)
+	req.Header.Set("X-Request-Timeout", "whenever")
+	RequestDeadline(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected an invalid timeout to be refused")
+	})).ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
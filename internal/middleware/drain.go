@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// drainRetryAfter tells clients when to retry; by then the load balancer has
+// moved them to another instance
+const drainRetryAfter = "5"
+
+// DrainGate tracks requests in flight for the drainer and refuses new ones
+// with 503 once a drain has started. The connection is closed so clients
+// reconnect through the load balancer.
+func DrainGate(drainer *services.Drainer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			done, ok := drainer.Track()
+			if !ok {
+				w.Header().Set("Retry-After", drainRetryAfter)
+				w.Header().Set("Connection", "close")
+				writeError(w, "SERVICE_DRAINING", "Server is draining for a deploy; retry on another instance", http.StatusServiceUnavailable)
+				return
+			}
+			defer done()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
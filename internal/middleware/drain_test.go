@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestDrainGate(t *testing.T) {
+	drainer := services.NewDrainer()
+	var inFlight int
+	handler := DrainGate(drainer)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight = drainer.Status().InFlight
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/verify", nil))
+	if w.Code != http.StatusOK || inFlight != 1 {
+		t.Fatalf("Expected the request to be tracked while serving, got %d with %d in flight", w.Code, inFlight)
+	}
+	if remaining := drainer.Status().InFlight; remaining != 0 {
+		t.Errorf("Expected the request to be released, got %d in flight", remaining)
+	}
+
+	drainer.Drain(context.Background())
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/verify", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 while drained, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" || w.Header().Get("Connection") != "close" {
+		t.Errorf("Expected Retry-After and Connection: close, got %v", w.Header())
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
 	"github.com/pavilion-trust/core-broker/internal/services"
 )
 
@@ -41,10 +42,26 @@ func Logging(next http.Handler) http.Handler {
 		// Create response writer wrapper to capture status code
 		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 		
-		next.ServeHTTP(wrapped, r)
+		// Let authentication attribute the request to a tenant
+		ctx, tenant := withTenantRef(r.Context())
+		
+		next.ServeHTTP(wrapped, r.WithContext(ctx))
 		
 		duration := time.Since(start)
 		
+		if tenant.rpID != "" {
+			log.Printf(
+				"%s %s %s %d %v tenant=%s",
+				r.Method,
+				r.RequestURI,
+				r.RemoteAddr,
+				wrapped.statusCode,
+				duration,
+				logTenantLabeler.Label(tenant.rpID),
+			)
+			return
+		}
+		
 		log.Printf(
 			"%s %s %s %d %v",
 			r.Method,
@@ -111,13 +128,14 @@ func SecurityHeaders(next http.Handler) http.Handler {
 
 // RateLimiting middleware implements rate limiting
 func RateLimiting(cfg *config.Config) func(http.Handler) http.Handler {
+	limiter := newRateLimiter()
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract client identifier (IP address or API key)
 			clientID := getClientID(r)
 			
-			// Check rate limit (simplified implementation)
-			if isRateLimited(clientID) {
+			// Limits are a runtime setting, so they are read on each request
+			if limiter.isRateLimited(clientID, cfg.RuntimeSettings().RateLimit) {
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusTooManyRequests)
 				json.NewEncoder(w).Encode(map[string]interface{}{
@@ -146,13 +164,6 @@ func getClientID(r *http.Request) string {
 	return r.RemoteAddr
 }
 
-// isRateLimited checks if a client has exceeded rate limits
-func isRateLimited(clientID string) bool {
-	// Simplified rate limiting - in production, this would use Redis
-	// For now, we'll allow all requests
-	return false
-}
-
 // Recovery middleware recovers from panics
 func Recovery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -208,6 +219,9 @@ func Authentication(cfg *config.Config) func(http.Handler) http.Handler {
 			
 			// Add user info to context
 			ctx := context.WithValue(r.Context(), "user", userInfo)
+			// Kept for DPs that exchange the RP's token for their own
+			ctx = services.WithSubjectToken(ctx, token)
+			setRequestTenant(ctx, userInfo.ResourceID)
 			
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -225,20 +239,14 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap exposes the underlying writer so streaming handlers can flush
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // writeError writes a structured error response
 func writeError(w http.ResponseWriter, code, message string, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	
-	response := map[string]interface{}{
-		"error": map[string]interface{}{
-			"code":    code,
-			"message": message,
-			"timestamp": time.Now().Format(time.RFC3339),
-		},
-	}
-	
-	json.NewEncoder(w).Encode(response)
+	WriteProblem(w, models.NewProblem(code, message, statusCode))
 }
 
 // RequireRole middleware checks if the user has the required role
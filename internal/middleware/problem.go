@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// WriteProblem writes a problem as an application/problem+json response.
+// A problem without a request ID takes the one already set on the response.
+func WriteProblem(w http.ResponseWriter, problem *models.Problem) {
+	if problem.RequestID == "" {
+		problem.RequestID = w.Header().Get("X-Request-ID")
+	}
+
+	w.Header().Set("Content-Type", models.ProblemContentType)
+	w.WriteHeader(problem.Status)
+
+	if data, err := problem.ToJSON(); err == nil {
+		w.Write(data)
+	} else {
+		// Fallback error response
+		w.Write([]byte(`{"type":"urn:pavilion:problem:internal-error","title":"Internal Server Error","status":500,"code":"INTERNAL_ERROR"}`))
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// maxRateLimitClients bounds the buckets kept before idle ones are dropped
+const maxRateLimitClients = 10000
+
+// tokenBucket holds a client's remaining requests
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter keeps a token bucket per client in memory; each gateway
+// instance limits the traffic it receives
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*tokenBucket), now: time.Now}
+}
+
+// isRateLimited takes a token from the client's bucket, reporting whether
+// none was left. A nil or zero limit allows every request.
+func (l *rateLimiter) isRateLimited(clientID string, limit *config.RateLimitSettings) bool {
+	if limit == nil || limit.RequestsPerMinute <= 0 {
+		return false
+	}
+	burst := float64(limit.Burst)
+	if burst <= 0 {
+		burst = float64(limit.RequestsPerMinute)
+	}
+	perSecond := float64(limit.RequestsPerMinute) / 60
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	bucket, exists := l.buckets[clientID]
+	if !exists {
+		if len(l.buckets) >= maxRateLimitClients {
+			l.pruneLocked(now, burst/perSecond)
+		}
+		bucket = &tokenBucket{tokens: burst, lastSeen: now}
+		l.buckets[clientID] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.lastSeen).Seconds() * perSecond
+	if bucket.tokens > burst {
+		bucket.tokens = burst
+	}
+	bucket.lastSeen = now
+	if bucket.tokens < 1 {
+		return true
+	}
+	bucket.tokens--
+	return false
+}
+
+// pruneLocked drops buckets idle long enough to have refilled
+func (l *rateLimiter) pruneLocked(now time.Time, refillSeconds float64) {
+	for clientID, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen).Seconds() >= refillSeconds {
+			delete(l.buckets, clientID)
+		}
+	}
+}
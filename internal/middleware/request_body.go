@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// defaultMaxBodyBytes and defaultMaxJSONDepth apply when the configuration
+// sets no limit
+const (
+	defaultMaxBodyBytes = 1 << 20
+	defaultMaxJSONDepth = 32
+)
+
+// requestBodyLimits are the body limits in effect for the gateway
+type requestBodyLimits struct {
+	maxBytes    int64
+	maxDepth    int
+	endpoints   map[string]int64
+	strictTypes map[string]func() interface{}
+}
+
+// newRequestBodyLimits reads the body limits from the configuration. Strict
+// decoding needs the request type an endpoint's body decodes into, from
+// bodyTypes; "*" in GATEWAY_STRICT_JSON makes every endpoint with a known
+// type strict. Invalid entries are reported and ignored.
+func newRequestBodyLimits(cfg *config.Config, bodyTypes map[string]func() interface{}) *requestBodyLimits {
+	limits := &requestBodyLimits{
+		maxBytes:    cfg.GatewayMaxBodyBytes,
+		maxDepth:    cfg.GatewayMaxJSONDepth,
+		endpoints:   make(map[string]int64),
+		strictTypes: make(map[string]func() interface{}),
+	}
+	if limits.maxBytes <= 0 {
+		limits.maxBytes = defaultMaxBodyBytes
+	}
+	if limits.maxDepth <= 0 {
+		limits.maxDepth = defaultMaxJSONDepth
+	}
+
+	for _, entry := range cfg.GatewayBodyLimits {
+		path, value, found := strings.Cut(entry, "=")
+		maxBytes, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if !found || err != nil || maxBytes <= 0 {
+			fmt.Printf("GATEWAY WARNING: ignoring body limit %q; expected path=bytes\n", entry)
+			continue
+		}
+		limits.endpoints[strings.TrimSpace(path)] = maxBytes
+	}
+
+	for _, path := range cfg.GatewayStrictJSON {
+		if path == "*" {
+			for typedPath, newBody := range bodyTypes {
+				limits.strictTypes[typedPath] = newBody
+			}
+			continue
+		}
+		newBody, exists := bodyTypes[path]
+		if !exists {
+			fmt.Printf("GATEWAY WARNING: no request type known for strict endpoint %s; unknown fields are allowed\n", path)
+			continue
+		}
+		limits.strictTypes[path] = newBody
+	}
+	return limits
+}
+
+// RequestBodyLimits protects the gateway's handlers from oversized and
+// malformed bodies. Bodies over the endpoint's limit are refused with 413;
+// JSON bodies that do not parse, nest deeper than GATEWAY_MAX_JSON_DEPTH or,
+// on strict endpoints, carry fields the endpoint's request type does not
+// have are refused with 400. Accepted bodies are passed on unchanged.
+func RequestBodyLimits(cfg *config.Config, bodyTypes map[string]func() interface{}) func(http.Handler) http.Handler {
+	limits := newRequestBodyLimits(cfg, bodyTypes)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			maxBytes := limits.maxBytes
+			if endpointMax, exists := limits.endpoints[r.URL.Path]; exists {
+				maxBytes = endpointMax
+			}
+			if r.ContentLength > maxBytes {
+				writeBodyTooLarge(w, maxBytes)
+				return
+			}
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					writeBodyTooLarge(w, maxBytes)
+					return
+				}
+				writeError(w, "INVALID_REQUEST", "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+
+			if len(body) > 0 && isJSONContent(r.Header.Get("Content-Type")) {
+				if code, err := checkJSONBody(body, limits.maxDepth, limits.strictTypes[r.URL.Path]); err != nil {
+					writeError(w, code, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+			r.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(body)), nil
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isJSONContent reports whether a content type is JSON, including the
+// +json structured syntax types
+func isJSONContent(contentType string) bool {
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// checkJSONBody checks a body is a single JSON value nested no deeper than
+// maxDepth and, when newBody is given, that it decodes into the endpoint's
+// request type without unknown fields. It returns the error code to report.
+func checkJSONBody(body []byte, maxDepth int, newBody func() interface{}) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	depth, tokens := 0, 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			if depth != 0 || tokens == 0 {
+				return "INVALID_JSON", fmt.Errorf("request body is not valid JSON: unexpected end of input")
+			}
+			break
+		}
+		if err != nil {
+			return "INVALID_JSON", fmt.Errorf("request body is not valid JSON: %v", err)
+		}
+		tokens++
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+			if depth > maxDepth {
+				return "JSON_TOO_DEEP", fmt.Errorf("request body nests deeper than %d levels", maxDepth)
+			}
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 && decoder.More() {
+			return "INVALID_JSON", fmt.Errorf("request body must hold a single JSON value")
+		}
+	}
+
+	if newBody == nil {
+		return "", nil
+	}
+	strict := json.NewDecoder(bytes.NewReader(body))
+	strict.DisallowUnknownFields()
+	if err := strict.Decode(newBody()); err != nil {
+		if strings.HasPrefix(err.Error(), "json: unknown field") {
+			return "UNKNOWN_FIELD", fmt.Errorf("request body has an %s", strings.TrimPrefix(err.Error(), "json: "))
+		}
+		return "INVALID_JSON", fmt.Errorf("request body does not match the endpoint's request: %v", err)
+	}
+	return "", nil
+}
+
+// writeBodyTooLarge refuses a body over the endpoint's limit
+func writeBodyTooLarge(w http.ResponseWriter, maxBytes int64) {
+	writeError(w, "REQUEST_TOO_LARGE", fmt.Sprintf("Request body exceeds the %d byte limit", maxBytes), http.StatusRequestEntityTooLarge)
+}
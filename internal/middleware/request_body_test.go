@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+type strictTestRequest struct {
+	UserID    string `json:"user_id"`
+	ClaimType string `json:"claim_type"`
+}
+
+func TestRequestBodyLimits(t *testing.T) {
+	cfg := &config.Config{
+		GatewayMaxBodyBytes: 64,
+		GatewayMaxJSONDepth: 3,
+		GatewayBodyLimits:   []string{"/api/v1/verify/batch=256", "invalid"},
+		GatewayStrictJSON:   []string{"/api/v1/verify", "/api/v1/unknown"},
+	}
+	bodyTypes := map[string]func() interface{}{
+		"/api/v1/verify":          func() interface{} { return &strictTestRequest{} },
+		"/api/v1/policy/simulate": func() interface{} { return &strictTestRequest{} },
+	}
+	var forwarded string
+	handler := RequestBodyLimits(cfg, bodyTypes)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		forwarded = string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	call := func(path, contentType, body string) (int, string) {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		var response struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		json.NewDecoder(w.Body).Decode(&response)
+		return w.Code, response.Error.Code
+	}
+
+	body := `{"user_id":"user_1","claim_type":"student_verification"}`
+	if code, _ := call("/api/v1/verify", "application/json", body); code != http.StatusOK || forwarded != body {
+		t.Errorf("Expected the body forwarded unchanged, got %d %q", code, forwarded)
+	}
+
+	large := `{"user_id":"` + strings.Repeat("a", 100) + `"}`
+	for _, tc := range []struct {
+		name, path, contentType, body string
+		status                        int
+		code                          string
+	}{
+		{"over the default limit", "/api/v1/verify", "application/json", large, http.StatusRequestEntityTooLarge, "REQUEST_TOO_LARGE"},
+		{"under an endpoint's limit", "/api/v1/verify/batch", "application/json", large, http.StatusOK, ""},
+		{"non-JSON over the limit", "/api/v1/exports", "text/plain", strings.Repeat("a", 65), http.StatusRequestEntityTooLarge, "REQUEST_TOO_LARGE"},
+		{"malformed", "/api/v1/exports", "application/json", `{"user_id":`, http.StatusBadRequest, "INVALID_JSON"},
+		{"trailing value", "/api/v1/exports", "application/json", `{} {}`, http.StatusBadRequest, "INVALID_JSON"},
+		{"too deep", "/api/v1/exports", "application/json; charset=utf-8", `{"a":[{"b":[]}]}`, http.StatusBadRequest, "JSON_TOO_DEEP"},
+		{"structured syntax JSON", "/api/v1/exports", "application/problem+json", `[[[[]]]]`, http.StatusBadRequest, "JSON_TOO_DEEP"},
+		{"unknown field on a strict endpoint", "/api/v1/verify", "application/json", `{"user_id":"u","role":"admin"}`, http.StatusBadRequest, "UNKNOWN_FIELD"},
+		{"wrong type on a strict endpoint", "/api/v1/verify", "application/json", `{"user_id":1}`, http.StatusBadRequest, "INVALID_JSON"},
+		{"unknown field elsewhere", "/api/v1/policy/simulate", "application/json", `{"user_id":"u","role":"admin"}`, http.StatusOK, ""},
+	} {
+		if status, code := call(tc.path, tc.contentType, tc.body); status != tc.status || code != tc.code {
+			t.Errorf("%s: expected %d %s, got %d %s", tc.name, tc.status, tc.code, status, code)
+		}
+	}
+}
+
+func TestRequestBodyLimits_Streamed(t *testing.T) {
+	handler := RequestBodyLimits(&config.Config{GatewayMaxBodyBytes: 8}, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Without a Content-Length the limit is enforced while reading
+	req := httptest.NewRequest("POST", "/api/v1/verify", io.NopCloser(strings.NewReader(strings.Repeat("a", 9))))
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/catalog", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected a request without a body to pass, got %d", w.Code)
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// selfTestExemptPaths stay reachable when the self-tests fail so operators and
+// orchestrators can see why the broker refuses traffic
+var selfTestExemptPaths = map[string]bool{
+	"/health":  true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// SelfTestGate refuses all other traffic with 503 when the startup
+// cryptographic self-tests did not pass
+func SelfTestGate(report *services.SelfTestReport) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if (report == nil || !report.Passed) && !selfTestExemptPaths[r.URL.Path] {
+				message := "Cryptographic self-tests failed"
+				if report != nil {
+					message += ": " + strings.Join(report.Failed(), ", ")
+				}
+				writeError(w, "SELF_TEST_FAILED", message, http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestSelfTestGate(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	failed := &services.SelfTestReport{
+		Passed:  false,
+		Results: []services.SelfTestResult{{Name: "jws_sign_verify", Passed: false}},
+	}
+
+	testCases := []struct {
+		name     string
+		report   *services.SelfTestReport
+		path     string
+		expected int
+	}{
+		{"passed", &services.SelfTestReport{Passed: true}, "/api/v1/verify", http.StatusOK},
+		{"failed blocks traffic", failed, "/api/v1/verify", http.StatusServiceUnavailable},
+		{"failed allows readiness", failed, "/readyz", http.StatusOK},
+		{"failed allows health", failed, "/health", http.StatusOK},
+		{"missing report blocks traffic", nil, "/api/v1/verify", http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			SelfTestGate(tc.report)(ok).ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+			if w.Code != tc.expected {
+				t.Errorf("Expected status %d, got %d", tc.expected, w.Code)
+			}
+		})
+	}
+}
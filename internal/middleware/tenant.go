@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+// TenantKey is the context key for the tenant attribution of a request
+type TenantKey struct{}
+
+// tenantRef is filled in once the caller is authenticated so that middleware
+// running before authentication can still attribute the request
+type tenantRef struct {
+	rpID string
+}
+
+// logTenantLabeler bounds tenant identifiers written to request logs
+var logTenantLabeler = services.NewTenantLabeler(0)
+
+// withTenantRef adds an empty tenant reference to the context
+func withTenantRef(ctx context.Context) (context.Context, *tenantRef) {
+	if ref, ok := ctx.Value(TenantKey{}).(*tenantRef); ok {
+		return ctx, ref
+	}
+	ref := &tenantRef{}
+	return context.WithValue(ctx, TenantKey{}, ref), ref
+}
+
+// setRequestTenant records the authenticated RP on the request's tenant reference
+func setRequestTenant(ctx context.Context, rpID string) {
+	if ref, ok := ctx.Value(TenantKey{}).(*tenantRef); ok {
+		ref.rpID = rpID
+	}
+}
+
+// RequestTenant returns the RP a request has been attributed to, if any
+func RequestTenant(ctx context.Context) string {
+	if ref, ok := ctx.Value(TenantKey{}).(*tenantRef); ok {
+		return ref.rpID
+	}
+	if userInfo, ok := ctx.Value("user").(*services.UserInfo); ok {
+		return userInfo.ResourceID
+	}
+	return ""
+}
+
+// TenantMetrics records request counts, errors and latency per tenant.
+// It must run after Authentication so the caller's RP is known.
+func TenantMetrics(metrics *services.MetricsService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+			next.ServeHTTP(wrapped, r)
+
+			latency := time.Since(start)
+			failed := wrapped.statusCode >= http.StatusInternalServerError
+
+			metrics.RecordRequest(latency)
+			if failed {
+				metrics.RecordError()
+			}
+			metrics.RecordTenantRequest(RequestTenant(r.Context()), latency, failed)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/services"
+)
+
+func TestTenantMetrics(t *testing.T) {
+	metrics := services.NewMetricsService(&config.Config{})
+
+	handler := Logging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Simulate Authentication populating the tenant further down the chain
+		ctx := context.WithValue(r.Context(), "user", &services.UserInfo{ResourceID: "rp_1"})
+		setRequestTenant(ctx, "rp_1")
+
+		TenantMetrics(metrics)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		})).ServeHTTP(w, r.WithContext(ctx))
+
+		if RequestTenant(r.Context()) != "rp_1" {
+			t.Error("Expected tenant to be visible to outer middleware")
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/verify", nil))
+
+	stats := metrics.GetTenantStats("rp_1")
+	if stats.Requests != 1 || stats.Errors != 1 {
+		t.Errorf("Expected one failed request for rp_1, got %+v", stats)
+	}
+	if other := metrics.GetTenantStats("rp_2"); other.Requests != 0 {
+		t.Error("Expected no requests attributed to other tenants")
+	}
+}
@@ -41,17 +41,9 @@ func ValidationMiddleware(next http.Handler) http.Handler {
 
 // writeValidationError writes a structured validation error response
 func writeValidationError(w http.ResponseWriter, code, message, requestID string) {
-	errorResponse := models.NewErrorResponse(code, message, requestID)
-	
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
-	
-	if data, err := errorResponse.ToJSON(); err == nil {
-		w.Write(data)
-	} else {
-		// Fallback error response
-		w.Write([]byte(`{"error":{"code":"INTERNAL_ERROR","message":"Failed to format error response"}}`))
-	}
+	problem := models.NewProblem(code, message, http.StatusBadRequest)
+	problem.RequestID = requestID
+	WriteProblem(w, problem)
 }
 
 // getValidatedRequest retrieves the validated request from context
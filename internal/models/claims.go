@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CanonicalClaimAttributes lists the attributes a canonical claim of each
+// claim type may carry. DP responses are normalized into these names, so
+// policies and response formatting never see a DP's own field names.
+var CanonicalClaimAttributes = map[string][]string{
+	"student_verification": {
+		"enrollment_status", // enrolled, graduated, withdrawn
+		"institution",
+		"program",
+		"level", // undergraduate, graduate, doctoral
+		"enrolled_from",
+		"enrolled_until",
+	},
+	"employee_verification": {
+		"employment_status", // employed, terminated, on_leave
+		"employer",
+		"job_title",
+		"employment_type", // full_time, part_time, contractor
+		"employed_from",
+		"employed_until",
+	},
+	"age_verification": {
+		"age_over", // the threshold verified, e.g. 18
+		"age_band",
+	},
+	"address_verification": {
+		"country",
+		"region",
+		"locality",
+		"postal_code",
+		"match_level", // full, partial
+	},
+}
+
+// Claim is the canonical form of a verified claim, whichever DP verified it
+type Claim struct {
+	Type       string                 `json:"type"`
+	Verified   bool                   `json:"verified"`
+	Confidence float64                `json:"confidence"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	// Source is the DP that verified the claim
+	Source     string `json:"source"`
+	VerifiedAt string `json:"verified_at,omitempty"`
+	ExpiresAt  string `json:"expires_at,omitempty"`
+}
+
+// Validate checks the claim's confidence and, for claim types with canonical
+// attributes, its attribute names. Custom claim types' attributes are not
+// checked.
+func (c *Claim) Validate() error {
+	if c.Type == "" {
+		return fmt.Errorf("claim type is required")
+	}
+	if c.Confidence < 0 || c.Confidence > 1 {
+		return fmt.Errorf("claim confidence %v is outside 0..1", c.Confidence)
+	}
+	allowed, known := CanonicalClaimAttributes[c.Type]
+	if !known {
+		return nil
+	}
+
+	var unknown []string
+	for name := range c.Attributes {
+		if !containsAttribute(allowed, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%s claims have no attributes %s", c.Type, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Attribute returns a claim attribute
+func (c *Claim) Attribute(name string) (interface{}, bool) {
+	value, exists := c.Attributes[name]
+	return value, exists
+}
+
+func containsAttribute(attributes []string, name string) bool {
+	for _, attribute := range attributes {
+		if attribute == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClaim_Validate(t *testing.T) {
+	claim := Claim{
+		Type:       "student_verification",
+		Verified:   true,
+		Confidence: 0.9,
+		Attributes: map[string]interface{}{"institution": "MIT", "enrollment_status": "enrolled"},
+		Source:     "sis",
+	}
+	if err := claim.Validate(); err != nil {
+		t.Errorf("Expected a canonical claim to be valid, got %v", err)
+	}
+	if value, exists := claim.Attribute("institution"); !exists || value != "MIT" {
+		t.Errorf("Expected the institution attribute, got %v", value)
+	}
+
+	claim.Attributes["stu_inst"] = "MIT"
+	if err := claim.Validate(); err == nil || !strings.Contains(err.Error(), "stu_inst") {
+		t.Errorf("Expected a DP-specific attribute to be rejected, got %v", err)
+	}
+
+	custom := Claim{Type: "over_18", Confidence: 1, Attributes: map[string]interface{}{"anything": true}}
+	if err := custom.Validate(); err != nil {
+		t.Errorf("Expected custom claim types' attributes to be unchecked, got %v", err)
+	}
+	for _, invalid := range []Claim{{Confidence: 0.5}, {Type: "age_verification", Confidence: 1.5}} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+}
@@ -79,6 +79,10 @@ type VerificationResponse struct {
 	Metadata        map[string]interface{} `json:"metadata,omitempty"`
 	ValidationErrors []string              `json:"validation_errors,omitempty"`
 	Error           *Error  `json:"error,omitempty"`
+	// Stale results were served from the cache past their expiry because
+	// the DP was unavailable; Timestamp is still when the DP verified them
+	Stale           bool    `json:"stale,omitempty"`
+	Degraded        bool    `json:"degraded,omitempty"`
 }
 
 // NewVerificationResponse creates a new verification response
@@ -127,6 +131,104 @@ type DPResponse struct {
 	DPID          string  `json:"dp_id"`
 	Timestamp     string  `json:"timestamp"`
 	Error         string  `json:"error,omitempty"`
+	// NotModified is set when the DP confirmed a cached result with 304;
+	// ETag and LastModified validate the result on its next refresh
+	NotModified  bool   `json:"not_modified,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// PartialResults is set when the DP could only determine some identifiers
+	PartialResults *PartialResults `json:"partial_results,omitempty"`
+	// Claim is the DP's result normalized into the canonical claim model
+	Claim *Claim `json:"claim,omitempty"`
+	// Consensus is set when several DPs were required to agree on the result
+	Consensus *ConsensusResult `json:"consensus,omitempty"`
+	// Scoring records how the confidence score was calibrated and combined
+	Scoring *ConfidenceScore `json:"scoring,omitempty"`
+}
+
+// ConfidenceScore records how a result's confidence was put on the broker's
+// 0-1 scale and, for results from several DPs, how it was combined
+type ConfidenceScore struct {
+	Score       float64     `json:"score"`
+	Raw         interface{} `json:"raw,omitempty"`
+	Scale       string      `json:"scale"`
+	Calibrated  bool        `json:"calibrated"`
+	Aggregation string      `json:"aggregation"`
+}
+
+// ConsensusResult records how the DPs queried for a consensus verification
+// answered and how their results were combined
+type ConsensusResult struct {
+	Required   int                       `json:"required"`
+	Queried    int                       `json:"queried"`
+	Agreed     int                       `json:"agreed"`
+	Verified   bool                      `json:"verified"`
+	Method     string                    `json:"method"`
+	Confidence float64                   `json:"confidence"`
+	Providers  []ConsensusProviderResult `json:"providers"`
+}
+
+// ConsensusProviderResult is one DP's answer in a consensus verification
+type ConsensusProviderResult struct {
+	DPID       string   `json:"dp_id"`
+	Verified   bool     `json:"verified"`
+	Confidence float64  `json:"confidence"`
+	Evidence   []string `json:"evidence,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Identifier outcomes reported in partial results
+const (
+	IdentifierMatched    = "matched"
+	IdentifierMismatched = "mismatched"
+	IdentifierUnknown    = "unknown"
+)
+
+// PartialResults is a DP's per-identifier outcome when it matched some of a
+// request's identifiers and could not determine the others, keyed by
+// identifier name (never by value)
+type PartialResults struct {
+	Identifiers map[string]string `json:"identifiers"`
+	Reason      string            `json:"reason,omitempty"`
+}
+
+// Validate checks that every identifier has a known outcome
+func (p *PartialResults) Validate() error {
+	if len(p.Identifiers) == 0 {
+		return fmt.Errorf("partial results must list at least one identifier")
+	}
+	for name, outcome := range p.Identifiers {
+		switch outcome {
+		case IdentifierMatched, IdentifierMismatched, IdentifierUnknown:
+		default:
+			return fmt.Errorf("identifier %s has unknown outcome %q", name, outcome)
+		}
+	}
+	return nil
+}
+
+// Counts returns the number of matched, mismatched and unknown identifiers
+func (p *PartialResults) Counts() (matched, mismatched, unknown int) {
+	for _, outcome := range p.Identifiers {
+		switch outcome {
+		case IdentifierMatched:
+			matched++
+		case IdentifierMismatched:
+			mismatched++
+		default:
+			unknown++
+		}
+	}
+	return matched, mismatched, unknown
+}
+
+// Coverage returns the share of identifiers the DP could determine
+func (p *PartialResults) Coverage() float64 {
+	if len(p.Identifiers) == 0 {
+		return 0
+	}
+	_, _, unknown := p.Counts()
+	return float64(len(p.Identifiers)-unknown) / float64(len(p.Identifiers))
 }
 
 // PolicyDecision represents a policy enforcement decision
@@ -149,6 +251,8 @@ type AuditEntry struct {
 	PolicyDecision string                `json:"policy_decision"`
 	Status        string                 `json:"status"`
 	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	// Problem is the error reported to the RP when the verification failed
+	Problem       *Problem               `json:"problem,omitempty"`
 }
 
 // CacheEntry represents a cached verification result
@@ -234,4 +234,30 @@ func TestVerificationResponse_Validate(t *testing.T) {
 	if err := response.Validate(); err == nil {
 		t.Error("Invalid confidence score should have validation error")
 	}
-} 
\ No newline at end of file
+} 
+func TestPartialResults(t *testing.T) {
+	partial := &PartialResults{Identifiers: map[string]string{
+		"email":         IdentifierMatched,
+		"student_id":    IdentifierMismatched,
+		"date_of_birth": IdentifierUnknown,
+		"phone":         IdentifierUnknown,
+	}}
+	if err := partial.Validate(); err != nil {
+		t.Errorf("Expected valid partial results, got %v", err)
+	}
+	if matched, mismatched, unknown := partial.Counts(); matched != 1 || mismatched != 1 || unknown != 2 {
+		t.Errorf("Unexpected counts %d, %d, %d", matched, mismatched, unknown)
+	}
+	if coverage := partial.Coverage(); coverage != 0.5 {
+		t.Errorf("Expected coverage 0.5, got %v", coverage)
+	}
+
+	for _, invalid := range []*PartialResults{
+		{},
+		{Identifiers: map[string]string{"email": "maybe"}},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+}
@@ -86,7 +86,19 @@ type Credential struct {
 	Claims         map[string]interface{} `json:"claims" validate:"required"`
 	Proof          CredentialProof        `json:"proof" validate:"required"`
 	Status         string                 `json:"status" validate:"required,oneof=valid revoked expired"`
-	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	// CredentialStatus points at the status list entry the issuer publishes
+	// revocation through
+	CredentialStatus *CredentialStatus      `json:"credential_status,omitempty"`
+	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// CredentialStatus represents a StatusList2021 entry
+type CredentialStatus struct {
+	ID                   string `json:"id,omitempty"`
+	Type                 string `json:"type"`
+	StatusPurpose        string `json:"statusPurpose"`
+	StatusListIndex      string `json:"statusListIndex"`
+	StatusListCredential string `json:"statusListCredential"`
 }
 
 // CredentialProof represents a credential proof
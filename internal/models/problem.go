@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeBase prefixes a problem's code, in kebab case, to form its type
+const ProblemTypeBase = "urn:pavilion:problem:"
+
+// Machine-readable problem codes shared by the broker and the gateway.
+// Handlers report other codes too; these are the ones clients are expected
+// to act on.
+const (
+	ProblemPolicyDenied      = "POLICY_DENIED"
+	ProblemDPTimeout         = "DP_TIMEOUT"
+	ProblemCircuitOpen       = "CIRCUIT_OPEN"
+	ProblemBrokerUnavailable = "BROKER_UNAVAILABLE"
+	ProblemBrokerTimeout     = "BROKER_TIMEOUT"
+	ProblemServiceBrownout   = "SERVICE_BROWNOUT"
+)
+
+// problemTitles are the titles of the shared codes; other codes are titled
+// with their HTTP status text
+var problemTitles = map[string]string{
+	ProblemPolicyDenied:      "Denied by policy",
+	ProblemDPTimeout:         "Data provider timed out",
+	ProblemCircuitOpen:       "Data provider unavailable",
+	ProblemBrokerUnavailable: "Core broker unavailable",
+	ProblemBrokerTimeout:     "Core broker timed out",
+	ProblemServiceBrownout:   "Service under maintenance",
+}
+
+// Problem is an error reported as RFC 7807 problem details. Code is the
+// machine-readable code clients branch on; Type is derived from it.
+type Problem struct {
+	Type       string                 `json:"type"`
+	Title      string                 `json:"title"`
+	Status     int                    `json:"status"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	Code       string                 `json:"code"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	Timestamp  string                 `json:"timestamp"`
+	RetryAfter int                    `json:"retry_after,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// NewProblem creates a problem for a code, its detail and HTTP status
+func NewProblem(code, detail string, status int) *Problem {
+	title, exists := problemTitles[code]
+	if !exists {
+		title = http.StatusText(status)
+	}
+	return &Problem{
+		Type:      ProblemType(code),
+		Title:     title,
+		Status:    status,
+		Detail:    detail,
+		Code:      code,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
+
+// ProblemType returns the type URI of a problem code
+func ProblemType(code string) string {
+	return ProblemTypeBase + strings.ReplaceAll(strings.ToLower(code), "_", "-")
+}
+
+// LegacyError returns the problem as the error object of the earlier
+// error body
+func (p *Problem) LegacyError() *Error {
+	return &Error{
+		Code:      p.Code,
+		Message:   p.Detail,
+		Timestamp: p.Timestamp,
+		RequestID: p.RequestID,
+		Details:   p.Details,
+	}
+}
+
+// ToJSON encodes the problem as a response body. The body also carries the
+// problem in the earlier {"error": {...}} shape, for clients written
+// against it.
+func (p *Problem) ToJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		*Problem
+		Error *Error `json:"error"`
+	}{p, p.LegacyError()})
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestNewProblem(t *testing.T) {
+	problem := NewProblem(ProblemCircuitOpen, "Data provider is unavailable", http.StatusServiceUnavailable)
+	if problem.Type != "urn:pavilion:problem:circuit-open" || problem.Title != "Data provider unavailable" {
+		t.Errorf("Unexpected problem: %+v", problem)
+	}
+
+	// Codes outside the shared set are titled by their status
+	other := NewProblem("NOT_FOUND", "Policy not found", http.StatusNotFound)
+	if other.Title != "Not Found" || other.Type != "urn:pavilion:problem:not-found" {
+		t.Errorf("Unexpected problem: %+v", other)
+	}
+}
+
+func TestProblem_ToJSON(t *testing.T) {
+	problem := NewProblem(ProblemPolicyDenied, "Claim type not allowed", http.StatusForbidden)
+	problem.RequestID = "req-1"
+	data, err := problem.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+
+	var body struct {
+		Problem
+		Error *Error `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if body.Code != ProblemPolicyDenied || body.Status != http.StatusForbidden || body.RequestID != "req-1" {
+		t.Errorf("Unexpected problem members: %+v", body.Problem)
+	}
+	if body.Error == nil || body.Error.Code != ProblemPolicyDenied || body.Error.Message != "Claim type not allowed" {
+		t.Errorf("Expected the legacy error member, got %+v", body.Error)
+	}
+
+	// Embedded in audit entries, the problem has no legacy member
+	plain, _ := json.Marshal(problem)
+	var members map[string]interface{}
+	json.Unmarshal(plain, &members)
+	if _, exists := members["error"]; exists {
+		t.Errorf("Expected no legacy member, got %v", members)
+	}
+}
@@ -14,17 +14,28 @@ import (
 	"github.com/pavilion-trust/core-broker/internal/config"
 	"github.com/pavilion-trust/core-broker/internal/handlers"
 	"github.com/pavilion-trust/core-broker/internal/middleware"
+	"github.com/pavilion-trust/core-broker/internal/models"
 	"github.com/pavilion-trust/core-broker/internal/services"
 )
 
 // Server represents the HTTP server
 type Server struct {
 	*http.Server
-	config *config.Config
+	config         *config.Config
+	selfTestReport *services.SelfTestReport
+	timeSync       *services.TimeSyncChecker
+	admin          *http.Server
+	drainer        *services.Drainer
+	reporting      *services.ReportingService
+	storage        services.Storage
+	outbox         *services.OutboxDispatcher
 }
 
 // New creates a new HTTP server with all routes and middleware
 func New(cfg *config.Config) *Server {
+	// Verify cryptographic primitives before accepting traffic
+	selfTestReport := services.RunCryptoSelfTests(cfg)
+
 	// Create router
 	router := mux.NewRouter()
 
@@ -33,10 +44,64 @@ func New(cfg *config.Config) *Server {
 	router.Use(middleware.Logging)
 	router.Use(middleware.RequestID)
 	router.Use(middleware.Recovery)
+	router.Use(middleware.RequestDeadline(cfg))
+	router.Use(middleware.SelfTestGate(selfTestReport))
 
 	// Create handlers
 	verificationHandler := handlers.NewVerificationHandler(cfg)
 	healthHandler := handlers.NewHealthHandler(cfg)
+	healthHandler.SetSelfTestReport(selfTestReport)
+	timeSync := services.NewTimeSyncChecker(cfg)
+	healthHandler.SetTimeSyncChecker(timeSync)
+
+	// Audit entries, pull jobs and consent revocations are kept in storage so
+	// they survive restarts
+	storage, err := services.OpenStorage(cfg)
+	if err != nil {
+		fmt.Printf("STORAGE WARNING: %v; using in-memory storage\n", err)
+		storage = services.NewMemoryStorage()
+	}
+	verificationHandler.SetStorage(storage)
+	auditService := verificationHandler.AuditService()
+
+	// Audit entries and webhook notifications are committed to the storage
+	// outbox with the state they describe, then delivered in the background
+	outbox := services.NewOutboxDispatcher(cfg, storage)
+	auditService.SetOutbox(outbox)
+
+	// Revoking consent invalidates the subject's cached verification results
+	consentService := services.NewConsentService()
+	if err := consentService.SetStorage(context.Background(), storage); err != nil {
+		fmt.Printf("STORAGE WARNING: %v\n", err)
+	}
+	verificationHandler.CacheService().SetConsentService(consentService)
+	consentHandler := handlers.NewConsentHandler(cfg, consentService)
+
+	// Stored verifications are rolled up into the reporting tables
+	reporting := services.NewReportingService(cfg)
+	verificationHandler.RecordStore().OnAppend(reporting.Observe)
+
+	// Reports and exports are anonymized before they leave the broker; an
+	// unloadable anonymization file blocks them
+	anonymization := services.NewAnonymizationService()
+	if cfg.AnonymizationFile != "" {
+		if err := anonymization.LoadFile(cfg.AnonymizationFile); err != nil {
+			fmt.Printf("ANONYMIZATION WARNING: %v\n", err)
+		}
+	}
+	reporting.SetAnonymization(anonymization)
+
+	// A drain refuses new verifications and waits for running ones, batches
+	// and pull jobs, then flushes the outbox, audit log and reporting rollups
+	drainer := services.NewDrainer()
+	drainer.AddWork("batches", verificationHandler.BatchTracker().RunningBatches)
+	drainer.AddWork("pull_jobs", verificationHandler.PullJobService().ActiveJobs)
+	drainer.AddWork("outbox", outbox.InFlight)
+	drainer.AddFlusher("outbox", outbox.Flush)
+	drainer.AddFlusher("audit", auditService.Flush)
+	drainer.AddFlusher("reporting", reporting.Flush)
+	healthHandler.SetDrainer(drainer)
+	drainGate := middleware.DrainGate(drainer)
 
 	// Create credential signing service
 	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
@@ -48,25 +113,115 @@ func New(cfg *config.Config) *Server {
 		panic(fmt.Sprintf("Failed to generate ECDSA key: %v", err))
 	}
 	signingService := services.NewCredentialSigningService(rsaKey, ecdsaKey, "key-1", cfg.Issuer)
+	signingService.SetClockSkewTolerance(services.ClockSkewTolerance(cfg))
 	credentialHandler := handlers.NewCredentialHandler(cfg, signingService)
 
+	// Credentials with a status entry are checked against the issuer's status list
+	statusLists := services.NewStatusListService(cfg)
+	credentialHandler.SetStatusListService(statusLists)
+
+	// Registered post-processing hooks run after issuance; drains wait for
+	// runs still being retried
+	credentialHooks := services.NewCredentialHookService()
+	credentialHandler.SetCredentialHooks(credentialHooks)
+	drainer.AddWork("credential_hooks", credentialHooks.Pending)
+
 	// Create policy storage and handler
 	policyStorage, err := services.NewPolicyStorage(cfg)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create policy storage: %v", err))
 	}
 	policyHandler := handlers.NewPolicyHandler(cfg, policyStorage)
+	policyHandler.SetStatusListService(statusLists)
+	// Issuer and holder DIDs of evaluated and presented credentials
+	didResolver := services.NewDIDResolver(cfg)
+	policyHandler.SetDIDResolver(didResolver)
+
+	// Create metrics service and handler
+	metricsService := services.NewMetricsService(cfg)
+	metricsHandler := handlers.NewMetricsHandler(cfg, metricsService)
+	metricsService.AddCollector(verificationHandler.DPService().BulkheadMetrics)
+	metricsService.AddCollector(verificationHandler.DPService().ConcurrencyLimitMetrics)
+	metricsService.AddCollector(verificationHandler.CustomMetrics().Metrics)
+
+	// Create export service and handler
+	exportService := services.NewExportService(cfg, verificationHandler.RecordStore(), auditService)
+	exportService.SetAnonymization(anonymization)
+	exportHandler := handlers.NewExportHandler(cfg, exportService)
+
+	// RPs are notified when their batches complete; drains wait for webhook
+	// deliveries still being retried
+	notificationService := services.NewNotificationService(cfg, auditService)
+	notificationService.SetOutbox(outbox)
+	verificationHandler.BatchTracker().OnComplete(notificationService.NotifyBatchCompleted)
+	drainer.AddWork("notifications", notificationService.PendingDeliveries)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+
+	// Create claim catalog handler from the schema registry and the DP registry
+	schemaRegistry := verificationHandler.SchemaRegistry()
+	catalogService := services.NewClaimCatalogService(schemaRegistry, verificationHandler.DPService(), verificationHandler.AuthorizationService())
+	catalogHandler := handlers.NewCatalogHandler(cfg, catalogService)
+	catalogHandler.SetDeprecationTracker(verificationHandler.ClaimDeprecations())
+
+	// Create ZKP handler; stored proofs are checked for revocation on verification
+	proofStore := services.NewZKPProofStore(cfg.ZKPProofTTL, cfg.ZKPProofMaxTTL)
+	zkpService := services.NewZKPService(services.ZKPConfigFromConfig(cfg))
+	zkpService.SetProofStore(proofStore)
+	zkpHandler := handlers.NewZKPHandler(cfg, zkpService, proofStore)
+
+	// Create GraphQL handler answering RP queries across verifications, audit references, claim types and circuits
+	graphQLHandler := handlers.NewGraphQLHandler(cfg, auditService, catalogService, zkpService)
+
+	// Create OpenID4VP presentation handler; wallets present SD-JWTs for the RP's claim type
+	openID4VPService := services.NewOpenID4VPService(cfg, schemaRegistry, verificationHandler.AuthorizationService())
+	openID4VPService.SetDIDResolver(didResolver)
+	openID4VPService.SetStatusListService(statusLists)
+	presentationHandler := handlers.NewPresentationHandler(cfg, openID4VPService)
+
+	// Other brokers discover this one's capabilities from its signed manifest
+	manifestService := services.NewBrokerManifestService(cfg, verificationHandler.AttestationService())
+	manifestService.SetClaimSchemas(schemaRegistry)
+	manifestService.SetZKPCircuits(zkpService.Circuits())
+	manifestService.SetCredentialSigning(signingService)
+	manifestHandler := handlers.NewManifestHandler(manifestService)
+
+	// Create sandbox console handler backed by the same schema registry
+	consoleHandler := handlers.NewConsoleHandler(cfg, services.NewConsoleService(schemaRegistry))
+
+	// Export downloads are authorized by the signed URL rather than a bearer token
+	router.HandleFunc("/api/v1/exports/{id}/download", exportHandler.HandleDownloadExport).Methods("GET")
+
+	// The broker manifest is public, for federation between brokers
+	router.HandleFunc(services.BrokerManifestPath, manifestHandler.HandleGetManifest).Methods("GET")
+
+	// Wallets post OpenID4VP responses directly; the request's state authorizes them
+	router.HandleFunc("/openid4vp/response", presentationHandler.HandleDirectPost).Methods("POST")
 
 	// API routes
 	apiRouter := router.PathPrefix("/api/v1").Subrouter()
 	apiRouter.Use(middleware.Authentication(cfg))
+	apiRouter.Use(middleware.TenantMetrics(metricsService))
+
+	// Batch verification endpoints (requires 'rp' role); items are validated individually
+	batchRouter := apiRouter.PathPrefix("/verify/batch").Subrouter()
+	batchRouter.Use(middleware.RequireRole("rp"))
+	batchRouter.Handle("", drainGate(http.HandlerFunc(verificationHandler.HandleBatchVerification))).Methods("POST")
+	batchRouter.HandleFunc("/{id}", verificationHandler.HandleBatchStatus).Methods("GET")
+	batchRouter.HandleFunc("/{id}/events", verificationHandler.HandleBatchEvents).Methods("GET")
 
 	// Verification endpoint (requires 'rp' role)
 	verificationRouter := apiRouter.PathPrefix("/verify").Subrouter()
 	verificationRouter.Use(middleware.RequireRole("rp"))
+	verificationRouter.Use(drainGate)
 	verificationRouter.Use(middleware.ValidationMiddleware)
 	verificationRouter.HandleFunc("", verificationHandler.HandleVerification).Methods("POST")
 
+	// Policy simulation for RP integration design (requires 'rp' role); no DP call or audit entry is made
+	simulationRouter := apiRouter.PathPrefix("/policy/simulate").Subrouter()
+	simulationRouter.Use(middleware.RequireRole("rp"))
+	simulationRouter.Use(middleware.ValidationMiddleware)
+	simulationRouter.HandleFunc("", verificationHandler.HandlePolicySimulation).Methods("POST")
+
 	// Policy endpoints (requires 'admin' role)
 	policyRouter := apiRouter.PathPrefix("/policies").Subrouter()
 	policyRouter.Use(middleware.RequireRole("admin"))
@@ -106,9 +261,102 @@ func New(cfg *config.Config) *Server {
 	credentialRouter.HandleFunc("/{id}/status", credentialHandler.HandleGetCredentialStatus).Methods("GET")
 	credentialRouter.HandleFunc("/{id}/verify", credentialHandler.HandleVerifyCredential).Methods("POST")
 
+	// Export endpoints (requires 'rp' role)
+	exportRouter := apiRouter.PathPrefix("/exports").Subrouter()
+	exportRouter.Use(middleware.RequireRole("rp"))
+	exportRouter.HandleFunc("", exportHandler.HandleCreateExport).Methods("POST")
+	exportRouter.HandleFunc("/{id}", exportHandler.HandleGetExport).Methods("GET")
+
+	// Notification webhooks and WebSocket streams (requires 'rp' role)
+	notificationRouter := apiRouter.PathPrefix("/notifications").Subrouter()
+	notificationRouter.Use(middleware.RequireRole("rp"))
+	notificationRouter.HandleFunc("/webhooks", notificationHandler.HandleRegisterWebhook).Methods("POST")
+	notificationRouter.HandleFunc("/webhooks", notificationHandler.HandleListWebhooks).Methods("GET")
+	notificationRouter.HandleFunc("/webhooks/{id}", notificationHandler.HandleDeleteWebhook).Methods("DELETE")
+	notificationRouter.HandleFunc("/deliveries", notificationHandler.HandleListDeliveries).Methods("GET")
+	notificationRouter.HandleFunc("/ws", notificationHandler.HandleStream).Methods("GET")
+
+	// Claim catalog for RP developer portals, scoped to the caller's tenant
+	apiRouter.Handle("/catalog", middleware.RequireRole("rp")(http.HandlerFunc(catalogHandler.HandleGetCatalog))).Methods("GET")
+	apiRouter.Handle("/catalog/deprecations", middleware.RequireRole("rp")(http.HandlerFunc(catalogHandler.HandleGetDeprecations))).Methods("GET")
+
+	// Consent revocations relayed by RPs for their own users
+	apiRouter.Handle("/consents/revoke", middleware.RequireRole("rp")(http.HandlerFunc(consentHandler.HandleRevokeConsent))).Methods("POST")
+
+	// OpenID4VP presentation requests (requires 'rp' role)
+	presentationRouter := apiRouter.PathPrefix("/presentations").Subrouter()
+	presentationRouter.Use(middleware.RequireRole("rp"))
+	presentationRouter.Handle("", drainGate(http.HandlerFunc(presentationHandler.HandleCreatePresentationRequest))).Methods("POST")
+	presentationRouter.HandleFunc("/{id}", presentationHandler.HandleGetPresentationRequest).Methods("GET")
+
+	// Stored zero-knowledge proofs (requires 'rp' role)
+	zkpRouter := apiRouter.PathPrefix("/zkp/proofs").Subrouter()
+	zkpRouter.Use(middleware.RequireRole("rp"))
+	zkpRouter.HandleFunc("", zkpHandler.HandleCreateProof).Methods("POST")
+	zkpRouter.HandleFunc("/{id}", zkpHandler.HandleGetProof).Methods("GET")
+	zkpRouter.HandleFunc("/{id}", zkpHandler.HandleRevokeProof).Methods("DELETE")
+
+	// ZKP circuits: RPs list them, admins register and remove custom circuits
+	apiRouter.Handle("/zkp/circuits", middleware.RequireRole("rp")(http.HandlerFunc(zkpHandler.HandleListCircuits))).Methods("GET")
+	apiRouter.Handle("/zkp/circuits", middleware.RequireRole("admin")(http.HandlerFunc(zkpHandler.HandleRegisterCircuit))).Methods("POST")
+	apiRouter.Handle("/zkp/circuits/{name}", middleware.RequireRole("admin")(http.HandlerFunc(zkpHandler.HandleUnregisterCircuit))).Methods("DELETE")
+
+	// GraphQL queries for RPs, with the same authentication and tenant scoping as REST
+	apiRouter.Handle("/graphql", middleware.RequireRole("rp")(http.HandlerFunc(graphQLHandler.HandleQuery))).Methods("GET", "POST")
+
+	// Developer console data endpoints (requires 'rp' role); only served in sandbox deployments
+	if cfg.SandboxEnabled {
+		consoleRouter := apiRouter.PathPrefix("/sandbox/console").Subrouter()
+		consoleRouter.Use(middleware.RequireRole("rp"))
+		consoleRouter.HandleFunc("/examples", consoleHandler.HandleListExamples).Methods("GET")
+		consoleRouter.HandleFunc("/examples/{claim_type}", consoleHandler.HandleGetExample).Methods("GET")
+		consoleRouter.HandleFunc("/validate", consoleHandler.HandleValidateDraft).Methods("POST")
+	}
+
+	// Encrypted audit metadata is only decrypted or rewrapped by admins
+	auditHandler := handlers.NewAuditHandler(cfg, auditService)
+	auditRouter := apiRouter.PathPrefix("/audit").Subrouter()
+	auditRouter.Use(middleware.RequireRole("admin"))
+	auditRouter.HandleFunc("/decrypt", auditHandler.HandleDecryptEntries).Methods("POST")
+	auditRouter.HandleFunc("/rewrap", auditHandler.HandleRewrapEntries).Methods("POST")
+
+	// Each runtime configuration reload is recorded with what it changed
+	auditRuntimeChanges(cfg, auditService)
+
+	// Tenant metric views: RPs see only their own usage, admins see the top tenants
+	apiRouter.Handle("/metrics/tenant", middleware.RequireRole("rp")(http.HandlerFunc(metricsHandler.HandleTenantMetrics))).Methods("GET")
+	apiRouter.Handle("/metrics/tenants", middleware.RequireRole("admin")(http.HandlerFunc(metricsHandler.HandleTenantOverview))).Methods("GET")
+
 	// Health check endpoint (no authentication required)
 	router.HandleFunc("/health", healthHandler.HandleHealth).Methods("GET")
 
+	// Readiness endpoint reporting the cryptographic self-test results and drains
+	router.HandleFunc("/readyz", healthHandler.HandleReadiness).Methods("GET")
+
+	// Prometheus metrics endpoint (no authentication required; tenant labels are bounded)
+	router.HandleFunc("/metrics", metricsHandler.HandlePrometheus).Methods("GET")
+
+	// Operational controls are served on their own port, away from RP traffic
+	var adminServer *http.Server
+	if cfg.AdminPort != "" {
+		adminHandler := handlers.NewAdminHandler(cfg, verificationHandler.DPService(), verificationHandler.CacheService(), auditService)
+		adminHandler.SetDrainer(drainer)
+		adminHandler.SetReportingService(reporting)
+		adminHandler.SetResponseFormatter(verificationHandler.ResponseFormatter())
+		adminHandler.SetCustomMetrics(verificationHandler.CustomMetrics())
+		adminHandler.SetSupportBundles(verificationHandler.SupportBundles())
+		// DP registry and claim schema changes are kept with their diffs, and
+		// RPs that verified the affected claim types are notified
+		registryChanges := services.NewRegistryChangeLog()
+		registryChanges.SetNotifications(notificationService, verificationHandler.RecordStore().RPsForClaimTypes)
+		adminHandler.SetRegistryChanges(registryChanges)
+		adminHandler.SetClaimSchemas(schemaRegistry)
+		adminHandler.SetCredentialHooks(credentialHooks)
+		adminHandler.SetFederation(verificationHandler.Federation())
+		adminHandler.SetOrchestrator(verificationHandler.Orchestrator())
+		adminServer = newAdminServer(cfg, adminHandler)
+	}
+
 	// Create HTTP server
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
@@ -119,14 +367,166 @@ func New(cfg *config.Config) *Server {
 	}
 
 	return &Server{
-		Server: srv,
-		config: cfg,
+		Server:         srv,
+		config:         cfg,
+		selfTestReport: selfTestReport,
+		timeSync:       timeSync,
+		admin:          adminServer,
+		drainer:        drainer,
+		reporting:      reporting,
+		storage:        storage,
+		outbox:         outbox,
 	}
 }
 
+// newAdminServer creates the admin listener; every route requires an admin
+func newAdminServer(cfg *config.Config, adminHandler *handlers.AdminHandler) *http.Server {
+	router := mux.NewRouter()
+	router.Use(middleware.Logging)
+	router.Use(middleware.RequestID)
+	router.Use(middleware.Recovery)
+	router.Use(middleware.AdminAuthentication(cfg))
+
+	adminRouter := router.PathPrefix("/admin/v1").Subrouter()
+	adminRouter.HandleFunc("/breakers", adminHandler.HandleGetBreakers).Methods("GET")
+	adminRouter.HandleFunc("/breakers/reset", adminHandler.HandleResetBreakers).Methods("POST")
+	adminRouter.HandleFunc("/breakers/{dp_id}/reset", adminHandler.HandleResetBreaker).Methods("POST")
+	adminRouter.HandleFunc("/connections/drain", adminHandler.HandleDrainConnections).Methods("POST")
+	adminRouter.HandleFunc("/cache/flush", adminHandler.HandleFlushCache).Methods("POST")
+	adminRouter.HandleFunc("/dp-registry/reload", adminHandler.HandleReloadRegistry).Methods("POST")
+	adminRouter.HandleFunc("/config", adminHandler.HandleGetConfig).Methods("GET")
+	adminRouter.HandleFunc("/config/reload", adminHandler.HandleReloadConfig).Methods("POST")
+	adminRouter.HandleFunc("/drain", adminHandler.HandleGetDrain).Methods("GET")
+	adminRouter.HandleFunc("/drain", adminHandler.HandleStartDrain).Methods("POST")
+	adminRouter.HandleFunc("/reports/verifications", adminHandler.HandleGetVerificationReport).Methods("GET")
+	adminRouter.HandleFunc("/response-templates", adminHandler.HandleListResponseTemplates).Methods("GET")
+	adminRouter.HandleFunc("/response-templates/{name}", adminHandler.HandlePutResponseTemplate).Methods("PUT")
+	adminRouter.HandleFunc("/response-templates/{name}", adminHandler.HandleDeleteResponseTemplate).Methods("DELETE")
+	adminRouter.HandleFunc("/custom-metrics", adminHandler.HandleListCustomMetrics).Methods("GET")
+	adminRouter.HandleFunc("/custom-metrics/{name}", adminHandler.HandlePutCustomMetric).Methods("PUT")
+	adminRouter.HandleFunc("/custom-metrics/{name}", adminHandler.HandleDeleteCustomMetric).Methods("DELETE")
+	adminRouter.HandleFunc("/dp-comparisons", adminHandler.HandleListDPComparisons).Methods("GET")
+	adminRouter.HandleFunc("/dp-comparisons/{claim_type}", adminHandler.HandleGetDPComparison).Methods("GET")
+	adminRouter.HandleFunc("/dp-comparisons/{claim_type}", adminHandler.HandlePutDPComparison).Methods("PUT")
+	adminRouter.HandleFunc("/dp-comparisons/{claim_type}", adminHandler.HandleDeleteDPComparison).Methods("DELETE")
+	adminRouter.HandleFunc("/schemas", adminHandler.HandleListValidationSchemas).Methods("GET")
+	adminRouter.HandleFunc("/schemas/{ref}", adminHandler.HandleGetValidationSchema).Methods("GET")
+	adminRouter.HandleFunc("/schemas/{name}/versions", adminHandler.HandleListValidationSchemaVersions).Methods("GET")
+	adminRouter.HandleFunc("/schemas/{name}/versions", adminHandler.HandlePublishValidationSchema).Methods("POST")
+	adminRouter.HandleFunc("/transformation-pipelines", adminHandler.HandleListTransformationPipelines).Methods("GET")
+	adminRouter.HandleFunc("/transformation-pipelines/{name}", adminHandler.HandlePutTransformationPipeline).Methods("PUT")
+	adminRouter.HandleFunc("/transformation-pipelines/{name}", adminHandler.HandleDeleteTransformationPipeline).Methods("DELETE")
+	adminRouter.HandleFunc("/support-bundles", adminHandler.HandleListSupportBundles).Methods("GET")
+	adminRouter.HandleFunc("/support-bundles/{request_id}", adminHandler.HandleGetSupportBundle).Methods("GET")
+	adminRouter.HandleFunc("/claim-schemas/{claim_type}", adminHandler.HandleUpdateClaimSchema).Methods("PUT")
+	adminRouter.HandleFunc("/registry-changes", adminHandler.HandleListRegistryChanges).Methods("GET")
+	adminRouter.HandleFunc("/registry-changes/{id}", adminHandler.HandleGetRegistryChange).Methods("GET")
+	adminRouter.HandleFunc("/credential-hooks", adminHandler.HandleGetCredentialHooks).Methods("GET")
+	adminRouter.HandleFunc("/federation/peers", adminHandler.HandleListFederationPeers).Methods("GET")
+	adminRouter.HandleFunc("/verification/stages", adminHandler.HandleListVerificationStages).Methods("GET")
+
+	return &http.Server{
+		Addr:         ":" + cfg.AdminPort,
+		Handler:      router,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+}
+
+// SelfTestReport returns the results of the startup cryptographic self-tests
+func (s *Server) SelfTestReport() *services.SelfTestReport {
+	return s.selfTestReport
+}
+
+// Admin returns the admin listener, or nil when ADMIN_PORT is empty
+func (s *Server) Admin() *http.Server {
+	return s.admin
+}
+
+// TimeSync returns the system clock checker, or nil for servers without one
+func (s *Server) TimeSync() *services.TimeSyncChecker {
+	return s.timeSync
+}
+
+// Reporting returns the verification reporting job, or nil for servers
+// without one
+func (s *Server) Reporting() *services.ReportingService {
+	return s.reporting
+}
+
+// Outbox returns the dispatcher delivering committed audit entries and
+// webhook notifications
+func (s *Server) Outbox() *services.OutboxDispatcher {
+	return s.outbox
+}
+
+// Drain takes the server out of rotation before a shutdown: readiness fails,
+// new verifications are refused and keep-alive connections are closed, and
+// the call returns once running work has finished and the audit log is
+// flushed, or when ctx ends. Servers without a drainer return at once.
+func (s *Server) Drain(ctx context.Context) services.DrainStatus {
+	if s.drainer == nil {
+		return services.DrainStatus{State: services.DrainDrained}
+	}
+	s.SetKeepAlivesEnabled(false)
+	return s.drainer.Drain(ctx)
+}
+
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
-	return s.Server.Shutdown(ctx)
+	if s.timeSync != nil {
+		s.timeSync.Stop()
+	}
+	if s.reporting != nil {
+		s.reporting.Stop()
+		if err := s.reporting.Flush(ctx); err != nil {
+			fmt.Printf("REPORTING WARNING: final flush failed: %v\n", err)
+		}
+	}
+	s.config.Secrets.Stop()
+	s.config.Runtime.Stop()
+	var adminErr error
+	if s.admin != nil {
+		adminErr = s.admin.Shutdown(ctx)
+	}
+	serverErr := s.Server.Shutdown(ctx)
+	// Storage is closed once in-flight requests have finished with it and
+	// the outbox has delivered what it can
+	if s.outbox != nil {
+		s.outbox.Stop()
+		if err := s.outbox.Flush(ctx); err != nil {
+			fmt.Printf("OUTBOX WARNING: final flush failed: %v\n", err)
+		}
+	}
+	if s.storage != nil {
+		if err := s.storage.Close(); err != nil {
+			fmt.Printf("STORAGE WARNING: close failed: %v\n", err)
+		}
+	}
+	if serverErr != nil {
+		return serverErr
+	}
+	return adminErr
+}
+
+// auditRuntimeChanges writes an audit entry for each runtime configuration
+// reload that changes a setting
+func auditRuntimeChanges(cfg *config.Config, auditService *services.AuditService) {
+	cfg.Runtime.OnChange(func(change config.RuntimeChange) {
+		auditService.LogConfigChange(context.Background(), change)
+	})
+}
+
+// gatewayBodyTypes are the request types the bodies of GATEWAY_STRICT_JSON
+// endpoints are decoded into
+var gatewayBodyTypes = map[string]func() interface{}{
+	"/api/v1/verify":                 func() interface{} { return &models.VerificationRequest{} },
+	"/api/v1/policy/simulate":        func() interface{} { return &models.VerificationRequest{} },
+	"/api/v1/verify/batch":           func() interface{} { return &handlers.BatchVerificationRequest{} },
+	"/api/v1/consents/revoke":        func() interface{} { return &handlers.RevokeConsentRequest{} },
+	"/api/v1/notifications/webhooks": func() interface{} { return &handlers.RegisterWebhookRequest{} },
+	"/api/v1/graphql":                func() interface{} { return &services.GraphQLRequest{} },
 }
 
 // NewAPIGateway creates a new API Gateway server with TLS termination and routing
@@ -141,26 +541,59 @@ func NewAPIGateway(cfg *config.Config) *Server {
 	router.Use(middleware.Recovery)
 	router.Use(middleware.HTTPSRedirect)
 	router.Use(middleware.SecurityHeaders)
+	// Client timeout hints become deadlines passed on to the broker
+	router.Use(middleware.RequestDeadline(cfg))
 
 	// Create API Gateway handlers
 	gatewayHandler := handlers.NewAPIGatewayHandler(cfg)
 	healthHandler := handlers.NewHealthHandler(cfg)
 
+	// Signed export downloads carry their own authorization
+	router.HandleFunc("/api/v1/exports/{id}/download", gatewayHandler.HandleAPIRequest).Methods("GET")
+
 	// API routes with authentication
 	apiRouter := router.PathPrefix("/api/v1").Subrouter()
 	apiRouter.Use(middleware.Authentication(cfg))
+	// Ahead of maintenance, a share of non-critical traffic is shed
+	apiRouter.Use(middleware.Brownout(cfg))
 	apiRouter.Use(middleware.RateLimiting(cfg))
+	// Oversized, malformed and over-nested bodies never reach the broker
+	apiRouter.Use(middleware.RequestBodyLimits(cfg, gatewayBodyTypes))
 
 	// Route all API requests to Core Broker
 	apiRouter.PathPrefix("").HandlerFunc(gatewayHandler.HandleAPIRequest)
 
+	// Rate limits are reloaded at runtime; record each change
+	auditRuntimeChanges(cfg, services.NewAuditService(cfg))
+
 	// Health check endpoint (no authentication required)
 	router.HandleFunc("/health", healthHandler.HandleHealth).Methods("GET")
 
+	// Kubernetes probes over the gateway's dependencies (no authentication required)
+	prober := services.NewHealthProber(healthHandler.DependencyProbes(), cfg.HealthProbeTimeout, cfg.HealthProbeCacheTTL)
+	probeHandler := handlers.NewProbeHandler(prober)
+	router.HandleFunc("/healthz", probeHandler.HandleLiveness).Methods("GET")
+	router.HandleFunc("/readyz", probeHandler.HandleReadiness).Methods("GET")
+
+	// Inbound TLS follows the configured versions, suites and curves
+	tlsConfig, err := services.ServerTLSSettings(cfg).TLSConfig(services.ActiveCryptoProfile(cfg))
+	if err != nil {
+		panic(fmt.Sprintf("Invalid inbound TLS configuration: %v", err))
+	}
+
+	// The certificate is reloaded when it rotates; without one loaded here,
+	// serving falls back to reading TLS_CERT_FILE and TLS_KEY_FILE once
+	if certificates, err := services.NewCertificateReloader(cfg); err == nil {
+		tlsConfig.GetCertificate = certificates.GetCertificate
+	} else {
+		fmt.Printf("TLS WARNING: gateway certificate will not be reloaded: %v\n", err)
+	}
+
 	// Create HTTP server with TLS
 	srv := &http.Server{
 		Addr:         ":" + cfg.APIGatewayPort,
 		Handler:      router,
+		TLSConfig:    tlsConfig,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConcurrencyLimitError is returned when a call finds its DP host at its
+// adaptive concurrency limit. Like a full bulkhead it is a local rejection,
+// so it never counts against a DP's circuit breaker.
+type ConcurrencyLimitError struct {
+	Host  string
+	Limit int
+}
+
+func (e *ConcurrencyLimitError) Error() string {
+	return fmt.Sprintf("adaptive concurrency limit for %s reached (%d concurrent calls)", e.Host, e.Limit)
+}
+
+// limitSample is what a call through an adaptive limiter says about its DP
+type limitSample int
+
+const (
+	// limitSampleIgnore says nothing about the DP: a call cancelled by a
+	// hedge or its caller, refused by the breaker, or failing fast
+	limitSampleIgnore limitSample = iota
+	// limitSampleSuccess is a response, with its latency
+	limitSampleSuccess
+	// limitSampleDropped is a DP timeout
+	limitSampleDropped
+)
+
+// AdaptiveLimitSettings configure an adaptive concurrency limiter
+type AdaptiveLimitSettings struct {
+	Initial int
+	Min     int
+	Max     int
+	// Tolerance is how many times its baseline latency a DP may take
+	// before the limit is cut
+	Tolerance float64
+	// Backoff multiplies the limit when it is cut
+	Backoff float64
+}
+
+// adaptiveLatencySmoothing weighs each latency sample in the recent latency
+const adaptiveLatencySmoothing = 0.2
+
+// adaptiveBaselineDrift is how far the baseline moves toward a sample slower
+// than it, so a DP that stays slower is eventually judged against its new
+// normal rather than throttled for good
+const adaptiveBaselineDrift = 0.01
+
+// AdaptiveLimiter limits concurrent calls to a DP host with AIMD: the limit
+// grows by one for each successful call while at least half of it is in
+// use and the host is timely, and is multiplied by Backoff when the host's
+// recent latency exceeds Tolerance times its baseline, or a call times out.
+// Latency is sampled per HTTP attempt, so retries, backoff waits and result
+// pages do not make a DP look slow. It is cut at most once per recent
+// latency, so one slow batch of calls cuts it once.
+type AdaptiveLimiter struct {
+	settings AdaptiveLimitSettings
+
+	mu          sync.Mutex
+	limit       float64
+	inFlight    int
+	baseline    time.Duration
+	recent      time.Duration
+	lastCut     time.Time
+	rejected    int64
+	cuts        int64
+	lastLatency time.Duration
+}
+
+// NewAdaptiveLimiter creates a limiter starting at settings.Initial
+func NewAdaptiveLimiter(settings AdaptiveLimitSettings) *AdaptiveLimiter {
+	if settings.Min < 1 {
+		settings.Min = 1
+	}
+	if settings.Max < settings.Min {
+		settings.Max = settings.Min
+	}
+	if settings.Initial < settings.Min || settings.Initial > settings.Max {
+		settings.Initial = settings.Max
+	}
+	if settings.Tolerance <= 1 {
+		settings.Tolerance = 2
+	}
+	if settings.Backoff <= 0 || settings.Backoff >= 1 {
+		settings.Backoff = 0.9
+	}
+	return &AdaptiveLimiter{settings: settings, limit: float64(settings.Initial)}
+}
+
+// Acquire takes a slot when the limit allows, returning the function that
+// releases it with the call's sample. A full limiter rejects at once: the
+// limit is the DP's capacity, so waiting would only queue calls on it.
+func (l *AdaptiveLimiter) Acquire() (func(limitSample, time.Duration), bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight >= int(l.limit) {
+		l.rejected++
+		return nil, false
+	}
+	l.inFlight++
+
+	var once sync.Once
+	return func(sample limitSample, latency time.Duration) {
+		once.Do(func() { l.release(sample, latency) })
+	}, true
+}
+
+func (l *AdaptiveLimiter) release(sample limitSample, latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	inFlight := l.inFlight
+	l.inFlight--
+
+	switch sample {
+	case limitSampleDropped:
+		l.cutLocked()
+	case limitSampleSuccess:
+		if latency > 0 {
+			l.observeLocked(latency)
+		}
+		if !l.slowLocked() && float64(inFlight)*2 >= l.limit {
+			// Only a limit in use is evidence the DP can take more
+			l.limit = math.Min(l.limit+1, float64(l.settings.Max))
+		}
+	}
+}
+
+// observe records the latency of one response from the host, cutting the
+// limit when its recent latency rises past the tolerance
+func (l *AdaptiveLimiter) observe(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.observeLocked(latency)
+}
+
+func (l *AdaptiveLimiter) observeLocked(latency time.Duration) {
+	l.lastLatency = latency
+	if l.baseline == 0 || latency < l.baseline {
+		l.baseline = latency
+	} else {
+		l.baseline += time.Duration(float64(latency-l.baseline) * adaptiveBaselineDrift)
+	}
+	if l.recent == 0 {
+		l.recent = latency
+	} else {
+		l.recent += time.Duration(float64(latency-l.recent) * adaptiveLatencySmoothing)
+	}
+	if l.slowLocked() {
+		l.cutLocked()
+	}
+}
+
+// slowLocked reports whether the host's recent latency exceeds Tolerance
+// times its baseline
+func (l *AdaptiveLimiter) slowLocked() bool {
+	return float64(l.recent) > float64(l.baseline)*l.settings.Tolerance
+}
+
+// cutLocked multiplies the limit by Backoff, unless it was cut within the
+// recent latency
+func (l *AdaptiveLimiter) cutLocked() {
+	now := time.Now()
+	if !l.lastCut.IsZero() && now.Sub(l.lastCut) < l.recent {
+		return
+	}
+	l.lastCut = now
+	l.cuts++
+	l.limit = math.Max(math.Floor(l.limit*l.settings.Backoff), float64(l.settings.Min))
+}
+
+// AdaptiveLimitStats describes a DP host's adaptive concurrency limit
+type AdaptiveLimitStats struct {
+	Host            string  `json:"host"`
+	Limit           int     `json:"limit"`
+	MinLimit        int     `json:"min_limit"`
+	MaxLimit        int     `json:"max_limit"`
+	InFlight        int     `json:"in_flight"`
+	BaselineLatency float64 `json:"baseline_latency_ms"`
+	RecentLatency   float64 `json:"recent_latency_ms"`
+	LastLatency     float64 `json:"last_latency_ms"`
+	Cuts            int64   `json:"cuts"`
+	Rejected        int64   `json:"rejected"`
+}
+
+// Stats returns the limiter's current limit, latencies and counters
+func (l *AdaptiveLimiter) Stats() AdaptiveLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return AdaptiveLimitStats{
+		Limit:           int(l.limit),
+		MinLimit:        l.settings.Min,
+		MaxLimit:        l.settings.Max,
+		InFlight:        l.inFlight,
+		BaselineLatency: float64(l.baseline) / float64(time.Millisecond),
+		RecentLatency:   float64(l.recent) / float64(time.Millisecond),
+		LastLatency:     float64(l.lastLatency) / float64(time.Millisecond),
+		Cuts:            l.cuts,
+		Rejected:        l.rejected,
+	}
+}
+
+// adaptiveLimiterGroup keeps a limiter per DP host. Hosts come from the DP
+// registry, so the group is not pruned.
+type adaptiveLimiterGroup struct {
+	settings AdaptiveLimitSettings
+
+	mu       sync.Mutex
+	limiters map[string]*AdaptiveLimiter
+}
+
+func newAdaptiveLimiterGroup(settings AdaptiveLimitSettings) *adaptiveLimiterGroup {
+	return &adaptiveLimiterGroup{settings: settings, limiters: make(map[string]*AdaptiveLimiter)}
+}
+
+// acquire takes a slot in the host's limiter; a group with no maximum does
+// not limit
+func (g *adaptiveLimiterGroup) acquire(host string) (func(limitSample, time.Duration), error) {
+	if g.settings.Max <= 0 {
+		return func(limitSample, time.Duration) {}, nil
+	}
+
+	g.mu.Lock()
+	limiter, exists := g.limiters[host]
+	if !exists {
+		limiter = NewAdaptiveLimiter(g.settings)
+		g.limiters[host] = limiter
+	}
+	g.mu.Unlock()
+
+	release, ok := limiter.Acquire()
+	if !ok {
+		return nil, &ConcurrencyLimitError{Host: host, Limit: limiter.Stats().Limit}
+	}
+	return release, nil
+}
+
+// observer returns the function recording a response latency in the
+// host's limiter; a group with no maximum does not limit
+func (g *adaptiveLimiterGroup) observer(host string) func(time.Duration) {
+	if g.settings.Max <= 0 {
+		return func(time.Duration) {}
+	}
+	g.mu.Lock()
+	limiter, exists := g.limiters[host]
+	g.mu.Unlock()
+	if !exists {
+		return func(time.Duration) {}
+	}
+	return limiter.observe
+}
+
+// limitObserverKey carries the function recording each HTTP attempt's
+// response latency in the adaptive limiter of the DP's host
+type limitObserverKey struct{}
+
+// withLimitObserver makes the DP requests sent under ctx sample their
+// response latency with observe
+func withLimitObserver(ctx context.Context, observe func(time.Duration)) context.Context {
+	return context.WithValue(ctx, limitObserverKey{}, observe)
+}
+
+// observeLimitLatency records the response latency of one HTTP attempt to a
+// DP, if the attempt is made under an adaptive limit
+func observeLimitLatency(ctx context.Context, latency time.Duration) {
+	if observe, ok := ctx.Value(limitObserverKey{}).(func(time.Duration)); ok {
+		observe(latency)
+	}
+}
+
+// stats returns each host's limiter stats ordered by host
+func (g *adaptiveLimiterGroup) stats() []AdaptiveLimitStats {
+	g.mu.Lock()
+	limiters := make(map[string]*AdaptiveLimiter, len(g.limiters))
+	for host, limiter := range g.limiters {
+		limiters[host] = limiter
+	}
+	g.mu.Unlock()
+
+	stats := make([]AdaptiveLimitStats, 0, len(limiters))
+	for host, limiter := range limiters {
+		entry := limiter.Stats()
+		entry.Host = host
+		stats = append(stats, entry)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Host < stats[j].Host })
+	return stats
+}
+
+// dpHost is the host a provider's calls are limited under: DPs served from
+// one host share its capacity. Providers without an endpoint host are
+// limited by DP ID.
+func dpHost(provider *DPProvider) string {
+	if parsed, err := url.Parse(provider.Endpoint); err == nil && parsed.Host != "" {
+		return parsed.Host
+	}
+	return provider.DPID
+}
+
+// acquireDPConcurrencyLimit takes a slot in the adaptive limiter of the
+// provider's host
+func (s *DPConnectorService) acquireDPConcurrencyLimit(provider *DPProvider) (func(limitSample, time.Duration), error) {
+	return s.dpLimiters.acquire(dpHost(provider))
+}
+
+// GetConcurrencyLimitStats returns the adaptive concurrency limit of each DP
+// host called so far
+func (s *DPConnectorService) GetConcurrencyLimitStats() map[string]interface{} {
+	return map[string]interface{}{
+		"enabled": s.dpLimiters.settings.Max > 0,
+		"hosts":   s.dpLimiters.stats(),
+	}
+}
+
+// ConcurrencyLimitMetrics returns each DP host's adaptive concurrency limit
+// and rejections as metrics
+func (s *DPConnectorService) ConcurrencyLimitMetrics() []Metric {
+	now := time.Now()
+	stats := s.dpLimiters.stats()
+
+	// Series sharing a name are kept together for the Prometheus output
+	var metrics []Metric
+	for _, entry := range stats {
+		metrics = append(metrics, Metric{Name: "core_broker_dp_concurrency_limit", Type: MetricTypeGauge, Value: float64(entry.Limit),
+			Labels: map[string]string{"host": entry.Host}, Help: "Adaptive limit of concurrent calls to the DP host", Time: now})
+	}
+	for _, entry := range stats {
+		metrics = append(metrics, Metric{Name: "core_broker_dp_concurrency_limit_rejections_total", Type: MetricTypeCounter, Value: float64(entry.Rejected),
+			Labels: map[string]string{"host": entry.Host}, Help: "DP calls rejected at the host's adaptive concurrency limit", Time: now})
+	}
+	return metrics
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestAdaptiveLimiter_GrowsAndBacksOff(t *testing.T) {
+	limiter := NewAdaptiveLimiter(AdaptiveLimitSettings{Initial: 4, Min: 2, Max: 6, Tolerance: 2, Backoff: 0.5})
+
+	var releases []func(limitSample, time.Duration)
+	for i := 0; i < 4; i++ {
+		release, ok := limiter.Acquire()
+		if !ok {
+			t.Fatalf("Expected call %d to get a slot", i+1)
+		}
+		releases = append(releases, release)
+	}
+	if _, ok := limiter.Acquire(); ok {
+		t.Fatal("Expected a call over the limit to be rejected")
+	}
+
+	// Timely responses grow the limit while half of it is in use, up to the max
+	for _, release := range releases {
+		release(limitSampleSuccess, time.Millisecond)
+	}
+	if stats := limiter.Stats(); stats.Limit != 6 || stats.InFlight != 0 || stats.Rejected != 1 {
+		t.Fatalf("Expected the limit to grow to 6, got %+v", stats)
+	}
+
+	// Latency rising past the tolerance cuts it
+	release, _ := limiter.Acquire()
+	release(limitSampleSuccess, 10*time.Millisecond)
+	if stats := limiter.Stats(); stats.Limit != 3 || stats.Cuts != 1 {
+		t.Fatalf("Expected slow responses to cut the limit to 3, got %+v", stats)
+	}
+	// Releasing twice does not count twice
+	release(limitSampleDropped, 0)
+
+	// So does a timeout, down to the min
+	time.Sleep(5 * time.Millisecond)
+	release, _ = limiter.Acquire()
+	release(limitSampleDropped, 0)
+	if stats := limiter.Stats(); stats.Limit != 2 || stats.Cuts != 2 || stats.InFlight != 0 {
+		t.Fatalf("Expected a timeout to cut the limit to the min, got %+v", stats)
+	}
+
+	// Calls that say nothing about the DP leave it alone
+	release, _ = limiter.Acquire()
+	release(limitSampleIgnore, 0)
+	if stats := limiter.Stats(); stats.Limit != 2 || stats.Cuts != 2 {
+		t.Errorf("Expected an ignored call to leave the limit, got %+v", stats)
+	}
+}
+
+func TestAdaptiveLimiterGroup_LimitsPerHost(t *testing.T) {
+	group := newAdaptiveLimiterGroup(AdaptiveLimitSettings{Initial: 1, Min: 1, Max: 4})
+	first := &DPProvider{DPID: "dp_a", Endpoint: "https://dp.example:8443/verify"}
+	second := &DPProvider{DPID: "dp_b", Endpoint: "https://dp.example:8443/v2/verify"}
+	other := &DPProvider{DPID: "dp_c", Endpoint: "https://other.example/verify"}
+
+	release, err := group.acquire(dpHost(first))
+	if err != nil {
+		t.Fatalf("Expected a slot, got %v", err)
+	}
+	// DPs on one host share its limit
+	var limitErr *ConcurrencyLimitError
+	if _, err := group.acquire(dpHost(second)); !errors.As(err, &limitErr) || limitErr.Host != "dp.example:8443" {
+		t.Errorf("Expected the shared host to be at its limit, got %v", err)
+	}
+	if _, err := group.acquire(dpHost(other)); err != nil {
+		t.Errorf("Expected another host to have its own limit, got %v", err)
+	}
+	release(limitSampleSuccess, time.Millisecond)
+
+	stats := group.stats()
+	if len(stats) != 2 || stats[0].Host != "dp.example:8443" || stats[0].Rejected != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	unlimited := newAdaptiveLimiterGroup(AdaptiveLimitSettings{})
+	for i := 0; i < 3; i++ {
+		if _, err := unlimited.acquire("dp.example"); err != nil {
+			t.Fatalf("Expected no limit without a max, got %v", err)
+		}
+	}
+}
+
+func TestAttemptProvider_SamplesLatencyPerHTTPAttempt(t *testing.T) {
+	var calls int32
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		// The second call fails once and is retried after the backoff
+		if atomic.AddInt32(&calls, 1) == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":              "j",
+			"status":              "completed",
+			"timestamp":           "2026-01-01T00:00:00Z",
+			"verification_result": map[string]interface{}{"verified": true, "confidence": 0.9},
+		})
+	}))
+	defer dp.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: dp.URL, DPAdaptiveLimitMax: 4})
+	service.retryConfig.BaseDelay = 100 * time.Millisecond
+	service.retryConfig.Jitter = false
+	req := &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"}
+	for i := 0; i < 2; i++ {
+		if _, err := service.VerifyWithDP(context.Background(), req); err != nil {
+			t.Fatalf("Expected call %d to succeed, got %v", i+1, err)
+		}
+	}
+
+	// The backoff before the retry is not the DP's latency
+	stats := service.dpLimiters.stats()
+	if len(stats) != 1 || stats[0].Cuts != 0 || stats[0].LastLatency >= 100 {
+		t.Errorf("Expected the retried call not to look slow, got %+v", stats)
+	}
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"sort"
+)
+
+// Operational controls used by the admin API to recover from incidents
+// without a restart
+
+// Reset closes the breaker and forgets its failures, for operators who know
+// the DP has recovered before the breaker's timeout
+func (cb *CircuitBreaker) Reset() {
+	defer cb.emit()
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failureCount = 0
+	cb.recentErrors = nil
+	if cb.window != nil {
+		cb.window.reset()
+	}
+	if event := cb.transitionLocked(CircuitClosed); event != nil {
+		event.Summary = "reset by an operator"
+	}
+}
+
+// State returns the breaker's current state
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// GetBreakerStats returns the circuit breaker of every registered DP
+func (s *DPConnectorService) GetBreakerStats() map[string]interface{} {
+	stats := make(map[string]interface{})
+	for _, provider := range s.registry.List() {
+		stats[provider.DPID] = s.providerBreaker(provider.DPID).GetCircuitBreakerStats()
+	}
+	return stats
+}
+
+// ResetBreaker resets a DP's circuit breaker, returning false for a DP that
+// is neither registered nor has a breaker
+func (s *DPConnectorService) ResetBreaker(dpID string) bool {
+	s.providerMu.Lock()
+	breaker, exists := s.providerBreakers[dpID]
+	s.providerMu.Unlock()
+
+	if !exists {
+		// A registered DP without a breaker has not failed yet
+		_, registered := s.registry.Get(dpID)
+		return registered
+	}
+	breaker.Reset()
+	return true
+}
+
+// ResetBreakers resets every DP's circuit breaker and returns the DPs whose
+// breaker was open or half-open
+func (s *DPConnectorService) ResetBreakers() []string {
+	s.providerMu.Lock()
+	breakers := make(map[string]*CircuitBreaker, len(s.providerBreakers))
+	for dpID, breaker := range s.providerBreakers {
+		breakers[dpID] = breaker
+	}
+	s.providerMu.Unlock()
+
+	reset := make([]string, 0)
+	for dpID, breaker := range breakers {
+		tripped := breaker.State() != CircuitClosed
+		breaker.Reset()
+		if tripped {
+			reset = append(reset, dpID)
+		}
+	}
+	sort.Strings(reset)
+	return reset
+}
+
+// Drain evicts every pooled client, closing its idle connections, and
+// returns how many were evicted
+func (p *ConnectionPool) Drain() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	drained := 0
+	for host := range p.clients {
+		p.evictLocked(host)
+		drained++
+	}
+	return drained
+}
+
+// DrainConnections closes idle connections to every DP so the next requests
+// dial again, e.g. after a DP fails over to new addresses. Requests in
+// flight finish on their connections. It returns the number of clients
+// drained.
+func (s *DPConnectorService) DrainConnections() int {
+	s.client.CloseIdleConnections()
+	drained := 1
+
+	s.providerMu.Lock()
+	for _, client := range s.providerClients {
+		client.CloseIdleConnections()
+		drained++
+	}
+	s.providerMu.Unlock()
+
+	return drained + s.pool.Drain()
+}
+
+// RegistryReload reports what a DP registry reload changed
+type RegistryReload struct {
+	Providers int      `json:"providers"`
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Updated   []string `json:"updated"`
+	// Changes are the structured diffs of the added, removed and updated
+	// providers
+	Changes []*RegistryChange `json:"changes"`
+}
+
+// ReloadRegistry rereads DP_REGISTRY_FILE and swaps in its providers. An
+// invalid file leaves the current registry in effect. Updated and removed
+// providers get new TLS clients and authenticators on their next request.
+func (s *DPConnectorService) ReloadRegistry() (*RegistryReload, error) {
+	next, err := LoadDPRegistry(s.config)
+	if err != nil {
+		return nil, err
+	}
+	reload := s.registry.replace(next)
+
+	s.providerMu.Lock()
+	for _, dpIDs := range [][]string{reload.Updated, reload.Removed} {
+		for _, dpID := range dpIDs {
+			if client, exists := s.providerClients[dpID]; exists {
+				client.CloseIdleConnections()
+			}
+			delete(s.providerClients, dpID)
+			delete(s.providerAuthenticators, dpID)
+		}
+	}
+	s.providerMu.Unlock()
+
+	return reload, nil
+}
+
+// replace swaps in another registry's providers and freshness policies,
+// comparing the providers by their configuration
+func (r *DPRegistry) replace(next *DPRegistry) *RegistryReload {
+	next.mu.RLock()
+	providers := next.providers
+	freshness := next.freshness
+	next.mu.RUnlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reload := &RegistryReload{
+		Providers: len(providers),
+		Added:     make([]string, 0),
+		Removed:   make([]string, 0),
+		Updated:   make([]string, 0),
+		Changes:   make([]*RegistryChange, 0),
+	}
+	for dpID, provider := range providers {
+		current, exists := r.providers[dpID]
+		if !exists {
+			reload.Added = append(reload.Added, dpID)
+			reload.Changes = append(reload.Changes, DiffDPProviders(nil, provider))
+			continue
+		}
+		if change := DiffDPProviders(current, provider); change != nil {
+			reload.Updated = append(reload.Updated, dpID)
+			reload.Changes = append(reload.Changes, change)
+		}
+	}
+	for dpID, current := range r.providers {
+		if _, exists := providers[dpID]; !exists {
+			reload.Removed = append(reload.Removed, dpID)
+			reload.Changes = append(reload.Changes, DiffDPProviders(current, nil))
+		}
+	}
+	r.providers = providers
+	r.freshness = freshness
+
+	sort.Strings(reload.Added)
+	sort.Strings(reload.Removed)
+	sort.Strings(reload.Updated)
+	sortRegistryChanges(reload.Changes)
+	return reload
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestDPConnectorService_ResetBreakers(t *testing.T) {
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: "http://localhost:8080", DPTimeout: 5 * time.Second})
+	service.registry = NewDPRegistry()
+	service.registry.Register(&DPProvider{DPID: "dp_1", Endpoint: "http://dp1.example.com", SupportedClaims: []string{"age_verification"}})
+	service.registry.Register(&DPProvider{DPID: "dp_2", Endpoint: "http://dp2.example.com", SupportedClaims: []string{"age_verification"}})
+	var events []CircuitBreakerEvent
+	service.SetBreakerEventHandler(func(event CircuitBreakerEvent) { events = append(events, event) })
+
+	breaker := service.providerBreaker("dp_1")
+	for i := 0; i < breaker.threshold; i++ {
+		breaker.RecordFailure()
+	}
+	if breaker.state != CircuitOpen {
+		t.Fatalf("Expected the breaker to open, got %s", breaker.state)
+	}
+
+	if !service.ResetBreaker("dp_1") || breaker.state != CircuitClosed || breaker.failureCount != 0 {
+		t.Fatalf("Expected the breaker to be reset, got %s with %d failures", breaker.state, breaker.failureCount)
+	}
+	if last := events[len(events)-1]; last.To != CircuitClosed || last.Summary != "reset by an operator" {
+		t.Errorf("Expected a reset event, got %+v", last)
+	}
+	if !service.ResetBreaker("dp_2") {
+		t.Error("Expected a registered DP without failures to reset")
+	}
+	if service.ResetBreaker("dp_unknown") {
+		t.Error("Expected an unknown DP not to reset")
+	}
+
+	for i := 0; i < breaker.threshold; i++ {
+		breaker.RecordFailure()
+	}
+	if reset := service.ResetBreakers(); !reflect.DeepEqual(reset, []string{"dp_1"}) {
+		t.Errorf("Expected only the open breaker to be reported, got %v", reset)
+	}
+	if stats := service.GetBreakerStats(); len(stats) != 2 {
+		t.Errorf("Expected a breaker per registered DP, got %v", stats)
+	}
+}
+
+func TestDPConnectorService_ReloadRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	write := func(contents string) {
+		if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	write(`{"providers": [
+		{"dp_id": "dp_1", "endpoint": "http://dp1.example.com", "supported_claims": ["age_verification"]},
+		{"dp_id": "dp_2", "endpoint": "http://dp2.example.com", "supported_claims": ["student_verification"]}
+	]}`)
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: "http://localhost:8080", DPRegistryFile: path, DPTimeout: 5 * time.Second})
+
+	write(`{"providers": [
+		{"dp_id": "dp_1", "endpoint": "http://dp1.example.com", "supported_claims": ["age_verification"]},
+		{"dp_id": "dp_2", "endpoint": "http://dp2-new.example.com", "supported_claims": ["student_verification"]},
+		{"dp_id": "dp_3", "endpoint": "http://dp3.example.com", "supported_claims": ["employee_verification"]}
+	]}`)
+	reload, err := service.ReloadRegistry()
+	if err != nil {
+		t.Fatalf("ReloadRegistry failed: %v", err)
+	}
+	if reload.Providers != 3 || !reflect.DeepEqual(reload.Added, []string{"dp_3"}) || !reflect.DeepEqual(reload.Updated, []string{"dp_2"}) || len(reload.Removed) != 0 {
+		t.Errorf("Unexpected reload %+v", reload)
+	}
+	if len(reload.Changes) != 2 || reload.Changes[0].Subject != "dp_2" || reload.Changes[0].Diff[0].Path != "endpoint" {
+		t.Errorf("Expected the diffs of dp_2 and dp_3, got %+v", reload.Changes)
+	}
+	if provider, _ := service.Registry().Get("dp_2"); provider.Endpoint != "http://dp2-new.example.com" {
+		t.Errorf("Expected the updated endpoint, got %s", provider.Endpoint)
+	}
+
+	// An invalid file keeps the current providers
+	write(`{"providers": []}`)
+	if _, err := service.ReloadRegistry(); err == nil {
+		t.Error("Expected an empty registry to be rejected")
+	}
+	if providers := service.Registry().List(); len(providers) != 3 {
+		t.Errorf("Expected the current providers to stay, got %d", len(providers))
+	}
+
+	write(`{"providers": [{"dp_id": "dp_3", "endpoint": "http://dp3.example.com", "supported_claims": ["employee_verification"]}]}`)
+	if reload, _ := service.ReloadRegistry(); !reflect.DeepEqual(reload.Removed, []string{"dp_1", "dp_2"}) {
+		t.Errorf("Expected dp_1 and dp_2 to be removed, got %+v", reload)
+	}
+	if _, exists := service.Registry().Get("dp_1"); exists {
+		t.Error("Expected dp_1 to be gone")
+	}
+}
+
+func TestDPConnectorService_DrainConnections(t *testing.T) {
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: "http://localhost:8080", DPTimeout: 5 * time.Second})
+	service.pool.GetConnection("dp1.example.com")
+	service.pool.GetConnection("dp2.example.com")
+
+	// The shared client and both pooled clients
+	if drained := service.DrainConnections(); drained != 3 {
+		t.Errorf("Expected 3 clients drained, got %d", drained)
+	}
+	if stats := service.pool.GetConnectionPoolStats(); stats["total_connections"] != 0 {
+		t.Errorf("Expected an empty pool, got %v", stats)
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	cfg := &config.Config{
+		Port:             "8080",
+		DPConnectorToken: "dp-token-value",
+		AdminAPIToken:    "secret:admin-token",
+		DatabaseURL:      "postgres://pavilion:hunter2@db:5432/pavilion?sslmode=disable",
+		RedisURL:         "redis://redis:6379?password=hunter2",
+		DPTimeout:        10 * time.Second,
+		Redis:            config.RedisConfig{Host: "redis", Password: "hunter2"},
+		Secrets:          config.NewSecretStore(nil),
+	}
+	redacted := cfg.Redacted()
+
+	if redacted["DPConnectorToken"] != "[REDACTED]" || redacted["HashKey"] != "" {
+		t.Errorf("Expected set secrets to be redacted and unset ones empty, got %v, %v", redacted["DPConnectorToken"], redacted["HashKey"])
+	}
+	if redacted["AdminAPIToken"] != "secret:admin-token" {
+		t.Errorf("Expected secret references to be shown, got %v", redacted["AdminAPIToken"])
+	}
+	for _, field := range []string{"DatabaseURL", "RedisURL"} {
+		if value := redacted[field].(string); value == "" || strings.Contains(value, "hunter2") {
+			t.Errorf("Expected the password removed from %s, got %q", field, value)
+		}
+	}
+	if redis := redacted["Redis"].(map[string]interface{}); redis["Password"] != "[REDACTED]" || redis["Host"] != "redis" {
+		t.Errorf("Expected nested settings to be redacted, got %v", redis)
+	}
+	if redacted["DPTimeout"] != "10s" || redacted["Port"] != "8080" {
+		t.Errorf("Expected plain settings, got %v and %v", redacted["DPTimeout"], redacted["Port"])
+	}
+	if _, exists := redacted["Secrets"]; exists {
+		t.Error("Expected stores to be left out")
+	}
+}
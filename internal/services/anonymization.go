@@ -0,0 +1,454 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Export types an anonymization profile applies to
+const (
+	// ExportTypeVerificationRecords is the RP export of verification records
+	ExportTypeVerificationRecords = "verification_records"
+	// ExportTypeVerificationReport is the daily verification rollup report
+	ExportTypeVerificationReport = "verification_report"
+)
+
+// Built-in anonymization step types
+const (
+	// AnonymizeGeneralize coarsens a field: timestamps to a granularity and
+	// numbers to buckets
+	AnonymizeGeneralize = "generalize"
+	// AnonymizeSuppress removes fields from every row
+	AnonymizeSuppress = "suppress"
+	// AnonymizeSuppressSmallGroups drops rows in groups smaller than
+	// min_group_size, counted by count_field or by rows sharing fields
+	AnonymizeSuppressSmallGroups = "suppress_small_groups"
+	// AnonymizeNoise adds Laplace noise of scale sensitivity/epsilon to
+	// numeric fields, as in differential privacy
+	AnonymizeNoise = "noise"
+)
+
+// AnonymizationStep configures one step of an export's anonymization. The
+// parameters used depend on the step's type.
+type AnonymizationStep struct {
+	Type   string   `json:"type"`
+	Fields []string `json:"fields"`
+	// Generalize: Granularity is hour, day or month for timestamps; Bucket
+	// is the width numbers are rounded down to
+	Granularity string  `json:"granularity,omitempty"`
+	Bucket      float64 `json:"bucket,omitempty"`
+	// Suppress small groups
+	MinGroupSize int    `json:"min_group_size,omitempty"`
+	CountField   string `json:"count_field,omitempty"`
+	// Noise
+	Epsilon     float64 `json:"epsilon,omitempty"`
+	Sensitivity float64 `json:"sensitivity,omitempty"`
+}
+
+// AnonymizationProfile is the anonymization applied to an export type, its
+// steps run in order
+type AnonymizationProfile struct {
+	ExportType string              `json:"export_type"`
+	Steps      []AnonymizationStep `json:"steps"`
+}
+
+// AnonymizationFile is the on-disk format of anonymization profiles
+type AnonymizationFile struct {
+	Profiles []*AnonymizationProfile `json:"profiles"`
+}
+
+// AnonymizationManifest records how an export was anonymized, so its
+// recipients know which fields were coarsened, dropped or perturbed
+type AnonymizationManifest struct {
+	ExportType     string              `json:"export_type"`
+	Steps          []AnonymizationStep `json:"steps"`
+	RowsIn         int                 `json:"rows_in"`
+	RowsOut        int                 `json:"rows_out"`
+	RowsSuppressed int                 `json:"rows_suppressed"`
+}
+
+// Anonymizer is one anonymization step applied to an export's rows. Steps
+// may change rows in place and return fewer rows than they were given.
+type Anonymizer interface {
+	Anonymize(rows []map[string]interface{}) []map[string]interface{}
+}
+
+// AnonymizerFactory builds the anonymizer of a configured step, rejecting
+// invalid parameters
+type AnonymizerFactory func(step AnonymizationStep, random func() float64) (Anonymizer, error)
+
+var (
+	anonymizerMu        sync.RWMutex
+	anonymizerFactories = map[string]AnonymizerFactory{
+		AnonymizeGeneralize:          newGeneralizer,
+		AnonymizeSuppress:            newSuppressor,
+		AnonymizeSuppressSmallGroups: newSmallGroupSuppressor,
+		AnonymizeNoise:               newNoiser,
+	}
+)
+
+// RegisterAnonymizer adds a step type profiles may use
+func RegisterAnonymizer(stepType string, factory AnonymizerFactory) {
+	anonymizerMu.Lock()
+	defer anonymizerMu.Unlock()
+	anonymizerFactories[stepType] = factory
+}
+
+func anonymizerFactory(stepType string) (AnonymizerFactory, bool) {
+	anonymizerMu.RLock()
+	defer anonymizerMu.RUnlock()
+	factory, exists := anonymizerFactories[stepType]
+	return factory, exists
+}
+
+// compiledProfile is a profile with its anonymizers built
+type compiledProfile struct {
+	profile     *AnonymizationProfile
+	anonymizers []Anonymizer
+}
+
+// AnonymizationService runs analytics and report exports through the
+// anonymization profile of their export type before they leave the broker.
+// When the profiles failed to load, exports are refused rather than sent
+// unanonymized.
+type AnonymizationService struct {
+	mu       sync.RWMutex
+	profiles map[string]*compiledProfile
+	loadErr  error
+	random   func() float64
+}
+
+// NewAnonymizationService creates a service without profiles; exports pass
+// through unchanged until one is set for their type
+func NewAnonymizationService() *AnonymizationService {
+	return &AnonymizationService{
+		profiles: make(map[string]*compiledProfile),
+		random:   rand.Float64,
+	}
+}
+
+// SetProfile validates and sets the profile of an export type
+func (s *AnonymizationService) SetProfile(profile *AnonymizationProfile) error {
+	compiled, err := s.compile(profile)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profiles[profile.ExportType] = compiled
+	return nil
+}
+
+func (s *AnonymizationService) compile(profile *AnonymizationProfile) (*compiledProfile, error) {
+	if profile.ExportType == "" {
+		return nil, fmt.Errorf("anonymization profile: export_type is required")
+	}
+	compiled := &compiledProfile{profile: profile}
+	for i, step := range profile.Steps {
+		factory, exists := anonymizerFactory(step.Type)
+		if !exists {
+			return nil, fmt.Errorf("anonymization profile %s: step %d: unknown type %q", profile.ExportType, i, step.Type)
+		}
+		anonymizer, err := factory(step, s.randomFloat)
+		if err != nil {
+			return nil, fmt.Errorf("anonymization profile %s: step %d (%s): %w", profile.ExportType, i, step.Type, err)
+		}
+		compiled.anonymizers = append(compiled.anonymizers, anonymizer)
+	}
+	return compiled, nil
+}
+
+// randomFloat reads the random source under the lock, so tests can replace
+// it while exports run
+func (s *AnonymizationService) randomFloat() float64 {
+	s.mu.RLock()
+	random := s.random
+	s.mu.RUnlock()
+	return random()
+}
+
+// LoadFile sets the profiles of an anonymization file. A file that cannot
+// be loaded blocks every export until profiles load successfully.
+func (s *AnonymizationService) LoadFile(path string) error {
+	err := s.loadFile(path)
+	s.mu.Lock()
+	s.loadErr = err
+	s.mu.Unlock()
+	return err
+}
+
+func (s *AnonymizationService) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read anonymization file: %w", err)
+	}
+
+	var file AnonymizationFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse anonymization file: %w", err)
+	}
+
+	for _, profile := range file.Profiles {
+		if err := s.SetProfile(profile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Profiles returns the configured profiles ordered by export type
+func (s *AnonymizationService) Profiles() []*AnonymizationProfile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	profiles := make([]*AnonymizationProfile, 0, len(s.profiles))
+	for _, exportType := range sortedMapKeys(s.profiles) {
+		profiles = append(profiles, s.profiles[exportType].profile)
+	}
+	return profiles
+}
+
+// Anonymize runs an export's rows through the profile of its type and
+// returns them with the manifest of what was applied. An export type without
+// a profile passes through unchanged, with a manifest listing no steps.
+func (s *AnonymizationService) Anonymize(exportType string, rows []map[string]interface{}) ([]map[string]interface{}, *AnonymizationManifest, error) {
+	s.mu.RLock()
+	compiled, exists := s.profiles[exportType]
+	loadErr := s.loadErr
+	s.mu.RUnlock()
+	if loadErr != nil {
+		return nil, nil, fmt.Errorf("anonymization is not configured: %w", loadErr)
+	}
+
+	manifest := &AnonymizationManifest{
+		ExportType: exportType,
+		Steps:      make([]AnonymizationStep, 0),
+		RowsIn:     len(rows),
+	}
+	if exists {
+		manifest.Steps = append(manifest.Steps, compiled.profile.Steps...)
+		for _, anonymizer := range compiled.anonymizers {
+			rows = anonymizer.Anonymize(rows)
+		}
+	}
+	manifest.RowsOut = len(rows)
+	manifest.RowsSuppressed = manifest.RowsIn - manifest.RowsOut
+	return rows, manifest, nil
+}
+
+// generalizer coarsens timestamps and numbers
+type generalizer struct {
+	fields      []string
+	granularity string
+	bucket      float64
+}
+
+func newGeneralizer(step AnonymizationStep, _ func() float64) (Anonymizer, error) {
+	if len(step.Fields) == 0 {
+		return nil, fmt.Errorf("fields are required")
+	}
+	switch step.Granularity {
+	case "", "hour", "day", "month":
+	default:
+		return nil, fmt.Errorf("granularity must be hour, day or month")
+	}
+	if step.Bucket < 0 || (step.Granularity == "" && step.Bucket == 0) {
+		return nil, fmt.Errorf("a granularity or a positive bucket is required")
+	}
+	return &generalizer{fields: step.Fields, granularity: step.Granularity, bucket: step.Bucket}, nil
+}
+
+func (g *generalizer) Anonymize(rows []map[string]interface{}) []map[string]interface{} {
+	for _, row := range rows {
+		for _, field := range g.fields {
+			value, exists := row[field]
+			if !exists {
+				continue
+			}
+			row[field] = g.generalize(value)
+		}
+	}
+	return rows
+}
+
+func (g *generalizer) generalize(value interface{}) interface{} {
+	if number, ok := numericValue(value); ok && g.bucket > 0 {
+		return math.Floor(number/g.bucket) * g.bucket
+	}
+	if g.granularity == "" {
+		return value
+	}
+
+	var timestamp time.Time
+	switch typed := value.(type) {
+	case time.Time:
+		timestamp = typed
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, typed)
+		if err != nil {
+			// Dates such as report days are already coarse
+			if _, err := time.Parse("2006-01-02", typed); err == nil && g.granularity == "month" {
+				return typed[:7]
+			}
+			return value
+		}
+		timestamp = parsed
+	default:
+		return value
+	}
+
+	timestamp = timestamp.UTC()
+	switch g.granularity {
+	case "hour":
+		return timestamp.Truncate(time.Hour).Format(time.RFC3339)
+	case "day":
+		return timestamp.Format("2006-01-02")
+	default:
+		return timestamp.Format("2006-01")
+	}
+}
+
+// suppressor removes fields
+type suppressor struct {
+	fields []string
+}
+
+func newSuppressor(step AnonymizationStep, _ func() float64) (Anonymizer, error) {
+	if len(step.Fields) == 0 {
+		return nil, fmt.Errorf("fields are required")
+	}
+	return &suppressor{fields: step.Fields}, nil
+}
+
+func (s *suppressor) Anonymize(rows []map[string]interface{}) []map[string]interface{} {
+	for _, row := range rows {
+		for _, field := range s.fields {
+			delete(row, field)
+		}
+	}
+	return rows
+}
+
+// smallGroupSuppressor drops rows describing fewer than minGroupSize
+// subjects: aggregate rows by their count field, other rows by how many
+// rows share their values of fields
+type smallGroupSuppressor struct {
+	fields       []string
+	minGroupSize int
+	countField   string
+}
+
+func newSmallGroupSuppressor(step AnonymizationStep, _ func() float64) (Anonymizer, error) {
+	if step.MinGroupSize < 2 {
+		return nil, fmt.Errorf("min_group_size must be at least 2")
+	}
+	if step.CountField == "" && len(step.Fields) == 0 {
+		return nil, fmt.Errorf("fields or count_field is required")
+	}
+	return &smallGroupSuppressor{fields: step.Fields, minGroupSize: step.MinGroupSize, countField: step.CountField}, nil
+}
+
+func (s *smallGroupSuppressor) Anonymize(rows []map[string]interface{}) []map[string]interface{} {
+	sizes := make(map[string]int)
+	if s.countField == "" {
+		for _, row := range rows {
+			sizes[s.groupKey(row)]++
+		}
+	}
+
+	kept := rows[:0]
+	for _, row := range rows {
+		size := sizes[s.groupKey(row)]
+		if s.countField != "" {
+			count, _ := numericValue(row[s.countField])
+			size = int(count)
+		}
+		if size >= s.minGroupSize {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+func (s *smallGroupSuppressor) groupKey(row map[string]interface{}) string {
+	if s.countField != "" {
+		return ""
+	}
+	values := make([]string, len(s.fields))
+	for i, field := range s.fields {
+		encoded, _ := json.Marshal(row[field])
+		values[i] = string(encoded)
+	}
+	return strings.Join(values, "\x00")
+}
+
+// noiser adds Laplace noise to numeric fields. Fields holding whole numbers
+// stay whole and non-negative, so counts remain counts.
+type noiser struct {
+	fields []string
+	scale  float64
+	random func() float64
+}
+
+func newNoiser(step AnonymizationStep, random func() float64) (Anonymizer, error) {
+	if len(step.Fields) == 0 {
+		return nil, fmt.Errorf("fields are required")
+	}
+	if step.Epsilon <= 0 {
+		return nil, fmt.Errorf("epsilon must be positive")
+	}
+	sensitivity := step.Sensitivity
+	if sensitivity == 0 {
+		sensitivity = 1
+	}
+	if sensitivity < 0 {
+		return nil, fmt.Errorf("sensitivity must be positive")
+	}
+	return &noiser{fields: step.Fields, scale: sensitivity / step.Epsilon, random: random}, nil
+}
+
+func (n *noiser) Anonymize(rows []map[string]interface{}) []map[string]interface{} {
+	for _, row := range rows {
+		for _, field := range n.fields {
+			number, ok := numericValue(row[field])
+			if !ok {
+				continue
+			}
+			noisy := number + n.laplace()
+			if number == math.Trunc(number) {
+				noisy = math.Max(0, math.Round(noisy))
+			}
+			row[field] = noisy
+		}
+	}
+	return rows
+}
+
+// laplace samples Laplace(0, scale) by inverting its CDF
+func (n *noiser) laplace() float64 {
+	u := n.random() - 0.5
+	sign := 1.0
+	if u < 0 {
+		sign = -1
+	}
+	return -n.scale * sign * math.Log(1-2*math.Abs(u))
+}
+
+// anonymizationRows converts typed export rows to the generic rows
+// anonymizers work on
+func anonymizationRows(value interface{}) ([]map[string]interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
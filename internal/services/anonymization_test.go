@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestAnonymizationService_AppliesProfileSteps(t *testing.T) {
+	service := NewAnonymizationService()
+	service.random = func() float64 { return 0.9 }
+	err := service.SetProfile(&AnonymizationProfile{
+		ExportType: ExportTypeVerificationReport,
+		Steps: []AnonymizationStep{
+			{Type: AnonymizeGeneralize, Fields: []string{"day"}, Granularity: "month"},
+			{Type: AnonymizeGeneralize, Fields: []string{"avg_confidence"}, Bucket: 0.25},
+			{Type: AnonymizeSuppress, Fields: []string{"dp_id"}},
+			{Type: AnonymizeSuppressSmallGroups, CountField: "verifications", MinGroupSize: 5},
+			{Type: AnonymizeNoise, Fields: []string{"verifications"}, Epsilon: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected the profile to be valid, got %v", err)
+	}
+
+	rows := []map[string]interface{}{
+		{"day": "2025-08-02", "dp_id": "dp_1", "verifications": 10.0, "avg_confidence": 0.93},
+		{"day": "2025-08-03", "dp_id": "dp_2", "verifications": 2.0, "avg_confidence": 0.5},
+	}
+	anonymized, manifest, err := service.Anonymize(ExportTypeVerificationReport, rows)
+	if err != nil {
+		t.Fatalf("Expected anonymization to succeed, got %v", err)
+	}
+
+	if len(anonymized) != 1 || manifest.RowsIn != 2 || manifest.RowsOut != 1 || manifest.RowsSuppressed != 1 {
+		t.Fatalf("Expected the small group suppressed, got %v and %+v", anonymized, manifest)
+	}
+	row := anonymized[0]
+	if row["day"] != "2025-08" || row["avg_confidence"] != 0.75 {
+		t.Errorf("Expected the day and confidence generalized, got %v", row)
+	}
+	if _, exists := row["dp_id"]; exists {
+		t.Errorf("Expected dp_id suppressed, got %v", row)
+	}
+	// Laplace(0, 1) at u=0.9 is ln(5), so the count rounds to 12
+	if row["verifications"] != 12.0 {
+		t.Errorf("Expected a noisy whole count, got %v", row["verifications"])
+	}
+	if len(manifest.Steps) != 5 || manifest.Steps[4].Epsilon != 1 {
+		t.Errorf("Expected the manifest to record the steps and their parameters, got %+v", manifest.Steps)
+	}
+}
+
+func TestAnonymizationService_GeneralizesTimestampsAndGroups(t *testing.T) {
+	service := NewAnonymizationService()
+	err := service.SetProfile(&AnonymizationProfile{
+		ExportType: ExportTypeVerificationRecords,
+		Steps: []AnonymizationStep{
+			{Type: AnonymizeGeneralize, Fields: []string{"created_at"}, Granularity: "day"},
+			{Type: AnonymizeSuppressSmallGroups, Fields: []string{"claim_type", "created_at"}, MinGroupSize: 2},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected the profile to be valid, got %v", err)
+	}
+
+	rows := []map[string]interface{}{
+		{"claim_type": "age_verification", "created_at": "2025-08-02T07:15:00Z"},
+		{"claim_type": "age_verification", "created_at": "2025-08-02T19:40:00Z"},
+		{"claim_type": "student_verification", "created_at": "2025-08-02T08:00:00Z"},
+	}
+	anonymized, _, err := service.Anonymize(ExportTypeVerificationRecords, rows)
+	if err != nil {
+		t.Fatalf("Expected anonymization to succeed, got %v", err)
+	}
+	if len(anonymized) != 2 || anonymized[0]["created_at"] != "2025-08-02" || anonymized[1]["claim_type"] != "age_verification" {
+		t.Errorf("Expected the single student verification suppressed, got %v", anonymized)
+	}
+
+	passthrough, manifest, err := service.Anonymize("other", []map[string]interface{}{{"a": 1}})
+	if err != nil || len(passthrough) != 1 || len(manifest.Steps) != 0 {
+		t.Errorf("Expected an export type without a profile to pass through, got %v, %+v, %v", passthrough, manifest, err)
+	}
+}
+
+func TestAnonymizationService_RejectsInvalidProfiles(t *testing.T) {
+	service := NewAnonymizationService()
+	for _, step := range []AnonymizationStep{
+		{Type: "shuffle", Fields: []string{"day"}},
+		{Type: AnonymizeGeneralize, Fields: []string{"day"}, Granularity: "week"},
+		{Type: AnonymizeSuppress},
+		{Type: AnonymizeSuppressSmallGroups, Fields: []string{"day"}, MinGroupSize: 1},
+		{Type: AnonymizeNoise, Fields: []string{"verifications"}},
+	} {
+		profile := &AnonymizationProfile{ExportType: ExportTypeVerificationReport, Steps: []AnonymizationStep{step}}
+		if err := service.SetProfile(profile); err == nil {
+			t.Errorf("Expected step %+v to be rejected", step)
+		}
+	}
+}
+
+func TestAnonymizationService_RegisteredAnonymizer(t *testing.T) {
+	RegisterAnonymizer("test_drop_all", func(step AnonymizationStep, _ func() float64) (Anonymizer, error) {
+		return &smallGroupSuppressor{countField: "missing", minGroupSize: 2}, nil
+	})
+	service := NewAnonymizationService()
+	profile := &AnonymizationProfile{ExportType: ExportTypeVerificationReport, Steps: []AnonymizationStep{{Type: "test_drop_all"}}}
+	if err := service.SetProfile(profile); err != nil {
+		t.Fatalf("Expected the registered step type to be accepted, got %v", err)
+	}
+	rows, _, _ := service.Anonymize(ExportTypeVerificationReport, []map[string]interface{}{{"day": "2025-08-02"}})
+	if len(rows) != 0 {
+		t.Errorf("Expected the registered anonymizer to run, got %v", rows)
+	}
+}
+
+func TestAnonymizationService_UnloadableFileBlocksExports(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anonymization.json")
+	os.WriteFile(path, []byte(`{"profiles": [{"export_type": "verification_report", "steps": [{"type": "noise", "fields": ["verifications"]}]}]}`), 0600)
+
+	service := NewAnonymizationService()
+	if err := service.LoadFile(path); err == nil {
+		t.Fatal("Expected a noise step without epsilon to fail loading")
+	}
+	if _, _, err := service.Anonymize(ExportTypeVerificationReport, nil); err == nil {
+		t.Error("Expected exports to be refused after a failed load")
+	}
+
+	os.WriteFile(path, []byte(`{"profiles": [{"export_type": "verification_report", "steps": [{"type": "noise", "fields": ["verifications"], "epsilon": 0.5}]}]}`), 0600)
+	if err := service.LoadFile(path); err != nil {
+		t.Fatalf("Expected the corrected file to load, got %v", err)
+	}
+	if _, _, err := service.Anonymize(ExportTypeVerificationReport, nil); err != nil {
+		t.Errorf("Expected exports to resume, got %v", err)
+	}
+	if profiles := service.Profiles(); len(profiles) != 1 || profiles[0].Steps[0].Epsilon != 0.5 {
+		t.Errorf("Expected the loaded profile, got %+v", profiles)
+	}
+}
+
+func TestExportService_AnonymizesArchive(t *testing.T) {
+	service, store := newTestExportService(t)
+	anonymization := NewAnonymizationService()
+	anonymization.SetProfile(&AnonymizationProfile{
+		ExportType: ExportTypeVerificationRecords,
+		Steps: []AnonymizationStep{
+			{Type: AnonymizeSuppress, Fields: []string{"request_id", "audit_reference"}},
+			{Type: AnonymizeGeneralize, Fields: []string{"created_at"}, Granularity: "month"},
+		},
+	})
+	service.SetAnonymization(anonymization)
+
+	created := time.Date(2025, 8, 2, 7, 0, 0, 0, time.UTC)
+	store.Append(&VerificationRecord{VerificationID: "v1", RPID: "rp_1", UserID: "user_1", RequestID: "req_1", ClaimType: "age_verification", Status: "verified", CreatedAt: created})
+	job := &ExportJob{ExportID: "exp_1", RPID: "rp_1", From: created.Add(-time.Hour), To: created.Add(time.Hour), RedactionLevel: RedactionStandard}
+
+	data, key, count, err := service.buildArchive(job)
+	if err != nil || count != 1 {
+		t.Fatalf("Expected one archived record, got %d (%v)", count, err)
+	}
+	archive, err := DecryptExportArchive(key, data, job.ExportID)
+	if err != nil {
+		t.Fatalf("Failed to decrypt archive: %v", err)
+	}
+	record := archive.Records[0]
+	if _, exists := record["request_id"]; exists || record["created_at"] != "2025-08" {
+		t.Errorf("Expected the record anonymized, got %v", record)
+	}
+	if manifest := archive.Manifest.Anonymization; manifest == nil || manifest.ExportType != ExportTypeVerificationRecords || len(manifest.Steps) != 2 {
+		t.Errorf("Expected the anonymization recorded in the manifest, got %+v", manifest)
+	}
+
+	anonymization.LoadFile(filepath.Join(t.TempDir(), "missing.json"))
+	if _, _, _, err := service.buildArchive(job); err == nil {
+		t.Error("Expected the export to fail while anonymization is not configured")
+	}
+}
+
+func TestReportingService_AnonymizedRows(t *testing.T) {
+	reporting := NewReportingService(&config.Config{})
+	anonymization := NewAnonymizationService()
+	anonymization.SetProfile(&AnonymizationProfile{
+		ExportType: ExportTypeVerificationReport,
+		Steps:      []AnonymizationStep{{Type: AnonymizeSuppressSmallGroups, CountField: "verifications", MinGroupSize: 2}},
+	})
+	reporting.SetAnonymization(anonymization)
+
+	now := time.Now().UTC()
+	for _, dpID := range []string{"dp_1", "dp_1", "dp_2"} {
+		reporting.Observe(&VerificationRecord{ClaimType: "age_verification", DPID: dpID, Status: "completed", CreatedAt: now})
+	}
+	reporting.Flush(context.Background())
+
+	rows, manifest, err := reporting.AnonymizedRows("", "")
+	if err != nil {
+		t.Fatalf("Expected the report to be anonymized, got %v", err)
+	}
+	if len(rows) != 1 || rows[0]["dp_id"] != "dp_1" || manifest.RowsSuppressed != 1 {
+		t.Errorf("Expected the single dp_2 verification suppressed, got %v and %+v", rows, manifest)
+	}
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Feedback severities for draft validation
+const (
+	FeedbackError   = "error"   // the request would be rejected
+	FeedbackWarning = "warning" // the request is accepted but likely not what was intended
+)
+
+// consoleIdentifierKeys are the identifier keys accepted on verification requests
+var consoleIdentifierKeys = []string{"address", "email", "license", "name", "passport", "phone", "ssn"}
+
+// consoleExampleIdentifiers are the sample values used in example requests
+var consoleExampleIdentifiers = map[string]string{
+	"email":    "jane.doe@example.edu",
+	"name":     "Jane Doe",
+	"phone":    "+1-555-0100",
+	"address":  "1 Example Street, Springfield",
+	"ssn":      "000-00-0000",
+	"passport": "X0000000",
+	"license":  "D0000000",
+}
+
+// FieldAnnotation explains one field of an example request or response
+type FieldAnnotation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+	Constraints string `json:"constraints,omitempty"`
+}
+
+// AnnotatedExample is an example body with an explanation of each field
+type AnnotatedExample struct {
+	Body        map[string]interface{} `json:"body"`
+	Annotations []FieldAnnotation      `json:"annotations"`
+}
+
+// ConsoleExample is the example request and response for one claim type
+type ConsoleExample struct {
+	ClaimType         string                     `json:"claim_type"`
+	Description       string                     `json:"description"`
+	Endpoint          string                     `json:"endpoint"`
+	Request           AnnotatedExample           `json:"request"`
+	Response          AnnotatedExample           `json:"response"`
+	DisclosureOptions map[string]DisclosureLevel `json:"disclosure_options"`
+}
+
+// FieldFeedback is a single finding about a field of a draft request
+type FieldFeedback struct {
+	Field      string `json:"field"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// DraftValidation is the result of validating a draft verification request
+type DraftValidation struct {
+	Valid     bool            `json:"valid"`
+	ClaimType string          `json:"claim_type,omitempty"`
+	Feedback  []FieldFeedback `json:"feedback"`
+}
+
+// ConsoleService backs the sandbox developer console with annotated examples
+// and draft validation driven by the claim schema registry
+type ConsoleService struct {
+	schemas *ClaimSchemaRegistry
+}
+
+// NewConsoleService creates a new console service
+func NewConsoleService(schemas *ClaimSchemaRegistry) *ConsoleService {
+	return &ConsoleService{schemas: schemas}
+}
+
+// Examples returns an example for every registered claim type
+func (s *ConsoleService) Examples() []*ConsoleExample {
+	schemas := s.schemas.List()
+	examples := make([]*ConsoleExample, 0, len(schemas))
+	for _, schema := range schemas {
+		examples = append(examples, consoleExample(schema))
+	}
+	return examples
+}
+
+// Example returns the example for a claim type
+func (s *ConsoleService) Example(claimType string) (*ConsoleExample, error) {
+	schema, exists := s.schemas.Get(claimType)
+	if !exists {
+		return nil, fmt.Errorf("unknown claim type: %s", claimType)
+	}
+	return consoleExample(schema), nil
+}
+
+// consoleExample builds the annotated request and response for a schema
+func consoleExample(schema *ClaimTypeSchema) *ConsoleExample {
+	identifiers := make(map[string]interface{})
+	for _, key := range schema.RequiredIdentifiers {
+		identifiers[key] = consoleExampleIdentifiers[key]
+	}
+
+	request := AnnotatedExample{
+		Body: map[string]interface{}{
+			"rp_id":       "rp_example",
+			"user_id":     "user_123",
+			"claim_type":  schema.ClaimType,
+			"identifiers": identifiers,
+		},
+		Annotations: []FieldAnnotation{
+			{Field: "rp_id", Description: "Your relying party identifier", Required: true, Constraints: "1-100 characters"},
+			{Field: "user_id", Description: "Your identifier for the user being verified", Required: true, Constraints: "1-100 characters"},
+			{Field: "claim_type", Description: schema.Description, Required: true, Constraints: "one of the registered claim types"},
+			{Field: "identifiers", Description: "Identifiers used to match the user at the DP; they are hashed before leaving the broker", Required: true, Constraints: "object of string values"},
+		},
+	}
+	for _, key := range schema.RequiredIdentifiers {
+		request.Annotations = append(request.Annotations, FieldAnnotation{
+			Field:       "identifiers." + key,
+			Description: fmt.Sprintf("The user's %s", key),
+			Required:    true,
+		})
+	}
+	for _, key := range schema.OptionalIdentifiers {
+		request.Annotations = append(request.Annotations, FieldAnnotation{
+			Field:       "identifiers." + key,
+			Description: fmt.Sprintf("The user's %s; improves match confidence", key),
+		})
+	}
+	request.Annotations = append(request.Annotations, FieldAnnotation{
+		Field:       "metadata",
+		Description: "Free-form context recorded with the verification",
+		Constraints: "object",
+	})
+
+	response := AnnotatedExample{
+		Body: map[string]interface{}{
+			"verification_id":  "3f2b8c1e-0000-4000-8000-000000000000",
+			"status":           "verified",
+			"verified":         true,
+			"confidence_score": 0.95,
+			"dp_id":            "dp_" + schema.DPCategory,
+			"attestation":      "eyJhbGciOiJSUzI1NiJ9...",
+			"timestamp":        "2024-01-01T00:00:00Z",
+			"expires_at":       "2024-03-31T00:00:00Z",
+			"request_id":       "req_123",
+		},
+		Annotations: []FieldAnnotation{
+			{Field: "verification_id", Description: "Unique identifier of this verification", Required: true},
+			{Field: "status", Description: "Outcome of the verification", Required: true, Constraints: "verified, not_found or error"},
+			{Field: "verified", Description: "Whether the claim holds for the user", Required: true},
+			{Field: "confidence_score", Description: "Confidence of the identifier match", Required: true, Constraints: "0-1"},
+			{Field: "dp_id", Description: "The DP that answered the request", Required: true},
+			{Field: "attestation", Description: "JWS signed by the broker over the result"},
+			{Field: "timestamp", Description: "When the verification was made", Required: true, Constraints: "RFC 3339"},
+			{Field: "expires_at", Description: "When the result should no longer be relied on", Required: true, Constraints: "RFC 3339"},
+			{Field: "request_id", Description: "Identifier to quote when contacting support", Required: true},
+		},
+	}
+
+	return &ConsoleExample{
+		ClaimType:         schema.ClaimType,
+		Description:       schema.Description,
+		Endpoint:          "POST /api/v1/verify",
+		Request:           request,
+		Response:          response,
+		DisclosureOptions: schema.Attributes,
+	}
+}
+
+// ValidateDraft checks a draft verification request against the schema
+// registry and returns feedback for every field that needs attention. The
+// draft is valid when no feedback has error severity.
+func (s *ConsoleService) ValidateDraft(draft map[string]interface{}) *DraftValidation {
+	result := &DraftValidation{Feedback: make([]FieldFeedback, 0)}
+	addError := func(field, message, suggestion string) {
+		result.Feedback = append(result.Feedback, FieldFeedback{Field: field, Severity: FeedbackError, Message: message, Suggestion: suggestion})
+	}
+	addWarning := func(field, message, suggestion string) {
+		result.Feedback = append(result.Feedback, FieldFeedback{Field: field, Severity: FeedbackWarning, Message: message, Suggestion: suggestion})
+	}
+
+	for _, field := range []string{"rp_id", "user_id"} {
+		value, present := draft[field]
+		text, isString := value.(string)
+		switch {
+		case !present:
+			addError(field, "field is required", "")
+		case !isString:
+			addError(field, "must be a string", "")
+		case len(text) == 0 || len(text) > 100:
+			addError(field, "must be between 1 and 100 characters", "")
+		}
+	}
+
+	var schema *ClaimTypeSchema
+	known := make([]string, 0)
+	for _, registered := range s.schemas.List() {
+		known = append(known, registered.ClaimType)
+	}
+	switch claimType, isString := draft["claim_type"].(string); {
+	case draft["claim_type"] == nil:
+		addError("claim_type", "field is required", "one of: "+strings.Join(known, ", "))
+	case !isString:
+		addError("claim_type", "must be a string", "")
+	default:
+		registered, exists := s.schemas.Get(claimType)
+		if !exists {
+			addError("claim_type", fmt.Sprintf("unknown claim type %q", claimType), "one of: "+strings.Join(known, ", "))
+		} else {
+			schema = registered
+			result.ClaimType = claimType
+		}
+	}
+
+	s.validateDraftIdentifiers(draft["identifiers"], schema, addError, addWarning)
+
+	if metadata, present := draft["metadata"]; present && metadata != nil {
+		if _, ok := metadata.(map[string]interface{}); !ok {
+			addError("metadata", "must be an object", "")
+		}
+	}
+
+	unknown := make([]string, 0)
+	for field := range draft {
+		switch field {
+		case "rp_id", "user_id", "claim_type", "identifiers", "metadata":
+		default:
+			unknown = append(unknown, field)
+		}
+	}
+	sort.Strings(unknown)
+	for _, field := range unknown {
+		addWarning(field, "unknown field is ignored", "")
+	}
+
+	result.Valid = true
+	for _, feedback := range result.Feedback {
+		if feedback.Severity == FeedbackError {
+			result.Valid = false
+			break
+		}
+	}
+	return result
+}
+
+// validateDraftIdentifiers checks identifier keys and values and, when the
+// claim type is known, that the schema's required identifiers are present
+func (s *ConsoleService) validateDraftIdentifiers(value interface{}, schema *ClaimTypeSchema, addError, addWarning func(field, message, suggestion string)) {
+	if value == nil {
+		suggestion := ""
+		if schema != nil {
+			suggestion = "include: " + strings.Join(schema.RequiredIdentifiers, ", ")
+		}
+		addError("identifiers", "field is required", suggestion)
+		return
+	}
+	identifiers, ok := value.(map[string]interface{})
+	if !ok {
+		addError("identifiers", "must be an object of string values", "")
+		return
+	}
+	if len(identifiers) == 0 {
+		addError("identifiers", "at least one identifier is required", "")
+	}
+
+	valid := make(map[string]bool)
+	for _, key := range consoleIdentifierKeys {
+		valid[key] = true
+	}
+	keys := make([]string, 0, len(identifiers))
+	for key := range identifiers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	used := make(map[string]bool)
+	if schema != nil {
+		for _, key := range schema.RequiredIdentifiers {
+			used[key] = true
+		}
+		for _, key := range schema.OptionalIdentifiers {
+			used[key] = true
+		}
+	}
+
+	for _, key := range keys {
+		field := "identifiers." + key
+		if !valid[key] {
+			addError(field, fmt.Sprintf("invalid identifier key %q", key), "one of: "+strings.Join(consoleIdentifierKeys, ", "))
+			continue
+		}
+		if text, isString := identifiers[key].(string); !isString || text == "" {
+			addError(field, "must be a non-empty string", "")
+			continue
+		}
+		if schema != nil && !used[key] {
+			addWarning(field, fmt.Sprintf("not used for %s and will not improve the match", schema.ClaimType), "")
+		}
+	}
+
+	if schema == nil {
+		return
+	}
+	for _, key := range schema.RequiredIdentifiers {
+		if _, present := identifiers[key]; !present {
+			addError("identifiers."+key, fmt.Sprintf("required for %s", schema.ClaimType), fmt.Sprintf("add the user's %s", key))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package services
+
+import "testing"
+
+func feedbackFor(validation *DraftValidation, field string) *FieldFeedback {
+	for i := range validation.Feedback {
+		if validation.Feedback[i].Field == field {
+			return &validation.Feedback[i]
+		}
+	}
+	return nil
+}
+
+func TestConsoleService_Examples(t *testing.T) {
+	service := NewConsoleService(NewClaimSchemaRegistry())
+
+	examples := service.Examples()
+	if len(examples) != len(DefaultClaimSchemas()) {
+		t.Fatalf("Expected an example per claim type, got %d", len(examples))
+	}
+
+	example, err := service.Example("address_verification")
+	if err != nil {
+		t.Fatalf("Expected example, got %v", err)
+	}
+	identifiers := example.Request.Body["identifiers"].(map[string]interface{})
+	if identifiers["name"] == nil || identifiers["address"] == nil {
+		t.Errorf("Expected required identifiers in example, got %v", identifiers)
+	}
+	if validation := service.ValidateDraft(example.Request.Body); !validation.Valid {
+		t.Errorf("Expected example request to validate, got %+v", validation.Feedback)
+	}
+
+	if _, err := service.Example("unknown"); err == nil {
+		t.Error("Expected error for unknown claim type")
+	}
+}
+
+func TestConsoleService_ValidateDraft(t *testing.T) {
+	service := NewConsoleService(NewClaimSchemaRegistry())
+
+	t.Run("missing required identifier", func(t *testing.T) {
+		validation := service.ValidateDraft(map[string]interface{}{
+			"rp_id":       "rp_1",
+			"user_id":     "user_1",
+			"claim_type":  "address_verification",
+			"identifiers": map[string]interface{}{"name": "Jane Doe"},
+		})
+		if validation.Valid || validation.ClaimType != "address_verification" {
+			t.Errorf("Unexpected validation: %+v", validation)
+		}
+		if feedback := feedbackFor(validation, "identifiers.address"); feedback == nil || feedback.Severity != FeedbackError {
+			t.Errorf("Expected error on identifiers.address, got %+v", validation.Feedback)
+		}
+	})
+
+	t.Run("unknown claim type", func(t *testing.T) {
+		validation := service.ValidateDraft(map[string]interface{}{
+			"rp_id":       "rp_1",
+			"user_id":     "user_1",
+			"claim_type":  "credit_check",
+			"identifiers": map[string]interface{}{"email": "a@b.c"},
+		})
+		feedback := feedbackFor(validation, "claim_type")
+		if validation.Valid || feedback == nil || feedback.Suggestion == "" {
+			t.Errorf("Expected claim_type error with suggestion, got %+v", validation.Feedback)
+		}
+	})
+
+	t.Run("warnings only", func(t *testing.T) {
+		validation := service.ValidateDraft(map[string]interface{}{
+			"rp_id":       "rp_1",
+			"user_id":     "user_1",
+			"claim_type":  "student_verification",
+			"identifiers": map[string]interface{}{"email": "a@b.c", "passport": "X1"},
+			"callback":    "https://rp.example",
+		})
+		if !validation.Valid {
+			t.Errorf("Expected warnings not to invalidate the draft, got %+v", validation.Feedback)
+		}
+		for _, field := range []string{"identifiers.passport", "callback"} {
+			if feedback := feedbackFor(validation, field); feedback == nil || feedback.Severity != FeedbackWarning {
+				t.Errorf("Expected warning on %s, got %+v", field, validation.Feedback)
+			}
+		}
+	})
+
+	t.Run("malformed fields", func(t *testing.T) {
+		validation := service.ValidateDraft(map[string]interface{}{
+			"user_id":     42.0,
+			"claim_type":  "student_verification",
+			"identifiers": map[string]interface{}{"email": "a@b.c", "iban": "X"},
+			"metadata":    "note",
+		})
+		for _, field := range []string{"rp_id", "user_id", "identifiers.iban", "metadata"} {
+			if feedback := feedbackFor(validation, field); feedback == nil || feedback.Severity != FeedbackError {
+				t.Errorf("Expected error on %s, got %+v", field, validation.Feedback)
+			}
+		}
+	})
+}
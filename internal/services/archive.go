@@ -0,0 +1,403 @@
+package services
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// Archive container format. The version is bumped whenever the layout or the
+// canonicalization changes; verifiers keep supporting every older version.
+const (
+	ArchiveFormat           = "pavilion-archive"
+	ArchiveVersion          = 1
+	ArchiveHashAlgorithm    = "sha-256"
+	ArchiveCanonicalization = "json-sorted-keys"
+	ArchiveSignatureAlg     = "ES256"
+)
+
+// Kinds of archived segments
+const (
+	ArchiveSegmentAudit  = "audit"
+	ArchiveSegmentProofs = "proofs"
+)
+
+// Purposes of keys carried in an archive
+const (
+	ArchiveKeyPurposeSigning         = "archive_signing"
+	ArchiveKeyPurposeZKPVerification = "zkp_verification"
+	ArchiveKeyPurposeAttestation     = "attestation"
+)
+
+// ArchiveContainer is a self-describing archive of audit segments and proofs.
+// Everything needed to check it is inside: the manifest describes the layout
+// and digests, the keys carry the public parts used to sign the archive and
+// the archived material, and the signature covers the canonical manifest.
+type ArchiveContainer struct {
+	Format    string           `json:"format"`
+	Version   int              `json:"version"`
+	Manifest  ArchiveManifest  `json:"manifest"`
+	Segments  []ArchiveSegment `json:"segments"`
+	Signature ArchiveSignature `json:"signature"`
+}
+
+// ArchiveManifest describes an archive's contents and how to check them
+type ArchiveManifest struct {
+	ArchiveID        string               `json:"archive_id"`
+	Creator          string               `json:"creator"`
+	CreatedAt        time.Time            `json:"created_at"`
+	HashAlgorithm    string               `json:"hash_algorithm"`
+	Canonicalization string               `json:"canonicalization"`
+	Segments         []ArchiveSegmentInfo `json:"segments"`
+	Keys             []ArchiveKey         `json:"keys"`
+}
+
+// ArchiveSegmentInfo is the manifest entry of one segment. The Merkle root
+// is computed over the hashes of the segment's canonical records.
+type ArchiveSegmentInfo struct {
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	RecordCount int    `json:"record_count"`
+	MerkleRoot  string `json:"merkle_root"`
+}
+
+// ArchiveSegment holds the canonical records of one segment
+type ArchiveSegment struct {
+	Name    string            `json:"name"`
+	Records []json.RawMessage `json:"records"`
+}
+
+// ArchiveKey is the public part of a key, as base64 DER (SubjectPublicKeyInfo
+// for signing keys, the backend's encoding for ZKP verification keys)
+type ArchiveKey struct {
+	KeyID     string `json:"key_id"`
+	Purpose   string `json:"purpose"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"`
+}
+
+// ArchiveSignature signs the canonical manifest
+type ArchiveSignature struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+// ArchiveBuilder assembles an archive from audit entries and proofs
+type ArchiveBuilder struct {
+	manifest ArchiveManifest
+	segments []ArchiveSegment
+	signer   *ecdsa.PrivateKey
+	keyID    string
+}
+
+// NewArchiveBuilder starts an archive signed with an ECDSA P-256 key. The
+// public part of the key is added to the archive.
+func NewArchiveBuilder(archiveID, creator string, signer *ecdsa.PrivateKey, keyID string) (*ArchiveBuilder, error) {
+	if archiveID == "" {
+		return nil, fmt.Errorf("archive ID is required")
+	}
+	if signer == nil || keyID == "" {
+		return nil, fmt.Errorf("archive signing key and key ID are required")
+	}
+
+	builder := &ArchiveBuilder{
+		manifest: ArchiveManifest{
+			ArchiveID:        archiveID,
+			Creator:          creator,
+			HashAlgorithm:    ArchiveHashAlgorithm,
+			Canonicalization: ArchiveCanonicalization,
+			Segments:         make([]ArchiveSegmentInfo, 0),
+			Keys:             make([]ArchiveKey, 0),
+		},
+		segments: make([]ArchiveSegment, 0),
+		signer:   signer,
+		keyID:    keyID,
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&signer.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode archive signing key: %w", err)
+	}
+	builder.AddKey(ArchiveKey{
+		KeyID:     keyID,
+		Purpose:   ArchiveKeyPurposeSigning,
+		Algorithm: ArchiveSignatureAlg,
+		PublicKey: base64.StdEncoding.EncodeToString(publicKey),
+	})
+	return builder, nil
+}
+
+// AddKey adds the public part of a key the archived material depends on,
+// such as a ZKP verification key or the attestation signing key
+func (b *ArchiveBuilder) AddKey(key ArchiveKey) {
+	b.manifest.Keys = append(b.manifest.Keys, key)
+}
+
+// AddZKPVerificationKey adds the verification key of a proof type, so that
+// archived proofs of that type can be checked without the broker
+func (b *ArchiveBuilder) AddZKPVerificationKey(proofType, algorithm string, key []byte) {
+	b.AddKey(ArchiveKey{
+		KeyID:     "zkp:" + proofType,
+		Purpose:   ArchiveKeyPurposeZKPVerification,
+		Algorithm: algorithm,
+		PublicKey: base64.StdEncoding.EncodeToString(key),
+	})
+}
+
+// AddAuditSegment archives a segment of audit entries
+func (b *ArchiveBuilder) AddAuditSegment(name string, entries []*models.AuditEntry) error {
+	records := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		records = append(records, entry)
+	}
+	return b.addSegment(name, ArchiveSegmentAudit, records)
+}
+
+// AddProofSegment archives a segment of stored proofs
+func (b *ArchiveBuilder) AddProofSegment(name string, proofs []*StoredProof) error {
+	records := make([]interface{}, 0, len(proofs))
+	for _, proof := range proofs {
+		records = append(records, proof)
+	}
+	return b.addSegment(name, ArchiveSegmentProofs, records)
+}
+
+func (b *ArchiveBuilder) addSegment(name, kind string, records []interface{}) error {
+	if name == "" {
+		return fmt.Errorf("segment name is required")
+	}
+	for _, segment := range b.segments {
+		if segment.Name == name {
+			return fmt.Errorf("segment %s already exists", name)
+		}
+	}
+
+	segment := ArchiveSegment{Name: name, Records: make([]json.RawMessage, 0, len(records))}
+	for i, record := range records {
+		canonical, err := CanonicalJSON(record)
+		if err != nil {
+			return fmt.Errorf("segment %s record %d: %w", name, i, err)
+		}
+		segment.Records = append(segment.Records, canonical)
+	}
+
+	b.segments = append(b.segments, segment)
+	b.manifest.Segments = append(b.manifest.Segments, ArchiveSegmentInfo{
+		Name:        name,
+		Kind:        kind,
+		RecordCount: len(segment.Records),
+		MerkleRoot:  archiveMerkleRoot(segment.Records),
+	})
+	return nil
+}
+
+// Build signs the manifest and returns the encoded archive
+func (b *ArchiveBuilder) Build() ([]byte, error) {
+	b.manifest.CreatedAt = time.Now().UTC()
+
+	manifest, err := CanonicalJSON(b.manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	digest := sha256.Sum256(manifest)
+	signature, err := ecdsa.SignASN1(rand.Reader, b.signer, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign manifest: %w", err)
+	}
+
+	return json.MarshalIndent(ArchiveContainer{
+		Format:   ArchiveFormat,
+		Version:  ArchiveVersion,
+		Manifest: b.manifest,
+		Segments: b.segments,
+		Signature: ArchiveSignature{
+			KeyID:     b.keyID,
+			Algorithm: ArchiveSignatureAlg,
+			Value:     base64.StdEncoding.EncodeToString(signature),
+		},
+	}, "", "  ")
+}
+
+// ArchiveVerification is the result of checking an archive
+type ArchiveVerification struct {
+	ArchiveID string    `json:"archive_id"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// SignerFingerprint is the SHA-256 of the signing key's DER encoding, to
+	// compare with the fingerprint published when the archive was made
+	SignerFingerprint string               `json:"signer_fingerprint"`
+	Segments          []ArchiveSegmentInfo `json:"segments"`
+	RecordCount       int                  `json:"record_count"`
+}
+
+// VerifyArchive checks an archive using only its own contents: the manifest
+// signature, and every segment's record count and Merkle root. Callers
+// should compare the returned signer fingerprint with a trusted one.
+func VerifyArchive(data []byte) (*ArchiveVerification, error) {
+	var container ArchiveContainer
+	if err := json.Unmarshal(data, &container); err != nil {
+		return nil, fmt.Errorf("failed to parse archive: %w", err)
+	}
+	if container.Format != ArchiveFormat {
+		return nil, fmt.Errorf("not an archive: format %q", container.Format)
+	}
+	if container.Version < 1 || container.Version > ArchiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", container.Version)
+	}
+	manifest := container.Manifest
+	if manifest.HashAlgorithm != ArchiveHashAlgorithm || manifest.Canonicalization != ArchiveCanonicalization {
+		return nil, fmt.Errorf("unsupported hash algorithm or canonicalization")
+	}
+
+	fingerprint, err := verifyArchiveSignature(&container)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(container.Segments) != len(manifest.Segments) {
+		return nil, fmt.Errorf("archive has %d segments, manifest lists %d", len(container.Segments), len(manifest.Segments))
+	}
+	result := &ArchiveVerification{
+		ArchiveID:         manifest.ArchiveID,
+		Version:           container.Version,
+		CreatedAt:         manifest.CreatedAt,
+		SignerFingerprint: fingerprint,
+		Segments:          manifest.Segments,
+	}
+	for i, info := range manifest.Segments {
+		segment := container.Segments[i]
+		if segment.Name != info.Name {
+			return nil, fmt.Errorf("segment %d is %s, manifest lists %s", i, segment.Name, info.Name)
+		}
+		if len(segment.Records) != info.RecordCount {
+			return nil, fmt.Errorf("segment %s has %d records, manifest lists %d", info.Name, len(segment.Records), info.RecordCount)
+		}
+		// Records are hashed in canonical form, so reformatting the archive
+		// (e.g. pretty-printing) does not break it
+		records := make([]json.RawMessage, 0, len(segment.Records))
+		for j, record := range segment.Records {
+			canonical, err := CanonicalJSON(record)
+			if err != nil {
+				return nil, fmt.Errorf("segment %s record %d: %w", info.Name, j, err)
+			}
+			records = append(records, canonical)
+		}
+		if archiveMerkleRoot(records) != info.MerkleRoot {
+			return nil, fmt.Errorf("segment %s does not match its Merkle root", info.Name)
+		}
+		result.RecordCount += info.RecordCount
+	}
+
+	return result, nil
+}
+
+// verifyArchiveSignature checks the manifest signature with the signing key
+// carried in the archive and returns the key's fingerprint
+func verifyArchiveSignature(container *ArchiveContainer) (string, error) {
+	if container.Signature.Algorithm != ArchiveSignatureAlg {
+		return "", fmt.Errorf("unsupported signature algorithm %q", container.Signature.Algorithm)
+	}
+
+	var signingKey *ArchiveKey
+	for i, key := range container.Manifest.Keys {
+		if key.KeyID == container.Signature.KeyID && key.Purpose == ArchiveKeyPurposeSigning {
+			signingKey = &container.Manifest.Keys[i]
+			break
+		}
+	}
+	if signingKey == nil {
+		return "", fmt.Errorf("signing key %s is not in the archive", container.Signature.KeyID)
+	}
+
+	der, err := base64.StdEncoding.DecodeString(signingKey.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid signing key encoding: %w", err)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return "", fmt.Errorf("invalid signing key: %w", err)
+	}
+	publicKey, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return "", fmt.Errorf("signing key is not an ECDSA key")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(container.Signature.Value)
+	if err != nil {
+		return "", fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	manifest, err := CanonicalJSON(container.Manifest)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	digest := sha256.Sum256(manifest)
+	if !ecdsa.VerifyASN1(publicKey, digest[:], signature) {
+		return "", fmt.Errorf("manifest signature is invalid")
+	}
+
+	fingerprint := sha256.Sum256(der)
+	return hex.EncodeToString(fingerprint[:]), nil
+}
+
+// CanonicalJSON encodes a value as JSON with object keys sorted, no
+// insignificant whitespace and no HTML escaping, so the same record always
+// hashes the same way
+func CanonicalJSON(value interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(generic); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// archiveMerkleRoot computes the hex SHA-256 Merkle root of records. Leaves
+// and nodes are domain separated, and an odd node is carried up unchanged.
+func archiveMerkleRoot(records []json.RawMessage) string {
+	if len(records) == 0 {
+		empty := sha256.Sum256(nil)
+		return hex.EncodeToString(empty[:])
+	}
+
+	level := make([][]byte, 0, len(records))
+	for _, record := range records {
+		leaf := sha256.Sum256(append([]byte{0x00}, record...))
+		level = append(level, leaf[:])
+	}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			node := sha256.Sum256(append(append([]byte{0x01}, level[i]...), level[i+1]...))
+			next = append(next, node[:])
+		}
+		level = next
+	}
+	return hex.EncodeToString(level[0])
+}
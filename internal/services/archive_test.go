@@ -0,0 +1,132 @@
+package services
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func buildTestArchive(t *testing.T) []byte {
+	t.Helper()
+	builder, err := NewArchiveBuilder("archive-2026-q1", "https://broker.example", mustArchiveKey(t), "archive-1")
+	if err != nil {
+		t.Fatalf("NewArchiveBuilder failed: %v", err)
+	}
+	entries := []*models.AuditEntry{
+		{Timestamp: "2026-01-01T00:00:00Z", RequestID: "req-1", RPID: "rp-1", ClaimType: "age_verification", PrivacyHash: "h1", Status: "SUCCESS", Metadata: map[string]interface{}{"confidence": 0.95}},
+		{Timestamp: "2026-01-01T00:01:00Z", RequestID: "req-2", RPID: "rp-1", ClaimType: "age_verification", PrivacyHash: "h2", Status: "AUTHORIZATION_DENIED"},
+		{Timestamp: "2026-01-01T00:02:00Z", RequestID: "req-3", RPID: "rp-2", ClaimType: "student_verification", PrivacyHash: "h3", Status: "SUCCESS"},
+	}
+	if err := builder.AddAuditSegment("audit-0001", entries); err != nil {
+		t.Fatalf("AddAuditSegment failed: %v", err)
+	}
+	proofs := []*StoredProof{{
+		ZKPResponse: &ZKPResponse{ProofID: "proof-1", ProofType: "age_verification", Proof: "p"},
+		OwnerID:     "rp-1",
+		Status:      ProofStatusActive,
+		StoredAt:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}}
+	if err := builder.AddProofSegment("proofs-0001", proofs); err != nil {
+		t.Fatalf("AddProofSegment failed: %v", err)
+	}
+	builder.AddZKPVerificationKey("age_verification", "groth16-bn254", []byte("vk"))
+	if err := builder.AddAuditSegment("audit-0001", nil); err == nil {
+		t.Error("Expected duplicate segment names to be rejected")
+	}
+
+	data, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	return data
+}
+
+func TestVerifyArchive(t *testing.T) {
+	data := buildTestArchive(t)
+
+	result, err := VerifyArchive(data)
+	if err != nil {
+		t.Fatalf("VerifyArchive failed: %v", err)
+	}
+	if result.ArchiveID != "archive-2026-q1" || result.RecordCount != 4 || len(result.Segments) != 2 {
+		t.Errorf("Unexpected verification result: %+v", result)
+	}
+	if len(result.SignerFingerprint) != 64 {
+		t.Errorf("Expected a SHA-256 signer fingerprint, got %q", result.SignerFingerprint)
+	}
+
+	// Reformatting the archive does not affect verification
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if _, err := VerifyArchive(compact.Bytes()); err != nil {
+		t.Errorf("Expected a reformatted archive to verify, got %v", err)
+	}
+}
+
+func TestVerifyArchive_DetectsTampering(t *testing.T) {
+	data := buildTestArchive(t)
+
+	tamper := func(modify func(container *ArchiveContainer)) []byte {
+		var container ArchiveContainer
+		if err := json.Unmarshal(data, &container); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		modify(&container)
+		tampered, _ := json.Marshal(container)
+		return tampered
+	}
+
+	cases := map[string][]byte{
+		"modified record": tamper(func(c *ArchiveContainer) {
+			c.Segments[0].Records[1] = json.RawMessage(bytes.Replace(c.Segments[0].Records[1], []byte("AUTHORIZATION_DENIED"), []byte("SUCCESS"), 1))
+		}),
+		"removed record": tamper(func(c *ArchiveContainer) {
+			c.Segments[0].Records = c.Segments[0].Records[:2]
+		}),
+		"edited manifest": tamper(func(c *ArchiveContainer) {
+			c.Segments[0].Records = c.Segments[0].Records[:2]
+			c.Manifest.Segments[0].RecordCount = 2
+		}),
+		"replaced signing key": tamper(func(c *ArchiveContainer) {
+			other, _ := NewArchiveBuilder("x", "x", mustArchiveKey(t), "archive-1")
+			c.Manifest.Keys[0] = other.manifest.Keys[0]
+		}),
+		"future version": tamper(func(c *ArchiveContainer) {
+			c.Version = ArchiveVersion + 1
+		}),
+	}
+	for name, tampered := range cases {
+		if _, err := VerifyArchive(tampered); err == nil {
+			t.Errorf("%s: expected verification to fail", name)
+		}
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	a, _ := CanonicalJSON(map[string]interface{}{"b": 1, "a": "<x>", "c": 1.5e3})
+	b, _ := CanonicalJSON(json.RawMessage(`{ "c": 1500, "a": "<x>", "b": 1 }`))
+
+	if string(a) != `{"a":"<x>","b":1,"c":1500}` {
+		t.Errorf("Unexpected canonical form %s", a)
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("Expected equal canonical forms, got %s and %s", a, b)
+	}
+}
+
+func mustArchiveKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	return key
+}
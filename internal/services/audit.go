@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/pavilion-trust/core-broker/internal/config"
@@ -23,6 +24,16 @@ const (
 // AuditService handles audit logging with cryptographic integrity
 type AuditService struct {
 	config *config.Config
+	// Sensitive metadata is encrypted before entries are written; nil when
+	// no key provider is configured
+	encryptor *AuditFieldEncryptor
+	// redact drops sensitive metadata when encryption is required but failed
+	redact bool
+	// storage, when set, keeps the entries as well as the console log
+	storage AuditStore
+	// outbox, when set, commits entries to be written to storage by its
+	// dispatcher, with the state they describe
+	outbox *OutboxDispatcher
 }
 
 // AuditReference represents an audit reference for responses
@@ -35,14 +46,62 @@ type AuditReference struct {
 
 // NewAuditService creates a new audit service
 func NewAuditService(cfg *config.Config) *AuditService {
+	encryptor, err := NewAuditFieldEncryptor(cfg)
+	if err != nil {
+		fmt.Printf("AUDIT WARNING: %v; sensitive metadata will be redacted\n", err)
+	}
 	return &AuditService{
-		config: cfg,
+		config:    cfg,
+		encryptor: encryptor,
+		redact:    err != nil,
 	}
 }
 
 // LogVerification logs a verification request/response for audit purposes
 // Returns an audit reference that can be included in the response
 func (s *AuditService) LogVerification(ctx context.Context, req models.VerificationRequest, response *models.VerificationResponse, status string) *AuditReference {
+	entry, auditEntryID := s.verificationEntry(ctx, req, response, status)
+	s.logAuditEntry(entry)
+
+	// Create and return audit reference
+	return s.createAuditReference(entry, auditEntryID)
+}
+
+// LogVerificationFailure logs a failed verification with the problem
+// reported to the RP, so the entry records why the RP was refused
+func (s *AuditService) LogVerificationFailure(ctx context.Context, req models.VerificationRequest, status string, problem *models.Problem) *AuditReference {
+	entry, auditEntryID := s.verificationEntry(ctx, req, nil, status)
+	if problem != nil {
+		embedded := *problem
+		if embedded.RequestID == "" {
+			embedded.RequestID = entry.RequestID
+		}
+		entry.Problem = &embedded
+	}
+	s.logAuditEntry(entry)
+	return s.createAuditReference(entry, auditEntryID)
+}
+
+// CommitVerification logs a verification concluded by a pull job. With an
+// outbox the entry is committed in the same transaction as the job's state,
+// which records the entry's ID, so neither is stored without the other.
+func (s *AuditService) CommitVerification(ctx context.Context, req models.VerificationRequest, response *models.VerificationResponse, status string, job *JobStatus) *AuditReference {
+	entry, auditEntryID := s.verificationEntry(ctx, req, response, status)
+	if job != nil {
+		committed := *job
+		committed.Metadata = make(map[string]interface{}, len(job.Metadata)+1)
+		for key, value := range job.Metadata {
+			committed.Metadata[key] = value
+		}
+		committed.Metadata["audit_entry_id"] = auditEntryID
+		job = &committed
+	}
+	s.recordAuditEntry(entry, job)
+	return s.createAuditReference(entry, auditEntryID)
+}
+
+// verificationEntry builds the audit entry for a verification
+func (s *AuditService) verificationEntry(ctx context.Context, req models.VerificationRequest, response *models.VerificationResponse, status string) (*models.AuditEntry, string) {
 	// Generate audit entry ID
 	auditEntryID := s.generateAuditEntryID(req, response)
 
@@ -67,13 +126,7 @@ func (s *AuditService) LogVerification(ctx context.Context, req models.Verificat
 	// Add sequence number for audit trail ordering
 	entry.Metadata["sequence_number"] = s.getNextSequenceNumber()
 	entry.Metadata["audit_entry_id"] = auditEntryID
-
-	// TODO: Send to audit database
-	// For now, just log to console
-	s.logAuditEntry(entry)
-
-	// Create and return audit reference
-	return s.createAuditReference(entry, auditEntryID)
+	return entry, auditEntryID
 }
 
 // createAuditReference creates an audit reference for inclusion in responses
@@ -91,6 +144,75 @@ func (s *AuditService) createAuditReference(entry *models.AuditEntry, auditEntry
 	}
 }
 
+// isVerificationEntry reports whether an entry records a verification
+// rather than another audited event, which carry their own policy decision
+func isVerificationEntry(entry *models.AuditEntry) bool {
+	return (entry.PolicyDecision == "ALLOW" || entry.PolicyDecision == "DENY") && entry.Status != "POLICY_DECISION"
+}
+
+// VerificationEntries returns an RP's stored verification entries recorded
+// since a time, oldest first, at most limit of them when limit is positive
+func (s *AuditService) VerificationEntries(ctx context.Context, rpID string, since time.Time, limit int) ([]*models.AuditEntry, error) {
+	if s.storage == nil {
+		return nil, fmt.Errorf("audit storage is not configured")
+	}
+	entries, err := s.storage.ListAuditEntries(ctx, AuditEntryFilter{RPID: rpID, Since: since})
+	if err != nil {
+		return nil, err
+	}
+	verifications := make([]*models.AuditEntry, 0)
+	for _, entry := range entries {
+		if !isVerificationEntry(entry) {
+			continue
+		}
+		verifications = append(verifications, entry)
+		if limit > 0 && len(verifications) == limit {
+			break
+		}
+	}
+	return verifications, nil
+}
+
+// VerificationEntry returns the latest verification entry for one of an
+// RP's requests
+func (s *AuditService) VerificationEntry(ctx context.Context, rpID, requestID string) (*models.AuditEntry, error) {
+	entries, err := s.VerificationEntries(ctx, rpID, time.Time{}, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].RequestID == requestID {
+			return entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("request %s: %w", requestID, ErrStorageNotFound)
+}
+
+// AuditReferenceForRP returns the reference of one of an RP's stored audit
+// entries. Other RPs' entries are reported as not found.
+func (s *AuditService) AuditReferenceForRP(ctx context.Context, rpID, auditEntryID string) (*AuditReference, error) {
+	if s.storage == nil {
+		return nil, fmt.Errorf("audit storage is not configured")
+	}
+	entry, err := s.storage.GetAuditEntry(ctx, auditEntryID)
+	if err != nil {
+		return nil, fmt.Errorf("audit entry %s: %w", auditEntryID, err)
+	}
+	if entry.RPID != rpID {
+		return nil, fmt.Errorf("audit entry %s: %w", auditEntryID, ErrStorageNotFound)
+	}
+	return s.createAuditReference(entry, auditEntryID), nil
+}
+
+// AuditReferenceOf returns the reference of a stored audit entry
+func (s *AuditService) AuditReferenceOf(entry *models.AuditEntry) (*AuditReference, error) {
+	auditEntryID, err := auditEntryIDOf(entry)
+	if err != nil {
+		return nil, err
+	}
+	return s.createAuditReference(entry, auditEntryID), nil
+}
+
 // generateAuditEntryID creates a unique audit entry ID
 func (s *AuditService) generateAuditEntryID(req models.VerificationRequest, _ *models.VerificationResponse) string {
 	// Create a unique ID based on request and timestamp
@@ -132,13 +254,19 @@ func (s *AuditService) ValidateAuditReference(reference *AuditReference) error {
 	return nil
 }
 
-// GetAuditReference retrieves an audit reference by entry ID
+// GetAuditReference retrieves an audit reference by entry ID. Without
+// storage a placeholder reference is returned.
 func (s *AuditService) GetAuditReference(auditEntryID string) (*AuditReference, error) {
-	// TODO: Retrieve from audit database
-	// For now, return a mock reference
 	if auditEntryID == "" {
 		return nil, fmt.Errorf("audit entry ID is empty")
 	}
+	if s.storage != nil {
+		entry, err := s.storage.GetAuditEntry(context.Background(), auditEntryID)
+		if err != nil {
+			return nil, fmt.Errorf("audit entry %s: %w", auditEntryID, err)
+		}
+		return s.createAuditReference(entry, auditEntryID), nil
+	}
 
 	return &AuditReference{
 		AuditEntryID: auditEntryID,
@@ -175,12 +303,149 @@ func (s *AuditService) generateMerkleProof(req models.VerificationRequest, respo
 	return hex.EncodeToString(hash[:])
 }
 
-// logAuditEntry logs an audit entry (placeholder for database storage)
+// logAuditEntry logs an audit entry to the console and, when configured,
+// to storage
 func (s *AuditService) logAuditEntry(entry *models.AuditEntry) {
-	// TODO: Store in audit database
-	// For now, just log to console
+	s.recordAuditEntry(entry, nil)
+}
+
+// recordAuditEntry logs an audit entry, committing it through the outbox
+// with a job's state when both are given. Should the outbox fail, the entry
+// is written to storage directly rather than lost.
+func (s *AuditService) recordAuditEntry(entry *models.AuditEntry, job *JobStatus) {
+	// Record which crypto profile produced the hashes and proofs
+	if entry.Metadata == nil {
+		entry.Metadata = make(map[string]interface{})
+	}
+	entry.Metadata["crypto_profile"] = ActiveCryptoProfile(s.config).Name
+	// Stored entries are keyed by ID, including those logged outside a
+	// verification
+	if _, exists := entry.Metadata["audit_entry_id"]; !exists && (s.storage != nil || s.outbox != nil) {
+		entry.Metadata["audit_entry_id"] = s.generateAuditEntryID(models.VerificationRequest{RPID: entry.RPID, ClaimType: entry.ClaimType}, nil)
+	}
+	s.protectMetadata(entry)
+
 	jsonData, _ := json.Marshal(entry)
 	fmt.Printf("AUDIT: %s\n", string(jsonData))
+
+	if s.outbox != nil {
+		message, err := NewOutboxMessage(OutboxAuditEntry, entry)
+		if err == nil {
+			err = s.outbox.Commit(context.Background(), job, message)
+		}
+		if err == nil {
+			return
+		}
+		fmt.Printf("AUDIT ERROR: outbox commit failed: %v\n", err)
+	}
+	if s.storage != nil {
+		if err := s.storage.AppendAuditEntry(context.Background(), entry); err != nil {
+			fmt.Printf("AUDIT ERROR: %v\n", err)
+		}
+	}
+}
+
+// SetStorage keeps audit entries in a store as well as the console log
+func (s *AuditService) SetStorage(storage AuditStore) {
+	s.storage = storage
+}
+
+// SetOutbox commits audit entries through an outbox, whose dispatcher
+// writes them to storage and retries until it succeeds
+func (s *AuditService) SetOutbox(outbox *OutboxDispatcher) {
+	s.outbox = outbox
+	outbox.Handle(OutboxAuditEntry, OutboxRetryPolicy{}, s.deliverOutboxEntry)
+}
+
+// deliverOutboxEntry writes an audit entry committed through the outbox
+func (s *AuditService) deliverOutboxEntry(ctx context.Context, message OutboxMessage) error {
+	if s.storage == nil {
+		return nil
+	}
+	var entry models.AuditEntry
+	if err := json.Unmarshal(message.Payload, &entry); err != nil {
+		return fmt.Errorf("failed to decode audit entry: %w", err)
+	}
+	return s.storage.AppendAuditEntry(ctx, &entry)
+}
+
+// protectMetadata encrypts the entry's sensitive metadata. If encryption is
+// configured but fails, those fields are dropped instead, so they are never
+// written in the clear.
+func (s *AuditService) protectMetadata(entry *models.AuditEntry) {
+	if s.encryptor == nil && !s.redact {
+		return
+	}
+
+	// Callers may still hold the metadata map
+	metadata := make(map[string]interface{}, len(entry.Metadata))
+	for key, value := range entry.Metadata {
+		metadata[key] = value
+	}
+	entry.Metadata = metadata
+
+	if s.encryptor != nil {
+		err := s.encryptor.EncryptEntry(entry)
+		if err == nil {
+			return
+		}
+		fmt.Printf("AUDIT WARNING: %v; sensitive metadata redacted\n", err)
+	}
+	sensitive := sensitiveAuditFields(s.config)
+	for key := range entry.Metadata {
+		if sensitive.contains(key) {
+			delete(entry.Metadata, key)
+		}
+	}
+}
+
+// DecryptAuditEntry returns a copy of an audit entry with its encrypted
+// metadata restored, recording who accessed it
+func (s *AuditService) DecryptAuditEntry(ctx context.Context, entry *models.AuditEntry, accessedBy string) (*models.AuditEntry, error) {
+	if s.encryptor == nil {
+		return nil, fmt.Errorf("audit encryption is not configured")
+	}
+	decrypted, err := s.encryptor.DecryptEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+	s.logAuditAccess(ctx, entry, accessedBy, "decrypt")
+	return decrypted, nil
+}
+
+// RewrapAuditEntry re-encrypts an audit entry's values under the current
+// master key, returning the entry and the number of values rewrapped
+func (s *AuditService) RewrapAuditEntry(entry *models.AuditEntry) (*models.AuditEntry, int, error) {
+	if s.encryptor == nil {
+		return nil, 0, fmt.Errorf("audit encryption is not configured")
+	}
+	return s.encryptor.RewrapEntry(entry)
+}
+
+// logAuditAccess records access to encrypted audit data
+func (s *AuditService) logAuditAccess(ctx context.Context, accessed *models.AuditEntry, accessedBy, action string) {
+	data := fmt.Sprintf("%s:%s:%s:%s", accessed.RequestID, accessed.Timestamp, accessedBy, action)
+	hash := sha256.Sum256([]byte(data))
+
+	entry := &models.AuditEntry{
+		Timestamp:      time.Now().Format(time.RFC3339),
+		RequestID:      getRequestID(ctx),
+		RPID:           accessed.RPID,
+		DPID:           accessed.DPID,
+		ClaimType:      accessed.ClaimType,
+		PrivacyHash:    hex.EncodeToString(hash[:]),
+		MerkleProof:    hex.EncodeToString(hash[:]),
+		PolicyDecision: "AUDIT_ACCESS",
+		Status:         action,
+		Metadata: map[string]interface{}{
+			"sequence_number":     s.getNextSequenceNumber(),
+			"accessed_by":         accessedBy,
+			"accessed_request_id": accessed.RequestID,
+			"accessed_timestamp":  accessed.Timestamp,
+		},
+	}
+
+	s.logAuditEntry(entry)
 }
 
 // getRequestID extracts request ID from context
@@ -229,6 +494,22 @@ func (s *AuditService) createAuditMetadata(req models.VerificationRequest, respo
 		metadata["dp_id"] = response.DPID
 		metadata["status"] = response.Status
 		metadata["processing_time"] = response.ProcessingTime
+
+		// A federated verification is chained to the peer's audit trail
+		if record, ok := response.Metadata["federation"].(*FederationRecord); ok {
+			metadata["federation_peer"] = record.PeerID
+			metadata["federation_network"] = record.Network
+			metadata["federation_peer_verification_id"] = record.PeerVerificationID
+			metadata["federation_peer_audit_reference"] = record.PeerAuditReference
+		}
+
+		// A consensus verification records every queried DP's answer
+		if consensus, ok := response.Metadata["consensus"].(*models.ConsensusResult); ok {
+			metadata["consensus_required"] = consensus.Required
+			metadata["consensus_agreed"] = consensus.Agreed
+			metadata["consensus_method"] = consensus.Method
+			metadata["consensus_providers"] = consensus.Providers
+		}
 	}
 
 	// Add request metadata
@@ -292,6 +573,173 @@ func (s *AuditService) LogPrivacyHash(ctx context.Context, req models.Verificati
 	s.logAuditEntry(entry)
 }
 
+// LogExportEvent logs an RP export lifecycle event
+func (s *AuditService) LogExportEvent(ctx context.Context, rpID string, status string, metadata map[string]interface{}) {
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	metadata["sequence_number"] = s.getNextSequenceNumber()
+
+	data := fmt.Sprintf("%s:%s:%v", rpID, status, metadata["export_id"])
+	hash := sha256.Sum256([]byte(data))
+
+	entry := &models.AuditEntry{
+		Timestamp:      time.Now().Format(time.RFC3339),
+		RequestID:      getRequestID(ctx),
+		RPID:           rpID,
+		ClaimType:      "export",
+		PrivacyHash:    hex.EncodeToString(hash[:]),
+		MerkleProof:    hex.EncodeToString(hash[:]),
+		PolicyDecision: "EXPORT",
+		Status:         status,
+		Metadata:       metadata,
+	}
+
+	s.logAuditEntry(entry)
+}
+
+// LogTokenExchange logs the exchange of an RP's token for a DP-scoped token,
+// with the subject and actor chain the downstream token carries
+func (s *AuditService) LogTokenExchange(ctx context.Context, record *TokenExchangeRecord) {
+	metadata := map[string]interface{}{
+		"sequence_number":   s.getNextSequenceNumber(),
+		"subject":           record.Subject,
+		"actor_chain":       record.ActorChain,
+		"scope":             record.Scope,
+		"issued_token_type": record.IssuedTokenType,
+		"expires_at":        record.ExpiresAt.Format(time.RFC3339),
+	}
+
+	data := fmt.Sprintf("%s:%s:%s:%s", record.RPID, record.DPID, record.ClaimType, record.Subject)
+	hash := sha256.Sum256([]byte(data))
+
+	entry := &models.AuditEntry{
+		Timestamp:      time.Now().Format(time.RFC3339),
+		RequestID:      getRequestID(ctx),
+		RPID:           record.RPID,
+		DPID:           record.DPID,
+		ClaimType:      record.ClaimType,
+		PrivacyHash:    hex.EncodeToString(hash[:]),
+		MerkleProof:    hex.EncodeToString(hash[:]),
+		PolicyDecision: "TOKEN_EXCHANGE",
+		Status:         "issued",
+		Metadata:       metadata,
+	}
+
+	s.logAuditEntry(entry)
+}
+
+// LogConfigChange logs a runtime configuration reload with the settings it
+// changed and their previous and new values
+func (s *AuditService) LogConfigChange(ctx context.Context, change config.RuntimeChange) {
+	previous, _ := json.Marshal(change.Previous)
+	current, _ := json.Marshal(change.Current)
+	var before, after map[string]interface{}
+	json.Unmarshal(previous, &before)
+	json.Unmarshal(current, &after)
+
+	changes := make(map[string]interface{}, len(change.Changed))
+	for _, name := range change.Changed {
+		changes[name] = map[string]interface{}{"previous": before[name], "current": after[name]}
+	}
+	metadata := map[string]interface{}{
+		"sequence_number": s.getNextSequenceNumber(),
+		"source":          change.Source,
+		"changed":         change.Changed,
+		"changes":         changes,
+	}
+
+	hash := sha256.Sum256(current)
+	entry := &models.AuditEntry{
+		Timestamp:      change.Timestamp.Format(time.RFC3339),
+		RequestID:      getRequestID(ctx),
+		ClaimType:      "config",
+		PrivacyHash:    hex.EncodeToString(hash[:]),
+		MerkleProof:    hex.EncodeToString(hash[:]),
+		PolicyDecision: "CONFIG_RELOAD",
+		Status:         "applied",
+		Metadata:       metadata,
+	}
+
+	s.logAuditEntry(entry)
+}
+
+// LogNotificationDelivery logs the outcome of delivering a notification to
+// an RP's webhook
+func (s *AuditService) LogNotificationDelivery(ctx context.Context, delivery *NotificationDelivery) {
+	metadata := map[string]interface{}{
+		"sequence_number": s.getNextSequenceNumber(),
+		"delivery_id":     delivery.ID,
+		"notification_id": delivery.NotificationID,
+		"subscription_id": delivery.SubscriptionID,
+		"event":           delivery.Event,
+		"url":             delivery.URL,
+		"attempts":        delivery.Attempts,
+	}
+	if delivery.StatusCode != 0 {
+		metadata["status_code"] = delivery.StatusCode
+	}
+	if delivery.Error != "" {
+		metadata["error"] = delivery.Error
+	}
+
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%s:%s", delivery.RPID, delivery.NotificationID, delivery.SubscriptionID)))
+	entry := &models.AuditEntry{
+		Timestamp:      time.Now().Format(time.RFC3339),
+		RequestID:      getRequestID(ctx),
+		RPID:           delivery.RPID,
+		ClaimType:      "notification",
+		PrivacyHash:    hex.EncodeToString(hash[:]),
+		MerkleProof:    hex.EncodeToString(hash[:]),
+		PolicyDecision: "NOTIFICATION",
+		Status:         delivery.Status,
+		Metadata:       metadata,
+	}
+
+	s.logAuditEntry(entry)
+}
+
+// LogAdminAction logs an operational action taken through the admin API, with
+// the operator who took it and its outcome
+func (s *AuditService) LogAdminAction(ctx context.Context, action, operator string, metadata map[string]interface{}) {
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	metadata["sequence_number"] = s.getNextSequenceNumber()
+	metadata["operator"] = operator
+
+	timestamp := time.Now().Format(time.RFC3339)
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%s:%s", action, operator, timestamp)))
+	entry := &models.AuditEntry{
+		Timestamp:      timestamp,
+		RequestID:      getRequestID(ctx),
+		ClaimType:      "admin",
+		PrivacyHash:    hex.EncodeToString(hash[:]),
+		MerkleProof:    hex.EncodeToString(hash[:]),
+		PolicyDecision: "ADMIN_ACTION",
+		Status:         action,
+		Metadata:       metadata,
+	}
+
+	s.logAuditEntry(entry)
+}
+
+// Flush syncs audit output to storage before shutdown. Entries are written
+// synchronously, so only stdout redirected to a file has anything to sync.
+func (s *AuditService) Flush(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	info, err := os.Stdout.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	if err := os.Stdout.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit output: %w", err)
+	}
+	return nil
+}
+
 // HealthCheck checks if the audit service is healthy
 func (s *AuditService) HealthCheck(ctx context.Context) error {
 	// Test privacy hash generation
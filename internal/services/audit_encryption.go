@@ -0,0 +1,177 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// defaultAuditEncryptedFields are the metadata fields that can identify a
+// user, plus everything the RP supplied with the request
+var defaultAuditEncryptedFields = []string{"user_id", "subject", "actor_chain", "request_*"}
+
+// auditEncryptedFieldsKey lists, in an entry's metadata, the fields holding
+// enc:v1 values
+const auditEncryptedFieldsKey = "encrypted_fields"
+
+// AuditFieldEncryptor envelope encrypts sensitive audit metadata. Each value
+// gets its own AES-256-GCM data key wrapped by the KeyProvider, so the master
+// key never leaves the provider and can be rotated by rewrapping.
+type AuditFieldEncryptor struct {
+	provider config.KeyProvider
+	keyID    string
+	fields   auditFieldSet
+}
+
+// auditFieldSet matches metadata field names; entries ending in "*" match
+// by prefix
+type auditFieldSet struct {
+	names    map[string]bool
+	prefixes []string
+}
+
+// sensitiveAuditFields returns the configured sensitive metadata fields
+func sensitiveAuditFields(cfg *config.Config) auditFieldSet {
+	fields := defaultAuditEncryptedFields
+	if cfg != nil && len(cfg.AuditEncryptedFields) > 0 {
+		fields = cfg.AuditEncryptedFields
+	}
+	set := auditFieldSet{names: make(map[string]bool)}
+	for _, field := range fields {
+		if prefix, found := strings.CutSuffix(field, "*"); found {
+			set.prefixes = append(set.prefixes, prefix)
+			continue
+		}
+		set.names[field] = true
+	}
+	return set
+}
+
+// contains reports whether a metadata field is in the set
+func (f auditFieldSet) contains(field string) bool {
+	if f.names[field] {
+		return true
+	}
+	for _, prefix := range f.prefixes {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAuditFieldEncryptor returns the configured encryptor, or nil when audit
+// encryption is disabled or no key provider is configured
+func NewAuditFieldEncryptor(cfg *config.Config) (*AuditFieldEncryptor, error) {
+	if cfg == nil || cfg.KeyProvider == nil || !cfg.AuditEncryption {
+		return nil, nil
+	}
+	if strings.Contains(cfg.AuditEncryptionKeyID, ":") {
+		return nil, fmt.Errorf("AUDIT_ENCRYPTION_KEY_ID must not contain ':'")
+	}
+
+	keyID := cfg.AuditEncryptionKeyID
+	if keyID == "" {
+		keyID = "default"
+	}
+	return &AuditFieldEncryptor{
+		provider: cfg.KeyProvider,
+		keyID:    keyID,
+		fields:   sensitiveAuditFields(cfg),
+	}, nil
+}
+
+// KeyID returns the master key new values are encrypted under
+func (e *AuditFieldEncryptor) KeyID() string {
+	return e.keyID
+}
+
+// EncryptEntry replaces the entry's sensitive metadata values with enc:v1
+// values of their JSON encoding and records which fields were encrypted
+func (e *AuditFieldEncryptor) EncryptEntry(entry *models.AuditEntry) error {
+	var encrypted []string
+	for field, value := range entry.Metadata {
+		if field == auditEncryptedFieldsKey || !e.fields.contains(field) || value == nil {
+			continue
+		}
+		if s, ok := value.(string); ok && (s == "" || config.IsEncryptedValue(s)) {
+			continue
+		}
+		plaintext, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit field %s: %w", field, err)
+		}
+		sealed, err := config.EncryptValue(e.provider, e.keyID, string(plaintext))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt audit field %s: %w", field, err)
+		}
+		entry.Metadata[field] = sealed
+		encrypted = append(encrypted, field)
+	}
+	if len(encrypted) > 0 {
+		sort.Strings(encrypted)
+		entry.Metadata[auditEncryptedFieldsKey] = encrypted
+	}
+	return nil
+}
+
+// DecryptEntry returns a copy of the entry with its encrypted metadata values
+// restored. The entry itself is left encrypted.
+func (e *AuditFieldEncryptor) DecryptEntry(entry *models.AuditEntry) (*models.AuditEntry, error) {
+	decrypted := *entry
+	decrypted.Metadata = make(map[string]interface{}, len(entry.Metadata))
+	for field, value := range entry.Metadata {
+		if field == auditEncryptedFieldsKey {
+			continue
+		}
+		s, ok := value.(string)
+		if !ok || !config.IsEncryptedValue(s) {
+			decrypted.Metadata[field] = value
+			continue
+		}
+		plaintext, err := config.DecryptValue(e.provider, s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt audit field %s: %w", field, err)
+		}
+		var restored interface{}
+		if err := json.Unmarshal([]byte(plaintext), &restored); err != nil {
+			return nil, fmt.Errorf("failed to decode audit field %s: %w", field, err)
+		}
+		decrypted.Metadata[field] = restored
+	}
+	return &decrypted, nil
+}
+
+// RewrapEntry returns a copy of the entry with values encrypted under a
+// previous master key encrypted again under the current one, and the number
+// of values rewrapped
+func (e *AuditFieldEncryptor) RewrapEntry(entry *models.AuditEntry) (*models.AuditEntry, int, error) {
+	rewrapped := *entry
+	rewrapped.Metadata = make(map[string]interface{}, len(entry.Metadata))
+	count := 0
+	for field, value := range entry.Metadata {
+		rewrapped.Metadata[field] = value
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if keyID, encrypted := config.EncryptedValueKeyID(s); !encrypted || keyID == e.keyID {
+			continue
+		}
+		plaintext, err := config.DecryptValue(e.provider, s)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to decrypt audit field %s: %w", field, err)
+		}
+		sealed, err := config.EncryptValue(e.provider, e.keyID, plaintext)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to encrypt audit field %s: %w", field, err)
+		}
+		rewrapped.Metadata[field] = sealed
+		count++
+	}
+	return &rewrapped, count, nil
+}
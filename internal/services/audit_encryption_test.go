@@ -0,0 +1,115 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func newAuditEncryptionConfig(t *testing.T, keyID string, keys map[string][]byte) *config.Config {
+	t.Helper()
+	provider, err := config.NewLocalKeyProvider(keys)
+	if err != nil {
+		t.Fatalf("NewLocalKeyProvider failed: %v", err)
+	}
+	return &config.Config{KeyProvider: provider, AuditEncryption: true, AuditEncryptionKeyID: keyID}
+}
+
+func TestAuditFieldEncryptor_RoundTrip(t *testing.T) {
+	cfg := newAuditEncryptionConfig(t, "k1", map[string][]byte{"k1": make([]byte, 32)})
+	encryptor, err := NewAuditFieldEncryptor(cfg)
+	if err != nil || encryptor == nil {
+		t.Fatalf("NewAuditFieldEncryptor failed: %v", err)
+	}
+
+	entry := &models.AuditEntry{RequestID: "req-1", Metadata: map[string]interface{}{
+		"user_id":        "user-42",
+		"request_source": map[string]interface{}{"channel": "web"},
+		"claim_type":     "age_verification",
+	}}
+	if err := encryptor.EncryptEntry(entry); err != nil {
+		t.Fatalf("EncryptEntry failed: %v", err)
+	}
+	for _, field := range []string{"user_id", "request_source"} {
+		if value, _ := entry.Metadata[field].(string); !strings.HasPrefix(value, "enc:v1:k1:") {
+			t.Errorf("Expected %s to be encrypted, got %v", field, entry.Metadata[field])
+		}
+	}
+	if entry.Metadata["claim_type"] != "age_verification" {
+		t.Error("Expected non-sensitive fields to stay in the clear")
+	}
+
+	decrypted, err := encryptor.DecryptEntry(entry)
+	if err != nil {
+		t.Fatalf("DecryptEntry failed: %v", err)
+	}
+	if decrypted.Metadata["user_id"] != "user-42" || decrypted.Metadata["request_source"].(map[string]interface{})["channel"] != "web" {
+		t.Errorf("Unexpected decrypted metadata: %v", decrypted.Metadata)
+	}
+	if _, listed := decrypted.Metadata[auditEncryptedFieldsKey]; listed {
+		t.Error("Expected the encrypted field list to be dropped on decryption")
+	}
+	if value, _ := entry.Metadata["user_id"].(string); !strings.HasPrefix(value, "enc:v1:") {
+		t.Error("Expected the original entry to stay encrypted")
+	}
+}
+
+func TestAuditFieldEncryptor_Rotation(t *testing.T) {
+	oldKey, newKey := make([]byte, 32), make([]byte, 32)
+	newKey[0] = 1
+	old, _ := NewAuditFieldEncryptor(newAuditEncryptionConfig(t, "k1", map[string][]byte{"k1": oldKey}))
+	entry := &models.AuditEntry{Metadata: map[string]interface{}{"user_id": "user-42"}}
+	if err := old.EncryptEntry(entry); err != nil {
+		t.Fatalf("EncryptEntry failed: %v", err)
+	}
+
+	rotated, _ := NewAuditFieldEncryptor(newAuditEncryptionConfig(t, "k2", map[string][]byte{"k1": oldKey, "k2": newKey}))
+	rewrapped, count, err := rotated.RewrapEntry(entry)
+	if err != nil || count != 1 {
+		t.Fatalf("Expected one value rewrapped, got %d, %v", count, err)
+	}
+	if value, _ := rewrapped.Metadata["user_id"].(string); !strings.HasPrefix(value, "enc:v1:k2:") {
+		t.Errorf("Expected the value under the new key, got %v", value)
+	}
+
+	// Once rewrapped, the retired key is no longer needed
+	current, _ := NewAuditFieldEncryptor(newAuditEncryptionConfig(t, "k2", map[string][]byte{"k2": newKey}))
+	decrypted, err := current.DecryptEntry(rewrapped)
+	if err != nil || decrypted.Metadata["user_id"] != "user-42" {
+		t.Errorf("Expected the rewrapped entry to decrypt, got %v, %v", decrypted, err)
+	}
+	if _, count, _ := current.RewrapEntry(rewrapped); count != 0 {
+		t.Error("Expected nothing to rewrap under the current key")
+	}
+}
+
+func TestAuditService_ProtectMetadata(t *testing.T) {
+	cfg := newAuditEncryptionConfig(t, "k1", map[string][]byte{"k1": make([]byte, 32)})
+	metadata := map[string]interface{}{"user_id": "user-42", "dp_id": "dp-1"}
+	entry := &models.AuditEntry{Metadata: metadata}
+	NewAuditService(cfg).protectMetadata(entry)
+	if value, _ := entry.Metadata["user_id"].(string); !strings.HasPrefix(value, "enc:v1:") {
+		t.Errorf("Expected user_id to be encrypted, got %v", entry.Metadata["user_id"])
+	}
+	if metadata["user_id"] != "user-42" {
+		t.Error("Expected the caller's metadata map to be left alone")
+	}
+
+	// A key ID that cannot be used drops sensitive fields rather than
+	// writing them in the clear
+	cfg.AuditEncryptionKeyID = "bad:id"
+	entry = &models.AuditEntry{Metadata: map[string]interface{}{"user_id": "user-42", "dp_id": "dp-1"}}
+	NewAuditService(cfg).protectMetadata(entry)
+	if _, present := entry.Metadata["user_id"]; present || entry.Metadata["dp_id"] != "dp-1" {
+		t.Errorf("Expected sensitive fields to be redacted, got %v", entry.Metadata)
+	}
+
+	// Without a key provider the metadata is written as before
+	entry = &models.AuditEntry{Metadata: map[string]interface{}{"user_id": "user-42"}}
+	NewAuditService(&config.Config{AuditEncryption: true}).protectMetadata(entry)
+	if entry.Metadata["user_id"] != "user-42" {
+		t.Error("Expected metadata to be unchanged without a key provider")
+	}
+}
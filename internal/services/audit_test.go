@@ -987,3 +987,23 @@ func TestAuditService_HealthCheck_Second(t *testing.T) {
 
 	assert.NoError(t, err)
 }
+
+func TestAuditService_LogVerificationFailure(t *testing.T) {
+	service := NewAuditService(&config.Config{})
+	store := NewMemoryStorage()
+	service.SetStorage(store)
+
+	req := models.VerificationRequest{RPID: "rp-1", UserID: "user-1", ClaimType: "age_verification"}
+	problem := models.NewProblem(models.ProblemPolicyDenied, "Claim type not allowed", 403)
+	ctx := context.WithValue(context.Background(), RequestIDKey, "req-1")
+
+	reference := service.LogVerificationFailure(ctx, req, "AUTHORIZATION_DENIED", problem)
+	require.NotNil(t, reference)
+
+	entry, err := store.GetAuditEntry(ctx, reference.AuditEntryID)
+	require.NoError(t, err)
+	assert.Equal(t, "AUTHORIZATION_DENIED", entry.Status)
+	require.NotNil(t, entry.Problem)
+	assert.Equal(t, models.ProblemPolicyDenied, entry.Problem.Code)
+	assert.Equal(t, "req-1", entry.Problem.RequestID)
+}
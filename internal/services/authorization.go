@@ -95,6 +95,14 @@ func (s *AuthorizationService) AuthorizeRequest(ctx context.Context, req models.
 	return decision, nil
 }
 
+// AllowsClaimType reports whether an RP's permission rules let it request a
+// claim type
+func (s *AuthorizationService) AllowsClaimType(rpID, claimType string) bool {
+	req := models.VerificationRequest{RPID: rpID, ClaimType: claimType}
+	decision := &AuthorizationDecision{Details: make(map[string]interface{})}
+	return s.checkRPPermissions(req, decision) == nil
+}
+
 // checkRPPermissions validates RP permissions for the request
 func (s *AuthorizationService) checkRPPermissions(req models.VerificationRequest, decision *AuthorizationDecision) error {
 	// Define RP permission rules
@@ -153,8 +161,12 @@ func (s *AuthorizationService) checkDPAccess(req models.VerificationRequest, dec
 	return nil
 }
 
-// getRPPermissionRules returns the RP permission rules
+// getRPPermissionRules returns the RP permission rules, from the runtime
+// settings when they are set
 func (s *AuthorizationService) getRPPermissionRules() []AuthorizationRule {
+	if rules := s.config.RuntimeSettings().RPPermissionRules; rules != nil {
+		return authorizationRules(rules)
+	}
 	return []AuthorizationRule{
 		{
 			ID:          "rp-student-verification",
@@ -191,8 +203,12 @@ func (s *AuthorizationService) getRPPermissionRules() []AuthorizationRule {
 	}
 }
 
-// getDPAccessRules returns the DP access rules
+// getDPAccessRules returns the DP access rules, from the runtime settings
+// when they are set
 func (s *AuthorizationService) getDPAccessRules() []AuthorizationRule {
+	if rules := s.config.RuntimeSettings().DPAccessRules; rules != nil {
+		return authorizationRules(rules)
+	}
 	return []AuthorizationRule{
 		{
 			ID:          "dp-university-access",
@@ -233,6 +249,24 @@ func (s *AuthorizationService) getDPAccessRules() []AuthorizationRule {
 	}
 }
 
+// authorizationRules converts rules from the runtime settings
+func authorizationRules(rules []config.PolicyRule) []AuthorizationRule {
+	converted := make([]AuthorizationRule, len(rules))
+	for i, rule := range rules {
+		converted[i] = AuthorizationRule{
+			ID:          rule.ID,
+			Name:        rule.Name,
+			Description: rule.Description,
+			RPID:        rule.RPID,
+			ClaimType:   rule.ClaimType,
+			DPID:        rule.DPID,
+			Action:      rule.Action,
+			Priority:    rule.Priority,
+		}
+	}
+	return converted
+}
+
 // logAuthorizationDecision logs the authorization decision
 func (s *AuthorizationService) logAuthorizationDecision(decision *AuthorizationDecision) {
 	// In a real implementation, this would log to an audit service
@@ -0,0 +1,534 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// Backup container format. As with archives, the version is bumped whenever
+// the layout or the canonicalization changes.
+const (
+	BackupFormat  = "pavilion-backup"
+	BackupVersion = 1
+)
+
+// Kinds of backup sections, besides the archive kinds used for audit and
+// proof segments
+const (
+	BackupSectionRegistry = "registry"
+	BackupSectionPolicies = "policies"
+	BackupSectionKeys     = "keys"
+)
+
+// BackupContainer holds the broker's durable state: the DP registry,
+// policies, retained audit and proof segments, and the public parts of its
+// keys. The manifest digest is the SHA-256 of the canonical manifest, which
+// lists every section's record count and Merkle root.
+type BackupContainer struct {
+	Format         string           `json:"format"`
+	Version        int              `json:"version"`
+	Manifest       BackupManifest   `json:"manifest"`
+	Sections       []ArchiveSegment `json:"sections"`
+	ManifestDigest string           `json:"manifest_digest"`
+}
+
+// BackupManifest describes a backup's contents and the retention applied
+type BackupManifest struct {
+	BackupID         string    `json:"backup_id"`
+	Creator          string    `json:"creator"`
+	CreatedAt        time.Time `json:"created_at"`
+	HashAlgorithm    string    `json:"hash_algorithm"`
+	Canonicalization string    `json:"canonicalization"`
+	// RetainedSince is the audit retention cutoff; older entries were left
+	// out. Zero when audit entries are kept indefinitely.
+	RetainedSince time.Time            `json:"retained_since"`
+	Sections      []ArchiveSegmentInfo `json:"sections"`
+	// Excluded counts the records of each section left out by retention
+	Excluded map[string]int `json:"excluded,omitempty"`
+}
+
+// BackupRetention decides which artifacts are still retained: audit entries
+// younger than AuditRetention and proofs that have not expired
+type BackupRetention struct {
+	// AuditRetention is how long audit entries are kept; zero keeps them all
+	AuditRetention time.Duration
+	// Now is when retention is checked; zero means the current time
+	Now time.Time
+}
+
+func (r BackupRetention) now() time.Time {
+	if r.Now.IsZero() {
+		return time.Now().UTC()
+	}
+	return r.Now
+}
+
+// cutoff returns the time before which audit entries have expired
+func (r BackupRetention) cutoff() time.Time {
+	if r.AuditRetention <= 0 {
+		return time.Time{}
+	}
+	return r.now().Add(-r.AuditRetention)
+}
+
+// retainsAudit reports whether an audit entry is within retention. Entries
+// whose timestamp cannot be read are kept rather than lost.
+func (r BackupRetention) retainsAudit(entry *models.AuditEntry) bool {
+	cutoff := r.cutoff()
+	if cutoff.IsZero() {
+		return true
+	}
+	timestamp, err := time.Parse(time.RFC3339, entry.Timestamp)
+	return err != nil || !timestamp.Before(cutoff)
+}
+
+// retainsProof reports whether a stored proof has not expired
+func (r BackupRetention) retainsProof(proof *StoredProof) bool {
+	return !r.now().After(proof.ExpiresAt)
+}
+
+// BackupBuilder assembles a backup, leaving out artifacts past retention
+type BackupBuilder struct {
+	manifest  BackupManifest
+	sections  []ArchiveSegment
+	keys      []ArchiveKey
+	retention BackupRetention
+}
+
+// NewBackupBuilder starts a backup that applies the given retention
+func NewBackupBuilder(backupID, creator string, retention BackupRetention) (*BackupBuilder, error) {
+	if backupID == "" {
+		return nil, fmt.Errorf("backup ID is required")
+	}
+	return &BackupBuilder{
+		manifest: BackupManifest{
+			BackupID:         backupID,
+			Creator:          creator,
+			HashAlgorithm:    ArchiveHashAlgorithm,
+			Canonicalization: ArchiveCanonicalization,
+			RetainedSince:    retention.cutoff(),
+			Sections:         make([]ArchiveSegmentInfo, 0),
+			Excluded:         make(map[string]int),
+		},
+		sections:  make([]ArchiveSegment, 0),
+		keys:      make([]ArchiveKey, 0),
+		retention: retention,
+	}, nil
+}
+
+// AddRegistry backs up a DP registry file as written on disk. Inline
+// plaintext secrets are dropped; enc:v1 values and secret: references are
+// kept, as are credentials read from environment variables or files.
+func (b *BackupBuilder) AddRegistry(data []byte) error {
+	var file DPRegistryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse DP registry file: %w", err)
+	}
+
+	records := make([]interface{}, 0, len(file.Providers))
+	for _, provider := range file.Providers {
+		if provider.Auth != nil {
+			for _, secret := range backupSecretFields(provider.Auth) {
+				if *secret != "" && !config.IsEncryptedValue(*secret) && !config.IsSecretRef(*secret) {
+					*secret = ""
+				}
+			}
+		}
+		records = append(records, provider)
+	}
+	return b.addSection(BackupSectionRegistry, BackupSectionRegistry, records, 0)
+}
+
+// backupSecretFields returns the auth fields that may hold inline secrets
+func backupSecretFields(auth *DPProviderAuth) []*string {
+	fields := []*string{&auth.APIKey, &auth.ClientSecret, &auth.JWTSecret, &auth.AWSSecretAccessKey}
+	for i := range auth.Credentials {
+		fields = append(fields, &auth.Credentials[i].Value)
+	}
+	return fields
+}
+
+// AddPolicies backs up verification policies
+func (b *BackupBuilder) AddPolicies(policies []*models.Policy) error {
+	records := make([]interface{}, 0, len(policies))
+	for _, policy := range policies {
+		records = append(records, policy)
+	}
+	return b.addSection(BackupSectionPolicies, BackupSectionPolicies, records, 0)
+}
+
+// AddArchive backs up the audit and proof segments of an archive, which must
+// verify first. Each segment becomes a section named <archive ID>/<segment>,
+// without the entries and proofs past retention; the archive's keys are
+// added to the backup's keys.
+func (b *BackupBuilder) AddArchive(data []byte) error {
+	verification, err := VerifyArchive(data)
+	if err != nil {
+		return err
+	}
+	var container ArchiveContainer
+	if err := json.Unmarshal(data, &container); err != nil {
+		return fmt.Errorf("failed to parse archive: %w", err)
+	}
+
+	for i, info := range container.Manifest.Segments {
+		name := verification.ArchiveID + "/" + info.Name
+		records, excluded, err := retainBackupRecords(info.Kind, container.Segments[i].Records, b.retention)
+		if err != nil {
+			return fmt.Errorf("archive %s segment %s: %w", verification.ArchiveID, info.Name, err)
+		}
+		if err := b.addSection(name, info.Kind, records, excluded); err != nil {
+			return err
+		}
+	}
+	for _, key := range container.Manifest.Keys {
+		b.AddKey(key)
+	}
+	return nil
+}
+
+// retainBackupRecords decodes audit or proof records and drops those past
+// retention, returning how many were dropped
+func retainBackupRecords(kind string, raw []json.RawMessage, retention BackupRetention) ([]interface{}, int, error) {
+	records := make([]interface{}, 0, len(raw))
+	excluded := 0
+	for j, record := range raw {
+		switch kind {
+		case ArchiveSegmentAudit:
+			var entry models.AuditEntry
+			if err := json.Unmarshal(record, &entry); err != nil {
+				return nil, 0, fmt.Errorf("record %d: %w", j, err)
+			}
+			if !retention.retainsAudit(&entry) {
+				excluded++
+				continue
+			}
+		case ArchiveSegmentProofs:
+			var proof StoredProof
+			if err := json.Unmarshal(record, &proof); err != nil {
+				return nil, 0, fmt.Errorf("record %d: %w", j, err)
+			}
+			if !retention.retainsProof(&proof) {
+				excluded++
+				continue
+			}
+		default:
+			return nil, 0, fmt.Errorf("unknown segment kind %q", kind)
+		}
+		records = append(records, record)
+	}
+	return records, excluded, nil
+}
+
+// AddKey adds the public part of a key. Keys are listed once per key ID and
+// purpose.
+func (b *BackupBuilder) AddKey(key ArchiveKey) {
+	for _, existing := range b.keys {
+		if existing.KeyID == key.KeyID && existing.Purpose == key.Purpose {
+			return
+		}
+	}
+	b.keys = append(b.keys, key)
+}
+
+func (b *BackupBuilder) addSection(name, kind string, records []interface{}, excluded int) error {
+	for _, section := range b.sections {
+		if section.Name == name {
+			return fmt.Errorf("section %s already exists", name)
+		}
+	}
+
+	section := ArchiveSegment{Name: name, Records: make([]json.RawMessage, 0, len(records))}
+	for i, record := range records {
+		canonical, err := CanonicalJSON(record)
+		if err != nil {
+			return fmt.Errorf("section %s record %d: %w", name, i, err)
+		}
+		section.Records = append(section.Records, canonical)
+	}
+
+	b.sections = append(b.sections, section)
+	b.manifest.Sections = append(b.manifest.Sections, ArchiveSegmentInfo{
+		Name:        name,
+		Kind:        kind,
+		RecordCount: len(section.Records),
+		MerkleRoot:  archiveMerkleRoot(section.Records),
+	})
+	if excluded > 0 {
+		b.manifest.Excluded[name] = excluded
+	}
+	return nil
+}
+
+// Build returns the encoded backup and its manifest digest, which should be
+// recorded apart from the backup so restores can check they have the backup
+// that was made
+func (b *BackupBuilder) Build() ([]byte, string, error) {
+	if len(b.keys) > 0 {
+		records := make([]interface{}, 0, len(b.keys))
+		for _, key := range b.keys {
+			records = append(records, key)
+		}
+		if err := b.addSection(BackupSectionKeys, BackupSectionKeys, records, 0); err != nil {
+			return nil, "", err
+		}
+	}
+	b.manifest.CreatedAt = time.Now().UTC()
+
+	digest, err := backupManifestDigest(b.manifest)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := json.MarshalIndent(BackupContainer{
+		Format:         BackupFormat,
+		Version:        BackupVersion,
+		Manifest:       b.manifest,
+		Sections:       b.sections,
+		ManifestDigest: digest,
+	}, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+	return data, digest, nil
+}
+
+// backupManifestDigest returns the hex SHA-256 of the canonical manifest
+func backupManifestDigest(manifest BackupManifest) (string, error) {
+	canonical, err := CanonicalJSON(manifest)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	digest := sha256.Sum256(canonical)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// VerifyBackup checks a backup's manifest digest, and every section's record
+// count and Merkle root. Callers should compare the manifest digest with the
+// one recorded when the backup was made.
+func VerifyBackup(data []byte) (*BackupContainer, error) {
+	var container BackupContainer
+	if err := json.Unmarshal(data, &container); err != nil {
+		return nil, fmt.Errorf("failed to parse backup: %w", err)
+	}
+	if container.Format != BackupFormat {
+		return nil, fmt.Errorf("not a backup: format %q", container.Format)
+	}
+	if container.Version < 1 || container.Version > BackupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", container.Version)
+	}
+	manifest := container.Manifest
+	if manifest.HashAlgorithm != ArchiveHashAlgorithm || manifest.Canonicalization != ArchiveCanonicalization {
+		return nil, fmt.Errorf("unsupported hash algorithm or canonicalization")
+	}
+
+	digest, err := backupManifestDigest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if digest != container.ManifestDigest {
+		return nil, fmt.Errorf("manifest does not match its digest")
+	}
+
+	if len(container.Sections) != len(manifest.Sections) {
+		return nil, fmt.Errorf("backup has %d sections, manifest lists %d", len(container.Sections), len(manifest.Sections))
+	}
+	for i, info := range manifest.Sections {
+		section := container.Sections[i]
+		if section.Name != info.Name {
+			return nil, fmt.Errorf("section %d is %s, manifest lists %s", i, section.Name, info.Name)
+		}
+		if len(section.Records) != info.RecordCount {
+			return nil, fmt.Errorf("section %s has %d records, manifest lists %d", info.Name, len(section.Records), info.RecordCount)
+		}
+		records := make([]json.RawMessage, 0, len(section.Records))
+		for j, record := range section.Records {
+			canonical, err := CanonicalJSON(record)
+			if err != nil {
+				return nil, fmt.Errorf("section %s record %d: %w", info.Name, j, err)
+			}
+			records = append(records, canonical)
+		}
+		if archiveMerkleRoot(records) != info.MerkleRoot {
+			return nil, fmt.Errorf("section %s does not match its Merkle root", info.Name)
+		}
+	}
+
+	return &container, nil
+}
+
+// BackupRestoreOptions selects what a restore writes. Sections without a
+// destination are verified but not restored.
+type BackupRestoreOptions struct {
+	// ExpectedDigest, when set, must match the backup's manifest digest
+	ExpectedDigest string
+	// RegistryFile receives the DP registry
+	RegistryFile string
+	// Policies receives the policies; existing policies are updated
+	Policies models.PolicyStorage
+	// Dir receives the audit and proof sections, and keys.json
+	Dir string
+	// Retention is applied again, so nothing that expired since the backup
+	// was made is restored
+	Retention BackupRetention
+}
+
+// BackupRestoreResult reports what a restore wrote
+type BackupRestoreResult struct {
+	BackupID       string         `json:"backup_id"`
+	ManifestDigest string         `json:"manifest_digest"`
+	Restored       map[string]int `json:"restored"`
+	// Expired counts the records past retention at restore time
+	Expired map[string]int `json:"expired,omitempty"`
+}
+
+// RestoreBackup verifies a backup and writes its sections to the selected
+// destinations. Every section is verified and decoded before anything is
+// written.
+func RestoreBackup(ctx context.Context, data []byte, opts BackupRestoreOptions) (*BackupRestoreResult, error) {
+	container, err := VerifyBackup(data)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ExpectedDigest != "" && !strings.EqualFold(opts.ExpectedDigest, container.ManifestDigest) {
+		return nil, fmt.Errorf("backup digest is %s, expected %s", container.ManifestDigest, opts.ExpectedDigest)
+	}
+
+	result := &BackupRestoreResult{
+		BackupID:       container.Manifest.BackupID,
+		ManifestDigest: container.ManifestDigest,
+		Restored:       make(map[string]int),
+		Expired:        make(map[string]int),
+	}
+	var registry *DPRegistryFile
+	var policies []*models.Policy
+	files := make(map[string][]interface{})
+	for i, info := range container.Manifest.Sections {
+		records := container.Sections[i].Records
+		switch info.Kind {
+		case BackupSectionRegistry:
+			registry = &DPRegistryFile{}
+			if err := decodeBackupRecords(records, &registry.Providers); err != nil {
+				return nil, fmt.Errorf("section %s: %w", info.Name, err)
+			}
+		case BackupSectionPolicies:
+			if err := decodeBackupRecords(records, &policies); err != nil {
+				return nil, fmt.Errorf("section %s: %w", info.Name, err)
+			}
+		case BackupSectionKeys:
+			var keys []ArchiveKey
+			if err := decodeBackupRecords(records, &keys); err != nil {
+				return nil, fmt.Errorf("section %s: %w", info.Name, err)
+			}
+			for _, key := range keys {
+				files["keys.json"] = append(files["keys.json"], key)
+			}
+		case ArchiveSegmentAudit, ArchiveSegmentProofs:
+			retained, expired, err := retainBackupRecords(info.Kind, records, opts.Retention)
+			if err != nil {
+				return nil, fmt.Errorf("section %s: %w", info.Name, err)
+			}
+			if expired > 0 {
+				result.Expired[info.Name] = expired
+			}
+			files[info.Kind+"/"+strings.ReplaceAll(info.Name, "/", "_")+".json"] = retained
+		default:
+			return nil, fmt.Errorf("section %s has unknown kind %q", info.Name, info.Kind)
+		}
+	}
+
+	if registry != nil && opts.RegistryFile != "" {
+		encoded, err := json.MarshalIndent(registry, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode DP registry: %w", err)
+		}
+		if err := writeBackupFile(opts.RegistryFile, encoded); err != nil {
+			return nil, err
+		}
+		result.Restored[BackupSectionRegistry] = len(registry.Providers)
+	}
+
+	if opts.Policies != nil {
+		for _, policy := range policies {
+			if _, err := opts.Policies.GetPolicy(ctx, policy.ID); err == nil {
+				err = opts.Policies.UpdatePolicy(ctx, policy)
+			} else {
+				err = opts.Policies.CreatePolicy(ctx, policy)
+			}
+			if err != nil {
+				return result, fmt.Errorf("failed to restore policy %s: %w", policy.ID, err)
+			}
+			result.Restored[BackupSectionPolicies]++
+		}
+	}
+
+	if opts.Dir != "" {
+		for name, records := range files {
+			encoded, err := json.MarshalIndent(records, "", "  ")
+			if err != nil {
+				return result, fmt.Errorf("failed to encode %s: %w", name, err)
+			}
+			if err := writeBackupFile(filepath.Join(opts.Dir, filepath.FromSlash(name)), encoded); err != nil {
+				return result, err
+			}
+			result.Restored[name] = len(records)
+		}
+	}
+
+	return result, nil
+}
+
+// decodeBackupRecords decodes a section's records into a slice
+func decodeBackupRecords(records []json.RawMessage, target interface{}) error {
+	encoded, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, target)
+}
+
+// writeBackupFile writes a restored file through a temporary file, so a
+// failed restore never leaves a partly written file behind
+func writeBackupFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// BackupKey returns the public part of the attestation key for backups and
+// archives
+func (s *JWSAttestationService) BackupKey() (ArchiveKey, error) {
+	if s.publicKey == nil {
+		return ArchiveKey{}, fmt.Errorf("public key not initialized")
+	}
+	der, err := x509.MarshalPKIXPublicKey(s.publicKey)
+	if err != nil {
+		return ArchiveKey{}, fmt.Errorf("failed to encode attestation key: %w", err)
+	}
+	return ArchiveKey{
+		KeyID:     s.keyID,
+		Purpose:   ArchiveKeyPurposeAttestation,
+		Algorithm: "RS256",
+		PublicKey: base64.StdEncoding.EncodeToString(der),
+	}, nil
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// memoryPolicyStorage keeps policies in a map; the other PolicyStorage
+// methods are not used by restores
+type memoryPolicyStorage struct {
+	models.PolicyStorage
+	policies map[string]*models.Policy
+}
+
+func (m *memoryPolicyStorage) GetPolicy(ctx context.Context, id string) (*models.Policy, error) {
+	if policy, ok := m.policies[id]; ok {
+		return policy, nil
+	}
+	return nil, fmt.Errorf("policy not found: %s", id)
+}
+
+func (m *memoryPolicyStorage) CreatePolicy(ctx context.Context, policy *models.Policy) error {
+	m.policies[policy.ID] = policy
+	return nil
+}
+
+func (m *memoryPolicyStorage) UpdatePolicy(ctx context.Context, policy *models.Policy) error {
+	m.policies[policy.ID] = policy
+	return nil
+}
+
+const testBackupRegistry = `{
+  "providers": [
+    {"dp_id": "dp_a", "url": "https://dp-a.example.com", "auth": {"method": "api_key", "api_key": "plaintext-key"}},
+    {"dp_id": "dp_b", "url": "https://dp-b.example.com", "auth": {"method": "oauth2", "client_secret": "enc:v1:default:abc:def", "api_key_env": "DP_B_KEY"}}
+  ]
+}`
+
+// buildTestBackup backs up the test archive 30 days and a minute after its
+// first entry, with 30 days of audit retention
+func buildTestBackup(t *testing.T) ([]byte, string, time.Time) {
+	t.Helper()
+	now := time.Date(2026, 1, 31, 0, 1, 0, 0, time.UTC)
+	builder, err := NewBackupBuilder("backup-1", "https://broker.example", BackupRetention{AuditRetention: 30 * 24 * time.Hour, Now: now})
+	if err != nil {
+		t.Fatalf("NewBackupBuilder failed: %v", err)
+	}
+	if err := builder.AddRegistry([]byte(testBackupRegistry)); err != nil {
+		t.Fatalf("AddRegistry failed: %v", err)
+	}
+	policies := []*models.Policy{{ID: "policy-1", Version: "1", Name: "Adults", Status: "active"}}
+	if err := builder.AddPolicies(policies); err != nil {
+		t.Fatalf("AddPolicies failed: %v", err)
+	}
+	if err := builder.AddArchive(buildTestArchive(t)); err != nil {
+		t.Fatalf("AddArchive failed: %v", err)
+	}
+
+	data, digest, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	return data, digest, now
+}
+
+func TestBackup_AppliesRetentionAndDropsSecrets(t *testing.T) {
+	data, digest, _ := buildTestBackup(t)
+
+	container, err := VerifyBackup(data)
+	if err != nil {
+		t.Fatalf("VerifyBackup failed: %v", err)
+	}
+	if container.ManifestDigest != digest || len(digest) != 64 {
+		t.Errorf("Expected the manifest digest, got %s", container.ManifestDigest)
+	}
+
+	counts := make(map[string]int)
+	for _, info := range container.Manifest.Sections {
+		counts[info.Name] = info.RecordCount
+	}
+	// req-1 is past retention and the proof has expired
+	if counts["archive-2026-q1/audit-0001"] != 2 || counts["archive-2026-q1/proofs-0001"] != 0 {
+		t.Errorf("Expected retention to be applied, got %v", counts)
+	}
+	if container.Manifest.Excluded["archive-2026-q1/audit-0001"] != 1 || container.Manifest.Excluded["archive-2026-q1/proofs-0001"] != 1 {
+		t.Errorf("Expected the excluded records counted, got %v", container.Manifest.Excluded)
+	}
+	// The archive signing key and ZKP verification key
+	if counts[BackupSectionKeys] != 2 || counts[BackupSectionRegistry] != 2 || counts[BackupSectionPolicies] != 1 {
+		t.Errorf("Unexpected sections: %v", counts)
+	}
+
+	if strings.Contains(string(data), "plaintext-key") {
+		t.Error("Expected inline plaintext secrets to be left out")
+	}
+	if !strings.Contains(string(data), "enc:v1:default:abc:def") || !strings.Contains(string(data), "DP_B_KEY") {
+		t.Error("Expected encrypted values and references to be kept")
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	data, digest, now := buildTestBackup(t)
+	dir := t.TempDir()
+	policies := &memoryPolicyStorage{policies: map[string]*models.Policy{
+		"policy-1": {ID: "policy-1", Name: "Old"},
+	}}
+
+	// Restored a minute later, req-2 has also passed retention
+	result, err := RestoreBackup(context.Background(), data, BackupRestoreOptions{
+		ExpectedDigest: digest,
+		RegistryFile:   filepath.Join(dir, "registry.json"),
+		Policies:       policies,
+		Dir:            dir,
+		Retention:      BackupRetention{AuditRetention: 30 * 24 * time.Hour, Now: now.Add(time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+	if result.Restored[BackupSectionRegistry] != 2 || result.Restored[BackupSectionPolicies] != 1 || result.Restored["keys.json"] != 2 {
+		t.Errorf("Unexpected restore result: %+v", result)
+	}
+	if result.Expired["archive-2026-q1/audit-0001"] != 1 || result.Restored["audit/archive-2026-q1_audit-0001.json"] != 1 {
+		t.Errorf("Expected entries expired since the backup to be skipped, got %+v", result)
+	}
+	if policies.policies["policy-1"].Name != "Adults" {
+		t.Error("Expected the existing policy to be updated")
+	}
+
+	registryData, err := os.ReadFile(filepath.Join(dir, "registry.json"))
+	if err != nil {
+		t.Fatalf("Expected the registry file: %v", err)
+	}
+	var registry DPRegistryFile
+	if err := json.Unmarshal(registryData, &registry); err != nil || len(registry.Providers) != 2 || registry.Providers[1].Auth.APIKeyEnv != "DP_B_KEY" {
+		t.Errorf("Unexpected restored registry: %s", registryData)
+	}
+}
+
+func TestRestoreBackup_RejectsTampering(t *testing.T) {
+	data, digest, _ := buildTestBackup(t)
+
+	tampered := strings.Replace(string(data), "req-2", "req-9", 1)
+	if _, err := VerifyBackup([]byte(tampered)); err == nil || !strings.Contains(err.Error(), "Merkle root") {
+		t.Errorf("Expected a changed record to be detected, got %v", err)
+	}
+
+	var container BackupContainer
+	json.Unmarshal(data, &container)
+	container.Manifest.Excluded = nil
+	edited, _ := json.Marshal(container)
+	if _, err := VerifyBackup(edited); err == nil || !strings.Contains(err.Error(), "digest") {
+		t.Errorf("Expected a changed manifest to be detected, got %v", err)
+	}
+
+	dir := t.TempDir()
+	_, err := RestoreBackup(context.Background(), data, BackupRestoreOptions{
+		ExpectedDigest: strings.Repeat("0", len(digest)),
+		Dir:            dir,
+	})
+	if err == nil {
+		t.Fatal("Expected a restore of a different backup to fail")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected nothing to be written, got %d files", len(entries))
+	}
+}
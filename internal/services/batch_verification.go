@@ -0,0 +1,307 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// BatchState represents the state of a batch verification
+type BatchState string
+
+const (
+	BatchRunning   BatchState = "running"
+	BatchCompleted BatchState = "completed"
+)
+
+// Batch event types streamed to subscribers
+const (
+	BatchEventItem     = "item"
+	BatchEventProgress = "progress"
+	BatchEventComplete = "complete"
+)
+
+// BatchItemResult represents the outcome of a single batch item
+type BatchItemResult struct {
+	Index       int                          `json:"index"`
+	Status      string                       `json:"status"`
+	Response    *models.VerificationResponse `json:"response,omitempty"`
+	Error       *models.Error                `json:"error,omitempty"`
+	CompletedAt time.Time                    `json:"completed_at"`
+}
+
+// BatchProgress summarizes batch completion
+type BatchProgress struct {
+	BatchID   string     `json:"batch_id"`
+	Status    BatchState `json:"status"`
+	Total     int        `json:"total"`
+	Completed int        `json:"completed"`
+	Succeeded int        `json:"succeeded"`
+	Failed    int        `json:"failed"`
+}
+
+// BatchEvent is a single event delivered to batch subscribers
+type BatchEvent struct {
+	ID       int              `json:"id"`
+	Type     string           `json:"type"`
+	Item     *BatchItemResult `json:"item,omitempty"`
+	Progress BatchProgress    `json:"progress"`
+}
+
+// Batch tracks the items and subscribers of a batch verification
+type Batch struct {
+	ID          string
+	RPID        string
+	CreatedAt   time.Time
+	Total       int
+	Status      BatchState
+	results     []*BatchItemResult
+	events      []BatchEvent
+	subscribers map[int]chan BatchEvent
+	nextSubID   int
+	succeeded   int
+	failed      int
+}
+
+// BatchTracker tracks batch verifications and fans out their results
+type BatchTracker struct {
+	mu       sync.Mutex
+	batches  map[string]*Batch
+	maxItems int
+	handlers []func(rpID string, progress BatchProgress)
+}
+
+// NewBatchTracker creates a new batch tracker
+func NewBatchTracker(maxItems int) *BatchTracker {
+	if maxItems <= 0 {
+		maxItems = 500
+	}
+	return &BatchTracker{
+		batches:  make(map[string]*Batch),
+		maxItems: maxItems,
+	}
+}
+
+// CreateBatch registers a new batch with the given number of items
+func (bt *BatchTracker) CreateBatch(rpID string, total int) (*BatchProgress, error) {
+	if total <= 0 {
+		return nil, fmt.Errorf("batch must contain at least one item")
+	}
+	if total > bt.maxItems {
+		return nil, fmt.Errorf("batch size %d exceeds maximum of %d", total, bt.maxItems)
+	}
+
+	idBytes := make([]byte, 8)
+	rand.Read(idBytes)
+
+	batch := &Batch{
+		ID:          fmt.Sprintf("batch_%s", hex.EncodeToString(idBytes)),
+		RPID:        rpID,
+		CreatedAt:   time.Now(),
+		Total:       total,
+		Status:      BatchRunning,
+		results:     make([]*BatchItemResult, total),
+		subscribers: make(map[int]chan BatchEvent),
+	}
+
+	bt.mu.Lock()
+	bt.batches[batch.ID] = batch
+	progress := batch.progress()
+	bt.mu.Unlock()
+
+	return &progress, nil
+}
+
+// RecordResult records an item result and notifies subscribers
+func (bt *BatchTracker) RecordResult(batchID string, result *BatchItemResult) error {
+	bt.mu.Lock()
+
+	batch, exists := bt.batches[batchID]
+	if !exists {
+		bt.mu.Unlock()
+		return fmt.Errorf("batch not found: %s", batchID)
+	}
+	if result.Index < 0 || result.Index >= batch.Total {
+		bt.mu.Unlock()
+		return fmt.Errorf("invalid batch item index: %d", result.Index)
+	}
+	if batch.results[result.Index] != nil {
+		bt.mu.Unlock()
+		return fmt.Errorf("batch item %d already recorded", result.Index)
+	}
+
+	if result.CompletedAt.IsZero() {
+		result.CompletedAt = time.Now()
+	}
+	batch.results[result.Index] = result
+	if result.Error == nil {
+		batch.succeeded++
+	} else {
+		batch.failed++
+	}
+
+	batch.publish(BatchEventItem, result)
+
+	if batch.succeeded+batch.failed == batch.Total {
+		batch.Status = BatchCompleted
+		batch.publish(BatchEventComplete, nil)
+		for id, ch := range batch.subscribers {
+			close(ch)
+			delete(batch.subscribers, id)
+		}
+	}
+	completed := batch.Status == BatchCompleted
+	progress := batch.progress()
+	handlers := bt.handlers
+	bt.mu.Unlock()
+
+	if completed {
+		for _, handler := range handlers {
+			handler(batch.RPID, progress)
+		}
+	}
+	return nil
+}
+
+// OnComplete registers a handler called with each batch once its last item
+// is recorded
+func (bt *BatchTracker) OnComplete(handler func(rpID string, progress BatchProgress)) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	bt.handlers = append(bt.handlers, handler)
+}
+
+// Subscribe returns a channel replaying past events followed by live events.
+// The channel is closed once the batch completes or the subscription is cancelled.
+func (bt *BatchTracker) Subscribe(batchID string) (<-chan BatchEvent, func(), error) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	batch, exists := bt.batches[batchID]
+	if !exists {
+		return nil, nil, fmt.Errorf("batch not found: %s", batchID)
+	}
+
+	// Buffer enough for the replay plus every remaining event
+	ch := make(chan BatchEvent, len(batch.events)+2*(batch.Total-batch.succeeded-batch.failed)+1)
+	for _, event := range batch.events {
+		ch <- event
+	}
+
+	if batch.Status == BatchCompleted {
+		close(ch)
+		return ch, func() {}, nil
+	}
+
+	subID := batch.nextSubID
+	batch.nextSubID++
+	batch.subscribers[subID] = ch
+
+	cancel := func() {
+		bt.mu.Lock()
+		defer bt.mu.Unlock()
+		if sub, ok := batch.subscribers[subID]; ok {
+			close(sub)
+			delete(batch.subscribers, subID)
+		}
+	}
+
+	return ch, cancel, nil
+}
+
+// GetBatch returns the progress and item results of a batch
+func (bt *BatchTracker) GetBatch(batchID string) (*BatchProgress, []*BatchItemResult, error) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	batch, exists := bt.batches[batchID]
+	if !exists {
+		return nil, nil, fmt.Errorf("batch not found: %s", batchID)
+	}
+
+	results := make([]*BatchItemResult, 0, batch.Total)
+	for _, result := range batch.results {
+		if result != nil {
+			results = append(results, result)
+		}
+	}
+
+	progress := batch.progress()
+	return &progress, results, nil
+}
+
+// GetBatchOwner returns the RP that submitted a batch
+func (bt *BatchTracker) GetBatchOwner(batchID string) (string, error) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	batch, exists := bt.batches[batchID]
+	if !exists {
+		return "", fmt.Errorf("batch not found: %s", batchID)
+	}
+	return batch.RPID, nil
+}
+
+// RunningBatches returns the number of batches with items still running
+func (bt *BatchTracker) RunningBatches() int {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	running := 0
+	for _, batch := range bt.batches {
+		if batch.Status == BatchRunning {
+			running++
+		}
+	}
+	return running
+}
+
+// CleanupExpiredBatches removes completed batches older than maxAge
+func (bt *BatchTracker) CleanupExpiredBatches(maxAge time.Duration) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	cutoff := time.Now().Add(-maxAge)
+	for batchID, batch := range bt.batches {
+		if batch.Status == BatchCompleted && batch.CreatedAt.Before(cutoff) {
+			delete(bt.batches, batchID)
+		}
+	}
+}
+
+// publish appends an event and delivers it to subscribers (caller holds the lock)
+func (b *Batch) publish(eventType string, item *BatchItemResult) {
+	event := BatchEvent{
+		ID:       len(b.events) + 1,
+		Type:     eventType,
+		Item:     item,
+		Progress: b.progress(),
+	}
+	b.events = append(b.events, event)
+
+	for id, ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Slow subscriber; drop it rather than block the batch
+			close(ch)
+			delete(b.subscribers, id)
+		}
+	}
+}
+
+// progress returns the current batch progress (caller holds the lock)
+func (b *Batch) progress() BatchProgress {
+	return BatchProgress{
+		BatchID:   b.ID,
+		Status:    b.Status,
+		Total:     b.Total,
+		Completed: b.succeeded + b.failed,
+		Succeeded: b.succeeded,
+		Failed:    b.failed,
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestBatchTracker_CreateBatch(t *testing.T) {
+	tracker := NewBatchTracker(2)
+
+	if _, err := tracker.CreateBatch("rp_1", 0); err == nil {
+		t.Error("Expected error for empty batch")
+	}
+	if _, err := tracker.CreateBatch("rp_1", 3); err == nil {
+		t.Error("Expected error for oversized batch")
+	}
+
+	progress, err := tracker.CreateBatch("rp_1", 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if progress.Status != BatchRunning || progress.Total != 2 {
+		t.Errorf("Unexpected initial progress: %+v", progress)
+	}
+
+	owner, err := tracker.GetBatchOwner(progress.BatchID)
+	if err != nil || owner != "rp_1" {
+		t.Errorf("Expected owner rp_1, got %s (%v)", owner, err)
+	}
+}
+
+func TestBatchTracker_RecordAndSubscribe(t *testing.T) {
+	tracker := NewBatchTracker(10)
+	progress, _ := tracker.CreateBatch("rp_1", 2)
+
+	// First item completes before anyone subscribes
+	tracker.RecordResult(progress.BatchID, &BatchItemResult{Index: 0, Status: "verified"})
+
+	events, cancel, err := tracker.Subscribe(progress.BatchID)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	defer cancel()
+
+	if err := tracker.RecordResult(progress.BatchID, &BatchItemResult{Index: 0}); err == nil {
+		t.Error("Expected error for duplicate item")
+	}
+	if err := tracker.RecordResult(progress.BatchID, &BatchItemResult{Index: 5}); err == nil {
+		t.Error("Expected error for out-of-range item")
+	}
+
+	tracker.RecordResult(progress.BatchID, &BatchItemResult{
+		Index:  1,
+		Status: "error",
+		Error:  models.NewError("DP_ERROR", "unavailable", "req_1"),
+	})
+
+	var received []BatchEvent
+	timeout := time.After(time.Second)
+	for done := false; !done; {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				done = true
+				break
+			}
+			received = append(received, event)
+		case <-timeout:
+			t.Fatal("Timed out waiting for events")
+		}
+	}
+
+	if len(received) != 3 {
+		t.Fatalf("Expected replayed, live and completion events, got %d", len(received))
+	}
+	if received[0].Item.Index != 0 || received[1].Item.Index != 1 {
+		t.Error("Expected item events in completion order")
+	}
+	last := received[2]
+	if last.Type != BatchEventComplete || last.Progress.Succeeded != 1 || last.Progress.Failed != 1 {
+		t.Errorf("Unexpected completion event: %+v", last)
+	}
+
+	final, results, _ := tracker.GetBatch(progress.BatchID)
+	if final.Status != BatchCompleted || len(results) != 2 {
+		t.Errorf("Expected completed batch with 2 results, got %s with %d", final.Status, len(results))
+	}
+}
+
+func TestBatchTracker_CleanupExpiredBatches(t *testing.T) {
+	tracker := NewBatchTracker(10)
+	progress, _ := tracker.CreateBatch("rp_1", 1)
+	running, _ := tracker.CreateBatch("rp_1", 1)
+	tracker.RecordResult(progress.BatchID, &BatchItemResult{Index: 0})
+
+	tracker.CleanupExpiredBatches(0)
+
+	if _, _, err := tracker.GetBatch(progress.BatchID); err == nil {
+		t.Error("Expected completed batch to be removed")
+	}
+	if _, _, err := tracker.GetBatch(running.BatchID); err != nil {
+		t.Error("Expected running batch to be kept")
+	}
+}
+
+func TestBatchTracker_OnComplete(t *testing.T) {
+	tracker := NewBatchTracker(10)
+	var completed []BatchProgress
+	tracker.OnComplete(func(rpID string, progress BatchProgress) {
+		if rpID != "rp_1" {
+			t.Errorf("Expected the batch's RP, got %s", rpID)
+		}
+		// Handlers run outside the tracker's lock
+		tracker.GetBatch(progress.BatchID)
+		completed = append(completed, progress)
+	})
+
+	progress, _ := tracker.CreateBatch("rp_1", 2)
+	tracker.RecordResult(progress.BatchID, &BatchItemResult{Index: 0, Status: "verified"})
+	if len(completed) != 0 {
+		t.Fatal("Expected no completion before the last item")
+	}
+	tracker.RecordResult(progress.BatchID, &BatchItemResult{Index: 1, Error: models.NewError("DP_ERROR", "failed", "req")})
+	if len(completed) != 1 || completed[0].Status != BatchCompleted || completed[0].Failed != 1 {
+		t.Errorf("Expected one completion, got %+v", completed)
+	}
+}
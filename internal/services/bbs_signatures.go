@@ -0,0 +1,598 @@
+package services
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+)
+
+// Domain separation tags for the hashes used by BBS+
+var (
+	bbsGeneratorDST = []byte("PAVILION_BBS+_BLS12381G1_GENERATOR_")
+	bbsMessageDST   = []byte("PAVILION_BBS+_BLS12381_MESSAGE_")
+	bbsChallengeDST = []byte("PAVILION_BBS+_BLS12381_CHALLENGE_")
+)
+
+// bbsMaxMessages bounds the claims of a credential, and so the generators
+// a verifier derives for a proof
+const bbsMaxMessages = 256
+
+// BBSPrivateKey signs credentials with BBS+ over BLS12-381. Its public key
+// w = g2^x is all that holders and verifiers need.
+type BBSPrivateKey struct {
+	x fr.Element
+	w bls12381.G2Affine
+}
+
+// GenerateBBSKey creates a random BBS+ signing key
+func GenerateBBSKey() (*BBSPrivateKey, error) {
+	key := &BBSPrivateKey{}
+	for key.x.IsZero() {
+		if _, err := key.x.SetRandom(); err != nil {
+			return nil, fmt.Errorf("failed to generate BBS+ key: %w", err)
+		}
+	}
+	_, _, _, g2 := bls12381.Generators()
+	key.w.ScalarMultiplication(&g2, key.x.BigInt(new(big.Int)))
+	return key, nil
+}
+
+// PublicKey returns the hex-encoded compressed public key
+func (k *BBSPrivateKey) PublicKey() string {
+	w := k.w.Bytes()
+	return hex.EncodeToString(w[:])
+}
+
+// BBSSignature is a BBS+ signature (A, e, s) on the claims of a credential
+type BBSSignature struct {
+	A string `json:"a"`
+	E string `json:"e"`
+	S string `json:"s"`
+}
+
+// BBSCredential is a credential signed once by the issuer, from which the
+// holder derives a proof for any subset of its claims
+type BBSCredential struct {
+	CredentialID string                 `json:"credential_id"`
+	Claims       map[string]interface{} `json:"claims"`
+	Signature    BBSSignature           `json:"signature"`
+	PublicKey    string                 `json:"public_key"`
+	IssuedAt     time.Time              `json:"issued_at"`
+}
+
+// BBSDisclosedClaim is a claim revealed by a proof, with its position in the
+// signed credential
+type BBSDisclosedClaim struct {
+	Index int         `json:"index"`
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// BBSDisclosureProof proves knowledge of a BBS+ signature on a credential
+// while revealing only some claims. Each proof is freshly randomized, so two
+// proofs from the same credential cannot be linked. It deliberately carries
+// no credential ID.
+type BBSDisclosureProof struct {
+	MessageCount int                 `json:"message_count"`
+	Disclosed    []BBSDisclosedClaim `json:"disclosed"`
+	APrime       string              `json:"a_prime"`
+	ABar         string              `json:"a_bar"`
+	D            string              `json:"d"`
+	Challenge    string              `json:"challenge"`
+	EHat         string              `json:"e_hat"`
+	R2Hat        string              `json:"r2_hat"`
+	R3Hat        string              `json:"r3_hat"`
+	SHat         string              `json:"s_hat"`
+	// MHat are the responses for the hidden claims in index order
+	MHat []string `json:"m_hat"`
+}
+
+// bbsMessages maps claims to scalars. Claims are ordered by name, so message
+// i is the i-th claim name and both sides agree on positions.
+func bbsMessages(claims map[string]interface{}) ([]string, []fr.Element, error) {
+	names := make([]string, 0, len(claims))
+	for name := range claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	messages := make([]fr.Element, len(names))
+	for i, name := range names {
+		message, err := bbsMessage(name, claims[name])
+		if err != nil {
+			return nil, nil, err
+		}
+		messages[i] = message
+	}
+	return names, messages, nil
+}
+
+// bbsMessage hashes a claim name and its JSON value to a scalar
+func bbsMessage(name string, value interface{}) (fr.Element, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fr.Element{}, fmt.Errorf("claim %s cannot be encoded: %w", name, err)
+	}
+	hashed, err := fr.Hash(append(append([]byte(name), 0), encoded...), bbsMessageDST, 1)
+	if err != nil {
+		return fr.Element{}, err
+	}
+	return hashed[0], nil
+}
+
+// bbsGenerators derives h0 (for the blinding s) and h1..hL (for the
+// messages) by hashing to G1, so nobody knows their discrete logarithms
+func bbsGenerators(count int) ([]bls12381.G1Affine, error) {
+	generators := make([]bls12381.G1Affine, count+1)
+	for i := range generators {
+		generator, err := bls12381.HashToG1([]byte("h"+strconv.Itoa(i)), bbsGeneratorDST)
+		if err != nil {
+			return nil, err
+		}
+		generators[i] = generator
+	}
+	return generators, nil
+}
+
+// bbsCommit computes g1 * h0^s * prod(h_i^m_i)
+func bbsCommit(generators []bls12381.G1Affine, s fr.Element, messages []fr.Element) bls12381.G1Affine {
+	g1, _, _, _ := bls12381.Generators()
+	b := g1
+	b.AddAssign(bbsMul(&generators[0], s))
+	for i := range messages {
+		b.AddAssign(bbsMul(&generators[i+1], messages[i]))
+	}
+	var affine bls12381.G1Affine
+	affine.FromJacobian(&b)
+	return affine
+}
+
+func bbsMul(point *bls12381.G1Affine, scalar fr.Element) *bls12381.G1Jac {
+	var jac bls12381.G1Jac
+	jac.FromAffine(point)
+	jac.ScalarMultiplication(&jac, scalar.BigInt(new(big.Int)))
+	return &jac
+}
+
+// Sign signs the claims of a credential: A = B^(1/(x+e)) for random e and s
+func (k *BBSPrivateKey) Sign(claims map[string]interface{}) (*BBSSignature, error) {
+	if len(claims) == 0 || len(claims) > bbsMaxMessages {
+		return nil, fmt.Errorf("a BBS+ credential must have between 1 and %d claims", bbsMaxMessages)
+	}
+	_, messages, err := bbsMessages(claims)
+	if err != nil {
+		return nil, err
+	}
+	generators, err := bbsGenerators(len(messages))
+	if err != nil {
+		return nil, err
+	}
+
+	var e, s, exponent fr.Element
+	for exponent.IsZero() {
+		if _, err := e.SetRandom(); err != nil {
+			return nil, err
+		}
+		exponent.Add(&k.x, &e)
+	}
+	if _, err := s.SetRandom(); err != nil {
+		return nil, err
+	}
+
+	b := bbsCommit(generators, s, messages)
+	exponent.Inverse(&exponent)
+	var a bls12381.G1Affine
+	a.ScalarMultiplication(&b, exponent.BigInt(new(big.Int)))
+
+	return &BBSSignature{A: encodeG1(&a), E: encodeScalar(&e), S: encodeScalar(&s)}, nil
+}
+
+// VerifyBBSSignature checks e(A, w * g2^e) == e(B, g2)
+func VerifyBBSSignature(publicKey string, claims map[string]interface{}, signature *BBSSignature) (bool, error) {
+	w, err := decodeG2(publicKey)
+	if err != nil {
+		return false, err
+	}
+	a, err := decodeG1(signature.A)
+	if err != nil {
+		return false, err
+	}
+	e, err := decodeScalar(signature.E)
+	if err != nil {
+		return false, err
+	}
+	s, err := decodeScalar(signature.S)
+	if err != nil {
+		return false, err
+	}
+	_, messages, err := bbsMessages(claims)
+	if err != nil {
+		return false, err
+	}
+	generators, err := bbsGenerators(len(messages))
+	if err != nil {
+		return false, err
+	}
+	if a.IsInfinity() {
+		return false, nil
+	}
+
+	_, _, _, g2 := bls12381.Generators()
+	var ge bls12381.G2Affine
+	ge.ScalarMultiplication(&g2, e.BigInt(new(big.Int)))
+	ge.Add(&ge, &w)
+
+	b := bbsCommit(generators, s, messages)
+	b.Neg(&b)
+	return bls12381.PairingCheck([]bls12381.G1Affine{a, b}, []bls12381.G2Affine{ge, g2})
+}
+
+// DeriveBBSProof proves possession of a signed credential, revealing only the
+// named claims. The nonce comes from the verifier and binds the proof to one
+// presentation.
+func DeriveBBSProof(credential *BBSCredential, disclose []string, nonce string) (*BBSDisclosureProof, error) {
+	if nonce == "" {
+		return nil, fmt.Errorf("nonce is required")
+	}
+	w, err := decodeG2(credential.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	a, err := decodeG1(credential.Signature.A)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeScalar(credential.Signature.E)
+	if err != nil {
+		return nil, err
+	}
+	s, err := decodeScalar(credential.Signature.S)
+	if err != nil {
+		return nil, err
+	}
+	names, messages, err := bbsMessages(credential.Claims)
+	if err != nil {
+		return nil, err
+	}
+	generators, err := bbsGenerators(len(messages))
+	if err != nil {
+		return nil, err
+	}
+
+	disclosed := make(map[int]bool, len(disclose))
+	for _, name := range disclose {
+		index := sort.SearchStrings(names, name)
+		if index == len(names) || names[index] != name {
+			return nil, fmt.Errorf("credential has no claim %s", name)
+		}
+		disclosed[index] = true
+	}
+
+	// Randomize the signature: A' = A^r1, Abar = A'^-e * B^r1, d = B^r1 * h0^-r2
+	var r1, r2, r3, sPrime fr.Element
+	for r1.IsZero() {
+		if _, err := r1.SetRandom(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := r2.SetRandom(); err != nil {
+		return nil, err
+	}
+	r3.Inverse(&r1)
+	sPrime.Mul(&r2, &r3)
+	sPrime.Sub(&s, &sPrime)
+
+	b := bbsCommit(generators, s, messages)
+	var negE, negR2 fr.Element
+	negE.Neg(&e)
+	negR2.Neg(&r2)
+
+	aPrimeJac := bbsMul(&a, r1)
+	var aPrime bls12381.G1Affine
+	aPrime.FromJacobian(aPrimeJac)
+
+	bR1 := bbsMul(&b, r1)
+	aBarJac := bbsMul(&aPrime, negE)
+	aBarJac.AddAssign(bR1)
+	var aBar bls12381.G1Affine
+	aBar.FromJacobian(aBarJac)
+
+	dJac := bbsMul(&generators[0], negR2)
+	dJac.AddAssign(bR1)
+	var d bls12381.G1Affine
+	d.FromJacobian(dJac)
+
+	// Commit with random blindings for e, r2, r3, s' and the hidden messages
+	var eTilde, r2Tilde, r3Tilde, sTilde fr.Element
+	for _, blinding := range []*fr.Element{&eTilde, &r2Tilde, &r3Tilde, &sTilde} {
+		if _, err := blinding.SetRandom(); err != nil {
+			return nil, err
+		}
+	}
+	hidden := make([]int, 0, len(messages)-len(disclosed))
+	mTilde := make(map[int]fr.Element)
+	for i := range messages {
+		if disclosed[i] {
+			continue
+		}
+		var blinding fr.Element
+		if _, err := blinding.SetRandom(); err != nil {
+			return nil, err
+		}
+		hidden = append(hidden, i)
+		mTilde[i] = blinding
+	}
+
+	t1, t2 := bbsProofCommitments(&aPrime, &d, generators, eTilde, r2Tilde, r3Tilde, sTilde, hidden, mTilde)
+
+	proof := &BBSDisclosureProof{
+		MessageCount: len(messages),
+		Disclosed:    make([]BBSDisclosedClaim, 0, len(disclosed)),
+		APrime:       encodeG1(&aPrime),
+		ABar:         encodeG1(&aBar),
+		D:            encodeG1(&d),
+	}
+	disclosedMessages := make(map[int]fr.Element, len(disclosed))
+	for i, name := range names {
+		if disclosed[i] {
+			proof.Disclosed = append(proof.Disclosed, BBSDisclosedClaim{Index: i, Name: name, Value: credential.Claims[name]})
+			disclosedMessages[i] = messages[i]
+		}
+	}
+
+	challenge, err := bbsChallenge(&w, proof, &aPrime, &aBar, &d, &t1, &t2, disclosedMessages, nonce)
+	if err != nil {
+		return nil, err
+	}
+	proof.Challenge = encodeScalar(&challenge)
+	proof.EHat = encodeScalar(bbsResponse(eTilde, challenge, e))
+	proof.R2Hat = encodeScalar(bbsResponse(r2Tilde, challenge, r2))
+	proof.R3Hat = encodeScalar(bbsResponse(r3Tilde, challenge, r3))
+	proof.SHat = encodeScalar(bbsResponse(sTilde, challenge, sPrime))
+	proof.MHat = make([]string, len(hidden))
+	for j, i := range hidden {
+		proof.MHat[j] = encodeScalar(bbsResponse(mTilde[i], challenge, messages[i]))
+	}
+
+	return proof, nil
+}
+
+// VerifyBBSProof checks a disclosure proof against the issuer's public key
+// and the verifier's nonce, returning the disclosed claims when it is valid
+func VerifyBBSProof(publicKey string, proof *BBSDisclosureProof, nonce string) (map[string]interface{}, bool, error) {
+	if proof == nil {
+		return nil, false, fmt.Errorf("proof is required")
+	}
+	if nonce == "" {
+		return nil, false, fmt.Errorf("nonce is required")
+	}
+	if proof.MessageCount < 1 || proof.MessageCount > bbsMaxMessages {
+		return nil, false, fmt.Errorf("message_count must be between 1 and %d", bbsMaxMessages)
+	}
+	if len(proof.Disclosed)+len(proof.MHat) != proof.MessageCount {
+		return nil, false, fmt.Errorf("proof does not cover every claim")
+	}
+
+	w, err := decodeG2(publicKey)
+	if err != nil {
+		return nil, false, err
+	}
+	points := make([]bls12381.G1Affine, 3)
+	for i, encoded := range []string{proof.APrime, proof.ABar, proof.D} {
+		if points[i], err = decodeG1(encoded); err != nil {
+			return nil, false, err
+		}
+	}
+	aPrime, aBar, d := points[0], points[1], points[2]
+
+	scalars := make([]fr.Element, 5)
+	for i, encoded := range []string{proof.Challenge, proof.EHat, proof.R2Hat, proof.R3Hat, proof.SHat} {
+		if scalars[i], err = decodeScalar(encoded); err != nil {
+			return nil, false, err
+		}
+	}
+	challenge, eHat, r2Hat, r3Hat, sHat := scalars[0], scalars[1], scalars[2], scalars[3], scalars[4]
+
+	claims := make(map[string]interface{}, len(proof.Disclosed))
+	disclosedMessages := make(map[int]fr.Element, len(proof.Disclosed))
+	lastIndex := -1
+	for _, claim := range proof.Disclosed {
+		if claim.Index <= lastIndex || claim.Index >= proof.MessageCount {
+			return nil, false, fmt.Errorf("disclosed claims must have ascending indexes below message_count")
+		}
+		lastIndex = claim.Index
+		message, err := bbsMessage(claim.Name, claim.Value)
+		if err != nil {
+			return nil, false, err
+		}
+		disclosedMessages[claim.Index] = message
+		claims[claim.Name] = claim.Value
+	}
+
+	hidden := make([]int, 0, len(proof.MHat))
+	mHat := make(map[int]fr.Element, len(proof.MHat))
+	for i := 0; i < proof.MessageCount; i++ {
+		if _, isDisclosed := disclosedMessages[i]; isDisclosed {
+			continue
+		}
+		response, err := decodeScalar(proof.MHat[len(hidden)])
+		if err != nil {
+			return nil, false, err
+		}
+		mHat[i] = response
+		hidden = append(hidden, i)
+	}
+
+	generators, err := bbsGenerators(proof.MessageCount)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// A' must be a real point and e(A', w) == e(Abar, g2), i.e. Abar = A'^x
+	if aPrime.IsInfinity() {
+		return nil, false, nil
+	}
+	_, _, _, g2 := bls12381.Generators()
+	var negABar bls12381.G1Affine
+	negABar.Neg(&aBar)
+	paired, err := bls12381.PairingCheck([]bls12381.G1Affine{aPrime, negABar}, []bls12381.G2Affine{w, g2})
+	if err != nil || !paired {
+		return nil, false, err
+	}
+
+	// Recompute the commitments from the responses and the public statements
+	// X1 = Abar / d and X2 = g1 * prod(h_i^m_i) over the disclosed claims
+	t1, t2 := bbsProofCommitments(&aPrime, &d, generators, eHat, r2Hat, r3Hat, sHat, hidden, mHat)
+
+	var negChallenge fr.Element
+	negChallenge.Neg(&challenge)
+
+	var x1 bls12381.G1Affine
+	x1.Sub(&aBar, &d)
+	t1Jac := bbsMul(&x1, negChallenge)
+	t1Jac.AddMixed(&t1)
+	t1.FromJacobian(t1Jac)
+
+	g1Jac, _, _, _ := bls12381.Generators()
+	x2Jac := g1Jac
+	for i, message := range disclosedMessages {
+		x2Jac.AddAssign(bbsMul(&generators[i+1], message))
+	}
+	var x2 bls12381.G1Affine
+	x2.FromJacobian(&x2Jac)
+	t2Jac := bbsMul(&x2, negChallenge)
+	t2Jac.AddMixed(&t2)
+	t2.FromJacobian(t2Jac)
+
+	expected, err := bbsChallenge(&w, proof, &aPrime, &aBar, &d, &t1, &t2, disclosedMessages, nonce)
+	if err != nil {
+		return nil, false, err
+	}
+	if !expected.Equal(&challenge) {
+		return nil, false, nil
+	}
+	return claims, true, nil
+}
+
+// bbsProofCommitments computes T1 = A'^-e * h0^r2 and
+// T2 = d^r3 * h0^-s' * prod(h_i^-m_i) over the hidden claims, for either the
+// blindings (prover) or the responses (verifier)
+func bbsProofCommitments(aPrime, d *bls12381.G1Affine, generators []bls12381.G1Affine, e, r2, r3, sPrime fr.Element, hidden []int, m map[int]fr.Element) (bls12381.G1Affine, bls12381.G1Affine) {
+	var negE, negS fr.Element
+	negE.Neg(&e)
+	negS.Neg(&sPrime)
+
+	t1Jac := bbsMul(aPrime, negE)
+	t1Jac.AddAssign(bbsMul(&generators[0], r2))
+
+	t2Jac := bbsMul(d, r3)
+	t2Jac.AddAssign(bbsMul(&generators[0], negS))
+	for _, i := range hidden {
+		var negM fr.Element
+		value := m[i]
+		negM.Neg(&value)
+		t2Jac.AddAssign(bbsMul(&generators[i+1], negM))
+	}
+
+	var t1, t2 bls12381.G1Affine
+	t1.FromJacobian(t1Jac)
+	t2.FromJacobian(t2Jac)
+	return t1, t2
+}
+
+// bbsChallenge is the Fiat-Shamir challenge over the public key, the
+// randomized signature, the commitments, the disclosed claims and the nonce
+func bbsChallenge(w *bls12381.G2Affine, proof *BBSDisclosureProof, aPrime, aBar, d, t1, t2 *bls12381.G1Affine, disclosed map[int]fr.Element, nonce string) (fr.Element, error) {
+	wBytes := w.Bytes()
+	transcript := append([]byte{}, wBytes[:]...)
+	for _, point := range []*bls12381.G1Affine{aPrime, aBar, d, t1, t2} {
+		pointBytes := point.Bytes()
+		transcript = append(transcript, pointBytes[:]...)
+	}
+	transcript = append(transcript, []byte(strconv.Itoa(proof.MessageCount)+":")...)
+
+	indexes := make([]int, 0, len(disclosed))
+	for i := range disclosed {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		message := disclosed[i]
+		messageBytes := message.Bytes()
+		transcript = append(transcript, []byte(strconv.Itoa(i)+":")...)
+		transcript = append(transcript, messageBytes[:]...)
+	}
+	transcript = append(transcript, []byte(nonce)...)
+
+	challenge, err := fr.Hash(transcript, bbsChallengeDST, 1)
+	if err != nil {
+		return fr.Element{}, err
+	}
+	return challenge[0], nil
+}
+
+// bbsResponse is blinding + challenge * secret
+func bbsResponse(blinding, challenge, secret fr.Element) *fr.Element {
+	var response fr.Element
+	response.Mul(&challenge, &secret)
+	response.Add(&response, &blinding)
+	return &response
+}
+
+func encodeG1(point *bls12381.G1Affine) string {
+	encoded := point.Bytes()
+	return hex.EncodeToString(encoded[:])
+}
+
+// decodeG1 parses a compressed point; SetBytes rejects points outside the
+// prime-order subgroup
+func decodeG1(encoded string) (bls12381.G1Affine, error) {
+	var point bls12381.G1Affine
+	data, err := hex.DecodeString(encoded)
+	if err != nil || len(data) != bls12381.SizeOfG1AffineCompressed {
+		return point, fmt.Errorf("invalid G1 point encoding")
+	}
+	if _, err := point.SetBytes(data); err != nil {
+		return point, fmt.Errorf("invalid G1 point: %w", err)
+	}
+	return point, nil
+}
+
+func decodeG2(encoded string) (bls12381.G2Affine, error) {
+	var point bls12381.G2Affine
+	data, err := hex.DecodeString(encoded)
+	if err != nil || len(data) != bls12381.SizeOfG2AffineCompressed {
+		return point, fmt.Errorf("invalid BBS+ public key encoding")
+	}
+	if _, err := point.SetBytes(data); err != nil {
+		return point, fmt.Errorf("invalid BBS+ public key: %w", err)
+	}
+	if point.IsInfinity() {
+		return point, fmt.Errorf("invalid BBS+ public key")
+	}
+	return point, nil
+}
+
+func encodeScalar(scalar *fr.Element) string {
+	encoded := scalar.Bytes()
+	return hex.EncodeToString(encoded[:])
+}
+
+func decodeScalar(encoded string) (fr.Element, error) {
+	var scalar fr.Element
+	data, err := hex.DecodeString(encoded)
+	if err != nil || len(data) != fr.Bytes {
+		return scalar, fmt.Errorf("invalid scalar encoding")
+	}
+	if err := scalar.SetBytesCanonical(data); err != nil {
+		return scalar, fmt.Errorf("invalid scalar: %w", err)
+	}
+	return scalar, nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"testing"
+)
+
+func newBBSTestService(t *testing.T) *SelectiveDisclosureService {
+	key, err := GenerateBBSKey()
+	if err != nil {
+		t.Fatalf("GenerateBBSKey failed: %v", err)
+	}
+	service := NewSelectiveDisclosureService(NewSelectiveDisclosureConfig(true, false, "test"))
+	service.SetBBSKey(key)
+	return service
+}
+
+func TestBBSSignatures_Disclosure(t *testing.T) {
+	service := newBBSTestService(t)
+
+	claims := map[string]interface{}{
+		"name":        "Alice Example",
+		"age_over_18": true,
+		"country":     "FR",
+		"score":       720,
+	}
+	credential, err := service.IssueBBSCredential("cred-1", claims)
+	if err != nil {
+		t.Fatalf("IssueBBSCredential failed: %v", err)
+	}
+
+	valid, err := VerifyBBSSignature(credential.PublicKey, credential.Claims, &credential.Signature)
+	if err != nil || !valid {
+		t.Fatalf("Expected a valid signature, got %v, %v", valid, err)
+	}
+
+	proof, err := service.DeriveBBSDisclosure(credential, []string{"age_over_18", "country"}, "nonce-1")
+	if err != nil {
+		t.Fatalf("DeriveBBSDisclosure failed: %v", err)
+	}
+
+	t.Run("verify", func(t *testing.T) {
+		disclosed, err := service.VerifyBBSDisclosure(proof, "nonce-1")
+		if err != nil {
+			t.Fatalf("VerifyBBSDisclosure failed: %v", err)
+		}
+		if len(disclosed) != 2 || disclosed["age_over_18"] != true || disclosed["country"] != "FR" {
+			t.Errorf("Unexpected disclosed claims: %v", disclosed)
+		}
+		if len(proof.MHat) != 2 {
+			t.Errorf("Expected 2 hidden claims, got %d", len(proof.MHat))
+		}
+	})
+
+	t.Run("wrong nonce", func(t *testing.T) {
+		if _, err := service.VerifyBBSDisclosure(proof, "nonce-2"); err == nil {
+			t.Error("Proof should not verify under another nonce")
+		}
+	})
+
+	t.Run("tampered claim", func(t *testing.T) {
+		tampered := *proof
+		tampered.Disclosed = append([]BBSDisclosedClaim(nil), proof.Disclosed...)
+		tampered.Disclosed[1].Value = "US"
+		if _, err := service.VerifyBBSDisclosure(&tampered, "nonce-1"); err == nil {
+			t.Error("Proof with a changed claim should not verify")
+		}
+	})
+
+	t.Run("other issuer", func(t *testing.T) {
+		other := newBBSTestService(t)
+		if _, err := other.VerifyBBSDisclosure(proof, "nonce-1"); err == nil {
+			t.Error("Proof should not verify under another issuer's key")
+		}
+	})
+
+	t.Run("unlinkable", func(t *testing.T) {
+		again, err := service.DeriveBBSDisclosure(credential, []string{"age_over_18", "country"}, "nonce-1")
+		if err != nil {
+			t.Fatalf("DeriveBBSDisclosure failed: %v", err)
+		}
+		if again.APrime == proof.APrime || again.ABar == proof.ABar || again.D == proof.D {
+			t.Error("Proofs from the same credential should not share signature components")
+		}
+	})
+
+	t.Run("unknown claim", func(t *testing.T) {
+		if _, err := service.DeriveBBSDisclosure(credential, []string{"email"}, "nonce-1"); err == nil {
+			t.Error("Expected an error disclosing a claim the credential does not have")
+		}
+	})
+
+	t.Run("altered credential", func(t *testing.T) {
+		altered := *credential
+		altered.Claims = map[string]interface{}{"name": "Mallory", "age_over_18": true, "country": "FR", "score": 720}
+		if _, err := service.DeriveBBSDisclosure(&altered, []string{"name"}, "nonce-1"); err == nil {
+			t.Error("Expected an error deriving from a credential whose claims were changed")
+		}
+	})
+}
+
+func TestBBSSignatures_DiscloseNothing(t *testing.T) {
+	service := newBBSTestService(t)
+
+	credential, err := service.IssueBBSCredential("cred-2", map[string]interface{}{"member": true})
+	if err != nil {
+		t.Fatalf("IssueBBSCredential failed: %v", err)
+	}
+	proof, err := service.DeriveBBSDisclosure(credential, nil, "nonce")
+	if err != nil {
+		t.Fatalf("DeriveBBSDisclosure failed: %v", err)
+	}
+	disclosed, err := service.VerifyBBSDisclosure(proof, "nonce")
+	if err != nil || len(disclosed) != 0 {
+		t.Errorf("Expected a valid proof disclosing nothing, got %v, %v", disclosed, err)
+	}
+}
+
+func TestBBSSignatures_RequiresKey(t *testing.T) {
+	service := NewSelectiveDisclosureService(NewSelectiveDisclosureConfig(true, false, "test"))
+	if _, err := service.IssueBBSCredential("cred-1", map[string]interface{}{"a": 1}); err == nil {
+		t.Error("Expected an error issuing without a BBS+ key")
+	}
+}
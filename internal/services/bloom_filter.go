@@ -1,6 +1,8 @@
 package services
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"hash/fnv"
@@ -8,20 +10,38 @@ import (
 	"strings"
 )
 
+// Hash algorithms used to derive Bloom filter bit positions
+const (
+	BloomHashFNV1a  = "FNV-1a"
+	BloomHashSHA256 = "SHA-256"
+)
+
 // BloomFilter represents a Bloom filter for privacy-preserving record linkage
 type BloomFilter struct {
 	bitArray    []bool
 	size        int
 	hashCount   int
+	hashAlgorithm string
 	hashFunctions []func([]byte) uint64
 }
 
 // NewBloomFilter creates a new Bloom filter with the specified parameters
 func NewBloomFilter(size, hashCount int) *BloomFilter {
+	return NewBloomFilterWithHash(size, hashCount, BloomHashFNV1a)
+}
+
+// NewBloomFilterWithHash creates a Bloom filter using the given hash algorithm.
+// Filters are only comparable when built with the same algorithm.
+func NewBloomFilterWithHash(size, hashCount int, hashAlgorithm string) *BloomFilter {
+	if hashAlgorithm != BloomHashSHA256 {
+		hashAlgorithm = BloomHashFNV1a
+	}
+
 	bf := &BloomFilter{
-		bitArray:  make([]bool, size),
-		size:      size,
-		hashCount: hashCount,
+		bitArray:      make([]bool, size),
+		size:          size,
+		hashCount:     hashCount,
+		hashAlgorithm: hashAlgorithm,
 	}
 	
 	// Initialize hash functions
@@ -36,6 +56,16 @@ func (bf *BloomFilter) createHashFunctions() []func([]byte) uint64 {
 	
 	for i := 0; i < bf.hashCount; i++ {
 		seed := uint64(i)
+		if bf.hashAlgorithm == BloomHashSHA256 {
+			functions[i] = func(data []byte) uint64 {
+				// Seeded SHA-256, for deployments restricted to approved hashes
+				h := sha256.New()
+				h.Write([]byte(fmt.Sprintf("%d", seed)))
+				h.Write(data)
+				return binary.BigEndian.Uint64(h.Sum(nil))
+			}
+			continue
+		}
 		functions[i] = func(data []byte) uint64 {
 			// Use FNV-1a hash with different seeds
 			h := fnv.New64a()
@@ -91,12 +121,17 @@ func (bf *BloomFilter) ToHexString() string {
 
 // FromHexString creates a Bloom filter from a hex string
 func FromHexString(hexString string, size, hashCount int) (*BloomFilter, error) {
+	return FromHexStringWithHash(hexString, size, hashCount, BloomHashFNV1a)
+}
+
+// FromHexStringWithHash creates a Bloom filter using the given hash algorithm from a hex string
+func FromHexStringWithHash(hexString string, size, hashCount int, hashAlgorithm string) (*BloomFilter, error) {
 	byteArray, err := hex.DecodeString(hexString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode hex string: %w", err)
 	}
 	
-	bf := NewBloomFilter(size, hashCount)
+	bf := NewBloomFilterWithHash(size, hashCount, hashAlgorithm)
 	
 	// Convert bytes back to boolean array
 	for i := 0; i < bf.size; i++ {
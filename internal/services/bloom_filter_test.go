@@ -89,6 +89,30 @@ func TestBloomFilter_FromHexString(t *testing.T) {
 	}
 }
 
+func TestBloomFilter_SHA256Hash(t *testing.T) {
+	bf := NewBloomFilterWithHash(1000, 5, BloomHashSHA256)
+	if bf.hashAlgorithm != BloomHashSHA256 {
+		t.Errorf("Expected hash algorithm %s, got %s", BloomHashSHA256, bf.hashAlgorithm)
+	}
+
+	bf.Add("john doe")
+	if !bf.Contains("john doe") {
+		t.Error("Bloom filter should contain added element")
+	}
+
+	restored, err := FromHexStringWithHash(bf.ToHexString(), 1000, 5, BloomHashSHA256)
+	if err != nil {
+		t.Fatalf("Failed to restore Bloom filter: %v", err)
+	}
+	if !restored.Contains("john doe") {
+		t.Error("Restored Bloom filter should contain added element")
+	}
+
+	if NewBloomFilterWithHash(1000, 5, "unknown").hashAlgorithm != BloomHashFNV1a {
+		t.Error("Unknown hash algorithms should fall back to FNV-1a")
+	}
+}
+
 func TestBloomFilter_GetFalsePositiveRate(t *testing.T) {
 	bf := NewBloomFilter(1000, 5)
 	
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// maxBreakerErrorSamples bounds the recent errors a breaker keeps for events
+const maxBreakerErrorSamples = 5
+
+// maxBreakerEvents bounds the recent breaker transitions kept for stats
+const maxBreakerEvents = 100
+
+// CircuitBreakerEvent records a circuit breaker state transition for a DP
+type CircuitBreakerEvent struct {
+	DPID         string       `json:"dp_id"`
+	From         CircuitState `json:"from"`
+	To           CircuitState `json:"to"`
+	FailureCount int          `json:"failure_count"`
+	Threshold    int          `json:"threshold"`
+	ErrorRate    float64      `json:"error_rate,omitempty"`
+	Summary      string       `json:"summary"`
+	RecentErrors []string     `json:"recent_errors,omitempty"`
+	Timestamp    time.Time    `json:"timestamp"`
+}
+
+// transitionLocked moves the breaker to a new state and queues the event
+// for emit, returning it, or nil when the state is unchanged
+func (cb *CircuitBreaker) transitionLocked(to CircuitState) *CircuitBreakerEvent {
+	from := cb.state
+	cb.state = to
+	if from == to {
+		return nil
+	}
+	successes := cb.probeSuccesses
+	cb.probes, cb.probeSuccesses = 0, 0
+	if cb.onTransition == nil {
+		return nil
+	}
+
+	event := &CircuitBreakerEvent{
+		DPID:         cb.dpID,
+		From:         from,
+		To:           to,
+		FailureCount: cb.failureCount,
+		Threshold:    cb.threshold,
+		RecentErrors: append([]string(nil), cb.recentErrors...),
+		Timestamp:    time.Now(),
+	}
+	switch {
+	case to == CircuitOpen && from == CircuitHalf:
+		event.Summary = fmt.Sprintf("probe failed with %d of %d successes needed; requests rejected for %s", successes, cb.settings.halfOpenSuccesses(), cb.timeout)
+	case to == CircuitOpen && cb.window != nil:
+		event.ErrorRate = cb.window.errorRate()
+		event.Summary = fmt.Sprintf("%d of the last %d calls failed (%.0f%%, threshold %.0f%%); requests rejected for %s",
+			cb.window.failures, cb.window.calls, event.ErrorRate*100, cb.window.threshold*100, cb.timeout)
+	case to == CircuitOpen:
+		event.Summary = fmt.Sprintf("%d consecutive failures (threshold %d); requests rejected for %s", cb.failureCount, cb.threshold, cb.timeout)
+	case to == CircuitHalf:
+		event.Summary = fmt.Sprintf("open for %s; probing DP with up to %d calls at once, %d successes in a row close the breaker",
+			cb.timeout, cb.settings.halfOpenMaxProbes(), cb.settings.halfOpenSuccesses())
+	case to == CircuitClosed && from == CircuitHalf:
+		event.Summary = fmt.Sprintf("DP recovered: %d successful probes in a row", successes)
+	case to == CircuitClosed:
+		event.Summary = "DP recovered"
+	}
+	cb.pendingEvents = append(cb.pendingEvents, event)
+	return event
+}
+
+// emit delivers queued transition events to the transition hook, outside
+// the breaker's lock. Deliveries are serialized and each takes every event
+// queued so far, so the hook sees transitions in the order they happened.
+func (cb *CircuitBreaker) emit() {
+	if cb.onTransition == nil {
+		return
+	}
+	cb.emitMu.Lock()
+	defer cb.emitMu.Unlock()
+
+	cb.mu.Lock()
+	events := cb.pendingEvents
+	cb.pendingEvents = nil
+	cb.mu.Unlock()
+
+	for _, event := range events {
+		cb.onTransition(*event)
+	}
+}
+
+// BreakerWebhookNotifier posts circuit breaker events to operator webhooks
+// (chat, paging or incident tools). Bodies are signed with HMAC-SHA256 in
+// X-Pavilion-Signature when a secret is configured.
+type BreakerWebhookNotifier struct {
+	urls   []string
+	secret []byte
+	client *http.Client
+}
+
+// NewBreakerWebhookNotifier creates a notifier posting to the given URLs
+func NewBreakerWebhookNotifier(urls []string, secret string) *BreakerWebhookNotifier {
+	return &BreakerWebhookNotifier{
+		urls:   urls,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Notify posts an event to every webhook in the background; a failed
+// delivery is logged and never holds up DP traffic
+func (n *BreakerWebhookNotifier) Notify(event CircuitBreakerEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		fmt.Printf("DP BREAKER WARNING: failed to encode event for %s: %v\n", event.DPID, err)
+		return
+	}
+	for _, url := range n.urls {
+		go func(url string) {
+			if err := n.post(url, body); err != nil {
+				fmt.Printf("DP BREAKER WARNING: failed to notify %s: %v\n", url, err)
+			}
+		}(url)
+	}
+}
+
+// post delivers one event body to a webhook
+func (n *BreakerWebhookNotifier) post(url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), n.client.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.secret) > 0 {
+		mac := hmac.New(sha256.New, n.secret)
+		mac.Write(body)
+		req.Header.Set("X-Pavilion-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestCircuitBreaker_TransitionEvents(t *testing.T) {
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: "http://dp.invalid", DPTimeout: time.Second})
+	var handled []CircuitBreakerEvent
+	service.SetBreakerEventHandler(func(event CircuitBreakerEvent) {
+		handled = append(handled, event)
+	})
+
+	breaker := service.providerBreaker("dp-1")
+	breaker.timeout = 10 * time.Millisecond
+	for i := 0; i < breaker.threshold; i++ {
+		breaker.RecordFailureWithError(errors.New("connection refused"))
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !breaker.CanExecute() {
+		t.Fatal("Expected the breaker to let a probe through after the timeout")
+	}
+	breaker.RecordSuccess()
+	breaker.RecordSuccess()
+
+	events := service.GetBreakerEvents()
+	if len(events) != 3 || len(handled) != 3 {
+		t.Fatalf("Expected open, half-open and close events, got %+v", events)
+	}
+	opened := events[0]
+	if opened.DPID != "dp-1" || opened.From != CircuitClosed || opened.To != CircuitOpen || opened.FailureCount != 5 {
+		t.Errorf("Unexpected open event: %+v", opened)
+	}
+	if len(opened.RecentErrors) != maxBreakerErrorSamples || opened.RecentErrors[0] != "connection refused" {
+		t.Errorf("Expected recent error samples, got %v", opened.RecentErrors)
+	}
+	if events[1].To != CircuitHalf || events[2].To != CircuitClosed || len(events[2].RecentErrors) != 5 {
+		t.Errorf("Unexpected recovery events: %+v", events[1:])
+	}
+}
+
+// TestCircuitBreaker_ConcurrentUse drives a breaker from many goroutines;
+// run it with -race to check the locking
+func TestCircuitBreaker_ConcurrentUse(t *testing.T) {
+	var mu sync.Mutex
+	var events []CircuitBreakerEvent
+	breaker := &CircuitBreaker{state: CircuitClosed, threshold: 3, timeout: time.Millisecond, dpID: "dp-1",
+		onTransition: func(event CircuitBreakerEvent) {
+			mu.Lock()
+			events = append(events, event)
+			mu.Unlock()
+		}}
+	settings := []*DPBreakerSettings{
+		{HalfOpenMaxProbes: 2, HalfOpenSuccesses: 2},
+		{Mode: BreakerModeErrorRate, WindowSize: 10, MinimumCalls: 4, HalfOpenMaxProbes: 2, HalfOpenSuccesses: 2},
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			random := rand.New(rand.NewSource(seed))
+			for i := 0; i < 2000; i++ {
+				switch op := random.Intn(100); {
+				case op < 40:
+					if !breaker.Acquire() {
+						continue
+					}
+					switch random.Intn(3) {
+					case 0:
+						breaker.RecordSuccess()
+					case 1:
+						breaker.RecordFailureWithError(errors.New("connection refused"))
+					default:
+						breaker.Release()
+					}
+				case op < 60:
+					breaker.CanExecute()
+				case op < 75:
+					breaker.RecordFailure()
+				case op < 90:
+					breaker.RecordSuccess()
+				case op < 96:
+					breaker.GetCircuitBreakerStats()
+				case op < 98:
+					breaker.configure(settings[random.Intn(len(settings))])
+				default:
+					breaker.Reset()
+				}
+				if probes := breaker.GetCircuitBreakerStats()["probes_in_flight"]; probes != nil && (probes.(int) < 0 || probes.(int) > 2) {
+					t.Errorf("Probes in flight out of bounds: %d", probes)
+					return
+				}
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+
+	if len(events) == 0 {
+		t.Fatal("Expected the breaker to change state")
+	}
+	// Events are delivered in the order the transitions happened
+	for i := 1; i < len(events); i++ {
+		if events[i].From != events[i-1].To {
+			t.Fatalf("Event %d went from %s, but the previous one ended in %s", i, events[i].From, events[i-1].To)
+		}
+	}
+	if last := events[len(events)-1]; last.To != breaker.State() {
+		t.Errorf("Expected the last event to end in %s, got %s", breaker.State(), last.To)
+	}
+}
+
+func TestBreakerWebhookNotifier_SignsEvents(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer webhook.Close()
+
+	NewBreakerWebhookNotifier([]string{webhook.URL}, "secret").Notify(CircuitBreakerEvent{DPID: "dp-1", From: CircuitClosed, To: CircuitOpen})
+
+	select {
+	case r := <-received:
+		body := <-bodies
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		if r.Header.Get("X-Pavilion-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("Unexpected signature %q", r.Header.Get("X-Pavilion-Signature"))
+		}
+		var event CircuitBreakerEvent
+		if err := json.Unmarshal(body, &event); err != nil || event.DPID != "dp-1" || event.To != CircuitOpen {
+			t.Errorf("Unexpected webhook body %s: %v", body, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the event to be posted to the webhook")
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import "fmt"
+
+// Circuit breaker modes
+const (
+	// BreakerModeConsecutive opens after a run of consecutive failures
+	BreakerModeConsecutive = "consecutive"
+	// BreakerModeErrorRate opens when the share of failed calls in a
+	// sliding window crosses a threshold
+	BreakerModeErrorRate = "error_rate"
+)
+
+// Defaults for the error rate mode
+const (
+	defaultBreakerErrorRate    = 0.5
+	defaultBreakerWindowSize   = 100
+	defaultBreakerMinimumCalls = 20
+)
+
+// Defaults for the half-open state: one probe at a time, and one success
+// closes the breaker
+const (
+	defaultHalfOpenMaxProbes = 1
+	defaultHalfOpenSuccesses = 1
+)
+
+// DPBreakerSettings configure a provider's circuit breaker. The default
+// consecutive-failure mode suits DPs that fail outright; under mixed traffic,
+// where a DP fails a large share of calls without failing several in a row,
+// the error rate mode opens the breaker where the other would not.
+type DPBreakerSettings struct {
+	// Mode is "consecutive" (the default) or "error_rate"
+	Mode string `json:"mode,omitempty"`
+	// ErrorRateThreshold is the share of failed calls in the window, from 0
+	// to 1, that opens the breaker; defaults to 0.5
+	ErrorRateThreshold float64 `json:"error_rate_threshold,omitempty"`
+	// WindowSize is the number of most recent calls considered; defaults
+	// to 100
+	WindowSize int `json:"window_size,omitempty"`
+	// MinimumCalls is the number of calls the window needs before the
+	// breaker can open; defaults to 20
+	MinimumCalls int `json:"minimum_calls,omitempty"`
+	// HalfOpenMaxProbes is the number of calls let through at once while
+	// the breaker is half-open; defaults to 1
+	HalfOpenMaxProbes int `json:"half_open_max_probes,omitempty"`
+	// HalfOpenSuccesses is the number of consecutive successful probes that
+	// close the breaker; defaults to 1
+	HalfOpenSuccesses int `json:"half_open_successes,omitempty"`
+}
+
+// Validate checks the breaker mode and its thresholds
+func (b *DPBreakerSettings) Validate() error {
+	switch b.Mode {
+	case "", BreakerModeConsecutive, BreakerModeErrorRate:
+	default:
+		return fmt.Errorf("breaker: unknown mode %q", b.Mode)
+	}
+	if b.ErrorRateThreshold < 0 || b.ErrorRateThreshold > 1 {
+		return fmt.Errorf("breaker: error_rate_threshold must be between 0 and 1")
+	}
+	if b.WindowSize < 0 || b.MinimumCalls < 0 {
+		return fmt.Errorf("breaker: window_size and minimum_calls must not be negative")
+	}
+	if b.WindowSize > 0 && b.MinimumCalls > b.WindowSize {
+		return fmt.Errorf("breaker: minimum_calls cannot exceed window_size")
+	}
+	if b.HalfOpenMaxProbes < 0 || b.HalfOpenSuccesses < 0 {
+		return fmt.Errorf("breaker: half_open_max_probes and half_open_successes must not be negative")
+	}
+	return nil
+}
+
+// halfOpenMaxProbes returns the concurrent probes allowed while half-open
+func (b DPBreakerSettings) halfOpenMaxProbes() int {
+	if b.HalfOpenMaxProbes > 0 {
+		return b.HalfOpenMaxProbes
+	}
+	return defaultHalfOpenMaxProbes
+}
+
+// halfOpenSuccesses returns the successful probes that close the breaker
+func (b DPBreakerSettings) halfOpenSuccesses() int {
+	if b.HalfOpenSuccesses > 0 {
+		return b.HalfOpenSuccesses
+	}
+	return defaultHalfOpenSuccesses
+}
+
+// newOutcomeWindow returns the sliding window for the settings, or nil in
+// consecutive mode
+func (b *DPBreakerSettings) newOutcomeWindow() *outcomeWindow {
+	if b == nil || b.Mode != BreakerModeErrorRate {
+		return nil
+	}
+	window := &outcomeWindow{
+		threshold:    b.ErrorRateThreshold,
+		minimumCalls: b.MinimumCalls,
+	}
+	if window.threshold == 0 {
+		window.threshold = defaultBreakerErrorRate
+	}
+	size := b.WindowSize
+	if size == 0 {
+		size = defaultBreakerWindowSize
+	}
+	if window.minimumCalls == 0 {
+		window.minimumCalls = defaultBreakerMinimumCalls
+		if window.minimumCalls > size {
+			window.minimumCalls = size
+		}
+	}
+	window.outcomes = make([]bool, size)
+	return window
+}
+
+// outcomeWindow is a ring buffer of the most recent call outcomes
+type outcomeWindow struct {
+	outcomes     []bool
+	next         int
+	calls        int
+	failures     int
+	threshold    float64
+	minimumCalls int
+}
+
+// record adds an outcome, evicting the oldest once the window is full
+func (w *outcomeWindow) record(failed bool) {
+	if w.calls == len(w.outcomes) {
+		if w.outcomes[w.next] {
+			w.failures--
+		}
+	} else {
+		w.calls++
+	}
+	w.outcomes[w.next] = failed
+	if failed {
+		w.failures++
+	}
+	w.next = (w.next + 1) % len(w.outcomes)
+}
+
+// errorRate returns the share of failed calls in the window
+func (w *outcomeWindow) errorRate() float64 {
+	if w.calls == 0 {
+		return 0
+	}
+	return float64(w.failures) / float64(w.calls)
+}
+
+// tripped reports whether the window has enough calls and too many failures
+func (w *outcomeWindow) tripped() bool {
+	return w.calls >= w.minimumCalls && w.errorRate() >= w.threshold
+}
+
+// reset forgets every outcome, so a recovered DP starts with a clean window
+func (w *outcomeWindow) reset() {
+	for i := range w.outcomes {
+		w.outcomes[i] = false
+	}
+	w.next, w.calls, w.failures = 0, 0, 0
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestCircuitBreaker_ErrorRateModeOpensUnderMixedTraffic(t *testing.T) {
+	settings := &DPBreakerSettings{Mode: BreakerModeErrorRate, ErrorRateThreshold: 0.5, WindowSize: 10, MinimumCalls: 6}
+	consecutive := &CircuitBreaker{state: CircuitClosed, threshold: 5, timeout: time.Minute}
+	errorRate := &CircuitBreaker{state: CircuitClosed, threshold: 5, timeout: time.Minute}
+	errorRate.configure(settings)
+	var events []CircuitBreakerEvent
+	errorRate.onTransition = func(event CircuitBreakerEvent) { events = append(events, event) }
+
+	// Two of every three calls fail, never five in a row
+	for i := 0; i < 5; i++ {
+		for _, breaker := range []*CircuitBreaker{consecutive, errorRate} {
+			if i%3 == 2 {
+				breaker.RecordSuccess()
+			} else {
+				breaker.RecordFailureWithError(errors.New("DP connector returned status 503"))
+			}
+		}
+		if errorRate.state == CircuitOpen {
+			t.Fatalf("Expected the minimum volume to hold the breaker closed, opened after %d calls", i+1)
+		}
+	}
+	errorRate.RecordFailure()
+
+	if consecutive.state != CircuitClosed {
+		t.Errorf("Expected the consecutive breaker to stay closed, got %s", consecutive.state)
+	}
+	if errorRate.state != CircuitOpen {
+		t.Fatalf("Expected the error rate breaker to open, got %s", errorRate.state)
+	}
+	if len(events) != 1 || events[0].ErrorRate < 0.5 {
+		t.Errorf("Expected an open event with the error rate, got %+v", events)
+	}
+	if stats := errorRate.GetCircuitBreakerStats(); stats["mode"] != BreakerModeErrorRate || stats["window_calls"] != 6 {
+		t.Errorf("Expected error rate stats, got %v", stats)
+	}
+}
+
+func TestCircuitBreaker_ErrorRateModeRecovery(t *testing.T) {
+	breaker := &CircuitBreaker{state: CircuitClosed, threshold: 5, timeout: time.Millisecond}
+	breaker.configure(&DPBreakerSettings{Mode: BreakerModeErrorRate, WindowSize: 4, MinimumCalls: 2})
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	if breaker.state != CircuitOpen {
+		t.Fatalf("Expected the breaker to open, got %s", breaker.state)
+	}
+
+	// A failed probe reopens the breaker
+	time.Sleep(2 * time.Millisecond)
+	if !breaker.CanExecute() || breaker.state != CircuitHalf {
+		t.Fatalf("Expected a probe to be allowed, got %s", breaker.state)
+	}
+	breaker.RecordFailure()
+	if breaker.state != CircuitOpen {
+		t.Fatalf("Expected the failed probe to reopen the breaker, got %s", breaker.state)
+	}
+
+	// A successful probe closes it with a clean window
+	time.Sleep(2 * time.Millisecond)
+	breaker.CanExecute()
+	breaker.RecordSuccess()
+	breaker.RecordFailure()
+	if breaker.state != CircuitClosed {
+		t.Errorf("Expected failures before recovery to be forgotten, got %s", breaker.state)
+	}
+
+	// Window of 4: the oldest outcome is evicted
+	window := (&DPBreakerSettings{Mode: BreakerModeErrorRate, WindowSize: 4}).newOutcomeWindow()
+	for _, failed := range []bool{true, true, false, false, false, false} {
+		window.record(failed)
+	}
+	if window.calls != 4 || window.failures != 0 || window.minimumCalls != 4 {
+		t.Errorf("Expected a full window without failures, got %+v", window)
+	}
+}
+
+func TestDPBreakerSettings_Validate(t *testing.T) {
+	if err := (&DPBreakerSettings{Mode: BreakerModeErrorRate, ErrorRateThreshold: 0.25, WindowSize: 50, MinimumCalls: 10}).Validate(); err != nil {
+		t.Errorf("Expected valid settings, got %v", err)
+	}
+	for _, invalid := range []*DPBreakerSettings{
+		{Mode: "sliding"},
+		{Mode: BreakerModeErrorRate, ErrorRateThreshold: 1.5},
+		{Mode: BreakerModeErrorRate, WindowSize: 10, MinimumCalls: 20},
+		{WindowSize: -1},
+		{HalfOpenMaxProbes: -1},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+}
+
+func TestDPConnectorService_ProviderBreakerSettings(t *testing.T) {
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: "http://localhost:8080", DPTimeout: 5 * time.Second})
+	service.registry = NewDPRegistry()
+	provider := &DPProvider{DPID: "dp_1", Endpoint: "http://dp.example.com", SupportedClaims: []string{"age_verification"}}
+	service.registry.Register(provider)
+
+	if mode := service.providerBreaker("dp_1").GetCircuitBreakerStats()["mode"]; mode != BreakerModeConsecutive {
+		t.Errorf("Expected the consecutive mode by default, got %v", mode)
+	}
+	service.registry.Remove("dp_1")
+	service.registry.Register(&DPProvider{DPID: "dp_1", Endpoint: "http://dp.example.com", SupportedClaims: []string{"age_verification"},
+		Breaker: &DPBreakerSettings{Mode: BreakerModeErrorRate}})
+	stats := service.providerBreaker("dp_1").GetCircuitBreakerStats()
+	if stats["mode"] != BreakerModeErrorRate || stats["minimum_calls"] != defaultBreakerMinimumCalls {
+		t.Errorf("Expected the updated provider settings to apply, got %v", stats)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenProbes(t *testing.T) {
+	var events []CircuitBreakerEvent
+	breaker := &CircuitBreaker{state: CircuitClosed, threshold: 1, timeout: 10 * time.Millisecond, dpID: "dp-1",
+		onTransition: func(event CircuitBreakerEvent) { events = append(events, event) }}
+	breaker.configure(&DPBreakerSettings{HalfOpenMaxProbes: 2, HalfOpenSuccesses: 2})
+	trip := func() {
+		breaker.RecordFailureWithError(errors.New("connection refused"))
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	trip()
+	if !breaker.Acquire() || !breaker.Acquire() {
+		t.Fatal("Expected two probes through once half-open")
+	}
+	if breaker.Acquire() || breaker.CanExecute() {
+		t.Fatal("Expected a third concurrent probe to be refused")
+	}
+
+	// A call whose outcome is not recorded gives its slot back
+	breaker.Release()
+	if !breaker.Acquire() {
+		t.Fatal("Expected the released slot to be reused")
+	}
+
+	breaker.RecordSuccess()
+	if breaker.state != CircuitHalf {
+		t.Fatalf("Expected one success to leave the breaker half-open, got %s", breaker.state)
+	}
+	if !breaker.Acquire() {
+		t.Fatal("Expected the finished probe's slot to be free")
+	}
+	breaker.RecordSuccess()
+	if breaker.state != CircuitClosed {
+		t.Fatalf("Expected two successes in a row to close the breaker, got %s", breaker.state)
+	}
+	if last := events[len(events)-1]; last.To != CircuitClosed || !strings.Contains(last.Summary, "2 successful probes") {
+		t.Errorf("Unexpected close event: %+v", last)
+	}
+
+	// A failed probe reopens the breaker, even after a success
+	trip()
+	breaker.Acquire()
+	breaker.RecordSuccess()
+	breaker.Acquire()
+	breaker.RecordFailureWithError(errors.New("timeout"))
+	if breaker.state != CircuitOpen {
+		t.Fatalf("Expected a failed probe to reopen the breaker, got %s", breaker.state)
+	}
+	if last := events[len(events)-1]; last.From != CircuitHalf || !strings.Contains(last.Summary, "probe failed with 1 of 2 successes") {
+		t.Errorf("Unexpected reopen event: %+v", last)
+	}
+
+	// Probes never reported are taken to be lost after the breaker timeout
+	time.Sleep(20 * time.Millisecond)
+	breaker.Acquire()
+	breaker.Acquire()
+	breaker.mu.Lock()
+	breaker.probeStarted = time.Now().Add(-time.Second)
+	breaker.mu.Unlock()
+	if !breaker.Acquire() {
+		t.Error("Expected lost probes' slots to be reclaimed")
+	}
+}
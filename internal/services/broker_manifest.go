@@ -0,0 +1,260 @@
+package services
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// BrokerManifestPath is where a broker publishes its manifest
+const BrokerManifestPath = "/.well-known/pavilion-broker"
+
+// brokerManifestRefresh bounds how long a signed manifest is served before
+// it is rebuilt, so registry changes appear without signing every request
+const brokerManifestRefresh = time.Minute
+
+// brokerAPIVersions are the API versions this build serves
+var brokerAPIVersions = []string{"v1"}
+
+// brokerEndpoints are the paths of the broker's main endpoints
+var brokerEndpoints = map[string]string{
+	"verification":          "/api/v1/verify",
+	"batch_verification":    "/api/v1/verify/batch",
+	"catalog":               "/api/v1/catalog",
+	"credentials":           "/api/v1/credentials",
+	"presentations":         "/api/v1/presentations",
+	"presentation_response": "/openid4vp/response",
+	"zkp_proofs":            "/api/v1/zkp/proofs",
+	"health":                "/health",
+	"manifest":              BrokerManifestPath,
+}
+
+// BrokerManifest describes a broker's capabilities for other brokers and
+// automated clients. Its JWKS holds the key the manifest and the broker's
+// signed responses are verified with.
+type BrokerManifest struct {
+	Issuer            string            `json:"issuer"`
+	APIVersions       []string          `json:"api_versions"`
+	ClaimTypes        []string          `json:"claim_types"`
+	ProofFormats      []string          `json:"proof_formats"`
+	CredentialFormats []string          `json:"credential_formats"`
+	PolicyProfiles    []string          `json:"policy_profiles"`
+	CryptoProfile     string            `json:"crypto_profile"`
+	Endpoints         map[string]string `json:"endpoints"`
+	JWKS              BrokerJWKS        `json:"jwks"`
+	IssuedAt          time.Time         `json:"issued_at"`
+	ExpiresAt         time.Time         `json:"expires_at"`
+}
+
+// BrokerJWKS is a JSON Web Key Set (RFC 7517)
+type BrokerJWKS struct {
+	Keys []map[string]interface{} `json:"keys"`
+}
+
+// SignedBrokerManifest is a manifest with its compact JWS, whose payload is
+// the manifest signed with the attestation key
+type SignedBrokerManifest struct {
+	Manifest       *BrokerManifest `json:"manifest"`
+	SignedManifest string          `json:"signed_manifest"`
+}
+
+// BrokerManifestService builds and signs the broker manifest from the
+// registries the broker serves
+type BrokerManifestService struct {
+	cfg    *config.Config
+	signer *JWSAttestationService
+
+	claimSchemas *ClaimSchemaRegistry
+	circuits     *ZKPCircuitRegistry
+	signing      *CredentialSigningService
+
+	mu     sync.Mutex
+	cached *SignedBrokerManifest
+	now    func() time.Time
+}
+
+// NewBrokerManifestService creates a manifest service signing with the
+// attestation key
+func NewBrokerManifestService(cfg *config.Config, signer *JWSAttestationService) *BrokerManifestService {
+	return &BrokerManifestService{cfg: cfg, signer: signer, now: time.Now}
+}
+
+// SetClaimSchemas lists the registered claim types in the manifest
+func (s *BrokerManifestService) SetClaimSchemas(claimSchemas *ClaimSchemaRegistry) {
+	s.claimSchemas = claimSchemas
+}
+
+// SetZKPCircuits lists the available zero-knowledge proof circuits in the
+// manifest's proof formats
+func (s *BrokerManifestService) SetZKPCircuits(circuits *ZKPCircuitRegistry) {
+	s.circuits = circuits
+}
+
+// SetCredentialSigning lists the credential signing methods in the
+// manifest's credential formats
+func (s *BrokerManifestService) SetCredentialSigning(signing *CredentialSigningService) {
+	s.signing = signing
+}
+
+// Manifest returns the signed manifest, rebuilding it when the served one
+// is older than a minute
+func (s *BrokerManifestService) Manifest() (*SignedBrokerManifest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.cached != nil && now.Sub(s.cached.Manifest.IssuedAt) < brokerManifestRefresh {
+		return s.cached, nil
+	}
+	manifest, err := s.build(now)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := s.sign(manifest)
+	if err != nil {
+		return nil, err
+	}
+	s.cached = &SignedBrokerManifest{Manifest: manifest, SignedManifest: signed}
+	return s.cached, nil
+}
+
+func (s *BrokerManifestService) build(now time.Time) (*BrokerManifest, error) {
+	if s.signer == nil {
+		return nil, fmt.Errorf("broker manifest requires an attestation key")
+	}
+	jwk, err := s.signer.GetJWK()
+	if err != nil {
+		return nil, fmt.Errorf("broker manifest key: %w", err)
+	}
+
+	ttl := s.cfg.BrokerManifestTTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	manifest := &BrokerManifest{
+		Issuer:            s.cfg.Issuer,
+		APIVersions:       brokerAPIVersions,
+		ClaimTypes:        make([]string, 0),
+		ProofFormats:      []string{"jwt", "cose_sign1"},
+		CredentialFormats: make([]string, 0),
+		PolicyProfiles:    make([]string, 0),
+		CryptoProfile:     ActiveCryptoProfile(s.cfg).Name,
+		Endpoints:         make(map[string]string, len(brokerEndpoints)),
+		JWKS:              BrokerJWKS{Keys: []map[string]interface{}{jwk}},
+		IssuedAt:          now.UTC().Truncate(time.Second),
+		ExpiresAt:         now.Add(ttl).UTC().Truncate(time.Second),
+	}
+
+	if s.claimSchemas != nil {
+		for _, schema := range s.claimSchemas.List() {
+			if schema.ClaimType != AnyClaimType {
+				manifest.ClaimTypes = append(manifest.ClaimTypes, schema.ClaimType)
+			}
+		}
+		sort.Strings(manifest.ClaimTypes)
+	}
+	for format := range presentationFormats {
+		manifest.ProofFormats = append(manifest.ProofFormats, format)
+	}
+	sort.Strings(manifest.ProofFormats)
+	if s.circuits != nil {
+		for _, circuit := range s.circuits.List() {
+			manifest.ProofFormats = append(manifest.ProofFormats, "zkp:"+circuit.Name)
+		}
+	}
+	if s.signing != nil {
+		for _, method := range s.signing.GetSupportedMethods() {
+			manifest.CredentialFormats = append(manifest.CredentialFormats, string(method))
+		}
+	}
+	manifest.PolicyProfiles = append(manifest.PolicyProfiles, s.cfg.BrokerPolicyProfiles...)
+
+	base := strings.TrimSuffix(s.cfg.BrokerPublicURL, "/")
+	for name, path := range brokerEndpoints {
+		manifest.Endpoints[name] = base + path
+	}
+	return manifest, nil
+}
+
+// sign signs the manifest as a JWT whose claims are the manifest fields
+// with iss, iat and exp
+func (s *BrokerManifestService) sign(manifest *BrokerManifest) (string, error) {
+	claims, err := brokerManifestClaims(manifest)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = s.signer.keyID
+	token.Header["typ"] = "pavilion-broker-manifest+jwt"
+	signed, err := token.SignedString(s.signer.privateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign broker manifest: %w", err)
+	}
+	return signed, nil
+}
+
+func brokerManifestClaims(manifest *BrokerManifest) (jwt.MapClaims, error) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	var claims jwt.MapClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, err
+	}
+	claims["iss"] = manifest.Issuer
+	claims["iat"] = manifest.IssuedAt.Unix()
+	claims["exp"] = manifest.ExpiresAt.Unix()
+	return claims, nil
+}
+
+// VerifyBrokerManifest checks a signed manifest against a broker's key and
+// returns the manifest. Keys are pinned out of band, or taken on first use
+// from the manifest's own JWKS with BrokerManifestKey.
+func VerifyBrokerManifest(signed string, key *rsa.PublicKey) (*BrokerManifest, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(signed, claims, func(token *jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker manifest: %w", err)
+	}
+
+	delete(claims, "iss")
+	delete(claims, "iat")
+	delete(claims, "exp")
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	var manifest BrokerManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid broker manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// BrokerManifestKey returns the RSA key of a manifest's JWKS with the key ID
+func BrokerManifestKey(manifest *BrokerManifest, keyID string) (*rsa.PublicKey, error) {
+	for _, jwk := range manifest.JWKS.Keys {
+		if jwk["kid"] != keyID || jwk["kty"] != "RSA" {
+			continue
+		}
+		n, nErr := base64.RawURLEncoding.DecodeString(fmt.Sprint(jwk["n"]))
+		e, eErr := base64.RawURLEncoding.DecodeString(fmt.Sprint(jwk["e"]))
+		if nErr != nil || eErr != nil {
+			return nil, fmt.Errorf("malformed key %s", keyID)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+	return nil, fmt.Errorf("no RSA key %s in the manifest", keyID)
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func newTestBrokerManifestService() *BrokerManifestService {
+	cfg := &config.Config{
+		Issuer:               "https://broker-a.example.com",
+		BrokerPublicURL:      "https://broker-a.example.com/",
+		BrokerPolicyProfiles: []string{"eu-age-assurance-v1"},
+	}
+	service := NewBrokerManifestService(cfg, NewJWSAttestationService(cfg))
+	schemas := NewClaimSchemaRegistry()
+	schemas.Register(&ClaimTypeSchema{ClaimType: "student_verification", RequiredIdentifiers: []string{"email"}})
+	service.SetClaimSchemas(schemas)
+	service.SetZKPCircuits(NewZKPService(ZKPConfigFromConfig(cfg)).Circuits())
+	return service
+}
+
+func TestBrokerManifestService_SignsCapabilities(t *testing.T) {
+	service := newTestBrokerManifestService()
+	signed, err := service.Manifest()
+	if err != nil {
+		t.Fatalf("Expected the manifest to be signed, got %v", err)
+	}
+
+	manifest := signed.Manifest
+	if manifest.Issuer != "https://broker-a.example.com" || manifest.CryptoProfile == "" || manifest.APIVersions[0] != "v1" {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+	if !containsString(manifest.ClaimTypes, "student_verification") || !containsString(manifest.ProofFormats, "dc+sd-jwt") {
+		t.Errorf("Expected the claim types and proof formats, got %v and %v", manifest.ClaimTypes, manifest.ProofFormats)
+	}
+	if manifest.Endpoints["verification"] != "https://broker-a.example.com/api/v1/verify" {
+		t.Errorf("Expected endpoints under the public URL, got %v", manifest.Endpoints)
+	}
+	if len(manifest.PolicyProfiles) != 1 || manifest.PolicyProfiles[0] != "eu-age-assurance-v1" {
+		t.Errorf("Expected the declared policy profiles, got %v", manifest.PolicyProfiles)
+	}
+
+	key, err := BrokerManifestKey(manifest, "pavilion-core-broker-v1")
+	if err != nil {
+		t.Fatalf("Expected the manifest key, got %v", err)
+	}
+	verified, err := VerifyBrokerManifest(signed.SignedManifest, key)
+	if err != nil {
+		t.Fatalf("Expected the signed manifest to verify, got %v", err)
+	}
+	if verified.Issuer != manifest.Issuer || !verified.ExpiresAt.Equal(manifest.ExpiresAt) || len(verified.ClaimTypes) != len(manifest.ClaimTypes) {
+		t.Errorf("Expected the verified manifest to match, got %+v", verified)
+	}
+
+	parts := strings.Split(signed.SignedManifest, ".")
+	tampered := parts[0] + "." + parts[1] + "x." + parts[2]
+	if _, err := VerifyBrokerManifest(tampered, key); err == nil {
+		t.Error("Expected a tampered manifest to be rejected")
+	}
+	other := newTestBrokerManifestService()
+	otherSigned, _ := other.Manifest()
+	if _, err := VerifyBrokerManifest(otherSigned.SignedManifest, key); err == nil {
+		t.Error("Expected a manifest signed by another key to be rejected")
+	}
+}
+
+func TestBrokerManifestService_RefreshesCachedManifest(t *testing.T) {
+	service := newTestBrokerManifestService()
+	now := time.Date(2025, 8, 2, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	first, _ := service.Manifest()
+	now = now.Add(30 * time.Second)
+	if second, _ := service.Manifest(); second != first {
+		t.Error("Expected the manifest to be served from cache")
+	}
+	now = now.Add(time.Minute)
+	if third, _ := service.Manifest(); third == first || !third.Manifest.IssuedAt.Equal(now) {
+		t.Error("Expected the manifest to be rebuilt once stale")
+	}
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Bulkhead kinds
+const (
+	BulkheadKindDP     = "dp"
+	BulkheadKindTenant = "tenant"
+)
+
+// BulkheadFullError is returned when a call finds its bulkhead full. It is a
+// local rejection, so it never counts against a DP's circuit breaker.
+type BulkheadFullError struct {
+	Kind  string
+	Key   string
+	Limit int
+}
+
+func (e *BulkheadFullError) Error() string {
+	return fmt.Sprintf("%s bulkhead for %s is full (%d concurrent calls)", e.Kind, e.Key, e.Limit)
+}
+
+// Bulkhead is a semaphore limiting concurrent calls. A call waits up to
+// maxWait for a slot before it is rejected.
+type Bulkhead struct {
+	slots    chan struct{}
+	maxWait  time.Duration
+	acquired int64
+	rejected int64
+}
+
+// NewBulkhead creates a bulkhead allowing limit concurrent calls
+func NewBulkhead(limit int, maxWait time.Duration) *Bulkhead {
+	return &Bulkhead{slots: make(chan struct{}, limit), maxWait: maxWait}
+}
+
+// Acquire takes a slot, returning the function that releases it
+func (b *Bulkhead) Acquire(ctx context.Context) (func(), bool) {
+	select {
+	case b.slots <- struct{}{}:
+		atomic.AddInt64(&b.acquired, 1)
+		return b.release, true
+	default:
+	}
+
+	if b.maxWait > 0 {
+		timer := time.NewTimer(b.maxWait)
+		defer timer.Stop()
+		select {
+		case b.slots <- struct{}{}:
+			atomic.AddInt64(&b.acquired, 1)
+			return b.release, true
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	atomic.AddInt64(&b.rejected, 1)
+	return nil, false
+}
+
+func (b *Bulkhead) release() {
+	<-b.slots
+}
+
+// BulkheadStats describes a bulkhead's saturation
+type BulkheadStats struct {
+	Kind       string  `json:"kind"`
+	Key        string  `json:"key"`
+	Limit      int     `json:"limit"`
+	InFlight   int     `json:"in_flight"`
+	Saturation float64 `json:"saturation"`
+	Acquired   int64   `json:"acquired"`
+	Rejected   int64   `json:"rejected"`
+}
+
+// Stats returns the bulkhead's limit, calls in flight and counters
+func (b *Bulkhead) Stats() BulkheadStats {
+	inFlight := len(b.slots)
+	return BulkheadStats{
+		Limit:      cap(b.slots),
+		InFlight:   inFlight,
+		Saturation: float64(inFlight) / float64(cap(b.slots)),
+		Acquired:   atomic.LoadInt64(&b.acquired),
+		Rejected:   atomic.LoadInt64(&b.rejected),
+	}
+}
+
+// bulkheadGroup keeps a bulkhead per key. Tenant bulkheads are dropped once
+// idle so the group does not grow with every RP ever seen; their rejections
+// are kept in a total.
+type bulkheadGroup struct {
+	kind    string
+	maxWait time.Duration
+	prune   bool
+
+	mu        sync.Mutex
+	bulkheads map[string]*Bulkhead
+	users     map[string]int
+	rejected  int64
+}
+
+func newBulkheadGroup(kind string, maxWait time.Duration, prune bool) *bulkheadGroup {
+	return &bulkheadGroup{
+		kind:      kind,
+		maxWait:   maxWait,
+		prune:     prune,
+		bulkheads: make(map[string]*Bulkhead),
+		users:     make(map[string]int),
+	}
+}
+
+// acquire takes a slot in the key's bulkhead; a limit of zero or less does
+// not limit the key
+func (g *bulkheadGroup) acquire(ctx context.Context, key string, limit int) (func(), error) {
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	g.mu.Lock()
+	bulkhead, exists := g.bulkheads[key]
+	if !exists || cap(bulkhead.slots) != limit {
+		// A changed limit takes effect once in-flight calls drain
+		if !exists || g.users[key] == 0 {
+			bulkhead = NewBulkhead(limit, g.maxWait)
+			g.bulkheads[key] = bulkhead
+		}
+	}
+	g.users[key]++
+	g.mu.Unlock()
+
+	release, ok := bulkhead.Acquire(ctx)
+	if !ok {
+		g.mu.Lock()
+		g.rejected++
+		g.leaveLocked(key)
+		g.mu.Unlock()
+		return nil, &BulkheadFullError{Kind: g.kind, Key: key, Limit: cap(bulkhead.slots)}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			release()
+			g.mu.Lock()
+			g.leaveLocked(key)
+			g.mu.Unlock()
+		})
+	}, nil
+}
+
+func (g *bulkheadGroup) leaveLocked(key string) {
+	g.users[key]--
+	if g.users[key] <= 0 {
+		delete(g.users, key)
+		if g.prune {
+			delete(g.bulkheads, key)
+		}
+	}
+}
+
+// stats returns each bulkhead's stats ordered by key, and the group's total
+// rejections
+func (g *bulkheadGroup) stats() ([]BulkheadStats, int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := make([]BulkheadStats, 0, len(g.bulkheads))
+	for key, bulkhead := range g.bulkheads {
+		entry := bulkhead.Stats()
+		entry.Kind, entry.Key = g.kind, key
+		stats = append(stats, entry)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Key < stats[j].Key })
+	return stats, g.rejected
+}
+
+// acquireTenantBulkhead takes a slot in the tenant's bulkhead for a
+// verification; the DP's is taken per attempt in attemptProvider
+func (s *DPConnectorService) acquireTenantBulkhead(ctx context.Context, rpID string) (func(), error) {
+	if rpID == "" {
+		return func() {}, nil
+	}
+	return s.tenantBulkheads.acquire(ctx, rpID, s.config.TenantMaxConcurrent)
+}
+
+// acquireDPBulkhead takes a slot in the DP's bulkhead, limited by the
+// provider's max_concurrent or DP_MAX_CONCURRENT
+func (s *DPConnectorService) acquireDPBulkhead(ctx context.Context, provider *DPProvider) (func(), error) {
+	limit := s.config.DPMaxConcurrent
+	if provider.MaxConcurrent > 0 {
+		limit = provider.MaxConcurrent
+	}
+	return s.dpBulkheads.acquire(ctx, provider.DPID, limit)
+}
+
+// GetBulkheadStats returns the saturation of the DP and tenant bulkheads
+func (s *DPConnectorService) GetBulkheadStats() map[string]interface{} {
+	dpStats, dpRejected := s.dpBulkheads.stats()
+	tenantStats, tenantRejected := s.tenantBulkheads.stats()
+	return map[string]interface{}{
+		"dp_max_concurrent":     s.config.DPMaxConcurrent,
+		"tenant_max_concurrent": s.config.TenantMaxConcurrent,
+		"dp":                    dpStats,
+		"dp_rejected":           dpRejected,
+		"tenants_in_flight":     len(tenantStats),
+		"tenant_rejected":       tenantRejected,
+	}
+}
+
+// BulkheadMetrics returns bulkhead saturation as metrics: per DP, and for
+// tenants in aggregate to keep label cardinality bounded
+func (s *DPConnectorService) BulkheadMetrics() []Metric {
+	now := time.Now()
+	dpStats, dpRejected := s.dpBulkheads.stats()
+	tenantStats, tenantRejected := s.tenantBulkheads.stats()
+
+	// Series sharing a name are kept together for the Prometheus output
+	var metrics []Metric
+	for _, entry := range dpStats {
+		metrics = append(metrics, Metric{Name: "core_broker_dp_bulkhead_in_flight", Type: MetricTypeGauge, Value: float64(entry.InFlight),
+			Labels: map[string]string{"dp_id": entry.Key}, Help: "DP calls in flight", Time: now})
+	}
+	for _, entry := range dpStats {
+		metrics = append(metrics, Metric{Name: "core_broker_dp_bulkhead_saturation", Type: MetricTypeGauge, Value: entry.Saturation,
+			Labels: map[string]string{"dp_id": entry.Key}, Help: "Share of the DP's concurrent call limit in use", Time: now})
+	}
+	saturatedTenants := 0
+	for _, entry := range tenantStats {
+		if entry.InFlight >= entry.Limit {
+			saturatedTenants++
+		}
+	}
+	metrics = append(metrics,
+		Metric{Name: "core_broker_dp_bulkhead_rejections_total", Type: MetricTypeCounter, Value: float64(dpRejected), Help: "DP calls rejected by a full bulkhead", Time: now},
+		Metric{Name: "core_broker_tenant_bulkhead_active", Type: MetricTypeGauge, Value: float64(len(tenantStats)), Help: "Tenants with verifications in flight", Time: now},
+		Metric{Name: "core_broker_tenant_bulkhead_saturated", Type: MetricTypeGauge, Value: float64(saturatedTenants), Help: "Tenants at their concurrent verification limit", Time: now},
+		Metric{Name: "core_broker_tenant_bulkhead_rejections_total", Type: MetricTypeCounter, Value: float64(tenantRejected), Help: "Verifications rejected by a full tenant bulkhead", Time: now},
+	)
+	return metrics
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestBulkhead_AcquireAndRelease(t *testing.T) {
+	bulkhead := NewBulkhead(1, 0)
+	release, ok := bulkhead.Acquire(context.Background())
+	if !ok {
+		t.Fatal("Expected the first call to get a slot")
+	}
+	if _, ok := bulkhead.Acquire(context.Background()); ok {
+		t.Error("Expected a full bulkhead to reject without waiting")
+	}
+	if stats := bulkhead.Stats(); stats.InFlight != 1 || stats.Saturation != 1 || stats.Rejected != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// A waiting call gets the slot once it is released
+	waiting := NewBulkhead(1, time.Second)
+	releaseWaiting, _ := waiting.Acquire(context.Background())
+	time.AfterFunc(10*time.Millisecond, releaseWaiting)
+	if _, ok := waiting.Acquire(context.Background()); !ok {
+		t.Error("Expected the waiting call to get the released slot")
+	}
+	release()
+}
+
+func TestDPConnectorService_BulkheadsIsolateDPsAndTenants(t *testing.T) {
+	block := make(chan struct{})
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":              "j",
+			"status":              "completed",
+			"timestamp":           "2026-01-01T00:00:00Z",
+			"verification_result": map[string]interface{}{"verified": true, "confidence": 0.9},
+		})
+	}))
+	defer dp.Close()
+	defer close(block)
+
+	cfg := &config.Config{DPConnectorURL: dp.URL, TenantMaxConcurrent: 1, DPMaxConcurrent: 10}
+	service := NewDPConnectorService(cfg)
+	service.registry = NewDPRegistry()
+	service.registry.Register(&DPProvider{DPID: "slow_dp", Endpoint: dp.URL, SupportedClaims: []string{"age_verification"}, MaxConcurrent: 2})
+
+	verify := func(rpID string) chan error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: rpID, ClaimType: "age_verification"})
+			done <- err
+		}()
+		return done
+	}
+	waitInFlight := func(want int) {
+		for i := 0; i < 100; i++ {
+			if stats, _ := service.dpBulkheads.stats(); len(stats) == 1 && stats[0].InFlight == want {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("Expected %d calls in flight", want)
+	}
+
+	verify("rp_heavy")
+	waitInFlight(1)
+
+	// The tenant's second concurrent verification is rejected
+	var bulkheadErr *BulkheadFullError
+	if err := <-verify("rp_heavy"); !errors.As(err, &bulkheadErr) || bulkheadErr.Kind != BulkheadKindTenant {
+		t.Fatalf("Expected a tenant bulkhead rejection, got %v", err)
+	}
+
+	// Another tenant is unaffected until the DP's own limit is reached
+	verify("rp_other")
+	waitInFlight(2)
+	if err := <-verify("rp_third"); !errors.As(err, &bulkheadErr) || bulkheadErr.Kind != BulkheadKindDP {
+		t.Fatalf("Expected a DP bulkhead rejection, got %v", err)
+	}
+	if breaker := service.providerBreaker("slow_dp"); breaker.failureCount != 0 {
+		t.Errorf("Expected bulkhead rejections not to count against the breaker, got %d", breaker.failureCount)
+	}
+
+	stats := service.GetBulkheadStats()
+	if stats["tenant_rejected"] != int64(1) || stats["dp_rejected"] != int64(1) || stats["tenants_in_flight"] != 2 {
+		t.Errorf("Unexpected bulkhead stats %v", stats)
+	}
+	names := make(map[string]bool)
+	for _, metric := range service.BulkheadMetrics() {
+		names[metric.Name] = true
+	}
+	if !names["core_broker_dp_bulkhead_saturation"] || !names["core_broker_tenant_bulkhead_rejections_total"] {
+		t.Errorf("Expected saturation metrics, got %v", names)
+	}
+}
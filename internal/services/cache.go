@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -15,6 +16,11 @@ import (
 type CacheService struct {
 	config *config.Config
 	client *redis.Client
+	// consent, when set, keeps results cached before a consent revocation
+	// from being served
+	consent *ConsentService
+	// registry, when set, holds the claim types' freshness policies
+	registry *DPRegistry
 	// Cache metrics
 	hitCount   int64
 	missCount  int64
@@ -73,12 +79,24 @@ func (s *CacheService) GetVerificationResult(req models.VerificationRequest) *mo
 		return nil
 	}
 
+	// Results cached before the user revoked consent are never served, even
+	// if deleting them on revocation failed
+	if s.revokedSince(req, &response) {
+		s.client.Del(ctx, key, s.validatorsKey(req))
+		s.missCount++
+		return nil
+	}
+
 	// Check if expired
 	if response.ExpiresAt != "" {
 		expiresAt, err := time.Parse(time.RFC3339, response.ExpiresAt)
 		if err == nil && time.Now().After(expiresAt) {
-			// Remove expired entry
-			s.client.Del(ctx, key)
+			// Remove expired entry, unless the DP's validators let it be
+			// revalidated with a conditional request, or it may be served
+			// stale during an outage
+			if exists, _ := s.client.Exists(ctx, s.validatorsKey(req)).Result(); exists == 0 && !s.staleAllowed(req) {
+				s.client.Del(ctx, key)
+			}
 			s.missCount++
 			return nil
 		}
@@ -89,7 +107,8 @@ func (s *CacheService) GetVerificationResult(req models.VerificationRequest) *mo
 	return &response
 }
 
-// CacheVerificationResult stores a verification result in cache with 90-day TTL
+// CacheVerificationResult stores a verification result in cache for its
+// claim type's freshness, or CACHE_TTL (90 days)
 func (s *CacheService) CacheVerificationResult(req models.VerificationRequest, response *models.VerificationResponse) {
 	ctx := context.Background()
 	key := s.generateCacheKey(req)
@@ -102,8 +121,7 @@ func (s *CacheService) CacheVerificationResult(req models.VerificationRequest, r
 		return
 	}
 
-	// Set in Redis with 90-day TTL (T-020 requirement)
-	ttl := 90 * 24 * time.Hour // 90 days
+	ttl := s.cacheTTL(ctx, req)
 	err = s.client.Set(ctx, key, data, ttl).Err()
 	if err != nil {
 		fmt.Printf("CACHE ERROR: Failed to cache response: %v\n", err)
@@ -111,8 +129,150 @@ func (s *CacheService) CacheVerificationResult(req models.VerificationRequest, r
 		return
 	}
 
-	fmt.Printf("CACHE: Cached verification result for RP %s, User %s, Claim %s (TTL: 90 days)\n",
-		req.RPID, req.UserID, req.ClaimType)
+	fmt.Printf("CACHE: Cached verification result for RP %s, User %s, Claim %s (TTL: %s)\n",
+		req.RPID, req.UserID, req.ClaimType, ttl)
+}
+
+// cacheTTL is how long a result is kept: its claim type's freshness, or its
+// stale_if_outage when longer, capped at CACHE_TTL, which never exceeds how
+// long consent revocations are remembered. Results the DP can revalidate
+// are kept for CACHE_TTL so they can be refreshed conditionally once
+// expired.
+func (s *CacheService) cacheTTL(ctx context.Context, req models.VerificationRequest) time.Duration {
+	ttl := s.config.CacheTTL
+	if ttl <= 0 || ttl > consentRevocationRetention {
+		ttl = consentRevocationRetention
+	}
+	if s.registry == nil {
+		return ttl
+	}
+	policy, exists := s.registry.Freshness(req.ClaimType)
+	keep := time.Duration(policy.MaxAge)
+	if stale := time.Duration(policy.StaleIfOutage); stale > keep {
+		keep = stale
+	}
+	if !exists || policy.Forever || keep >= ttl {
+		return ttl
+	}
+	if revalidatable, _ := s.client.Exists(ctx, s.validatorsKey(req)).Result(); revalidatable > 0 {
+		return ttl
+	}
+	return keep
+}
+
+// staleAllowed reports whether the request's claim type lets expired
+// results be served during an outage
+func (s *CacheService) staleAllowed(req models.VerificationRequest) bool {
+	if s.registry == nil {
+		return false
+	}
+	policy, exists := s.registry.Freshness(req.ClaimType)
+	return exists && policy.StaleIfOutage > 0
+}
+
+// GetStaleResult returns a cached result, expired or not, that its claim
+// type's stale_if_outage lets be served while the DP is unavailable, or nil
+// if there is none
+func (s *CacheService) GetStaleResult(req models.VerificationRequest) *models.VerificationResponse {
+	if s.registry == nil {
+		return nil
+	}
+	policy, exists := s.registry.Freshness(req.ClaimType)
+	if !exists || policy.StaleIfOutage <= 0 {
+		return nil
+	}
+
+	result, err := s.client.Get(context.Background(), s.generateCacheKey(req)).Result()
+	if err != nil {
+		return nil
+	}
+	var response models.VerificationResponse
+	if err := json.Unmarshal([]byte(result), &response); err != nil {
+		return nil
+	}
+	if s.revokedSince(req, &response) {
+		return nil
+	}
+	verifiedAt, err := time.Parse(time.RFC3339, response.Timestamp)
+	if err != nil || !policy.ServesStale(verifiedAt, time.Now()) {
+		return nil
+	}
+	return &response
+}
+
+// CacheVerificationValidators stores the DP's ETag and Last-Modified with a
+// cached verification result, for conditional refreshes
+func (s *CacheService) CacheVerificationValidators(req models.VerificationRequest, validators *DPValidators) {
+	ctx := context.Background()
+	if validators == nil {
+		s.client.Del(ctx, s.validatorsKey(req))
+		return
+	}
+
+	data, err := json.Marshal(validators)
+	if err != nil {
+		fmt.Printf("CACHE ERROR: Failed to serialize validators: %v\n", err)
+		s.errorCount++
+		return
+	}
+	if err := s.client.Set(ctx, s.validatorsKey(req), data, 90*24*time.Hour).Err(); err != nil {
+		fmt.Printf("CACHE ERROR: Failed to cache validators: %v\n", err)
+		s.errorCount++
+	}
+}
+
+// GetRevalidationCandidate returns an expired cached result together with
+// the validators to refresh it conditionally, or nil if there is none
+func (s *CacheService) GetRevalidationCandidate(req models.VerificationRequest) (*models.VerificationResponse, *DPValidators) {
+	ctx := context.Background()
+
+	data, err := s.client.Get(ctx, s.validatorsKey(req)).Result()
+	if err != nil {
+		return nil, nil
+	}
+	var validators DPValidators
+	if err := json.Unmarshal([]byte(data), &validators); err != nil {
+		return nil, nil
+	}
+
+	result, err := s.client.Get(ctx, s.generateCacheKey(req)).Result()
+	if err != nil {
+		return nil, nil
+	}
+	var response models.VerificationResponse
+	if err := json.Unmarshal([]byte(result), &response); err != nil {
+		return nil, nil
+	}
+	if s.revokedSince(req, &response) {
+		return nil, nil
+	}
+	return &response, &validators
+}
+
+// ExtendVerificationResult renews a cached result the DP confirmed unchanged
+// for another validity period, instead of replacing it with a new
+// verification
+func (s *CacheService) ExtendVerificationResult(req models.VerificationRequest, response *models.VerificationResponse, validators *DPValidators) {
+	now := time.Now()
+	validity := 24 * time.Hour
+	issued, issuedErr := time.Parse(time.RFC3339, response.Timestamp)
+	expires, expiresErr := time.Parse(time.RFC3339, response.ExpiresAt)
+	if issuedErr == nil && expiresErr == nil && expires.After(issued) {
+		validity = expires.Sub(issued)
+	}
+	response.ExpiresAt = now.Add(validity).Format(time.RFC3339)
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["revalidated_at"] = now.Format(time.RFC3339)
+
+	s.CacheVerificationValidators(req, validators)
+	s.CacheVerificationResult(req, response)
+}
+
+// validatorsKey is where a cached result's DP validators are stored
+func (s *CacheService) validatorsKey(req models.VerificationRequest) string {
+	return s.generateCacheKey(req) + ":validators"
 }
 
 // generateCacheKey creates a cache key for a verification request
@@ -187,7 +347,7 @@ func (s *CacheService) InvalidateVerificationCache(req models.VerificationReques
 	ctx := context.Background()
 	key := s.generateCacheKey(req)
 
-	err := s.client.Del(ctx, key).Err()
+	err := s.client.Del(ctx, key, s.validatorsKey(req)).Err()
 	if err != nil {
 		fmt.Printf("CACHE ERROR: Failed to invalidate cache for key %s: %v\n", key, err)
 		s.errorCount++
@@ -199,6 +359,86 @@ func (s *CacheService) InvalidateVerificationCache(req models.VerificationReques
 	return nil
 }
 
+// SetConsentService subscribes the cache to consent revocations: a
+// revocation deletes the subject's cached results for the RP before Revoke
+// returns, and results cached before a revocation are treated as misses
+func (s *CacheService) SetConsentService(consent *ConsentService) {
+	s.consent = consent
+	consent.OnRevoke(func(ctx context.Context, revocation ConsentRevocation) error {
+		_, err := s.InvalidateSubject(ctx, revocation.RPID, revocation.UserID, revocation.ClaimTypes)
+		return err
+	})
+}
+
+// SetDPRegistry sets the registry whose freshness policies bound how long
+// each claim type's results are cached
+func (s *CacheService) SetDPRegistry(registry *DPRegistry) {
+	s.registry = registry
+}
+
+// InvalidateSubject deletes a user's cached verification results and
+// validators for an RP, for the given claim types or all of them, and
+// returns how many keys were deleted
+func (s *CacheService) InvalidateSubject(ctx context.Context, rpID, userID string, claimTypes []string) (int, error) {
+	var keys []string
+	if len(claimTypes) > 0 {
+		for _, claimType := range claimTypes {
+			req := models.VerificationRequest{RPID: rpID, UserID: userID, ClaimType: claimType}
+			keys = append(keys, s.generateCacheKey(req), s.validatorsKey(req))
+		}
+	} else {
+		pattern := fmt.Sprintf("verification:%s:%s:*", escapeRedisPattern(rpID), escapeRedisPattern(userID))
+		iter := s.client.Scan(ctx, 0, pattern, 500).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			s.errorCount++
+			return 0, fmt.Errorf("failed to find cached results: %w", err)
+		}
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	deleted, err := s.client.Del(ctx, keys...).Result()
+	if err != nil {
+		fmt.Printf("CACHE ERROR: Failed to invalidate results for RP %s, User %s: %v\n", rpID, userID, err)
+		s.errorCount++
+		return 0, err
+	}
+	fmt.Printf("CACHE: Invalidated %d entries for RP %s, User %s after consent revocation\n", deleted, rpID, userID)
+	return int(deleted), nil
+}
+
+// revokedSince reports whether consent for a cached result was revoked after
+// it was issued. Timestamps have second precision, so a result issued in the
+// same second as the revocation counts as revoked.
+func (s *CacheService) revokedSince(req models.VerificationRequest, response *models.VerificationResponse) bool {
+	if s.consent == nil {
+		return false
+	}
+	revokedAt := s.consent.RevokedAt(req.RPID, req.UserID, req.ClaimType)
+	if revokedAt.IsZero() {
+		return false
+	}
+	issued, err := time.Parse(time.RFC3339, response.Timestamp)
+	return err != nil || !issued.After(revokedAt.Truncate(time.Second))
+}
+
+// escapeRedisPattern escapes glob characters so a value matches literally
+// in a SCAN pattern
+func escapeRedisPattern(value string) string {
+	var escaped strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune(`*?[]^\`, r) {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
+
 // InvalidateCacheByPattern invalidates cache entries matching a pattern
 func (s *CacheService) InvalidateCacheByPattern(pattern string) error {
 	ctx := context.Background()
@@ -230,6 +470,45 @@ func (s *CacheService) InvalidateCacheByPattern(pattern string) error {
 	return nil
 }
 
+// FlushVerificationCache deletes every cached verification result and its
+// validators, leaving other keys in the database alone, and returns how many
+// keys were deleted
+func (s *CacheService) FlushVerificationCache(ctx context.Context) (int, error) {
+	deleted := 0
+	iter := s.client.Scan(ctx, 0, "verification:*", 500).Iterator()
+	var keys []string
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		if err := s.client.Del(ctx, keys...).Err(); err != nil {
+			s.errorCount++
+			return err
+		}
+		deleted += len(keys)
+		keys = keys[:0]
+		return nil
+	}
+
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 500 {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	if err := flush(); err != nil {
+		return deleted, err
+	}
+
+	fmt.Printf("CACHE: Flushed %d verification cache entries\n", deleted)
+	return deleted, nil
+}
+
 // GetCacheMetrics returns cache performance metrics
 func (s *CacheService) GetCacheMetrics() map[string]interface{} {
 	totalRequests := s.hitCount + s.missCount
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"sort"
+	"time"
+)
+
+// Latency sources reported in catalog entries
+const (
+	LatencySourceObserved = "observed" // median of recent DP responses
+	LatencySourceDeclared = "declared" // the schema's typical latency
+)
+
+// ClaimCatalog lists the claim types available to a tenant
+type ClaimCatalog struct {
+	TenantID    string              `json:"tenant_id"`
+	ClaimTypes  []ClaimCatalogEntry `json:"claim_types"`
+	GeneratedAt time.Time           `json:"generated_at"`
+}
+
+// ClaimCatalogEntry describes one claim type for RP developer portals
+type ClaimCatalogEntry struct {
+	ClaimType           string                     `json:"claim_type"`
+	Description         string                     `json:"description"`
+	RequiredIdentifiers []string                   `json:"required_identifiers"`
+	OptionalIdentifiers []string                   `json:"optional_identifiers,omitempty"`
+	DisclosureOptions   map[string]DisclosureLevel `json:"disclosure_options"`
+	TypicalLatencyMs    int64                      `json:"typical_latency_ms"`
+	LatencySource       string                     `json:"latency_source"`
+	DPCategories        []string                   `json:"dp_categories"`
+	ProviderCount       int                        `json:"provider_count"`
+	// Lifecycle is set for deprecated and retired claim types, with the
+	// guidance for migrating away from them
+	Lifecycle *ClaimDeprecationNotice `json:"lifecycle,omitempty"`
+}
+
+// ClaimCatalogService builds claim catalogs from the schema registry, the DP
+// registry and each tenant's permissions
+type ClaimCatalogService struct {
+	schemas       *ClaimSchemaRegistry
+	dpService     *DPConnectorService
+	authorization *AuthorizationService
+}
+
+// NewClaimCatalogService creates a new claim catalog service
+func NewClaimCatalogService(schemas *ClaimSchemaRegistry, dpService *DPConnectorService, authorization *AuthorizationService) *ClaimCatalogService {
+	return &ClaimCatalogService{
+		schemas:       schemas,
+		dpService:     dpService,
+		authorization: authorization,
+	}
+}
+
+// Catalog returns the claim types an RP may request and that at least one
+// enabled DP can answer. Retired claim types stay listed so RPs can find
+// their migration guidance.
+func (s *ClaimCatalogService) Catalog(rpID string) *ClaimCatalog {
+	catalog := &ClaimCatalog{
+		TenantID:    rpID,
+		ClaimTypes:  make([]ClaimCatalogEntry, 0),
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	for _, schema := range s.schemas.List() {
+		if !s.authorization.AllowsClaimType(rpID, schema.ClaimType) {
+			continue
+		}
+		providers := s.dpService.Registry().ProvidersForClaim(schema.ClaimType)
+		if len(providers) == 0 && schema.LifecycleState(catalog.GeneratedAt) != ClaimStateRetired {
+			continue
+		}
+		catalog.ClaimTypes = append(catalog.ClaimTypes, s.entry(schema, providers, catalog.GeneratedAt))
+	}
+
+	return catalog
+}
+
+// entry combines a schema with what is known about the providers serving it
+func (s *ClaimCatalogService) entry(schema *ClaimTypeSchema, providers []*DPProvider, now time.Time) ClaimCatalogEntry {
+	entry := ClaimCatalogEntry{
+		ClaimType:           schema.ClaimType,
+		Description:         schema.Description,
+		RequiredIdentifiers: schema.RequiredIdentifiers,
+		OptionalIdentifiers: schema.OptionalIdentifiers,
+		DisclosureOptions:   schema.Attributes,
+		TypicalLatencyMs:    schema.TypicalLatencyMs,
+		LatencySource:       LatencySourceDeclared,
+		ProviderCount:       len(providers),
+		Lifecycle:           schema.DeprecationNotice(now),
+	}
+
+	categories := make(map[string]bool)
+	for _, provider := range providers {
+		category := provider.Category
+		if category == "" {
+			category = schema.DPCategory
+		}
+		if category != "" {
+			categories[category] = true
+		}
+	}
+	for category := range categories {
+		entry.DPCategories = append(entry.DPCategories, category)
+	}
+	sort.Strings(entry.DPCategories)
+
+	// Retired claim types may no longer have providers
+	if len(providers) == 0 {
+		return entry
+	}
+
+	// Requests go to the highest priority provider first, so its observed
+	// latency is what an RP will typically see
+	if latency, ok := s.dpService.ObservedLatency(providers[0].DPID); ok {
+		entry.TypicalLatencyMs = latency.Milliseconds()
+		entry.LatencySource = LatencySourceObserved
+	}
+
+	return entry
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func newTestCatalogService(t *testing.T, providers ...*DPProvider) (*ClaimCatalogService, *DPConnectorService) {
+	t.Helper()
+	cfg := &config.Config{
+		DPConnectorURL: "http://localhost:8081",
+		DPTimeout:      time.Second,
+		OPAURL:         "http://invalid-opa-url:8181",
+	}
+	dpService := NewDPConnectorService(cfg)
+	if len(providers) > 0 {
+		dpService.registry = NewDPRegistry()
+		for _, provider := range providers {
+			if err := dpService.registry.Register(provider); err != nil {
+				t.Fatalf("Failed to register provider: %v", err)
+			}
+		}
+	}
+	authorization := NewAuthorizationService(cfg, NewPolicyService(cfg))
+	return NewClaimCatalogService(NewClaimSchemaRegistry(), dpService, authorization), dpService
+}
+
+func TestClaimCatalog_DefaultProviderServesAllClaims(t *testing.T) {
+	service, _ := newTestCatalogService(t)
+
+	catalog := service.Catalog("rp_1")
+	if catalog.TenantID != "rp_1" {
+		t.Errorf("Expected tenant rp_1, got %s", catalog.TenantID)
+	}
+	if len(catalog.ClaimTypes) != len(DefaultClaimSchemas()) {
+		t.Fatalf("Expected %d claim types, got %d", len(DefaultClaimSchemas()), len(catalog.ClaimTypes))
+	}
+
+	for _, entry := range catalog.ClaimTypes {
+		if entry.Description == "" || len(entry.RequiredIdentifiers) == 0 || len(entry.DisclosureOptions) == 0 {
+			t.Errorf("Expected %s to be fully described, got %+v", entry.ClaimType, entry)
+		}
+		if entry.LatencySource != LatencySourceDeclared {
+			t.Errorf("Expected declared latency for %s, got %s", entry.ClaimType, entry.LatencySource)
+		}
+		// The default provider declares no category, so the schema's is used
+		if len(entry.DPCategories) != 1 || entry.DPCategories[0] != builtinClaimSchemas[entry.ClaimType].DPCategory {
+			t.Errorf("Unexpected DP categories for %s: %v", entry.ClaimType, entry.DPCategories)
+		}
+	}
+}
+
+func TestClaimCatalog_OnlyClaimsWithProviders(t *testing.T) {
+	service, dpService := newTestCatalogService(t,
+		&DPProvider{DPID: "uni-a", Endpoint: "https://uni-a.example", SupportedClaims: []string{"student_verification"}, Category: "university"},
+		&DPProvider{DPID: "uni-b", Endpoint: "https://uni-b.example", SupportedClaims: []string{"student_verification"}, Priority: 1},
+	)
+
+	for i := 0; i < minHedgeSamples; i++ {
+		dpService.latencies.Record("uni-a", 300*time.Millisecond)
+	}
+
+	catalog := service.Catalog("rp_1")
+	if len(catalog.ClaimTypes) != 1 {
+		t.Fatalf("Expected only student_verification, got %+v", catalog.ClaimTypes)
+	}
+
+	entry := catalog.ClaimTypes[0]
+	if entry.ProviderCount != 2 {
+		t.Errorf("Expected 2 providers, got %d", entry.ProviderCount)
+	}
+	if len(entry.DPCategories) != 2 || entry.DPCategories[0] != "education" || entry.DPCategories[1] != "university" {
+		t.Errorf("Unexpected DP categories: %v", entry.DPCategories)
+	}
+	if entry.LatencySource != LatencySourceObserved || entry.TypicalLatencyMs != 300 {
+		t.Errorf("Expected observed latency of 300ms, got %d (%s)", entry.TypicalLatencyMs, entry.LatencySource)
+	}
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// FreshnessPolicy bounds how long a claim type's results stay valid. A
+// result expires MaxAge after the DP verified it, e.g. 24h for an
+// enrollment status; Forever results, such as an age threshold once
+// passed, do not expire. StaleIfOutage lets a result up to that old be
+// served once expired, marked stale, while the claim type's DP is
+// unavailable.
+type FreshnessPolicy struct {
+	MaxAge        config.Duration `json:"max_age,omitempty"`
+	Forever       bool            `json:"forever,omitempty"`
+	StaleIfOutage config.Duration `json:"stale_if_outage,omitempty"`
+}
+
+// Validate checks that a policy sets exactly one of MaxAge and Forever
+func (p FreshnessPolicy) Validate() error {
+	if p.Forever == (p.MaxAge != 0) {
+		return fmt.Errorf("freshness policies set either max_age or forever")
+	}
+	if p.MaxAge < 0 {
+		return fmt.Errorf("freshness max_age must be positive")
+	}
+	if p.StaleIfOutage < 0 {
+		return fmt.Errorf("freshness stale_if_outage must be positive")
+	}
+	return nil
+}
+
+// ServesStale reports whether a result verified at the given time may still
+// be served stale during an outage
+func (p FreshnessPolicy) ServesStale(verifiedAt, now time.Time) bool {
+	return p.StaleIfOutage > 0 && !now.After(verifiedAt.Add(time.Duration(p.StaleIfOutage)))
+}
+
+// ExpiresAt returns when a result verified at the given time expires, or
+// the zero time if it does not
+func (p FreshnessPolicy) ExpiresAt(verifiedAt time.Time) time.Time {
+	if p.Forever {
+		return time.Time{}
+	}
+	return verifiedAt.Add(time.Duration(p.MaxAge))
+}
+
+// SetFreshness replaces the registry's freshness policies, keyed by claim
+// type ("*" for every claim type without its own)
+func (r *DPRegistry) SetFreshness(policies map[string]FreshnessPolicy) error {
+	for claimType, policy := range policies {
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("claim type %s: %w", claimType, err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.freshness = policies
+	return nil
+}
+
+// Freshness returns the freshness policy of a claim type, if it has one
+func (r *DPRegistry) Freshness(claimType string) (FreshnessPolicy, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if policy, exists := r.freshness[claimType]; exists {
+		return policy, true
+	}
+	policy, exists := r.freshness[AnyClaimType]
+	return policy, exists
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestLoadDPRegistry_Freshness(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	os.WriteFile(path, []byte(`{
+		"providers": [{"dp_id": "dp_university", "endpoint": "https://university.example.com", "supported_claims": ["*"]}],
+		"freshness": {"student_verification": {"max_age": "24h"}, "age_verification": {"forever": true}, "*": {"max_age": "720h"}}
+	}`), 0600)
+
+	registry, err := LoadDPRegistry(&config.Config{DPRegistryFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy, exists := registry.Freshness("student_verification"); !exists || time.Duration(policy.MaxAge) != 24*time.Hour {
+		t.Errorf("Expected a 24h policy, got %+v", policy)
+	}
+	if policy, _ := registry.Freshness("age_verification"); !policy.Forever || !policy.ExpiresAt(time.Now()).IsZero() {
+		t.Errorf("Expected age results to never expire, got %+v", policy)
+	}
+	if policy, _ := registry.Freshness("employee_verification"); time.Duration(policy.MaxAge) != 720*time.Hour {
+		t.Errorf("Expected the wildcard policy, got %+v", policy)
+	}
+
+	for _, invalid := range []string{`{}`, `{"max_age": "24h", "forever": true}`, `{"max_age": "-1h"}`, `{"max_age": "24h", "stale_if_outage": "-1h"}`} {
+		os.WriteFile(path, []byte(`{"providers": [{"dp_id": "dp", "endpoint": "https://dp.example.com", "supported_claims": ["*"]}],
+			"freshness": {"student_verification": `+invalid+`}}`), 0600)
+		if _, err := LoadDPRegistry(&config.Config{DPRegistryFile: path}); err == nil {
+			t.Errorf("Expected freshness policy %s to be rejected", invalid)
+		}
+	}
+}
+
+func TestResponseParserService_FreshnessExpiration(t *testing.T) {
+	service := NewResponseParserService(&config.Config{})
+	response := &DPResponse{
+		JobID:              "job_1",
+		DPID:               "dp_university",
+		Status:             DPStatusCompleted,
+		Timestamp:          "2026-01-01T00:00:00Z",
+		VerificationResult: &VerificationResult{Verified: true, Confidence: 0.9},
+	}
+
+	parsed, _ := service.ParseAndValidateResponse(response)
+	if parsed.ExpirationTime != "2026-01-02T00:00:00Z" {
+		t.Errorf("Expected results to expire after 24 hours by default, got %s", parsed.ExpirationTime)
+	}
+
+	response.Freshness = &FreshnessPolicy{MaxAge: config.Duration(time.Hour)}
+	parsed, _ = service.ParseAndValidateResponse(response)
+	if parsed.ExpirationTime != "2026-01-01T01:00:00Z" {
+		t.Errorf("Expected the claim type's max age, got %s", parsed.ExpirationTime)
+	}
+
+	response.Freshness = &FreshnessPolicy{Forever: true}
+	parsed, _ = service.ParseAndValidateResponse(response)
+	if parsed.ExpirationTime != "" {
+		t.Errorf("Expected no expiration, got %s", parsed.ExpirationTime)
+	}
+}
+
+func TestCacheService_FreshnessTTL(t *testing.T) {
+	cache := NewCacheService(&config.Config{CacheTTL: 30 * 24 * time.Hour, Redis: config.RedisConfig{Host: "127.0.0.1", Port: 1}})
+	req := models.VerificationRequest{RPID: "rp_1", UserID: "user_1", ClaimType: "student_verification"}
+	if ttl := cache.cacheTTL(context.Background(), req); ttl != 30*24*time.Hour {
+		t.Errorf("Expected CACHE_TTL without a policy, got %s", ttl)
+	}
+
+	registry := NewDPRegistry()
+	registry.SetFreshness(map[string]FreshnessPolicy{
+		"student_verification":  {MaxAge: config.Duration(24 * time.Hour)},
+		"age_verification":      {Forever: true},
+		"address_verification":  {MaxAge: config.Duration(365 * 24 * time.Hour)},
+		"employee_verification": {MaxAge: config.Duration(24 * time.Hour), StaleIfOutage: config.Duration(72 * time.Hour)},
+	})
+	cache.SetDPRegistry(registry)
+	for claimType, expected := range map[string]time.Duration{
+		"student_verification": 24 * time.Hour,
+		"age_verification":     30 * 24 * time.Hour,
+		"address_verification": 30 * 24 * time.Hour,
+		// Kept for as long as it may be served stale
+		"employee_verification": 72 * time.Hour,
+	} {
+		req.ClaimType = claimType
+		if ttl := cache.cacheTTL(context.Background(), req); ttl != expected {
+			t.Errorf("%s: expected a TTL of %s, got %s", claimType, expected, ttl)
+		}
+	}
+}
+
+func TestFreshnessPolicy_ServesStale(t *testing.T) {
+	verifiedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	policy := FreshnessPolicy{MaxAge: config.Duration(24 * time.Hour), StaleIfOutage: config.Duration(72 * time.Hour)}
+	if !policy.ServesStale(verifiedAt, verifiedAt.Add(48*time.Hour)) {
+		t.Error("Expected an expired result within stale_if_outage to be served")
+	}
+	if policy.ServesStale(verifiedAt, verifiedAt.Add(73*time.Hour)) {
+		t.Error("Expected a result older than stale_if_outage not to be served")
+	}
+	policy.StaleIfOutage = 0
+	if policy.ServesStale(verifiedAt, verifiedAt.Add(time.Hour)) {
+		t.Error("Expected no stale results without stale_if_outage")
+	}
+
+	cache := NewCacheService(&config.Config{Redis: config.RedisConfig{Host: "127.0.0.1", Port: 1}})
+	req := models.VerificationRequest{RPID: "rp_1", UserID: "user_1", ClaimType: "student_verification"}
+	if cache.GetStaleResult(req) != nil {
+		t.Error("Expected no stale result without a registry")
+	}
+	registry := NewDPRegistry()
+	registry.SetFreshness(map[string]FreshnessPolicy{"student_verification": {MaxAge: config.Duration(24 * time.Hour)}})
+	cache.SetDPRegistry(registry)
+	if cache.staleAllowed(req) || cache.GetStaleResult(req) != nil {
+		t.Error("Expected no stale result for a claim type without stale_if_outage")
+	}
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ClaimLifecycleState is where a claim type is in its deprecation workflow
+type ClaimLifecycleState string
+
+const (
+	ClaimStateActive     ClaimLifecycleState = "active"
+	ClaimStateDeprecated ClaimLifecycleState = "deprecated"
+	ClaimStateRetired    ClaimLifecycleState = "retired"
+)
+
+// maxClaimDeprecationEvents bounds the recent deprecation events kept
+const maxClaimDeprecationEvents = 100
+
+// ClaimLifecycle describes the deprecation of a claim type. Deprecated types
+// keep working until their sunset date and are retired automatically after it.
+type ClaimLifecycle struct {
+	State        ClaimLifecycleState `json:"state"`
+	DeprecatedAt *time.Time          `json:"deprecated_at,omitempty"`
+	SunsetDate   *time.Time          `json:"sunset_date,omitempty"`
+	// ReplacedBy names the claim type RPs should migrate to
+	ReplacedBy     string `json:"replaced_by,omitempty"`
+	MigrationGuide string `json:"migration_guide,omitempty"`
+}
+
+// Validate checks that a lifecycle is usable
+func (l *ClaimLifecycle) Validate() error {
+	switch l.State {
+	case ClaimStateActive, ClaimStateRetired:
+	case ClaimStateDeprecated:
+		if l.SunsetDate == nil {
+			return fmt.Errorf("deprecated claim types need a sunset date")
+		}
+		if l.DeprecatedAt != nil && l.SunsetDate.Before(*l.DeprecatedAt) {
+			return fmt.Errorf("sunset date is before the deprecation date")
+		}
+	default:
+		return fmt.Errorf("unknown lifecycle state %q", l.State)
+	}
+	return nil
+}
+
+// LifecycleState returns a schema's state at a point in time, retiring
+// deprecated types whose sunset date has passed
+func (s *ClaimTypeSchema) LifecycleState(now time.Time) ClaimLifecycleState {
+	if s.Lifecycle == nil || s.Lifecycle.State == "" {
+		return ClaimStateActive
+	}
+	if s.Lifecycle.State == ClaimStateDeprecated && !now.Before(*s.Lifecycle.SunsetDate) {
+		return ClaimStateRetired
+	}
+	return s.Lifecycle.State
+}
+
+// ClaimDeprecationNotice tells RPs that a claim type is going away and what
+// to use instead
+type ClaimDeprecationNotice struct {
+	ClaimType      string              `json:"claim_type"`
+	State          ClaimLifecycleState `json:"state"`
+	DeprecatedAt   *time.Time          `json:"deprecated_at,omitempty"`
+	SunsetDate     *time.Time          `json:"sunset_date,omitempty"`
+	ReplacedBy     string              `json:"replaced_by,omitempty"`
+	MigrationGuide string              `json:"migration_guide,omitempty"`
+}
+
+// DeprecationNotice returns the notice for a deprecated or retired claim
+// type, or nil while it is active
+func (s *ClaimTypeSchema) DeprecationNotice(now time.Time) *ClaimDeprecationNotice {
+	state := s.LifecycleState(now)
+	if state == ClaimStateActive {
+		return nil
+	}
+
+	return &ClaimDeprecationNotice{
+		ClaimType:      s.ClaimType,
+		State:          state,
+		DeprecatedAt:   s.Lifecycle.DeprecatedAt,
+		SunsetDate:     s.Lifecycle.SunsetDate,
+		ReplacedBy:     s.Lifecycle.ReplacedBy,
+		MigrationGuide: s.Lifecycle.MigrationGuide,
+	}
+}
+
+// ClaimDeprecationEvent is raised when an RP uses a deprecated or retired
+// claim type
+type ClaimDeprecationEvent struct {
+	RPID      string                 `json:"rp_id"`
+	Notice    ClaimDeprecationNotice `json:"notice"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// ClaimDeprecationTracker records which RPs still use deprecated claim types.
+// It raises an event the first time an RP uses a claim type in each state
+// and counts every use.
+type ClaimDeprecationTracker struct {
+	mu       sync.Mutex
+	notified map[string]bool
+	usage    map[string]map[string]int64
+	events   []ClaimDeprecationEvent
+	handler  func(ClaimDeprecationEvent)
+}
+
+// NewClaimDeprecationTracker creates a deprecation tracker
+func NewClaimDeprecationTracker() *ClaimDeprecationTracker {
+	return &ClaimDeprecationTracker{
+		notified: make(map[string]bool),
+		usage:    make(map[string]map[string]int64),
+	}
+}
+
+// SetEventHandler registers a callback for deprecation events
+func (t *ClaimDeprecationTracker) SetEventHandler(handler func(ClaimDeprecationEvent)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handler = handler
+}
+
+// Record notes that an RP used a deprecated or retired claim type
+func (t *ClaimDeprecationTracker) Record(rpID string, notice *ClaimDeprecationNotice) {
+	t.mu.Lock()
+	if t.usage[notice.ClaimType] == nil {
+		t.usage[notice.ClaimType] = make(map[string]int64)
+	}
+	t.usage[notice.ClaimType][rpID]++
+
+	key := rpID + "|" + notice.ClaimType + "|" + string(notice.State)
+	if t.notified[key] {
+		t.mu.Unlock()
+		return
+	}
+	t.notified[key] = true
+
+	event := ClaimDeprecationEvent{RPID: rpID, Notice: *notice, Timestamp: time.Now()}
+	t.events = append(t.events, event)
+	if len(t.events) > maxClaimDeprecationEvents {
+		t.events = t.events[len(t.events)-maxClaimDeprecationEvents:]
+	}
+	handler := t.handler
+	t.mu.Unlock()
+
+	if handler != nil {
+		handler(event)
+	}
+}
+
+// EventsForRP returns the recent deprecation events of one RP
+func (t *ClaimDeprecationTracker) EventsForRP(rpID string) []ClaimDeprecationEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	events := make([]ClaimDeprecationEvent, 0)
+	for _, event := range t.events {
+		if event.RPID == rpID {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// RPsUsing returns the RPs that used a deprecated claim type, ordered by ID,
+// with how often they did
+func (t *ClaimDeprecationTracker) RPsUsing(claimType string) ([]string, map[string]int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	counts := make(map[string]int64, len(t.usage[claimType]))
+	rpIDs := make([]string, 0, len(t.usage[claimType]))
+	for rpID, count := range t.usage[claimType] {
+		counts[rpID] = count
+		rpIDs = append(rpIDs, rpID)
+	}
+	sort.Strings(rpIDs)
+	return rpIDs, counts
+}
+
+// ClaimLifecycleFile is the on-disk format of claim type lifecycles
+type ClaimLifecycleFile struct {
+	ClaimTypes map[string]*ClaimLifecycle `json:"claim_types"`
+}
+
+// LoadClaimLifecycleFile sets the lifecycles of a file on the registered
+// schemas. The file is applied only if every lifecycle is valid.
+func (r *ClaimSchemaRegistry) LoadClaimLifecycleFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read claim lifecycle file: %w", err)
+	}
+
+	var file ClaimLifecycleFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse claim lifecycle file: %w", err)
+	}
+
+	updated := make([]*ClaimTypeSchema, 0, len(file.ClaimTypes))
+	for claimType, lifecycle := range file.ClaimTypes {
+		schema, exists := r.Get(claimType)
+		if !exists {
+			return fmt.Errorf("lifecycle for unknown claim type %s", claimType)
+		}
+		if lifecycle == nil {
+			return fmt.Errorf("lifecycle for %s is empty", claimType)
+		}
+
+		// Schemas are shared with readers, so replace rather than modify them
+		copied := *schema
+		copied.Lifecycle = lifecycle
+		if err := copied.Validate(); err != nil {
+			return err
+		}
+		updated = append(updated, &copied)
+	}
+
+	for _, schema := range updated {
+		if err := r.Register(schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClaimTypeSchema_LifecycleState(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	sunset := now.Add(30 * 24 * time.Hour)
+
+	schema := *DefaultClaimSchemas()[0]
+	if schema.LifecycleState(now) != ClaimStateActive || schema.DeprecationNotice(now) != nil {
+		t.Error("Expected schemas without a lifecycle to be active")
+	}
+
+	schema.Lifecycle = &ClaimLifecycle{State: ClaimStateDeprecated, SunsetDate: &sunset, ReplacedBy: "enrollment_verification"}
+	notice := schema.DeprecationNotice(now)
+	if notice == nil || notice.State != ClaimStateDeprecated || notice.ReplacedBy != "enrollment_verification" {
+		t.Errorf("Expected a deprecation notice, got %+v", notice)
+	}
+
+	// Deprecated claim types retire automatically at their sunset date
+	if schema.LifecycleState(sunset) != ClaimStateRetired {
+		t.Errorf("Expected the claim type to be retired at its sunset date, got %s", schema.LifecycleState(sunset))
+	}
+}
+
+func TestClaimLifecycle_Validate(t *testing.T) {
+	sunset := time.Now().Add(time.Hour)
+	deprecated := time.Now().Add(2 * time.Hour)
+
+	invalid := []*ClaimLifecycle{
+		{State: "sunsetting"},
+		{State: ClaimStateDeprecated},
+		{State: ClaimStateDeprecated, SunsetDate: &sunset, DeprecatedAt: &deprecated},
+	}
+	for _, lifecycle := range invalid {
+		if err := lifecycle.Validate(); err == nil {
+			t.Errorf("Expected lifecycle %+v to be rejected", lifecycle)
+		}
+	}
+
+	schema := *DefaultClaimSchemas()[0]
+	schema.Lifecycle = &ClaimLifecycle{State: ClaimStateRetired, ReplacedBy: schema.ClaimType}
+	if err := schema.Validate(); err == nil {
+		t.Error("Expected a claim type replacing itself to be rejected")
+	}
+}
+
+func TestClaimDeprecationTracker(t *testing.T) {
+	tracker := NewClaimDeprecationTracker()
+	var raised []ClaimDeprecationEvent
+	tracker.SetEventHandler(func(event ClaimDeprecationEvent) {
+		raised = append(raised, event)
+	})
+
+	sunset := time.Now().Add(time.Hour)
+	notice := &ClaimDeprecationNotice{ClaimType: "student_verification", State: ClaimStateDeprecated, SunsetDate: &sunset}
+	tracker.Record("rp-1", notice)
+	tracker.Record("rp-1", notice)
+	tracker.Record("rp-2", notice)
+
+	if len(raised) != 2 {
+		t.Errorf("Expected one event per RP, got %d", len(raised))
+	}
+	if events := tracker.EventsForRP("rp-1"); len(events) != 1 || events[0].Notice.ClaimType != "student_verification" {
+		t.Errorf("Unexpected events for rp-1: %+v", events)
+	}
+
+	rpIDs, counts := tracker.RPsUsing("student_verification")
+	if len(rpIDs) != 2 || rpIDs[0] != "rp-1" || counts["rp-1"] != 2 {
+		t.Errorf("Unexpected usage: %v %v", rpIDs, counts)
+	}
+
+	// Retirement is a new event for RPs that were already notified
+	retired := *notice
+	retired.State = ClaimStateRetired
+	tracker.Record("rp-1", &retired)
+	if len(raised) != 3 {
+		t.Errorf("Expected an event on retirement, got %d", len(raised))
+	}
+}
+
+func TestClaimSchemaRegistry_LoadClaimLifecycleFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lifecycle.json")
+	os.WriteFile(path, []byte(`{"claim_types": {"age_verification": {"state": "deprecated", "sunset_date": "2099-01-01T00:00:00Z", "replaced_by": "age_over_threshold", "migration_guide": "https://docs.example/age"}}}`), 0600)
+
+	registry := NewClaimSchemaRegistry()
+	if err := registry.LoadClaimLifecycleFile(path); err != nil {
+		t.Fatalf("LoadClaimLifecycleFile failed: %v", err)
+	}
+	schema, _ := registry.Get("age_verification")
+	if schema.LifecycleState(time.Now()) != ClaimStateDeprecated || schema.Lifecycle.MigrationGuide == "" {
+		t.Errorf("Expected age_verification to be deprecated, got %+v", schema.Lifecycle)
+	}
+
+	// Invalid files leave every claim type untouched
+	os.WriteFile(path, []byte(`{"claim_types": {"student_verification": {"state": "retired"}, "employee_verification": {"state": "deprecated"}}}`), 0600)
+	if err := registry.LoadClaimLifecycleFile(path); err == nil {
+		t.Fatal("Expected a deprecation without a sunset date to be rejected")
+	}
+	if schema, _ := registry.Get("student_verification"); schema.Lifecycle != nil {
+		t.Error("Expected an invalid file not to be applied")
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// ClaimNormalizer maps DP responses into canonical claims. Providers with a
+// claims pipeline have their responses mapped by it into the claim's JSON
+// shape (verified, confidence, attributes, verified_at, expires_at); the
+// claim's type and source are always set by the broker. Other providers'
+// claims carry their verification result without attributes.
+type ClaimNormalizer struct {
+	transformer *DataTransformer
+}
+
+// NewClaimNormalizer creates a normalizer running claims pipelines with the
+// given transformer
+func NewClaimNormalizer(transformer *DataTransformer) *ClaimNormalizer {
+	return &ClaimNormalizer{transformer: transformer}
+}
+
+// Normalize returns the canonical claim for a provider's response to a
+// verification of the claim type
+func (n *ClaimNormalizer) Normalize(claimType string, provider *DPProvider, response *DPResponse) (*models.Claim, error) {
+	claim := &models.Claim{}
+	if provider != nil && provider.ClaimsPipeline != "" {
+		mapped, err := n.mapClaim(provider.ClaimsPipeline, response)
+		if err != nil {
+			return nil, err
+		}
+		claim = mapped
+		// A calibrated confidence replaces the provider's own
+		if scoring := response.Scoring; scoring != nil && scoring.Calibrated {
+			claim.Confidence = scoring.Score
+		}
+	} else if result := response.VerificationResult; result != nil {
+		claim.Verified = result.Verified
+		claim.Confidence = result.Confidence
+		claim.VerifiedAt = result.Timestamp
+	}
+
+	claim.Type = claimType
+	claim.Source = response.DPID
+	if claim.VerifiedAt == "" {
+		claim.VerifiedAt = response.Timestamp
+	}
+	if err := claim.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid canonical claim from DP %s: %w", response.DPID, err)
+	}
+	return claim, nil
+}
+
+// mapClaim runs a claims pipeline over the DP's own response body, or over
+// the decoded response when there is none (adapters and PSI)
+func (n *ClaimNormalizer) mapClaim(pipeline string, response *DPResponse) (*models.Claim, error) {
+	body := response.body
+	if body == nil {
+		var err error
+		if body, err = json.Marshal(response); err != nil {
+			return nil, err
+		}
+	}
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode DP response: %w", err)
+	}
+
+	result := n.transformer.TransformData(TransformationRequest{Data: data, Pipeline: pipeline})
+	if !result.Success {
+		first := result.Errors[0]
+		return nil, fmt.Errorf("claims pipeline %s failed at %s: %s", pipeline, first.Field, first.Message)
+	}
+	mapped, err := json.Marshal(result.Data)
+	if err != nil {
+		return nil, err
+	}
+	var claim models.Claim
+	if err := json.Unmarshal(mapped, &claim); err != nil {
+		return nil, fmt.Errorf("claims pipeline %s did not produce a claim: %w", pipeline, err)
+	}
+	return &claim, nil
+}
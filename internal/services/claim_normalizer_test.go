@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestDPConnectorService_CanonicalClaims(t *testing.T) {
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":              "job-1",
+			"status":              "completed",
+			"verification_result": map[string]interface{}{"verified": true, "confidence": 0.9, "timestamp": "2026-01-01T00:00:00Z"},
+			"stu_inst":            "MIT",
+			"stu_status":          "E",
+		})
+	}))
+	defer dp.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: dp.URL, DPTimeout: 5 * time.Second})
+	service.registry = NewDPRegistry()
+	service.registry.Register(&DPProvider{DPID: "sis", Endpoint: dp.URL, SupportedClaims: []string{"student_verification"}})
+	verify := func() (*DPResponse, error) {
+		return service.VerifyWithDP(context.Background(), &models.PrivacyRequest{ClaimType: "student_verification"})
+	}
+
+	response, err := verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claim := response.Claim; claim == nil || claim.Type != "student_verification" || !claim.Verified || claim.Confidence != 0.9 || claim.Source != "sis" || len(claim.Attributes) != 0 {
+		t.Errorf("Expected a claim from the verification result, got %+v", response.Claim)
+	}
+
+	service.TransformationPipelines().Register(&TransformationPipeline{
+		Name: "sis.claims",
+		Transformations: []TransformationRule{
+			{SourceField: "verification_result.verified", TargetField: "verified", Transformation: "copy"},
+			{SourceField: "verification_result.confidence", TargetField: "confidence", Transformation: "copy"},
+			{SourceField: "stu_inst", TargetField: "attributes.institution", Transformation: "copy"},
+			{SourceField: "stu_status", TargetField: "attributes.enrollment_status", Transformation: "expression",
+				Parameters: map[string]interface{}{"expression": `value == "E" ? "enrolled" : "withdrawn"`}},
+		},
+	})
+	provider, _ := service.registry.Get("sis")
+	provider.ClaimsPipeline = "sis.claims"
+	service.registry.Register(provider)
+
+	response, err = verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	claim := response.Claim
+	if claim == nil || claim.Attributes["institution"] != "MIT" || claim.Attributes["enrollment_status"] != "enrolled" || claim.Source != "sis" {
+		t.Errorf("Expected DP fields mapped to canonical attributes, got %+v", claim)
+	}
+
+	service.TransformationPipelines().Register(&TransformationPipeline{
+		Name:            "sis.claims",
+		Transformations: []TransformationRule{{SourceField: "stu_inst", TargetField: "attributes.stu_inst", Transformation: "copy"}},
+	})
+	if _, err := verify(); err == nil || !strings.Contains(err.Error(), "stu_inst") {
+		t.Errorf("Expected a non-canonical attribute to fail the response, got %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ClaimTypeSchema describes a claim type RPs can request: the identifiers a
+// request must carry, the attributes released and how much of each is
+// disclosed, and the kind of DP that answers it
+type ClaimTypeSchema struct {
+	ClaimType           string                     `json:"claim_type"`
+	Description         string                     `json:"description"`
+	RequiredIdentifiers []string                   `json:"required_identifiers"`
+	OptionalIdentifiers []string                   `json:"optional_identifiers,omitempty"`
+	Attributes          map[string]DisclosureLevel `json:"attributes"`
+	// DPCategory is assumed for providers that do not declare a category
+	DPCategory string `json:"dp_category"`
+	// TypicalLatencyMs is quoted until latencies have been observed
+	TypicalLatencyMs int64 `json:"typical_latency_ms"`
+	// EvidenceWeighting optionally weighs DP results by evidence code and DP
+	EvidenceWeighting *EvidenceWeighting `json:"evidence_weighting,omitempty"`
+	// Lifecycle marks the claim type as deprecated or retired; nil is active
+	Lifecycle *ClaimLifecycle `json:"lifecycle,omitempty"`
+}
+
+// Validate checks that a schema is usable
+func (s *ClaimTypeSchema) Validate() error {
+	if s == nil {
+		return fmt.Errorf("claim schema cannot be nil")
+	}
+	if s.ClaimType == "" || s.ClaimType == AnyClaimType {
+		return fmt.Errorf("claim schema claim_type is required")
+	}
+	if len(s.RequiredIdentifiers) == 0 {
+		return fmt.Errorf("claim schema %s: at least one required identifier is needed", s.ClaimType)
+	}
+	for attribute, level := range s.Attributes {
+		switch level {
+		case DisclosureLevelFull, DisclosureLevelHash, DisclosureLevelRange, DisclosureLevelProof, DisclosureLevelNone:
+		default:
+			return fmt.Errorf("claim schema %s: attribute %s has unknown disclosure level %q", s.ClaimType, attribute, level)
+		}
+	}
+	if s.EvidenceWeighting != nil {
+		if err := s.EvidenceWeighting.Validate(); err != nil {
+			return fmt.Errorf("claim schema %s: %w", s.ClaimType, err)
+		}
+	}
+	if s.Lifecycle != nil {
+		if err := s.Lifecycle.Validate(); err != nil {
+			return fmt.Errorf("claim schema %s: %w", s.ClaimType, err)
+		}
+		if s.Lifecycle.ReplacedBy == s.ClaimType {
+			return fmt.Errorf("claim schema %s cannot replace itself", s.ClaimType)
+		}
+	}
+	return nil
+}
+
+// DefaultClaimSchemas returns the schemas of the built-in claim types
+func DefaultClaimSchemas() []*ClaimTypeSchema {
+	return []*ClaimTypeSchema{
+		{
+			ClaimType:           "student_verification",
+			Description:         "Confirms current enrollment at an educational institution",
+			RequiredIdentifiers: []string{"email"},
+			OptionalIdentifiers: []string{"name", "phone"},
+			Attributes: map[string]DisclosureLevel{
+				"enrollment_status": DisclosureLevelFull,
+				"institution":       DisclosureLevelFull,
+				"student_id":        DisclosureLevelHash,
+			},
+			DPCategory:       "education",
+			TypicalLatencyMs: 800,
+		},
+		{
+			ClaimType:           "employee_verification",
+			Description:         "Confirms active employment with an employer",
+			RequiredIdentifiers: []string{"email"},
+			OptionalIdentifiers: []string{"name", "phone", "ssn"},
+			Attributes: map[string]DisclosureLevel{
+				"employment_status": DisclosureLevelFull,
+				"employer":          DisclosureLevelFull,
+				"employee_id":       DisclosureLevelHash,
+			},
+			DPCategory:       "employment",
+			TypicalLatencyMs: 1000,
+		},
+		{
+			ClaimType:           "age_verification",
+			Description:         "Proves that a person meets an age threshold without revealing their birth date",
+			RequiredIdentifiers: []string{"name"},
+			OptionalIdentifiers: []string{"passport", "license", "ssn"},
+			Attributes: map[string]DisclosureLevel{
+				"age_over_threshold": DisclosureLevelProof,
+				"age":                DisclosureLevelRange,
+				"date_of_birth":      DisclosureLevelNone,
+			},
+			DPCategory:       "government",
+			TypicalLatencyMs: 1500,
+		},
+		{
+			ClaimType:           "address_verification",
+			Description:         "Confirms that a person resides at an address",
+			RequiredIdentifiers: []string{"name", "address"},
+			OptionalIdentifiers: []string{"email", "phone"},
+			Attributes: map[string]DisclosureLevel{
+				"address_match": DisclosureLevelFull,
+				"postal_code":   DisclosureLevelRange,
+				"address":       DisclosureLevelHash,
+			},
+			DPCategory:       "postal",
+			TypicalLatencyMs: 1200,
+		},
+	}
+}
+
+// builtinClaimSchemas indexes the default schemas by claim type
+var builtinClaimSchemas = func() map[string]*ClaimTypeSchema {
+	schemas := make(map[string]*ClaimTypeSchema)
+	for _, schema := range DefaultClaimSchemas() {
+		schemas[schema.ClaimType] = schema
+	}
+	return schemas
+}()
+
+// ClaimSchemaRegistry holds the schemas of the claim types the broker offers
+type ClaimSchemaRegistry struct {
+	mu      sync.RWMutex
+	schemas map[string]*ClaimTypeSchema
+}
+
+// NewClaimSchemaRegistry creates a registry holding the built-in schemas
+func NewClaimSchemaRegistry() *ClaimSchemaRegistry {
+	registry := &ClaimSchemaRegistry{
+		schemas: make(map[string]*ClaimTypeSchema),
+	}
+	for _, schema := range DefaultClaimSchemas() {
+		registry.schemas[schema.ClaimType] = schema
+	}
+	return registry
+}
+
+// Register adds or replaces a claim type schema
+func (r *ClaimSchemaRegistry) Register(schema *ClaimTypeSchema) error {
+	if err := schema.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schemas[schema.ClaimType] = schema
+	return nil
+}
+
+// Update adds or replaces a claim type schema, returning the schema it
+// replaced, or nil for a new claim type
+func (r *ClaimSchemaRegistry) Update(schema *ClaimTypeSchema) (*ClaimTypeSchema, error) {
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous := r.schemas[schema.ClaimType]
+	r.schemas[schema.ClaimType] = schema
+	return previous, nil
+}
+
+// Get returns the schema for a claim type
+func (r *ClaimSchemaRegistry) Get(claimType string) (*ClaimTypeSchema, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schema, exists := r.schemas[claimType]
+	return schema, exists
+}
+
+// List returns all schemas ordered by claim type
+func (r *ClaimSchemaRegistry) List() []*ClaimTypeSchema {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schemas := make([]*ClaimTypeSchema, 0, len(r.schemas))
+	for _, schema := range r.schemas {
+		schemas = append(schemas, schema)
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].ClaimType < schemas[j].ClaimType
+	})
+	return schemas
+}
@@ -0,0 +1,51 @@
+package services
+
+import "testing"
+
+func TestClaimSchemaRegistry_Defaults(t *testing.T) {
+	registry := NewClaimSchemaRegistry()
+
+	schemas := registry.List()
+	if len(schemas) != len(DefaultClaimSchemas()) {
+		t.Fatalf("Expected %d schemas, got %d", len(DefaultClaimSchemas()), len(schemas))
+	}
+	for i := 1; i < len(schemas); i++ {
+		if schemas[i-1].ClaimType > schemas[i].ClaimType {
+			t.Error("Expected schemas to be ordered by claim type")
+		}
+	}
+	for _, schema := range schemas {
+		if err := schema.Validate(); err != nil {
+			t.Errorf("Expected built-in schema to be valid: %v", err)
+		}
+	}
+}
+
+func TestClaimSchemaRegistry_Register(t *testing.T) {
+	registry := NewClaimSchemaRegistry()
+
+	schema := &ClaimTypeSchema{
+		ClaimType:           "license_verification",
+		Description:         "Confirms a professional license",
+		RequiredIdentifiers: []string{"license"},
+		Attributes:          map[string]DisclosureLevel{"license_status": DisclosureLevelFull},
+	}
+	if err := registry.Register(schema); err != nil {
+		t.Fatalf("Expected schema to register, got %v", err)
+	}
+	if got, exists := registry.Get("license_verification"); !exists || got != schema {
+		t.Error("Expected registered schema to be returned")
+	}
+
+	invalid := []*ClaimTypeSchema{
+		nil,
+		{RequiredIdentifiers: []string{"email"}},
+		{ClaimType: "no_identifiers"},
+		{ClaimType: "bad_level", RequiredIdentifiers: []string{"email"}, Attributes: map[string]DisclosureLevel{"x": "partial"}},
+	}
+	for _, schema := range invalid {
+		if err := registry.Register(schema); err == nil {
+			t.Errorf("Expected schema %+v to be rejected", schema)
+		}
+	}
+}
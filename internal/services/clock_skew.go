@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// DefaultClockSkewTolerance applies when no tolerance is configured
+const DefaultClockSkewTolerance = 60 * time.Second
+
+// ClockSkewTolerance returns how far another party's clock may differ from
+// ours before timestamps it issued (JWT exp/nbf/iat, signed URLs, key binding
+// proofs) are rejected
+func ClockSkewTolerance(cfg *config.Config) time.Duration {
+	if cfg == nil || cfg.ClockSkewTolerance <= 0 {
+		return DefaultClockSkewTolerance
+	}
+	return cfg.ClockSkewTolerance
+}
+
+// ExpiredWithSkew reports whether a timestamp has passed by more than the
+// tolerance
+func ExpiredWithSkew(expiresAt, now time.Time, tolerance time.Duration) bool {
+	return now.After(expiresAt.Add(tolerance))
+}
+
+// NotYetValidWithSkew reports whether a timestamp is further in the future
+// than the tolerance
+func NotYetValidWithSkew(notBefore, now time.Time, tolerance time.Duration) bool {
+	return now.Add(tolerance).Before(notBefore)
+}
+
+// Time sync statuses
+const (
+	TimeSyncOK       = "ok"
+	TimeSyncWarning  = "warning"
+	TimeSyncCritical = "critical"
+	TimeSyncUnknown  = "unknown"
+)
+
+// TimeSourceResult is one trusted source's view of our clock offset
+type TimeSourceResult struct {
+	Source   string  `json:"source"`
+	OffsetMs float64 `json:"offset_ms"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// TimeSyncReport is the outcome of comparing the system clock with the
+// trusted time sources. The offset is the median of the sources that
+// answered, so one bad source cannot raise or hide an alert.
+type TimeSyncReport struct {
+	Status    string             `json:"status"`
+	OffsetMs  float64            `json:"offset_ms"`
+	Sources   []TimeSourceResult `json:"sources"`
+	CheckedAt time.Time          `json:"checked_at"`
+}
+
+// TimeSyncChecker compares the system clock with trusted time sources at
+// startup and periodically, alerting when drift approaches the skew
+// tolerance and would start breaking timestamp validation
+type TimeSyncChecker struct {
+	sources  []string
+	warning  time.Duration
+	critical time.Duration
+	interval time.Duration
+	timeout  time.Duration
+	// query measures our offset from one source; replaced in tests
+	query func(ctx context.Context, source string, timeout time.Duration) (time.Duration, error)
+
+	mu      sync.Mutex
+	last    *TimeSyncReport
+	handler func(TimeSyncReport)
+	once    sync.Once
+	stop    chan struct{}
+}
+
+// NewTimeSyncChecker creates a checker for the configured time sources.
+// Sources are NTP servers ("pool.ntp.org", "ntp://time.example:123") or
+// HTTPS URLs whose Date header is used where NTP is blocked.
+func NewTimeSyncChecker(cfg *config.Config) *TimeSyncChecker {
+	critical := ClockSkewTolerance(cfg)
+	warning := cfg.TimeDriftWarning
+	if warning <= 0 || warning > critical {
+		warning = critical / 2
+	}
+	interval := cfg.TimeCheckInterval
+	if interval <= 0 {
+		interval = 15 * time.Minute
+	}
+
+	return &TimeSyncChecker{
+		sources:  cfg.TimeSources,
+		warning:  warning,
+		critical: critical,
+		interval: interval,
+		timeout:  3 * time.Second,
+		query:    queryTimeSource,
+	}
+}
+
+// Enabled reports whether any time sources are configured
+func (c *TimeSyncChecker) Enabled() bool {
+	return len(c.sources) > 0
+}
+
+// SetAlertHandler registers a callback for checks that find drift above the
+// warning threshold or cannot reach any source
+func (c *TimeSyncChecker) SetAlertHandler(handler func(TimeSyncReport)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handler = handler
+}
+
+// Check queries every source and records the report
+func (c *TimeSyncChecker) Check(ctx context.Context) *TimeSyncReport {
+	report := &TimeSyncReport{
+		Status:    TimeSyncUnknown,
+		Sources:   make([]TimeSourceResult, 0, len(c.sources)),
+		CheckedAt: time.Now().UTC(),
+	}
+
+	offsets := make([]time.Duration, 0, len(c.sources))
+	for _, source := range c.sources {
+		offset, err := c.query(ctx, source, c.timeout)
+		result := TimeSourceResult{Source: source}
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.OffsetMs = durationMs(offset)
+			offsets = append(offsets, offset)
+		}
+		report.Sources = append(report.Sources, result)
+	}
+
+	if len(offsets) > 0 {
+		sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+		offset := offsets[len(offsets)/2]
+		if len(offsets)%2 == 0 {
+			offset = (offsets[len(offsets)/2-1] + offsets[len(offsets)/2]) / 2
+		}
+		report.OffsetMs = durationMs(offset)
+
+		drift := time.Duration(math.Abs(float64(offset)))
+		switch {
+		case drift >= c.critical:
+			report.Status = TimeSyncCritical
+		case drift >= c.warning:
+			report.Status = TimeSyncWarning
+		default:
+			report.Status = TimeSyncOK
+		}
+	}
+
+	c.mu.Lock()
+	c.last = report
+	handler := c.handler
+	c.mu.Unlock()
+
+	if report.Status != TimeSyncOK {
+		fmt.Printf("CLOCK WARNING: system clock status %s, offset %.0fms from trusted sources (tolerance %s)\n", report.Status, report.OffsetMs, c.critical)
+		if handler != nil {
+			handler(*report)
+		}
+	}
+	return report
+}
+
+// LastReport returns the most recent check, or nil before the first one
+func (c *TimeSyncChecker) LastReport() *TimeSyncReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last == nil {
+		return nil
+	}
+	copied := *c.last
+	return &copied
+}
+
+// Start checks the clock periodically until Stop is called
+func (c *TimeSyncChecker) Start() {
+	if !c.Enabled() {
+		return
+	}
+	c.once.Do(func() {
+		c.stop = make(chan struct{})
+		go c.run(c.stop)
+	})
+}
+
+// Stop ends periodic checks
+func (c *TimeSyncChecker) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Prevent checks from starting after stop
+	c.once.Do(func() {})
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+func (c *TimeSyncChecker) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.Check(context.Background())
+		}
+	}
+}
+
+// queryTimeSource measures our clock offset from an NTP server or the Date
+// header of an HTTP(S) endpoint. A positive offset means our clock is behind.
+func queryTimeSource(ctx context.Context, source string, timeout time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://") {
+		return queryHTTPDate(ctx, source)
+	}
+	return queryNTP(ctx, strings.TrimPrefix(source, "ntp://"))
+}
+
+// ntpEpochOffset is the number of seconds from 1900 (NTP) to 1970 (Unix)
+const ntpEpochOffset = 2208988800
+
+// queryNTP runs one SNTP (RFC 4330) exchange
+func queryNTP(ctx context.Context, host string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "123")
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", host)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach NTP server: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// LI = 0, version 4, mode 3 (client)
+	request := make([]byte, 48)
+	request[0] = 0x23
+
+	sent := time.Now()
+	if _, err := conn.Write(request); err != nil {
+		return 0, fmt.Errorf("failed to send NTP request: %w", err)
+	}
+	response := make([]byte, 48)
+	n, err := conn.Read(response)
+	received := time.Now()
+	if err != nil {
+		return 0, fmt.Errorf("no NTP response: %w", err)
+	}
+	if n < 48 || response[0]&0x07 != 4 {
+		return 0, fmt.Errorf("invalid NTP response")
+	}
+	if response[1] == 0 {
+		return 0, fmt.Errorf("NTP server refused the request")
+	}
+
+	serverReceived := ntpTime(response[32:40])
+	serverSent := ntpTime(response[40:48])
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+func ntpTime(b []byte) time.Time {
+	seconds := int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+	fraction := int64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(seconds, fraction*int64(time.Second)>>32)
+}
+
+// queryHTTPDate estimates the offset from an HTTP Date header, which only has
+// second resolution
+func queryHTTPDate(ctx context.Context, url string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	received := time.Now()
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach time source: %w", err)
+	}
+	resp.Body.Close()
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("time source sent no valid Date header")
+	}
+	// The Date header is truncated to the second, so assume mid-second
+	midpoint := sent.Add(received.Sub(sent) / 2)
+	return date.Add(500 * time.Millisecond).Sub(midpoint), nil
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestSkewHelpers(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if ExpiredWithSkew(now.Add(-30*time.Second), now, time.Minute) {
+		t.Error("Expected a timestamp within the tolerance not to be expired")
+	}
+	if !ExpiredWithSkew(now.Add(-2*time.Minute), now, time.Minute) {
+		t.Error("Expected a timestamp beyond the tolerance to be expired")
+	}
+	if NotYetValidWithSkew(now.Add(30*time.Second), now, time.Minute) {
+		t.Error("Expected a nbf within the tolerance to be valid")
+	}
+	if !NotYetValidWithSkew(now.Add(2*time.Minute), now, time.Minute) {
+		t.Error("Expected a nbf beyond the tolerance to be rejected")
+	}
+
+	if ClockSkewTolerance(nil) != DefaultClockSkewTolerance || ClockSkewTolerance(&config.Config{ClockSkewTolerance: 5 * time.Second}) != 5*time.Second {
+		t.Error("Unexpected configured tolerance")
+	}
+}
+
+func TestTimeSyncChecker_Check(t *testing.T) {
+	checker := NewTimeSyncChecker(&config.Config{
+		ClockSkewTolerance: time.Minute,
+		TimeSources:        []string{"a", "b", "c"},
+	})
+	offsets := map[string]time.Duration{"a": 40 * time.Second, "b": 35 * time.Second, "c": 10 * time.Minute}
+	checker.query = func(ctx context.Context, source string, timeout time.Duration) (time.Duration, error) {
+		return offsets[source], nil
+	}
+	var alerts []TimeSyncReport
+	checker.SetAlertHandler(func(report TimeSyncReport) {
+		alerts = append(alerts, report)
+	})
+
+	// The median ignores the one source that is far off
+	report := checker.Check(context.Background())
+	if report.Status != TimeSyncWarning || report.OffsetMs != 40000 || len(alerts) != 1 {
+		t.Errorf("Expected a warning at the median offset, got %+v", report)
+	}
+
+	offsets["a"], offsets["b"] = -2*time.Minute, -90*time.Second
+	if report := checker.Check(context.Background()); report.Status != TimeSyncCritical {
+		t.Errorf("Expected drift beyond the tolerance to be critical, got %s", report.Status)
+	}
+
+	checker.query = func(ctx context.Context, source string, timeout time.Duration) (time.Duration, error) {
+		return 0, fmt.Errorf("unreachable")
+	}
+	report = checker.Check(context.Background())
+	if report.Status != TimeSyncUnknown || report.Sources[0].Error == "" {
+		t.Errorf("Expected an unknown status when no source answers, got %+v", report)
+	}
+	if last := checker.LastReport(); last == nil || last.Status != TimeSyncUnknown {
+		t.Errorf("Expected the last report to be recorded, got %+v", last)
+	}
+}
+
+func TestQueryHTTPDate(t *testing.T) {
+	ahead := time.Now().Add(time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", ahead.UTC().Format(http.TimeFormat))
+	}))
+	defer server.Close()
+
+	offset, err := queryTimeSource(context.Background(), server.URL, time.Second)
+	if err != nil {
+		t.Fatalf("queryTimeSource failed: %v", err)
+	}
+	if offset < 59*time.Minute || offset > 61*time.Minute {
+		t.Errorf("Expected an offset of about an hour, got %s", offset)
+	}
+}
@@ -0,0 +1,497 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// maxCompiledSchemas bounds the compiled schemas a validator caches; the
+// cache is emptied when it fills so schemas built per request cannot grow
+// it without limit
+const maxCompiledSchemas = 256
+
+// CompiledSchema is a schema prepared for repeated validation: its $refs
+// are resolved, patterns compiled, field schemas merged, constants decoded
+// and scalar enums indexed. It holds its own copy of the schema and is safe
+// for concurrent use.
+type CompiledSchema struct {
+	validator *DataValidator
+	schema    *ValidationSchema
+	scope     *schemaScope
+	hash      string
+	// err is the schema's first structural problem; validation still runs
+	// so the response reports the data's errors alongside it
+	err error
+}
+
+// schemaCompilation holds what a compiled schema precomputes, keyed by the
+// subschemas of its copy
+type schemaCompilation struct {
+	refs         map[string]*ValidationSchema
+	fields       map[schemaFieldKey]*ValidationSchema
+	consts       map[*ValidationSchema]interface{}
+	enums        map[*ValidationSchema]map[string]bool
+	patternNames map[*ValidationSchema][]string
+}
+
+// schemaFieldKey identifies a property of an object schema
+type schemaFieldKey struct {
+	parent *ValidationSchema
+	name   string
+}
+
+// CompileSchema prepares a schema for validating many values. Compiled
+// schemas are cached by the hash of the schema, so compiling an equal schema
+// again returns the cached one.
+func (dv *DataValidator) CompileSchema(schema *ValidationSchema) (*CompiledSchema, error) {
+	compiled := dv.compiledSchema(schema)
+	if compiled.err != nil {
+		return nil, compiled.err
+	}
+	return compiled, nil
+}
+
+// compiledSchema returns the cached compilation of a schema, compiling it
+// on a miss. Invalid schemas are cached too, keeping their error.
+func (dv *DataValidator) compiledSchema(schema *ValidationSchema) *CompiledSchema {
+	key := schemaHash(schema)
+
+	dv.compiledMu.Lock()
+	compiled, exists := dv.compiled[key]
+	dv.compiledMu.Unlock()
+	if exists {
+		return compiled
+	}
+
+	compiled = dv.compile(schema, key)
+	dv.compiledMu.Lock()
+	defer dv.compiledMu.Unlock()
+	if dv.compiled == nil || len(dv.compiled) >= maxCompiledSchemas {
+		dv.compiled = make(map[string]*CompiledSchema)
+	}
+	dv.compiled[key] = compiled
+	return compiled
+}
+
+// compile copies a schema and precomputes its validation state
+func (dv *DataValidator) compile(schema *ValidationSchema, key string) *CompiledSchema {
+	root := cloneSchema(schema)
+	scope := newSchemaScope(root)
+	// Checking compiles every valid pattern into the scope
+	err := scope.check(root, "#")
+
+	compilation := &schemaCompilation{
+		refs:         make(map[string]*ValidationSchema),
+		fields:       make(map[schemaFieldKey]*ValidationSchema),
+		consts:       make(map[*ValidationSchema]interface{}),
+		enums:        make(map[*ValidationSchema]map[string]bool),
+		patternNames: make(map[*ValidationSchema][]string),
+	}
+	compilation.add(root, scope, make(map[*ValidationSchema]bool))
+	scope.compiled = compilation
+
+	return &CompiledSchema{validator: dv, schema: root, scope: scope, hash: key, err: err}
+}
+
+// add precomputes a schema and every subschema reachable from it
+func (c *schemaCompilation) add(schema *ValidationSchema, scope *schemaScope, seen map[*ValidationSchema]bool) {
+	if schema == nil || seen[schema] || schema.Bool != nil {
+		return
+	}
+	seen[schema] = true
+
+	if schema.Ref != "" {
+		if target, err := scope.resolve(schema.Ref); err == nil {
+			c.refs[schema.Ref] = target
+			c.add(target, scope, seen)
+		}
+	}
+	if schema.Const != nil {
+		var value interface{}
+		if err := json.Unmarshal(schema.Const, &value); err == nil {
+			c.consts[schema] = value
+		}
+	}
+	if values, ok := enumSet(schema.Enum); ok {
+		c.enums[schema] = values
+	}
+	if len(schema.PatternProperties) > 0 {
+		c.patternNames[schema] = sortedMapKeys(schema.PatternProperties)
+	}
+
+	for name, field := range schema.Properties {
+		merged := field.schema()
+		c.fields[schemaFieldKey{schema, name}] = merged
+		c.add(merged, scope, seen)
+	}
+	for pointer, child := range schema.subschemas() {
+		if !strings.HasPrefix(pointer, "properties/") {
+			c.add(child, scope, seen)
+		}
+	}
+}
+
+// Hash returns the hash compiled schemas are cached by
+func (c *CompiledSchema) Hash() string {
+	return c.hash
+}
+
+// Validate validates data against the compiled schema
+func (c *CompiledSchema) Validate(data interface{}, options ValidationOptions) ValidationResponse {
+	return c.validator.validate(data, c, options)
+}
+
+// newScope returns a scope for one validation. It shares the compiled,
+// read-only state and tracks its own $ref depth.
+func (c *CompiledSchema) newScope() *schemaScope {
+	scope := *c.scope
+	scope.depth = 0
+	return &scope
+}
+
+// enumSet indexes an enum's values for lookup. Only enums of strings,
+// numbers, booleans and nulls are indexed; others are searched in order.
+func enumSet(values []interface{}) (map[string]bool, bool) {
+	if values == nil {
+		return nil, false
+	}
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		key, ok := enumKey(value)
+		if !ok {
+			return nil, false
+		}
+		set[key] = true
+	}
+	return set, true
+}
+
+// enumKey returns the key of a scalar JSON value, equal for values jsonEqual
+// considers equal
+func enumKey(value interface{}) (string, bool) {
+	switch typed := value.(type) {
+	case nil:
+		return "null", true
+	case string:
+		return "s:" + typed, true
+	case bool:
+		return "b:" + strconv.FormatBool(typed), true
+	}
+	number, ok := numericValue(value)
+	if !ok || math.IsNaN(number) {
+		return "", false
+	}
+	if number == 0 {
+		// -0 equals 0
+		number = 0
+	}
+	return "n:" + strconv.FormatFloat(number, 'g', -1, 64), true
+}
+
+// schemaHash hashes a schema's content, including the fields JSON encoding
+// leaves out. Schemas built with shared or cyclic pointers hash by the shape
+// of their references. Keywords added to ValidationSchema or SchemaField
+// must be hashed here too.
+func schemaHash(schema *ValidationSchema) string {
+	hasher := schemaHasher{seen: make(map[*ValidationSchema]int)}
+	hasher.schema(schema)
+	sum := sha256.Sum256(hasher.buf)
+	return hex.EncodeToString(sum[:])
+}
+
+// schemaHasher encodes a schema unambiguously for hashing
+type schemaHasher struct {
+	buf  []byte
+	seen map[*ValidationSchema]int
+}
+
+func (hs *schemaHasher) tag(tag byte) {
+	hs.buf = append(hs.buf, tag)
+}
+
+func (hs *schemaHasher) number(n uint64) {
+	hs.buf = binary.AppendUvarint(hs.buf, n)
+}
+
+func (hs *schemaHasher) str(s string) {
+	hs.number(uint64(len(s)))
+	hs.buf = append(hs.buf, s...)
+}
+
+func (hs *schemaHasher) strs(list []string) {
+	if list == nil {
+		hs.tag('0')
+		return
+	}
+	hs.tag('[')
+	hs.number(uint64(len(list)))
+	for _, s := range list {
+		hs.str(s)
+	}
+}
+
+func (hs *schemaHasher) boolean(b bool) {
+	if b {
+		hs.tag('t')
+	} else {
+		hs.tag('f')
+	}
+}
+
+func (hs *schemaHasher) boolPtr(b *bool) {
+	if b == nil {
+		hs.tag('0')
+		return
+	}
+	hs.boolean(*b)
+}
+
+func (hs *schemaHasher) intPtr(n *int) {
+	if n == nil {
+		hs.tag('0')
+		return
+	}
+	hs.tag('i')
+	hs.number(uint64(*n))
+}
+
+func (hs *schemaHasher) floatPtr(f *float64) {
+	if f == nil {
+		hs.tag('0')
+		return
+	}
+	hs.tag('g')
+	hs.number(math.Float64bits(*f))
+}
+
+func (hs *schemaHasher) strPtr(s *string) {
+	if s == nil {
+		hs.tag('0')
+		return
+	}
+	hs.tag('s')
+	hs.str(*s)
+}
+
+// value hashes an enum or default value by its type and JSON encoding
+func (hs *schemaHasher) value(v interface{}) {
+	if v == nil {
+		hs.tag('0')
+		return
+	}
+	hs.tag('v')
+	hs.str(reflect.TypeOf(v).String())
+	if encoded, err := json.Marshal(v); err == nil {
+		hs.str(string(encoded))
+	} else {
+		hs.str(fmt.Sprintf("%#v", v))
+	}
+}
+
+func (hs *schemaHasher) values(list []interface{}) {
+	if list == nil {
+		hs.tag('0')
+		return
+	}
+	hs.tag('[')
+	hs.number(uint64(len(list)))
+	for _, v := range list {
+		hs.value(v)
+	}
+}
+
+func (hs *schemaHasher) schemaMap(schemas map[string]*ValidationSchema) {
+	if schemas == nil {
+		hs.tag('0')
+		return
+	}
+	hs.tag('m')
+	hs.number(uint64(len(schemas)))
+	for _, name := range sortedMapKeys(schemas) {
+		hs.str(name)
+		hs.schema(schemas[name])
+	}
+}
+
+func (hs *schemaHasher) schemaList(schemas []*ValidationSchema) {
+	if schemas == nil {
+		hs.tag('0')
+		return
+	}
+	hs.tag('[')
+	hs.number(uint64(len(schemas)))
+	for _, schema := range schemas {
+		hs.schema(schema)
+	}
+}
+
+func (hs *schemaHasher) field(f SchemaField) {
+	hs.str(f.Type)
+	hs.str(f.Description)
+	hs.boolean(f.Required)
+	hs.intPtr(f.MinLength)
+	hs.intPtr(f.MaxLength)
+	hs.strPtr(f.Pattern)
+	hs.floatPtr(f.MinValue)
+	hs.floatPtr(f.MaxValue)
+	hs.values(f.Enum)
+	hs.strPtr(f.Format)
+	hs.strPtr(f.CustomRule)
+	hs.value(f.Default)
+	hs.schema(f.Schema)
+}
+
+func (hs *schemaHasher) schema(s *ValidationSchema) {
+	if s == nil {
+		hs.tag('0')
+		return
+	}
+	if index, exists := hs.seen[s]; exists {
+		hs.tag('@')
+		hs.number(uint64(index))
+		return
+	}
+	hs.seen[s] = len(hs.seen)
+	hs.tag('{')
+
+	hs.str(s.Type)
+	hs.strs(s.Required)
+	if s.Properties == nil {
+		hs.tag('0')
+	} else {
+		hs.tag('m')
+		hs.number(uint64(len(s.Properties)))
+		for _, name := range sortedMapKeys(s.Properties) {
+			hs.str(name)
+			hs.field(s.Properties[name])
+		}
+	}
+	hs.intPtr(s.MinItems)
+	hs.intPtr(s.MaxItems)
+	hs.strPtr(s.Pattern)
+	hs.strPtr(s.Format)
+	hs.strs(s.Types)
+	hs.boolPtr(s.Bool)
+
+	hs.str(s.SchemaURI)
+	hs.str(s.ID)
+	hs.str(s.Anchor)
+	hs.str(s.Ref)
+	hs.schemaMap(s.Defs)
+	hs.schemaMap(s.Definitions)
+
+	hs.values(s.Enum)
+	if s.Const == nil {
+		hs.tag('0')
+	} else {
+		hs.tag('c')
+		hs.str(string(s.Const))
+	}
+
+	hs.intPtr(s.MinLength)
+	hs.intPtr(s.MaxLength)
+	hs.floatPtr(s.Minimum)
+	hs.floatPtr(s.Maximum)
+	hs.floatPtr(s.ExclusiveMinimum)
+	hs.floatPtr(s.ExclusiveMaximum)
+	hs.floatPtr(s.MultipleOf)
+
+	hs.schemaMap(s.PatternProperties)
+	hs.schema(s.AdditionalProperties)
+	hs.schema(s.PropertyNames)
+	hs.intPtr(s.MinProperties)
+	hs.intPtr(s.MaxProperties)
+	if s.DependentRequired == nil {
+		hs.tag('0')
+	} else {
+		hs.tag('m')
+		hs.number(uint64(len(s.DependentRequired)))
+		for _, name := range sortedMapKeys(s.DependentRequired) {
+			hs.str(name)
+			hs.strs(s.DependentRequired[name])
+		}
+	}
+	hs.schemaMap(s.DependentSchemas)
+
+	hs.schema(s.Items)
+	hs.schemaList(s.PrefixItems)
+	hs.schema(s.Contains)
+	hs.intPtr(s.MinContains)
+	hs.intPtr(s.MaxContains)
+	hs.boolean(s.UniqueItems)
+
+	hs.schemaList(s.AllOf)
+	hs.schemaList(s.AnyOf)
+	hs.schemaList(s.OneOf)
+	hs.schema(s.Not)
+	hs.schema(s.If)
+	hs.schema(s.Then)
+	hs.schema(s.Else)
+
+	hs.strs(s.Unsupported)
+}
+
+// cloneSchema deep-copies a schema, keeping shared and cyclic pointers
+// shared and cyclic in the copy, so a compiled schema is unaffected by later
+// changes to the caller's schema
+func cloneSchema(schema *ValidationSchema) *ValidationSchema {
+	return cloneValue(reflect.ValueOf(schema), make(map[uintptr]reflect.Value)).Interface().(*ValidationSchema)
+}
+
+func cloneValue(value reflect.Value, seen map[uintptr]reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			return value
+		}
+		if clone, exists := seen[value.Pointer()]; exists {
+			return clone
+		}
+		clone := reflect.New(value.Type().Elem())
+		seen[value.Pointer()] = clone
+		clone.Elem().Set(cloneValue(value.Elem(), seen))
+		return clone
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		clone := reflect.New(value.Type()).Elem()
+		clone.Set(cloneValue(value.Elem(), seen))
+		return clone
+	case reflect.Struct:
+		clone := reflect.New(value.Type()).Elem()
+		for i := 0; i < value.NumField(); i++ {
+			if clone.Field(i).CanSet() {
+				clone.Field(i).Set(cloneValue(value.Field(i), seen))
+			}
+		}
+		return clone
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+		clone := reflect.MakeMapWithSize(value.Type(), value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			clone.SetMapIndex(iter.Key(), cloneValue(iter.Value(), seen))
+		}
+		return clone
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		clone := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			clone.Index(i).Set(cloneValue(value.Index(i), seen))
+		}
+		return clone
+	}
+	return value
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func parseSchema(t testing.TB, schemaJSON string) *ValidationSchema {
+	t.Helper()
+	var schema ValidationSchema
+	if err := json.Unmarshal([]byte(schemaJSON), &schema); err != nil {
+		t.Fatalf("Schema did not parse: %v", err)
+	}
+	return &schema
+}
+
+func parseData(t testing.TB, dataJSON string) interface{} {
+	t.Helper()
+	var data interface{}
+	if err := json.Unmarshal([]byte(dataJSON), &data); err != nil {
+		t.Fatalf("Data did not parse: %v", err)
+	}
+	return data
+}
+
+func TestCompiledSchema_MatchesUncompiledValidation(t *testing.T) {
+	validator := NewDataValidator(DataValidatorConfig{MaxErrors: 100})
+	schema := parseSchema(t, dpResponseSchema)
+	compiled, err := validator.CompileSchema(schema)
+	if err != nil {
+		t.Fatalf("Expected schema to compile, got %v", err)
+	}
+
+	documents := []string{
+		`{"job_id": "job-1", "status": "completed", "result": {"verified": true, "confidence": 0.9}, "evidence": ["registry"], "error": null}`,
+		`{"job_id": "j", "status": "done", "result": {"confidence": 2}, "evidence": ["a", "a"], "extra": 1}`,
+		`{"job_id": "job-2", "status": "failed", "result": {"verified": false}}`,
+		`{"job_id": 7, "status": null, "result": "yes"}`,
+	}
+	for _, document := range documents {
+		data := parseData(t, document)
+
+		var expected ValidationResponse
+		validator.evaluate(data, schema, "", &expected, ValidationOptions{}, newSchemaScope(schema))
+		got := compiled.Validate(data, ValidationOptions{})
+		if !reflect.DeepEqual(errorCodes(got), errorCodes(expected)) {
+			t.Errorf("%s: compiled errors %v, uncompiled %v", document, errorCodes(got), errorCodes(expected))
+		}
+	}
+}
+
+func TestCompiledSchema_CachedByHash(t *testing.T) {
+	validator := NewDataValidator(DataValidatorConfig{})
+	first, err := validator.CompileSchema(parseSchema(t, dpResponseSchema))
+	if err != nil {
+		t.Fatalf("Expected schema to compile, got %v", err)
+	}
+	second, err := validator.CompileSchema(parseSchema(t, dpResponseSchema))
+	if err != nil {
+		t.Fatalf("Expected schema to compile, got %v", err)
+	}
+	if first != second {
+		t.Error("Expected an equal schema to reuse the cached compilation")
+	}
+
+	other, err := validator.CompileSchema(parseSchema(t, `{"type": ["string", "null"]}`))
+	if err != nil {
+		t.Fatalf("Expected schema to compile, got %v", err)
+	}
+	single, err := validator.CompileSchema(parseSchema(t, `{"type": "string"}`))
+	if err != nil {
+		t.Fatalf("Expected schema to compile, got %v", err)
+	}
+	if other.Hash() == single.Hash() || other.Hash() == first.Hash() {
+		t.Error("Expected different schemas, including their type arrays, to hash differently")
+	}
+	if result := single.Validate(nil, ValidationOptions{}); result.Valid {
+		t.Error("Expected null to be rejected by the string schema")
+	}
+}
+
+func TestCompiledSchema_UnaffectedByLaterChanges(t *testing.T) {
+	validator := NewDataValidator(DataValidatorConfig{})
+	schema := parseSchema(t, `{"type": "object", "properties": {"status": {"enum": ["ok"]}}}`)
+	compiled, err := validator.CompileSchema(schema)
+	if err != nil {
+		t.Fatalf("Expected schema to compile, got %v", err)
+	}
+
+	field := schema.Properties["status"]
+	field.Enum = []interface{}{"changed"}
+	schema.Properties["status"] = field
+
+	if result := compiled.Validate(parseData(t, `{"status": "ok"}`), ValidationOptions{}); !result.Valid {
+		t.Errorf("Expected the compiled copy to keep the original enum, got %+v", result.Errors)
+	}
+	result := validator.ValidateData(ValidationRequest{Data: parseData(t, `{"status": "ok"}`), Schema: *schema})
+	if result.Valid {
+		t.Error("Expected the changed schema to be compiled anew")
+	}
+}
+
+func TestCompiledSchema_InvalidSchema(t *testing.T) {
+	validator := NewDataValidator(DataValidatorConfig{})
+	if _, err := validator.CompileSchema(parseSchema(t, `{"type": "string", "pattern": "(["}`)); err == nil {
+		t.Fatal("Expected an invalid pattern to fail compilation")
+	}
+
+	result := validator.ValidateData(ValidationRequest{Data: "x", Schema: *parseSchema(t, `{"type": "record"}`)})
+	if result.Valid || errorCodes(result)["schema"] != "INVALID_SCHEMA" {
+		t.Errorf("Expected INVALID_SCHEMA, got %+v", result.Errors)
+	}
+}
+
+func TestCompiledSchema_EnumLookup(t *testing.T) {
+	validator := NewDataValidator(DataValidatorConfig{})
+	compiled, err := validator.CompileSchema(parseSchema(t, `{"enum": ["a", 1, true, null, {"k": 1}]}`))
+	if err != nil {
+		t.Fatalf("Expected schema to compile, got %v", err)
+	}
+	for _, value := range []interface{}{"a", 1.0, 1, json.Number("1.0"), true, nil, map[string]interface{}{"k": 1.0}} {
+		if result := compiled.Validate(value, ValidationOptions{}); !result.Valid {
+			t.Errorf("Expected %#v to be in the enum", value)
+		}
+	}
+	for _, value := range []interface{}{"b", 2.0, false, "1", map[string]interface{}{"k": 2.0}} {
+		if result := compiled.Validate(value, ValidationOptions{}); result.Valid {
+			t.Errorf("Expected %#v not to be in the enum", value)
+		}
+	}
+
+	scalars, err := validator.CompileSchema(parseSchema(t, `{"enum": ["a", 0, false]}`))
+	if err != nil {
+		t.Fatalf("Expected schema to compile, got %v", err)
+	}
+	for value, valid := range map[interface{}]bool{"a": true, -0.0: true, 0: true, false: true, "0": false, "false": false} {
+		if result := scalars.Validate(value, ValidationOptions{}); result.Valid != valid {
+			t.Errorf("Expected %#v valid=%v, got %v", value, valid, result.Valid)
+		}
+	}
+}
+
+func TestCompiledSchema_ConcurrentValidation(t *testing.T) {
+	validator := NewDataValidator(DataValidatorConfig{MaxErrors: 100})
+	schema := parseSchema(t, `{
+		"type": "object",
+		"properties": {"node": {"$ref": "#/$defs/node"}},
+		"patternProperties": {"^x-": {"type": "string", "pattern": "^[a-z]+$"}},
+		"$defs": {"node": {"type": "object", "properties": {"next": {"$ref": "#/$defs/node"}, "id": {"type": "string", "pattern": "^n[0-9]+$"}}}}
+	}`)
+	valid := parseData(t, `{"node": {"id": "n1", "next": {"id": "n2"}}, "x-tag": "abc"}`)
+	invalid := parseData(t, `{"node": {"id": "n1", "next": {"id": "bad"}}, "x-tag": "ABC"}`)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if result := validator.ValidateData(ValidationRequest{Data: valid, Schema: *schema}); !result.Valid {
+					t.Errorf("Expected valid data to pass, got %+v", result.Errors)
+					return
+				}
+				if result := validator.ValidateData(ValidationRequest{Data: invalid, Schema: *schema}); len(result.Errors) != 2 {
+					t.Errorf("Expected 2 errors, got %+v", result.Errors)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// BenchmarkValidateData_Uncompiled measures validation that indexes and
+// checks the schema on every call, as ValidateData did before compilation
+func BenchmarkValidateData_Uncompiled(b *testing.B) {
+	validator := NewDataValidator(DataValidatorConfig{MaxErrors: 100})
+	schema := parseSchema(b, dpResponseSchema)
+	data := parseData(b, `{"job_id": "job-1", "status": "completed", "result": {"verified": true, "confidence": 0.9}, "evidence": ["registry"], "error": null}`)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var response ValidationResponse
+		newSchemaScope(schema).check(schema, "#")
+		validator.evaluate(data, schema, "", &response, ValidationOptions{}, newSchemaScope(schema))
+	}
+}
+
+// BenchmarkValidateData_Cached measures ValidateData, which hashes the
+// schema and reuses its cached compilation
+func BenchmarkValidateData_Cached(b *testing.B) {
+	validator := NewDataValidator(DataValidatorConfig{MaxErrors: 100})
+	schema := parseSchema(b, dpResponseSchema)
+	data := parseData(b, `{"job_id": "job-1", "status": "completed", "result": {"verified": true, "confidence": 0.9}, "evidence": ["registry"], "error": null}`)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		validator.ValidateData(ValidationRequest{Data: data, Schema: *schema})
+	}
+}
+
+// BenchmarkCompiledSchema_Validate measures validation with a schema
+// compiled once up front
+func BenchmarkCompiledSchema_Validate(b *testing.B) {
+	validator := NewDataValidator(DataValidatorConfig{MaxErrors: 100})
+	compiled, err := validator.CompileSchema(parseSchema(b, dpResponseSchema))
+	if err != nil {
+		b.Fatalf("Expected schema to compile, got %v", err)
+	}
+	data := parseData(b, `{"job_id": "job-1", "status": "completed", "result": {"verified": true, "confidence": 0.9}, "evidence": ["registry"], "error": null}`)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		compiled.Validate(data, ValidationOptions{})
+	}
+}
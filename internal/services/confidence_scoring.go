@@ -0,0 +1,256 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// Scales DPs report confidence on
+const (
+	ConfidenceScaleUnit    = "unit"
+	ConfidenceScalePercent = "percent"
+	ConfidenceScaleTier    = "tier"
+)
+
+// Methods combining the confidence of several DPs; a result from one DP is
+// scored as "single"
+const (
+	ConfidenceAggregationSingle       = "single"
+	ConfidenceAggregationMean         = "mean"
+	ConfidenceAggregationMin          = "min"
+	ConfidenceAggregationMax          = "max"
+	ConfidenceAggregationWeightedMean = "weighted_mean"
+)
+
+// CalibrationPoint maps a confidence on the unit scale to its calibrated
+// value
+type CalibrationPoint struct {
+	Raw        float64 `json:"raw"`
+	Calibrated float64 `json:"calibrated"`
+}
+
+// ConfidenceCalibration puts a provider's confidence values on the broker's
+// 0-1 scale. Values are first read on the provider's scale: unit (0-1),
+// percent (0-100) or named tiers. The curve, when set, then maps them by
+// linear interpolation between its points, so a DP that is overconfident
+// can be scored down. Weight is the provider's weight in a weighted mean.
+type ConfidenceCalibration struct {
+	Scale  string             `json:"scale,omitempty"`
+	Tiers  map[string]float64 `json:"tiers,omitempty"`
+	Curve  []CalibrationPoint `json:"curve,omitempty"`
+	Weight float64            `json:"weight,omitempty"`
+}
+
+// Validate checks that a calibration is usable
+func (c *ConfidenceCalibration) Validate() error {
+	switch c.Scale {
+	case "", ConfidenceScaleUnit, ConfidenceScalePercent:
+	case ConfidenceScaleTier:
+		if len(c.Tiers) == 0 {
+			return fmt.Errorf("calibration: the tier scale requires tiers")
+		}
+	default:
+		return fmt.Errorf("calibration: unsupported scale %q", c.Scale)
+	}
+	for tier, value := range c.Tiers {
+		if value < 0 || value > 1 {
+			return fmt.Errorf("calibration: tier %s must be between 0 and 1", tier)
+		}
+	}
+
+	if len(c.Curve) == 1 {
+		return fmt.Errorf("calibration: a curve needs at least two points")
+	}
+	for i, point := range c.Curve {
+		if point.Raw < 0 || point.Raw > 1 || point.Calibrated < 0 || point.Calibrated > 1 {
+			return fmt.Errorf("calibration: curve points must be between 0 and 1")
+		}
+		if i > 0 && point.Raw <= c.Curve[i-1].Raw {
+			return fmt.Errorf("calibration: curve points must be in increasing raw order")
+		}
+	}
+
+	if c.Weight < 0 {
+		return fmt.Errorf("calibration: weight must not be negative")
+	}
+	return nil
+}
+
+// Calibrate returns a raw confidence value on the broker's 0-1 scale
+func (c *ConfidenceCalibration) Calibrate(raw interface{}) (float64, error) {
+	var value float64
+	switch c.Scale {
+	case ConfidenceScaleTier:
+		tier, ok := raw.(string)
+		if !ok {
+			return 0, fmt.Errorf("confidence %v is not a tier", raw)
+		}
+		score, found := c.Tiers[tier]
+		if !found {
+			for name, tierScore := range c.Tiers {
+				if strings.EqualFold(name, tier) {
+					score, found = tierScore, true
+					break
+				}
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown confidence tier %q", tier)
+		}
+		value = score
+	default:
+		number, ok := raw.(float64)
+		if !ok {
+			return 0, fmt.Errorf("confidence %v is not a number", raw)
+		}
+		limit := 1.0
+		if c.Scale == ConfidenceScalePercent {
+			limit = 100
+		}
+		if number < 0 || number > limit {
+			return 0, fmt.Errorf("confidence %v is outside the %s scale", number, c.scaleName())
+		}
+		value = number / limit
+	}
+	return c.applyCurve(value), nil
+}
+
+func (c *ConfidenceCalibration) scaleName() string {
+	if c.Scale == "" {
+		return ConfidenceScaleUnit
+	}
+	return c.Scale
+}
+
+// applyCurve interpolates a value between the curve's points; values
+// outside the curve take its end points' calibrated values
+func (c *ConfidenceCalibration) applyCurve(value float64) float64 {
+	if len(c.Curve) == 0 {
+		return value
+	}
+	first, last := c.Curve[0], c.Curve[len(c.Curve)-1]
+	if value <= first.Raw {
+		return first.Calibrated
+	}
+	if value >= last.Raw {
+		return last.Calibrated
+	}
+	for i := 1; i < len(c.Curve); i++ {
+		low, high := c.Curve[i-1], c.Curve[i]
+		if value <= high.Raw {
+			return low.Calibrated + (value-low.Raw)/(high.Raw-low.Raw)*(high.Calibrated-low.Calibrated)
+		}
+	}
+	return last.Calibrated
+}
+
+// weight returns the provider's weight in a weighted mean, 1 by default
+func (c *ConfidenceCalibration) weight() float64 {
+	if c == nil || c.Weight == 0 {
+		return 1
+	}
+	return c.Weight
+}
+
+// IsConfidenceAggregation reports whether a method can combine the
+// confidence of several DPs
+func IsConfidenceAggregation(method string) bool {
+	switch method {
+	case ConfidenceAggregationMean, ConfidenceAggregationMin, ConfidenceAggregationMax, ConfidenceAggregationWeightedMean:
+		return true
+	}
+	return false
+}
+
+// AggregateConfidence combines confidence scores with a method; weights are
+// only used by the weighted mean. Unknown methods take the mean.
+func AggregateConfidence(method string, scores, weights []float64) float64 {
+	if len(scores) == 0 {
+		return 0
+	}
+	result := scores[0]
+	switch method {
+	case ConfidenceAggregationMin:
+		for _, score := range scores[1:] {
+			if score < result {
+				result = score
+			}
+		}
+	case ConfidenceAggregationMax:
+		for _, score := range scores[1:] {
+			if score > result {
+				result = score
+			}
+		}
+	case ConfidenceAggregationWeightedMean:
+		var total, weighted float64
+		for i, score := range scores {
+			total += weights[i]
+			weighted += weights[i] * score
+		}
+		if total > 0 {
+			return weighted / total
+		}
+		fallthrough
+	default:
+		result = 0
+		for _, score := range scores {
+			result += score
+		}
+		result /= float64(len(scores))
+	}
+	return result
+}
+
+// calibrateConfidence rewrites the confidence of a mapped DP response onto
+// the broker's scale with the provider's calibration
+func calibrateConfidence(provider *DPProvider, mapped []byte) ([]byte, *models.ConfidenceScore, error) {
+	if provider == nil || provider.Calibration == nil {
+		return mapped, nil, nil
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(mapped, &data); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode DP response: %w", err)
+	}
+	result, ok := data["verification_result"].(map[string]interface{})
+	if !ok {
+		return mapped, nil, nil
+	}
+	raw, present := result["confidence"]
+	if !present {
+		return mapped, nil, nil
+	}
+
+	score, err := provider.Calibration.Calibrate(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("DP %s confidence calibration failed: %w", provider.DPID, err)
+	}
+	result["confidence"] = score
+	calibrated, err := json.Marshal(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return calibrated, &models.ConfidenceScore{
+		Score:       score,
+		Raw:         raw,
+		Scale:       provider.Calibration.scaleName(),
+		Calibrated:  true,
+		Aggregation: ConfidenceAggregationSingle,
+	}, nil
+}
+
+// scoreResponse records the confidence scoring of a single DP's result
+// that was not calibrated
+func scoreResponse(response *DPResponse) {
+	if response.Scoring != nil || response.VerificationResult == nil {
+		return
+	}
+	response.Scoring = &models.ConfidenceScore{
+		Score:       response.VerificationResult.Confidence,
+		Scale:       ConfidenceScaleUnit,
+		Aggregation: ConfidenceAggregationSingle,
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestConfidenceCalibration_Calibrate(t *testing.T) {
+	tests := []struct {
+		name        string
+		calibration ConfidenceCalibration
+		raw         interface{}
+		expected    float64
+	}{
+		{"unit", ConfidenceCalibration{}, 0.8, 0.8},
+		{"percent", ConfidenceCalibration{Scale: ConfidenceScalePercent}, 93.0, 0.93},
+		{"tier", ConfidenceCalibration{Scale: ConfidenceScaleTier, Tiers: map[string]float64{"high": 0.9, "low": 0.4}}, "HIGH", 0.9},
+		{"curve", ConfidenceCalibration{Curve: []CalibrationPoint{{0, 0}, {0.5, 0.3}, {1, 0.8}}}, 0.75, 0.55},
+		{"below the curve", ConfidenceCalibration{Curve: []CalibrationPoint{{0.2, 0.1}, {1, 1}}}, 0.1, 0.1},
+		{"percent with curve", ConfidenceCalibration{Scale: ConfidenceScalePercent, Curve: []CalibrationPoint{{0, 0}, {1, 0.5}}}, 80.0, 0.4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.calibration.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			score, err := tt.calibration.Calibrate(tt.raw)
+			if err != nil || math.Abs(score-tt.expected) > 1e-9 {
+				t.Errorf("Expected %v, got %v, %v", tt.expected, score, err)
+			}
+		})
+	}
+
+	for _, invalid := range []struct {
+		calibration ConfidenceCalibration
+		raw         interface{}
+	}{
+		{ConfidenceCalibration{}, 93.0},
+		{ConfidenceCalibration{Scale: ConfidenceScalePercent}, "high"},
+		{ConfidenceCalibration{Scale: ConfidenceScaleTier, Tiers: map[string]float64{"high": 0.9}}, "medium"},
+	} {
+		if _, err := invalid.calibration.Calibrate(invalid.raw); err == nil {
+			t.Errorf("Expected %v to be rejected on the %s scale", invalid.raw, invalid.calibration.scaleName())
+		}
+	}
+}
+
+func TestConfidenceCalibration_Validate(t *testing.T) {
+	for _, invalid := range []ConfidenceCalibration{
+		{Scale: "stars"},
+		{Scale: ConfidenceScaleTier},
+		{Scale: ConfidenceScaleTier, Tiers: map[string]float64{"high": 90}},
+		{Curve: []CalibrationPoint{{0.5, 0.5}}},
+		{Curve: []CalibrationPoint{{0.5, 0.5}, {0.2, 0.8}}},
+		{Weight: -1},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+}
+
+func TestAggregateConfidence(t *testing.T) {
+	scores, weights := []float64{0.9, 0.6}, []float64{2, 1}
+	for method, expected := range map[string]float64{
+		ConfidenceAggregationMean:         0.75,
+		ConfidenceAggregationMin:          0.6,
+		ConfidenceAggregationMax:          0.9,
+		ConfidenceAggregationWeightedMean: 0.8,
+		"unknown":                         0.75,
+	} {
+		if got := AggregateConfidence(method, scores, weights); math.Abs(got-expected) > 1e-9 {
+			t.Errorf("%s: expected %v, got %v", method, expected, got)
+		}
+	}
+}
+
+func TestDPConnectorService_ConfidenceCalibration(t *testing.T) {
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"job_id": "job_1", "status": "completed", "timestamp": "2026-01-01T00:00:00Z",
+			"verification_result": {"verified": true, "confidence": "high", "timestamp": "2026-01-01T00:00:00Z"}}`))
+	}))
+	defer dp.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: dp.URL})
+	service.registry = NewDPRegistry()
+	service.registry.Register(&DPProvider{
+		DPID:            "tiered_dp",
+		Endpoint:        dp.URL,
+		SupportedClaims: []string{"age_verification"},
+		Calibration:     &ConfidenceCalibration{Scale: ConfidenceScaleTier, Tiers: map[string]float64{"high": 0.9, "low": 0.5}},
+	})
+
+	response, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.VerificationResult.Confidence != 0.9 || response.Claim.Confidence != 0.9 {
+		t.Errorf("Expected the tier's calibrated confidence, got %+v", response.VerificationResult)
+	}
+	scoring := response.Scoring
+	if scoring == nil || !scoring.Calibrated || scoring.Raw != "high" || scoring.Scale != ConfidenceScaleTier || scoring.Aggregation != ConfidenceAggregationSingle {
+		t.Errorf("Expected the calibration recorded, got %+v", scoring)
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// consentRevocationRetention is how long revocations are remembered. It
+// matches the verification cache TTL: no cached result can be older.
+const consentRevocationRetention = 90 * 24 * time.Hour
+
+// allClaimTypes keys a revocation covering every claim type
+const allClaimTypes = "*"
+
+// ConsentRevocation is published when a user withdraws consent from an RP,
+// for the listed claim types or for every claim type when none are listed
+type ConsentRevocation struct {
+	RPID       string    `json:"rp_id"`
+	UserID     string    `json:"user_id"`
+	ClaimTypes []string  `json:"claim_types,omitempty"`
+	RevokedAt  time.Time `json:"revoked_at"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+type consentSubject struct {
+	rpID   string
+	userID string
+}
+
+// ConsentService records consent revocations (database in production) and
+// notifies the services holding data derived from the revoked consent
+type ConsentService struct {
+	mu          sync.RWMutex
+	revocations map[consentSubject]map[string]time.Time
+	handlers    []func(context.Context, ConsentRevocation) error
+	now         func() time.Time
+	// store, when set, keeps revocations across restarts
+	store ConsentStore
+}
+
+// NewConsentService creates a new consent service
+func NewConsentService() *ConsentService {
+	return &ConsentService{
+		revocations: make(map[consentSubject]map[string]time.Time),
+		now:         time.Now,
+	}
+}
+
+// SetStorage keeps revocations in a store and loads those still inside the
+// retention window, so a restart does not serve results cached before a
+// revocation
+func (s *ConsentService) SetStorage(ctx context.Context, store ConsentStore) error {
+	revocations, err := store.ListConsentRevocations(ctx, s.now().Add(-consentRevocationRetention))
+	if err != nil {
+		return fmt.Errorf("failed to load consent revocations: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = store
+	for _, revocation := range revocations {
+		s.recordLocked(revocation)
+	}
+	return nil
+}
+
+// OnRevoke registers a handler called with each revocation before Revoke
+// returns
+func (s *ConsentService) OnRevoke(handler func(context.Context, ConsentRevocation) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, handler)
+}
+
+// Revoke records that a user withdrew consent from an RP and runs the
+// revocation handlers. The revocation is recorded even when a handler fails,
+// so RevokedAt keeps covering data the handler could not remove; the
+// handler errors are returned.
+func (s *ConsentService) Revoke(ctx context.Context, revocation ConsentRevocation) (ConsentRevocation, error) {
+	if revocation.RPID == "" || revocation.UserID == "" {
+		return revocation, fmt.Errorf("consent revocation requires rp_id and user_id")
+	}
+	revocation.RevokedAt = s.now()
+
+	s.mu.Lock()
+	s.recordLocked(revocation)
+	s.pruneLocked(revocation.RevokedAt)
+	handlers := s.handlers
+	store := s.store
+	s.mu.Unlock()
+
+	var errs []error
+	if store != nil {
+		if err := store.SaveConsentRevocation(ctx, revocation); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, handler := range handlers {
+		if err := handler(ctx, revocation); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return revocation, errors.Join(errs...)
+}
+
+// RevokedAt returns when the user last withdrew consent from the RP for a
+// claim type, or the zero time if they have not
+func (s *ConsentService) RevokedAt(rpID, userID, claimType string) time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	claims := s.revocations[consentSubject{rpID: rpID, userID: userID}]
+	revokedAt := claims[allClaimTypes]
+	if claimRevokedAt := claims[claimType]; claimRevokedAt.After(revokedAt) {
+		revokedAt = claimRevokedAt
+	}
+	return revokedAt
+}
+
+// recordLocked remembers a revocation, keeping the later time when a claim
+// type was already revoked
+func (s *ConsentService) recordLocked(revocation ConsentRevocation) {
+	subject := consentSubject{rpID: revocation.RPID, userID: revocation.UserID}
+	if s.revocations[subject] == nil {
+		s.revocations[subject] = make(map[string]time.Time)
+	}
+	for _, claimType := range revocationClaimTypes(revocation) {
+		if revocation.RevokedAt.After(s.revocations[subject][claimType]) {
+			s.revocations[subject][claimType] = revocation.RevokedAt
+		}
+	}
+}
+
+// pruneLocked forgets revocations older than any cached result
+func (s *ConsentService) pruneLocked(now time.Time) {
+	cutoff := now.Add(-consentRevocationRetention)
+	for subject, claims := range s.revocations {
+		for claimType, revokedAt := range claims {
+			if revokedAt.Before(cutoff) {
+				delete(claims, claimType)
+			}
+		}
+		if len(claims) == 0 {
+			delete(s.revocations, subject)
+		}
+	}
+}
+
+// GetConsentStats returns the number of subjects with remembered revocations
+func (s *ConsentService) GetConsentStats() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return map[string]interface{}{
+		"revoked_subjects": len(s.revocations),
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestConsentService_Revoke(t *testing.T) {
+	service := NewConsentService()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	var published []ConsentRevocation
+	service.OnRevoke(func(ctx context.Context, revocation ConsentRevocation) error {
+		published = append(published, revocation)
+		return nil
+	})
+
+	if _, err := service.Revoke(context.Background(), ConsentRevocation{RPID: "rp_1", UserID: "user_1", ClaimTypes: []string{"age_verification"}}); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if len(published) != 1 || !published[0].RevokedAt.Equal(now) {
+		t.Fatalf("Expected the revocation published with its time, got %+v", published)
+	}
+	if !service.RevokedAt("rp_1", "user_1", "age_verification").Equal(now) {
+		t.Error("Expected the claim type revoked")
+	}
+	if !service.RevokedAt("rp_1", "user_1", "student_verification").IsZero() || !service.RevokedAt("rp_2", "user_1", "age_verification").IsZero() {
+		t.Error("Expected other claim types and RPs unaffected")
+	}
+
+	// A revocation without claim types covers all of them
+	now = now.Add(time.Hour)
+	service.Revoke(context.Background(), ConsentRevocation{RPID: "rp_1", UserID: "user_1"})
+	if !service.RevokedAt("rp_1", "user_1", "student_verification").Equal(now) || !service.RevokedAt("rp_1", "user_1", "age_verification").Equal(now) {
+		t.Error("Expected every claim type revoked")
+	}
+
+	if _, err := service.Revoke(context.Background(), ConsentRevocation{RPID: "rp_1"}); err == nil {
+		t.Error("Expected a revocation without a user to be rejected")
+	}
+}
+
+func TestConsentService_HandlerFailureKeepsRevocation(t *testing.T) {
+	service := NewConsentService()
+	service.OnRevoke(func(ctx context.Context, revocation ConsentRevocation) error {
+		return errors.New("cache unavailable")
+	})
+
+	_, err := service.Revoke(context.Background(), ConsentRevocation{RPID: "rp_1", UserID: "user_1"})
+	if err == nil {
+		t.Fatal("Expected the handler error returned")
+	}
+	if service.RevokedAt("rp_1", "user_1", "age_verification").IsZero() {
+		t.Error("Expected the revocation recorded despite the failure")
+	}
+}
+
+func TestConsentService_PrunesOldRevocations(t *testing.T) {
+	service := NewConsentService()
+	now := time.Now()
+	service.now = func() time.Time { return now }
+	service.Revoke(context.Background(), ConsentRevocation{RPID: "rp_1", UserID: "user_1"})
+
+	now = now.Add(consentRevocationRetention + time.Hour)
+	service.Revoke(context.Background(), ConsentRevocation{RPID: "rp_1", UserID: "user_2"})
+	if !service.RevokedAt("rp_1", "user_1", "age_verification").IsZero() {
+		t.Error("Expected revocations older than the cache TTL forgotten")
+	}
+	if service.GetConsentStats()["revoked_subjects"] != 1 {
+		t.Errorf("Unexpected stats: %v", service.GetConsentStats())
+	}
+}
+
+func TestCacheService_RevokedSince(t *testing.T) {
+	consent := NewConsentService()
+	revokedAt := time.Date(2026, 3, 1, 12, 0, 0, 500000000, time.UTC)
+	consent.now = func() time.Time { return revokedAt }
+	cache := &CacheService{consent: consent}
+	req := models.VerificationRequest{RPID: "rp_1", UserID: "user_1", ClaimType: "age_verification"}
+	issued := func(at time.Time) *models.VerificationResponse {
+		return &models.VerificationResponse{Timestamp: at.Format(time.RFC3339)}
+	}
+
+	if cache.revokedSince(req, issued(revokedAt.Add(-time.Hour))) {
+		t.Error("Expected results served while consent stands")
+	}
+
+	consent.Revoke(context.Background(), ConsentRevocation{RPID: "rp_1", UserID: "user_1"})
+	if !cache.revokedSince(req, issued(revokedAt.Add(-time.Hour))) {
+		t.Error("Expected a result cached before the revocation rejected")
+	}
+	if !cache.revokedSince(req, issued(revokedAt)) {
+		t.Error("Expected a result from the second of the revocation rejected")
+	}
+	if cache.revokedSince(req, issued(revokedAt.Add(2*time.Second))) {
+		t.Error("Expected a result verified after renewed consent served")
+	}
+	if other := (models.VerificationRequest{RPID: "rp_2", UserID: "user_1", ClaimType: "age_verification"}); cache.revokedSince(other, issued(revokedAt.Add(-time.Hour))) {
+		t.Error("Expected other RPs' results unaffected")
+	}
+}
+
+func TestEscapeRedisPattern(t *testing.T) {
+	if escaped := escapeRedisPattern(`user*[1]?\`); escaped != `user\*\[1\]\?\\` {
+		t.Errorf("Unexpected escaping: %s", escaped)
+	}
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// Credential hook run statuses
+const (
+	HookRunSucceeded = "succeeded"
+	HookRunFailed    = "failed"
+)
+
+// maxCredentialHookRuns bounds the hook run history kept in memory
+const maxCredentialHookRuns = 1000
+
+// ErrCredentialHookPermanent marks a hook failure that retrying cannot fix;
+// hooks wrap it, e.g. fmt.Errorf("wallet rejected credential: %w", ErrCredentialHookPermanent)
+var ErrCredentialHookPermanent = errors.New("permanent hook failure")
+
+// CredentialIssuedEvent is what post-processing hooks receive about an
+// issued credential. Hooks must not modify the credential.
+type CredentialIssuedEvent struct {
+	Credential *models.Credential
+	Signature  *SigningResult
+	IssuedAt   time.Time
+}
+
+// CredentialHook post-processes an issued credential, e.g. pushing it to a
+// wallet provider or notifying a CRM. Hooks run in the background after the
+// issuance response is sent; errors are retried and never reach the client.
+type CredentialHook func(ctx context.Context, event CredentialIssuedEvent) error
+
+// CredentialHookOptions bound a hook's runs; zero values take the defaults
+// of 3 attempts, a 1s backoff doubling per retry and a 10s attempt timeout
+type CredentialHookOptions struct {
+	MaxAttempts int           `json:"max_attempts"`
+	Backoff     time.Duration `json:"backoff"`
+	Timeout     time.Duration `json:"timeout"`
+}
+
+func (o CredentialHookOptions) withDefaults() CredentialHookOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 3
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = time.Second
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	return o
+}
+
+type registeredCredentialHook struct {
+	name    string
+	hook    CredentialHook
+	options CredentialHookOptions
+}
+
+var (
+	credentialHookMu sync.RWMutex
+	credentialHooks  = map[string]registeredCredentialHook{}
+)
+
+// RegisterCredentialHook adds a hook run after every credential issuance.
+// Deployments call it from an init function in their own build; registering
+// a name again replaces the hook.
+func RegisterCredentialHook(name string, hook CredentialHook, options CredentialHookOptions) {
+	credentialHookMu.Lock()
+	defer credentialHookMu.Unlock()
+	credentialHooks[name] = registeredCredentialHook{name: name, hook: hook, options: options.withDefaults()}
+}
+
+// UnregisterCredentialHook removes a registered hook
+func UnregisterCredentialHook(name string) {
+	credentialHookMu.Lock()
+	defer credentialHookMu.Unlock()
+	delete(credentialHooks, name)
+}
+
+// registeredCredentialHooks returns the registered hooks ordered by name
+func registeredCredentialHooks() []registeredCredentialHook {
+	credentialHookMu.RLock()
+	defer credentialHookMu.RUnlock()
+	hooks := make([]registeredCredentialHook, 0, len(credentialHooks))
+	for _, hook := range credentialHooks {
+		hooks = append(hooks, hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].name < hooks[j].name })
+	return hooks
+}
+
+// CredentialHookInfo describes a registered hook
+type CredentialHookInfo struct {
+	Name    string                `json:"name"`
+	Options CredentialHookOptions `json:"options"`
+}
+
+// CredentialHookRun records one hook's processing of an issued credential
+type CredentialHookRun struct {
+	ID           string     `json:"id"`
+	Hook         string     `json:"hook"`
+	CredentialID string     `json:"credential_id"`
+	Status       string     `json:"status"`
+	Attempts     int        `json:"attempts"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+}
+
+// CredentialHookService runs the registered hooks for issued credentials.
+// Each hook runs in its own goroutine with its own retries, timeout and
+// panic recovery, so a slow or broken hook affects neither the issuance
+// response nor the other hooks.
+type CredentialHookService struct {
+	mu       sync.Mutex
+	runs     []CredentialHookRun
+	inFlight int
+}
+
+// NewCredentialHookService creates a hook service
+func NewCredentialHookService() *CredentialHookService {
+	return &CredentialHookService{}
+}
+
+// Hooks returns the registered hooks
+func (s *CredentialHookService) Hooks() []CredentialHookInfo {
+	hooks := registeredCredentialHooks()
+	infos := make([]CredentialHookInfo, 0, len(hooks))
+	for _, hook := range hooks {
+		infos = append(infos, CredentialHookInfo{Name: hook.name, Options: hook.options})
+	}
+	return infos
+}
+
+// Dispatch starts the registered hooks for an issued credential and returns
+// without waiting for them. Hooks receive a copy of the credential.
+func (s *CredentialHookService) Dispatch(credential *models.Credential, signature *SigningResult) {
+	hooks := registeredCredentialHooks()
+	if len(hooks) == 0 || credential == nil {
+		return
+	}
+	issued := *credential
+	event := CredentialIssuedEvent{Credential: &issued, Signature: signature, IssuedAt: time.Now()}
+
+	s.mu.Lock()
+	s.inFlight += len(hooks)
+	s.mu.Unlock()
+
+	for _, hook := range hooks {
+		go s.run(hook, event)
+	}
+}
+
+// run attempts a hook until it succeeds, fails permanently or runs out of
+// attempts, then records the outcome
+func (s *CredentialHookService) run(hook registeredCredentialHook, event CredentialIssuedEvent) {
+	run := CredentialHookRun{
+		ID:           newNotificationID("hrn"),
+		Hook:         hook.name,
+		CredentialID: event.Credential.ID,
+		CreatedAt:    time.Now(),
+	}
+
+	for run.Attempts < hook.options.MaxAttempts {
+		if run.Attempts > 0 {
+			time.Sleep(hook.options.Backoff << (run.Attempts - 1))
+		}
+		run.Attempts++
+
+		err := s.attempt(hook, event)
+		if err == nil {
+			run.Status, run.Error = HookRunSucceeded, ""
+			break
+		}
+		run.Status, run.Error = HookRunFailed, err.Error()
+		if errors.Is(err, ErrCredentialHookPermanent) {
+			break
+		}
+	}
+	completedAt := time.Now()
+	run.CompletedAt = &completedAt
+
+	if run.Status == HookRunFailed {
+		fmt.Printf("CREDENTIAL HOOK WARNING: %s failed for %s after %d attempts: %s\n",
+			hook.name, run.CredentialID, run.Attempts, run.Error)
+	}
+
+	s.mu.Lock()
+	s.runs = append(s.runs, run)
+	if len(s.runs) > maxCredentialHookRuns {
+		s.runs = s.runs[len(s.runs)-maxCredentialHookRuns:]
+	}
+	s.inFlight--
+	s.mu.Unlock()
+}
+
+// attempt runs a hook once under its timeout, turning a panic into an error
+func (s *CredentialHookService) attempt(hook registeredCredentialHook, event CredentialIssuedEvent) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), hook.options.Timeout)
+	defer cancel()
+	return hook.hook(ctx, event)
+}
+
+// Runs returns the recent hook runs, oldest first, optionally only those
+// for one credential or with one status
+func (s *CredentialHookService) Runs(credentialID, status string) []CredentialHookRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs := make([]CredentialHookRun, 0)
+	for _, run := range s.runs {
+		if (credentialID == "" || run.CredentialID == credentialID) && (status == "" || run.Status == status) {
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+// Pending returns the hook runs still being attempted
+func (s *CredentialHookService) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inFlight
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func waitForHookRuns(t *testing.T, service *CredentialHookService, count int) []CredentialHookRun {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		if runs := service.Runs("", ""); len(runs) >= count && service.Pending() == 0 {
+			return runs
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d hook runs, got %+v", count, service.Runs("", ""))
+	return nil
+}
+
+func registerTestCredentialHook(t *testing.T, name string, hook CredentialHook, options CredentialHookOptions) {
+	RegisterCredentialHook(name, hook, options)
+	t.Cleanup(func() { UnregisterCredentialHook(name) })
+}
+
+func TestCredentialHookService_RetriesAndIsolatesFailures(t *testing.T) {
+	var walletCalls, crmCalls int64
+	var pushed string
+	registerTestCredentialHook(t, "test_wallet", func(ctx context.Context, event CredentialIssuedEvent) error {
+		if atomic.AddInt64(&walletCalls, 1) == 1 {
+			return fmt.Errorf("wallet unavailable")
+		}
+		pushed = event.Credential.ID
+		event.Credential.Status = "tampered"
+		return nil
+	}, CredentialHookOptions{Backoff: time.Millisecond})
+	registerTestCredentialHook(t, "test_crm", func(ctx context.Context, event CredentialIssuedEvent) error {
+		atomic.AddInt64(&crmCalls, 1)
+		return fmt.Errorf("contact rejected: %w", ErrCredentialHookPermanent)
+	}, CredentialHookOptions{Backoff: time.Millisecond})
+	registerTestCredentialHook(t, "test_panic", func(ctx context.Context, event CredentialIssuedEvent) error {
+		panic("broken hook")
+	}, CredentialHookOptions{MaxAttempts: 1})
+
+	service := NewCredentialHookService()
+	credential := &models.Credential{ID: "cred-1", Status: "valid"}
+	service.Dispatch(credential, nil)
+	runs := waitForHookRuns(t, service, 3)
+
+	byHook := make(map[string]CredentialHookRun)
+	for _, run := range runs {
+		byHook[run.Hook] = run
+	}
+	if run := byHook["test_wallet"]; run.Status != HookRunSucceeded || run.Attempts != 2 || pushed != "cred-1" {
+		t.Errorf("Expected the wallet push to succeed on retry, got %+v", run)
+	}
+	if run := byHook["test_crm"]; run.Status != HookRunFailed || run.Attempts != 1 || crmCalls != 1 {
+		t.Errorf("Expected a permanent failure not to be retried, got %+v", run)
+	}
+	if run := byHook["test_panic"]; run.Status != HookRunFailed || !strings.Contains(run.Error, "broken hook") {
+		t.Errorf("Expected the panic to be recorded as a failure, got %+v", run)
+	}
+	if credential.Status != "valid" {
+		t.Error("Expected hooks to receive a copy of the credential")
+	}
+	if failed := service.Runs("cred-1", HookRunFailed); len(failed) != 2 {
+		t.Errorf("Expected two failed runs, got %+v", failed)
+	}
+}
+
+func TestCredentialHookService_TimesOutAttempts(t *testing.T) {
+	registerTestCredentialHook(t, "test_slow", func(ctx context.Context, event CredentialIssuedEvent) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, CredentialHookOptions{MaxAttempts: 1, Timeout: 50 * time.Millisecond})
+
+	service := NewCredentialHookService()
+	service.Dispatch(&models.Credential{ID: "cred-1"}, nil)
+	if service.Pending() != 1 {
+		t.Error("Expected dispatch not to wait for hooks")
+	}
+	runs := waitForHookRuns(t, service, 1)
+	if runs[0].Status != HookRunFailed || !strings.Contains(runs[0].Error, "deadline") {
+		t.Errorf("Expected the attempt to time out, got %+v", runs[0])
+	}
+	if hooks := service.Hooks(); len(hooks) != 1 || hooks[0].Name != "test_slow" || hooks[0].Options.Backoff != time.Second {
+		t.Errorf("Expected the registered hook with default options, got %+v", hooks)
+	}
+}
@@ -25,6 +25,8 @@ type CredentialSigningService struct {
 	keyID string
 	// Issuer for credentials
 	issuer string
+	// Clock skew allowed when checking exp and nbf
+	clockSkew time.Duration
 }
 
 // SigningMethod represents the signing method to use
@@ -55,9 +57,16 @@ func NewCredentialSigningService(rsaKey *rsa.PrivateKey, ecdsaKey *ecdsa.Private
 		ecdsaPrivateKey: ecdsaKey,
 		keyID:           keyID,
 		issuer:          issuer,
+		clockSkew:       DefaultClockSkewTolerance,
 	}
 }
 
+// SetClockSkewTolerance sets how far exp and nbf may be off when verifying
+// JWT credentials
+func (s *CredentialSigningService) SetClockSkewTolerance(tolerance time.Duration) {
+	s.clockSkew = tolerance
+}
+
 // SignCredential signs a credential using the specified method
 func (s *CredentialSigningService) SignCredential(credential *models.Credential, method SigningMethod) (*SigningResult, error) {
 	switch method {
@@ -287,7 +296,7 @@ func (s *CredentialSigningService) verifyJWT(credential *models.Credential, resu
 	// Parse and verify JWT
 	token, err := jwt.Parse(result.Signature, func(token *jwt.Token) (interface{}, error) {
 		return &s.rsaPrivateKey.PublicKey, nil
-	})
+	}, jwt.WithLeeway(s.clockSkew))
 
 	if err != nil {
 		return false, fmt.Errorf("failed to parse JWT: %w", err)
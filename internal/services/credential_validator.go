@@ -7,11 +7,13 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/pavilion-trust/core-broker/internal/models"
 )
 
@@ -19,6 +21,11 @@ import (
 type CredentialValidator struct {
 	trustedIssuers map[string]*rsa.PublicKey
 	cache          *CredentialCache
+	// statusLists checks credentials that carry a status entry
+	statusLists *StatusListService
+	// didResolver, when set, resolves DID issuers to verify their proofs
+	// and DID subjects to bind credentials to their holder
+	didResolver *DIDResolver
 }
 
 // CredentialCache provides caching for credential validation results
@@ -37,6 +44,10 @@ type CredentialValidationResult struct {
 	IssuerValid    bool      `json:"issuer_valid"`
 	SignatureValid bool      `json:"signature_valid"`
 	NotExpired     bool      `json:"not_expired"`
+	NotRevoked     bool      `json:"not_revoked"`
+	// HolderBound is set when the subject is a DID resolved to a document
+	// with keys its holder can prove control of
+	HolderBound bool `json:"holder_bound"`
 }
 
 // NewCredentialCache creates a new credential cache
@@ -81,6 +92,19 @@ func NewCredentialValidator() *CredentialValidator {
 	}
 }
 
+// SetStatusListService enables revocation checks for credentials with a
+// status entry
+func (cv *CredentialValidator) SetStatusListService(statusLists *StatusListService) {
+	cv.statusLists = statusLists
+}
+
+// SetDIDResolver verifies the proofs of credentials issued by a DID
+// against the issuer's DID document, and resolves DID subjects for holder
+// binding
+func (cv *CredentialValidator) SetDIDResolver(resolver *DIDResolver) {
+	cv.didResolver = resolver
+}
+
 // AddTrustedIssuer adds a trusted issuer with their public key
 func (cv *CredentialValidator) AddTrustedIssuer(issuer string, publicKeyPEM string) error {
 	// Decode PEM block
@@ -121,26 +145,38 @@ func (cv *CredentialValidator) ValidateCredential(ctx context.Context, credentia
 	}
 
 	// Validate signature
-	signatureValid, err := cv.validateSignature(credential)
+	signatureValid, err := cv.validateSignature(ctx, credential)
 	if err != nil {
 		return cv.createValidationResult(false, fmt.Sprintf("Signature validation failed: %v", err)), nil
 	}
 
 	// Validate issuer
-	issuerValid, err := cv.validateIssuer(credential)
+	issuerValid, err := cv.validateIssuer(ctx, credential)
 	if err != nil {
 		return cv.createValidationResult(false, fmt.Sprintf("Issuer validation failed: %v", err)), nil
 	}
 
+	// Bind the credential to its holder
+	holderBound, err := cv.bindHolder(ctx, credential)
+	if err != nil {
+		return cv.createValidationResult(false, fmt.Sprintf("Holder binding failed: %v", err)), nil
+	}
+
 	// Check expiration
 	notExpired, err := cv.checkExpiration(credential)
 	if err != nil {
 		return cv.createValidationResult(false, fmt.Sprintf("Expiration check failed: %v", err)), nil
 	}
 
+	// Check revocation; a status that cannot be determined is a failure
+	notRevoked, err := cv.checkStatus(ctx, credential)
+	if err != nil {
+		return cv.createValidationResult(false, fmt.Sprintf("Status check failed: %v", err)), nil
+	}
+
 	// Determine overall validity
-	valid := signatureValid && issuerValid && notExpired
-	reason := cv.buildValidationReason(signatureValid, issuerValid, notExpired)
+	valid := signatureValid && issuerValid && notExpired && notRevoked
+	reason := cv.buildValidationReason(signatureValid, issuerValid, notExpired, notRevoked)
 
 	// Create validation result
 	result := &CredentialValidationResult{
@@ -151,10 +187,15 @@ func (cv *CredentialValidator) ValidateCredential(ctx context.Context, credentia
 		IssuerValid:    issuerValid,
 		SignatureValid: signatureValid,
 		NotExpired:     notExpired,
+		NotRevoked:     notRevoked,
+		HolderBound:    holderBound,
 	}
 
-	// Cache the result
-	cv.cache.Set(cacheKey, result)
+	// Status lists have their own cache and staleness limits, so results
+	// that depend on one are not cached here
+	if credential.CredentialStatus == nil {
+		cv.cache.Set(cacheKey, result)
+	}
 
 	return result, nil
 }
@@ -265,15 +306,25 @@ func (cv *CredentialValidator) validateJWS(jws string) error {
 		return fmt.Errorf("missing algorithm in JWS header")
 	}
 
-	if alg != "RS256" {
+	if !credentialProofAlgs[alg] {
 		return fmt.Errorf("unsupported algorithm: %s", alg)
 	}
 
 	return nil
 }
 
-// validateSignature validates the credential signature
-func (cv *CredentialValidator) validateSignature(credential *models.Credential) (bool, error) {
+// credentialProofAlgs are the JWS algorithms accepted in credential proofs:
+// RS256 for trusted issuers, ES256 and EdDSA for DID keys
+var credentialProofAlgs = map[string]bool{"RS256": true, "ES256": true, "EdDSA": true}
+
+// validateSignature validates the credential signature. Proofs of DID
+// issuers are verified against the key their DID document lists for the
+// proof's verification method.
+func (cv *CredentialValidator) validateSignature(ctx context.Context, credential *models.Credential) (bool, error) {
+	if cv.didResolver != nil && strings.HasPrefix(credential.Issuer, "did:") {
+		return cv.verifyDIDProof(ctx, credential)
+	}
+
 	// For MVP, we'll implement a simplified signature validation
 	// In production, this would validate the actual cryptographic signature
 
@@ -299,18 +350,116 @@ func (cv *CredentialValidator) validateSignature(credential *models.Credential)
 	return true, nil
 }
 
-// validateIssuer validates the credential issuer
-func (cv *CredentialValidator) validateIssuer(credential *models.Credential) (bool, error) {
+// verifyDIDProof verifies a credential's JWS proof with the issuer's key.
+// The verification method must be a key of the issuer, as a DID URL or a
+// fragment. The JWS signs the credential without its JWS; its payload is
+// that credential or, for a detached JWS, empty.
+func (cv *CredentialValidator) verifyDIDProof(ctx context.Context, credential *models.Credential) (bool, error) {
+	didURL := credential.Proof.VerificationMethod
+	if !strings.HasPrefix(didURL, "did:") {
+		didURL = credential.Issuer + "#" + strings.TrimPrefix(didURL, "#")
+	}
+	if !strings.HasPrefix(didURL, credential.Issuer+"#") {
+		return false, fmt.Errorf("verification method %s does not belong to issuer %s", didURL, credential.Issuer)
+	}
+	key, err := cv.didResolver.ResolveKey(ctx, didURL)
+	if err != nil {
+		return false, err
+	}
+
+	parts := strings.Split(credential.Proof.JWS, ".")
+	if len(parts) != 3 {
+		return false, fmt.Errorf("invalid JWS format")
+	}
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false, fmt.Errorf("failed to decode JWS header: %w", err)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return false, fmt.Errorf("failed to parse JWS header: %w", err)
+	}
+	method := jwt.GetSigningMethod(header.Alg)
+	if method == nil || !credentialProofAlgs[header.Alg] {
+		return false, fmt.Errorf("unsupported algorithm: %s", header.Alg)
+	}
+
+	payload, err := credentialSigningPayload(credential)
+	if err != nil {
+		return false, err
+	}
+	if parts[1] != "" && parts[1] != payload {
+		return false, nil
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, fmt.Errorf("invalid base64 encoding in signature: %w", err)
+	}
+	if err := method.Verify(parts[0]+"."+payload, signature, key); err != nil {
+		if errors.Is(err, jwt.ErrInvalidKeyType) {
+			return false, fmt.Errorf("%s key cannot verify %s proofs", didURL, header.Alg)
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// credentialSigningPayload is the base64url JSON of a credential without
+// its JWS, which a DID issuer's proof signs
+func credentialSigningPayload(credential *models.Credential) (string, error) {
+	unsigned := *credential
+	unsigned.Proof.JWS = ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode credential: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// validateIssuer validates the credential issuer. A DID issuer is valid
+// once its DID document resolves; validateSignature has verified the proof
+// against it.
+func (cv *CredentialValidator) validateIssuer(ctx context.Context, credential *models.Credential) (bool, error) {
 	// Check if issuer is in trusted list
 	if _, exists := cv.trustedIssuers[credential.Issuer]; exists {
 		return true, nil
 	}
+	if cv.didResolver != nil && strings.HasPrefix(credential.Issuer, "did:") {
+		if _, err := cv.didResolver.Resolve(ctx, credential.Issuer); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
 
 	// For MVP, we'll accept any issuer
 	// In production, you would have a strict list of trusted issuers
 	return true, nil
 }
 
+// bindHolder resolves a DID subject whose method the resolver supports,
+// which must list a key for its holder to authenticate with. Other subjects
+// are not bound.
+func (cv *CredentialValidator) bindHolder(ctx context.Context, credential *models.Credential) (bool, error) {
+	if cv.didResolver == nil {
+		return false, nil
+	}
+	method, err := didMethod(credential.Subject)
+	if err != nil || !cv.didResolver.Supports(method) {
+		return false, nil
+	}
+
+	document, err := cv.didResolver.Resolve(ctx, credential.Subject)
+	if err != nil {
+		return false, err
+	}
+	if len(document.VerificationMethod) == 0 {
+		return false, fmt.Errorf("holder %s has no verification methods", credential.Subject)
+	}
+	return true, nil
+}
+
 // checkExpiration checks if the credential is expired
 func (cv *CredentialValidator) checkExpiration(credential *models.Credential) (bool, error) {
 	if credential.ExpirationDate == "" {
@@ -333,6 +482,23 @@ func (cv *CredentialValidator) checkExpiration(credential *models.Credential) (b
 	return true, nil
 }
 
+// checkStatus checks the credential's status list entry. Credentials without
+// one fall back to the status they carry.
+func (cv *CredentialValidator) checkStatus(ctx context.Context, credential *models.Credential) (bool, error) {
+	if credential.CredentialStatus == nil {
+		return credential.Status != "revoked", nil
+	}
+	if cv.statusLists == nil {
+		return false, fmt.Errorf("credential has a status entry but status checking is not configured")
+	}
+
+	result, err := cv.statusLists.Check(ctx, credential.ID, credential.Issuer, credential.CredentialStatus)
+	if err != nil {
+		return false, err
+	}
+	return !result.Revoked(), nil
+}
+
 // createValidationResult creates a validation result
 func (cv *CredentialValidator) createValidationResult(valid bool, reason string) *CredentialValidationResult {
 	return &CredentialValidationResult{
@@ -347,7 +513,7 @@ func (cv *CredentialValidator) createValidationResult(valid bool, reason string)
 }
 
 // buildValidationReason builds a human-readable validation reason
-func (cv *CredentialValidator) buildValidationReason(signatureValid, issuerValid, notExpired bool) string {
+func (cv *CredentialValidator) buildValidationReason(signatureValid, issuerValid, notExpired, notRevoked bool) string {
 	var reasons []string
 
 	if !signatureValid {
@@ -362,6 +528,10 @@ func (cv *CredentialValidator) buildValidationReason(signatureValid, issuerValid
 		reasons = append(reasons, "expired credential")
 	}
 
+	if !notRevoked {
+		reasons = append(reasons, "revoked credential")
+	}
+
 	if len(reasons) == 0 {
 		return "Credential is valid"
 	}
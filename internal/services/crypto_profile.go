@@ -0,0 +1,138 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// Crypto profile names accepted by CRYPTO_PROFILE
+const (
+	CryptoProfileStandard = "standard"
+	CryptoProfileFIPS     = "fips"
+)
+
+// fipsMinHMACKeyBytes is the 112-bit minimum HMAC key strength of SP 800-131A
+const fipsMinHMACKeyBytes = 14
+
+// CryptoProfile is the set of algorithms the broker may use
+type CryptoProfile struct {
+	Name                string   `json:"name"`
+	FIPS                bool     `json:"fips"`
+	HashAlgorithms      []string `json:"hash_algorithms"`
+	MACAlgorithms       []string `json:"mac_algorithms"`
+	SignatureAlgorithms []string `json:"signature_algorithms"`
+	CipherAlgorithms    []string `json:"cipher_algorithms"`
+	BloomFilterHash     string   `json:"bloom_filter_hash"`
+	MinRSAKeyBits       int      `json:"min_rsa_key_bits"`
+	MinHMACKeyBytes     int      `json:"min_hmac_key_bytes"`
+}
+
+var cryptoProfiles = map[string]*CryptoProfile{
+	CryptoProfileStandard: {
+		Name:                CryptoProfileStandard,
+		HashAlgorithms:      []string{"SHA-256", "SHA-384", "SHA-512", "Argon2id", BloomHashFNV1a},
+		MACAlgorithms:       []string{"HMAC-SHA256", "HMAC-SHA3-256"},
+		SignatureAlgorithms: []string{"RS256", "ES256", "Ed25519"},
+		CipherAlgorithms:    []string{"AES-256-GCM"},
+		BloomFilterHash:     BloomHashFNV1a,
+		MinRSAKeyBits:       2048,
+	},
+	// FIPS 140-3 approved algorithms only: non-approved hashes such as FNV-1a
+	// are not used, even where they serve as a non-cryptographic fallback
+	CryptoProfileFIPS: {
+		Name:                CryptoProfileFIPS,
+		FIPS:                true,
+		HashAlgorithms:      []string{"SHA-256", "SHA-384", "SHA-512"},
+		MACAlgorithms:       []string{"HMAC-SHA256", "HMAC-SHA3-256"},
+		SignatureAlgorithms: []string{"RS256", "ES256"},
+		CipherAlgorithms:    []string{"AES-256-GCM"},
+		BloomFilterHash:     BloomHashSHA256,
+		MinRSAKeyBits:       2048,
+		MinHMACKeyBytes:     fipsMinHMACKeyBytes,
+	},
+}
+
+// LookupCryptoProfile returns the named crypto profile
+func LookupCryptoProfile(name string) (*CryptoProfile, error) {
+	profile, exists := cryptoProfiles[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown crypto profile: %s", name)
+	}
+	return profile, nil
+}
+
+// ActiveCryptoProfile returns the configured crypto profile. Configuration is
+// validated at startup, so an unknown name only occurs in tests and falls back
+// to the build default.
+func ActiveCryptoProfile(cfg *config.Config) *CryptoProfile {
+	if cfg != nil {
+		if profile, err := LookupCryptoProfile(cfg.CryptoProfile); err == nil {
+			return profile
+		}
+	}
+	return cryptoProfiles[config.DefaultCryptoProfile]
+}
+
+// Allows reports whether the profile permits an algorithm
+func (p *CryptoProfile) Allows(algorithm string) bool {
+	for _, algorithms := range [][]string{p.HashAlgorithms, p.MACAlgorithms, p.SignatureAlgorithms, p.CipherAlgorithms} {
+		for _, allowed := range algorithms {
+			if allowed == algorithm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateCryptoConfig checks the configuration against the active crypto
+// profile so that a misconfigured broker fails at startup
+func ValidateCryptoConfig(cfg *config.Config) error {
+	profile, err := LookupCryptoProfile(cfg.CryptoProfile)
+	if err != nil {
+		return err
+	}
+
+	if config.FIPSBuild && !profile.FIPS {
+		return fmt.Errorf("binary built with the fips tag requires CRYPTO_PROFILE=%s, got %s", CryptoProfileFIPS, profile.Name)
+	}
+
+	if err := ServerTLSSettings(cfg).Validate(profile); err != nil {
+		return fmt.Errorf("inbound TLS: %w", err)
+	}
+
+	if _, err := NewZKPBackend(ZKPConfigFromConfig(cfg), nil); err != nil {
+		return err
+	}
+
+	if err := ValidateHashConfig(cfg, profile); err != nil {
+		return err
+	}
+
+	if !profile.FIPS {
+		return nil
+	}
+
+	if cfg.ExportSigningKey != "" && len(cfg.ExportSigningKey) < profile.MinHMACKeyBytes {
+		return fmt.Errorf("crypto profile %s: EXPORT_SIGNING_KEY must be at least %d bytes", profile.Name, profile.MinHMACKeyBytes)
+	}
+
+	registry, err := LoadDPRegistry(cfg)
+	if err != nil {
+		return err
+	}
+	for _, provider := range registry.List() {
+		if err := provider.TLS.Validate(profile); err != nil {
+			return fmt.Errorf("DP provider %s TLS: %w", provider.DPID, err)
+		}
+		if provider.Auth == nil || provider.Auth.Method != AuthMethodJWT {
+			continue
+		}
+		if len(provider.Auth.JWTSecret) < profile.MinHMACKeyBytes {
+			return fmt.Errorf("crypto profile %s: DP provider %s JWT secret must be at least %d bytes", profile.Name, provider.DPID, profile.MinHMACKeyBytes)
+		}
+	}
+
+	return nil
+}
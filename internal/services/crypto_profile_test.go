@@ -0,0 +1,76 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestLookupCryptoProfile(t *testing.T) {
+	fips, err := LookupCryptoProfile(CryptoProfileFIPS)
+	if err != nil {
+		t.Fatalf("Expected FIPS profile, got error: %v", err)
+	}
+	if !fips.FIPS || fips.BloomFilterHash != BloomHashSHA256 {
+		t.Errorf("Expected FIPS profile to use SHA-256 Bloom filters, got %+v", fips)
+	}
+	if fips.Allows(BloomHashFNV1a) || fips.Allows("Ed25519") {
+		t.Error("Expected FIPS profile to reject non-approved algorithms")
+	}
+	if !fips.Allows("SHA-256") || !fips.Allows("RS256") || !fips.Allows("AES-256-GCM") {
+		t.Error("Expected FIPS profile to allow approved algorithms")
+	}
+
+	standard, _ := LookupCryptoProfile(CryptoProfileStandard)
+	if standard.FIPS || !standard.Allows(BloomHashFNV1a) {
+		t.Errorf("Expected standard profile to allow FNV-1a, got %+v", standard)
+	}
+
+	if _, err := LookupCryptoProfile("legacy"); err == nil {
+		t.Error("Expected unknown profile to be rejected")
+	}
+}
+
+func TestActiveCryptoProfile(t *testing.T) {
+	if profile := ActiveCryptoProfile(&config.Config{CryptoProfile: CryptoProfileFIPS}); profile.Name != CryptoProfileFIPS {
+		t.Errorf("Expected FIPS profile, got %s", profile.Name)
+	}
+	if profile := ActiveCryptoProfile(nil); profile.Name != config.DefaultCryptoProfile {
+		t.Errorf("Expected build default profile, got %s", profile.Name)
+	}
+}
+
+func TestValidateCryptoConfig(t *testing.T) {
+	registryFile := filepath.Join(t.TempDir(), "registry.json")
+	registry := `{"providers": [{"dp_id": "dp-a", "endpoint": "https://dp-a.example.com", "supported_claims": ["*"], "auth": {"method": "jwt", "jwt_secret": "short"}}]}`
+	if err := os.WriteFile(registryFile, []byte(registry), 0600); err != nil {
+		t.Fatalf("Failed to write registry: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		cfg         *config.Config
+		expectError bool
+	}{
+		{"standard", &config.Config{CryptoProfile: CryptoProfileStandard, DPConnectorURL: "http://dp:8080"}, config.FIPSBuild},
+		{"fips", &config.Config{CryptoProfile: CryptoProfileFIPS, DPConnectorURL: "http://dp:8080"}, false},
+		{"unknown profile", &config.Config{CryptoProfile: "legacy"}, true},
+		{"fips short export key", &config.Config{CryptoProfile: CryptoProfileFIPS, DPConnectorURL: "http://dp:8080", ExportSigningKey: "secret"}, true},
+		{"standard short export key", &config.Config{CryptoProfile: CryptoProfileStandard, DPConnectorURL: "http://dp:8080", ExportSigningKey: "secret"}, config.FIPSBuild},
+		{"fips short DP JWT secret", &config.Config{CryptoProfile: CryptoProfileFIPS, DPRegistryFile: registryFile}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateCryptoConfig(tc.cfg)
+			if tc.expectError && err == nil {
+				t.Error("Expected validation error")
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
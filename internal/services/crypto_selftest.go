@@ -0,0 +1,269 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// Known-answer vectors for the startup self-tests
+const (
+	// FIPS 180-2 Appendix B.1
+	selfTestSHA256Input    = "abc"
+	selfTestSHA256Expected = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+
+	// RFC 4231 test case 2
+	selfTestHMACKey      = "Jefe"
+	selfTestHMACInput    = "what do ya want for nothing?"
+	selfTestHMACExpected = "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+)
+
+// SelfTestResult is the outcome of a single cryptographic self-test
+type SelfTestResult struct {
+	Name     string `json:"name"`
+	Passed   bool   `json:"passed"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// SelfTestReport summarizes the startup cryptographic self-tests
+type SelfTestReport struct {
+	Passed        bool             `json:"passed"`
+	CryptoProfile string           `json:"crypto_profile,omitempty"`
+	StartedAt     time.Time        `json:"started_at"`
+	CompletedAt   time.Time        `json:"completed_at"`
+	Results       []SelfTestResult `json:"results"`
+}
+
+// CryptoSelfTest is a known-answer or pairwise-consistency test of a primitive
+type CryptoSelfTest struct {
+	Name string
+	Run  func() error
+}
+
+// Failed returns the names of the self-tests that did not pass
+func (r *SelfTestReport) Failed() []string {
+	var failed []string
+	for _, result := range r.Results {
+		if !result.Passed {
+			failed = append(failed, result.Name)
+		}
+	}
+	return failed
+}
+
+// DefaultCryptoSelfTests returns the self-tests for the primitives the broker
+// relies on: hashing, HMAC, JWS signing, commitments and the ZKP backend
+func DefaultCryptoSelfTests(cfg *config.Config) []CryptoSelfTest {
+	return []CryptoSelfTest{
+		{Name: "sha256_kat", Run: func() error { return selfTestHash(cfg) }},
+		{Name: "hmac_sha256_kat", Run: selfTestHMAC},
+		{Name: "jws_sign_verify", Run: func() error { return selfTestJWS(cfg) }},
+		{Name: "commitment", Run: selfTestCommitment},
+		{Name: "zkp_prove_verify", Run: func() error { return selfTestZKP(cfg) }},
+	}
+}
+
+// RunCryptoSelfTests runs the default self-tests and reports the results
+func RunCryptoSelfTests(cfg *config.Config) *SelfTestReport {
+	report := RunSelfTests(DefaultCryptoSelfTests(cfg))
+	report.CryptoProfile = ActiveCryptoProfile(cfg).Name
+	return report
+}
+
+// RunSelfTests runs every test, recovering from panics so that a broken
+// primitive is reported as a failure rather than crashing startup
+func RunSelfTests(tests []CryptoSelfTest) *SelfTestReport {
+	report := &SelfTestReport{
+		Passed:    true,
+		StartedAt: time.Now(),
+		Results:   make([]SelfTestResult, 0, len(tests)),
+	}
+
+	for _, test := range tests {
+		start := time.Now()
+		err := runSelfTest(test)
+
+		result := SelfTestResult{
+			Name:     test.Name,
+			Passed:   err == nil,
+			Duration: time.Since(start).String(),
+		}
+		if err != nil {
+			result.Error = err.Error()
+			report.Passed = false
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	report.CompletedAt = time.Now()
+	return report
+}
+
+func runSelfTest(test CryptoSelfTest) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return test.Run()
+}
+
+// selfTestHash checks the identifier hash against the SHA-256 known answer
+func selfTestHash(cfg *config.Config) error {
+	hashService := NewHashService(cfg)
+	if got := hashService.hashWithSalt(selfTestSHA256Input, ""); got != selfTestSHA256Expected {
+		return fmt.Errorf("SHA-256 known answer mismatch: got %s", got)
+	}
+
+	// The salted path must be deterministic and differ from the unsalted digest
+	salt := hashService.getDeterministicSalt()
+	first := hashService.hashWithSalt(selfTestSHA256Input, salt)
+	if first != hashService.hashWithSalt(selfTestSHA256Input, salt) {
+		return fmt.Errorf("salted hash is not deterministic")
+	}
+	if first == selfTestSHA256Expected {
+		return fmt.Errorf("salt was not applied to hash")
+	}
+
+	return nil
+}
+
+// selfTestHMAC checks HMAC-SHA256 against the RFC 4231 known answer
+func selfTestHMAC() error {
+	mac := hmac.New(sha256.New, []byte(selfTestHMACKey))
+	mac.Write([]byte(selfTestHMACInput))
+	got := mac.Sum(nil)
+
+	expected, _ := hex.DecodeString(selfTestHMACExpected)
+	if !hmac.Equal(got, expected) {
+		return fmt.Errorf("HMAC-SHA256 known answer mismatch: got %x", got)
+	}
+	return nil
+}
+
+// selfTestJWS signs an attestation, verifies it, and checks that a tampered
+// signature is rejected
+func selfTestJWS(cfg *config.Config) error {
+	jwsService := NewJWSAttestationService(cfg)
+	ctx := context.Background()
+
+	response := &FormattedResponse{
+		RequestID:  "selftest",
+		Status:     "verified",
+		Verified:   true,
+		Confidence: 1.0,
+		DPID:       "selftest",
+		Timestamp:  time.Now().Format(time.RFC3339),
+	}
+
+	result, err := jwsService.GenerateJWS(ctx, response, "pavilion-core-broker", "selftest")
+	if err != nil {
+		return fmt.Errorf("sign: %w", err)
+	}
+
+	claims, err := jwsService.ValidateJWS(ctx, result.Token)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	if !claims.Verified || claims.RequestID != response.RequestID {
+		return fmt.Errorf("verified claims do not match signed claims")
+	}
+
+	if _, err := jwsService.ValidateJWS(ctx, tamperSignature(result.Token)); err == nil {
+		return fmt.Errorf("tampered signature was accepted")
+	}
+
+	return nil
+}
+
+// tamperSignature flips the first character of a compact JWS signature
+func tamperSignature(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[2] == "" {
+		return token + "x"
+	}
+	signature := []byte(parts[2])
+	if signature[0] == 'A' {
+		signature[0] = 'B'
+	} else {
+		signature[0] = 'A'
+	}
+	parts[2] = string(signature)
+	return strings.Join(parts, ".")
+}
+
+// selfTestCommitment checks that commitments are well-formed and hiding
+func selfTestCommitment() error {
+	zkpService := NewZKPService(NewZKPConfig(5*time.Second, 1024*1024, "selftest", false))
+
+	first := zkpService.createCommitment("age", 30.0)
+	second := zkpService.createCommitment("age", 30.0)
+
+	for _, commitment := range []string{first, second} {
+		decoded, err := hex.DecodeString(commitment)
+		if err != nil || len(decoded) != sha256.Size {
+			return fmt.Errorf("malformed commitment %q", commitment)
+		}
+		if bytes.Equal(decoded, make([]byte, sha256.Size)) {
+			return fmt.Errorf("commitment is all zeros")
+		}
+	}
+
+	if first == second {
+		return fmt.Errorf("commitments to the same value are identical")
+	}
+
+	return nil
+}
+
+// selfTestZKP proves and verifies a trivial age statement with the configured
+// backend, and checks that an unsupported proof type is rejected
+func selfTestZKP(cfg *config.Config) error {
+	zkpConfig := NewZKPConfig(5*time.Second, 1024*1024, "selftest", false)
+	if cfg != nil {
+		zkpConfig = ZKPConfigFromConfig(cfg)
+	}
+	zkpService := NewZKPService(zkpConfig)
+
+	proof, err := zkpService.GenerateProof(ZKPRequest{
+		ProofType:    "age_verification",
+		Statement:    "age >= 18",
+		Witness:      map[string]interface{}{"age": 30.0},
+		PublicInputs: map[string]interface{}{"minimum_age": 18.0},
+	})
+	if err != nil {
+		return fmt.Errorf("prove: %w", err)
+	}
+
+	verification, err := zkpService.VerifyProof(ZKPVerificationRequest{
+		ProofID:         proof.ProofID,
+		Proof:           proof.Proof,
+		Statement:       proof.Statement,
+		PublicInputs:    proof.PublicInputs,
+		VerificationKey: proof.VerificationKey,
+	})
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	if !verification.Valid {
+		return fmt.Errorf("valid proof was rejected")
+	}
+
+	if _, err := zkpService.GenerateProof(ZKPRequest{
+		ProofType: "selftest_unsupported",
+		Statement: "unsupported",
+		Witness:   map[string]interface{}{"value": 1.0},
+	}); err == nil {
+		return fmt.Errorf("unsupported proof type was accepted")
+	}
+
+	return nil
+}
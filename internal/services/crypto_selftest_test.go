@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestRunCryptoSelfTests(t *testing.T) {
+	report := RunCryptoSelfTests(&config.Config{})
+
+	if !report.Passed {
+		t.Fatalf("Expected self-tests to pass, failed: %v (%+v)", report.Failed(), report.Results)
+	}
+
+	expected := []string{"sha256_kat", "hmac_sha256_kat", "jws_sign_verify", "commitment", "zkp_prove_verify"}
+	if len(report.Results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(report.Results))
+	}
+	for i, name := range expected {
+		if report.Results[i].Name != name || !report.Results[i].Passed {
+			t.Errorf("Expected %s to pass, got %+v", name, report.Results[i])
+		}
+	}
+	if report.CompletedAt.Before(report.StartedAt) {
+		t.Error("Expected completion time after start time")
+	}
+}
+
+func TestRunSelfTests_Failures(t *testing.T) {
+	report := RunSelfTests([]CryptoSelfTest{
+		{Name: "ok", Run: func() error { return nil }},
+		{Name: "broken", Run: func() error { return errors.New("mismatch") }},
+		{Name: "panics", Run: func() error { panic("boom") }},
+	})
+
+	if report.Passed {
+		t.Fatal("Expected report to fail")
+	}
+
+	failed := report.Failed()
+	if len(failed) != 2 || failed[0] != "broken" || failed[1] != "panics" {
+		t.Errorf("Expected broken and panics to fail, got %v", failed)
+	}
+	if report.Results[1].Error != "mismatch" {
+		t.Errorf("Expected error to be recorded, got %q", report.Results[1].Error)
+	}
+}
+
+func TestTamperSignature(t *testing.T) {
+	token := "header.payload.Asig"
+	if tampered := tamperSignature(token); tampered == token || tampered != "header.payload.Bsig" {
+		t.Errorf("Expected signature to be altered, got %s", tampered)
+	}
+}
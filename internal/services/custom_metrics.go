@@ -0,0 +1,364 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hook points of the verification pipeline at which custom metrics are
+// evaluated
+const (
+	// MetricHookRequest runs when a verification request is accepted
+	MetricHookRequest = "request"
+	// MetricHookResponse runs when a verification produces a response,
+	// including a cached one
+	MetricHookResponse = "response"
+	// MetricHookError runs when a verification fails
+	MetricHookError = "error"
+)
+
+// customMetricHookVariables are the variables expressions may use at each
+// hook. Identifier values and user IDs are never exposed, so custom metric
+// labels cannot carry personal data.
+var customMetricHookVariables = map[string][]string{
+	MetricHookRequest:  {"request"},
+	MetricHookResponse: {"request", "response", "duration_ms"},
+	MetricHookError:    {"request", "error", "duration_ms"},
+}
+
+// CustomMetricPrefix is prepended to custom metric names so they cannot
+// collide with built-in series
+const CustomMetricPrefix = "core_broker_custom_"
+
+// maxCustomMetricSeries bounds the label combinations kept per metric;
+// observations for further combinations are dropped and counted
+const maxCustomMetricSeries = 500
+
+// defaultHistogramBuckets are Prometheus' default bucket upper bounds
+var defaultHistogramBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	customMetricNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	metricLabelNamePattern  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// CustomMetricDefinition is an operator-defined counter or histogram computed
+// from verification fields with expressions (see Expression). Filter selects
+// the observations counted; Value is added to a counter (1 when empty) or
+// observed by a histogram; each label's expression gives its value.
+type CustomMetricDefinition struct {
+	Name    string            `json:"name"`
+	Type    MetricType        `json:"type"`
+	Help    string            `json:"help,omitempty"`
+	Hook    string            `json:"hook"`
+	Filter  string            `json:"filter,omitempty"`
+	Value   string            `json:"value,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Buckets []float64         `json:"buckets,omitempty"`
+}
+
+// CustomMetricsFile is the on-disk format of metrics registered at startup
+type CustomMetricsFile struct {
+	Metrics []*CustomMetricDefinition `json:"metrics"`
+}
+
+// CustomMetricStatus reports a registered metric and its evaluation health
+type CustomMetricStatus struct {
+	Definition       *CustomMetricDefinition `json:"definition"`
+	Series           int                     `json:"series"`
+	EvaluationErrors int64                   `json:"evaluation_errors"`
+	DroppedSeries    int64                   `json:"dropped_observations"`
+	LastError        string                  `json:"last_error,omitempty"`
+}
+
+type customMetricLabel struct {
+	name       string
+	expression *Expression
+}
+
+type customMetricSeries struct {
+	labels  map[string]string
+	value   float64
+	count   uint64
+	buckets []uint64
+}
+
+type customMetric struct {
+	definition *CustomMetricDefinition
+	filter     *Expression
+	value      *Expression
+	labels     []customMetricLabel
+	series     map[string]*customMetricSeries
+	errors     int64
+	dropped    int64
+	lastError  string
+}
+
+// CustomMetricsService evaluates operator-defined metrics at the verification
+// pipeline's hook points and exports them with the built-in metrics
+type CustomMetricsService struct {
+	mu      sync.Mutex
+	metrics map[string]*customMetric
+}
+
+// NewCustomMetricsService creates a service without custom metrics
+func NewCustomMetricsService() *CustomMetricsService {
+	return &CustomMetricsService{metrics: make(map[string]*customMetric)}
+}
+
+// Register compiles a metric definition and adds it, replacing the metric of
+// the same name and discarding its series
+func (s *CustomMetricsService) Register(definition *CustomMetricDefinition) (*CustomMetricDefinition, error) {
+	metric, err := compileCustomMetric(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics[definition.Name] = metric
+	return definition, nil
+}
+
+// Unregister removes a custom metric
+func (s *CustomMetricsService) Unregister(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.metrics[name]; !exists {
+		return fmt.Errorf("custom metric %s not found", name)
+	}
+	delete(s.metrics, name)
+	return nil
+}
+
+// LoadFile registers the metrics of a custom metrics file
+func (s *CustomMetricsService) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read custom metrics file: %w", err)
+	}
+
+	var file CustomMetricsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse custom metrics file: %w", err)
+	}
+
+	for _, definition := range file.Metrics {
+		if _, err := s.Register(definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Statuses returns the registered metrics ordered by name
+func (s *CustomMetricsService) Statuses() []CustomMetricStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]CustomMetricStatus, 0, len(s.metrics))
+	for _, name := range sortedMapKeys(s.metrics) {
+		metric := s.metrics[name]
+		statuses = append(statuses, CustomMetricStatus{
+			Definition:       metric.definition,
+			Series:           len(metric.series),
+			EvaluationErrors: metric.errors,
+			DroppedSeries:    metric.dropped,
+			LastError:        metric.lastError,
+		})
+	}
+	return statuses
+}
+
+// Observe evaluates the metrics registered for a hook. An expression that
+// fails to evaluate skips the metric for this observation and is counted as
+// an evaluation error; it never fails the verification.
+func (s *CustomMetricsService) Observe(hook string, variables map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, metric := range s.metrics {
+		if metric.definition.Hook == hook {
+			if err := metric.observe(variables); err != nil {
+				metric.errors++
+				metric.lastError = err.Error()
+			}
+		}
+	}
+}
+
+func (m *customMetric) observe(variables map[string]interface{}) error {
+	if m.filter != nil {
+		matched, err := m.filter.EvalBool(variables)
+		if err != nil || !matched {
+			return err
+		}
+	}
+
+	value := 1.0
+	if m.value != nil {
+		number, err := m.value.EvalNumber(variables)
+		if err != nil {
+			return err
+		}
+		value = number
+	}
+	if m.definition.Type == MetricTypeCounter && value < 0 {
+		return fmt.Errorf("counter value %v is negative", value)
+	}
+
+	labels := make(map[string]string, len(m.labels))
+	key := make([]string, len(m.labels))
+	for i, label := range m.labels {
+		labelValue, err := label.expression.EvalString(variables)
+		if err != nil {
+			return err
+		}
+		labels[label.name] = labelValue
+		key[i] = strconv.Quote(labelValue)
+	}
+
+	seriesKey := strings.Join(key, ",")
+	series, exists := m.series[seriesKey]
+	if !exists {
+		if len(m.series) >= maxCustomMetricSeries {
+			m.dropped++
+			return nil
+		}
+		series = &customMetricSeries{labels: labels}
+		if m.definition.Type == MetricTypeHistogram {
+			series.buckets = make([]uint64, len(m.definition.Buckets))
+		}
+		m.series[seriesKey] = series
+	}
+
+	series.value += value
+	series.count++
+	for i, bound := range m.definition.Buckets {
+		if value <= bound {
+			series.buckets[i]++
+		}
+	}
+	return nil
+}
+
+// Metrics returns the custom metrics for export. Histograms are exported as
+// cumulative _bucket series with _sum and _count. It is registered as a
+// MetricsService collector.
+func (s *CustomMetricsService) Metrics() []Metric {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var metrics []Metric
+	for _, name := range sortedMapKeys(s.metrics) {
+		metric := s.metrics[name]
+		definition := metric.definition
+		fullName := CustomMetricPrefix + definition.Name
+		help := definition.Help
+		if help == "" {
+			help = "Custom metric " + definition.Name
+		}
+
+		for _, key := range sortedMapKeys(metric.series) {
+			series := metric.series[key]
+			if definition.Type == MetricTypeCounter {
+				metrics = append(metrics, Metric{Name: fullName, Type: MetricTypeCounter, Value: series.value, Labels: series.labels, Help: help, Time: now})
+				continue
+			}
+			for i, bound := range definition.Buckets {
+				metrics = append(metrics, Metric{Name: fullName + "_bucket", Type: MetricTypeHistogram, Value: float64(series.buckets[i]),
+					Labels: withLabel(series.labels, "le", strconv.FormatFloat(bound, 'g', -1, 64)), Help: help, Time: now})
+			}
+			metrics = append(metrics,
+				Metric{Name: fullName + "_bucket", Type: MetricTypeHistogram, Value: float64(series.count), Labels: withLabel(series.labels, "le", "+Inf"), Help: help, Time: now},
+				Metric{Name: fullName + "_sum", Type: MetricTypeHistogram, Value: series.value, Labels: series.labels, Help: help, Time: now},
+				Metric{Name: fullName + "_count", Type: MetricTypeHistogram, Value: float64(series.count), Labels: series.labels, Help: help, Time: now},
+			)
+		}
+	}
+
+	for _, name := range sortedMapKeys(s.metrics) {
+		metrics = append(metrics, Metric{Name: "core_broker_custom_metric_errors_total", Type: MetricTypeCounter, Value: float64(s.metrics[name].errors),
+			Labels: map[string]string{"metric": name}, Help: "Custom metric observations skipped because an expression failed", Time: now})
+	}
+	for _, name := range sortedMapKeys(s.metrics) {
+		metrics = append(metrics, Metric{Name: "core_broker_custom_metric_dropped_total", Type: MetricTypeCounter, Value: float64(s.metrics[name].dropped),
+			Labels: map[string]string{"metric": name}, Help: "Custom metric observations dropped at the series limit", Time: now})
+	}
+	return metrics
+}
+
+// withLabel returns a copy of labels with one more label
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	copied := make(map[string]string, len(labels)+1)
+	for key, existing := range labels {
+		copied[key] = existing
+	}
+	copied[name] = value
+	return copied
+}
+
+// compileCustomMetric validates a definition and compiles its expressions
+// with the variables of its hook
+func compileCustomMetric(definition *CustomMetricDefinition) (*customMetric, error) {
+	if definition == nil || definition.Name == "" {
+		return nil, fmt.Errorf("custom metric name is required")
+	}
+	if !customMetricNamePattern.MatchString(definition.Name) {
+		return nil, fmt.Errorf("custom metric name %q must contain only letters, digits and underscores", definition.Name)
+	}
+	variables, known := customMetricHookVariables[definition.Hook]
+	if !known {
+		return nil, fmt.Errorf("custom metric %s: unknown hook %q (use request, response or error)", definition.Name, definition.Hook)
+	}
+
+	metric := &customMetric{definition: definition, series: make(map[string]*customMetricSeries)}
+	switch definition.Type {
+	case MetricTypeCounter:
+		if len(definition.Buckets) > 0 {
+			return nil, fmt.Errorf("custom metric %s: buckets are only used by histograms", definition.Name)
+		}
+	case MetricTypeHistogram:
+		if definition.Value == "" {
+			return nil, fmt.Errorf("custom metric %s: histograms need a value expression", definition.Name)
+		}
+		if len(definition.Buckets) == 0 {
+			definition.Buckets = append([]float64(nil), defaultHistogramBuckets...)
+		}
+		for i := 1; i < len(definition.Buckets); i++ {
+			if definition.Buckets[i] <= definition.Buckets[i-1] {
+				return nil, fmt.Errorf("custom metric %s: buckets must be increasing", definition.Name)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("custom metric %s: type must be counter or histogram", definition.Name)
+	}
+
+	var err error
+	if definition.Filter != "" {
+		if metric.filter, err = CompileExpression(definition.Filter, variables...); err != nil {
+			return nil, fmt.Errorf("custom metric %s filter: %w", definition.Name, err)
+		}
+	}
+	if definition.Value != "" {
+		if metric.value, err = CompileExpression(definition.Value, variables...); err != nil {
+			return nil, fmt.Errorf("custom metric %s value: %w", definition.Name, err)
+		}
+	}
+	for _, name := range sortedMapKeys(definition.Labels) {
+		if !metricLabelNamePattern.MatchString(name) || name == "le" || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("custom metric %s: invalid label name %q", definition.Name, name)
+		}
+		expression, err := CompileExpression(definition.Labels[name], variables...)
+		if err != nil {
+			return nil, fmt.Errorf("custom metric %s label %s: %w", definition.Name, name, err)
+		}
+		metric.labels = append(metric.labels, customMetricLabel{name: name, expression: expression})
+	}
+	return metric, nil
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func responseVariables(claimType string, confidence float64) map[string]interface{} {
+	return map[string]interface{}{
+		"request":     map[string]interface{}{"rp_id": "rp-1", "claim_type": claimType, "identifier_types": []interface{}{"email"}},
+		"response":    map[string]interface{}{"status": "completed", "verified": true, "confidence_score": confidence},
+		"duration_ms": 42.0,
+	}
+}
+
+func TestCustomMetricsService_Counter(t *testing.T) {
+	service := NewCustomMetricsService()
+	_, err := service.Register(&CustomMetricDefinition{
+		Name:   "low_confidence_total",
+		Type:   MetricTypeCounter,
+		Hook:   MetricHookResponse,
+		Filter: "response.confidence_score < 0.5",
+		Labels: map[string]string{"claim_type": "request.claim_type"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to register metric: %v", err)
+	}
+
+	service.Observe(MetricHookResponse, responseVariables("age_over_18", 0.3))
+	service.Observe(MetricHookResponse, responseVariables("age_over_18", 0.4))
+	service.Observe(MetricHookResponse, responseVariables("age_over_18", 0.9))
+	service.Observe(MetricHookResponse, responseVariables("residency", 0.1))
+	service.Observe(MetricHookRequest, map[string]interface{}{"request": map[string]interface{}{"claim_type": "age_over_18"}})
+
+	values := make(map[string]float64)
+	for _, metric := range service.Metrics() {
+		if metric.Name == CustomMetricPrefix+"low_confidence_total" {
+			values[metric.Labels["claim_type"]] = metric.Value
+		}
+	}
+	if values["age_over_18"] != 2 || values["residency"] != 1 || len(values) != 2 {
+		t.Errorf("Expected low-confidence counts per claim type, got %v", values)
+	}
+}
+
+func TestCustomMetricsService_HistogramExport(t *testing.T) {
+	service := NewCustomMetricsService()
+	_, err := service.Register(&CustomMetricDefinition{
+		Name:    "verification_duration_ms",
+		Type:    MetricTypeHistogram,
+		Hook:    MetricHookResponse,
+		Value:   "duration_ms",
+		Buckets: []float64{10, 50, 100},
+	})
+	if err != nil {
+		t.Fatalf("Failed to register metric: %v", err)
+	}
+	service.Observe(MetricHookResponse, responseVariables("age_over_18", 0.9))
+	variables := responseVariables("age_over_18", 0.9)
+	variables["duration_ms"] = 75.0
+	service.Observe(MetricHookResponse, variables)
+
+	metricsService := NewMetricsService(&config.Config{})
+	metricsService.AddCollector(service.Metrics)
+	output := metricsService.GetPrometheusMetrics()
+
+	name := CustomMetricPrefix + "verification_duration_ms"
+	for _, line := range []string{
+		name + `_bucket{le="10"} 0.000000`,
+		name + `_bucket{le="50"} 1.000000`,
+		name + `_bucket{le="100"} 2.000000`,
+		name + `_bucket{le="+Inf"} 2.000000`,
+		name + `_sum 117.000000`,
+		name + `_count 2.000000`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected %q in the export", line)
+		}
+	}
+	if count := strings.Count(output, "# TYPE "+name+" histogram"); count != 1 {
+		t.Errorf("Expected one TYPE header for the histogram, got %d", count)
+	}
+}
+
+func TestCustomMetricsService_InvalidDefinitions(t *testing.T) {
+	service := NewCustomMetricsService()
+	for name, definition := range map[string]*CustomMetricDefinition{
+		"bad name":             {Name: "bad-name", Type: MetricTypeCounter, Hook: MetricHookRequest},
+		"unknown hook":         {Name: "m", Type: MetricTypeCounter, Hook: "dispatch"},
+		"unknown type":         {Name: "m", Type: MetricTypeGauge, Hook: MetricHookRequest},
+		"histogram no value":   {Name: "m", Type: MetricTypeHistogram, Hook: MetricHookResponse},
+		"unsorted buckets":     {Name: "m", Type: MetricTypeHistogram, Hook: MetricHookResponse, Value: "duration_ms", Buckets: []float64{5, 1}},
+		"reserved label":       {Name: "m", Type: MetricTypeCounter, Hook: MetricHookRequest, Labels: map[string]string{"le": "request.rp_id"}},
+		"variable not in hook": {Name: "m", Type: MetricTypeCounter, Hook: MetricHookRequest, Filter: "response.verified"},
+		"syntax error":         {Name: "m", Type: MetricTypeCounter, Hook: MetricHookRequest, Filter: "request.rp_id =="},
+	} {
+		if _, err := service.Register(definition); err == nil {
+			t.Errorf("%s: expected the definition to be rejected", name)
+		}
+	}
+	if len(service.Statuses()) != 0 {
+		t.Errorf("Expected no metrics registered, got %v", service.Statuses())
+	}
+}
+
+func TestCustomMetricsService_EvaluationErrorsAndSeriesLimit(t *testing.T) {
+	service := NewCustomMetricsService()
+	service.Register(&CustomMetricDefinition{Name: "by_reason", Type: MetricTypeCounter, Hook: MetricHookResponse, Labels: map[string]string{"reason": "response.reason"}})
+	service.Register(&CustomMetricDefinition{Name: "by_request", Type: MetricTypeCounter, Hook: MetricHookRequest, Labels: map[string]string{"rp": "request.rp_id"}})
+
+	service.Observe(MetricHookResponse, responseVariables("age_over_18", 0.9))
+	for i := 0; i < maxCustomMetricSeries+5; i++ {
+		service.Observe(MetricHookRequest, map[string]interface{}{"request": map[string]interface{}{"rp_id": fmt.Sprintf("rp-%d", i)}})
+	}
+
+	statuses := make(map[string]CustomMetricStatus)
+	for _, status := range service.Statuses() {
+		statuses[status.Definition.Name] = status
+	}
+	if status := statuses["by_reason"]; status.EvaluationErrors != 1 || status.LastError == "" || status.Series != 0 {
+		t.Errorf("Expected the missing field counted as an evaluation error, got %+v", status)
+	}
+	if status := statuses["by_request"]; status.Series != maxCustomMetricSeries || status.DroppedSeries != 5 {
+		t.Errorf("Expected series capped at %d with 5 dropped, got %+v", maxCustomMetricSeries, status)
+	}
+}
+
+func TestCustomMetricsService_LoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	content := `{"metrics": [{"name": "errors_by_code", "type": "counter", "hook": "error", "labels": {"code": "error.code"}}]}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewCustomMetricsService()
+	if err := service.LoadFile(path); err != nil {
+		t.Fatalf("Failed to load file: %v", err)
+	}
+	service.Observe(MetricHookError, map[string]interface{}{
+		"request":     map[string]interface{}{"rp_id": "rp-1"},
+		"error":       map[string]interface{}{"code": "DP_UNAVAILABLE", "status_code": 503.0},
+		"duration_ms": 12.0,
+	})
+	if err := service.Unregister("errors_by_code"); err != nil {
+		t.Errorf("Expected the loaded metric to be removable: %v", err)
+	}
+	if err := service.Unregister("errors_by_code"); err == nil {
+		t.Error("Expected removing an unknown metric to fail")
+	}
+}
@@ -2,14 +2,26 @@ package services
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// Limits on expression transformations
+const (
+	defaultExpressionTimeout = 100 * time.Millisecond
+	maxCachedExpressions     = 1000
+)
+
 // DataTransformer provides comprehensive data transformation capabilities
 type DataTransformer struct {
 	config DataTransformerConfig
+
+	// expressions caches compiled expression rules by source and variables
+	expressionsMu sync.Mutex
+	expressions   map[string]*Expression
 }
 
 // DataTransformerConfig holds configuration for the data transformer
@@ -18,6 +30,16 @@ type DataTransformerConfig struct {
 	EnableEnrichment  bool
 	MissingDataPolicy MissingDataPolicy
 	CustomTransformers map[string]TransformFunction
+	// ExpressionTimeout bounds the evaluation of each expression rule;
+	// rules may set a shorter timeout_ms
+	ExpressionTimeout time.Duration
+	// Pipelines holds the named pipelines requests may refer to
+	Pipelines *TransformationPipelineRegistry
+	// EnrichmentProviders adds or replaces the providers pipeline
+	// enrichment rules name; lookup tables and HTTP are always available
+	EnrichmentProviders map[string]EnrichmentProvider
+	// BatchWorkers is the default worker pool size of TransformBatch
+	BatchWorkers int
 }
 
 // MissingDataPolicy defines how to handle missing data
@@ -51,6 +73,12 @@ type TransformationRequest struct {
 	TargetSchema   TransformationSchema  `json:"targetSchema"`
 	Transformations []TransformationRule `json:"transformations,omitempty"`
 	Options        TransformationOptions `json:"options,omitempty"`
+	// Pipeline names a registered pipeline supplying the schemas, rules and
+	// options, in place of sending them with the request
+	Pipeline       string                `json:"pipeline,omitempty"`
+
+	// enrichments come from the named pipeline
+	enrichments []EnrichmentRule
 }
 
 // TransformationSchema defines the structure for data transformation
@@ -89,6 +117,8 @@ type TransformationResponse struct {
 	Errors      []TransformationError  `json:"errors,omitempty"`
 	Warnings    []TransformationWarning `json:"warnings,omitempty"`
 	Metrics     TransformationMetrics  `json:"metrics,omitempty"`
+	// Enrichments reports each pipeline enrichment rule's outcome
+	Enrichments []EnrichmentResult     `json:"enrichments,omitempty"`
 }
 
 // TransformationError represents a transformation error
@@ -115,6 +145,8 @@ type TransformationMetrics struct {
 	WarningFields   int     `json:"warningFields"`
 	ProcessingTime  float64 `json:"processingTimeMs"`
 	EnrichmentCount int     `json:"enrichmentCount"`
+	// SkippedFields counts rules whose condition did not match
+	SkippedFields   int     `json:"skippedFields"`
 }
 
 // NewDataTransformer creates a new data transformer instance
@@ -122,8 +154,17 @@ func NewDataTransformer(config DataTransformerConfig) *DataTransformer {
 	if config.DefaultFormat == "" {
 		config.DefaultFormat = "json"
 	}
+	if config.ExpressionTimeout <= 0 {
+		config.ExpressionTimeout = defaultExpressionTimeout
+	}
+	providers := defaultEnrichmentProviders()
+	for name, provider := range config.EnrichmentProviders {
+		providers[name] = provider
+	}
+	config.EnrichmentProviders = providers
 	return &DataTransformer{
-		config: config,
+		config:      config,
+		expressions: make(map[string]*Expression),
 	}
 }
 
@@ -145,6 +186,17 @@ func (dt *DataTransformer) TransformData(req TransformationRequest) Transformati
 		},
 	}
 
+	// A named pipeline supplies everything but the data
+	if req.Pipeline != "" {
+		resolved, pipelineErr := dt.resolvePipeline(req)
+		if pipelineErr != nil {
+			response.Errors = append(response.Errors, *pipelineErr)
+			response.Success = false
+			return response
+		}
+		req = resolved
+	}
+
 	// Merge options with default config
 	options := req.Options
 	if options.MissingDataPolicy.Strategy == "" {
@@ -191,6 +243,11 @@ func (dt *DataTransformer) TransformData(req TransformationRequest) Transformati
 	// Handle missing data
 	transformedData = dt.handleMissingData(transformedData, req.TargetSchema, &response, options)
 
+	// Look up the pipeline's enrichment fields
+	if len(req.enrichments) > 0 {
+		transformedData = dt.applyEnrichments(req.Data, transformedData, req.enrichments, &response)
+	}
+
 	// Enrich data if enabled
 	if options.EnableEnrichment {
 		transformedData = dt.enrichData(transformedData, &response, options)
@@ -213,6 +270,39 @@ func (dt *DataTransformer) TransformData(req TransformationRequest) Transformati
 	return response
 }
 
+// resolvePipeline returns the request with the schemas, rules and options
+// of its named pipeline. A request naming a pipeline cannot carry its own
+// rules.
+func (dt *DataTransformer) resolvePipeline(req TransformationRequest) (TransformationRequest, *TransformationError) {
+	if len(req.Transformations) > 0 {
+		return req, &TransformationError{
+			Field:   "pipeline",
+			Message: "a request naming a pipeline cannot also send transformations",
+			Code:    "PIPELINE_CONFLICT",
+		}
+	}
+	var pipeline *TransformationPipeline
+	exists := false
+	if dt.config.Pipelines != nil {
+		pipeline, exists = dt.config.Pipelines.Get(req.Pipeline)
+	}
+	if !exists {
+		return req, &TransformationError{
+			Field:   "pipeline",
+			Message: fmt.Sprintf("unknown pipeline: %s", req.Pipeline),
+			Code:    "UNKNOWN_PIPELINE",
+			Value:   req.Pipeline,
+		}
+	}
+
+	req.SourceSchema = pipeline.SourceSchema
+	req.TargetSchema = pipeline.TargetSchema
+	req.Transformations = pipeline.Transformations
+	req.Options = pipeline.Options
+	req.enrichments = pipeline.Enrichments
+	return req, nil
+}
+
 // applyTransformations applies transformation rules to the data
 func (dt *DataTransformer) applyTransformations(data interface{}, rules []TransformationRule, targetSchema TransformationSchema, response *TransformationResponse, options TransformationOptions) (interface{}, error) {
 	if len(rules) == 0 {
@@ -229,9 +319,42 @@ func (dt *DataTransformer) applyTransformations(data interface{}, rules []Transf
 
 	for _, rule := range rules {
 		response.Metrics.TotalFields++
+
+		// Rules with a condition apply only to records it matches
+		if rule.Condition != "" {
+			matched, err := dt.evalCondition(rule, dataMap)
+			if err != nil {
+				response.Errors = append(response.Errors, TransformationError{
+					Field:   rule.SourceField,
+					Message: err.Error(),
+					Code:    "CONDITION_ERROR",
+				})
+				response.Metrics.ErrorFields++
+				continue
+			}
+			if !matched {
+				response.Metrics.SkippedFields++
+				continue
+			}
+		}
 		
-		// Get source value
-		sourceValue, exists := dataMap[rule.SourceField]
+		// Get source value; expression rules may compute a target from
+		// other fields without one
+		var sourceValue interface{}
+		exists := rule.Transformation == "expression" && rule.SourceField == ""
+		if !exists {
+			var err error
+			sourceValue, exists, err = getTransformPath(dataMap, rule.SourceField)
+			if err != nil {
+				response.Errors = append(response.Errors, TransformationError{
+					Field:   rule.SourceField,
+					Message: err.Error(),
+					Code:    "INVALID_PATH",
+				})
+				response.Metrics.ErrorFields++
+				continue
+			}
+		}
 		if !exists {
 			// Handle missing source field
 			switch options.MissingDataPolicy.Strategy {
@@ -253,10 +376,14 @@ func (dt *DataTransformer) applyTransformations(data interface{}, rules []Transf
 		}
 
 		// Apply transformation
-		transformedValue, err := dt.applyTransformation(sourceValue, rule, options)
+		transformedValue, err := dt.applyTransformation(sourceValue, rule, dataMap, options)
 		if err != nil {
+			errorField := rule.SourceField
+			if errorField == "" {
+				errorField = rule.TargetField
+			}
 			response.Errors = append(response.Errors, TransformationError{
-				Field:   rule.SourceField,
+				Field:   errorField,
 				Message: err.Error(),
 				Code:    "TRANSFORMATION_ERROR",
 				Value:   sourceValue,
@@ -265,21 +392,31 @@ func (dt *DataTransformer) applyTransformations(data interface{}, rules []Transf
 			continue
 		}
 
-		// Set target field
+		// Set target field, creating the objects on a nested path
 		targetField := rule.TargetField
 		if targetField == "" {
 			targetField = rule.SourceField
 		}
 
-		result[targetField] = transformedValue
+		if err := setTransformPath(result, targetField, transformedValue); err != nil {
+			response.Errors = append(response.Errors, TransformationError{
+				Field:   targetField,
+				Message: err.Error(),
+				Code:    "INVALID_PATH",
+				Value:   transformedValue,
+			})
+			response.Metrics.ErrorFields++
+			continue
+		}
 		response.Metrics.TransformedFields++
 	}
 
 	return result, nil
 }
 
-// applyTransformation applies a single transformation rule
-func (dt *DataTransformer) applyTransformation(value interface{}, rule TransformationRule, options TransformationOptions) (interface{}, error) {
+// applyTransformation applies a single transformation rule. Data is the
+// object the source value was read from.
+func (dt *DataTransformer) applyTransformation(value interface{}, rule TransformationRule, data map[string]interface{}, options TransformationOptions) (interface{}, error) {
 	switch rule.Transformation {
 	case "copy":
 		return value, nil
@@ -311,6 +448,8 @@ func (dt *DataTransformer) applyTransformation(value interface{}, rule Transform
 		return dt.applyDefault(value, rule.DefaultValue)
 	case "custom":
 		return dt.applyCustomTransformation(value, rule, options)
+	case "expression":
+		return dt.applyExpression(value, rule, data)
 	default:
 		return nil, fmt.Errorf("unknown transformation: %s", rule.Transformation)
 	}
@@ -615,6 +754,120 @@ func (dt *DataTransformer) applyCustomTransformation(value interface{}, rule Tra
 	return transformer.Function(value)
 }
 
+// applyExpression evaluates the rule's expression parameter, an expression
+// in the language of CompileExpression such as
+// concat(first_name, ' ', last_name) or age >= 18. The object's fields are
+// its variables, along with value, the source field's value, and data, the
+// whole object, which shadow fields of the same names. Expressions cannot
+// reach anything but these values, and each evaluation is bounded by the
+// rule's timeout_ms parameter, at most the configured ExpressionTimeout.
+func (dt *DataTransformer) applyExpression(value interface{}, rule TransformationRule, data map[string]interface{}) (interface{}, error) {
+	source, ok := rule.Parameters["expression"].(string)
+	if !ok || source == "" {
+		return nil, fmt.Errorf("expression transformation requires 'expression' parameter")
+	}
+
+	timeout := dt.config.ExpressionTimeout
+	if param, exists := rule.Parameters["timeout_ms"]; exists {
+		ms, ok := numericValue(param)
+		if !ok || ms <= 0 {
+			return nil, fmt.Errorf("timeout_ms must be a positive number")
+		}
+		if ruleTimeout := time.Duration(ms * float64(time.Millisecond)); ruleTimeout < timeout {
+			timeout = ruleTimeout
+		}
+	}
+
+	variables := expressionVariables(value, data)
+	expression, err := dt.compileExpression(source, variables)
+	if err != nil {
+		return nil, err
+	}
+	return evalExpressionWithTimeout(expression, variables, timeout)
+}
+
+// evalCondition evaluates a rule's condition against the source record. The
+// condition is a boolean expression over the same variables as expression
+// transformations, e.g. "country == 'US' && age >= 18"; fields that may be
+// absent are guarded with has(), as in "has(data.email) && data.email != ''".
+func (dt *DataTransformer) evalCondition(rule TransformationRule, data map[string]interface{}) (bool, error) {
+	value, _, _ := getTransformPath(data, rule.SourceField)
+	variables := expressionVariables(value, data)
+	expression, err := dt.compileExpression(rule.Condition, variables)
+	if err != nil {
+		return false, fmt.Errorf("condition: %w", err)
+	}
+	result, err := evalExpressionWithTimeout(expression, variables, dt.config.ExpressionTimeout)
+	if err != nil {
+		return false, fmt.Errorf("condition: %w", err)
+	}
+	matched, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition %q is %s, not a bool", rule.Condition, exprTypeName(result))
+	}
+	return matched, nil
+}
+
+// expressionVariables are the variables of expressions over a record: its
+// fields, the rule's source value as value and the record itself as data
+func expressionVariables(value interface{}, data map[string]interface{}) map[string]interface{} {
+	variables := make(map[string]interface{}, len(data)+2)
+	for field, fieldValue := range data {
+		variables[field] = fieldValue
+	}
+	variables["value"] = value
+	variables["data"] = data
+	return variables
+}
+
+// compileExpression compiles an expression for the variables given, reusing
+// earlier compilations for the same variable names
+func (dt *DataTransformer) compileExpression(source string, variables map[string]interface{}) (*Expression, error) {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	key := source + "\x00" + strings.Join(names, "\x00")
+
+	dt.expressionsMu.Lock()
+	defer dt.expressionsMu.Unlock()
+	if expression, exists := dt.expressions[key]; exists {
+		return expression, nil
+	}
+	expression, err := CompileExpression(source, names...)
+	if err != nil {
+		return nil, err
+	}
+	if len(dt.expressions) < maxCachedExpressions {
+		dt.expressions[key] = expression
+	}
+	return expression, nil
+}
+
+// evalExpressionWithTimeout evaluates an expression, giving up after the
+// timeout. Expressions have no loops, so an abandoned evaluation still ends.
+func evalExpressionWithTimeout(expression *Expression, variables map[string]interface{}, timeout time.Duration) (interface{}, error) {
+	type result struct {
+		value interface{}
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := expression.Eval(variables)
+		done <- result{value: value, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-timer.C:
+		return nil, &ExpressionError{Source: expression.String(), Position: -1, Message: fmt.Sprintf("timed out after %s", timeout)}
+	}
+}
+
 // applySchemaTransformation applies schema transformation
 func (dt *DataTransformer) applySchemaTransformation(data interface{}, schema TransformationSchema, response *TransformationResponse, options TransformationOptions) (interface{}, error) {
 	if schema.Type == "object" {
@@ -2,7 +2,9 @@ package services
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDataTransformer_BasicTransformation(t *testing.T) {
@@ -827,4 +829,209 @@ func TestDataTransformer_Integration(t *testing.T) {
 	if response.Metrics.ProcessingTime <= 0 {
 		t.Error("Expected positive processing time")
 	}
-} 
\ No newline at end of file
+}
+
+func TestDataTransformer_ExpressionTransformations(t *testing.T) {
+	transformer := NewDataTransformer(DataTransformerConfig{
+		MissingDataPolicy: MissingDataPolicy{Strategy: MissingDataError},
+	})
+
+	response := transformer.TransformData(TransformationRequest{
+		Data: map[string]interface{}{
+			"first_name": "Jane",
+			"last_name":  "Doe",
+			"age":        21.0,
+			"email":      " Jane@Example.com ",
+		},
+		Transformations: []TransformationRule{
+			{TargetField: "full_name", Transformation: "expression", Parameters: map[string]interface{}{"expression": "concat(first_name, ' ', last_name)"}},
+			{TargetField: "adult", Transformation: "expression", Parameters: map[string]interface{}{"expression": "age >= 18"}},
+			{SourceField: "email", Transformation: "expression", Parameters: map[string]interface{}{"expression": "lower(trim(value))"}},
+			{TargetField: "tier", Transformation: "expression", Parameters: map[string]interface{}{"expression": "has(data.vip) ? 'vip' : 'standard'", "timeout_ms": 50}},
+		},
+	})
+	if !response.Success {
+		t.Fatalf("Expected successful transformation, got errors: %v", response.Errors)
+	}
+	result := response.Data.(map[string]interface{})
+	for field, expected := range map[string]interface{}{
+		"full_name": "Jane Doe",
+		"adult":     true,
+		"email":     "jane@example.com",
+		"tier":      "standard",
+	} {
+		if result[field] != expected {
+			t.Errorf("Expected %s to be %v, got %v", field, expected, result[field])
+		}
+	}
+
+	response = transformer.TransformData(TransformationRequest{
+		Data: map[string]interface{}{"first_name": "Jane"},
+		Transformations: []TransformationRule{
+			{TargetField: "full_name", Transformation: "expression", Parameters: map[string]interface{}{"expression": "concat(first_name, ' ', last_name)"}},
+			{TargetField: "bad", Transformation: "expression", Parameters: map[string]interface{}{"expression": "first_name *"}},
+			{TargetField: "missing", Transformation: "expression"},
+			{TargetField: "slow", Transformation: "expression", Parameters: map[string]interface{}{"expression": "1", "timeout_ms": -1}},
+		},
+	})
+	if response.Success || len(response.Errors) != 4 {
+		t.Fatalf("Expected every rule to fail, got %+v", response.Errors)
+	}
+	if response.Errors[0].Field != "full_name" || !strings.Contains(response.Errors[0].Message, "last_name") {
+		t.Errorf("Expected an undeclared reference to last_name, got %+v", response.Errors[0])
+	}
+}
+
+func TestDataTransformer_ExpressionTimeout(t *testing.T) {
+	transformer := NewDataTransformer(DataTransformerConfig{ExpressionTimeout: time.Nanosecond})
+	items := make([]interface{}, 1000000)
+	for i := range items {
+		items[i] = fmt.Sprintf("item-%d", i)
+	}
+
+	response := transformer.TransformData(TransformationRequest{
+		Data: map[string]interface{}{"items": items},
+		Transformations: []TransformationRule{
+			{TargetField: "found", Transformation: "expression", Parameters: map[string]interface{}{"expression": "'absent' in items", "timeout_ms": 1000}},
+		},
+	})
+	if response.Success || len(response.Errors) != 1 || !strings.Contains(response.Errors[0].Message, "timed out") {
+		t.Errorf("Expected the configured timeout to bound the rule's, got %+v", response.Errors)
+	}
+}
+
+func TestDataTransformer_NestedPaths(t *testing.T) {
+	transformer := NewDataTransformer(DataTransformerConfig{
+		MissingDataPolicy: MissingDataPolicy{Strategy: MissingDataError},
+	})
+	address := map[string]interface{}{"city": "springfield", "zip": "12345"}
+
+	response := transformer.TransformData(TransformationRequest{
+		Data: map[string]interface{}{
+			"user":       map[string]interface{}{"address": address},
+			"items":      []interface{}{map[string]interface{}{"id": "item-1"}},
+			"legacy.key": "dotted",
+		},
+		Transformations: []TransformationRule{
+			{SourceField: "user.address.city", TargetField: "location.city", Transformation: "uppercase"},
+			{SourceField: "user.address.zip", TargetField: "location.postal[\"code.value\"]", Transformation: "copy"},
+			{SourceField: "items[0].id", TargetField: "ids[1]", Transformation: "copy"},
+			{SourceField: "user", TargetField: "user", Transformation: "copy"},
+			{SourceField: "legacy.key", TargetField: "user.address.note", Transformation: "copy"},
+		},
+	})
+	if !response.Success {
+		t.Fatalf("Expected successful transformation, got errors: %v", response.Errors)
+	}
+	result := response.Data.(map[string]interface{})
+	location := result["location"].(map[string]interface{})
+	if location["city"] != "SPRINGFIELD" || location["postal"].(map[string]interface{})["code.value"] != "12345" {
+		t.Errorf("Expected intermediate objects to be created, got %v", location)
+	}
+	if ids := result["ids"].([]interface{}); len(ids) != 2 || ids[0] != nil || ids[1] != "item-1" {
+		t.Errorf("Expected the array to be extended, got %v", ids)
+	}
+	if note := result["user"].(map[string]interface{})["address"].(map[string]interface{})["note"]; note != "dotted" {
+		t.Errorf("Expected a top-level field with a dotted name to be read, got %v", note)
+	}
+	if _, exists := address["note"]; exists {
+		t.Error("Expected the input to be left unchanged")
+	}
+
+	response = transformer.TransformData(TransformationRequest{
+		Data: map[string]interface{}{"user": map[string]interface{}{"name": "Jane"}},
+		Transformations: []TransformationRule{
+			{SourceField: "user..name", Transformation: "copy"},
+			{SourceField: "user.email", Transformation: "copy"},
+			{SourceField: "user.name", TargetField: "name[x]", Transformation: "copy"},
+			{SourceField: "user.name", Transformation: "copy"},
+			{SourceField: "user.name", TargetField: "user.name.first", Transformation: "copy"},
+			{SourceField: "user.name", TargetField: "names[100000]", Transformation: "copy"},
+		},
+	})
+	codes := make([]string, 0, len(response.Errors))
+	for _, err := range response.Errors {
+		codes = append(codes, err.Code)
+	}
+	if strings.Join(codes, ",") != "INVALID_PATH,MISSING_SOURCE_FIELD,INVALID_PATH,INVALID_PATH,INVALID_PATH" {
+		t.Errorf("Unexpected errors: %+v", response.Errors)
+	}
+}
+
+func TestParseTransformPath(t *testing.T) {
+	segments, err := parseTransformPath(`items[12].labels['app.name'].value`)
+	if err != nil {
+		t.Fatalf("Expected the path to parse, got %v", err)
+	}
+	var parts []string
+	for _, segment := range segments {
+		parts = append(parts, segment.String())
+	}
+	if strings.Join(parts, " ") != "items [12] labels app.name value" {
+		t.Errorf("Unexpected segments: %v", parts)
+	}
+
+	for _, path := range []string{"", ".a", "a.", "[0]", "a[", "a[-1]", "a[+1]", "a[0]b", `a["b]`, `a["b"c]`} {
+		if _, err := parseTransformPath(path); err == nil {
+			t.Errorf("Expected %q to be rejected", path)
+		}
+	}
+}
+
+func TestDataTransformer_ConditionalTransformations(t *testing.T) {
+	transformer := NewDataTransformer(DataTransformerConfig{
+		MissingDataPolicy: MissingDataPolicy{Strategy: MissingDataError},
+	})
+	rules := []TransformationRule{
+		{SourceField: "name", Transformation: "uppercase", Condition: "country == 'US'"},
+		{SourceField: "age", TargetField: "adult", Transformation: "copy", Condition: "value >= 18"},
+		{SourceField: "email", Transformation: "lowercase", Condition: "has(data.email) && data.email != ''"},
+		{SourceField: "user.tier", TargetField: "tier", Transformation: "copy", Condition: "has(data.user) && has(data.user.tier)"},
+	}
+
+	response := transformer.TransformData(TransformationRequest{
+		Data: map[string]interface{}{
+			"name":    "jane",
+			"country": "US",
+			"age":     21.0,
+			"email":   "Jane@Example.com",
+			"user":    map[string]interface{}{"tier": "gold"},
+		},
+		Transformations: rules,
+	})
+	if !response.Success {
+		t.Fatalf("Expected successful transformation, got errors: %v", response.Errors)
+	}
+	result := response.Data.(map[string]interface{})
+	if result["name"] != "JANE" || result["adult"] != 21.0 || result["email"] != "jane@example.com" || result["tier"] != "gold" {
+		t.Errorf("Expected every rule to apply, got %v", result)
+	}
+	if response.Metrics.SkippedFields != 0 || response.Metrics.TransformedFields != 4 {
+		t.Errorf("Expected no skipped rules, got %+v", response.Metrics)
+	}
+
+	// Skipped rules neither write their target nor report missing fields
+	response = transformer.TransformData(TransformationRequest{
+		Data:            map[string]interface{}{"name": "jean", "country": "FR", "age": 16.0},
+		Transformations: rules,
+	})
+	if !response.Success {
+		t.Fatalf("Expected successful transformation, got errors: %v", response.Errors)
+	}
+	result = response.Data.(map[string]interface{})
+	if len(result) != 0 || response.Metrics.SkippedFields != 4 {
+		t.Errorf("Expected every rule to be skipped, got %v and %+v", result, response.Metrics)
+	}
+
+	response = transformer.TransformData(TransformationRequest{
+		Data: map[string]interface{}{"name": "jane", "age": 21.0},
+		Transformations: []TransformationRule{
+			{SourceField: "name", Transformation: "copy", Condition: "country == 'US'"},
+			{SourceField: "name", Transformation: "copy", Condition: "age + 1"},
+			{SourceField: "name", Transformation: "copy", Condition: "data.country == 'US'"},
+		},
+	})
+	if len(response.Errors) != 3 || response.Errors[0].Code != "CONDITION_ERROR" || !strings.Contains(response.Errors[1].Message, "not a bool") {
+		t.Errorf("Expected the invalid conditions to be reported, got %+v", response.Errors)
+	}
+}
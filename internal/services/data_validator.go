@@ -1,16 +1,20 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
-	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
 // DataValidator provides comprehensive data validation capabilities
 type DataValidator struct {
 	config DataValidatorConfig
+
+	compiledMu sync.Mutex
+	compiled   map[string]*CompiledSchema
 }
 
 // DataValidatorConfig holds configuration for the data validator
@@ -19,6 +23,9 @@ type DataValidatorConfig struct {
 	MaxErrors      int
 	EnableMetrics  bool
 	CustomValidators map[string]DataValidationRule
+	// Schemas holds the versioned claim type schemas DP responses are
+	// validated against
+	Schemas *ValidationSchemaRegistry
 }
 
 // DataValidationRule defines a custom validation rule
@@ -28,7 +35,10 @@ type DataValidationRule struct {
 	Message  string
 }
 
-// ValidationSchema defines the structure for data validation
+// ValidationSchema defines the structure for data validation. Besides the
+// original subset it accepts JSON Schema draft 2020-12: schemas written as
+// standard JSON Schema unmarshal into it directly, including boolean
+// schemas, "type" arrays and $ref within the document.
 type ValidationSchema struct {
 	Type       string                 `json:"type"`
 	Required   []string              `json:"required,omitempty"`
@@ -37,6 +47,56 @@ type ValidationSchema struct {
 	MaxItems   *int                  `json:"maxItems,omitempty"`
 	Pattern    *string               `json:"pattern,omitempty"`
 	Format     *string               `json:"format,omitempty"`
+
+	// Types lists the allowed types of a "type" array; Bool is set for the
+	// true and false schemas
+	Types []string `json:"-"`
+	Bool  *bool    `json:"-"`
+
+	SchemaURI   string                       `json:"$schema,omitempty"`
+	ID          string                       `json:"$id,omitempty"`
+	Anchor      string                       `json:"$anchor,omitempty"`
+	Ref         string                       `json:"$ref,omitempty"`
+	Defs        map[string]*ValidationSchema `json:"$defs,omitempty"`
+	Definitions map[string]*ValidationSchema `json:"definitions,omitempty"`
+
+	Enum  []interface{}   `json:"enum,omitempty"`
+	Const json.RawMessage `json:"const,omitempty"`
+
+	MinLength        *int     `json:"minLength,omitempty"`
+	MaxLength        *int     `json:"maxLength,omitempty"`
+	Minimum          *float64 `json:"minimum,omitempty"`
+	Maximum          *float64 `json:"maximum,omitempty"`
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum,omitempty"`
+	MultipleOf       *float64 `json:"multipleOf,omitempty"`
+
+	PatternProperties    map[string]*ValidationSchema `json:"patternProperties,omitempty"`
+	AdditionalProperties *ValidationSchema            `json:"additionalProperties,omitempty"`
+	PropertyNames        *ValidationSchema            `json:"propertyNames,omitempty"`
+	MinProperties        *int                         `json:"minProperties,omitempty"`
+	MaxProperties        *int                         `json:"maxProperties,omitempty"`
+	DependentRequired    map[string][]string          `json:"dependentRequired,omitempty"`
+	DependentSchemas     map[string]*ValidationSchema `json:"dependentSchemas,omitempty"`
+
+	Items       *ValidationSchema   `json:"items,omitempty"`
+	PrefixItems []*ValidationSchema `json:"prefixItems,omitempty"`
+	Contains    *ValidationSchema   `json:"contains,omitempty"`
+	MinContains *int                `json:"minContains,omitempty"`
+	MaxContains *int                `json:"maxContains,omitempty"`
+	UniqueItems bool                `json:"uniqueItems,omitempty"`
+
+	AllOf []*ValidationSchema `json:"allOf,omitempty"`
+	AnyOf []*ValidationSchema `json:"anyOf,omitempty"`
+	OneOf []*ValidationSchema `json:"oneOf,omitempty"`
+	Not   *ValidationSchema   `json:"not,omitempty"`
+	If    *ValidationSchema   `json:"if,omitempty"`
+	Then  *ValidationSchema   `json:"then,omitempty"`
+	Else  *ValidationSchema   `json:"else,omitempty"`
+
+	// Unsupported lists keywords of the document the validator cannot
+	// apply, so the schema is rejected rather than silently weakened
+	Unsupported []string `json:"-"`
 }
 
 // SchemaField defines validation rules for a specific field
@@ -53,6 +113,9 @@ type SchemaField struct {
 	Format      *string     `json:"format,omitempty"`
 	CustomRule  *string     `json:"customRule,omitempty"`
 	Default     interface{} `json:"default,omitempty"`
+	// Schema holds the field's JSON Schema keywords; the fields above
+	// take precedence over it
+	Schema *ValidationSchema `json:"-"`
 }
 
 // ValidationRequest represents a validation request
@@ -111,12 +174,19 @@ func NewDataValidator(config DataValidatorConfig) *DataValidator {
 		config.MaxErrors = 100
 	}
 	return &DataValidator{
-		config: config,
+		config:   config,
+		compiled: make(map[string]*CompiledSchema),
 	}
 }
 
-// ValidateData performs comprehensive data validation
+// ValidateData performs comprehensive data validation. Schemas are compiled
+// on first use and reused by later requests with the same schema.
 func (dv *DataValidator) ValidateData(req ValidationRequest) ValidationResponse {
+	return dv.validate(req.Data, dv.compiledSchema(&req.Schema), req.Options)
+}
+
+// validate validates data against a compiled schema
+func (dv *DataValidator) validate(data interface{}, compiled *CompiledSchema, requestOptions ValidationOptions) ValidationResponse {
 	startTime := time.Now()
 	
 	response := ValidationResponse{
@@ -132,7 +202,7 @@ func (dv *DataValidator) ValidateData(req ValidationRequest) ValidationResponse
 	}
 
 	// Merge options with default config
-	options := req.Options
+	options := requestOptions
 	if options.MaxErrors == 0 {
 		options.MaxErrors = dv.config.MaxErrors
 	}
@@ -151,7 +221,7 @@ func (dv *DataValidator) ValidateData(req ValidationRequest) ValidationResponse
 	}
 
 	// Validate schema structure
-	if err := dv.validateSchema(req.Schema); err != nil {
+	if err := compiled.err; err != nil {
 		response.Errors = append(response.Errors, ValidationError{
 			Field:   "schema",
 			Message: err.Error(),
@@ -161,7 +231,7 @@ func (dv *DataValidator) ValidateData(req ValidationRequest) ValidationResponse
 	}
 
 	// Validate data against schema
-	dv.validateDataAgainstSchema(req.Data, req.Schema, "", &response, options)
+	dv.evaluate(data, compiled.schema, "", &response, options, compiled.newScope())
 
 	// Calculate metrics
 	processingTime := time.Since(startTime)
@@ -186,453 +256,11 @@ func (dv *DataValidator) ValidateData(req ValidationRequest) ValidationResponse
 	return response
 }
 
-// validateSchema validates the schema structure itself
-func (dv *DataValidator) validateSchema(schema ValidationSchema) error {
-	if schema.Type == "" {
-		return fmt.Errorf("schema type is required")
-	}
-
-	// Validate required fields are present in properties
-	if len(schema.Required) > 0 && len(schema.Properties) > 0 {
-		for _, required := range schema.Required {
-			if _, exists := schema.Properties[required]; !exists {
-				return fmt.Errorf("required field '%s' not found in properties", required)
-			}
-		}
-	}
-
-	return nil
-}
-
-// validateDataAgainstSchema recursively validates data against the schema
-func (dv *DataValidator) validateDataAgainstSchema(data interface{}, schema ValidationSchema, path string, response *ValidationResponse, options ValidationOptions) {
-	dv.validateType(data, schema.Type, path, response, options)
-	
-	if schema.Type == "object" {
-		dv.validateObject(data, schema, path, response, options)
-	} else if schema.Type == "array" {
-		dv.validateArray(data, schema, path, response, options)
-	}
-}
-
-// validateType checks if the data matches the expected type
-func (dv *DataValidator) validateType(data interface{}, expectedType string, path string, response *ValidationResponse, options ValidationOptions) {
-	// Only count fields at the leaf level (not objects/arrays)
-	if path != "" && !strings.HasSuffix(path, "]") {
-		response.Metrics.TotalFields++
-	}
-	
-	actualType := reflect.TypeOf(data).Kind()
-	
-	switch expectedType {
-	case "string":
-		if actualType != reflect.String {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: fmt.Sprintf("expected string, got %v", actualType),
-				Code:    "TYPE_MISMATCH",
-				Value:   data,
-			})
-			response.Metrics.ErrorFields++
-			return
-		}
-		dv.validateString(data.(string), path, response, options)
-		
-	case "number":
-		if actualType != reflect.Float64 && actualType != reflect.Int && actualType != reflect.Int64 {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: fmt.Sprintf("expected number, got %v", actualType),
-				Code:    "TYPE_MISMATCH",
-				Value:   data,
-			})
-			response.Metrics.ErrorFields++
-			return
-		}
-		dv.validateNumber(data, path, response, options)
-		
-	case "integer":
-		if actualType != reflect.Int && actualType != reflect.Int64 {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: fmt.Sprintf("expected integer, got %v", actualType),
-				Code:    "TYPE_MISMATCH",
-				Value:   data,
-			})
-			response.Metrics.ErrorFields++
-			return
-		}
-		dv.validateInteger(data, path, response, options)
-		
-	case "boolean":
-		if actualType != reflect.Bool {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: fmt.Sprintf("expected boolean, got %v", actualType),
-				Code:    "TYPE_MISMATCH",
-				Value:   data,
-			})
-			response.Metrics.ErrorFields++
-			return
-		}
-		
-	case "object":
-		if actualType != reflect.Map {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: fmt.Sprintf("expected object, got %v", actualType),
-				Code:    "TYPE_MISMATCH",
-				Value:   data,
-			})
-			response.Metrics.ErrorFields++
-			return
-		}
-		
-	case "array":
-		if actualType != reflect.Slice {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: fmt.Sprintf("expected array, got %v", actualType),
-				Code:    "TYPE_MISMATCH",
-				Value:   data,
-			})
-			response.Metrics.ErrorFields++
-			return
-		}
-		
-	case "null":
-		if data != nil {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: "expected null value",
-				Code:    "TYPE_MISMATCH",
-				Value:   data,
-			})
-			response.Metrics.ErrorFields++
-			return
-		}
-	}
-	
-	// Only count valid fields at the leaf level
-	if path != "" && !strings.HasSuffix(path, "]") {
-		response.Metrics.ValidFields++
-	}
-}
-
-// validateString validates string-specific constraints
-func (dv *DataValidator) validateString(value string, path string, response *ValidationResponse, options ValidationOptions) {
-	// TODO: Re-enable for production - schema field validation
-	// This is commented out for MVP to avoid linter errors
-	// Will be re-enabled for production with proper schema field access
-	/*
-	if schema.MinLength != nil && len(value) < *schema.MinLength {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("string length %d is less than minimum %d", len(value), *schema.MinLength),
-			Code:    "MIN_LENGTH_VIOLATION",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-	}
-	
-	if schema.MaxLength != nil && len(value) > *schema.MaxLength {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("string length %d exceeds maximum %d", len(value), *schema.MaxLength),
-			Code:    "MAX_LENGTH_VIOLATION",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-	}
-	
-	if schema.Pattern != nil {
-		matched, err := regexp.MatchString(*schema.Pattern, value)
-		if err != nil {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: fmt.Sprintf("invalid regex pattern: %v", err),
-				Code:    "INVALID_PATTERN",
-				Value:   value,
-			})
-			response.Metrics.ErrorFields++
-		} else if !matched {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: fmt.Sprintf("value does not match pattern: %s", *schema.Pattern),
-				Code:    "PATTERN_MISMATCH",
-				Value:   value,
-			})
-			response.Metrics.ErrorFields++
-		}
-	}
-	
-	if schema.Enum != nil {
-		found := false
-		for _, enumValue := range schema.Enum {
-			if enumValue == value {
-				found = true
-				break
-			}
-		}
-		if !found {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: fmt.Sprintf("value not in allowed enum values"),
-				Code:    "ENUM_VIOLATION",
-				Value:   value,
-			})
-			response.Metrics.ErrorFields++
-		}
-	}
-	
-	if schema.Format != nil {
-		if err := dv.validateFormat(value, *schema.Format); err != nil {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: err.Error(),
-				Code:    "FORMAT_VIOLATION",
-				Value:   value,
-			})
-			response.Metrics.ErrorFields++
-		}
-	}
-	
-	if schema.CustomRule != nil {
-		if rule, exists := options.CustomRules[*schema.CustomRule]; exists {
-			if valid, message := rule.Function(value); !valid {
-				response.Errors = append(response.Errors, ValidationError{
-					Field:   path,
-					Message: message,
-					Code:    "CUSTOM_RULE_VIOLATION",
-					Value:   value,
-				})
-				response.Metrics.ErrorFields++
-			}
-		}
-	}
-	*/
-}
-
-// validateNumber validates number-specific constraints
-func (dv *DataValidator) validateNumber(value interface{}, path string, response *ValidationResponse, options ValidationOptions) {
-	// TODO: Re-enable for production - schema field validation
-	// This is commented out for MVP to avoid linter errors
-	// Will be re-enabled for production with proper schema field access
-	/*
-	var numValue float64
-	switch v := value.(type) {
-	case float64:
-		numValue = v
-	case int:
-		numValue = float64(v)
-	case int64:
-		numValue = float64(v)
-	default:
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: "invalid number type",
-			Code:    "INVALID_NUMBER",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-		return
-	}
-	
-	if schema.MinValue != nil && numValue < *schema.MinValue {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("value %f is less than minimum %f", numValue, *schema.MinValue),
-			Code:    "MIN_VALUE_VIOLATION",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-	}
-	
-	if schema.MaxValue != nil && numValue > *schema.MaxValue {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("value %f exceeds maximum %f", numValue, *schema.MaxValue),
-			Code:    "MAX_VALUE_VIOLATION",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-	}
-	*/
-}
-
-// validateInteger validates integer-specific constraints
-func (dv *DataValidator) validateInteger(value interface{}, path string, response *ValidationResponse, options ValidationOptions) {
-	// TODO: Re-enable for production - schema field validation
-	// This is commented out for MVP to avoid linter errors
-	// Will be re-enabled for production with proper schema field access
-	/*
-	var intValue int64
-	switch v := value.(type) {
-	case int:
-		intValue = int64(v)
-	case int64:
-		intValue = v
-	default:
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: "invalid integer type",
-			Code:    "INVALID_INTEGER",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-		return
-	}
-	
-	if schema.MinValue != nil && float64(intValue) < *schema.MinValue {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("value %d is less than minimum %f", intValue, *schema.MinValue),
-			Code:    "MIN_VALUE_VIOLATION",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-	}
-	
-	if schema.MaxValue != nil && float64(intValue) > *schema.MaxValue {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("value %d exceeds maximum %f", intValue, *schema.MaxValue),
-			Code:    "MAX_VALUE_VIOLATION",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-	}
-	*/
-}
-
-// validateObject validates object-specific constraints
-func (dv *DataValidator) validateObject(data interface{}, schema ValidationSchema, path string, response *ValidationResponse, options ValidationOptions) {
-	dataMap, ok := data.(map[string]interface{})
-	if !ok {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: "invalid object type",
-			Code:    "INVALID_OBJECT",
-			Value:   data,
-		})
-		response.Metrics.ErrorFields++
-		return
-	}
-
-	// Check required fields
-	for _, required := range schema.Required {
-		if _, exists := dataMap[required]; !exists {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   fmt.Sprintf("%s.%s", path, required),
-				Message: "required field is missing",
-				Code:    "REQUIRED_FIELD_MISSING",
-			})
-			response.Metrics.ErrorFields++
-		}
-	}
-
-	// Validate each field
-	for fieldName, fieldValue := range dataMap {
-		fieldPath := fieldName
-		if path != "" {
-			fieldPath = fmt.Sprintf("%s.%s", path, fieldName)
-		}
-
-		// Skip fields if specified
-		if dv.containsString(options.SkipFields, fieldName) {
-			continue
-		}
-
-		if fieldSchema, exists := schema.Properties[fieldName]; exists {
-			dv.validateField(fieldValue, fieldSchema, fieldPath, response, options)
-		} else if options.StrictMode {
-			response.Warnings = append(response.Warnings, ValidationWarning{
-				Field:   fieldPath,
-				Message: "unknown field",
-				Code:    "UNKNOWN_FIELD",
-				Value:   fieldValue,
-			})
-			response.Metrics.WarningFields++
-		}
-	}
-
-	// Check array constraints
-	if schema.MinItems != nil && len(dataMap) < *schema.MinItems {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("object has %d items, minimum is %d", len(dataMap), *schema.MinItems),
-			Code:    "MIN_ITEMS_VIOLATION",
-			Value:   data,
-		})
-		response.Metrics.ErrorFields++
-	}
-
-	if schema.MaxItems != nil && len(dataMap) > *schema.MaxItems {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("object has %d items, maximum is %d", len(dataMap), *schema.MaxItems),
-			Code:    "MAX_ITEMS_VIOLATION",
-			Value:   data,
-		})
-		response.Metrics.ErrorFields++
-	}
-}
-
-// validateArray validates array-specific constraints
-func (dv *DataValidator) validateArray(data interface{}, schema ValidationSchema, path string, response *ValidationResponse, options ValidationOptions) {
-	dataSlice, ok := data.([]interface{})
-	if !ok {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: "invalid array type",
-			Code:    "INVALID_ARRAY",
-			Value:   data,
-		})
-		response.Metrics.ErrorFields++
-		return
-	}
-
-	// Check array constraints
-	if schema.MinItems != nil && len(dataSlice) < *schema.MinItems {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("array has %d items, minimum is %d", len(dataSlice), *schema.MinItems),
-			Code:    "MIN_ITEMS_VIOLATION",
-			Value:   data,
-		})
-		response.Metrics.ErrorFields++
-	}
-
-	if schema.MaxItems != nil && len(dataSlice) > *schema.MaxItems {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("array has %d items, maximum is %d", len(dataSlice), *schema.MaxItems),
-			Code:    "MAX_ITEMS_VIOLATION",
-			Value:   data,
-		})
-		response.Metrics.ErrorFields++
-	}
-
-	// Validate each array item with a simple schema
-	itemSchema := ValidationSchema{
-		Type: "string", // Default to string for array items
-	}
-	for i, item := range dataSlice {
-		itemPath := fmt.Sprintf("%s[%d]", path, i)
-		dv.validateDataAgainstSchema(item, itemSchema, itemPath, response, options)
-	}
-}
-
 // validateField validates a specific field against its schema
-func (dv *DataValidator) validateField(value interface{}, fieldSchema SchemaField, path string, response *ValidationResponse, options ValidationOptions) {
+func (dv *DataValidator) validateField(value interface{}, fieldSchema SchemaField, schema *ValidationSchema, path string, response *ValidationResponse, options ValidationOptions, scope *schemaScope) {
 	// Check if field is required
 	if fieldSchema.Required && value == nil {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: "required field is missing",
-			Code:    "REQUIRED_FIELD_MISSING",
-		})
-		response.Metrics.ErrorFields++
+		dv.addError(response, path, "REQUIRED_FIELD_MISSING", "required field is missing", nil)
 		return
 	}
 
@@ -641,211 +269,38 @@ func (dv *DataValidator) validateField(value interface{}, fieldSchema SchemaFiel
 		value = fieldSchema.Default
 	}
 
-	// Validate type
-	dv.validateType(value, fieldSchema.Type, path, response, options)
-
-	// Validate string-specific constraints
-	if fieldSchema.Type == "string" && value != nil {
-		if strValue, ok := value.(string); ok {
-			dv.validateStringConstraints(strValue, fieldSchema, path, response, options)
-		}
-	}
-
-	// Validate number-specific constraints
-	if fieldSchema.Type == "number" && value != nil {
-		dv.validateNumberConstraints(value, fieldSchema, path, response, options)
-	}
-
-	// Validate integer-specific constraints
-	if fieldSchema.Type == "integer" && value != nil {
-		dv.validateIntegerConstraints(value, fieldSchema, path, response, options)
-	}
-}
-
-// validateStringConstraints validates string field constraints
-func (dv *DataValidator) validateStringConstraints(value string, fieldSchema SchemaField, path string, response *ValidationResponse, options ValidationOptions) {
-	if fieldSchema.MinLength != nil && len(value) < *fieldSchema.MinLength {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("string length %d is less than minimum %d", len(value), *fieldSchema.MinLength),
-			Code:    "MIN_LENGTH_VIOLATION",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-	}
-
-	if fieldSchema.MaxLength != nil && len(value) > *fieldSchema.MaxLength {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("string length %d exceeds maximum %d", len(value), *fieldSchema.MaxLength),
-			Code:    "MAX_LENGTH_VIOLATION",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-	}
-
-	if fieldSchema.Pattern != nil {
-		matched, err := regexp.MatchString(*fieldSchema.Pattern, value)
-		if err != nil {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: fmt.Sprintf("invalid regex pattern: %v", err),
-				Code:    "INVALID_PATTERN",
-				Value:   value,
-			})
-			response.Metrics.ErrorFields++
-		} else if !matched {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: fmt.Sprintf("value does not match pattern: %s", *fieldSchema.Pattern),
-				Code:    "PATTERN_MISMATCH",
-				Value:   value,
-			})
-			response.Metrics.ErrorFields++
-		}
-	}
-
-	if fieldSchema.Enum != nil {
-		found := false
-		for _, enumValue := range fieldSchema.Enum {
-			if enumValue == value {
-				found = true
-				break
-			}
-		}
-		if !found {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: "value not in allowed enum values",
-				Code:    "ENUM_VIOLATION",
-				Value:   value,
-			})
-			response.Metrics.ErrorFields++
-		}
+	// Only count fields at the leaf level (not array items)
+	counted := path != "" && !strings.HasSuffix(path, "]")
+	if counted {
+		response.Metrics.TotalFields++
 	}
+	errors := len(response.Errors)
 
-	if fieldSchema.Format != nil {
-		if err := dv.validateFormat(value, *fieldSchema.Format); err != nil {
-			response.Errors = append(response.Errors, ValidationError{
-				Field:   path,
-				Message: err.Error(),
-				Code:    "FORMAT_VIOLATION",
-				Value:   value,
-			})
-			response.Metrics.ErrorFields++
-		}
-	}
+	dv.evaluate(value, schema, path, response, options, scope)
 
-	if fieldSchema.CustomRule != nil {
+	// Custom rules run on values that satisfy the schema
+	if fieldSchema.CustomRule != nil && len(response.Errors) == errors {
 		if rule, exists := options.CustomRules[*fieldSchema.CustomRule]; exists {
 			if valid, message := rule.Function(value); !valid {
-				response.Errors = append(response.Errors, ValidationError{
-					Field:   path,
-					Message: message,
-					Code:    "CUSTOM_RULE_VIOLATION",
-					Value:   value,
-				})
-				response.Metrics.ErrorFields++
+				dv.addError(response, path, "CUSTOM_RULE_VIOLATION", message, value)
 			}
 		}
 	}
-}
-
-// validateNumberConstraints validates number field constraints
-func (dv *DataValidator) validateNumberConstraints(value interface{}, fieldSchema SchemaField, path string, response *ValidationResponse, options ValidationOptions) {
-	var numValue float64
-	switch v := value.(type) {
-	case float64:
-		numValue = v
-	case int:
-		numValue = float64(v)
-	case int64:
-		numValue = float64(v)
-	default:
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: "invalid number type",
-			Code:    "INVALID_NUMBER",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-		return
-	}
 
-	if fieldSchema.MinValue != nil && numValue < *fieldSchema.MinValue {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("value %f is less than minimum %f", numValue, *fieldSchema.MinValue),
-			Code:    "MIN_VALUE_VIOLATION",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-	}
-
-	if fieldSchema.MaxValue != nil && numValue > *fieldSchema.MaxValue {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("value %f exceeds maximum %f", numValue, *fieldSchema.MaxValue),
-			Code:    "MAX_VALUE_VIOLATION",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-	}
-
-	// Validate custom rule
-	if fieldSchema.CustomRule != nil {
-		if rule, exists := options.CustomRules[*fieldSchema.CustomRule]; exists {
-			if valid, message := rule.Function(value); !valid {
-				response.Errors = append(response.Errors, ValidationError{
-					Field:   path,
-					Message: message,
-					Code:    "CUSTOM_RULE_VIOLATION",
-					Value:   value,
-				})
-				response.Metrics.ErrorFields++
-			}
-		}
+	if counted && len(response.Errors) == errors {
+		response.Metrics.ValidFields++
 	}
 }
 
-// validateIntegerConstraints validates integer field constraints
-func (dv *DataValidator) validateIntegerConstraints(value interface{}, fieldSchema SchemaField, path string, response *ValidationResponse, options ValidationOptions) {
-	var intValue int64
-	switch v := value.(type) {
-	case int:
-		intValue = int64(v)
-	case int64:
-		intValue = v
-	default:
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: "invalid integer type",
-			Code:    "INVALID_INTEGER",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-		return
-	}
-
-	if fieldSchema.MinValue != nil && float64(intValue) < *fieldSchema.MinValue {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("value %d is less than minimum %f", intValue, *fieldSchema.MinValue),
-			Code:    "MIN_VALUE_VIOLATION",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-	}
-
-	if fieldSchema.MaxValue != nil && float64(intValue) > *fieldSchema.MaxValue {
-		response.Errors = append(response.Errors, ValidationError{
-			Field:   path,
-			Message: fmt.Sprintf("value %d exceeds maximum %f", intValue, *fieldSchema.MaxValue),
-			Code:    "MAX_VALUE_VIOLATION",
-			Value:   value,
-		})
-		response.Metrics.ErrorFields++
-	}
+// addError records a validation error
+func (dv *DataValidator) addError(response *ValidationResponse, path, code, message string, value interface{}) {
+	response.Errors = append(response.Errors, ValidationError{
+		Field:   path,
+		Message: message,
+		Code:    code,
+		Value:   value,
+	})
+	response.Metrics.ErrorFields++
 }
 
 // validateFormat validates string format
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RequestTimeoutHeader carries how long a caller will wait for a response.
+// The gateway and the broker make it the request's deadline and pass the
+// time remaining on to the broker and DPs, so no one keeps working on a
+// request its caller has given up on.
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// minRetryBudget is the least time that must be left before a request's
+// deadline, after the backoff delay, for a DP request to be retried
+const minRetryBudget = 100 * time.Millisecond
+
+// ErrDeadlineBudget is returned instead of retrying a DP request when too
+// little of the request's deadline is left for another attempt. It is a
+// context.DeadlineExceeded, so it is reported as a DP timeout.
+var ErrDeadlineBudget = fmt.Errorf("too little time left before the request deadline to retry: %w", context.DeadlineExceeded)
+
+// ParseRequestTimeout parses an X-Request-Timeout value: a number of
+// milliseconds ("2500") or a duration ("2.5s", "500ms")
+func ParseRequestTimeout(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	var timeout time.Duration
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		timeout = time.Duration(ms) * time.Millisecond
+	} else if timeout, err = time.ParseDuration(value); err != nil {
+		return 0, errors.New("expected milliseconds or a duration such as 2s")
+	}
+	if timeout <= 0 {
+		return 0, errors.New("timeout must be positive")
+	}
+	return timeout, nil
+}
+
+// RemainingBudget returns the time left before the context's deadline, and
+// false when it has none
+func RemainingBudget(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// SetRequestTimeout sets X-Request-Timeout to the milliseconds left before
+// the context's deadline, when it has one
+func SetRequestTimeout(ctx context.Context, header http.Header) {
+	remaining, ok := RemainingBudget(ctx)
+	if !ok {
+		return
+	}
+	ms := remaining.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	header.Set(RequestTimeoutHeader, strconv.FormatInt(ms, 10))
+}
+
+// checkRetryBudget returns ErrDeadlineBudget, wrapping the error that made
+// the attempt fail, when waiting delay would leave too little time before
+// the deadline for another attempt
+func checkRetryBudget(ctx context.Context, delay time.Duration, lastErr error) error {
+	remaining, ok := RemainingBudget(ctx)
+	if !ok || remaining-delay >= minRetryBudget {
+		return nil
+	}
+	return fmt.Errorf("%w (%s left): %w", ErrDeadlineBudget, remaining.Round(time.Millisecond), lastErr)
+}
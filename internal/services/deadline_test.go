@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestParseRequestTimeout(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"2500", 2500 * time.Millisecond, false},
+		{"2.5s", 2500 * time.Millisecond, false},
+		{" 500ms ", 500 * time.Millisecond, false},
+		{"0", 0, true},
+		{"-1s", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRequestTimeout(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRequestTimeout(%q) = %v, %v; expected %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestSetRequestTimeout(t *testing.T) {
+	header := http.Header{}
+	SetRequestTimeout(context.Background(), header)
+	if header.Get(RequestTimeoutHeader) != "" {
+		t.Errorf("Expected no timeout without a deadline, got %q", header.Get(RequestTimeoutHeader))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	SetRequestTimeout(ctx, header)
+	if ms, err := strconv.Atoi(header.Get(RequestTimeoutHeader)); err != nil || ms <= 1000 || ms > 2000 {
+		t.Errorf("Expected the remaining milliseconds, got %q", header.Get(RequestTimeoutHeader))
+	}
+}
+
+func TestExecuteWithRetry_DeadlineBudget(t *testing.T) {
+	var timeouts []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ms, _ := strconv.Atoi(r.Header.Get(RequestTimeoutHeader))
+		timeouts = append(timeouts, ms)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	service := newRetryTestService()
+	service.retryConfig.BaseDelay = 200 * time.Millisecond
+	service.retryConfig.MaxDelay = time.Second
+	service.retryConfig.BackoffMultiplier = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	SetRequestTimeout(ctx, req.Header)
+	err := service.executeWithRetry(ctx, service.client, req, nil, func(resp *http.Response) error { return nil })
+
+	// The first retry fits in the deadline; the second, 400ms later, does not
+	if !errors.Is(err, ErrDeadlineBudget) {
+		t.Fatalf("Expected the deadline budget to stop retries, got %v", err)
+	}
+	if DPProblemCode(err) != models.ProblemDPTimeout {
+		t.Errorf("Expected a DP timeout, got %q", DPProblemCode(err))
+	}
+	if len(timeouts) != 2 || timeouts[0] > 500 || timeouts[1] >= timeouts[0] || timeouts[1] <= 0 {
+		t.Errorf("Expected two attempts told the time left, got %v", timeouts)
+	}
+	if ctx.Err() != nil {
+		t.Error("Expected to give up before the deadline")
+	}
+}
+
+func TestAttemptProvider_CallerDeadlineLeavesBreakerClosed(t *testing.T) {
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":              "j",
+			"status":              "completed",
+			"timestamp":           "2026-01-01T00:00:00Z",
+			"verification_result": map[string]interface{}{"verified": true, "confidence": 0.9},
+		})
+	}))
+	defer dp.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: dp.URL})
+	req := &models.PrivacyRequest{RPID: "rp_impatient", ClaimType: "age_verification"}
+
+	// Callers giving up before the DP answers say nothing about the DP
+	for i := 0; i < 6; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := service.VerifyWithDP(ctx, req)
+		cancel()
+		if DPProblemCode(err) != models.ProblemDPTimeout {
+			t.Fatalf("Expected call %d to time out, got %v", i+1, err)
+		}
+	}
+	if state := service.providerBreaker(DefaultDPProviderID).State(); state != CircuitClosed {
+		t.Fatalf("Expected expired caller deadlines to leave the breaker closed, got %s", state)
+	}
+	if _, err := service.VerifyWithDP(context.Background(), req); err != nil {
+		t.Errorf("Expected a patient caller to be verified, got %v", err)
+	}
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// Diagnostic check statuses
+const (
+	DiagnosticPass = "pass"
+	DiagnosticWarn = "warn"
+	DiagnosticFail = "fail"
+	DiagnosticSkip = "skip"
+)
+
+// diagnosticTimeout bounds each check that reaches another service
+const diagnosticTimeout = 5 * time.Second
+
+// certificateExpiryWarning is how close to expiry a certificate is reported
+const certificateExpiryWarning = 30 * 24 * time.Hour
+
+// DiagnosticResult is the outcome of a single startup check
+type DiagnosticResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// DiagnosticReport summarizes the configuration checks run before startup.
+// It passes when no check failed; warnings are reported but allowed.
+type DiagnosticReport struct {
+	Component   string             `json:"component"`
+	Passed      bool               `json:"passed"`
+	StartedAt   time.Time          `json:"started_at"`
+	CompletedAt time.Time          `json:"completed_at"`
+	Results     []DiagnosticResult `json:"results"`
+}
+
+// Diagnostic is a configuration check. Run returns a detail for the report;
+// errors made with diagnosticWarning are warnings, and errSkipped marks a
+// check that does not apply to the configuration.
+type Diagnostic struct {
+	Name string
+	Run  func(ctx context.Context) (string, error)
+}
+
+// errSkipped marks a check that does not apply
+var errSkipped = errors.New("not configured")
+
+// warningError is a problem that does not stop the server from starting
+type warningError struct {
+	message string
+}
+
+func (e *warningError) Error() string {
+	return e.message
+}
+
+func diagnosticWarning(format string, args ...interface{}) error {
+	return &warningError{message: fmt.Sprintf(format, args...)}
+}
+
+// GatewayDiagnostics returns the checks for the API gateway's configuration:
+// crypto and TLS settings, the certificate, secrets, the runtime
+// configuration and DP registry, and the services it connects to
+func GatewayDiagnostics(cfg *config.Config) []Diagnostic {
+	return []Diagnostic{
+		{Name: "crypto_config", Run: func(context.Context) (string, error) {
+			return "crypto profile " + ActiveCryptoProfile(cfg).Name, ValidateCryptoConfig(cfg)
+		}},
+		{Name: "tls_settings", Run: func(context.Context) (string, error) {
+			return "settings allowed by the crypto profile", ServerTLSSettings(cfg).Validate(ActiveCryptoProfile(cfg))
+		}},
+		{Name: "tls_certificate", Run: func(context.Context) (string, error) { return diagnoseCertificate(cfg) }},
+		{Name: "secrets", Run: func(context.Context) (string, error) { return diagnoseSecrets(cfg) }},
+		{Name: "runtime_config", Run: func(context.Context) (string, error) {
+			if cfg.RuntimeConfigFile == "" {
+				return "", errSkipped
+			}
+			// Load has already validated the file
+			return "loaded from " + cfg.RuntimeConfigFile, nil
+		}},
+		{Name: "dp_registry", Run: func(context.Context) (string, error) {
+			registry, err := LoadDPRegistry(cfg)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d providers", len(registry.List())), nil
+		}},
+		{Name: "core_broker", Run: func(ctx context.Context) (string, error) {
+			return diagnoseURL(ctx, strings.TrimRight(cfg.CoreBrokerURL, "/")+"/health")
+		}},
+		{Name: "keycloak_jwks", Run: func(ctx context.Context) (string, error) {
+			return diagnoseURL(ctx, fmt.Sprintf("%s/realms/%s/protocol/openid-connect/certs", cfg.KeycloakURL, cfg.KeycloakRealm))
+		}},
+		{Name: "database", Run: func(ctx context.Context) (string, error) { return diagnoseDatabase(ctx, cfg.DatabaseURL) }},
+		{Name: "redis", Run: func(ctx context.Context) (string, error) { return diagnoseRedis(ctx, cfg) }},
+	}
+}
+
+// RunDiagnostics runs every check with a timeout, recovering from panics so
+// one broken check does not hide the others
+func RunDiagnostics(component string, diagnostics []Diagnostic) *DiagnosticReport {
+	report := &DiagnosticReport{
+		Component: component,
+		Passed:    true,
+		StartedAt: time.Now(),
+		Results:   make([]DiagnosticResult, 0, len(diagnostics)),
+	}
+
+	for _, diagnostic := range diagnostics {
+		start := time.Now()
+		detail, err := runDiagnostic(diagnostic)
+
+		result := DiagnosticResult{Name: diagnostic.Name, Status: DiagnosticPass, Detail: detail}
+		var warning *warningError
+		switch {
+		case err == nil:
+		case errors.Is(err, errSkipped):
+			result.Status, result.Detail = DiagnosticSkip, err.Error()
+		case errors.As(err, &warning):
+			result.Status, result.Detail = DiagnosticWarn, err.Error()
+		default:
+			result.Status, result.Detail = DiagnosticFail, err.Error()
+			report.Passed = false
+		}
+		result.Duration = time.Since(start).String()
+		report.Results = append(report.Results, result)
+	}
+
+	report.CompletedAt = time.Now()
+	return report
+}
+
+func runDiagnostic(diagnostic Diagnostic) (detail string, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticTimeout)
+	defer cancel()
+	return diagnostic.Run(ctx)
+}
+
+// diagnoseCertificate parses the TLS certificate and key and checks the
+// certificate's validity period
+func diagnoseCertificate(cfg *config.Config) (string, error) {
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return "", fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE are required")
+	}
+	certPEM, err := readPEMSource(cfg.Secrets, cfg.TLSCertFile)
+	if err != nil {
+		return "", fmt.Errorf("certificate: %w", err)
+	}
+	keyPEM, err := readPEMSource(cfg.Secrets, cfg.TLSKeyFile)
+	if err != nil {
+		return "", fmt.Errorf("key: %w", err)
+	}
+	pair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return "", fmt.Errorf("invalid TLS certificate or key: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return "", fmt.Errorf("invalid TLS certificate: %w", err)
+	}
+
+	detail := fmt.Sprintf("%s, expires %s", leaf.Subject.CommonName, leaf.NotAfter.Format(time.RFC3339))
+	now := time.Now()
+	switch {
+	case now.After(leaf.NotAfter):
+		return "", fmt.Errorf("certificate %s expired on %s", leaf.Subject.CommonName, leaf.NotAfter.Format(time.RFC3339))
+	case now.Before(leaf.NotBefore):
+		return "", fmt.Errorf("certificate %s is not valid until %s", leaf.Subject.CommonName, leaf.NotBefore.Format(time.RFC3339))
+	case leaf.NotAfter.Sub(now) < certificateExpiryWarning:
+		return "", diagnosticWarning("certificate %s expires soon (%s)", leaf.Subject.CommonName, leaf.NotAfter.Format(time.RFC3339))
+	}
+	return detail, nil
+}
+
+// diagnoseSecrets resolves every secret: reference in the configuration
+func diagnoseSecrets(cfg *config.Config) (string, error) {
+	refs := secretReferences(cfg)
+	if len(refs) == 0 {
+		return "", errSkipped
+	}
+
+	var missing []string
+	for _, field := range refs {
+		value := reflect.ValueOf(cfg).Elem().FieldByName(field)
+		if _, err := cfg.Secrets.Resolve(value.String(), "", nil); err != nil {
+			missing = append(missing, fmt.Sprintf("%s (%v)", field, err))
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("unresolved secrets: %s", strings.Join(missing, "; "))
+	}
+	return fmt.Sprintf("%d secret references resolved", len(refs)), nil
+}
+
+// secretReferences returns the configuration fields holding secret:
+// references
+func secretReferences(cfg *config.Config) []string {
+	var fields []string
+	value := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if field.Kind() == reflect.String && config.IsSecretRef(field.String()) {
+			fields = append(fields, value.Type().Field(i).Name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// diagnoseURL checks that a service answers; a non-2xx answer is a warning
+func diagnoseURL(ctx context.Context, target string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %s: %w", target, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%s unreachable: %w", target, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", diagnosticWarning("%s returned status %d", target, resp.StatusCode)
+	}
+	return fmt.Sprintf("%s returned status %d", target, resp.StatusCode), nil
+}
+
+// diagnoseDatabase connects to the database
+func diagnoseDatabase(ctx context.Context, databaseURL string) (string, error) {
+	if databaseURL == "" {
+		return "", errSkipped
+	}
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid DATABASE_URL: %w", err)
+	}
+	defer db.Close()
+	if err := db.PingContext(ctx); err != nil {
+		return "", fmt.Errorf("database unreachable: %w", err)
+	}
+	return "connected", nil
+}
+
+// diagnoseRedis connects to Redis
+func diagnoseRedis(ctx context.Context, cfg *config.Config) (string, error) {
+	if cfg.Redis.Host == "" {
+		return "", errSkipped
+	}
+	addr := fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port)
+	client := redis.NewClient(&redis.Options{Addr: addr, Password: cfg.Redis.Password, DB: cfg.Redis.DB})
+	defer client.Close()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return "", fmt.Errorf("redis at %s unreachable: %w", addr, err)
+	}
+	return "connected to " + addr, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestRunDiagnostics_Statuses(t *testing.T) {
+	report := RunDiagnostics("test", []Diagnostic{
+		{Name: "ok", Run: func(context.Context) (string, error) { return "fine", nil }},
+		{Name: "warned", Run: func(context.Context) (string, error) { return "", diagnosticWarning("almost %s", "expired") }},
+		{Name: "skipped", Run: func(context.Context) (string, error) { return "", errSkipped }},
+	})
+	if !report.Passed || report.Component != "test" || len(report.Results) != 3 {
+		t.Fatalf("Expected warnings and skips to pass, got %+v", report)
+	}
+	for i, want := range []string{DiagnosticPass, DiagnosticWarn, DiagnosticSkip} {
+		if report.Results[i].Status != want {
+			t.Errorf("Expected %s to be %s, got %+v", report.Results[i].Name, want, report.Results[i])
+		}
+	}
+	if report.Results[1].Detail != "almost expired" {
+		t.Errorf("Expected the warning as detail, got %q", report.Results[1].Detail)
+	}
+
+	// A failing or panicking check fails the report without stopping the others
+	report = RunDiagnostics("test", []Diagnostic{
+		{Name: "failed", Run: func(context.Context) (string, error) { return "", errors.New("unreachable") }},
+		{Name: "panicked", Run: func(context.Context) (string, error) { panic("boom") }},
+		{Name: "ok", Run: func(context.Context) (string, error) { return "", nil }},
+	})
+	if report.Passed {
+		t.Error("Expected a failed check to fail the report")
+	}
+	if report.Results[1].Status != DiagnosticFail || !strings.Contains(report.Results[1].Detail, "boom") || report.Results[2].Status != DiagnosticPass {
+		t.Errorf("Expected the panic to be reported and the next check to run, got %+v", report.Results)
+	}
+}
+
+func TestDiagnoseCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	cfg := &config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile, Secrets: config.NewSecretStore(nil)}
+
+	if _, err := diagnoseCertificate(cfg); err == nil {
+		t.Error("Expected a missing certificate to fail")
+	}
+
+	// The test certificate expires within the hour
+	writeTestCertificate(t, certFile, keyFile, "gateway.example.com")
+	var warning *warningError
+	if _, err := diagnoseCertificate(cfg); !errors.As(err, &warning) || !strings.Contains(err.Error(), "gateway.example.com") {
+		t.Errorf("Expected an expiry warning, got %v", err)
+	}
+
+	os.WriteFile(keyFile, []byte("not a key"), 0600)
+	if _, err := diagnoseCertificate(cfg); err == nil || errors.As(err, &warning) {
+		t.Errorf("Expected an invalid key to fail, got %v", err)
+	}
+}
+
+func TestDiagnoseSecrets(t *testing.T) {
+	if _, err := diagnoseSecrets(&config.Config{DPConnectorToken: "plain"}); !errors.Is(err, errSkipped) {
+		t.Errorf("Expected the check to be skipped without references, got %v", err)
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "dp-token"), []byte("token"), 0600)
+	cfg := &config.Config{
+		DPConnectorToken:       "secret:dp-token",
+		DPBreakerWebhookSecret: "secret:webhook-secret",
+		Secrets:                config.NewSecretStore(config.NewFileSecretsProvider(dir)),
+	}
+	_, err := diagnoseSecrets(cfg)
+	if err == nil || !strings.Contains(err.Error(), "DPBreakerWebhookSecret") || strings.Contains(err.Error(), "DPConnectorToken") {
+		t.Errorf("Expected only the missing secret to be reported, got %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "webhook-secret"), []byte("secret"), 0600)
+	if detail, err := diagnoseSecrets(cfg); err != nil || detail != "2 secret references resolved" {
+		t.Errorf("Expected the secrets to resolve, got %q, %v", detail, err)
+	}
+}
+
+func TestDiagnoseURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	if _, err := diagnoseURL(context.Background(), server.URL+"/health"); err != nil {
+		t.Errorf("Expected a healthy service to pass, got %v", err)
+	}
+	var warning *warningError
+	if _, err := diagnoseURL(context.Background(), server.URL+"/missing"); !errors.As(err, &warning) {
+		t.Errorf("Expected an error status to warn, got %v", err)
+	}
+	server.Close()
+	if _, err := diagnoseURL(context.Background(), server.URL+"/health"); err == nil || errors.As(err, &warning) {
+		t.Errorf("Expected an unreachable service to fail, got %v", err)
+	}
+}
@@ -0,0 +1,407 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// DIDDocument is the subset of a W3C DID document the broker uses
+type DIDDocument struct {
+	Context            interface{}          `json:"@context,omitempty"`
+	ID                 string               `json:"id"`
+	Controller         interface{}          `json:"controller,omitempty"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod"`
+	Authentication     []interface{}        `json:"authentication,omitempty"`
+	AssertionMethod    []interface{}        `json:"assertionMethod,omitempty"`
+}
+
+// VerificationMethod is a public key in a DID document
+type VerificationMethod struct {
+	ID                 string                 `json:"id"`
+	Type               string                 `json:"type"`
+	Controller         string                 `json:"controller"`
+	PublicKeyJwk       map[string]interface{} `json:"publicKeyJwk,omitempty"`
+	PublicKeyMultibase string                 `json:"publicKeyMultibase,omitempty"`
+}
+
+// DIDMethodDriver resolves the DIDs of one method, e.g. "web" or "key"
+type DIDMethodDriver interface {
+	Method() string
+	Resolve(ctx context.Context, did string) (*DIDDocument, error)
+}
+
+// didCacheEntry is a resolved document and when it stops being served
+type didCacheEntry struct {
+	document  *DIDDocument
+	expiresAt time.Time
+}
+
+// DIDResolver resolves issuer and holder DIDs through pluggable method
+// drivers, caching documents and bounding each resolution by a timeout
+type DIDResolver struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu      sync.RWMutex
+	drivers map[string]DIDMethodDriver
+	cache   map[string]didCacheEntry
+}
+
+// NewDIDResolver creates a resolver with the did:web and did:key drivers
+func NewDIDResolver(cfg *config.Config) *DIDResolver {
+	timeout := cfg.DIDResolutionTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	resolver := &DIDResolver{
+		timeout:  timeout,
+		cacheTTL: cfg.DIDCacheTTL,
+		drivers:  make(map[string]DIDMethodDriver),
+		cache:    make(map[string]didCacheEntry),
+	}
+	resolver.RegisterDriver(NewDIDWebDriver(&http.Client{Timeout: timeout}))
+	resolver.RegisterDriver(DIDKeyDriver{})
+	return resolver
+}
+
+// RegisterDriver adds or replaces the driver for a DID method
+func (r *DIDResolver) RegisterDriver(driver DIDMethodDriver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.drivers[driver.Method()] = driver
+}
+
+// Methods lists the DID methods that can be resolved
+func (r *DIDResolver) Methods() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	methods := make([]string, 0, len(r.drivers))
+	for method := range r.drivers {
+		methods = append(methods, method)
+	}
+	return methods
+}
+
+// Supports reports whether DIDs of a method can be resolved
+func (r *DIDResolver) Supports(method string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, supported := r.drivers[method]
+	return supported
+}
+
+// Resolve returns the DID document of a DID. A DID URL's fragment and query
+// are ignored.
+func (r *DIDResolver) Resolve(ctx context.Context, did string) (*DIDDocument, error) {
+	did = strings.SplitN(strings.SplitN(did, "#", 2)[0], "?", 2)[0]
+	method, err := didMethod(did)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	driver, supported := r.drivers[method]
+	entry, cached := r.cache[did]
+	r.mu.RUnlock()
+	if !supported {
+		return nil, fmt.Errorf("unsupported DID method: %s", method)
+	}
+	if cached && time.Now().Before(entry.expiresAt) {
+		return entry.document, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	document, err := driver.Resolve(ctx, did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", did, err)
+	}
+	if document.ID != did {
+		return nil, fmt.Errorf("DID document id %s does not match %s", document.ID, did)
+	}
+
+	if r.cacheTTL > 0 {
+		r.mu.Lock()
+		r.cache[did] = didCacheEntry{document: document, expiresAt: time.Now().Add(r.cacheTTL)}
+		r.mu.Unlock()
+	}
+	return document, nil
+}
+
+// Invalidate drops a DID from the cache, e.g. after a key rotation
+func (r *DIDResolver) Invalidate(did string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cache, did)
+}
+
+// ResolveKey returns the public key a DID URL refers to. Without a fragment
+// the DID's first assertion method is used, falling back to its first
+// verification method.
+func (r *DIDResolver) ResolveKey(ctx context.Context, didURL string) (crypto.PublicKey, error) {
+	document, err := r.Resolve(ctx, didURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var id string
+	if i := strings.Index(didURL, "#"); i >= 0 {
+		id = didURL[i:]
+	} else if len(document.AssertionMethod) > 0 {
+		if ref, ok := document.AssertionMethod[0].(string); ok {
+			id = ref
+		}
+	}
+
+	// Methods may be referenced by absolute DID URL or by fragment alone
+	fragment := func(ref string) string {
+		return ref[strings.Index(ref, "#")+1:]
+	}
+	for _, method := range document.VerificationMethod {
+		if id == "" || fragment(method.ID) == fragment(id) {
+			return method.PublicKey()
+		}
+	}
+	return nil, fmt.Errorf("verification method %s not found in %s", id, document.ID)
+}
+
+// PublicKey decodes the method's key from its JWK or multibase encoding.
+// P-256 keys are returned as *ecdsa.PublicKey and Ed25519 keys as
+// ed25519.PublicKey.
+func (m VerificationMethod) PublicKey() (crypto.PublicKey, error) {
+	if m.PublicKeyJwk != nil {
+		if m.PublicKeyJwk["kty"] == "OKP" && m.PublicKeyJwk["crv"] == "Ed25519" {
+			x, _ := m.PublicKeyJwk["x"].(string)
+			raw, err := base64.RawURLEncoding.DecodeString(x)
+			if err != nil || len(raw) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("invalid Ed25519 key")
+			}
+			return ed25519.PublicKey(raw), nil
+		}
+		return ecdsaPublicKeyFromJWK(m.PublicKeyJwk)
+	}
+	if m.PublicKeyMultibase != "" {
+		return decodeMultibaseKey(m.PublicKeyMultibase)
+	}
+	return nil, fmt.Errorf("verification method %s has no supported key encoding", m.ID)
+}
+
+func didMethod(did string) (string, error) {
+	parts := strings.SplitN(did, ":", 3)
+	if len(parts) != 3 || parts[0] != "did" || parts[1] == "" || parts[2] == "" {
+		return "", fmt.Errorf("malformed DID: %q", did)
+	}
+	return parts[1], nil
+}
+
+// DIDWebDriver resolves did:web DIDs from did.json documents served over HTTPS
+type DIDWebDriver struct {
+	client *http.Client
+	// scheme is "https"; tests serve documents over plain HTTP
+	scheme string
+}
+
+// NewDIDWebDriver creates a did:web driver
+func NewDIDWebDriver(client *http.Client) *DIDWebDriver {
+	return &DIDWebDriver{client: client, scheme: "https"}
+}
+
+// Method implements DIDMethodDriver
+func (d *DIDWebDriver) Method() string {
+	return "web"
+}
+
+// Resolve implements DIDMethodDriver. did:web:example.com resolves to
+// https://example.com/.well-known/did.json and did:web:example.com:user:alice
+// to https://example.com/user/alice/did.json.
+func (d *DIDWebDriver) Resolve(ctx context.Context, did string) (*DIDDocument, error) {
+	documentURL, err := d.documentURL(did)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, documentURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/did+json, application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DID document request returned status %d", resp.StatusCode)
+	}
+
+	var document DIDDocument
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid DID document: %w", err)
+	}
+	return &document, nil
+}
+
+func (d *DIDWebDriver) documentURL(did string) (string, error) {
+	segments := strings.Split(strings.TrimPrefix(did, "did:web:"), ":")
+	host, err := url.PathUnescape(segments[0])
+	if err != nil || host == "" || strings.ContainsAny(host, "/?#@") {
+		return "", fmt.Errorf("invalid did:web domain")
+	}
+
+	path := "/.well-known"
+	if len(segments) > 1 {
+		for _, segment := range segments[1:] {
+			if segment == "" || segment == "." || segment == ".." {
+				return "", fmt.Errorf("invalid did:web path")
+			}
+		}
+		path = "/" + strings.Join(segments[1:], "/")
+	}
+	return d.scheme + "://" + host + path + "/did.json", nil
+}
+
+// DIDKeyDriver resolves did:key DIDs, which carry the key itself
+type DIDKeyDriver struct{}
+
+// Method implements DIDMethodDriver
+func (DIDKeyDriver) Method() string {
+	return "key"
+}
+
+// Resolve implements DIDMethodDriver, supporting Ed25519 and P-256 keys
+func (DIDKeyDriver) Resolve(ctx context.Context, did string) (*DIDDocument, error) {
+	encoded := strings.TrimPrefix(did, "did:key:")
+	key, err := decodeMultibaseKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	method := VerificationMethod{
+		ID:                 did + "#" + encoded,
+		Controller:         did,
+		PublicKeyMultibase: encoded,
+	}
+	switch key.(type) {
+	case ed25519.PublicKey:
+		method.Type = "Ed25519VerificationKey2020"
+	default:
+		method.Type = "Multikey"
+	}
+
+	reference := []interface{}{method.ID}
+	return &DIDDocument{
+		Context:            []string{"https://www.w3.org/ns/did/v1"},
+		ID:                 did,
+		VerificationMethod: []VerificationMethod{method},
+		Authentication:     reference,
+		AssertionMethod:    reference,
+	}, nil
+}
+
+// Multicodec prefixes of the supported key types
+var (
+	multicodecEd25519 = []byte{0xed, 0x01}
+	multicodecP256    = []byte{0x80, 0x24}
+)
+
+// decodeMultibaseKey decodes a base58btc ("z") multibase, multicodec-tagged
+// public key
+func decodeMultibaseKey(encoded string) (crypto.PublicKey, error) {
+	if !strings.HasPrefix(encoded, "z") {
+		return nil, fmt.Errorf("only base58btc multibase keys are supported")
+	}
+	raw, err := base58Decode(encoded[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(raw) == 2+ed25519.PublicKeySize && raw[0] == multicodecEd25519[0] && raw[1] == multicodecEd25519[1]:
+		return ed25519.PublicKey(raw[2:]), nil
+	case len(raw) == 2+33 && raw[0] == multicodecP256[0] && raw[1] == multicodecP256[1]:
+		x, y := elliptic.UnmarshalCompressed(elliptic.P256(), raw[2:])
+		if x == nil {
+			return nil, fmt.Errorf("invalid P-256 key")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported multicodec key type")
+	}
+}
+
+// EncodeDIDKey returns the did:key of an Ed25519 or P-256 public key
+func EncodeDIDKey(key crypto.PublicKey) (string, error) {
+	var raw []byte
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		raw = append(append([]byte{}, multicodecEd25519...), k...)
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return "", fmt.Errorf("only P-256 ECDSA keys are supported")
+		}
+		raw = append(append([]byte{}, multicodecP256...), elliptic.MarshalCompressed(k.Curve, k.X, k.Y)...)
+	default:
+		return "", fmt.Errorf("unsupported key type %T", key)
+	}
+	return "did:key:z" + base58Encode(raw), nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func base58Decode(s string) ([]byte, error) {
+	value := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range s {
+		digit := strings.IndexRune(base58Alphabet, c)
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		value.Mul(value, radix)
+		value.Add(value, big.NewInt(int64(digit)))
+	}
+
+	// Leading '1's encode leading zero bytes
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+	return append(make([]byte, zeros), value.Bytes()...), nil
+}
+
+func base58Encode(b []byte) string {
+	value := new(big.Int).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var encoded []byte
+	for value.Sign() > 0 {
+		value.DivMod(value, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+	for i := 0; i < len(b) && b[i] == 0; i++ {
+		encoded = append(encoded, '1')
+	}
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestDIDKeyDriver(t *testing.T) {
+	resolver := NewDIDResolver(&config.Config{})
+
+	// Example from the did:key specification
+	did := "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"
+	key, err := resolver.ResolveKey(context.Background(), did)
+	if err != nil {
+		t.Fatalf("ResolveKey failed: %v", err)
+	}
+	if encoded, _ := EncodeDIDKey(key); encoded != did {
+		t.Errorf("Expected the key to encode back to %s, got %s", did, encoded)
+	}
+
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	did, _ = EncodeDIDKey(&p256.PublicKey)
+	if !strings.HasPrefix(did, "did:key:zDn") {
+		t.Errorf("Expected a P-256 did:key, got %s", did)
+	}
+	key, err = resolver.ResolveKey(context.Background(), did+"#"+strings.TrimPrefix(did, "did:key:"))
+	if err != nil || !p256.PublicKey.Equal(key) {
+		t.Errorf("Expected the P-256 key back, got %v (%v)", key, err)
+	}
+
+	if _, err := resolver.Resolve(context.Background(), "did:key:zInvalid0"); err == nil {
+		t.Error("Expected an invalid did:key to be rejected")
+	}
+	if _, err := resolver.Resolve(context.Background(), "did:ion:abc"); err == nil {
+		t.Error("Expected an unsupported method to be rejected")
+	}
+}
+
+func TestDIDWebDriver(t *testing.T) {
+	publicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	requests := 0
+	var did string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/issuers/acme/did.json" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(DIDDocument{
+			ID: did,
+			VerificationMethod: []VerificationMethod{{
+				ID:           did + "#key-1",
+				Type:         "JsonWebKey2020",
+				Controller:   did,
+				PublicKeyJwk: map[string]interface{}{"kty": "OKP", "crv": "Ed25519", "x": base64.RawURLEncoding.EncodeToString(publicKey)},
+			}},
+			AssertionMethod: []interface{}{"#key-1"},
+		})
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	did = "did:web:" + strings.Replace(host, ":", "%3A", 1) + ":issuers:acme"
+
+	resolver := NewDIDResolver(&config.Config{DIDCacheTTL: time.Minute})
+	driver := NewDIDWebDriver(server.Client())
+	driver.scheme = "http"
+	resolver.RegisterDriver(driver)
+
+	key, err := resolver.ResolveKey(context.Background(), did)
+	if err != nil {
+		t.Fatalf("ResolveKey failed: %v", err)
+	}
+	if !publicKey.Equal(key) {
+		t.Error("Expected the document's assertion key")
+	}
+
+	// Documents are cached until the TTL passes or they are invalidated
+	resolver.ResolveKey(context.Background(), did+"#key-1")
+	if requests != 1 {
+		t.Errorf("Expected one document fetch, got %d", requests)
+	}
+	resolver.Invalidate(did)
+	resolver.Resolve(context.Background(), did)
+	if requests != 2 {
+		t.Errorf("Expected a fetch after invalidation, got %d", requests)
+	}
+
+	if _, err := resolver.Resolve(context.Background(), "did:web:"+strings.Replace(host, ":", "%3A", 1)+":other"); err == nil {
+		t.Error("Expected a missing document to fail")
+	}
+	if _, err := driver.documentURL("did:web:example.com:..:admin"); err == nil {
+		t.Error("Expected path traversal to be rejected")
+	}
+}
+
+type blockingDIDDriver struct{}
+
+func (blockingDIDDriver) Method() string { return "slow" }
+
+func (blockingDIDDriver) Resolve(ctx context.Context, did string) (*DIDDocument, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestDIDResolver_Timeout(t *testing.T) {
+	resolver := NewDIDResolver(&config.Config{DIDResolutionTimeout: 10 * time.Millisecond})
+	resolver.RegisterDriver(blockingDIDDriver{})
+
+	start := time.Now()
+	if _, err := resolver.Resolve(context.Background(), "did:slow:1"); err == nil {
+		t.Error("Expected resolution to time out")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Expected the timeout to bound resolution")
+	}
+}
+
+func TestOpenID4VPService_DIDIssuer(t *testing.T) {
+	issuer, issuerKey := newSDJWTTestService(t)
+	did, _ := EncodeDIDKey(&issuerKey.PublicKey)
+	issuer.sdJWTSigner.Issuer = did
+	issuer.sdJWTSigner.KeyID = did + "#" + strings.TrimPrefix(did, "did:key:")
+
+	service := NewOpenID4VPService(&config.Config{Issuer: "https://broker.example", OpenID4VPTrustedDIDs: []string{did}}, NewClaimSchemaRegistry(), nil)
+	service.SetDIDResolver(NewDIDResolver(&config.Config{}))
+
+	session, _ := service.CreateRequest("rp-1", PresentationRequest{ClaimType: "student_verification", Attributes: []string{"institution"}})
+	auth := session.AuthorizationRequest
+	vpToken := walletPresentation(t, issuer, map[string]interface{}{"institution": "State University"}, []string{"institution"}, auth)
+	submission := &PresentationSubmission{DefinitionID: auth.PresentationDefinition.ID, DescriptorMap: []SubmissionDescriptor{{ID: "student_verification", Format: "dc+sd-jwt", Path: "$"}}}
+
+	result, err := service.SubmitResponse(context.Background(), session.ID, vpToken, submission)
+	if err != nil {
+		t.Fatalf("SubmitResponse failed: %v", err)
+	}
+	if result.Issuer != did {
+		t.Errorf("Expected the DID issuer, got %s", result.Issuer)
+	}
+}
+
+func TestOpenID4VPService_DIDIssuerHonorsContext(t *testing.T) {
+	issuer, _ := newSDJWTTestService(t)
+	issuer.sdJWTSigner.Issuer = "did:slow:issuer"
+	issuer.sdJWTSigner.KeyID = "did:slow:issuer#key-1"
+
+	service := NewOpenID4VPService(&config.Config{Issuer: "https://broker.example", OpenID4VPTrustedDIDs: []string{"did:slow:issuer"}}, NewClaimSchemaRegistry(), nil)
+	resolver := NewDIDResolver(&config.Config{DIDResolutionTimeout: time.Minute})
+	resolver.RegisterDriver(blockingDIDDriver{})
+	service.SetDIDResolver(resolver)
+
+	session, _ := service.CreateRequest("rp-1", PresentationRequest{ClaimType: "student_verification", Attributes: []string{"institution"}})
+	auth := session.AuthorizationRequest
+	vpToken := walletPresentation(t, issuer, map[string]interface{}{"institution": "State University"}, []string{"institution"}, auth)
+	submission := &PresentationSubmission{DefinitionID: auth.PresentationDefinition.ID, DescriptorMap: []SubmissionDescriptor{{ID: "student_verification", Format: "dc+sd-jwt", Path: "$"}}}
+
+	// The caller's deadline, not the resolver's timeout, bounds resolution
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := service.SubmitResponse(ctx, session.ID, vpToken, submission); err == nil {
+		t.Fatal("Expected resolution to stop at the caller's deadline")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Expected the caller's deadline to bound resolution")
+	}
+}
+
+// didCredential returns a credential issued by a did:key issuer and signed
+// with a detached EdDSA JWS, for a did:key holder
+func didCredential(t *testing.T) (*models.Credential, ed25519.PrivateKey) {
+	t.Helper()
+	issuerPublic, issuerPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	issuer, _ := EncodeDIDKey(issuerPublic)
+	holderKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	holder, _ := EncodeDIDKey(&holderKey.PublicKey)
+
+	credential := &models.Credential{
+		ID:           "cred-did-1",
+		Type:         "StudentCredential",
+		Issuer:       issuer,
+		Subject:      holder,
+		IssuanceDate: "2026-01-01T00:00:00Z",
+		Version:      "1.0",
+		Claims:       map[string]interface{}{"enrolled": true},
+		Proof: models.CredentialProof{
+			Type:               "JsonWebSignature2020",
+			Created:            "2026-01-01T00:00:00Z",
+			VerificationMethod: "#" + strings.TrimPrefix(issuer, "did:key:"),
+			ProofPurpose:       "assertionMethod",
+		},
+		Status: "valid",
+	}
+	signCredential(t, credential, issuerPrivate)
+	return credential, issuerPrivate
+}
+
+// signCredential sets a credential's detached EdDSA JWS
+func signCredential(t *testing.T, credential *models.Credential, key ed25519.PrivateKey) {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"EdDSA"}`))
+	payload, err := credentialSigningPayload(credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := jwt.SigningMethodEdDSA.Sign(header+"."+payload, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential.Proof.JWS = header + ".." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestCredentialValidator_DIDIssuer(t *testing.T) {
+	validate := func(credential *models.Credential) *CredentialValidationResult {
+		validator := NewCredentialValidator()
+		validator.SetDIDResolver(NewDIDResolver(&config.Config{}))
+		result, err := validator.ValidateCredential(context.Background(), credential)
+		if err != nil {
+			t.Fatalf("ValidateCredential failed: %v", err)
+		}
+		return result
+	}
+
+	credential, issuerKey := didCredential(t)
+	if result := validate(credential); !result.Valid || !result.SignatureValid || !result.IssuerValid || !result.HolderBound {
+		t.Fatalf("Expected a valid, holder-bound credential, got %+v", result)
+	}
+
+	// A claim changed after signing breaks the proof
+	tampered := *credential
+	tampered.Claims = map[string]interface{}{"enrolled": false}
+	if result := validate(&tampered); result.Valid || result.SignatureValid {
+		t.Errorf("Expected a tampered credential to fail, got %+v", result)
+	}
+
+	// So does a signature by a key other than the issuer's
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	forged := *credential
+	signCredential(t, &forged, otherKey)
+	if result := validate(&forged); result.Valid {
+		t.Errorf("Expected a forged credential to fail, got %+v", result)
+	}
+
+	// The verification method must be one of the issuer's keys
+	foreign := *credential
+	foreign.Proof.VerificationMethod = "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK#key-1"
+	signCredential(t, &foreign, issuerKey)
+	if result := validate(&foreign); result.Valid || !strings.Contains(result.Reason, "does not belong") {
+		t.Errorf("Expected another DID's key to be refused, got %+v", result)
+	}
+
+	// A holder DID that does not resolve cannot be bound
+	unbound := *credential
+	unbound.Subject = "did:key:zInvalid0"
+	signCredential(t, &unbound, issuerKey)
+	if result := validate(&unbound); result.Valid || !strings.Contains(result.Reason, "Holder binding failed") {
+		t.Errorf("Expected an unresolvable holder to be refused, got %+v", result)
+	}
+
+	// Subjects of other methods are not bound, nor refused
+	other := *credential
+	other.Subject = "did:example:student"
+	signCredential(t, &other, issuerKey)
+	if result := validate(&other); !result.Valid || result.HolderBound {
+		t.Errorf("Expected a valid credential without holder binding, got %+v", result)
+	}
+}
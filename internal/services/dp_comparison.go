@@ -0,0 +1,370 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxComparisonDisagreements is the number of recent disagreements kept per
+// comparison for the report
+const maxComparisonDisagreements = 20
+
+// DPComparison sends a sample of the verifications an incumbent DP answers for
+// a claim type to a candidate DP as well. The candidate's answer is only
+// recorded; RPs always get the incumbent's. A candidate may be disabled in the
+// registry so that it receives no live traffic of its own.
+type DPComparison struct {
+	ClaimType string `json:"claim_type"`
+	Incumbent string `json:"incumbent"`
+	Candidate string `json:"candidate"`
+	// SampleRate is the fraction (0-1] of the incumbent's verifications sent
+	// to the candidate
+	SampleRate float64 `json:"sample_rate"`
+}
+
+// Validate checks a comparison definition
+func (c *DPComparison) Validate() error {
+	if c.ClaimType == "" {
+		return fmt.Errorf("DP comparison claim_type is required")
+	}
+	if c.Incumbent == "" || c.Candidate == "" {
+		return fmt.Errorf("DP comparison for %s: incumbent and candidate are required", c.ClaimType)
+	}
+	if c.Incumbent == c.Candidate {
+		return fmt.Errorf("DP comparison for %s: incumbent and candidate must differ", c.ClaimType)
+	}
+	if c.SampleRate <= 0 || c.SampleRate > 1 {
+		return fmt.Errorf("DP comparison for %s: sample_rate must be in (0, 1]", c.ClaimType)
+	}
+	return nil
+}
+
+// DPComparisonsFile is the on-disk format of comparisons started at startup
+type DPComparisonsFile struct {
+	Comparisons []*DPComparison `json:"comparisons"`
+}
+
+// DPComparisonDisagreement is one verification on which the DPs differed. It
+// carries the DPs' job IDs, never the identifiers verified.
+type DPComparisonDisagreement struct {
+	Timestamp           time.Time `json:"timestamp"`
+	IncumbentJobID      string    `json:"incumbent_job_id"`
+	CandidateJobID      string    `json:"candidate_job_id"`
+	IncumbentVerified   bool      `json:"incumbent_verified"`
+	CandidateVerified   bool      `json:"candidate_verified"`
+	IncumbentConfidence float64   `json:"incumbent_confidence"`
+	CandidateConfidence float64   `json:"candidate_confidence"`
+}
+
+// DPComparisonLatency summarizes one DP's latencies in a comparison
+type DPComparisonLatency struct {
+	P50Ms  float64 `json:"p50_ms"`
+	P95Ms  float64 `json:"p95_ms"`
+	MeanMs float64 `json:"mean_ms"`
+}
+
+// DPComparisonReport is the outcome of a comparison so far. Agreement,
+// latency and confidence are taken over the samples both DPs answered.
+type DPComparisonReport struct {
+	Comparison *DPComparison `json:"comparison"`
+	StartedAt  time.Time     `json:"started_at"`
+	// Samples counts the verifications sent to the candidate; Compared the
+	// ones both DPs returned a result for
+	Samples         int64   `json:"samples"`
+	Compared        int64   `json:"compared"`
+	Agreements      int64   `json:"agreements"`
+	Disagreements   int64   `json:"disagreements"`
+	AgreementRate   float64 `json:"agreement_rate"`
+	CandidateErrors int64   `json:"candidate_errors"`
+	// Inconclusive counts samples where either DP returned no result, e.g.
+	// an accepted asynchronous job
+	Inconclusive int64 `json:"inconclusive"`
+	// Skipped counts samples dropped because the candidate was not
+	// registered
+	Skipped          int64               `json:"skipped"`
+	IncumbentLatency DPComparisonLatency `json:"incumbent_latency"`
+	CandidateLatency DPComparisonLatency `json:"candidate_latency"`
+	// MeanLatencyDeltaMs is the candidate's latency minus the incumbent's
+	MeanLatencyDeltaMs float64 `json:"mean_latency_delta_ms"`
+	// MeanConfidenceDelta is the candidate's confidence minus the
+	// incumbent's; MeanAbsConfidenceDelta ignores the direction
+	MeanConfidenceDelta    float64                    `json:"mean_confidence_delta"`
+	MeanAbsConfidenceDelta float64                    `json:"mean_abs_confidence_delta"`
+	RecentDisagreements    []DPComparisonDisagreement `json:"recent_disagreements"`
+	LastCandidateError     string                     `json:"last_candidate_error,omitempty"`
+}
+
+// dpComparisonState accumulates the samples of one comparison
+type dpComparisonState struct {
+	comparison         *DPComparison
+	startedAt          time.Time
+	samples            int64
+	agreements         int64
+	disagreements      int64
+	candidateErrors    int64
+	inconclusive       int64
+	skipped            int64
+	incumbentLatencies []time.Duration
+	candidateLatencies []time.Duration
+	latencyDeltaSum    float64
+	confidenceDeltaSum float64
+	absConfidenceSum   float64
+	disagreementLog    []DPComparisonDisagreement
+	lastCandidateError string
+}
+
+// DPComparisonService holds the comparisons between incumbent and candidate
+// DPs, one per claim type
+type DPComparisonService struct {
+	mu          sync.Mutex
+	comparisons map[string]*dpComparisonState
+	// random draws the sampling decision; replaced in tests
+	random func() float64
+}
+
+// NewDPComparisonService creates a service without comparisons
+func NewDPComparisonService() *DPComparisonService {
+	return &DPComparisonService{
+		comparisons: make(map[string]*dpComparisonState),
+		random:      rand.Float64,
+	}
+}
+
+// Start begins a comparison for its claim type, replacing any running one and
+// discarding its samples
+func (s *DPComparisonService) Start(comparison *DPComparison) error {
+	if err := comparison.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.comparisons[comparison.ClaimType] = &dpComparisonState{
+		comparison: comparison,
+		startedAt:  time.Now(),
+	}
+	return nil
+}
+
+// Stop ends the comparison for a claim type, returning its final report
+func (s *DPComparisonService) Stop(claimType string) (*DPComparisonReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, exists := s.comparisons[claimType]
+	if !exists {
+		return nil, fmt.Errorf("no DP comparison running for claim type %s", claimType)
+	}
+	delete(s.comparisons, claimType)
+	return state.report(), nil
+}
+
+// LoadFile starts the comparisons of a comparisons file
+func (s *DPComparisonService) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read DP comparisons file: %w", err)
+	}
+
+	var file DPComparisonsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse DP comparisons file: %w", err)
+	}
+
+	for _, comparison := range file.Comparisons {
+		if err := s.Start(comparison); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Report returns the report of the comparison for a claim type
+func (s *DPComparisonService) Report(claimType string) (*DPComparisonReport, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, exists := s.comparisons[claimType]
+	if !exists {
+		return nil, false
+	}
+	return state.report(), true
+}
+
+// Reports returns the reports of the running comparisons ordered by claim type
+func (s *DPComparisonService) Reports() []*DPComparisonReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reports := make([]*DPComparisonReport, 0, len(s.comparisons))
+	for _, claimType := range sortedMapKeys(s.comparisons) {
+		reports = append(reports, s.comparisons[claimType].report())
+	}
+	return reports
+}
+
+// sample decides whether a verification answered by a DP is also sent to the
+// candidate of its claim type's comparison
+func (s *DPComparisonService) sample(claimType, dpID string) (*DPComparison, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, exists := s.comparisons[claimType]
+	if !exists || state.comparison.Incumbent != dpID {
+		return nil, false
+	}
+	if s.random() >= state.comparison.SampleRate {
+		return nil, false
+	}
+	state.samples++
+	return state.comparison, true
+}
+
+// skip records a sample that could not be sent to the candidate
+func (s *DPComparisonService) skip(comparison *DPComparison) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state := s.comparisons[comparison.ClaimType]; state != nil && state.comparison == comparison {
+		state.skipped++
+	}
+}
+
+// record adds the candidate's answer to a sample. Samples of a comparison
+// that has since been stopped or replaced are dropped.
+func (s *DPComparisonService) record(comparison *DPComparison, incumbent *DPResponse, incumbentLatency time.Duration, candidate *DPResponse, candidateLatency time.Duration, candidateErr error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.comparisons[comparison.ClaimType]
+	if state == nil || state.comparison != comparison {
+		return
+	}
+
+	if candidateErr != nil {
+		state.candidateErrors++
+		state.lastCandidateError = candidateErr.Error()
+		return
+	}
+	if incumbent.VerificationResult == nil || candidate.VerificationResult == nil {
+		state.inconclusive++
+		return
+	}
+
+	state.incumbentLatencies = appendLatencySample(state.incumbentLatencies, incumbentLatency)
+	state.candidateLatencies = appendLatencySample(state.candidateLatencies, candidateLatency)
+	state.latencyDeltaSum += float64(candidateLatency-incumbentLatency) / float64(time.Millisecond)
+
+	delta := candidate.VerificationResult.Confidence - incumbent.VerificationResult.Confidence
+	state.confidenceDeltaSum += delta
+	state.absConfidenceSum += math.Abs(delta)
+
+	if incumbent.VerificationResult.Verified == candidate.VerificationResult.Verified {
+		state.agreements++
+		return
+	}
+	state.disagreements++
+	state.disagreementLog = append(state.disagreementLog, DPComparisonDisagreement{
+		Timestamp:           time.Now(),
+		IncumbentJobID:      incumbent.JobID,
+		CandidateJobID:      candidate.JobID,
+		IncumbentVerified:   incumbent.VerificationResult.Verified,
+		CandidateVerified:   candidate.VerificationResult.Verified,
+		IncumbentConfidence: incumbent.VerificationResult.Confidence,
+		CandidateConfidence: candidate.VerificationResult.Confidence,
+	})
+	if len(state.disagreementLog) > maxComparisonDisagreements {
+		state.disagreementLog = state.disagreementLog[len(state.disagreementLog)-maxComparisonDisagreements:]
+	}
+}
+
+// appendLatencySample keeps the most recent latencySampleSize latencies
+func appendLatencySample(samples []time.Duration, latency time.Duration) []time.Duration {
+	samples = append(samples, latency)
+	if len(samples) > latencySampleSize {
+		samples = samples[len(samples)-latencySampleSize:]
+	}
+	return samples
+}
+
+func (s *dpComparisonState) report() *DPComparisonReport {
+	report := &DPComparisonReport{
+		Comparison:          s.comparison,
+		StartedAt:           s.startedAt,
+		Samples:             s.samples,
+		Agreements:          s.agreements,
+		Disagreements:       s.disagreements,
+		CandidateErrors:     s.candidateErrors,
+		Inconclusive:        s.inconclusive,
+		Skipped:             s.skipped,
+		IncumbentLatency:    summarizeLatencies(s.incumbentLatencies),
+		CandidateLatency:    summarizeLatencies(s.candidateLatencies),
+		RecentDisagreements: append([]DPComparisonDisagreement{}, s.disagreementLog...),
+		LastCandidateError:  s.lastCandidateError,
+	}
+	report.Compared = s.agreements + s.disagreements
+	if report.Compared > 0 {
+		compared := float64(report.Compared)
+		report.AgreementRate = float64(s.agreements) / compared
+		report.MeanLatencyDeltaMs = s.latencyDeltaSum / compared
+		report.MeanConfidenceDelta = s.confidenceDeltaSum / compared
+		report.MeanAbsConfidenceDelta = s.absConfidenceSum / compared
+	}
+	return report
+}
+
+// summarizeLatencies returns the median, 95th percentile and mean of latencies
+func summarizeLatencies(latencies []time.Duration) DPComparisonLatency {
+	if len(latencies) == 0 {
+		return DPComparisonLatency{}
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, latency := range sorted {
+		total += latency
+	}
+	percentile := func(p float64) float64 {
+		index := int(math.Ceil(float64(len(sorted))*p)) - 1
+		if index < 0 {
+			index = 0
+		}
+		return float64(sorted[index]) / float64(time.Millisecond)
+	}
+	return DPComparisonLatency{
+		P50Ms:  percentile(0.5),
+		P95Ms:  percentile(0.95),
+		MeanMs: float64(total) / float64(len(sorted)) / float64(time.Millisecond),
+	}
+}
+
+// Comparisons returns the DP comparisons run alongside live verifications
+func (s *DPConnectorService) Comparisons() *DPComparisonService {
+	return s.comparisons
+}
+
+// compareWithCandidate sends a sampled verification answered by a
+// comparison's incumbent to its candidate in the background. The candidate is
+// called like any other DP, so its breaker and bulkhead apply, but it never
+// holds the tenant's slot or delays the RP's response.
+func (s *DPConnectorService) compareWithCandidate(ctx context.Context, claimType string, payload []byte, incumbent *DPResponse, incumbentLatency time.Duration) {
+	comparison, sampled := s.comparisons.sample(claimType, incumbent.DPID)
+	if !sampled {
+		return
+	}
+	candidate, exists := s.registry.Get(comparison.Candidate)
+	if !exists {
+		s.comparisons.skip(comparison)
+		return
+	}
+
+	// The RP's request may finish, and its context be cancelled, first
+	shadowCtx := context.WithoutCancel(ctx)
+	go func() {
+		start := time.Now()
+		response, err := s.attemptProvider(shadowCtx, candidate, payload)
+		s.comparisons.record(comparison, incumbent, incumbentLatency, response, time.Since(start), err)
+	}()
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// newComparisonTestService registers an incumbent DP and a disabled candidate
+// answering with the given verified flags
+func newComparisonTestService(t *testing.T, incumbentVerified, candidateVerified bool) (*DPConnectorService, func()) {
+	t.Helper()
+
+	respond := func(verified bool, confidence float64) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(DPResponse{
+				JobID:              "job_1",
+				Status:             "completed",
+				VerificationResult: &VerificationResult{Verified: verified, Confidence: confidence},
+				Timestamp:          "2025-08-02T07:00:00Z",
+			})
+		}
+	}
+	incumbent := httptest.NewServer(respond(incumbentVerified, 0.9))
+	candidate := httptest.NewServer(respond(candidateVerified, 0.7))
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: "http://localhost:1"})
+	service.retryConfig.MaxRetries = 0
+	service.registry = NewDPRegistry()
+	service.registry.Register(&DPProvider{DPID: "dp_incumbent", Endpoint: incumbent.URL, SupportedClaims: []string{"age_verification"}})
+	service.registry.Register(&DPProvider{DPID: "dp_candidate", Endpoint: candidate.URL, SupportedClaims: []string{"age_verification"}, Disabled: true})
+
+	return service, func() {
+		incumbent.Close()
+		candidate.Close()
+	}
+}
+
+// waitForComparison waits for the background candidate calls to be recorded
+func waitForComparison(t *testing.T, service *DPConnectorService, answered int64) *DPComparisonReport {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		report, _ := service.Comparisons().Report("age_verification")
+		if report.Compared+report.CandidateErrors+report.Inconclusive >= answered || time.Now().After(deadline) {
+			return report
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDPComparison_RecordsAgreement(t *testing.T) {
+	service, cleanup := newComparisonTestService(t, true, true)
+	defer cleanup()
+	service.Comparisons().Start(&DPComparison{ClaimType: "age_verification", Incumbent: "dp_incumbent", Candidate: "dp_candidate", SampleRate: 1})
+
+	for i := 0; i < 3; i++ {
+		response, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"})
+		if err != nil || response.DPID != "dp_incumbent" {
+			t.Fatalf("Expected the incumbent to answer the RP, got %v, %v", response, err)
+		}
+	}
+
+	report := waitForComparison(t, service, 3)
+	if report.Samples != 3 || report.Agreements != 3 || report.AgreementRate != 1 {
+		t.Errorf("Expected 3 agreeing samples, got %+v", report)
+	}
+	if report.MeanConfidenceDelta > -0.19 || report.MeanConfidenceDelta < -0.21 {
+		t.Errorf("Expected a confidence delta of -0.2, got %v", report.MeanConfidenceDelta)
+	}
+	if report.CandidateLatency.P50Ms <= 0 {
+		t.Errorf("Expected candidate latencies recorded, got %+v", report.CandidateLatency)
+	}
+}
+
+func TestDPComparison_RecordsDisagreement(t *testing.T) {
+	service, cleanup := newComparisonTestService(t, true, false)
+	defer cleanup()
+	service.Comparisons().Start(&DPComparison{ClaimType: "age_verification", Incumbent: "dp_incumbent", Candidate: "dp_candidate", SampleRate: 1})
+
+	response, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"})
+	if err != nil || !response.VerificationResult.Verified {
+		t.Fatalf("Expected the RP to get the incumbent's result, got %v, %v", response, err)
+	}
+
+	report := waitForComparison(t, service, 1)
+	if report.Disagreements != 1 || len(report.RecentDisagreements) != 1 {
+		t.Fatalf("Expected one disagreement, got %+v", report)
+	}
+	if disagreement := report.RecentDisagreements[0]; !disagreement.IncumbentVerified || disagreement.CandidateVerified {
+		t.Errorf("Unexpected disagreement: %+v", disagreement)
+	}
+}
+
+func TestDPComparison_Sampling(t *testing.T) {
+	service, cleanup := newComparisonTestService(t, true, true)
+	defer cleanup()
+	comparisons := service.Comparisons()
+	comparisons.Start(&DPComparison{ClaimType: "age_verification", Incumbent: "dp_incumbent", Candidate: "dp_candidate", SampleRate: 0.5})
+
+	draws := []float64{0.2, 0.7}
+	comparisons.random = func() float64 {
+		draw := draws[0]
+		draws = draws[1:]
+		return draw
+	}
+	if _, sampled := comparisons.sample("age_verification", "dp_incumbent"); !sampled {
+		t.Error("Expected a draw under the rate to be sampled")
+	}
+	if _, sampled := comparisons.sample("age_verification", "dp_incumbent"); sampled {
+		t.Error("Expected a draw over the rate not to be sampled")
+	}
+	if _, sampled := comparisons.sample("age_verification", "dp_candidate"); sampled {
+		t.Error("Expected responses from other DPs not to be sampled")
+	}
+}
+
+func TestDPComparison_Validate(t *testing.T) {
+	invalid := []*DPComparison{
+		{Incumbent: "dp_1", Candidate: "dp_2", SampleRate: 0.1},
+		{ClaimType: "age_verification", Incumbent: "dp_1", SampleRate: 0.1},
+		{ClaimType: "age_verification", Incumbent: "dp_1", Candidate: "dp_1", SampleRate: 0.1},
+		{ClaimType: "age_verification", Incumbent: "dp_1", Candidate: "dp_2", SampleRate: 1.5},
+	}
+	for _, comparison := range invalid {
+		if err := comparison.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", comparison)
+		}
+	}
+
+	service := NewDPComparisonService()
+	if _, err := service.Stop("age_verification"); err == nil {
+		t.Error("Expected stopping an unknown comparison to fail")
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DPValidators are the HTTP cache validators a DP returned with a
+// verification. Stored with the cached result, they let a refresh ask the DP
+// whether the result changed instead of verifying again.
+type DPValidators struct {
+	DPID         string `json:"dp_id"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// dpValidatorsKey carries the validators of the result being refreshed
+type dpValidatorsKey struct{}
+
+// WithDPValidators makes the DP request for a refresh conditional on the
+// cached result's validators
+func WithDPValidators(ctx context.Context, validators *DPValidators) context.Context {
+	return context.WithValue(ctx, dpValidatorsKey{}, validators)
+}
+
+// dpValidatorsFor returns the validators for a provider; validators from
+// another DP mean nothing to this one
+func dpValidatorsFor(ctx context.Context, dpID string) *DPValidators {
+	validators, _ := ctx.Value(dpValidatorsKey{}).(*DPValidators)
+	if validators == nil || validators.DPID != dpID || (validators.ETag == "" && validators.LastModified == "") {
+		return nil
+	}
+	return validators
+}
+
+// conditionalHeaders returns the headers making a request conditional
+func (v *DPValidators) conditionalHeaders() http.Header {
+	if v == nil {
+		return nil
+	}
+	header := http.Header{}
+	if v.ETag != "" {
+		header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		header.Set("If-Modified-Since", v.LastModified)
+	}
+	return header
+}
+
+// responseValidators reads the validators from a DP response, or returns nil
+// for DPs that send none
+func responseValidators(dpID string, resp *http.Response) *DPValidators {
+	validators := &DPValidators{
+		DPID:         dpID,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if validators.ETag == "" && validators.LastModified == "" {
+		return nil
+	}
+	return validators
+}
+
+// notModifiedResponse turns a 304 into a response confirming the cached
+// result, keeping validators the DP did not replace
+func notModifiedResponse(previous *DPValidators, resp *http.Response) *DPResponse {
+	validators := *previous
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		validators.ETag = etag
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		validators.LastModified = lastModified
+	}
+	// A 304 has no body, so there is no DP job to refer to
+	return &DPResponse{
+		JobID:      string(DPStatusNotModified),
+		DPID:       validators.DPID,
+		Status:     DPStatusNotModified,
+		Timestamp:  time.Now().Format(time.RFC3339),
+		Validators: &validators,
+	}
+}
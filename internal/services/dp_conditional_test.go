@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestDPConnectorService_ConditionalRequests(t *testing.T) {
+	var conditions []string
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditions = append(conditions, r.Header.Get("If-None-Match"))
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Wed, 01 Jan 2026 00:00:00 GMT")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":              "j",
+			"status":              "completed",
+			"timestamp":           "2026-01-01T00:00:00Z",
+			"verification_result": map[string]interface{}{"verified": true, "confidence": 0.9},
+		})
+	}))
+	defer dp.Close()
+
+	cfg := &config.Config{DPConnectorURL: dp.URL, DPTimeout: 5 * time.Second}
+	service := NewDPConnectorService(cfg)
+	req := &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"}
+
+	first, err := service.VerifyWithDP(context.Background(), req)
+	if err != nil {
+		t.Fatalf("VerifyWithDP failed: %v", err)
+	}
+	if first.Validators == nil || first.Validators.ETag != `"v1"` || first.Validators.DPID != DefaultDPProviderID {
+		t.Fatalf("Expected the DP's validators, got %+v", first.Validators)
+	}
+
+	refreshed, err := service.VerifyWithDP(WithDPValidators(context.Background(), first.Validators), req)
+	if err != nil {
+		t.Fatalf("Conditional VerifyWithDP failed: %v", err)
+	}
+	if refreshed.Status != DPStatusNotModified || refreshed.Validators.LastModified != "Wed, 01 Jan 2026 00:00:00 GMT" {
+		t.Errorf("Expected a not_modified response with validators, got %+v", refreshed)
+	}
+
+	// Validators from another DP do not make the request conditional
+	other := &DPValidators{DPID: "dp-other", ETag: `"v1"`}
+	if response, err := service.VerifyWithDP(WithDPValidators(context.Background(), other), req); err != nil || response.Status == DPStatusNotModified {
+		t.Errorf("Expected another DP's validators to be ignored, got %+v, %v", response, err)
+	}
+	if len(conditions) != 3 || conditions[0] != "" || conditions[1] != `"v1"` || conditions[2] != "" {
+		t.Errorf("Unexpected conditional headers: %q", conditions)
+	}
+
+	result, err := NewPullJobService(cfg, service).parseJobResult(refreshed)
+	if err != nil {
+		t.Fatalf("parseJobResult failed: %v", err)
+	}
+	if !result.NotModified || result.ETag != `"v1"` {
+		t.Errorf("Expected the job result to carry the 304, got %+v", result)
+	}
+}
@@ -5,14 +5,16 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"math"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/pavilion-trust/core-broker/internal/config"
 	"github.com/pavilion-trust/core-broker/internal/models"
 )
@@ -29,6 +31,56 @@ type DPConnectorService struct {
 	retryConfig *RetryConfig
 	// Authentication handler
 	authenticator *Authenticator
+	// Registry routing claim types to providers
+	registry *DPRegistry
+	// Per-provider circuit breakers, authenticators and TLS clients
+	providerMu             sync.Mutex
+	providerBreakers       map[string]*CircuitBreaker
+	providerAuthenticators map[string]*Authenticator
+	providerClients        map[string]*http.Client
+	// Pinning and OCSP failures, kept apart from generic errors
+	tlsEventMu      sync.Mutex
+	tlsEvents       []TLSEvent
+	tlsEventCounts  map[string]int64
+	tlsEventHandler func(TLSEvent)
+	// Circuit breaker transitions, reported so outages reach operators first
+	breakerEventMu      sync.Mutex
+	breakerEvents       []CircuitBreakerEvent
+	breakerEventHandler func(CircuitBreakerEvent)
+	// Differences between DP responses and their registered schemas
+	schemaDrift *SchemaDriftDetector
+	// Responses with statuses outside the DP protocol
+	statusQuarantine *DPStatusQuarantine
+	// Recent DP latencies and counters for hedged verifications
+	latencies      *latencyTracker
+	hedgedRequests int64
+	hedgeWins      int64
+	// Incumbent and candidate DPs compared on a sample of live requests
+	comparisons *DPComparisonService
+	// Validates DP responses against their claim type's published schema
+	dataValidator *DataValidator
+	// Maps provider responses with their named response pipelines
+	transformer *DataTransformer
+	// Normalizes provider responses into canonical claims
+	claims *ClaimNormalizer
+	// Claim types requiring several DPs to agree on their result
+	consensusRules map[string]ConsensusRule
+	// Multi-record results cut short by pagination limits
+	paginationTruncations int64
+	// DP payload limits, advertised and learned, for splitting requests
+	payloadLimits *dpPayloadLimits
+	// Bulkheads keep one slow DP or heavy tenant from taking every
+	// connection and goroutine
+	dpBulkheads     *bulkheadGroup
+	tenantBulkheads *bulkheadGroup
+	// Adaptive limits back off from a DP host as its latency rises
+	dpLimiters *adaptiveLimiterGroup
+	// Token exchanges for delegated DP access are recorded here
+	auditService *AuditService
+	// The DPoP key is loaded when the first provider requiring it is used
+	dpopOnce     sync.Once
+	dpopKeyValue *DPoPKey
+	dpopKeyErr   error
 }
 
 // ConnectionPool manages HTTP connections
@@ -43,6 +95,13 @@ type ConnectionPool struct {
 	loadBalancer *LoadBalancer
 	// Connection timeout configuration
 	timeoutConfig *TimeoutConfig
+	// LRU bookkeeping: clients beyond maxIdle, or idle longer than idleTime,
+	// are evicted
+	lastUsed      map[string]time.Time
+	lruEvictions  int64
+	idleEvictions int64
+	janitorOnce   sync.Once
+	janitorStop   chan struct{}
 }
 
 // HealthCheck tracks connection health
@@ -81,14 +140,52 @@ type TimeoutConfig struct {
 	KeepAliveTimeout time.Duration
 }
 
-// CircuitBreaker implements circuit breaker pattern
+// ErrCircuitOpen is returned for a DP whose circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// DPProblemCode returns the problem code reporting a failed DP call:
+// CIRCUIT_OPEN when the DP's breaker refused the call, DP_TIMEOUT when the
+// call timed out, and "" for other failures
+func DPProblemCode(err error) string {
+	if errors.Is(err, ErrCircuitOpen) {
+		return models.ProblemCircuitOpen
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &timeout) && timeout.Timeout()) {
+		return models.ProblemDPTimeout
+	}
+	return ""
+}
+
+// CircuitBreaker implements circuit breaker pattern. All of its state is
+// guarded by mu, and each state change is made under a single hold of it;
+// transition events are queued under mu and delivered in order, outside it,
+// by emit.
 type CircuitBreaker struct {
-	mu              sync.RWMutex
+	mu              sync.Mutex
 	failureCount    int
 	lastFailureTime time.Time
 	state           CircuitState
 	threshold       int
 	timeout         time.Duration
+	// Transitions are reported with the DP and its most recent errors
+	dpID         string
+	recentErrors []string
+	onTransition func(CircuitBreakerEvent)
+	// In error rate mode the breaker opens on the share of failures among
+	// recent calls instead of a run of consecutive failures
+	settings DPBreakerSettings
+	window   *outcomeWindow
+	// While half-open, probes are the calls in flight to the DP, the
+	// latest started at probeStarted, and probeSuccesses the consecutive
+	// probes that succeeded
+	probes         int
+	probeStarted   time.Time
+	probeSuccesses int
+	// pendingEvents are transitions not yet delivered; emitMu orders their
+	// delivery
+	pendingEvents []*CircuitBreakerEvent
+	emitMu        sync.Mutex
 }
 
 // CircuitState represents the state of the circuit breaker
@@ -106,16 +203,42 @@ type RetryConfig struct {
 	BaseDelay         time.Duration
 	MaxDelay          time.Duration
 	BackoffMultiplier float64
+	// Jitter randomizes each backoff delay between zero and its cap
+	Jitter bool
+	// Budget limits retries across requests; nil disables the limit
+	Budget *RetryBudget
 }
 
 // DPResponse represents a response from the DP Connector
 type DPResponse struct {
 	JobID              string                 `json:"job_id"`
-	Status             string                 `json:"status"`
+	DPID               string                 `json:"dp_id,omitempty"`
+	Status             DPStatus               `json:"status"`
 	VerificationResult *VerificationResult    `json:"verification_result,omitempty"`
 	Error              string                 `json:"error,omitempty"`
 	Timestamp          string                 `json:"timestamp"`
 	Metadata           map[string]interface{} `json:"metadata,omitempty"`
+	// Records and Pagination are set by DPs returning multi-record results;
+	// the pages of one query are merged before the response is returned
+	Records    []map[string]interface{} `json:"records,omitempty"`
+	Pagination *DPPageInfo              `json:"pagination,omitempty"`
+	// PartialResults is set by DPs that matched some identifiers and could
+	// not determine the others
+	PartialResults *models.PartialResults `json:"partial_results,omitempty"`
+	// Claim is the result normalized into the canonical claim model
+	Claim *models.Claim `json:"claim,omitempty"`
+	// Consensus is set when several DPs were required to agree on the result
+	Consensus *models.ConsensusResult `json:"consensus,omitempty"`
+	// Scoring records how the result's confidence was calibrated and
+	// combined
+	Scoring *models.ConfidenceScore `json:"scoring,omitempty"`
+	// Validators are the DP's ETag and Last-Modified for the result
+	Validators *DPValidators `json:"-"`
+	// Freshness, when set, is the claim type's freshness policy, deciding
+	// when the result expires
+	Freshness *FreshnessPolicy `json:"-"`
+	// body is the provider's own response, before any response pipeline
+	body []byte
 }
 
 // VerificationResult represents the result of a verification
@@ -135,6 +258,9 @@ func NewDPConnectorService(cfg *config.Config) *DPConnectorService {
 		BaseDelay:         1 * time.Second,
 		MaxDelay:          30 * time.Second,
 		BackoffMultiplier: 2.0,
+		Jitter:            true,
+		// Retries may add at most 20% load, with 10 per window always allowed
+		Budget: NewRetryBudget(0.2, 10, 10*time.Second),
 	}
 
 	// Create connection pool
@@ -160,6 +286,12 @@ func NewDPConnectorService(cfg *config.Config) *DPConnectorService {
 		timeout:      60 * time.Second,
 	}
 
+	// Default TLS settings follow the crypto profile (TLS 1.2 minimum)
+	tlsConfig, err := (&TLSSettings{}).TLSConfig(ActiveCryptoProfile(cfg))
+	if err != nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
 	// Create HTTP client with connection pooling
 	client := &http.Client{
 		Timeout: 30 * time.Second,
@@ -168,9 +300,7 @@ func NewDPConnectorService(cfg *config.Config) *DPConnectorService {
 			MaxIdleConnsPerHost: 10,
 			IdleConnTimeout:     90 * time.Second,
 			TLSHandshakeTimeout: 10 * time.Second,
-			TLSClientConfig: &tls.Config{
-				MinVersion: tls.VersionTLS12,
-			},
+			TLSClientConfig:     tlsConfig,
 		},
 	}
 
@@ -186,23 +316,158 @@ func NewDPConnectorService(cfg *config.Config) *DPConnectorService {
 			AuthMethod: AuthMethodNone,
 		}
 	}
+	var service *DPConnectorService
+	resolveAuthSecrets(cfg.Secrets, fmt.Sprintf("dp:%p:%s", authConfig, DefaultDPProviderID), authConfig, func() {
+		service.dropAuthenticator(DefaultDPProviderID)
+	})
 	authenticator := NewAuthenticator(authConfig)
 
-	return &DPConnectorService{
-		config:         cfg,
-		client:         client,
-		pool:           pool,
-		circuitBreaker: circuitBreaker,
-		retryConfig:    retryConfig,
-		authenticator:  authenticator,
+	// Load the DP registry. Startup rejects a registry that does not load;
+	// claims are never routed past one to DP_CONNECTOR_URL
+	registry, err := LoadDPRegistry(cfg)
+	if err != nil {
+		fmt.Printf("DP REGISTRY ERROR: %v; no DP providers are registered\n", err)
+		registry = NewDPRegistry()
+	}
+
+	consensusRules, err := ParseConsensusRules(cfg.DPConsensusClaims)
+	if err != nil {
+		fmt.Printf("DP CONSENSUS WARNING: %v; verifications use a single DP\n", err)
+	}
+	if !IsConfidenceAggregation(cfg.DPConsensusConfidence) && len(consensusRules) > 0 {
+		fmt.Printf("DP CONSENSUS WARNING: unknown confidence aggregation %q; using mean\n", cfg.DPConsensusConfidence)
+	}
+
+	// Response pipelines may enrich fields from the broker's Redis
+	transformer := NewDataTransformer(DataTransformerConfig{
+		Pipelines: NewTransformationPipelineRegistry(),
+		EnrichmentProviders: map[string]EnrichmentProvider{
+			EnrichmentProviderRedis: NewRedisEnrichment(redis.NewClient(&redis.Options{
+				Addr:     fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port),
+				Password: cfg.Redis.Password,
+				DB:       cfg.Redis.DB,
+			})),
+		},
+	})
+
+	service = &DPConnectorService{
+		config:                 cfg,
+		client:                 client,
+		pool:                   pool,
+		circuitBreaker:         circuitBreaker,
+		retryConfig:            retryConfig,
+		authenticator:          authenticator,
+		registry:               registry,
+		providerBreakers:       map[string]*CircuitBreaker{DefaultDPProviderID: circuitBreaker},
+		providerAuthenticators: map[string]*Authenticator{DefaultDPProviderID: authenticator},
+		providerClients:        make(map[string]*http.Client),
+		tlsEventCounts:         make(map[string]int64),
+		schemaDrift:            NewSchemaDriftDetector(),
+		statusQuarantine:       NewDPStatusQuarantine(),
+		latencies:              newLatencyTracker(),
+		comparisons:            NewDPComparisonService(),
+		dataValidator:          NewDataValidator(DataValidatorConfig{Schemas: NewValidationSchemaRegistry()}),
+		transformer:            transformer,
+		claims:                 NewClaimNormalizer(transformer),
+		consensusRules:         consensusRules,
+		payloadLimits:          newDPPayloadLimits(),
+		dpBulkheads:            newBulkheadGroup(BulkheadKindDP, cfg.BulkheadMaxWait, false),
+		tenantBulkheads:        newBulkheadGroup(BulkheadKindTenant, cfg.BulkheadMaxWait, true),
+		dpLimiters: newAdaptiveLimiterGroup(AdaptiveLimitSettings{
+			Initial:   cfg.DPAdaptiveLimitInitial,
+			Min:       cfg.DPAdaptiveLimitMin,
+			Max:       cfg.DPAdaptiveLimitMax,
+			Tolerance: cfg.DPAdaptiveLimitTolerance,
+			Backoff:   cfg.DPAdaptiveLimitBackoff,
+		}),
+	}
+	circuitBreaker.dpID = DefaultDPProviderID
+	circuitBreaker.onTransition = service.recordBreakerEvent
+	if cfg.DPComparisonsFile != "" {
+		if err := service.comparisons.LoadFile(cfg.DPComparisonsFile); err != nil {
+			fmt.Printf("DP COMPARISON WARNING: %v\n", err)
+		}
+	}
+	if cfg.ValidationSchemasFile != "" {
+		if err := service.ValidationSchemas().LoadFile(cfg.ValidationSchemasFile); err != nil {
+			fmt.Printf("VALIDATION SCHEMA WARNING: %v\n", err)
+		}
+	}
+	if cfg.TransformationPipelinesFile != "" {
+		if err := service.TransformationPipelines().LoadFile(cfg.TransformationPipelinesFile); err != nil {
+			fmt.Printf("TRANSFORMATION PIPELINE WARNING: %v\n", err)
+		}
 	}
+	if len(cfg.DPBreakerWebhookURLs) > 0 {
+		service.breakerEventHandler = NewBreakerWebhookNotifier(cfg.DPBreakerWebhookURLs, cfg.DPBreakerWebhookSecret).Notify
+	}
+	return service
+}
+
+// Close releases pooled connections and stops background cleanup
+func (s *DPConnectorService) Close() {
+	s.pool.Close()
+}
+
+// Registry returns the DP registry used for routing
+func (s *DPConnectorService) Registry() *DPRegistry {
+	return s.registry
+}
+
+// SchemaDrift returns the detector comparing DP responses with their schemas
+func (s *DPConnectorService) SchemaDrift() *SchemaDriftDetector {
+	return s.schemaDrift
+}
+
+// StatusQuarantine returns the responses withheld for unknown statuses
+func (s *DPConnectorService) StatusQuarantine() *DPStatusQuarantine {
+	return s.statusQuarantine
+}
+
+// UpdateResponseSchema replaces a provider's response schema and lifts any
+// quarantine caused by drift from the old one
+func (s *DPConnectorService) UpdateResponseSchema(dpID string, schema *DPResponseSchema) error {
+	provider, exists := s.registry.Get(dpID)
+	if !exists {
+		return fmt.Errorf("unknown DP provider: %s", dpID)
+	}
+
+	// Providers are shared with in-flight requests, so replace rather than modify
+	updated := *provider
+	updated.ResponseSchema = schema
+	if err := updated.Validate(); err != nil {
+		return err
+	}
+	s.registry.Remove(dpID)
+	if err := s.registry.Register(&updated); err != nil {
+		return err
+	}
+
+	s.schemaDrift.Release(dpID)
+	return nil
 }
 
-// VerifyWithDP sends a verification request to the DP Connector
+// VerifyWithDP routes a verification request to the providers registered for
+// its claim type, failing over in priority order. Claim types requiring
+// consensus are sent to several DPs, which must agree on the result. Claim
+// types configured for hedging are also sent to a second DP when the first
+// is slow. Providers
+// quarantined for schema drift are skipped. A sample of the verifications
+// under comparison is also sent to the candidate DP in the background.
 func (s *DPConnectorService) VerifyWithDP(ctx context.Context, req *models.PrivacyRequest) (*DPResponse, error) {
-	// Check circuit breaker state
-	if !s.circuitBreaker.CanExecute() {
-		return nil, fmt.Errorf("circuit breaker is open, DP connector is unavailable")
+	registered := s.registry.ProvidersForClaim(req.ClaimType)
+	if len(registered) == 0 {
+		return nil, fmt.Errorf("no data provider registered for claim type: %s", req.ClaimType)
+	}
+
+	providers := make([]*DPProvider, 0, len(registered))
+	for _, provider := range registered {
+		if !s.schemaDrift.IsQuarantined(provider.DPID) {
+			providers = append(providers, provider)
+		}
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("all data providers for claim type %s are quarantined for schema drift", req.ClaimType)
 	}
 
 	// Prepare request payload
@@ -210,130 +475,696 @@ func (s *DPConnectorService) VerifyWithDP(ctx context.Context, req *models.Priva
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	ctx = withExchangeScope(ctx, req.RPID, req.ClaimType)
 
-	// Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", s.config.DPConnectorURL+"/verify", strings.NewReader(string(payload)))
+	// A tenant holds one slot for the whole verification, failover included
+	release, err := s.acquireTenantBulkhead(ctx, req.RPID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		return nil, err
 	}
+	defer release()
 
-	httpReq.Header.Set("Content-Type", "application/json")
+	start := time.Now()
+	var response *DPResponse
+	if rule, required := s.consensusRule(req.ClaimType); required {
+		response, err = s.verifyConsensus(ctx, providers, payload, rule)
+	} else if len(providers) > 1 && s.hedgingEnabled(req.ClaimType) {
+		response, err = s.verifyHedged(ctx, providers, payload)
+	} else {
+		response, err = s.verifyFailover(ctx, providers, payload)
+	}
+	if err != nil {
+		return nil, err
+	}
+	scoreResponse(response)
 
-	// Add authentication
-	if err := s.authenticator.AuthenticateRequest(httpReq); err != nil {
-		return nil, fmt.Errorf("failed to authenticate request: %w", err)
+	provider, _ := s.registry.Get(response.DPID)
+	if response.Claim, err = s.claims.Normalize(req.ClaimType, provider, response); err != nil {
+		return nil, err
+	}
+
+	s.compareWithCandidate(ctx, req.ClaimType, payload, response, time.Since(start))
+	return response, nil
+}
+
+// verifyFailover tries providers in priority order until one succeeds
+func (s *DPConnectorService) verifyFailover(ctx context.Context, providers []*DPProvider, payload []byte) (*DPResponse, error) {
+	var lastErr error
+	for _, provider := range providers {
+		response, err := s.attemptProvider(ctx, provider, payload)
+		if err != nil {
+			lastErr = err
+
+			// Don't fail over once the caller has given up
+			if ctx.Err() != nil {
+				return nil, lastErr
+			}
+			continue
+		}
+		return response, nil
+	}
+
+	return nil, lastErr
+}
+
+// attemptProvider verifies with a single provider, updating its circuit
+// breaker and latency samples
+func (s *DPConnectorService) attemptProvider(ctx context.Context, provider *DPProvider, payload []byte) (*DPResponse, error) {
+	breaker := s.providerBreaker(provider.DPID)
+	attempt := VerificationTraceFrom(ctx).startAttempt(provider.DPID, breaker)
+
+	// A full bulkhead is a local limit, not a DP failure, so it is checked
+	// before the breaker and never recorded against it
+	release, err := s.acquireDPBulkhead(ctx, provider)
+	if err != nil {
+		attempt.finish(DPAttemptBulkheadFull, nil, err)
+		return nil, err
+	}
+	defer release()
+
+	// So is the host's adaptive limit. Only responses and DP timeouts are
+	// samples of the host's latency, each HTTP attempt's response on its
+	// own, so retries and their backoff are not counted as latency.
+	releaseLimit, err := s.acquireDPConcurrencyLimit(provider)
+	if err != nil {
+		attempt.finish(DPAttemptConcurrencyLimited, nil, err)
+		return nil, err
+	}
+	sample := limitSampleIgnore
+	defer func() { releaseLimit(sample, 0) }()
+	ctx = withLimitObserver(ctx, s.dpLimiters.observer(dpHost(provider)))
+	callerCtx := ctx
+
+	// Check circuit breaker state
+	if !breaker.Acquire() {
+		err := fmt.Errorf("%w, DP %s is unavailable", ErrCircuitOpen, provider.DPID)
+		attempt.finish(DPAttemptBreakerOpen, nil, err)
+		return nil, err
+	}
+
+	// The DP timeout is a runtime setting, so it is applied per attempt
+	// rather than fixed in the HTTP clients
+	if timeout := time.Duration(s.config.RuntimeSettings().DPTimeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	response, err := s.verifyWithProvider(ctx, provider, payload)
+	if err != nil {
+		attempt.finish(attemptOutcome(ctx), nil, err)
+		// A request cancelled because a hedge won says nothing about the DP
+		if errors.Is(context.Cause(ctx), errHedgeCancelled) {
+			breaker.Release()
+			return nil, err
+		}
+		// Nor does one its caller gave up on: a short X-Request-Timeout
+		// from one RP must not open the breaker for every tenant
+		if callerCtx.Err() != nil {
+			breaker.Release()
+			return nil, fmt.Errorf("DP verification failed: %w", err)
+		}
+		s.recordTLSEvent(provider.DPID, err)
+		if DPProblemCode(err) == models.ProblemDPTimeout {
+			sample = limitSampleDropped
+		}
+		if provider.FailureClassifier.CountsAsFailure(err) {
+			breaker.RecordFailureWithError(err)
+		} else {
+			breaker.Release()
+		}
+		return nil, fmt.Errorf("DP verification failed: %w", err)
+	}
+
+	breaker.RecordSuccess()
+	sample = limitSampleSuccess
+	s.latencies.Record(provider.DPID, time.Since(start))
+	response.DPID = provider.DPID
+
+	// A response breaking its claim type's schema fails over like an error,
+	// without counting against a DP that answered
+	if err := s.validateResponse(ctx, response); err != nil {
+		attempt.finish(DPAttemptInvalidResponse, response, err)
+		return nil, fmt.Errorf("DP verification failed: %w", err)
+	}
+	attempt.finish(DPAttemptSuccess, response, nil)
+	return response, nil
+}
+
+// verifyWithProvider sends the request to a single provider using its
+// adapter and request template, following REST providers' result pages.
+// Requests over the provider's payload limit, or rejected by it as too
+// large, are split by identifier.
+func (s *DPConnectorService) verifyWithProvider(ctx context.Context, provider *DPProvider, payload []byte) (*DPResponse, error) {
+	if provider.MatchingMode == MatchingModePSI {
+		return s.verifyWithPSI(ctx, provider, payload)
+	}
+
+	if limit := s.maxPayloadBytes(ctx, provider); limit > 0 && len(payload) > limit {
+		return s.verifyOversized(ctx, provider, payload, limit)
+	}
+	response, err := s.verifyPayload(ctx, provider, payload, dpValidatorsFor(ctx, provider.DPID))
+	if isPayloadTooLarge(err) {
+		return s.verifyRejected(ctx, provider, payload, err)
+	}
+	return response, err
+}
+
+// verifyPayload sends one request to a provider
+func (s *DPConnectorService) verifyPayload(ctx context.Context, provider *DPProvider, payload []byte, validators *DPValidators) (*DPResponse, error) {
+	rendered, err := provider.renderRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+	if provider.AdapterType != AdapterTypeREST {
+		return s.verifyWithAdapter(ctx, provider, rendered)
 	}
 
-	// Execute request with retry logic
+	response, err := s.sendVerifyRequest(ctx, provider, rendered, validators)
+	if err != nil {
+		return nil, err
+	}
+	if response.Status == DPStatusNotModified {
+		return response, nil
+	}
+	return s.collectPages(ctx, provider, payload, response)
+}
+
+// sendVerifyRequest posts one request to a REST provider. With validators the
+// request is conditional, and a 304 yields a not_modified response.
+func (s *DPConnectorService) sendVerifyRequest(ctx context.Context, provider *DPProvider, payload []byte, validators *DPValidators) (*DPResponse, error) {
 	var response *DPResponse
-	err = s.executeWithRetry(ctx, httpReq, func(resp *http.Response) error {
+	err := s.postToProvider(ctx, provider, "/verify", payload, validators.conditionalHeaders(), func(resp *http.Response) error {
+		if resp.StatusCode == http.StatusNotModified && validators != nil {
+			response = notModifiedResponse(validators, resp)
+			return nil
+		}
 		var err error
-		response, err = s.parseDPResponse(resp)
+		response, err = s.parseDPResponse(provider, resp)
+		if err == nil {
+			response.Validators = responseValidators(provider.DPID, resp)
+		}
 		return err
 	})
-
 	if err != nil {
-		s.circuitBreaker.RecordFailure()
-		return nil, fmt.Errorf("DP verification failed: %w", err)
+		return nil, err
 	}
 
-	s.circuitBreaker.RecordSuccess()
 	return response, nil
 }
 
-// executeWithRetry executes a request with exponential backoff retry
-func (s *DPConnectorService) executeWithRetry(ctx context.Context, req *http.Request, handler func(*http.Response) error) error {
+// postToProvider posts a body to a path under the provider's endpoint with
+// its authentication and the connector's retry policy
+func (s *DPConnectorService) postToProvider(ctx context.Context, provider *DPProvider, path string, payload []byte, header http.Header, handler func(*http.Response) error) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(provider.Endpoint, "/")+path, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	for name, values := range header {
+		httpReq.Header[name] = values
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept-Encoding", dpAcceptEncoding)
+	SetRequestTimeout(ctx, httpReq.Header)
+
+	authenticator := s.providerAuthenticator(provider)
+	if err := authenticator.AuthenticateRequest(httpReq); err != nil {
+		return fmt.Errorf("failed to authenticate request: %w", err)
+	}
+
+	client, err := s.providerClient(provider)
+	if err != nil {
+		return err
+	}
+	return s.executeWithRetry(ctx, client, httpReq, authenticator, handler)
+}
+
+// verifyWithAdapter sends the request through a non-REST integration adapter
+func (s *DPConnectorService) verifyWithAdapter(ctx context.Context, provider *DPProvider, payload []byte) (*DPResponse, error) {
+	adapter := AdapterFactory(provider.AdapterType)
+	defer adapter.Close()
+
+	adapterConfig := &AdapterConfig{
+		URL:         provider.Endpoint,
+		Timeout:     s.client.Timeout,
+		AuthConfig:  provider.AuthenticationConfig(),
+		AdapterType: provider.AdapterType,
+	}
+	if err := adapter.Connect(ctx, adapterConfig); err != nil {
+		return nil, err
+	}
+
+	var request map[string]interface{}
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return nil, fmt.Errorf("failed to prepare adapter request: %w", err)
+	}
+
+	sent := time.Now()
+	result, err := adapter.SendRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	observeLimitLatency(ctx, time.Since(sent))
+
+	// Adapters return generic maps; round-trip them into a DPResponse
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode adapter response: %w", err)
+	}
+
+	var response DPResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode adapter response: %w", err)
+	}
+	s.schemaDrift.Check(provider, data)
+	if err := s.statusQuarantine.Check(provider, &response); err != nil {
+		return nil, err
+	}
+	if err := applyPartialResults(provider, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// providerBreaker returns the circuit breaker for a provider, configured
+// with the provider's current breaker settings
+func (s *DPConnectorService) providerBreaker(dpID string) *CircuitBreaker {
+	var settings *DPBreakerSettings
+	if provider, exists := s.registry.Get(dpID); exists {
+		settings = provider.Breaker
+	}
+
+	s.providerMu.Lock()
+	breaker, exists := s.providerBreakers[dpID]
+	if !exists {
+		breaker = &CircuitBreaker{
+			state:        CircuitClosed,
+			threshold:    s.circuitBreaker.threshold,
+			timeout:      s.circuitBreaker.timeout,
+			dpID:         dpID,
+			onTransition: s.recordBreakerEvent,
+		}
+		s.providerBreakers[dpID] = breaker
+	}
+	s.providerMu.Unlock()
+
+	breaker.configure(settings)
+	return breaker
+}
+
+// ClaimCircuitOpen reports whether the breaker of one of a claim type's
+// providers is open or half-open, i.e. whether the claim type is in an
+// outage, whatever error its last call failed with
+func (s *DPConnectorService) ClaimCircuitOpen(claimType string) bool {
+	for _, provider := range s.registry.ProvidersForClaim(claimType) {
+		if s.providerBreaker(provider.DPID).State() != CircuitClosed {
+			return true
+		}
+	}
+	return false
+}
+
+// providerAuthenticator returns the authenticator for a provider
+func (s *DPConnectorService) providerAuthenticator(provider *DPProvider) *Authenticator {
+	s.providerMu.Lock()
+	defer s.providerMu.Unlock()
+
+	authenticator, exists := s.providerAuthenticators[provider.DPID]
+	if !exists {
+		// Rotated secrets are picked up by building the authenticator again
+		authConfig := provider.AuthenticationConfig()
+		dpID := provider.DPID
+		resolveAuthSecrets(s.config.Secrets, fmt.Sprintf("dp:%p:%s", s, dpID), authConfig, func() {
+			s.dropAuthenticator(dpID)
+		})
+		authenticator = NewAuthenticator(authConfig)
+		if authenticator.exchanger != nil {
+			authenticator.exchanger.onExchange = s.auditTokenExchange
+		}
+		if authenticator.config.DPoP {
+			authenticator.setDPoPKey(s.dpopKey())
+		}
+		s.providerAuthenticators[provider.DPID] = authenticator
+	}
+	return authenticator
+}
+
+// dropAuthenticator discards a provider's authenticator, and the tokens it
+// holds, so the next request builds one with current secrets
+func (s *DPConnectorService) dropAuthenticator(dpID string) {
+	s.providerMu.Lock()
+	defer s.providerMu.Unlock()
+	delete(s.providerAuthenticators, dpID)
+}
+
+// providerClient returns the HTTP client for a provider, applying its TLS
+// settings on top of the shared transport
+func (s *DPConnectorService) providerClient(provider *DPProvider) (*http.Client, error) {
+	if provider.TLS == nil {
+		return s.client, nil
+	}
+
+	s.providerMu.Lock()
+	defer s.providerMu.Unlock()
+
+	if client, exists := s.providerClients[provider.DPID]; exists {
+		return client, nil
+	}
+
+	tlsConfig, err := provider.TLS.TLSConfig(ActiveCryptoProfile(s.config))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS settings for DP %s: %w", provider.DPID, err)
+	}
+
+	transport, ok := s.client.Transport.(*http.Transport)
+	if !ok {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+	transport = transport.Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	client := &http.Client{
+		Timeout:   s.client.Timeout,
+		Transport: transport,
+	}
+	s.providerClients[provider.DPID] = client
+	return client, nil
+}
+
+// dpopKey loads the broker's DPoP key once
+func (s *DPConnectorService) dpopKey() (*DPoPKey, error) {
+	s.dpopOnce.Do(func() {
+		s.dpopKeyValue, s.dpopKeyErr = LoadDPoPKey(s.config)
+		if s.dpopKeyErr != nil {
+			fmt.Printf("DPOP WARNING: %v; requests to DPs requiring DPoP will fail\n", s.dpopKeyErr)
+		}
+	})
+	return s.dpopKeyValue, s.dpopKeyErr
+}
+
+// SetAuditService records token exchanges in the audit trail
+func (s *DPConnectorService) SetAuditService(auditService *AuditService) {
+	s.providerMu.Lock()
+	defer s.providerMu.Unlock()
+	s.auditService = auditService
+}
+
+// auditTokenExchange records who a downstream token was issued for
+func (s *DPConnectorService) auditTokenExchange(ctx context.Context, record *TokenExchangeRecord) {
+	s.providerMu.Lock()
+	auditService := s.auditService
+	s.providerMu.Unlock()
+	if auditService != nil {
+		auditService.LogTokenExchange(ctx, record)
+	}
+}
+
+// maxTLSEvents bounds the recent TLS events kept for stats
+const maxTLSEvents = 100
+
+// SetTLSEventHandler registers a callback for pinning and OCSP failures
+func (s *DPConnectorService) SetTLSEventHandler(handler func(TLSEvent)) {
+	s.tlsEventMu.Lock()
+	defer s.tlsEventMu.Unlock()
+	s.tlsEventHandler = handler
+}
+
+// recordTLSEvent raises an event when a provider error is a pin or OCSP failure
+func (s *DPConnectorService) recordTLSEvent(dpID string, err error) {
+	eventType := classifyTLSEvent(err)
+	if eventType == "" {
+		return
+	}
+
+	event := TLSEvent{
+		Type:      eventType,
+		DPID:      dpID,
+		Error:     err.Error(),
+		Timestamp: time.Now(),
+	}
+
+	s.tlsEventMu.Lock()
+	s.tlsEventCounts[eventType]++
+	s.tlsEvents = append(s.tlsEvents, event)
+	if len(s.tlsEvents) > maxTLSEvents {
+		s.tlsEvents = s.tlsEvents[len(s.tlsEvents)-maxTLSEvents:]
+	}
+	handler := s.tlsEventHandler
+	s.tlsEventMu.Unlock()
+
+	fmt.Printf("DP TLS WARNING: %s for %s: %v\n", eventType, dpID, err)
+	if handler != nil {
+		handler(event)
+	}
+}
+
+// GetTLSEvents returns recent pinning and OCSP failures with totals by type
+func (s *DPConnectorService) GetTLSEvents() ([]TLSEvent, map[string]int64) {
+	s.tlsEventMu.Lock()
+	defer s.tlsEventMu.Unlock()
+
+	events := append([]TLSEvent(nil), s.tlsEvents...)
+	counts := make(map[string]int64, len(s.tlsEventCounts))
+	for eventType, count := range s.tlsEventCounts {
+		counts[eventType] = count
+	}
+	return events, counts
+}
+
+// SetBreakerEventHandler registers a callback for circuit breaker
+// transitions, replacing any webhook notifier from configuration
+func (s *DPConnectorService) SetBreakerEventHandler(handler func(CircuitBreakerEvent)) {
+	s.breakerEventMu.Lock()
+	defer s.breakerEventMu.Unlock()
+	s.breakerEventHandler = handler
+}
+
+// recordBreakerEvent keeps a breaker transition and passes it on
+func (s *DPConnectorService) recordBreakerEvent(event CircuitBreakerEvent) {
+	s.breakerEventMu.Lock()
+	s.breakerEvents = append(s.breakerEvents, event)
+	if len(s.breakerEvents) > maxBreakerEvents {
+		s.breakerEvents = s.breakerEvents[len(s.breakerEvents)-maxBreakerEvents:]
+	}
+	handler := s.breakerEventHandler
+	s.breakerEventMu.Unlock()
+
+	if event.To == CircuitOpen {
+		fmt.Printf("DP BREAKER WARNING: circuit for %s opened: %s\n", event.DPID, event.Summary)
+	}
+	if handler != nil {
+		handler(event)
+	}
+}
+
+// GetBreakerEvents returns recent circuit breaker transitions
+func (s *DPConnectorService) GetBreakerEvents() []CircuitBreakerEvent {
+	s.breakerEventMu.Lock()
+	defer s.breakerEventMu.Unlock()
+	return append([]CircuitBreakerEvent(nil), s.breakerEvents...)
+}
+
+// executeWithRetry executes a request with jittered exponential backoff,
+// honoring Retry-After and the retry budget. Each attempt after the first
+// is a copy of req with a fresh body from GetBody, and each response body
+// is drained and closed before the next attempt.
+func (s *DPConnectorService) executeWithRetry(ctx context.Context, client *http.Client, req *http.Request, auth *Authenticator, handler func(*http.Response) error) error {
 	var lastErr error
+	if err := bufferRequestBody(req); err != nil {
+		return err
+	}
+
+	retryConfig := s.currentRetryConfig()
+	if retryConfig.Budget != nil {
+		retryConfig.Budget.RecordRequest()
+	}
+
+	sent := 0
+	challenged := false
+	for attempt := 0; attempt <= retryConfig.MaxRetries; attempt++ {
+		attemptReq, err := rewindRequest(req, sent)
+		if err != nil {
+			return fmt.Errorf("%v: %w", err, lastErr)
+		}
+		// Each attempt tells the DP how much of the deadline is left
+		if sent > 0 {
+			SetRequestTimeout(ctx, attemptReq.Header)
+		}
+		// Signatures and DPoP proofs carry a timestamp and nonce, so every
+		// request after the first is authenticated again
+		if sent > 0 && auth != nil && auth.SignsRequests() {
+			if err := auth.AuthenticateRequest(attemptReq); err != nil {
+				return fmt.Errorf("failed to authenticate retry: %w", err)
+			}
+		}
 
-	for attempt := 0; attempt <= s.retryConfig.MaxRetries; attempt++ {
 		// Execute request
-		resp, err := s.client.Do(req)
+		var retryAfter time.Duration
+		var hasRetryAfter bool
+		attemptStart := time.Now()
+		resp, err := client.Do(attemptReq)
+		sent++
+		if err == nil {
+			observeLimitLatency(ctx, time.Since(attemptStart))
+		}
+		if err == nil && !challenged && auth.HandleChallenge(resp) {
+			// The DP wants a proof with its nonce; answering is not a retry
+			challenged = true
+			closeResponseBody(resp)
+			attempt--
+			continue
+		}
 		if err != nil {
 			lastErr = fmt.Errorf("request failed: %w", err)
 
-			// If this is the last attempt, return the error
-			if attempt == s.retryConfig.MaxRetries {
+			// Pin and OCSP failures will not resolve on retry
+			if classifyTLSEvent(err) != "" {
 				return lastErr
 			}
+		} else if resp.StatusCode >= 500 || resp.StatusCode == 429 {
+			// Check if response indicates retry is needed
+			lastErr = &DPStatusError{StatusCode: resp.StatusCode}
+			retryAfter, hasRetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+
+			// Drain so the connection can be reused by the next attempt
+			closeResponseBody(resp)
+		} else {
+			// Handle successful response
+			err := handler(resp)
+			closeResponseBody(resp)
+			return err
+		}
 
-			// Calculate delay for next attempt
-			delay := s.calculateDelay(attempt)
+		// If this is the last attempt, return the error
+		if attempt == retryConfig.MaxRetries {
+			return lastErr
+		}
 
-			// Wait before retry
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(delay):
-				continue
+		// Calculate delay for next attempt; the DP's Retry-After wins over
+		// backoff, and we give up rather than wait past MaxDelay
+		delay := calculateDelay(retryConfig, attempt)
+		if hasRetryAfter {
+			if retryAfter > retryConfig.MaxDelay {
+				return fmt.Errorf("%w (Retry-After %s exceeds max delay)", lastErr, retryAfter)
 			}
+			delay = retryAfter
 		}
-		defer resp.Body.Close()
 
-		// Check if response indicates retry is needed
-		if resp.StatusCode >= 500 || resp.StatusCode == 429 {
-			lastErr = fmt.Errorf("server error, status: %d", resp.StatusCode)
-
-			if attempt == s.retryConfig.MaxRetries {
-				return lastErr
-			}
+		// A retry the request's deadline would cut short is not started
+		if err := checkRetryBudget(ctx, delay, lastErr); err != nil {
+			return err
+		}
 
-			delay := s.calculateDelay(attempt)
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(delay):
-				continue
-			}
+		if retryConfig.Budget != nil && !retryConfig.Budget.TryRetry() {
+			return fmt.Errorf("%w (retry budget exhausted)", lastErr)
 		}
 
-		// Handle successful response
-		return handler(resp)
+		// Wait before retry
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
 	}
 
 	return lastErr
 }
 
-// calculateDelay calculates the delay for exponential backoff
-func (s *DPConnectorService) calculateDelay(attempt int) time.Duration {
-	delay := time.Duration(float64(s.retryConfig.BaseDelay) * math.Pow(s.retryConfig.BackoffMultiplier, float64(attempt)))
-	if delay > s.retryConfig.MaxDelay {
-		delay = s.retryConfig.MaxDelay
+// calculateDelay calculates the delay for exponential backoff, with full
+// jitter when enabled
+func calculateDelay(retryConfig *RetryConfig, attempt int) time.Duration {
+	delay := time.Duration(float64(retryConfig.BaseDelay) * math.Pow(retryConfig.BackoffMultiplier, float64(attempt)))
+	if delay > retryConfig.MaxDelay {
+		delay = retryConfig.MaxDelay
+	}
+	if retryConfig.Jitter {
+		delay = fullJitter(delay)
 	}
 	return delay
 }
 
-// parseDPResponse parses the response from the DP Connector
-func (s *DPConnectorService) parseDPResponse(resp *http.Response) (*DPResponse, error) {
+// currentRetryConfig returns the retry policy in effect, taking runtime
+// settings over the built-in one; the retry budget is always shared
+func (s *DPConnectorService) currentRetryConfig() *RetryConfig {
+	retry := s.config.RuntimeSettings().Retry
+	if retry == nil {
+		return s.retryConfig
+	}
+	return &RetryConfig{
+		MaxRetries:        retry.MaxRetries,
+		BaseDelay:         time.Duration(retry.BaseDelay),
+		MaxDelay:          time.Duration(retry.MaxDelay),
+		BackoffMultiplier: retry.BackoffMultiplier,
+		Jitter:            retry.Jitter,
+		Budget:            s.retryConfig.Budget,
+	}
+}
+
+// parseDPResponse parses the response from the DP Connector, mapping it
+// with the provider's response pipeline, calibrating its confidence and
+// checking its shape against the provider's registered schema
+func (s *DPConnectorService) parseDPResponse(provider *DPProvider, resp *http.Response) (*DPResponse, error) {
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("DP connector returned status %d: %s", resp.StatusCode, string(body))
+		body, _ := s.readDPResponseBody(provider, resp)
+		return nil, &DPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
+	body, err := s.readDPResponseBody(provider, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	mapped, err := s.applyResponsePipeline(provider, body)
+	if err != nil {
+		return nil, err
+	}
+	mapped, scoring, err := calibrateConfidence(provider, mapped)
+	if err != nil {
+		return nil, err
+	}
 	var dpResp DPResponse
-	if err := json.NewDecoder(resp.Body).Decode(&dpResp); err != nil {
+	if err := json.Unmarshal(mapped, &dpResp); err != nil {
 		return nil, fmt.Errorf("failed to decode DP response: %w", err)
 	}
+	dpResp.Scoring = scoring
+	// Drift is checked, and claims are mapped, against the provider's own
+	// response shape
+	dpResp.body = body
+	s.schemaDrift.Check(provider, body)
+	if err := s.statusQuarantine.Check(provider, &dpResp); err != nil {
+		return nil, err
+	}
+	if err := applyPartialResults(provider, &dpResp); err != nil {
+		return nil, err
+	}
 
 	return &dpResp, nil
 }
 
-// GetConnection returns a connection from the pool
+// GetConnection returns a connection from the pool, evicting the least
+// recently used client when the pool is full
 func (p *ConnectionPool) GetConnection(host string) *http.Client {
-	p.mu.RLock()
-	if client, exists := p.clients[host]; exists {
-		p.mu.RUnlock()
-		return client
-	}
-	p.mu.RUnlock()
-
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	// Double-check after acquiring write lock
+	if p.lastUsed == nil {
+		p.lastUsed = make(map[string]time.Time)
+	}
+
 	if client, exists := p.clients[host]; exists {
+		p.lastUsed[host] = time.Now()
 		return client
 	}
 
+	if p.maxIdle > 0 {
+		for len(p.clients) >= p.maxIdle {
+			p.evictLocked(p.leastRecentlyUsedLocked())
+			p.lruEvictions++
+		}
+	}
+
 	// Create new client for this host with enhanced timeout configuration
 	timeoutConfig := p.getTimeoutConfig()
 	client := &http.Client{
@@ -351,6 +1182,7 @@ func (p *ConnectionPool) GetConnection(host string) *http.Client {
 	}
 
 	p.clients[host] = client
+	p.lastUsed[host] = time.Now()
 
 	// Initialize health check for this connection
 	if p.healthChecks == nil {
@@ -361,9 +1193,110 @@ func (p *ConnectionPool) GetConnection(host string) *http.Client {
 		IsHealthy: true,
 	}
 
+	p.startJanitor()
+
 	return client
 }
 
+// leastRecentlyUsedLocked returns the host whose client was used longest ago
+// (caller must hold the write lock)
+func (p *ConnectionPool) leastRecentlyUsedLocked() string {
+	var oldestHost string
+	var oldest time.Time
+	for host := range p.clients {
+		used := p.lastUsed[host]
+		if oldestHost == "" || used.Before(oldest) {
+			oldestHost = host
+			oldest = used
+		}
+	}
+	return oldestHost
+}
+
+// evictLocked removes a client and closes its idle connections (caller must
+// hold the write lock)
+func (p *ConnectionPool) evictLocked(host string) {
+	if client, exists := p.clients[host]; exists {
+		client.CloseIdleConnections()
+	}
+	delete(p.clients, host)
+	delete(p.lastUsed, host)
+	delete(p.healthChecks, host)
+}
+
+// EvictIdle removes clients unused for longer than idleTime and returns how
+// many were evicted
+func (p *ConnectionPool) EvictIdle(now time.Time) int {
+	if p.idleTime <= 0 {
+		return 0
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	evicted := 0
+	for host := range p.clients {
+		if now.Sub(p.lastUsed[host]) > p.idleTime {
+			p.evictLocked(host)
+			evicted++
+		}
+	}
+	p.idleEvictions += int64(evicted)
+	return evicted
+}
+
+// startJanitor launches the idle cleanup goroutine once (caller must hold the
+// write lock)
+func (p *ConnectionPool) startJanitor() {
+	if p.idleTime <= 0 {
+		return
+	}
+	p.janitorOnce.Do(func() {
+		p.janitorStop = make(chan struct{})
+		go p.runJanitor(p.janitorStop, p.janitorInterval())
+	})
+}
+
+// janitorInterval checks twice per idle period, but at most once a second
+func (p *ConnectionPool) janitorInterval() time.Duration {
+	interval := p.idleTime / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
+// runJanitor periodically evicts idle clients until stopped
+func (p *ConnectionPool) runJanitor(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			p.EvictIdle(now)
+		}
+	}
+}
+
+// Close stops the janitor and closes all pooled clients
+func (p *ConnectionPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Prevent a janitor from starting after close
+	p.janitorOnce.Do(func() {})
+	if p.janitorStop != nil {
+		close(p.janitorStop)
+		p.janitorStop = nil
+	}
+	for host := range p.clients {
+		p.evictLocked(host)
+	}
+}
+
 // getTimeoutConfig returns the timeout configuration
 func (p *ConnectionPool) getTimeoutConfig() *TimeoutConfig {
 	if p.timeoutConfig == nil {
@@ -380,10 +1313,11 @@ func (p *ConnectionPool) getTimeoutConfig() *TimeoutConfig {
 
 // PerformHealthCheck performs a health check on a connection
 func (p *ConnectionPool) PerformHealthCheck(host string) error {
+	client := p.GetConnection(host)
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	client := p.GetConnection(host)
 	healthCheck, exists := p.healthChecks[host]
 	if !exists {
 		return fmt.Errorf("no health check found for host: %s", host)
@@ -454,11 +1388,17 @@ func (p *ConnectionPool) getConnectionRoundRobin(hosts []string) (*http.Client,
 		return nil, fmt.Errorf("no hosts available")
 	}
 
-	p.loadBalancer.mu.Lock()
-	defer p.loadBalancer.mu.Unlock()
+	if p.loadBalancer == nil {
+		p.loadBalancer = &LoadBalancer{
+			connections: hosts,
+			strategy:    StrategyRoundRobin,
+		}
+	}
 
+	p.loadBalancer.mu.Lock()
 	host := hosts[p.loadBalancer.currentIndex%len(hosts)]
 	p.loadBalancer.currentIndex++
+	p.loadBalancer.mu.Unlock()
 
 	return p.GetConnection(host), nil
 }
@@ -468,6 +1408,7 @@ func (p *ConnectionPool) getConnectionHealthCheck(hosts []string) (*http.Client,
 	var healthiestHost string
 	var bestResponseTime time.Duration = time.Hour // Start with a very high value
 
+	p.mu.RLock()
 	for _, host := range hosts {
 		healthCheck, exists := p.healthChecks[host]
 		if !exists || !healthCheck.IsHealthy {
@@ -479,6 +1420,7 @@ func (p *ConnectionPool) getConnectionHealthCheck(hosts []string) (*http.Client,
 			healthiestHost = host
 		}
 	}
+	p.mu.RUnlock()
 
 	if healthiestHost == "" {
 		return nil, fmt.Errorf("no healthy hosts available")
@@ -499,6 +1441,11 @@ func (p *ConnectionPool) GetConnectionHealthStats() map[string]interface{} {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
+	return p.connectionHealthStatsLocked()
+}
+
+// connectionHealthStatsLocked builds health statistics (caller must hold the lock)
+func (p *ConnectionPool) connectionHealthStatsLocked() map[string]interface{} {
 	stats := make(map[string]interface{})
 	for host, healthCheck := range p.healthChecks {
 		stats[host] = map[string]interface{}{
@@ -513,66 +1460,187 @@ func (p *ConnectionPool) GetConnectionHealthStats() map[string]interface{} {
 	return stats
 }
 
-// CanExecute checks if the circuit breaker allows execution
+// CanExecute checks if the circuit breaker allows execution, without
+// taking a half-open probe slot; calls to the DP use Acquire
 func (cb *CircuitBreaker) CanExecute() bool {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
+	defer cb.emit()
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
 
 	switch cb.state {
 	case CircuitClosed:
 		return true
-	case CircuitOpen:
+	case CircuitHalf:
+		return cb.probeSlotFreeLocked()
+	default:
 		// Check if timeout has passed
 		if time.Since(cb.lastFailureTime) > cb.timeout {
-			cb.mu.RUnlock()
-			cb.mu.Lock()
-			cb.state = CircuitHalf
-			cb.mu.Unlock()
-			cb.mu.RLock()
+			cb.transitionLocked(CircuitHalf)
 			return true
 		}
 		return false
-	case CircuitHalf:
+	}
+}
+
+// Acquire reports whether a call may go to the DP. A half-open breaker only
+// lets HalfOpenMaxProbes calls through at once, so a recovering DP is not
+// flooded; a call Acquire allows ends with RecordSuccess, RecordFailure or,
+// when its outcome says nothing about the DP, Release.
+func (cb *CircuitBreaker) Acquire() bool {
+	defer cb.emit()
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitOpen && time.Since(cb.lastFailureTime) > cb.timeout {
+		cb.transitionLocked(CircuitHalf)
+	}
+	switch {
+	case cb.state == CircuitClosed:
 		return true
-	default:
-		return false
+	case cb.state == CircuitHalf && cb.probeSlotFreeLocked():
+		if cb.probes >= cb.settings.halfOpenMaxProbes() {
+			// The probes outlived the breaker timeout; their outcome was lost
+			cb.probes = 0
+		}
+		cb.probes++
+		cb.probeStarted = time.Now()
+		return true
+	}
+	return false
+}
+
+// Release gives back the probe slot of a call whose outcome is not recorded
+func (cb *CircuitBreaker) Release() {
+	cb.mu.Lock()
+	cb.releaseProbeLocked()
+	cb.mu.Unlock()
+}
+
+// probeSlotFreeLocked reports whether a half-open breaker can take another
+// probe. Slots held past the breaker timeout are taken to be lost, so a
+// caller that never reports its outcome cannot hold the breaker half-open.
+func (cb *CircuitBreaker) probeSlotFreeLocked() bool {
+	return cb.probes < cb.settings.halfOpenMaxProbes() || time.Since(cb.probeStarted) > cb.timeout
+}
+
+func (cb *CircuitBreaker) releaseProbeLocked() {
+	if cb.state == CircuitHalf && cb.probes > 0 {
+		cb.probes--
 	}
 }
 
 // RecordFailure records a failure in the circuit breaker
 func (cb *CircuitBreaker) RecordFailure() {
+	cb.RecordFailureWithError(nil)
+}
+
+// RecordFailureWithError records a failure, keeping the error as a sample for
+// transition events
+func (cb *CircuitBreaker) RecordFailureWithError(err error) {
+	defer cb.emit()
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
 	cb.failureCount++
 	cb.lastFailureTime = time.Now()
+	if err != nil {
+		cb.recentErrors = append(cb.recentErrors, err.Error())
+		if len(cb.recentErrors) > maxBreakerErrorSamples {
+			cb.recentErrors = cb.recentErrors[len(cb.recentErrors)-maxBreakerErrorSamples:]
+		}
+	}
 
-	if cb.failureCount >= cb.threshold {
-		cb.state = CircuitOpen
+	if cb.window != nil {
+		cb.window.record(true)
+	}
+	// A failed probe reopens the breaker straight away
+	switch {
+	case cb.state == CircuitHalf:
+		cb.transitionLocked(CircuitOpen)
+	case cb.window != nil:
+		if cb.window.tripped() {
+			cb.transitionLocked(CircuitOpen)
+		}
+	case cb.failureCount >= cb.threshold:
+		cb.transitionLocked(CircuitOpen)
 	}
 }
 
-// RecordSuccess records a success in the circuit breaker
+// RecordSuccess records a success in the circuit breaker. Calls that
+// started before the breaker opened and succeed once it is open do not
+// close it; only probes do.
 func (cb *CircuitBreaker) RecordSuccess() {
+	defer cb.emit()
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
+	switch cb.state {
+	case CircuitOpen:
+		return
+	case CircuitHalf:
+		// A half-open breaker closes after HalfOpenSuccesses probes in a row
+		cb.releaseProbeLocked()
+		cb.probeSuccesses++
+		if cb.probeSuccesses < cb.settings.halfOpenSuccesses() {
+			return
+		}
+	}
 	cb.failureCount = 0
-	cb.state = CircuitClosed
+	if cb.window != nil {
+		if cb.state == CircuitClosed {
+			cb.window.record(false)
+		} else {
+			cb.window.reset()
+		}
+	}
+	cb.transitionLocked(CircuitClosed)
+	cb.recentErrors = nil
 }
 
 // GetCircuitBreakerStats returns circuit breaker statistics
 func (cb *CircuitBreaker) GetCircuitBreakerStats() map[string]interface{} {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
 
-	return map[string]interface{}{
-		"state":         cb.state,
-		"failure_count": cb.failureCount,
-		"threshold":     cb.threshold,
-		"timeout":       cb.timeout.String(),
-		"last_failure":  cb.lastFailureTime.Format(time.RFC3339),
+	stats := map[string]interface{}{
+		"state":                cb.state,
+		"mode":                 BreakerModeConsecutive,
+		"failure_count":        cb.failureCount,
+		"threshold":            cb.threshold,
+		"timeout":              cb.timeout.String(),
+		"last_failure":         cb.lastFailureTime.Format(time.RFC3339),
+		"half_open_max_probes": cb.settings.halfOpenMaxProbes(),
+		"half_open_successes":  cb.settings.halfOpenSuccesses(),
+	}
+	if cb.state == CircuitHalf {
+		stats["probes_in_flight"] = cb.probes
+		stats["probe_successes"] = cb.probeSuccesses
+	}
+	if cb.window != nil {
+		stats["mode"] = BreakerModeErrorRate
+		stats["error_rate"] = cb.window.errorRate()
+		stats["error_rate_threshold"] = cb.window.threshold
+		stats["window_calls"] = cb.window.calls
+		stats["minimum_calls"] = cb.window.minimumCalls
+	}
+	return stats
+}
+
+// configure applies a provider's breaker settings, starting a new window
+// when they change
+func (cb *CircuitBreaker) configure(settings *DPBreakerSettings) {
+	var next DPBreakerSettings
+	if settings != nil {
+		next = *settings
 	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if next == cb.settings {
+		return
+	}
+	cb.settings = next
+	cb.window = next.newOutcomeWindow()
 }
 
 // GetConnectionPoolStats returns connection pool statistics
@@ -593,18 +1661,27 @@ func (p *ConnectionPool) GetConnectionPoolStats() map[string]interface{} {
 		"healthy_connections": healthyCount,
 		"max_idle":            p.maxIdle,
 		"idle_timeout":        p.idleTime.String(),
-		"health_stats":        p.GetConnectionHealthStats(),
+		"evictions":           p.lruEvictions + p.idleEvictions,
+		"lru_evictions":       p.lruEvictions,
+		"idle_evictions":      p.idleEvictions,
+		"health_stats":        p.connectionHealthStatsLocked(),
 	}
 }
 
 // GetRetryStats returns retry configuration statistics
 func (s *DPConnectorService) GetRetryStats() map[string]interface{} {
-	return map[string]interface{}{
-		"max_retries":        s.retryConfig.MaxRetries,
-		"base_delay":         s.retryConfig.BaseDelay.String(),
-		"max_delay":          s.retryConfig.MaxDelay.String(),
-		"backoff_multiplier": s.retryConfig.BackoffMultiplier,
+	retryConfig := s.currentRetryConfig()
+	stats := map[string]interface{}{
+		"max_retries":        retryConfig.MaxRetries,
+		"base_delay":         retryConfig.BaseDelay.String(),
+		"max_delay":          retryConfig.MaxDelay.String(),
+		"backoff_multiplier": retryConfig.BackoffMultiplier,
+		"jitter":             retryConfig.Jitter,
+	}
+	if retryConfig.Budget != nil {
+		stats["budget"] = retryConfig.Budget.GetStats()
 	}
+	return stats
 }
 
 // GetDPStats returns comprehensive DP connector statistics
@@ -624,6 +1701,52 @@ func (s *DPConnectorService) GetDPStats() map[string]interface{} {
 	// Add retry stats
 	stats["retry_stats"] = s.GetRetryStats()
 
+	// Add hedging stats
+	stats["hedging"] = s.GetHedgingStats()
+
+	// Add bulkhead saturation
+	stats["bulkheads"] = s.GetBulkheadStats()
+
+	// Add adaptive concurrency limits
+	stats["concurrency_limits"] = s.GetConcurrencyLimitStats()
+
+	// Add per-provider routing stats
+	providers := make([]map[string]interface{}, 0)
+	for _, provider := range s.registry.List() {
+		providers = append(providers, map[string]interface{}{
+			"dp_id":            provider.DPID,
+			"endpoint":         provider.Endpoint,
+			"supported_claims": provider.SupportedClaims,
+			"priority":         provider.Priority,
+			"adapter_type":     provider.AdapterType,
+			"disabled":         provider.Disabled,
+			"quarantined":      s.schemaDrift.IsQuarantined(provider.DPID),
+			"circuit_breaker":  s.providerBreaker(provider.DPID).GetCircuitBreakerStats(),
+		})
+	}
+	stats["providers"] = providers
+
+	// Add pinning and OCSP failure counts
+	_, tlsEventCounts := s.GetTLSEvents()
+	stats["tls_events"] = tlsEventCounts
+
+	// Add schema drift events and quarantined providers
+	driftEvents, quarantined := s.schemaDrift.GetEvents()
+	stats["schema_drift"] = map[string]interface{}{
+		"events":      len(driftEvents),
+		"quarantined": quarantined,
+	}
+
+	// Add responses quarantined for unknown statuses
+	_, unknownStatuses := s.statusQuarantine.GetEvents()
+	stats["unknown_statuses"] = unknownStatuses
+
+	// Add multi-record results cut short by pagination limits
+	stats["pagination_truncations"] = atomic.LoadInt64(&s.paginationTruncations)
+
+	// Add verifications split to fit DP payload limits
+	stats["payload_splits"] = s.GetPayloadSplitStats()
+
 	return stats
 }
 
@@ -631,7 +1754,7 @@ func (s *DPConnectorService) GetDPStats() map[string]interface{} {
 func (s *DPConnectorService) HealthCheck(ctx context.Context) error {
 	// Check circuit breaker state
 	if !s.circuitBreaker.CanExecute() {
-		return fmt.Errorf("circuit breaker is open")
+		return ErrCircuitOpen
 	}
 
 	// Test connection to DP connector
@@ -659,7 +1782,29 @@ type AuthenticationConfig struct {
 	OAuth2     *OAuth2Config
 	MTLS       *MTLSConfig
 	JWT        *JWTConfig
+	SigV4      *SigV4Config
 	AuthMethod AuthMethod
+	// Credentials are sent with every request in addition to the method's own
+	Credentials []AuthCredential
+	// TokenExchange swaps the RP's inbound token for a DP-scoped token
+	TokenExchange *TokenExchangeConfig
+	// DPoP sender-constrains the method's bearer token with a proof
+	DPoP bool
+}
+
+// Locations of custom credentials
+const (
+	CredentialInHeader = "header"
+	CredentialInQuery  = "query"
+)
+
+// AuthCredential is a credential sent in a named header or query parameter,
+// for providers that do not accept Bearer tokens
+type AuthCredential struct {
+	In     string
+	Name   string
+	Prefix string
+	Value  string
 }
 
 // AuthMethod defines the authentication method
@@ -671,6 +1816,12 @@ const (
 	AuthMethodMTLS   AuthMethod = "mtls"
 	AuthMethodJWT    AuthMethod = "jwt"
 	AuthMethodNone   AuthMethod = "none"
+	// AuthMethodCustom sends only the configured credentials
+	AuthMethodCustom AuthMethod = "custom"
+	// AuthMethodSigV4 signs requests for AWS-hosted providers
+	AuthMethodSigV4 AuthMethod = "sigv4"
+	// AuthMethodTokenExchange exchanges the RP's token (RFC 8693)
+	AuthMethodTokenExchange AuthMethod = "token_exchange"
 )
 
 // OAuth2Config defines OAuth 2.0 configuration
@@ -699,36 +1850,109 @@ type JWTConfig struct {
 
 // Authenticator handles authentication for DP connections
 type Authenticator struct {
-	config *AuthenticationConfig
-	client *http.Client
+	config    *AuthenticationConfig
+	client    *http.Client
+	signer    *SigV4Signer
+	exchanger *TokenExchanger
+	dpop      *DPoPProver
+	dpopErr   error
 }
 
 // NewAuthenticator creates a new authenticator
 func NewAuthenticator(config *AuthenticationConfig) *Authenticator {
-	return &Authenticator{
+	authenticator := &Authenticator{
 		config: config,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
+	if config.SigV4 != nil {
+		authenticator.signer = NewSigV4Signer(config.SigV4)
+	}
+	if config.TokenExchange != nil {
+		authenticator.exchanger = NewTokenExchanger(config.TokenExchange)
+	}
+	return authenticator
+}
+
+// SignsRequests reports whether the authentication covers the request
+// contents and must be redone for each attempt
+func (a *Authenticator) SignsRequests() bool {
+	return a.config.AuthMethod == AuthMethodSigV4 || a.config.DPoP
 }
 
 // AuthenticateRequest adds authentication headers to the request
 func (a *Authenticator) AuthenticateRequest(req *http.Request) error {
+	var err error
 	switch a.config.AuthMethod {
 	case AuthMethodAPIKey:
-		return a.addAPIKeyAuth(req)
+		err = a.addAPIKeyAuth(req)
 	case AuthMethodOAuth2:
-		return a.addOAuth2Auth(req)
+		err = a.addOAuth2Auth(req)
 	case AuthMethodMTLS:
-		return a.addMTLSAuth(req)
+		err = a.addMTLSAuth(req)
 	case AuthMethodJWT:
-		return a.addJWTAuth(req)
+		err = a.addJWTAuth(req)
+	case AuthMethodSigV4:
+		// Custom credentials go first so query parameters are signed
+		if err = a.addCredentials(req); err == nil {
+			err = a.addSigV4Auth(req)
+		}
+		return err
+	case AuthMethodTokenExchange:
+		err = a.addTokenExchangeAuth(req)
+	case AuthMethodCustom:
+		if len(a.config.Credentials) == 0 {
+			err = fmt.Errorf("custom authentication has no credentials")
+		}
 	case AuthMethodNone:
-		return nil
 	default:
-		return fmt.Errorf("unsupported authentication method: %s", a.config.AuthMethod)
+		err = fmt.Errorf("unsupported authentication method: %s", a.config.AuthMethod)
+	}
+	if err != nil {
+		return err
+	}
+	if a.config.DPoP {
+		if err := a.addDPoPProof(req); err != nil {
+			return err
+		}
+	}
+	return a.addCredentials(req)
+}
+
+// addSigV4Auth signs the request with AWS Signature Version 4
+func (a *Authenticator) addSigV4Auth(req *http.Request) error {
+	if a.signer == nil {
+		return fmt.Errorf("SigV4 configuration not provided")
 	}
+	if err := a.signer.Sign(req); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+	return nil
+}
+
+// addCredentials sets each custom credential in its header or query parameter
+func (a *Authenticator) addCredentials(req *http.Request) error {
+	if len(a.config.Credentials) == 0 {
+		return nil
+	}
+
+	query := req.URL.Query()
+	for _, credential := range a.config.Credentials {
+		if credential.Value == "" {
+			return fmt.Errorf("credential %s has no value", credential.Name)
+		}
+		switch credential.In {
+		case CredentialInHeader:
+			req.Header.Set(credential.Name, credential.Prefix+credential.Value)
+		case CredentialInQuery:
+			query.Set(credential.Name, credential.Prefix+credential.Value)
+		default:
+			return fmt.Errorf("unsupported credential location: %s", credential.In)
+		}
+	}
+	req.URL.RawQuery = query.Encode()
+	return nil
 }
 
 // addAPIKeyAuth adds API key authentication
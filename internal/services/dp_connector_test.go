@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -799,6 +800,54 @@ func TestAuthenticator_AuthenticationMethods(t *testing.T) {
 	})
 }
 
+func TestAuthenticator_CustomCredentials(t *testing.T) {
+	auth := NewAuthenticator(&AuthenticationConfig{
+		AuthMethod: AuthMethodCustom,
+		Credentials: []AuthCredential{
+			{In: CredentialInHeader, Name: "X-Tenant-Key", Value: "tenant"},
+			{In: CredentialInHeader, Name: "X-Signature", Prefix: "Sig ", Value: "abc"},
+			{In: CredentialInQuery, Name: "api_key", Value: "a&b"},
+		},
+	})
+	req, _ := http.NewRequest("POST", "https://example.com/verify?version=2", nil)
+	if err := auth.AuthenticateRequest(req); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if req.Header.Get("X-Tenant-Key") != "tenant" || req.Header.Get("X-Signature") != "Sig abc" {
+		t.Errorf("Unexpected headers: %v", req.Header)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Error("Expected no Authorization header for custom auth")
+	}
+	if req.URL.Query().Get("api_key") != "a&b" || req.URL.Query().Get("version") != "2" {
+		t.Errorf("Unexpected query: %s", req.URL.RawQuery)
+	}
+
+	// Credentials are sent alongside the method's own
+	combined := NewAuthenticator(&AuthenticationConfig{
+		AuthMethod:  AuthMethodAPIKey,
+		APIKey:      "key",
+		Credentials: []AuthCredential{{In: CredentialInHeader, Name: "X-Tenant-Key", Value: "tenant"}},
+	})
+	req, _ = http.NewRequest("POST", "https://example.com/verify", nil)
+	if err := combined.AuthenticateRequest(req); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if req.Header.Get("Authorization") != "Bearer key" || req.Header.Get("X-Tenant-Key") != "tenant" {
+		t.Errorf("Expected both credentials, got %v", req.Header)
+	}
+
+	// A credential whose value could not be resolved fails the request
+	missing := NewAuthenticator(&AuthenticationConfig{
+		AuthMethod:  AuthMethodCustom,
+		Credentials: []AuthCredential{{In: CredentialInQuery, Name: "api_key"}},
+	})
+	req, _ = http.NewRequest("POST", "https://example.com/verify", nil)
+	if err := missing.AuthenticateRequest(req); err == nil {
+		t.Error("Expected an empty credential to fail")
+	}
+}
+
 func TestAuthenticator_TokenValidation(t *testing.T) {
 	t.Run("JWT Token Validation", func(t *testing.T) {
 		config := &AuthenticationConfig{
@@ -1281,3 +1330,101 @@ func TestAdapterFunctionalityTests(t *testing.T) {
 		}
 	})
 }
+
+func TestConnectionPool_LRUEviction(t *testing.T) {
+	pool := &ConnectionPool{
+		clients:  make(map[string]*http.Client),
+		maxIdle:  2,
+		idleTime: time.Minute,
+	}
+	defer pool.Close()
+
+	pool.GetConnection("host1")
+	pool.GetConnection("host2")
+	pool.GetConnection("host1") // host2 is now least recently used
+	pool.GetConnection("host3")
+
+	if len(pool.clients) != 2 {
+		t.Fatalf("Expected pool to be capped at 2 clients, got %d", len(pool.clients))
+	}
+	if _, exists := pool.clients["host2"]; exists {
+		t.Error("Expected least recently used host to be evicted")
+	}
+	if _, exists := pool.healthChecks["host2"]; exists {
+		t.Error("Expected health check of evicted host to be removed")
+	}
+
+	stats := pool.GetConnectionPoolStats()
+	if stats["lru_evictions"] != int64(1) || stats["evictions"] != int64(1) {
+		t.Errorf("Expected one LRU eviction, got %v", stats)
+	}
+}
+
+func TestConnectionPool_EvictIdle(t *testing.T) {
+	pool := &ConnectionPool{
+		clients:  make(map[string]*http.Client),
+		maxIdle:  10,
+		idleTime: time.Minute,
+	}
+	defer pool.Close()
+
+	pool.GetConnection("stale")
+	pool.GetConnection("fresh")
+	pool.lastUsed["stale"] = time.Now().Add(-2 * time.Minute)
+
+	if evicted := pool.EvictIdle(time.Now()); evicted != 1 {
+		t.Fatalf("Expected one idle client to be evicted, got %d", evicted)
+	}
+	if _, exists := pool.clients["fresh"]; !exists {
+		t.Error("Expected recently used client to be kept")
+	}
+	if stats := pool.GetConnectionPoolStats(); stats["idle_evictions"] != int64(1) {
+		t.Errorf("Expected one idle eviction, got %v", stats["idle_evictions"])
+	}
+}
+
+func TestConnectionPool_Janitor(t *testing.T) {
+	pool := &ConnectionPool{
+		clients:  make(map[string]*http.Client),
+		maxIdle:  10,
+		idleTime: 10 * time.Millisecond,
+	}
+	defer pool.Close()
+
+	pool.mu.Lock()
+	pool.janitorOnce.Do(func() {
+		pool.janitorStop = make(chan struct{})
+		go pool.runJanitor(pool.janitorStop, 5*time.Millisecond)
+	})
+	pool.mu.Unlock()
+
+	pool.GetConnection("host1")
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		pool.mu.RLock()
+		remaining := len(pool.clients)
+		pool.mu.RUnlock()
+		if remaining == 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("Expected janitor to evict idle client")
+}
+
+func TestDPProblemCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w, DP dp-1 is unavailable", ErrCircuitOpen), models.ProblemCircuitOpen},
+		{fmt.Errorf("request failed: %w", context.DeadlineExceeded), models.ProblemDPTimeout},
+		{fmt.Errorf("DP returned status 500"), ""},
+	}
+	for _, tt := range tests {
+		if got := DPProblemCode(tt.err); got != tt.want {
+			t.Errorf("DPProblemCode(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
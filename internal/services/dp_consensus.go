@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// ConsensusRule requires Required of Providers DPs to agree on a claim
+// type's result. Providers of zero queries every DP registered for it.
+type ConsensusRule struct {
+	Required  int `json:"required"`
+	Providers int `json:"providers,omitempty"`
+}
+
+// ErrConsensusNotReached is returned when too few DPs agree on a result
+type ErrConsensusNotReached struct {
+	Result *models.ConsensusResult
+}
+
+func (e *ErrConsensusNotReached) Error() string {
+	return fmt.Sprintf("DP consensus not reached: %d of %d queried DPs required to agree", e.Result.Required, e.Result.Queried)
+}
+
+// ParseConsensusRules parses claim_type=K/N entries, where K of N DPs must
+// agree; without /N every registered DP is queried
+func ParseConsensusRules(entries []string) (map[string]ConsensusRule, error) {
+	rules := make(map[string]ConsensusRule)
+	for _, entry := range entries {
+		claimType, spec, found := strings.Cut(entry, "=")
+		if !found || claimType == "" {
+			return nil, fmt.Errorf("DP_CONSENSUS_CLAIMS entries must be claim_type=K/N")
+		}
+		required, providers, hasProviders := strings.Cut(spec, "/")
+		var rule ConsensusRule
+		var err error
+		if rule.Required, err = strconv.Atoi(required); err != nil || rule.Required < 1 {
+			return nil, fmt.Errorf("consensus for %s: invalid required count %q", claimType, required)
+		}
+		if hasProviders {
+			if rule.Providers, err = strconv.Atoi(providers); err != nil || rule.Providers < rule.Required {
+				return nil, fmt.Errorf("consensus for %s: provider count %q must be at least %d", claimType, providers, rule.Required)
+			}
+		}
+		rules[claimType] = rule
+	}
+	return rules, nil
+}
+
+// consensusRule returns the consensus rule of a claim type, if it has one
+func (s *DPConnectorService) consensusRule(claimType string) (ConsensusRule, bool) {
+	if rule, exists := s.consensusRules[claimType]; exists {
+		return rule, true
+	}
+	rule, exists := s.consensusRules[AnyClaimType]
+	return rule, exists
+}
+
+// verifyConsensus sends the request to the rule's number of providers in
+// priority order, skipping those whose breaker is open, and returns the
+// result the required number of them agree on. The returned response is the
+// highest-priority agreeing DP's, with the combined confidence, the agreeing
+// DPs' evidence and every queried DP's answer.
+func (s *DPConnectorService) verifyConsensus(ctx context.Context, providers []*DPProvider, payload []byte, rule ConsensusRule) (*DPResponse, error) {
+	selected := make([]*DPProvider, 0, len(providers))
+	for _, provider := range providers {
+		if rule.Providers > 0 && len(selected) == rule.Providers {
+			break
+		}
+		if s.providerBreaker(provider.DPID).CanExecute() {
+			selected = append(selected, provider)
+		}
+	}
+	if len(selected) < rule.Required {
+		return nil, fmt.Errorf("DP consensus requires %d available data providers, %d are available", rule.Required, len(selected))
+	}
+
+	responses := make([]*DPResponse, len(selected))
+	errs := make([]error, len(selected))
+	var wg sync.WaitGroup
+	for i, provider := range selected {
+		wg.Add(1)
+		go func(i int, provider *DPProvider) {
+			defer wg.Done()
+			responses[i], errs[i] = s.attemptProvider(ctx, provider, payload)
+		}(i, provider)
+	}
+	wg.Wait()
+
+	method := s.config.DPConsensusConfidence
+	if !IsConfidenceAggregation(method) {
+		method = ConfidenceAggregationMean
+	}
+	result := &models.ConsensusResult{Required: rule.Required, Queried: len(selected), Method: method}
+	votes := map[bool][]int{}
+	for i, provider := range selected {
+		answer := models.ConsensusProviderResult{DPID: provider.DPID}
+		if errs[i] != nil {
+			answer.Error = errs[i].Error()
+		} else if verification := responses[i].VerificationResult; verification != nil {
+			answer.Verified = verification.Verified
+			answer.Confidence = verification.Confidence
+			answer.Evidence = verification.Evidence
+			votes[answer.Verified] = append(votes[answer.Verified], i)
+		} else {
+			votes[false] = append(votes[false], i)
+		}
+		result.Providers = append(result.Providers, answer)
+	}
+
+	// With a required count of at most half the DPs queried, both outcomes
+	// can reach it; conflicting DPs are no consensus
+	var agreeing []int
+	verified, unverified := len(votes[true]) >= rule.Required, len(votes[false]) >= rule.Required
+	switch {
+	case verified && !unverified:
+		agreeing, result.Verified = votes[true], true
+	case unverified && !verified:
+		agreeing = votes[false]
+	default:
+		result.Agreed = max(len(votes[true]), len(votes[false]))
+		return nil, &ErrConsensusNotReached{Result: result}
+	}
+	result.Agreed = len(agreeing)
+
+	var evidence []string
+	scores := make([]float64, 0, len(agreeing))
+	weights := make([]float64, 0, len(agreeing))
+	calibrated := false
+	for _, i := range agreeing {
+		scores = append(scores, result.Providers[i].Confidence)
+		weights = append(weights, selected[i].Calibration.weight())
+		if scoring := responses[i].Scoring; scoring != nil && scoring.Calibrated {
+			calibrated = true
+		}
+		for _, item := range result.Providers[i].Evidence {
+			if !containsString(evidence, item) {
+				evidence = append(evidence, item)
+			}
+		}
+	}
+	result.Confidence = AggregateConfidence(method, scores, weights)
+
+	response := *responses[agreeing[0]]
+	response.VerificationResult = &VerificationResult{
+		Verified:   result.Verified,
+		Confidence: result.Confidence,
+		Evidence:   evidence,
+		Timestamp:  response.Timestamp,
+	}
+	if primary := responses[agreeing[0]].VerificationResult; primary != nil {
+		response.VerificationResult.Reason = primary.Reason
+		response.VerificationResult.Timestamp = primary.Timestamp
+	}
+	response.Consensus = result
+	response.Scoring = &models.ConfidenceScore{
+		Score:       result.Confidence,
+		Scale:       ConfidenceScaleUnit,
+		Calibrated:  calibrated,
+		Aggregation: method,
+	}
+	return &response, nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// newConsensusTestService registers a DP per result, in priority order; a
+// nil result fails with a 500
+func newConsensusTestService(t *testing.T, cfg *config.Config, results ...*VerificationResult) *DPConnectorService {
+	t.Helper()
+	cfg.DPConnectorURL = "http://localhost:1"
+	service := NewDPConnectorService(cfg)
+	service.retryConfig.MaxRetries = 0
+	service.registry = NewDPRegistry()
+	for i, result := range results {
+		result := result
+		dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if result == nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(DPResponse{JobID: "job_1", Status: "completed", Timestamp: "2026-01-01T00:00:00Z", VerificationResult: result})
+		}))
+		t.Cleanup(dp.Close)
+		service.registry.Register(&DPProvider{DPID: string(rune('a' + i)), Endpoint: dp.URL, SupportedClaims: []string{"age_verification"}, Priority: i})
+	}
+	return service
+}
+
+func TestDPConnectorService_Consensus(t *testing.T) {
+	service := newConsensusTestService(t, &config.Config{DPConsensusClaims: []string{"age_verification=2/3"}},
+		&VerificationResult{Verified: true, Confidence: 0.9, Evidence: []string{"passport"}},
+		&VerificationResult{Verified: false, Confidence: 0.4},
+		&VerificationResult{Verified: true, Confidence: 0.7, Evidence: []string{"passport", "credit_file"}},
+	)
+
+	response, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"})
+	if err != nil {
+		t.Fatalf("Expected two of three DPs to reach consensus, got %v", err)
+	}
+	consensus := response.Consensus
+	if consensus == nil || !consensus.Verified || consensus.Agreed != 2 || consensus.Queried != 3 || len(consensus.Providers) != 3 {
+		t.Fatalf("Expected the consensus record, got %+v", consensus)
+	}
+	if result := response.VerificationResult; !result.Verified || result.Confidence < 0.79 || result.Confidence > 0.81 || len(result.Evidence) != 2 {
+		t.Errorf("Expected the agreeing DPs' mean confidence and evidence, got %+v", result)
+	}
+	if response.DPID != "a" || consensus.Providers[1].Verified || consensus.Providers[1].Confidence != 0.4 {
+		t.Errorf("Expected every DP's answer recorded, got %+v", consensus.Providers)
+	}
+
+	service.config.DPConsensusConfidence = ConfidenceAggregationMin
+	response, _ = service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"})
+	if response.VerificationResult.Confidence != 0.7 || response.Consensus.Method != ConfidenceAggregationMin || response.Scoring.Aggregation != ConfidenceAggregationMin {
+		t.Errorf("Expected the lowest agreeing confidence, got %+v", response.Consensus)
+	}
+}
+
+func TestDPConnectorService_ConsensusNotReached(t *testing.T) {
+	service := newConsensusTestService(t, &config.Config{},
+		&VerificationResult{Verified: true, Confidence: 0.9},
+		nil,
+		&VerificationResult{Verified: false, Confidence: 0.8},
+	)
+	service.consensusRules = map[string]ConsensusRule{AnyClaimType: {Required: 2}}
+
+	_, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"})
+	var notReached *ErrConsensusNotReached
+	if !errors.As(err, &notReached) || notReached.Result.Agreed != 1 || notReached.Result.Providers[1].Error == "" {
+		t.Fatalf("Expected no consensus with a failed DP, got %v", err)
+	}
+
+	service.consensusRules = map[string]ConsensusRule{AnyClaimType: {Required: 1, Providers: 3}}
+	if _, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"}); !errors.As(err, &notReached) {
+		t.Errorf("Expected conflicting DPs to be no consensus, got %v", err)
+	}
+}
+
+func TestParseConsensusRules(t *testing.T) {
+	rules, err := ParseConsensusRules([]string{"age_verification=2/3", "*=2"})
+	if err != nil || rules["age_verification"] != (ConsensusRule{Required: 2, Providers: 3}) || rules["*"].Providers != 0 {
+		t.Errorf("Expected the rules to parse, got %+v, %v", rules, err)
+	}
+	for _, invalid := range []string{"age_verification", "age_verification=0", "age_verification=3/2", "=2"} {
+		if _, err := ParseConsensusRules([]string{invalid}); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Failure types a DP error is classified as
+const (
+	FailureTypeStatus     = "status"
+	FailureTypeTimeout    = "timeout"
+	FailureTypeConnection = "connection"
+	FailureTypeTLS        = "tls"
+	FailureTypeDecode     = "decode"
+	FailureTypeCanceled   = "canceled"
+	FailureTypeOther      = "other"
+)
+
+// defaultFailureStatusCodes are the statuses that count against a DP unless
+// its classifier says otherwise: the DP failing, overloaded or too slow, but
+// not rejecting a request it was sent
+var defaultFailureStatusCodes = []string{"5xx", "429", "408"}
+
+// DPStatusError is an unexpected HTTP status from a DP
+type DPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *DPStatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("DP connector returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("DP connector returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// DPFailureClassifier decides which failed verifications count toward a DP's
+// circuit breaker. A 400 caused by bad RP data says nothing about the DP's
+// health and should not open its breaker.
+type DPFailureClassifier struct {
+	// StatusCodes counted as failures, as codes ("429"), ranges
+	// ("500-599") or classes ("5xx"); defaults to 5xx, 429 and 408
+	StatusCodes []string `json:"status_codes,omitempty"`
+	// ErrorTypes counted as failures among timeout, connection, tls, decode
+	// and other; all of them when unset. Cancelled requests never count.
+	ErrorTypes []string `json:"error_types,omitempty"`
+}
+
+// Validate checks the status code specs and error types
+func (c *DPFailureClassifier) Validate() error {
+	for _, spec := range c.StatusCodes {
+		if _, _, err := parseStatusCodeSpec(spec); err != nil {
+			return err
+		}
+	}
+	for _, failureType := range c.ErrorTypes {
+		switch failureType {
+		case FailureTypeTimeout, FailureTypeConnection, FailureTypeTLS, FailureTypeDecode, FailureTypeOther:
+		default:
+			return fmt.Errorf("failure classifier: unknown error type %q", failureType)
+		}
+	}
+	return nil
+}
+
+// CountsAsFailure reports whether an error should increment the breaker's
+// failure count. A nil classifier applies the defaults.
+func (c *DPFailureClassifier) CountsAsFailure(err error) bool {
+	var tooLarge *PayloadTooLargeError
+	if errors.As(err, &tooLarge) {
+		return false
+	}
+	failureType := classifyDPFailure(err)
+	switch failureType {
+	case FailureTypeCanceled:
+		return false
+	case FailureTypeStatus:
+		var statusErr *DPStatusError
+		errors.As(err, &statusErr)
+		specs := defaultFailureStatusCodes
+		if c != nil && len(c.StatusCodes) > 0 {
+			specs = c.StatusCodes
+		}
+		return statusCodeMatches(specs, statusErr.StatusCode)
+	}
+
+	if c == nil || c.ErrorTypes == nil {
+		return true
+	}
+	for _, counted := range c.ErrorTypes {
+		if counted == failureType {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyDPFailure returns the failure type of a DP error
+func classifyDPFailure(err error) string {
+	var statusErr *DPStatusError
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	var unknownStatusErr *UnknownDPStatusError
+	var partialErr *InvalidPartialResultsError
+	var tooLargeErr *ResponseTooLargeError
+
+	switch {
+	case errors.As(err, &statusErr):
+		return FailureTypeStatus
+	case errors.Is(err, context.Canceled):
+		return FailureTypeCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return FailureTypeTimeout
+	case classifyTLSEvent(err) != "", errors.As(err, &recordErr), errors.As(err, &authorityErr),
+		errors.As(err, &invalidErr), errors.As(err, &hostnameErr):
+		return FailureTypeTLS
+	case errors.As(err, &netErr):
+		return FailureTypeConnection
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.As(err, &unknownStatusErr),
+		errors.As(err, &partialErr), errors.As(err, &tooLargeErr):
+		return FailureTypeDecode
+	default:
+		return FailureTypeOther
+	}
+}
+
+// parseStatusCodeSpec returns the inclusive range a status code spec covers
+func parseStatusCodeSpec(spec string) (int, int, error) {
+	invalid := fmt.Errorf("failure classifier: invalid status code %q", spec)
+	spec = strings.TrimSpace(strings.ToLower(spec))
+	if len(spec) == 3 && strings.HasSuffix(spec, "xx") {
+		class, err := strconv.Atoi(spec[:1])
+		if err != nil || class < 1 || class > 5 {
+			return 0, 0, invalid
+		}
+		return class * 100, class*100 + 99, nil
+	}
+
+	low, high, isRange := strings.Cut(spec, "-")
+	from, err := strconv.Atoi(low)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	to := from
+	if isRange {
+		if to, err = strconv.Atoi(high); err != nil {
+			return 0, 0, invalid
+		}
+	}
+	if from < 100 || to > 599 || from > to {
+		return 0, 0, invalid
+	}
+	return from, to, nil
+}
+
+// statusCodeMatches reports whether a status code falls in any of the specs
+func statusCodeMatches(specs []string, statusCode int) bool {
+	for _, spec := range specs {
+		from, to, err := parseStatusCodeSpec(spec)
+		if err == nil && statusCode >= from && statusCode <= to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestDPFailureClassifier_CountsAsFailure(t *testing.T) {
+	var defaults *DPFailureClassifier
+	custom := &DPFailureClassifier{StatusCodes: []string{"500-503", "409"}, ErrorTypes: []string{FailureTypeTimeout}}
+
+	tests := []struct {
+		name     string
+		err      error
+		defaults bool
+		custom   bool
+	}{
+		{"bad request", &DPStatusError{StatusCode: 400}, false, false},
+		{"conflict", fmt.Errorf("wrapped: %w", &DPStatusError{StatusCode: 409}), false, true},
+		{"rate limited", &DPStatusError{StatusCode: 429}, true, false},
+		{"unavailable", &DPStatusError{StatusCode: 503}, true, true},
+		{"gateway timeout", &DPStatusError{StatusCode: 504}, true, false},
+		{"deadline", fmt.Errorf("request failed: %w", context.DeadlineExceeded), true, true},
+		{"cancelled", context.Canceled, false, false},
+		{"other", errors.New("adapter failed"), true, false},
+	}
+	for _, tt := range tests {
+		if got := defaults.CountsAsFailure(tt.err); got != tt.defaults {
+			t.Errorf("%s: default classifier counted %v, want %v", tt.name, got, tt.defaults)
+		}
+		if got := custom.CountsAsFailure(tt.err); got != tt.custom {
+			t.Errorf("%s: custom classifier counted %v, want %v", tt.name, got, tt.custom)
+		}
+	}
+}
+
+func TestDPFailureClassifier_Validate(t *testing.T) {
+	valid := &DPFailureClassifier{StatusCodes: []string{"5xx", "429", "500-504"}, ErrorTypes: []string{"timeout", "tls"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected a valid classifier, got %v", err)
+	}
+	for _, invalid := range []*DPFailureClassifier{
+		{StatusCodes: []string{"6xx"}},
+		{StatusCodes: []string{"504-500"}},
+		{StatusCodes: []string{"abc"}},
+		{ErrorTypes: []string{"dns"}},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+}
+
+func TestDPConnectorService_ClientErrorsDoNotOpenBreaker(t *testing.T) {
+	status := http.StatusBadRequest
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer dp.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: dp.URL, DPTimeout: 5 * time.Second})
+	service.retryConfig.MaxRetries = 0
+	service.registry = NewDPRegistry()
+	service.registry.Register(&DPProvider{DPID: "dp_1", Endpoint: dp.URL, SupportedClaims: []string{"age_verification"}})
+	req := &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"}
+	breaker := service.providerBreaker("dp_1")
+
+	for i := 0; i < breaker.threshold; i++ {
+		if _, err := service.VerifyWithDP(context.Background(), req); err == nil {
+			t.Fatal("Expected the 400 to fail the verification")
+		}
+	}
+	if breaker.state != CircuitClosed || breaker.failureCount != 0 {
+		t.Errorf("Expected 400s not to count, got state %s with %d failures", breaker.state, breaker.failureCount)
+	}
+
+	status = http.StatusServiceUnavailable
+	for i := 0; i < breaker.threshold; i++ {
+		service.VerifyWithDP(context.Background(), req)
+	}
+	if breaker.state != CircuitOpen {
+		t.Errorf("Expected 503s to open the breaker, got %s", breaker.state)
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// latencySampleSize is the number of recent latencies kept per DP
+	latencySampleSize = 200
+	// minHedgeSamples is the number of samples needed before the percentile
+	// replaces the configured hedge delay
+	minHedgeSamples = 20
+	// defaultHedgeDelay is used when no delay is configured
+	defaultHedgeDelay = 250 * time.Millisecond
+)
+
+// errHedgeCancelled is the cancellation cause for a request that lost the race
+var errHedgeCancelled = errors.New("superseded by hedged request")
+
+// latencyTracker keeps a ring of recent successful latencies per DP
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+	next    map[string]int
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		samples: make(map[string][]time.Duration),
+		next:    make(map[string]int),
+	}
+}
+
+// Record adds a latency sample for a DP
+func (t *latencyTracker) Record(dpID string, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	samples := t.samples[dpID]
+	if len(samples) < latencySampleSize {
+		t.samples[dpID] = append(samples, latency)
+		return
+	}
+	samples[t.next[dpID]] = latency
+	t.next[dpID] = (t.next[dpID] + 1) % latencySampleSize
+}
+
+// Percentile returns the latency at percentile p (0-1) for a DP, or false
+// when there are too few samples
+func (t *latencyTracker) Percentile(dpID string, p float64) (time.Duration, bool) {
+	t.mu.Lock()
+	samples := append([]time.Duration(nil), t.samples[dpID]...)
+	t.mu.Unlock()
+
+	if len(samples) < minHedgeSamples {
+		return 0, false
+	}
+	if p <= 0 || p > 1 {
+		p = 0.95
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	index := int(float64(len(samples))*p+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(samples) {
+		index = len(samples) - 1
+	}
+	return samples[index], true
+}
+
+// hedgingEnabled reports whether verifications for a claim type are hedged
+func (s *DPConnectorService) hedgingEnabled(claimType string) bool {
+	for _, claim := range s.config.DPHedgeClaims {
+		if claim == claimType || claim == AnyClaimType {
+			return true
+		}
+	}
+	return false
+}
+
+// hedgeDelay returns how long to wait for a DP before hedging: its observed
+// latency percentile, or the configured delay until enough samples exist
+func (s *DPConnectorService) hedgeDelay(dpID string) time.Duration {
+	if delay, ok := s.latencies.Percentile(dpID, s.config.DPHedgePercentile); ok {
+		return delay
+	}
+	if s.config.DPHedgeDelay > 0 {
+		return s.config.DPHedgeDelay
+	}
+	return defaultHedgeDelay
+}
+
+// ObservedLatency returns the median latency of recent successful requests
+// to a DP, or false when too few have been seen
+func (s *DPConnectorService) ObservedLatency(dpID string) (time.Duration, bool) {
+	return s.latencies.Percentile(dpID, 0.5)
+}
+
+// hedgeResult is the outcome of one provider attempt in a hedged verification
+type hedgeResult struct {
+	response *DPResponse
+	err      error
+	hedge    bool
+}
+
+// verifyHedged sends the request to the first healthy provider and, if it has
+// not answered within the hedge delay, to a second one. The first success is
+// returned and the other request is cancelled. Failures fail over in priority
+// order as in the unhedged path.
+func (s *DPConnectorService) verifyHedged(ctx context.Context, providers []*DPProvider, payload []byte) (*DPResponse, error) {
+	hedgeCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(errHedgeCancelled)
+
+	results := make(chan hedgeResult, len(providers))
+	var lastErr error
+	next, inFlight := 0, 0
+
+	// launchNext starts the next provider whose breaker allows traffic
+	launchNext := func(hedge bool) (*DPProvider, bool) {
+		for next < len(providers) {
+			provider := providers[next]
+			next++
+			if !s.providerBreaker(provider.DPID).CanExecute() {
+				lastErr = fmt.Errorf("%w, DP %s is unavailable", ErrCircuitOpen, provider.DPID)
+				continue
+			}
+			inFlight++
+			go func() {
+				response, err := s.attemptProvider(hedgeCtx, provider, payload)
+				results <- hedgeResult{response: response, err: err, hedge: hedge}
+			}()
+			return provider, true
+		}
+		return nil, false
+	}
+
+	primary, ok := launchNext(false)
+	if !ok {
+		return nil, lastErr
+	}
+
+	timer := time.NewTimer(s.hedgeDelay(primary.DPID))
+	defer timer.Stop()
+	hedged := false
+
+	for inFlight > 0 {
+		select {
+		case result := <-results:
+			inFlight--
+			if result.err == nil {
+				if result.hedge {
+					atomic.AddInt64(&s.hedgeWins, 1)
+				}
+				return result.response, nil
+			}
+			lastErr = result.err
+
+			// Don't fail over once the caller has given up
+			if ctx.Err() != nil {
+				return nil, lastErr
+			}
+			if inFlight == 0 {
+				launchNext(false)
+			}
+		case <-timer.C:
+			if !hedged {
+				hedged = true
+				if _, ok := launchNext(true); ok {
+					atomic.AddInt64(&s.hedgedRequests, 1)
+				}
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return nil, lastErr
+}
+
+// GetHedgingStats returns hedging configuration and counters
+func (s *DPConnectorService) GetHedgingStats() map[string]interface{} {
+	return map[string]interface{}{
+		"claims":          s.config.DPHedgeClaims,
+		"percentile":      s.config.DPHedgePercentile,
+		"default_delay":   s.config.DPHedgeDelay.String(),
+		"hedged_requests": atomic.LoadInt64(&s.hedgedRequests),
+		"hedge_wins":      atomic.LoadInt64(&s.hedgeWins),
+	}
+}
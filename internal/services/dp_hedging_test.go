@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// newHedgingTestService registers a slow primary and a fast backup DP
+func newHedgingTestService(t *testing.T, cfg *config.Config, primaryDelay time.Duration) (*DPConnectorService, func()) {
+	t.Helper()
+
+	respond := func(w http.ResponseWriter) {
+		json.NewEncoder(w).Encode(DPResponse{JobID: "job_1", Status: "completed", Timestamp: "2025-08-02T07:00:00Z"})
+	}
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(primaryDelay):
+			respond(w)
+		case <-r.Context().Done():
+		}
+	}))
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(w)
+	}))
+
+	cfg.DPConnectorURL = "http://localhost:1"
+	service := NewDPConnectorService(cfg)
+	service.retryConfig.MaxRetries = 0
+	service.registry = NewDPRegistry()
+	service.registry.Register(&DPProvider{DPID: "dp_primary", Endpoint: primary.URL, SupportedClaims: []string{"age_verification"}, Priority: 0})
+	service.registry.Register(&DPProvider{DPID: "dp_backup", Endpoint: backup.URL, SupportedClaims: []string{"age_verification"}, Priority: 1})
+
+	return service, func() {
+		primary.Close()
+		backup.Close()
+	}
+}
+
+func TestDPConnectorService_HedgedRequest(t *testing.T) {
+	service, cleanup := newHedgingTestService(t, &config.Config{
+		DPHedgeClaims: []string{"age_verification"},
+		DPHedgeDelay:  20 * time.Millisecond,
+	}, 2*time.Second)
+	defer cleanup()
+
+	start := time.Now()
+	response, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"})
+	if err != nil {
+		t.Fatalf("Expected hedged verification to succeed, got %v", err)
+	}
+	if response.DPID != "dp_backup" {
+		t.Errorf("Expected the hedge to answer first, got %s", response.DPID)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected hedge to avoid waiting for the slow DP, took %v", elapsed)
+	}
+
+	stats := service.GetHedgingStats()
+	if stats["hedged_requests"].(int64) != 1 || stats["hedge_wins"].(int64) != 1 {
+		t.Errorf("Unexpected hedging stats: %v", stats)
+	}
+
+	// The cancelled primary must not count as a DP failure
+	if count := service.providerBreaker("dp_primary").GetCircuitBreakerStats()["failure_count"]; count != 0 {
+		t.Errorf("Expected no breaker failure for the cancelled request, got %v", count)
+	}
+}
+
+func TestDPConnectorService_HedgingDisabledForClaim(t *testing.T) {
+	service, cleanup := newHedgingTestService(t, &config.Config{
+		DPHedgeClaims: []string{"student_verification"},
+		DPHedgeDelay:  20 * time.Millisecond,
+	}, 100*time.Millisecond)
+	defer cleanup()
+
+	response, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"})
+	if err != nil {
+		t.Fatalf("Expected verification to succeed, got %v", err)
+	}
+	if response.DPID != "dp_primary" {
+		t.Errorf("Expected unhedged request to wait for the primary, got %s", response.DPID)
+	}
+	if service.GetHedgingStats()["hedged_requests"].(int64) != 0 {
+		t.Error("Expected no hedged requests")
+	}
+}
+
+func TestDPConnectorService_HedgedFailover(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer failing.Close()
+
+	service, cleanup := newHedgingTestService(t, &config.Config{
+		DPHedgeClaims: []string{AnyClaimType},
+		DPHedgeDelay:  time.Minute,
+	}, 0)
+	defer cleanup()
+	primary, _ := service.registry.Get("dp_primary")
+	primary.Endpoint = failing.URL
+
+	response, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"})
+	if err != nil {
+		t.Fatalf("Expected failover to succeed, got %v", err)
+	}
+	if response.DPID != "dp_backup" {
+		t.Errorf("Expected failover to dp_backup, got %s", response.DPID)
+	}
+	if service.GetHedgingStats()["hedged_requests"].(int64) != 0 {
+		t.Error("Expected failover without hedging")
+	}
+}
+
+func TestLatencyTracker_Percentile(t *testing.T) {
+	tracker := newLatencyTracker()
+
+	for i := 1; i < minHedgeSamples; i++ {
+		tracker.Record("dp_a", time.Duration(i)*time.Millisecond)
+	}
+	if _, ok := tracker.Percentile("dp_a", 0.95); ok {
+		t.Fatal("Expected no percentile before enough samples")
+	}
+
+	for i := minHedgeSamples; i <= 100; i++ {
+		tracker.Record("dp_a", time.Duration(i)*time.Millisecond)
+	}
+	p95, ok := tracker.Percentile("dp_a", 0.95)
+	if !ok || p95 != 95*time.Millisecond {
+		t.Errorf("Expected p95 of 95ms, got %v", p95)
+	}
+
+	// Old samples are overwritten once the ring is full
+	for i := 0; i < latencySampleSize; i++ {
+		tracker.Record("dp_a", time.Second)
+	}
+	if p50, _ := tracker.Percentile("dp_a", 0.5); p50 != time.Second {
+		t.Errorf("Expected ring to hold only recent samples, got p50 %v", p50)
+	}
+}
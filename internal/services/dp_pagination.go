@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Pagination modes of DPs returning multi-record results
+const (
+	PaginationCursor = "cursor"
+	PaginationOffset = "offset"
+)
+
+// Pagination limits applied when a provider does not set its own
+const (
+	defaultPaginationMaxPages   = 10
+	defaultPaginationMaxRecords = 1000
+	defaultPaginationDeadline   = 10 * time.Second
+)
+
+// DPPagination configures how follow-up pages are requested from a provider
+// that splits one query's results across pages. Follow-up requests repeat
+// the query with "cursor" or "offset" (and "limit" when a page size is set).
+type DPPagination struct {
+	Mode       string `json:"mode"`
+	PageSize   int    `json:"page_size,omitempty"`
+	MaxPages   int    `json:"max_pages,omitempty"`
+	MaxRecords int    `json:"max_records,omitempty"`
+	// DeadlineMs bounds the time spent fetching follow-up pages
+	DeadlineMs int64 `json:"deadline_ms,omitempty"`
+}
+
+// Validate checks that the pagination settings are usable
+func (p *DPPagination) Validate() error {
+	if p.Mode != PaginationCursor && p.Mode != PaginationOffset {
+		return fmt.Errorf("pagination mode must be %q or %q", PaginationCursor, PaginationOffset)
+	}
+	if p.PageSize < 0 || p.MaxPages < 0 || p.MaxRecords < 0 || p.DeadlineMs < 0 {
+		return fmt.Errorf("pagination limits cannot be negative")
+	}
+	return nil
+}
+
+// DPPageInfo is the pagination block of a DP response page
+type DPPageInfo struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more,omitempty"`
+	Total      int    `json:"total,omitempty"`
+}
+
+// DPPaginationSummary records how a multi-record result was assembled. It is
+// set in the response metadata under "pagination".
+type DPPaginationSummary struct {
+	Pages     int    `json:"pages"`
+	Records   int    `json:"records"`
+	Truncated bool   `json:"truncated"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Reasons a paginated result was truncated
+const (
+	PaginationNotConfigured = "pagination_not_configured"
+	PaginationMaxPages      = "max_pages"
+	PaginationMaxRecords    = "max_records"
+	PaginationDeadline      = "deadline"
+)
+
+// hasMore reports whether the DP says results continue past this page
+func (p *DPPageInfo) hasMore(fetched int) bool {
+	if p == nil {
+		return false
+	}
+	return p.HasMore || p.NextCursor != "" || (p.Total > 0 && fetched < p.Total)
+}
+
+// collectPages fetches the remaining pages of a paginated response and
+// merges them into the first page. Results are never silently cut to page
+// one: when limits stop collection, or the provider has no pagination
+// settings, the summary marks the result as truncated.
+func (s *DPConnectorService) collectPages(ctx context.Context, provider *DPProvider, payload []byte, first *DPResponse) (*DPResponse, error) {
+	summary := &DPPaginationSummary{Pages: 1, Records: len(first.Records)}
+	if !first.Pagination.hasMore(summary.Records) {
+		if first.Pagination != nil {
+			first.setPaginationSummary(summary)
+		}
+		return first, nil
+	}
+
+	pagination := provider.Pagination
+	if pagination == nil {
+		fmt.Printf("DP PAGINATION WARNING: DP %s returned more pages but has no pagination settings; using page one only\n", provider.DPID)
+		s.truncate(first, summary, PaginationNotConfigured)
+		return first, nil
+	}
+
+	maxPages, maxRecords, deadline := pagination.MaxPages, pagination.MaxRecords, time.Duration(pagination.DeadlineMs)*time.Millisecond
+	if maxPages == 0 {
+		maxPages = defaultPaginationMaxPages
+	}
+	if maxRecords == 0 {
+		maxRecords = defaultPaginationMaxRecords
+	}
+	if deadline == 0 {
+		deadline = defaultPaginationDeadline
+	}
+	pageCtx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+
+	var query map[string]interface{}
+	if err := json.Unmarshal(payload, &query); err != nil {
+		return nil, fmt.Errorf("failed to prepare page request: %w", err)
+	}
+
+	page := first
+	for page.Pagination.hasMore(summary.Records) {
+		switch {
+		case summary.Pages >= maxPages:
+			s.truncate(first, summary, PaginationMaxPages)
+			return first, nil
+		case summary.Records >= maxRecords:
+			first.capRecords(summary, maxRecords)
+			s.truncate(first, summary, PaginationMaxRecords)
+			return first, nil
+		}
+
+		if pagination.Mode == PaginationCursor {
+			if page.Pagination.NextCursor == "" {
+				return nil, fmt.Errorf("DP %s reported more results without a cursor", provider.DPID)
+			}
+			query["cursor"] = page.Pagination.NextCursor
+		} else {
+			query["offset"] = summary.Records
+		}
+		if pagination.PageSize > 0 {
+			query["limit"] = pagination.PageSize
+		}
+		pagePayload, err := json.Marshal(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare page request: %w", err)
+		}
+		if pagePayload, err = provider.renderRequest(pagePayload); err != nil {
+			return nil, err
+		}
+
+		page, err = s.sendVerifyRequest(pageCtx, provider, pagePayload, nil)
+		if err != nil {
+			// Running out of time yields the pages collected so far, but a
+			// cancelled request does not
+			if errors.Is(pageCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+				s.truncate(first, summary, PaginationDeadline)
+				return first, nil
+			}
+			return nil, fmt.Errorf("failed to fetch page %d: %w", summary.Pages+1, err)
+		}
+		if len(page.Records) == 0 && page.Pagination.hasMore(summary.Records) {
+			return nil, fmt.Errorf("DP %s returned an empty page with more results pending", provider.DPID)
+		}
+
+		summary.Pages++
+		summary.Records += len(page.Records)
+		first.mergePage(page)
+	}
+
+	// The last page may overshoot the record limit
+	if summary.Records > maxRecords {
+		first.capRecords(summary, maxRecords)
+		s.truncate(first, summary, PaginationMaxRecords)
+		return first, nil
+	}
+
+	first.Pagination = nil
+	first.setPaginationSummary(summary)
+	return first, nil
+}
+
+// truncate marks a response as holding only part of the DP's results
+func (s *DPConnectorService) truncate(response *DPResponse, summary *DPPaginationSummary, reason string) {
+	atomic.AddInt64(&s.paginationTruncations, 1)
+	summary.Truncated = true
+	summary.Reason = reason
+	response.setPaginationSummary(summary)
+}
+
+// mergePage appends a follow-up page's records and evidence
+func (r *DPResponse) mergePage(page *DPResponse) {
+	r.Records = append(r.Records, page.Records...)
+	r.Pagination = page.Pagination
+
+	if page.VerificationResult == nil {
+		return
+	}
+	if r.VerificationResult == nil {
+		r.VerificationResult = page.VerificationResult
+		return
+	}
+	seen := make(map[string]bool, len(r.VerificationResult.Evidence))
+	for _, evidence := range r.VerificationResult.Evidence {
+		seen[evidence] = true
+	}
+	for _, evidence := range page.VerificationResult.Evidence {
+		if !seen[evidence] {
+			seen[evidence] = true
+			r.VerificationResult.Evidence = append(r.VerificationResult.Evidence, evidence)
+		}
+	}
+}
+
+// capRecords keeps at most max records
+func (r *DPResponse) capRecords(summary *DPPaginationSummary, max int) {
+	if len(r.Records) > max {
+		r.Records = r.Records[:max]
+	}
+	summary.Records = len(r.Records)
+}
+
+func (r *DPResponse) setPaginationSummary(summary *DPPaginationSummary) {
+	if r.Metadata == nil {
+		r.Metadata = make(map[string]interface{})
+	}
+	r.Metadata["pagination"] = summary
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// newPaginatedDP serves total records in pages of pageSize, by cursor or
+// offset, and counts the requests made
+func newPaginatedDP(t *testing.T, total, pageSize int, requests *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		var query map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&query)
+
+		start := 0
+		if cursor, ok := query["cursor"].(string); ok {
+			fmt.Sscanf(cursor, "c%d", &start)
+		}
+		if offset, ok := query["offset"].(float64); ok {
+			start = int(offset)
+		}
+		end := start + pageSize
+		if end > total {
+			end = total
+		}
+
+		records := make([]map[string]interface{}, 0)
+		for i := start; i < end; i++ {
+			records = append(records, map[string]interface{}{"id": i})
+		}
+		page := map[string]interface{}{
+			"job_id":              "j",
+			"status":              "completed",
+			"timestamp":           "2026-01-01T00:00:00Z",
+			"records":             records,
+			"verification_result": map[string]interface{}{"verified": true, "confidence": 0.9, "evidence": []string{fmt.Sprintf("page-%d", start/pageSize)}},
+			"pagination":          map[string]interface{}{"total": total},
+		}
+		if end < total {
+			page["pagination"] = map[string]interface{}{"total": total, "next_cursor": fmt.Sprintf("c%d", end)}
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+}
+
+func newPaginationTestService(t *testing.T, endpoint string, pagination *DPPagination) *DPConnectorService {
+	t.Helper()
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: endpoint, DPTimeout: 5 * time.Second})
+	service.Registry().Remove(DefaultDPProviderID)
+	if err := service.Registry().Register(&DPProvider{
+		DPID:            "dp-pages",
+		Endpoint:        endpoint,
+		SupportedClaims: []string{AnyClaimType},
+		AdapterType:     AdapterTypeREST,
+		Pagination:      pagination,
+	}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	return service
+}
+
+func TestDPConnectorService_MergesPages(t *testing.T) {
+	for _, mode := range []string{PaginationCursor, PaginationOffset} {
+		requests := 0
+		server := newPaginatedDP(t, 25, 10, &requests)
+
+		service := newPaginationTestService(t, server.URL, &DPPagination{Mode: mode})
+		response, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "student_verification"})
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s: VerifyWithDP failed: %v", mode, err)
+		}
+
+		if len(response.Records) != 25 || requests != 3 || response.Records[24]["id"] != 24.0 {
+			t.Errorf("%s: expected 25 records from 3 pages, got %d from %d", mode, len(response.Records), requests)
+		}
+		summary := response.Metadata["pagination"].(*DPPaginationSummary)
+		if summary.Pages != 3 || summary.Truncated {
+			t.Errorf("%s: unexpected summary %+v", mode, summary)
+		}
+		if len(response.VerificationResult.Evidence) != 3 {
+			t.Errorf("%s: expected evidence from every page, got %v", mode, response.VerificationResult.Evidence)
+		}
+	}
+}
+
+func TestDPConnectorService_PaginationLimits(t *testing.T) {
+	requests := 0
+	server := newPaginatedDP(t, 100, 10, &requests)
+	defer server.Close()
+	req := &models.PrivacyRequest{RPID: "rp_1", ClaimType: "student_verification"}
+
+	cases := []struct {
+		pagination *DPPagination
+		reason     string
+		records    int
+	}{
+		{nil, PaginationNotConfigured, 10},
+		{&DPPagination{Mode: PaginationCursor, MaxPages: 2}, PaginationMaxPages, 20},
+		{&DPPagination{Mode: PaginationCursor, MaxRecords: 25}, PaginationMaxRecords, 25},
+	}
+	for _, tc := range cases {
+		service := newPaginationTestService(t, server.URL, tc.pagination)
+		response, err := service.VerifyWithDP(context.Background(), req)
+		if err != nil {
+			t.Fatalf("%s: VerifyWithDP failed: %v", tc.reason, err)
+		}
+		summary := response.Metadata["pagination"].(*DPPaginationSummary)
+		if !summary.Truncated || summary.Reason != tc.reason || len(response.Records) != tc.records {
+			t.Errorf("%s: unexpected summary %+v with %d records", tc.reason, summary, len(response.Records))
+		}
+		if service.GetDPStats()["pagination_truncations"] != int64(1) {
+			t.Errorf("%s: expected the truncation to be counted", tc.reason)
+		}
+	}
+}
+
+func TestDPConnectorService_PaginationDeadline(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls > 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte(`{"job_id":"j","status":"completed","timestamp":"t","records":[{"id":1}],"pagination":{"next_cursor":"next"}}`))
+	}))
+	defer server.Close()
+
+	service := newPaginationTestService(t, server.URL, &DPPagination{Mode: PaginationCursor, DeadlineMs: 50})
+	response, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "student_verification"})
+	if err != nil {
+		t.Fatalf("VerifyWithDP failed: %v", err)
+	}
+	if summary := response.Metadata["pagination"].(*DPPaginationSummary); summary.Reason != PaginationDeadline || len(response.Records) != 1 {
+		t.Errorf("Expected the first page after the deadline, got %+v", summary)
+	}
+
+	if err := (&DPPagination{Mode: "page"}).Validate(); err == nil {
+		t.Error("Expected an unknown pagination mode to be rejected")
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// InvalidPartialResultsError is returned for a partial response the broker
+// cannot use: a partial status without partial_results, an unknown
+// identifier outcome, or a verified result contradicted by a mismatched
+// identifier. Like an undecodable response it counts as a decode failure.
+type InvalidPartialResultsError struct {
+	DPID   string
+	Reason string
+}
+
+func (e *InvalidPartialResultsError) Error() string {
+	return fmt.Sprintf("DP %s returned invalid partial results: %s", e.DPID, e.Reason)
+}
+
+// applyPartialResults validates a response's partial results. A partial
+// result is an answer, not a failure: when the DP gave no verification
+// result one is derived from the identifier outcomes, verified only if
+// nothing mismatched, with the share of identifiers matched as confidence.
+func applyPartialResults(provider *DPProvider, response *DPResponse) error {
+	partial := response.PartialResults
+	if partial == nil {
+		if response.Status == DPStatusPartial {
+			return &InvalidPartialResultsError{DPID: provider.DPID, Reason: "partial status without partial_results"}
+		}
+		return nil
+	}
+	if err := partial.Validate(); err != nil {
+		return &InvalidPartialResultsError{DPID: provider.DPID, Reason: err.Error()}
+	}
+
+	matched, mismatched, _ := partial.Counts()
+	if response.VerificationResult != nil {
+		if response.VerificationResult.Verified && mismatched > 0 {
+			return &InvalidPartialResultsError{DPID: provider.DPID, Reason: "verified result with mismatched identifiers"}
+		}
+		return nil
+	}
+
+	reason := partial.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("%d of %d identifiers matched", matched, len(partial.Identifiers))
+	}
+	response.VerificationResult = &VerificationResult{
+		Verified:   matched > 0 && mismatched == 0,
+		Confidence: float64(matched) / float64(len(partial.Identifiers)),
+		Reason:     reason,
+		Timestamp:  response.Timestamp,
+	}
+	return nil
+}
+
+// PartialResultsSummary describes partial results for a verification
+// response's metadata, or returns nil for a complete result
+func PartialResultsSummary(partial *models.PartialResults) map[string]interface{} {
+	if partial == nil {
+		return nil
+	}
+	matched, mismatched, unknown := partial.Counts()
+	summary := map[string]interface{}{
+		"identifiers": partial.Identifiers,
+		"matched":     matched,
+		"mismatched":  mismatched,
+		"unknown":     unknown,
+		"coverage":    partial.Coverage(),
+	}
+	if partial.Reason != "" {
+		summary["reason"] = partial.Reason
+	}
+	return summary
+}
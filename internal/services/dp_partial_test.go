@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestDPConnectorService_PartialResults(t *testing.T) {
+	responses := map[string]map[string]interface{}{
+		"/partial/verify": {
+			"job_id":    "j",
+			"status":    "partial",
+			"timestamp": "2026-01-01T00:00:00Z",
+			"partial_results": map[string]interface{}{
+				"identifiers": map[string]string{"email": "matched", "date_of_birth": "unknown"},
+				"reason":      "no date of birth on file",
+			},
+		},
+		"/inconsistent/verify": {
+			"job_id":              "j",
+			"status":              "partial",
+			"timestamp":           "2026-01-01T00:00:00Z",
+			"verification_result": map[string]interface{}{"verified": true, "confidence": 0.9},
+			"partial_results":     map[string]interface{}{"identifiers": map[string]string{"email": "mismatched"}},
+		},
+		"/bare/verify": {"job_id": "j", "status": "partial", "timestamp": "2026-01-01T00:00:00Z"},
+	}
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(responses[r.URL.Path])
+	}))
+	defer dp.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: dp.URL, DPTimeout: 5 * time.Second})
+	verify := func(path string) (*DPResponse, error) {
+		prefix := strings.TrimSuffix(path, "/verify")
+		service.registry = NewDPRegistry()
+		service.registry.Register(&DPProvider{DPID: "dp" + prefix, Endpoint: dp.URL + prefix, SupportedClaims: []string{"age_verification"}})
+		return service.VerifyWithDP(context.Background(), &models.PrivacyRequest{ClaimType: "age_verification"})
+	}
+
+	// Without a verification result one is derived from the outcomes
+	response, err := verify("/partial/verify")
+	if err != nil {
+		t.Fatalf("Expected the partial result to be accepted, got %v", err)
+	}
+	result := response.VerificationResult
+	if response.Status != DPStatusPartial || result == nil || !result.Verified || result.Confidence != 0.5 || result.Reason != "no date of birth on file" {
+		t.Errorf("Expected a verified result with half confidence, got %+v", result)
+	}
+
+	var partialErr *InvalidPartialResultsError
+	for _, path := range []string{"/inconsistent/verify", "/bare/verify"} {
+		_, err := verify(path)
+		if !errors.As(err, &partialErr) {
+			t.Errorf("Expected %s to be rejected as invalid partial results, got %v", path, err)
+		}
+		if failureType := classifyDPFailure(err); failureType != FailureTypeDecode {
+			t.Errorf("Expected invalid partial results to count as a decode failure, got %s", failureType)
+		}
+	}
+}
+
+func TestEvidenceWeighting_AggregatesPartialResults(t *testing.T) {
+	weighting := &EvidenceWeighting{Version: "v1"}
+	aggregate := weighting.Aggregate([]EvidenceResult{
+		{DPID: "dp_registry", Verified: true, Confidence: 0.9},
+		// Matched one identifier of four and mismatched none; the unknown
+		// identifiers do not count against the verification
+		{DPID: "dp_partial", Verified: false, Confidence: 0.25, Partial: true, Coverage: 0.25},
+		// A DP that could determine nothing carries no weight
+		{DPID: "dp_unknown", Verified: false, Partial: true, Coverage: 0},
+	})
+
+	if !aggregate.Verified {
+		t.Error("Expected the complete result to outweigh the partial one")
+	}
+	if aggregate.Results[1].Weight != 0.25 || aggregate.Results[2].Weight != 0 {
+		t.Errorf("Expected partial results to be weighed by coverage, got %+v", aggregate.Results)
+	}
+	if want := 0.9 / 1.25; aggregate.Confidence != want {
+		t.Errorf("Expected confidence %v, got %v", want, aggregate.Confidence)
+	}
+}
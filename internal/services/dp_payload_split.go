@@ -0,0 +1,520 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// dpCapabilitiesTTL is how long discovered capabilities are trusted before
+// the DP is asked again, and how long a limit learned from 413 responses
+// holds before requests grow back to the configured or advertised limit
+const dpCapabilitiesTTL = 15 * time.Minute
+
+// maxCapabilitiesBytes bounds the capabilities document read from a DP
+const maxCapabilitiesBytes = 64 << 10
+
+// DPCapabilities is what a DP advertises at its capabilities_path
+type DPCapabilities struct {
+	// MaxPayloadBytes is the largest verification request body the DP
+	// accepts; zero means no limit
+	MaxPayloadBytes int `json:"max_payload_bytes,omitempty"`
+}
+
+// DPSplitSummary describes a verification sent to a DP in several requests
+// because it exceeded the DP's payload limit
+type DPSplitSummary struct {
+	Requests        int      `json:"requests"`
+	MaxPayloadBytes int      `json:"max_payload_bytes"`
+	JobIDs          []string `json:"job_ids,omitempty"`
+}
+
+// PayloadTooLargeError is returned when a single identifier, with its Bloom
+// filters, does not fit in a DP's payload limit. It is a property of the
+// request, so it never counts against the DP's breaker.
+type PayloadTooLargeError struct {
+	DPID            string
+	Identifier      string
+	MaxPayloadBytes int
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	return fmt.Sprintf("identifier %s alone exceeds the %d byte payload limit of DP %s", e.Identifier, e.MaxPayloadBytes, e.DPID)
+}
+
+// dpPayloadLimit is the limit state of one provider
+type dpPayloadLimit struct {
+	advertised   *DPCapabilities
+	discoveredAt time.Time
+	learned      int
+	learnedAt    time.Time
+}
+
+// dpPayloadLimits tracks the payload limits DPs advertise and the ones
+// learned when a DP rejects a request as too large
+type dpPayloadLimits struct {
+	mu     sync.Mutex
+	limits map[string]*dpPayloadLimit
+	splits int64
+}
+
+func newDPPayloadLimits() *dpPayloadLimits {
+	return &dpPayloadLimits{limits: make(map[string]*dpPayloadLimit)}
+}
+
+func (l *dpPayloadLimits) entryLocked(dpID string) *dpPayloadLimit {
+	entry, exists := l.limits[dpID]
+	if !exists {
+		entry = &dpPayloadLimit{}
+		l.limits[dpID] = entry
+	}
+	return entry
+}
+
+// learn lowers a provider's limit to half the size of a request it rejected
+// as too large, and returns the new limit
+func (l *dpPayloadLimits) learn(dpID string, rejected int, now time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := l.entryLocked(dpID)
+	if entry.learned == 0 || now.Sub(entry.learnedAt) >= dpCapabilitiesTTL || rejected/2 < entry.learned {
+		entry.learned = rejected / 2
+		entry.learnedAt = now
+	}
+	return entry.learned
+}
+
+// maxPayloadBytes returns the payload limit in effect for a provider: the
+// smallest of its configured max_payload_bytes, the limit it advertises and
+// the limit learned from its 413 responses. Zero means no limit.
+func (s *DPConnectorService) maxPayloadBytes(ctx context.Context, provider *DPProvider) int {
+	limit := provider.MaxPayloadBytes
+	lower := func(candidate int) {
+		if candidate > 0 && (limit == 0 || candidate < limit) {
+			limit = candidate
+		}
+	}
+
+	if capabilities := s.DiscoverCapabilities(ctx, provider); capabilities != nil {
+		lower(capabilities.MaxPayloadBytes)
+	}
+
+	s.payloadLimits.mu.Lock()
+	if entry, exists := s.payloadLimits.limits[provider.DPID]; exists && time.Since(entry.learnedAt) < dpCapabilitiesTTL {
+		lower(entry.learned)
+	}
+	s.payloadLimits.mu.Unlock()
+	return limit
+}
+
+// DiscoverCapabilities returns the capabilities a provider advertises at its
+// capabilities_path, fetching them when the cached copy has expired. It
+// returns nil for providers without a capabilities_path, and while the DP's
+// capabilities cannot be fetched.
+func (s *DPConnectorService) DiscoverCapabilities(ctx context.Context, provider *DPProvider) *DPCapabilities {
+	if provider.CapabilitiesPath == "" {
+		return nil
+	}
+
+	s.payloadLimits.mu.Lock()
+	entry := s.payloadLimits.entryLocked(provider.DPID)
+	if !entry.discoveredAt.IsZero() && time.Since(entry.discoveredAt) < dpCapabilitiesTTL {
+		capabilities := entry.advertised
+		s.payloadLimits.mu.Unlock()
+		return capabilities
+	}
+	s.payloadLimits.mu.Unlock()
+
+	capabilities, err := s.fetchCapabilities(ctx, provider)
+	if err != nil {
+		// A failed fetch is cached too, so an unreachable capabilities
+		// endpoint is not asked again on every verification
+		fmt.Printf("DP CAPABILITIES WARNING: DP %s: %v; using its configured payload limit\n", provider.DPID, err)
+	}
+
+	s.payloadLimits.mu.Lock()
+	entry = s.payloadLimits.entryLocked(provider.DPID)
+	entry.advertised = capabilities
+	entry.discoveredAt = time.Now()
+	s.payloadLimits.mu.Unlock()
+	return capabilities
+}
+
+// fetchCapabilities reads a provider's capabilities document
+func (s *DPConnectorService) fetchCapabilities(ctx context.Context, provider *DPProvider) (*DPCapabilities, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(provider.Endpoint, "/")+provider.CapabilitiesPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capabilities request: %w", err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if err := s.providerAuthenticator(provider).AuthenticateRequest(httpReq); err != nil {
+		return nil, fmt.Errorf("failed to authenticate capabilities request: %w", err)
+	}
+
+	client, err := s.providerClient(provider)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("capabilities request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, &DPStatusError{StatusCode: resp.StatusCode}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCapabilitiesBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capabilities: %w", err)
+	}
+	if len(data) > maxCapabilitiesBytes {
+		return nil, &ResponseTooLargeError{DPID: provider.DPID, MaxResponseBytes: maxCapabilitiesBytes}
+	}
+	var capabilities DPCapabilities
+	if err := json.Unmarshal(data, &capabilities); err != nil {
+		return nil, fmt.Errorf("failed to decode capabilities: %w", err)
+	}
+	if capabilities.MaxPayloadBytes < 0 {
+		return nil, fmt.Errorf("invalid max_payload_bytes %d", capabilities.MaxPayloadBytes)
+	}
+	return &capabilities, nil
+}
+
+// isPayloadTooLarge reports whether a DP rejected a request with 413
+func isPayloadTooLarge(err error) bool {
+	var statusErr *DPStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusRequestEntityTooLarge
+}
+
+// verifyOversized sends a request exceeding a provider's payload limit as
+// several requests, each carrying some of the identifiers and their Bloom
+// filters, and merges the results
+func (s *DPConnectorService) verifyOversized(ctx context.Context, provider *DPProvider, payload []byte, limit int) (*DPResponse, error) {
+	var req models.PrivacyRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, fmt.Errorf("failed to split request: %w", err)
+	}
+	chunks, err := splitPrivacyRequest(provider.DPID, &req, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.verifySplit(ctx, provider, chunks, limit)
+}
+
+// verifyRejected splits a request the provider rejected as too large. A
+// request with a single identifier cannot be split, so the DP's error is
+// returned.
+func (s *DPConnectorService) verifyRejected(ctx context.Context, provider *DPProvider, payload []byte, rejection error) (*DPResponse, error) {
+	var req models.PrivacyRequest
+	if err := json.Unmarshal(payload, &req); err != nil || len(privacyRequestUnits(&req)) < 2 {
+		return nil, rejection
+	}
+	limit := s.payloadLimits.learn(provider.DPID, len(payload), time.Now())
+	return s.verifySplit(ctx, provider, splitRejected(provider.DPID, &req, len(payload)), limit)
+}
+
+// verifySplit sends the requests of a split verification and merges the
+// results. Requests are sent one after another, so a split verification
+// never holds more than its one slot at the DP.
+func (s *DPConnectorService) verifySplit(ctx context.Context, provider *DPProvider, chunks []*models.PrivacyRequest, limit int) (*DPResponse, error) {
+	var parts []*splitPart
+	for _, chunk := range chunks {
+		chunkParts, err := s.verifyChunk(ctx, provider, chunk)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, chunkParts...)
+	}
+	atomic.AddInt64(&s.payloadLimits.splits, 1)
+
+	response := mergeSplitParts(parts)
+	summary := &DPSplitSummary{Requests: len(parts), MaxPayloadBytes: limit}
+	for _, part := range parts {
+		if part.response.JobID != "" {
+			summary.JobIDs = append(summary.JobIDs, part.response.JobID)
+		}
+	}
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["split"] = summary
+	return response, nil
+}
+
+// splitPart is the response to one request of a split verification
+type splitPart struct {
+	identifiers []string
+	response    *DPResponse
+}
+
+// verifyChunk sends one request of a split verification. A DP answering 413
+// is under more pressure than its advertised limit suggests: the chunk is
+// split in half and the halved limit remembered for later requests.
+func (s *DPConnectorService) verifyChunk(ctx context.Context, provider *DPProvider, chunk *models.PrivacyRequest) ([]*splitPart, error) {
+	payload, err := json.Marshal(chunk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Split requests are never conditional: the DP's validators belong to
+	// the whole request
+	response, err := s.verifyPayload(ctx, provider, payload, nil)
+	if err == nil {
+		return []*splitPart{{identifiers: privacyRequestUnits(chunk), response: response}}, nil
+	}
+	if !isPayloadTooLarge(err) || len(privacyRequestUnits(chunk)) < 2 {
+		return nil, err
+	}
+
+	s.payloadLimits.learn(provider.DPID, len(payload), time.Now())
+	var parts []*splitPart
+	for _, half := range splitRejected(provider.DPID, chunk, len(payload)) {
+		halfParts, err := s.verifyChunk(ctx, provider, half)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, halfParts...)
+	}
+	return parts, nil
+}
+
+// unitName groups a request's entries by identifier: an identifier's hash,
+// its Bloom filter and its phonetic filter always travel together
+func unitName(key string) string {
+	return strings.TrimSuffix(key, "_phonetic")
+}
+
+// privacyRequestUnits returns the identifiers of a request, sorted
+func privacyRequestUnits(req *models.PrivacyRequest) []string {
+	seen := make(map[string]bool)
+	for key := range req.HashedIdentifiers {
+		seen[unitName(key)] = true
+	}
+	for key := range req.BloomFilters {
+		seen[unitName(key)] = true
+	}
+	units := make([]string, 0, len(seen))
+	for unit := range seen {
+		units = append(units, unit)
+	}
+	sort.Strings(units)
+	return units
+}
+
+// chunkOf returns a copy of a request carrying only some identifiers
+func chunkOf(req *models.PrivacyRequest, units []string) *models.PrivacyRequest {
+	include := make(map[string]bool, len(units))
+	for _, unit := range units {
+		include[unit] = true
+	}
+	chunk := &models.PrivacyRequest{
+		RPID:              req.RPID,
+		UserHash:          req.UserHash,
+		ClaimType:         req.ClaimType,
+		HashedIdentifiers: make(map[string]string),
+		Metadata:          req.Metadata,
+	}
+	for key, value := range req.HashedIdentifiers {
+		if include[unitName(key)] {
+			chunk.HashedIdentifiers[key] = value
+		}
+	}
+	for key, value := range req.BloomFilters {
+		if include[unitName(key)] {
+			if chunk.BloomFilters == nil {
+				chunk.BloomFilters = make(map[string]string)
+			}
+			chunk.BloomFilters[key] = value
+		}
+	}
+	return chunk
+}
+
+// splitPrivacyRequest packs a request's identifiers into as few requests as
+// fit within limit bytes each
+func splitPrivacyRequest(dpID string, req *models.PrivacyRequest, limit int) ([]*models.PrivacyRequest, error) {
+	var chunks []*models.PrivacyRequest
+	var current []string
+	for _, unit := range privacyRequestUnits(req) {
+		candidate := append(append([]string(nil), current...), unit)
+		data, err := json.Marshal(chunkOf(req, candidate))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		if len(data) <= limit {
+			current = candidate
+			continue
+		}
+		if len(current) == 0 {
+			return nil, &PayloadTooLargeError{DPID: dpID, Identifier: unit, MaxPayloadBytes: limit}
+		}
+		chunks = append(chunks, chunkOf(req, current))
+
+		data, err = json.Marshal(chunkOf(req, []string{unit}))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		if len(data) > limit {
+			return nil, &PayloadTooLargeError{DPID: dpID, Identifier: unit, MaxPayloadBytes: limit}
+		}
+		current = []string{unit}
+	}
+	if len(current) > 0 || len(chunks) == 0 {
+		chunks = append(chunks, chunkOf(req, current))
+	}
+	return chunks, nil
+}
+
+// splitRejected splits a request rejected at the given size into requests
+// of at most half that size, or into two halves by identifier when one
+// identifier is larger than that
+func splitRejected(dpID string, req *models.PrivacyRequest, rejected int) []*models.PrivacyRequest {
+	if chunks, err := splitPrivacyRequest(dpID, req, rejected/2); err == nil && len(chunks) > 1 {
+		return chunks
+	}
+	return halvePrivacyRequest(req)
+}
+
+// halvePrivacyRequest splits a request's identifiers into two requests
+func halvePrivacyRequest(req *models.PrivacyRequest) []*models.PrivacyRequest {
+	units := privacyRequestUnits(req)
+	middle := len(units) / 2
+	return []*models.PrivacyRequest{chunkOf(req, units[:middle]), chunkOf(req, units[middle:])}
+}
+
+// splitStatusPrecedence orders the statuses of a split verification's
+// responses; the merged response takes the highest
+var splitStatusPrecedence = map[DPStatus]int{
+	DPStatusCompleted:  0,
+	DPStatusPartial:    1,
+	DPStatusPending:    2,
+	DPStatusProcessing: 3,
+	DPStatusFailed:     4,
+}
+
+// mergeSplitParts merges the responses of a split verification. The user is
+// verified only if every request verified, with the lowest confidence of
+// them; evidence and records are combined. When any request came back
+// partial, the merged partial results list every identifier, taking the
+// outcome of a complete request's identifiers from its result.
+func mergeSplitParts(parts []*splitPart) *DPResponse {
+	first := parts[0].response
+	merged := &DPResponse{
+		JobID:     first.JobID,
+		Status:    first.Status,
+		Timestamp: first.Timestamp,
+	}
+	if first.Metadata != nil {
+		merged.Metadata = make(map[string]interface{}, len(first.Metadata))
+		for key, value := range first.Metadata {
+			merged.Metadata[key] = value
+		}
+	}
+
+	partial, missingResult := false, false
+	for _, part := range parts {
+		response := part.response
+		if splitStatusPrecedence[response.Status] > splitStatusPrecedence[merged.Status] {
+			merged.Status = response.Status
+		}
+		merged.Records = append(merged.Records, response.Records...)
+		if response.PartialResults != nil {
+			partial = true
+		}
+		if response.VerificationResult == nil {
+			missingResult = true
+			continue
+		}
+		merged.mergeSplitResult(response.VerificationResult)
+	}
+	// Identifiers the DP gave no result for are not verified
+	if missingResult && merged.VerificationResult != nil {
+		merged.VerificationResult.Verified = false
+	}
+
+	if partial {
+		merged.PartialResults = &models.PartialResults{Identifiers: make(map[string]string)}
+		for _, part := range parts {
+			if part.response.PartialResults != nil {
+				for name, outcome := range part.response.PartialResults.Identifiers {
+					merged.PartialResults.Identifiers[name] = outcome
+				}
+				continue
+			}
+			outcome := models.IdentifierMismatched
+			if result := part.response.VerificationResult; result != nil && result.Verified {
+				outcome = models.IdentifierMatched
+			}
+			for _, name := range part.identifiers {
+				merged.PartialResults.Identifiers[name] = outcome
+			}
+		}
+	}
+	return merged
+}
+
+// mergeSplitResult folds one request's verification result into the merged
+// result
+func (r *DPResponse) mergeSplitResult(result *VerificationResult) {
+	if r.VerificationResult == nil {
+		copied := *result
+		copied.Evidence = append([]string(nil), result.Evidence...)
+		r.VerificationResult = &copied
+		return
+	}
+
+	merged := r.VerificationResult
+	if merged.Verified && !result.Verified {
+		merged.Reason = result.Reason
+	}
+	merged.Verified = merged.Verified && result.Verified
+	if result.Confidence < merged.Confidence {
+		merged.Confidence = result.Confidence
+	}
+	seen := make(map[string]bool, len(merged.Evidence))
+	for _, evidence := range merged.Evidence {
+		seen[evidence] = true
+	}
+	for _, evidence := range result.Evidence {
+		if !seen[evidence] {
+			seen[evidence] = true
+			merged.Evidence = append(merged.Evidence, evidence)
+		}
+	}
+}
+
+// GetPayloadSplitStats returns the number of verifications split to fit DP
+// payload limits and each provider's limit in effect
+func (s *DPConnectorService) GetPayloadSplitStats() map[string]interface{} {
+	s.payloadLimits.mu.Lock()
+	defer s.payloadLimits.mu.Unlock()
+
+	learned := make(map[string]int)
+	advertised := make(map[string]int)
+	for dpID, entry := range s.payloadLimits.limits {
+		if entry.learned > 0 && time.Since(entry.learnedAt) < dpCapabilitiesTTL {
+			learned[dpID] = entry.learned
+		}
+		if entry.advertised != nil && entry.advertised.MaxPayloadBytes > 0 {
+			advertised[dpID] = entry.advertised.MaxPayloadBytes
+		}
+	}
+	return map[string]interface{}{
+		"split_verifications": atomic.LoadInt64(&s.payloadLimits.splits),
+		"advertised_limits":   advertised,
+		"learned_limits":      learned,
+	}
+}
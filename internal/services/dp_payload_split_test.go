@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// splitTestDP verifies every identifier it receives, with one evidence code
+// per identifier, and rejects bodies over rejectOver with 413
+type splitTestDP struct {
+	advertised   int
+	rejectOver   int
+	capabilities int
+	rejected     int
+	bodies       []int
+	requests     []*models.PrivacyRequest
+}
+
+func (d *splitTestDP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/capabilities" {
+		d.capabilities++
+		json.NewEncoder(w).Encode(DPCapabilities{MaxPayloadBytes: d.advertised})
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	if d.rejectOver > 0 && len(body) > d.rejectOver {
+		d.rejected++
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	var req models.PrivacyRequest
+	json.Unmarshal(body, &req)
+	d.bodies = append(d.bodies, len(body))
+	d.requests = append(d.requests, &req)
+
+	evidence := make([]string, 0)
+	for name := range req.HashedIdentifiers {
+		evidence = append(evidence, "matched:"+name)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job_id":              "job-" + strings.Join(sortedKeys(req.HashedIdentifiers), "-"),
+		"status":              "completed",
+		"timestamp":           "2026-01-01T00:00:00Z",
+		"verification_result": map[string]interface{}{"verified": true, "confidence": 0.9, "evidence": evidence},
+	})
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newSplitTestService(t *testing.T, endpoint string, provider *DPProvider) *DPConnectorService {
+	t.Helper()
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: endpoint, DPTimeout: 5 * time.Second})
+	service.Registry().Remove(DefaultDPProviderID)
+	provider.DPID = "dp-split"
+	provider.Endpoint = endpoint
+	provider.SupportedClaims = []string{AnyClaimType}
+	if err := service.Registry().Register(provider); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	return service
+}
+
+// newLargeRequest has four identifiers, each with a 200 character Bloom
+// filter, and a phonetic filter for the name
+func newLargeRequest() *models.PrivacyRequest {
+	req := &models.PrivacyRequest{
+		RPID:              "rp_1",
+		UserHash:          "user-hash",
+		ClaimType:         "student_verification",
+		HashedIdentifiers: make(map[string]string),
+		BloomFilters:      make(map[string]string),
+	}
+	for _, name := range []string{"email", "full_name", "phone", "student_id"} {
+		req.HashedIdentifiers[name] = "hash-" + name
+		req.BloomFilters[name] = strings.Repeat("f", 200)
+	}
+	req.BloomFilters["full_name_phonetic"] = "F450"
+	return req
+}
+
+func TestDPConnectorService_SplitsToAdvertisedLimit(t *testing.T) {
+	dp := &splitTestDP{advertised: 700}
+	server := httptest.NewServer(dp)
+	defer server.Close()
+	service := newSplitTestService(t, server.URL, &DPProvider{CapabilitiesPath: "/capabilities"})
+
+	response, err := service.VerifyWithDP(context.Background(), newLargeRequest())
+	if err != nil {
+		t.Fatalf("VerifyWithDP failed: %v", err)
+	}
+	if len(dp.requests) < 2 {
+		t.Fatalf("Expected the request to be split, got %d requests", len(dp.requests))
+	}
+	for i, size := range dp.bodies {
+		if size > 700 {
+			t.Errorf("Request %d is %d bytes, over the advertised limit", i, size)
+		}
+	}
+
+	seen := make(map[string]int)
+	for _, req := range dp.requests {
+		for name := range req.HashedIdentifiers {
+			seen[name]++
+			if req.BloomFilters[name] == "" {
+				t.Errorf("Expected %s sent with its Bloom filter", name)
+			}
+		}
+		if _, ok := req.BloomFilters["full_name_phonetic"]; ok && req.HashedIdentifiers["full_name"] == "" {
+			t.Error("Expected the phonetic filter sent with full_name")
+		}
+		if req.UserHash != "user-hash" || req.ClaimType != "student_verification" {
+			t.Errorf("Expected every request to carry the subject and claim, got %+v", req)
+		}
+	}
+	if len(seen) != 4 || seen["email"] != 1 || seen["student_id"] != 1 {
+		t.Errorf("Expected each identifier sent exactly once, got %v", seen)
+	}
+
+	if !response.VerificationResult.Verified || len(response.VerificationResult.Evidence) != 4 {
+		t.Errorf("Expected merged evidence of every identifier, got %+v", response.VerificationResult)
+	}
+	summary, _ := response.Metadata["split"].(*DPSplitSummary)
+	if summary == nil || summary.Requests != len(dp.requests) || summary.MaxPayloadBytes != 700 || len(summary.JobIDs) != len(dp.requests) {
+		t.Errorf("Unexpected split summary: %+v", summary)
+	}
+
+	// Capabilities are cached between verifications
+	service.VerifyWithDP(context.Background(), newLargeRequest())
+	if dp.capabilities != 1 {
+		t.Errorf("Expected capabilities fetched once, got %d", dp.capabilities)
+	}
+}
+
+func TestDPConnectorService_ConfiguredLimitBelowAdvertised(t *testing.T) {
+	dp := &splitTestDP{advertised: 5000}
+	server := httptest.NewServer(dp)
+	defer server.Close()
+	service := newSplitTestService(t, server.URL, &DPProvider{CapabilitiesPath: "/capabilities", MaxPayloadBytes: 500})
+
+	if _, err := service.VerifyWithDP(context.Background(), newLargeRequest()); err != nil {
+		t.Fatalf("VerifyWithDP failed: %v", err)
+	}
+	for i, size := range dp.bodies {
+		if size > 500 {
+			t.Errorf("Request %d is %d bytes, over the configured limit", i, size)
+		}
+	}
+}
+
+func TestDPConnectorService_SplitsOnPayloadTooLarge(t *testing.T) {
+	dp := &splitTestDP{rejectOver: 600}
+	server := httptest.NewServer(dp)
+	defer server.Close()
+	service := newSplitTestService(t, server.URL, &DPProvider{})
+
+	response, err := service.VerifyWithDP(context.Background(), newLargeRequest())
+	if err != nil {
+		t.Fatalf("VerifyWithDP failed: %v", err)
+	}
+	if dp.rejected == 0 || !response.VerificationResult.Verified {
+		t.Fatalf("Expected a 413 followed by split requests, got %d rejections", dp.rejected)
+	}
+	if service.providerBreaker("dp-split").GetCircuitBreakerStats()["failure_count"] != 0 {
+		t.Error("Expected 413 responses not to count against the breaker")
+	}
+
+	// The learned limit splits the next verification up front
+	rejected := dp.rejected
+	if _, err := service.VerifyWithDP(context.Background(), newLargeRequest()); err != nil {
+		t.Fatalf("VerifyWithDP failed: %v", err)
+	}
+	if dp.rejected != rejected {
+		t.Errorf("Expected no further 413s once the limit was learned, got %d more", dp.rejected-rejected)
+	}
+	stats := service.GetPayloadSplitStats()
+	if learned := stats["learned_limits"].(map[string]int)["dp-split"]; learned == 0 || learned > 600 {
+		t.Errorf("Expected a learned limit, got %v", stats)
+	}
+	if stats["split_verifications"].(int64) != 2 {
+		t.Errorf("Expected two split verifications, got %v", stats["split_verifications"])
+	}
+}
+
+func TestDPConnectorService_IdentifierLargerThanLimit(t *testing.T) {
+	dp := &splitTestDP{}
+	server := httptest.NewServer(dp)
+	defer server.Close()
+	service := newSplitTestService(t, server.URL, &DPProvider{MaxPayloadBytes: 200})
+
+	_, err := service.VerifyWithDP(context.Background(), newLargeRequest())
+	var tooLarge *PayloadTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("Expected a PayloadTooLargeError, got %v", err)
+	}
+	if len(dp.requests) != 0 {
+		t.Error("Expected nothing sent to the DP")
+	}
+	if service.providerBreaker("dp-split").GetCircuitBreakerStats()["failure_count"] != 0 {
+		t.Error("Expected an oversized identifier not to count against the breaker")
+	}
+}
+
+func TestMergeSplitParts(t *testing.T) {
+	parts := []*splitPart{
+		{identifiers: []string{"email"}, response: &DPResponse{
+			JobID: "job-1", Status: DPStatusCompleted,
+			VerificationResult: &VerificationResult{Verified: true, Confidence: 0.9, Evidence: []string{"registry"}},
+		}},
+		{identifiers: []string{"phone", "student_id"}, response: &DPResponse{
+			JobID: "job-2", Status: DPStatusPartial,
+			VerificationResult: &VerificationResult{Verified: false, Confidence: 0.5, Reason: "1 of 2 identifiers matched", Evidence: []string{"registry", "roster"}},
+			PartialResults:     &models.PartialResults{Identifiers: map[string]string{"phone": models.IdentifierUnknown, "student_id": models.IdentifierMatched}},
+		}},
+	}
+
+	merged := mergeSplitParts(parts)
+	result := merged.VerificationResult
+	if result.Verified || result.Confidence != 0.5 || result.Reason != "1 of 2 identifiers matched" {
+		t.Errorf("Expected the unverified request to decide the result, got %+v", result)
+	}
+	if len(result.Evidence) != 2 {
+		t.Errorf("Expected evidence combined without duplicates, got %v", result.Evidence)
+	}
+	if merged.Status != DPStatusPartial {
+		t.Errorf("Expected a partial status, got %s", merged.Status)
+	}
+	identifiers := merged.PartialResults.Identifiers
+	if identifiers["email"] != models.IdentifierMatched || identifiers["phone"] != models.IdentifierUnknown || identifiers["student_id"] != models.IdentifierMatched {
+		t.Errorf("Unexpected merged partial results: %v", identifiers)
+	}
+	if parts[0].response.VerificationResult.Verified != true {
+		t.Error("Expected the first response left unchanged")
+	}
+}
+
+func TestFetchCapabilities_SizeLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"max_payload_bytes": 500, "padding": "`))
+		w.Write([]byte(strings.Repeat("a", maxCapabilitiesBytes)))
+		w.Write([]byte(`"}`))
+	}))
+	defer server.Close()
+	service := newSplitTestService(t, server.URL, &DPProvider{CapabilitiesPath: "/capabilities"})
+	provider, _ := service.registry.Get("dp-split")
+
+	_, err := service.fetchCapabilities(context.Background(), provider)
+	var tooLarge *ResponseTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.MaxResponseBytes != maxCapabilitiesBytes {
+		t.Errorf("Expected an oversized capabilities document to be refused, got %v", err)
+	}
+}
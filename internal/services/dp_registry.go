@@ -0,0 +1,564 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// DefaultDPProviderID identifies the provider built from DP_CONNECTOR_URL
+const DefaultDPProviderID = "dp-connector"
+
+// AnyClaimType matches every claim type in a provider's supported claims
+const AnyClaimType = "*"
+
+// DPProvider describes a Data Provider and the claim types it serves
+type DPProvider struct {
+	DPID            string          `json:"dp_id"`
+	Name            string          `json:"name,omitempty"`
+	Category        string          `json:"category,omitempty"`
+	Endpoint        string          `json:"endpoint"`
+	SupportedClaims []string        `json:"supported_claims"`
+	Priority        int             `json:"priority"`
+	AdapterType     AdapterType     `json:"adapter_type,omitempty"`
+	Auth            *DPProviderAuth `json:"auth,omitempty"`
+	TLS             *TLSSettings    `json:"tls,omitempty"`
+	Disabled        bool            `json:"disabled,omitempty"`
+	// ResponseSchema is the expected shape of the provider's responses;
+	// with QuarantineOnDrift, a drifting provider stops receiving requests
+	// until its schema is updated
+	ResponseSchema    *DPResponseSchema `json:"response_schema,omitempty"`
+	QuarantineOnDrift bool              `json:"quarantine_on_drift,omitempty"`
+	// Pagination tells the connector how to fetch the remaining pages of
+	// multi-record results
+	Pagination *DPPagination `json:"pagination,omitempty"`
+	// RequestTemplate maps the canonical request onto the provider's own
+	// field names and envelope
+	RequestTemplate *DPRequestTemplate `json:"request_template,omitempty"`
+	// MatchingMode is "hashed" (the default) or "psi"; PSI providers are
+	// sent blinded identifiers only, as configured by PSI
+	MatchingMode string         `json:"matching_mode,omitempty"`
+	PSI          *DPPSISettings `json:"psi,omitempty"`
+	// FailureClassifier decides which errors count toward the provider's
+	// circuit breaker; by default 5xx, 429, 408 and transport failures do
+	FailureClassifier *DPFailureClassifier `json:"failure_classifier,omitempty"`
+	// Breaker selects how the provider's circuit breaker decides to open;
+	// by default after consecutive failures
+	Breaker *DPBreakerSettings `json:"breaker,omitempty"`
+	// MaxConcurrent bounds the calls in flight to the provider; defaults to
+	// DP_MAX_CONCURRENT
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+	// MaxPayloadBytes caps the size of verification requests; larger ones
+	// are split by identifier. CapabilitiesPath, when set, is where the DP
+	// advertises its own limit, and the lower of the two applies.
+	MaxPayloadBytes  int    `json:"max_payload_bytes,omitempty"`
+	CapabilitiesPath string `json:"capabilities_path,omitempty"`
+	// MaxResponseBytes caps the size of the provider's responses, as sent
+	// and once decompressed; defaults to DP_MAX_RESPONSE_BYTES
+	MaxResponseBytes int `json:"max_response_bytes,omitempty"`
+	// ResponsePipeline names the transformation pipeline mapping the
+	// provider's responses into the broker's DP response shape
+	ResponsePipeline string `json:"response_pipeline,omitempty"`
+	// ClaimsPipeline names the transformation pipeline mapping the
+	// provider's responses into canonical claims
+	ClaimsPipeline string `json:"claims_pipeline,omitempty"`
+	// Calibration puts the provider's confidence values on the broker's
+	// 0-1 scale; without it they are taken as given
+	Calibration *ConfidenceCalibration `json:"calibration,omitempty"`
+}
+
+// DPProviderAuth defines how the broker authenticates to a provider.
+// Secrets may be given inline, as the name of an environment variable, or
+// as a secret: reference that is refreshed when the secret rotates.
+type DPProviderAuth struct {
+	Method       AuthMethod `json:"method"`
+	APIKey       string     `json:"api_key,omitempty"`
+	APIKeyEnv    string     `json:"api_key_env,omitempty"`
+	TokenURL     string     `json:"token_url,omitempty"`
+	ClientID     string     `json:"client_id,omitempty"`
+	ClientSecret string     `json:"client_secret,omitempty"`
+	Scopes       []string   `json:"scopes,omitempty"`
+	CertFile     string     `json:"cert_file,omitempty"`
+	KeyFile      string     `json:"key_file,omitempty"`
+	CAFile       string     `json:"ca_file,omitempty"`
+	JWTSecret    string     `json:"jwt_secret,omitempty"`
+	JWTIssuer    string     `json:"jwt_issuer,omitempty"`
+	JWTAudience  string     `json:"jwt_audience,omitempty"`
+	// SigV4 signing; without static keys, credentials come from the AWS
+	// provider chain (environment, shared file, container, instance role)
+	Region             string `json:"region,omitempty"`
+	Service            string `json:"service,omitempty"`
+	AWSAccessKeyID     string `json:"aws_access_key_id,omitempty"`
+	AWSSecretAccessKey string `json:"aws_secret_access_key,omitempty"`
+	AWSProfile         string `json:"aws_profile,omitempty"`
+	// Token exchange (RFC 8693) uses token_url, client_id, client_secret and
+	// scopes, where scopes may contain {claim_type} and {dp_id}
+	Audience         string `json:"audience,omitempty"`
+	Resource         string `json:"resource,omitempty"`
+	SubjectTokenType string `json:"subject_token_type,omitempty"`
+	// DPoP sender-constrains the bearer token of oauth2, jwt and
+	// token_exchange auth with a proof signed by the broker's DPoP key
+	DPoP bool `json:"dpop,omitempty"`
+	// Credentials are sent in addition to the method's own, e.g. a tenant
+	// key header alongside OAuth 2.0; with method "custom" they are the only
+	// authentication
+	Credentials []DPAuthCredential `json:"credentials,omitempty"`
+}
+
+// DPAuthCredential is a credential sent in a header or query parameter. The
+// value is given inline, or read from an environment variable or file.
+type DPAuthCredential struct {
+	In        string `json:"in"`
+	Name      string `json:"name"`
+	Prefix    string `json:"prefix,omitempty"`
+	Value     string `json:"value,omitempty"`
+	ValueEnv  string `json:"value_env,omitempty"`
+	ValueFile string `json:"value_file,omitempty"`
+}
+
+// DPRegistryFile is the on-disk format of the DP registry
+type DPRegistryFile struct {
+	Providers []*DPProvider `json:"providers"`
+	// Freshness bounds how long each claim type's results are cached and
+	// valid
+	Freshness map[string]FreshnessPolicy `json:"freshness,omitempty"`
+}
+
+// DPRegistry maps claim types to the providers able to verify them
+type DPRegistry struct {
+	mu        sync.RWMutex
+	providers map[string]*DPProvider
+	freshness map[string]FreshnessPolicy
+}
+
+// NewDPRegistry creates an empty DP registry
+func NewDPRegistry() *DPRegistry {
+	return &DPRegistry{
+		providers: make(map[string]*DPProvider),
+	}
+}
+
+// LoadDPRegistry builds the registry from DP_REGISTRY_FILE, or from the single
+// DP_CONNECTOR_URL when no registry file is configured
+func LoadDPRegistry(cfg *config.Config) (*DPRegistry, error) {
+	registry := NewDPRegistry()
+
+	if cfg.DPRegistryFile == "" {
+		if err := registry.Register(DefaultDPProvider(cfg)); err != nil {
+			return nil, err
+		}
+		return registry, nil
+	}
+
+	data, err := os.ReadFile(cfg.DPRegistryFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DP registry file: %w", err)
+	}
+
+	var file DPRegistryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse DP registry file: %w", err)
+	}
+
+	if len(file.Providers) == 0 {
+		return nil, fmt.Errorf("DP registry file defines no providers")
+	}
+
+	for _, provider := range file.Providers {
+		// Credentials may be committed as enc:v1 values
+		if provider.Auth != nil {
+			if err := config.DecryptFields(cfg.KeyProvider, provider.Auth); err != nil {
+				return nil, fmt.Errorf("DP provider %s auth: %w", provider.DPID, err)
+			}
+		}
+		if err := registry.Register(provider); err != nil {
+			return nil, err
+		}
+	}
+	if err := registry.SetFreshness(file.Freshness); err != nil {
+		return nil, err
+	}
+
+	return registry, nil
+}
+
+// DefaultDPProvider returns a provider serving every claim type via DP_CONNECTOR_URL
+func DefaultDPProvider(cfg *config.Config) *DPProvider {
+	provider := &DPProvider{
+		DPID:            DefaultDPProviderID,
+		Name:            "DP Connector",
+		Endpoint:        cfg.DPConnectorURL,
+		SupportedClaims: []string{AnyClaimType},
+		AdapterType:     AdapterTypeREST,
+	}
+
+	if cfg.DPConnectorToken != "" {
+		provider.Auth = &DPProviderAuth{
+			Method: AuthMethodAPIKey,
+			APIKey: cfg.DPConnectorToken,
+		}
+	}
+
+	return provider
+}
+
+// Register validates and adds a provider to the registry
+func (r *DPRegistry) Register(provider *DPProvider) error {
+	if err := provider.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.providers[provider.DPID]; exists {
+		return fmt.Errorf("duplicate DP provider: %s", provider.DPID)
+	}
+
+	r.providers[provider.DPID] = provider
+	return nil
+}
+
+// Remove deletes a provider from the registry
+func (r *DPRegistry) Remove(dpID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.providers[dpID]; !exists {
+		return false
+	}
+	delete(r.providers, dpID)
+	return true
+}
+
+// Get returns a provider by ID
+func (r *DPRegistry) Get(dpID string) (*DPProvider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	provider, exists := r.providers[dpID]
+	return provider, exists
+}
+
+// List returns all registered providers ordered by priority
+func (r *DPRegistry) List() []*DPProvider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	providers := make([]*DPProvider, 0, len(r.providers))
+	for _, provider := range r.providers {
+		providers = append(providers, provider)
+	}
+	sortProviders(providers)
+	return providers
+}
+
+// ProvidersForClaim returns the enabled providers for a claim type, lowest
+// priority value first. Providers listing the claim explicitly are preferred
+// over wildcard providers with the same priority.
+func (r *DPRegistry) ProvidersForClaim(claimType string) []*DPProvider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var providers []*DPProvider
+	for _, provider := range r.providers {
+		if !provider.Disabled && provider.Supports(claimType) {
+			providers = append(providers, provider)
+		}
+	}
+
+	sort.SliceStable(providers, func(i, j int) bool {
+		if providers[i].Priority != providers[j].Priority {
+			return providers[i].Priority < providers[j].Priority
+		}
+		iExplicit := providers[i].supportsExplicitly(claimType)
+		if iExplicit != providers[j].supportsExplicitly(claimType) {
+			return iExplicit
+		}
+		return providers[i].DPID < providers[j].DPID
+	})
+
+	return providers
+}
+
+// Validate checks that a provider definition is usable
+func (p *DPProvider) Validate() error {
+	if p == nil {
+		return fmt.Errorf("DP provider cannot be nil")
+	}
+	if p.DPID == "" {
+		return fmt.Errorf("DP provider dp_id is required")
+	}
+	if p.Endpoint == "" {
+		return fmt.Errorf("DP provider %s: endpoint is required", p.DPID)
+	}
+
+	endpoint, err := url.Parse(p.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return fmt.Errorf("DP provider %s: invalid endpoint %q", p.DPID, p.Endpoint)
+	}
+
+	if len(p.SupportedClaims) == 0 {
+		return fmt.Errorf("DP provider %s: at least one supported claim is required", p.DPID)
+	}
+
+	switch p.AdapterType {
+	case "":
+		p.AdapterType = AdapterTypeREST
+	case AdapterTypeREST, AdapterTypeGraphQL, AdapterTypeGRPC, AdapterTypeWebSocket:
+	default:
+		return fmt.Errorf("DP provider %s: unsupported adapter type %s", p.DPID, p.AdapterType)
+	}
+
+	if p.TLS != nil {
+		if err := p.TLS.Validate(nil); err != nil {
+			return fmt.Errorf("DP provider %s: %w", p.DPID, err)
+		}
+	}
+
+	if p.ResponseSchema != nil {
+		if err := p.ResponseSchema.Validate(); err != nil {
+			return fmt.Errorf("DP provider %s: %w", p.DPID, err)
+		}
+	}
+
+	if p.Pagination != nil {
+		if err := p.Pagination.Validate(); err != nil {
+			return fmt.Errorf("DP provider %s: %w", p.DPID, err)
+		}
+	}
+
+	if p.RequestTemplate != nil {
+		if err := p.RequestTemplate.Validate(); err != nil {
+			return fmt.Errorf("DP provider %s: %w", p.DPID, err)
+		}
+	}
+
+	if p.FailureClassifier != nil {
+		if err := p.FailureClassifier.Validate(); err != nil {
+			return fmt.Errorf("DP provider %s: %w", p.DPID, err)
+		}
+	}
+
+	if p.Breaker != nil {
+		if err := p.Breaker.Validate(); err != nil {
+			return fmt.Errorf("DP provider %s: %w", p.DPID, err)
+		}
+	}
+
+	if p.Calibration != nil {
+		if err := p.Calibration.Validate(); err != nil {
+			return fmt.Errorf("DP provider %s: %w", p.DPID, err)
+		}
+	}
+
+	if p.MaxConcurrent < 0 {
+		return fmt.Errorf("DP provider %s: max_concurrent must not be negative", p.DPID)
+	}
+
+	if p.MaxPayloadBytes < 0 {
+		return fmt.Errorf("DP provider %s: max_payload_bytes must not be negative", p.DPID)
+	}
+	if p.CapabilitiesPath != "" && !strings.HasPrefix(p.CapabilitiesPath, "/") {
+		return fmt.Errorf("DP provider %s: capabilities_path must start with /", p.DPID)
+	}
+
+	switch p.MatchingMode {
+	case "", MatchingModeHashed:
+	case MatchingModePSI:
+		if p.PSI == nil {
+			return fmt.Errorf("DP provider %s: psi matching requires psi settings", p.DPID)
+		}
+		if err := p.PSI.Validate(); err != nil {
+			return fmt.Errorf("DP provider %s: %w", p.DPID, err)
+		}
+		if p.AdapterType != AdapterTypeREST || p.RequestTemplate != nil || p.Pagination != nil {
+			return fmt.Errorf("DP provider %s: psi matching is only supported for REST providers without request templates or pagination", p.DPID)
+		}
+	default:
+		return fmt.Errorf("DP provider %s: unsupported matching mode %q", p.DPID, p.MatchingMode)
+	}
+
+	if p.Auth != nil {
+		switch p.Auth.Method {
+		case AuthMethodAPIKey, AuthMethodOAuth2, AuthMethodMTLS, AuthMethodJWT, AuthMethodNone:
+		case AuthMethodCustom:
+			if len(p.Auth.Credentials) == 0 {
+				return fmt.Errorf("DP provider %s: custom auth requires credentials", p.DPID)
+			}
+		case AuthMethodTokenExchange:
+			tokenURL, err := url.Parse(p.Auth.TokenURL)
+			if err != nil || tokenURL.Scheme == "" || tokenURL.Host == "" {
+				return fmt.Errorf("DP provider %s: token_exchange auth requires a token_url", p.DPID)
+			}
+		case AuthMethodSigV4:
+			if p.Auth.Region == "" || p.Auth.Service == "" {
+				return fmt.Errorf("DP provider %s: sigv4 auth requires region and service", p.DPID)
+			}
+			if (p.Auth.AWSAccessKeyID == "") != (p.Auth.AWSSecretAccessKey == "") {
+				return fmt.Errorf("DP provider %s: sigv4 static keys need both aws_access_key_id and aws_secret_access_key", p.DPID)
+			}
+		default:
+			return fmt.Errorf("DP provider %s: unsupported auth method %s", p.DPID, p.Auth.Method)
+		}
+		if p.Auth.DPoP && p.Auth.Method != AuthMethodOAuth2 && p.Auth.Method != AuthMethodJWT && p.Auth.Method != AuthMethodTokenExchange {
+			return fmt.Errorf("DP provider %s: dpop requires oauth2, jwt or token_exchange auth", p.DPID)
+		}
+		for i, credential := range p.Auth.Credentials {
+			if err := credential.validate(); err != nil {
+				return fmt.Errorf("DP provider %s: credential %d: %w", p.DPID, i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Supports reports whether the provider can verify the claim type
+func (p *DPProvider) Supports(claimType string) bool {
+	for _, claim := range p.SupportedClaims {
+		if claim == claimType || claim == AnyClaimType {
+			return true
+		}
+	}
+	return false
+}
+
+// supportsExplicitly reports whether the claim type is listed by name
+func (p *DPProvider) supportsExplicitly(claimType string) bool {
+	for _, claim := range p.SupportedClaims {
+		if claim == claimType {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthenticationConfig converts the provider auth settings for the Authenticator
+func (p *DPProvider) AuthenticationConfig() *AuthenticationConfig {
+	if p.Auth == nil {
+		return &AuthenticationConfig{AuthMethod: AuthMethodNone}
+	}
+
+	authConfig := &AuthenticationConfig{
+		AuthMethod: p.Auth.Method,
+		APIKey:     p.Auth.APIKey,
+		DPoP:       p.Auth.DPoP,
+	}
+	if p.Auth.APIKeyEnv != "" {
+		authConfig.APIKey = os.Getenv(p.Auth.APIKeyEnv)
+	}
+
+	switch p.Auth.Method {
+	case AuthMethodOAuth2:
+		authConfig.OAuth2 = &OAuth2Config{
+			ClientID:     p.Auth.ClientID,
+			ClientSecret: p.Auth.ClientSecret,
+			TokenURL:     p.Auth.TokenURL,
+			Scopes:       p.Auth.Scopes,
+		}
+	case AuthMethodMTLS:
+		authConfig.MTLS = &MTLSConfig{
+			CertFile: p.Auth.CertFile,
+			KeyFile:  p.Auth.KeyFile,
+			CAFile:   p.Auth.CAFile,
+		}
+	case AuthMethodJWT:
+		authConfig.JWT = &JWTConfig{
+			Secret:   p.Auth.JWTSecret,
+			Issuer:   p.Auth.JWTIssuer,
+			Audience: p.Auth.JWTAudience,
+		}
+	case AuthMethodTokenExchange:
+		authConfig.TokenExchange = &TokenExchangeConfig{
+			DPID:             p.DPID,
+			TokenURL:         p.Auth.TokenURL,
+			ClientID:         p.Auth.ClientID,
+			ClientSecret:     p.Auth.ClientSecret,
+			Audience:         p.Auth.Audience,
+			Resource:         p.Auth.Resource,
+			Scopes:           p.Auth.Scopes,
+			SubjectTokenType: p.Auth.SubjectTokenType,
+		}
+	case AuthMethodSigV4:
+		authConfig.SigV4 = &SigV4Config{
+			Region:          p.Auth.Region,
+			Service:         p.Auth.Service,
+			AccessKeyID:     p.Auth.AWSAccessKeyID,
+			SecretAccessKey: p.Auth.AWSSecretAccessKey,
+			Profile:         p.Auth.AWSProfile,
+		}
+	}
+
+	for _, credential := range p.Auth.Credentials {
+		value, err := credential.resolve()
+		if err != nil {
+			// Left empty, the credential fails each request to the DP
+			fmt.Printf("DP REGISTRY WARNING: DP %s credential %s: %v\n", p.DPID, credential.Name, err)
+		}
+		authConfig.Credentials = append(authConfig.Credentials, AuthCredential{
+			In:     credential.In,
+			Name:   credential.Name,
+			Prefix: credential.Prefix,
+			Value:  value,
+		})
+	}
+
+	return authConfig
+}
+
+// validate checks the credential's location, name and value source
+func (c DPAuthCredential) validate() error {
+	if c.In != CredentialInHeader && c.In != CredentialInQuery {
+		return fmt.Errorf("location must be %q or %q", CredentialInHeader, CredentialInQuery)
+	}
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	sources := 0
+	for _, source := range []string{c.Value, c.ValueEnv, c.ValueFile} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("exactly one of value, value_env and value_file is required")
+	}
+	return nil
+}
+
+// resolve returns the credential's value from its source
+func (c DPAuthCredential) resolve() (string, error) {
+	switch {
+	case c.ValueEnv != "":
+		value := os.Getenv(c.ValueEnv)
+		if value == "" {
+			return "", fmt.Errorf("environment variable %s is not set", c.ValueEnv)
+		}
+		return value, nil
+	case c.ValueFile != "":
+		data, err := os.ReadFile(c.ValueFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read value file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	default:
+		return c.Value, nil
+	}
+}
+
+// sortProviders orders providers by priority, then ID
+func sortProviders(providers []*DPProvider) {
+	sort.Slice(providers, func(i, j int) bool {
+		if providers[i].Priority != providers[j].Priority {
+			return providers[i].Priority < providers[j].Priority
+		}
+		return providers[i].DPID < providers[j].DPID
+	})
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestLoadDPRegistry_DefaultProvider(t *testing.T) {
+	cfg := &config.Config{DPConnectorURL: "http://dp-connector:8080", DPConnectorToken: "token"}
+
+	registry, err := LoadDPRegistry(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	providers := registry.ProvidersForClaim("age_verification")
+	if len(providers) != 1 || providers[0].DPID != DefaultDPProviderID {
+		t.Fatalf("Expected default provider for any claim, got %v", providers)
+	}
+	if providers[0].AuthenticationConfig().APIKey != "token" {
+		t.Error("Expected DP connector token to be used for the default provider")
+	}
+}
+
+func TestLoadDPRegistry_File(t *testing.T) {
+	os.Setenv("TEST_DP_UNIVERSITY_KEY", "secret")
+	defer os.Unsetenv("TEST_DP_UNIVERSITY_KEY")
+
+	file := DPRegistryFile{Providers: []*DPProvider{
+		{DPID: "dp_university", Endpoint: "https://university.example.com", SupportedClaims: []string{"student_verification"}, Priority: 1,
+			Auth: &DPProviderAuth{Method: AuthMethodAPIKey, APIKeyEnv: "TEST_DP_UNIVERSITY_KEY"}},
+		{DPID: "dp_backup", Endpoint: "https://backup.example.com", SupportedClaims: []string{AnyClaimType}, Priority: 1},
+		{DPID: "dp_dmv", Endpoint: "https://dmv.example.com", SupportedClaims: []string{"age_verification"}, Priority: 0},
+		{DPID: "dp_disabled", Endpoint: "https://old.example.com", SupportedClaims: []string{"student_verification"}, Disabled: true},
+	}}
+	data, _ := json.Marshal(file)
+	path := filepath.Join(t.TempDir(), "registry.json")
+	os.WriteFile(path, data, 0600)
+
+	registry, err := LoadDPRegistry(&config.Config{DPRegistryFile: path})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	students := registry.ProvidersForClaim("student_verification")
+	if len(students) != 2 || students[0].DPID != "dp_university" || students[1].DPID != "dp_backup" {
+		t.Errorf("Expected explicit provider before wildcard at equal priority, got %v", providerIDs(students))
+	}
+
+	ages := registry.ProvidersForClaim("age_verification")
+	if len(ages) != 2 || ages[0].DPID != "dp_dmv" {
+		t.Errorf("Expected lower priority value first, got %v", providerIDs(ages))
+	}
+
+	university, _ := registry.Get("dp_university")
+	if university.AdapterType != AdapterTypeREST {
+		t.Errorf("Expected adapter type to default to rest, got %s", university.AdapterType)
+	}
+	if university.AuthenticationConfig().APIKey != "secret" {
+		t.Error("Expected API key to be read from the environment")
+	}
+}
+
+func TestLoadDPRegistry_EncryptedCredentials(t *testing.T) {
+	keyProvider, err := config.NewLocalKeyProvider(map[string][]byte{"ops": make([]byte, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiKey, err := config.EncryptValue(keyProvider, "ops", "university-secret")
+	if err != nil {
+		t.Fatalf("Expected encryption to succeed, got %v", err)
+	}
+	if !config.IsEncryptedValue(apiKey) || strings.Contains(apiKey, "university-secret") {
+		t.Fatalf("Expected an opaque enc:v1 value, got %s", apiKey)
+	}
+
+	file := DPRegistryFile{Providers: []*DPProvider{
+		{DPID: "dp_university", Endpoint: "https://university.example.com", SupportedClaims: []string{"student_verification"},
+			Auth: &DPProviderAuth{Method: AuthMethodAPIKey, APIKey: apiKey}},
+	}}
+	data, _ := json.Marshal(file)
+	path := filepath.Join(t.TempDir(), "registry.json")
+	os.WriteFile(path, data, 0600)
+
+	registry, err := LoadDPRegistry(&config.Config{DPRegistryFile: path, KeyProvider: keyProvider})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	university, _ := registry.Get("dp_university")
+	if university.AuthenticationConfig().APIKey != "university-secret" {
+		t.Error("Expected API key to be decrypted at load time")
+	}
+
+	// Without a key provider the encrypted value must not be used as-is
+	if _, err := LoadDPRegistry(&config.Config{DPRegistryFile: path}); err == nil {
+		t.Error("Expected error when no key provider is configured")
+	}
+
+	// A different master key fails authentication
+	otherProvider, _ := config.NewLocalKeyProvider(map[string][]byte{"ops": []byte(strings.Repeat("k", 32))})
+	if _, err := config.DecryptValue(otherProvider, apiKey); err == nil {
+		t.Error("Expected decryption with the wrong master key to fail")
+	}
+}
+
+func TestLoadDPRegistry_CustomCredentials(t *testing.T) {
+	keyProvider, _ := config.NewLocalKeyProvider(map[string][]byte{"ops": make([]byte, 32)})
+	tenantKey, _ := config.EncryptValue(keyProvider, "ops", "tenant-secret")
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "signature"), []byte("file-secret\n"), 0600)
+	t.Setenv("TEST_DP_QUERY_KEY", "env-secret")
+
+	file := DPRegistryFile{Providers: []*DPProvider{
+		{DPID: "dp_custom", Endpoint: "https://custom.example.com", SupportedClaims: []string{"age_verification"},
+			Auth: &DPProviderAuth{Method: AuthMethodCustom, Credentials: []DPAuthCredential{
+				{In: CredentialInHeader, Name: "X-Tenant-Key", Value: tenantKey},
+				{In: CredentialInQuery, Name: "api_key", ValueEnv: "TEST_DP_QUERY_KEY"},
+				{In: CredentialInHeader, Name: "X-Signature", Prefix: "Sig ", ValueFile: filepath.Join(dir, "signature")},
+			}}},
+	}}
+	data, _ := json.Marshal(file)
+	path := filepath.Join(dir, "registry.json")
+	os.WriteFile(path, data, 0600)
+
+	registry, err := LoadDPRegistry(&config.Config{DPRegistryFile: path, KeyProvider: keyProvider})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	provider, _ := registry.Get("dp_custom")
+	credentials := provider.AuthenticationConfig().Credentials
+	if len(credentials) != 3 || credentials[0].Value != "tenant-secret" || credentials[1].Value != "env-secret" || credentials[2].Value != "file-secret" {
+		t.Errorf("Unexpected resolved credentials: %+v", credentials)
+	}
+}
+
+func TestDPRegistry_RegisterValidation(t *testing.T) {
+	registry := NewDPRegistry()
+
+	tests := []struct {
+		name     string
+		provider *DPProvider
+	}{
+		{"missing id", &DPProvider{Endpoint: "https://dp.example.com", SupportedClaims: []string{"age_verification"}}},
+		{"invalid endpoint", &DPProvider{DPID: "dp_1", Endpoint: "dp.example.com", SupportedClaims: []string{"age_verification"}}},
+		{"no claims", &DPProvider{DPID: "dp_1", Endpoint: "https://dp.example.com"}},
+		{"unknown adapter", &DPProvider{DPID: "dp_1", Endpoint: "https://dp.example.com", SupportedClaims: []string{"age_verification"}, AdapterType: "soap"}},
+		{"unknown auth", &DPProvider{DPID: "dp_1", Endpoint: "https://dp.example.com", SupportedClaims: []string{"age_verification"}, Auth: &DPProviderAuth{Method: "basic"}}},
+		{"insecure TLS", &DPProvider{DPID: "dp_1", Endpoint: "https://dp.example.com", SupportedClaims: []string{"age_verification"}, TLS: &TLSSettings{MinVersion: "1.0"}}},
+		{"custom auth without credentials", &DPProvider{DPID: "dp_1", Endpoint: "https://dp.example.com", SupportedClaims: []string{"age_verification"}, Auth: &DPProviderAuth{Method: AuthMethodCustom}}},
+		{"credential location", &DPProvider{DPID: "dp_1", Endpoint: "https://dp.example.com", SupportedClaims: []string{"age_verification"}, Auth: &DPProviderAuth{Method: AuthMethodCustom, Credentials: []DPAuthCredential{{In: "cookie", Name: "k", Value: "v"}}}}},
+		{"credential sources", &DPProvider{DPID: "dp_1", Endpoint: "https://dp.example.com", SupportedClaims: []string{"age_verification"}, Auth: &DPProviderAuth{Method: AuthMethodCustom, Credentials: []DPAuthCredential{{In: CredentialInHeader, Name: "k", Value: "v", ValueEnv: "K"}}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := registry.Register(tt.provider); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+
+	valid := &DPProvider{DPID: "dp_1", Endpoint: "https://dp.example.com", SupportedClaims: []string{"age_verification"}}
+	if err := registry.Register(valid); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := registry.Register(valid); err == nil {
+		t.Error("Expected duplicate provider error")
+	}
+	if !registry.Remove("dp_1") || registry.Remove("dp_1") {
+		t.Error("Expected provider to be removed once")
+	}
+}
+
+func TestDPConnectorService_RoutesByClaimType(t *testing.T) {
+	var primaryCalls, backupCalls int
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryCalls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer primary.Close()
+
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backupCalls++
+		if r.Header.Get("X-API-Key") != "backup-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(DPResponse{JobID: "job_1", Status: "completed", Timestamp: "2025-08-02T07:00:00Z"})
+	}))
+	defer backup.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: "http://localhost:1"})
+	service.retryConfig.MaxRetries = 0
+	service.registry = NewDPRegistry()
+	service.registry.Register(&DPProvider{DPID: "dp_primary", Endpoint: primary.URL, SupportedClaims: []string{"student_verification"}, Priority: 0})
+	service.registry.Register(&DPProvider{DPID: "dp_backup", Endpoint: backup.URL, SupportedClaims: []string{"student_verification"}, Priority: 1,
+		Auth: &DPProviderAuth{Method: AuthMethodAPIKey, APIKey: "backup-key"}})
+
+	response, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "student_verification"})
+	if err != nil {
+		t.Fatalf("Expected failover to succeed, got %v", err)
+	}
+	if response.DPID != "dp_backup" {
+		t.Errorf("Expected response from dp_backup, got %s", response.DPID)
+	}
+	if primaryCalls != 1 || backupCalls != 1 {
+		t.Errorf("Expected one call per provider, got primary=%d backup=%d", primaryCalls, backupCalls)
+	}
+	if service.providerBreaker("dp_primary").failureCount != 1 {
+		t.Error("Expected failure to be recorded against the primary provider only")
+	}
+
+	if _, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{ClaimType: "age_verification"}); err == nil {
+		t.Error("Expected error for claim type without providers")
+	}
+}
+
+func TestNewDPConnectorService_InvalidRegistryFileRoutesNowhere(t *testing.T) {
+	var calls int
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(DPResponse{JobID: "job_1", Status: "completed", Timestamp: "2025-08-02T07:00:00Z"})
+	}))
+	defer dp.Close()
+
+	path := filepath.Join(t.TempDir(), "registry.json")
+	os.WriteFile(path, []byte(`{"providers": [`), 0600)
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: dp.URL, DPRegistryFile: path})
+	service.retryConfig.MaxRetries = 0
+	if providers := service.registry.List(); len(providers) != 0 {
+		t.Fatalf("Expected no providers from an invalid registry file, got %v", providerIDs(providers))
+	}
+	if _, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{ClaimType: "age_verification"}); err == nil {
+		t.Error("Expected error for claim type without providers")
+	}
+	if calls != 0 {
+		t.Errorf("Expected DP_CONNECTOR_URL not to be called, got %d calls", calls)
+	}
+}
+
+func providerIDs(providers []*DPProvider) []string {
+	ids := make([]string, len(providers))
+	for i, provider := range providers {
+		ids[i] = provider.DPID
+	}
+	return ids
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Request template types
+const (
+	RequestTemplateGo        = "go_template"
+	RequestTemplateJSONPatch = "json_patch"
+)
+
+// DPRequestTemplate reshapes the canonical PrivacyRequest into the body a
+// provider expects, either by rendering a Go template over the request's JSON
+// fields (.rp_id, .claim_type, .hashed_identifiers.email, ...) or by applying
+// an RFC 6902 JSON patch to it. Page parameters are added to the canonical
+// request before it is reshaped, so templates can place .cursor, .offset and
+// .limit too.
+type DPRequestTemplate struct {
+	Type     string        `json:"type"`
+	Template string        `json:"template,omitempty"`
+	Patch    []JSONPatchOp `json:"patch,omitempty"`
+	compiled *template.Template
+}
+
+// JSONPatchOp is one RFC 6902 operation
+type JSONPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// requestTemplateFuncs are available to Go templates. "json" encodes a value,
+// so templates can emit nested objects and quoted strings safely.
+var requestTemplateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+// Validate compiles the template or checks the patch operations
+func (t *DPRequestTemplate) Validate() error {
+	switch t.Type {
+	case RequestTemplateGo:
+		if t.Template == "" {
+			return fmt.Errorf("request template is empty")
+		}
+		compiled, err := template.New("request").Funcs(requestTemplateFuncs).Parse(t.Template)
+		if err != nil {
+			return fmt.Errorf("invalid request template: %w", err)
+		}
+		t.compiled = compiled
+	case RequestTemplateJSONPatch:
+		if len(t.Patch) == 0 {
+			return fmt.Errorf("request patch has no operations")
+		}
+		for i, op := range t.Patch {
+			switch op.Op {
+			case "add", "remove", "replace", "test":
+			case "move", "copy":
+				if _, err := parseJSONPointer(op.From); err != nil {
+					return fmt.Errorf("request patch operation %d: %w", i, err)
+				}
+			default:
+				return fmt.Errorf("request patch operation %d: unsupported op %q", i, op.Op)
+			}
+			if _, err := parseJSONPointer(op.Path); err != nil {
+				return fmt.Errorf("request patch operation %d: %w", i, err)
+			}
+		}
+	default:
+		return fmt.Errorf("request template type must be %q or %q", RequestTemplateGo, RequestTemplateJSONPatch)
+	}
+	return nil
+}
+
+// Render reshapes a canonical request payload into the provider's body
+func (t *DPRequestTemplate) Render(payload []byte) ([]byte, error) {
+	var request interface{}
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return nil, fmt.Errorf("failed to decode canonical request: %w", err)
+	}
+
+	if t.Type == RequestTemplateJSONPatch {
+		patched, err := applyJSONPatch(request, t.Patch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply request patch: %w", err)
+		}
+		return json.Marshal(patched)
+	}
+
+	if t.compiled == nil {
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	if err := t.compiled.Execute(&buf, request); err != nil {
+		return nil, fmt.Errorf("failed to render request template: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("request template did not render valid JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+// renderRequest applies the provider's request template, if any
+func (p *DPProvider) renderRequest(payload []byte) ([]byte, error) {
+	if p.RequestTemplate == nil {
+		return payload, nil
+	}
+	rendered, err := p.RequestTemplate.Render(payload)
+	if err != nil {
+		return nil, fmt.Errorf("DP %s: %w", p.DPID, err)
+	}
+	return rendered, nil
+}
+
+// applyJSONPatch applies RFC 6902 operations to a decoded JSON document
+func applyJSONPatch(document interface{}, ops []JSONPatchOp) (interface{}, error) {
+	for i, op := range ops {
+		path, err := parseJSONPointer(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+
+		switch op.Op {
+		case "add":
+			document, err = jsonPointerAdd(document, path, op.Value)
+		case "remove":
+			document, _, err = jsonPointerRemove(document, path)
+		case "replace":
+			if document, _, err = jsonPointerRemove(document, path); err == nil {
+				document, err = jsonPointerAdd(document, path, op.Value)
+			}
+		case "move", "copy":
+			from, ferr := parseJSONPointer(op.From)
+			if ferr != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, ferr)
+			}
+			var value interface{}
+			if op.Op == "move" {
+				document, value, err = jsonPointerRemove(document, from)
+			} else {
+				value, err = jsonPointerGet(document, from)
+				value = copyJSONValue(value)
+			}
+			if err == nil {
+				document, err = jsonPointerAdd(document, path, value)
+			}
+		case "test":
+			var value interface{}
+			if value, err = jsonPointerGet(document, path); err == nil && !jsonValuesEqual(value, op.Value) {
+				err = fmt.Errorf("test failed at %s", op.Path)
+			}
+		default:
+			err = fmt.Errorf("unsupported op %q", op.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return document, nil
+}
+
+// parseJSONPointer splits an RFC 6901 pointer into unescaped tokens
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func jsonPointerGet(document interface{}, path []string) (interface{}, error) {
+	current := document
+	for _, token := range path {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, exists := node[token]
+			if !exists {
+				return nil, fmt.Errorf("path member %q does not exist", token)
+			}
+			current = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("path member %q is not inside an object or array", token)
+		}
+	}
+	return current, nil
+}
+
+// jsonPointerAdd adds a value, creating it in an object or inserting it into
+// an array ("-" appends), and returns the updated document
+func jsonPointerAdd(document interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := jsonPointerGet(document, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+		return document, nil
+	case []interface{}:
+		index := len(node)
+		if last != "-" {
+			if index, err = arrayIndex(last, len(node)); err != nil {
+				return nil, err
+			}
+		}
+		updated := append(node[:index:index], append([]interface{}{value}, node[index:]...)...)
+		return jsonPointerSet(document, path[:len(path)-1], updated)
+	default:
+		return nil, fmt.Errorf("cannot add %q to a non-container value", last)
+	}
+}
+
+// jsonPointerRemove removes a value and returns the updated document and the
+// removed value
+func jsonPointerRemove(document interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, document, nil
+	}
+	parent, err := jsonPointerGet(document, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		value, exists := node[last]
+		if !exists {
+			return nil, nil, fmt.Errorf("path member %q does not exist", last)
+		}
+		delete(node, last)
+		return document, value, nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(node)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := node[index]
+		updated := append(node[:index:index], node[index+1:]...)
+		document, err = jsonPointerSet(document, path[:len(path)-1], updated)
+		return document, value, err
+	default:
+		return nil, nil, fmt.Errorf("cannot remove %q from a non-container value", last)
+	}
+}
+
+// jsonPointerSet replaces the value at a path; arrays are rebuilt when they
+// grow or shrink, so their parent must point at the new slice
+func jsonPointerSet(document interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := jsonPointerGet(document, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+	case []interface{}:
+		index, err := arrayIndex(last, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = value
+	}
+	return document, nil
+}
+
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return index, nil
+}
+
+func copyJSONValue(value interface{}) interface{} {
+	data, _ := json.Marshal(value)
+	var copied interface{}
+	json.Unmarshal(data, &copied)
+	return copied
+}
+
+func jsonValuesEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(copyJSONValue(a), copyJSONValue(b))
+}
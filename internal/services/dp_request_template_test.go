@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestDPRequestTemplate_GoTemplate(t *testing.T) {
+	tmpl := &DPRequestTemplate{
+		Type:     RequestTemplateGo,
+		Template: `{"envelope": {"client": {{json .rp_id}}, "check": {{json .claim_type}}, "email_hash": {{json .hashed_identifiers.email}}}}`,
+	}
+	if err := tmpl.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	payload, _ := json.Marshal(models.PrivacyRequest{RPID: `rp_"1"`, ClaimType: "student_verification", HashedIdentifiers: map[string]string{"email": "abc"}})
+	rendered, err := tmpl.Render(payload)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	var body map[string]map[string]interface{}
+	if err := json.Unmarshal(rendered, &body); err != nil {
+		t.Fatalf("Rendered body is not JSON: %s", rendered)
+	}
+	envelope := body["envelope"]
+	if envelope["client"] != `rp_"1"` || envelope["check"] != "student_verification" || envelope["email_hash"] != "abc" {
+		t.Errorf("Unexpected rendered body: %s", rendered)
+	}
+
+	invalid := &DPRequestTemplate{Type: RequestTemplateGo, Template: `{"client": {{.rp_id}}}`}
+	if _, err := invalid.Render(payload); err == nil {
+		t.Error("Expected a template rendering invalid JSON to fail")
+	}
+	if err := (&DPRequestTemplate{Type: RequestTemplateGo, Template: `{{.rp_id`}).Validate(); err == nil {
+		t.Error("Expected an unparsable template to be rejected")
+	}
+}
+
+func TestDPRequestTemplate_JSONPatch(t *testing.T) {
+	tmpl := &DPRequestTemplate{
+		Type: RequestTemplateJSONPatch,
+		Patch: []JSONPatchOp{
+			{Op: "test", Path: "/claim_type", Value: "student_verification"},
+			{Op: "move", From: "/rp_id", Path: "/clientId"},
+			{Op: "copy", From: "/hashed_identifiers/email", Path: "/emailHash"},
+			{Op: "remove", Path: "/hashed_identifiers"},
+			{Op: "replace", Path: "/claim_type", Value: "ENROLLMENT"},
+			{Op: "add", Path: "/tags", Value: []interface{}{"b"}},
+			{Op: "add", Path: "/tags/0", Value: "a"},
+			{Op: "add", Path: "/tags/-", Value: "c"},
+			{Op: "add", Path: "/a~1b", Value: true},
+		},
+	}
+	if err := tmpl.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	payload, _ := json.Marshal(models.PrivacyRequest{RPID: "rp_1", ClaimType: "student_verification", HashedIdentifiers: map[string]string{"email": "abc"}})
+	rendered, err := tmpl.Render(payload)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	var body map[string]interface{}
+	json.Unmarshal(rendered, &body)
+
+	if body["clientId"] != "rp_1" || body["emailHash"] != "abc" || body["claim_type"] != "ENROLLMENT" || body["a/b"] != true {
+		t.Errorf("Unexpected patched body: %s", rendered)
+	}
+	if _, exists := body["rp_id"]; exists {
+		t.Errorf("Expected rp_id to be moved: %s", rendered)
+	}
+	if _, exists := body["hashed_identifiers"]; exists {
+		t.Errorf("Expected hashed_identifiers to be removed: %s", rendered)
+	}
+	if tags, _ := json.Marshal(body["tags"]); string(tags) != `["a","b","c"]` {
+		t.Errorf("Unexpected tags: %s", tags)
+	}
+
+	failing := &DPRequestTemplate{Type: RequestTemplateJSONPatch, Patch: []JSONPatchOp{{Op: "test", Path: "/claim_type", Value: "other"}}}
+	if _, err := failing.Render(payload); err == nil {
+		t.Error("Expected a failing test operation to fail the patch")
+	}
+	for _, op := range []JSONPatchOp{{Op: "merge", Path: "/x"}, {Op: "add", Path: "x"}, {Op: "move", From: "y", Path: "/x"}} {
+		if err := (&DPRequestTemplate{Type: RequestTemplateJSONPatch, Patch: []JSONPatchOp{op}}).Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", op)
+		}
+	}
+}
+
+func TestDPConnectorService_RequestTemplate(t *testing.T) {
+	var received []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		received = append(received, body)
+
+		page := map[string]interface{}{
+			"job_id":              "j",
+			"status":              "completed",
+			"timestamp":           "2026-01-01T00:00:00Z",
+			"records":             []map[string]interface{}{{"id": len(received)}},
+			"verification_result": map[string]interface{}{"verified": true, "confidence": 0.9},
+		}
+		if len(received) == 1 {
+			page["pagination"] = map[string]interface{}{"next_cursor": "c1"}
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: server.URL, DPTimeout: 5 * time.Second})
+	service.Registry().Remove(DefaultDPProviderID)
+	if err := service.Registry().Register(&DPProvider{
+		DPID:            "dp-templated",
+		Endpoint:        server.URL,
+		SupportedClaims: []string{AnyClaimType},
+		Pagination:      &DPPagination{Mode: PaginationCursor},
+		RequestTemplate: &DPRequestTemplate{
+			Type:     RequestTemplateGo,
+			Template: `{"query": {"requester": {{json .rp_id}}{{with .cursor}}, "page_token": {{json .}}{{end}}}}`,
+		},
+	}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	if _, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "student_verification"}); err != nil {
+		t.Fatalf("VerifyWithDP failed: %v", err)
+	}
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(received))
+	}
+	first, _ := received[0]["query"].(map[string]interface{})
+	second, _ := received[1]["query"].(map[string]interface{})
+	if first["requester"] != "rp_1" || first["page_token"] != nil {
+		t.Errorf("Unexpected first request: %v", received[0])
+	}
+	if second["requester"] != "rp_1" || second["page_token"] != "c1" {
+		t.Errorf("Expected the page cursor inside the envelope, got %v", received[1])
+	}
+}
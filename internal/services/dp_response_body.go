@@ -0,0 +1,128 @@
+package services
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// defaultDPMaxResponseBytes caps DP responses when DP_MAX_RESPONSE_BYTES is
+// not set
+const defaultDPMaxResponseBytes = 10 << 20
+
+// dpAcceptEncoding is offered to DPs; their responses are decoded by
+// readDPResponseBody rather than by the transport, so the size limit applies
+// to the decoded body
+const dpAcceptEncoding = "gzip, deflate"
+
+// ResponseTooLargeError is returned when a DP response, as sent or once
+// decoded, exceeds the provider's response size limit. It is a malformed
+// response, so it counts against the DP's breaker as a decode failure.
+type ResponseTooLargeError struct {
+	DPID             string
+	MaxResponseBytes int64
+	Encoding         string
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	if e.Encoding != "" {
+		return fmt.Sprintf("DP %s response exceeds the %d byte limit once %s decoded", e.DPID, e.MaxResponseBytes, e.Encoding)
+	}
+	return fmt.Sprintf("DP %s response exceeds the %d byte limit", e.DPID, e.MaxResponseBytes)
+}
+
+// maxResponseBytes returns the provider's response size limit: its
+// max_response_bytes, or DP_MAX_RESPONSE_BYTES
+func (s *DPConnectorService) maxResponseBytes(provider *DPProvider) int64 {
+	if provider.MaxResponseBytes > 0 {
+		return int64(provider.MaxResponseBytes)
+	}
+	if s.config.DPMaxResponseBytes > 0 {
+		return int64(s.config.DPMaxResponseBytes)
+	}
+	return defaultDPMaxResponseBytes
+}
+
+// readDPResponseBody reads a DP response body, decoding a gzip or deflate
+// Content-Encoding. Both the body as sent and as decoded are limited to the
+// provider's max response size, so neither a huge response nor a
+// decompression bomb is read into memory.
+func (s *DPConnectorService) readDPResponseBody(provider *DPProvider, resp *http.Response) ([]byte, error) {
+	limit := s.maxResponseBytes(provider)
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "identity" {
+		encoding = ""
+	}
+	tooLarge := &ResponseTooLargeError{DPID: provider.DPID, MaxResponseBytes: limit}
+	if resp.ContentLength > limit {
+		return nil, tooLarge
+	}
+
+	raw := &limitedReader{reader: io.LimitReader(resp.Body, limit+1), limit: limit}
+	reader, err := decodeContent(encoding, raw)
+	if err != nil {
+		if raw.exceeded() {
+			return nil, tooLarge
+		}
+		return nil, fmt.Errorf("failed to decode DP response: %w", err)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if raw.exceeded() {
+		return nil, tooLarge
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DP response: %w", err)
+	}
+	if int64(len(body)) > limit {
+		tooLarge.Encoding = encoding
+		return nil, tooLarge
+	}
+	return body, nil
+}
+
+// decodeContent wraps a body in the decoder for its Content-Encoding
+func decodeContent(encoding string, body io.Reader) (io.Reader, error) {
+	switch encoding {
+	case "":
+		return body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		// Deflate should be zlib-wrapped, but some servers send raw deflate
+		buffered := bufio.NewReader(body)
+		header, err := buffered.Peek(2)
+		if err != nil {
+			return nil, err
+		}
+		if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			return zlib.NewReader(buffered)
+		}
+		return flate.NewReader(buffered), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// limitedReader reads from an io.LimitReader of limit+1 bytes, recording
+// whether the body ran past limit
+type limitedReader struct {
+	reader io.Reader
+	limit  int64
+	read   int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	return n, err
+}
+
+func (r *limitedReader) exceeded() bool {
+	return r.read > r.limit
+}
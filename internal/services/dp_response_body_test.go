@@ -0,0 +1,98 @@
+package services
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testDPResponseBody = `{"job_id":"j","status":"completed","timestamp":"2026-01-01T00:00:00Z","verification_result":{"verified":true,"confidence":0.9}}`
+
+func compressed(t *testing.T, encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "deflate":
+		writer = zlib.NewWriter(&buf)
+	case "raw deflate":
+		writer, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+	writer.Write(data)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func dpTestResponse(encoding string, body []byte, contentLength int64) *http.Response {
+	header := http.Header{}
+	if encoding != "" {
+		header.Set("Content-Encoding", strings.TrimPrefix(encoding, "raw "))
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader(body)), ContentLength: contentLength}
+}
+
+func TestParseDPResponse_DecodesContent(t *testing.T) {
+	service := newRetryTestService()
+	provider := &DPProvider{DPID: "dp_a"}
+
+	for _, encoding := range []string{"", "gzip", "deflate", "raw deflate"} {
+		body := []byte(testDPResponseBody)
+		if encoding != "" {
+			body = compressed(t, encoding, body)
+		}
+		response, err := service.parseDPResponse(provider, dpTestResponse(encoding, body, -1))
+		if err != nil {
+			t.Fatalf("%q: expected the response to decode, got %v", encoding, err)
+		}
+		if response.VerificationResult == nil || !response.VerificationResult.Verified {
+			t.Errorf("%q: unexpected response %+v", encoding, response)
+		}
+	}
+
+	if _, err := service.parseDPResponse(provider, dpTestResponse("br", []byte(testDPResponseBody), -1)); err == nil || !strings.Contains(err.Error(), "unsupported content encoding") {
+		t.Errorf("Expected an unsupported encoding to be refused, got %v", err)
+	}
+}
+
+func TestParseDPResponse_SizeLimit(t *testing.T) {
+	service := newRetryTestService()
+	provider := &DPProvider{DPID: "dp_a", MaxResponseBytes: 1024}
+	huge := []byte(`{"padding":"` + strings.Repeat("a", 4096) + `"}`)
+
+	tests := []struct {
+		name     string
+		response *http.Response
+	}{
+		{"declared length", dpTestResponse("", huge, int64(len(huge)))},
+		{"unknown length", dpTestResponse("", huge, -1)},
+		// Under the limit on the wire, far over it once decoded
+		{"gzip bomb", dpTestResponse("gzip", compressed(t, "gzip", bytes.Repeat(huge, 100)), -1)},
+		{"deflate bomb", dpTestResponse("deflate", compressed(t, "deflate", bytes.Repeat(huge, 100)), -1)},
+	}
+	for _, tt := range tests {
+		_, err := service.parseDPResponse(provider, tt.response)
+		var tooLarge *ResponseTooLargeError
+		if !errors.As(err, &tooLarge) || tooLarge.DPID != "dp_a" || tooLarge.MaxResponseBytes != 1024 {
+			t.Errorf("%s: expected a response too large error, got %v", tt.name, err)
+			continue
+		}
+		if classifyDPFailure(err) != FailureTypeDecode {
+			t.Errorf("%s: expected a decode failure, got %s", tt.name, classifyDPFailure(err))
+		}
+	}
+
+	// The limit defaults to DP_MAX_RESPONSE_BYTES
+	service.config.DPMaxResponseBytes = 64
+	if _, err := service.parseDPResponse(&DPProvider{DPID: "dp_b"}, dpTestResponse("", []byte(testDPResponseBody), -1)); !errors.As(err, new(*ResponseTooLargeError)) {
+		t.Errorf("Expected the configured limit to apply, got %v", err)
+	}
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of difference between a DP response and its registered schema
+const (
+	SchemaDriftAdded       = "added"
+	SchemaDriftMissing     = "missing"
+	SchemaDriftTypeChanged = "type_changed"
+)
+
+// JSON types used in response schemas
+const (
+	JSONTypeString  = "string"
+	JSONTypeNumber  = "number"
+	JSONTypeBoolean = "boolean"
+	JSONTypeObject  = "object"
+	JSONTypeArray   = "array"
+	JSONTypeNull    = "null"
+)
+
+// maxSchemaDriftEvents bounds the recent drift events kept for stats
+const maxSchemaDriftEvents = 100
+
+// DPResponseSchema is the JSON shape a DP's responses are expected to have.
+// Fields maps dotted paths to JSON types; array elements use the path of the
+// array followed by "[]", e.g. "verification_result.evidence[]".
+type DPResponseSchema struct {
+	Fields map[string]string `json:"fields"`
+	// Optional fields may be absent or null
+	Optional []string `json:"optional,omitempty"`
+}
+
+// Validate checks that a response schema is usable
+func (s *DPResponseSchema) Validate() error {
+	if len(s.Fields) == 0 {
+		return fmt.Errorf("response schema must list at least one field")
+	}
+	for path, fieldType := range s.Fields {
+		switch fieldType {
+		case JSONTypeString, JSONTypeNumber, JSONTypeBoolean, JSONTypeObject, JSONTypeArray, JSONTypeNull:
+		default:
+			return fmt.Errorf("response schema field %s has unknown type %q", path, fieldType)
+		}
+	}
+	for _, path := range s.Optional {
+		if _, exists := s.Fields[path]; !exists {
+			return fmt.Errorf("optional field %s is not in the response schema", path)
+		}
+	}
+	return nil
+}
+
+// SchemaDriftChange is one difference between a response and the schema
+type SchemaDriftChange struct {
+	Field    string `json:"field"`
+	Kind     string `json:"kind"`
+	Expected string `json:"expected,omitempty"`
+	Observed string `json:"observed,omitempty"`
+}
+
+// SchemaDriftEvent is raised when a DP's responses start to drift from its
+// registered schema, or drift differently than before
+type SchemaDriftEvent struct {
+	DPID        string              `json:"dp_id"`
+	Changes     []SchemaDriftChange `json:"changes"`
+	Quarantined bool                `json:"quarantined"`
+	Timestamp   time.Time           `json:"timestamp"`
+}
+
+// SchemaDriftDetector compares DP responses with the providers' registered
+// schemas. It raises an event when a DP's drift changes rather than on every
+// response, and quarantines DPs that opted in until their schema is updated.
+type SchemaDriftDetector struct {
+	mu          sync.Mutex
+	current     map[string][]SchemaDriftChange
+	signatures  map[string]string
+	quarantined map[string]time.Time
+	events      []SchemaDriftEvent
+	handler     func(SchemaDriftEvent)
+}
+
+// NewSchemaDriftDetector creates a drift detector
+func NewSchemaDriftDetector() *SchemaDriftDetector {
+	return &SchemaDriftDetector{
+		current:     make(map[string][]SchemaDriftChange),
+		signatures:  make(map[string]string),
+		quarantined: make(map[string]time.Time),
+	}
+}
+
+// SetEventHandler registers a callback for drift events
+func (d *SchemaDriftDetector) SetEventHandler(handler func(SchemaDriftEvent)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handler = handler
+}
+
+// Check compares a raw response body with a provider's schema and returns
+// the differences. Providers without a schema are not checked.
+func (d *SchemaDriftDetector) Check(provider *DPProvider, body []byte) []SchemaDriftChange {
+	if provider.ResponseSchema == nil {
+		return nil
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil
+	}
+	changes := CompareResponseShape(provider.ResponseSchema, ObserveResponseShape(document))
+	signature := driftSignature(changes)
+
+	d.mu.Lock()
+	if len(changes) == 0 {
+		delete(d.current, provider.DPID)
+	} else {
+		d.current[provider.DPID] = changes
+	}
+	if signature == d.signatures[provider.DPID] {
+		d.mu.Unlock()
+		return changes
+	}
+	d.signatures[provider.DPID] = signature
+	if len(changes) == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+
+	event := SchemaDriftEvent{DPID: provider.DPID, Changes: changes, Timestamp: time.Now()}
+	if provider.QuarantineOnDrift {
+		if _, exists := d.quarantined[provider.DPID]; !exists {
+			d.quarantined[provider.DPID] = event.Timestamp
+		}
+		event.Quarantined = true
+	}
+	d.events = append(d.events, event)
+	if len(d.events) > maxSchemaDriftEvents {
+		d.events = d.events[len(d.events)-maxSchemaDriftEvents:]
+	}
+	handler := d.handler
+	d.mu.Unlock()
+
+	fmt.Printf("DP SCHEMA WARNING: responses from %s drift from the registered schema: %s\n", provider.DPID, signature)
+	if handler != nil {
+		handler(event)
+	}
+	return changes
+}
+
+// CurrentDrift returns the differences found in a DP's latest response
+func (d *SchemaDriftDetector) CurrentDrift(dpID string) []SchemaDriftChange {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]SchemaDriftChange(nil), d.current[dpID]...)
+}
+
+// IsQuarantined reports whether a DP is held back pending a schema update
+func (d *SchemaDriftDetector) IsQuarantined(dpID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, exists := d.quarantined[dpID]
+	return exists
+}
+
+// Release lifts a DP's quarantine and forgets its drift, e.g. after its
+// schema was updated
+func (d *SchemaDriftDetector) Release(dpID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.quarantined, dpID)
+	delete(d.current, dpID)
+	delete(d.signatures, dpID)
+}
+
+// GetEvents returns recent drift events and the quarantined DPs
+func (d *SchemaDriftDetector) GetEvents() ([]SchemaDriftEvent, []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	quarantined := make([]string, 0, len(d.quarantined))
+	for dpID := range d.quarantined {
+		quarantined = append(quarantined, dpID)
+	}
+	sort.Strings(quarantined)
+	return append([]SchemaDriftEvent(nil), d.events...), quarantined
+}
+
+// ObserveResponseShape flattens a decoded JSON document into dotted paths
+// and their JSON types
+func ObserveResponseShape(document interface{}) map[string]string {
+	shape := make(map[string]string)
+	observeShape(shape, "", document)
+	return shape
+}
+
+func observeShape(shape map[string]string, path string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if path != "" {
+			shape[path] = JSONTypeObject
+		}
+		for key, child := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			observeShape(shape, childPath, child)
+		}
+	case []interface{}:
+		shape[path] = JSONTypeArray
+		for _, element := range v {
+			observeShape(shape, path+"[]", element)
+		}
+	case string:
+		shape[path] = JSONTypeString
+	case float64:
+		shape[path] = JSONTypeNumber
+	case bool:
+		shape[path] = JSONTypeBoolean
+	case nil:
+		shape[path] = JSONTypeNull
+	}
+}
+
+// CompareResponseShape lists the differences between an observed shape and
+// a schema, ordered by field
+func CompareResponseShape(schema *DPResponseSchema, observed map[string]string) []SchemaDriftChange {
+	optional := make(map[string]bool, len(schema.Optional))
+	for _, path := range schema.Optional {
+		optional[path] = true
+	}
+
+	var changes []SchemaDriftChange
+	for path, expected := range schema.Fields {
+		observedType, exists := observed[path]
+		switch {
+		case !exists:
+			if !optional[path] && !parentReported(schema, optional, observed, path) {
+				changes = append(changes, SchemaDriftChange{Field: path, Kind: SchemaDriftMissing, Expected: expected})
+			}
+		case observedType == JSONTypeNull && optional[path]:
+		case observedType != expected:
+			changes = append(changes, SchemaDriftChange{Field: path, Kind: SchemaDriftTypeChanged, Expected: expected, Observed: observedType})
+		}
+	}
+	for path, observedType := range observed {
+		if _, exists := schema.Fields[path]; !exists && !parentDrifted(schema, observed, path) {
+			changes = append(changes, SchemaDriftChange{Field: path, Kind: SchemaDriftAdded, Observed: observedType})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+// parentReported reports whether a missing field sits under a field that is
+// itself missing, null where optional, of another type, or an empty array, so
+// only the outermost difference is reported
+func parentReported(schema *DPResponseSchema, optional map[string]bool, observed map[string]string, path string) bool {
+	for parent := shapeParent(path); parent != ""; parent = shapeParent(parent) {
+		observedType, exists := observed[parent]
+		switch {
+		case !exists:
+			return true
+		case observedType == JSONTypeNull && optional[parent]:
+			return true
+		case observedType != schema.Fields[parent]:
+			return true
+		case observedType == JSONTypeArray:
+			if _, hasElements := observed[parent+"[]"]; !hasElements {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parentDrifted reports whether an added field sits under a field that is
+// new or of another type, so only the outermost difference is reported
+func parentDrifted(schema *DPResponseSchema, observed map[string]string, path string) bool {
+	for parent := shapeParent(path); parent != ""; parent = shapeParent(parent) {
+		expected, known := schema.Fields[parent]
+		if !known || observed[parent] != expected {
+			return true
+		}
+	}
+	return false
+}
+
+// shapeParent returns the path of the object or array holding a field
+func shapeParent(path string) string {
+	if strings.HasSuffix(path, "[]") {
+		return strings.TrimSuffix(path, "[]")
+	}
+	if dot := strings.LastIndex(path, "."); dot >= 0 {
+		return path[:dot]
+	}
+	return ""
+}
+
+// driftSignature identifies a set of changes so repeated drift raises one event
+func driftSignature(changes []SchemaDriftChange) string {
+	parts := make([]string, 0, len(changes))
+	for _, change := range changes {
+		parts = append(parts, change.Kind+":"+change.Field)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func testResponseSchema() *DPResponseSchema {
+	return &DPResponseSchema{
+		Fields: map[string]string{
+			"job_id":                         JSONTypeString,
+			"status":                         JSONTypeString,
+			"timestamp":                      JSONTypeString,
+			"verification_result":            JSONTypeObject,
+			"verification_result.verified":   JSONTypeBoolean,
+			"verification_result.confidence": JSONTypeNumber,
+			"verification_result.evidence":   JSONTypeArray,
+			"verification_result.evidence[]": JSONTypeString,
+			"error":                          JSONTypeString,
+		},
+		Optional: []string{"error"},
+	}
+}
+
+func TestDPResponseSchema_Validate(t *testing.T) {
+	if err := testResponseSchema().Validate(); err != nil {
+		t.Errorf("Expected schema to be valid, got %v", err)
+	}
+
+	invalid := []*DPResponseSchema{
+		{},
+		{Fields: map[string]string{"status": "text"}},
+		{Fields: map[string]string{"status": JSONTypeString}, Optional: []string{"error"}},
+	}
+	for _, schema := range invalid {
+		if err := schema.Validate(); err == nil {
+			t.Errorf("Expected schema %v to be rejected", schema)
+		}
+	}
+}
+
+func TestCompareResponseShape(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected []SchemaDriftChange
+	}{
+		{
+			name: "matching response",
+			body: `{"job_id":"j","status":"completed","timestamp":"t","verification_result":{"verified":true,"confidence":0.9,"evidence":["a"]}}`,
+		},
+		{
+			name: "optional field null",
+			body: `{"job_id":"j","status":"completed","timestamp":"t","error":null,"verification_result":{"verified":true,"confidence":0.9,"evidence":[]}}`,
+		},
+		{
+			name: "added field",
+			body: `{"job_id":"j","status":"completed","timestamp":"t","score":{"value":1},"verification_result":{"verified":true,"confidence":0.9,"evidence":["a"]}}`,
+			expected: []SchemaDriftChange{
+				{Field: "score", Kind: SchemaDriftAdded, Observed: JSONTypeObject},
+			},
+		},
+		{
+			name: "missing object",
+			body: `{"job_id":"j","status":"completed","timestamp":"t"}`,
+			expected: []SchemaDriftChange{
+				{Field: "verification_result", Kind: SchemaDriftMissing, Expected: JSONTypeObject},
+			},
+		},
+		{
+			name: "type changed",
+			body: `{"job_id":7,"status":"completed","timestamp":"t","verification_result":{"verified":"yes","confidence":0.9,"evidence":["a"]}}`,
+			expected: []SchemaDriftChange{
+				{Field: "job_id", Kind: SchemaDriftTypeChanged, Expected: JSONTypeString, Observed: JSONTypeNumber},
+				{Field: "verification_result.verified", Kind: SchemaDriftTypeChanged, Expected: JSONTypeBoolean, Observed: JSONTypeString},
+			},
+		},
+		{
+			name: "array element type changed",
+			body: `{"job_id":"j","status":"completed","timestamp":"t","verification_result":{"verified":true,"confidence":0.9,"evidence":[{"code":"a"}]}}`,
+			expected: []SchemaDriftChange{
+				{Field: "verification_result.evidence[]", Kind: SchemaDriftTypeChanged, Expected: JSONTypeString, Observed: JSONTypeObject},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := NewSchemaDriftDetector()
+			changes := detector.Check(&DPProvider{DPID: "dp-1", ResponseSchema: testResponseSchema()}, []byte(tt.body))
+
+			if len(changes) != len(tt.expected) {
+				t.Fatalf("Expected changes %v, got %v", tt.expected, changes)
+			}
+			for i := range changes {
+				if changes[i] != tt.expected[i] {
+					t.Errorf("Expected change %v, got %v", tt.expected[i], changes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSchemaDriftDetector_EventsAndQuarantine(t *testing.T) {
+	detector := NewSchemaDriftDetector()
+	var raised []SchemaDriftEvent
+	detector.SetEventHandler(func(event SchemaDriftEvent) {
+		raised = append(raised, event)
+	})
+
+	provider := &DPProvider{DPID: "dp-1", ResponseSchema: testResponseSchema(), QuarantineOnDrift: true}
+	drifted := []byte(`{"job_id":"j","status":"completed","timestamp":"t","extra":true,"verification_result":{"verified":true,"confidence":0.9,"evidence":[]}}`)
+
+	// Repeated drift raises a single event
+	detector.Check(provider, drifted)
+	detector.Check(provider, drifted)
+	if len(raised) != 1 {
+		t.Fatalf("Expected one drift event, got %d", len(raised))
+	}
+	if !raised[0].Quarantined || !detector.IsQuarantined("dp-1") {
+		t.Error("Expected the DP to be quarantined")
+	}
+	if len(detector.CurrentDrift("dp-1")) != 1 {
+		t.Errorf("Expected the current drift to be kept, got %v", detector.CurrentDrift("dp-1"))
+	}
+
+	events, quarantined := detector.GetEvents()
+	if len(events) != 1 || len(quarantined) != 1 || quarantined[0] != "dp-1" {
+		t.Errorf("Expected one event and dp-1 quarantined, got %v and %v", events, quarantined)
+	}
+
+	detector.Release("dp-1")
+	if detector.IsQuarantined("dp-1") || len(detector.CurrentDrift("dp-1")) != 0 {
+		t.Error("Expected release to clear the quarantine and drift")
+	}
+
+	// Providers that did not opt in are only reported
+	observed := &DPProvider{DPID: "dp-2", ResponseSchema: testResponseSchema()}
+	detector.Check(observed, drifted)
+	if detector.IsQuarantined("dp-2") {
+		t.Error("Expected the DP not to be quarantined")
+	}
+}
+
+func TestDPConnectorService_QuarantinesDriftingDP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"job_id":"j","status":"completed","timestamp":"2025-08-02T07:00:00Z","verification_result":{"verified":true,"confidence":0.9,"evidence":["a"],"score":3}}`))
+	}))
+	defer server.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: server.URL, DPTimeout: 5 * time.Second})
+	service.Registry().Remove(DefaultDPProviderID)
+	if err := service.Registry().Register(&DPProvider{
+		DPID:              "dp-drift",
+		Endpoint:          server.URL,
+		SupportedClaims:   []string{AnyClaimType},
+		AdapterType:       AdapterTypeREST,
+		ResponseSchema:    testResponseSchema(),
+		QuarantineOnDrift: true,
+	}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	req := &models.PrivacyRequest{RPID: "rp_123", UserHash: "hash", ClaimType: "student_verification"}
+	if _, err := service.VerifyWithDP(context.Background(), req); err != nil {
+		t.Fatalf("Expected the drifting response to be returned, got %v", err)
+	}
+	if !service.SchemaDrift().IsQuarantined("dp-drift") {
+		t.Fatal("Expected the DP to be quarantined")
+	}
+
+	if _, err := service.VerifyWithDP(context.Background(), req); err == nil {
+		t.Error("Expected quarantined DPs to be skipped")
+	}
+
+	// Accepting the new field lifts the quarantine
+	schema := testResponseSchema()
+	schema.Fields["verification_result.score"] = JSONTypeNumber
+	if err := service.UpdateResponseSchema("dp-drift", schema); err != nil {
+		t.Fatalf("UpdateResponseSchema failed: %v", err)
+	}
+	if _, err := service.VerifyWithDP(context.Background(), req); err != nil {
+		t.Errorf("Expected the DP to be used again, got %v", err)
+	}
+	if service.SchemaDrift().IsQuarantined("dp-drift") {
+		t.Error("Expected no drift against the updated schema")
+	}
+}
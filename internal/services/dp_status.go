@@ -0,0 +1,135 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DPStatus is the status a DP reports for a verification job
+type DPStatus string
+
+// Statuses a DP may report. Any other status is quarantined.
+const (
+	DPStatusCompleted  DPStatus = "completed"
+	DPStatusPending    DPStatus = "pending"
+	DPStatusProcessing DPStatus = "processing"
+	DPStatusFailed     DPStatus = "failed"
+	// DPStatusPartial is the status of a result the DP could only partly
+	// determine; the response carries partial_results
+	DPStatusPartial DPStatus = "partial"
+	// DPStatusNotModified is the status of a response to a conditional
+	// request the DP answered with 304 Not Modified: the cached result still
+	// stands
+	DPStatusNotModified DPStatus = "not_modified"
+)
+
+// Valid reports whether the status is one the broker understands
+func (s DPStatus) Valid() bool {
+	switch s {
+	case DPStatusCompleted, DPStatusPending, DPStatusProcessing, DPStatusFailed, DPStatusPartial, DPStatusNotModified:
+		return true
+	}
+	return false
+}
+
+// maxUnknownStatusEvents bounds the quarantined responses kept for review
+const maxUnknownStatusEvents = 100
+
+// UnknownDPStatusError is returned for a DP response whose status is not one
+// the broker understands. The response is quarantined instead of being
+// trusted, and like an undecodable response it counts as a decode failure.
+type UnknownDPStatusError struct {
+	DPID   string
+	Status DPStatus
+}
+
+func (e *UnknownDPStatusError) Error() string {
+	if e.Status == "" {
+		return fmt.Sprintf("DP %s returned a response without a status", e.DPID)
+	}
+	return fmt.Sprintf("DP %s returned unknown status %q", e.DPID, e.Status)
+}
+
+// UnknownDPStatusEvent records a quarantined DP response. The response body
+// is not kept since it may carry personal data.
+type UnknownDPStatusEvent struct {
+	DPID      string    `json:"dp_id"`
+	JobID     string    `json:"job_id,omitempty"`
+	Status    DPStatus  `json:"status"`
+	FirstSeen bool      `json:"first_seen"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DPStatusQuarantine validates the statuses DPs return. Responses with an
+// unknown status are withheld and recorded; an alert is raised the first
+// time a DP returns each unknown status rather than on every response.
+type DPStatusQuarantine struct {
+	mu      sync.Mutex
+	seen    map[string]map[DPStatus]int64
+	events  []UnknownDPStatusEvent
+	handler func(UnknownDPStatusEvent)
+}
+
+// NewDPStatusQuarantine creates a status quarantine
+func NewDPStatusQuarantine() *DPStatusQuarantine {
+	return &DPStatusQuarantine{seen: make(map[string]map[DPStatus]int64)}
+}
+
+// SetEventHandler registers a callback for quarantined responses
+func (q *DPStatusQuarantine) SetEventHandler(handler func(UnknownDPStatusEvent)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handler = handler
+}
+
+// Check validates a response's status, quarantining it when the status is
+// unknown
+func (q *DPStatusQuarantine) Check(provider *DPProvider, response *DPResponse) error {
+	if response.Status.Valid() {
+		return nil
+	}
+
+	event := UnknownDPStatusEvent{
+		DPID:      provider.DPID,
+		JobID:     response.JobID,
+		Status:    response.Status,
+		Timestamp: time.Now(),
+	}
+
+	q.mu.Lock()
+	if q.seen[provider.DPID] == nil {
+		q.seen[provider.DPID] = make(map[DPStatus]int64)
+	}
+	event.FirstSeen = q.seen[provider.DPID][response.Status] == 0
+	q.seen[provider.DPID][response.Status]++
+	q.events = append(q.events, event)
+	if len(q.events) > maxUnknownStatusEvents {
+		q.events = q.events[len(q.events)-maxUnknownStatusEvents:]
+	}
+	handler := q.handler
+	q.mu.Unlock()
+
+	if event.FirstSeen {
+		fmt.Printf("DP STATUS WARNING: %s returned unknown status %q; its responses are quarantined\n", provider.DPID, response.Status)
+		if handler != nil {
+			handler(event)
+		}
+	}
+	return &UnknownDPStatusError{DPID: provider.DPID, Status: response.Status}
+}
+
+// GetEvents returns recently quarantined responses and the number of
+// responses quarantined per DP
+func (q *DPStatusQuarantine) GetEvents() ([]UnknownDPStatusEvent, map[string]int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	counts := make(map[string]int64, len(q.seen))
+	for dpID, statuses := range q.seen {
+		for _, count := range statuses {
+			counts[dpID] += count
+		}
+	}
+	return append([]UnknownDPStatusEvent(nil), q.events...), counts
+}
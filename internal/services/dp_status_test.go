@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestDPStatus_Valid(t *testing.T) {
+	for _, status := range []DPStatus{DPStatusCompleted, DPStatusPending, DPStatusProcessing, DPStatusFailed, DPStatusNotModified} {
+		if !status.Valid() {
+			t.Errorf("Expected %q to be valid", status)
+		}
+	}
+	for _, status := range []DPStatus{"", "approved", "COMPLETED"} {
+		if status.Valid() {
+			t.Errorf("Expected %q to be invalid", status)
+		}
+	}
+}
+
+func TestDPConnectorService_QuarantinesUnknownStatuses(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":              "job_1",
+			"status":              "approved",
+			"timestamp":           "2026-01-01T00:00:00Z",
+			"verification_result": map[string]interface{}{"verified": true, "confidence": 0.9},
+		})
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(DPResponse{JobID: "job_2", Status: DPStatusCompleted, Timestamp: "2026-01-01T00:00:00Z"})
+	}))
+	defer backup.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: "http://localhost:1"})
+	service.retryConfig.MaxRetries = 0
+	service.registry = NewDPRegistry()
+	service.registry.Register(&DPProvider{DPID: "dp_primary", Endpoint: primary.URL, SupportedClaims: []string{"age_verification"}, Priority: 0})
+	var alerts []UnknownDPStatusEvent
+	service.StatusQuarantine().SetEventHandler(func(event UnknownDPStatusEvent) { alerts = append(alerts, event) })
+
+	req := &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"}
+	var statusErr *UnknownDPStatusError
+	for i := 0; i < 2; i++ {
+		if _, err := service.VerifyWithDP(context.Background(), req); !errors.As(err, &statusErr) || statusErr.Status != "approved" {
+			t.Fatalf("Expected the unknown status to be rejected, got %v", err)
+		}
+	}
+	if classifyDPFailure(statusErr) != FailureTypeDecode {
+		t.Errorf("Expected an unknown status to be a decode failure, got %s", classifyDPFailure(statusErr))
+	}
+
+	// One alert per DP and status; every response is recorded
+	events, counts := service.StatusQuarantine().GetEvents()
+	if len(alerts) != 1 || alerts[0].DPID != "dp_primary" || alerts[0].JobID != "job_1" || !alerts[0].FirstSeen {
+		t.Errorf("Expected one alert, got %+v", alerts)
+	}
+	if len(events) != 2 || counts["dp_primary"] != 2 {
+		t.Errorf("Expected both responses to be quarantined, got %d events and %v", len(events), counts)
+	}
+	if stats := service.GetDPStats(); stats["unknown_statuses"].(map[string]int64)["dp_primary"] != 2 {
+		t.Errorf("Expected the DP stats to count quarantined responses, got %v", stats["unknown_statuses"])
+	}
+
+	// A quarantined response fails over to the next DP
+	service.registry.Register(&DPProvider{DPID: "dp_backup", Endpoint: backup.URL, SupportedClaims: []string{"age_verification"}, Priority: 1})
+	response, err := service.VerifyWithDP(context.Background(), req)
+	if err != nil || response.DPID != "dp_backup" || response.Status != DPStatusCompleted {
+		t.Errorf("Expected the backup DP to answer, got %+v, %v", response, err)
+	}
+}
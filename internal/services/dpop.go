@@ -0,0 +1,210 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// DPoPHeader carries DPoP proofs (RFC 9449) and DPoPNonceHeader the nonces
+// servers require in them
+const (
+	DPoPHeader      = "DPoP"
+	DPoPNonceHeader = "DPoP-Nonce"
+)
+
+// dpopNonceError is the error servers return when a proof lacks their nonce
+const dpopNonceError = "use_dpop_nonce"
+
+// DPoPKey is the broker's proof-of-possession key. DPs and authorization
+// servers bind sender-constrained tokens to its thumbprint.
+type DPoPKey struct {
+	key        *ecdsa.PrivateKey
+	jwk        map[string]interface{}
+	thumbprint string
+}
+
+// NewDPoPKey wraps a P-256 key for DPoP proofs
+func NewDPoPKey(key *ecdsa.PrivateKey) (*DPoPKey, error) {
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("DPoP key must be P-256")
+	}
+	x := base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32)))
+	y := base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32)))
+
+	// RFC 7638: the required members in lexicographic order, no whitespace
+	canonical := fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`, x, y)
+	digest := sha256.Sum256([]byte(canonical))
+
+	return &DPoPKey{
+		key:        key,
+		jwk:        map[string]interface{}{"kty": "EC", "crv": "P-256", "x": x, "y": y},
+		thumbprint: base64.RawURLEncoding.EncodeToString(digest[:]),
+	}, nil
+}
+
+// GenerateDPoPKey creates a new DPoP key
+func GenerateDPoPKey() (*DPoPKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate DPoP key: %w", err)
+	}
+	return NewDPoPKey(key)
+}
+
+// LoadDPoPKey returns the configured DPoP key. With DPOP_KEY_FILE the key is
+// kept there as an enc:v1 value wrapped by the KeyProvider, and created on
+// first use, so tokens bound to it survive restarts; without it a new key is
+// generated for the process.
+func LoadDPoPKey(cfg *config.Config) (*DPoPKey, error) {
+	if cfg == nil || cfg.DPoPKeyFile == "" {
+		return GenerateDPoPKey()
+	}
+	parsed, err := loadWrappedKey(cfg, "DPoP", cfg.DPoPKeyFile, cfg.DPoPKeyID, func() (crypto.PrivateKey, error) {
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	})
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("DPoP key must be an EC key")
+	}
+	return NewDPoPKey(key)
+}
+
+// Thumbprint returns the RFC 7638 JWK thumbprint tokens are bound to (jkt)
+func (k *DPoPKey) Thumbprint() string {
+	return k.thumbprint
+}
+
+// DPoPProver creates proofs for one provider and remembers the nonces its
+// servers hand out, by origin
+type DPoPProver struct {
+	key *DPoPKey
+	now func() time.Time
+
+	mu     sync.Mutex
+	nonces map[string]string
+}
+
+// NewDPoPProver creates a prover using the broker's DPoP key
+func NewDPoPProver(key *DPoPKey) *DPoPProver {
+	return &DPoPProver{key: key, now: time.Now, nonces: make(map[string]string)}
+}
+
+// Proof creates a DPoP proof for a request. With an access token the proof
+// carries its hash (ath), binding the two together.
+func (p *DPoPProver) Proof(method, target, accessToken string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid DPoP target: %w", err)
+	}
+	htu := u.Scheme + "://" + u.Host + u.EscapedPath()
+
+	claims := jwt.MapClaims{
+		"jti": uuid.New().String(),
+		"htm": method,
+		"htu": htu,
+		"iat": p.now().Unix(),
+	}
+	if accessToken != "" {
+		digest := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(digest[:])
+	}
+	p.mu.Lock()
+	if nonce := p.nonces[u.Scheme+"://"+u.Host]; nonce != "" {
+		claims["nonce"] = nonce
+	}
+	p.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = p.key.jwk
+	proof, err := token.SignedString(p.key.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign DPoP proof: %w", err)
+	}
+	return proof, nil
+}
+
+// ObserveResponse records a nonce the server provided and reports whether the
+// response is a challenge to retry with it: a 401 from a resource server or a
+// 400 from an authorization server with the use_dpop_nonce error
+func (p *DPoPProver) ObserveResponse(resp *http.Response, body []byte) bool {
+	nonce := resp.Header.Get(DPoPNonceHeader)
+	if nonce == "" || resp.Request == nil {
+		return false
+	}
+	p.mu.Lock()
+	p.nonces[resp.Request.URL.Scheme+"://"+resp.Request.URL.Host] = nonce
+	p.mu.Unlock()
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return strings.Contains(resp.Header.Get("WWW-Authenticate"), dpopNonceError)
+	case http.StatusBadRequest:
+		var oauthErr struct {
+			Error string `json:"error"`
+		}
+		return json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error == dpopNonceError
+	}
+	return false
+}
+
+// addDPoPProof sender-constrains the request's bearer token with a proof
+func (a *Authenticator) addDPoPProof(req *http.Request) error {
+	if a.dpop == nil {
+		if a.dpopErr != nil {
+			return fmt.Errorf("DPoP key unavailable: %w", a.dpopErr)
+		}
+		return fmt.Errorf("DPoP key unavailable")
+	}
+	token, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return fmt.Errorf("DPoP requires an access token")
+	}
+	proof, err := a.dpop.Proof(req.Method, req.URL.String(), token)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "DPoP "+token)
+	req.Header.Set(DPoPHeader, proof)
+	return nil
+}
+
+// setDPoPKey gives the authenticator, and its token exchanger, the key for
+// DPoP proofs; a key that failed to load fails each request instead
+func (a *Authenticator) setDPoPKey(key *DPoPKey, err error) {
+	if err != nil {
+		a.dpopErr = err
+		return
+	}
+	a.dpop = NewDPoPProver(key)
+	if a.exchanger != nil {
+		a.exchanger.dpop = a.dpop
+	}
+}
+
+// HandleChallenge records DPoP nonces and reports whether the response asks
+// for the request to be sent again with a fresh proof
+func (a *Authenticator) HandleChallenge(resp *http.Response) bool {
+	if a == nil || a.dpop == nil {
+		return false
+	}
+	return a.dpop.ObserveResponse(resp, nil)
+}
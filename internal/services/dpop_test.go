@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// verifyDPoPProof checks a proof the way a resource server would and returns
+// its claims
+func verifyDPoPProof(t *testing.T, r *http.Request) jwt.MapClaims {
+	t.Helper()
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(r.Header.Get(DPoPHeader), claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["typ"] != "dpop+jwt" {
+			t.Errorf("Expected typ dpop+jwt, got %v", token.Header["typ"])
+		}
+		jwk, _ := token.Header["jwk"].(map[string]interface{})
+		x, _ := base64.RawURLEncoding.DecodeString(jwk["x"].(string))
+		y, _ := base64.RawURLEncoding.DecodeString(jwk["y"].(string))
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}, jwt.WithValidMethods([]string{"ES256"}))
+	if err != nil {
+		t.Fatalf("Invalid DPoP proof: %v", err)
+	}
+	return claims
+}
+
+func TestDPConnectorService_DPoP(t *testing.T) {
+	var proofs []jwt.MapClaims
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "DPoP ")
+		if !found {
+			t.Errorf("Expected a DPoP authorization scheme, got %q", r.Header.Get("Authorization"))
+		}
+		claims := verifyDPoPProof(t, r)
+		proofs = append(proofs, claims)
+
+		digest := sha256.Sum256([]byte(token))
+		if claims["ath"] != base64.RawURLEncoding.EncodeToString(digest[:]) {
+			t.Errorf("Expected the proof to be bound to the access token")
+		}
+		if claims["htm"] != "POST" || claims["htu"] != "http://"+r.Host+"/verify" {
+			t.Errorf("Unexpected htm/htu: %v %v", claims["htm"], claims["htu"])
+		}
+
+		if claims["nonce"] != "server-nonce" {
+			w.Header().Set(DPoPNonceHeader, "server-nonce")
+			w.Header().Set("WWW-Authenticate", `DPoP error="use_dpop_nonce"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":              "j",
+			"status":              "completed",
+			"timestamp":           "2026-01-01T00:00:00Z",
+			"verification_result": map[string]interface{}{"verified": true, "confidence": 0.9},
+		})
+	}))
+	defer dp.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: dp.URL, DPTimeout: 5 * time.Second})
+	service.Registry().Remove(DefaultDPProviderID)
+	if err := service.Registry().Register(&DPProvider{
+		DPID:            "dp-dpop",
+		Endpoint:        dp.URL,
+		SupportedClaims: []string{AnyClaimType},
+		Auth:            &DPProviderAuth{Method: AuthMethodJWT, JWTSecret: "secret", DPoP: true},
+	}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"}); err != nil {
+			t.Fatalf("VerifyWithDP failed: %v", err)
+		}
+	}
+
+	// The first request is challenged for a nonce; the nonce is then reused
+	if len(proofs) != 3 {
+		t.Fatalf("Expected 3 proofs, got %d", len(proofs))
+	}
+	if proofs[0]["jti"] == proofs[1]["jti"] {
+		t.Error("Expected a fresh jti for the challenged request")
+	}
+	if proofs[2]["nonce"] != "server-nonce" {
+		t.Error("Expected the nonce to be remembered for later requests")
+	}
+}
+
+func TestLoadDPoPKey(t *testing.T) {
+	provider, err := config.NewLocalKeyProvider(map[string][]byte{"default": make([]byte, 32)})
+	if err != nil {
+		t.Fatalf("NewLocalKeyProvider failed: %v", err)
+	}
+	cfg := &config.Config{KeyProvider: provider, DPoPKeyFile: filepath.Join(t.TempDir(), "dpop.key"), DPoPKeyID: "default"}
+
+	first, err := LoadDPoPKey(cfg)
+	if err != nil {
+		t.Fatalf("LoadDPoPKey failed: %v", err)
+	}
+	stored, _ := os.ReadFile(cfg.DPoPKeyFile)
+	if !config.IsEncryptedValue(string(stored)) {
+		t.Errorf("Expected the key to be stored wrapped, got %q", stored)
+	}
+	second, err := LoadDPoPKey(cfg)
+	if err != nil {
+		t.Fatalf("LoadDPoPKey failed: %v", err)
+	}
+	if first.Thumbprint() != second.Thumbprint() {
+		t.Error("Expected the stored key to be reused")
+	}
+
+	if _, err := LoadDPoPKey(&config.Config{DPoPKeyFile: cfg.DPoPKeyFile}); err == nil {
+		t.Error("Expected a key file without a key provider to be rejected")
+	}
+
+	apiKey := &DPProvider{DPID: "dp", Endpoint: "https://dp.example", SupportedClaims: []string{AnyClaimType}, Auth: &DPProviderAuth{Method: AuthMethodAPIKey, DPoP: true}}
+	if err := apiKey.Validate(); err == nil {
+		t.Error("Expected dpop with API key auth to be rejected")
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Drain states
+const (
+	DrainServing  = "serving"
+	DrainDraining = "draining"
+	DrainDrained  = "drained"
+)
+
+// drainPollInterval is how often a drain checks for remaining work
+const drainPollInterval = 50 * time.Millisecond
+
+// DrainStatus reports the progress of a drain
+type DrainStatus struct {
+	State       string            `json:"state"`
+	StartedAt   *time.Time        `json:"started_at,omitempty"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+	InFlight    int               `json:"in_flight"`
+	Pending     map[string]int    `json:"pending,omitempty"`
+	Flushed     []string          `json:"flushed,omitempty"`
+	FlushErrors map[string]string `json:"flush_errors,omitempty"`
+	TimedOut    bool              `json:"timed_out,omitempty"`
+}
+
+// Remaining returns the verifications and background work still running
+func (s DrainStatus) Remaining() int {
+	remaining := s.InFlight
+	for _, count := range s.Pending {
+		remaining += count
+	}
+	return remaining
+}
+
+type drainWork struct {
+	name    string
+	pending func() int
+}
+
+type drainFlusher struct {
+	name  string
+	flush func(ctx context.Context) error
+}
+
+// Drainer takes a server out of rotation for a rolling deploy: once draining
+// it refuses new verifications, waits for in-flight requests and background
+// jobs to finish, then flushes buffered output such as audit entries
+type Drainer struct {
+	mu          sync.Mutex
+	state       string
+	inFlight    int
+	startedAt   time.Time
+	completedAt time.Time
+	timedOut    bool
+	flushed     []string
+	flushErrors map[string]string
+	work        []drainWork
+	flushers    []drainFlusher
+	done        chan struct{}
+}
+
+// NewDrainer creates a drainer in the serving state
+func NewDrainer() *Drainer {
+	return &Drainer{state: DrainServing}
+}
+
+// AddWork registers background work the drain waits for; pending returns how
+// many items are still running
+func (d *Drainer) AddWork(name string, pending func() int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.work = append(d.work, drainWork{name: name, pending: pending})
+}
+
+// AddFlusher registers a flush run once the drain's work has finished
+func (d *Drainer) AddFlusher(name string, flush func(ctx context.Context) error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.flushers = append(d.flushers, drainFlusher{name: name, flush: flush})
+}
+
+// Track admits a request, returning the function that marks it done. It
+// refuses new requests once a drain has started.
+func (d *Drainer) Track() (func(), bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.state != DrainServing {
+		return nil, false
+	}
+	d.inFlight++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.mu.Lock()
+			d.inFlight--
+			d.mu.Unlock()
+		})
+	}, true
+}
+
+// Draining reports whether a drain has started
+func (d *Drainer) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state != DrainServing
+}
+
+// Drain stops admitting requests and waits until in-flight requests and
+// registered work finish or ctx ends, then runs the flushers. Flushers run
+// even when the wait times out so buffered output is not lost. Concurrent
+// callers wait for the same drain.
+func (d *Drainer) Drain(ctx context.Context) DrainStatus {
+	if done, started := d.begin(); !started {
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		return d.Status()
+	}
+	return d.run(ctx)
+}
+
+// Start begins a drain in the background, bounded by timeout. It returns
+// false when a drain had already started.
+func (d *Drainer) Start(timeout time.Duration) bool {
+	if _, started := d.begin(); !started {
+		return false
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		d.run(ctx)
+	}()
+	return true
+}
+
+// begin moves the drainer to draining, reporting whether this call started
+// the drain
+func (d *Drainer) begin() (chan struct{}, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.done != nil {
+		return d.done, false
+	}
+	d.state = DrainDraining
+	d.startedAt = time.Now()
+	d.done = make(chan struct{})
+	return d.done, true
+}
+
+// run waits for the remaining work and flushes
+func (d *Drainer) run(ctx context.Context) DrainStatus {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	timedOut := false
+	for d.Status().Remaining() > 0 && !timedOut {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			timedOut = true
+		}
+	}
+
+	d.mu.Lock()
+	flushers := append([]drainFlusher(nil), d.flushers...)
+	d.mu.Unlock()
+
+	// The flush gets its own context when the wait used up ctx
+	flushCtx := ctx
+	if timedOut {
+		var cancel context.CancelFunc
+		flushCtx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+	}
+	var flushed []string
+	flushErrors := make(map[string]string)
+	for _, flusher := range flushers {
+		if err := flusher.flush(flushCtx); err != nil {
+			flushErrors[flusher.name] = err.Error()
+			fmt.Printf("DRAIN WARNING: flushing %s failed: %v\n", flusher.name, err)
+			continue
+		}
+		flushed = append(flushed, flusher.name)
+	}
+
+	d.mu.Lock()
+	d.state = DrainDrained
+	d.completedAt = time.Now()
+	d.timedOut = timedOut
+	d.flushed = flushed
+	if len(flushErrors) > 0 {
+		d.flushErrors = flushErrors
+	}
+	close(d.done)
+	d.mu.Unlock()
+
+	return d.Status()
+}
+
+// Status returns the drain's state and the work still remaining
+func (d *Drainer) Status() DrainStatus {
+	d.mu.Lock()
+	status := DrainStatus{
+		State:    d.state,
+		InFlight: d.inFlight,
+		TimedOut: d.timedOut,
+		Flushed:  append([]string(nil), d.flushed...),
+	}
+	if !d.startedAt.IsZero() {
+		startedAt := d.startedAt
+		status.StartedAt = &startedAt
+	}
+	if !d.completedAt.IsZero() {
+		completedAt := d.completedAt
+		status.CompletedAt = &completedAt
+	}
+	if len(d.flushErrors) > 0 {
+		status.FlushErrors = make(map[string]string, len(d.flushErrors))
+		for name, err := range d.flushErrors {
+			status.FlushErrors[name] = err
+		}
+	}
+	work := append([]drainWork(nil), d.work...)
+	d.mu.Unlock()
+
+	// Work counters take their own locks, so they are read outside ours
+	if len(work) > 0 {
+		status.Pending = make(map[string]int, len(work))
+		for _, entry := range work {
+			status.Pending[entry.name] = entry.pending()
+		}
+	}
+	return status
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDrainer_WaitsForWorkThenFlushes(t *testing.T) {
+	drainer := NewDrainer()
+	var pendingJobs int64 = 1
+	drainer.AddWork("pull_jobs", func() int { return int(atomic.LoadInt64(&pendingJobs)) })
+	var flushedAfterWork bool
+	drainer.AddFlusher("audit", func(context.Context) error {
+		flushedAfterWork = atomic.LoadInt64(&pendingJobs) == 0
+		return nil
+	})
+	drainer.AddFlusher("exports", func(context.Context) error { return errors.New("disk full") })
+
+	release, ok := drainer.Track()
+	if !ok {
+		t.Fatal("Expected requests to be admitted while serving")
+	}
+
+	done := make(chan DrainStatus, 1)
+	go func() { done <- drainer.Drain(context.Background()) }()
+	for !drainer.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := drainer.Track(); ok {
+		t.Error("Expected new requests to be refused while draining")
+	}
+	if status := drainer.Status(); status.State != DrainDraining || status.Remaining() != 2 {
+		t.Errorf("Expected the request and job to remain, got %+v", status)
+	}
+
+	release()
+	atomic.StoreInt64(&pendingJobs, 0)
+	status := <-done
+	if status.State != DrainDrained || status.TimedOut || status.Remaining() != 0 {
+		t.Errorf("Expected a completed drain, got %+v", status)
+	}
+	if !flushedAfterWork || len(status.Flushed) != 1 || status.FlushErrors["exports"] != "disk full" {
+		t.Errorf("Expected the flushers to run after the work finished, got %+v", status)
+	}
+
+	// Later calls report the finished drain
+	if again := drainer.Drain(context.Background()); again.CompletedAt == nil || !again.CompletedAt.Equal(*status.CompletedAt) {
+		t.Errorf("Expected the same drain to be reported, got %+v", again)
+	}
+}
+
+func TestDrainer_TimeoutStillFlushes(t *testing.T) {
+	drainer := NewDrainer()
+	drainer.AddWork("batches", func() int { return 3 })
+	flushed := false
+	drainer.AddFlusher("audit", func(ctx context.Context) error {
+		flushed = ctx.Err() == nil
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	status := drainer.Drain(ctx)
+	if !status.TimedOut || status.Pending["batches"] != 3 {
+		t.Errorf("Expected the drain to time out with the batches pending, got %+v", status)
+	}
+	if !flushed {
+		t.Error("Expected the audit log to be flushed with a live context after the timeout")
+	}
+
+	// Start reports a drain that already happened
+	if drainer.Start(time.Second) {
+		t.Error("Expected Start not to begin a second drain")
+	}
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// duplicateSubjectIdentifiers are the identifiers specific enough to tell
+// subjects apart; names and addresses are shared by too many people
+var duplicateSubjectIdentifiers = []string{"email", "phone", "ssn", "passport", "license"}
+
+// DuplicateSubjectSignal tells an RP whether it has verified the same subject
+// before. It carries nothing else, in particular nothing about other RPs.
+type DuplicateSubjectSignal struct {
+	SeenBefore bool `json:"seen_before"`
+	Count      int  `json:"count"`
+}
+
+// DuplicateSubjectDetector flags subjects an RP has already verified within a
+// window. Subjects are tracked by pairwise identifiers: keyed hashes of an
+// identifier and the RP, so the same person has unrelated identifiers at
+// different RPs and no lookup can cross tenants.
+type DuplicateSubjectDetector struct {
+	mu      sync.Mutex
+	key     []byte
+	window  time.Duration
+	enabled []string
+	// seen maps RP ID to pairwise identifier to verification times
+	seen map[string]map[string][]time.Time
+}
+
+// NewDuplicateSubjectDetector creates a detector for the RPs that opted in.
+// The pairwise key is generated per process and never leaves it.
+func NewDuplicateSubjectDetector(cfg *config.Config) *DuplicateSubjectDetector {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	return &DuplicateSubjectDetector{
+		key:     key,
+		window:  cfg.DuplicateDetectionWindow,
+		enabled: cfg.DuplicateDetectionRPs,
+		seen:    make(map[string]map[string][]time.Time),
+	}
+}
+
+// Enabled reports whether an RP opted in to duplicate detection
+func (d *DuplicateSubjectDetector) Enabled(rpID string) bool {
+	for _, enabled := range d.enabled {
+		if enabled == rpID || enabled == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Observe records a verification of a subject for an RP and reports how many
+// earlier verifications of the same subject the RP made within the window.
+// It returns nil when the RP has not opted in or no identifier is specific
+// enough to recognise the subject.
+func (d *DuplicateSubjectDetector) Observe(rpID string, identifiers map[string]string) *DuplicateSubjectSignal {
+	if !d.Enabled(rpID) {
+		return nil
+	}
+	pairwiseIDs := d.pairwiseIdentifiers(rpID, identifiers)
+	if len(pairwiseIDs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	cutoff := now.Add(-d.window)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	subjects, exists := d.seen[rpID]
+	if !exists {
+		subjects = make(map[string][]time.Time)
+		d.seen[rpID] = subjects
+	}
+
+	// A subject verified with several identifiers is recorded under each, so
+	// count distinct verification times rather than matches
+	previous := make(map[time.Time]bool)
+	for _, pairwiseID := range pairwiseIDs {
+		times := pruneBefore(subjects[pairwiseID], cutoff)
+		for _, seenAt := range times {
+			previous[seenAt] = true
+		}
+		subjects[pairwiseID] = append(times, now)
+	}
+
+	return &DuplicateSubjectSignal{SeenBefore: len(previous) > 0, Count: len(previous)}
+}
+
+// CleanupExpiredSubjects drops verifications older than the window
+func (d *DuplicateSubjectDetector) CleanupExpiredSubjects() {
+	cutoff := time.Now().Add(-d.window)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for rpID, subjects := range d.seen {
+		for pairwiseID, times := range subjects {
+			if times = pruneBefore(times, cutoff); len(times) == 0 {
+				delete(subjects, pairwiseID)
+			} else {
+				subjects[pairwiseID] = times
+			}
+		}
+		if len(subjects) == 0 {
+			delete(d.seen, rpID)
+		}
+	}
+}
+
+// pairwiseIdentifiers derives HMAC(key, rp_id | type | normalized value) for
+// each specific identifier of a request
+func (d *DuplicateSubjectDetector) pairwiseIdentifiers(rpID string, identifiers map[string]string) []string {
+	pairwiseIDs := make([]string, 0, len(duplicateSubjectIdentifiers))
+	for _, key := range duplicateSubjectIdentifiers {
+		value, exists := identifiers[key]
+		if !exists {
+			continue
+		}
+		normalized := normalizeSubjectIdentifier(key, value)
+		if normalized == "" {
+			continue
+		}
+
+		mac := hmac.New(sha256.New, d.key)
+		mac.Write([]byte(rpID + "\x00" + key + "\x00" + normalized))
+		pairwiseIDs = append(pairwiseIDs, hex.EncodeToString(mac.Sum(nil)))
+	}
+	return pairwiseIDs
+}
+
+// normalizeSubjectIdentifier makes formatting differences, such as case or
+// phone number punctuation, map to the same subject
+func normalizeSubjectIdentifier(key, value string) string {
+	switch key {
+	case "phone", "ssn":
+		return identifierDigits(value)
+	default:
+		return strings.ToLower(strings.Join(strings.Fields(value), ""))
+	}
+}
+
+// pruneBefore removes times before the cutoff from an ascending slice
+func pruneBefore(times []time.Time, cutoff time.Time) []time.Time {
+	for i, t := range times {
+		if !t.Before(cutoff) {
+			return times[i:]
+		}
+	}
+	return times[:0]
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestDuplicateSubjectDetector_Observe(t *testing.T) {
+	detector := NewDuplicateSubjectDetector(&config.Config{
+		DuplicateDetectionRPs:    []string{"rp_1", "rp_2"},
+		DuplicateDetectionWindow: time.Hour,
+	})
+
+	first := detector.Observe("rp_1", map[string]string{"email": "Jane.Doe@example.edu", "name": "Jane Doe"})
+	if first == nil || first.SeenBefore || first.Count != 0 {
+		t.Fatalf("Expected a first-time subject, got %+v", first)
+	}
+
+	// Formatting differences do not hide the subject
+	second := detector.Observe("rp_1", map[string]string{"email": " jane.doe@EXAMPLE.edu", "phone": "+1 415 555 0142"})
+	if second == nil || !second.SeenBefore || second.Count != 1 {
+		t.Errorf("Expected subject seen once before, got %+v", second)
+	}
+
+	// Verifications recorded under several identifiers are counted once each
+	third := detector.Observe("rp_1", map[string]string{"email": "jane.doe@example.edu", "phone": "14155550142"})
+	if third == nil || third.Count != 2 {
+		t.Errorf("Expected subject seen twice before, got %+v", third)
+	}
+
+	// Other RPs learn nothing about rp_1's verifications
+	if other := detector.Observe("rp_2", map[string]string{"email": "jane.doe@example.edu"}); other == nil || other.SeenBefore {
+		t.Errorf("Expected no cross-RP signal, got %+v", other)
+	}
+}
+
+func TestDuplicateSubjectDetector_OptInAndIdentifiers(t *testing.T) {
+	detector := NewDuplicateSubjectDetector(&config.Config{
+		DuplicateDetectionRPs:    []string{"rp_1"},
+		DuplicateDetectionWindow: time.Hour,
+	})
+
+	if signal := detector.Observe("rp_other", map[string]string{"email": "jane@example.edu"}); signal != nil {
+		t.Errorf("Expected no signal for RPs that did not opt in, got %+v", signal)
+	}
+	if signal := detector.Observe("rp_1", map[string]string{"name": "Jane Doe", "address": "1 Main St"}); signal != nil {
+		t.Errorf("Expected no signal without a specific identifier, got %+v", signal)
+	}
+	if !NewDuplicateSubjectDetector(&config.Config{DuplicateDetectionRPs: []string{"*"}}).Enabled("any_rp") {
+		t.Error("Expected * to enable every RP")
+	}
+}
+
+func TestDuplicateSubjectDetector_Window(t *testing.T) {
+	detector := NewDuplicateSubjectDetector(&config.Config{
+		DuplicateDetectionRPs:    []string{"rp_1"},
+		DuplicateDetectionWindow: 10 * time.Millisecond,
+	})
+	identifiers := map[string]string{"ssn": "123-45-6789"}
+
+	detector.Observe("rp_1", identifiers)
+	time.Sleep(20 * time.Millisecond)
+
+	if signal := detector.Observe("rp_1", identifiers); signal.SeenBefore {
+		t.Errorf("Expected verifications outside the window to be forgotten, got %+v", signal)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	detector.CleanupExpiredSubjects()
+	if len(detector.seen) != 0 {
+		t.Errorf("Expected expired subjects to be removed, got %d RPs", len(detector.seen))
+	}
+}
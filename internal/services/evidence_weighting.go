@@ -0,0 +1,173 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// EvidenceWeighting is a versioned table of how much a claim type trusts
+// each evidence code and each DP. Weights are relative: a result's weight is
+// its DP weight times the weight of its strongest evidence code, so primary
+// registry evidence can outweigh self-asserted data when DPs disagree.
+type EvidenceWeighting struct {
+	Version       string             `json:"version"`
+	EvidenceCodes map[string]float64 `json:"evidence_codes,omitempty"`
+	Providers     map[string]float64 `json:"providers,omitempty"`
+	// Defaults apply to codes and DPs missing from the table; nil means 1
+	DefaultEvidenceWeight *float64 `json:"default_evidence_weight,omitempty"`
+	DefaultProviderWeight *float64 `json:"default_provider_weight,omitempty"`
+}
+
+// Validate checks that a weighting table is usable
+func (w *EvidenceWeighting) Validate() error {
+	if w.Version == "" {
+		return fmt.Errorf("evidence weighting version is required")
+	}
+	for code, weight := range w.EvidenceCodes {
+		if weight < 0 {
+			return fmt.Errorf("evidence code %s has a negative weight", code)
+		}
+	}
+	for dpID, weight := range w.Providers {
+		if weight < 0 {
+			return fmt.Errorf("provider %s has a negative weight", dpID)
+		}
+	}
+	if (w.DefaultEvidenceWeight != nil && *w.DefaultEvidenceWeight < 0) || (w.DefaultProviderWeight != nil && *w.DefaultProviderWeight < 0) {
+		return fmt.Errorf("default weights cannot be negative")
+	}
+	return nil
+}
+
+// EvidenceResult is one DP's answer to a verification
+type EvidenceResult struct {
+	DPID       string   `json:"dp_id"`
+	Verified   bool     `json:"verified"`
+	Confidence float64  `json:"confidence"`
+	Evidence   []string `json:"evidence,omitempty"`
+	// Partial results carry the share of identifiers the DP could
+	// determine; unknown identifiers count neither for nor against
+	Partial  bool    `json:"partial,omitempty"`
+	Coverage float64 `json:"coverage,omitempty"`
+}
+
+// WeightedEvidence is a result with the weight the table gave it
+type WeightedEvidence struct {
+	EvidenceResult
+	Weight float64 `json:"weight"`
+}
+
+// EvidenceAggregate is the weighted outcome of one or more DP results
+type EvidenceAggregate struct {
+	Version    string             `json:"version"`
+	Verified   bool               `json:"verified"`
+	Confidence float64            `json:"confidence"`
+	Results    []WeightedEvidence `json:"results"`
+}
+
+// Weight returns the weight of a result: its DP weight times the weight of
+// its strongest evidence code, scaled by its coverage when it is partial
+func (w *EvidenceWeighting) Weight(result EvidenceResult) float64 {
+	providerWeight := weightOrDefault(w.Providers, result.DPID, w.DefaultProviderWeight)
+
+	evidenceWeight := weightOrDefault(nil, "", w.DefaultEvidenceWeight)
+	for i, code := range result.Evidence {
+		weight := weightOrDefault(w.EvidenceCodes, code, w.DefaultEvidenceWeight)
+		if i == 0 || weight > evidenceWeight {
+			evidenceWeight = weight
+		}
+	}
+
+	weight := providerWeight * evidenceWeight
+	if result.Partial {
+		weight *= result.Coverage
+	}
+	return weight
+}
+
+// Aggregate combines DP results. The outcome is verified when the weight of
+// verifying results exceeds the weight of the others, and the confidence is
+// the weighted mean of the results' confidence with non-verifying results
+// counting as zero. A single result keeps its own confidence.
+func (w *EvidenceWeighting) Aggregate(results []EvidenceResult) *EvidenceAggregate {
+	aggregate := &EvidenceAggregate{
+		Version: w.Version,
+		Results: make([]WeightedEvidence, 0, len(results)),
+	}
+
+	var total, support, against, weightedConfidence float64
+	for _, result := range results {
+		weight := w.Weight(result)
+		aggregate.Results = append(aggregate.Results, WeightedEvidence{EvidenceResult: result, Weight: weight})
+
+		total += weight
+		if result.Verified {
+			support += weight
+			weightedConfidence += weight * result.Confidence
+		} else {
+			against += weight
+		}
+	}
+
+	if total > 0 {
+		aggregate.Verified = support > against
+		aggregate.Confidence = weightedConfidence / total
+	}
+	return aggregate
+}
+
+func weightOrDefault(weights map[string]float64, key string, fallback *float64) float64 {
+	if weight, exists := weights[key]; exists {
+		return weight
+	}
+	if fallback != nil {
+		return *fallback
+	}
+	return 1
+}
+
+// EvidenceWeightingFile is the on-disk format of weighting tables by claim type
+type EvidenceWeightingFile struct {
+	ClaimTypes map[string]*EvidenceWeighting `json:"claim_types"`
+}
+
+// LoadEvidenceWeightingFile sets the weighting tables of a file on the
+// registered schemas. The file is applied only if every table is valid.
+func (r *ClaimSchemaRegistry) LoadEvidenceWeightingFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read evidence weighting file: %w", err)
+	}
+
+	var file EvidenceWeightingFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse evidence weighting file: %w", err)
+	}
+
+	updated := make([]*ClaimTypeSchema, 0, len(file.ClaimTypes))
+	for claimType, weighting := range file.ClaimTypes {
+		schema, exists := r.Get(claimType)
+		if !exists {
+			return fmt.Errorf("evidence weighting for unknown claim type %s", claimType)
+		}
+		if weighting == nil {
+			return fmt.Errorf("evidence weighting for %s is empty", claimType)
+		}
+		if err := weighting.Validate(); err != nil {
+			return fmt.Errorf("claim type %s: %w", claimType, err)
+		}
+
+		// Schemas are shared with readers, so replace rather than modify them
+		copied := *schema
+		copied.EvidenceWeighting = weighting
+		updated = append(updated, &copied)
+	}
+
+	for _, schema := range updated {
+		if err := r.Register(schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
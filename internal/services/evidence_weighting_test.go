@@ -0,0 +1,108 @@
+package services
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEvidenceWeighting_Aggregate(t *testing.T) {
+	selfAsserted := 0.2
+	weighting := &EvidenceWeighting{
+		Version: "2026-10-01",
+		EvidenceCodes: map[string]float64{
+			"registry_record": 1.0,
+			"self_asserted":   selfAsserted,
+		},
+		Providers: map[string]float64{"dp-untrusted": 0},
+	}
+
+	t.Run("primary source outweighs self-asserted data", func(t *testing.T) {
+		aggregate := weighting.Aggregate([]EvidenceResult{
+			{DPID: "dp-registry", Verified: true, Confidence: 0.9, Evidence: []string{"registry_record"}},
+			{DPID: "dp-survey", Verified: false, Evidence: []string{"self_asserted"}},
+			{DPID: "dp-form", Verified: false, Evidence: []string{"self_asserted"}},
+		})
+		if !aggregate.Verified {
+			t.Error("Expected registry evidence to decide the outcome")
+		}
+		if math.Abs(aggregate.Confidence-0.9/1.4) > 1e-9 {
+			t.Errorf("Expected confidence %.4f, got %.4f", 0.9/1.4, aggregate.Confidence)
+		}
+		if aggregate.Version != "2026-10-01" || len(aggregate.Results) != 3 || aggregate.Results[1].Weight != selfAsserted {
+			t.Errorf("Unexpected aggregate: %+v", aggregate)
+		}
+	})
+
+	t.Run("single result keeps its confidence", func(t *testing.T) {
+		aggregate := weighting.Aggregate([]EvidenceResult{{DPID: "dp-registry", Verified: true, Confidence: 0.8}})
+		if !aggregate.Verified || aggregate.Confidence != 0.8 {
+			t.Errorf("Unexpected aggregate: %+v", aggregate)
+		}
+	})
+
+	t.Run("strongest evidence code counts", func(t *testing.T) {
+		weight := weighting.Weight(EvidenceResult{DPID: "dp-1", Evidence: []string{"self_asserted", "registry_record"}})
+		if weight != 1.0 {
+			t.Errorf("Expected weight 1.0, got %f", weight)
+		}
+	})
+
+	t.Run("zero-weight provider", func(t *testing.T) {
+		aggregate := weighting.Aggregate([]EvidenceResult{{DPID: "dp-untrusted", Verified: true, Confidence: 1}})
+		if aggregate.Verified || aggregate.Confidence != 0 {
+			t.Errorf("Results with no weight should not verify, got %+v", aggregate)
+		}
+	})
+}
+
+func TestEvidenceWeighting_Validate(t *testing.T) {
+	negative := -1.0
+	invalid := []*EvidenceWeighting{
+		{},
+		{Version: "v1", EvidenceCodes: map[string]float64{"x": -0.5}},
+		{Version: "v1", Providers: map[string]float64{"dp": -1}},
+		{Version: "v1", DefaultEvidenceWeight: &negative},
+	}
+	for i, weighting := range invalid {
+		if err := weighting.Validate(); err == nil {
+			t.Errorf("case %d: expected a validation error", i)
+		}
+	}
+}
+
+func TestClaimSchemaRegistry_LoadEvidenceWeightingFile(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "weights.json")
+	content := `{"claim_types":{"employee_verification":{"version":"v2","evidence_codes":{"payroll_record":1,"self_asserted":0.1}}}}`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write weighting file: %v", err)
+	}
+
+	registry := NewClaimSchemaRegistry()
+	if err := registry.LoadEvidenceWeightingFile(path); err != nil {
+		t.Fatalf("LoadEvidenceWeightingFile failed: %v", err)
+	}
+	schema, _ := registry.Get("employee_verification")
+	if schema.EvidenceWeighting == nil || schema.EvidenceWeighting.Version != "v2" {
+		t.Errorf("Expected weighting v2 on employee_verification, got %+v", schema.EvidenceWeighting)
+	}
+	if builtinClaimSchemas["employee_verification"].EvidenceWeighting != nil {
+		t.Error("Loading weights should not modify the built-in schemas")
+	}
+
+	badPath := filepath.Join(dir, "bad.json")
+	bad := `{"claim_types":{"student_verification":{"version":"v1"},"unknown_claim":{"version":"v1"}}}`
+	if err := os.WriteFile(badPath, []byte(bad), 0600); err != nil {
+		t.Fatalf("Failed to write weighting file: %v", err)
+	}
+	registry = NewClaimSchemaRegistry()
+	if err := registry.LoadEvidenceWeightingFile(badPath); err == nil {
+		t.Error("Expected an error for an unknown claim type")
+	}
+	if schema, _ := registry.Get("student_verification"); schema.EvidenceWeighting != nil {
+		t.Error("A file with errors should not be partially applied")
+	}
+}
@@ -0,0 +1,554 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// ExportService handles asynchronous historical exports of RP verification records
+type ExportService struct {
+	config       *config.Config
+	records      *VerificationRecordStore
+	auditService *AuditService
+	// anonymization runs before records leave the broker
+	anonymization *AnonymizationService
+	// Export tracking
+	mu       sync.RWMutex
+	exports  map[string]*ExportJob
+	archives map[string][]byte
+	// Signed URL configuration
+	signingKey []byte
+	urlTTL     time.Duration
+	maxRange   time.Duration
+}
+
+// ExportState represents the state of an export job
+type ExportState string
+
+const (
+	ExportPending   ExportState = "pending"
+	ExportRunning   ExportState = "running"
+	ExportCompleted ExportState = "completed"
+	ExportFailed    ExportState = "failed"
+)
+
+// Redaction levels mirror the policy audit levels
+const (
+	RedactionMinimal  = "minimal"
+	RedactionStandard = "standard"
+	RedactionDetailed = "detailed"
+)
+
+// ExportRequest represents a request to export verification records
+type ExportRequest struct {
+	RPID           string    `json:"rp_id"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	ClaimType      string    `json:"claim_type,omitempty"`
+	RedactionLevel string    `json:"redaction_level,omitempty"`
+	RequestedBy    string    `json:"requested_by,omitempty"`
+}
+
+// ExportJob represents the status of an export
+type ExportJob struct {
+	ExportID       string      `json:"export_id"`
+	RPID           string      `json:"rp_id"`
+	From           time.Time   `json:"from"`
+	To             time.Time   `json:"to"`
+	ClaimType      string      `json:"claim_type,omitempty"`
+	RedactionLevel string      `json:"redaction_level"`
+	RequestedBy    string      `json:"requested_by,omitempty"`
+	Status         ExportState `json:"status"`
+	RecordCount    int         `json:"record_count"`
+	CreatedAt      time.Time   `json:"created_at"`
+	CompletedAt    *time.Time  `json:"completed_at,omitempty"`
+	ExpiresAt      *time.Time  `json:"expires_at,omitempty"`
+	DownloadURL    string      `json:"download_url,omitempty"`
+	ArchiveSHA256  string      `json:"archive_sha256,omitempty"`
+	Encryption     string      `json:"encryption,omitempty"`
+	DecryptionKey  string      `json:"decryption_key,omitempty"`
+	Error          string      `json:"error,omitempty"`
+}
+
+// ExportManifest describes the contents of an export archive
+type ExportManifest struct {
+	ExportID       string    `json:"export_id"`
+	RPID           string    `json:"rp_id"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	ClaimType      string    `json:"claim_type,omitempty"`
+	RedactionLevel string    `json:"redaction_level"`
+	RecordCount    int       `json:"record_count"`
+	GeneratedAt    time.Time `json:"generated_at"`
+	Format         string    `json:"format"`
+	// Anonymization records the anonymization applied after redaction
+	Anonymization *AnonymizationManifest `json:"anonymization,omitempty"`
+}
+
+// ExportArchive is the plaintext archive structure before compression and encryption
+type ExportArchive struct {
+	Manifest ExportManifest           `json:"manifest"`
+	Records  []map[string]interface{} `json:"records"`
+}
+
+// NewExportService creates a new export service
+func NewExportService(cfg *config.Config, records *VerificationRecordStore, auditService *AuditService) *ExportService {
+	signingKey := []byte(cfg.ExportSigningKey)
+	if len(signingKey) == 0 {
+		// Generate an ephemeral key; signed URLs will not survive restarts
+		signingKey = make([]byte, 32)
+		rand.Read(signingKey)
+	}
+
+	urlTTL := cfg.ExportURLTTL
+	if urlTTL <= 0 {
+		urlTTL = 24 * time.Hour
+	}
+
+	return &ExportService{
+		config:       cfg,
+		records:      records,
+		auditService: auditService,
+		exports:      make(map[string]*ExportJob),
+		archives:     make(map[string][]byte),
+		signingKey:   signingKey,
+		urlTTL:       urlTTL,
+		maxRange:     366 * 24 * time.Hour,
+	}
+}
+
+// SetAnonymization runs export records through the verification_records
+// anonymization profile
+func (s *ExportService) SetAnonymization(anonymization *AnonymizationService) {
+	s.anonymization = anonymization
+}
+
+// RequestExport validates and submits a new export job
+func (s *ExportService) RequestExport(ctx context.Context, req ExportRequest) (*ExportJob, error) {
+	if err := s.validateExportRequest(&req); err != nil {
+		return nil, err
+	}
+
+	job := &ExportJob{
+		ExportID:       s.generateExportID(),
+		RPID:           req.RPID,
+		From:           req.From,
+		To:             req.To,
+		ClaimType:      req.ClaimType,
+		RedactionLevel: req.RedactionLevel,
+		RequestedBy:    req.RequestedBy,
+		Status:         ExportPending,
+		CreatedAt:      time.Now(),
+	}
+
+	s.mu.Lock()
+	s.exports[job.ExportID] = job
+	s.mu.Unlock()
+
+	s.auditExport(ctx, job, "EXPORT_REQUESTED")
+
+	// Build archive in background
+	go s.processExport(context.Background(), job.ExportID)
+
+	return s.copyJob(job), nil
+}
+
+// validateExportRequest validates an export request and applies defaults
+func (s *ExportService) validateExportRequest(req *ExportRequest) error {
+	if req.RPID == "" {
+		return fmt.Errorf("rp_id is required")
+	}
+	if req.From.IsZero() || req.To.IsZero() {
+		return fmt.Errorf("from and to are required")
+	}
+	if !req.From.Before(req.To) {
+		return fmt.Errorf("from must be before to")
+	}
+	if req.To.Sub(req.From) > s.maxRange {
+		return fmt.Errorf("export range exceeds maximum of %s", s.maxRange)
+	}
+
+	switch req.RedactionLevel {
+	case "":
+		req.RedactionLevel = RedactionStandard
+	case RedactionMinimal, RedactionStandard, RedactionDetailed:
+	default:
+		return fmt.Errorf("invalid redaction level: %s", req.RedactionLevel)
+	}
+
+	return nil
+}
+
+// processExport builds, encrypts, and publishes the archive for an export
+func (s *ExportService) processExport(ctx context.Context, exportID string) {
+	s.mu.Lock()
+	job, exists := s.exports[exportID]
+	if !exists {
+		s.mu.Unlock()
+		return
+	}
+	job.Status = ExportRunning
+	s.mu.Unlock()
+
+	archive, key, count, err := s.buildArchive(job)
+	if err != nil {
+		s.failExport(ctx, exportID, err)
+		return
+	}
+
+	digest := sha256.Sum256(archive)
+	completedAt := time.Now()
+	expiresAt := completedAt.Add(s.urlTTL)
+
+	s.mu.Lock()
+	job.Status = ExportCompleted
+	job.RecordCount = count
+	job.CompletedAt = &completedAt
+	job.ExpiresAt = &expiresAt
+	job.ArchiveSHA256 = hex.EncodeToString(digest[:])
+	job.Encryption = "AES-256-GCM"
+	job.DecryptionKey = base64.StdEncoding.EncodeToString(key)
+	job.DownloadURL = s.signedURL(exportID, expiresAt)
+	s.archives[exportID] = archive
+	s.mu.Unlock()
+
+	s.auditExport(ctx, job, "EXPORT_COMPLETED")
+}
+
+// failExport marks an export as failed
+func (s *ExportService) failExport(ctx context.Context, exportID string, err error) {
+	s.mu.Lock()
+	job, exists := s.exports[exportID]
+	if exists {
+		job.Status = ExportFailed
+		job.Error = err.Error()
+	}
+	s.mu.Unlock()
+
+	if exists {
+		s.auditExport(ctx, job, "EXPORT_FAILED")
+	}
+}
+
+// buildArchive collects, redacts, anonymizes, compresses, and encrypts the records for an export
+func (s *ExportService) buildArchive(job *ExportJob) ([]byte, []byte, int, error) {
+	records := s.records.Query(VerificationRecordQuery{
+		RPID:      job.RPID,
+		ClaimType: job.ClaimType,
+		From:      job.From,
+		To:        job.To,
+	})
+
+	archive := ExportArchive{
+		Manifest: ExportManifest{
+			ExportID:       job.ExportID,
+			RPID:           job.RPID,
+			From:           job.From,
+			To:             job.To,
+			ClaimType:      job.ClaimType,
+			RedactionLevel: job.RedactionLevel,
+			RecordCount:    len(records),
+			GeneratedAt:    time.Now(),
+			Format:         "application/json+gzip",
+		},
+		Records: make([]map[string]interface{}, 0, len(records)),
+	}
+
+	for _, record := range records {
+		archive.Records = append(archive.Records, RedactVerificationRecord(record, job.RedactionLevel))
+	}
+
+	if s.anonymization != nil {
+		anonymized, manifest, err := s.anonymization.Anonymize(ExportTypeVerificationRecords, archive.Records)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		archive.Records = anonymized
+		archive.Manifest.RecordCount = len(anonymized)
+		archive.Manifest.Anonymization = manifest
+	}
+
+	payload, err := json.Marshal(archive)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to marshal archive: %w", err)
+	}
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(payload); err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to compress archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to compress archive: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to generate archive key: %w", err)
+	}
+
+	encrypted, err := encryptArchive(key, compressed.Bytes(), []byte(job.ExportID))
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return encrypted, key, len(archive.Records), nil
+}
+
+// RedactVerificationRecord returns the exportable view of a record for a redaction level
+func RedactVerificationRecord(record *VerificationRecord, level string) map[string]interface{} {
+	redacted := map[string]interface{}{
+		"verification_id": record.VerificationID,
+		"claim_type":      record.ClaimType,
+		"status":          record.Status,
+		"verified":        record.Verified,
+		"created_at":      record.CreatedAt.Format(time.RFC3339),
+	}
+
+	if level == RedactionMinimal {
+		return redacted
+	}
+
+	// Subjects are only exported as RP-scoped pseudonyms
+	subject := sha256.Sum256([]byte(record.RPID + ":" + record.UserID))
+	redacted["subject_ref"] = hex.EncodeToString(subject[:16])
+	redacted["request_id"] = record.RequestID
+	redacted["confidence_score"] = record.ConfidenceScore
+	redacted["dp_id"] = record.DPID
+	redacted["audit_reference"] = record.AuditReference
+
+	if level == RedactionDetailed {
+		redacted["reason"] = record.Reason
+		redacted["evidence"] = record.Evidence
+		redacted["identifier_types"] = record.IdentifierTypes
+	}
+
+	return redacted
+}
+
+// encryptArchive encrypts data with AES-GCM, prefixing the nonce
+func encryptArchive(key, plaintext, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// DecryptExportArchive decrypts and decompresses an export archive
+func DecryptExportArchive(key, data []byte, exportID string) (*ExportArchive, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("archive too short")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	compressed, err := gcm.Open(nil, nonce, ciphertext, []byte(exportID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt archive: %w", err)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	defer gz.Close()
+
+	var archive ExportArchive
+	if err := json.NewDecoder(gz).Decode(&archive); err != nil {
+		return nil, fmt.Errorf("failed to decode archive: %w", err)
+	}
+
+	return &archive, nil
+}
+
+// signedURL builds a time-limited download URL for an export
+func (s *ExportService) signedURL(exportID string, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	values := url.Values{}
+	values.Set("expires", expires)
+	values.Set("signature", s.sign(exportID, expires))
+	return fmt.Sprintf("%s/api/v1/exports/%s/download?%s", s.config.ExportBaseURL, exportID, values.Encode())
+}
+
+// sign computes the URL signature for an export
+func (s *ExportService) sign(exportID, expires string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(exportID + ":" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyDownloadSignature checks a signed download URL's expiry and signature
+func (s *ExportService) VerifyDownloadSignature(exportID, expires, signature string) error {
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid expiry")
+	}
+	// Links may be verified by a different instance than the one that signed them
+	if ExpiredWithSkew(time.Unix(expiresUnix, 0), time.Now(), ClockSkewTolerance(s.config)) {
+		return fmt.Errorf("download link expired")
+	}
+
+	expected := s.sign(exportID, expires)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	return nil
+}
+
+// GetExport returns an export job by ID
+func (s *ExportService) GetExport(exportID string) (*ExportJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, exists := s.exports[exportID]
+	if !exists {
+		return nil, fmt.Errorf("export not found: %s", exportID)
+	}
+	return s.copyJob(job), nil
+}
+
+// ListExports returns all exports for an RP
+func (s *ExportService) ListExports(rpID string) []*ExportJob {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := make([]*ExportJob, 0)
+	for _, job := range s.exports {
+		if job.RPID == rpID {
+			jobs = append(jobs, s.copyJob(job))
+		}
+	}
+	return jobs
+}
+
+// DownloadArchive returns the encrypted archive for a signed download request
+func (s *ExportService) DownloadArchive(ctx context.Context, exportID, expires, signature string) ([]byte, error) {
+	if err := s.VerifyDownloadSignature(exportID, expires, signature); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	job, exists := s.exports[exportID]
+	archive := s.archives[exportID]
+	s.mu.RUnlock()
+
+	if !exists || archive == nil {
+		return nil, fmt.Errorf("export not found: %s", exportID)
+	}
+
+	s.auditExport(ctx, job, "EXPORT_DOWNLOADED")
+	return archive, nil
+}
+
+// CleanupExpiredExports removes archives whose download window has closed
+func (s *ExportService) CleanupExpiredExports() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for exportID, job := range s.exports {
+		if job.ExpiresAt != nil && now.After(*job.ExpiresAt) {
+			delete(s.archives, exportID)
+			delete(s.exports, exportID)
+		}
+	}
+}
+
+// auditExport records an export lifecycle event
+func (s *ExportService) auditExport(ctx context.Context, job *ExportJob, status string) {
+	if s.auditService == nil {
+		return
+	}
+
+	s.mu.RLock()
+	metadata := map[string]interface{}{
+		"export_id":       job.ExportID,
+		"from":            job.From.Format(time.RFC3339),
+		"to":              job.To.Format(time.RFC3339),
+		"redaction_level": job.RedactionLevel,
+		"record_count":    job.RecordCount,
+		"requested_by":    job.RequestedBy,
+	}
+	if job.ClaimType != "" {
+		metadata["claim_type"] = job.ClaimType
+	}
+	if job.ArchiveSHA256 != "" {
+		metadata["archive_sha256"] = job.ArchiveSHA256
+	}
+	rpID := job.RPID
+	s.mu.RUnlock()
+
+	s.auditService.LogExportEvent(ctx, rpID, status, metadata)
+}
+
+// copyJob returns a snapshot of a job
+func (s *ExportService) copyJob(job *ExportJob) *ExportJob {
+	copied := *job
+	return &copied
+}
+
+// generateExportID generates a unique export ID
+func (s *ExportService) generateExportID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return fmt.Sprintf("export_%s", hex.EncodeToString(bytes))
+}
+
+// GetExportStats returns export statistics
+func (s *ExportService) GetExportStats() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := map[string]interface{}{
+		"total_exports":     len(s.exports),
+		"pending_exports":   0,
+		"running_exports":   0,
+		"completed_exports": 0,
+		"failed_exports":    0,
+		"stored_archives":   len(s.archives),
+	}
+
+	for _, job := range s.exports {
+		key := fmt.Sprintf("%s_exports", job.Status)
+		if count, ok := stats[key].(int); ok {
+			stats[key] = count + 1
+		}
+	}
+
+	return stats
+}
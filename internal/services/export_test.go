@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func newTestExportService(t *testing.T) (*ExportService, *VerificationRecordStore) {
+	t.Helper()
+
+	cfg := &config.Config{
+		ExportSigningKey: "test-signing-key",
+		ExportURLTTL:     time.Hour,
+	}
+	store := NewVerificationRecordStore()
+	return NewExportService(cfg, store, NewAuditService(cfg)), store
+}
+
+func waitForExport(t *testing.T, service *ExportService, exportID string) *ExportJob {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := service.GetExport(exportID)
+		if err != nil {
+			t.Fatalf("Failed to get export: %v", err)
+		}
+		if job.Status == ExportCompleted || job.Status == ExportFailed {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Export did not complete in time")
+	return nil
+}
+
+func TestExportService_RequestExport(t *testing.T) {
+	service, store := newTestExportService(t)
+	now := time.Now()
+
+	store.Append(&VerificationRecord{VerificationID: "v1", RPID: "rp_1", UserID: "user_1", ClaimType: "age_verification", Status: "verified", Verified: true, CreatedAt: now.Add(-2 * time.Hour)})
+	store.Append(&VerificationRecord{VerificationID: "v2", RPID: "rp_1", UserID: "user_2", ClaimType: "student_verification", Status: "not_found", CreatedAt: now.Add(-time.Hour)})
+	store.Append(&VerificationRecord{VerificationID: "v3", RPID: "rp_2", UserID: "user_1", ClaimType: "age_verification", Status: "verified", CreatedAt: now.Add(-time.Hour)})
+	store.Append(&VerificationRecord{VerificationID: "v4", RPID: "rp_1", UserID: "user_3", ClaimType: "age_verification", Status: "verified", CreatedAt: now.Add(-48 * time.Hour)})
+
+	job, err := service.RequestExport(context.Background(), ExportRequest{
+		RPID: "rp_1",
+		From: now.Add(-24 * time.Hour),
+		To:   now,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if job.RedactionLevel != RedactionStandard {
+		t.Errorf("Expected default redaction level %s, got %s", RedactionStandard, job.RedactionLevel)
+	}
+
+	completed := waitForExport(t, service, job.ExportID)
+	if completed.Status != ExportCompleted {
+		t.Fatalf("Expected export to complete, got %s (%s)", completed.Status, completed.Error)
+	}
+	if completed.RecordCount != 2 {
+		t.Errorf("Expected 2 records, got %d", completed.RecordCount)
+	}
+	if completed.DecryptionKey == "" || completed.DownloadURL == "" {
+		t.Fatal("Expected decryption key and download URL to be set")
+	}
+
+	// Download via signed URL and decrypt
+	parsed, err := url.Parse(completed.DownloadURL)
+	if err != nil {
+		t.Fatalf("Invalid download URL: %v", err)
+	}
+	data, err := service.DownloadArchive(context.Background(), job.ExportID, parsed.Query().Get("expires"), parsed.Query().Get("signature"))
+	if err != nil {
+		t.Fatalf("Failed to download archive: %v", err)
+	}
+
+	key, _ := base64.StdEncoding.DecodeString(completed.DecryptionKey)
+	archive, err := DecryptExportArchive(key, data, job.ExportID)
+	if err != nil {
+		t.Fatalf("Failed to decrypt archive: %v", err)
+	}
+	if archive.Manifest.RecordCount != 2 || len(archive.Records) != 2 {
+		t.Errorf("Expected 2 archived records, got %d", len(archive.Records))
+	}
+	for _, record := range archive.Records {
+		if _, exists := record["user_id"]; exists {
+			t.Error("Expected raw user_id to be redacted")
+		}
+		if _, exists := record["subject_ref"]; !exists {
+			t.Error("Expected pseudonymous subject reference")
+		}
+	}
+}
+
+func TestExportService_Validation(t *testing.T) {
+	service, _ := newTestExportService(t)
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		req  ExportRequest
+	}{
+		{"missing rp", ExportRequest{From: now.Add(-time.Hour), To: now}},
+		{"inverted range", ExportRequest{RPID: "rp_1", From: now, To: now.Add(-time.Hour)}},
+		{"range too large", ExportRequest{RPID: "rp_1", From: now.Add(-400 * 24 * time.Hour), To: now}},
+		{"invalid redaction", ExportRequest{RPID: "rp_1", From: now.Add(-time.Hour), To: now, RedactionLevel: "everything"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.RequestExport(context.Background(), tt.req); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
+
+func TestExportService_SignedURL(t *testing.T) {
+	service, _ := newTestExportService(t)
+
+	expires := time.Now().Add(time.Hour)
+	signed, _ := url.Parse(service.signedURL("export_1", expires))
+	query := signed.Query()
+
+	if err := service.VerifyDownloadSignature("export_1", query.Get("expires"), query.Get("signature")); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+
+	if err := service.VerifyDownloadSignature("export_2", query.Get("expires"), query.Get("signature")); err == nil {
+		t.Error("Expected signature bound to export ID")
+	}
+
+	expired, _ := url.Parse(service.signedURL("export_1", time.Now().Add(-time.Minute)))
+	err := service.VerifyDownloadSignature("export_1", expired.Query().Get("expires"), expired.Query().Get("signature"))
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Expected expired link error, got %v", err)
+	}
+}
+
+func TestRedactVerificationRecord(t *testing.T) {
+	record := &VerificationRecord{
+		VerificationID:  "v1",
+		RPID:            "rp_1",
+		UserID:          "user_1",
+		ClaimType:       "age_verification",
+		Reason:          "matched",
+		IdentifierTypes: []string{"email"},
+		CreatedAt:       time.Now(),
+	}
+
+	minimal := RedactVerificationRecord(record, RedactionMinimal)
+	if _, exists := minimal["subject_ref"]; exists {
+		t.Error("Expected minimal redaction to omit subject reference")
+	}
+
+	standard := RedactVerificationRecord(record, RedactionStandard)
+	if _, exists := standard["reason"]; exists {
+		t.Error("Expected standard redaction to omit reason")
+	}
+
+	detailed := RedactVerificationRecord(record, RedactionDetailed)
+	if detailed["reason"] != "matched" {
+		t.Error("Expected detailed redaction to include reason")
+	}
+
+	other := RedactVerificationRecord(&VerificationRecord{RPID: "rp_2", UserID: "user_1"}, RedactionStandard)
+	if other["subject_ref"] == standard["subject_ref"] {
+		t.Error("Expected subject references to differ across RPs")
+	}
+}
@@ -0,0 +1,1013 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on operator-supplied expressions
+const (
+	maxExpressionLength = 2048
+	maxExpressionDepth  = 64
+	// maxExpressionStringLength bounds the strings functions build
+	maxExpressionStringLength = 64 << 10
+)
+
+// Expression is a compiled expression in a subset of CEL (Common Expression
+// Language). It supports null, bool, number, string and list literals; field
+// selection and indexing; the operators ! - * / % + == != < <= > >= in && ||
+// and ?:; the functions size, has, int, double, string, concat, upper, lower
+// and trim; and the string methods startsWith, endsWith, contains, matches
+// and size. Numbers are evaluated as 64-bit floats. Expressions are
+// side-effect free and are safe to evaluate concurrently.
+type Expression struct {
+	source string
+	root   exprNode
+}
+
+// ExpressionError reports an expression that failed to compile or evaluate
+type ExpressionError struct {
+	Source   string
+	Position int
+	Message  string
+}
+
+func (e *ExpressionError) Error() string {
+	if e.Position >= 0 {
+		return fmt.Sprintf("expression %q: %s at offset %d", e.Source, e.Message, e.Position)
+	}
+	return fmt.Sprintf("expression %q: %s", e.Source, e.Message)
+}
+
+// CompileExpression parses an expression. Every identifier not followed by a
+// call must be one of the variables.
+func CompileExpression(source string, variables ...string) (*Expression, error) {
+	if len(source) > maxExpressionLength {
+		return nil, &ExpressionError{Source: source, Position: -1, Message: fmt.Sprintf("longer than %d characters", maxExpressionLength)}
+	}
+	tokens, err := lexExpression(source)
+	if err != nil {
+		return nil, err
+	}
+	declared := make(map[string]bool, len(variables))
+	for _, variable := range variables {
+		declared[variable] = true
+	}
+
+	p := &exprParser{source: source, tokens: tokens, variables: declared}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, p.errorAt(next, fmt.Sprintf("unexpected %q", next.text))
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+// String returns the expression's source
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Eval evaluates the expression with values for its variables. Values are
+// JSON-like: nil, bool, numbers, strings, slices and string-keyed maps.
+func (e *Expression) Eval(variables map[string]interface{}) (interface{}, error) {
+	value, err := e.root.eval(variables)
+	if err != nil {
+		return nil, &ExpressionError{Source: e.source, Position: -1, Message: err.Error()}
+	}
+	return value, nil
+}
+
+// EvalBool evaluates an expression that must produce a bool
+func (e *Expression) EvalBool(variables map[string]interface{}) (bool, error) {
+	value, err := e.Eval(variables)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, &ExpressionError{Source: e.source, Position: -1, Message: fmt.Sprintf("produced %s, not a bool", exprTypeName(value))}
+	}
+	return result, nil
+}
+
+// EvalNumber evaluates an expression that must produce a number
+func (e *Expression) EvalNumber(variables map[string]interface{}) (float64, error) {
+	value, err := e.Eval(variables)
+	if err != nil {
+		return 0, err
+	}
+	number, ok := numericValue(value)
+	if !ok {
+		return 0, &ExpressionError{Source: e.source, Position: -1, Message: fmt.Sprintf("produced %s, not a number", exprTypeName(value))}
+	}
+	return number, nil
+}
+
+// EvalString evaluates an expression and formats its result as a string
+func (e *Expression) EvalString(variables map[string]interface{}) (string, error) {
+	value, err := e.Eval(variables)
+	if err != nil {
+		return "", err
+	}
+	return exprString(value), nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+type exprToken struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+// exprOperators are the operator tokens, longest first
+var exprOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "!", "<", ">", "+", "-", "*", "/", "%", "?", ":", ".", ",", "(", ")", "[", "]"}
+
+func lexExpression(source string) ([]exprToken, error) {
+	var tokens []exprToken
+	for pos := 0; pos < len(source); {
+		r, width := utf8.DecodeRuneInString(source[pos:])
+		switch {
+		case unicode.IsSpace(r):
+			pos += width
+
+		case r >= '0' && r <= '9':
+			start := pos
+			for pos < len(source) && (source[pos] >= '0' && source[pos] <= '9' || source[pos] == '.' || source[pos] == 'e' || source[pos] == 'E' ||
+				(source[pos] == '-' || source[pos] == '+') && (source[pos-1] == 'e' || source[pos-1] == 'E')) {
+				pos++
+			}
+			number, err := strconv.ParseFloat(source[start:pos], 64)
+			if err != nil {
+				return nil, &ExpressionError{Source: source, Position: start, Message: fmt.Sprintf("invalid number %q", source[start:pos])}
+			}
+			tokens = append(tokens, exprToken{kind: tokenNumber, text: source[start:pos], value: number, pos: start})
+
+		case r == '"' || r == '\'':
+			start := pos
+			end := pos + 1
+			for end < len(source) && source[end] != byte(r) {
+				if source[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(source) {
+				return nil, &ExpressionError{Source: source, Position: start, Message: "unterminated string"}
+			}
+			var text strings.Builder
+			for quoted := source[start+1 : end]; quoted != ""; {
+				value, _, tail, err := strconv.UnquoteChar(quoted, byte(r))
+				if err != nil {
+					return nil, &ExpressionError{Source: source, Position: start, Message: "invalid string escape"}
+				}
+				text.WriteRune(value)
+				quoted = tail
+			}
+			pos = end + 1
+			tokens = append(tokens, exprToken{kind: tokenString, text: source[start:pos], value: text.String(), pos: start})
+
+		case r == '_' || unicode.IsLetter(r):
+			start := pos
+			for pos < len(source) {
+				next, nextWidth := utf8.DecodeRuneInString(source[pos:])
+				if next != '_' && !unicode.IsLetter(next) && !unicode.IsDigit(next) {
+					break
+				}
+				pos += nextWidth
+			}
+			tokens = append(tokens, exprToken{kind: tokenIdent, text: source[start:pos], pos: start})
+
+		default:
+			matched := ""
+			for _, operator := range exprOperators {
+				if strings.HasPrefix(source[pos:], operator) {
+					matched = operator
+					break
+				}
+			}
+			if matched == "" {
+				return nil, &ExpressionError{Source: source, Position: pos, Message: fmt.Sprintf("unexpected character %q", r)}
+			}
+			tokens = append(tokens, exprToken{kind: tokenOperator, text: matched, pos: pos})
+			pos += len(matched)
+		}
+	}
+	return append(tokens, exprToken{kind: tokenEOF, pos: len(source)}), nil
+}
+
+// exprParser is a recursive descent parser following CEL's precedence
+type exprParser struct {
+	source    string
+	tokens    []exprToken
+	pos       int
+	depth     int
+	variables map[string]bool
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	token := p.tokens[p.pos]
+	if token.kind != tokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+// accept consumes the next token if it is the operator or keyword given
+func (p *exprParser) accept(text string) bool {
+	token := p.peek()
+	if (token.kind == tokenOperator || token.kind == tokenIdent) && token.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(text string) error {
+	if !p.accept(text) {
+		token := p.peek()
+		if token.kind == tokenEOF {
+			return p.errorAt(token, fmt.Sprintf("expected %q at end of expression", text))
+		}
+		return p.errorAt(token, fmt.Sprintf("expected %q, found %q", text, token.text))
+	}
+	return nil
+}
+
+func (p *exprParser) errorAt(token exprToken, message string) error {
+	return &ExpressionError{Source: p.source, Position: token.pos, Message: message}
+}
+
+func (p *exprParser) parseExpr() (exprNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExpressionDepth {
+		return nil, p.errorAt(p.peek(), fmt.Sprintf("nested deeper than %d levels", maxExpressionDepth))
+	}
+
+	condition, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("?") {
+		return condition, nil
+	}
+	then, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &conditionalNode{condition: condition, then: then, otherwise: otherwise}, nil
+}
+
+// binaryPrecedence lists the binary operators from loosest to tightest
+var binaryPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">=", "in"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) parseBinary(level int) (exprNode, error) {
+	if level == len(binaryPrecedence) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator := ""
+		for _, candidate := range binaryPrecedence[level] {
+			if p.accept(candidate) {
+				operator = candidate
+				break
+			}
+		}
+		if operator == "" {
+			return left, nil
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{operator: operator, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	for _, operator := range []string{"!", "-"} {
+		if p.accept(operator) {
+			p.depth++
+			defer func() { p.depth-- }()
+			if p.depth > maxExpressionDepth {
+				return nil, p.errorAt(p.peek(), fmt.Sprintf("nested deeper than %d levels", maxExpressionDepth))
+			}
+			operand, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return &unaryNode{operator: operator, operand: operand}, nil
+		}
+	}
+	return p.parseMember()
+}
+
+func (p *exprParser) parseMember() (exprNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("."):
+			name := p.next()
+			if name.kind != tokenIdent {
+				return nil, p.errorAt(name, "expected a field name after '.'")
+			}
+			if p.accept("(") {
+				args, err := p.parseArgs()
+				if err != nil {
+					return nil, err
+				}
+				if _, known := exprMethods[name.text]; !known {
+					return nil, p.errorAt(name, fmt.Sprintf("unknown method %s", name.text))
+				}
+				node = &callNode{name: name.text, target: node, args: args}
+			} else {
+				node = &selectNode{operand: node, field: name.text}
+			}
+		case p.accept("["):
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			node = &indexNode{operand: node, index: index}
+		default:
+			return node, nil
+		}
+	}
+}
+
+func (p *exprParser) parseArgs() ([]exprNode, error) {
+	var args []exprNode
+	if p.accept(")") {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.accept(")") {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	token := p.next()
+	switch token.kind {
+	case tokenNumber, tokenString:
+		return &literalNode{value: token.value}, nil
+
+	case tokenIdent:
+		switch token.text {
+		case "true", "false":
+			return &literalNode{value: token.text == "true"}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		if p.accept("(") {
+			return p.parseCall(token)
+		}
+		if !p.variables[token.text] {
+			return nil, p.errorAt(token, fmt.Sprintf("undeclared reference to %s", token.text))
+		}
+		return &variableNode{name: token.text}, nil
+
+	case tokenOperator:
+		switch token.text {
+		case "(":
+			node, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		case "[":
+			list := &listNode{}
+			if p.accept("]") {
+				return list, nil
+			}
+			for {
+				element, err := p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				list.elements = append(list.elements, element)
+				if p.accept("]") {
+					return list, nil
+				}
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case tokenEOF:
+		return nil, p.errorAt(token, "unexpected end of expression")
+	}
+	return nil, p.errorAt(token, fmt.Sprintf("unexpected %q", token.text))
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	if name.text == "has" {
+		if len(args) != 1 {
+			return nil, p.errorAt(name, "has() takes one field selection")
+		}
+		selection, ok := args[0].(*selectNode)
+		if !ok {
+			return nil, p.errorAt(name, "has() argument must be a field selection, like has(request.field)")
+		}
+		return &hasNode{selection: selection}, nil
+	}
+	function, known := exprFunctions[name.text]
+	if !known {
+		return nil, p.errorAt(name, fmt.Sprintf("unknown function %s", name.text))
+	}
+	if function.variadic {
+		if len(args) == 0 {
+			return nil, p.errorAt(name, fmt.Sprintf("%s() takes at least one argument", name.text))
+		}
+	} else if len(args) != 1 {
+		return nil, p.errorAt(name, fmt.Sprintf("%s() takes one argument", name.text))
+	}
+	return &callNode{name: name.text, args: args, function: function.call}, nil
+}
+
+// exprNode is a node of a compiled expression
+type exprNode interface {
+	eval(variables map[string]interface{}) (interface{}, error)
+}
+
+type literalNode struct{ value interface{} }
+
+func (n *literalNode) eval(map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+type variableNode struct{ name string }
+
+func (n *variableNode) eval(variables map[string]interface{}) (interface{}, error) {
+	value, exists := variables[n.name]
+	if !exists {
+		return nil, fmt.Errorf("no value for %s", n.name)
+	}
+	return value, nil
+}
+
+type listNode struct{ elements []exprNode }
+
+func (n *listNode) eval(variables map[string]interface{}) (interface{}, error) {
+	list := make([]interface{}, len(n.elements))
+	for i, element := range n.elements {
+		value, err := element.eval(variables)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = value
+	}
+	return list, nil
+}
+
+type selectNode struct {
+	operand exprNode
+	field   string
+}
+
+func (n *selectNode) eval(variables map[string]interface{}) (interface{}, error) {
+	value, err := n.operand.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	object, ok := objectValue(value)
+	if !ok {
+		return nil, fmt.Errorf("cannot select field %s from %s", n.field, exprTypeName(value))
+	}
+	field, exists := object[n.field]
+	if !exists {
+		return nil, fmt.Errorf("no such key: %s", n.field)
+	}
+	return field, nil
+}
+
+// hasNode tests whether a field is present without failing when it is not
+type hasNode struct{ selection *selectNode }
+
+func (n *hasNode) eval(variables map[string]interface{}) (interface{}, error) {
+	value, err := n.selection.operand.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	object, ok := objectValue(value)
+	if !ok {
+		return nil, fmt.Errorf("has() cannot test a field of %s", exprTypeName(value))
+	}
+	_, exists := object[n.selection.field]
+	return exists, nil
+}
+
+type indexNode struct{ operand, index exprNode }
+
+func (n *indexNode) eval(variables map[string]interface{}) (interface{}, error) {
+	value, err := n.operand.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	if object, ok := objectValue(value); ok {
+		key, ok := index.(string)
+		if !ok {
+			return nil, fmt.Errorf("map key must be a string, got %s", exprTypeName(index))
+		}
+		field, exists := object[key]
+		if !exists {
+			return nil, fmt.Errorf("no such key: %s", key)
+		}
+		return field, nil
+	}
+	if list, ok := arrayValue(value); ok {
+		position, ok := numericValue(index)
+		if !ok || position != math.Trunc(position) {
+			return nil, fmt.Errorf("list index must be an integer, got %s", exprString(index))
+		}
+		if position < 0 || int(position) >= len(list) {
+			return nil, fmt.Errorf("index %d out of range", int(position))
+		}
+		return list[int(position)], nil
+	}
+	return nil, fmt.Errorf("cannot index %s", exprTypeName(value))
+}
+
+type unaryNode struct {
+	operator string
+	operand  exprNode
+}
+
+func (n *unaryNode) eval(variables map[string]interface{}) (interface{}, error) {
+	value, err := n.operand.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	if n.operator == "!" {
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("! needs a bool, got %s", exprTypeName(value))
+		}
+		return !b, nil
+	}
+	number, ok := numericValue(value)
+	if !ok {
+		return nil, fmt.Errorf("- needs a number, got %s", exprTypeName(value))
+	}
+	return -number, nil
+}
+
+type conditionalNode struct{ condition, then, otherwise exprNode }
+
+func (n *conditionalNode) eval(variables map[string]interface{}) (interface{}, error) {
+	value, err := n.condition.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	condition, ok := value.(bool)
+	if !ok {
+		return nil, fmt.Errorf("?: condition must be a bool, got %s", exprTypeName(value))
+	}
+	if condition {
+		return n.then.eval(variables)
+	}
+	return n.otherwise.eval(variables)
+}
+
+type binaryNode struct {
+	operator    string
+	left, right exprNode
+}
+
+func (n *binaryNode) eval(variables map[string]interface{}) (interface{}, error) {
+	if n.operator == "&&" || n.operator == "||" {
+		return n.evalLogical(variables)
+	}
+
+	left, err := n.left.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.operator {
+	case "==":
+		return jsonEqual(left, right), nil
+	case "!=":
+		return !jsonEqual(left, right), nil
+	case "in":
+		if object, ok := objectValue(right); ok {
+			key, ok := left.(string)
+			if !ok {
+				return false, nil
+			}
+			_, exists := object[key]
+			return exists, nil
+		}
+		list, ok := arrayValue(right)
+		if !ok {
+			return nil, fmt.Errorf("in needs a list or map, got %s", exprTypeName(right))
+		}
+		return containsJSONValue(list, left), nil
+	case "<", "<=", ">", ">=":
+		return compareExprValues(n.operator, left, right)
+	}
+
+	if n.operator == "+" {
+		if a, ok := left.(string); ok {
+			if b, ok := right.(string); ok {
+				return a + b, nil
+			}
+		}
+		if a, ok := arrayValue(left); ok {
+			if b, ok := arrayValue(right); ok {
+				return append(append([]interface{}{}, a...), b...), nil
+			}
+		}
+	}
+	a, okA := numericValue(left)
+	b, okB := numericValue(right)
+	if !okA || !okB {
+		return nil, fmt.Errorf("no such overload: %s %s %s", exprTypeName(left), n.operator, exprTypeName(right))
+	}
+	switch n.operator {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	default:
+		if b == 0 {
+			return nil, fmt.Errorf("modulus by zero")
+		}
+		return math.Mod(a, b), nil
+	}
+}
+
+// evalLogical follows CEL: an error on one side is absorbed when the other
+// side decides the result
+func (n *binaryNode) evalLogical(variables map[string]interface{}) (interface{}, error) {
+	decisive := n.operator == "||"
+	operand := func(node exprNode) (bool, error) {
+		value, err := node.eval(variables)
+		if err != nil {
+			return false, err
+		}
+		b, ok := value.(bool)
+		if !ok {
+			return false, fmt.Errorf("%s needs bools, got %s", n.operator, exprTypeName(value))
+		}
+		return b, nil
+	}
+
+	left, leftErr := operand(n.left)
+	if leftErr == nil && left == decisive {
+		return decisive, nil
+	}
+	right, rightErr := operand(n.right)
+	if rightErr == nil && right == decisive {
+		return decisive, nil
+	}
+	if leftErr != nil {
+		return nil, leftErr
+	}
+	if rightErr != nil {
+		return nil, rightErr
+	}
+	return !decisive, nil
+}
+
+func compareExprValues(operator string, left, right interface{}) (interface{}, error) {
+	var order int
+	if a, ok := numericValue(left); ok {
+		b, ok := numericValue(right)
+		if !ok {
+			return nil, fmt.Errorf("no such overload: number %s %s", operator, exprTypeName(right))
+		}
+		order = compareOrdered(a, b)
+	} else if a, ok := left.(string); ok {
+		b, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("no such overload: string %s %s", operator, exprTypeName(right))
+		}
+		order = strings.Compare(a, b)
+	} else {
+		return nil, fmt.Errorf("no such overload: %s %s %s", exprTypeName(left), operator, exprTypeName(right))
+	}
+
+	switch operator {
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	default:
+		return order >= 0, nil
+	}
+}
+
+func compareOrdered(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+type callNode struct {
+	name     string
+	target   exprNode
+	args     []exprNode
+	function func([]interface{}) (interface{}, error)
+}
+
+func (n *callNode) eval(variables map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(variables)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	if n.target == nil {
+		return n.function(args)
+	}
+
+	target, err := n.target.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	return exprMethods[n.name](target, args)
+}
+
+// exprFunction is a global function. Functions take one argument unless
+// they are variadic.
+type exprFunction struct {
+	call     func(args []interface{}) (interface{}, error)
+	variadic bool
+}
+
+// unaryFunction adapts a function of one argument
+func unaryFunction(function func(interface{}) (interface{}, error)) exprFunction {
+	return exprFunction{call: func(args []interface{}) (interface{}, error) {
+		return function(args[0])
+	}}
+}
+
+// stringFunction adapts a string function of one argument
+func stringFunction(name string, function func(string) string) exprFunction {
+	return unaryFunction(func(value interface{}) (interface{}, error) {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s() needs a string, got %s", name, exprTypeName(value))
+		}
+		return function(s), nil
+	})
+}
+
+// exprFunctions are the global functions other than the has() macro
+var exprFunctions = map[string]exprFunction{
+	"size": unaryFunction(exprSize),
+	"int": unaryFunction(func(value interface{}) (interface{}, error) {
+		number, err := exprDouble(value)
+		if err != nil {
+			return nil, err
+		}
+		return math.Trunc(number.(float64)), nil
+	}),
+	"double": unaryFunction(exprDouble),
+	"string": unaryFunction(func(value interface{}) (interface{}, error) {
+		return exprString(value), nil
+	}),
+	// concat joins its arguments formatted as string() does; null arguments
+	// are left out
+	"concat": {variadic: true, call: func(args []interface{}) (interface{}, error) {
+		var joined strings.Builder
+		for _, arg := range args {
+			if arg == nil {
+				continue
+			}
+			joined.WriteString(exprString(arg))
+			if joined.Len() > maxExpressionStringLength {
+				return nil, fmt.Errorf("concat() result longer than %d bytes", maxExpressionStringLength)
+			}
+		}
+		return joined.String(), nil
+	}},
+	"upper": stringFunction("upper", strings.ToUpper),
+	"lower": stringFunction("lower", strings.ToLower),
+	"trim":  stringFunction("trim", strings.TrimSpace),
+}
+
+// exprMethods are the methods callable on strings
+var exprMethods = map[string]func(interface{}, []interface{}) (interface{}, error){
+	"size": func(target interface{}, args []interface{}) (interface{}, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("size() takes no arguments")
+		}
+		return exprSize(target)
+	},
+	"startsWith": stringMethod("startsWith", strings.HasPrefix),
+	"endsWith":   stringMethod("endsWith", strings.HasSuffix),
+	"contains":   stringMethod("contains", strings.Contains),
+	"matches": stringMethod("matches", func(value, pattern string) bool {
+		compiled, err := compileExprPattern(pattern)
+		return err == nil && compiled.MatchString(value)
+	}),
+}
+
+func stringMethod(name string, test func(string, string) bool) func(interface{}, []interface{}) (interface{}, error) {
+	return func(target interface{}, args []interface{}) (interface{}, error) {
+		value, ok := target.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s() needs a string, got %s", name, exprTypeName(target))
+		}
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s() takes one argument", name)
+		}
+		argument, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s() argument must be a string, got %s", name, exprTypeName(args[0]))
+		}
+		if name == "matches" {
+			if _, err := compileExprPattern(argument); err != nil {
+				return nil, err
+			}
+		}
+		return test(value, argument), nil
+	}
+}
+
+// exprPatterns caches the patterns passed to matches()
+var exprPatterns = struct {
+	mu       sync.Mutex
+	compiled map[string]*regexp.Regexp
+}{compiled: make(map[string]*regexp.Regexp)}
+
+func compileExprPattern(pattern string) (*regexp.Regexp, error) {
+	exprPatterns.mu.Lock()
+	defer exprPatterns.mu.Unlock()
+	if compiled, exists := exprPatterns.compiled[pattern]; exists {
+		return compiled, nil
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	if len(exprPatterns.compiled) < 1000 {
+		exprPatterns.compiled[pattern] = compiled
+	}
+	return compiled, nil
+}
+
+func exprSize(value interface{}) (interface{}, error) {
+	if s, ok := value.(string); ok {
+		return float64(utf8.RuneCountInString(s)), nil
+	}
+	if list, ok := arrayValue(value); ok {
+		return float64(len(list)), nil
+	}
+	if object, ok := objectValue(value); ok {
+		return float64(len(object)), nil
+	}
+	return nil, fmt.Errorf("size() needs a string, list or map, got %s", exprTypeName(value))
+}
+
+func exprDouble(value interface{}) (interface{}, error) {
+	if number, ok := numericValue(value); ok {
+		return number, nil
+	}
+	if s, ok := value.(string); ok {
+		number, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %q to a number", s)
+		}
+		return number, nil
+	}
+	return nil, fmt.Errorf("cannot convert %s to a number", exprTypeName(value))
+}
+
+// exprString formats a value the way string() does: numbers without
+// trailing zeros, lists and maps in CEL literal syntax
+func exprString(value interface{}) string {
+	if number, ok := numericValue(value); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	if object, ok := objectValue(value); ok {
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			parts[i] = strconv.Quote(key) + ": " + exprString(object[key])
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	if list, ok := arrayValue(value); ok {
+		parts := make([]string, len(list))
+		for i, element := range list {
+			parts[i] = exprString(element)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// exprTypeName names a value's type in error messages
+func exprTypeName(value interface{}) string {
+	switch jsonTypeOf(value) {
+	case "boolean":
+		return "bool"
+	case "array":
+		return "list"
+	case "object":
+		return "map"
+	case "null":
+		return "null"
+	case "string", "number":
+		return jsonTypeOf(value)
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpression_Evaluate(t *testing.T) {
+	variables := map[string]interface{}{
+		"request": map[string]interface{}{
+			"claim_type":       "age_over_18",
+			"identifier_types": []interface{}{"email", "phone"},
+		},
+		"response": map[string]interface{}{
+			"verified":         true,
+			"confidence_score": 0.4,
+			"status":           "completed",
+		},
+		"duration_ms": 120.0,
+	}
+
+	for source, expected := range map[string]interface{}{
+		`1 + 2 * 3`:       7.0,
+		`(1 + 2) * 3 % 4`: 1.0,
+		`response.confidence_score < 0.5 && response.verified`: true,
+		`!response.verified || duration_ms > 100`:              true,
+		`"email" in request.identifier_types`:                  true,
+		`size(request.identifier_types) == 2`:                  true,
+		`request.claim_type.startsWith("age_")`:                true,
+		`request.claim_type.matches("^age_over_[0-9]+$")`:      true,
+		`has(response.reason)`:                                 false,
+		`has(response.reason) ? response.reason : "none"`:      "none",
+		`request.identifier_types[1]`:                          "phone",
+		`string(int(duration_ms / 100)) + "s"`:                 "1s",
+		`'it\'s' == "it's"`:                                    true,
+		`concat(request.claim_type, "/", duration_ms, null)`:   "age_over_18/120",
+		`upper(trim(" ok "))`:                                  "OK",
+	} {
+		expression, err := CompileExpression(source, "request", "response", "duration_ms")
+		if err != nil {
+			t.Errorf("%s: unexpected compile error: %v", source, err)
+			continue
+		}
+		value, err := expression.Eval(variables)
+		if err != nil || value != expected {
+			t.Errorf("%s: expected %v, got %v (%v)", source, expected, value, err)
+		}
+	}
+}
+
+func TestExpression_CompileErrors(t *testing.T) {
+	for _, source := range []string{
+		`secret == "x"`,
+		`lookup(request)`,
+		`request.claim_type ==`,
+		`(1 + 2`,
+		`"unterminated`,
+		``,
+	} {
+		_, err := CompileExpression(source, "request")
+		var expressionErr *ExpressionError
+		if !errors.As(err, &expressionErr) {
+			t.Errorf("%q: expected an ExpressionError, got %v", source, err)
+		}
+	}
+}
+
+func TestExpression_ErrorAbsorption(t *testing.T) {
+	variables := map[string]interface{}{"request": map[string]interface{}{}}
+	compile := func(source string) *Expression {
+		expression, err := CompileExpression(source, "request")
+		if err != nil {
+			t.Fatalf("%s: unexpected compile error: %v", source, err)
+		}
+		return expression
+	}
+
+	if _, err := compile(`request.missing == 1`).Eval(variables); err == nil {
+		t.Error("Expected selecting a missing field to fail")
+	}
+	if value, err := compile(`request.missing == 1 || true`).EvalBool(variables); err != nil || !value {
+		t.Errorf("Expected || to absorb the error, got %v (%v)", value, err)
+	}
+	if value, err := compile(`false && request.missing == 1`).EvalBool(variables); err != nil || value {
+		t.Errorf("Expected && to absorb the error, got %v (%v)", value, err)
+	}
+	if _, err := compile(`1 / 0`).EvalNumber(variables); err == nil {
+		t.Error("Expected division by zero to fail")
+	}
+	if _, err := compile(`"a" + 1`).Eval(variables); err == nil {
+		t.Error("Expected mismatched operand types to fail")
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// testPeerBroker is a peer broker serving its signed manifest and signing
+// verification responses with the manifest's key
+type testPeerBroker struct {
+	server   *httptest.Server
+	signer   *JWSAttestationService
+	received models.VerificationRequest
+	token    string
+	audience string
+}
+
+func newTestPeerBroker(t *testing.T) *testPeerBroker {
+	peer := &testPeerBroker{}
+	peer.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case BrokerManifestPath:
+			signed, err := peer.manifest().Manifest()
+			if err != nil {
+				t.Errorf("Peer manifest failed: %v", err)
+			}
+			w.Write([]byte(signed.SignedManifest))
+		case "/api/v1/verify":
+			json.NewDecoder(r.Body).Decode(&peer.received)
+			peer.token = r.Header.Get("Authorization")
+			audience := peer.received.RPID
+			if peer.audience != "" {
+				audience = peer.audience
+			}
+			signed, _ := peer.signer.signJWT(map[string]interface{}{
+				"iss":              "https://peer.example.com",
+				"aud":              audience,
+				"exp":              time.Now().Add(time.Hour).Unix(),
+				"verification_id":  "peer-ver-1",
+				"request_id":       "peer-req-1",
+				"audit_reference":  "peer-audit-1",
+				"status":           "verified",
+				"verified":         true,
+				"confidence_score": 0.9,
+				"evidence":         []string{"registry_match"},
+				"timestamp":        "2026-10-16T10:00:00Z",
+			})
+			w.Write(signed)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	cfg := &config.Config{Issuer: "https://peer.example.com", BrokerPublicURL: peer.server.URL}
+	peer.signer = NewJWSAttestationService(cfg)
+	t.Cleanup(peer.server.Close)
+	return peer
+}
+
+func (p *testPeerBroker) manifest() *BrokerManifestService {
+	cfg := &config.Config{Issuer: "https://peer.example.com", BrokerPublicURL: p.server.URL}
+	service := NewBrokerManifestService(cfg, p.signer)
+	schemas := NewClaimSchemaRegistry()
+	schemas.Register(&ClaimTypeSchema{ClaimType: "employee_verification", RequiredIdentifiers: []string{"work_email"}})
+	service.SetClaimSchemas(schemas)
+	return service
+}
+
+func newTestFederationService(t *testing.T, peers ...FederationPeer) *FederationService {
+	path := filepath.Join(t.TempDir(), "federation.json")
+	data, _ := json.Marshal(FederationFile{Peers: peers})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	service := NewFederationService(&config.Config{Issuer: "https://broker-a.example.com"})
+	if err := service.LoadFile(path); err != nil {
+		t.Fatalf("Expected the federation file to load, got %v", err)
+	}
+	return service
+}
+
+func testFederationPeer(peer *testPeerBroker) FederationPeer {
+	return FederationPeer{
+		ID:          "network-b",
+		Network:     "Network B",
+		ManifestURL: peer.server.URL + BrokerManifestPath,
+		RPID:        "broker-a",
+		Token:       "peer-token",
+		ClaimMappings: map[string]FederationClaimMapping{
+			"student_verification": {
+				ClaimType:   "employee_verification",
+				Identifiers: map[string]string{"email": "work_email"},
+			},
+		},
+	}
+}
+
+func TestFederationService_ForwardsAndVerifiesPeerResponse(t *testing.T) {
+	peer := newTestPeerBroker(t)
+	service := newTestFederationService(t, testFederationPeer(peer))
+
+	peerID, federated := service.Route("student_verification", nil)
+	if !federated || peerID != "network-b" {
+		t.Fatalf("Expected student verifications to be federated, got %q", peerID)
+	}
+	if _, federated := service.Route("age_verification", nil); federated {
+		t.Error("Expected unmapped claim types to stay local")
+	}
+
+	req := models.VerificationRequest{
+		RPID:        "rp-1",
+		UserID:      "user-1",
+		ClaimType:   "student_verification",
+		Identifiers: map[string]string{"email": "a@example.com", "phone": "+15550100"},
+	}
+	dpResponse, record, err := service.Forward(context.Background(), peerID, req, "req-1")
+	if err != nil {
+		t.Fatalf("Expected the verification to be forwarded, got %v", err)
+	}
+
+	received := peer.received
+	if received.ClaimType != "employee_verification" || received.RPID != "broker-a" || peer.token != "Bearer peer-token" {
+		t.Errorf("Expected the request translated for the peer, got %+v", received)
+	}
+	if len(received.Identifiers) != 1 || received.Identifiers["work_email"] != "a@example.com" {
+		t.Errorf("Expected only mapped identifiers to be forwarded, got %v", received.Identifiers)
+	}
+	if received.UserID == "user-1" || !strings.HasPrefix(received.UserID, "fed_") {
+		t.Errorf("Expected a pseudonymous user ID, got %s", received.UserID)
+	}
+	if received.Metadata["upstream_request_id"] != "req-1" || received.Metadata["upstream_broker"] != "https://broker-a.example.com" {
+		t.Errorf("Expected the upstream request to be referenced, got %v", received.Metadata)
+	}
+
+	if !dpResponse.Verified || dpResponse.ConfidenceScore != 0.9 || dpResponse.DPID != "network-b" || dpResponse.Evidence[0] != "registry_match" {
+		t.Errorf("Unexpected DP response: %+v", dpResponse)
+	}
+	if record.PeerAuditReference != "peer-audit-1" || record.PeerVerificationID != "peer-ver-1" || record.PeerIssuer != "https://peer.example.com" || record.Attestation == "" {
+		t.Errorf("Expected the peer's audit chain, got %+v", record)
+	}
+	if peers := service.Peers(); len(peers) != 1 || peers[0].Forwarded != 1 || peers[0].KeyThumbprint == "" || peers[0].ManifestExpiresAt == nil {
+		t.Errorf("Expected the peer status, got %+v", peers)
+	}
+}
+
+func TestFederationService_RejectsUntrustedPeers(t *testing.T) {
+	peer := newTestPeerBroker(t)
+
+	t.Run("pinned key mismatch", func(t *testing.T) {
+		pinned := testFederationPeer(peer)
+		pinned.KeyThumbprint = "not-the-peer-key"
+		service := newTestFederationService(t, pinned)
+		if _, _, err := service.Forward(context.Background(), "network-b", models.VerificationRequest{ClaimType: "student_verification"}, "req-1"); err == nil || !strings.Contains(err.Error(), "pinned key") {
+			t.Errorf("Expected a key mismatch, got %v", err)
+		}
+		if peers := service.Peers(); peers[0].Failed != 1 || peers[0].LastError == "" {
+			t.Errorf("Expected the failure to be recorded, got %+v", peers[0])
+		}
+	})
+
+	t.Run("response for another RP", func(t *testing.T) {
+		peer.audience = "someone-else"
+		defer func() { peer.audience = "" }()
+		service := newTestFederationService(t, testFederationPeer(peer))
+		if _, _, err := service.Forward(context.Background(), "network-b", models.VerificationRequest{ClaimType: "student_verification"}, "req-1"); err == nil || !strings.Contains(err.Error(), "invalid signed response") {
+			t.Errorf("Expected the response to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("claim type the peer does not verify", func(t *testing.T) {
+		unsupported := testFederationPeer(peer)
+		unsupported.ClaimMappings = map[string]FederationClaimMapping{"age_verification": {ClaimType: "over_18"}}
+		service := newTestFederationService(t, unsupported)
+		if _, _, err := service.Forward(context.Background(), "network-b", models.VerificationRequest{ClaimType: "age_verification"}, "req-1"); err == nil || !strings.Contains(err.Error(), "does not verify") {
+			t.Errorf("Expected the claim type to be refused, got %v", err)
+		}
+	})
+}
+
+func TestFederationService_LoadFileValidation(t *testing.T) {
+	valid := FederationPeer{
+		ID:            "network-b",
+		ManifestURL:   "https://peer.example.com" + BrokerManifestPath,
+		RPID:          "broker-a",
+		ClaimMappings: map[string]FederationClaimMapping{"age_verification": {}},
+	}
+	duplicate := valid
+	duplicate.ID = "network-c"
+	insecure := valid
+	insecure.ManifestURL = "http://peer.example.com" + BrokerManifestPath
+
+	for name, peers := range map[string][]FederationPeer{
+		"claim type mapped twice": {valid, duplicate},
+		"plain http manifest":     {insecure},
+	} {
+		path := filepath.Join(t.TempDir(), "federation.json")
+		data, _ := json.Marshal(FederationFile{Peers: peers})
+		os.WriteFile(path, data, 0600)
+		if err := NewFederationService(&config.Config{}).LoadFile(path); err == nil {
+			t.Errorf("%s: expected the federation file to be rejected", name)
+		}
+	}
+
+	fallback := valid
+	fallback.FallbackOnly = true
+	service := newTestFederationService(t, fallback)
+	registry := NewDPRegistry()
+	if _, federated := service.Route("age_verification", registry); !federated {
+		t.Error("Expected a fallback peer to be used without a local DP")
+	}
+	if err := registry.Register(&DPProvider{DPID: "dp-1", Endpoint: "https://dp.example.com", SupportedClaims: []string{"age_verification"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, federated := service.Route("age_verification", registry); federated {
+		t.Error("Expected a local DP to take precedence over a fallback peer")
+	}
+}
+
+func TestJWKThumbprint(t *testing.T) {
+	// RFC 7638 section 3.1
+	jwk := map[string]interface{}{
+		"kty": "RSA",
+		"n":   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		"e":   "AQAB",
+		"alg": "RS256",
+		"kid": "2011-04-29",
+	}
+	thumbprint, err := JWKThumbprint(jwk)
+	if err != nil || thumbprint != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("Expected the RFC 7638 thumbprint, got %s (%v)", thumbprint, err)
+	}
+}
@@ -0,0 +1,1020 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits on GraphQL documents, applied before the schema's depth and
+// complexity limits
+const (
+	maxGraphQLQueryLength = 64 << 10
+	maxGraphQLParseDepth  = 64
+	// defaultGraphQLListSize is the number of items a list field without a
+	// limit argument is assumed to return when computing complexity
+	defaultGraphQLListSize = 10
+)
+
+// Codes reported in the extensions of GraphQL errors
+const (
+	GraphQLParseFailed      = "GRAPHQL_PARSE_FAILED"
+	GraphQLValidationFailed = "GRAPHQL_VALIDATION_FAILED"
+	GraphQLQueryTooDeep     = "QUERY_TOO_DEEP"
+	GraphQLQueryTooComplex  = "QUERY_TOO_COMPLEX"
+	GraphQLResolverFailed   = "RESOLVER_FAILED"
+)
+
+// GraphQLResolver returns a field's value for its parent object, source,
+// which is nil for fields of the Query type
+type GraphQLResolver func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error)
+
+// GraphQLField is a field of a GraphQL object type. Type is a scalar
+// (String, ID, Int, Float or Boolean) or an object type of the schema,
+// optionally in a list and followed by ! when never null. A field without a
+// resolver reads its name from a map[string]interface{} parent.
+type GraphQLField struct {
+	Type    string
+	Args    map[string]string
+	Resolve GraphQLResolver
+}
+
+// GraphQLObject is an object type's fields by name
+type GraphQLObject map[string]GraphQLField
+
+// GraphQLSchema is a read-only GraphQL schema: the fields of its Query type
+// and the object types they return. Queries nested deeper than MaxDepth or
+// costing more than MaxComplexity are refused before they run; a limit of
+// zero is not enforced.
+type GraphQLSchema struct {
+	Query         GraphQLObject
+	Types         map[string]GraphQLObject
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// GraphQLRequest is a GraphQL request as posted over HTTP
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// GraphQLError is an error in a GraphQL response. Path is the response path
+// of the field that failed.
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphQLResponse is the result of a GraphQL request. Data is nil when the
+// request was refused before it ran.
+type GraphQLResponse struct {
+	Data   interface{}    `json:"data,omitempty"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+func graphQLRequestError(code, format string, args ...interface{}) *GraphQLResponse {
+	return &GraphQLResponse{Errors: []GraphQLError{{
+		Message:    fmt.Sprintf(format, args...),
+		Extensions: map[string]interface{}{"code": code},
+	}}}
+}
+
+// Execute runs a query against the schema. Parse and validation failures,
+// and queries over the schema's limits, are returned as errors without
+// data; a resolver failure nulls its field and is reported with its path.
+func (s *GraphQLSchema) Execute(ctx context.Context, req GraphQLRequest) *GraphQLResponse {
+	if len(req.Query) > maxGraphQLQueryLength {
+		return graphQLRequestError(GraphQLParseFailed, "query is longer than %d bytes", maxGraphQLQueryLength)
+	}
+	operations, err := parseGraphQL(req.Query)
+	if err != nil {
+		return graphQLRequestError(GraphQLParseFailed, "%v", err)
+	}
+	operation, err := selectGraphQLOperation(operations, req.OperationName)
+	if err != nil {
+		return graphQLRequestError(GraphQLValidationFailed, "%v", err)
+	}
+	if operation.kind != "query" {
+		return graphQLRequestError(GraphQLValidationFailed, "%s operations are not supported; the API is read-only", operation.kind)
+	}
+	variables, err := coerceGraphQLVariables(operation.variables, req.Variables)
+	if err != nil {
+		return graphQLRequestError(GraphQLValidationFailed, "%v", err)
+	}
+
+	v := &graphQLValidator{schema: s, variables: variables}
+	complexity, err := v.validate(s.Query, operation.selections, 1)
+	if err != nil {
+		return graphQLRequestError(GraphQLValidationFailed, "%v", err)
+	}
+	if s.MaxDepth > 0 && v.depth > s.MaxDepth {
+		return graphQLRequestError(GraphQLQueryTooDeep, "query depth %d exceeds the limit of %d", v.depth, s.MaxDepth)
+	}
+	if s.MaxComplexity > 0 && complexity > s.MaxComplexity {
+		return graphQLRequestError(GraphQLQueryTooComplex, "query complexity %d exceeds the limit of %d", complexity, s.MaxComplexity)
+	}
+
+	e := &graphQLExecutor{schema: s, variables: variables}
+	data := e.executeSelections(ctx, s.Query, "Query", nil, operation.selections, nil)
+	return &GraphQLResponse{Data: data, Errors: e.errors}
+}
+
+func selectGraphQLOperation(operations []*graphQLOperation, name string) (*graphQLOperation, error) {
+	if name == "" {
+		if len(operations) != 1 {
+			return nil, fmt.Errorf("operationName is required for a document with %d operations", len(operations))
+		}
+		return operations[0], nil
+	}
+	for _, operation := range operations {
+		if operation.name == name {
+			return operation, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// coerceGraphQLVariables checks the request's variables against the
+// operation's definitions, applying defaults
+func coerceGraphQLVariables(definitions []graphQLVariableDef, values map[string]interface{}) (map[string]interface{}, error) {
+	coerced := make(map[string]interface{}, len(definitions))
+	for _, definition := range definitions {
+		value, provided := values[definition.name]
+		if !provided && definition.defaultValue != nil {
+			var err error
+			if value, err = resolveGraphQLValue(definition.defaultValue, nil); err != nil {
+				return nil, err
+			}
+		}
+		value, err := coerceGraphQLInput(definition.typ, value)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %v", definition.name, err)
+		}
+		coerced[definition.name] = value
+	}
+	return coerced, nil
+}
+
+// coerceGraphQLInput checks an input value against a scalar or list type,
+// converting JSON numbers to Int and Float
+func coerceGraphQLInput(typ string, value interface{}) (interface{}, error) {
+	nonNull := strings.HasSuffix(typ, "!")
+	typ = strings.TrimSuffix(typ, "!")
+	if value == nil {
+		if nonNull {
+			return nil, fmt.Errorf("a value of type %s! is required", typ)
+		}
+		return nil, nil
+	}
+
+	if strings.HasPrefix(typ, "[") {
+		inner := strings.TrimSuffix(strings.TrimPrefix(typ, "["), "]")
+		items, ok := value.([]interface{})
+		if !ok {
+			// A single value is coerced to a list of one
+			items = []interface{}{value}
+		}
+		coerced := make([]interface{}, len(items))
+		for i, item := range items {
+			var err error
+			if coerced[i], err = coerceGraphQLInput(inner, item); err != nil {
+				return nil, err
+			}
+		}
+		return coerced, nil
+	}
+
+	switch typ {
+	case "String", "ID":
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case "Int":
+		switch n := value.(type) {
+		case int64:
+			if n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		case float64:
+			if n == math.Trunc(n) && n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		case json.Number:
+			if i, err := n.Int64(); err == nil && i >= math.MinInt32 && i <= math.MaxInt32 {
+				return int(i), nil
+			}
+		}
+	case "Float":
+		switch n := value.(type) {
+		case int64:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		case json.Number:
+			if f, err := n.Float64(); err == nil {
+				return f, nil
+			}
+		}
+	case "Boolean":
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	default:
+		return nil, fmt.Errorf("input type %s is not supported", typ)
+	}
+	return nil, fmt.Errorf("%v is not a valid %s", value, typ)
+}
+
+// graphQLNamedType strips a type's list and non-null wrappers
+func graphQLNamedType(typ string) string {
+	return strings.Trim(typ, "[]!")
+}
+
+// isGraphQLList reports whether a type is a list
+func isGraphQLList(typ string) bool {
+	return strings.HasPrefix(strings.TrimSuffix(typ, "!"), "[")
+}
+
+func isGraphQLScalar(typ string) bool {
+	switch typ {
+	case "String", "ID", "Int", "Float", "Boolean":
+		return true
+	}
+	return false
+}
+
+// graphQLValidator checks a selection set against the schema, coercing each
+// field's arguments and measuring the query's depth and complexity
+type graphQLValidator struct {
+	schema    *GraphQLSchema
+	variables map[string]interface{}
+	depth     int
+}
+
+// validate checks the selections of an object type at a depth, returning
+// their complexity: one for each field, with a list field's selections
+// counted once for each item its limit argument allows
+func (v *graphQLValidator) validate(object GraphQLObject, selections []*graphQLSelection, depth int) (int, error) {
+	if depth > v.depth {
+		v.depth = depth
+	}
+	complexity := 0
+	seen := make(map[string]bool, len(selections))
+	for _, selection := range selections {
+		included, err := selection.included(v.variables)
+		if err != nil {
+			return 0, err
+		}
+		if !included {
+			continue
+		}
+		key := selection.responseKey()
+		if seen[key] {
+			return 0, fmt.Errorf("field %q is selected more than once", key)
+		}
+		seen[key] = true
+
+		if selection.name == "__typename" {
+			if len(selection.args) > 0 || selection.selections != nil {
+				return 0, fmt.Errorf("__typename takes no arguments or selections")
+			}
+			continue
+		}
+		field, exists := object[selection.name]
+		if !exists {
+			return 0, fmt.Errorf("unknown field %q", selection.name)
+		}
+		args, err := v.coerceArgs(selection, field)
+		if err != nil {
+			return 0, err
+		}
+		selection.coercedArgs = args
+
+		named := graphQLNamedType(field.Type)
+		cost := 1
+		if isGraphQLScalar(named) {
+			if selection.selections != nil {
+				return 0, fmt.Errorf("field %q is a %s and has no selections", selection.name, named)
+			}
+		} else {
+			fieldObject, exists := v.schema.Types[named]
+			if !exists {
+				return 0, fmt.Errorf("field %q has unknown type %s", selection.name, named)
+			}
+			if len(selection.selections) == 0 {
+				return 0, fmt.Errorf("field %q of type %s needs a selection of subfields", selection.name, named)
+			}
+			childCost, err := v.validate(fieldObject, selection.selections, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			items := 1
+			if isGraphQLList(field.Type) {
+				items = defaultGraphQLListSize
+				if limit, ok := args["limit"].(int); ok && limit > 0 {
+					items = limit
+				}
+			}
+			cost += items * childCost
+		}
+		// Costs are capped so a large limit cannot overflow the total
+		complexity += cost
+		if complexity > math.MaxInt32 {
+			complexity = math.MaxInt32
+		}
+	}
+	return complexity, nil
+}
+
+// coerceArgs resolves and checks a selection's arguments against the field
+func (v *graphQLValidator) coerceArgs(selection *graphQLSelection, field GraphQLField) (map[string]interface{}, error) {
+	args := make(map[string]interface{}, len(field.Args))
+	for _, arg := range selection.args {
+		typ, exists := field.Args[arg.name]
+		if !exists {
+			return nil, fmt.Errorf("field %q has no argument %q", selection.name, arg.name)
+		}
+		value, err := resolveGraphQLValue(arg.value, v.variables)
+		if err != nil {
+			return nil, err
+		}
+		if args[arg.name], err = coerceGraphQLInput(typ, value); err != nil {
+			return nil, fmt.Errorf("argument %q of field %q: %v", arg.name, selection.name, err)
+		}
+	}
+	for name, typ := range field.Args {
+		if _, provided := args[name]; !provided && strings.HasSuffix(typ, "!") {
+			return nil, fmt.Errorf("field %q requires argument %q", selection.name, name)
+		}
+	}
+	return args, nil
+}
+
+// graphQLExecutor resolves a validated query, collecting resolver errors
+type graphQLExecutor struct {
+	schema    *GraphQLSchema
+	variables map[string]interface{}
+	errors    []GraphQLError
+}
+
+func (e *graphQLExecutor) fail(path []interface{}, err error) {
+	e.errors = append(e.errors, GraphQLError{
+		Message:    err.Error(),
+		Path:       append([]interface{}(nil), path...),
+		Extensions: map[string]interface{}{"code": GraphQLResolverFailed},
+	})
+}
+
+func (e *graphQLExecutor) executeSelections(ctx context.Context, object GraphQLObject, typeName string, source interface{}, selections []*graphQLSelection, path []interface{}) graphQLResult {
+	result := make(graphQLResult, 0, len(selections))
+	for _, selection := range selections {
+		// Directives were checked during validation
+		if included, _ := selection.included(e.variables); !included {
+			continue
+		}
+		key := selection.responseKey()
+		fieldPath := append(path[:len(path):len(path)], key)
+		if selection.name == "__typename" {
+			result = append(result, graphQLResultField{key, typeName})
+			continue
+		}
+
+		field := object[selection.name]
+		var value interface{}
+		var err error
+		if field.Resolve != nil {
+			value, err = field.Resolve(ctx, source, selection.coercedArgs)
+		} else if parent, ok := source.(map[string]interface{}); ok {
+			value = parent[selection.name]
+		}
+		if err != nil {
+			e.fail(fieldPath, err)
+			result = append(result, graphQLResultField{key, nil})
+			continue
+		}
+		result = append(result, graphQLResultField{key, e.complete(ctx, field.Type, value, selection, fieldPath)})
+	}
+	return result
+}
+
+// complete shapes a resolved value to its type and the selection's subfields
+func (e *graphQLExecutor) complete(ctx context.Context, typ string, value interface{}, selection *graphQLSelection, path []interface{}) interface{} {
+	nonNull := strings.HasSuffix(typ, "!")
+	typ = strings.TrimSuffix(typ, "!")
+	if isGraphQLNil(value) {
+		if nonNull {
+			e.fail(path, fmt.Errorf("non-null field %q resolved to null", selection.name))
+		}
+		return nil
+	}
+
+	if strings.HasPrefix(typ, "[") {
+		inner := strings.TrimSuffix(strings.TrimPrefix(typ, "["), "]")
+		items := reflect.ValueOf(value)
+		if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+			e.fail(path, fmt.Errorf("field %q resolved to %T, not a list", selection.name, value))
+			return nil
+		}
+		completed := make([]interface{}, items.Len())
+		for i := range completed {
+			completed[i] = e.complete(ctx, inner, items.Index(i).Interface(), selection, append(path[:len(path):len(path)], i))
+		}
+		return completed
+	}
+
+	if isGraphQLScalar(typ) {
+		return value
+	}
+	return e.executeSelections(ctx, e.schema.Types[typ], typ, value, selection.selections, path)
+}
+
+func isGraphQLNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	switch reflected := reflect.ValueOf(value); reflected.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return reflected.IsNil()
+	}
+	return false
+}
+
+// graphQLResult is an object in a response, keeping the order its fields
+// were selected in
+type graphQLResult []graphQLResultField
+
+type graphQLResultField struct {
+	key   string
+	value interface{}
+}
+
+func (r graphQLResult) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(field.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Parsed GraphQL documents. Values are Go values, with graphQLVariable for
+// variable references, []interface{} for lists and map[string]interface{}
+// for input objects.
+
+type graphQLOperation struct {
+	kind       string
+	name       string
+	variables  []graphQLVariableDef
+	selections []*graphQLSelection
+}
+
+type graphQLVariableDef struct {
+	name         string
+	typ          string
+	defaultValue interface{}
+}
+
+type graphQLSelection struct {
+	alias      string
+	name       string
+	args       []graphQLArgument
+	directives []graphQLDirective
+	// selections is nil for a field without a selection set
+	selections  []*graphQLSelection
+	coercedArgs map[string]interface{}
+}
+
+type graphQLArgument struct {
+	name  string
+	value interface{}
+}
+
+type graphQLDirective struct {
+	name string
+	args []graphQLArgument
+}
+
+type graphQLVariable string
+
+func (s *graphQLSelection) responseKey() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+// included applies the @skip and @include directives
+func (s *graphQLSelection) included(variables map[string]interface{}) (bool, error) {
+	for _, directive := range s.directives {
+		if directive.name != "skip" && directive.name != "include" {
+			return false, fmt.Errorf("unknown directive @%s", directive.name)
+		}
+		if len(directive.args) != 1 || directive.args[0].name != "if" {
+			return false, fmt.Errorf("@%s requires a single if argument", directive.name)
+		}
+		value, err := resolveGraphQLValue(directive.args[0].value, variables)
+		if err != nil {
+			return false, err
+		}
+		condition, ok := value.(bool)
+		if !ok {
+			return false, fmt.Errorf("@%s(if:) must be a Boolean", directive.name)
+		}
+		if condition == (directive.name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// resolveGraphQLValue substitutes variables into a parsed value
+func resolveGraphQLValue(value interface{}, variables map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case graphQLVariable:
+		resolved, defined := variables[string(v)]
+		if !defined {
+			return nil, fmt.Errorf("variable $%s is not defined", string(v))
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if resolved[i], err = resolveGraphQLValue(item, variables); err != nil {
+				return nil, err
+			}
+		}
+		return resolved, nil
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			var err error
+			if resolved[key], err = resolveGraphQLValue(item, variables); err != nil {
+				return nil, err
+			}
+		}
+		return resolved, nil
+	}
+	return value, nil
+}
+
+type graphQLTokenKind int
+
+const (
+	graphQLTokenEOF graphQLTokenKind = iota
+	graphQLTokenPunct
+	graphQLTokenName
+	graphQLTokenInt
+	graphQLTokenFloat
+	graphQLTokenString
+)
+
+type graphQLToken struct {
+	kind graphQLTokenKind
+	text string
+	pos  int
+}
+
+// lexGraphQL splits a document into tokens, dropping whitespace, commas
+// and comments
+func lexGraphQL(source string) ([]graphQLToken, error) {
+	tokens := make([]graphQLToken, 0)
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(source[i:], "..."):
+			tokens = append(tokens, graphQLToken{graphQLTokenPunct, "...", i})
+			i += 3
+		case strings.ContainsRune("!$()=:@[]{}|&", rune(c)):
+			tokens = append(tokens, graphQLToken{graphQLTokenPunct, string(c), i})
+			i++
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			start := i
+			for i < len(source) && (source[i] == '_' || (source[i] >= 'a' && source[i] <= 'z') || (source[i] >= 'A' && source[i] <= 'Z') || (source[i] >= '0' && source[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, graphQLToken{graphQLTokenName, source[start:i], start})
+		case c == '-' || (c >= '0' && c <= '9'):
+			start := i
+			kind := graphQLTokenInt
+			i++
+			for i < len(source) {
+				d := source[i]
+				if d >= '0' && d <= '9' {
+					i++
+				} else if d == '.' || d == 'e' || d == 'E' || ((d == '+' || d == '-') && (source[i-1] == 'e' || source[i-1] == 'E')) {
+					kind = graphQLTokenFloat
+					i++
+				} else {
+					break
+				}
+			}
+			tokens = append(tokens, graphQLToken{kind, source[start:i], start})
+		case c == '"':
+			text, end, err := lexGraphQLString(source, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, graphQLToken{graphQLTokenString, text, i})
+			i = end
+		default:
+			r, _ := utf8.DecodeRuneInString(source[i:])
+			return nil, fmt.Errorf("unexpected character %q at offset %d", r, i)
+		}
+	}
+	return append(tokens, graphQLToken{graphQLTokenEOF, "", len(source)}), nil
+}
+
+// lexGraphQLString reads the string starting at offset start, returning its
+// value and the offset after it. Block strings are not supported.
+func lexGraphQLString(source string, start int) (string, int, error) {
+	if strings.HasPrefix(source[start:], `"""`) {
+		return "", 0, fmt.Errorf("block strings are not supported at offset %d", start)
+	}
+	var value strings.Builder
+	for i := start + 1; i < len(source); {
+		c := source[i]
+		switch c {
+		case '"':
+			return value.String(), i + 1, nil
+		case '\n', '\r':
+			return "", 0, fmt.Errorf("unterminated string at offset %d", start)
+		case '\\':
+			if i+1 >= len(source) {
+				return "", 0, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			switch escape := source[i+1]; escape {
+			case '"', '\\', '/':
+				value.WriteByte(escape)
+			case 'b':
+				value.WriteByte('\b')
+			case 'f':
+				value.WriteByte('\f')
+			case 'n':
+				value.WriteByte('\n')
+			case 'r':
+				value.WriteByte('\r')
+			case 't':
+				value.WriteByte('\t')
+			case 'u':
+				if i+6 > len(source) {
+					return "", 0, fmt.Errorf("invalid unicode escape at offset %d", i)
+				}
+				code, err := strconv.ParseUint(source[i+2:i+6], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid unicode escape at offset %d", i)
+				}
+				value.WriteRune(rune(code))
+				i += 4
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c at offset %d", escape, i)
+			}
+			i += 2
+		default:
+			value.WriteByte(c)
+			i++
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string at offset %d", start)
+}
+
+// graphQLParser parses the executable subset of GraphQL the API serves:
+// operations with variables, fields with aliases, arguments and the @skip
+// and @include directives. Fragments are not supported.
+type graphQLParser struct {
+	tokens []graphQLToken
+	pos    int
+	depth  int
+}
+
+func parseGraphQL(source string) ([]*graphQLOperation, error) {
+	tokens, err := lexGraphQL(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &graphQLParser{tokens: tokens}
+	operations := make([]*graphQLOperation, 0, 1)
+	for p.peek().kind != graphQLTokenEOF {
+		operation, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, operation)
+	}
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("document has no operations")
+	}
+	return operations, nil
+}
+
+func (p *graphQLParser) peek() graphQLToken {
+	return p.tokens[p.pos]
+}
+
+func (p *graphQLParser) next() graphQLToken {
+	token := p.tokens[p.pos]
+	if token.kind != graphQLTokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+func (p *graphQLParser) accept(punct string) bool {
+	if token := p.peek(); token.kind == graphQLTokenPunct && token.text == punct {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *graphQLParser) expect(punct string) error {
+	if !p.accept(punct) {
+		return p.unexpected(fmt.Sprintf("expected %q", punct))
+	}
+	return nil
+}
+
+func (p *graphQLParser) expectName() (string, error) {
+	token := p.peek()
+	if token.kind != graphQLTokenName {
+		return "", p.unexpected("expected a name")
+	}
+	p.pos++
+	return token.text, nil
+}
+
+func (p *graphQLParser) unexpected(message string) error {
+	token := p.peek()
+	if token.kind == graphQLTokenEOF {
+		return fmt.Errorf("%s, found end of document", message)
+	}
+	return fmt.Errorf("%s, found %q at offset %d", message, token.text, token.pos)
+}
+
+func (p *graphQLParser) parseOperation() (*graphQLOperation, error) {
+	operation := &graphQLOperation{kind: "query"}
+	if token := p.peek(); token.kind == graphQLTokenName {
+		switch token.text {
+		case "query", "mutation", "subscription":
+			operation.kind = token.text
+		case "fragment":
+			return nil, fmt.Errorf("fragments are not supported")
+		default:
+			return nil, p.unexpected("expected an operation")
+		}
+		p.pos++
+		if p.peek().kind == graphQLTokenName {
+			operation.name = p.next().text
+		}
+		if p.accept("(") {
+			for !p.accept(")") {
+				definition, err := p.parseVariableDefinition()
+				if err != nil {
+					return nil, err
+				}
+				operation.variables = append(operation.variables, definition)
+			}
+		}
+	}
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	operation.selections = selections
+	return operation, nil
+}
+
+func (p *graphQLParser) parseVariableDefinition() (graphQLVariableDef, error) {
+	if err := p.expect("$"); err != nil {
+		return graphQLVariableDef{}, err
+	}
+	name, err := p.expectName()
+	if err != nil {
+		return graphQLVariableDef{}, err
+	}
+	if err := p.expect(":"); err != nil {
+		return graphQLVariableDef{}, err
+	}
+	typ, err := p.parseType()
+	if err != nil {
+		return graphQLVariableDef{}, err
+	}
+	definition := graphQLVariableDef{name: name, typ: typ}
+	if p.accept("=") {
+		if definition.defaultValue, err = p.parseValue(true); err != nil {
+			return graphQLVariableDef{}, err
+		}
+	}
+	return definition, nil
+}
+
+func (p *graphQLParser) parseType() (string, error) {
+	var typ string
+	if p.accept("[") {
+		inner, err := p.parseType()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		typ = "[" + inner + "]"
+	} else {
+		name, err := p.expectName()
+		if err != nil {
+			return "", err
+		}
+		typ = name
+	}
+	if p.accept("!") {
+		typ += "!"
+	}
+	return typ, nil
+}
+
+func (p *graphQLParser) parseSelectionSet() ([]*graphQLSelection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	p.depth++
+	if p.depth > maxGraphQLParseDepth {
+		return nil, fmt.Errorf("selections nest deeper than %d levels", maxGraphQLParseDepth)
+	}
+	selections := make([]*graphQLSelection, 0)
+	for !p.accept("}") {
+		if p.peek().kind == graphQLTokenEOF {
+			return nil, p.unexpected(`expected "}"`)
+		}
+		if p.accept("...") {
+			return nil, fmt.Errorf("fragments are not supported")
+		}
+		selection, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	p.depth--
+	if len(selections) == 0 {
+		return nil, fmt.Errorf("selection set is empty")
+	}
+	return selections, nil
+}
+
+func (p *graphQLParser) parseField() (*graphQLSelection, error) {
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	selection := &graphQLSelection{name: name}
+	if p.accept(":") {
+		selection.alias = name
+		if selection.name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+	if selection.args, err = p.parseArguments(); err != nil {
+		return nil, err
+	}
+	for p.accept("@") {
+		directive := graphQLDirective{}
+		if directive.name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+		if directive.args, err = p.parseArguments(); err != nil {
+			return nil, err
+		}
+		selection.directives = append(selection.directives, directive)
+	}
+	if token := p.peek(); token.kind == graphQLTokenPunct && token.text == "{" {
+		if selection.selections, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return selection, nil
+}
+
+func (p *graphQLParser) parseArguments() ([]graphQLArgument, error) {
+	if !p.accept("(") {
+		return nil, nil
+	}
+	args := make([]graphQLArgument, 0)
+	for !p.accept(")") {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue(false)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, graphQLArgument{name: name, value: value})
+	}
+	return args, nil
+}
+
+// parseValue parses a value; constant values, such as variable defaults,
+// may not reference variables
+func (p *graphQLParser) parseValue(constant bool) (interface{}, error) {
+	token := p.next()
+	switch token.kind {
+	case graphQLTokenInt:
+		n, err := strconv.ParseInt(token.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Int %s at offset %d", token.text, token.pos)
+		}
+		return n, nil
+	case graphQLTokenFloat:
+		f, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Float %s at offset %d", token.text, token.pos)
+		}
+		return f, nil
+	case graphQLTokenString:
+		return token.text, nil
+	case graphQLTokenName:
+		switch token.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		// Enum values are passed to resolvers as strings
+		return token.text, nil
+	case graphQLTokenPunct:
+		switch token.text {
+		case "$":
+			if constant {
+				return nil, fmt.Errorf("variables are not allowed in constant values at offset %d", token.pos)
+			}
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			return graphQLVariable(name), nil
+		case "[":
+			items := make([]interface{}, 0)
+			for !p.accept("]") {
+				if p.peek().kind == graphQLTokenEOF {
+					return nil, p.unexpected(`expected "]"`)
+				}
+				item, err := p.parseValue(constant)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+			return items, nil
+		case "{":
+			fields := make(map[string]interface{})
+			for !p.accept("}") {
+				name, err := p.expectName()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if fields[name], err = p.parseValue(constant); err != nil {
+					return nil, err
+				}
+			}
+			return fields, nil
+		}
+	}
+	if token.kind != graphQLTokenEOF {
+		p.pos--
+	}
+	return nil, p.unexpected("expected a value")
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func testGraphQLSchema() *GraphQLSchema {
+	books := []interface{}{
+		map[string]interface{}{"title": "Dune", "year": 1965, "author": map[string]interface{}{"name": "Herbert"}},
+		map[string]interface{}{"title": "Emma", "year": 1815, "author": map[string]interface{}{"name": "Austen"}},
+	}
+	return &GraphQLSchema{
+		Query: GraphQLObject{
+			"books": {
+				Type: "[Book!]!",
+				Args: map[string]string{"limit": "Int", "title": "String"},
+				Resolve: func(_ context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					if title, ok := args["title"].(string); ok {
+						for _, book := range books {
+							if book.(map[string]interface{})["title"] == title {
+								return []interface{}{book}, nil
+							}
+						}
+						return []interface{}{}, nil
+					}
+					if limit, ok := args["limit"].(int); ok && limit < len(books) {
+						return books[:limit], nil
+					}
+					return books, nil
+				},
+			},
+			"broken": {
+				Type: "Book",
+				Resolve: func(context.Context, interface{}, map[string]interface{}) (interface{}, error) {
+					return nil, fmt.Errorf("backend unavailable")
+				},
+			},
+		},
+		Types: map[string]GraphQLObject{
+			"Book": {
+				"title":  {Type: "String!"},
+				"year":   {Type: "Int"},
+				"author": {Type: "Author"},
+			},
+			"Author": {
+				"name": {Type: "String!"},
+			},
+		},
+		MaxDepth:      3,
+		MaxComplexity: 100,
+	}
+}
+
+func executeGraphQL(t *testing.T, schema *GraphQLSchema, req GraphQLRequest) (string, *GraphQLResponse) {
+	t.Helper()
+	response := schema.Execute(context.Background(), req)
+	data, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("Failed to encode response: %v", err)
+	}
+	return string(data), response
+}
+
+func TestGraphQLSchema_Execute(t *testing.T) {
+	schema := testGraphQLSchema()
+
+	body, _ := executeGraphQL(t, schema, GraphQLRequest{Query: `{ books(limit: 1) { year name: title author { name } __typename } }`})
+	want := `{"data":{"books":[{"year":1965,"name":"Dune","author":{"name":"Herbert"},"__typename":"Book"}]}}`
+	if body != want {
+		t.Errorf("Expected %s, got %s", want, body)
+	}
+
+	// Variables, defaults and directives
+	query := `query Find($title: String!, $withYear: Boolean = false) {
+		books(title: $title) { title year @include(if: $withYear) }
+	}`
+	body, _ = executeGraphQL(t, schema, GraphQLRequest{Query: query, Variables: map[string]interface{}{"title": "Emma"}})
+	if want := `{"data":{"books":[{"title":"Emma"}]}}`; body != want {
+		t.Errorf("Expected %s, got %s", want, body)
+	}
+	body, _ = executeGraphQL(t, schema, GraphQLRequest{Query: query, Variables: map[string]interface{}{"title": "Emma", "withYear": true}})
+	if want := `{"data":{"books":[{"title":"Emma","year":1815}]}}`; body != want {
+		t.Errorf("Expected %s, got %s", want, body)
+	}
+}
+
+func TestGraphQLSchema_ResolverErrors(t *testing.T) {
+	body, response := executeGraphQL(t, testGraphQLSchema(), GraphQLRequest{Query: `{ broken { title } books(limit: 1) { title } }`})
+	if response.Data == nil || len(response.Errors) != 1 {
+		t.Fatalf("Expected data with one error, got %s", body)
+	}
+	if err := response.Errors[0]; err.Message != "backend unavailable" || len(err.Path) != 1 || err.Path[0] != "broken" {
+		t.Errorf("Unexpected error: %+v", err)
+	}
+}
+
+func TestGraphQLSchema_Refusals(t *testing.T) {
+	tests := []struct {
+		name string
+		req  GraphQLRequest
+		code string
+	}{
+		{"syntax error", GraphQLRequest{Query: `{ books { title }`}, GraphQLParseFailed},
+		{"fragment", GraphQLRequest{Query: `{ books { ...fields } }`}, GraphQLParseFailed},
+		{"mutation", GraphQLRequest{Query: `mutation { books { title } }`}, GraphQLValidationFailed},
+		{"unknown field", GraphQLRequest{Query: `{ books { isbn } }`}, GraphQLValidationFailed},
+		{"unknown argument", GraphQLRequest{Query: `{ books(sort: "year") { title } }`}, GraphQLValidationFailed},
+		{"missing selections", GraphQLRequest{Query: `{ books }`}, GraphQLValidationFailed},
+		{"wrong argument type", GraphQLRequest{Query: `{ books(limit: "two") { title } }`}, GraphQLValidationFailed},
+		{"missing variable", GraphQLRequest{Query: `query($t: String!) { books(title: $t) { title } }`}, GraphQLValidationFailed},
+		{"undefined variable", GraphQLRequest{Query: `{ books(title: $t) { title } }`}, GraphQLValidationFailed},
+		{"operation not named", GraphQLRequest{Query: `query A { books { title } } query B { books { year } }`}, GraphQLValidationFailed},
+		{"too deep", GraphQLRequest{Query: `{ books { author { name } } }`}, GraphQLQueryTooDeep},
+		{"too complex", GraphQLRequest{Query: `{ books(limit: 60) { title year } }`}, GraphQLQueryTooComplex},
+	}
+
+	schema := testGraphQLSchema()
+	schema.MaxDepth = 2
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, response := executeGraphQL(t, schema, tt.req)
+			if response.Data != nil || len(response.Errors) != 1 || response.Errors[0].Extensions["code"] != tt.code {
+				t.Errorf("Expected a %s refusal, got %s", tt.code, body)
+			}
+		})
+	}
+}
+
+func TestGraphQLSchema_Complexity(t *testing.T) {
+	schema := testGraphQLSchema()
+	v := &graphQLValidator{schema: schema, variables: map[string]interface{}{}}
+	operations, err := parseGraphQL(`{ books(limit: 3) { title author { name } } }`)
+	if err != nil {
+		t.Fatalf("parseGraphQL failed: %v", err)
+	}
+	// books costs 1, plus 3 items of title (1) and author (1 + name 1)
+	complexity, err := v.validate(schema.Query, operations[0].selections, 1)
+	if err != nil || complexity != 10 || v.depth != 3 {
+		t.Errorf("Expected complexity 10 at depth 3, got %d at %d (%v)", complexity, v.depth, err)
+	}
+}
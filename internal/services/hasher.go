@@ -0,0 +1,241 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/sha3"
+)
+
+// Identifier hash schemes accepted by HASH_SCHEME
+const (
+	// HashSchemeSHA256 is the original salted SHA-256 scheme. Its hashes
+	// carry no prefix, so existing DP integrations keep matching.
+	HashSchemeSHA256     = "sha256"
+	HashSchemeHMACSHA256 = "hmac-sha256"
+	HashSchemeHMACSHA3   = "hmac-sha3-256"
+	// HashSchemeArgon2id slows down dictionary attacks on identifiers with
+	// few possible values, such as dates of birth or postcodes
+	HashSchemeArgon2id = "argon2id"
+)
+
+// hashSchemeAlgorithms maps schemes onto crypto profile algorithm names
+var hashSchemeAlgorithms = map[string]string{
+	HashSchemeSHA256:     "SHA-256",
+	HashSchemeHMACSHA256: "HMAC-SHA256",
+	HashSchemeHMACSHA3:   "HMAC-SHA3-256",
+	HashSchemeArgon2id:   "Argon2id",
+}
+
+// hasherRPKeyInfo separates the per-RP keys derived from HASH_KEY
+const hasherRPKeyInfo = "pavilion-identifier-hash-rp:"
+
+// Hasher hashes identifiers under one configured scheme. Keyed schemes use an
+// HMAC key per RP, derived from HASH_KEY, so hashes of the same identifier
+// cannot be linked across RPs. Keyed hashes are prefixed "scheme:vN:" with the
+// key version, so hashes made under a previous key or scheme stay verifiable
+// while they are rotated out.
+type Hasher struct {
+	scheme           string
+	version          string
+	keys             map[string][]byte
+	lowEntropyFields map[string]bool
+	legacySalt       string
+	argon2Time       uint32
+	argon2MemoryKB   uint32
+}
+
+// NewHasher builds the hasher described by the configuration
+func NewHasher(cfg *config.Config) (*Hasher, error) {
+	h := &Hasher{
+		scheme:           HashSchemeSHA256,
+		version:          "1",
+		keys:             make(map[string][]byte),
+		lowEntropyFields: make(map[string]bool),
+		legacySalt:       "pavilion_deterministic_salt_v1",
+		argon2Time:       2,
+		argon2MemoryKB:   19456,
+	}
+	if cfg == nil {
+		return h, nil
+	}
+
+	if cfg.HashSalt != "" {
+		h.legacySalt = cfg.HashSalt
+	}
+	if cfg.HashScheme != "" {
+		h.scheme = cfg.HashScheme
+	}
+	if _, known := hashSchemeAlgorithms[h.scheme]; !known {
+		return nil, fmt.Errorf("unknown hash scheme: %s", h.scheme)
+	}
+	if cfg.HashKeyVersion != "" {
+		h.version = cfg.HashKeyVersion
+	}
+	if cfg.HashArgon2Time > 0 {
+		h.argon2Time = uint32(cfg.HashArgon2Time)
+	}
+	if cfg.HashArgon2MemoryKB > 0 {
+		h.argon2MemoryKB = uint32(cfg.HashArgon2MemoryKB)
+	}
+	for _, field := range cfg.HashLowEntropyFields {
+		h.lowEntropyFields[strings.ToLower(field)] = true
+	}
+
+	for _, entry := range cfg.HashPreviousKeys {
+		version, key, found := strings.Cut(entry, "=")
+		if !found || version == "" || key == "" {
+			return nil, fmt.Errorf("HASH_PREVIOUS_KEYS entries must be version=key")
+		}
+		h.keys[version] = []byte(key)
+	}
+	if cfg.HashKey != "" {
+		h.keys[h.version] = []byte(cfg.HashKey)
+	}
+
+	if h.scheme != HashSchemeSHA256 || len(h.lowEntropyFields) > 0 {
+		if cfg.HashKey == "" {
+			return nil, fmt.Errorf("hash scheme %s requires HASH_KEY", h.scheme)
+		}
+		if len(cfg.HashKey) < 16 {
+			return nil, fmt.Errorf("HASH_KEY must be at least 16 bytes")
+		}
+	}
+	return h, nil
+}
+
+// Scheme returns the scheme new hashes are made with
+func (h *Hasher) Scheme() string {
+	return h.scheme
+}
+
+// SchemeFor returns the scheme a field is hashed with; low-entropy fields use
+// Argon2id whatever the configured scheme
+func (h *Hasher) SchemeFor(field string) string {
+	if h.lowEntropyFields[strings.ToLower(field)] {
+		return HashSchemeArgon2id
+	}
+	return h.scheme
+}
+
+// Hash hashes an identifier field for an RP under the current scheme and key
+func (h *Hasher) Hash(rpID, field, value string) (string, error) {
+	if value == "" {
+		return "", fmt.Errorf("identifier cannot be empty")
+	}
+	scheme := h.SchemeFor(field)
+	if scheme == HashSchemeSHA256 {
+		return h.legacyHash(value), nil
+	}
+	digest, err := h.digest(scheme, h.version, rpID, field, value)
+	if err != nil {
+		return "", err
+	}
+	return scheme + ":v" + h.version + ":" + digest, nil
+}
+
+// Matches reports whether a stored hash is of the value, using the scheme and
+// key version recorded in its prefix
+func (h *Hasher) Matches(rpID, field, value, hashed string) (bool, error) {
+	scheme, version, digest := ParseHashPrefix(hashed)
+	if scheme == HashSchemeSHA256 {
+		return subtle.ConstantTimeCompare([]byte(h.legacyHash(value)), []byte(digest)) == 1, nil
+	}
+	expected, err := h.digest(scheme, version, rpID, field, value)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(digest)) == 1, nil
+}
+
+// NeedsRehash reports whether a stored hash was made under another scheme or
+// key version than the field would be hashed with now
+func (h *Hasher) NeedsRehash(field, hashed string) bool {
+	scheme, version, _ := ParseHashPrefix(hashed)
+	if scheme != h.SchemeFor(field) {
+		return true
+	}
+	return scheme != HashSchemeSHA256 && version != h.version
+}
+
+// ParseHashPrefix splits a hash into its scheme, key version and hex digest.
+// Hashes without a prefix are legacy SHA-256 hashes.
+func ParseHashPrefix(hashed string) (scheme, version, digest string) {
+	parts := strings.SplitN(hashed, ":", 3)
+	if len(parts) == 3 && strings.HasPrefix(parts[1], "v") {
+		if _, known := hashSchemeAlgorithms[parts[0]]; known {
+			return parts[0], strings.TrimPrefix(parts[1], "v"), parts[2]
+		}
+	}
+	return HashSchemeSHA256, "", hashed
+}
+
+// digest computes a keyed hash under a key version
+func (h *Hasher) digest(scheme, version, rpID, field, value string) (string, error) {
+	master, exists := h.keys[version]
+	if !exists {
+		return "", fmt.Errorf("unknown hash key version: %s", version)
+	}
+	key := h.rpKey(master, rpID)
+	// The field is bound in, so equal values of different fields differ
+	message := []byte(strings.ToLower(field) + "\x00" + value)
+
+	switch scheme {
+	case HashSchemeHMACSHA256:
+		return hex.EncodeToString(hmacSum(sha256.New, key, message)), nil
+	case HashSchemeHMACSHA3:
+		return hex.EncodeToString(hmacSum(sha3.New256, key, message)), nil
+	case HashSchemeArgon2id:
+		// The salt must be deterministic for DPs to match; keying it keeps
+		// precomputed tables from being reused across RPs
+		salt := hmacSum(sha256.New, key, []byte("argon2id-salt\x00"+strings.ToLower(field)))[:16]
+		return hex.EncodeToString(argon2.IDKey(message, salt, h.argon2Time, h.argon2MemoryKB, 1, 32)), nil
+	default:
+		return "", fmt.Errorf("unsupported hash scheme: %s", scheme)
+	}
+}
+
+// rpKey derives the HMAC key for an RP
+func (h *Hasher) rpKey(master []byte, rpID string) []byte {
+	return hmacSum(sha256.New, master, []byte(hasherRPKeyInfo+rpID))
+}
+
+// legacyHash is the unkeyed SHA-256 of the identifier and deterministic salt
+func (h *Hasher) legacyHash(value string) string {
+	sum := sha256.Sum256([]byte(value + h.legacySalt))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSum(newHash func() hash.Hash, key, message []byte) []byte {
+	mac := hmac.New(newHash, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// ValidateHashConfig checks the hashing configuration against a crypto profile
+func ValidateHashConfig(cfg *config.Config, profile *CryptoProfile) error {
+	hasher, err := NewHasher(cfg)
+	if err != nil {
+		return err
+	}
+	schemes := []string{hasher.scheme}
+	if len(hasher.lowEntropyFields) > 0 {
+		schemes = append(schemes, HashSchemeArgon2id)
+	}
+	for _, scheme := range schemes {
+		if !profile.Allows(hashSchemeAlgorithms[scheme]) {
+			return fmt.Errorf("crypto profile %s does not allow hash scheme %s", profile.Name, scheme)
+		}
+	}
+	if profile.MinHMACKeyBytes > 0 && cfg.HashKey != "" && len(cfg.HashKey) < profile.MinHMACKeyBytes {
+		return fmt.Errorf("crypto profile %s: HASH_KEY must be at least %d bytes", profile.Name, profile.MinHMACKeyBytes)
+	}
+	return nil
+}
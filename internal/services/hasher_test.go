@@ -0,0 +1,139 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func newTestHasher(t *testing.T, cfg *config.Config) *Hasher {
+	t.Helper()
+	hasher, err := NewHasher(cfg)
+	if err != nil {
+		t.Fatalf("NewHasher failed: %v", err)
+	}
+	return hasher
+}
+
+func TestHasher_LegacyScheme(t *testing.T) {
+	hasher := newTestHasher(t, &config.Config{})
+	hashed, err := hasher.Hash("rp_1", "email", "user@example.com")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+
+	// The default scheme matches HashIdentifierDeterministic, unprefixed
+	expected, _ := NewHashService(&config.Config{}).HashIdentifierDeterministic("user@example.com")
+	if hashed != expected.HashedValue {
+		t.Errorf("Expected the legacy hash %s, got %s", expected.HashedValue, hashed)
+	}
+	if other, _ := hasher.Hash("rp_2", "email", "user@example.com"); other != hashed {
+		t.Error("Expected legacy hashes not to depend on the RP")
+	}
+}
+
+func TestHasher_KeyedSchemes(t *testing.T) {
+	for _, scheme := range []string{HashSchemeHMACSHA256, HashSchemeHMACSHA3, HashSchemeArgon2id} {
+		t.Run(scheme, func(t *testing.T) {
+			hasher := newTestHasher(t, &config.Config{HashScheme: scheme, HashKey: "0123456789abcdef0123", HashKeyVersion: "2", HashArgon2MemoryKB: 1024, HashArgon2Time: 1})
+
+			hashed, err := hasher.Hash("rp_1", "email", "user@example.com")
+			if err != nil {
+				t.Fatalf("Hash failed: %v", err)
+			}
+			if !strings.HasPrefix(hashed, scheme+":v2:") {
+				t.Errorf("Expected a %s:v2: prefix, got %s", scheme, hashed)
+			}
+			if again, _ := hasher.Hash("rp_1", "email", "user@example.com"); again != hashed {
+				t.Error("Expected keyed hashes to be deterministic")
+			}
+			if other, _ := hasher.Hash("rp_2", "email", "user@example.com"); other == hashed {
+				t.Error("Expected hashes to differ between RPs")
+			}
+			if other, _ := hasher.Hash("rp_1", "username", "user@example.com"); other == hashed {
+				t.Error("Expected hashes to differ between fields")
+			}
+			if matches, err := hasher.Matches("rp_1", "email", "user@example.com", hashed); err != nil || !matches {
+				t.Errorf("Expected the hash to match, got %v, %v", matches, err)
+			}
+			if matches, _ := hasher.Matches("rp_1", "email", "other@example.com", hashed); matches {
+				t.Error("Expected another value not to match")
+			}
+		})
+	}
+}
+
+func TestHasher_Rotation(t *testing.T) {
+	old := newTestHasher(t, &config.Config{HashScheme: HashSchemeHMACSHA256, HashKey: "old-key-0123456789", HashKeyVersion: "1"})
+	oldHash, _ := old.Hash("rp_1", "email", "user@example.com")
+	legacyHash, _ := newTestHasher(t, &config.Config{}).Hash("rp_1", "email", "user@example.com")
+
+	rotated := newTestHasher(t, &config.Config{
+		HashScheme:       HashSchemeHMACSHA3,
+		HashKey:          "new-key-0123456789",
+		HashKeyVersion:   "2",
+		HashPreviousKeys: []string{"1=old-key-0123456789"},
+	})
+	for _, hashed := range []string{oldHash, legacyHash} {
+		if matches, err := rotated.Matches("rp_1", "email", "user@example.com", hashed); err != nil || !matches {
+			t.Errorf("Expected %s to match after rotation, got %v, %v", hashed, matches, err)
+		}
+		if !rotated.NeedsRehash("email", hashed) {
+			t.Errorf("Expected %s to need rehashing", hashed)
+		}
+	}
+	current, _ := rotated.Hash("rp_1", "email", "user@example.com")
+	if rotated.NeedsRehash("email", current) {
+		t.Error("Expected a current hash not to need rehashing")
+	}
+	if _, err := rotated.Matches("rp_1", "email", "user@example.com", "hmac-sha256:v9:abcd"); err == nil {
+		t.Error("Expected an unknown key version to fail")
+	}
+}
+
+func TestHasher_LowEntropyFields(t *testing.T) {
+	hasher := newTestHasher(t, &config.Config{HashScheme: HashSchemeHMACSHA256, HashKey: "0123456789abcdef", HashLowEntropyFields: []string{"date_of_birth"}, HashArgon2MemoryKB: 1024, HashArgon2Time: 1})
+	if hashed, _ := hasher.Hash("rp_1", "date_of_birth", "1990-01-01"); !strings.HasPrefix(hashed, HashSchemeArgon2id+":v1:") {
+		t.Errorf("Expected Argon2id for a low-entropy field, got %s", hashed)
+	}
+	if hashed, _ := hasher.Hash("rp_1", "email", "user@example.com"); !strings.HasPrefix(hashed, HashSchemeHMACSHA256+":v1:") {
+		t.Errorf("Expected HMAC-SHA256 for other fields, got %s", hashed)
+	}
+}
+
+func TestNewHasher_InvalidConfig(t *testing.T) {
+	for name, cfg := range map[string]*config.Config{
+		"unknown scheme":    {HashScheme: "md5", HashKey: "0123456789abcdef"},
+		"missing key":       {HashScheme: HashSchemeHMACSHA256},
+		"short key":         {HashScheme: HashSchemeHMACSHA256, HashKey: "short"},
+		"bad previous keys": {HashScheme: HashSchemeHMACSHA256, HashKey: "0123456789abcdef", HashPreviousKeys: []string{"nokey"}},
+	} {
+		if _, err := NewHasher(cfg); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+
+	fips, _ := LookupCryptoProfile(CryptoProfileFIPS)
+	if err := ValidateHashConfig(&config.Config{HashScheme: HashSchemeArgon2id, HashKey: "0123456789abcdef"}, fips); err == nil {
+		t.Error("Expected Argon2id to be rejected by the FIPS profile")
+	}
+	if err := ValidateHashConfig(&config.Config{HashScheme: HashSchemeHMACSHA3, HashKey: "0123456789abcdef"}, fips); err != nil {
+		t.Errorf("Expected HMAC-SHA3-256 to be allowed by the FIPS profile, got %v", err)
+	}
+}
+
+func TestHashService_HashIdentifierFor(t *testing.T) {
+	cfg := &config.Config{HashScheme: HashSchemeHMACSHA256, HashKey: "0123456789abcdef"}
+	service := NewHashService(cfg)
+	result, err := service.HashIdentifierFor("rp_1", "email", "user@example.com")
+	if err != nil {
+		t.Fatalf("HashIdentifierFor failed: %v", err)
+	}
+	if result.HashType != HashSchemeHMACSHA256 || result.Metadata["key_version"] != "1" {
+		t.Errorf("Unexpected hash result: %+v", result)
+	}
+	if err := service.ValidateHash(result); err != nil {
+		t.Errorf("Expected a prefixed hash to validate, got %v", err)
+	}
+}
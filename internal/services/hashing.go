@@ -14,6 +14,7 @@ import (
 // HashService handles identifier hashing with enhanced privacy features
 type HashService struct {
 	config *config.Config
+	hasher *Hasher
 }
 
 // HashResult represents the result of a hashing operation
@@ -28,8 +29,16 @@ type HashResult struct {
 
 // NewHashService creates a new hash service
 func NewHashService(cfg *config.Config) *HashService {
+	hasher, err := NewHasher(cfg)
+	if err != nil {
+		// Startup validation rejects this configuration; keep hashing with
+		// the original scheme rather than failing every request
+		fmt.Printf("HASH WARNING: %v, using the sha256 scheme\n", err)
+		hasher, _ = NewHasher(&config.Config{HashSalt: cfg.HashSalt})
+	}
 	return &HashService{
 		config: cfg,
+		hasher: hasher,
 	}
 }
 
@@ -95,6 +104,46 @@ func (s *HashService) HashIdentifierDeterministic(identifier string) (*HashResul
 	return result, nil
 }
 
+// HashIdentifierFor hashes an identifier field for an RP with the configured
+// identifier hash scheme. Under the default sha256 scheme this is the same
+// hash as HashIdentifierDeterministic.
+func (s *HashService) HashIdentifierFor(rpID, field, identifier string) (*HashResult, error) {
+	if identifier == "" {
+		return nil, fmt.Errorf("identifier cannot be empty")
+	}
+	if s.hasher.SchemeFor(field) == HashSchemeSHA256 {
+		return s.HashIdentifierDeterministic(identifier)
+	}
+
+	hashedValue, err := s.hasher.Hash(rpID, field, identifier)
+	if err != nil {
+		return nil, err
+	}
+	scheme, version, _ := ParseHashPrefix(hashedValue)
+
+	result := &HashResult{
+		OriginalValue: identifier,
+		HashedValue:   hashedValue,
+		HashType:      scheme,
+		Timestamp:     time.Now().Format(time.RFC3339),
+		Metadata: map[string]string{
+			"algorithm":     hashSchemeAlgorithms[scheme],
+			"key_version":   version,
+			"deterministic": "true",
+		},
+	}
+
+	// Log hash operation for audit
+	s.logHashOperation(result)
+
+	return result, nil
+}
+
+// Hasher returns the identifier hasher
+func (s *HashService) Hasher() *Hasher {
+	return s.hasher
+}
+
 // ValidateHash validates a hash result
 func (s *HashService) ValidateHash(result *HashResult) error {
 	if result == nil {
@@ -113,13 +162,15 @@ func (s *HashService) ValidateHash(result *HashResult) error {
 		return fmt.Errorf("timestamp cannot be empty")
 	}
 
-	// Validate hash format (should be 64 characters for SHA-256)
-	if len(result.HashedValue) != 64 {
-		return fmt.Errorf("invalid hash length: expected 64, got %d", len(result.HashedValue))
+	// Validate hash format (should be 64 characters for SHA-256; keyed
+	// schemes prefix the digest with their scheme and key version)
+	_, _, digest := ParseHashPrefix(result.HashedValue)
+	if len(digest) != 64 {
+		return fmt.Errorf("invalid hash length: expected 64, got %d", len(digest))
 	}
 
 	// Validate hex format
-	if _, err := hex.DecodeString(result.HashedValue); err != nil {
+	if _, err := hex.DecodeString(digest); err != nil {
 		return fmt.Errorf("invalid hash format: not a valid hex string")
 	}
 
@@ -153,8 +204,11 @@ func (s *HashService) generateSalt() (string, error) {
 
 // getDeterministicSalt returns a fixed salt for deterministic hashing
 func (s *HashService) getDeterministicSalt() string {
-	// Use a fixed salt for deterministic hashing
-	// In production, this should be configurable and stored securely
+	// HASH_SALT may be supplied as an enc:v1 value so it is never stored in
+	// plaintext; the built-in salt is kept for existing deployments
+	if s.config != nil && s.config.HashSalt != "" {
+		return s.config.HashSalt
+	}
 	return "pavilion_deterministic_salt_v1"
 }
 
@@ -183,6 +237,7 @@ func (s *HashService) GetHashStats() map[string]interface{} {
 	return map[string]interface{}{
 		"service_status": "active",
 		"hash_algorithm": "SHA-256",
+		"identifier_scheme": s.hasher.Scheme(),
 		"salt_length":    32, // 256 bits
 		"deterministic_salt_enabled": true,
 	}
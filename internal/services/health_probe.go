@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Dependency probe statuses
+const (
+	ProbeUp   = "up"
+	ProbeDown = "down"
+)
+
+// HealthProbe checks one dependency. Liveness probes check the process
+// itself, so their failure means it should be restarted; the others check
+// services it relies on and only take it out of rotation.
+type HealthProbe struct {
+	Name     string
+	Liveness bool
+	Check    func(ctx context.Context) error
+}
+
+// ProbeResult is the outcome of one dependency check
+type ProbeResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Latency  string `json:"latency"`
+	Liveness bool   `json:"liveness,omitempty"`
+}
+
+// ProbeReport is the outcome of every dependency check
+type ProbeReport struct {
+	CheckedAt    time.Time              `json:"checked_at"`
+	Dependencies map[string]ProbeResult `json:"dependencies"`
+}
+
+// Live reports whether every liveness check passed
+func (r *ProbeReport) Live() bool {
+	for _, result := range r.Dependencies {
+		if result.Liveness && result.Status != ProbeUp {
+			return false
+		}
+	}
+	return true
+}
+
+// Ready reports whether every check passed
+func (r *ProbeReport) Ready() bool {
+	for _, result := range r.Dependencies {
+		if result.Status != ProbeUp {
+			return false
+		}
+	}
+	return true
+}
+
+// HealthProber runs dependency checks for Kubernetes probes. Checks run
+// concurrently, each bounded by the timeout, and a report is reused for the
+// cache TTL so frequent probes from several kubelets do not load the
+// dependencies.
+type HealthProber struct {
+	probes   []HealthProbe
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu   sync.Mutex
+	last *ProbeReport
+}
+
+// NewHealthProber creates a prober over the given checks
+func NewHealthProber(probes []HealthProbe, timeout, cacheTTL time.Duration) *HealthProber {
+	return &HealthProber{probes: probes, timeout: timeout, cacheTTL: cacheTTL}
+}
+
+// Check returns the dependency report, running the checks unless a report
+// younger than the cache TTL exists
+func (p *HealthProber) Check(ctx context.Context) *ProbeReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.last != nil && time.Since(p.last.CheckedAt) < p.cacheTTL {
+		return p.last
+	}
+
+	// The report is shared, so one caller hanging up must not fail the checks
+	ctx = context.WithoutCancel(ctx)
+	report := &ProbeReport{CheckedAt: time.Now(), Dependencies: make(map[string]ProbeResult, len(p.probes))}
+	results := make([]ProbeResult, len(p.probes))
+	var wg sync.WaitGroup
+	for i, probe := range p.probes {
+		wg.Add(1)
+		go func(i int, probe HealthProbe) {
+			defer wg.Done()
+			results[i] = p.run(ctx, probe)
+		}(i, probe)
+	}
+	wg.Wait()
+	for i, probe := range p.probes {
+		report.Dependencies[probe.Name] = results[i]
+	}
+
+	p.last = report
+	return report
+}
+
+// run runs one check, recovering from panics so a broken check reports as
+// down rather than failing the probe
+func (p *HealthProber) run(ctx context.Context, probe HealthProbe) (result ProbeResult) {
+	start := time.Now()
+	result = ProbeResult{Status: ProbeUp, Liveness: probe.Liveness}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result.Status, result.Error = ProbeDown, fmt.Sprintf("panic: %v", recovered)
+		}
+		result.Latency = time.Since(start).String()
+	}()
+
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	if err := probe.Check(ctx); err != nil {
+		result.Status, result.Error = ProbeDown, err.Error()
+	}
+	return result
+}
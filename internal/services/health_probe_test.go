@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthProber_Check(t *testing.T) {
+	var calls int64
+	prober := NewHealthProber([]HealthProbe{
+		{Name: "audit", Liveness: true, Check: func(context.Context) error {
+			atomic.AddInt64(&calls, 1)
+			return nil
+		}},
+		{Name: "cache", Check: func(context.Context) error { return errors.New("redis connection failed") }},
+		{Name: "policy", Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		{Name: "dp_connector", Check: func(context.Context) error { panic("nil client") }},
+	}, 20*time.Millisecond, time.Minute)
+
+	// A cancelled caller does not fail the shared checks
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := prober.Check(ctx)
+	if !report.Live() || report.Ready() {
+		t.Errorf("Expected a live but unready report, got %+v", report.Dependencies)
+	}
+	if audit := report.Dependencies["audit"]; audit.Status != ProbeUp || !audit.Liveness {
+		t.Errorf("Expected the audit check to pass, got %+v", audit)
+	}
+	if policy := report.Dependencies["policy"]; policy.Status != ProbeDown || policy.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("Expected the hanging check to time out, got %+v", policy)
+	}
+	if dp := report.Dependencies["dp_connector"]; dp.Status != ProbeDown || dp.Error != "panic: nil client" {
+		t.Errorf("Expected the panicking check to report down, got %+v", dp)
+	}
+
+	// Reports are reused within the cache TTL
+	if again := prober.Check(context.Background()); again != report || atomic.LoadInt64(&calls) != 1 {
+		t.Errorf("Expected the cached report, got %d checks", calls)
+	}
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// Identifier issue codes returned to RPs
+const (
+	IdentifierIssueLowEntropy      = "low_entropy"
+	IdentifierIssueInvalidFormat   = "invalid_format"
+	IdentifierIssueDisposableEmail = "disposable_email"
+)
+
+var identifierEmailPattern = regexp.MustCompile(`^[^@\s]+@([A-Za-z0-9-]+\.)+[A-Za-z]{2,}$`)
+
+// IdentifierIssue describes why an identifier was rejected
+type IdentifierIssue struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// IdentifierValidationError lists every identifier that failed the quality
+// checks, so RPs can fix them all at once
+type IdentifierValidationError struct {
+	Issues []IdentifierIssue
+}
+
+func (e *IdentifierValidationError) Error() string {
+	fields := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		fields = append(fields, issue.Field+": "+issue.Code)
+	}
+	return "identifier validation failed: " + strings.Join(fields, ", ")
+}
+
+// IdentifierPolicy sets the quality checks applied to a tenant's identifiers.
+// A nil MinEntropyBits keeps the broker default; zero disables the check.
+type IdentifierPolicy struct {
+	MinEntropyBits         *float64 `json:"min_entropy_bits,omitempty"`
+	DisposableEmailDomains []string `json:"disposable_email_domains,omitempty"`
+}
+
+// IdentifierPolicyFile is the on-disk format of per-tenant identifier policies
+type IdentifierPolicyFile struct {
+	Tenants map[string]IdentifierPolicy `json:"tenants"`
+}
+
+// IdentifierService screens identifiers before they are hashed, so that junk
+// values such as all-zero phone numbers never become DP queries
+type IdentifierService struct {
+	mu                sync.RWMutex
+	minEntropyBits    float64
+	disposableDomains map[string]bool
+	tenants           map[string]IdentifierPolicy
+}
+
+// NewIdentifierService creates an identifier service from the broker
+// configuration, loading per-tenant policies when a policy file is configured
+func NewIdentifierService(cfg *config.Config) *IdentifierService {
+	service := &IdentifierService{
+		minEntropyBits:    cfg.IdentifierMinEntropyBits,
+		disposableDomains: make(map[string]bool),
+		tenants:           make(map[string]IdentifierPolicy),
+	}
+	for _, domain := range cfg.IdentifierDisposableDomains {
+		service.disposableDomains[strings.ToLower(strings.TrimSpace(domain))] = true
+	}
+
+	if cfg.IdentifierPolicyFile != "" {
+		if err := service.loadPolicyFile(cfg.IdentifierPolicyFile); err != nil {
+			fmt.Printf("IDENTIFIER WARNING: %v; using default identifier checks for all tenants\n", err)
+		}
+	}
+
+	return service
+}
+
+// loadPolicyFile reads per-tenant policies from a JSON file
+func (s *IdentifierService) loadPolicyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read identifier policy file: %w", err)
+	}
+
+	var file IdentifierPolicyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse identifier policy file: %w", err)
+	}
+
+	for rpID, policy := range file.Tenants {
+		s.SetTenantPolicy(rpID, policy)
+	}
+	return nil
+}
+
+// SetTenantPolicy sets the identifier policy for a tenant
+func (s *IdentifierService) SetTenantPolicy(rpID string, policy IdentifierPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenants[rpID] = policy
+}
+
+// ValidateIdentifiers checks every identifier of a request for a tenant and
+// returns an *IdentifierValidationError listing all failures
+func (s *IdentifierService) ValidateIdentifiers(rpID string, identifiers map[string]string) error {
+	s.mu.RLock()
+	policy, hasPolicy := s.tenants[rpID]
+	s.mu.RUnlock()
+
+	minEntropy := s.minEntropyBits
+	if hasPolicy && policy.MinEntropyBits != nil {
+		minEntropy = *policy.MinEntropyBits
+	}
+
+	keys := make([]string, 0, len(identifiers))
+	for key := range identifiers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	validationErr := &IdentifierValidationError{}
+	for _, key := range keys {
+		if issue := s.checkIdentifier(key, identifiers[key], minEntropy, policy); issue != nil {
+			validationErr.Issues = append(validationErr.Issues, *issue)
+		}
+	}
+
+	if len(validationErr.Issues) > 0 {
+		return validationErr
+	}
+	return nil
+}
+
+// checkIdentifier applies the format checks for a key and then the entropy
+// check, returning the first problem found
+func (s *IdentifierService) checkIdentifier(key, value string, minEntropy float64, policy IdentifierPolicy) *IdentifierIssue {
+	field := "identifiers." + key
+	value = strings.TrimSpace(value)
+
+	switch key {
+	case "email":
+		if !identifierEmailPattern.MatchString(value) {
+			return &IdentifierIssue{Field: field, Code: IdentifierIssueInvalidFormat, Message: "not a valid email address"}
+		}
+		domain := strings.ToLower(value[strings.LastIndex(value, "@")+1:])
+		if s.isDisposableDomain(domain, policy.DisposableEmailDomains) {
+			return &IdentifierIssue{Field: field, Code: IdentifierIssueDisposableEmail, Message: fmt.Sprintf("email domain %s is not accepted", domain)}
+		}
+	case "phone":
+		digits := identifierDigits(value)
+		if len(digits) < 7 || len(digits) > 15 {
+			return &IdentifierIssue{Field: field, Code: IdentifierIssueInvalidFormat, Message: "phone number must have 7 to 15 digits"}
+		}
+		if isRepeatedOrSequential(digits) {
+			return &IdentifierIssue{Field: field, Code: IdentifierIssueLowEntropy, Message: "phone number is a placeholder"}
+		}
+	case "ssn":
+		digits := identifierDigits(value)
+		if len(digits) != 9 || digits[:3] == "000" || digits[:3] == "666" || digits[0] == '9' || digits[3:5] == "00" || digits[5:] == "0000" {
+			return &IdentifierIssue{Field: field, Code: IdentifierIssueInvalidFormat, Message: "not a valid SSN"}
+		}
+		if isRepeatedOrSequential(digits) {
+			return &IdentifierIssue{Field: field, Code: IdentifierIssueLowEntropy, Message: "SSN is a placeholder"}
+		}
+	}
+
+	if minEntropy > 0 && EstimateEntropyBits(value) < minEntropy {
+		return &IdentifierIssue{Field: field, Code: IdentifierIssueLowEntropy, Message: fmt.Sprintf("value is too predictable to identify a person (minimum %.0f bits)", minEntropy)}
+	}
+	return nil
+}
+
+// isDisposableDomain matches a domain or any of its parents against the
+// broker and tenant disposable domain lists
+func (s *IdentifierService) isDisposableDomain(domain string, tenantDomains []string) bool {
+	for candidate := domain; candidate != ""; {
+		if s.disposableDomains[candidate] {
+			return true
+		}
+		for _, tenantDomain := range tenantDomains {
+			if strings.EqualFold(candidate, tenantDomain) {
+				return true
+			}
+		}
+		dot := strings.Index(candidate, ".")
+		if dot < 0 {
+			break
+		}
+		candidate = candidate[dot+1:]
+	}
+	return false
+}
+
+// EstimateEntropyBits estimates the information in a value as its length
+// times the Shannon entropy of its case-folded characters. It is a cheap
+// screen for placeholders such as "0000000000" or "aaaa", not a measure of
+// how identifying a value is.
+func EstimateEntropyBits(value string) float64 {
+	runes := []rune(strings.ToLower(value))
+	if len(runes) == 0 {
+		return 0
+	}
+
+	counts := make(map[rune]int)
+	for _, r := range runes {
+		counts[r]++
+	}
+
+	perChar := 0.0
+	total := float64(len(runes))
+	for _, count := range counts {
+		p := float64(count) / total
+		perChar -= p * math.Log2(p)
+	}
+	return perChar * total
+}
+
+// identifierDigits strips everything but digits from a value
+func identifierDigits(value string) string {
+	var digits strings.Builder
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	return digits.String()
+}
+
+// isRepeatedOrSequential reports digit strings such as 5555555 or 1234567
+// that have high character entropy but carry no information
+func isRepeatedOrSequential(digits string) bool {
+	if len(digits) < 2 {
+		return true
+	}
+	repeated, ascending, descending := true, true, true
+	for i := 1; i < len(digits); i++ {
+		step := int(digits[i]) - int(digits[i-1])
+		repeated = repeated && step == 0
+		ascending = ascending && (step == 1 || step == -9)
+		descending = descending && (step == -1 || step == 9)
+	}
+	return repeated || ascending || descending
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func identifierIssueCodes(t *testing.T, err error) map[string]string {
+	t.Helper()
+	codes := make(map[string]string)
+	if err == nil {
+		return codes
+	}
+	validationErr, ok := err.(*IdentifierValidationError)
+	if !ok {
+		t.Fatalf("Expected *IdentifierValidationError, got %T", err)
+	}
+	for _, issue := range validationErr.Issues {
+		codes[issue.Field] = issue.Code
+	}
+	return codes
+}
+
+func TestIdentifierService_ValidateIdentifiers(t *testing.T) {
+	service := NewIdentifierService(&config.Config{
+		IdentifierMinEntropyBits:    8,
+		IdentifierDisposableDomains: []string{"mailinator.com"},
+	})
+
+	tests := []struct {
+		name        string
+		identifiers map[string]string
+		field       string
+		code        string
+	}{
+		{"valid", map[string]string{"email": "jane.doe@example.edu", "name": "Jane Doe", "phone": "+1 (415) 555-0142"}, "", ""},
+		{"all-zero phone", map[string]string{"phone": "000-000-0000"}, "identifiers.phone", IdentifierIssueLowEntropy},
+		{"sequential phone", map[string]string{"phone": "1234567890"}, "identifiers.phone", IdentifierIssueLowEntropy},
+		{"short phone", map[string]string{"phone": "12345"}, "identifiers.phone", IdentifierIssueInvalidFormat},
+		{"malformed email", map[string]string{"email": "jane.doe"}, "identifiers.email", IdentifierIssueInvalidFormat},
+		{"disposable email", map[string]string{"email": "jane@mailinator.com"}, "identifiers.email", IdentifierIssueDisposableEmail},
+		{"disposable subdomain", map[string]string{"email": "jane@eu.mailinator.com"}, "identifiers.email", IdentifierIssueDisposableEmail},
+		{"invalid ssn", map[string]string{"ssn": "000-12-3456"}, "identifiers.ssn", IdentifierIssueInvalidFormat},
+		{"placeholder name", map[string]string{"name": "aaaaaaaa"}, "identifiers.name", IdentifierIssueLowEntropy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes := identifierIssueCodes(t, service.ValidateIdentifiers("rp_1", tt.identifiers))
+			if tt.field == "" {
+				if len(codes) != 0 {
+					t.Errorf("Expected no issues, got %v", codes)
+				}
+				return
+			}
+			if codes[tt.field] != tt.code {
+				t.Errorf("Expected %s on %s, got %v", tt.code, tt.field, codes)
+			}
+		})
+	}
+}
+
+func TestIdentifierService_TenantPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identifiers.json")
+	policy := `{"tenants": {"strict_rp": {"min_entropy_bits": 40, "disposable_email_domains": ["example.org"]}, "lenient_rp": {"min_entropy_bits": 0}}}`
+	if err := os.WriteFile(path, []byte(policy), 0600); err != nil {
+		t.Fatalf("Failed to write policy file: %v", err)
+	}
+
+	service := NewIdentifierService(&config.Config{IdentifierMinEntropyBits: 8, IdentifierPolicyFile: path})
+
+	identifiers := map[string]string{"email": "jo@example.org", "name": "Al"}
+	if codes := identifierIssueCodes(t, service.ValidateIdentifiers("strict_rp", identifiers)); codes["identifiers.email"] != IdentifierIssueDisposableEmail || codes["identifiers.name"] != IdentifierIssueLowEntropy {
+		t.Errorf("Expected tenant policy to apply, got %v", codes)
+	}
+	if codes := identifierIssueCodes(t, service.ValidateIdentifiers("lenient_rp", identifiers)); len(codes) != 0 {
+		t.Errorf("Expected entropy check to be disabled for tenant, got %v", codes)
+	}
+	if codes := identifierIssueCodes(t, service.ValidateIdentifiers("other_rp", identifiers)); codes["identifiers.name"] != IdentifierIssueLowEntropy || codes["identifiers.email"] != "" {
+		t.Errorf("Expected default policy for other tenants, got %v", codes)
+	}
+}
+
+func TestEstimateEntropyBits(t *testing.T) {
+	if bits := EstimateEntropyBits("0000000000"); bits != 0 {
+		t.Errorf("Expected no entropy for repeated characters, got %v", bits)
+	}
+	if EstimateEntropyBits("Jane Doe") <= EstimateEntropyBits("aaab") {
+		t.Error("Expected varied values to have more entropy")
+	}
+}
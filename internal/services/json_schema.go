@@ -0,0 +1,904 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSchemaDepth bounds nested $refs applied to one value, and the nesting
+// walked when indexing and checking schemas built with cyclic pointers
+const maxSchemaDepth = 64
+
+// jsonSchemaTypes are the types a schema's "type" may name
+var jsonSchemaTypes = map[string]bool{
+	"string": true, "number": true, "integer": true, "boolean": true,
+	"object": true, "array": true, "null": true,
+}
+
+// unsupportedSchemaKeywords are JSON Schema keywords the validator does not
+// implement. Schemas using them are rejected, since ignoring them would
+// accept values the author meant to reject.
+var unsupportedSchemaKeywords = []string{
+	"unevaluatedProperties", "unevaluatedItems", "$dynamicRef", "$dynamicAnchor",
+	"$recursiveRef", "$recursiveAnchor", "dependencies",
+}
+
+// BoolSchema returns the schema accepting every value (true) or none (false)
+func BoolSchema(allow bool) *ValidationSchema {
+	return &ValidationSchema{Bool: &allow}
+}
+
+// UnmarshalJSON reads a JSON Schema, accepting boolean schemas and "type"
+// arrays. A field-level "required": true of the original subset is left to
+// SchemaField.
+func (s *ValidationSchema) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch string(data) {
+	case "true", "false":
+		allow := string(data) == "true"
+		*s = ValidationSchema{Bool: &allow}
+		return nil
+	}
+
+	type plainSchema ValidationSchema
+	var raw struct {
+		*plainSchema
+		Type     json.RawMessage `json:"type,omitempty"`
+		Required json.RawMessage `json:"required,omitempty"`
+	}
+	*s = ValidationSchema{}
+	raw.plainSchema = (*plainSchema)(s)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if len(raw.Type) > 0 {
+		if err := json.Unmarshal(raw.Type, &s.Type); err != nil {
+			if err := json.Unmarshal(raw.Type, &s.Types); err != nil {
+				return fmt.Errorf("schema type must be a string or an array of strings")
+			}
+		}
+	}
+	if len(raw.Required) > 0 && raw.Required[0] == '[' {
+		if err := json.Unmarshal(raw.Required, &s.Required); err != nil {
+			return fmt.Errorf("schema required must be an array of strings")
+		}
+	}
+
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return err
+	}
+	for _, keyword := range unsupportedSchemaKeywords {
+		if _, used := keywords[keyword]; used {
+			s.Unsupported = append(s.Unsupported, keyword)
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON reads a property schema, which may be written as standard
+// JSON Schema or in the original subset
+func (f *SchemaField) UnmarshalJSON(data []byte) error {
+	var schema ValidationSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return err
+	}
+	*f = SchemaField{
+		Type:      schema.Type,
+		MinLength: schema.MinLength,
+		MaxLength: schema.MaxLength,
+		Pattern:   schema.Pattern,
+		Enum:      schema.Enum,
+		Format:    schema.Format,
+		Schema:    &schema,
+	}
+	if schema.Bool != nil {
+		return nil
+	}
+
+	var subset struct {
+		Description string          `json:"description"`
+		Required    json.RawMessage `json:"required"`
+		MinValue    *float64        `json:"minValue"`
+		MaxValue    *float64        `json:"maxValue"`
+		CustomRule  *string         `json:"customRule"`
+		Default     interface{}     `json:"default"`
+	}
+	if err := json.Unmarshal(data, &subset); err != nil {
+		return err
+	}
+	f.Description = subset.Description
+	f.Required = string(bytes.TrimSpace(subset.Required)) == "true"
+	f.MinValue = subset.MinValue
+	f.MaxValue = subset.MaxValue
+	f.CustomRule = subset.CustomRule
+	f.Default = subset.Default
+	return nil
+}
+
+// schema returns the JSON Schema a field's value must satisfy: its Schema
+// with the field's own constraints applied over it
+func (f SchemaField) schema() *ValidationSchema {
+	var schema ValidationSchema
+	if f.Schema != nil {
+		schema = *f.Schema
+	}
+	if f.Type != "" {
+		schema.Type = f.Type
+		schema.Types = nil
+	}
+	if f.MinLength != nil {
+		schema.MinLength = f.MinLength
+	}
+	if f.MaxLength != nil {
+		schema.MaxLength = f.MaxLength
+	}
+	if f.Pattern != nil {
+		schema.Pattern = f.Pattern
+	}
+	if f.Enum != nil {
+		schema.Enum = f.Enum
+	}
+	if f.Format != nil {
+		schema.Format = f.Format
+	}
+	if f.MinValue != nil {
+		schema.Minimum = f.MinValue
+	}
+	if f.MaxValue != nil {
+		schema.Maximum = f.MaxValue
+	}
+	return &schema
+}
+
+// types returns the types a schema allows; nil allows any
+func (s *ValidationSchema) types() []string {
+	if s.Types != nil {
+		return s.Types
+	}
+	if s.Type != "" {
+		return []string{s.Type}
+	}
+	return nil
+}
+
+// subschemas returns a schema's direct subschemas keyed by their JSON
+// pointer relative to it
+func (s *ValidationSchema) subschemas() map[string]*ValidationSchema {
+	children := make(map[string]*ValidationSchema)
+	addMap := func(keyword string, schemas map[string]*ValidationSchema) {
+		for name, schema := range schemas {
+			children[keyword+"/"+escapePointerToken(name)] = schema
+		}
+	}
+	addList := func(keyword string, schemas []*ValidationSchema) {
+		for i, schema := range schemas {
+			children[keyword+"/"+strconv.Itoa(i)] = schema
+		}
+	}
+	addOne := func(keyword string, schema *ValidationSchema) {
+		if schema != nil {
+			children[keyword] = schema
+		}
+	}
+
+	addMap("$defs", s.Defs)
+	addMap("definitions", s.Definitions)
+	for name, field := range s.Properties {
+		children["properties/"+escapePointerToken(name)] = field.schema()
+	}
+	addMap("patternProperties", s.PatternProperties)
+	addMap("dependentSchemas", s.DependentSchemas)
+	addList("prefixItems", s.PrefixItems)
+	addList("allOf", s.AllOf)
+	addList("anyOf", s.AnyOf)
+	addList("oneOf", s.OneOf)
+	addOne("additionalProperties", s.AdditionalProperties)
+	addOne("propertyNames", s.PropertyNames)
+	addOne("items", s.Items)
+	addOne("contains", s.Contains)
+	addOne("not", s.Not)
+	addOne("if", s.If)
+	addOne("then", s.Then)
+	addOne("else", s.Else)
+	return children
+}
+
+// schemaScope resolves $refs within the schema document being validated and
+// caches its compiled patterns. A scope with a compilation is shared by the
+// validations of a CompiledSchema, so its maps are only read.
+type schemaScope struct {
+	root     *ValidationSchema
+	ids      map[string]*ValidationSchema
+	anchors  map[string]*ValidationSchema
+	patterns map[string]*regexp.Regexp
+	compiled *schemaCompilation
+	depth    int
+}
+
+func newSchemaScope(root *ValidationSchema) *schemaScope {
+	scope := &schemaScope{
+		root:     root,
+		ids:      make(map[string]*ValidationSchema),
+		anchors:  make(map[string]*ValidationSchema),
+		patterns: make(map[string]*regexp.Regexp),
+	}
+	scope.index(root, 0)
+	return scope
+}
+
+// index records the $ids and $anchors of a schema and its subschemas
+func (sc *schemaScope) index(schema *ValidationSchema, depth int) {
+	if schema == nil || depth > maxSchemaDepth {
+		return
+	}
+	if schema.ID != "" {
+		sc.ids[strings.TrimSuffix(schema.ID, "#")] = schema
+	}
+	if schema.Anchor != "" {
+		sc.anchors[schema.Anchor] = schema
+	}
+	for _, child := range schema.subschemas() {
+		sc.index(child, depth+1)
+	}
+}
+
+// resolve returns the schema a $ref points to. References name the root
+// ("#"), a JSON pointer ("#/$defs/address"), an $anchor ("#address") or a
+// subschema's $id, optionally followed by a pointer; remote schemas are not
+// fetched.
+func (sc *schemaScope) resolve(ref string) (*ValidationSchema, error) {
+	if sc.compiled != nil {
+		if target, exists := sc.compiled.refs[ref]; exists {
+			return target, nil
+		}
+	}
+	base, fragment, _ := strings.Cut(ref, "#")
+	target := sc.root
+	if base != "" {
+		target = sc.lookupID(base)
+		if target == nil {
+			return nil, fmt.Errorf("$ref %s: no schema with $id %s in the document", ref, base)
+		}
+	}
+	if fragment == "" {
+		return target, nil
+	}
+	if !strings.HasPrefix(fragment, "/") {
+		anchor, exists := sc.anchors[fragment]
+		if !exists {
+			return nil, fmt.Errorf("$ref %s: no schema with $anchor %s", ref, fragment)
+		}
+		return anchor, nil
+	}
+
+	pointer, err := url.PathUnescape(fragment)
+	if err != nil {
+		return nil, fmt.Errorf("$ref %s: %w", ref, err)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(tokens[i], "~1", "/"), "~0", "~")
+	}
+	for i := 0; i < len(tokens); {
+		children := target.subschemas()
+		next, exists := children[escapePointerToken(tokens[i])]
+		step := 1
+		if !exists && i+1 < len(tokens) {
+			next, exists = children[tokens[i]+"/"+escapePointerToken(tokens[i+1])]
+			step = 2
+		}
+		if !exists {
+			return nil, fmt.Errorf("$ref %s: %s does not point to a schema", ref, pointer)
+		}
+		target = next
+		i += step
+	}
+	return target, nil
+}
+
+// lookupID finds the schema with an $id, matching a relative reference
+// against the end of absolute $ids
+func (sc *schemaScope) lookupID(base string) *ValidationSchema {
+	if schema, exists := sc.ids[base]; exists {
+		return schema
+	}
+	ids := make([]string, 0, len(sc.ids))
+	for id := range sc.ids {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	relative := "/" + strings.TrimPrefix(base, "./")
+	for _, id := range ids {
+		if strings.HasSuffix(id, relative) {
+			return sc.ids[id]
+		}
+	}
+	return nil
+}
+
+// pattern compiles a schema pattern once per validation, or once per
+// compiled schema
+func (sc *schemaScope) pattern(expression string) (*regexp.Regexp, error) {
+	if compiled, exists := sc.patterns[expression]; exists {
+		return compiled, nil
+	}
+	compiled, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	if sc.compiled == nil {
+		sc.patterns[expression] = compiled
+	}
+	return compiled, nil
+}
+
+// field returns the schema a property's value must satisfy
+func (sc *schemaScope) field(parent *ValidationSchema, name string, field SchemaField) *ValidationSchema {
+	if sc.compiled != nil {
+		if schema, exists := sc.compiled.fields[schemaFieldKey{parent, name}]; exists {
+			return schema
+		}
+	}
+	return field.schema()
+}
+
+// inEnum reports whether a value is one of a schema's enum values
+func (sc *schemaScope) inEnum(schema *ValidationSchema, data interface{}) bool {
+	if sc.compiled != nil {
+		if values, exists := sc.compiled.enums[schema]; exists {
+			if key, ok := enumKey(data); ok {
+				return values[key]
+			}
+		}
+	}
+	return containsJSONValue(schema.Enum, data)
+}
+
+// constValue returns a schema's decoded const
+func (sc *schemaScope) constValue(schema *ValidationSchema) (interface{}, bool) {
+	if sc.compiled != nil {
+		if value, exists := sc.compiled.consts[schema]; exists {
+			return value, true
+		}
+	}
+	var value interface{}
+	if err := json.Unmarshal(schema.Const, &value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// patternNames returns a schema's patternProperties expressions in order
+func (sc *schemaScope) patternNames(schema *ValidationSchema) []string {
+	if sc.compiled != nil {
+		if names, exists := sc.compiled.patternNames[schema]; exists {
+			return names
+		}
+	}
+	return sortedMapKeys(schema.PatternProperties)
+}
+
+// check reports the first problem with a schema or its subschemas: unknown
+// types, unresolvable $refs, invalid patterns or constants, and unsupported
+// keywords
+func (sc *schemaScope) check(schema *ValidationSchema, location string) error {
+	return sc.checkDepth(schema, location, 0)
+}
+
+func (sc *schemaScope) checkDepth(schema *ValidationSchema, location string, depth int) error {
+	if schema == nil || schema.Bool != nil || depth > maxSchemaDepth {
+		return nil
+	}
+	if len(schema.Unsupported) > 0 {
+		return fmt.Errorf("%s: keyword %s is not supported", location, schema.Unsupported[0])
+	}
+	for _, schemaType := range schema.types() {
+		if !jsonSchemaTypes[schemaType] {
+			return fmt.Errorf("%s: unknown type %q", location, schemaType)
+		}
+	}
+	if schema.Ref != "" {
+		if _, err := sc.resolve(schema.Ref); err != nil {
+			return fmt.Errorf("%s: %w", location, err)
+		}
+	}
+	if schema.Pattern != nil {
+		if _, err := sc.pattern(*schema.Pattern); err != nil {
+			return fmt.Errorf("%s: invalid pattern: %w", location, err)
+		}
+	}
+	for expression := range schema.PatternProperties {
+		if _, err := sc.pattern(expression); err != nil {
+			return fmt.Errorf("%s: invalid patternProperties pattern: %w", location, err)
+		}
+	}
+	if schema.Const != nil && !json.Valid(schema.Const) {
+		return fmt.Errorf("%s: const is not valid JSON", location)
+	}
+	if schema.MultipleOf != nil && *schema.MultipleOf <= 0 {
+		return fmt.Errorf("%s: multipleOf must be greater than 0", location)
+	}
+
+	// A required field no property may have can never be satisfied
+	if closed := schema.AdditionalProperties; closed != nil && closed.Bool != nil && !*closed.Bool {
+		for _, required := range schema.Required {
+			if _, exists := schema.Properties[required]; !exists && !sc.matchesPatternProperty(schema, required) {
+				return fmt.Errorf("required field '%s' not found in properties", required)
+			}
+		}
+	}
+
+	children := schema.subschemas()
+	pointers := make([]string, 0, len(children))
+	for pointer := range children {
+		pointers = append(pointers, pointer)
+	}
+	sort.Strings(pointers)
+	for _, pointer := range pointers {
+		if err := sc.checkDepth(children[pointer], location+"/"+pointer, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchesPatternProperty reports whether a property name is covered by one
+// of a schema's patternProperties
+func (sc *schemaScope) matchesPatternProperty(schema *ValidationSchema, name string) bool {
+	for expression := range schema.PatternProperties {
+		if compiled, err := sc.pattern(expression); err == nil && compiled.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate validates a value against a schema, recording errors in response
+func (dv *DataValidator) evaluate(data interface{}, schema *ValidationSchema, path string, response *ValidationResponse, options ValidationOptions, scope *schemaScope) {
+	if schema == nil {
+		return
+	}
+	if schema.Bool != nil {
+		if !*schema.Bool {
+			dv.addError(response, path, "FALSE_SCHEMA", "no value is allowed here", data)
+		}
+		return
+	}
+
+	// Keywords next to a $ref apply as well
+	if schema.Ref != "" {
+		target, err := scope.resolve(schema.Ref)
+		switch {
+		case err != nil:
+			dv.addError(response, path, "INVALID_REF", err.Error(), nil)
+		case scope.depth >= maxSchemaDepth:
+			dv.addError(response, path, "INVALID_REF", fmt.Sprintf("$ref %s nests more than %d levels", schema.Ref, maxSchemaDepth), nil)
+		default:
+			scope.depth++
+			dv.evaluate(data, target, path, response, options, scope)
+			scope.depth--
+		}
+	}
+
+	if types := schema.types(); types != nil && !matchesAnyType(data, types) {
+		dv.addError(response, path, "TYPE_MISMATCH", fmt.Sprintf("expected %s, got %s", strings.Join(types, " or "), jsonTypeOf(data)), data)
+		return
+	}
+
+	if schema.Enum != nil && !scope.inEnum(schema, data) {
+		dv.addError(response, path, "ENUM_VIOLATION", "value not in allowed enum values", data)
+	}
+	if schema.Const != nil {
+		if expected, ok := scope.constValue(schema); ok && !jsonEqual(expected, data) {
+			dv.addError(response, path, "CONST_VIOLATION", fmt.Sprintf("value must be %s", schema.Const), data)
+		}
+	}
+
+	if value, ok := data.(string); ok {
+		dv.evaluateString(value, schema, path, response, scope)
+	}
+	if number, ok := numericValue(data); ok {
+		dv.evaluateNumber(number, data, schema, path, response)
+	}
+	if object, ok := objectValue(data); ok {
+		dv.evaluateObject(object, schema, path, response, options, scope)
+	}
+	if items, ok := arrayValue(data); ok {
+		dv.evaluateArray(items, schema, path, response, options, scope)
+	}
+
+	for _, subschema := range schema.AllOf {
+		dv.evaluate(data, subschema, path, response, options, scope)
+	}
+	if len(schema.AnyOf) > 0 {
+		matched := false
+		for _, subschema := range schema.AnyOf {
+			if dv.matches(data, subschema, path, options, scope) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			dv.addError(response, path, "ANY_OF_VIOLATION", "value does not match any schema in anyOf", data)
+		}
+	}
+	if len(schema.OneOf) > 0 {
+		matched := 0
+		for _, subschema := range schema.OneOf {
+			if dv.matches(data, subschema, path, options, scope) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			dv.addError(response, path, "ONE_OF_VIOLATION", fmt.Sprintf("value matches %d schemas in oneOf, expected exactly one", matched), data)
+		}
+	}
+	if schema.Not != nil && dv.matches(data, schema.Not, path, options, scope) {
+		dv.addError(response, path, "NOT_VIOLATION", "value must not match the schema in not", data)
+	}
+	if schema.If != nil {
+		if dv.matches(data, schema.If, path, options, scope) {
+			dv.evaluate(data, schema.Then, path, response, options, scope)
+		} else {
+			dv.evaluate(data, schema.Else, path, response, options, scope)
+		}
+	}
+}
+
+// matches reports whether a value satisfies a schema without recording
+// errors
+func (dv *DataValidator) matches(data interface{}, schema *ValidationSchema, path string, options ValidationOptions, scope *schemaScope) bool {
+	var scratch ValidationResponse
+	dv.evaluate(data, schema, path, &scratch, options, scope)
+	return len(scratch.Errors) == 0
+}
+
+// evaluateString applies the string keywords. Lengths count characters,
+// not bytes.
+func (dv *DataValidator) evaluateString(value string, schema *ValidationSchema, path string, response *ValidationResponse, scope *schemaScope) {
+	length := utf8.RuneCountInString(value)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		dv.addError(response, path, "MIN_LENGTH_VIOLATION", fmt.Sprintf("string length %d is less than minimum %d", length, *schema.MinLength), value)
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		dv.addError(response, path, "MAX_LENGTH_VIOLATION", fmt.Sprintf("string length %d exceeds maximum %d", length, *schema.MaxLength), value)
+	}
+
+	if schema.Pattern != nil {
+		compiled, err := scope.pattern(*schema.Pattern)
+		if err != nil {
+			dv.addError(response, path, "INVALID_PATTERN", fmt.Sprintf("invalid regex pattern: %v", err), value)
+		} else if !compiled.MatchString(value) {
+			dv.addError(response, path, "PATTERN_MISMATCH", fmt.Sprintf("value does not match pattern: %s", *schema.Pattern), value)
+		}
+	}
+
+	if schema.Format != nil {
+		if err := dv.validateFormat(value, *schema.Format); err != nil {
+			dv.addError(response, path, "FORMAT_VIOLATION", err.Error(), value)
+		}
+	}
+}
+
+// evaluateNumber applies the numeric keywords
+func (dv *DataValidator) evaluateNumber(number float64, data interface{}, schema *ValidationSchema, path string, response *ValidationResponse) {
+	if schema.Minimum != nil && number < *schema.Minimum {
+		dv.addError(response, path, "MIN_VALUE_VIOLATION", fmt.Sprintf("value %v is less than minimum %v", number, *schema.Minimum), data)
+	}
+	if schema.Maximum != nil && number > *schema.Maximum {
+		dv.addError(response, path, "MAX_VALUE_VIOLATION", fmt.Sprintf("value %v exceeds maximum %v", number, *schema.Maximum), data)
+	}
+	if schema.ExclusiveMinimum != nil && number <= *schema.ExclusiveMinimum {
+		dv.addError(response, path, "EXCLUSIVE_MINIMUM_VIOLATION", fmt.Sprintf("value %v must be greater than %v", number, *schema.ExclusiveMinimum), data)
+	}
+	if schema.ExclusiveMaximum != nil && number >= *schema.ExclusiveMaximum {
+		dv.addError(response, path, "EXCLUSIVE_MAXIMUM_VIOLATION", fmt.Sprintf("value %v must be less than %v", number, *schema.ExclusiveMaximum), data)
+	}
+	if schema.MultipleOf != nil && *schema.MultipleOf > 0 {
+		quotient := number / *schema.MultipleOf
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			dv.addError(response, path, "MULTIPLE_OF_VIOLATION", fmt.Sprintf("value %v is not a multiple of %v", number, *schema.MultipleOf), data)
+		}
+	}
+}
+
+// evaluateObject applies the object keywords. Properties are validated as
+// fields, so they count toward the metrics; fields in SkipFields are not
+// validated at all.
+func (dv *DataValidator) evaluateObject(object map[string]interface{}, schema *ValidationSchema, path string, response *ValidationResponse, options ValidationOptions, scope *schemaScope) {
+	for _, required := range schema.Required {
+		if _, exists := object[required]; !exists {
+			dv.addError(response, joinSchemaPath(path, required), "REQUIRED_FIELD_MISSING", "required field is missing", nil)
+		}
+	}
+	for _, name := range sortedMapKeys(schema.DependentRequired) {
+		if _, present := object[name]; !present {
+			continue
+		}
+		for _, dependent := range schema.DependentRequired[name] {
+			if _, exists := object[dependent]; !exists {
+				dv.addError(response, joinSchemaPath(path, dependent), "DEPENDENT_REQUIRED_MISSING", fmt.Sprintf("required when %s is present", name), nil)
+			}
+		}
+	}
+	for _, name := range sortedMapKeys(schema.DependentSchemas) {
+		if _, present := object[name]; present {
+			dv.evaluate(object, schema.DependentSchemas[name], path, response, options, scope)
+		}
+	}
+
+	// The original subset bounds object sizes with minItems and maxItems
+	if schema.MinItems != nil && len(object) < *schema.MinItems {
+		dv.addError(response, path, "MIN_ITEMS_VIOLATION", fmt.Sprintf("object has %d items, minimum is %d", len(object), *schema.MinItems), object)
+	}
+	if schema.MaxItems != nil && len(object) > *schema.MaxItems {
+		dv.addError(response, path, "MAX_ITEMS_VIOLATION", fmt.Sprintf("object has %d items, maximum is %d", len(object), *schema.MaxItems), object)
+	}
+	if schema.MinProperties != nil && len(object) < *schema.MinProperties {
+		dv.addError(response, path, "MIN_PROPERTIES_VIOLATION", fmt.Sprintf("object has %d properties, minimum is %d", len(object), *schema.MinProperties), object)
+	}
+	if schema.MaxProperties != nil && len(object) > *schema.MaxProperties {
+		dv.addError(response, path, "MAX_PROPERTIES_VIOLATION", fmt.Sprintf("object has %d properties, maximum is %d", len(object), *schema.MaxProperties), object)
+	}
+
+	patterns := scope.patternNames(schema)
+	for _, name := range sortedMapKeys(object) {
+		value := object[name]
+		fieldPath := joinSchemaPath(path, name)
+		if dv.containsString(options.SkipFields, name) {
+			continue
+		}
+
+		if schema.PropertyNames != nil && !dv.matches(name, schema.PropertyNames, fieldPath, options, scope) {
+			dv.addError(response, fieldPath, "PROPERTY_NAME_VIOLATION", "property name does not match propertyNames", name)
+		}
+
+		known := false
+		if fieldSchema, exists := schema.Properties[name]; exists {
+			known = true
+			dv.validateField(value, fieldSchema, scope.field(schema, name, fieldSchema), fieldPath, response, options, scope)
+		}
+		for _, expression := range patterns {
+			if compiled, err := scope.pattern(expression); err == nil && compiled.MatchString(name) {
+				known = true
+				dv.evaluate(value, schema.PatternProperties[expression], fieldPath, response, options, scope)
+			}
+		}
+		if known {
+			continue
+		}
+
+		switch additional := schema.AdditionalProperties; {
+		case additional != nil && additional.Bool != nil && !*additional.Bool:
+			dv.addError(response, fieldPath, "ADDITIONAL_PROPERTY", "additional properties are not allowed", value)
+		case additional != nil:
+			dv.evaluate(value, additional, fieldPath, response, options, scope)
+		case options.StrictMode:
+			response.Warnings = append(response.Warnings, ValidationWarning{
+				Field:   fieldPath,
+				Message: "unknown field",
+				Code:    "UNKNOWN_FIELD",
+				Value:   value,
+			})
+			response.Metrics.WarningFields++
+		}
+	}
+}
+
+// evaluateArray applies the array keywords
+func (dv *DataValidator) evaluateArray(items []interface{}, schema *ValidationSchema, path string, response *ValidationResponse, options ValidationOptions, scope *schemaScope) {
+	if schema.MinItems != nil && len(items) < *schema.MinItems {
+		dv.addError(response, path, "MIN_ITEMS_VIOLATION", fmt.Sprintf("array has %d items, minimum is %d", len(items), *schema.MinItems), items)
+	}
+	if schema.MaxItems != nil && len(items) > *schema.MaxItems {
+		dv.addError(response, path, "MAX_ITEMS_VIOLATION", fmt.Sprintf("array has %d items, maximum is %d", len(items), *schema.MaxItems), items)
+	}
+	if schema.UniqueItems {
+	unique:
+		for i := range items {
+			for j := i + 1; j < len(items); j++ {
+				if jsonEqual(items[i], items[j]) {
+					dv.addError(response, path, "UNIQUE_ITEMS_VIOLATION", fmt.Sprintf("items %d and %d are equal", i, j), items)
+					break unique
+				}
+			}
+		}
+	}
+
+	for i, item := range items {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		if i < len(schema.PrefixItems) {
+			dv.evaluate(item, schema.PrefixItems[i], itemPath, response, options, scope)
+		} else {
+			dv.evaluate(item, schema.Items, itemPath, response, options, scope)
+		}
+	}
+
+	if schema.Contains != nil {
+		contained := 0
+		for i, item := range items {
+			if dv.matches(item, schema.Contains, fmt.Sprintf("%s[%d]", path, i), options, scope) {
+				contained++
+			}
+		}
+		minimum := 1
+		if schema.MinContains != nil {
+			minimum = *schema.MinContains
+		}
+		if contained < minimum {
+			dv.addError(response, path, "CONTAINS_VIOLATION", fmt.Sprintf("array contains %d matching items, minimum is %d", contained, minimum), items)
+		}
+		if schema.MaxContains != nil && contained > *schema.MaxContains {
+			dv.addError(response, path, "CONTAINS_VIOLATION", fmt.Sprintf("array contains %d matching items, maximum is %d", contained, *schema.MaxContains), items)
+		}
+	}
+}
+
+// joinSchemaPath appends a property name to a dotted field path
+func joinSchemaPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// escapePointerToken escapes a name for use in a JSON pointer
+func escapePointerToken(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
+
+// sortedMapKeys returns the keys of a string-keyed map in order
+func sortedMapKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// numericValue returns a value as a float64 if it is a JSON number
+func numericValue(data interface{}) (float64, bool) {
+	if number, ok := data.(json.Number); ok {
+		value, err := number.Float64()
+		return value, err == nil
+	}
+	value := reflect.ValueOf(data)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+	return 0, false
+}
+
+// objectValue returns a value as a JSON object if it is one
+func objectValue(data interface{}) (map[string]interface{}, bool) {
+	if object, ok := data.(map[string]interface{}); ok {
+		return object, true
+	}
+	value := reflect.ValueOf(data)
+	if value.Kind() != reflect.Map || value.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	object := make(map[string]interface{}, value.Len())
+	for _, key := range value.MapKeys() {
+		object[key.String()] = value.MapIndex(key).Interface()
+	}
+	return object, true
+}
+
+// arrayValue returns a value as a JSON array if it is one
+func arrayValue(data interface{}) ([]interface{}, bool) {
+	if items, ok := data.([]interface{}); ok {
+		return items, true
+	}
+	value := reflect.ValueOf(data)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return nil, false
+	}
+	items := make([]interface{}, value.Len())
+	for i := range items {
+		items[i] = value.Index(i).Interface()
+	}
+	return items, true
+}
+
+// jsonTypeOf names the JSON type of a value
+func jsonTypeOf(data interface{}) string {
+	switch {
+	case data == nil:
+		return "null"
+	case reflect.TypeOf(data).Kind() == reflect.String:
+		return "string"
+	case reflect.TypeOf(data).Kind() == reflect.Bool:
+		return "boolean"
+	}
+	if _, ok := numericValue(data); ok {
+		return "number"
+	}
+	if _, ok := objectValue(data); ok {
+		return "object"
+	}
+	if _, ok := arrayValue(data); ok {
+		return "array"
+	}
+	return reflect.TypeOf(data).String()
+}
+
+// matchesAnyType reports whether a value has one of the given JSON types.
+// Integers are numbers without a fractional part, however they are stored.
+func matchesAnyType(data interface{}, types []string) bool {
+	actual := jsonTypeOf(data)
+	for _, expected := range types {
+		if expected == actual {
+			return true
+		}
+		if expected == "integer" && actual == "number" {
+			number, _ := numericValue(data)
+			if !math.IsInf(number, 0) && number == math.Trunc(number) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsJSONValue reports whether a list holds a value equal to data
+func containsJSONValue(values []interface{}, data interface{}) bool {
+	for _, value := range values {
+		if jsonEqual(value, data) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonEqual compares two values as JSON: numbers by value, objects by their
+// members and arrays element by element
+func jsonEqual(a, b interface{}) bool {
+	if numberA, ok := numericValue(a); ok {
+		numberB, ok := numericValue(b)
+		return ok && numberA == numberB
+	}
+	if objectA, ok := objectValue(a); ok {
+		objectB, ok := objectValue(b)
+		if !ok || len(objectA) != len(objectB) {
+			return false
+		}
+		for key, value := range objectA {
+			other, exists := objectB[key]
+			if !exists || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	if itemsA, ok := arrayValue(a); ok {
+		itemsB, ok := arrayValue(b)
+		if !ok || len(itemsA) != len(itemsB) {
+			return false
+		}
+		for i := range itemsA {
+			if !jsonEqual(itemsA[i], itemsB[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
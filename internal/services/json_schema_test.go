@@ -0,0 +1,195 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// dpResponseSchema is a DP response schema written as standard JSON Schema
+const dpResponseSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"$id": "https://dp.example.com/schemas/response.json",
+	"type": "object",
+	"required": ["job_id", "status", "result"],
+	"additionalProperties": false,
+	"properties": {
+		"job_id": {"type": "string", "minLength": 3},
+		"status": {"enum": ["completed", "partial", "failed"]},
+		"result": {"$ref": "#/$defs/result"},
+		"evidence": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
+		"error": {"type": ["string", "null"]}
+	},
+	"if": {"properties": {"status": {"const": "failed"}}},
+	"then": {"required": ["error"]},
+	"$defs": {
+		"result": {
+			"type": "object",
+			"required": ["verified"],
+			"properties": {
+				"verified": {"type": "boolean"},
+				"confidence": {"type": "number", "minimum": 0, "maximum": 1}
+			}
+		}
+	}
+}`
+
+func validateJSON(t *testing.T, schemaJSON, dataJSON string) ValidationResponse {
+	t.Helper()
+	var schema ValidationSchema
+	if err := json.Unmarshal([]byte(schemaJSON), &schema); err != nil {
+		t.Fatalf("Schema did not parse: %v", err)
+	}
+	var data interface{}
+	if err := json.Unmarshal([]byte(dataJSON), &data); err != nil {
+		t.Fatalf("Data did not parse: %v", err)
+	}
+	validator := NewDataValidator(DataValidatorConfig{MaxErrors: 100})
+	return validator.ValidateData(ValidationRequest{Data: data, Schema: schema})
+}
+
+func errorCodes(response ValidationResponse) map[string]string {
+	codes := make(map[string]string)
+	for _, validationError := range response.Errors {
+		codes[validationError.Field] = validationError.Code
+	}
+	return codes
+}
+
+func TestJSONSchema_StandardSchema(t *testing.T) {
+	valid := `{"job_id": "job-1", "status": "completed", "result": {"verified": true, "confidence": 0.9}, "evidence": ["registry"], "error": null}`
+	if response := validateJSON(t, dpResponseSchema, valid); !response.Valid {
+		t.Fatalf("Expected a valid response, got %v", response.Errors)
+	}
+
+	invalid := `{"job_id": "j", "status": "failed", "result": {"confidence": 2}, "evidence": ["a", "a"], "extra": 1}`
+	codes := errorCodes(validateJSON(t, dpResponseSchema, invalid))
+	expected := map[string]string{
+		"job_id":            "MIN_LENGTH_VIOLATION",
+		"result.verified":   "REQUIRED_FIELD_MISSING",
+		"result.confidence": "MAX_VALUE_VIOLATION",
+		"evidence":          "UNIQUE_ITEMS_VIOLATION",
+		"extra":             "ADDITIONAL_PROPERTY",
+		"error":             "REQUIRED_FIELD_MISSING",
+	}
+	for field, code := range expected {
+		if codes[field] != code {
+			t.Errorf("Expected %s at %s, got %q", code, field, codes[field])
+		}
+	}
+}
+
+func TestJSONSchema_Combinators(t *testing.T) {
+	schema := `{
+		"properties": {
+			"id": {"anyOf": [{"type": "string", "pattern": "^S-"}, {"type": "integer"}]},
+			"contact": {"oneOf": [{"type": "string", "format": "email"}, {"type": "string", "pattern": "^\\+"}]},
+			"score": {"allOf": [{"type": "number"}, {"minimum": 10}], "not": {"const": 13}}
+		}
+	}`
+	if response := validateJSON(t, schema, `{"id": 42, "contact": "+15550100", "score": 12}`); !response.Valid {
+		t.Errorf("Expected a valid document, got %v", response.Errors)
+	}
+
+	codes := errorCodes(validateJSON(t, schema, `{"id": "X-1", "contact": 7, "score": 13}`))
+	if codes["id"] != "ANY_OF_VIOLATION" || codes["contact"] != "ONE_OF_VIOLATION" || codes["score"] != "NOT_VIOLATION" {
+		t.Errorf("Unexpected errors: %v", codes)
+	}
+	if codes := errorCodes(validateJSON(t, schema, `{"score": 5}`)); codes["score"] != "MIN_VALUE_VIOLATION" {
+		t.Errorf("Expected allOf branches applied, got %v", codes)
+	}
+}
+
+func TestJSONSchema_RecursiveRef(t *testing.T) {
+	schema := `{
+		"$ref": "#/$defs/node",
+		"$defs": {
+			"node": {
+				"type": "object",
+				"required": ["name"],
+				"properties": {
+					"name": {"type": "string"},
+					"children": {"type": "array", "items": {"$ref": "#/$defs/node"}}
+				}
+			}
+		}
+	}`
+	valid := `{"name": "root", "children": [{"name": "a", "children": [{"name": "b"}]}]}`
+	if response := validateJSON(t, schema, valid); !response.Valid {
+		t.Errorf("Expected a valid tree, got %v", response.Errors)
+	}
+	codes := errorCodes(validateJSON(t, schema, `{"name": "root", "children": [{"children": [{"name": 1}]}]}`))
+	if codes["children[0].name"] != "REQUIRED_FIELD_MISSING" || codes["children[0].children[0].name"] != "TYPE_MISMATCH" {
+		t.Errorf("Expected errors at nested paths, got %v", codes)
+	}
+}
+
+func TestJSONSchema_ArraysAndObjects(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"point": {"type": "array", "prefixItems": [{"type": "number"}, {"type": "number"}], "items": false},
+			"tags": {"type": "array", "contains": {"const": "verified"}},
+			"count": {"type": "integer", "multipleOf": 5}
+		},
+		"patternProperties": {"^x-": {"type": "string"}},
+		"additionalProperties": {"type": "boolean"},
+		"dependentRequired": {"card": ["expiry"]}
+	}`
+	valid := `{"point": [1.5, 2], "tags": ["verified"], "count": 10.0, "x-source": "dp", "flag": true}`
+	if response := validateJSON(t, schema, valid); !response.Valid {
+		t.Errorf("Expected a valid document, got %v", response.Errors)
+	}
+
+	invalid := `{"point": [1, 2, 3], "tags": [], "count": 7, "x-source": 1, "flag": "yes", "card": true}`
+	codes := errorCodes(validateJSON(t, schema, invalid))
+	expected := map[string]string{
+		"point[2]": "FALSE_SCHEMA",
+		"tags":     "CONTAINS_VIOLATION",
+		"count":    "MULTIPLE_OF_VIOLATION",
+		"x-source": "TYPE_MISMATCH",
+		"flag":     "TYPE_MISMATCH",
+		"expiry":   "DEPENDENT_REQUIRED_MISSING",
+	}
+	for field, code := range expected {
+		if codes[field] != code {
+			t.Errorf("Expected %s at %s, got %q", code, field, codes[field])
+		}
+	}
+}
+
+func TestJSONSchema_InvalidSchemas(t *testing.T) {
+	validator := NewDataValidator(DataValidatorConfig{MaxErrors: 100})
+	for name, schemaJSON := range map[string]string{
+		"unknown type":        `{"type": "text"}`,
+		"unresolvable ref":    `{"properties": {"a": {"$ref": "#/$defs/missing"}}}`,
+		"remote ref":          `{"$ref": "https://example.com/other.json"}`,
+		"invalid pattern":     `{"patternProperties": {"(": true}}`,
+		"unsupported keyword": `{"properties": {"a": {"type": "object", "unevaluatedProperties": false}}}`,
+	} {
+		var schema ValidationSchema
+		if err := json.Unmarshal([]byte(schemaJSON), &schema); err != nil {
+			t.Fatalf("%s: schema did not parse: %v", name, err)
+		}
+		response := validator.ValidateData(ValidationRequest{Data: map[string]interface{}{}, Schema: schema})
+		if response.Valid || len(response.Errors) == 0 || response.Errors[0].Code != "INVALID_SCHEMA" {
+			t.Errorf("%s: expected INVALID_SCHEMA, got %v", name, response.Errors)
+		}
+	}
+}
+
+func TestJSONSchema_OriginalSubsetStillParses(t *testing.T) {
+	schemaJSON := `{"type": "object", "properties": {"age": {"type": "integer", "required": true, "minValue": 18, "default": 21}}}`
+	var schema ValidationSchema
+	if err := json.Unmarshal([]byte(schemaJSON), &schema); err != nil {
+		t.Fatalf("Schema did not parse: %v", err)
+	}
+	age := schema.Properties["age"]
+	if !age.Required || age.Type != "integer" || age.MinValue == nil || *age.MinValue != 18 || age.Default != float64(21) {
+		t.Errorf("Expected the original field keywords read, got %+v", age)
+	}
+
+	codes := errorCodes(validateJSON(t, schemaJSON, `{"age": 16}`))
+	if codes["age"] != "MIN_VALUE_VIOLATION" {
+		t.Errorf("Expected minValue applied, got %v", codes)
+	}
+}
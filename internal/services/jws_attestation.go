@@ -2,10 +2,12 @@ package services
 
 import (
 	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"math/big"
@@ -69,6 +71,85 @@ type JWSClaims struct {
 	JWTID           string    `json:"jti"`
 }
 
+// jwsClaimsJSON is the wire form of JWSClaims with time claims as NumericDate
+type jwsClaimsJSON JWSClaims
+
+// MarshalJSON encodes nbf, exp and iat as NumericDate values (RFC 7519 §2)
+func (c JWSClaims) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		jwsClaimsJSON
+		NotBefore *jwt.NumericDate `json:"nbf,omitempty"`
+		ExpiresAt *jwt.NumericDate `json:"exp,omitempty"`
+		IssuedAt  *jwt.NumericDate `json:"iat,omitempty"`
+	}{
+		jwsClaimsJSON: jwsClaimsJSON(c),
+		NotBefore:     toNumericDate(c.NotBefore),
+		ExpiresAt:     toNumericDate(c.ExpiresAt),
+		IssuedAt:      toNumericDate(c.IssuedAt),
+	})
+}
+
+// UnmarshalJSON decodes NumericDate time claims
+func (c *JWSClaims) UnmarshalJSON(data []byte) error {
+	wire := struct {
+		*jwsClaimsJSON
+		NotBefore *jwt.NumericDate `json:"nbf,omitempty"`
+		ExpiresAt *jwt.NumericDate `json:"exp,omitempty"`
+		IssuedAt  *jwt.NumericDate `json:"iat,omitempty"`
+	}{jwsClaimsJSON: (*jwsClaimsJSON)(c)}
+
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+
+	c.NotBefore = fromNumericDate(wire.NotBefore)
+	c.ExpiresAt = fromNumericDate(wire.ExpiresAt)
+	c.IssuedAt = fromNumericDate(wire.IssuedAt)
+	return nil
+}
+
+// GetExpirationTime implements jwt.Claims using the attestation's own exp claim
+func (c JWSClaims) GetExpirationTime() (*jwt.NumericDate, error) {
+	return toNumericDate(c.ExpiresAt), nil
+}
+
+// GetNotBefore implements jwt.Claims using the attestation's own nbf claim
+func (c JWSClaims) GetNotBefore() (*jwt.NumericDate, error) {
+	return toNumericDate(c.NotBefore), nil
+}
+
+// GetIssuedAt implements jwt.Claims using the attestation's own iat claim
+func (c JWSClaims) GetIssuedAt() (*jwt.NumericDate, error) {
+	return toNumericDate(c.IssuedAt), nil
+}
+
+// GetIssuer implements jwt.Claims using the attestation's own iss claim
+func (c JWSClaims) GetIssuer() (string, error) {
+	return c.Issuer, nil
+}
+
+// GetAudience implements jwt.Claims using the attestation's own aud claim
+func (c JWSClaims) GetAudience() (jwt.ClaimStrings, error) {
+	if c.Audience == "" {
+		return nil, nil
+	}
+	return jwt.ClaimStrings{c.Audience}, nil
+}
+
+func toNumericDate(t time.Time) *jwt.NumericDate {
+	if t.IsZero() {
+		return nil
+	}
+	return jwt.NewNumericDate(t)
+}
+
+func fromNumericDate(d *jwt.NumericDate) time.Time {
+	if d == nil {
+		return time.Time{}
+	}
+	return d.Time
+}
+
 // JWSHeader represents the header of a JWS token
 type JWSHeader struct {
 	Algorithm string `json:"alg"`
@@ -114,13 +195,27 @@ func NewJWSAttestationService(cfg *config.Config) *JWSAttestationService {
 	return service
 }
 
-// initializeKeys initializes the RSA key pair for JWS signing
+// initializeKeys initializes the RSA key pair for JWS signing. With
+// ATTESTATION_KEY_FILE the key is kept there wrapped by the KeyProvider, so
+// attestations stay verifiable across restarts; otherwise a new key pair is
+// generated.
 func (s *JWSAttestationService) initializeKeys() error {
-	// In production, load keys from secure storage or environment
-	// For now, generate a new key pair
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	generate := func() (crypto.PrivateKey, error) {
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+	var key crypto.PrivateKey
+	var err error
+	if s.config != nil && s.config.AttestationKeyFile != "" {
+		key, err = loadWrappedKey(s.config, "attestation", s.config.AttestationKeyFile, s.config.AttestationKeyID, generate)
+	} else {
+		key, err = generate()
+	}
 	if err != nil {
-		return fmt.Errorf("failed to generate RSA key: %w", err)
+		return fmt.Errorf("failed to load RSA key: %w", err)
+	}
+	privateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("attestation key must be an RSA key")
 	}
 
 	s.privateKey = privateKey
@@ -146,6 +241,12 @@ func (s *JWSAttestationService) GenerateJWS(
 	// Create claims
 	now := time.Now()
 	expiresAt := now.Add(24 * time.Hour) // JWS expires in 24 hours
+	// The attestation must not outlive the result it attests to
+	if response.ExpirationTime != "" {
+		if resultExpiry, err := time.Parse(time.RFC3339, response.ExpirationTime); err == nil && resultExpiry.Before(expiresAt) {
+			expiresAt = resultExpiry
+		}
+	}
 
 	claims := JWSClaims{
 		Verified:        response.Verified,
@@ -180,8 +281,8 @@ func (s *JWSAttestationService) GenerateJWS(
 		return nil, fmt.Errorf("failed to sign JWS token: %w", err)
 	}
 
-	// Parse the signed token to extract components
-	parsedToken, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+	// Parse the signed token to extract components (time claims are checked on validation)
+	parsedToken, err := jwt.NewParser(jwt.WithoutClaimsValidation()).Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		return s.publicKey, nil
 	})
 	if err != nil {
@@ -254,7 +355,7 @@ func (s *JWSAttestationService) ValidateJWS(ctx context.Context, tokenString str
 		}
 
 		return s.publicKey, nil
-	})
+	}, jwt.WithLeeway(ClockSkewTolerance(s.config)))
 
 	if err != nil {
 		s.auditLogger.LogEvent("jws_validation_failed", "JWS validation failed", "", "", "error", map[string]string{
@@ -285,13 +386,16 @@ func (s *JWSAttestationService) ValidateJWS(ctx context.Context, tokenString str
 
 // VerifyJWSClaims verifies the claims in a JWS token
 func (s *JWSAttestationService) VerifyJWSClaims(claims *JWSClaims) error {
+	now := time.Now()
+	tolerance := ClockSkewTolerance(s.config)
+
 	// Check if token is expired
-	if time.Now().After(claims.ExpiresAt) {
+	if ExpiredWithSkew(claims.ExpiresAt, now, tolerance) {
 		return fmt.Errorf("JWS token is expired")
 	}
 
 	// Check if token is not yet valid
-	if time.Now().Before(claims.NotBefore) {
+	if NotYetValidWithSkew(claims.NotBefore, now, tolerance) {
 		return fmt.Errorf("JWS token is not yet valid")
 	}
 
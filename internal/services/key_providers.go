@@ -0,0 +1,308 @@
+package services
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// Key provider backends selectable with CONFIG_KEY_PROVIDER. With either, the
+// master key stays in the KMS: only data keys are sent to be wrapped or
+// unwrapped, and CONFIG_MASTER_KEY_ID names the KMS key.
+const (
+	KeyProviderAWSKMS       = "aws-kms"
+	KeyProviderVaultTransit = "vault-transit"
+)
+
+func init() {
+	config.RegisterKeyProvider(KeyProviderAWSKMS, func() (config.KeyProvider, error) {
+		return AWSKMSKeyProviderFromEnv()
+	})
+	config.RegisterKeyProvider(KeyProviderVaultTransit, func() (config.KeyProvider, error) {
+		return VaultTransitKeyProviderFromEnv()
+	})
+}
+
+// AWSKMSKeyProvider wraps data keys with AWS KMS Encrypt and Decrypt. Key IDs
+// are KMS key IDs or aliases ("alias/pavilion-master"); ARNs cannot be used
+// because enc:v1 key IDs may not contain ':'.
+type AWSKMSKeyProvider struct {
+	endpoint string
+	signer   *SigV4Signer
+	client   *http.Client
+}
+
+// NewAWSKMSKeyProvider creates a provider for the KMS endpoint, signing with
+// the given credentials or the standard provider chain
+func NewAWSKMSKeyProvider(endpoint string, sigv4 *SigV4Config) *AWSKMSKeyProvider {
+	sigv4.Service = "kms"
+	return &AWSKMSKeyProvider{
+		endpoint: endpoint,
+		signer:   NewSigV4Signer(sigv4),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AWSKMSKeyProviderFromEnv creates a provider from AWS_REGION (or
+// AWS_KMS_REGION), AWS_KMS_ENDPOINT for VPC endpoints and AWS_PROFILE
+func AWSKMSKeyProviderFromEnv() (*AWSKMSKeyProvider, error) {
+	region := os.Getenv("AWS_KMS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("aws-kms key provider requires AWS_REGION")
+	}
+	endpoint := os.Getenv("AWS_KMS_ENDPOINT")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://kms.%s.amazonaws.com/", region)
+	}
+	return NewAWSKMSKeyProvider(endpoint, &SigV4Config{Region: region, Profile: os.Getenv("AWS_PROFILE")}), nil
+}
+
+// kmsEncryptionContext binds wrapped keys to the broker and key ID; KMS
+// refuses to decrypt them under any other context
+func kmsEncryptionContext(keyID string) map[string]string {
+	return map[string]string{"pavilion:key_id": keyID}
+}
+
+// WrapKey encrypts a data key under the named KMS key
+func (p *AWSKMSKeyProvider) WrapKey(keyID string, dataKey []byte) ([]byte, error) {
+	var output struct {
+		CiphertextBlob []byte `json:"CiphertextBlob"`
+	}
+	err := p.call("Encrypt", map[string]interface{}{
+		"KeyId":             keyID,
+		"Plaintext":         dataKey,
+		"EncryptionContext": kmsEncryptionContext(keyID),
+	}, &output)
+	if err != nil {
+		return nil, err
+	}
+	return output.CiphertextBlob, nil
+}
+
+// UnwrapKey decrypts a data key wrapped under the named KMS key
+func (p *AWSKMSKeyProvider) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	var output struct {
+		Plaintext []byte `json:"Plaintext"`
+	}
+	err := p.call("Decrypt", map[string]interface{}{
+		"KeyId":             keyID,
+		"CiphertextBlob":    wrapped,
+		"EncryptionContext": kmsEncryptionContext(keyID),
+	}, &output)
+	if err != nil {
+		return nil, err
+	}
+	return output.Plaintext, nil
+}
+
+// call sends a signed KMS JSON API request
+func (p *AWSKMSKeyProvider) call(action string, input, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	if err := p.signer.Sign(req); err != nil {
+		return fmt.Errorf("failed to sign KMS request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("KMS %s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var kmsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &kmsErr)
+		return fmt.Errorf("KMS %s failed with status %d: %s %s", action, resp.StatusCode, kmsErr.Type, kmsErr.Message)
+	}
+	return json.Unmarshal(data, output)
+}
+
+// VaultTransitKeyProvider wraps data keys with a HashiCorp Vault transit
+// secrets engine. Key IDs are transit key names.
+type VaultTransitKeyProvider struct {
+	address   string
+	mount     string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// NewVaultTransitKeyProvider creates a provider for the transit engine at mount
+func NewVaultTransitKeyProvider(address, mount, token, namespace string) *VaultTransitKeyProvider {
+	return &VaultTransitKeyProvider{
+		address:   strings.TrimRight(address, "/"),
+		mount:     strings.Trim(mount, "/"),
+		token:     token,
+		namespace: namespace,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// VaultTransitKeyProviderFromEnv creates a provider from VAULT_ADDR,
+// VAULT_TOKEN (or the file named by VAULT_TOKEN_FILE), VAULT_NAMESPACE and
+// VAULT_TRANSIT_MOUNT
+func VaultTransitKeyProviderFromEnv() (*VaultTransitKeyProvider, error) {
+	address := os.Getenv("VAULT_ADDR")
+	if address == "" {
+		return nil, fmt.Errorf("vault-transit key provider requires VAULT_ADDR")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if path := os.Getenv("VAULT_TOKEN_FILE"); token == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Vault token file: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token == "" {
+		return nil, fmt.Errorf("vault-transit key provider requires VAULT_TOKEN or VAULT_TOKEN_FILE")
+	}
+	mount := os.Getenv("VAULT_TRANSIT_MOUNT")
+	if mount == "" {
+		mount = "transit"
+	}
+	return NewVaultTransitKeyProvider(address, mount, token, os.Getenv("VAULT_NAMESPACE")), nil
+}
+
+// WrapKey encrypts a data key under the named transit key
+func (p *VaultTransitKeyProvider) WrapKey(keyID string, dataKey []byte) ([]byte, error) {
+	var output struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	input := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}
+	if err := p.call("encrypt", keyID, input, &output); err != nil {
+		return nil, err
+	}
+	if output.Ciphertext == "" {
+		return nil, fmt.Errorf("vault returned no ciphertext")
+	}
+	// The vault:vN: ciphertext is kept as is so Vault can tell its key version
+	return []byte(output.Ciphertext), nil
+}
+
+// UnwrapKey decrypts a data key wrapped under the named transit key
+func (p *VaultTransitKeyProvider) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	var output struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := p.call("decrypt", keyID, map[string]string{"ciphertext": string(wrapped)}, &output); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(output.Plaintext)
+}
+
+// call sends a transit request and decodes its data
+func (p *VaultTransitKeyProvider) call(operation, keyID string, input, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/v1/%s/%s/%s", p.address, p.mount, operation, url.PathEscape(keyID))
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault transit %s failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&envelope); err != nil && resp.StatusCode == http.StatusOK {
+		return fmt.Errorf("malformed vault response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault transit %s failed with status %d: %s", operation, resp.StatusCode, strings.Join(envelope.Errors, "; "))
+	}
+	return json.Unmarshal(envelope.Data, output)
+}
+
+// loadWrappedKey returns the private key kept in path as an enc:v1 value
+// wrapped by the KeyProvider, generating and storing it on first use. The key
+// is only ever written wrapped, so it is as safe as the master key.
+func loadWrappedKey(cfg *config.Config, name, path, keyID string, generate func() (crypto.PrivateKey, error)) (crypto.PrivateKey, error) {
+	if cfg.KeyProvider == nil {
+		return nil, fmt.Errorf("%s key file requires a key provider (set CONFIG_MASTER_KEY or CONFIG_KEY_PROVIDER)", name)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := generate()
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s key: %w", name, err)
+		}
+		wrapped, err := config.EncryptValue(cfg.KeyProvider, keyID, base64.StdEncoding.EncodeToString(der))
+		if err != nil {
+			return nil, fmt.Errorf("failed to wrap %s key: %w", name, err)
+		}
+		if err := os.WriteFile(path, []byte(wrapped+"\n"), 0600); err != nil {
+			return nil, fmt.Errorf("failed to store %s key: %w", name, err)
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s key: %w", name, err)
+	}
+
+	value := strings.TrimSpace(string(data))
+	if !config.IsEncryptedValue(value) {
+		return nil, fmt.Errorf("%s key file must hold an enc:v1 value", name)
+	}
+	encoded, err := config.DecryptValue(cfg.KeyProvider, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap %s key: %w", name, err)
+	}
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed %s key", name)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("malformed %s key: %w", name, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestVaultTransitKeyProvider_RoundTrip(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		var input map[string]string
+		json.NewDecoder(r.Body).Decode(&input)
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/pavilion":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"ciphertext": "vault:v1:" + input["plaintext"]}})
+		case "/v1/transit/decrypt/pavilion":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"plaintext": strings.TrimPrefix(input["ciphertext"], "vault:v1:")}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	provider := NewVaultTransitKeyProvider(vault.URL, "transit", "s.token", "")
+	value, err := config.EncryptValue(provider, "pavilion", "client-secret")
+	if err != nil {
+		t.Fatalf("EncryptValue failed: %v", err)
+	}
+	if plaintext, err := config.DecryptValue(provider, value); err != nil || plaintext != "client-secret" {
+		t.Errorf("Expected the value to decrypt, got %q, %v", plaintext, err)
+	}
+
+	denied := NewVaultTransitKeyProvider(vault.URL, "transit", "wrong", "")
+	if _, err := denied.WrapKey("pavilion", make([]byte, 32)); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected Vault's error to be reported, got %v", err)
+	}
+}
+
+func TestAWSKMSKeyProvider_RoundTrip(t *testing.T) {
+	kms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "/us-east-1/kms/aws4_request") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var input map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&input)
+		if input["EncryptionContext"].(map[string]interface{})["pavilion:key_id"] != input["KeyId"] {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"InvalidCiphertextException","message":"context mismatch"}`))
+			return
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.Encrypt":
+			json.NewEncoder(w).Encode(map[string]interface{}{"CiphertextBlob": input["Plaintext"]})
+		case "TrentService.Decrypt":
+			json.NewEncoder(w).Encode(map[string]interface{}{"Plaintext": input["CiphertextBlob"]})
+		}
+	}))
+	defer kms.Close()
+
+	provider := NewAWSKMSKeyProvider(kms.URL, &SigV4Config{Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	value, err := config.EncryptValue(provider, "alias/pavilion", "client-secret")
+	if err != nil {
+		t.Fatalf("EncryptValue failed: %v", err)
+	}
+	if plaintext, err := config.DecryptValue(provider, value); err != nil || plaintext != "client-secret" {
+		t.Errorf("Expected the value to decrypt, got %q, %v", plaintext, err)
+	}
+}
+
+func TestKeyProviderFromEnv_SelectsBackend(t *testing.T) {
+	t.Setenv("CONFIG_KEY_PROVIDER", KeyProviderVaultTransit)
+	t.Setenv("VAULT_ADDR", "https://vault.example")
+	t.Setenv("VAULT_TOKEN", "s.token")
+	provider, err := config.KeyProviderFromEnv()
+	if err != nil {
+		t.Fatalf("KeyProviderFromEnv failed: %v", err)
+	}
+	if _, ok := provider.(*VaultTransitKeyProvider); !ok {
+		t.Errorf("Expected the Vault transit provider, got %T", provider)
+	}
+
+	t.Setenv("CONFIG_KEY_PROVIDER", "pkcs11")
+	if _, err := config.KeyProviderFromEnv(); err == nil {
+		t.Error("Expected an unknown key provider to be rejected")
+	}
+}
+
+func TestJWSAttestationService_WrappedKey(t *testing.T) {
+	provider, _ := config.NewLocalKeyProvider(map[string][]byte{"k1": make([]byte, 32)})
+	path := filepath.Join(t.TempDir(), "attestation.key")
+	cfg := &config.Config{KeyProvider: provider, AttestationKeyFile: path, AttestationKeyID: "k1"}
+
+	first := NewJWSAttestationService(cfg)
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.HasPrefix(string(data), "enc:v1:k1:") {
+		t.Fatalf("Expected the key to be stored wrapped, got %q, %v", data, err)
+	}
+	if strings.Contains(string(data), base64.StdEncoding.EncodeToString(first.privateKey.D.Bytes())[:16]) {
+		t.Error("Expected no key material in the clear")
+	}
+
+	second := NewJWSAttestationService(cfg)
+	if second.privateKey == nil || !second.privateKey.Equal(first.privateKey) {
+		t.Error("Expected the stored key to be reused across restarts")
+	}
+}
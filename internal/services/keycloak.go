@@ -73,7 +73,7 @@ func (s *KeycloakService) ValidateToken(ctx context.Context, tokenString string)
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return publicKey, nil
-	})
+	}, jwt.WithLeeway(ClockSkewTolerance(s.config)))
 
 	if err != nil {
 		return nil, fmt.Errorf("token validation failed: %w", err)
@@ -173,16 +173,19 @@ func (s *KeycloakService) convertJWKToPublicKey() (*rsa.PublicKey, error) {
 
 // validateClaims validates required JWT claims
 func (s *KeycloakService) validateClaims(claims jwt.MapClaims) error {
+	now := time.Now()
+	tolerance := ClockSkewTolerance(s.config)
+
 	// Check if token is expired
 	if exp, ok := claims["exp"].(float64); ok {
-		if time.Unix(int64(exp), 0).Before(time.Now()) {
+		if ExpiredWithSkew(time.Unix(int64(exp), 0), now, tolerance) {
 			return fmt.Errorf("token expired")
 		}
 	}
 
 	// Check if token is not yet valid
 	if nbf, ok := claims["nbf"].(float64); ok {
-		if time.Unix(int64(nbf), 0).After(time.Now()) {
+		if NotYetValidWithSkew(time.Unix(int64(nbf), 0), now, tolerance) {
 			return fmt.Errorf("token not yet valid")
 		}
 	}
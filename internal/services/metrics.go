@@ -3,6 +3,8 @@ package services
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -30,6 +32,16 @@ type MetricsService struct {
 	auditLogEntries     int64
 	policyDecisions     int64
 
+	// Per-tenant metrics with bounded label cardinality
+	tenantLabeler     *TenantLabeler
+	tenants           map[string]*tenantCounters
+	untrackedTenants  tenantCounters
+	tenantTopN        int
+	maxTrackedTenants int
+
+	// Collectors add metrics owned by other services
+	collectors []func() []Metric
+
 	// Performance tracking
 	startTime time.Time
 	lastReset time.Time
@@ -65,13 +77,34 @@ type AlertThreshold struct {
 
 // NewMetricsService creates a new metrics service
 func NewMetricsService(cfg *config.Config) *MetricsService {
+	topN := cfg.TenantMetricsTopN
+	if topN <= 0 {
+		topN = 20
+	}
+	maxTracked := cfg.TenantMetricsMaxTracked
+	if maxTracked <= 0 {
+		maxTracked = 10000
+	}
+
 	return &MetricsService{
-		config:    cfg,
-		startTime: time.Now(),
-		lastReset: time.Now(),
+		config:            cfg,
+		tenantLabeler:     NewTenantLabeler(cfg.TenantLabelMaxLength),
+		tenants:           make(map[string]*tenantCounters),
+		tenantTopN:        topN,
+		maxTrackedTenants: maxTracked,
+		startTime:         time.Now(),
+		lastReset:         time.Now(),
 	}
 }
 
+// AddCollector adds metrics owned by another service, such as the DP
+// connector's bulkheads, to every scrape
+func (s *MetricsService) AddCollector(collector func() []Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collectors = append(s.collectors, collector)
+}
+
 // RecordRequest records a request with latency
 func (s *MetricsService) RecordRequest(latency time.Duration) {
 	s.mu.Lock()
@@ -152,10 +185,20 @@ func (s *MetricsService) RecordPolicyDecision() {
 
 // GetMetrics returns all current metrics
 func (s *MetricsService) GetMetrics() []Metric {
+	now := time.Now()
+	tenantMetrics := s.tenantMetrics(now)
+
+	s.mu.RLock()
+	collectors := append([]func() []Metric(nil), s.collectors...)
+	s.mu.RUnlock()
+	var collected []Metric
+	for _, collector := range collectors {
+		collected = append(collected, collector()...)
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	now := time.Now()
 	uptime := now.Sub(s.startTime)
 
 	var metrics []Metric
@@ -294,31 +337,56 @@ func (s *MetricsService) GetMetrics() []Metric {
 		Time:  now,
 	})
 
+	// Per-tenant metrics (top-N plus "other")
+	metrics = append(metrics, tenantMetrics...)
+
+	// Metrics from other services
+	metrics = append(metrics, collected...)
+
 	return metrics
 }
 
+// prometheusLabelEscaper escapes label values for the text exposition format
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
 // GetPrometheusMetrics returns metrics in Prometheus format
 func (s *MetricsService) GetPrometheusMetrics() string {
 	metrics := s.GetMetrics()
 	var prometheus string
 
+	lastName := ""
 	for _, metric := range metrics {
-		// Add help text
-		if metric.Help != "" {
-			prometheus += fmt.Sprintf("# HELP %s %s\n", metric.Name, metric.Help)
+		// Labeled series share a single HELP/TYPE header; a histogram's
+		// _bucket, _sum and _count series share their family's
+		family := metric.Name
+		if metric.Type == MetricTypeHistogram {
+			for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+				if strings.HasSuffix(family, suffix) {
+					family = strings.TrimSuffix(family, suffix)
+					break
+				}
+			}
 		}
+		if family != lastName {
+			// Add help text
+			if metric.Help != "" {
+				prometheus += fmt.Sprintf("# HELP %s %s\n", family, metric.Help)
+			}
 
-		// Add type
-		prometheus += fmt.Sprintf("# TYPE %s %s\n", metric.Name, metric.Type)
+			// Add type
+			prometheus += fmt.Sprintf("# TYPE %s %s\n", family, metric.Type)
+			lastName = family
+		}
 
 		// Add metric value
 		labels := ""
 		if len(metric.Labels) > 0 {
 			var labelPairs []string
 			for k, v := range metric.Labels {
-				labelPairs = append(labelPairs, fmt.Sprintf(`%s="%s"`, k, v))
+				labelPairs = append(labelPairs, fmt.Sprintf(`%s="%s"`, k, prometheusLabelEscaper.Replace(v)))
 			}
-			labels = fmt.Sprintf("{%s}", fmt.Sprintf("%s", labelPairs))
+			sort.Strings(labelPairs)
+			labels = fmt.Sprintf("{%s}", strings.Join(labelPairs, ","))
 		}
 
 		prometheus += fmt.Sprintf("%s%s %f\n", metric.Name, labels, metric.Value)
@@ -459,6 +527,8 @@ func (s *MetricsService) ResetMetrics() {
 	s.auditLogEntries = 0
 	s.policyDecisions = 0
 	s.requestLatency = nil
+	s.tenants = make(map[string]*tenantCounters)
+	s.untrackedTenants = tenantCounters{}
 	s.lastReset = time.Now()
 }
 
@@ -0,0 +1,509 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// Notification events sent to RPs
+const (
+	NotificationBatchCompleted = "batch.completed"
+	// NotificationRegistryChanged tells an RP that a DP registry entry or
+	// claim schema for a claim type it uses changed behavior
+	NotificationRegistryChanged = "registry.changed"
+)
+
+// Notification delivery outcomes
+const (
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// maxNotificationDeliveries bounds the delivery history kept for RPs
+const maxNotificationDeliveries = 1000
+
+// notificationStreamBuffer bounds the notifications queued for a stream
+// before it is dropped as too slow
+const notificationStreamBuffer = 16
+
+// RPNotification tells an RP that asynchronous work finished. It carries
+// identifiers and counts only; results are fetched from the resource's URL
+// with the RP's credentials.
+type RPNotification struct {
+	ID         string                 `json:"id"`
+	Event      string                 `json:"event"`
+	RPID       string                 `json:"rp_id"`
+	ResourceID string                 `json:"resource_id"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
+}
+
+// WebhookSubscription is a URL an RP registered for notifications. The
+// signing secret is only returned when the subscription is created.
+type WebhookSubscription struct {
+	ID        string    `json:"id"`
+	RPID      string    `json:"rp_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+	secret    string
+}
+
+// wants reports whether the subscription receives an event
+func (s *WebhookSubscription) wants(event string) bool {
+	for _, subscribed := range s.Events {
+		if subscribed == event || subscribed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationDelivery records the delivery of a notification to a webhook
+type NotificationDelivery struct {
+	ID             string     `json:"id"`
+	NotificationID string     `json:"notification_id"`
+	SubscriptionID string     `json:"subscription_id"`
+	RPID           string     `json:"rp_id"`
+	Event          string     `json:"event"`
+	URL            string     `json:"url"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	StatusCode     int        `json:"status_code,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+}
+
+// NotificationService sends RPs notifications when asynchronous
+// verifications complete, to the webhooks they registered and to their open
+// WebSocket streams. Webhook bodies are signed with HMAC-SHA256 under the
+// subscription's secret in X-Pavilion-Signature, failed deliveries are
+// retried with exponential backoff, and every delivery is audited. With an
+// outbox, webhook deliveries are committed to storage first, so they
+// survive a crash.
+type NotificationService struct {
+	auditService *AuditService
+	client       *http.Client
+	maxAttempts  int
+	backoff      time.Duration
+
+	mu            sync.Mutex
+	outbox        *OutboxDispatcher
+	subscriptions map[string]*WebhookSubscription
+	streams       map[string]map[int]chan RPNotification
+	nextStream    int
+	deliveries    []NotificationDelivery
+	inFlight      int
+}
+
+// NewNotificationService creates a notification service
+func NewNotificationService(cfg *config.Config, auditService *AuditService) *NotificationService {
+	maxAttempts := cfg.NotificationMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	timeout := cfg.NotificationTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &NotificationService{
+		auditService:  auditService,
+		client:        &http.Client{Timeout: timeout},
+		maxAttempts:   maxAttempts,
+		backoff:       cfg.NotificationRetryBackoff,
+		subscriptions: make(map[string]*WebhookSubscription),
+		streams:       make(map[string]map[int]chan RPNotification),
+	}
+}
+
+// outboxNotification is a webhook delivery committed to the outbox
+type outboxNotification struct {
+	SubscriptionID string         `json:"subscription_id"`
+	Notification   RPNotification `json:"notification"`
+}
+
+// SetOutbox commits webhook deliveries through an outbox, whose dispatcher
+// attempts them with the service's retry policy
+func (s *NotificationService) SetOutbox(outbox *OutboxDispatcher) {
+	s.mu.Lock()
+	s.outbox = outbox
+	s.mu.Unlock()
+	outbox.Handle(OutboxNotification, OutboxRetryPolicy{MaxAttempts: s.maxAttempts, Backoff: s.backoff}, s.deliverOutbox)
+}
+
+// RegisterWebhook subscribes a URL to an RP's notifications, returning the
+// subscription and the secret its deliveries are signed with. Webhooks must
+// use HTTPS, except on loopback addresses for local development.
+func (s *NotificationService) RegisterWebhook(rpID, rawURL string, events []string) (*WebhookSubscription, string, error) {
+	if rpID == "" {
+		return nil, "", fmt.Errorf("webhooks require an RP")
+	}
+	if err := validateWebhookURL(rawURL); err != nil {
+		return nil, "", err
+	}
+	if len(events) == 0 {
+		events = []string{"*"}
+	}
+	for _, event := range events {
+		if event != "*" && event != NotificationBatchCompleted && event != NotificationRegistryChanged {
+			return nil, "", fmt.Errorf("unknown notification event: %s", event)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	encodedSecret := "whsec_" + hex.EncodeToString(secret)
+	subscription := &WebhookSubscription{
+		ID:        newNotificationID("whk"),
+		RPID:      rpID,
+		URL:       rawURL,
+		Events:    append([]string(nil), events...),
+		CreatedAt: time.Now(),
+		secret:    encodedSecret,
+	}
+
+	s.mu.Lock()
+	s.subscriptions[subscription.ID] = subscription
+	s.mu.Unlock()
+
+	registered := *subscription
+	return &registered, encodedSecret, nil
+}
+
+// validateWebhookURL checks a webhook is an absolute HTTPS URL, allowing
+// plain HTTP only to loopback hosts
+func validateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("webhook URL must be absolute")
+	}
+	switch parsed.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := parsed.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			return nil
+		}
+		return fmt.Errorf("webhook URL must use https")
+	default:
+		return fmt.Errorf("webhook URL must use https")
+	}
+}
+
+// DeleteWebhook removes one of an RP's webhooks
+func (s *NotificationService) DeleteWebhook(rpID, subscriptionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, exists := s.subscriptions[subscriptionID]
+	if !exists || subscription.RPID != rpID {
+		return fmt.Errorf("webhook not found: %s", subscriptionID)
+	}
+	delete(s.subscriptions, subscriptionID)
+	return nil
+}
+
+// Webhooks returns an RP's webhooks
+func (s *NotificationService) Webhooks(rpID string) []WebhookSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhooks := make([]WebhookSubscription, 0)
+	for _, subscription := range s.subscriptions {
+		if subscription.RPID == rpID {
+			webhooks = append(webhooks, *subscription)
+		}
+	}
+	return webhooks
+}
+
+// Stream returns a channel of an RP's notifications and the function that
+// closes it. A stream that falls behind is closed rather than holding up
+// delivery to the others.
+func (s *NotificationService) Stream(rpID string) (<-chan RPNotification, func()) {
+	ch := make(chan RPNotification, notificationStreamBuffer)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextStream
+	s.nextStream++
+	if s.streams[rpID] == nil {
+		s.streams[rpID] = make(map[int]chan RPNotification)
+	}
+	s.streams[rpID][id] = ch
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if stream, ok := s.streams[rpID][id]; ok {
+			close(stream)
+			delete(s.streams[rpID], id)
+		}
+	}
+}
+
+// Notify sends a notification to the RP's streams and, in the background,
+// to each of its webhooks subscribed to the event
+func (s *NotificationService) Notify(notification RPNotification) {
+	if notification.ID == "" {
+		notification.ID = newNotificationID("ntf")
+	}
+	if notification.Timestamp.IsZero() {
+		notification.Timestamp = time.Now()
+	}
+	body, err := json.Marshal(notification)
+	if err != nil {
+		fmt.Printf("NOTIFICATION WARNING: failed to encode %s for %s: %v\n", notification.Event, notification.RPID, err)
+		return
+	}
+
+	s.mu.Lock()
+	for id, stream := range s.streams[notification.RPID] {
+		select {
+		case stream <- notification:
+		default:
+			close(stream)
+			delete(s.streams[notification.RPID], id)
+		}
+	}
+	var targets []*WebhookSubscription
+	for _, subscription := range s.subscriptions {
+		if subscription.RPID == notification.RPID && subscription.wants(notification.Event) {
+			targets = append(targets, subscription)
+		}
+	}
+	outbox := s.outbox
+	if outbox == nil {
+		s.inFlight += len(targets)
+	}
+	s.mu.Unlock()
+
+	if outbox != nil && len(targets) > 0 {
+		err := s.commitDeliveries(outbox, notification, targets)
+		if err == nil {
+			return
+		}
+		fmt.Printf("NOTIFICATION WARNING: outbox commit failed for %s, delivering directly: %v\n", notification.ID, err)
+		s.mu.Lock()
+		s.inFlight += len(targets)
+		s.mu.Unlock()
+	}
+	for _, subscription := range targets {
+		go s.deliver(subscription, notification, body)
+	}
+}
+
+// commitDeliveries commits a notification's webhook deliveries to the outbox
+// together
+func (s *NotificationService) commitDeliveries(outbox *OutboxDispatcher, notification RPNotification, targets []*WebhookSubscription) error {
+	messages := make([]OutboxMessage, 0, len(targets))
+	for _, subscription := range targets {
+		message, err := NewOutboxMessage(OutboxNotification, outboxNotification{SubscriptionID: subscription.ID, Notification: notification})
+		if err != nil {
+			return err
+		}
+		messages = append(messages, message)
+	}
+	return outbox.Commit(context.Background(), nil, messages...)
+}
+
+// deliverOutbox makes one attempt at a webhook delivery from the outbox. A
+// delivery that may succeed later returns its error to be retried; once it
+// succeeds, is rejected or runs out of attempts it is recorded and audited.
+func (s *NotificationService) deliverOutbox(ctx context.Context, message OutboxMessage) error {
+	var queued outboxNotification
+	if err := json.Unmarshal(message.Payload, &queued); err != nil {
+		return fmt.Errorf("failed to decode notification: %w", err)
+	}
+	s.mu.Lock()
+	subscription, exists := s.subscriptions[queued.SubscriptionID]
+	s.mu.Unlock()
+	if !exists {
+		// The RP deleted the webhook, or it was registered before a restart
+		return nil
+	}
+	body, err := json.Marshal(queued.Notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	delivery := NotificationDelivery{
+		ID:             newNotificationID("dlv"),
+		NotificationID: queued.Notification.ID,
+		SubscriptionID: subscription.ID,
+		RPID:           subscription.RPID,
+		Event:          queued.Notification.Event,
+		URL:            subscription.URL,
+		Attempts:       message.Attempts + 1,
+		CreatedAt:      message.CreatedAt,
+	}
+	statusCode, err := s.post(subscription, queued.Notification.ID, body)
+	delivery.StatusCode = statusCode
+	if err == nil {
+		delivery.Status = DeliveryDelivered
+	} else {
+		delivery.Status, delivery.Error = DeliveryFailed, err.Error()
+		if retryableDelivery(statusCode) && delivery.Attempts < s.maxAttempts {
+			return err
+		}
+	}
+	s.recordDelivery(delivery)
+	return nil
+}
+
+// deliver posts a notification to a webhook, retrying failures that may be
+// temporary, then records and audits the outcome
+func (s *NotificationService) deliver(subscription *WebhookSubscription, notification RPNotification, body []byte) {
+	delivery := NotificationDelivery{
+		ID:             newNotificationID("dlv"),
+		NotificationID: notification.ID,
+		SubscriptionID: subscription.ID,
+		RPID:           subscription.RPID,
+		Event:          notification.Event,
+		URL:            subscription.URL,
+		CreatedAt:      time.Now(),
+	}
+
+	for delivery.Attempts < s.maxAttempts {
+		if delivery.Attempts > 0 {
+			time.Sleep(s.backoff << (delivery.Attempts - 1))
+		}
+		delivery.Attempts++
+
+		statusCode, err := s.post(subscription, notification.ID, body)
+		delivery.StatusCode = statusCode
+		if err == nil {
+			delivery.Status, delivery.Error = DeliveryDelivered, ""
+			break
+		}
+		delivery.Status, delivery.Error = DeliveryFailed, err.Error()
+		if !retryableDelivery(statusCode) {
+			break
+		}
+	}
+	s.recordDelivery(delivery)
+
+	s.mu.Lock()
+	s.inFlight--
+	s.mu.Unlock()
+}
+
+// recordDelivery completes a delivery, keeping and auditing its outcome
+func (s *NotificationService) recordDelivery(delivery NotificationDelivery) {
+	completedAt := time.Now()
+	delivery.CompletedAt = &completedAt
+
+	if delivery.Status == DeliveryFailed {
+		fmt.Printf("NOTIFICATION WARNING: failed to deliver %s to %s after %d attempts: %s\n",
+			delivery.NotificationID, delivery.URL, delivery.Attempts, delivery.Error)
+	}
+	if s.auditService != nil {
+		s.auditService.LogNotificationDelivery(context.Background(), &delivery)
+	}
+
+	s.mu.Lock()
+	s.deliveries = append(s.deliveries, delivery)
+	if len(s.deliveries) > maxNotificationDeliveries {
+		s.deliveries = s.deliveries[len(s.deliveries)-maxNotificationDeliveries:]
+	}
+	s.mu.Unlock()
+}
+
+// post sends one delivery attempt, returning the webhook's status code
+func (s *NotificationService) post(subscription *WebhookSubscription, notificationID string, body []byte) (int, error) {
+	req, err := http.NewRequest("POST", subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Pavilion-Signature", SignNotification(subscription.secret, body))
+	req.Header.Set("X-Pavilion-Notification-ID", notificationID)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryableDelivery reports whether a failed attempt may succeed later:
+// connection errors, throttling and server errors are retried, while other
+// client errors mean the webhook rejected the notification
+func retryableDelivery(statusCode int) bool {
+	return statusCode == 0 || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// Deliveries returns an RP's recent webhook deliveries, oldest first
+func (s *NotificationService) Deliveries(rpID string) []NotificationDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries := make([]NotificationDelivery, 0)
+	for _, delivery := range s.deliveries {
+		if delivery.RPID == rpID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries
+}
+
+// PendingDeliveries returns the webhook deliveries still being attempted
+func (s *NotificationService) PendingDeliveries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inFlight
+}
+
+// NotifyBatchCompleted notifies the RP that submitted a batch once it
+// completes; register it with the batch tracker's OnComplete
+func (s *NotificationService) NotifyBatchCompleted(rpID string, progress BatchProgress) {
+	if rpID == "" {
+		return
+	}
+	s.Notify(RPNotification{
+		Event:      NotificationBatchCompleted,
+		RPID:       rpID,
+		ResourceID: progress.BatchID,
+		Data: map[string]interface{}{
+			"status_url": "/api/v1/verify/batch/" + progress.BatchID,
+			"total":      progress.Total,
+			"succeeded":  progress.Succeeded,
+			"failed":     progress.Failed,
+		},
+	})
+}
+
+// SignNotification returns the X-Pavilion-Signature value for a body, so RPs
+// can check deliveries against their webhook secret
+func SignNotification(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newNotificationID(prefix string) string {
+	idBytes := make([]byte, 8)
+	rand.Read(idBytes)
+	return fmt.Sprintf("%s_%s", prefix, hex.EncodeToString(idBytes))
+}
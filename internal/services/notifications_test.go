@@ -0,0 +1,138 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func newTestNotificationService() *NotificationService {
+	return NewNotificationService(&config.Config{
+		NotificationMaxAttempts:  3,
+		NotificationRetryBackoff: time.Millisecond,
+		NotificationTimeout:      time.Second,
+	}, nil)
+}
+
+func waitForDeliveries(t *testing.T, service *NotificationService, rpID string, count int) []NotificationDelivery {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		if deliveries := service.Deliveries(rpID); len(deliveries) >= count && service.PendingDeliveries() == 0 {
+			return deliveries
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d deliveries, got %+v", count, service.Deliveries(rpID))
+	return nil
+}
+
+func TestNotificationService_SignsAndRetriesWebhooks(t *testing.T) {
+	var calls int64
+	var signature string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		signature = r.Header.Get("X-Pavilion-Signature")
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	service := newTestNotificationService()
+	webhook, secret, err := service.RegisterWebhook("rp-1", server.URL, []string{NotificationBatchCompleted})
+	if err != nil {
+		t.Fatalf("RegisterWebhook failed: %v", err)
+	}
+	if len(service.Webhooks("rp-1")) != 1 || len(service.Webhooks("rp-2")) != 0 {
+		t.Error("Expected webhooks to be listed only for their RP")
+	}
+
+	service.NotifyBatchCompleted("rp-1", BatchProgress{BatchID: "batch_1", Total: 2, Succeeded: 2})
+	service.NotifyBatchCompleted("rp-2", BatchProgress{BatchID: "batch_2", Total: 1, Succeeded: 1})
+
+	deliveries := waitForDeliveries(t, service, "rp-1", 1)
+	delivery := deliveries[0]
+	if delivery.Status != DeliveryDelivered || delivery.Attempts != 2 || delivery.SubscriptionID != webhook.ID {
+		t.Errorf("Expected the delivery to succeed on the retry, got %+v", delivery)
+	}
+	if signature != SignNotification(secret, body) {
+		t.Errorf("Expected the body signed with the webhook secret, got %s", signature)
+	}
+	if calls != 2 {
+		t.Errorf("Expected other RPs' notifications not to be delivered, got %d calls", calls)
+	}
+}
+
+func TestNotificationService_StopsOnRejection(t *testing.T) {
+	var calls int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	service := newTestNotificationService()
+	service.RegisterWebhook("rp-1", server.URL, nil)
+	service.NotifyBatchCompleted("rp-1", BatchProgress{BatchID: "batch_1", Total: 1, Failed: 1})
+
+	delivery := waitForDeliveries(t, service, "rp-1", 1)[0]
+	if delivery.Status != DeliveryFailed || delivery.Attempts != 1 || delivery.StatusCode != http.StatusGone {
+		t.Errorf("Expected a rejected delivery not to be retried, got %+v", delivery)
+	}
+}
+
+func TestNotificationService_RegisterWebhookValidation(t *testing.T) {
+	service := newTestNotificationService()
+	for _, url := range []string{"http://rp.example.com/hooks", "ftp://rp.example.com", "/hooks"} {
+		if _, _, err := service.RegisterWebhook("rp-1", url, nil); err == nil {
+			t.Errorf("Expected %s to be rejected", url)
+		}
+	}
+	if _, _, err := service.RegisterWebhook("rp-1", "https://rp.example.com/hooks", []string{"user.deleted"}); err == nil {
+		t.Error("Expected an unknown event to be rejected")
+	}
+
+	webhook, _, err := service.RegisterWebhook("rp-1", "https://rp.example.com/hooks", nil)
+	if err != nil {
+		t.Fatalf("RegisterWebhook failed: %v", err)
+	}
+	if err := service.DeleteWebhook("rp-2", webhook.ID); err == nil {
+		t.Error("Expected RPs not to delete other RPs' webhooks")
+	}
+	if err := service.DeleteWebhook("rp-1", webhook.ID); err != nil || len(service.Webhooks("rp-1")) != 0 {
+		t.Errorf("Expected the webhook to be deleted, got %v", err)
+	}
+}
+
+func TestNotificationService_Streams(t *testing.T) {
+	service := newTestNotificationService()
+	stream, cancel := service.Stream("rp-1")
+	defer cancel()
+
+	service.NotifyBatchCompleted("rp-2", BatchProgress{BatchID: "batch_2"})
+	service.NotifyBatchCompleted("rp-1", BatchProgress{BatchID: "batch_1", Total: 1, Succeeded: 1})
+
+	select {
+	case notification := <-stream:
+		if notification.ResourceID != "batch_1" || notification.Event != NotificationBatchCompleted || notification.ID == "" {
+			t.Errorf("Unexpected notification: %+v", notification)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the notification on the RP's stream")
+	}
+
+	// A stream that stops reading is dropped instead of blocking delivery
+	for i := 0; i <= notificationStreamBuffer; i++ {
+		service.NotifyBatchCompleted("rp-1", BatchProgress{BatchID: "batch_1"})
+	}
+	for range stream {
+	}
+}
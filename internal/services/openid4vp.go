@@ -0,0 +1,689 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// ErrPresentationNotFound is returned for unknown or foreign presentation requests
+var ErrPresentationNotFound = errors.New("presentation request not found")
+
+// PresentationState is the state of an OpenID4VP presentation request
+type PresentationState string
+
+const (
+	PresentationPending  PresentationState = "pending"
+	PresentationVerified PresentationState = "verified"
+	PresentationFailed   PresentationState = "failed"
+	PresentationExpired  PresentationState = "expired"
+)
+
+// SD-JWT formats wallets may submit; "vc+sd-jwt" is the pre-1.0 media type
+var presentationFormats = map[string]bool{"dc+sd-jwt": true, "vc+sd-jwt": true}
+
+// PresentationDefinition is a DIF Presentation Exchange definition telling
+// the wallet which claims to present
+type PresentationDefinition struct {
+	ID               string            `json:"id"`
+	InputDescriptors []InputDescriptor `json:"input_descriptors"`
+}
+
+// InputDescriptor describes one credential the wallet must present
+type InputDescriptor struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name,omitempty"`
+	Purpose     string                 `json:"purpose,omitempty"`
+	Format      map[string]interface{} `json:"format,omitempty"`
+	Constraints InputConstraints       `json:"constraints"`
+}
+
+// InputConstraints lists the claims an input descriptor asks for
+type InputConstraints struct {
+	LimitDisclosure string       `json:"limit_disclosure,omitempty"`
+	Fields          []InputField `json:"fields"`
+}
+
+// InputField selects a claim by JSONPath. Filter is a JSON Schema subset:
+// type, const, enum, minimum and maximum.
+type InputField struct {
+	Path     []string               `json:"path"`
+	Filter   map[string]interface{} `json:"filter,omitempty"`
+	Optional bool                   `json:"optional,omitempty"`
+}
+
+// PresentationSubmission maps the wallet's vp_token to input descriptors
+type PresentationSubmission struct {
+	ID            string                 `json:"id"`
+	DefinitionID  string                 `json:"definition_id"`
+	DescriptorMap []SubmissionDescriptor `json:"descriptor_map"`
+}
+
+// SubmissionDescriptor locates the presentation for one input descriptor
+type SubmissionDescriptor struct {
+	ID     string `json:"id"`
+	Format string `json:"format"`
+	Path   string `json:"path"`
+}
+
+// PresentationRequest is an RP's request to collect a presentation. Without
+// a presentation definition one is built from the claim type's attributes.
+type PresentationRequest struct {
+	ClaimType              string                  `json:"claim_type"`
+	Attributes             []string                `json:"attributes,omitempty"`
+	PresentationDefinition *PresentationDefinition `json:"presentation_definition,omitempty"`
+}
+
+// AuthorizationRequest is the OpenID4VP request the wallet receives, using
+// the direct_post response mode
+type AuthorizationRequest struct {
+	ResponseType           string                  `json:"response_type"`
+	ResponseMode           string                  `json:"response_mode"`
+	ClientID               string                  `json:"client_id"`
+	ResponseURI            string                  `json:"response_uri"`
+	Nonce                  string                  `json:"nonce"`
+	State                  string                  `json:"state"`
+	PresentationDefinition *PresentationDefinition `json:"presentation_definition"`
+}
+
+// PresentationSession tracks a presentation request from creation until the
+// wallet's response has been verified. Claims hold the presented values
+// reduced to the claim type's disclosure levels.
+type PresentationSession struct {
+	ID                   string                     `json:"id"`
+	RPID                 string                     `json:"rp_id"`
+	ClaimType            string                     `json:"claim_type"`
+	State                PresentationState          `json:"state"`
+	AuthorizationRequest AuthorizationRequest       `json:"authorization_request"`
+	RequestURI           string                     `json:"request_uri"`
+	Disclosures          map[string]DisclosureLevel `json:"disclosures"`
+	Issuer               string                     `json:"issuer,omitempty"`
+	Claims               map[string]interface{}     `json:"claims,omitempty"`
+	Proofs               map[string]interface{}     `json:"proofs,omitempty"`
+	Error                string                     `json:"error,omitempty"`
+	CreatedAt            time.Time                  `json:"created_at"`
+	ExpiresAt            time.Time                  `json:"expires_at"`
+	CompletedAt          *time.Time                 `json:"completed_at,omitempty"`
+}
+
+// OpenID4VPService lets wallet holders present SD-JWT credentials to the
+// broker on behalf of an RP
+type OpenID4VPService struct {
+	config        *config.Config
+	schemas       *ClaimSchemaRegistry
+	authorization *AuthorizationService
+	disclosure    *SelectiveDisclosureService
+	ttl           time.Duration
+
+	// didResolver resolves the keys of trusted issuers identified by DID
+	didResolver *DIDResolver
+	trustedDIDs map[string]bool
+	// statusLists checks credentials whose issuer JWT has a credentialStatus
+	statusLists *StatusListService
+
+	mu       sync.Mutex
+	sessions map[string]*PresentationSession
+	issuers  map[string]*ecdsa.PublicKey
+}
+
+// NewOpenID4VPService creates a new OpenID4VP service
+func NewOpenID4VPService(cfg *config.Config, schemas *ClaimSchemaRegistry, authorization *AuthorizationService) *OpenID4VPService {
+	ttl := cfg.OpenID4VPRequestTTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+
+	service := &OpenID4VPService{
+		config:        cfg,
+		schemas:       schemas,
+		authorization: authorization,
+		disclosure:    NewSelectiveDisclosureService(NewSelectiveDisclosureConfig(true, false, cfg.HashSalt)),
+		ttl:           ttl,
+		sessions:      make(map[string]*PresentationSession),
+		issuers:       make(map[string]*ecdsa.PublicKey),
+		trustedDIDs:   make(map[string]bool),
+	}
+	for _, did := range cfg.OpenID4VPTrustedDIDs {
+		service.trustedDIDs[did] = true
+	}
+
+	if cfg.OpenID4VPTrustedIssuersFile != "" {
+		if err := service.LoadTrustedIssuersFile(cfg.OpenID4VPTrustedIssuersFile); err != nil {
+			fmt.Printf("OPENID4VP WARNING: %v; no presentations will be accepted\n", err)
+		}
+	}
+
+	return service
+}
+
+// AddTrustedIssuer accepts SD-JWTs signed by the issuer's key
+func (s *OpenID4VPService) AddTrustedIssuer(issuer string, key *ecdsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.issuers[issuer] = key
+}
+
+// SetDIDResolver resolves the keys of issuers listed in
+// OPENID4VP_TRUSTED_DIDS, so they can rotate keys through their DID document
+func (s *OpenID4VPService) SetDIDResolver(resolver *DIDResolver) {
+	s.didResolver = resolver
+}
+
+// SetStatusListService enables revocation checks of presented credentials
+func (s *OpenID4VPService) SetStatusListService(statusLists *StatusListService) {
+	s.statusLists = statusLists
+}
+
+// TrustedIssuersFile is the on-disk format of the issuers whose SD-JWTs are
+// accepted, mapping issuer identifiers to P-256 public JWKs
+type TrustedIssuersFile struct {
+	Issuers map[string]map[string]interface{} `json:"issuers"`
+}
+
+// LoadTrustedIssuersFile adds the issuers of a file. The file is applied only
+// if every key is valid.
+func (s *OpenID4VPService) LoadTrustedIssuersFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read trusted issuers file: %w", err)
+	}
+
+	var file TrustedIssuersFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse trusted issuers file: %w", err)
+	}
+
+	keys := make(map[string]*ecdsa.PublicKey, len(file.Issuers))
+	for issuer, jwk := range file.Issuers {
+		key, err := ecdsaPublicKeyFromJWK(jwk)
+		if err != nil {
+			return fmt.Errorf("trusted issuer %s: %w", issuer, err)
+		}
+		keys[issuer] = key
+	}
+
+	for issuer, key := range keys {
+		s.AddTrustedIssuer(issuer, key)
+	}
+	return nil
+}
+
+// CreateRequest starts a presentation request for an RP. The presentation
+// definition's fields are mapped to the claim type's attributes, which fix
+// the disclosure level applied to each presented value.
+func (s *OpenID4VPService) CreateRequest(rpID string, request PresentationRequest) (*PresentationSession, error) {
+	schema, exists := s.schemas.Get(request.ClaimType)
+	if !exists {
+		return nil, fmt.Errorf("unknown claim type: %s", request.ClaimType)
+	}
+	if s.authorization != nil && !s.authorization.AllowsClaimType(rpID, request.ClaimType) {
+		return nil, fmt.Errorf("claim type %s is not permitted for this RP", request.ClaimType)
+	}
+	now := time.Now()
+	if schema.LifecycleState(now) == ClaimStateRetired {
+		return nil, fmt.Errorf("claim type %s is retired", request.ClaimType)
+	}
+
+	definition := request.PresentationDefinition
+	if definition == nil {
+		var err error
+		if definition, err = defaultPresentationDefinition(schema, request.Attributes); err != nil {
+			return nil, err
+		}
+	}
+	disclosures, err := presentationDisclosures(schema, definition)
+	if err != nil {
+		return nil, err
+	}
+
+	id := "vp_" + randomHex(16)
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+
+	session := &PresentationSession{
+		ID:        id,
+		RPID:      rpID,
+		ClaimType: schema.ClaimType,
+		State:     PresentationPending,
+		AuthorizationRequest: AuthorizationRequest{
+			ResponseType:           "vp_token",
+			ResponseMode:           "direct_post",
+			ClientID:               s.clientID(),
+			ResponseURI:            s.responseURI(),
+			Nonce:                  base64.RawURLEncoding.EncodeToString(nonce),
+			State:                  id,
+			PresentationDefinition: definition,
+		},
+		Disclosures: disclosures,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.ttl),
+	}
+	session.RequestURI = openID4VPRequestURI(session.AuthorizationRequest)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(now)
+	s.sessions[id] = session
+	return s.copySession(session), nil
+}
+
+// GetRequest returns one of an RP's presentation requests
+func (s *OpenID4VPService) GetRequest(rpID, id string) (*PresentationSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists || session.RPID != rpID {
+		return nil, ErrPresentationNotFound
+	}
+	s.expireLocked(session, time.Now())
+	return s.copySession(session), nil
+}
+
+// SubmitResponse verifies a wallet's direct_post response. Each request
+// accepts a single response; a failed one cannot be retried.
+func (s *OpenID4VPService) SubmitResponse(ctx context.Context, state, vpToken string, submission *PresentationSubmission) (*PresentationSession, error) {
+	// DID resolution and status lists may reach the network, so find the
+	// issuer key and check revocation first, within the request's deadline
+	issuer, key, keyErr := s.issuerKey(ctx, vpToken)
+	if keyErr == nil {
+		keyErr = s.checkStatus(ctx, vpToken, issuer, key)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[state]
+	if !exists {
+		return nil, ErrPresentationNotFound
+	}
+	now := time.Now()
+	s.expireLocked(session, now)
+	if session.State != PresentationPending {
+		return nil, fmt.Errorf("presentation request is %s", session.State)
+	}
+
+	var claims map[string]interface{}
+	err := keyErr
+	if err == nil {
+		claims, err = s.verifyPresentation(session, vpToken, submission, key)
+	}
+	completed := now.UTC()
+	session.CompletedAt = &completed
+	if err != nil {
+		session.State = PresentationFailed
+		session.Error = err.Error()
+		return nil, err
+	}
+
+	session.Issuer = issuer
+	session.Claims = make(map[string]interface{})
+	session.Proofs = make(map[string]interface{})
+	for attribute, level := range session.Disclosures {
+		value, presented := claims[attribute]
+		if !presented {
+			continue
+		}
+		// JSON numbers decode as float64; whole numbers get integer ranges
+		if f, ok := value.(float64); ok && f == math.Trunc(f) {
+			value = int(f)
+		}
+		disclosed, proof, err := s.disclosure.processClaim(attribute, value, Claim{Name: attribute, Value: value, Disclosure: level})
+		if err != nil {
+			session.State = PresentationFailed
+			session.Error = err.Error()
+			return nil, err
+		}
+		if disclosed != nil {
+			session.Claims[attribute] = disclosed
+		}
+		if proof != nil {
+			session.Proofs[attribute] = proof
+		}
+	}
+	session.State = PresentationVerified
+
+	return s.copySession(session), nil
+}
+
+// issuerKey returns the issuer of an SD-JWT and its key, from the trusted
+// issuers or, for trusted DIDs, the issuer's DID document
+func (s *OpenID4VPService) issuerKey(ctx context.Context, vpToken string) (string, *ecdsa.PublicKey, error) {
+	issuerJWT, _, _, err := splitSDJWT(vpToken)
+	if err != nil {
+		return "", nil, err
+	}
+	unverified := jwt.MapClaims{}
+	token, _, err := jwt.NewParser().ParseUnverified(issuerJWT, unverified)
+	if err != nil {
+		return "", nil, fmt.Errorf("malformed SD-JWT: %w", err)
+	}
+	issuer, _ := unverified["iss"].(string)
+
+	s.mu.Lock()
+	key, trusted := s.issuers[issuer]
+	s.mu.Unlock()
+	if trusted {
+		return issuer, key, nil
+	}
+	if !s.trustedDIDs[issuer] || s.didResolver == nil {
+		return "", nil, fmt.Errorf("untrusted credential issuer: %q", issuer)
+	}
+
+	// The kid names the verification method, as a DID URL of the issuer or
+	// a fragment
+	didURL := issuer
+	if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+		if !strings.HasPrefix(kid, "did:") {
+			kid = issuer + "#" + strings.TrimPrefix(kid, "#")
+		}
+		if !strings.HasPrefix(kid, issuer+"#") {
+			return "", nil, fmt.Errorf("key %s does not belong to issuer %s", kid, issuer)
+		}
+		didURL = kid
+	}
+	resolved, err := s.didResolver.ResolveKey(ctx, didURL)
+	if err != nil {
+		return "", nil, err
+	}
+	ecKey, ok := resolved.(*ecdsa.PublicKey)
+	if !ok {
+		return "", nil, fmt.Errorf("issuer key must be a P-256 key")
+	}
+	return issuer, ecKey, nil
+}
+
+// checkStatus rejects SD-JWTs whose credentialStatus entry is revoked or
+// cannot be checked. The issuer JWT is verified first so the status list URL
+// comes from the issuer.
+func (s *OpenID4VPService) checkStatus(ctx context.Context, vpToken, issuer string, key *ecdsa.PublicKey) error {
+	issuerJWT, _, _, err := splitSDJWT(vpToken)
+	if err != nil {
+		return err
+	}
+	payload := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(issuerJWT, payload, func(token *jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}), jwt.WithLeeway(ClockSkewTolerance(s.config))); err != nil {
+		return fmt.Errorf("invalid SD-JWT signature: %w", err)
+	}
+
+	raw, hasStatus := payload["credentialStatus"]
+	if !hasStatus {
+		return nil
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	var entry models.CredentialStatus
+	if err := json.Unmarshal(encoded, &entry); err != nil {
+		return fmt.Errorf("malformed credentialStatus: %w", err)
+	}
+	if s.statusLists == nil {
+		return fmt.Errorf("credential has a status entry but status checking is not configured")
+	}
+
+	credentialID, _ := payload["jti"].(string)
+	result, err := s.statusLists.Check(ctx, credentialID, issuer, &entry)
+	if err != nil {
+		return fmt.Errorf("credential status could not be checked: %w", err)
+	}
+	if result.Revoked() {
+		return fmt.Errorf("credential is %s", result.Status)
+	}
+	return nil
+}
+
+// verifyPresentation checks the submission against the request's definition
+// and returns the disclosed claims of the SD-JWT
+func (s *OpenID4VPService) verifyPresentation(session *PresentationSession, vpToken string, submission *PresentationSubmission, key *ecdsa.PublicKey) (map[string]interface{}, error) {
+	definition := session.AuthorizationRequest.PresentationDefinition
+	if submission == nil {
+		return nil, fmt.Errorf("presentation_submission is required")
+	}
+	if submission.DefinitionID != definition.ID {
+		return nil, fmt.Errorf("presentation_submission does not answer definition %s", definition.ID)
+	}
+	submitted := make(map[string]bool, len(submission.DescriptorMap))
+	for _, descriptor := range submission.DescriptorMap {
+		if !presentationFormats[descriptor.Format] {
+			return nil, fmt.Errorf("unsupported presentation format: %s", descriptor.Format)
+		}
+		// The vp_token carries a single SD-JWT presentation
+		if descriptor.Path != "$" {
+			return nil, fmt.Errorf("unsupported descriptor path: %s", descriptor.Path)
+		}
+		submitted[descriptor.ID] = true
+	}
+
+	auth := session.AuthorizationRequest
+	claims, err := VerifySDJWT(vpToken, key, auth.ClientID, auth.Nonce, ClockSkewTolerance(s.config))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, descriptor := range definition.InputDescriptors {
+		if !submitted[descriptor.ID] {
+			return nil, fmt.Errorf("no presentation submitted for input descriptor %s", descriptor.ID)
+		}
+		for _, field := range descriptor.Constraints.Fields {
+			attribute := fieldAttribute(field.Path)
+			value, presented := claims[attribute]
+			if !presented {
+				if field.Optional {
+					continue
+				}
+				return nil, fmt.Errorf("required claim %s was not disclosed", attribute)
+			}
+			if err := matchFieldFilter(value, field.Filter); err != nil {
+				return nil, fmt.Errorf("claim %s %w", attribute, err)
+			}
+		}
+	}
+
+	return claims, nil
+}
+
+func (s *OpenID4VPService) clientID() string {
+	if s.config.OpenID4VPClientID != "" {
+		return s.config.OpenID4VPClientID
+	}
+	return s.config.Issuer
+}
+
+func (s *OpenID4VPService) responseURI() string {
+	if s.config.OpenID4VPResponseURI != "" {
+		return s.config.OpenID4VPResponseURI
+	}
+	return strings.TrimSuffix(s.config.CoreBrokerURL, "/") + "/openid4vp/response"
+}
+
+// expireLocked marks a pending session expired once its TTL has passed
+func (s *OpenID4VPService) expireLocked(session *PresentationSession, now time.Time) {
+	if session.State == PresentationPending && now.After(session.ExpiresAt) {
+		session.State = PresentationExpired
+	}
+}
+
+// pruneLocked drops sessions that expired over an hour ago, giving RPs time
+// to collect results
+func (s *OpenID4VPService) pruneLocked(now time.Time) {
+	for id, session := range s.sessions {
+		if now.After(session.ExpiresAt.Add(time.Hour)) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+func (s *OpenID4VPService) copySession(session *PresentationSession) *PresentationSession {
+	copied := *session
+	return &copied
+}
+
+// defaultPresentationDefinition asks for the claim type's disclosable
+// attributes, or the given subset of them
+func defaultPresentationDefinition(schema *ClaimTypeSchema, attributes []string) (*PresentationDefinition, error) {
+	if len(attributes) == 0 {
+		for attribute, level := range schema.Attributes {
+			if level != DisclosureLevelNone {
+				attributes = append(attributes, attribute)
+			}
+		}
+		sort.Strings(attributes)
+	}
+	if len(attributes) == 0 {
+		return nil, fmt.Errorf("claim type %s has no disclosable attributes", schema.ClaimType)
+	}
+
+	fields := make([]InputField, 0, len(attributes))
+	for _, attribute := range attributes {
+		fields = append(fields, InputField{Path: []string{"$." + attribute}})
+	}
+
+	return &PresentationDefinition{
+		ID: schema.ClaimType,
+		InputDescriptors: []InputDescriptor{{
+			ID:      schema.ClaimType,
+			Purpose: schema.Description,
+			Format: map[string]interface{}{
+				"dc+sd-jwt": map[string]interface{}{
+					"sd-jwt_alg_values": []string{"ES256"},
+					"kb-jwt_alg_values": []string{"ES256"},
+				},
+			},
+			Constraints: InputConstraints{LimitDisclosure: "required", Fields: fields},
+		}},
+	}, nil
+}
+
+// presentationDisclosures maps each field of a definition to a claim type
+// attribute and its disclosure level
+func presentationDisclosures(schema *ClaimTypeSchema, definition *PresentationDefinition) (map[string]DisclosureLevel, error) {
+	if definition.ID == "" || len(definition.InputDescriptors) == 0 {
+		return nil, fmt.Errorf("presentation definition needs an id and at least one input descriptor")
+	}
+
+	disclosures := make(map[string]DisclosureLevel)
+	for _, descriptor := range definition.InputDescriptors {
+		if descriptor.ID == "" || len(descriptor.Constraints.Fields) == 0 {
+			return nil, fmt.Errorf("input descriptors need an id and at least one field")
+		}
+		for _, field := range descriptor.Constraints.Fields {
+			attribute := fieldAttribute(field.Path)
+			level, exists := schema.Attributes[attribute]
+			if !exists {
+				return nil, fmt.Errorf("field %v is not a %s attribute", field.Path, schema.ClaimType)
+			}
+			if level == DisclosureLevelNone {
+				return nil, fmt.Errorf("attribute %s is never disclosed", attribute)
+			}
+			disclosures[attribute] = level
+		}
+	}
+	return disclosures, nil
+}
+
+// fieldAttribute returns the claim a field selects. Paths may address the
+// claim directly ($.age, $['age']) or under credentialSubject.
+func fieldAttribute(paths []string) string {
+	for _, path := range paths {
+		path = strings.TrimPrefix(path, "$")
+		path = strings.TrimPrefix(path, ".credentialSubject")
+		switch {
+		case strings.HasPrefix(path, "['") && strings.HasSuffix(path, "']"):
+			path = path[2 : len(path)-2]
+		case strings.HasPrefix(path, "."):
+			path = path[1:]
+		default:
+			continue
+		}
+		if path != "" && !strings.ContainsAny(path, ".[]*") {
+			return path
+		}
+	}
+	return ""
+}
+
+// matchFieldFilter checks a presented value against a field's filter
+func matchFieldFilter(value interface{}, filter map[string]interface{}) error {
+	if len(filter) == 0 {
+		return nil
+	}
+
+	if expected, ok := filter["type"].(string); ok {
+		var matches bool
+		switch v := value.(type) {
+		case string:
+			matches = expected == "string"
+		case bool:
+			matches = expected == "boolean"
+		case float64:
+			matches = expected == "number" || (expected == "integer" && v == math.Trunc(v))
+		}
+		if !matches {
+			return fmt.Errorf("is not of type %s", expected)
+		}
+	}
+	if expected, ok := filter["const"]; ok && !reflect.DeepEqual(value, expected) {
+		return fmt.Errorf("does not match the requested value")
+	}
+	if enum, ok := filter["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if reflect.DeepEqual(value, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("is not one of the requested values")
+		}
+	}
+	number, isNumber := value.(float64)
+	if minimum, ok := filter["minimum"].(float64); ok && (!isNumber || number < minimum) {
+		return fmt.Errorf("is below the requested minimum")
+	}
+	if maximum, ok := filter["maximum"].(float64); ok && (!isNumber || number > maximum) {
+		return fmt.Errorf("is above the requested maximum")
+	}
+	return nil
+}
+
+// openID4VPRequestURI encodes an authorization request as a wallet
+// invocation URL, e.g. for a QR code
+func openID4VPRequestURI(request AuthorizationRequest) string {
+	definition, _ := json.Marshal(request.PresentationDefinition)
+	values := url.Values{}
+	values.Set("response_type", request.ResponseType)
+	values.Set("response_mode", request.ResponseMode)
+	values.Set("client_id", request.ClientID)
+	values.Set("response_uri", request.ResponseURI)
+	values.Set("nonce", request.Nonce)
+	values.Set("state", request.State)
+	values.Set("presentation_definition", string(definition))
+	return "openid4vp://?" + values.Encode()
+}
+
+func randomHex(n int) string {
+	bytes := make([]byte, n)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// walletPresentation issues an SD-JWT to a holder and presents the given
+// claims for an authorization request
+func walletPresentation(t *testing.T, issuer *SelectiveDisclosureService, credential map[string]interface{}, disclose []string, request AuthorizationRequest) string {
+	t.Helper()
+	holderKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	claims := make(map[string]Claim)
+	for name := range credential {
+		claims[name] = Claim{Name: name, Disclosure: DisclosureLevelFull}
+	}
+	issued, err := issuer.ExtractClaims(credential, SelectiveDisclosureRequest{
+		CredentialID: "cred-1",
+		Purpose:      "wallet issuance",
+		RequesterID:  "wallet",
+		Format:       DisclosureFormatSDJWT,
+		HolderJWK:    ECDSAPublicJWK(&holderKey.PublicKey),
+		Claims:       claims,
+	})
+	if err != nil {
+		t.Fatalf("ExtractClaims failed: %v", err)
+	}
+
+	presentation, err := PresentSDJWT(issued.SDJWT, disclose, holderKey, request.ClientID, request.Nonce)
+	if err != nil {
+		t.Fatalf("PresentSDJWT failed: %v", err)
+	}
+	return presentation
+}
+
+func newOpenID4VPTestService(t *testing.T) (*OpenID4VPService, *SelectiveDisclosureService) {
+	t.Helper()
+	issuer, issuerKey := newSDJWTTestService(t)
+	service := NewOpenID4VPService(&config.Config{Issuer: "https://broker.example", CoreBrokerURL: "https://broker.example"}, NewClaimSchemaRegistry(), nil)
+	service.AddTrustedIssuer("https://broker.example", &issuerKey.PublicKey)
+	return service, issuer
+}
+
+func TestOpenID4VPService_PresentationFlow(t *testing.T) {
+	service, issuer := newOpenID4VPTestService(t)
+
+	session, err := service.CreateRequest("rp-1", PresentationRequest{ClaimType: "age_verification"})
+	if err != nil {
+		t.Fatalf("CreateRequest failed: %v", err)
+	}
+	auth := session.AuthorizationRequest
+	if auth.ResponseMode != "direct_post" || auth.ResponseURI != "https://broker.example/openid4vp/response" || auth.Nonce == "" {
+		t.Errorf("Unexpected authorization request: %+v", auth)
+	}
+	if !strings.HasPrefix(session.RequestURI, "openid4vp://?") || session.Disclosures["age"] != DisclosureLevelRange {
+		t.Errorf("Unexpected session: %+v", session)
+	}
+	if len(auth.PresentationDefinition.InputDescriptors[0].Constraints.Fields) != 2 {
+		t.Errorf("Expected date_of_birth not to be requested, got %+v", auth.PresentationDefinition)
+	}
+
+	credential := map[string]interface{}{"age": 34, "age_over_threshold": true, "date_of_birth": "1992-01-01"}
+	vpToken := walletPresentation(t, issuer, credential, []string{"age", "age_over_threshold"}, auth)
+	submission := &PresentationSubmission{
+		ID:            "sub-1",
+		DefinitionID:  auth.PresentationDefinition.ID,
+		DescriptorMap: []SubmissionDescriptor{{ID: "age_verification", Format: "dc+sd-jwt", Path: "$"}},
+	}
+
+	result, err := service.SubmitResponse(context.Background(), auth.State, vpToken, submission)
+	if err != nil {
+		t.Fatalf("SubmitResponse failed: %v", err)
+	}
+	// Presented values are reduced to the claim type's disclosure levels
+	if result.State != PresentationVerified || result.Claims["age"] == 34 || result.Claims["age"] == nil || result.Proofs["age_over_threshold"] == nil {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	if _, err := service.SubmitResponse(context.Background(), auth.State, vpToken, submission); err == nil {
+		t.Error("Expected a replayed response to be rejected")
+	}
+	if _, err := service.GetRequest("rp-2", session.ID); err != ErrPresentationNotFound {
+		t.Errorf("Expected other RPs not to see the request, got %v", err)
+	}
+}
+
+func TestOpenID4VPService_RejectsInvalidPresentations(t *testing.T) {
+	service, issuer := newOpenID4VPTestService(t)
+	definition := &PresentationDefinition{
+		ID: "adult-check",
+		InputDescriptors: []InputDescriptor{{
+			ID: "age",
+			Constraints: InputConstraints{Fields: []InputField{
+				{Path: []string{"$.credentialSubject.age"}, Filter: map[string]interface{}{"type": "integer", "minimum": 18.0}},
+			}},
+		}},
+	}
+	submission := &PresentationSubmission{DefinitionID: "adult-check", DescriptorMap: []SubmissionDescriptor{{ID: "age", Format: "dc+sd-jwt", Path: "$"}}}
+
+	cases := map[string]func(auth AuthorizationRequest) (string, *PresentationSubmission){
+		"filter not met": func(auth AuthorizationRequest) (string, *PresentationSubmission) {
+			return walletPresentation(t, issuer, map[string]interface{}{"age": 16}, []string{"age"}, auth), submission
+		},
+		"claim withheld": func(auth AuthorizationRequest) (string, *PresentationSubmission) {
+			return walletPresentation(t, issuer, map[string]interface{}{"age": 30, "name": "x"}, []string{"name"}, auth), submission
+		},
+		"wrong nonce": func(auth AuthorizationRequest) (string, *PresentationSubmission) {
+			auth.Nonce = "other"
+			return walletPresentation(t, issuer, map[string]interface{}{"age": 30}, []string{"age"}, auth), submission
+		},
+		"untrusted issuer": func(auth AuthorizationRequest) (string, *PresentationSubmission) {
+			other, _ := newSDJWTTestService(t)
+			other.sdJWTSigner.Issuer = "https://rogue.example"
+			return walletPresentation(t, other, map[string]interface{}{"age": 30}, []string{"age"}, auth), submission
+		},
+		"missing submission": func(auth AuthorizationRequest) (string, *PresentationSubmission) {
+			return walletPresentation(t, issuer, map[string]interface{}{"age": 30}, []string{"age"}, auth), nil
+		},
+	}
+	for name, present := range cases {
+		session, err := service.CreateRequest("rp-1", PresentationRequest{ClaimType: "age_verification", PresentationDefinition: definition})
+		if err != nil {
+			t.Fatalf("CreateRequest failed: %v", err)
+		}
+		vpToken, sub := present(session.AuthorizationRequest)
+		if _, err := service.SubmitResponse(context.Background(), session.ID, vpToken, sub); err == nil {
+			t.Errorf("%s: expected the presentation to be rejected", name)
+		}
+		if stored, _ := service.GetRequest("rp-1", session.ID); stored.State != PresentationFailed {
+			t.Errorf("%s: expected the request to fail, got %s", name, stored.State)
+		}
+	}
+
+	// Fields must map to attributes the claim type discloses
+	for _, path := range []string{"$.date_of_birth", "$.ssn", "$.credentialSubject.address.city"} {
+		bad := &PresentationDefinition{ID: "d", InputDescriptors: []InputDescriptor{{ID: "d", Constraints: InputConstraints{Fields: []InputField{{Path: []string{path}}}}}}}
+		if _, err := service.CreateRequest("rp-1", PresentationRequest{ClaimType: "age_verification", PresentationDefinition: bad}); err == nil {
+			t.Errorf("Expected field %s to be rejected", path)
+		}
+	}
+}
+
+func TestOpenID4VPService_Expiry(t *testing.T) {
+	service, _ := newOpenID4VPTestService(t)
+	service.ttl = time.Millisecond
+
+	session, _ := service.CreateRequest("rp-1", PresentationRequest{ClaimType: "student_verification"})
+	time.Sleep(5 * time.Millisecond)
+	if _, err := service.SubmitResponse(context.Background(), session.ID, "x~", &PresentationSubmission{}); err == nil {
+		t.Error("Expected an expired request to be rejected")
+	}
+	if stored, _ := service.GetRequest("rp-1", session.ID); stored.State != PresentationExpired {
+		t.Errorf("Expected the request to be expired, got %s", stored.State)
+	}
+}
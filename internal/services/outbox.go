@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// Outbox message kinds
+const (
+	OutboxAuditEntry   = "audit_entry"
+	OutboxNotification = "notification"
+)
+
+// Outbox message statuses. Dead messages ran out of attempts and are kept
+// for operators to inspect rather than retried.
+const (
+	OutboxPending = "pending"
+	OutboxDead    = "dead"
+)
+
+// outboxClaimLease is how long a claimed message is left to its dispatcher
+// before it is claimed again, so one that crashed mid-delivery is retried
+const outboxClaimLease = time.Minute
+
+// maxOutboxBackoff caps the wait between delivery attempts
+const maxOutboxBackoff = 10 * time.Minute
+
+// OutboxMessage is a side effect, such as an audit entry or a webhook
+// notification, committed with the state change that caused it and
+// delivered afterwards by the OutboxDispatcher, at least once
+type OutboxMessage struct {
+	ID            string          `json:"id"`
+	Kind          string          `json:"kind"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// NewOutboxMessage creates a pending message of a kind, due now
+func NewOutboxMessage(kind string, payload interface{}) (OutboxMessage, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxMessage{}, fmt.Errorf("failed to encode %s outbox message: %w", kind, err)
+	}
+	now := time.Now()
+	return OutboxMessage{
+		ID:            newNotificationID("obx"),
+		Kind:          kind,
+		Payload:       data,
+		Status:        OutboxPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}, nil
+}
+
+// OutboxRetryPolicy is how a kind of message is retried. Attempts back off
+// exponentially from Backoff, the dispatcher's OUTBOX_RETRY_BACKOFF when
+// zero; with MaxAttempts zero a message is retried until it is delivered.
+type OutboxRetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+type outboxHandler struct {
+	policy  OutboxRetryPolicy
+	deliver func(context.Context, OutboxMessage) error
+}
+
+// OutboxDispatcher delivers outbox messages in the background. Producers
+// commit messages with Commit; each kind is delivered by the handler
+// registered for it, and a failed delivery is retried after a backoff.
+type OutboxDispatcher struct {
+	store     OutboxStore
+	interval  time.Duration
+	batchSize int
+	backoff   time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	handlers map[string]outboxHandler
+	inFlight int
+	once     sync.Once
+	stop     chan struct{}
+	wake     chan struct{}
+
+	dispatchMu sync.Mutex
+}
+
+// NewOutboxDispatcher creates a dispatcher for the messages in a store
+func NewOutboxDispatcher(cfg *config.Config, store OutboxStore) *OutboxDispatcher {
+	batchSize := cfg.OutboxBatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	backoff := cfg.OutboxRetryBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	return &OutboxDispatcher{
+		store:     store,
+		interval:  cfg.OutboxPollInterval,
+		batchSize: batchSize,
+		backoff:   backoff,
+		now:       time.Now,
+		handlers:  make(map[string]outboxHandler),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Handle registers how a kind of message is delivered and retried
+func (d *OutboxDispatcher) Handle(kind string, policy OutboxRetryPolicy, deliver func(context.Context, OutboxMessage) error) {
+	if policy.Backoff <= 0 {
+		policy.Backoff = d.backoff
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[kind] = outboxHandler{policy: policy, deliver: deliver}
+}
+
+// Commit stores a job's state, when given, and the messages in one
+// transaction, then wakes the dispatcher to deliver them
+func (d *OutboxDispatcher) Commit(ctx context.Context, job *JobStatus, messages ...OutboxMessage) error {
+	if err := d.store.CommitOutbox(ctx, job, messages); err != nil {
+		return err
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Dispatch delivers the messages that are due, returning how many it
+// claimed
+func (d *OutboxDispatcher) Dispatch(ctx context.Context) (int, error) {
+	d.dispatchMu.Lock()
+	defer d.dispatchMu.Unlock()
+
+	messages, err := d.store.ClaimOutboxMessages(ctx, d.now(), d.batchSize, outboxClaimLease)
+	if err != nil {
+		return 0, err
+	}
+	d.mu.Lock()
+	d.inFlight = len(messages)
+	d.mu.Unlock()
+
+	for _, message := range messages {
+		if err := d.deliver(ctx, message); err != nil {
+			fmt.Printf("OUTBOX WARNING: %v\n", err)
+		}
+		d.mu.Lock()
+		d.inFlight--
+		d.mu.Unlock()
+	}
+	return len(messages), nil
+}
+
+// deliver attempts one message, removing it once delivered and otherwise
+// scheduling its retry or, out of attempts, keeping it as dead
+func (d *OutboxDispatcher) deliver(ctx context.Context, message OutboxMessage) error {
+	d.mu.Lock()
+	handler, exists := d.handlers[message.Kind]
+	d.mu.Unlock()
+
+	var err error
+	if exists {
+		err = handler.deliver(ctx, message)
+	} else {
+		err = fmt.Errorf("no handler for %s messages", message.Kind)
+	}
+	if err == nil {
+		return d.store.DeleteOutboxMessage(ctx, message.ID)
+	}
+
+	message.Attempts++
+	message.LastError = err.Error()
+	if !exists || (handler.policy.MaxAttempts > 0 && message.Attempts >= handler.policy.MaxAttempts) {
+		message.Status = OutboxDead
+		fmt.Printf("OUTBOX WARNING: giving up on %s message %s after %d attempts: %v\n", message.Kind, message.ID, message.Attempts, err)
+	} else {
+		message.NextAttemptAt = d.now().Add(handler.policy.delay(message.Attempts))
+	}
+	return d.store.UpdateOutboxMessage(ctx, message)
+}
+
+// delay returns the wait after a message's nth failed attempt
+func (p OutboxRetryPolicy) delay(attempts int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempts && delay < maxOutboxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxOutboxBackoff {
+		delay = maxOutboxBackoff
+	}
+	return delay
+}
+
+// InFlight returns the messages being delivered
+func (d *OutboxDispatcher) InFlight() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.inFlight
+}
+
+// Flush delivers messages until none are due. Messages waiting out a
+// backoff stay in the outbox for the next dispatcher.
+func (d *OutboxDispatcher) Flush(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		claimed, err := d.Dispatch(ctx)
+		if err != nil {
+			return err
+		}
+		if claimed < d.batchSize {
+			return nil
+		}
+	}
+}
+
+// Start begins delivering messages every poll interval and whenever new
+// ones are committed
+func (d *OutboxDispatcher) Start() {
+	if d.interval <= 0 {
+		return
+	}
+	d.once.Do(func() {
+		d.mu.Lock()
+		d.stop = make(chan struct{})
+		stop := d.stop
+		d.mu.Unlock()
+		go d.run(stop)
+	})
+}
+
+// Stop ends background delivery
+func (d *OutboxDispatcher) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Prevent delivery from starting after stop
+	d.once.Do(func() {})
+	if d.stop != nil {
+		close(d.stop)
+		d.stop = nil
+	}
+}
+
+func (d *OutboxDispatcher) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+		if _, err := d.Dispatch(context.Background()); err != nil {
+			fmt.Printf("OUTBOX WARNING: %v\n", err)
+		}
+	}
+}
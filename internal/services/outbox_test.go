@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestOutboxDispatcher_Retries(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStorage()
+	dispatcher := NewOutboxDispatcher(&config.Config{OutboxRetryBackoff: time.Second}, store)
+
+	failures := 2
+	delivered := 0
+	dispatcher.Handle(OutboxAuditEntry, OutboxRetryPolicy{}, func(context.Context, OutboxMessage) error {
+		if failures > 0 {
+			failures--
+			return errors.New("storage unavailable")
+		}
+		delivered++
+		return nil
+	})
+	dispatcher.Handle(OutboxNotification, OutboxRetryPolicy{MaxAttempts: 2}, func(context.Context, OutboxMessage) error {
+		return errors.New("webhook unavailable")
+	})
+
+	audit, _ := NewOutboxMessage(OutboxAuditEntry, "entry")
+	notification, _ := NewOutboxMessage(OutboxNotification, "notification")
+	unknown, _ := NewOutboxMessage("unknown", "payload")
+	if err := dispatcher.Commit(ctx, nil, audit, notification, unknown); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	dispatcher.now = func() time.Time { return now }
+
+	dispatcher.Dispatch(ctx)
+	if message := store.outbox[audit.ID]; message.Attempts != 1 || !message.NextAttemptAt.Equal(now.Add(time.Second)) {
+		t.Errorf("Expected a retry after the backoff, got %+v", message)
+	}
+	if message := store.outbox[unknown.ID]; message.Status != OutboxDead {
+		t.Errorf("Expected a message without a handler to be dead, got %+v", message)
+	}
+
+	// Attempts back off exponentially
+	now = now.Add(time.Second)
+	dispatcher.Dispatch(ctx)
+	if message := store.outbox[audit.ID]; message.Attempts != 2 || !message.NextAttemptAt.Equal(now.Add(2*time.Second)) {
+		t.Errorf("Expected the backoff to double, got %+v", message)
+	}
+	if message := store.outbox[notification.ID]; message.Status != OutboxDead || message.LastError != "webhook unavailable" {
+		t.Errorf("Expected the notification dead after 2 attempts, got %+v", message)
+	}
+
+	now = now.Add(2 * time.Second)
+	if err := dispatcher.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := store.outbox[audit.ID]; exists || delivered != 1 {
+		t.Errorf("Expected the audit entry delivered once and removed, delivered %d", delivered)
+	}
+}
+
+func TestAuditService_Outbox(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStorage()
+	dispatcher := NewOutboxDispatcher(&config.Config{}, store)
+	service := NewAuditService(&config.Config{})
+	service.SetStorage(store)
+	service.SetOutbox(dispatcher)
+
+	job := &JobStatus{JobID: "job_1", Status: JobCompleted, UpdatedAt: time.Now()}
+	ref := service.CommitVerification(ctx, models.VerificationRequest{RPID: "rp_1", ClaimType: "student_verification"}, nil, "SUCCESS", job)
+
+	// The job and the entry are committed together; the entry is written
+	// when the outbox is delivered
+	stored, err := store.GetJob(ctx, "job_1")
+	if err != nil || stored.Metadata["audit_entry_id"] != ref.AuditEntryID {
+		t.Errorf("Expected the job to record its audit entry, got %+v, %v", stored, err)
+	}
+	if job.Metadata != nil {
+		t.Error("Expected the caller's job to be left unchanged")
+	}
+	if _, err := store.GetAuditEntry(ctx, ref.AuditEntryID); !errors.Is(err, ErrStorageNotFound) {
+		t.Errorf("Expected the entry to wait in the outbox, got %v", err)
+	}
+	if err := dispatcher.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetAuditEntry(ctx, ref.AuditEntryID); err != nil {
+		t.Errorf("Expected the entry stored once delivered, got %v", err)
+	}
+}
+
+func TestNotificationService_Outbox(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+
+	store := NewMemoryStorage()
+	dispatcher := NewOutboxDispatcher(&config.Config{}, store)
+	service := NewNotificationService(&config.Config{NotificationMaxAttempts: 3, NotificationRetryBackoff: time.Second}, nil)
+	service.SetOutbox(dispatcher)
+	service.RegisterWebhook("rp_1", webhook.URL, nil)
+
+	service.Notify(RPNotification{Event: NotificationBatchCompleted, RPID: "rp_1", ResourceID: "batch_1"})
+	if service.PendingDeliveries() != 0 || len(store.outbox) != 1 {
+		t.Fatalf("Expected the delivery committed to the outbox, got %d messages", len(store.outbox))
+	}
+	now := time.Now()
+	dispatcher.now = func() time.Time { return now }
+
+	dispatcher.Dispatch(ctx)
+	if len(service.Deliveries("rp_1")) != 0 {
+		t.Error("Expected a retryable failure not to be recorded yet")
+	}
+	now = now.Add(time.Second)
+	dispatcher.Dispatch(ctx)
+
+	deliveries := service.Deliveries("rp_1")
+	if len(deliveries) != 1 || deliveries[0].Status != DeliveryDelivered || deliveries[0].Attempts != 2 {
+		t.Errorf("Expected one delivery on the second attempt, got %+v", deliveries)
+	}
+	if len(store.outbox) != 0 {
+		t.Errorf("Expected the delivered message removed, got %d", len(store.outbox))
+	}
+}
@@ -72,10 +72,9 @@ func (c *PolicyCache) Set(key string, decision *models.PolicyDecision) {
 func NewPolicyService(cfg *config.Config) *PolicyService {
 	return &PolicyService{
 		config: cfg,
-		client: &http.Client{
-			Timeout: cfg.OPATimeout,
-		},
-		cache: NewPolicyCache(5 * time.Minute), // Cache policy decisions for 5 minutes
+		// The OPA timeout is a runtime setting applied to each query
+		client: &http.Client{},
+		cache:  NewPolicyCache(5 * time.Minute), // Cache policy decisions for 5 minutes
 	}
 }
 
@@ -92,18 +91,8 @@ func (s *PolicyService) EnforcePolicy(ctx context.Context, req models.Verificati
 		return nil
 	}
 
-	// Create policy query
-	query := map[string]interface{}{
-		"input": map[string]interface{}{
-			"rp_id":      req.RPID,
-			"claim_type": req.ClaimType,
-			"user_id":    req.UserID,
-			"timestamp":  time.Now().Format(time.RFC3339),
-		},
-	}
-
 	// Send query to OPA
-	decision, err := s.queryOPA(ctx, query)
+	decision, err := s.queryOPA(ctx, s.policyQuery(req, false))
 	if err != nil {
 		// Log the error but don't cache failures
 		return fmt.Errorf("policy query failed: %w", err)
@@ -120,6 +109,40 @@ func (s *PolicyService) EnforcePolicy(ctx context.Context, req models.Verificati
 	return nil
 }
 
+// SimulatePolicy evaluates the policy for a hypothetical request. Cached
+// decisions are used when present, but new decisions are not cached.
+func (s *PolicyService) SimulatePolicy(ctx context.Context, req models.VerificationRequest) error {
+	if cached, exists := s.cache.Get(s.generateCacheKey(req)); exists {
+		if !cached.Allowed {
+			return fmt.Errorf("policy violation (cached): %s", cached.Reason)
+		}
+		return nil
+	}
+
+	decision, err := s.queryOPA(ctx, s.policyQuery(req, true))
+	if err != nil {
+		return fmt.Errorf("policy query failed: %w", err)
+	}
+	if !decision.Allowed {
+		return fmt.Errorf("policy violation: %s", decision.Reason)
+	}
+	return nil
+}
+
+// policyQuery creates the OPA input for a request
+func (s *PolicyService) policyQuery(req models.VerificationRequest, simulation bool) map[string]interface{} {
+	input := map[string]interface{}{
+		"rp_id":      req.RPID,
+		"claim_type": req.ClaimType,
+		"user_id":    req.UserID,
+		"timestamp":  time.Now().Format(time.RFC3339),
+	}
+	if simulation {
+		input["simulation"] = true
+	}
+	return map[string]interface{}{"input": input}
+}
+
 // queryOPA sends a query to the OPA service
 func (s *PolicyService) queryOPA(ctx context.Context, query map[string]interface{}) (*models.PolicyDecision, error) {
 	// Prepare the request body
@@ -128,6 +151,12 @@ func (s *PolicyService) queryOPA(ctx context.Context, query map[string]interface
 		return nil, fmt.Errorf("failed to marshal policy query: %w", err)
 	}
 
+	if timeout := time.Duration(s.config.RuntimeSettings().OPATimeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	// Create HTTP request
 	req, err := http.NewRequestWithContext(ctx, "POST", s.config.OPAURL+"/v1/data/pavilion/allow", bytes.NewBuffer(requestBody))
 	if err != nil {
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// PolicySimulation is the outcome of evaluating a hypothetical verification
+// request. It is computed without contacting a DP, writing audit entries or
+// populating caches, so RPs can design integrations against it freely.
+type PolicySimulation struct {
+	Simulation       bool                       `json:"simulation"`
+	Decision         *AuthorizationDecision     `json:"decision"`
+	DisclosureLevels map[string]DisclosureLevel `json:"disclosure_levels"`
+	RequiredConsents []ConsentRequirement       `json:"required_consents"`
+	Timestamp        string                     `json:"timestamp"`
+}
+
+// ConsentRequirement describes a consent the user must have given before a
+// real request with the same shape can be fulfilled
+type ConsentRequirement struct {
+	Scope       string `json:"scope"`
+	Purpose     string `json:"purpose"`
+	Recipient   string `json:"recipient"`
+	Description string `json:"description"`
+}
+
+// SimulateRequest runs the authorization checks for a request and reports the
+// disclosure levels and consents that would apply. Policy decisions are read
+// from the cache when present but never written to it, and the decision is
+// not logged.
+func (s *AuthorizationService) SimulateRequest(ctx context.Context, req models.VerificationRequest) (*PolicySimulation, error) {
+	decision := &AuthorizationDecision{
+		RPID:      req.RPID,
+		ClaimType: req.ClaimType,
+		UserID:    req.UserID,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Details:   map[string]interface{}{"simulation": true},
+	}
+
+	if err := s.checkRPPermissions(req, decision); err != nil {
+		decision.Reason = fmt.Sprintf("RP permission check failed: %s", err.Error())
+		decision.RuleID = "rp-permission-check"
+	} else if err := s.checkDPAccess(req, decision); err != nil {
+		decision.Reason = fmt.Sprintf("DP access validation failed: %s", err.Error())
+		decision.RuleID = "dp-access-check"
+	} else if err := s.policyService.SimulatePolicy(ctx, req); err != nil {
+		decision.Reason = fmt.Sprintf("Policy enforcement failed: %s", err.Error())
+		decision.RuleID = "opa-policy-enforcement"
+	} else {
+		decision.Allowed = true
+		decision.Reason = "Request would be authorized"
+		decision.RuleID = "authorization-complete"
+	}
+
+	return &PolicySimulation{
+		Simulation:       true,
+		Decision:         decision,
+		DisclosureLevels: simulatedDisclosureLevels(req),
+		RequiredConsents: simulatedConsents(req, decision.DPID),
+		Timestamp:        decision.Timestamp,
+	}, nil
+}
+
+// simulatedDisclosureLevels returns the disclosure level for every attribute
+// released for the claim type. Identifiers are only ever shared with the DP
+// as privacy-preserving hashes.
+func simulatedDisclosureLevels(req models.VerificationRequest) map[string]DisclosureLevel {
+	levels := make(map[string]DisclosureLevel)
+	if schema, exists := builtinClaimSchemas[req.ClaimType]; exists {
+		for attribute, level := range schema.Attributes {
+			levels[attribute] = level
+		}
+	}
+	for key := range req.Identifiers {
+		levels["identifiers."+key] = DisclosureLevelHash
+	}
+	return levels
+}
+
+// simulatedConsents returns the consents a real request would depend on: the
+// user's consent to the verification itself and to matching each identifier
+// at the DP
+func simulatedConsents(req models.VerificationRequest, dpID string) []ConsentRequirement {
+	consents := []ConsentRequirement{
+		{
+			Scope:       req.ClaimType,
+			Purpose:     "verification",
+			Recipient:   req.RPID,
+			Description: fmt.Sprintf("User consents to %s sharing the %s result", req.RPID, req.ClaimType),
+		},
+	}
+
+	if dpID == "" {
+		return consents
+	}
+
+	keys := make([]string, 0, len(req.Identifiers))
+	for key := range req.Identifiers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		consents = append(consents, ConsentRequirement{
+			Scope:       "identifiers." + key,
+			Purpose:     "record_linkage",
+			Recipient:   dpID,
+			Description: fmt.Sprintf("User consents to a hashed %s being matched at %s", key, dpID),
+		})
+	}
+	return consents
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// newSimulationOPA returns an OPA stub answering with the given result and
+// counting the queries it receives
+func newSimulationOPA(t *testing.T, allow bool, queries *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(queries, 1)
+
+		var query struct {
+			Input map[string]interface{} `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			t.Errorf("failed to decode OPA query: %v", err)
+		}
+		if query.Input["simulation"] != true {
+			t.Errorf("expected simulation flag in OPA input, got %v", query.Input)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": allow})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func simulationRequest() models.VerificationRequest {
+	return models.VerificationRequest{
+		RPID:      "test-rp",
+		UserID:    "test-user",
+		ClaimType: "student_verification",
+		Identifiers: map[string]string{
+			"email": "test@example.com",
+			"name":  "Test User",
+		},
+	}
+}
+
+func TestSimulateRequest_Allowed(t *testing.T) {
+	var queries int32
+	opa := newSimulationOPA(t, true, &queries)
+	cfg := &config.Config{OPAURL: opa.URL, OPATimeout: time.Second}
+	policyService := NewPolicyService(cfg)
+	authService := NewAuthorizationService(cfg, policyService)
+
+	simulation, err := authService.SimulateRequest(context.Background(), simulationRequest())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !simulation.Simulation {
+		t.Error("Expected result to be marked as a simulation")
+	}
+	if !simulation.Decision.Allowed {
+		t.Errorf("Expected request to be allowed, got reason %q", simulation.Decision.Reason)
+	}
+	if simulation.Decision.DPID != "university-dp" {
+		t.Errorf("Expected DP university-dp, got %s", simulation.Decision.DPID)
+	}
+	if _, logged := simulation.Decision.Details["logged"]; logged {
+		t.Error("Expected simulated decision not to be logged")
+	}
+
+	if level := simulation.DisclosureLevels["student_id"]; level != DisclosureLevelHash {
+		t.Errorf("Expected student_id to be hashed, got %q", level)
+	}
+	if level := simulation.DisclosureLevels["identifiers.email"]; level != DisclosureLevelHash {
+		t.Errorf("Expected email identifier to be hashed, got %q", level)
+	}
+
+	// One verification consent plus one linkage consent per identifier
+	if len(simulation.RequiredConsents) != 3 {
+		t.Fatalf("Expected 3 required consents, got %d", len(simulation.RequiredConsents))
+	}
+	if simulation.RequiredConsents[1].Scope != "identifiers.email" || simulation.RequiredConsents[1].Recipient != "university-dp" {
+		t.Errorf("Unexpected linkage consent: %+v", simulation.RequiredConsents[1])
+	}
+}
+
+func TestSimulateRequest_DoesNotCacheDecision(t *testing.T) {
+	var queries int32
+	opa := newSimulationOPA(t, true, &queries)
+	cfg := &config.Config{OPAURL: opa.URL, OPATimeout: time.Second}
+	policyService := NewPolicyService(cfg)
+	authService := NewAuthorizationService(cfg, policyService)
+
+	for i := 0; i < 2; i++ {
+		if _, err := authService.SimulateRequest(context.Background(), simulationRequest()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt32(&queries); got != 2 {
+		t.Errorf("Expected OPA to be queried for each simulation, got %d queries", got)
+	}
+	if _, cached := policyService.cache.Get(policyService.generateCacheKey(simulationRequest())); cached {
+		t.Error("Expected simulated decision not to be cached")
+	}
+}
+
+func TestSimulateRequest_PolicyDenied(t *testing.T) {
+	var queries int32
+	opa := newSimulationOPA(t, false, &queries)
+	cfg := &config.Config{OPAURL: opa.URL, OPATimeout: time.Second}
+	authService := NewAuthorizationService(cfg, NewPolicyService(cfg))
+
+	simulation, err := authService.SimulateRequest(context.Background(), simulationRequest())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if simulation.Decision.Allowed {
+		t.Error("Expected request to be denied")
+	}
+	if simulation.Decision.RuleID != "opa-policy-enforcement" {
+		t.Errorf("Expected opa-policy-enforcement rule, got %s", simulation.Decision.RuleID)
+	}
+	if len(simulation.DisclosureLevels) == 0 {
+		t.Error("Expected disclosure levels to be reported for denied requests")
+	}
+}
+
+func TestSimulateRequest_UnknownClaimType(t *testing.T) {
+	cfg := &config.Config{OPAURL: "http://invalid-opa-url:8181", OPATimeout: time.Second}
+	authService := NewAuthorizationService(cfg, NewPolicyService(cfg))
+
+	req := simulationRequest()
+	req.ClaimType = "unknown_verification"
+
+	simulation, err := authService.SimulateRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if simulation.Decision.RuleID != "dp-access-check" {
+		t.Errorf("Expected dp-access-check rule, got %s", simulation.Decision.RuleID)
+	}
+	// Without a DP no linkage consents apply
+	if len(simulation.RequiredConsents) != 1 {
+		t.Errorf("Expected only the verification consent, got %d", len(simulation.RequiredConsents))
+	}
+}
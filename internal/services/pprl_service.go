@@ -19,6 +19,7 @@ type PPRLConfig struct {
 	BloomFilterSize              int
 	BloomFilterHashCount         int
 	BloomFilterFalsePositiveRate float64
+	BloomFilterHash              string
 	HashAlgorithm                string
 	Salt                         string
 }
@@ -29,6 +30,7 @@ func NewPPRLConfig(size, hashCount int, falsePositiveRate float64, salt string)
 		BloomFilterSize:              size,
 		BloomFilterHashCount:         hashCount,
 		BloomFilterFalsePositiveRate: falsePositiveRate,
+		BloomFilterHash:              BloomHashFNV1a,
 		HashAlgorithm:                "SHA-256",
 		Salt:                         salt,
 	}
@@ -36,7 +38,7 @@ func NewPPRLConfig(size, hashCount int, falsePositiveRate float64, salt string)
 
 // NewPPRLService creates a new PPRL service
 func NewPPRLService(config *PPRLConfig) *PPRLService {
-	bloomFilter := NewBloomFilter(config.BloomFilterSize, config.BloomFilterHashCount)
+	bloomFilter := NewBloomFilterWithHash(config.BloomFilterSize, config.BloomFilterHashCount, config.BloomFilterHash)
 
 	return &PPRLService{
 		bloomFilter: bloomFilter,
@@ -138,7 +140,7 @@ func (p *PPRLService) preprocessForFuzzy(value string) string {
 // CreateBloomFilterForRecord creates a Bloom filter for a data provider record
 func (p *PPRLService) CreateBloomFilterForRecord(record DataProviderRecord) (*BloomFilter, error) {
 	// Create a new Bloom filter for this record
-	bloomFilter := NewBloomFilter(p.config.BloomFilterSize, p.config.BloomFilterHashCount)
+	bloomFilter := NewBloomFilterWithHash(p.config.BloomFilterSize, p.config.BloomFilterHashCount, p.config.BloomFilterHash)
 
 	// Hash and add each sensitive field
 	for fieldName, field := range record.Fields {
@@ -161,7 +163,7 @@ func (p *PPRLService) CreateBloomFilterForRecord(record DataProviderRecord) (*Bl
 // PerformPPRL performs privacy-preserving record linkage
 func (p *PPRLService) PerformPPRL(request PPRLRequest, providerRecords []DataProviderRecord) (*PPRLResponse, error) {
 	// Create Bloom filter for query fields
-	queryBloomFilter := NewBloomFilter(p.config.BloomFilterSize, p.config.BloomFilterHashCount)
+	queryBloomFilter := NewBloomFilterWithHash(p.config.BloomFilterSize, p.config.BloomFilterHashCount, p.config.BloomFilterHash)
 
 	// Hash and add query fields to Bloom filter
 	for fieldName, field := range request.QueryFields {
@@ -329,9 +331,10 @@ func (p *PPRLService) GetPPRLStats() map[string]interface{} {
 // ExportBloomFilter exports a Bloom filter to a portable format
 func (p *PPRLService) ExportBloomFilter(bloomFilter *BloomFilter) (map[string]interface{}, error) {
 	return map[string]interface{}{
-		"bloom_filter": bloomFilter.ToHexString(),
-		"size":         bloomFilter.size,
-		"hash_count":   bloomFilter.hashCount,
+		"bloom_filter":   bloomFilter.ToHexString(),
+		"size":           bloomFilter.size,
+		"hash_count":     bloomFilter.hashCount,
+		"hash_algorithm": bloomFilter.hashAlgorithm,
 		"metadata": map[string]interface{}{
 			"exported_at":         time.Now().Format(time.RFC3339),
 			"false_positive_rate": bloomFilter.GetFalsePositiveRate(),
@@ -356,5 +359,11 @@ func (p *PPRLService) ImportBloomFilter(data map[string]interface{}) (*BloomFilt
 		return nil, fmt.Errorf("invalid hash count data")
 	}
 
-	return FromHexString(bloomFilterHex, size, hashCount)
+	// Filters exported before the hash algorithm was recorded used FNV-1a
+	hashAlgorithm, ok := data["hash_algorithm"].(string)
+	if !ok {
+		hashAlgorithm = BloomHashFNV1a
+	}
+
+	return FromHexStringWithHash(bloomFilterHex, size, hashCount, hashAlgorithm)
 }
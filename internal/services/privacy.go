@@ -23,7 +23,7 @@ type PrivacyService struct {
 // NewPrivacyService creates a new privacy service
 func NewPrivacyService(cfg *config.Config) *PrivacyService {
 	// Create Bloom filter with configurable parameters
-	bloomFilter := NewBloomFilter(cfg.BloomFilterSize, cfg.BloomFilterHashCount)
+	bloomFilter := NewBloomFilterWithHash(cfg.BloomFilterSize, cfg.BloomFilterHashCount, ActiveCryptoProfile(cfg).BloomFilterHash)
 	
 	return &PrivacyService{
 		config:       cfg,
@@ -49,7 +49,7 @@ func (s *PrivacyService) TransformRequest(ctx context.Context, req models.Verifi
 	}
 
 	// Hash user ID with enhanced hashing
-	userHashResult, err := s.hashService.HashIdentifierDeterministic(req.UserID)
+	userHashResult, err := s.hashService.HashIdentifierFor(req.RPID, "user_id", req.UserID)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +63,7 @@ func (s *PrivacyService) TransformRequest(ctx context.Context, req models.Verifi
 			return nil, fmt.Errorf("data minimization failed for %s: %w", key, err)
 		}
 
-		hashResult, err := s.hashService.HashIdentifierDeterministic(minimizedValue)
+		hashResult, err := s.hashService.HashIdentifierFor(req.RPID, key, minimizedValue)
 		if err != nil {
 			return nil, err
 		}
@@ -116,7 +116,7 @@ func (s *PrivacyService) hashIdentifier(identifier string) string {
 // generateBloomFilter creates a Bloom filter for fuzzy matching
 func (s *PrivacyService) generateBloomFilter(value string) string {
 	// Create a new Bloom filter for this value
-	bf := NewBloomFilter(s.config.BloomFilterSize, s.config.BloomFilterHashCount)
+	bf := NewBloomFilterWithHash(s.config.BloomFilterSize, s.config.BloomFilterHashCount, ActiveCryptoProfile(s.config).BloomFilterHash)
 	
 	// Add the value to the Bloom filter
 	bf.Add(value)
@@ -0,0 +1,365 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// PSIProtocol identifies the ECDH private set intersection the broker runs:
+// identifiers are hashed to BLS12-381 G1 points and blinded with each side's
+// secret scalar, so only doubly-blinded points are ever compared
+const PSIProtocol = "ecdh-bls12381-g1"
+
+// Matching modes decide how a provider matches the user against its records
+const (
+	// MatchingModeHashed sends hashed identifiers and Bloom filters
+	MatchingModeHashed = "hashed"
+	// MatchingModePSI runs a private set intersection, so the provider never
+	// sees the identifiers, not even hashed
+	MatchingModePSI = "psi"
+)
+
+// psiDST separates PSI points from the other hash-to-curve uses of BLS12-381
+var psiDST = []byte("PAVILION_PSI_BLS12381G1_XMD:SHA-256_SSWU_RO_")
+
+// defaultPSIMaxServerSet bounds the blinded set a provider may return, and so
+// the scalar multiplications one verification costs
+const defaultPSIMaxServerSet = 10000
+
+// DPPSISettings configures PSI matching for a provider. Each key is a list of
+// identifier fields that together identify a record (["email"] or
+// ["national_id", "date_of_birth"]); the provider blinds the same keys for
+// the records that satisfy the claim.
+type DPPSISettings struct {
+	Keys         [][]string `json:"keys"`
+	MaxServerSet int        `json:"max_server_set,omitempty"`
+}
+
+// Validate checks the PSI keys
+func (s *DPPSISettings) Validate() error {
+	if len(s.Keys) == 0 {
+		return fmt.Errorf("PSI matching requires at least one key")
+	}
+	for i, key := range s.Keys {
+		if len(key) == 0 {
+			return fmt.Errorf("PSI key %d has no fields", i)
+		}
+		for _, field := range key {
+			if field == "" {
+				return fmt.Errorf("PSI key %d has an empty field", i)
+			}
+		}
+	}
+	if s.MaxServerSet < 0 {
+		return fmt.Errorf("PSI max_server_set cannot be negative")
+	}
+	return nil
+}
+
+// PSIKeyName is the name a key is reported under, e.g. "national_id+date_of_birth"
+func PSIKeyName(key []string) string {
+	return strings.Join(key, "+")
+}
+
+// PSIElement builds the set element for a key from a record's identifiers,
+// in the key's field order. It reports false when a field is missing.
+func PSIElement(key []string, identifiers map[string]string) (string, bool) {
+	parts := make([]string, len(key))
+	for i, field := range key {
+		value, exists := identifiers[field]
+		if !exists || value == "" {
+			return "", false
+		}
+		parts[i] = field + "=" + value
+	}
+	return strings.Join(parts, "&"), true
+}
+
+// PSIClient is the broker's side of the intersection. Its secret is fresh for
+// every request, so blinded points cannot be linked across requests.
+type PSIClient struct {
+	secret   *big.Int
+	elements []string
+}
+
+// NewPSIClient blinds a set of elements with a new secret
+func NewPSIClient(elements []string) (*PSIClient, error) {
+	secret, err := newPSISecret()
+	if err != nil {
+		return nil, err
+	}
+	return &PSIClient{secret: secret, elements: elements}, nil
+}
+
+// Blinded returns H(e)^a for each element, in order
+func (c *PSIClient) Blinded() ([]string, error) {
+	blinded := make([]string, len(c.elements))
+	for i, element := range c.elements {
+		point, err := hashPSIElement(element)
+		if err != nil {
+			return nil, err
+		}
+		blinded[i] = encodePSIPoint(point.ScalarMultiplication(&point, c.secret))
+	}
+	return blinded, nil
+}
+
+// Intersect finishes the protocol. doubleBlinded holds H(e)^ab for the
+// client's elements in order; serverSet holds H(s)^b for the provider's
+// elements. It returns the indexes of the client elements in the intersection.
+func (c *PSIClient) Intersect(doubleBlinded, serverSet []string) ([]int, error) {
+	if len(doubleBlinded) != len(c.elements) {
+		return nil, fmt.Errorf("expected %d double-blinded elements, got %d", len(c.elements), len(doubleBlinded))
+	}
+
+	server := make(map[[bls12381.SizeOfG1AffineCompressed]byte]bool, len(serverSet))
+	for _, encoded := range serverSet {
+		point, err := decodePSIPoint(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server element: %w", err)
+		}
+		point.ScalarMultiplication(point, c.secret)
+		server[point.Bytes()] = true
+	}
+
+	var matched []int
+	for i, encoded := range doubleBlinded {
+		point, err := decodePSIPoint(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid double-blinded element: %w", err)
+		}
+		if server[point.Bytes()] {
+			matched = append(matched, i)
+		}
+	}
+	return matched, nil
+}
+
+// PSIServer is the provider's side of the intersection. The broker does not
+// run it; it is the reference for provider implementations and tests.
+type PSIServer struct {
+	secret *big.Int
+}
+
+// NewPSIServer creates a provider side with a new secret
+func NewPSIServer() (*PSIServer, error) {
+	secret, err := newPSISecret()
+	if err != nil {
+		return nil, err
+	}
+	return &PSIServer{secret: secret}, nil
+}
+
+// Respond raises the client's blinded elements to the server's secret
+func (s *PSIServer) Respond(blinded []string) ([]string, error) {
+	response := make([]string, len(blinded))
+	for i, encoded := range blinded {
+		point, err := decodePSIPoint(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blinded element: %w", err)
+		}
+		response[i] = encodePSIPoint(point.ScalarMultiplication(point, s.secret))
+	}
+	return response, nil
+}
+
+// BlindSet returns H(e)^b for the server's elements in a random order, so
+// positions say nothing about the records behind them
+func (s *PSIServer) BlindSet(elements []string) ([]string, error) {
+	blinded := make([]string, len(elements))
+	for i, element := range elements {
+		point, err := hashPSIElement(element)
+		if err != nil {
+			return nil, err
+		}
+		blinded[i] = encodePSIPoint(point.ScalarMultiplication(&point, s.secret))
+	}
+	for i := len(blinded) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to shuffle PSI set: %w", err)
+		}
+		blinded[i], blinded[j.Int64()] = blinded[j.Int64()], blinded[i]
+	}
+	return blinded, nil
+}
+
+func newPSISecret() (*big.Int, error) {
+	var secret fr.Element
+	for secret.IsZero() {
+		if _, err := secret.SetRandom(); err != nil {
+			return nil, fmt.Errorf("failed to generate PSI secret: %w", err)
+		}
+	}
+	return secret.BigInt(new(big.Int)), nil
+}
+
+func hashPSIElement(element string) (bls12381.G1Affine, error) {
+	point, err := bls12381.HashToG1([]byte(element), psiDST)
+	if err != nil {
+		return point, fmt.Errorf("failed to hash PSI element: %w", err)
+	}
+	return point, nil
+}
+
+func encodePSIPoint(point *bls12381.G1Affine) string {
+	data := point.Bytes()
+	return base64.RawURLEncoding.EncodeToString(data[:])
+}
+
+// decodePSIPoint accepts only compressed points in the prime-order subgroup;
+// the identity would make every element look the same
+func decodePSIPoint(encoded string) (*bls12381.G1Affine, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(data) != bls12381.SizeOfG1AffineCompressed {
+		return nil, fmt.Errorf("malformed point")
+	}
+	var point bls12381.G1Affine
+	if _, err := point.SetBytes(data); err != nil {
+		return nil, fmt.Errorf("malformed point: %w", err)
+	}
+	if point.IsInfinity() {
+		return nil, fmt.Errorf("identity point")
+	}
+	return &point, nil
+}
+
+// PSIRequest is the body posted to a provider's /psi endpoint. It carries no
+// identifiers or user hash, only the blinded elements and the key they were
+// built from.
+type PSIRequest struct {
+	RPID      string                 `json:"rp_id"`
+	ClaimType string                 `json:"claim_type"`
+	Protocol  string                 `json:"protocol"`
+	Keys      []string               `json:"keys"`
+	Elements  []string               `json:"elements"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// PSIResponse is a provider's answer: the broker's elements raised to its
+// secret, in order, and its own blinded set for records satisfying the claim
+type PSIResponse struct {
+	JobID         string   `json:"job_id"`
+	Status        string   `json:"status"`
+	DoubleBlinded []string `json:"double_blinded"`
+	ServerSet     []string `json:"server_set"`
+	Error         string   `json:"error,omitempty"`
+	Timestamp     string   `json:"timestamp"`
+}
+
+// verifyWithPSI matches the request's hashed identifiers against the
+// provider's records without sending them. The user is verified when any of
+// the provider's keys intersects its set.
+func (s *DPConnectorService) verifyWithPSI(ctx context.Context, provider *DPProvider, payload []byte) (*DPResponse, error) {
+	var request models.PrivacyRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return nil, fmt.Errorf("failed to prepare PSI request: %w", err)
+	}
+
+	var keys []string
+	var elements []string
+	for _, key := range provider.PSI.Keys {
+		if element, ok := PSIElement(key, request.HashedIdentifiers); ok {
+			keys = append(keys, PSIKeyName(key))
+			elements = append(elements, element)
+		}
+	}
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("DP %s: request has no identifiers for any PSI key", provider.DPID)
+	}
+
+	client, err := NewPSIClient(elements)
+	if err != nil {
+		return nil, err
+	}
+	blinded, err := client.Blinded()
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(PSIRequest{
+		RPID:      request.RPID,
+		ClaimType: request.ClaimType,
+		Protocol:  PSIProtocol,
+		Keys:      keys,
+		Elements:  blinded,
+		Metadata:  request.Metadata,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode PSI request: %w", err)
+	}
+
+	maxServerSet := provider.PSI.MaxServerSet
+	if maxServerSet == 0 {
+		maxServerSet = defaultPSIMaxServerSet
+	}
+	var psiResp PSIResponse
+	err = s.postToProvider(ctx, provider, "/psi", body, nil, func(resp *http.Response) error {
+		data, err := s.readDPResponseBody(provider, resp)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("DP connector returned status %d: %s", resp.StatusCode, string(data))
+		}
+		if err := json.Unmarshal(data, &psiResp); err != nil {
+			return fmt.Errorf("failed to decode DP PSI response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if psiResp.Error != "" {
+		return nil, fmt.Errorf("DP %s: %s", provider.DPID, psiResp.Error)
+	}
+	if len(psiResp.ServerSet) > maxServerSet {
+		return nil, fmt.Errorf("DP %s: PSI set of %d elements exceeds the limit of %d", provider.DPID, len(psiResp.ServerSet), maxServerSet)
+	}
+
+	matched, err := client.Intersect(psiResp.DoubleBlinded, psiResp.ServerSet)
+	if err != nil {
+		return nil, fmt.Errorf("DP %s: %w", provider.DPID, err)
+	}
+
+	result := &VerificationResult{Timestamp: time.Now().UTC().Format(time.RFC3339)}
+	if len(matched) > 0 {
+		result.Verified = true
+		result.Confidence = 1.0
+		for _, index := range matched {
+			result.Evidence = append(result.Evidence, "psi_match:"+keys[index])
+		}
+	} else {
+		result.Reason = "no PSI match"
+	}
+
+	status := DPStatus(psiResp.Status)
+	if status == "" {
+		status = DPStatusCompleted
+	}
+	response := &DPResponse{
+		JobID:              psiResp.JobID,
+		DPID:               provider.DPID,
+		Status:             status,
+		VerificationResult: result,
+		Timestamp:          psiResp.Timestamp,
+		Metadata: map[string]interface{}{
+			"matching_mode":       MatchingModePSI,
+			"psi_server_set_size": len(psiResp.ServerSet),
+		},
+	}
+	if err := s.statusQuarantine.Check(provider, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestPSI_Intersection(t *testing.T) {
+	client, err := NewPSIClient([]string{"email=a", "email=b", "email=c"})
+	if err != nil {
+		t.Fatalf("NewPSIClient failed: %v", err)
+	}
+	server, _ := NewPSIServer()
+
+	blinded, err := client.Blinded()
+	if err != nil {
+		t.Fatalf("Blinded failed: %v", err)
+	}
+	doubleBlinded, err := server.Respond(blinded)
+	if err != nil {
+		t.Fatalf("Respond failed: %v", err)
+	}
+	serverSet, err := server.BlindSet([]string{"email=c", "email=x", "email=a"})
+	if err != nil {
+		t.Fatalf("BlindSet failed: %v", err)
+	}
+
+	matched, err := client.Intersect(doubleBlinded, serverSet)
+	if err != nil {
+		t.Fatalf("Intersect failed: %v", err)
+	}
+	if len(matched) != 2 || matched[0] != 0 || matched[1] != 2 {
+		t.Errorf("Expected elements 0 and 2 to match, got %v", matched)
+	}
+
+	// Blinding hides the element: the same element blinds differently per client
+	other, _ := NewPSIClient([]string{"email=a"})
+	otherBlinded, _ := other.Blinded()
+	if otherBlinded[0] == blinded[0] {
+		t.Error("Expected blinded elements to differ between clients")
+	}
+
+	if _, err := client.Intersect(doubleBlinded[:1], serverSet); err == nil {
+		t.Error("Expected a short double-blinded list to be rejected")
+	}
+	identity := make([]byte, 48)
+	identity[0] = 0xc0
+	for _, bad := range []string{"not-base64!", "AAAA", strings.Repeat("A", 64)} {
+		if _, err := client.Intersect(doubleBlinded, append(serverSet, bad)); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+	if _, err := decodePSIPoint(base64.RawURLEncoding.EncodeToString(identity)); err == nil {
+		t.Error("Expected the identity point to be rejected")
+	}
+}
+
+func TestPSIElement(t *testing.T) {
+	identifiers := map[string]string{"national_id": "h1", "date_of_birth": "h2"}
+	if element, ok := PSIElement([]string{"national_id", "date_of_birth"}, identifiers); !ok || element != "national_id=h1&date_of_birth=h2" {
+		t.Errorf("Unexpected element %q, %v", element, ok)
+	}
+	if _, ok := PSIElement([]string{"email"}, identifiers); ok {
+		t.Error("Expected a key with a missing field to be skipped")
+	}
+}
+
+func TestDPConnectorService_PSIMatching(t *testing.T) {
+	psiServer, _ := NewPSIServer()
+	records := []string{"email=hash-enrolled", "email=hash-other"}
+	var received map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/psi" {
+			http.NotFound(w, r)
+			return
+		}
+		var request PSIRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		received = map[string]interface{}{"protocol": request.Protocol, "keys": request.Keys}
+
+		doubleBlinded, err := psiServer.Respond(request.Elements)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		serverSet, _ := psiServer.BlindSet(records)
+		json.NewEncoder(w).Encode(PSIResponse{JobID: "j", Status: "completed", DoubleBlinded: doubleBlinded, ServerSet: serverSet, Timestamp: "2026-01-01T00:00:00Z"})
+	}))
+	defer server.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: server.URL, DPTimeout: 5 * time.Second})
+	service.Registry().Remove(DefaultDPProviderID)
+	if err := service.Registry().Register(&DPProvider{
+		DPID:            "dp-psi",
+		Endpoint:        server.URL,
+		SupportedClaims: []string{AnyClaimType},
+		MatchingMode:    MatchingModePSI,
+		PSI:             &DPPSISettings{Keys: [][]string{{"email"}, {"national_id", "date_of_birth"}}},
+	}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	response, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{
+		RPID:              "rp_1",
+		UserHash:          "user-hash",
+		ClaimType:         "student_verification",
+		HashedIdentifiers: map[string]string{"email": "hash-enrolled"},
+	})
+	if err != nil {
+		t.Fatalf("VerifyWithDP failed: %v", err)
+	}
+	if !response.VerificationResult.Verified || len(response.VerificationResult.Evidence) != 1 || response.VerificationResult.Evidence[0] != "psi_match:email" {
+		t.Errorf("Expected a PSI match on email, got %+v", response.VerificationResult)
+	}
+	if received["protocol"] != PSIProtocol || len(received["keys"].([]string)) != 1 {
+		t.Errorf("Unexpected PSI request: %v", received)
+	}
+
+	response, err = service.VerifyWithDP(context.Background(), &models.PrivacyRequest{
+		RPID:              "rp_1",
+		ClaimType:         "student_verification",
+		HashedIdentifiers: map[string]string{"email": "hash-unknown"},
+	})
+	if err != nil {
+		t.Fatalf("VerifyWithDP failed: %v", err)
+	}
+	if response.VerificationResult.Verified {
+		t.Errorf("Expected no PSI match, got %+v", response.VerificationResult)
+	}
+
+	if _, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "student_verification"}); err == nil {
+		t.Error("Expected a request without PSI identifiers to fail")
+	}
+}
+
+func TestDPProvider_ValidatePSI(t *testing.T) {
+	for name, provider := range map[string]*DPProvider{
+		"missing settings": {MatchingMode: MatchingModePSI},
+		"empty key":        {MatchingMode: MatchingModePSI, PSI: &DPPSISettings{Keys: [][]string{{}}}},
+		"with template":    {MatchingMode: MatchingModePSI, PSI: &DPPSISettings{Keys: [][]string{{"email"}}}, RequestTemplate: &DPRequestTemplate{Type: RequestTemplateGo, Template: "{}"}},
+		"unknown mode":     {MatchingMode: "fuzzy"},
+	} {
+		provider.DPID = "dp"
+		provider.Endpoint = "https://dp.example"
+		provider.SupportedClaims = []string{AnyClaimType}
+		if err := provider.Validate(); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+}
@@ -24,6 +24,8 @@ type PullJobService struct {
 type JobTracker struct {
 	mu    sync.RWMutex
 	jobs  map[string]*JobStatus
+	// store, when set, keeps job state across restarts
+	store JobStore
 }
 
 // JobStatus represents the status of a pull-job
@@ -36,6 +38,8 @@ type JobStatus struct {
 	CompletedAt     *time.Time             `json:"completed_at,omitempty"`
 	Result          *models.DPResponse     `json:"result,omitempty"`
 	Error           string                 `json:"error,omitempty"`
+	// ErrorCode is the problem code of a failed job's DP error, if it has one
+	ErrorCode       string                 `json:"error_code,omitempty"`
 	RetryCount      int                    `json:"retry_count"`
 	MaxRetries      int                    `json:"max_retries"`
 	Timeout         time.Duration          `json:"timeout"`
@@ -137,14 +141,14 @@ func (s *PullJobService) processJob(ctx context.Context, jobStatus *JobStatus, r
 	// Send request to DP connector
 	dpResp, err := s.dpService.VerifyWithDP(ctx, req)
 	if err != nil {
-		s.handleJobFailure(jobStatus, err)
+		s.handleJobFailure(jobStatus, req, err)
 		return
 	}
 
 	// Parse and validate response
 	result, err := s.parseJobResult(dpResp)
 	if err != nil {
-		s.handleJobFailure(jobStatus, err)
+		s.handleJobFailure(jobStatus, req, err)
 		return
 	}
 
@@ -156,20 +160,26 @@ func (s *PullJobService) processJob(ctx context.Context, jobStatus *JobStatus, r
 	})
 }
 
-// handleJobFailure handles job failures with retry logic
-func (s *PullJobService) handleJobFailure(jobStatus *JobStatus, err error) {
+// handleJobFailure handles job failures with retry logic. A job is not
+// retried while one of its claim type's DPs has an open breaker: the retry
+// would be refused or add load to a DP in an outage, and failing at once
+// lets the verification serve a stale result.
+func (s *PullJobService) handleJobFailure(jobStatus *JobStatus, req *models.PrivacyRequest, err error) {
+	s.jobTracker.mu.Lock()
 	jobStatus.RetryCount++
+	retryCount := jobStatus.RetryCount
+	s.jobTracker.mu.Unlock()
 	
-	if jobStatus.RetryCount <= jobStatus.MaxRetries {
+	if retryCount <= jobStatus.MaxRetries && !s.dpService.ClaimCircuitOpen(req.ClaimType) {
 		// Retry the job
-		s.auditLogger.LogEvent("job_retry", fmt.Sprintf("Job retry %d/%d", jobStatus.RetryCount, jobStatus.MaxRetries), 
+		s.auditLogger.LogEvent("job_retry", fmt.Sprintf("Job retry %d/%d", retryCount, jobStatus.MaxRetries), 
 			jobStatus.JobID, jobStatus.RequestID, JobPending, map[string]string{
-				"retry_count": fmt.Sprintf("%d", jobStatus.RetryCount),
+				"retry_count": fmt.Sprintf("%d", retryCount),
 				"error":       err.Error(),
 			})
 		
 		// Schedule retry with exponential backoff
-		delay := time.Duration(jobStatus.RetryCount) * time.Second
+		delay := time.Duration(retryCount) * time.Second
 		time.AfterFunc(delay, func() {
 			// Re-process the job
 			// Note: In a real implementation, you'd re-fetch the original request
@@ -178,7 +188,7 @@ func (s *PullJobService) handleJobFailure(jobStatus *JobStatus, err error) {
 		})
 	} else {
 		// Max retries exceeded, mark as failed
-		s.jobTracker.UpdateJobStatus(jobStatus.JobID, JobFailed, err.Error())
+		s.jobTracker.FailJob(jobStatus.JobID, err)
 		s.auditLogger.LogEvent("job_failed", "Job failed after max retries", 
 			jobStatus.JobID, jobStatus.RequestID, JobFailed, map[string]string{
 				"error": err.Error(),
@@ -199,12 +209,25 @@ func (s *PullJobService) parseJobResult(dpResp *DPResponse) (*models.DPResponse,
 
 	// Convert DPResponse to models.DPResponse
 	result := &models.DPResponse{
-		Status:         dpResp.Status,
+		Status:         string(dpResp.Status),
 		ConfidenceScore: 0.0,
-		DPID:          "dp-connector",
+		DPID:          dpResp.DPID,
 		Timestamp:     dpResp.Timestamp,
 	}
 
+	if result.DPID == "" {
+		result.DPID = DefaultDPProviderID
+	}
+	if dpResp.Validators != nil {
+		result.ETag = dpResp.Validators.ETag
+		result.LastModified = dpResp.Validators.LastModified
+	}
+	result.NotModified = dpResp.Status == DPStatusNotModified
+	result.PartialResults = dpResp.PartialResults
+	result.Claim = dpResp.Claim
+	result.Consensus = dpResp.Consensus
+	result.Scoring = dpResp.Scoring
+
 	// Extract verification result if available
 	if dpResp.VerificationResult != nil {
 		result.Verified = dpResp.VerificationResult.Verified
@@ -225,6 +248,18 @@ func (s *PullJobService) GetJobStatus(jobID string) (*JobStatus, error) {
 	return s.jobTracker.GetJob(jobID)
 }
 
+// JobSnapshot returns a copy of a job's current state
+func (s *PullJobService) JobSnapshot(jobID string) (*JobStatus, error) {
+	s.jobTracker.mu.RLock()
+	defer s.jobTracker.mu.RUnlock()
+	job, exists := s.jobTracker.jobs[jobID]
+	if !exists {
+		return nil, fmt.Errorf("job not found: %s", jobID)
+	}
+	snapshot := *job
+	return &snapshot, nil
+}
+
 // ListJobs lists all tracked jobs
 func (s *PullJobService) ListJobs() []*JobStatus {
 	return s.jobTracker.ListJobs()
@@ -235,6 +270,13 @@ func (s *PullJobService) CleanupExpiredJobs() {
 	s.jobTracker.CleanupExpiredJobs()
 }
 
+// SetStorage keeps job state in a store so it survives restarts
+func (s *PullJobService) SetStorage(store JobStore) {
+	s.jobTracker.mu.Lock()
+	defer s.jobTracker.mu.Unlock()
+	s.jobTracker.store = store
+}
+
 // generateJobID generates a unique job ID
 func (s *PullJobService) generateJobID() string {
 	return fmt.Sprintf("job_%d", time.Now().UnixNano())
@@ -243,52 +285,105 @@ func (s *PullJobService) generateJobID() string {
 // TrackJob tracks a new job
 func (jt *JobTracker) TrackJob(job *JobStatus) {
 	jt.mu.Lock()
-	defer jt.mu.Unlock()
 	jt.jobs[job.JobID] = job
+	snapshot := *job
+	jt.mu.Unlock()
+
+	jt.persist(&snapshot)
 }
 
 // UpdateJobStatus updates the status of a job
 func (jt *JobTracker) UpdateJobStatus(jobID string, status JobState, error string) {
 	jt.mu.Lock()
-	defer jt.mu.Unlock()
-	
-	if job, exists := jt.jobs[jobID]; exists {
-		job.Status = status
-		job.UpdatedAt = time.Now()
-		if error != "" {
-			job.Error = error
-		}
+	job, exists := jt.jobs[jobID]
+	if !exists {
+		jt.mu.Unlock()
+		return
+	}
+	job.Status = status
+	job.UpdatedAt = time.Now()
+	if error != "" {
+		job.Error = error
 	}
+	snapshot := *job
+	jt.mu.Unlock()
+
+	jt.persist(&snapshot)
+}
+
+// FailJob marks a job as failed with its error
+func (jt *JobTracker) FailJob(jobID string, err error) {
+	jt.mu.Lock()
+	job, exists := jt.jobs[jobID]
+	if !exists {
+		jt.mu.Unlock()
+		return
+	}
+	job.Status = JobFailed
+	job.UpdatedAt = time.Now()
+	job.Error = err.Error()
+	job.ErrorCode = DPProblemCode(err)
+	snapshot := *job
+	jt.mu.Unlock()
+
+	jt.persist(&snapshot)
 }
 
 // CompleteJob marks a job as completed with result
 func (jt *JobTracker) CompleteJob(jobID string, result *models.DPResponse, error string) {
 	jt.mu.Lock()
-	defer jt.mu.Unlock()
-	
-	if job, exists := jt.jobs[jobID]; exists {
-		job.Status = JobCompleted
-		job.UpdatedAt = time.Now()
-		completedAt := time.Now()
-		job.CompletedAt = &completedAt
-		job.Result = result
-		if error != "" {
-			job.Error = error
-		}
+	job, exists := jt.jobs[jobID]
+	if !exists {
+		jt.mu.Unlock()
+		return
+	}
+	job.Status = JobCompleted
+	job.UpdatedAt = time.Now()
+	completedAt := time.Now()
+	job.CompletedAt = &completedAt
+	job.Result = result
+	if error != "" {
+		job.Error = error
 	}
+	snapshot := *job
+	jt.mu.Unlock()
+
+	jt.persist(&snapshot)
 }
 
-// GetJob retrieves a job by ID
+// GetJob retrieves a copy of a job by ID, falling back to the store for
+// jobs tracked before a restart. The job is copied under the tracker's lock
+// because its processing goroutine keeps updating it.
 func (jt *JobTracker) GetJob(jobID string) (*JobStatus, error) {
 	jt.mu.RLock()
-	defer jt.mu.RUnlock()
-	
-	if job, exists := jt.jobs[jobID]; exists {
-		return job, nil
+	job, exists := jt.jobs[jobID]
+	var snapshot JobStatus
+	if exists {
+		snapshot = *job
+	}
+	jt.mu.RUnlock()
+	if exists {
+		return &snapshot, nil
+	}
+
+	if jt.store != nil {
+		if stored, err := jt.store.GetJob(context.Background(), jobID); err == nil {
+			return stored, nil
+		}
 	}
 	return nil, fmt.Errorf("job not found: %s", jobID)
 }
 
+// persist saves a snapshot of a job to the store, if one is set
+func (jt *JobTracker) persist(job *JobStatus) {
+	if jt.store == nil {
+		return
+	}
+	if err := jt.store.SaveJob(context.Background(), job); err != nil {
+		fmt.Printf("JOB STORAGE WARNING: failed to save job %s: %v\n", job.JobID, err)
+	}
+}
+
 // ListJobs lists all tracked jobs
 func (jt *JobTracker) ListJobs() []*JobStatus {
 	jt.mu.RLock()
@@ -312,6 +407,11 @@ func (jt *JobTracker) CleanupExpiredJobs() {
 			delete(jt.jobs, jobID)
 		}
 	}
+	if jt.store != nil {
+		if _, err := jt.store.DeleteJobsBefore(context.Background(), cutoff); err != nil {
+			fmt.Printf("JOB STORAGE WARNING: failed to delete expired jobs: %v\n", err)
+		}
+	}
 }
 
 // LogEvent logs a job audit event
@@ -378,6 +478,20 @@ func (s *PullJobService) GetJobStats() map[string]interface{} {
 	return stats
 }
 
+// ActiveJobs returns the number of jobs still pending or running
+func (s *PullJobService) ActiveJobs() int {
+	s.jobTracker.mu.RLock()
+	defer s.jobTracker.mu.RUnlock()
+
+	active := 0
+	for _, job := range s.jobTracker.jobs {
+		if job.Status == JobPending || job.Status == JobRunning {
+			active++
+		}
+	}
+	return active
+}
+
 // HealthCheck checks if the pull-job service is healthy
 func (s *PullJobService) HealthCheck(ctx context.Context) error {
 	// Check job tracker
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry change kinds
+const (
+	RegistryChangeDPProvider  = "dp_provider"
+	RegistryChangeClaimSchema = "claim_schema"
+)
+
+// Registry change actions and field change operations
+const (
+	RegistryChangeAdded   = "added"
+	RegistryChangeRemoved = "removed"
+	RegistryChangeUpdated = "updated"
+)
+
+// maxRegistryChanges bounds the change history kept in memory
+const maxRegistryChanges = 1000
+
+// ErrChangeReasonRequired is returned when a registry change has no reason
+var ErrChangeReasonRequired = fmt.Errorf("a change reason is required")
+
+// FieldChange is one difference between two versions of a registry entry.
+// Op is added, removed or updated; for set-like fields such as
+// supported_claims each added or removed element is its own change.
+type FieldChange struct {
+	Path   string      `json:"path"`
+	Op     string      `json:"op"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// RegistryChange records a change to a DP registry entry or claim schema:
+// its structured diff, why it was made and who was told. Behavioral changes
+// alter how verifications of the claim types are routed or answered, and
+// are notified to the RPs using them.
+type RegistryChange struct {
+	ID          string        `json:"id"`
+	Kind        string        `json:"kind"`
+	Subject     string        `json:"subject"`
+	Action      string        `json:"action"`
+	Reason      string        `json:"reason"`
+	Actor       string        `json:"actor,omitempty"`
+	Timestamp   time.Time     `json:"timestamp"`
+	ClaimTypes  []string      `json:"claim_types"`
+	Behavioral  bool          `json:"behavioral"`
+	Diff        []FieldChange `json:"diff"`
+	NotifiedRPs []string      `json:"notified_rps,omitempty"`
+}
+
+// registryDiffSchema tells the diff how to treat the fields of an entry
+type registryDiffSchema struct {
+	// sets are compared element by element, ignoring order
+	sets map[string]bool
+	// secrets are reported as changed without their values
+	secrets map[string]bool
+	// operational fields do not change verification behavior
+	operational map[string]bool
+}
+
+var dpProviderDiffSchema = registryDiffSchema{
+	sets:        map[string]bool{"supported_claims": true},
+	secrets:     map[string]bool{"auth": true},
+	operational: map[string]bool{"name": true, "category": true, "auth": true, "tls": true},
+}
+
+var claimSchemaDiffSchema = registryDiffSchema{
+	sets:        map[string]bool{"required_identifiers": true, "optional_identifiers": true},
+	operational: map[string]bool{"description": true, "typical_latency_ms": true},
+}
+
+// DiffDPProviders compares two versions of a provider; before is nil for an
+// added provider and after for a removed one. It returns nil when they are
+// the same.
+func DiffDPProviders(before, after *DPProvider) *RegistryChange {
+	change := diffRegistryEntries(dpProviderDiffSchema, before, after)
+	if change == nil {
+		return nil
+	}
+	change.Kind = RegistryChangeDPProvider
+	claimTypes := make(map[string]bool)
+	for _, provider := range []*DPProvider{before, after} {
+		if provider == nil {
+			continue
+		}
+		change.Subject = provider.DPID
+		for _, claimType := range provider.SupportedClaims {
+			claimTypes[claimType] = true
+		}
+	}
+	change.ClaimTypes = sortedMapKeys(claimTypes)
+	return change
+}
+
+// DiffClaimSchemas compares two versions of a claim schema; before is nil
+// for a new claim type. It returns nil when they are the same.
+func DiffClaimSchemas(before, after *ClaimTypeSchema) *RegistryChange {
+	change := diffRegistryEntries(claimSchemaDiffSchema, before, after)
+	if change == nil {
+		return nil
+	}
+	change.Kind = RegistryChangeClaimSchema
+	if after != nil {
+		change.Subject = after.ClaimType
+	} else {
+		change.Subject = before.ClaimType
+	}
+	change.ClaimTypes = []string{change.Subject}
+	return change
+}
+
+func diffRegistryEntries(schema registryDiffSchema, before, after interface{}) *RegistryChange {
+	beforeFields, beforeExists := registryFields(before)
+	afterFields, afterExists := registryFields(after)
+
+	change := &RegistryChange{Action: RegistryChangeUpdated, Diff: make([]FieldChange, 0)}
+	switch {
+	case !beforeExists && !afterExists:
+		return nil
+	case !beforeExists:
+		change.Action = RegistryChangeAdded
+	case !afterExists:
+		change.Action = RegistryChangeRemoved
+	}
+
+	schema.diff("", beforeFields, afterFields, &change.Diff)
+	if len(change.Diff) == 0 {
+		return nil
+	}
+
+	change.Behavioral = change.Action != RegistryChangeUpdated
+	for _, field := range change.Diff {
+		if !schema.operational[topLevelField(field.Path)] {
+			change.Behavioral = true
+		}
+	}
+	return change
+}
+
+// registryFields returns an entry's JSON fields, and false for a nil entry
+func registryFields(entry interface{}) (map[string]interface{}, bool) {
+	value := reflect.ValueOf(entry)
+	if !value.IsValid() || (value.Kind() == reflect.Ptr && value.IsNil()) {
+		return map[string]interface{}{}, false
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return map[string]interface{}{}, false
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	return fields, true
+}
+
+func topLevelField(path string) string {
+	if i := strings.IndexByte(path, '.'); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+func (s registryDiffSchema) diff(path string, before, after map[string]interface{}, changes *[]FieldChange) {
+	keys := make(map[string]bool, len(before)+len(after))
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	for _, key := range sortedMapKeys(keys) {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+		beforeValue, beforeExists := before[key]
+		afterValue, afterExists := after[key]
+		if beforeExists && afterExists && reflect.DeepEqual(beforeValue, afterValue) {
+			continue
+		}
+
+		beforeObject, beforeIsObject := beforeValue.(map[string]interface{})
+		afterObject, afterIsObject := afterValue.(map[string]interface{})
+		if beforeIsObject && afterIsObject {
+			s.diff(fieldPath, beforeObject, afterObject, changes)
+			continue
+		}
+		if s.secrets[topLevelField(fieldPath)] {
+			*changes = append(*changes, s.fieldChange(fieldPath, beforeExists, afterExists, supportRedacted, supportRedacted))
+			continue
+		}
+		if s.sets[fieldPath] {
+			beforeList, beforeIsList := arrayValue(beforeValue)
+			afterList, afterIsList := arrayValue(afterValue)
+			if (beforeIsList || !beforeExists) && (afterIsList || !afterExists) {
+				s.diffSet(fieldPath, beforeList, afterList, changes)
+				continue
+			}
+		}
+		*changes = append(*changes, s.fieldChange(fieldPath, beforeExists, afterExists, beforeValue, afterValue))
+	}
+}
+
+func (s registryDiffSchema) fieldChange(path string, beforeExists, afterExists bool, before, after interface{}) FieldChange {
+	change := FieldChange{Path: path, Op: RegistryChangeUpdated, Before: before, After: after}
+	if !beforeExists {
+		change.Op, change.Before = RegistryChangeAdded, nil
+	} else if !afterExists {
+		change.Op, change.After = RegistryChangeRemoved, nil
+	}
+	return change
+}
+
+// diffSet reports the elements removed from and added to a set-like field
+func (s registryDiffSchema) diffSet(path string, before, after []interface{}, changes *[]FieldChange) {
+	for _, element := range before {
+		if !containsJSONValue(after, element) {
+			*changes = append(*changes, FieldChange{Path: path, Op: RegistryChangeRemoved, Before: element})
+		}
+	}
+	for _, element := range after {
+		if !containsJSONValue(before, element) {
+			*changes = append(*changes, FieldChange{Path: path, Op: RegistryChangeAdded, After: element})
+		}
+	}
+}
+
+// RegistryChangeQuery filters the change history; empty fields match all
+type RegistryChangeQuery struct {
+	Kind      string
+	Subject   string
+	ClaimType string
+}
+
+// RegistryChangeLog keeps the history of DP registry and claim schema
+// changes and notifies the RPs affected by behavioral ones
+type RegistryChangeLog struct {
+	mu       sync.RWMutex
+	changes  []*RegistryChange
+	capacity int
+
+	notifications *NotificationService
+	affectedRPs   func(claimTypes []string) []string
+}
+
+// NewRegistryChangeLog creates an empty change history
+func NewRegistryChangeLog() *RegistryChangeLog {
+	return &RegistryChangeLog{capacity: maxRegistryChanges}
+}
+
+// SetNotifications notifies behavioral changes to the RPs affectedRPs
+// returns for the changed claim types
+func (l *RegistryChangeLog) SetNotifications(notifications *NotificationService, affectedRPs func(claimTypes []string) []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.notifications = notifications
+	l.affectedRPs = affectedRPs
+}
+
+// Record stores changes made for a reason by an operator, notifying the
+// affected RPs of behavioral changes. The changes are completed in place
+// with their ID, reason, actor, time and notified RPs.
+func (l *RegistryChangeLog) Record(reason, actor string, changes ...*RegistryChange) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return ErrChangeReasonRequired
+	}
+
+	l.mu.RLock()
+	notifications, affectedRPs := l.notifications, l.affectedRPs
+	l.mu.RUnlock()
+
+	now := time.Now()
+	for _, change := range changes {
+		change.ID = newNotificationID("chg")
+		change.Reason = reason
+		change.Actor = actor
+		change.Timestamp = now
+		if change.Behavioral && notifications != nil && affectedRPs != nil {
+			change.NotifiedRPs = affectedRPs(change.ClaimTypes)
+			for _, rpID := range change.NotifiedRPs {
+				notifications.Notify(registryChangeNotification(rpID, change))
+			}
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes = append(l.changes, changes...)
+	if len(l.changes) > l.capacity {
+		l.changes = l.changes[len(l.changes)-l.capacity:]
+	}
+	return nil
+}
+
+// registryChangeNotification describes a change to an RP by the fields it
+// touched; values, which may describe DP internals, are left out
+func registryChangeNotification(rpID string, change *RegistryChange) RPNotification {
+	fields := make(map[string]bool, len(change.Diff))
+	for _, field := range change.Diff {
+		fields[field.Path] = true
+	}
+	return RPNotification{
+		Event:      NotificationRegistryChanged,
+		RPID:       rpID,
+		ResourceID: change.ID,
+		Data: map[string]interface{}{
+			"kind":        change.Kind,
+			"subject":     change.Subject,
+			"action":      change.Action,
+			"claim_types": change.ClaimTypes,
+			"fields":      sortedMapKeys(fields),
+			"reason":      change.Reason,
+		},
+	}
+}
+
+// List returns the recorded changes matching the query, newest first
+func (l *RegistryChangeLog) List(query RegistryChangeQuery) []*RegistryChange {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	changes := make([]*RegistryChange, 0)
+	for i := len(l.changes) - 1; i >= 0; i-- {
+		change := l.changes[i]
+		if query.Kind != "" && change.Kind != query.Kind {
+			continue
+		}
+		if query.Subject != "" && change.Subject != query.Subject {
+			continue
+		}
+		if query.ClaimType != "" && !change.affects(query.ClaimType) {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// Get returns a recorded change by ID
+func (l *RegistryChangeLog) Get(id string) (*RegistryChange, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, change := range l.changes {
+		if change.ID == id {
+			return change, true
+		}
+	}
+	return nil, false
+}
+
+func (c *RegistryChange) affects(claimType string) bool {
+	for _, changed := range c.ClaimTypes {
+		if changed == claimType || changed == AnyClaimType {
+			return true
+		}
+	}
+	return false
+}
+
+// sortRegistryChanges orders changes by subject, for stable reload reports
+func sortRegistryChanges(changes []*RegistryChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Subject < changes[j].Subject
+	})
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffDPProviders(t *testing.T) {
+	before := &DPProvider{
+		DPID:            "dp_1",
+		Name:            "Registry",
+		Endpoint:        "http://dp1.example.com",
+		SupportedClaims: []string{"age_verification", "student_verification"},
+		Auth:            &DPProviderAuth{Method: AuthMethodAPIKey, APIKey: "old-key"},
+	}
+	after := &DPProvider{
+		DPID:            "dp_1",
+		Name:            "Registry",
+		Endpoint:        "http://dp1.example.com",
+		SupportedClaims: []string{"student_verification", "employee_verification"},
+		Priority:        2,
+		Auth:            &DPProviderAuth{Method: AuthMethodAPIKey, APIKey: "new-key"},
+	}
+
+	change := DiffDPProviders(before, after)
+	if change == nil || change.Kind != RegistryChangeDPProvider || change.Subject != "dp_1" || change.Action != RegistryChangeUpdated {
+		t.Fatalf("Expected an update of dp_1, got %+v", change)
+	}
+	expected := []FieldChange{
+		{Path: "auth.api_key", Op: RegistryChangeUpdated, Before: supportRedacted, After: supportRedacted},
+		{Path: "priority", Op: RegistryChangeUpdated, Before: 0.0, After: 2.0},
+		{Path: "supported_claims", Op: RegistryChangeRemoved, Before: "age_verification"},
+		{Path: "supported_claims", Op: RegistryChangeAdded, After: "employee_verification"},
+	}
+	if !reflect.DeepEqual(change.Diff, expected) {
+		t.Errorf("Expected diff %+v, got %+v", expected, change.Diff)
+	}
+	if !change.Behavioral {
+		t.Error("Expected a routing change to be behavioral")
+	}
+	if !reflect.DeepEqual(change.ClaimTypes, []string{"age_verification", "employee_verification", "student_verification"}) {
+		t.Errorf("Expected the claim types of both versions, got %v", change.ClaimTypes)
+	}
+	encoded, _ := json.Marshal(change)
+	if strings.Contains(string(encoded), "old-key") || strings.Contains(string(encoded), "new-key") {
+		t.Errorf("Expected credentials to be redacted, got %s", encoded)
+	}
+
+	rotated := *before
+	rotated.Auth = &DPProviderAuth{Method: AuthMethodAPIKey, APIKey: "new-key"}
+	rotated.Name = "Renamed"
+	if change := DiffDPProviders(before, &rotated); change == nil || change.Behavioral {
+		t.Errorf("Expected a credential rotation and rename to be operational, got %+v", change)
+	}
+	reordered := *before
+	reordered.SupportedClaims = []string{"student_verification", "age_verification"}
+	if change := DiffDPProviders(before, &reordered); change != nil {
+		t.Errorf("Expected reordered supported claims to be no change, got %+v", change.Diff)
+	}
+	if change := DiffDPProviders(nil, after); change.Action != RegistryChangeAdded || !change.Behavioral {
+		t.Errorf("Expected an added provider, got %+v", change)
+	}
+	if change := DiffDPProviders(before, nil); change.Action != RegistryChangeRemoved || change.Subject != "dp_1" {
+		t.Errorf("Expected a removed provider, got %+v", change)
+	}
+}
+
+func TestDiffClaimSchemas(t *testing.T) {
+	before := &ClaimTypeSchema{ClaimType: "age_verification", Description: "Age", RequiredIdentifiers: []string{"email"}}
+	described := *before
+	described.Description = "Age over a threshold"
+	if change := DiffClaimSchemas(before, &described); change == nil || change.Behavioral || change.Diff[0].Path != "description" {
+		t.Errorf("Expected a description change to be operational, got %+v", change)
+	}
+
+	stricter := *before
+	stricter.RequiredIdentifiers = []string{"email", "phone"}
+	change := DiffClaimSchemas(before, &stricter)
+	if change == nil || !change.Behavioral || !reflect.DeepEqual(change.ClaimTypes, []string{"age_verification"}) {
+		t.Fatalf("Expected a behavioral change of age_verification, got %+v", change)
+	}
+	if len(change.Diff) != 1 || change.Diff[0] != (FieldChange{Path: "required_identifiers", Op: RegistryChangeAdded, After: "phone"}) {
+		t.Errorf("Expected phone to be added to the required identifiers, got %+v", change.Diff)
+	}
+	if DiffClaimSchemas(before, before) != nil {
+		t.Error("Expected no change between equal schemas")
+	}
+}
+
+func TestRegistryChangeLog_RecordsAndNotifies(t *testing.T) {
+	notifications := newTestNotificationService()
+	stream, cancel := notifications.Stream("rp_1")
+	defer cancel()
+
+	store := NewVerificationRecordStore()
+	store.Append(&VerificationRecord{RPID: "rp_1", ClaimType: "age_verification"})
+	store.Append(&VerificationRecord{RPID: "rp_2", ClaimType: "student_verification"})
+
+	log := NewRegistryChangeLog()
+	log.SetNotifications(notifications, store.RPsForClaimTypes)
+
+	behavioral := DiffDPProviders(
+		&DPProvider{DPID: "dp_1", Endpoint: "http://dp1.example.com", SupportedClaims: []string{"age_verification"}},
+		&DPProvider{DPID: "dp_1", Endpoint: "http://dp1-new.example.com", SupportedClaims: []string{"age_verification"}},
+	)
+	operational := DiffDPProviders(
+		&DPProvider{DPID: "dp_2", Name: "Old", SupportedClaims: []string{"student_verification"}},
+		&DPProvider{DPID: "dp_2", Name: "New", SupportedClaims: []string{"student_verification"}},
+	)
+	if err := log.Record(" ", "operator-1", behavioral); err != ErrChangeReasonRequired {
+		t.Fatalf("Expected a reason to be required, got %v", err)
+	}
+	if err := log.Record("DP moved hosts", "operator-1", behavioral, operational); err != nil {
+		t.Fatalf("Expected the changes to be recorded, got %v", err)
+	}
+
+	if behavioral.ID == "" || behavioral.Reason != "DP moved hosts" || behavioral.Actor != "operator-1" {
+		t.Errorf("Expected the change to be completed, got %+v", behavioral)
+	}
+	if !reflect.DeepEqual(behavioral.NotifiedRPs, []string{"rp_1"}) || len(operational.NotifiedRPs) != 0 {
+		t.Errorf("Expected only rp_1 notified of the behavioral change, got %v and %v", behavioral.NotifiedRPs, operational.NotifiedRPs)
+	}
+	select {
+	case notification := <-stream:
+		if notification.Event != NotificationRegistryChanged || notification.ResourceID != behavioral.ID || strings.Contains(notification.Data["fields"].([]string)[0], "dp1-new") {
+			t.Errorf("Unexpected notification: %+v", notification)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected rp_1 to be notified")
+	}
+
+	if changes := log.List(RegistryChangeQuery{}); len(changes) != 2 || changes[0] != operational {
+		t.Errorf("Expected both changes newest first, got %+v", changes)
+	}
+	if changes := log.List(RegistryChangeQuery{ClaimType: "age_verification"}); len(changes) != 1 || changes[0] != behavioral {
+		t.Errorf("Expected the age_verification change, got %+v", changes)
+	}
+	if change, exists := log.Get(behavioral.ID); !exists || change != behavioral {
+		t.Errorf("Expected the change by ID, got %+v", change)
+	}
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// reportingDayFormat is the format of reporting days, which are UTC dates
+const reportingDayFormat = "2006-01-02"
+
+// reportingMemoryDays bounds the days of rollups kept in memory for the admin
+// API; the reporting table keeps the full history
+const reportingMemoryDays = 90
+
+// reportingSchema creates the reporting table and the views BI tools query.
+// The table is denormalized rollups, never joined to transactional tables.
+var reportingSchema = []string{
+	`CREATE TABLE IF NOT EXISTS reporting_verifications_daily (
+		day DATE NOT NULL,
+		claim_type VARCHAR(100) NOT NULL,
+		dp_id VARCHAR(255) NOT NULL,
+		status VARCHAR(50) NOT NULL,
+		verifications BIGINT NOT NULL,
+		verified BIGINT NOT NULL,
+		confidence_sum DOUBLE PRECISION NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (day, claim_type, dp_id, status)
+	)`,
+	`CREATE OR REPLACE VIEW reporting_verifications_by_day AS
+		SELECT day, claim_type, dp_id, status, verifications, verified,
+			confidence_sum / NULLIF(verifications, 0) AS avg_confidence
+		FROM reporting_verifications_daily`,
+}
+
+// reportingUpsert adds a batch of counts to a reporting row
+const reportingUpsert = `
+	INSERT INTO reporting_verifications_daily (day, claim_type, dp_id, status, verifications, verified, confidence_sum, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (day, claim_type, dp_id, status) DO UPDATE SET
+		verifications = reporting_verifications_daily.verifications + EXCLUDED.verifications,
+		verified = reporting_verifications_daily.verified + EXCLUDED.verified,
+		confidence_sum = reporting_verifications_daily.confidence_sum + EXCLUDED.confidence_sum,
+		updated_at = EXCLUDED.updated_at
+`
+
+// ReportingRow counts verifications by day, claim type, DP and status. It
+// carries no RP, subject or identifier data, so BI tools can read reports
+// without access to sensitive columns.
+type ReportingRow struct {
+	Day           string  `json:"day"`
+	ClaimType     string  `json:"claim_type"`
+	DPID          string  `json:"dp_id"`
+	Status        string  `json:"status"`
+	Verifications int64   `json:"verifications"`
+	Verified      int64   `json:"verified"`
+	AvgConfidence float64 `json:"avg_confidence"`
+}
+
+type reportingKey struct {
+	day, claimType, dpID, status string
+}
+
+type reportingCounts struct {
+	verifications, verified int64
+	confidenceSum           float64
+}
+
+func (c *reportingCounts) add(other reportingCounts) {
+	c.verifications += other.verifications
+	c.verified += other.verified
+	c.confidenceSum += other.confidenceSum
+}
+
+// ReportingService maintains the verification reporting rollups. Stored
+// verification records are counted as they arrive, and a background job
+// flushes the counts to the reporting table in REPORTING_DATABASE_URL, so
+// reports survive the record store's eviction and restarts. Without a
+// database the rollups are only kept in memory.
+type ReportingService struct {
+	db            *sql.DB
+	interval      time.Duration
+	anonymization *AnonymizationService
+
+	mu          sync.Mutex
+	pending     map[reportingKey]reportingCounts
+	totals      map[reportingKey]reportingCounts
+	schemaReady bool
+	flushedAt   time.Time
+	lastErr     error
+
+	// flushMu serializes flushes so a batch is never written twice
+	flushMu sync.Mutex
+	once    sync.Once
+	stop    chan struct{}
+}
+
+// NewReportingService creates a reporting service. The database connection
+// is opened lazily, so an unreachable database only delays the flushes.
+func NewReportingService(cfg *config.Config) *ReportingService {
+	service := &ReportingService{
+		interval: cfg.ReportingFlushInterval,
+		pending:  make(map[reportingKey]reportingCounts),
+		totals:   make(map[reportingKey]reportingCounts),
+	}
+	if cfg.ReportingDatabaseURL != "" {
+		db, err := sql.Open("postgres", cfg.ReportingDatabaseURL)
+		if err != nil {
+			fmt.Printf("REPORTING WARNING: invalid REPORTING_DATABASE_URL, keeping reports in memory: %v\n", err)
+		} else {
+			service.db = db
+		}
+	}
+	return service
+}
+
+// Observe counts a stored verification record; register it with the record
+// store's OnAppend
+func (s *ReportingService) Observe(record *VerificationRecord) {
+	counts := reportingCounts{verifications: 1, confidenceSum: record.ConfidenceScore}
+	if record.Verified {
+		counts.verified = 1
+	}
+	key := reportingKey{
+		day:       record.CreatedAt.UTC().Format(reportingDayFormat),
+		claimType: record.ClaimType,
+		dpID:      record.DPID,
+		status:    record.Status,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.pending[key]
+	entry.add(counts)
+	s.pending[key] = entry
+}
+
+// Flush writes the counts observed since the last flush to the reporting
+// table and the in-memory rollups. If the write fails the counts are kept
+// for the next flush.
+func (s *ReportingService) Flush(ctx context.Context) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = make(map[reportingKey]reportingCounts)
+	s.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := s.write(ctx, batch)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastErr = err
+	if err != nil {
+		for key, counts := range batch {
+			entry := s.pending[key]
+			entry.add(counts)
+			s.pending[key] = entry
+		}
+		return err
+	}
+	for key, counts := range batch {
+		entry := s.totals[key]
+		entry.add(counts)
+		s.totals[key] = entry
+	}
+	s.flushedAt = time.Now()
+	s.pruneLocked()
+	return nil
+}
+
+// write adds a batch to the reporting table in one transaction
+func (s *ReportingService) write(ctx context.Context, batch map[reportingKey]reportingCounts) error {
+	if s.db == nil {
+		return nil
+	}
+	if err := s.initSchema(ctx); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start reporting transaction: %w", err)
+	}
+	now := time.Now().UTC()
+	for key, counts := range batch {
+		if _, err := tx.ExecContext(ctx, reportingUpsert, key.day, key.claimType, key.dpID, key.status,
+			counts.verifications, counts.verified, counts.confidenceSum, now); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to write reporting rows: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit reporting rows: %w", err)
+	}
+	return nil
+}
+
+func (s *ReportingService) initSchema(ctx context.Context) error {
+	s.mu.Lock()
+	ready := s.schemaReady
+	s.mu.Unlock()
+	if ready {
+		return nil
+	}
+
+	for _, query := range reportingSchema {
+		if _, err := s.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to initialize reporting schema: %w", err)
+		}
+	}
+	s.mu.Lock()
+	s.schemaReady = true
+	s.mu.Unlock()
+	return nil
+}
+
+// pruneLocked drops in-memory rollups older than reportingMemoryDays
+func (s *ReportingService) pruneLocked() {
+	cutoff := time.Now().UTC().AddDate(0, 0, -reportingMemoryDays).Format(reportingDayFormat)
+	for key := range s.totals {
+		if key.day < cutoff {
+			delete(s.totals, key)
+		}
+	}
+}
+
+// Rows returns the flushed rollups for days between from and to inclusive,
+// given as YYYY-MM-DD; an empty bound is open
+func (s *ReportingService) Rows(from, to string) []ReportingRow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows := make([]ReportingRow, 0, len(s.totals))
+	for key, counts := range s.totals {
+		if (from != "" && key.day < from) || (to != "" && key.day > to) {
+			continue
+		}
+		rows = append(rows, ReportingRow{
+			Day:           key.day,
+			ClaimType:     key.claimType,
+			DPID:          key.dpID,
+			Status:        key.status,
+			Verifications: counts.verifications,
+			Verified:      counts.verified,
+			AvgConfidence: counts.confidenceSum / float64(counts.verifications),
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.ClaimType != b.ClaimType {
+			return a.ClaimType < b.ClaimType
+		}
+		if a.DPID != b.DPID {
+			return a.DPID < b.DPID
+		}
+		return a.Status < b.Status
+	})
+	return rows
+}
+
+// SetAnonymization runs reports through the verification_report
+// anonymization profile
+func (s *ReportingService) SetAnonymization(anonymization *AnonymizationService) {
+	s.anonymization = anonymization
+}
+
+// AnonymizedRows returns Rows as they may leave the broker, run through the
+// verification_report anonymization profile, with the manifest of the
+// anonymization applied
+func (s *ReportingService) AnonymizedRows(from, to string) ([]map[string]interface{}, *AnonymizationManifest, error) {
+	rows, err := anonymizationRows(s.Rows(from, to))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to prepare report: %w", err)
+	}
+	if rows == nil {
+		rows = make([]map[string]interface{}, 0)
+	}
+	if s.anonymization == nil {
+		return rows, nil, nil
+	}
+	return s.anonymization.Anonymize(ExportTypeVerificationReport, rows)
+}
+
+// GetReportingStats returns the state of the reporting job
+func (s *ReportingService) GetReportingStats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := map[string]interface{}{
+		"database":     s.db != nil,
+		"pending_rows": len(s.pending),
+		"rows":         len(s.totals),
+	}
+	if !s.flushedAt.IsZero() {
+		stats["flushed_at"] = s.flushedAt.UTC().Format(time.RFC3339)
+	}
+	if s.lastErr != nil {
+		stats["last_error"] = s.lastErr.Error()
+	}
+	return stats
+}
+
+// Start begins flushing on REPORTING_FLUSH_INTERVAL; a zero interval
+// leaves flushes to the drain and shutdown
+func (s *ReportingService) Start() {
+	if s.interval <= 0 {
+		return
+	}
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.stop = make(chan struct{})
+		stop := s.stop
+		s.mu.Unlock()
+		go s.run(stop)
+	})
+}
+
+// Stop ends periodic flushes
+func (s *ReportingService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Prevent flushes from starting after stop
+	s.once.Do(func() {})
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+func (s *ReportingService) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.Flush(context.Background()); err != nil {
+				fmt.Printf("REPORTING WARNING: %v\n", err)
+			}
+		}
+	}
+}
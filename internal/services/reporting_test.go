@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestReportingService_RollsUpRecords(t *testing.T) {
+	reporting := NewReportingService(&config.Config{})
+	store := NewVerificationRecordStore()
+	store.OnAppend(reporting.Observe)
+
+	// 23:30 EST the evening before utcDay
+	utcDay := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -2)
+	day := utcDay.Add(4*time.Hour + 30*time.Minute).In(time.FixedZone("EST", -5*3600))
+	today, yesterday := utcDay.Format("2006-01-02"), utcDay.AddDate(0, 0, -1).Format("2006-01-02")
+	store.Append(&VerificationRecord{VerificationID: "v1", RPID: "rp-1", UserID: "user-1", ClaimType: "student_verification", DPID: "dp-1", Status: "completed", Verified: true, ConfidenceScore: 0.9, CreatedAt: day})
+	store.Append(&VerificationRecord{VerificationID: "v2", RPID: "rp-2", UserID: "user-2", ClaimType: "student_verification", DPID: "dp-1", Status: "completed", ConfidenceScore: 0.5, CreatedAt: day})
+	store.Append(&VerificationRecord{VerificationID: "v3", RPID: "rp-1", UserID: "user-3", ClaimType: "age_verification", DPID: "dp-2", Status: "failed", CreatedAt: day.AddDate(0, 0, -1)})
+
+	if rows := reporting.Rows("", ""); len(rows) != 0 {
+		t.Fatalf("Expected no rows before a flush, got %+v", rows)
+	}
+	if err := reporting.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	rows := reporting.Rows("", "")
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %+v", rows)
+	}
+	if rows[0].Day != yesterday || rows[0].ClaimType != "age_verification" || rows[0].Verifications != 1 {
+		t.Errorf("Expected the failed verification first, got %+v", rows[0])
+	}
+	// Days are UTC dates, so an evening verification in EST counts on the UTC date
+	if row := rows[1]; row.Day != today || row.Verifications != 2 || row.Verified != 1 || math.Abs(row.AvgConfidence-0.7) > 1e-9 {
+		t.Errorf("Expected the student verifications rolled up, got %+v", row)
+	}
+
+	if rows := reporting.Rows(today, today); len(rows) != 1 || rows[0].DPID != "dp-1" {
+		t.Errorf("Expected only the rows for the requested day, got %+v", rows)
+	}
+
+	// Later verifications add to the flushed counts
+	store.Append(&VerificationRecord{VerificationID: "v4", RPID: "rp-3", ClaimType: "student_verification", DPID: "dp-1", Status: "completed", Verified: true, ConfidenceScore: 0.7, CreatedAt: day})
+	reporting.Flush(context.Background())
+	if rows := reporting.Rows(today, ""); len(rows) != 1 || rows[0].Verifications != 3 || rows[0].Verified != 2 {
+		t.Errorf("Expected the new verification added to the day, got %+v", rows)
+	}
+}
+
+func TestReportingService_KeepsCountsWhenFlushFails(t *testing.T) {
+	reporting := NewReportingService(&config.Config{})
+	db, err := sql.Open("postgres", "postgres://reporting@localhost/reporting?sslmode=disable")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.Close()
+	reporting.db = db
+
+	reporting.Observe(&VerificationRecord{ClaimType: "student_verification", DPID: "dp-1", Status: "completed", Verified: true, ConfidenceScore: 1, CreatedAt: time.Now()})
+	if err := reporting.Flush(context.Background()); err == nil {
+		t.Fatal("Expected the flush to fail on a closed database")
+	}
+	if rows := reporting.Rows("", ""); len(rows) != 0 {
+		t.Errorf("Expected no rows to be reported before they are written, got %+v", rows)
+	}
+	stats := reporting.GetReportingStats()
+	if stats["pending_rows"] != 1 || stats["last_error"] == nil {
+		t.Errorf("Expected the counts to be kept for the next flush, got %+v", stats)
+	}
+
+	// Once the database is back the kept counts are written
+	reporting.db = nil
+	if err := reporting.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if rows := reporting.Rows("", ""); len(rows) != 1 || rows[0].Verifications != 1 {
+		t.Errorf("Expected the kept counts to be flushed, got %+v", rows)
+	}
+}
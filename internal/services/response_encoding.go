@@ -0,0 +1,258 @@
+package services
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// ResponseFormat is an encoding RPs can negotiate for verification responses
+type ResponseFormat string
+
+// Response formats
+const (
+	// ResponseFormatJSON is the verification response as JSON
+	ResponseFormatJSON ResponseFormat = "json"
+	// ResponseFormatJWT is a signed JWT assertion of the response
+	ResponseFormatJWT ResponseFormat = "jwt"
+	// ResponseFormatCBOR is the response as deterministic CBOR
+	ResponseFormatCBOR ResponseFormat = "cbor"
+	// ResponseFormatCOSE is the CBOR response signed as a COSE_Sign1 message
+	ResponseFormatCOSE ResponseFormat = "cose"
+)
+
+// responseFormatMediaTypes maps Accept media types to formats
+var responseFormatMediaTypes = map[string]ResponseFormat{
+	"application/json": ResponseFormatJSON,
+	"application/*":    ResponseFormatJSON,
+	"*/*":              ResponseFormatJSON,
+	"application/jwt":  ResponseFormatJWT,
+	"application/cbor": ResponseFormatCBOR,
+	"application/cose": ResponseFormatCOSE,
+}
+
+// COSE header labels and algorithm (RFC 9052, RFC 8812)
+const (
+	coseHeaderAlg   = 1
+	coseHeaderKID   = 4
+	coseAlgRS256    = -257
+	coseSign1Tag    = 18
+	coseSigContext1 = "Signature1"
+)
+
+// ErrNotAcceptable is returned when an Accept header names no format the
+// broker produces
+var ErrNotAcceptable = errors.New("no acceptable response format")
+
+// coseEncMode encodes CBOR deterministically (RFC 8949 core deterministic
+// encoding) so signed payloads are reproducible
+var coseEncMode, _ = cbor.CoreDetEncOptions().EncMode()
+
+// ContentType returns the media type of a response in this format
+func (f ResponseFormat) ContentType() string {
+	switch f {
+	case ResponseFormatJWT:
+		return "application/jwt"
+	case ResponseFormatCBOR:
+		return "application/cbor"
+	case ResponseFormatCOSE:
+		return `application/cose; cose-type="cose-sign1"`
+	default:
+		return "application/json"
+	}
+}
+
+// NegotiateResponseFormat picks the format an Accept header prefers. Media
+// types are ranked by q value, then by their order in the header; an empty
+// header or a wildcard selects JSON.
+func NegotiateResponseFormat(accept string) (ResponseFormat, error) {
+	if strings.TrimSpace(accept) == "" {
+		return ResponseFormatJSON, nil
+	}
+
+	type candidate struct {
+		format  ResponseFormat
+		quality float64
+		order   int
+	}
+	var candidates []candidate
+	for i, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		format, ok := responseFormatMediaTypes[mediaType]
+		if !ok {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{format: format, quality: quality, order: i})
+	}
+	if len(candidates) == 0 {
+		return "", ErrNotAcceptable
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+	return candidates[0].format, nil
+}
+
+// SetSigner sets the attestation service that signs JWT and COSE responses
+func (s *ResponseFormatterService) SetSigner(signer *JWSAttestationService) {
+	s.signer = signer
+}
+
+// SelectFields returns the response fields an RP's template includes in a
+// format, keyed by the names the RP receives them under. Custom templates
+// that do not restrict a format fall back to the built-in template's field
+// list, so compact formats still leave out the metadata.
+func (s *ResponseFormatterService) SelectFields(response *models.VerificationResponse, format ResponseFormat, rpID string) (map[string]interface{}, error) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode response: %w", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	template := s.TemplateForRP(rpID)
+	include := template.fieldsFor(format)
+	if include == nil && !template.Builtin {
+		builtin, err := s.GetResponseTemplate("verification")
+		if err != nil {
+			return nil, err
+		}
+		include = builtin.fieldsFor(format)
+	}
+	return template.apply(fields, include), nil
+}
+
+// EncodeResponse encodes a verification response in a negotiated format,
+// shaped by the template of the RP it is issued to. Signed formats are
+// issued to the RP as audience and expire with the result.
+func (s *ResponseFormatterService) EncodeResponse(response *models.VerificationResponse, format ResponseFormat, audience string) ([]byte, error) {
+	if format == ResponseFormatJSON && !s.TemplateForRP(audience).shapes(format) {
+		return json.Marshal(response)
+	}
+
+	fields, err := s.SelectFields(response, format, audience)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case ResponseFormatJSON:
+		return json.Marshal(fields)
+	case ResponseFormatCBOR:
+		return coseEncMode.Marshal(fields)
+	case ResponseFormatJWT, ResponseFormatCOSE:
+		if s.signer == nil || s.signer.privateKey == nil {
+			return nil, fmt.Errorf("no signing key for %s responses", format)
+		}
+		s.addAssertionClaims(fields, response, audience)
+		if format == ResponseFormatJWT {
+			return s.signer.signJWT(fields)
+		}
+		payload, err := coseEncMode.Marshal(fields)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode COSE payload: %w", err)
+		}
+		return s.signer.signCOSE(payload)
+	default:
+		return nil, fmt.Errorf("unsupported response format: %s", format)
+	}
+}
+
+// addAssertionClaims adds the registered JWT claims (also used as the COSE
+// payload's claims) identifying the issuer, RP and validity
+func (s *ResponseFormatterService) addAssertionClaims(fields map[string]interface{}, response *models.VerificationResponse, audience string) {
+	now := time.Now()
+	expiresAt := now.Add(24 * time.Hour)
+	if resultExpiry, err := time.Parse(time.RFC3339, response.ExpiresAt); err == nil && resultExpiry.Before(expiresAt) {
+		expiresAt = resultExpiry
+	}
+
+	if s.config != nil && s.config.Issuer != "" {
+		fields["iss"] = s.config.Issuer
+	}
+	if audience != "" {
+		fields["aud"] = audience
+	}
+	fields["iat"] = now.Unix()
+	fields["exp"] = expiresAt.Unix()
+	if response.VerificationID != "" {
+		fields["jti"] = response.VerificationID
+	}
+}
+
+// signJWT signs claims as a compact JWT with the attestation key
+func (s *JWSAttestationService) signJWT(claims map[string]interface{}) ([]byte, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims(claims))
+	token.Header["kid"] = s.keyID
+	token.Header["typ"] = "JWT"
+	signed, err := token.SignedString(s.privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign JWT response: %w", err)
+	}
+	return []byte(signed), nil
+}
+
+// coseSign1 is the COSE_Sign1 structure (RFC 9052 section 4.2)
+type coseSign1 struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected map[int]interface{}
+	Payload     []byte
+	Signature   []byte
+}
+
+// signCOSE signs a CBOR payload as a tagged COSE_Sign1 message with the
+// attestation key (RS256)
+func (s *JWSAttestationService) signCOSE(payload []byte) ([]byte, error) {
+	protected, err := coseEncMode.Marshal(map[int]interface{}{coseHeaderAlg: coseAlgRS256})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode COSE header: %w", err)
+	}
+	toBeSigned, err := coseEncMode.Marshal([]interface{}{coseSigContext1, protected, []byte{}, payload})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode COSE signature structure: %w", err)
+	}
+	digest := sha256.Sum256(toBeSigned)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign COSE response: %w", err)
+	}
+
+	return coseEncMode.Marshal(cbor.Tag{
+		Number: coseSign1Tag,
+		Content: coseSign1{
+			Protected:   protected,
+			Unprotected: map[int]interface{}{coseHeaderKID: []byte(s.keyID)},
+			Payload:     payload,
+			Signature:   signature,
+		},
+	})
+}
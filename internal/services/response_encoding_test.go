@@ -0,0 +1,169 @@
+package services
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestNegotiateResponseFormat(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   ResponseFormat
+	}{
+		{"", ResponseFormatJSON},
+		{"*/*", ResponseFormatJSON},
+		{"application/json", ResponseFormatJSON},
+		{"application/jwt", ResponseFormatJWT},
+		{"application/cbor", ResponseFormatCBOR},
+		{`application/cose; cose-type="cose-sign1"`, ResponseFormatCOSE},
+		{"application/json;q=0.5, application/jwt", ResponseFormatJWT},
+		{"application/cbor, application/jwt", ResponseFormatCBOR},
+		{"text/html, application/jwt;q=0.1", ResponseFormatJWT},
+	}
+	for _, tt := range tests {
+		got, err := NegotiateResponseFormat(tt.accept)
+		if err != nil || got != tt.want {
+			t.Errorf("NegotiateResponseFormat(%q) = %s, %v; want %s", tt.accept, got, err, tt.want)
+		}
+	}
+
+	for _, accept := range []string{"text/html", "application/jwt;q=0"} {
+		if _, err := NegotiateResponseFormat(accept); err != ErrNotAcceptable {
+			t.Errorf("Expected %q to be not acceptable, got %v", accept, err)
+		}
+	}
+}
+
+func newEncodingTestResponse() *models.VerificationResponse {
+	return &models.VerificationResponse{
+		VerificationID:  "ver_123",
+		RequestID:       "req_123",
+		Status:          "verified",
+		Verified:        true,
+		ConfidenceScore: 0.95,
+		DPID:            "dp_university_001",
+		Timestamp:       time.Now().Format(time.RFC3339),
+		ExpiresAt:       time.Now().Add(time.Hour).Format(time.RFC3339),
+		ProcessingTime:  "1.5s",
+		Metadata:        map[string]interface{}{"jws_token": "eyJ..."},
+	}
+}
+
+func newEncodingTestFormatter(t *testing.T) (*ResponseFormatterService, *JWSAttestationService) {
+	cfg := &config.Config{Issuer: "https://pavilion-trust.com"}
+	signer := NewJWSAttestationService(cfg)
+	if signer.privateKey == nil {
+		t.Fatal("Expected an attestation key")
+	}
+	formatter := NewResponseFormatterService(cfg)
+	formatter.SetSigner(signer)
+	return formatter, signer
+}
+
+func TestResponseFormatter_EncodeJSONAndCBOR(t *testing.T) {
+	formatter, _ := newEncodingTestFormatter(t)
+	response := newEncodingTestResponse()
+
+	body, err := formatter.EncodeResponse(response, ResponseFormatJSON, "rp-1")
+	if err != nil {
+		t.Fatalf("EncodeResponse(json) failed: %v", err)
+	}
+	var decoded models.VerificationResponse
+	if err := json.Unmarshal(body, &decoded); err != nil || decoded.Metadata["jws_token"] != "eyJ..." {
+		t.Errorf("Expected the full JSON response, got %s", body)
+	}
+
+	body, err = formatter.EncodeResponse(response, ResponseFormatCBOR, "rp-1")
+	if err != nil {
+		t.Fatalf("EncodeResponse(cbor) failed: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := cbor.Unmarshal(body, &fields); err != nil {
+		t.Fatalf("Expected CBOR, got %v", err)
+	}
+	if fields["verified"] != true || fields["confidence_score"] != 0.95 || fields["dp_id"] != "dp_university_001" {
+		t.Errorf("Unexpected CBOR fields: %v", fields)
+	}
+	// The template leaves metadata and processing details out of compact formats
+	if _, ok := fields["metadata"]; ok {
+		t.Error("Expected metadata to be left out of CBOR responses")
+	}
+	if _, ok := fields["processing_time"]; ok {
+		t.Error("Expected processing_time to be left out of CBOR responses")
+	}
+}
+
+func TestResponseFormatter_EncodeJWT(t *testing.T) {
+	formatter, signer := newEncodingTestFormatter(t)
+
+	body, err := formatter.EncodeResponse(newEncodingTestResponse(), ResponseFormatJWT, "rp-1")
+	if err != nil {
+		t.Fatalf("EncodeResponse(jwt) failed: %v", err)
+	}
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(string(body), claims, func(token *jwt.Token) (interface{}, error) {
+		return signer.publicKey, nil
+	}, jwt.WithAudience("rp-1"), jwt.WithIssuer("https://pavilion-trust.com"))
+	if err != nil || !token.Valid {
+		t.Fatalf("Expected a valid signed assertion, got %v", err)
+	}
+	if token.Header["kid"] != signer.keyID || claims["verified"] != true || claims["jti"] != "ver_123" {
+		t.Errorf("Unexpected assertion: %v %v", token.Header, claims)
+	}
+	if _, ok := claims["metadata"]; ok {
+		t.Error("Expected metadata to be left out of the assertion")
+	}
+}
+
+func TestResponseFormatter_EncodeCOSE(t *testing.T) {
+	formatter, signer := newEncodingTestFormatter(t)
+
+	body, err := formatter.EncodeResponse(newEncodingTestResponse(), ResponseFormatCOSE, "rp-1")
+	if err != nil {
+		t.Fatalf("EncodeResponse(cose) failed: %v", err)
+	}
+
+	var tag cbor.RawTag
+	if err := cbor.Unmarshal(body, &tag); err != nil || tag.Number != coseSign1Tag {
+		t.Fatalf("Expected a tagged COSE_Sign1 message, got %v (%v)", tag.Number, err)
+	}
+	var message coseSign1
+	if err := cbor.Unmarshal(tag.Content, &message); err != nil {
+		t.Fatalf("Failed to decode COSE_Sign1: %v", err)
+	}
+	var protected map[int]interface{}
+	cbor.Unmarshal(message.Protected, &protected)
+	if protected[coseHeaderAlg] != int64(coseAlgRS256) {
+		t.Errorf("Expected RS256 in the protected header, got %v", protected)
+	}
+	if kid, _ := message.Unprotected[coseHeaderKID].([]byte); string(kid) != signer.keyID {
+		t.Errorf("Expected the attestation key ID, got %v", message.Unprotected)
+	}
+
+	toBeSigned, _ := coseEncMode.Marshal([]interface{}{coseSigContext1, message.Protected, []byte{}, message.Payload})
+	digest := sha256.Sum256(toBeSigned)
+	if err := rsa.VerifyPKCS1v15(signer.publicKey, crypto.SHA256, digest[:], message.Signature); err != nil {
+		t.Errorf("Expected the COSE signature to verify: %v", err)
+	}
+
+	var claims map[string]interface{}
+	if err := cbor.Unmarshal(message.Payload, &claims); err != nil || claims["aud"] != "rp-1" || claims["status"] != "verified" {
+		t.Errorf("Unexpected COSE payload: %v (%v)", claims, err)
+	}
+}
+
+func TestResponseFormatter_SignedFormatsNeedSigner(t *testing.T) {
+	formatter := NewResponseFormatterService(&config.Config{})
+	if _, err := formatter.EncodeResponse(newEncodingTestResponse(), ResponseFormatJWT, "rp-1"); err == nil {
+		t.Error("Expected signed formats to fail without a signer")
+	}
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/pavilion-trust/core-broker/internal/config"
@@ -14,8 +15,12 @@ type ResponseFormatterService struct {
 	config *config.Config
 	// Response validation
 	validator *ResponseValidator
-	// Response templates
-	templates map[string]*ResponseTemplate
+	// Response templates, and the template assigned to each RP
+	mu          sync.RWMutex
+	templates   map[string]*ResponseTemplate
+	rpTemplates map[string]string
+	// Signs JWT and COSE responses
+	signer *JWSAttestationService
 }
 
 // ResponseValidator validates formatted responses
@@ -30,6 +35,19 @@ type ResponseTemplate struct {
 	Fields       map[string]FieldSpec   `json:"fields"`
 	Required     []string               `json:"required"`
 	Optional     []string               `json:"optional"`
+	// Formats lists the response fields included in each negotiated format;
+	// a format without a list gets the Include fields
+	Formats      map[string][]string    `json:"formats,omitempty"`
+	// Include lists the response fields included in formats without their
+	// own list; empty includes every field
+	Include      []string               `json:"include,omitempty"`
+	// Rename maps response fields to the names the RP receives them under
+	Rename       map[string]string      `json:"rename,omitempty"`
+	// MetadataKeys, when set, is the whitelist of metadata keys returned
+	MetadataKeys []string               `json:"metadata_keys,omitempty"`
+	// RPIDs are the RPs whose responses use this template
+	RPIDs        []string               `json:"rp_ids,omitempty"`
+	Builtin      bool                   `json:"builtin,omitempty"`
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
 }
 
@@ -67,15 +85,28 @@ func NewResponseFormatterService(cfg *config.Config) *ResponseFormatterService {
 		validator: &ResponseValidator{
 			rules: make(map[string]ValidationRule),
 		},
-		templates: make(map[string]*ResponseTemplate),
+		templates:   make(map[string]*ResponseTemplate),
+		rpTemplates: make(map[string]string),
 	}
 
 	// Initialize response templates
 	service.initializeTemplates()
+	if cfg != nil && cfg.ResponseTemplatesFile != "" {
+		if err := service.LoadTemplatesFile(cfg.ResponseTemplatesFile); err != nil {
+			fmt.Printf("RESPONSE TEMPLATE WARNING: %v; later templates in the file were not registered\n", err)
+		}
+	}
 
 	return service
 }
 
+// verificationAssertionFields are the response fields in signed and CBOR
+// verification responses
+var verificationAssertionFields = []string{
+	"verification_id", "request_id", "status", "verified", "confidence_score", "reason",
+	"evidence", "dp_id", "audit_reference", "timestamp", "expires_at", "response_hash",
+}
+
 // initializeTemplates sets up response formatting templates
 func (s *ResponseFormatterService) initializeTemplates() {
 	// Standard verification response template
@@ -145,6 +176,14 @@ func (s *ResponseFormatterService) initializeTemplates() {
 		},
 		Required: []string{"request_id", "status", "verified", "confidence", "dp_id", "timestamp"},
 		Optional: []string{"reason", "evidence", "expiration_time", "processing_time", "request_hash", "response_hash", "metadata"},
+		// Compact formats carry the result and drop the metadata, which holds
+		// the JSON response's own JWS attestation
+		Formats: map[string][]string{
+			string(ResponseFormatJWT):  verificationAssertionFields,
+			string(ResponseFormatCBOR): verificationAssertionFields,
+			string(ResponseFormatCOSE): verificationAssertionFields,
+		},
+		Builtin: true,
 		Metadata: map[string]interface{}{
 			"version": "1.0",
 			"format":  "json",
@@ -324,6 +363,9 @@ func (s *ResponseFormatterService) ValidateResponseIntegrity(response *Formatted
 
 // GetResponseTemplate returns a response template by name
 func (s *ResponseFormatterService) GetResponseTemplate(templateName string) (*ResponseTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	template, exists := s.templates[templateName]
 	if !exists {
 		return nil, fmt.Errorf("template not found: %s", templateName)
@@ -333,6 +375,9 @@ func (s *ResponseFormatterService) GetResponseTemplate(templateName string) (*Re
 
 // ListTemplates lists all available response templates
 func (s *ResponseFormatterService) ListTemplates() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	templates := make([]string, 0, len(s.templates))
 	for name := range s.templates {
 		templates = append(templates, name)
@@ -342,10 +387,11 @@ func (s *ResponseFormatterService) ListTemplates() []string {
 
 // GetFormattedResponseStats returns response formatting statistics
 func (s *ResponseFormatterService) GetFormattedResponseStats() map[string]interface{} {
+	templates := s.ListTemplates()
 	return map[string]interface{}{
 		"service_status": "active",
-		"templates_count": len(s.templates),
-		"available_templates": s.ListTemplates(),
+		"templates_count": len(templates),
+		"available_templates": templates,
 		"validation_enabled": true,
 		"integrity_checking_enabled": true,
 	}
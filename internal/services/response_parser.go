@@ -50,6 +50,7 @@ type ParsedResponse struct {
 	IntegrityHash   string                 `json:"integrity_hash,omitempty"`
 	Metadata        map[string]interface{} `json:"metadata,omitempty"`
 	ValidationErrors []string              `json:"validation_errors,omitempty"`
+	PartialResults  *models.PartialResults `json:"partial_results,omitempty"`
 }
 
 // NewResponseParserService creates a new response parser service
@@ -81,7 +82,7 @@ func (s *ResponseParserService) initializeValidationRules() {
 			FieldName:     "status",
 			Required:      true,
 			Type:          "string",
-			AllowedValues: []string{"verified", "not_verified", "pending", "failed", "timeout"},
+			AllowedValues: []string{"verified", "not_verified", "partial", "pending", "failed", "timeout"},
 		},
 		"verified": {
 			FieldName: "verified",
@@ -119,9 +120,10 @@ func (s *ResponseParserService) ParseDPResponse(dpResp *DPResponse) (*ParsedResp
 	// Create parsed response
 	parsed := &ParsedResponse{
 		JobID:      dpResp.JobID,
-		Status:     dpResp.Status,
+		Status:     string(dpResp.Status),
 		Timestamp:  dpResp.Timestamp,
 		Metadata:   dpResp.Metadata,
+		PartialResults: dpResp.PartialResults,
 	}
 
 	// Extract verification result
@@ -132,14 +134,27 @@ func (s *ResponseParserService) ParseDPResponse(dpResp *DPResponse) (*ParsedResp
 		parsed.Evidence = dpResp.VerificationResult.Evidence
 	}
 
-	// Set DP ID
-	parsed.DPID = "dp-connector"
+	// Set DP ID from the routed provider, falling back to response metadata
+	parsed.DPID = dpResp.DPID
+	if parsed.DPID == "" {
+		if dpID, ok := dpResp.Metadata["dp_id"].(string); ok && dpID != "" {
+			parsed.DPID = dpID
+		} else {
+			parsed.DPID = DefaultDPProviderID
+		}
+	}
 
-	// Set expiration time (24 hours from timestamp)
+	// Set expiration time from the claim type's freshness policy, or 24
+	// hours from timestamp; results that never expire have none
 	if parsed.Timestamp != "" {
 		if timestamp, err := time.Parse(time.RFC3339, parsed.Timestamp); err == nil {
 			expiration := timestamp.Add(24 * time.Hour)
-			parsed.ExpirationTime = expiration.Format(time.RFC3339)
+			if dpResp.Freshness != nil {
+				expiration = dpResp.Freshness.ExpiresAt(timestamp)
+			}
+			if !expiration.IsZero() {
+				parsed.ExpirationTime = expiration.Format(time.RFC3339)
+			}
 		}
 	}
 
@@ -366,6 +381,7 @@ func (s *ResponseParserService) ConvertToDPResponse(parsed *ParsedResponse) *mod
 		Reason:         parsed.Reason,
 		DPID:           parsed.DPID,
 		Timestamp:      parsed.Timestamp,
+		PartialResults: parsed.PartialResults,
 	}
 }
 
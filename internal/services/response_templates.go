@@ -0,0 +1,266 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ResponseTemplatesFile is the on-disk format of templates registered at
+// startup
+type ResponseTemplatesFile struct {
+	Templates []*ResponseTemplate `json:"templates"`
+}
+
+// verificationResponseFields are the verification response fields templates
+// can include and rename, by their JSON names
+var verificationResponseFields = map[string]bool{
+	"verification_id": true, "status": true, "verified": true, "confidence_score": true,
+	"reason": true, "evidence": true, "dp_id": true, "attestation": true,
+	"audit_reference": true, "timestamp": true, "expires_at": true, "request_id": true,
+	"processing_time": true, "request_hash": true, "response_hash": true, "metadata": true,
+	"validation_errors": true, "error": true,
+}
+
+// reservedAssertionClaims are added to signed responses, so no field may be
+// renamed to them
+var reservedAssertionClaims = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true, "iat": true, "jti": true,
+}
+
+// RegisterTemplate adds a custom template, or replaces the custom template
+// of the same name, and assigns it to its RPs. Each RP uses one template, so
+// an RP listed by another template is moved to this one. Built-in templates
+// cannot be replaced.
+func (s *ResponseFormatterService) RegisterTemplate(template *ResponseTemplate) (*ResponseTemplate, error) {
+	if template == nil || template.TemplateName == "" {
+		return nil, fmt.Errorf("template_name is required")
+	}
+	if err := template.validate(); err != nil {
+		return nil, fmt.Errorf("template %s: %w", template.TemplateName, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, exists := s.templates[template.TemplateName]; exists && existing.Builtin {
+		return nil, fmt.Errorf("built-in template %s cannot be replaced", template.TemplateName)
+	}
+	registered := *template
+	registered.Builtin = false
+	registered.RPIDs = append([]string(nil), template.RPIDs...)
+
+	s.unassignLocked(template.TemplateName)
+	for _, rpID := range registered.RPIDs {
+		if previous, assigned := s.rpTemplates[rpID]; assigned {
+			s.removeRPLocked(previous, rpID)
+		}
+		s.rpTemplates[rpID] = registered.TemplateName
+	}
+	s.templates[registered.TemplateName] = &registered
+	return &registered, nil
+}
+
+// UnregisterTemplate removes a custom template; its RPs go back to the
+// built-in verification template
+func (s *ResponseFormatterService) UnregisterTemplate(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	template, exists := s.templates[name]
+	if !exists {
+		return fmt.Errorf("template not found: %s", name)
+	}
+	if template.Builtin {
+		return fmt.Errorf("built-in template %s cannot be removed", name)
+	}
+	s.unassignLocked(name)
+	delete(s.templates, name)
+	return nil
+}
+
+// unassignLocked drops the RP assignments of a template
+func (s *ResponseFormatterService) unassignLocked(name string) {
+	for rpID, assigned := range s.rpTemplates {
+		if assigned == name {
+			delete(s.rpTemplates, rpID)
+		}
+	}
+}
+
+// removeRPLocked takes an RP off a template's RP list. Registered templates
+// are never modified in place, since callers may hold them.
+func (s *ResponseFormatterService) removeRPLocked(name, rpID string) {
+	template, exists := s.templates[name]
+	if !exists {
+		return
+	}
+	updated := *template
+	updated.RPIDs = make([]string, 0, len(template.RPIDs))
+	for _, id := range template.RPIDs {
+		if id != rpID {
+			updated.RPIDs = append(updated.RPIDs, id)
+		}
+	}
+	s.templates[name] = &updated
+}
+
+// TemplateForRP returns the template an RP's verification responses use:
+// the template assigned to it, or the built-in verification template
+func (s *ResponseFormatterService) TemplateForRP(rpID string) *ResponseTemplate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if name, assigned := s.rpTemplates[rpID]; assigned {
+		return s.templates[name]
+	}
+	return s.templates["verification"]
+}
+
+// ResponseTemplates returns the built-in templates followed by the custom
+// ones by name
+func (s *ResponseFormatterService) ResponseTemplates() []*ResponseTemplate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	templates := make([]*ResponseTemplate, 0, len(s.templates))
+	for _, template := range s.templates {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Builtin != templates[j].Builtin {
+			return templates[i].Builtin
+		}
+		return templates[i].TemplateName < templates[j].TemplateName
+	})
+	return templates
+}
+
+// LoadTemplatesFile registers the templates of a templates file
+func (s *ResponseFormatterService) LoadTemplatesFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read response templates file: %w", err)
+	}
+
+	var file ResponseTemplatesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse response templates file: %w", err)
+	}
+
+	for _, template := range file.Templates {
+		if _, err := s.RegisterTemplate(template); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks that a custom template names known fields and formats,
+// and that renamed fields stay distinct
+func (t *ResponseTemplate) validate() error {
+	for _, field := range t.Include {
+		if !verificationResponseFields[field] {
+			return fmt.Errorf("unknown field %q", field)
+		}
+	}
+	for format, fields := range t.Formats {
+		if _, known := responseFormatMediaTypes["application/"+format]; !known {
+			return fmt.Errorf("unknown format %q", format)
+		}
+		for _, field := range fields {
+			if !verificationResponseFields[field] {
+				return fmt.Errorf("unknown field %q in format %s", field, format)
+			}
+		}
+	}
+
+	names := make(map[string]string, len(verificationResponseFields))
+	for field := range verificationResponseFields {
+		names[field] = field
+	}
+	for field, renamed := range t.Rename {
+		if !verificationResponseFields[field] {
+			return fmt.Errorf("cannot rename unknown field %q", field)
+		}
+		if renamed == "" || reservedAssertionClaims[renamed] {
+			return fmt.Errorf("cannot rename %s to %q", field, renamed)
+		}
+		names[field] = renamed
+	}
+	seen := make(map[string]string, len(names))
+	for field, name := range names {
+		if other, taken := seen[name]; taken {
+			return fmt.Errorf("fields %s and %s would both be named %q", other, field, name)
+		}
+		seen[name] = field
+	}
+
+	for _, key := range t.MetadataKeys {
+		if key == "" {
+			return fmt.Errorf("metadata keys cannot be empty")
+		}
+	}
+	for _, rpID := range t.RPIDs {
+		if rpID == "" {
+			return fmt.Errorf("rp_ids cannot be empty")
+		}
+	}
+	return nil
+}
+
+// fieldsFor returns the fields a template includes in a format: the
+// format's own list, then Include. It returns nil when the template does not
+// restrict the format.
+func (t *ResponseTemplate) fieldsFor(format ResponseFormat) []string {
+	if fields, ok := t.Formats[string(format)]; ok {
+		return fields
+	}
+	return t.Include
+}
+
+// shapes reports whether the template changes a format's fields at all
+func (t *ResponseTemplate) shapes(format ResponseFormat) bool {
+	return t.fieldsFor(format) != nil || len(t.Rename) > 0 || t.MetadataKeys != nil
+}
+
+// apply filters fields to those included in a format, applies the metadata
+// whitelist and renames the remaining fields
+func (t *ResponseTemplate) apply(fields map[string]interface{}, include []string) map[string]interface{} {
+	if include != nil {
+		filtered := make(map[string]interface{}, len(include))
+		for _, name := range include {
+			if value, ok := fields[name]; ok {
+				filtered[name] = value
+			}
+		}
+		fields = filtered
+	}
+
+	if metadata, ok := fields["metadata"].(map[string]interface{}); ok && t.MetadataKeys != nil {
+		allowed := make(map[string]interface{}, len(t.MetadataKeys))
+		for _, key := range t.MetadataKeys {
+			if value, ok := metadata[key]; ok {
+				allowed[key] = value
+			}
+		}
+		if len(allowed) == 0 {
+			delete(fields, "metadata")
+		} else {
+			fields["metadata"] = allowed
+		}
+	}
+
+	if len(t.Rename) == 0 {
+		return fields
+	}
+	renamed := make(map[string]interface{}, len(fields))
+	for field, value := range fields {
+		if name, ok := t.Rename[field]; ok {
+			field = name
+		}
+		renamed[field] = value
+	}
+	return renamed
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestResponseTemplates_ShapeRPResponses(t *testing.T) {
+	formatter, _ := newEncodingTestFormatter(t)
+	response := newEncodingTestResponse()
+	response.Metadata["claim_type"] = "age_verification"
+
+	_, err := formatter.RegisterTemplate(&ResponseTemplate{
+		TemplateName: "compact",
+		Include:      []string{"verification_id", "verified", "confidence_score", "metadata"},
+		Rename:       map[string]string{"confidence_score": "score", "verification_id": "id"},
+		MetadataKeys: []string{"claim_type"},
+		RPIDs:        []string{"rp-1"},
+	})
+	if err != nil {
+		t.Fatalf("RegisterTemplate failed: %v", err)
+	}
+
+	body, err := formatter.EncodeResponse(response, ResponseFormatJSON, "rp-1")
+	if err != nil {
+		t.Fatalf("EncodeResponse failed: %v", err)
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(body, &fields)
+	if len(fields) != 4 || fields["id"] != "ver_123" || fields["score"] != 0.95 || fields["verified"] != true {
+		t.Errorf("Expected the included fields under their new names, got %s", body)
+	}
+	if metadata, _ := fields["metadata"].(map[string]interface{}); len(metadata) != 1 || metadata["claim_type"] != "age_verification" {
+		t.Errorf("Expected only whitelisted metadata, got %v", fields["metadata"])
+	}
+
+	// Other RPs keep the built-in template
+	body, _ = formatter.EncodeResponse(response, ResponseFormatJSON, "rp-2")
+	json.Unmarshal(body, &fields)
+	if fields["confidence_score"] != 0.95 || fields["metadata"].(map[string]interface{})["jws_token"] != "eyJ..." {
+		t.Errorf("Expected the full response for other RPs, got %s", body)
+	}
+}
+
+func TestResponseTemplates_CompactFormatsKeepBuiltinFields(t *testing.T) {
+	formatter, _ := newEncodingTestFormatter(t)
+	formatter.RegisterTemplate(&ResponseTemplate{
+		TemplateName: "renamed",
+		Rename:       map[string]string{"dp_id": "provider"},
+		RPIDs:        []string{"rp-1"},
+	})
+
+	body, err := formatter.EncodeResponse(newEncodingTestResponse(), ResponseFormatCBOR, "rp-1")
+	if err != nil {
+		t.Fatalf("EncodeResponse failed: %v", err)
+	}
+	var fields map[string]interface{}
+	cbor.Unmarshal(body, &fields)
+	if fields["provider"] != "dp_university_001" || fields["dp_id"] != nil {
+		t.Errorf("Expected dp_id renamed, got %v", fields)
+	}
+	if _, ok := fields["metadata"]; ok {
+		t.Error("Expected compact formats to leave out metadata without a field list")
+	}
+}
+
+func TestResponseTemplates_Registration(t *testing.T) {
+	formatter := NewResponseFormatterService(&config.Config{})
+
+	invalid := []*ResponseTemplate{
+		{TemplateName: "verification"},
+		{TemplateName: "unknown_field", Include: []string{"ssn"}},
+		{TemplateName: "unknown_format", Formats: map[string][]string{"xml": {"verified"}}},
+		{TemplateName: "reserved", Rename: map[string]string{"dp_id": "iss"}},
+		{TemplateName: "collision", Rename: map[string]string{"dp_id": "status"}},
+	}
+	for _, template := range invalid {
+		if _, err := formatter.RegisterTemplate(template); err == nil {
+			t.Errorf("Expected template %s to be rejected", template.TemplateName)
+		}
+	}
+
+	// Swapping two names is allowed
+	if _, err := formatter.RegisterTemplate(&ResponseTemplate{TemplateName: "swap", Rename: map[string]string{"status": "reason", "reason": "status"}}); err != nil {
+		t.Errorf("Expected swapped names to be accepted: %v", err)
+	}
+
+	formatter.RegisterTemplate(&ResponseTemplate{TemplateName: "a", RPIDs: []string{"rp-1", "rp-2"}})
+	formatter.RegisterTemplate(&ResponseTemplate{TemplateName: "b", RPIDs: []string{"rp-2"}})
+	if formatter.TemplateForRP("rp-2").TemplateName != "b" || formatter.TemplateForRP("rp-1").TemplateName != "a" {
+		t.Error("Expected an RP to move to the template that last listed it")
+	}
+	if template, _ := formatter.GetResponseTemplate("a"); len(template.RPIDs) != 1 {
+		t.Errorf("Expected rp-2 taken off template a, got %v", template.RPIDs)
+	}
+
+	if err := formatter.UnregisterTemplate("verification"); err == nil {
+		t.Error("Expected the built-in template to be kept")
+	}
+	if err := formatter.UnregisterTemplate("b"); err != nil || formatter.TemplateForRP("rp-2").TemplateName != "verification" {
+		t.Errorf("Expected rp-2 back on the built-in template, got %v", err)
+	}
+}
+
+func TestResponseTemplates_LoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.json")
+	os.WriteFile(path, []byte(`{"templates": [{"template_name": "minimal", "include": ["verified"], "rp_ids": ["rp-1"]}]}`), 0o600)
+
+	formatter := NewResponseFormatterService(&config.Config{ResponseTemplatesFile: path})
+	if formatter.TemplateForRP("rp-1").TemplateName != "minimal" {
+		t.Fatal("Expected the template from the file to be assigned to rp-1")
+	}
+	if templates := formatter.ResponseTemplates(); len(templates) != 2 || !templates[0].Builtin {
+		t.Errorf("Expected the built-in template listed first, got %d templates", len(templates))
+	}
+}
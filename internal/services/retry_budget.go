@@ -0,0 +1,183 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryBudget caps retries to a fraction of requests within a window so that
+// a struggling DP is not hit by a retry storm. A minimum number of retries is
+// always allowed so low-traffic periods can still recover from blips.
+type RetryBudget struct {
+	mu          sync.Mutex
+	ratio       float64
+	minRetries  int
+	window      time.Duration
+	windowStart time.Time
+	requests    int
+	retries     int
+	exhausted   int64
+}
+
+// NewRetryBudget creates a budget allowing ratio retries per request, and at
+// least minRetries, in each window
+func NewRetryBudget(ratio float64, minRetries int, window time.Duration) *RetryBudget {
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+	return &RetryBudget{
+		ratio:       ratio,
+		minRetries:  minRetries,
+		window:      window,
+		windowStart: time.Now(),
+	}
+}
+
+// RecordRequest counts an original (non-retry) request in the current window
+func (b *RetryBudget) RecordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollLocked(time.Now())
+	b.requests++
+}
+
+// TryRetry reserves a retry if the budget allows it
+func (b *RetryBudget) TryRetry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollLocked(time.Now())
+	if b.retries >= b.allowedLocked() {
+		b.exhausted++
+		return false
+	}
+	b.retries++
+	return true
+}
+
+// rollLocked starts a new window once the current one has elapsed
+func (b *RetryBudget) rollLocked(now time.Time) {
+	if now.Sub(b.windowStart) >= b.window {
+		b.windowStart = now
+		b.requests = 0
+		b.retries = 0
+	}
+}
+
+func (b *RetryBudget) allowedLocked() int {
+	allowed := int(float64(b.requests) * b.ratio)
+	if allowed < b.minRetries {
+		allowed = b.minRetries
+	}
+	return allowed
+}
+
+// GetStats returns the budget usage for the current window
+func (b *RetryBudget) GetStats() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollLocked(time.Now())
+	return map[string]interface{}{
+		"ratio":           b.ratio,
+		"min_retries":     b.minRetries,
+		"window":          b.window.String(),
+		"window_requests": b.requests,
+		"window_retries":  b.retries,
+		"allowed_retries": b.allowedLocked(),
+		"exhausted_total": b.exhausted,
+	}
+}
+
+// fullJitter returns a random delay in [0, delay), spreading retries from
+// many clients instead of having them arrive in lockstep
+func fullJitter(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay)))
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date. It returns false when the header is absent or malformed.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// maxDrainBytes bounds how much of a response body is read to free its
+// connection for reuse; larger bodies are closed with the connection
+const maxDrainBytes = 64 << 10
+
+// bufferRequestBody reads a request body that GetBody cannot recreate into
+// memory, so every attempt can resend it
+func bufferRequestBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+	payload, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(payload)), nil
+	}
+	req.Body, _ = req.GetBody()
+	req.ContentLength = int64(len(payload))
+	return nil
+}
+
+// closeResponseBody drains what is left of a response body, up to
+// maxDrainBytes, and closes it
+func closeResponseBody(resp *http.Response) {
+	io.CopyN(io.Discard, resp.Body, maxDrainBytes)
+	resp.Body.Close()
+}
+
+// rewindRequest returns a request for the given attempt. The first attempt
+// uses the original request; later attempts get a fresh body from GetBody so
+// retried POSTs resend the full payload.
+func rewindRequest(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("request body cannot be rewound for retry")
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rewind request body: %w", err)
+	}
+	retry := req.Clone(req.Context())
+	retry.Body = body
+	return retry, nil
+}
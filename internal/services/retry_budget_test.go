@@ -0,0 +1,297 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestRetryBudget(t *testing.T) {
+	budget := NewRetryBudget(0.5, 1, time.Minute)
+
+	// The minimum applies before any traffic
+	if !budget.TryRetry() {
+		t.Fatal("Expected minimum retry to be allowed")
+	}
+	if budget.TryRetry() {
+		t.Fatal("Expected budget to be exhausted")
+	}
+
+	for i := 0; i < 4; i++ {
+		budget.RecordRequest()
+	}
+	if !budget.TryRetry() {
+		t.Fatal("Expected retry within ratio to be allowed")
+	}
+	if budget.TryRetry() {
+		t.Fatal("Expected retries to be capped at half the requests")
+	}
+
+	stats := budget.GetStats()
+	if stats["exhausted_total"].(int64) != 2 {
+		t.Errorf("Expected 2 exhausted retries, got %v", stats["exhausted_total"])
+	}
+}
+
+func TestRetryBudget_WindowResets(t *testing.T) {
+	budget := NewRetryBudget(0, 1, 20*time.Millisecond)
+	budget.TryRetry()
+	if budget.TryRetry() {
+		t.Fatal("Expected budget to be exhausted")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !budget.TryRetry() {
+		t.Error("Expected budget to reset in the next window")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 8, 2, 7, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	}
+
+	for _, tt := range tests {
+		delay, ok := parseRetryAfter(tt.value, now)
+		if ok != tt.ok || delay != tt.expected {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; expected %v, %v", tt.value, delay, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestFullJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if delay := fullJitter(time.Second); delay < 0 || delay >= time.Second {
+			t.Fatalf("Jittered delay %v out of range", delay)
+		}
+	}
+	if fullJitter(0) != 0 {
+		t.Error("Expected zero delay to stay zero")
+	}
+}
+
+func TestRewindRequest(t *testing.T) {
+	req, _ := http.NewRequest("POST", "http://example.com", bytes.NewReader([]byte("payload")))
+	io.ReadAll(req.Body)
+
+	retry, err := rewindRequest(req, 1)
+	if err != nil {
+		t.Fatalf("Expected rewind to succeed, got %v", err)
+	}
+	body, _ := io.ReadAll(retry.Body)
+	if string(body) != "payload" {
+		t.Errorf("Expected rewound body, got %q", body)
+	}
+
+	req.GetBody = nil
+	if _, err := rewindRequest(req, 1); err == nil {
+		t.Error("Expected error for a body without GetBody")
+	}
+}
+
+// newRetryTestService returns a service with fast, deterministic retries
+func newRetryTestService() *DPConnectorService {
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: "http://localhost:1"})
+	service.retryConfig.BaseDelay = time.Millisecond
+	service.retryConfig.MaxDelay = 50 * time.Millisecond
+	service.retryConfig.Jitter = false
+	return service
+}
+
+func TestExecuteWithRetry_RewindsBodyAndHonorsRetryAfter(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	service := newRetryTestService()
+	service.retryConfig.BaseDelay = time.Hour // Retry-After must override backoff
+	service.retryConfig.MaxDelay = time.Hour
+
+	req, _ := http.NewRequest("POST", server.URL, bytes.NewReader([]byte(`{"claim":"age"}`)))
+	err := service.executeWithRetry(context.Background(), service.client, req, nil, func(resp *http.Response) error { return nil })
+	if err != nil {
+		t.Fatalf("Expected retries to succeed, got %v", err)
+	}
+
+	if len(bodies) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(bodies))
+	}
+	for i, body := range bodies {
+		if body != `{"claim":"age"}` {
+			t.Errorf("Attempt %d sent body %q", i+1, body)
+		}
+	}
+}
+
+func TestExecuteWithRetry_RetryAfterBeyondMaxDelay(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	service := newRetryTestService()
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	err := service.executeWithRetry(context.Background(), service.client, req, nil, func(resp *http.Response) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "Retry-After") {
+		t.Fatalf("Expected Retry-After error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no retries, got %d calls", calls)
+	}
+}
+
+func TestExecuteWithRetry_BudgetExhausted(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	service := newRetryTestService()
+	service.retryConfig.Budget = NewRetryBudget(0, 1, time.Minute)
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	err := service.executeWithRetry(context.Background(), service.client, req, nil, func(resp *http.Response) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "retry budget exhausted") {
+		t.Fatalf("Expected budget error, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected original request plus one budgeted retry, got %d calls", calls)
+	}
+
+	if _, ok := service.GetRetryStats()["budget"]; !ok {
+		t.Error("Expected budget in retry stats")
+	}
+}
+
+func TestExecuteWithRetry_BuffersUnrewindableBody(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	service := newRetryTestService()
+	// A body http.NewRequest cannot give a GetBody for
+	req, _ := http.NewRequest("POST", server.URL, io.MultiReader(strings.NewReader(`{"claim":`), strings.NewReader(`"age"}`)))
+	if req.GetBody != nil {
+		t.Fatal("Expected a request without GetBody")
+	}
+	err := service.executeWithRetry(context.Background(), service.client, req, nil, func(resp *http.Response) error { return nil })
+	if err != nil {
+		t.Fatalf("Expected retries to succeed, got %v", err)
+	}
+	if len(bodies) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(bodies))
+	}
+	for i, body := range bodies {
+		if body != `{"claim":"age"}` {
+			t.Errorf("Attempt %d sent body %q", i+1, body)
+		}
+	}
+}
+
+// trackedBody records whether a response body was closed
+type trackedBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+// trackingTransport answers with the given statuses in turn, checking that
+// every earlier response body was closed before the next attempt
+type trackingTransport struct {
+	t        *testing.T
+	statuses []int
+	bodies   []*trackedBody
+	requests []*http.Request
+}
+
+func (tr *trackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for i, body := range tr.bodies {
+		if !body.closed {
+			tr.t.Errorf("Response %d was still open when attempt %d was sent", i+1, len(tr.bodies)+1)
+		}
+	}
+	if req.Body != nil {
+		io.ReadAll(req.Body)
+		req.Body.Close()
+	}
+	tr.requests = append(tr.requests, req)
+	body := &trackedBody{Reader: strings.NewReader(strings.Repeat("x", 1024))}
+	tr.bodies = append(tr.bodies, body)
+	return &http.Response{StatusCode: tr.statuses[len(tr.bodies)-1], Header: http.Header{}, Body: body, Request: req}, nil
+}
+
+func TestExecuteWithRetry_ClosesBodyPerAttempt(t *testing.T) {
+	for _, handlerErr := range []error{nil, errors.New("bad response")} {
+		transport := &trackingTransport{t: t, statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}}
+		client := &http.Client{Transport: transport}
+
+		service := newRetryTestService()
+		req, _ := http.NewRequest("POST", "http://dp.example/verify", bytes.NewReader([]byte("payload")))
+		err := service.executeWithRetry(context.Background(), client, req, nil, func(resp *http.Response) error {
+			// Read part of the body, as a decoder would
+			resp.Body.Read(make([]byte, 16))
+			return handlerErr
+		})
+		if !errors.Is(err, handlerErr) {
+			t.Fatalf("Expected the handler's error %v, got %v", handlerErr, err)
+		}
+
+		if len(transport.bodies) != 3 {
+			t.Fatalf("Expected 3 attempts, got %d", len(transport.bodies))
+		}
+		for i, body := range transport.bodies {
+			if !body.closed {
+				t.Errorf("Expected response %d to be closed", i+1)
+			}
+		}
+		// Each attempt is its own request
+		if transport.requests[1] == transport.requests[0] || transport.requests[2] == transport.requests[1] {
+			t.Error("Expected a new request per attempt")
+		}
+	}
+}
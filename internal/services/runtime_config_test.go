@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func writeRuntimeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write runtime configuration: %v", err)
+	}
+}
+
+func TestRuntimeConfig_ReloadValidatesBeforeSwap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runtime.json")
+	runtime := config.NewRuntimeConfig(path, config.RuntimeSettings{DPTimeout: config.Duration(30 * time.Second)})
+	var changes []config.RuntimeChange
+	runtime.OnChange(func(change config.RuntimeChange) { changes = append(changes, change) })
+
+	writeRuntimeConfig(t, path, `{"opa_timeout": "2s", "rate_limit": {"requests_per_minute": 120}}`)
+	change, err := runtime.Reload(config.ReloadSourceSignal)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if want := []string{"opa_timeout", "rate_limit"}; !reflect.DeepEqual(change.Changed, want) {
+		t.Errorf("Expected %v to change, got %v", want, change.Changed)
+	}
+	if settings := runtime.Current(); time.Duration(settings.DPTimeout) != 30*time.Second || time.Duration(settings.OPATimeout) != 2*time.Second {
+		t.Errorf("Expected unset settings to keep their base values, got %+v", settings)
+	}
+
+	for _, invalid := range []string{
+		`{"retry": {"max_retries": 3, "base_delay": "2s", "max_delay": "1s", "backoff_multiplier": 2}}`,
+		`{"dp_access_rules": [{"id": "r1", "action": "maybe"}]}`,
+		`{"port": "9090"}`,
+		`{"dp_timeout": 30}`,
+	} {
+		writeRuntimeConfig(t, path, invalid)
+		if _, err := runtime.Reload(config.ReloadSourceFileWatch); err == nil {
+			t.Errorf("Expected %s to be rejected", invalid)
+		}
+	}
+	if time.Duration(runtime.Current().OPATimeout) != 2*time.Second {
+		t.Error("Expected a rejected reload to keep the current settings")
+	}
+
+	// Reloading the same settings is not a change
+	writeRuntimeConfig(t, path, `{"opa_timeout": "2s", "rate_limit": {"requests_per_minute": 120}}`)
+	if change, err := runtime.Reload(config.ReloadSourceSignal); err != nil || len(change.Changed) != 0 {
+		t.Errorf("Expected no change, got %v, %v", change, err)
+	}
+	if len(changes) != 1 || changes[0].Source != config.ReloadSourceSignal {
+		t.Errorf("Expected one change notification, got %+v", changes)
+	}
+}
+
+func TestDPConnectorService_UsesReloadedRetrySettings(t *testing.T) {
+	attempts := 0
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer dp.Close()
+
+	path := filepath.Join(t.TempDir(), "runtime.json")
+	writeRuntimeConfig(t, path, `{"retry": {"max_retries": 0, "base_delay": "1ms", "max_delay": "1ms", "backoff_multiplier": 1}}`)
+	cfg := &config.Config{DPConnectorURL: dp.URL, Runtime: config.NewRuntimeConfig(path, config.RuntimeSettings{})}
+	if _, err := cfg.Runtime.Reload(config.ReloadSourceStartup); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	service := NewDPConnectorService(cfg)
+	req := &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"}
+
+	service.VerifyWithDP(context.Background(), req)
+	if attempts != 1 {
+		t.Errorf("Expected no retries, got %d attempts", attempts)
+	}
+
+	writeRuntimeConfig(t, path, `{"retry": {"max_retries": 2, "base_delay": "1ms", "max_delay": "1ms", "backoff_multiplier": 1}}`)
+	if _, err := cfg.Runtime.Reload(config.ReloadSourceSignal); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	attempts = 0
+	service.VerifyWithDP(context.Background(), req)
+	if attempts != 3 {
+		t.Errorf("Expected the reloaded retries to apply, got %d attempts", attempts)
+	}
+	if stats := service.GetRetryStats(); stats["max_retries"] != 2 {
+		t.Errorf("Expected stats to report the reloaded retries, got %v", stats["max_retries"])
+	}
+}
+
+func TestAuthorizationService_UsesReloadedRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runtime.json")
+	cfg := &config.Config{Runtime: config.NewRuntimeConfig(path, config.RuntimeSettings{})}
+	service := NewAuthorizationService(cfg, NewPolicyService(cfg))
+	if !service.AllowsClaimType("rp_1", "age_verification") {
+		t.Fatal("Expected the built-in rules to allow the claim type")
+	}
+
+	writeRuntimeConfig(t, path, `{"rp_permission_rules": [{"id": "rp-1-no-age", "rp_id": "rp_1", "claim_type": "age_verification", "action": "deny"}]}`)
+	if _, err := cfg.Runtime.Reload(config.ReloadSourceSignal); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if service.AllowsClaimType("rp_1", "age_verification") {
+		t.Error("Expected the reloaded rule to deny the claim type")
+	}
+	if stats := service.GetAuthorizationStats(); stats["rp_rules_count"] != 1 {
+		t.Errorf("Expected the reloaded rules to replace the built-in ones, got %v", stats["rp_rules_count"])
+	}
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DisclosureFormat selects how ExtractClaims returns the disclosed claims
+type DisclosureFormat string
+
+const (
+	// DisclosureFormatJSON returns the claims as plain JSON (the default)
+	DisclosureFormatJSON DisclosureFormat = "json"
+	// DisclosureFormatSDJWT also returns the claims as an SD-JWT that
+	// OpenID4VP wallets can hold and present
+	DisclosureFormatSDJWT DisclosureFormat = "sd-jwt"
+)
+
+// SD-JWT header types and digest algorithm
+const (
+	sdJWTType        = "dc+sd-jwt"
+	sdJWTKeyBindType = "kb+jwt"
+	sdJWTDigestAlg   = "sha-256"
+)
+
+// SDJWTSigner is the issuer key SD-JWTs are signed with (ES256)
+type SDJWTSigner struct {
+	Key    *ecdsa.PrivateKey
+	KeyID  string
+	Issuer string
+}
+
+// SetSDJWTSigner sets the key used to issue SD-JWT disclosures
+func (s *SelectiveDisclosureService) SetSDJWTSigner(signer *SDJWTSigner) {
+	s.sdJWTSigner = signer
+}
+
+// issueSDJWT makes each disclosed claim selectively disclosable and binds
+// the SD-JWT to the holder's key through the cnf claim
+func (s *SelectiveDisclosureService) issueSDJWT(request SelectiveDisclosureRequest, disclosedClaims map[string]interface{}) (string, error) {
+	if s.sdJWTSigner == nil || s.sdJWTSigner.Key == nil {
+		return "", fmt.Errorf("SD-JWT signing is not enabled")
+	}
+	if s.sdJWTSigner.Key.Curve != elliptic.P256() {
+		return "", fmt.Errorf("SD-JWT signing key must use P-256")
+	}
+
+	disclosures := make([]string, 0, len(disclosedClaims))
+	digests := make([]string, 0, len(disclosedClaims))
+	for name, value := range disclosedClaims {
+		disclosure, err := newSDJWTDisclosure(name, value)
+		if err != nil {
+			return "", err
+		}
+		disclosures = append(disclosures, disclosure)
+		digests = append(digests, sdJWTDigest(disclosure))
+	}
+	// Digest order must not reveal which claim is which
+	sort.Strings(digests)
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":     s.sdJWTSigner.Issuer,
+		"iat":     now.Unix(),
+		"jti":     request.CredentialID,
+		"_sd":     digests,
+		"_sd_alg": sdJWTDigestAlg,
+		"cnf":     map[string]interface{}{"jwk": request.HolderJWK},
+	}
+	if !request.Expiration.IsZero() {
+		claims["exp"] = request.Expiration.Unix()
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = sdJWTType
+	if s.sdJWTSigner.KeyID != "" {
+		token.Header["kid"] = s.sdJWTSigner.KeyID
+	}
+	issuerJWT, err := token.SignedString(s.sdJWTSigner.Key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign SD-JWT: %w", err)
+	}
+
+	sdJWT := issuerJWT + "~"
+	for _, disclosure := range disclosures {
+		sdJWT += disclosure + "~"
+	}
+	return sdJWT, nil
+}
+
+// PresentSDJWT is the holder side of an SD-JWT: it keeps only the named
+// claims' disclosures and appends a key binding JWT for the verifier's
+// audience and nonce, signed with the holder key the SD-JWT is bound to
+func PresentSDJWT(sdJWT string, disclose []string, holderKey *ecdsa.PrivateKey, audience, nonce string) (string, error) {
+	issuerJWT, disclosures, _, err := splitSDJWT(sdJWT)
+	if err != nil {
+		return "", err
+	}
+
+	selected := make([]string, 0, len(disclose))
+	for _, disclosure := range disclosures {
+		name, _, err := decodeSDJWTDisclosure(disclosure)
+		if err != nil {
+			return "", err
+		}
+		if containsString(disclose, name) {
+			selected = append(selected, disclosure)
+		}
+	}
+	if len(selected) != len(disclose) {
+		return "", fmt.Errorf("SD-JWT does not contain every claim to disclose")
+	}
+
+	presentation := issuerJWT + "~"
+	for _, disclosure := range selected {
+		presentation += disclosure + "~"
+	}
+
+	kb := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iat":     time.Now().Unix(),
+		"aud":     audience,
+		"nonce":   nonce,
+		"sd_hash": sdJWTDigest(presentation),
+	})
+	kb.Header["typ"] = sdJWTKeyBindType
+	kbJWT, err := kb.SignedString(holderKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign key binding JWT: %w", err)
+	}
+	return presentation + kbJWT, nil
+}
+
+// VerifySDJWT verifies a presented SD-JWT against the issuer key and returns
+// the disclosed claims. SD-JWTs bound to a holder key must carry a key
+// binding JWT for the given audience and nonce. Timestamps may be off by up
+// to leeway (see ClockSkewTolerance).
+func VerifySDJWT(presentation string, issuerKey *ecdsa.PublicKey, audience, nonce string, leeway time.Duration) (map[string]interface{}, error) {
+	issuerJWT, disclosures, kbJWT, err := splitSDJWT(presentation)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(issuerJWT, payload, func(token *jwt.Token) (interface{}, error) {
+		return issuerKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}), jwt.WithLeeway(leeway)); err != nil {
+		return nil, fmt.Errorf("invalid SD-JWT signature: %w", err)
+	}
+	if payload["_sd_alg"] != sdJWTDigestAlg {
+		return nil, fmt.Errorf("unsupported SD-JWT digest algorithm: %v", payload["_sd_alg"])
+	}
+
+	digests := make(map[string]bool)
+	if sd, ok := payload["_sd"].([]interface{}); ok {
+		for _, digest := range sd {
+			if d, ok := digest.(string); ok {
+				digests[d] = true
+			}
+		}
+	}
+
+	claims := make(map[string]interface{}, len(disclosures))
+	for _, disclosure := range disclosures {
+		digest := sdJWTDigest(disclosure)
+		if !digests[digest] {
+			return nil, fmt.Errorf("disclosure is not part of the SD-JWT")
+		}
+		// A digest may only be disclosed once
+		delete(digests, digest)
+
+		name, value, err := decodeSDJWTDisclosure(disclosure)
+		if err != nil {
+			return nil, err
+		}
+		claims[name] = value
+	}
+
+	cnf, bound := payload["cnf"].(map[string]interface{})
+	if !bound {
+		return claims, nil
+	}
+	holderJWK, _ := cnf["jwk"].(map[string]interface{})
+	holderKey, err := ecdsaPublicKeyFromJWK(holderJWK)
+	if err != nil {
+		return nil, fmt.Errorf("invalid holder key: %w", err)
+	}
+	if kbJWT == "" {
+		return nil, fmt.Errorf("key binding JWT is required")
+	}
+
+	kbClaims := jwt.MapClaims{}
+	kbToken, err := jwt.ParseWithClaims(kbJWT, kbClaims, func(token *jwt.Token) (interface{}, error) {
+		return holderKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}), jwt.WithAudience(audience), jwt.WithIssuedAt(), jwt.WithLeeway(leeway))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key binding JWT: %w", err)
+	}
+	if kbToken.Header["typ"] != sdJWTKeyBindType {
+		return nil, fmt.Errorf("key binding JWT has the wrong type")
+	}
+	if kbClaims["nonce"] != nonce {
+		return nil, fmt.Errorf("key binding JWT nonce does not match")
+	}
+	if kbClaims["sd_hash"] != sdJWTDigest(strings.TrimSuffix(presentation, kbJWT)) {
+		return nil, fmt.Errorf("key binding JWT does not cover the presented disclosures")
+	}
+
+	return claims, nil
+}
+
+// ECDSAPublicJWK returns the JWK of a P-256 public key, e.g. for a holder's
+// holder_jwk in a disclosure request
+func ECDSAPublicJWK(key *ecdsa.PublicKey) map[string]interface{} {
+	size := (key.Curve.Params().BitSize + 7) / 8
+	return map[string]interface{}{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+	}
+}
+
+// ecdsaPublicKeyFromJWK parses a P-256 public JWK
+func ecdsaPublicKeyFromJWK(jwk map[string]interface{}) (*ecdsa.PublicKey, error) {
+	if jwk["kty"] != "EC" || jwk["crv"] != "P-256" {
+		return nil, fmt.Errorf("only P-256 EC keys are supported")
+	}
+	x, errX := decodeJWKCoordinate(jwk["x"])
+	y, errY := decodeJWKCoordinate(jwk["y"])
+	if errX != nil || errY != nil {
+		return nil, fmt.Errorf("invalid key coordinates")
+	}
+
+	key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	if !key.Curve.IsOnCurve(x, y) {
+		return nil, fmt.Errorf("key is not on the P-256 curve")
+	}
+	return key, nil
+}
+
+func decodeJWKCoordinate(value interface{}) (*big.Int, error) {
+	encoded, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("coordinate is not a string")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
+
+// newSDJWTDisclosure encodes a salted [salt, name, value] disclosure
+func newSDJWTDisclosure(name string, value interface{}) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate disclosure salt: %w", err)
+	}
+	return encodeSDJWTDisclosure(salt, name, value)
+}
+
+// encodeSDJWTDisclosure encodes a [salt, name, value] disclosure with a given
+// salt
+func encodeSDJWTDisclosure(salt []byte, name string, value interface{}) (string, error) {
+	encoded, err := json.Marshal([]interface{}{base64.RawURLEncoding.EncodeToString(salt), name, value})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode disclosure for %s: %w", name, err)
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+// decodeSDJWTDisclosure returns the claim name and value of a disclosure
+func decodeSDJWTDisclosure(disclosure string) (string, interface{}, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(disclosure)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid disclosure encoding: %w", err)
+	}
+
+	var parts []interface{}
+	if err := json.Unmarshal(raw, &parts); err != nil || len(parts) != 3 {
+		return "", nil, fmt.Errorf("invalid disclosure")
+	}
+	name, ok := parts[1].(string)
+	if !ok || name == "" || name == "_sd" || name == "..." {
+		return "", nil, fmt.Errorf("invalid disclosure claim name")
+	}
+	return name, parts[2], nil
+}
+
+// sdJWTDigest is the base64url SHA-256 digest of a disclosure or presentation
+func sdJWTDigest(value string) string {
+	digest := sha256.Sum256([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+// splitSDJWT splits <issuer JWT>~<disclosure>~...~[<key binding JWT>]
+func splitSDJWT(sdJWT string) (string, []string, string, error) {
+	parts := strings.Split(sdJWT, "~")
+	if len(parts) < 2 || parts[0] == "" {
+		return "", nil, "", fmt.Errorf("malformed SD-JWT")
+	}
+
+	disclosures := parts[1 : len(parts)-1]
+	for _, disclosure := range disclosures {
+		if disclosure == "" {
+			return "", nil, "", fmt.Errorf("malformed SD-JWT")
+		}
+	}
+	return parts[0], disclosures, parts[len(parts)-1], nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"strings"
+	"testing"
+)
+
+func newSDJWTTestService(t *testing.T) (*SelectiveDisclosureService, *ecdsa.PrivateKey) {
+	t.Helper()
+	issuerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+
+	service := NewSelectiveDisclosureService(NewSelectiveDisclosureConfig(true, false, "test"))
+	service.SetSDJWTSigner(&SDJWTSigner{Key: issuerKey, KeyID: "sd-1", Issuer: "https://broker.example"})
+	return service, issuerKey
+}
+
+func TestSelectiveDisclosureService_ExtractClaimsAsSDJWT(t *testing.T) {
+	service, issuerKey := newSDJWTTestService(t)
+	holderKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	credential := map[string]interface{}{"name": "Jane Doe", "age": 34, "ssn": "123-45-6789"}
+	request := SelectiveDisclosureRequest{
+		CredentialID: "cred-1",
+		Purpose:      "age check",
+		RequesterID:  "rp-1",
+		Format:       DisclosureFormatSDJWT,
+		HolderJWK:    ECDSAPublicJWK(&holderKey.PublicKey),
+		Claims: map[string]Claim{
+			"name": {Name: "name", Disclosure: DisclosureLevelFull},
+			"age":  {Name: "age", Disclosure: DisclosureLevelRange},
+			"ssn":  {Name: "ssn", Disclosure: DisclosureLevelNone},
+		},
+	}
+
+	response, err := service.ExtractClaims(credential, request)
+	if err != nil {
+		t.Fatalf("ExtractClaims failed: %v", err)
+	}
+	if response.SDJWT == "" || !strings.HasSuffix(response.SDJWT, "~") {
+		t.Fatalf("Expected an SD-JWT without key binding, got %q", response.SDJWT)
+	}
+	if strings.Count(response.SDJWT, "~") != 3 {
+		t.Errorf("Expected a disclosure per disclosed claim, got %q", response.SDJWT)
+	}
+
+	// The holder presents only the age range to a verifier
+	presentation, err := PresentSDJWT(response.SDJWT, []string{"age"}, holderKey, "https://verifier.example", "n-1")
+	if err != nil {
+		t.Fatalf("PresentSDJWT failed: %v", err)
+	}
+	claims, err := VerifySDJWT(presentation, &issuerKey.PublicKey, "https://verifier.example", "n-1", DefaultClockSkewTolerance)
+	if err != nil {
+		t.Fatalf("VerifySDJWT failed: %v", err)
+	}
+	if len(claims) != 1 || claims["age"] != "30-50" {
+		t.Errorf("Expected only the age range to be disclosed, got %v", claims)
+	}
+
+	if _, err := VerifySDJWT(presentation, &issuerKey.PublicKey, "https://verifier.example", "n-2", DefaultClockSkewTolerance); err == nil {
+		t.Error("Expected a presentation for another nonce to be rejected")
+	}
+	if _, err := VerifySDJWT(response.SDJWT, &issuerKey.PublicKey, "https://verifier.example", "n-1", DefaultClockSkewTolerance); err == nil {
+		t.Error("Expected a holder-bound SD-JWT without key binding to be rejected")
+	}
+
+	// Disclosures cannot be added after the holder signed the presentation
+	_, disclosures, _, _ := splitSDJWT(response.SDJWT)
+	parts := strings.SplitN(presentation, "~", 2)
+	tampered := parts[0] + "~" + strings.Join(disclosures, "~") + "~" + parts[1][strings.LastIndex(parts[1], "~")+1:]
+	if _, err := VerifySDJWT(tampered, &issuerKey.PublicKey, "https://verifier.example", "n-1", DefaultClockSkewTolerance); err == nil {
+		t.Error("Expected disclosures outside the key binding to be rejected")
+	}
+
+	otherIssuer, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err := VerifySDJWT(presentation, &otherIssuer.PublicKey, "https://verifier.example", "n-1", DefaultClockSkewTolerance); err == nil {
+		t.Error("Expected a presentation from another issuer to be rejected")
+	}
+}
+
+func TestSelectiveDisclosureService_SDJWTRequiresHolderKeyAndSigner(t *testing.T) {
+	request := SelectiveDisclosureRequest{
+		CredentialID: "cred-1",
+		Purpose:      "age check",
+		RequesterID:  "rp-1",
+		Format:       DisclosureFormatSDJWT,
+		Claims:       map[string]Claim{"name": {Name: "name", Disclosure: DisclosureLevelFull}},
+	}
+	credential := map[string]interface{}{"name": "Jane Doe"}
+
+	service, _ := newSDJWTTestService(t)
+	if _, err := service.ExtractClaims(credential, request); err == nil {
+		t.Error("Expected an SD-JWT request without a holder key to be rejected")
+	}
+
+	holderKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	request.HolderJWK = ECDSAPublicJWK(&holderKey.PublicKey)
+	unsigned := NewSelectiveDisclosureService(NewSelectiveDisclosureConfig(true, false, "test"))
+	if _, err := unsigned.ExtractClaims(credential, request); err == nil {
+		t.Error("Expected SD-JWT output to require a signer")
+	}
+
+	request.Format = "jwt-vc"
+	if _, err := service.ExtractClaims(credential, request); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// resolveAuthSecrets replaces secret: references in an authentication config
+// with their values. onRotate runs when any of them later changes, so the
+// authenticator can be rebuilt with the new secret; a secret that cannot be
+// fetched is left empty and fails each request to the DP.
+func resolveAuthSecrets(secrets *config.SecretStore, watchKey string, authConfig *AuthenticationConfig, onRotate func()) {
+	fields := map[string]*string{"api_key": &authConfig.APIKey}
+	if authConfig.OAuth2 != nil {
+		fields["oauth2_client_secret"] = &authConfig.OAuth2.ClientSecret
+	}
+	if authConfig.JWT != nil {
+		fields["jwt_secret"] = &authConfig.JWT.Secret
+	}
+	if authConfig.SigV4 != nil {
+		fields["aws_secret_access_key"] = &authConfig.SigV4.SecretAccessKey
+	}
+	if authConfig.TokenExchange != nil {
+		fields["token_exchange_client_secret"] = &authConfig.TokenExchange.ClientSecret
+	}
+	for i := range authConfig.Credentials {
+		fields["credential_"+authConfig.Credentials[i].Name] = &authConfig.Credentials[i].Value
+	}
+
+	for name, field := range fields {
+		value, err := secrets.Resolve(*field, watchKey+":"+name, func(string) { onRotate() })
+		if err != nil {
+			fmt.Printf("SECRETS WARNING: %s %s: %v\n", watchKey, name, err)
+		}
+		*field = value
+	}
+}
+
+// CertificateReloader serves the gateway's TLS certificate, reloading it when
+// the certificate or key changes. Each of TLS_CERT_FILE and TLS_KEY_FILE is a
+// path, typically a mounted secret, or a secret: reference to the PEM.
+type CertificateReloader struct {
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// NewCertificateReloader loads the configured certificate and watches it for
+// rotation through the configuration's secret store
+func NewCertificateReloader(cfg *config.Config) (*CertificateReloader, error) {
+	reloader := &CertificateReloader{}
+	fetch := func() (string, error) {
+		certPEM, err := readPEMSource(cfg.Secrets, cfg.TLSCertFile)
+		if err != nil {
+			return "", err
+		}
+		keyPEM, err := readPEMSource(cfg.Secrets, cfg.TLSKeyFile)
+		if err != nil {
+			return "", err
+		}
+		return certPEM + "\n" + keyPEM, nil
+	}
+
+	pair, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	if err := reloader.load(pair); err != nil {
+		return nil, err
+	}
+	err = cfg.Secrets.Watch("tls:gateway_certificate", fetch, func(pair string) {
+		if err := reloader.load(pair); err != nil {
+			fmt.Printf("TLS WARNING: keeping the current certificate: %v\n", err)
+		}
+	})
+	return reloader, err
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// load parses a certificate and key and makes them current
+func (r *CertificateReloader) load(pair string) error {
+	// X509KeyPair takes the certificate blocks from the first argument and
+	// the key block from the second, so the concatenated PEM serves as both
+	cert, err := tls.X509KeyPair([]byte(pair), []byte(pair))
+	if err != nil {
+		return fmt.Errorf("invalid TLS certificate or key: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	return nil
+}
+
+// readPEMSource reads a PEM file, or resolves a secret: reference to one
+func readPEMSource(secrets *config.SecretStore, source string) (string, error) {
+	if config.IsSecretRef(source) {
+		return secrets.Resolve(source, "", nil)
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestDPConnectorService_RotatedTokenIsPickedUp(t *testing.T) {
+	var keys []string
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("X-API-Key"))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":              "j",
+			"status":              "completed",
+			"timestamp":           "2026-01-01T00:00:00Z",
+			"verification_result": map[string]interface{}{"verified": true, "confidence": 0.9},
+		})
+	}))
+	defer dp.Close()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "dp-token"), []byte("token-1\n"), 0600)
+	secrets := config.NewSecretStore(config.NewFileSecretsProvider(dir))
+	cfg := &config.Config{DPConnectorURL: dp.URL, DPConnectorToken: "secret:dp-token", DPTimeout: 5 * time.Second, Secrets: secrets}
+	service := NewDPConnectorService(cfg)
+	req := &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"}
+
+	if _, err := service.VerifyWithDP(context.Background(), req); err != nil {
+		t.Fatalf("VerifyWithDP failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "dp-token"), []byte("token-2\n"), 0600)
+	if changed, err := secrets.Refresh(); err != nil || changed == 0 {
+		t.Fatalf("Expected the rotated token to be applied, got %d, %v", changed, err)
+	}
+	if _, err := service.VerifyWithDP(context.Background(), req); err != nil {
+		t.Fatalf("VerifyWithDP failed after rotation: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != "token-1" || keys[1] != "token-2" {
+		t.Errorf("Expected the DP to see the rotated token, got %q", keys)
+	}
+}
+
+func TestVaultSecretsProvider_GetSecret(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		secrets := map[string]map[string]interface{}{
+			"/v1/secret/data/pavilion":    {"dp-token": "from-default-path"},
+			"/v1/secret/data/dp/acme-dp1": {"client_secret": "from-other-path"},
+		}
+		data, exists := secrets[r.URL.Path]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": data}})
+	}))
+	defer vault.Close()
+
+	store := config.NewSecretStore(config.NewVaultSecretsProvider(vault.URL, "s.token", "", "secret", "pavilion"))
+	for ref, want := range map[string]string{
+		"secret:dp-token":                  "from-default-path",
+		"secret:dp/acme-dp1#client_secret": "from-other-path",
+		"plain-value-left-alone":           "plain-value-left-alone",
+	} {
+		if got, err := store.Resolve(ref, "", nil); err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", ref, got, err, want)
+		}
+	}
+	if _, err := store.Resolve("secret:missing", "", nil); err == nil {
+		t.Error("Expected a missing secret to fail")
+	}
+	if _, err := (*config.SecretStore)(nil).Resolve("secret:dp-token", "", nil); err == nil {
+		t.Error("Expected a reference without a secrets provider to fail")
+	}
+}
+
+func TestCertificateReloader_ReloadsRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	writeTestCertificate(t, certFile, keyFile, "gateway-1")
+
+	cfg := &config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile, Secrets: config.NewSecretStore(nil)}
+	reloader, err := NewCertificateReloader(cfg)
+	if err != nil {
+		t.Fatalf("NewCertificateReloader failed: %v", err)
+	}
+	if name := certificateName(t, reloader); name != "gateway-1" {
+		t.Fatalf("Expected the initial certificate, got %s", name)
+	}
+
+	writeTestCertificate(t, certFile, keyFile, "gateway-2")
+	if _, err := cfg.Secrets.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if name := certificateName(t, reloader); name != "gateway-2" {
+		t.Errorf("Expected the rotated certificate, got %s", name)
+	}
+
+	// A broken key file keeps the current certificate in service
+	os.WriteFile(keyFile, []byte("not a key"), 0600)
+	cfg.Secrets.Refresh()
+	if name := certificateName(t, reloader); name != "gateway-2" {
+		t.Errorf("Expected the current certificate to be kept, got %s", name)
+	}
+}
+
+func writeTestCertificate(t *testing.T, certFile, keyFile, commonName string) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	keyDER, _ := x509.MarshalPKCS8PrivateKey(key)
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600)
+}
+
+func certificateName(t *testing.T, reloader *CertificateReloader) string {
+	t.Helper()
+	cert, err := reloader.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil || cert == nil {
+		t.Fatalf("GetCertificate failed: %v", err)
+	}
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	return parsed.Subject.CommonName
+}
@@ -12,6 +12,11 @@ import (
 // SelectiveDisclosureService provides selective disclosure functionality
 type SelectiveDisclosureService struct {
 	config *SelectiveDisclosureConfig
+	// bbsKey signs credentials that support unlinkable disclosure of any
+	// subset of their claims
+	bbsKey *BBSPrivateKey
+	// sdJWTSigner issues disclosures as SD-JWTs for OpenID4VP wallets
+	sdJWTSigner *SDJWTSigner
 }
 
 // SelectiveDisclosureConfig holds configuration for selective disclosure
@@ -39,6 +44,80 @@ func NewSelectiveDisclosureService(config *SelectiveDisclosureConfig) *Selective
 	}
 }
 
+// SetBBSKey sets the key used to issue BBS+ credentials
+func (s *SelectiveDisclosureService) SetBBSKey(key *BBSPrivateKey) {
+	s.bbsKey = key
+}
+
+// BBSPublicKey returns the hex-encoded key verifiers check BBS+ proofs
+// against, or an empty string when BBS+ is not enabled
+func (s *SelectiveDisclosureService) BBSPublicKey() string {
+	if s.bbsKey == nil {
+		return ""
+	}
+	return s.bbsKey.PublicKey()
+}
+
+// IssueBBSCredential signs all claims of a credential once. The holder can
+// then disclose any subset with DeriveBBSDisclosure, without contacting the
+// issuer and without presentations being linkable to each other.
+func (s *SelectiveDisclosureService) IssueBBSCredential(credentialID string, claims map[string]interface{}) (*BBSCredential, error) {
+	if s.bbsKey == nil {
+		return nil, fmt.Errorf("BBS+ signing is not enabled")
+	}
+	if credentialID == "" {
+		return nil, fmt.Errorf("credential ID is required")
+	}
+
+	signature, err := s.bbsKey.Sign(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign credential: %w", err)
+	}
+
+	return &BBSCredential{
+		CredentialID: credentialID,
+		Claims:       claims,
+		Signature:    *signature,
+		PublicKey:    s.bbsKey.PublicKey(),
+		IssuedAt:     time.Now(),
+	}, nil
+}
+
+// DeriveBBSDisclosure checks a BBS+ credential and derives a proof revealing
+// only the named claims, bound to the verifier's nonce
+func (s *SelectiveDisclosureService) DeriveBBSDisclosure(credential *BBSCredential, disclose []string, nonce string) (*BBSDisclosureProof, error) {
+	if credential == nil {
+		return nil, fmt.Errorf("credential is required")
+	}
+
+	valid, err := VerifyBBSSignature(credential.PublicKey, credential.Claims, &credential.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential: %w", err)
+	}
+	if !valid {
+		return nil, fmt.Errorf("credential signature is invalid")
+	}
+
+	return DeriveBBSProof(credential, disclose, nonce)
+}
+
+// VerifyBBSDisclosure verifies a BBS+ disclosure proof against this
+// service's public key and returns the disclosed claims
+func (s *SelectiveDisclosureService) VerifyBBSDisclosure(proof *BBSDisclosureProof, nonce string) (map[string]interface{}, error) {
+	if s.bbsKey == nil {
+		return nil, fmt.Errorf("BBS+ signing is not enabled")
+	}
+
+	claims, valid, err := VerifyBBSProof(s.bbsKey.PublicKey(), proof, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid disclosure proof: %w", err)
+	}
+	if !valid {
+		return nil, fmt.Errorf("disclosure proof does not verify")
+	}
+	return claims, nil
+}
+
 // Claim represents a claim that can be selectively disclosed
 type Claim struct {
 	Name       string                 `json:"name"`
@@ -68,6 +147,10 @@ type SelectiveDisclosureRequest struct {
 	RequesterID  string                 `json:"requester_id"`
 	Expiration   time.Time              `json:"expiration,omitempty"`
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	// Format "sd-jwt" also returns the disclosed claims as an SD-JWT bound
+	// to HolderJWK, the holder's public key
+	Format    DisclosureFormat       `json:"format,omitempty"`
+	HolderJWK map[string]interface{} `json:"holder_jwk,omitempty"`
 }
 
 // SelectiveDisclosureResponse represents the response from selective disclosure
@@ -78,6 +161,9 @@ type SelectiveDisclosureResponse struct {
 	Proofs          map[string]interface{} `json:"proofs,omitempty"`
 	AuditLog        *DisclosureAuditLog    `json:"audit_log,omitempty"`
 	Metadata        map[string]interface{} `json:"metadata"`
+	// SDJWT holds the disclosed claims as <issuer JWT>~<disclosures>~ when
+	// the request asked for the SD-JWT format
+	SDJWT string `json:"sd_jwt,omitempty"`
 }
 
 // DisclosureAuditLog represents an audit log entry for disclosure
@@ -149,6 +235,15 @@ func (s *SelectiveDisclosureService) ExtractClaims(credential map[string]interfa
 		},
 	}
 
+	if request.Format == DisclosureFormatSDJWT {
+		sdJWT, err := s.issueSDJWT(request, disclosedClaims)
+		if err != nil {
+			return nil, fmt.Errorf("failed to issue SD-JWT: %w", err)
+		}
+		response.SDJWT = sdJWT
+		response.Metadata["format"] = string(DisclosureFormatSDJWT)
+	}
+
 	return response, nil
 }
 
@@ -332,6 +427,17 @@ func (s *SelectiveDisclosureService) validateDisclosureRequest(request Selective
 		return fmt.Errorf("requester ID is required")
 	}
 
+	switch request.Format {
+	case "", DisclosureFormatJSON:
+	case DisclosureFormatSDJWT:
+		// SD-JWTs are always bound to the holder presenting them
+		if _, err := ecdsaPublicKeyFromJWK(request.HolderJWK); err != nil {
+			return fmt.Errorf("holder key is required for SD-JWT: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported disclosure format: %s", request.Format)
+	}
+
 	// Validate each claim
 	for claimName, claim := range request.Claims {
 		if claimName == "" {
@@ -393,6 +499,8 @@ func (s *SelectiveDisclosureService) GetDisclosureStats() map[string]interface{}
 		"minimal_disclosure_enabled": s.config.MinimalDisclosureEnabled,
 		"audit_logging_enabled":      s.config.AuditLoggingEnabled,
 		"hash_algorithm":             s.config.HashAlgorithm,
+		"bbs_enabled":                s.bbsKey != nil,
+		"sd_jwt_enabled":             s.sdJWTSigner != nil,
 		"supported_disclosure_levels": []string{
 			string(DisclosureLevelFull),
 			string(DisclosureLevelHash),
@@ -0,0 +1,488 @@
+package services
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	// Temporary credentials are refreshed this long before they expire
+	awsCredentialsRefreshWindow = 5 * time.Minute
+)
+
+// SigV4Config configures AWS Signature Version 4 signing. Without static keys,
+// credentials come from the standard provider chain.
+type SigV4Config struct {
+	Region          string
+	Service         string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Profile selects the shared credentials file profile
+	Profile string
+}
+
+// AWSCredentials are the keys requests are signed with
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Expires is zero for long-lived keys
+	Expires time.Time
+}
+
+// AWSCredentialsProvider retrieves AWS credentials
+type AWSCredentialsProvider interface {
+	Retrieve() (*AWSCredentials, error)
+}
+
+// StaticAWSCredentials returns fixed credentials
+type StaticAWSCredentials AWSCredentials
+
+// Retrieve implements AWSCredentialsProvider
+func (c StaticAWSCredentials) Retrieve() (*AWSCredentials, error) {
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return nil, fmt.Errorf("static AWS credentials are incomplete")
+	}
+	credentials := AWSCredentials(c)
+	return &credentials, nil
+}
+
+// AWSCredentialChain resolves credentials the way the AWS SDKs do:
+// environment variables, the shared credentials file, the ECS container
+// endpoint and finally the EC2 instance metadata service. Temporary
+// credentials are cached until shortly before they expire.
+type AWSCredentialChain struct {
+	Profile string
+	client  *http.Client
+	// Metadata endpoints; replaced in tests
+	containerHost string
+	imdsHost      string
+
+	mu     sync.Mutex
+	cached *AWSCredentials
+}
+
+// NewAWSCredentialChain creates the standard credential provider chain
+func NewAWSCredentialChain(profile string) *AWSCredentialChain {
+	return &AWSCredentialChain{
+		Profile:       profile,
+		client:        &http.Client{Timeout: 2 * time.Second},
+		containerHost: "http://169.254.170.2",
+		imdsHost:      "http://169.254.169.254",
+	}
+}
+
+// Retrieve implements AWSCredentialsProvider
+func (c *AWSCredentialChain) Retrieve() (*AWSCredentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached != nil && (c.cached.Expires.IsZero() || time.Now().Add(awsCredentialsRefreshWindow).Before(c.cached.Expires)) {
+		return c.cached, nil
+	}
+
+	var errs []string
+	for _, source := range []struct {
+		name     string
+		retrieve func() (*AWSCredentials, error)
+	}{
+		{"environment", awsEnvCredentials},
+		{"shared credentials file", c.sharedFileCredentials},
+		{"container endpoint", c.containerCredentials},
+		{"instance metadata", c.instanceCredentials},
+	} {
+		credentials, err := source.retrieve()
+		if err == nil {
+			c.cached = credentials
+			return credentials, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", source.name, err))
+	}
+	return nil, fmt.Errorf("no AWS credentials found (%s)", strings.Join(errs, "; "))
+}
+
+func awsEnvCredentials() (*AWSCredentials, error) {
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set")
+	}
+	return &AWSCredentials{AccessKeyID: accessKey, SecretAccessKey: secretKey, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+}
+
+// sharedFileCredentials reads a profile of ~/.aws/credentials or
+// AWS_SHARED_CREDENTIALS_FILE
+func (c *AWSCredentialChain) sharedFileCredentials() (*AWSCredentials, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := c.Profile
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	inProfile := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inProfile = strings.TrimSpace(line[1:len(line)-1]) == profile
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok && inProfile {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if values["aws_access_key_id"] == "" || values["aws_secret_access_key"] == "" {
+		return nil, fmt.Errorf("profile %s has no keys", profile)
+	}
+	return &AWSCredentials{
+		AccessKeyID:     values["aws_access_key_id"],
+		SecretAccessKey: values["aws_secret_access_key"],
+		SessionToken:    values["aws_session_token"],
+	}, nil
+}
+
+// awsTemporaryCredentials is the document returned by the container and
+// instance metadata endpoints
+type awsTemporaryCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+}
+
+// containerCredentials fetches task role credentials from the ECS or EKS Pod
+// Identity container endpoint
+func (c *AWSCredentialChain) containerCredentials() (*AWSCredentials, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		endpoint = c.containerHost + relative
+	}
+	if endpoint == "" {
+		return nil, fmt.Errorf("no container credentials endpoint is set")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if tokenFile := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	return c.fetchTemporaryCredentials(req)
+}
+
+// instanceCredentials fetches instance role credentials through IMDSv2
+func (c *AWSCredentialChain) instanceCredentials() (*AWSCredentials, error) {
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return nil, fmt.Errorf("instance metadata is disabled")
+	}
+
+	tokenReq, err := http.NewRequest(http.MethodPut, c.imdsHost+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	tokenReq.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := c.metadataText(tokenReq)
+	if err != nil {
+		return nil, err
+	}
+
+	roleReq, err := http.NewRequest(http.MethodGet, c.imdsHost+"/latest/meta-data/iam/security-credentials/", nil)
+	if err != nil {
+		return nil, err
+	}
+	roleReq.Header.Set("X-aws-ec2-metadata-token", token)
+	role, err := c.metadataText(roleReq)
+	if err != nil {
+		return nil, err
+	}
+	role = strings.TrimSpace(strings.SplitN(role, "\n", 2)[0])
+	if role == "" {
+		return nil, fmt.Errorf("instance has no IAM role")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, c.imdsHost+"/latest/meta-data/iam/security-credentials/"+url.PathEscape(role), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	return c.fetchTemporaryCredentials(req)
+}
+
+func (c *AWSCredentialChain) metadataText(req *http.Request) (string, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata endpoint returned %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return string(data), err
+}
+
+func (c *AWSCredentialChain) fetchTemporaryCredentials(req *http.Request) (*AWSCredentials, error) {
+	body, err := c.metadataText(req)
+	if err != nil {
+		return nil, err
+	}
+	var document awsTemporaryCredentials
+	if err := json.Unmarshal([]byte(body), &document); err != nil {
+		return nil, fmt.Errorf("malformed credentials document: %w", err)
+	}
+	if document.AccessKeyID == "" || document.SecretAccessKey == "" {
+		return nil, fmt.Errorf("credentials document has no keys")
+	}
+
+	credentials := &AWSCredentials{
+		AccessKeyID:     document.AccessKeyID,
+		SecretAccessKey: document.SecretAccessKey,
+		SessionToken:    document.Token,
+	}
+	if document.Expiration != "" {
+		if credentials.Expires, err = time.Parse(time.RFC3339, document.Expiration); err != nil {
+			return nil, fmt.Errorf("invalid credentials expiration: %w", err)
+		}
+	}
+	return credentials, nil
+}
+
+// SigV4Signer signs requests with AWS Signature Version 4
+type SigV4Signer struct {
+	region      string
+	service     string
+	credentials AWSCredentialsProvider
+	now         func() time.Time
+}
+
+// NewSigV4Signer creates a signer for the configured region and service
+func NewSigV4Signer(config *SigV4Config) *SigV4Signer {
+	var credentials AWSCredentialsProvider
+	if config.AccessKeyID != "" {
+		credentials = StaticAWSCredentials{AccessKeyID: config.AccessKeyID, SecretAccessKey: config.SecretAccessKey, SessionToken: config.SessionToken}
+	} else {
+		credentials = NewAWSCredentialChain(config.Profile)
+	}
+	return &SigV4Signer{
+		region:      config.Region,
+		service:     config.Service,
+		credentials: credentials,
+		now:         time.Now,
+	}
+}
+
+// Sign adds the X-Amz-Date, session token and Authorization headers. It
+// replaces any earlier signature, so retries are signed afresh.
+func (s *SigV4Signer) Sign(req *http.Request) error {
+	credentials, err := s.credentials.Retrieve()
+	if err != nil {
+		return err
+	}
+
+	payloadHash, err := sigV4PayloadHash(req)
+	if err != nil {
+		return err
+	}
+
+	now := s.now().UTC()
+	amzDate := now.Format(sigV4TimeFormat)
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	} else {
+		req.Header.Del("X-Amz-Security-Token")
+	}
+	if s.service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	signedHeaders, canonicalHeaders := sigV4CanonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		sigV4CanonicalURI(req.URL, s.service),
+		sigV4CanonicalQuery(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format("20060102"), s.region, s.service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), []byte(now.Format("20060102")))
+	for _, part := range []string{s.region, s.service, "aws4_request"} {
+		key = hmacSHA256(key, []byte(part))
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, []byte(stringToSign)))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, credentials.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// sigV4PayloadHash hashes the body without consuming it
+func sigV4PayloadHash(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return sha256Hex(nil), nil
+	}
+	if req.GetBody == nil {
+		return "", fmt.Errorf("request body cannot be read for signing")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// sigV4CanonicalHeaders signs host, content-type and the x-amz-* headers
+func sigV4CanonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower != "content-type" && !strings.HasPrefix(lower, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[lower] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return strings.Join(names, ";"), canonical.String()
+}
+
+// sigV4CanonicalURI encodes the path; services other than S3 expect each
+// segment encoded twice
+func sigV4CanonicalURI(u *url.URL, service string) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if service == "s3" {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = sigV4Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func sigV4CanonicalQuery(u *url.URL) string {
+	type pair struct{ key, value string }
+	var pairs []pair
+	for key, values := range u.Query() {
+		for _, value := range values {
+			pairs = append(pairs, pair{sigV4Escape(key), sigV4Escape(value)})
+		}
+	}
+	// Sorted by encoded key, then value
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].key != pairs[j].key {
+			return pairs[i].key < pairs[j].key
+		}
+		return pairs[i].value < pairs[j].value
+	})
+
+	encoded := make([]string, len(pairs))
+	for i, p := range pairs {
+		encoded[i] = p.key + "=" + p.value
+	}
+	return strings.Join(encoded, "&")
+}
+
+// sigV4Escape percent-encodes everything but unreserved characters
+func sigV4Escape(value string) string {
+	var escaped strings.Builder
+	for _, b := range []byte(value) {
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') || b == '-' || b == '_' || b == '.' || b == '~' {
+			escaped.WriteByte(b)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	return escaped.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// newTestSigV4Signer uses the credentials and clock of the AWS SigV4 test suite
+func newTestSigV4Signer() *SigV4Signer {
+	signer := NewSigV4Signer(&SigV4Config{
+		Region:          "us-east-1",
+		Service:         "service",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	})
+	signer.now = func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+	return signer
+}
+
+func TestSigV4Signer_TestSuite(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		url       string
+		signature string
+	}{
+		{"get-vanilla", "GET", "https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", "GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"post-vanilla", "POST", "https://example.amazonaws.com/", "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.url, nil)
+			if err := newTestSigV4Signer().Sign(req); err != nil {
+				t.Fatalf("Sign failed: %v", err)
+			}
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization = %s\nwant %s", got, want)
+			}
+			if req.Header.Get("X-Amz-Date") != "20150830T123600Z" {
+				t.Errorf("Unexpected X-Amz-Date: %s", req.Header.Get("X-Amz-Date"))
+			}
+		})
+	}
+}
+
+func TestSigV4Signer_BodyAndResigning(t *testing.T) {
+	signer := newTestSigV4Signer()
+	req, _ := http.NewRequest("POST", "https://abc.execute-api.us-east-1.amazonaws.com/prod/verify", bytes.NewReader([]byte(`{"claim_type":"age"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	if err := signer.Sign(req); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	first := req.Header.Get("Authorization")
+	if !strings.Contains(first, "SignedHeaders=content-type;host;x-amz-date,") {
+		t.Errorf("Expected content-type to be signed: %s", first)
+	}
+
+	// Signing again later replaces the signature rather than adding one
+	signer.now = func() time.Time { return time.Date(2015, 8, 30, 12, 41, 0, 0, time.UTC) }
+	if err := signer.Sign(req); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if len(req.Header.Values("Authorization")) != 1 || req.Header.Get("Authorization") == first {
+		t.Errorf("Expected a single fresh signature, got %v", req.Header.Values("Authorization"))
+	}
+
+	// Changing the body changes the signature
+	other, _ := http.NewRequest("POST", req.URL.String(), bytes.NewReader([]byte(`{"claim_type":"student"}`)))
+	other.Header.Set("Content-Type", "application/json")
+	signer.Sign(other)
+	if other.Header.Get("Authorization") == req.Header.Get("Authorization") {
+		t.Error("Expected the payload to be covered by the signature")
+	}
+}
+
+func TestAWSCredentialChain(t *testing.T) {
+	for _, name := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE", "AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	dir := t.TempDir()
+	credentialsFile := filepath.Join(dir, "credentials")
+	os.WriteFile(credentialsFile, []byte("[default]\naws_access_key_id = AKIDDEFAULT\naws_secret_access_key = default-secret\n\n[dp]\naws_access_key_id=AKIDPROFILE\naws_secret_access_key=profile-secret\naws_session_token=profile-token\n"), 0600)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+
+	credentials, err := NewAWSCredentialChain("dp").Retrieve()
+	if err != nil || credentials.AccessKeyID != "AKIDPROFILE" || credentials.SessionToken != "profile-token" {
+		t.Errorf("Expected the dp profile, got %+v, %v", credentials, err)
+	}
+
+	// Environment variables come first
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDENV")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	if credentials, _ := NewAWSCredentialChain("dp").Retrieve(); credentials.AccessKeyID != "AKIDENV" {
+		t.Errorf("Expected environment credentials, got %+v", credentials)
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "missing"))
+
+	// Container credentials expire and are fetched again before they do
+	fetches := 0
+	expiration := time.Now().Add(time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if r.URL.Path != "/v2/credentials/task" || r.Header.Get("Authorization") != "container-token" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"AccessKeyId":     "ASIATASK",
+			"SecretAccessKey": "task-secret",
+			"Token":           "task-token",
+			"Expiration":      expiration.UTC().Format(time.RFC3339),
+		})
+	}))
+	defer server.Close()
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "/v2/credentials/task")
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "container-token")
+
+	chain := NewAWSCredentialChain("")
+	chain.containerHost = server.URL
+	credentials, err = chain.Retrieve()
+	if err != nil || credentials.AccessKeyID != "ASIATASK" || credentials.SessionToken != "task-token" {
+		t.Fatalf("Expected container credentials, got %+v, %v", credentials, err)
+	}
+	chain.Retrieve()
+	if fetches != 1 {
+		t.Errorf("Expected cached credentials, got %d fetches", fetches)
+	}
+	chain.cached.Expires = time.Now().Add(time.Minute)
+	chain.Retrieve()
+	if fetches != 2 {
+		t.Errorf("Expected credentials near expiry to be refreshed, got %d fetches", fetches)
+	}
+
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "")
+	if _, err := NewAWSCredentialChain("").Retrieve(); err == nil {
+		t.Error("Expected an error when no source has credentials")
+	}
+}
+
+func TestDPConnectorService_SigV4ResignsRetries(t *testing.T) {
+	var dates, signatures []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dates = append(dates, r.Header.Get("X-Amz-Date"))
+		signatures = append(signatures, r.Header.Get("Authorization"))
+		if len(signatures) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":              "j",
+			"status":              "completed",
+			"timestamp":           "2026-01-01T00:00:00Z",
+			"verification_result": map[string]interface{}{"verified": true, "confidence": 0.9},
+		})
+	}))
+	defer server.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: server.URL, DPTimeout: 5 * time.Second})
+	service.retryConfig.BaseDelay = time.Millisecond
+	service.Registry().Remove(DefaultDPProviderID)
+	if err := service.Registry().Register(&DPProvider{
+		DPID:            "dp-aws",
+		Endpoint:        server.URL,
+		SupportedClaims: []string{AnyClaimType},
+		Auth:            &DPProviderAuth{Method: AuthMethodSigV4, Region: "eu-west-1", Service: "execute-api", AWSAccessKeyID: "AKIDEXAMPLE", AWSSecretAccessKey: "secret"},
+	}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	provider, _ := service.Registry().Get("dp-aws")
+
+	// Each attempt gets its own timestamp
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	service.providerAuthenticator(provider).signer.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	if _, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"}); err != nil {
+		t.Fatalf("VerifyWithDP failed: %v", err)
+	}
+	if len(signatures) != 2 {
+		t.Fatalf("Expected a retry, got %d requests", len(signatures))
+	}
+	if dates[0] == dates[1] || signatures[0] == signatures[1] {
+		t.Errorf("Expected the retry to be signed again, got %v", dates)
+	}
+	for _, signature := range signatures {
+		if !strings.HasPrefix(signature, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20260101/eu-west-1/execute-api/aws4_request") {
+			t.Errorf("Unexpected signature: %s", signature)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// Credential status entry types
+const (
+	StatusList2021EntryType      = "StatusList2021Entry"
+	BitstringStatusListEntryType = "BitstringStatusListEntry"
+	// RevocationListEntryType points at a CRL-style endpoint listing the IDs
+	// of revoked credentials
+	RevocationListEntryType = "RevocationListEntry"
+)
+
+// Credential statuses reported by a status check
+const (
+	CredentialStatusValid     = "valid"
+	CredentialStatusRevoked   = "revoked"
+	CredentialStatusSuspended = "suspended"
+)
+
+// Status list size limits; a 16KB list covers 131,072 credentials
+const (
+	maxStatusListBody    = 4 << 20
+	maxStatusListEntries = 16 << 20
+)
+
+// CredentialStatusResult is the outcome of a status check
+type CredentialStatusResult struct {
+	Status    string    `json:"status"`
+	ListURL   string    `json:"list_url"`
+	FetchedAt time.Time `json:"fetched_at"`
+	// Stale is set when the list could not be refreshed and a cached copy
+	// within the staleness limit was used
+	Stale bool `json:"stale,omitempty"`
+}
+
+// Revoked reports whether the credential must not be accepted
+func (r *CredentialStatusResult) Revoked() bool {
+	return r.Status != CredentialStatusValid
+}
+
+// statusList is a fetched status list: a bitstring for StatusList2021 and
+// Bitstring lists, or a set of credential IDs for revocation lists
+type statusList struct {
+	issuer     string
+	purpose    string
+	bits       []byte
+	revoked    map[string]bool
+	fetchedAt  time.Time
+	validUntil time.Time
+}
+
+// StatusListService checks credentials against their issuer's status list
+// before they are accepted. Lists are cached for the cache TTL; when a
+// refresh fails a cached list is used until it reaches the staleness limit,
+// after which checks fail so a revoked credential is never reported valid.
+type StatusListService struct {
+	client       *http.Client
+	cacheTTL     time.Duration
+	maxStaleness time.Duration
+	// allowHTTP permits plain HTTP list URLs; tests serve lists over HTTP
+	allowHTTP bool
+
+	mu    sync.Mutex
+	lists map[string]*statusList
+}
+
+// NewStatusListService creates a status list service
+func NewStatusListService(cfg *config.Config) *StatusListService {
+	timeout := cfg.StatusListTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	maxStaleness := cfg.StatusListMaxStaleness
+	if maxStaleness < cfg.StatusListCacheTTL {
+		maxStaleness = cfg.StatusListCacheTTL
+	}
+
+	return &StatusListService{
+		client:       &http.Client{Timeout: timeout},
+		cacheTTL:     cfg.StatusListCacheTTL,
+		maxStaleness: maxStaleness,
+		lists:        make(map[string]*statusList),
+	}
+}
+
+// Check looks up a credential's status. Any error means the status is
+// unknown and the credential must not be reported as verified.
+func (s *StatusListService) Check(ctx context.Context, credentialID, issuer string, entry *models.CredentialStatus) (*CredentialStatusResult, error) {
+	if entry == nil {
+		return nil, fmt.Errorf("credential has no status entry")
+	}
+	purpose := entry.StatusPurpose
+	if purpose == "" {
+		purpose = "revocation"
+	}
+	if purpose != "revocation" && purpose != "suspension" {
+		return nil, fmt.Errorf("unsupported status purpose: %s", purpose)
+	}
+
+	list, stale, err := s.list(ctx, entry.StatusListCredential)
+	if err != nil {
+		return nil, err
+	}
+	if issuer != "" && list.issuer != "" && list.issuer != issuer {
+		return nil, fmt.Errorf("status list issuer %s does not match credential issuer %s", list.issuer, issuer)
+	}
+	if list.purpose != "" && list.purpose != purpose {
+		return nil, fmt.Errorf("status list purpose %s does not match entry purpose %s", list.purpose, purpose)
+	}
+
+	var set bool
+	switch entry.Type {
+	case StatusList2021EntryType, BitstringStatusListEntryType:
+		if list.bits == nil {
+			return nil, fmt.Errorf("%s does not point at a status list", entry.Type)
+		}
+		index, err := strconv.Atoi(entry.StatusListIndex)
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("invalid status list index: %q", entry.StatusListIndex)
+		}
+		if index >= len(list.bits)*8 {
+			return nil, fmt.Errorf("status list index %d is out of range", index)
+		}
+		// Index 0 is the most significant bit of the first byte
+		set = list.bits[index/8]&(0x80>>uint(index%8)) != 0
+	case RevocationListEntryType:
+		if list.revoked == nil {
+			return nil, fmt.Errorf("%s does not point at a revocation list", entry.Type)
+		}
+		if entry.ID != "" {
+			credentialID = entry.ID
+		}
+		if credentialID == "" {
+			return nil, fmt.Errorf("revocation list check needs a credential ID")
+		}
+		set = list.revoked[credentialID]
+	default:
+		return nil, fmt.Errorf("unsupported credential status type: %s", entry.Type)
+	}
+
+	result := &CredentialStatusResult{
+		Status:    CredentialStatusValid,
+		ListURL:   entry.StatusListCredential,
+		FetchedAt: list.fetchedAt,
+		Stale:     stale,
+	}
+	if set {
+		result.Status = CredentialStatusRevoked
+		if purpose == "suspension" {
+			result.Status = CredentialStatusSuspended
+		}
+	}
+	return result, nil
+}
+
+// Invalidate drops a cached list so the next check fetches it again
+func (s *StatusListService) Invalidate(listURL string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.lists, listURL)
+}
+
+// list returns the status list at a URL, from the cache while it is fresh
+func (s *StatusListService) list(ctx context.Context, listURL string) (*statusList, bool, error) {
+	if listURL == "" {
+		return nil, false, fmt.Errorf("credential status has no list URL")
+	}
+	if !strings.HasPrefix(listURL, "https://") && !(s.allowHTTP && strings.HasPrefix(listURL, "http://")) {
+		return nil, false, fmt.Errorf("status list URL must use https: %s", listURL)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	cached := s.lists[listURL]
+	s.mu.Unlock()
+	if cached != nil && cached.usable(now, s.cacheTTL) {
+		return cached, false, nil
+	}
+
+	list, err := s.fetch(ctx, listURL)
+	if err == nil {
+		s.mu.Lock()
+		s.lists[listURL] = list
+		s.mu.Unlock()
+		return list, false, nil
+	}
+
+	if cached != nil && cached.usable(now, s.maxStaleness) {
+		fmt.Printf("STATUS LIST WARNING: failed to refresh %s, using list fetched at %s: %v\n", listURL, cached.fetchedAt.Format(time.RFC3339), err)
+		return cached, true, nil
+	}
+	return nil, false, fmt.Errorf("status list unavailable: %w", err)
+}
+
+// usable reports whether the list is younger than maxAge and has not passed
+// its own validity period
+func (l *statusList) usable(now time.Time, maxAge time.Duration) bool {
+	if !l.validUntil.IsZero() && now.After(l.validUntil) {
+		return false
+	}
+	return now.Sub(l.fetchedAt) < maxAge
+}
+
+// fetch downloads and decodes a status list credential or revocation list.
+// Lists are served as JSON credentials or as JWTs carrying one in "vc".
+func (s *StatusListService) fetch(ctx context.Context, listURL string) (*statusList, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, listURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vc+ld+json, application/vc+jwt, application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status list endpoint returned %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxStatusListBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxStatusListBody {
+		return nil, fmt.Errorf("status list exceeds %d bytes", maxStatusListBody)
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] != '{' {
+		if body, err = statusListJWTPayload(string(body)); err != nil {
+			return nil, err
+		}
+	}
+	return parseStatusList(body, time.Now())
+}
+
+// statusListDocument covers status list credentials and revocation lists
+type statusListDocument struct {
+	VC                *statusListDocument `json:"vc,omitempty"`
+	Issuer            interface{}         `json:"issuer"`
+	ValidUntil        string              `json:"validUntil,omitempty"`
+	ExpirationDate    string              `json:"expirationDate,omitempty"`
+	CredentialSubject *struct {
+		Type          string `json:"type"`
+		StatusPurpose string `json:"statusPurpose"`
+		EncodedList   string `json:"encodedList"`
+	} `json:"credentialSubject,omitempty"`
+	Revoked []string `json:"revoked,omitempty"`
+}
+
+func parseStatusList(body []byte, now time.Time) (*statusList, error) {
+	var document statusListDocument
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, fmt.Errorf("malformed status list: %w", err)
+	}
+	if document.VC != nil {
+		document = *document.VC
+	}
+
+	list := &statusList{fetchedAt: now}
+	switch issuer := document.Issuer.(type) {
+	case string:
+		list.issuer = issuer
+	case map[string]interface{}:
+		list.issuer, _ = issuer["id"].(string)
+	}
+	for _, until := range []string{document.ValidUntil, document.ExpirationDate} {
+		if until == "" {
+			continue
+		}
+		validUntil, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status list validity: %w", err)
+		}
+		list.validUntil = validUntil
+		if now.After(validUntil) {
+			return nil, fmt.Errorf("status list expired at %s", until)
+		}
+	}
+
+	if subject := document.CredentialSubject; subject != nil && subject.EncodedList != "" {
+		encoded := subject.EncodedList
+		// Bitstring status lists are multibase encoded with a "u" prefix
+		if subject.Type == "BitstringStatusList" {
+			encoded = strings.TrimPrefix(encoded, "u")
+		}
+		bits, err := decodeStatusListBits(encoded)
+		if err != nil {
+			return nil, err
+		}
+		list.bits = bits
+		list.purpose = subject.StatusPurpose
+		return list, nil
+	}
+	if document.Revoked != nil {
+		list.revoked = make(map[string]bool, len(document.Revoked))
+		for _, id := range document.Revoked {
+			list.revoked[id] = true
+		}
+		list.purpose = "revocation"
+		return list, nil
+	}
+	return nil, fmt.Errorf("document is neither a status list nor a revocation list")
+}
+
+// decodeStatusListBits decodes a base64url, GZIP-compressed bitstring
+func decodeStatusListBits(encoded string) ([]byte, error) {
+	compressed, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encoded status list: %w", err)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("invalid status list compression: %w", err)
+	}
+	defer reader.Close()
+
+	bits, err := io.ReadAll(io.LimitReader(reader, maxStatusListEntries/8+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid status list compression: %w", err)
+	}
+	if len(bits) > maxStatusListEntries/8 {
+		return nil, fmt.Errorf("status list exceeds %d entries", maxStatusListEntries)
+	}
+	if len(bits) == 0 {
+		return nil, fmt.Errorf("status list is empty")
+	}
+	return bits, nil
+}
+
+// statusListJWTPayload returns the claims of a JWT-encoded status list. Lists
+// are bound to the credential's issuer by the issuer check and fetched over
+// HTTPS from the URL the signed credential names.
+func statusListJWTPayload(token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("status list is neither JSON nor a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed status list JWT: %w", err)
+	}
+	return payload, nil
+}
+
+// EncodeStatusList compresses and encodes a bitstring as an encodedList
+func EncodeStatusList(bits []byte) (string, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(bits); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// statusListCredential builds a StatusList2021 credential with the given
+// indexes set
+func statusListCredential(t *testing.T, issuer string, size int, set ...int) map[string]interface{} {
+	t.Helper()
+	bits := make([]byte, size/8)
+	for _, index := range set {
+		bits[index/8] |= 0x80 >> uint(index%8)
+	}
+	encoded, err := EncodeStatusList(bits)
+	if err != nil {
+		t.Fatalf("EncodeStatusList failed: %v", err)
+	}
+	return map[string]interface{}{
+		"type":   []string{"VerifiableCredential", "StatusList2021Credential"},
+		"issuer": issuer,
+		"credentialSubject": map[string]interface{}{
+			"type":          "StatusList2021",
+			"statusPurpose": "revocation",
+			"encodedList":   encoded,
+		},
+	}
+}
+
+// newStatusListTestServer serves a status list and counts fetches; failing
+// makes it return errors
+func newStatusListTestServer(t *testing.T, document interface{}) (*httptest.Server, *int32, *atomic.Bool) {
+	t.Helper()
+	var fetches int32
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(document)
+	}))
+	t.Cleanup(server.Close)
+	return server, &fetches, &failing
+}
+
+func newStatusListTestService(cacheTTL, maxStaleness time.Duration) *StatusListService {
+	service := NewStatusListService(&config.Config{StatusListCacheTTL: cacheTTL, StatusListMaxStaleness: maxStaleness})
+	service.allowHTTP = true
+	return service
+}
+
+func statusEntry(url string, index string) *models.CredentialStatus {
+	return &models.CredentialStatus{
+		Type:                 StatusList2021EntryType,
+		StatusPurpose:        "revocation",
+		StatusListIndex:      index,
+		StatusListCredential: url,
+	}
+}
+
+func TestStatusListService_Check(t *testing.T) {
+	server, fetches, _ := newStatusListTestServer(t, statusListCredential(t, "did:web:issuer.example", 16384*8, 3, 94567))
+	service := newStatusListTestService(time.Minute, time.Hour)
+	ctx := context.Background()
+
+	for index, want := range map[string]string{"3": CredentialStatusRevoked, "94567": CredentialStatusRevoked, "4": CredentialStatusValid, "0": CredentialStatusValid} {
+		result, err := service.Check(ctx, "cred-1", "did:web:issuer.example", statusEntry(server.URL, index))
+		if err != nil {
+			t.Fatalf("Check(%s) failed: %v", index, err)
+		}
+		if result.Status != want {
+			t.Errorf("Check(%s) = %s, want %s", index, result.Status, want)
+		}
+	}
+	if *fetches != 1 {
+		t.Errorf("Expected the list to be fetched once, got %d", *fetches)
+	}
+
+	for name, entry := range map[string]*models.CredentialStatus{
+		"out of range":    statusEntry(server.URL, "131072"),
+		"bad index":       statusEntry(server.URL, "-1"),
+		"wrong purpose":   {Type: StatusList2021EntryType, StatusPurpose: "suspension", StatusListIndex: "1", StatusListCredential: server.URL},
+		"unknown type":    {Type: "CustomStatus", StatusListIndex: "1", StatusListCredential: server.URL},
+		"insecure scheme": statusEntry(strings.Replace(server.URL, "http://", "ftp://", 1), "1"),
+	} {
+		if _, err := service.Check(ctx, "cred-1", "did:web:issuer.example", entry); err == nil {
+			t.Errorf("Expected %s to fail", name)
+		}
+	}
+
+	if _, err := service.Check(ctx, "cred-1", "did:web:other.example", statusEntry(server.URL, "1")); err == nil {
+		t.Error("Expected a list from another issuer to be rejected")
+	}
+}
+
+func TestStatusListService_Staleness(t *testing.T) {
+	server, fetches, failing := newStatusListTestServer(t, statusListCredential(t, "did:web:issuer.example", 1024, 7))
+	service := newStatusListTestService(time.Minute, time.Hour)
+	ctx := context.Background()
+
+	if _, err := service.Check(ctx, "cred-1", "", statusEntry(server.URL, "7")); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	// Past the cache TTL a failed refresh falls back to the cached list
+	failing.Store(true)
+	service.lists[server.URL].fetchedAt = time.Now().Add(-2 * time.Minute)
+	result, err := service.Check(ctx, "cred-1", "", statusEntry(server.URL, "7"))
+	if err != nil {
+		t.Fatalf("Expected the cached list within the staleness limit, got %v", err)
+	}
+	if !result.Stale || !result.Revoked() {
+		t.Errorf("Expected a stale revoked result, got %+v", result)
+	}
+
+	// Past the staleness limit the status is unknown
+	service.lists[server.URL].fetchedAt = time.Now().Add(-2 * time.Hour)
+	if _, err := service.Check(ctx, "cred-1", "", statusEntry(server.URL, "7")); err == nil {
+		t.Error("Expected a check against a list past the staleness limit to fail")
+	}
+	if *fetches != 3 {
+		t.Errorf("Expected 3 fetches, got %d", *fetches)
+	}
+}
+
+func TestStatusListService_RevocationList(t *testing.T) {
+	server, _, _ := newStatusListTestServer(t, map[string]interface{}{
+		"issuer":  "https://issuer.example",
+		"revoked": []string{"cred-revoked"},
+	})
+	service := newStatusListTestService(time.Minute, time.Hour)
+	entry := &models.CredentialStatus{Type: RevocationListEntryType, StatusListCredential: server.URL}
+
+	revoked, err := service.Check(context.Background(), "cred-revoked", "https://issuer.example", entry)
+	if err != nil || !revoked.Revoked() {
+		t.Errorf("Expected cred-revoked to be revoked, got %+v, %v", revoked, err)
+	}
+	valid, err := service.Check(context.Background(), "cred-valid", "https://issuer.example", entry)
+	if err != nil || valid.Revoked() {
+		t.Errorf("Expected cred-valid to be valid, got %+v, %v", valid, err)
+	}
+}
+
+func TestCredentialValidator_StatusList(t *testing.T) {
+	server, _, failing := newStatusListTestServer(t, statusListCredential(t, "https://issuer.example", 1024, 5))
+	validator := NewCredentialValidator()
+	validator.SetStatusListService(newStatusListTestService(time.Minute, time.Hour))
+
+	credential := models.Credential{
+		ID:           "cred-1",
+		Type:         "StudentCredential",
+		Issuer:       "https://issuer.example",
+		Subject:      "did:example:student",
+		IssuanceDate: "2024-01-01T00:00:00Z",
+		Version:      "1.0",
+		Claims:       map[string]interface{}{"enrolled": true},
+		Proof: models.CredentialProof{
+			Type:               "JwtProof2020",
+			Created:            "2024-01-01T00:00:00Z",
+			VerificationMethod: "https://issuer.example#key-1",
+			ProofPurpose:       "assertionMethod",
+			JWS:                "eyJhbGciOiJSUzI1NiJ9.e30.c2ln",
+		},
+		Status:           "valid",
+		CredentialStatus: statusEntry(server.URL, "6"),
+	}
+	result, err := validator.ValidateCredential(context.Background(), &credential)
+	if err != nil || !result.Valid || !result.NotRevoked {
+		t.Fatalf("Expected a valid credential, got %+v, %v", result, err)
+	}
+
+	credential.CredentialStatus = statusEntry(server.URL, "5")
+	result, _ = validator.ValidateCredential(context.Background(), &credential)
+	if result.Valid || result.NotRevoked {
+		t.Errorf("Expected a revoked credential, got %+v", result)
+	}
+
+	// An unreachable list fails closed
+	failing.Store(true)
+	credential.CredentialStatus = statusEntry(server.URL+"/other", "6")
+	result, _ = validator.ValidateCredential(context.Background(), &credential)
+	if result.Valid {
+		t.Errorf("Expected an unchecked credential to be invalid, got %+v", result)
+	}
+}
+
+func TestOpenID4VPService_RevokedCredential(t *testing.T) {
+	server, _, _ := newStatusListTestServer(t, statusListCredential(t, "https://broker.example", 1024, 9))
+	service, _ := newOpenID4VPTestService(t)
+	issuerKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	issue := func(index string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+			"iss":              "https://broker.example",
+			"_sd_alg":          sdJWTDigestAlg,
+			"credentialStatus": statusEntry(server.URL, index),
+		}).SignedString(issuerKey)
+		if err != nil {
+			t.Fatalf("SignedString failed: %v", err)
+		}
+		return token + "~"
+	}
+
+	if err := service.checkStatus(context.Background(), issue("9"), "https://broker.example", &issuerKey.PublicKey); err == nil {
+		t.Error("Expected a credential with a status entry to fail without status checking")
+	}
+
+	service.SetStatusListService(newStatusListTestService(time.Minute, time.Hour))
+	if err := service.checkStatus(context.Background(), issue("8"), "https://broker.example", &issuerKey.PublicKey); err != nil {
+		t.Errorf("Expected an unrevoked credential to pass, got %v", err)
+	}
+	if err := service.checkStatus(context.Background(), issue("9"), "https://broker.example", &issuerKey.PublicKey); err == nil || !strings.Contains(err.Error(), "revoked") {
+		t.Errorf("Expected a revoked credential to fail, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// Storage backends
+const (
+	StorageBackendMemory   = "memory"
+	StorageBackendPostgres = "postgres"
+	StorageBackendSQLite   = "sqlite"
+)
+
+// ErrStorageNotFound is returned when a stored record does not exist
+var ErrStorageNotFound = errors.New("record not found")
+
+// AuditEntryFilter selects stored audit entries; zero fields match every
+// entry, and entries are returned oldest first
+type AuditEntryFilter struct {
+	RPID  string
+	Since time.Time
+	Limit int
+}
+
+// AuditStore keeps audit entries, keyed by their audit entry ID. Appending
+// an entry already stored is a no-op, so outbox redeliveries are harmless.
+type AuditStore interface {
+	AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error
+	GetAuditEntry(ctx context.Context, auditEntryID string) (*models.AuditEntry, error)
+	ListAuditEntries(ctx context.Context, filter AuditEntryFilter) ([]*models.AuditEntry, error)
+}
+
+// JobStore keeps the state of pull jobs
+type JobStore interface {
+	SaveJob(ctx context.Context, job *JobStatus) error
+	GetJob(ctx context.Context, jobID string) (*JobStatus, error)
+	DeleteJobsBefore(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// ConsentStore keeps consent revocations. A revocation is stored once per
+// claim type, and a later revocation of the same claim type replaces it.
+type ConsentStore interface {
+	SaveConsentRevocation(ctx context.Context, revocation ConsentRevocation) error
+	ListConsentRevocations(ctx context.Context, since time.Time) ([]ConsentRevocation, error)
+}
+
+// OutboxStore keeps messages waiting to be delivered by the outbox
+// dispatcher. A claimed message is not claimed again until its lease
+// expires, so a dispatcher that crashes mid-delivery leaves it to be retried.
+type OutboxStore interface {
+	// CommitOutbox stores a job's state, when given, and the messages in one
+	// transaction
+	CommitOutbox(ctx context.Context, job *JobStatus, messages []OutboxMessage) error
+	ClaimOutboxMessages(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]OutboxMessage, error)
+	DeleteOutboxMessage(ctx context.Context, id string) error
+	// UpdateOutboxMessage stores a message's attempts, next attempt, last
+	// error and status
+	UpdateOutboxMessage(ctx context.Context, message OutboxMessage) error
+}
+
+// Storage is where the broker keeps the state that must survive restarts:
+// audit entries, pull jobs, consent revocations and the outbox. Tests and
+// single-node development use the in-memory implementation; production uses
+// Postgres.
+type Storage interface {
+	AuditStore
+	JobStore
+	ConsentStore
+	OutboxStore
+	Close() error
+}
+
+// OpenStorage opens the storage backend selected by STORAGE_BACKEND. SQL
+// backends have their pending migrations applied when STORAGE_AUTO_MIGRATE
+// is set, and are refused with migrations pending otherwise.
+func OpenStorage(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageBackend {
+	case "", StorageBackendMemory:
+		return NewMemoryStorage(), nil
+	case StorageBackendPostgres, StorageBackendSQLite:
+	default:
+		return nil, fmt.Errorf("unsupported storage backend %q", cfg.StorageBackend)
+	}
+
+	store, err := OpenSQLStorage(cfg.StorageBackend, cfg.StorageURL)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if cfg.StorageAutoMigrate {
+		if _, err := store.Migrate(ctx); err != nil {
+			store.Close()
+			return nil, err
+		}
+		return store, nil
+	}
+	pending, err := store.PendingMigrations(ctx)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if len(pending) > 0 {
+		store.Close()
+		return nil, fmt.Errorf("storage has %d pending migrations; run storage-migrate up", len(pending))
+	}
+	return store, nil
+}
+
+// auditEntryIDOf returns the audit entry ID an entry was logged under
+func auditEntryIDOf(entry *models.AuditEntry) (string, error) {
+	if id, ok := entry.Metadata["audit_entry_id"].(string); ok && id != "" {
+		return id, nil
+	}
+	return "", fmt.Errorf("audit entry has no audit_entry_id")
+}
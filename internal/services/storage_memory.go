@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// memoryAuditEntryLimit bounds the audit entries kept in memory; the oldest
+// are dropped first
+const memoryAuditEntryLimit = 10000
+
+// MemoryStorage keeps the broker's state in process memory. It is lost on
+// restart, so it suits tests and development rather than production.
+type MemoryStorage struct {
+	mu          sync.RWMutex
+	audit       []*models.AuditEntry
+	auditIndex  map[string]*models.AuditEntry
+	jobs        map[string]*JobStatus
+	revocations map[consentRevocationKey]ConsentRevocation
+	outbox      map[string]OutboxMessage
+}
+
+type consentRevocationKey struct {
+	rpID      string
+	userID    string
+	claimType string
+}
+
+// NewMemoryStorage creates an empty in-memory storage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		auditIndex:  make(map[string]*models.AuditEntry),
+		jobs:        make(map[string]*JobStatus),
+		revocations: make(map[consentRevocationKey]ConsentRevocation),
+		outbox:      make(map[string]OutboxMessage),
+	}
+}
+
+// AppendAuditEntry stores an audit entry
+func (m *MemoryStorage) AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	id, err := auditEntryIDOf(entry)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.auditIndex[id]; exists {
+		return nil
+	}
+	m.audit = append(m.audit, entry)
+	m.auditIndex[id] = entry
+	if len(m.audit) > memoryAuditEntryLimit {
+		dropped := m.audit[0]
+		m.audit = m.audit[1:]
+		if droppedID, err := auditEntryIDOf(dropped); err == nil && m.auditIndex[droppedID] == dropped {
+			delete(m.auditIndex, droppedID)
+		}
+	}
+	return nil
+}
+
+// GetAuditEntry returns the audit entry logged under an ID
+func (m *MemoryStorage) GetAuditEntry(ctx context.Context, auditEntryID string) (*models.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, exists := m.auditIndex[auditEntryID]
+	if !exists {
+		return nil, ErrStorageNotFound
+	}
+	return entry, nil
+}
+
+// ListAuditEntries returns the audit entries matching a filter
+func (m *MemoryStorage) ListAuditEntries(ctx context.Context, filter AuditEntryFilter) ([]*models.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := make([]*models.AuditEntry, 0)
+	for _, entry := range m.audit {
+		if filter.RPID != "" && entry.RPID != filter.RPID {
+			continue
+		}
+		if !filter.Since.IsZero() {
+			timestamp, err := time.Parse(time.RFC3339, entry.Timestamp)
+			if err != nil || timestamp.Before(filter.Since) {
+				continue
+			}
+		}
+		entries = append(entries, entry)
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// SaveJob stores a copy of a job's state
+func (m *MemoryStorage) SaveJob(ctx context.Context, job *JobStatus) error {
+	saved := *job
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job.JobID] = &saved
+	return nil
+}
+
+// GetJob returns a copy of a stored job
+func (m *MemoryStorage) GetJob(ctx context.Context, jobID string) (*JobStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, exists := m.jobs[jobID]
+	if !exists {
+		return nil, ErrStorageNotFound
+	}
+	found := *job
+	return &found, nil
+}
+
+// DeleteJobsBefore deletes jobs last updated before the cutoff
+func (m *MemoryStorage) DeleteJobsBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deleted := 0
+	for jobID, job := range m.jobs {
+		if job.UpdatedAt.Before(cutoff) {
+			delete(m.jobs, jobID)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// SaveConsentRevocation stores a revocation for each of its claim types
+func (m *MemoryStorage) SaveConsentRevocation(ctx context.Context, revocation ConsentRevocation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, claimType := range revocationClaimTypes(revocation) {
+		key := consentRevocationKey{rpID: revocation.RPID, userID: revocation.UserID, claimType: claimType}
+		m.revocations[key] = singleClaimRevocation(revocation, claimType)
+	}
+	return nil
+}
+
+// ListConsentRevocations returns the revocations made since a time, one per
+// claim type
+func (m *MemoryStorage) ListConsentRevocations(ctx context.Context, since time.Time) ([]ConsentRevocation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	revocations := make([]ConsentRevocation, 0, len(m.revocations))
+	for _, revocation := range m.revocations {
+		if !revocation.RevokedAt.Before(since) {
+			revocations = append(revocations, revocation)
+		}
+	}
+	return revocations, nil
+}
+
+// CommitOutbox stores a job's state, when given, and the messages together
+func (m *MemoryStorage) CommitOutbox(ctx context.Context, job *JobStatus, messages []OutboxMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job != nil {
+		saved := *job
+		m.jobs[job.JobID] = &saved
+	}
+	for _, message := range messages {
+		m.outbox[message.ID] = message
+	}
+	return nil
+}
+
+// ClaimOutboxMessages returns up to limit pending messages due by now,
+// oldest first, leasing them until now plus lease
+func (m *MemoryStorage) ClaimOutboxMessages(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]OutboxMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	due := make([]OutboxMessage, 0)
+	for _, message := range m.outbox {
+		if message.Status == OutboxPending && !message.NextAttemptAt.After(now) {
+			due = append(due, message)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].CreatedAt.Before(due[j].CreatedAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	for i := range due {
+		due[i].NextAttemptAt = now.Add(lease)
+		m.outbox[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+// DeleteOutboxMessage removes a delivered message
+func (m *MemoryStorage) DeleteOutboxMessage(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.outbox, id)
+	return nil
+}
+
+// UpdateOutboxMessage stores a message's delivery state
+func (m *MemoryStorage) UpdateOutboxMessage(ctx context.Context, message OutboxMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.outbox[message.ID]; !exists {
+		return ErrStorageNotFound
+	}
+	m.outbox[message.ID] = message
+	return nil
+}
+
+// Close releases nothing; memory storage needs no cleanup
+func (m *MemoryStorage) Close() error {
+	return nil
+}
+
+// revocationClaimTypes returns the claim types a revocation covers, "*" for
+// every claim type
+func revocationClaimTypes(revocation ConsentRevocation) []string {
+	if len(revocation.ClaimTypes) == 0 {
+		return []string{allClaimTypes}
+	}
+	return revocation.ClaimTypes
+}
+
+// singleClaimRevocation returns a revocation narrowed to one claim type
+func singleClaimRevocation(revocation ConsentRevocation, claimType string) ConsentRevocation {
+	revocation.ClaimTypes = nil
+	if claimType != allClaimTypes {
+		revocation.ClaimTypes = []string{claimType}
+	}
+	return revocation
+}
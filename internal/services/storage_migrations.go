@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StorageMigration is one versioned change to the storage schema, written
+// for each SQL backend
+type StorageMigration struct {
+	Version  int
+	Name     string
+	Postgres []string
+	SQLite   []string
+}
+
+// storageMigrations are applied in version order. Released migrations are
+// never edited; schema changes are added as new versions.
+var storageMigrations = []StorageMigration{
+	{
+		Version: 1,
+		Name:    "create_audit_entries",
+		Postgres: []string{
+			`CREATE TABLE audit_entries (
+				audit_entry_id VARCHAR(255) PRIMARY KEY,
+				recorded_at TIMESTAMPTZ NOT NULL,
+				rp_id VARCHAR(255) NOT NULL,
+				claim_type VARCHAR(255) NOT NULL,
+				status VARCHAR(100) NOT NULL,
+				entry JSONB NOT NULL
+			)`,
+			`CREATE INDEX idx_audit_entries_rp_recorded ON audit_entries(rp_id, recorded_at)`,
+			`CREATE INDEX idx_audit_entries_recorded ON audit_entries(recorded_at)`,
+		},
+		SQLite: []string{
+			`CREATE TABLE audit_entries (
+				audit_entry_id TEXT PRIMARY KEY,
+				recorded_at TIMESTAMP NOT NULL,
+				rp_id TEXT NOT NULL,
+				claim_type TEXT NOT NULL,
+				status TEXT NOT NULL,
+				entry TEXT NOT NULL
+			)`,
+			`CREATE INDEX idx_audit_entries_rp_recorded ON audit_entries(rp_id, recorded_at)`,
+			`CREATE INDEX idx_audit_entries_recorded ON audit_entries(recorded_at)`,
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create_pull_jobs",
+		Postgres: []string{
+			`CREATE TABLE pull_jobs (
+				job_id VARCHAR(255) PRIMARY KEY,
+				status VARCHAR(50) NOT NULL,
+				updated_at TIMESTAMPTZ NOT NULL,
+				job JSONB NOT NULL
+			)`,
+			`CREATE INDEX idx_pull_jobs_updated ON pull_jobs(updated_at)`,
+		},
+		SQLite: []string{
+			`CREATE TABLE pull_jobs (
+				job_id TEXT PRIMARY KEY,
+				status TEXT NOT NULL,
+				updated_at TIMESTAMP NOT NULL,
+				job TEXT NOT NULL
+			)`,
+			`CREATE INDEX idx_pull_jobs_updated ON pull_jobs(updated_at)`,
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create_consent_revocations",
+		Postgres: []string{
+			`CREATE TABLE consent_revocations (
+				rp_id VARCHAR(255) NOT NULL,
+				user_id VARCHAR(255) NOT NULL,
+				claim_type VARCHAR(255) NOT NULL,
+				revoked_at TIMESTAMPTZ NOT NULL,
+				reason TEXT NOT NULL DEFAULT '',
+				PRIMARY KEY (rp_id, user_id, claim_type)
+			)`,
+			`CREATE INDEX idx_consent_revocations_revoked ON consent_revocations(revoked_at)`,
+		},
+		SQLite: []string{
+			`CREATE TABLE consent_revocations (
+				rp_id TEXT NOT NULL,
+				user_id TEXT NOT NULL,
+				claim_type TEXT NOT NULL,
+				revoked_at TIMESTAMP NOT NULL,
+				reason TEXT NOT NULL DEFAULT '',
+				PRIMARY KEY (rp_id, user_id, claim_type)
+			)`,
+			`CREATE INDEX idx_consent_revocations_revoked ON consent_revocations(revoked_at)`,
+		},
+	},
+	{
+		Version: 4,
+		Name:    "create_outbox_messages",
+		Postgres: []string{
+			`CREATE TABLE outbox_messages (
+				id VARCHAR(255) PRIMARY KEY,
+				kind VARCHAR(100) NOT NULL,
+				payload JSONB NOT NULL,
+				status VARCHAR(50) NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				next_attempt_at TIMESTAMPTZ NOT NULL,
+				last_error TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE INDEX idx_outbox_messages_due ON outbox_messages(status, next_attempt_at)`,
+		},
+		SQLite: []string{
+			`CREATE TABLE outbox_messages (
+				id TEXT PRIMARY KEY,
+				kind TEXT NOT NULL,
+				payload TEXT NOT NULL,
+				status TEXT NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				next_attempt_at TIMESTAMP NOT NULL,
+				last_error TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idx_outbox_messages_due ON outbox_messages(status, next_attempt_at)`,
+		},
+	},
+}
+
+// StorageMigrations returns every storage migration in version order
+func StorageMigrations() []StorageMigration {
+	return append([]StorageMigration(nil), storageMigrations...)
+}
+
+// statements returns a migration's statements for a backend
+func (m StorageMigration) statements(backend string) []string {
+	if backend == StorageBackendSQLite {
+		return m.SQLite
+	}
+	return m.Postgres
+}
+
+// ensureMigrationTable creates the table recording applied migrations
+func (s *SQLStorage) ensureMigrationTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// AppliedMigrations returns the versions of the migrations already applied
+func (s *SQLStorage) AppliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+	if err := s.ensureMigrationTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// PendingMigrations returns the migrations not yet applied, in order
+func (s *SQLStorage) PendingMigrations(ctx context.Context) ([]StorageMigration, error) {
+	applied, err := s.AppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]StorageMigration, 0)
+	for _, migration := range storageMigrations {
+		if _, done := applied[migration.Version]; !done {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies the pending migrations in order, each in its own
+// transaction, and returns those it applied. It stops at the first failure,
+// leaving the earlier migrations applied.
+func (s *SQLStorage) Migrate(ctx context.Context) ([]StorageMigration, error) {
+	pending, err := s.PendingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]StorageMigration, 0, len(pending))
+	for _, migration := range pending {
+		if err := s.applyMigration(ctx, migration); err != nil {
+			return applied, err
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+func (s *SQLStorage) applyMigration(ctx context.Context, migration StorageMigration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	defer tx.Rollback()
+
+	for _, statement := range migration.statements(s.backend) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, s.bind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+		migration.Version, migration.Name, time.Now().UTC()); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// sqliteDriverName is the database/sql driver the sqlite backend opens. No
+// SQLite driver is linked into the default build; binaries using the
+// backend register one, such as github.com/mattn/go-sqlite3.
+const sqliteDriverName = "sqlite3"
+
+// SQLStorage keeps the broker's state in Postgres or SQLite. Records are
+// stored as JSON next to the columns they are looked up by.
+type SQLStorage struct {
+	db      *sql.DB
+	backend string
+}
+
+// sqlExecer is a database or a transaction
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// OpenSQLStorage connects to a Postgres or SQLite database. Its schema is
+// not changed; see Migrate.
+func OpenSQLStorage(backend, url string) (*SQLStorage, error) {
+	driver := backend
+	switch backend {
+	case StorageBackendPostgres:
+	case StorageBackendSQLite:
+		driver = sqliteDriverName
+		if !containsString(sql.Drivers(), driver) {
+			return nil, fmt.Errorf("the sqlite storage backend needs a binary with a %s database/sql driver", driver)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported SQL storage backend %q", backend)
+	}
+	if url == "" {
+		return nil, fmt.Errorf("STORAGE_URL is required for the %s storage backend", backend)
+	}
+
+	db, err := sql.Open(driver, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s storage: %w", backend, err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s storage: %w", backend, err)
+	}
+	return &SQLStorage{db: db, backend: backend}, nil
+}
+
+// bind rewrites ? placeholders as $n for Postgres
+func (s *SQLStorage) bind(query string) string {
+	if s.backend != StorageBackendPostgres {
+		return query
+	}
+	var bound strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			bound.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		bound.WriteRune(r)
+	}
+	return bound.String()
+}
+
+// AppendAuditEntry stores an audit entry
+func (s *SQLStorage) AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	id, err := auditEntryIDOf(entry)
+	if err != nil {
+		return err
+	}
+	recordedAt, err := time.Parse(time.RFC3339, entry.Timestamp)
+	if err != nil {
+		recordedAt = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, s.bind(`INSERT INTO audit_entries (audit_entry_id, recorded_at, rp_id, claim_type, status, entry)
+		VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (audit_entry_id) DO NOTHING`), id, recordedAt.UTC(), entry.RPID, entry.ClaimType, entry.Status, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to store audit entry: %w", err)
+	}
+	return nil
+}
+
+// GetAuditEntry returns the audit entry logged under an ID
+func (s *SQLStorage) GetAuditEntry(ctx context.Context, auditEntryID string) (*models.AuditEntry, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, s.bind(`SELECT entry FROM audit_entries WHERE audit_entry_id = ?`), auditEntryID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrStorageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit entry: %w", err)
+	}
+	var entry models.AuditEntry
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode audit entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// ListAuditEntries returns the audit entries matching a filter
+func (s *SQLStorage) ListAuditEntries(ctx context.Context, filter AuditEntryFilter) ([]*models.AuditEntry, error) {
+	query := `SELECT entry FROM audit_entries WHERE 1 = 1`
+	var args []interface{}
+	if filter.RPID != "" {
+		query += ` AND rp_id = ?`
+		args = append(args, filter.RPID)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND recorded_at >= ?`
+		args = append(args, filter.Since.UTC())
+	}
+	query += ` ORDER BY recorded_at, audit_entry_id`
+	if filter.Limit > 0 {
+		query += ` LIMIT ` + strconv.Itoa(filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.bind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*models.AuditEntry, 0)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read audit entry: %w", err)
+		}
+		var entry models.AuditEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode audit entry: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
+
+// SaveJob stores a job's state, replacing what was stored before
+func (s *SQLStorage) SaveJob(ctx context.Context, job *JobStatus) error {
+	return s.saveJob(ctx, s.db, job)
+}
+
+func (s *SQLStorage) saveJob(ctx context.Context, db sqlExecer, job *JobStatus) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+	_, err = db.ExecContext(ctx, s.bind(`INSERT INTO pull_jobs (job_id, status, updated_at, job) VALUES (?, ?, ?, ?)
+		ON CONFLICT (job_id) DO UPDATE SET status = excluded.status, updated_at = excluded.updated_at, job = excluded.job`),
+		job.JobID, string(job.Status), job.UpdatedAt.UTC(), string(data))
+	if err != nil {
+		return fmt.Errorf("failed to store job: %w", err)
+	}
+	return nil
+}
+
+// GetJob returns a stored job
+func (s *SQLStorage) GetJob(ctx context.Context, jobID string) (*JobStatus, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, s.bind(`SELECT job FROM pull_jobs WHERE job_id = ?`), jobID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrStorageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job: %w", err)
+	}
+	var job JobStatus
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, fmt.Errorf("failed to decode job: %w", err)
+	}
+	return &job, nil
+}
+
+// DeleteJobsBefore deletes jobs last updated before the cutoff
+func (s *SQLStorage) DeleteJobsBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, s.bind(`DELETE FROM pull_jobs WHERE updated_at < ?`), cutoff.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete jobs: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+	return int(deleted), nil
+}
+
+// SaveConsentRevocation stores a revocation for each of its claim types
+func (s *SQLStorage) SaveConsentRevocation(ctx context.Context, revocation ConsentRevocation) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to store consent revocation: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, claimType := range revocationClaimTypes(revocation) {
+		_, err := tx.ExecContext(ctx, s.bind(`INSERT INTO consent_revocations (rp_id, user_id, claim_type, revoked_at, reason) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (rp_id, user_id, claim_type) DO UPDATE SET revoked_at = excluded.revoked_at, reason = excluded.reason`),
+			revocation.RPID, revocation.UserID, claimType, revocation.RevokedAt.UTC(), revocation.Reason)
+		if err != nil {
+			return fmt.Errorf("failed to store consent revocation: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store consent revocation: %w", err)
+	}
+	return nil
+}
+
+// ListConsentRevocations returns the revocations made since a time, one per
+// claim type
+func (s *SQLStorage) ListConsentRevocations(ctx context.Context, since time.Time) ([]ConsentRevocation, error) {
+	rows, err := s.db.QueryContext(ctx, s.bind(`SELECT rp_id, user_id, claim_type, revoked_at, reason FROM consent_revocations
+		WHERE revoked_at >= ? ORDER BY revoked_at`), since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consent revocations: %w", err)
+	}
+	defer rows.Close()
+
+	revocations := make([]ConsentRevocation, 0)
+	for rows.Next() {
+		var revocation ConsentRevocation
+		var claimType string
+		if err := rows.Scan(&revocation.RPID, &revocation.UserID, &claimType, &revocation.RevokedAt, &revocation.Reason); err != nil {
+			return nil, fmt.Errorf("failed to read consent revocation: %w", err)
+		}
+		revocations = append(revocations, singleClaimRevocation(revocation, claimType))
+	}
+	return revocations, rows.Err()
+}
+
+// CommitOutbox stores a job's state, when given, and the messages in one
+// transaction
+func (s *SQLStorage) CommitOutbox(ctx context.Context, job *JobStatus, messages []OutboxMessage) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to commit outbox: %w", err)
+	}
+	defer tx.Rollback()
+
+	if job != nil {
+		if err := s.saveJob(ctx, tx, job); err != nil {
+			return err
+		}
+	}
+	for _, message := range messages {
+		_, err := tx.ExecContext(ctx, s.bind(`INSERT INTO outbox_messages (id, kind, payload, status, attempts, next_attempt_at, last_error, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`), message.ID, message.Kind, string(message.Payload), message.Status,
+			message.Attempts, message.NextAttemptAt.UTC(), message.LastError, message.CreatedAt.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to store outbox message: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit outbox: %w", err)
+	}
+	return nil
+}
+
+// ClaimOutboxMessages returns up to limit pending messages due by now,
+// oldest first, leasing them until now plus lease. On Postgres, messages
+// another dispatcher is claiming are skipped rather than waited for.
+func (s *SQLStorage) ClaimOutboxMessages(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]OutboxMessage, error) {
+	locking := ""
+	if s.backend == StorageBackendPostgres {
+		locking = " FOR UPDATE SKIP LOCKED"
+	}
+	rows, err := s.db.QueryContext(ctx, s.bind(`UPDATE outbox_messages SET next_attempt_at = ? WHERE id IN (
+		SELECT id FROM outbox_messages WHERE status = ? AND next_attempt_at <= ? ORDER BY created_at LIMIT `+strconv.Itoa(limit)+locking+`)
+		RETURNING id, kind, payload, status, attempts, next_attempt_at, last_error, created_at`),
+		now.Add(lease).UTC(), OutboxPending, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := make([]OutboxMessage, 0)
+	for rows.Next() {
+		var message OutboxMessage
+		var payload string
+		if err := rows.Scan(&message.ID, &message.Kind, &payload, &message.Status, &message.Attempts,
+			&message.NextAttemptAt, &message.LastError, &message.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read outbox message: %w", err)
+		}
+		message.Payload = json.RawMessage(payload)
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+	return messages, nil
+}
+
+// DeleteOutboxMessage removes a delivered message
+func (s *SQLStorage) DeleteOutboxMessage(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, s.bind(`DELETE FROM outbox_messages WHERE id = ?`), id); err != nil {
+		return fmt.Errorf("failed to delete outbox message: %w", err)
+	}
+	return nil
+}
+
+// UpdateOutboxMessage stores a message's delivery state
+func (s *SQLStorage) UpdateOutboxMessage(ctx context.Context, message OutboxMessage) error {
+	result, err := s.db.ExecContext(ctx, s.bind(`UPDATE outbox_messages SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?`),
+		message.Status, message.Attempts, message.NextAttemptAt.UTC(), message.LastError, message.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update outbox message: %w", err)
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return ErrStorageNotFound
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *SQLStorage) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// testStorage runs the behaviour every Storage implementation shares
+func testStorage(t *testing.T, store Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for i, rpID := range []string{"rp_1", "rp_2", "rp_1"} {
+		entry := &models.AuditEntry{
+			Timestamp: now.Add(time.Duration(i) * time.Minute).Format(time.RFC3339),
+			RPID:      rpID,
+			ClaimType: "student_verification",
+			Status:    "SUCCESS",
+			Metadata:  map[string]interface{}{"audit_entry_id": "audit_" + string(rune('a'+i))},
+		}
+		if err := store.AppendAuditEntry(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	redelivered := &models.AuditEntry{Timestamp: now.Format(time.RFC3339), RPID: "rp_1", Metadata: map[string]interface{}{"audit_entry_id": "audit_a"}}
+	if err := store.AppendAuditEntry(ctx, redelivered); err != nil {
+		t.Errorf("Expected appending a stored entry again to be a no-op, got %v", err)
+	}
+	if err := store.AppendAuditEntry(ctx, &models.AuditEntry{RPID: "rp_1"}); err == nil {
+		t.Error("Expected an entry without an audit_entry_id to be rejected")
+	}
+	if entry, err := store.GetAuditEntry(ctx, "audit_b"); err != nil || entry.RPID != "rp_2" {
+		t.Errorf("Expected audit_b for rp_2, got %+v, %v", entry, err)
+	}
+	if _, err := store.GetAuditEntry(ctx, "audit_z"); !errors.Is(err, ErrStorageNotFound) {
+		t.Errorf("Expected ErrStorageNotFound, got %v", err)
+	}
+	if entries, _ := store.ListAuditEntries(ctx, AuditEntryFilter{RPID: "rp_1"}); len(entries) != 2 {
+		t.Errorf("Expected 2 entries for rp_1, got %d", len(entries))
+	}
+	if entries, _ := store.ListAuditEntries(ctx, AuditEntryFilter{Since: now.Add(time.Minute), Limit: 1}); len(entries) != 1 || entries[0].RPID != "rp_2" {
+		t.Errorf("Expected the first entry after a minute, got %+v", entries)
+	}
+
+	job := &JobStatus{JobID: "job_1", Status: JobRunning, UpdatedAt: now.Add(-48 * time.Hour)}
+	if err := store.SaveJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	job.Status = JobCompleted
+	store.SaveJob(ctx, job)
+	store.SaveJob(ctx, &JobStatus{JobID: "job_2", Status: JobPending, UpdatedAt: now})
+	if stored, err := store.GetJob(ctx, "job_1"); err != nil || stored.Status != JobCompleted {
+		t.Errorf("Expected the latest job state, got %+v, %v", stored, err)
+	}
+	if deleted, err := store.DeleteJobsBefore(ctx, now.Add(-24*time.Hour)); err != nil || deleted != 1 {
+		t.Errorf("Expected 1 expired job deleted, got %d, %v", deleted, err)
+	}
+	if _, err := store.GetJob(ctx, "job_1"); !errors.Is(err, ErrStorageNotFound) {
+		t.Errorf("Expected the expired job to be gone, got %v", err)
+	}
+
+	store.SaveConsentRevocation(ctx, ConsentRevocation{RPID: "rp_1", UserID: "user_1", RevokedAt: now.Add(-100 * 24 * time.Hour)})
+	store.SaveConsentRevocation(ctx, ConsentRevocation{RPID: "rp_1", UserID: "user_2", ClaimTypes: []string{"a", "b"}, RevokedAt: now})
+	revocations, err := store.ListConsentRevocations(ctx, now.Add(-time.Hour))
+	if err != nil || len(revocations) != 2 {
+		t.Fatalf("Expected one revocation per recent claim type, got %+v, %v", revocations, err)
+	}
+	for _, revocation := range revocations {
+		if revocation.UserID != "user_2" || len(revocation.ClaimTypes) != 1 {
+			t.Errorf("Unexpected revocation %+v", revocation)
+		}
+	}
+	first, _ := NewOutboxMessage(OutboxAuditEntry, map[string]string{"n": "1"})
+	second, _ := NewOutboxMessage(OutboxNotification, map[string]string{"n": "2"})
+	second.CreatedAt = first.CreatedAt.Add(time.Millisecond)
+	if err := store.CommitOutbox(ctx, &JobStatus{JobID: "job_3", Status: JobCompleted, UpdatedAt: now}, []OutboxMessage{second, first}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetJob(ctx, "job_3"); err != nil {
+		t.Errorf("Expected the job committed with the outbox, got %v", err)
+	}
+	claimed, err := store.ClaimOutboxMessages(ctx, time.Now(), 10, time.Minute)
+	if err != nil || len(claimed) != 2 || claimed[0].ID != first.ID || string(claimed[0].Payload) != `{"n":"1"}` {
+		t.Fatalf("Expected both messages oldest first, got %+v, %v", claimed, err)
+	}
+	if again, _ := store.ClaimOutboxMessages(ctx, time.Now(), 10, time.Minute); len(again) != 0 {
+		t.Errorf("Expected leased messages not to be claimed again, got %d", len(again))
+	}
+	store.DeleteOutboxMessage(ctx, first.ID)
+	claimed[1].Status = OutboxDead
+	if err := store.UpdateOutboxMessage(ctx, claimed[1]); err != nil {
+		t.Error(err)
+	}
+	if again, _ := store.ClaimOutboxMessages(ctx, time.Now().Add(time.Hour), 10, time.Minute); len(again) != 0 {
+		t.Errorf("Expected delivered and dead messages not to be claimed, got %d", len(again))
+	}
+	if err := store.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, NewMemoryStorage())
+}
+
+func TestOpenStorage(t *testing.T) {
+	if store, err := OpenStorage(&config.Config{StorageBackend: StorageBackendMemory}); err != nil {
+		t.Error(err)
+	} else if _, ok := store.(*MemoryStorage); !ok {
+		t.Errorf("Expected memory storage, got %T", store)
+	}
+	if _, err := OpenStorage(&config.Config{StorageBackend: "mongodb"}); err == nil {
+		t.Error("Expected an unknown backend to be rejected")
+	}
+	if _, err := OpenStorage(&config.Config{StorageBackend: StorageBackendPostgres}); err == nil {
+		t.Error("Expected postgres without STORAGE_URL to be rejected")
+	}
+}
+
+func TestSQLStorage_Bind(t *testing.T) {
+	query := `SELECT entry FROM audit_entries WHERE rp_id = ? AND recorded_at >= ?`
+	postgres := &SQLStorage{backend: StorageBackendPostgres}
+	if bound := postgres.bind(query); bound != `SELECT entry FROM audit_entries WHERE rp_id = $1 AND recorded_at >= $2` {
+		t.Errorf("Unexpected Postgres query %s", bound)
+	}
+	sqlite := &SQLStorage{backend: StorageBackendSQLite}
+	if bound := sqlite.bind(query); bound != query {
+		t.Errorf("Expected SQLite placeholders unchanged, got %s", bound)
+	}
+}
+
+func TestAuditService_Storage(t *testing.T) {
+	store := NewMemoryStorage()
+	service := NewAuditService(&config.Config{})
+	service.SetStorage(store)
+
+	ref := service.LogVerification(context.Background(), models.VerificationRequest{RPID: "rp_1", ClaimType: "student_verification"}, nil, "CACHE_HIT")
+	found, err := service.GetAuditReference(ref.AuditEntryID)
+	if err != nil || found.AuditEntryID != ref.AuditEntryID {
+		t.Errorf("Expected the stored reference, got %+v, %v", found, err)
+	}
+	if _, err := service.GetAuditReference("audit_missing"); err == nil {
+		t.Error("Expected an unknown entry to be reported")
+	}
+
+	// Entries logged outside a verification are given an ID to be stored
+	service.LogExportEvent(context.Background(), "rp_1", "EXPORT_CREATED", nil)
+	if entries, _ := store.ListAuditEntries(context.Background(), AuditEntryFilter{}); len(entries) != 2 {
+		t.Errorf("Expected both entries stored, got %d", len(entries))
+	}
+}
+
+func TestPullJobService_Storage(t *testing.T) {
+	store := NewMemoryStorage()
+	service := NewPullJobService(&config.Config{}, nil)
+	service.SetStorage(store)
+
+	service.jobTracker.TrackJob(&JobStatus{JobID: "job_1", Status: JobPending, UpdatedAt: time.Now()})
+	service.jobTracker.UpdateJobStatus("job_1", JobRunning, "")
+
+	// A restarted broker still answers for jobs it tracked before
+	restarted := NewPullJobService(&config.Config{}, nil)
+	restarted.SetStorage(store)
+	job, err := restarted.GetJobStatus("job_1")
+	if err != nil || job.Status != JobRunning {
+		t.Errorf("Expected the stored running job, got %+v, %v", job, err)
+	}
+}
+
+func TestConsentService_Storage(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStorage()
+	service := NewConsentService()
+	if err := service.SetStorage(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+	revocation, err := service.Revoke(ctx, ConsentRevocation{RPID: "rp_1", UserID: "user_1", ClaimTypes: []string{"student_verification"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewConsentService()
+	if err := restarted.SetStorage(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+	if revokedAt := restarted.RevokedAt("rp_1", "user_1", "student_verification"); !revokedAt.Equal(revocation.RevokedAt) {
+		t.Errorf("Expected the revocation to survive a restart, got %s", revokedAt)
+	}
+	if revokedAt := restarted.RevokedAt("rp_1", "user_1", "age_verification"); !revokedAt.IsZero() {
+		t.Errorf("Expected other claim types unaffected, got %s", revokedAt)
+	}
+}
@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// defaultSupportBundleCapacity bounds the support bundles kept in memory
+const defaultSupportBundleCapacity = 1000
+
+// Statuses of a traced pipeline stage
+const (
+	TraceStageRunning = "running"
+	TraceStageOK      = "ok"
+	TraceStageFailed  = "failed"
+)
+
+// Outcomes of a traced DP attempt
+const (
+	DPAttemptSuccess            = "success"
+	DPAttemptFailed             = "failed"
+	DPAttemptBreakerOpen        = "breaker_open"
+	DPAttemptBulkheadFull       = "bulkhead_full"
+	DPAttemptConcurrencyLimited = "concurrency_limited"
+	DPAttemptInvalidResponse    = "invalid_response"
+	DPAttemptCancelled          = "cancelled"
+)
+
+// supportRedacted replaces identifying values in support bundles
+const supportRedacted = "[REDACTED]"
+
+// urlQueryPattern matches the query of URLs quoted in error messages, which
+// may carry tokens or identifiers
+var urlQueryPattern = regexp.MustCompile(`(https?://[^\s"?]+)\?[^\s"]*`)
+
+// TraceStage is one stage of the verification pipeline as it ran
+type TraceStage struct {
+	Stage      string    `json:"stage"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs float64   `json:"duration_ms"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// TraceDPAttempt is one call to a DP made for a verification. Breaker is
+// the DP's circuit breaker as the attempt started.
+type TraceDPAttempt struct {
+	DPID           string                 `json:"dp_id"`
+	StartedAt      time.Time              `json:"started_at"`
+	DurationMs     float64                `json:"duration_ms"`
+	Outcome        string                 `json:"outcome"`
+	ResponseStatus string                 `json:"response_status,omitempty"`
+	Error          string                 `json:"error,omitempty"`
+	Breaker        map[string]interface{} `json:"breaker,omitempty"`
+}
+
+// VerificationTrace records the stages and DP attempts of one verification
+// so a failure can be turned into a support bundle. A nil trace records
+// nothing.
+type VerificationTrace struct {
+	mu              sync.Mutex
+	requestID       string
+	rpID            string
+	claimType       string
+	subjectRef      string
+	identifierTypes []string
+	sensitive       []string
+	startedAt       time.Time
+	stages          []*TraceStage
+	attempts        []*TraceDPAttempt
+	now             func() time.Time
+}
+
+type verificationTraceKey struct{}
+
+// NewVerificationTrace starts the trace of a verification request. Its
+// identifier values and user ID are kept only to be redacted from errors.
+func NewVerificationTrace(requestID string, req models.VerificationRequest) *VerificationTrace {
+	trace := &VerificationTrace{
+		requestID: requestID,
+		rpID:      req.RPID,
+		claimType: req.ClaimType,
+		now:       time.Now,
+	}
+	trace.startedAt = trace.now()
+
+	// Subjects are identified by an RP-scoped pseudonym, as in exports
+	subject := sha256.Sum256([]byte(req.RPID + ":" + req.UserID))
+	trace.subjectRef = hex.EncodeToString(subject[:16])
+
+	for name, value := range req.Identifiers {
+		trace.identifierTypes = append(trace.identifierTypes, name)
+		trace.sensitive = append(trace.sensitive, value)
+	}
+	sort.Strings(trace.identifierTypes)
+	trace.sensitive = append(trace.sensitive, req.UserID)
+	return trace
+}
+
+// WithVerificationTrace returns a context carrying a verification's trace
+func WithVerificationTrace(ctx context.Context, trace *VerificationTrace) context.Context {
+	return context.WithValue(ctx, verificationTraceKey{}, trace)
+}
+
+// VerificationTraceFrom returns the trace carried by a context, or nil
+func VerificationTraceFrom(ctx context.Context) *VerificationTrace {
+	trace, _ := ctx.Value(verificationTraceKey{}).(*VerificationTrace)
+	return trace
+}
+
+// Begin starts a pipeline stage, ending the running one as successful
+func (t *VerificationTrace) Begin(stage string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	t.endLocked(now, TraceStageOK, "")
+	t.stages = append(t.stages, &TraceStage{Stage: stage, StartedAt: now, Status: TraceStageRunning})
+}
+
+// Finish ends the running stage: as failed with a message, or successful
+// when message is empty
+func (t *VerificationTrace) Finish(message string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := TraceStageOK
+	if message != "" {
+		status = TraceStageFailed
+	}
+	t.endLocked(t.now(), status, message)
+}
+
+func (t *VerificationTrace) endLocked(now time.Time, status, message string) {
+	if len(t.stages) == 0 {
+		return
+	}
+	stage := t.stages[len(t.stages)-1]
+	if stage.Status != TraceStageRunning {
+		return
+	}
+	stage.Status = status
+	stage.Error = message
+	stage.DurationMs = durationMs(now.Sub(stage.StartedAt))
+}
+
+// startAttempt records a call to a DP starting, with its breaker's state
+func (t *VerificationTrace) startAttempt(dpID string, breaker *CircuitBreaker) *traceAttempt {
+	if t == nil {
+		return nil
+	}
+	attempt := &TraceDPAttempt{DPID: dpID, StartedAt: t.now(), Breaker: breaker.GetCircuitBreakerStats()}
+	t.mu.Lock()
+	t.attempts = append(t.attempts, attempt)
+	t.mu.Unlock()
+	return &traceAttempt{trace: t, attempt: attempt}
+}
+
+// traceAttempt finishes a DP attempt recorded in a trace
+type traceAttempt struct {
+	trace   *VerificationTrace
+	attempt *TraceDPAttempt
+}
+
+// finish records how a DP attempt ended
+func (a *traceAttempt) finish(outcome string, response *DPResponse, err error) {
+	if a == nil {
+		return
+	}
+	a.trace.mu.Lock()
+	defer a.trace.mu.Unlock()
+	a.attempt.Outcome = outcome
+	a.attempt.DurationMs = durationMs(a.trace.now().Sub(a.attempt.StartedAt))
+	if response != nil {
+		a.attempt.ResponseStatus = string(response.Status)
+	}
+	if err != nil {
+		a.attempt.Error = err.Error()
+	}
+}
+
+// attemptOutcome classifies a DP call that returned an error: cancelled
+// because a hedge won, or failed
+func attemptOutcome(ctx context.Context) string {
+	if errors.Is(context.Cause(ctx), errHedgeCancelled) {
+		return DPAttemptCancelled
+	}
+	return DPAttemptFailed
+}
+
+// SupportBundleFailure is how a verification failed, as returned to the RP
+type SupportBundleFailure struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	StatusCode int    `json:"status_code"`
+	Stage      string `json:"stage,omitempty"`
+}
+
+// SupportStageError is a failed stage or DP attempt
+type SupportStageError struct {
+	Stage string `json:"stage"`
+	DPID  string `json:"dp_id,omitempty"`
+	Error string `json:"error"`
+}
+
+// SupportBundle gathers what support needs to escalate a failed
+// verification with a provider: its timeline, stage errors, DP responses,
+// breaker states and the configuration it ran under. Identifier values and
+// user IDs are redacted; the subject is an RP-scoped pseudonym.
+type SupportBundle struct {
+	RequestID       string                 `json:"request_id"`
+	GeneratedAt     time.Time              `json:"generated_at"`
+	StartedAt       time.Time              `json:"started_at"`
+	RPID            string                 `json:"rp_id"`
+	SubjectRef      string                 `json:"subject_ref"`
+	ClaimType       string                 `json:"claim_type"`
+	IdentifierTypes []string               `json:"identifier_types"`
+	Failure         SupportBundleFailure   `json:"failure"`
+	Timeline        []TraceStage           `json:"timeline"`
+	StageErrors     []SupportStageError    `json:"stage_errors"`
+	DPAttempts      []TraceDPAttempt       `json:"dp_attempts"`
+	Breakers        map[string]interface{} `json:"breakers"`
+	Versions        map[string]string      `json:"versions"`
+}
+
+// SupportBundleSummary lists a bundle without its details
+type SupportBundleSummary struct {
+	RequestID   string    `json:"request_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	RPID        string    `json:"rp_id"`
+	ClaimType   string    `json:"claim_type"`
+	Code        string    `json:"code"`
+	Stage       string    `json:"stage,omitempty"`
+}
+
+// Bundle builds the redacted support bundle of a failed verification. The
+// running stage is ended as failed, and the breakers of the claim type's DPs
+// are captured as they are now.
+func (t *VerificationTrace) Bundle(failure SupportBundleFailure, cfg *config.Config, dpService *DPConnectorService) *SupportBundle {
+	t.Finish(failure.Message)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	bundle := &SupportBundle{
+		RequestID:       t.requestID,
+		GeneratedAt:     t.now(),
+		StartedAt:       t.startedAt,
+		RPID:            t.rpID,
+		SubjectRef:      t.subjectRef,
+		ClaimType:       t.claimType,
+		IdentifierTypes: t.identifierTypes,
+		Failure:         failure,
+		Timeline:        make([]TraceStage, 0, len(t.stages)),
+		StageErrors:     make([]SupportStageError, 0),
+		DPAttempts:      make([]TraceDPAttempt, 0, len(t.attempts)),
+		Versions:        SupportBundleVersions(cfg, dpService, t.claimType),
+	}
+	bundle.Failure.Message = t.redact(failure.Message)
+
+	for _, stage := range t.stages {
+		entry := *stage
+		entry.Error = t.redact(entry.Error)
+		bundle.Timeline = append(bundle.Timeline, entry)
+		if entry.Status == TraceStageFailed {
+			bundle.Failure.Stage = entry.Stage
+			bundle.StageErrors = append(bundle.StageErrors, SupportStageError{Stage: entry.Stage, Error: entry.Error})
+		}
+	}
+
+	dpIDs := make(map[string]bool)
+	for _, attempt := range t.attempts {
+		entry := *attempt
+		entry.Error = t.redact(entry.Error)
+		bundle.DPAttempts = append(bundle.DPAttempts, entry)
+		dpIDs[entry.DPID] = true
+		if entry.Error != "" && entry.Outcome != DPAttemptCancelled {
+			bundle.StageErrors = append(bundle.StageErrors, SupportStageError{Stage: "dp_call", DPID: entry.DPID, Error: entry.Error})
+		}
+	}
+
+	bundle.Breakers = make(map[string]interface{})
+	if dpService != nil {
+		for _, provider := range dpService.Registry().ProvidersForClaim(t.claimType) {
+			dpIDs[provider.DPID] = true
+		}
+		for dpID := range dpIDs {
+			bundle.Breakers[dpID] = dpService.providerBreaker(dpID).GetCircuitBreakerStats()
+		}
+	}
+	return bundle
+}
+
+// redact removes identifier values, the user ID and URL queries from a
+// message
+func (t *VerificationTrace) redact(message string) string {
+	if message == "" {
+		return message
+	}
+	for _, value := range t.sensitive {
+		if value != "" {
+			message = strings.ReplaceAll(message, value, supportRedacted)
+		}
+	}
+	return urlQueryPattern.ReplaceAllString(message, "$1?"+supportRedacted)
+}
+
+// SupportBundleVersions identifies the configuration a verification ran
+// under: the crypto profile, fingerprints of the runtime settings and of
+// the claim type's DP registry entries, and the response schema in effect
+func SupportBundleVersions(cfg *config.Config, dpService *DPConnectorService, claimType string) map[string]string {
+	versions := make(map[string]string)
+	if cfg != nil {
+		versions["environment"] = cfg.Env
+		versions["crypto_profile"] = ActiveCryptoProfile(cfg).Name
+		versions["runtime_settings"] = fingerprintJSON(cfg.RuntimeSettings())
+	}
+	if dpService == nil {
+		return versions
+	}
+
+	// Credentials are left out so the fingerprint cannot be used to guess them
+	providers := dpService.Registry().ProvidersForClaim(claimType)
+	entries := make([]DPProvider, 0, len(providers))
+	for _, provider := range providers {
+		entry := *provider
+		entry.Auth = nil
+		entries = append(entries, entry)
+	}
+	versions["dp_registry"] = fingerprintJSON(entries)
+
+	if schema, exists := dpService.ValidationSchemas().ForClaim(claimType, SchemaKindResponse); exists {
+		versions["response_schema"] = schema.Ref()
+	}
+	return versions
+}
+
+// fingerprintJSON returns a short hash of a value's JSON encoding
+func fingerprintJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "unknown"
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// SupportBundleStore keeps the support bundles of recent failed
+// verifications by request ID, dropping the oldest beyond its capacity
+type SupportBundleStore struct {
+	mu       sync.RWMutex
+	bundles  map[string]*SupportBundle
+	order    []string
+	capacity int
+}
+
+// NewSupportBundleStore creates a support bundle store
+func NewSupportBundleStore() *SupportBundleStore {
+	return &SupportBundleStore{
+		bundles:  make(map[string]*SupportBundle),
+		capacity: defaultSupportBundleCapacity,
+	}
+}
+
+// Record keeps a bundle, replacing one for the same request
+func (s *SupportBundleStore) Record(bundle *SupportBundle) {
+	if bundle == nil || bundle.RequestID == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.bundles[bundle.RequestID]; !exists {
+		s.order = append(s.order, bundle.RequestID)
+	}
+	s.bundles[bundle.RequestID] = bundle
+	for len(s.order) > s.capacity {
+		delete(s.bundles, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// Get returns the bundle of a request
+func (s *SupportBundleStore) Get(requestID string) (*SupportBundle, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	bundle, exists := s.bundles[requestID]
+	return bundle, exists
+}
+
+// List summarizes the bundles kept, newest first
+func (s *SupportBundleStore) List() []SupportBundleSummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	summaries := make([]SupportBundleSummary, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		bundle := s.bundles[s.order[i]]
+		summaries = append(summaries, SupportBundleSummary{
+			RequestID:   bundle.RequestID,
+			GeneratedAt: bundle.GeneratedAt,
+			RPID:        bundle.RPID,
+			ClaimType:   bundle.ClaimType,
+			Code:        bundle.Failure.Code,
+			Stage:       bundle.Failure.Stage,
+		})
+	}
+	return summaries
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestVerificationTrace_BundleOfFailedVerification(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	answering := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(DPResponse{JobID: "job_1", Status: "completed", Timestamp: "2025-08-02T07:00:00Z"})
+	}))
+	defer answering.Close()
+
+	cfg := &config.Config{DPConnectorURL: "http://localhost:1", Env: "test"}
+	service := NewDPConnectorService(cfg)
+	service.retryConfig.MaxRetries = 0
+	service.registry = NewDPRegistry()
+	service.registry.Register(&DPProvider{DPID: "dp_failing", Endpoint: failing.URL, SupportedClaims: []string{"age_verification"}, Priority: 1})
+	service.registry.Register(&DPProvider{DPID: "dp_answering", Endpoint: answering.URL, SupportedClaims: []string{"age_verification"}, Priority: 2})
+
+	req := models.VerificationRequest{
+		RPID:        "rp_1",
+		UserID:      "user-4711",
+		ClaimType:   "age_verification",
+		Identifiers: map[string]string{"email": "jane@example.com"},
+	}
+	trace := NewVerificationTrace("req-1", req)
+	ctx := WithVerificationTrace(context.Background(), trace)
+
+	trace.Begin("authorization")
+	trace.Begin("dp_job")
+	if _, err := service.VerifyWithDP(ctx, &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"}); err != nil {
+		t.Fatalf("Expected the second DP to answer, got %v", err)
+	}
+	trace.Begin("response_parsing")
+
+	bundle := trace.Bundle(SupportBundleFailure{
+		Code:       "RESPONSE_PARSE_ERROR",
+		Message:    "cannot parse result for jane@example.com from " + answering.URL + "/verify?token=secret",
+		StatusCode: http.StatusInternalServerError,
+	}, cfg, service)
+
+	if bundle.RequestID != "req-1" || bundle.Failure.Stage != "response_parsing" {
+		t.Errorf("Expected the failure to be placed at response_parsing, got %+v", bundle.Failure)
+	}
+	if len(bundle.Timeline) != 3 || bundle.Timeline[0].Status != TraceStageOK || bundle.Timeline[2].Status != TraceStageFailed {
+		t.Errorf("Expected two passed stages and a failed one, got %+v", bundle.Timeline)
+	}
+	if len(bundle.DPAttempts) != 2 {
+		t.Fatalf("Expected both DP attempts recorded, got %+v", bundle.DPAttempts)
+	}
+	if first := bundle.DPAttempts[0]; first.DPID != "dp_failing" || first.Outcome != DPAttemptFailed || first.Breaker["state"] == nil {
+		t.Errorf("Expected a failed attempt with its breaker state, got %+v", first)
+	}
+	if second := bundle.DPAttempts[1]; second.Outcome != DPAttemptSuccess || second.ResponseStatus != "completed" {
+		t.Errorf("Expected a successful attempt, got %+v", second)
+	}
+	if len(bundle.StageErrors) != 2 || bundle.StageErrors[1].DPID != "dp_failing" {
+		t.Errorf("Expected the stage and DP errors, got %+v", bundle.StageErrors)
+	}
+	if _, exists := bundle.Breakers["dp_answering"]; !exists {
+		t.Errorf("Expected the breakers of the claim type's DPs, got %v", bundle.Breakers)
+	}
+	if bundle.Versions["environment"] != "test" || bundle.Versions["dp_registry"] == "" || bundle.Versions["runtime_settings"] == "" {
+		t.Errorf("Expected configuration versions, got %v", bundle.Versions)
+	}
+
+	encoded, _ := json.Marshal(bundle)
+	for _, secret := range []string{"jane@example.com", "user-4711", "token=secret"} {
+		if strings.Contains(string(encoded), secret) {
+			t.Errorf("Expected %q to be redacted from the bundle: %s", secret, encoded)
+		}
+	}
+	if !strings.Contains(bundle.Failure.Message, supportRedacted) {
+		t.Errorf("Expected a redacted failure message, got %q", bundle.Failure.Message)
+	}
+}
+
+func TestVerificationTrace_NilTraceRecordsNothing(t *testing.T) {
+	var trace *VerificationTrace
+	trace.Begin("cache")
+	trace.Finish("")
+	trace.startAttempt("dp_1", nil).finish(DPAttemptSuccess, nil, nil)
+	if VerificationTraceFrom(context.Background()) != nil {
+		t.Error("Expected no trace in a bare context")
+	}
+}
+
+func TestSupportBundleStore_KeepsRecentBundles(t *testing.T) {
+	store := NewSupportBundleStore()
+	store.capacity = 2
+	for _, requestID := range []string{"req-1", "req-2", "req-3"} {
+		store.Record(&SupportBundle{RequestID: requestID, Failure: SupportBundleFailure{Code: "JOB_FAILED"}})
+	}
+
+	if _, exists := store.Get("req-1"); exists {
+		t.Error("Expected the oldest bundle to be dropped")
+	}
+	summaries := store.List()
+	if len(summaries) != 2 || summaries[0].RequestID != "req-3" || summaries[0].Code != "JOB_FAILED" {
+		t.Errorf("Expected the newest bundles first, got %+v", summaries)
+	}
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+)
+
+// Tenant labels used when a tenant cannot be reported individually
+const (
+	TenantLabelUnknown = "unknown"
+	TenantLabelOther   = "other"
+)
+
+// TenantLabeler maps RP identifiers to bounded, label-safe metric values
+type TenantLabeler struct {
+	maxLength int
+}
+
+// NewTenantLabeler creates a labeler that hashes IDs longer than maxLength
+func NewTenantLabeler(maxLength int) *TenantLabeler {
+	if maxLength <= 0 {
+		maxLength = 32
+	}
+	return &TenantLabeler{maxLength: maxLength}
+}
+
+// Label returns the metric label for an RP. Long IDs, or IDs with characters
+// unsafe for labels and log lines, are replaced by a stable hash.
+func (l *TenantLabeler) Label(rpID string) string {
+	if rpID == "" {
+		return TenantLabelUnknown
+	}
+	if len(rpID) <= l.maxLength && isLabelSafe(rpID) && rpID != TenantLabelOther && rpID != TenantLabelUnknown {
+		return rpID
+	}
+
+	hash := sha256.Sum256([]byte(rpID))
+	return "h_" + hex.EncodeToString(hash[:8])
+}
+
+// isLabelSafe reports whether a value only contains [A-Za-z0-9_.-]
+func isLabelSafe(value string) bool {
+	for _, c := range value {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// TenantStats is the per-tenant view of request metrics
+type TenantStats struct {
+	Tenant        string    `json:"tenant"`
+	Requests      int64     `json:"requests"`
+	Errors        int64     `json:"errors"`
+	ErrorRate     float64   `json:"error_rate"`
+	AvgLatencySec float64   `json:"avg_latency_seconds"`
+	MaxLatencySec float64   `json:"max_latency_seconds"`
+	LastSeen      time.Time `json:"last_seen,omitempty"`
+}
+
+// tenantCounters accumulates request metrics for one tenant
+type tenantCounters struct {
+	requests     int64
+	errors       int64
+	totalLatency time.Duration
+	maxLatency   time.Duration
+	lastSeen     time.Time
+}
+
+func (c *tenantCounters) record(latency time.Duration, failed bool, now time.Time) {
+	c.requests++
+	if failed {
+		c.errors++
+	}
+	c.totalLatency += latency
+	if latency > c.maxLatency {
+		c.maxLatency = latency
+	}
+	c.lastSeen = now
+}
+
+func (c *tenantCounters) merge(other *tenantCounters) {
+	c.requests += other.requests
+	c.errors += other.errors
+	c.totalLatency += other.totalLatency
+	if other.maxLatency > c.maxLatency {
+		c.maxLatency = other.maxLatency
+	}
+	if other.lastSeen.After(c.lastSeen) {
+		c.lastSeen = other.lastSeen
+	}
+}
+
+func (c *tenantCounters) stats(tenant string) TenantStats {
+	stats := TenantStats{
+		Tenant:        tenant,
+		Requests:      c.requests,
+		Errors:        c.errors,
+		MaxLatencySec: c.maxLatency.Seconds(),
+		LastSeen:      c.lastSeen,
+	}
+	if c.requests > 0 {
+		stats.ErrorRate = float64(c.errors) / float64(c.requests) * 100.0
+		stats.AvgLatencySec = (c.totalLatency / time.Duration(c.requests)).Seconds()
+	}
+	return stats
+}
+
+// TenantLabel returns the bounded metric label for an RP
+func (s *MetricsService) TenantLabel(rpID string) string {
+	return s.tenantLabeler.Label(rpID)
+}
+
+// RecordTenantRequest records a request attributed to an RP
+func (s *MetricsService) RecordTenantRequest(rpID string, latency time.Duration, failed bool) {
+	label := s.tenantLabeler.Label(rpID)
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counters, exists := s.tenants[label]
+	if !exists {
+		// Stop tracking new tenants individually once the cap is reached
+		if len(s.tenants) >= s.maxTrackedTenants {
+			s.untrackedTenants.record(latency, failed, now)
+			return
+		}
+		counters = &tenantCounters{}
+		s.tenants[label] = counters
+	}
+	counters.record(latency, failed, now)
+}
+
+// GetTenantStats returns the metrics view for a single RP only
+func (s *MetricsService) GetTenantStats(rpID string) TenantStats {
+	label := s.tenantLabeler.Label(rpID)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if counters, exists := s.tenants[label]; exists {
+		return counters.stats(label)
+	}
+	return TenantStats{Tenant: label}
+}
+
+// GetTopTenants returns the n busiest tenants plus an aggregated "other" entry
+// covering the remainder, keeping label cardinality bounded
+func (s *MetricsService) GetTopTenants(n int) []TenantStats {
+	if n <= 0 {
+		n = s.tenantTopN
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	labels := make([]string, 0, len(s.tenants))
+	for label := range s.tenants {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		ri, rj := s.tenants[labels[i]].requests, s.tenants[labels[j]].requests
+		if ri != rj {
+			return ri > rj
+		}
+		return labels[i] < labels[j]
+	})
+
+	var result []TenantStats
+	other := s.untrackedTenants
+	for i, label := range labels {
+		if i < n {
+			result = append(result, s.tenants[label].stats(label))
+		} else {
+			other.merge(s.tenants[label])
+		}
+	}
+
+	if other.requests > 0 {
+		result = append(result, other.stats(TenantLabelOther))
+	}
+
+	return result
+}
+
+// tenantMetrics converts the top-N tenant view into labeled metrics grouped by
+// name (caller must not hold the metrics lock)
+func (s *MetricsService) tenantMetrics(now time.Time) []Metric {
+	tenants := s.GetTopTenants(0)
+
+	definitions := []struct {
+		name       string
+		metricType MetricType
+		help       string
+		value      func(TenantStats) float64
+	}{
+		{"core_broker_tenant_requests_total", MetricTypeCounter, "Total requests per tenant", func(t TenantStats) float64 { return float64(t.Requests) }},
+		{"core_broker_tenant_errors_total", MetricTypeCounter, "Total errors per tenant", func(t TenantStats) float64 { return float64(t.Errors) }},
+		{"core_broker_tenant_request_latency_avg", MetricTypeGauge, "Average request latency per tenant in seconds", func(t TenantStats) float64 { return t.AvgLatencySec }},
+	}
+
+	var metrics []Metric
+	for _, definition := range definitions {
+		for _, stats := range tenants {
+			metrics = append(metrics, Metric{
+				Name:   definition.name,
+				Type:   definition.metricType,
+				Value:  definition.value(stats),
+				Labels: map[string]string{"tenant": stats.Tenant},
+				Help:   definition.help,
+				Time:   now,
+			})
+		}
+	}
+	return metrics
+}
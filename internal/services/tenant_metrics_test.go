@@ -0,0 +1,92 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestTenantLabeler_Label(t *testing.T) {
+	labeler := NewTenantLabeler(16)
+
+	if label := labeler.Label("rp_1"); label != "rp_1" {
+		t.Errorf("Expected short IDs to be kept, got %s", label)
+	}
+	if label := labeler.Label(""); label != TenantLabelUnknown {
+		t.Errorf("Expected unknown label, got %s", label)
+	}
+
+	long := labeler.Label("rp_with_a_very_long_identifier")
+	if !strings.HasPrefix(long, "h_") || long != labeler.Label("rp_with_a_very_long_identifier") {
+		t.Errorf("Expected stable hashed label, got %s", long)
+	}
+	if label := labeler.Label(`rp"1`); !strings.HasPrefix(label, "h_") {
+		t.Errorf("Expected unsafe characters to be hashed, got %s", label)
+	}
+	if label := labeler.Label(TenantLabelOther); label == TenantLabelOther {
+		t.Error("Expected reserved label to be hashed")
+	}
+}
+
+func TestMetricsService_TenantTopN(t *testing.T) {
+	service := NewMetricsService(&config.Config{TenantMetricsTopN: 2})
+
+	for i := 0; i < 5; i++ {
+		service.RecordTenantRequest("rp_busy", 10*time.Millisecond, false)
+	}
+	for i := 0; i < 3; i++ {
+		service.RecordTenantRequest("rp_medium", 20*time.Millisecond, i == 0)
+	}
+	service.RecordTenantRequest("rp_quiet_1", time.Millisecond, false)
+	service.RecordTenantRequest("rp_quiet_2", time.Millisecond, true)
+
+	top := service.GetTopTenants(0)
+	if len(top) != 3 {
+		t.Fatalf("Expected two tenants plus other, got %d", len(top))
+	}
+	if top[0].Tenant != "rp_busy" || top[1].Tenant != "rp_medium" {
+		t.Errorf("Expected tenants ordered by volume, got %s, %s", top[0].Tenant, top[1].Tenant)
+	}
+	if top[2].Tenant != TenantLabelOther || top[2].Requests != 2 || top[2].Errors != 1 {
+		t.Errorf("Expected remaining tenants aggregated into other, got %+v", top[2])
+	}
+
+	own := service.GetTenantStats("rp_medium")
+	if own.Requests != 3 || own.Errors != 1 {
+		t.Errorf("Expected per-tenant view for rp_medium, got %+v", own)
+	}
+
+	prometheus := service.GetPrometheusMetrics()
+	if !strings.Contains(prometheus, `core_broker_tenant_requests_total{tenant="rp_busy"} 5`) {
+		t.Error("Expected labeled tenant series in Prometheus output")
+	}
+	if strings.Contains(prometheus, "rp_quiet_1") {
+		t.Error("Expected tenants outside the top-N to be folded into other")
+	}
+	if strings.Count(prometheus, "# TYPE core_broker_tenant_requests_total") != 1 {
+		t.Error("Expected a single TYPE header per metric name")
+	}
+}
+
+func TestMetricsService_TenantTrackingCap(t *testing.T) {
+	service := NewMetricsService(&config.Config{TenantMetricsMaxTracked: 1})
+
+	service.RecordTenantRequest("rp_1", time.Millisecond, false)
+	service.RecordTenantRequest("rp_2", time.Millisecond, false)
+
+	if stats := service.GetTenantStats("rp_2"); stats.Requests != 0 {
+		t.Error("Expected tenants beyond the tracking cap not to be tracked individually")
+	}
+
+	top := service.GetTopTenants(10)
+	if len(top) != 2 || top[1].Tenant != TenantLabelOther || top[1].Requests != 1 {
+		t.Errorf("Expected untracked tenant counted under other, got %+v", top)
+	}
+
+	service.ResetMetrics()
+	if len(service.GetTopTenants(10)) != 0 {
+		t.Error("Expected tenant metrics to be reset")
+	}
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// Test vector file format. The version is bumped whenever a vector's inputs
+// or encoding change; a changed algorithm gets a new algorithm version and
+// keeps the old vectors.
+const (
+	TestVectorsFormat  = "pavilion-test-vectors"
+	TestVectorsVersion = 1
+)
+
+// Algorithm versions of the vectors that are not identifier hash schemes
+const (
+	PrivacyHashAlgorithm    = "sha-256-v1"
+	ResponseHashAlgorithm   = "length-v0"
+	CommitmentPedersenBN254 = "pedersen-bn254-v1"
+	CommitmentMiMCBN254     = "mimc-bn254-v1"
+	SDJWTDigestAlgorithm    = "sha-256"
+)
+
+// testVectorHashKey is the HASH_KEY of the keyed identifier hash vectors. It
+// is published with the vectors and must never be used as a real key.
+const testVectorHashKey = "pavilion-test-vector-key-0001"
+
+// TestVectorSet holds canonical inputs and the outputs the broker produces
+// for them, so external verifiers can check their implementations
+type TestVectorSet struct {
+	Format           string                  `json:"format"`
+	Version          int                     `json:"version"`
+	IdentifierHashes []IdentifierHashVector  `json:"identifier_hashes"`
+	PrivacyHashes    []PrivacyHashVector     `json:"privacy_hashes"`
+	ResponseHashes   []ResponseHashVector    `json:"response_hashes"`
+	Commitments      []CommitmentVector      `json:"commitments"`
+	SDJWTDisclosures []SDJWTDisclosureVector `json:"sd_jwt_disclosures"`
+}
+
+// IdentifierHashVector is an identifier hashed for an RP. Keyed schemes use
+// Key as HASH_KEY at KeyVersion; sha256 uses Salt.
+type IdentifierHashVector struct {
+	Scheme         string `json:"scheme"`
+	KeyVersion     string `json:"key_version,omitempty"`
+	Key            string `json:"key,omitempty"`
+	Salt           string `json:"salt,omitempty"`
+	Argon2Time     int    `json:"argon2_time,omitempty"`
+	Argon2MemoryKB int    `json:"argon2_memory_kb,omitempty"`
+	RPID           string `json:"rp_id"`
+	Field          string `json:"field"`
+	Value          string `json:"value"`
+	Expected       string `json:"expected"`
+}
+
+// PrivacyHashVector is the privacy hash recorded in audit entries
+type PrivacyHashVector struct {
+	Algorithm       string `json:"algorithm"`
+	RPID            string `json:"rp_id"`
+	UserID          string `json:"user_id"`
+	ClaimType       string `json:"claim_type"`
+	IdentifierCount int    `json:"identifier_count"`
+	Expected        string `json:"expected"`
+}
+
+// ResponseHashVector is the integrity hash of a parsed DP response
+type ResponseHashVector struct {
+	Algorithm  string  `json:"algorithm"`
+	JobID      string  `json:"job_id"`
+	Status     string  `json:"status"`
+	Verified   bool    `json:"verified"`
+	Confidence float64 `json:"confidence"`
+	DPID       string  `json:"dp_id"`
+	Timestamp  string  `json:"timestamp"`
+	Expected   string  `json:"expected"`
+}
+
+// CommitmentVector is a ZKP commitment. Pedersen commitments are
+// value*G + blinding*H as a compressed BN254 G1 point (hex), with H derived
+// by hash-to-curve; MiMC commitments are the decimal MiMC hash of the
+// value's SHA-256 reduced into the BN254 scalar field.
+type CommitmentVector struct {
+	Scheme   string `json:"scheme"`
+	Value    string `json:"value"`
+	Blinding string `json:"blinding,omitempty"`
+	Expected string `json:"expected"`
+}
+
+// SDJWTDisclosureVector is an SD-JWT disclosure and the digest listed in _sd
+type SDJWTDisclosureVector struct {
+	Algorithm  string      `json:"algorithm"`
+	Salt       string      `json:"salt"`
+	Name       string      `json:"name"`
+	Value      interface{} `json:"value"`
+	Disclosure string      `json:"disclosure"`
+	Digest     string      `json:"digest"`
+}
+
+// GenerateTestVectors computes the test vectors from fixed inputs. The
+// output is deterministic, so the published file can be regenerated and
+// compared.
+func GenerateTestVectors() (*TestVectorSet, error) {
+	set := &TestVectorSet{Format: TestVectorsFormat, Version: TestVectorsVersion}
+
+	if err := set.addIdentifierHashes(); err != nil {
+		return nil, err
+	}
+	set.addPrivacyHashes()
+	set.addResponseHashes()
+	if err := set.addCommitments(); err != nil {
+		return nil, err
+	}
+	if err := set.addSDJWTDisclosures(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+func (set *TestVectorSet) addIdentifierHashes() error {
+	inputs := []struct{ rpID, field, value string }{
+		{"rp_test_vectors", "email", "alice@example.com"},
+		{"rp_test_vectors", "student_id", "S-2024-0042"},
+		{"rp_other", "email", "alice@example.com"},
+	}
+	for _, scheme := range []string{HashSchemeSHA256, HashSchemeHMACSHA256, HashSchemeHMACSHA3, HashSchemeArgon2id} {
+		cfg := &config.Config{HashScheme: scheme, HashKeyVersion: "1"}
+		if scheme != HashSchemeSHA256 {
+			cfg.HashKey = testVectorHashKey
+		}
+		hasher, err := NewHasher(cfg)
+		if err != nil {
+			return fmt.Errorf("%s hasher: %w", scheme, err)
+		}
+		for _, input := range inputs {
+			expected, err := hasher.Hash(input.rpID, input.field, input.value)
+			if err != nil {
+				return fmt.Errorf("%s hash: %w", scheme, err)
+			}
+			vector := IdentifierHashVector{
+				Scheme:   scheme,
+				RPID:     input.rpID,
+				Field:    input.field,
+				Value:    input.value,
+				Expected: expected,
+			}
+			if scheme == HashSchemeSHA256 {
+				vector.Salt = hasher.legacySalt
+			} else {
+				vector.KeyVersion = hasher.version
+				vector.Key = testVectorHashKey
+			}
+			if scheme == HashSchemeArgon2id {
+				vector.Argon2Time = int(hasher.argon2Time)
+				vector.Argon2MemoryKB = int(hasher.argon2MemoryKB)
+			}
+			set.IdentifierHashes = append(set.IdentifierHashes, vector)
+		}
+	}
+	return nil
+}
+
+func (set *TestVectorSet) addPrivacyHashes() {
+	audit := &AuditService{}
+	for _, req := range []models.VerificationRequest{
+		{RPID: "rp_test_vectors", UserID: "user_001", ClaimType: "age_verification", Identifiers: map[string]string{"email": "alice@example.com"}},
+		{RPID: "rp_test_vectors", UserID: "user_002", ClaimType: "student_verification", Identifiers: map[string]string{"email": "bob@example.com", "student_id": "S-2024-0042"}},
+	} {
+		set.PrivacyHashes = append(set.PrivacyHashes, PrivacyHashVector{
+			Algorithm:       PrivacyHashAlgorithm,
+			RPID:            req.RPID,
+			UserID:          req.UserID,
+			ClaimType:       req.ClaimType,
+			IdentifierCount: len(req.Identifiers),
+			Expected:        audit.generatePrivacyHash(req),
+		})
+	}
+}
+
+func (set *TestVectorSet) addResponseHashes() {
+	parser := &ResponseParserService{}
+	for _, parsed := range []*ParsedResponse{
+		{JobID: "job_001", Status: "verified", Verified: true, Confidence: 0.95, DPID: "dp_university_001", Timestamp: "2024-01-15T10:30:00Z"},
+		{JobID: "job_002", Status: "not_verified", Verified: false, Confidence: 0.1, DPID: "dp_employer_001", Timestamp: "2024-01-15T10:31:00Z"},
+	} {
+		set.ResponseHashes = append(set.ResponseHashes, ResponseHashVector{
+			Algorithm:  ResponseHashAlgorithm,
+			JobID:      parsed.JobID,
+			Status:     parsed.Status,
+			Verified:   parsed.Verified,
+			Confidence: parsed.Confidence,
+			DPID:       parsed.DPID,
+			Timestamp:  parsed.Timestamp,
+			Expected:   parser.generateIntegrityHash(parsed),
+		})
+	}
+}
+
+func (set *TestVectorSet) addCommitments() error {
+	gens, err := getBulletproofGenerators()
+	if err != nil {
+		return err
+	}
+	for _, input := range []struct {
+		value    uint64
+		blinding uint64
+	}{{42, 7}, {65000, 123456789}} {
+		var blinding fr.Element
+		blinding.SetUint64(input.blinding)
+		blindingBytes := blinding.Bytes()
+		commitment := pedersenCommit(gens, frFromUint64(input.value), blinding)
+		compressed := commitment.Bytes()
+		set.Commitments = append(set.Commitments, CommitmentVector{
+			Scheme:   CommitmentPedersenBN254,
+			Value:    fmt.Sprintf("%d", input.value),
+			Blinding: hex.EncodeToString(blindingBytes[:]),
+			Expected: hex.EncodeToString(compressed[:]),
+		})
+	}
+
+	for _, value := range []string{"alice@example.com", "S-2024-0042"} {
+		set.Commitments = append(set.Commitments, CommitmentVector{
+			Scheme:   CommitmentMiMCBN254,
+			Value:    value,
+			Expected: mimcCommitment(stringToField(value)).String(),
+		})
+	}
+	return nil
+}
+
+func (set *TestVectorSet) addSDJWTDisclosures() error {
+	for _, input := range []struct {
+		salt  string
+		name  string
+		value interface{}
+	}{
+		{"_26bc4LT-ac6q2KI6cBW5es", "age_over_18", true},
+		{"eluV5Og3gSNII8EYnsxA_A", "given_name", "Alice"},
+		{"6Ij7tM-a5iVPGboS5tmvVA", "address", map[string]interface{}{"country": "US", "postal_code": "94105"}},
+	} {
+		salt, err := base64.RawURLEncoding.DecodeString(input.salt)
+		if err != nil {
+			return fmt.Errorf("disclosure salt: %w", err)
+		}
+		disclosure, err := encodeSDJWTDisclosure(salt, input.name, input.value)
+		if err != nil {
+			return err
+		}
+		set.SDJWTDisclosures = append(set.SDJWTDisclosures, SDJWTDisclosureVector{
+			Algorithm:  SDJWTDigestAlgorithm,
+			Salt:       input.salt,
+			Name:       input.name,
+			Value:      input.value,
+			Disclosure: disclosure,
+			Digest:     sdJWTDigest(disclosure),
+		})
+	}
+	return nil
+}
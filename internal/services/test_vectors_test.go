@@ -0,0 +1,68 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestGenerateTestVectors_MatchesPublishedFile(t *testing.T) {
+	vectors, err := GenerateTestVectors()
+	if err != nil {
+		t.Fatalf("GenerateTestVectors failed: %v", err)
+	}
+	generated, _ := json.MarshalIndent(vectors, "", "  ")
+	generated = append(generated, '\n')
+
+	published, err := os.ReadFile("../../testdata/test-vectors.json")
+	if err != nil {
+		t.Fatalf("Failed to read the published vectors: %v", err)
+	}
+	if !bytes.Equal(generated, published) {
+		t.Fatal("testdata/test-vectors.json is out of date; regenerate it with go run ./cmd/test-vectors -out testdata/test-vectors.json")
+	}
+}
+
+func TestGenerateTestVectors_Verify(t *testing.T) {
+	vectors, err := GenerateTestVectors()
+	if err != nil {
+		t.Fatalf("GenerateTestVectors failed: %v", err)
+	}
+
+	for _, vector := range vectors.IdentifierHashes {
+		if vector.Scheme == HashSchemeSHA256 {
+			sum := sha256.Sum256([]byte(vector.Value + vector.Salt))
+			if hex.EncodeToString(sum[:]) != vector.Expected {
+				t.Errorf("sha256 vector for %s does not match", vector.Value)
+			}
+			continue
+		}
+		hasher, _ := NewHasher(&config.Config{HashScheme: vector.Scheme, HashKey: vector.Key, HashKeyVersion: vector.KeyVersion})
+		if ok, err := hasher.Matches(vector.RPID, vector.Field, vector.Value, vector.Expected); !ok || err != nil {
+			t.Errorf("%s vector for %s/%s does not match: %v", vector.Scheme, vector.RPID, vector.Field, err)
+		}
+	}
+
+	for _, vector := range vectors.SDJWTDisclosures {
+		raw, _ := base64.RawURLEncoding.DecodeString(vector.Disclosure)
+		var parts []interface{}
+		json.Unmarshal(raw, &parts)
+		if len(parts) != 3 || parts[0] != vector.Salt || parts[1] != vector.Name {
+			t.Errorf("Disclosure %s does not carry its salt and name: %s", vector.Name, raw)
+		}
+		sum := sha256.Sum256([]byte(vector.Disclosure))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != vector.Digest {
+			t.Errorf("Digest of disclosure %s does not match", vector.Name)
+		}
+	}
+
+	if len(vectors.PrivacyHashes) == 0 || len(vectors.ResponseHashes) == 0 || len(vectors.Commitments) != 4 {
+		t.Errorf("Expected vectors of every kind, got %+v", vectors)
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// OCSP checking modes for outbound TLS
+const (
+	OCSPModeOff     = "off"
+	OCSPModeStapled = "stapled" // verify a stapled response when the server sends one
+	OCSPModeRequire = "require" // fail the handshake without a valid stapled response
+)
+
+// TLS event types raised for DP connections
+const (
+	TLSEventPinFailure  = "pin_failure"
+	TLSEventOCSPFailure = "ocsp_failure"
+)
+
+// PinError reports that a peer presented no certificate matching its pins.
+// It is kept distinct from generic TLS errors so rotations can be coordinated.
+type PinError struct {
+	Host         string
+	Presented    []string
+	ExpectedPins int
+}
+
+func (e *PinError) Error() string {
+	return fmt.Sprintf("certificate pin mismatch for %s: presented SPKI %s, %d pins configured", e.Host, strings.Join(e.Presented, ","), e.ExpectedPins)
+}
+
+// OCSPError reports a missing, invalid or revoked OCSP staple
+type OCSPError struct {
+	Host   string
+	Reason string
+}
+
+func (e *OCSPError) Error() string {
+	return fmt.Sprintf("OCSP check failed for %s: %s", e.Host, e.Reason)
+}
+
+// TLSEvent records a pinning or OCSP failure for a DP
+type TLSEvent struct {
+	Type      string    `json:"type"`
+	DPID      string    `json:"dp_id"`
+	Error     string    `json:"error"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// classifyTLSEvent returns the event type for pin and OCSP failures, or ""
+// for any other error
+func classifyTLSEvent(err error) string {
+	var pinErr *PinError
+	if errors.As(err, &pinErr) {
+		return TLSEventPinFailure
+	}
+	var ocspErr *OCSPError
+	if errors.As(err, &ocspErr) {
+		return TLSEventOCSPFailure
+	}
+	return ""
+}
+
+// SPKIFingerprint returns the base64 SHA-256 hash of a certificate's
+// SubjectPublicKeyInfo, the format used for SPKI pins
+func SPKIFingerprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// CertificateFingerprint returns the hex SHA-256 hash of a certificate
+func CertificateFingerprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(hash[:])
+}
+
+// validatePins checks that pins are well-formed SHA-256 hashes
+func (t *TLSSettings) validatePins() error {
+	for _, pin := range t.PinnedSPKISHA256 {
+		if decoded, err := base64.StdEncoding.DecodeString(pin); err != nil || len(decoded) != sha256.Size {
+			return fmt.Errorf("invalid SPKI pin %q: expected base64 SHA-256", pin)
+		}
+	}
+	for _, pin := range t.PinnedCertSHA256 {
+		if decoded, err := hex.DecodeString(normalizeCertPin(pin)); err != nil || len(decoded) != sha256.Size {
+			return fmt.Errorf("invalid certificate pin %q: expected hex SHA-256", pin)
+		}
+	}
+
+	switch t.OCSP {
+	case "", OCSPModeOff, OCSPModeStapled, OCSPModeRequire:
+	default:
+		return fmt.Errorf("unknown OCSP mode: %s", t.OCSP)
+	}
+
+	return nil
+}
+
+// normalizeCertPin accepts fingerprints with or without colons, in any case
+func normalizeCertPin(pin string) string {
+	return strings.ToLower(strings.ReplaceAll(pin, ":", ""))
+}
+
+// hasPins reports whether certificate or SPKI pins are configured
+func (t *TLSSettings) hasPins() bool {
+	return len(t.PinnedSPKISHA256) > 0 || len(t.PinnedCertSHA256) > 0
+}
+
+// ocspEnabled reports whether stapled OCSP responses are checked
+func (t *TLSSettings) ocspEnabled() bool {
+	return t.OCSP == OCSPModeStapled || t.OCSP == OCSPModeRequire
+}
+
+// verifyConnection enforces pins and OCSP after standard chain verification
+func (t *TLSSettings) verifyConnection(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("peer presented no certificates")
+	}
+
+	if t.hasPins() {
+		if err := t.verifyPins(state); err != nil {
+			return err
+		}
+	}
+
+	if t.ocspEnabled() {
+		if err := t.verifyOCSP(state); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyPins accepts the connection when any certificate in the presented or
+// verified chain matches a pin, so intermediate and backup pins both work
+func (t *TLSSettings) verifyPins(state tls.ConnectionState) error {
+	certificates := state.PeerCertificates
+	for _, chain := range state.VerifiedChains {
+		certificates = append(certificates, chain...)
+	}
+
+	var presented []string
+	for _, cert := range certificates {
+		spki := SPKIFingerprint(cert)
+		for _, pin := range t.PinnedSPKISHA256 {
+			if pin == spki {
+				return nil
+			}
+		}
+		fingerprint := CertificateFingerprint(cert)
+		for _, pin := range t.PinnedCertSHA256 {
+			if normalizeCertPin(pin) == fingerprint {
+				return nil
+			}
+		}
+		presented = append(presented, spki)
+	}
+
+	return &PinError{
+		Host:         state.ServerName,
+		Presented:    presented,
+		ExpectedPins: len(t.PinnedSPKISHA256) + len(t.PinnedCertSHA256),
+	}
+}
+
+// verifyOCSP validates the stapled OCSP response for the leaf certificate
+func (t *TLSSettings) verifyOCSP(state tls.ConnectionState) error {
+	if len(state.OCSPResponse) == 0 {
+		if t.OCSP == OCSPModeRequire {
+			return &OCSPError{Host: state.ServerName, Reason: "no stapled response"}
+		}
+		return nil
+	}
+
+	leaf := state.PeerCertificates[0]
+	issuer := ocspIssuer(state)
+	if issuer == nil {
+		return &OCSPError{Host: state.ServerName, Reason: "issuer certificate unavailable"}
+	}
+
+	response, err := ocsp.ParseResponseForCert(state.OCSPResponse, leaf, issuer)
+	if err != nil {
+		return &OCSPError{Host: state.ServerName, Reason: err.Error()}
+	}
+
+	switch response.Status {
+	case ocsp.Good:
+	case ocsp.Revoked:
+		return &OCSPError{Host: state.ServerName, Reason: "certificate revoked"}
+	default:
+		return &OCSPError{Host: state.ServerName, Reason: "certificate status unknown"}
+	}
+
+	if !response.NextUpdate.IsZero() && time.Now().After(response.NextUpdate) {
+		return &OCSPError{Host: state.ServerName, Reason: "stapled response expired"}
+	}
+
+	return nil
+}
+
+// ocspIssuer returns the issuer of the leaf from the verified or presented chain
+func ocspIssuer(state tls.ConnectionState) *x509.Certificate {
+	leaf := state.PeerCertificates[0]
+	for _, chain := range state.VerifiedChains {
+		if len(chain) > 1 {
+			return chain[1]
+		}
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		if bytes.Equal(cert.RawSubject, leaf.RawIssuer) {
+			return cert
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// pinnedClient builds a client trusting the test server with the given settings
+func pinnedClient(t *testing.T, server *httptest.Server, settings *TLSSettings) *http.Client {
+	t.Helper()
+	tlsConfig, err := settings.TLSConfig(nil)
+	if err != nil {
+		t.Fatalf("Invalid TLS settings: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	tlsConfig.RootCAs = roots
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+}
+
+func TestTLSSettings_Pinning(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cert := server.Certificate()
+	wrongPin := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	t.Run("matching SPKI pin", func(t *testing.T) {
+		client := pinnedClient(t, server, &TLSSettings{PinnedSPKISHA256: []string{wrongPin, SPKIFingerprint(cert)}})
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Expected pinned connection to succeed, got %v", err)
+		}
+		resp.Body.Close()
+	})
+
+	t.Run("matching certificate pin", func(t *testing.T) {
+		client := pinnedClient(t, server, &TLSSettings{PinnedCertSHA256: []string{CertificateFingerprint(cert)}})
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Expected pinned connection to succeed, got %v", err)
+		}
+		resp.Body.Close()
+	})
+
+	t.Run("pin mismatch", func(t *testing.T) {
+		client := pinnedClient(t, server, &TLSSettings{PinnedSPKISHA256: []string{wrongPin}})
+		_, err := client.Get(server.URL)
+
+		var pinErr *PinError
+		if !errors.As(err, &pinErr) {
+			t.Fatalf("Expected PinError, got %v", err)
+		}
+		if classifyTLSEvent(err) != TLSEventPinFailure {
+			t.Error("Expected pin failure to be classified distinctly")
+		}
+	})
+
+	t.Run("OCSP staple required", func(t *testing.T) {
+		client := pinnedClient(t, server, &TLSSettings{OCSP: OCSPModeRequire})
+		_, err := client.Get(server.URL)
+
+		var ocspErr *OCSPError
+		if !errors.As(err, &ocspErr) {
+			t.Fatalf("Expected OCSPError, got %v", err)
+		}
+	})
+
+	t.Run("OCSP staple optional", func(t *testing.T) {
+		client := pinnedClient(t, server, &TLSSettings{OCSP: OCSPModeStapled})
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Expected connection without staple to succeed, got %v", err)
+		}
+		resp.Body.Close()
+	})
+}
+
+func TestTLSSettings_ValidatePins(t *testing.T) {
+	invalid := []*TLSSettings{
+		{PinnedSPKISHA256: []string{"not-base64"}},
+		{PinnedSPKISHA256: []string{base64.StdEncoding.EncodeToString([]byte("short"))}},
+		{PinnedCertSHA256: []string{"abcd"}},
+		{OCSP: "always"},
+	}
+	for _, settings := range invalid {
+		if err := settings.Validate(nil); err == nil {
+			t.Errorf("Expected %+v to be rejected", settings)
+		}
+	}
+
+	colonPin := "AB:CD:" + CertificateFingerprint(&x509.Certificate{Raw: []byte("cert")})[4:]
+	if err := (&TLSSettings{PinnedCertSHA256: []string{colonPin}}).Validate(nil); err != nil {
+		t.Errorf("Expected colon-separated fingerprint to be accepted, got %v", err)
+	}
+}
+
+func TestDPConnectorService_TLSEvents(t *testing.T) {
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: "http://dp-connector:8080"})
+
+	var received []TLSEvent
+	service.SetTLSEventHandler(func(event TLSEvent) {
+		received = append(received, event)
+	})
+
+	service.recordTLSEvent("dp-a", fmt.Errorf("request failed: %w", &PinError{Host: "dp-a.example.com"}))
+	service.recordTLSEvent("dp-a", fmt.Errorf("request failed: %w", &OCSPError{Host: "dp-a.example.com", Reason: "revoked"}))
+	service.recordTLSEvent("dp-a", errors.New("connection refused"))
+
+	events, counts := service.GetTLSEvents()
+	if len(events) != 2 || len(received) != 2 {
+		t.Fatalf("Expected 2 TLS events, got %d (handler %d)", len(events), len(received))
+	}
+	if counts[TLSEventPinFailure] != 1 || counts[TLSEventOCSPFailure] != 1 {
+		t.Errorf("Unexpected TLS event counts: %v", counts)
+	}
+	if events[0].Type != TLSEventPinFailure || events[0].DPID != "dp-a" {
+		t.Errorf("Unexpected event: %+v", events[0])
+	}
+
+	if _, ok := service.GetDPStats()["tls_events"]; !ok {
+		t.Error("Expected TLS events in DP stats")
+	}
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+// TLSSettings configures TLS for a peer. Empty fields use the crypto profile
+// defaults: TLS 1.2 minimum, the latest supported maximum, and Go's cipher
+// suite and curve preferences. Pins and OCSP apply to outbound connections.
+type TLSSettings struct {
+	MinVersion       string   `json:"min_version,omitempty"`
+	MaxVersion       string   `json:"max_version,omitempty"`
+	CipherSuites     []string `json:"cipher_suites,omitempty"`
+	CurvePreferences []string `json:"curve_preferences,omitempty"`
+	PinnedSPKISHA256 []string `json:"pinned_spki_sha256,omitempty"`
+	PinnedCertSHA256 []string `json:"pinned_cert_sha256,omitempty"`
+	OCSP             string   `json:"ocsp,omitempty"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P-256":  tls.CurveP256,
+	"P-384":  tls.CurveP384,
+	"P-521":  tls.CurveP521,
+}
+
+// fipsCipherSuites are the TLS 1.2 suites built from FIPS-approved primitives
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// fipsCurves are the FIPS-approved key exchange curves
+var fipsCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+// ServerTLSSettings returns the TLS settings for the inbound server
+func ServerTLSSettings(cfg *config.Config) *TLSSettings {
+	return &TLSSettings{
+		MinVersion:       cfg.TLSMinVersion,
+		MaxVersion:       cfg.TLSMaxVersion,
+		CipherSuites:     cfg.TLSCipherSuites,
+		CurvePreferences: cfg.TLSCurvePreferences,
+	}
+}
+
+// Validate rejects unknown or insecure settings, and settings the crypto
+// profile does not allow
+func (t *TLSSettings) Validate(profile *CryptoProfile) error {
+	_, err := t.TLSConfig(profile)
+	return err
+}
+
+// TLSConfig builds a tls.Config from the settings
+func (t *TLSSettings) TLSConfig(profile *CryptoProfile) (*tls.Config, error) {
+	if t == nil {
+		t = &TLSSettings{}
+	}
+
+	minVersion, err := parseTLSVersion(t.MinVersion, tls.VersionTLS12)
+	if err != nil {
+		return nil, err
+	}
+	maxVersion, err := parseTLSVersion(t.MaxVersion, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if minVersion < tls.VersionTLS12 {
+		return nil, fmt.Errorf("TLS minimum version %s is insecure; 1.2 or later is required", t.MinVersion)
+	}
+	if maxVersion != 0 && maxVersion < minVersion {
+		return nil, fmt.Errorf("TLS maximum version %s is below minimum version %s", t.MaxVersion, t.MinVersion)
+	}
+
+	if err := t.validatePins(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: minVersion,
+		MaxVersion: maxVersion,
+	}
+	if t.hasPins() || t.ocspEnabled() {
+		tlsConfig.VerifyConnection = t.verifyConnection
+	}
+
+	if len(t.CipherSuites) > 0 {
+		// Go does not allow TLS 1.3 suites to be configured
+		if minVersion >= tls.VersionTLS13 {
+			return nil, fmt.Errorf("TLS cipher suites cannot be configured when the minimum version is 1.3")
+		}
+		for _, name := range t.CipherSuites {
+			id, err := parseCipherSuite(name)
+			if err != nil {
+				return nil, err
+			}
+			if profile != nil && profile.FIPS && !containsUint16(fipsCipherSuites, id) {
+				return nil, fmt.Errorf("TLS cipher suite %s is not allowed by crypto profile %s", name, profile.Name)
+			}
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+		}
+	} else if profile != nil && profile.FIPS {
+		tlsConfig.CipherSuites = append([]uint16(nil), fipsCipherSuites...)
+	}
+
+	if len(t.CurvePreferences) > 0 {
+		for _, name := range t.CurvePreferences {
+			curve, exists := tlsCurves[name]
+			if !exists {
+				return nil, fmt.Errorf("unknown TLS curve: %s", name)
+			}
+			if profile != nil && profile.FIPS && !containsCurve(fipsCurves, curve) {
+				return nil, fmt.Errorf("TLS curve %s is not allowed by crypto profile %s", name, profile.Name)
+			}
+			tlsConfig.CurvePreferences = append(tlsConfig.CurvePreferences, curve)
+		}
+	} else if profile != nil && profile.FIPS {
+		tlsConfig.CurvePreferences = append([]tls.CurveID(nil), fipsCurves...)
+	}
+
+	return tlsConfig, nil
+}
+
+// parseTLSVersion accepts "1.2", "TLS1.2" or "TLS 1.2"
+func parseTLSVersion(value string, defaultVersion uint16) (uint16, error) {
+	if value == "" {
+		return defaultVersion, nil
+	}
+	normalized := strings.TrimSpace(strings.TrimPrefix(strings.ToUpper(value), "TLS"))
+	version, exists := tlsVersions[normalized]
+	if !exists {
+		return 0, fmt.Errorf("unknown TLS version: %s", value)
+	}
+	return version, nil
+}
+
+// parseCipherSuite resolves a cipher suite by its IANA name, rejecting
+// suites Go considers insecure
+func parseCipherSuite(name string) (uint16, error) {
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.Name == name {
+			return 0, fmt.Errorf("TLS cipher suite %s is insecure", name)
+		}
+	}
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name != name {
+			continue
+		}
+		if !supportsTLS12(suite) {
+			return 0, fmt.Errorf("TLS cipher suite %s is TLS 1.3 only and cannot be configured", name)
+		}
+		return suite.ID, nil
+	}
+	return 0, fmt.Errorf("unknown TLS cipher suite: %s", name)
+}
+
+func supportsTLS12(suite *tls.CipherSuite) bool {
+	for _, version := range suite.SupportedVersions {
+		if version == tls.VersionTLS12 {
+			return true
+		}
+	}
+	return false
+}
+
+func containsUint16(values []uint16, value uint16) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsCurve(curves []tls.CurveID, curve tls.CurveID) bool {
+	for _, c := range curves {
+		if c == curve {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+)
+
+func TestTLSSettings_TLSConfig(t *testing.T) {
+	settings := &TLSSettings{
+		MinVersion:       "1.2",
+		MaxVersion:       "TLS1.3",
+		CipherSuites:     []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+		CurvePreferences: []string{"X25519", "P-256"},
+	}
+
+	tlsConfig, err := settings.TLSConfig(nil)
+	if err != nil {
+		t.Fatalf("Expected valid settings, got %v", err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS12 || tlsConfig.MaxVersion != tls.VersionTLS13 {
+		t.Errorf("Unexpected versions: min %x max %x", tlsConfig.MinVersion, tlsConfig.MaxVersion)
+	}
+	if len(tlsConfig.CipherSuites) != 1 || tlsConfig.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384 {
+		t.Errorf("Unexpected cipher suites: %v", tlsConfig.CipherSuites)
+	}
+	if len(tlsConfig.CurvePreferences) != 2 || tlsConfig.CurvePreferences[0] != tls.X25519 {
+		t.Errorf("Unexpected curves: %v", tlsConfig.CurvePreferences)
+	}
+
+	defaults, err := (*TLSSettings)(nil).TLSConfig(nil)
+	if err != nil || defaults.MinVersion != tls.VersionTLS12 || defaults.CipherSuites != nil {
+		t.Errorf("Expected TLS 1.2 minimum with Go default suites, got %+v (%v)", defaults, err)
+	}
+}
+
+func TestTLSSettings_RejectsInsecureCombinations(t *testing.T) {
+	testCases := []struct {
+		name     string
+		settings *TLSSettings
+	}{
+		{"TLS 1.0 minimum", &TLSSettings{MinVersion: "1.0"}},
+		{"TLS 1.1 minimum", &TLSSettings{MinVersion: "1.1"}},
+		{"maximum below minimum", &TLSSettings{MinVersion: "1.3", MaxVersion: "1.2"}},
+		{"unknown version", &TLSSettings{MinVersion: "2.0"}},
+		{"insecure cipher suite", &TLSSettings{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}},
+		{"TLS 1.3 suite", &TLSSettings{CipherSuites: []string{"TLS_AES_128_GCM_SHA256"}}},
+		{"suites with TLS 1.3 minimum", &TLSSettings{MinVersion: "1.3", CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}}},
+		{"unknown cipher suite", &TLSSettings{CipherSuites: []string{"TLS_NOPE"}}},
+		{"unknown curve", &TLSSettings{CurvePreferences: []string{"P-192"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.settings.Validate(nil); err == nil {
+				t.Error("Expected settings to be rejected")
+			}
+		})
+	}
+}
+
+func TestTLSSettings_FIPSProfile(t *testing.T) {
+	fips, _ := LookupCryptoProfile(CryptoProfileFIPS)
+
+	tlsConfig, err := (&TLSSettings{}).TLSConfig(fips)
+	if err != nil {
+		t.Fatalf("Expected FIPS defaults, got %v", err)
+	}
+	if len(tlsConfig.CipherSuites) != len(fipsCipherSuites) || len(tlsConfig.CurvePreferences) != len(fipsCurves) {
+		t.Errorf("Expected FIPS suites and curves, got %v %v", tlsConfig.CipherSuites, tlsConfig.CurvePreferences)
+	}
+
+	if err := (&TLSSettings{CurvePreferences: []string{"X25519"}}).Validate(fips); err == nil {
+		t.Error("Expected X25519 to be rejected by the FIPS profile")
+	}
+	if err := (&TLSSettings{CipherSuites: []string{"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"}}).Validate(fips); err == nil {
+		t.Error("Expected ChaCha20-Poly1305 to be rejected by the FIPS profile")
+	}
+}
+
+func TestDPConnectorService_ProviderClient(t *testing.T) {
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: "http://dp-connector:8080"})
+
+	defaultClient, err := service.providerClient(&DPProvider{DPID: DefaultDPProviderID})
+	if err != nil || defaultClient != service.client {
+		t.Error("Expected providers without TLS settings to share the default client")
+	}
+
+	provider := &DPProvider{DPID: "dp-tls", TLS: &TLSSettings{MinVersion: "1.3"}}
+	client, err := service.providerClient(provider)
+	if err != nil {
+		t.Fatalf("Expected provider client, got %v", err)
+	}
+	if client == service.client {
+		t.Fatal("Expected a dedicated client for provider TLS settings")
+	}
+
+	transport := client.Transport.(*http.Transport)
+	if transport.TLSClientConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Expected TLS 1.3 minimum, got %x", transport.TLSClientConfig.MinVersion)
+	}
+
+	if cached, _ := service.providerClient(provider); cached != client {
+		t.Error("Expected provider client to be cached")
+	}
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RFC 8693 grant and token type identifiers
+const (
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	TokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJWT           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// tokenExchangeRefreshMargin is how long before expiry an exchanged token is
+// replaced, so it does not expire in flight
+const tokenExchangeRefreshMargin = 30 * time.Second
+
+// tokenExchangeMaxCached bounds the exchanged tokens kept per provider
+const tokenExchangeMaxCached = 1000
+
+// TokenExchangeConfig configures RFC 8693 token exchange for a provider.
+// Scopes may contain {claim_type} and {dp_id}, so the downstream token is
+// limited to the one claim being verified at the one DP.
+type TokenExchangeConfig struct {
+	DPID             string
+	TokenURL         string
+	ClientID         string
+	ClientSecret     string
+	Audience         string
+	Resource         string
+	Scopes           []string
+	SubjectTokenType string
+}
+
+// TokenExchangeRecord describes one exchange for the audit trail. ActorChain
+// lists the actors of the issued token's act claim, most recent first.
+type TokenExchangeRecord struct {
+	DPID            string    `json:"dp_id"`
+	RPID            string    `json:"rp_id,omitempty"`
+	ClaimType       string    `json:"claim_type"`
+	Subject         string    `json:"subject,omitempty"`
+	ActorChain      []string  `json:"actor_chain,omitempty"`
+	Scope           string    `json:"scope,omitempty"`
+	IssuedTokenType string    `json:"issued_token_type"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+// tokenExchangeResponse is the token endpoint's answer
+type tokenExchangeResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int    `json:"expires_in"`
+	Scope           string `json:"scope"`
+}
+
+type exchangedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// TokenExchanger swaps the RP's inbound token for downstream tokens scoped to
+// one DP and claim. Tokens are cached per subject token and claim until
+// shortly before they expire.
+type TokenExchanger struct {
+	config     *TokenExchangeConfig
+	client     *http.Client
+	onExchange func(ctx context.Context, record *TokenExchangeRecord)
+	dpop       *DPoPProver
+	now        func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]exchangedToken
+}
+
+// NewTokenExchanger creates a token exchanger for a provider
+func NewTokenExchanger(config *TokenExchangeConfig) *TokenExchanger {
+	return &TokenExchanger{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+		cache:  make(map[string]exchangedToken),
+	}
+}
+
+// subjectTokenKey carries the RP's inbound bearer token
+type subjectTokenKey struct{}
+
+// exchangeScopeKey carries the RP and claim a DP request is made for
+type exchangeScopeKey struct{}
+
+type exchangeScope struct {
+	rpID      string
+	claimType string
+}
+
+// WithSubjectToken records the inbound bearer token, so DP requests made for
+// the request can exchange it
+func WithSubjectToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, subjectTokenKey{}, token)
+}
+
+// SubjectTokenFromContext returns the inbound bearer token, if any
+func SubjectTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(subjectTokenKey{}).(string)
+	return token
+}
+
+func withExchangeScope(ctx context.Context, rpID, claimType string) context.Context {
+	return context.WithValue(ctx, exchangeScopeKey{}, exchangeScope{rpID: rpID, claimType: claimType})
+}
+
+// Exchange returns a downstream token for the claim, exchanging the subject
+// token at the token endpoint unless a cached token is still valid
+func (e *TokenExchanger) Exchange(ctx context.Context, subjectToken, rpID, claimType string) (string, error) {
+	if subjectToken == "" {
+		return "", fmt.Errorf("token exchange requires the RP's inbound token")
+	}
+
+	digest := sha256.Sum256([]byte(subjectToken))
+	cacheKey := hex.EncodeToString(digest[:]) + "|" + claimType
+	e.mu.Lock()
+	if cached, exists := e.cache[cacheKey]; exists && e.now().Before(cached.expiresAt.Add(-tokenExchangeRefreshMargin)) {
+		e.mu.Unlock()
+		return cached.token, nil
+	}
+	e.mu.Unlock()
+
+	scope := e.scope(claimType)
+	form := url.Values{
+		"grant_type":           {TokenExchangeGrantType},
+		"subject_token":        {subjectToken},
+		"subject_token_type":   {e.subjectTokenType()},
+		"requested_token_type": {TokenTypeAccessToken},
+	}
+	if e.config.Audience != "" {
+		form.Set("audience", e.config.Audience)
+	}
+	if e.config.Resource != "" {
+		form.Set("resource", e.config.Resource)
+	}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+
+	body, err := e.requestToken(ctx, form)
+	if err != nil {
+		return "", err
+	}
+
+	var exchanged tokenExchangeResponse
+	if err := json.Unmarshal(body, &exchanged); err != nil {
+		return "", fmt.Errorf("failed to decode token exchange response: %w", err)
+	}
+	if exchanged.AccessToken == "" {
+		return "", fmt.Errorf("token exchange response has no access_token")
+	}
+	if exchanged.TokenType != "" && !strings.EqualFold(exchanged.TokenType, "Bearer") && !strings.EqualFold(exchanged.TokenType, "DPoP") && !strings.EqualFold(exchanged.TokenType, "N_A") {
+		return "", fmt.Errorf("unsupported exchanged token type %q", exchanged.TokenType)
+	}
+
+	expiresAt := e.now().Add(time.Duration(exchanged.ExpiresIn) * time.Second)
+	if exchanged.ExpiresIn <= 0 {
+		// Without a lifetime the token is used for this request only
+		expiresAt = e.now()
+	}
+	if exchanged.Scope != "" {
+		scope = exchanged.Scope
+	}
+
+	record := &TokenExchangeRecord{
+		DPID:            e.config.DPID,
+		RPID:            rpID,
+		ClaimType:       claimType,
+		Scope:           scope,
+		IssuedTokenType: exchanged.IssuedTokenType,
+		ExpiresAt:       expiresAt,
+	}
+	record.Subject, record.ActorChain = delegationChain(exchanged.AccessToken)
+	if record.Subject == "" {
+		record.Subject, _ = delegationChain(subjectToken)
+	}
+	if e.onExchange != nil {
+		e.onExchange(ctx, record)
+	}
+
+	e.mu.Lock()
+	if len(e.cache) >= tokenExchangeMaxCached {
+		e.pruneLocked()
+	}
+	e.cache[cacheKey] = exchangedToken{token: exchanged.AccessToken, expiresAt: expiresAt}
+	e.mu.Unlock()
+
+	return exchanged.AccessToken, nil
+}
+
+// requestToken posts the exchange to the token endpoint. With DPoP the
+// request carries a proof, so the issued token is bound to the broker's key;
+// a nonce challenge is answered once.
+func (e *TokenExchanger) requestToken(ctx context.Context, form url.Values) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", e.config.TokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create token exchange request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		// The broker authenticates as itself; the token endpoint records it
+		// as the actor in the issued token's act claim
+		if e.config.ClientID != "" {
+			req.SetBasicAuth(url.QueryEscape(e.config.ClientID), url.QueryEscape(e.config.ClientSecret))
+		}
+		if e.dpop != nil {
+			proof, err := e.dpop.Proof("POST", e.config.TokenURL, "")
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set(DPoPHeader, proof)
+		}
+
+		resp, err := e.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("token exchange failed: %w", err)
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token exchange response: %w", err)
+		}
+		if e.dpop != nil && e.dpop.ObserveResponse(resp, body) && attempt == 0 {
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("token exchange returned status %d: %s", resp.StatusCode, string(body))
+		}
+		return body, nil
+	}
+}
+
+func (e *TokenExchanger) subjectTokenType() string {
+	if e.config.SubjectTokenType != "" {
+		return e.config.SubjectTokenType
+	}
+	return TokenTypeAccessToken
+}
+
+// scope fills the claim type and DP into the configured scopes
+func (e *TokenExchanger) scope(claimType string) string {
+	replacer := strings.NewReplacer("{claim_type}", claimType, "{dp_id}", e.config.DPID)
+	scopes := make([]string, len(e.config.Scopes))
+	for i, scope := range e.config.Scopes {
+		scopes[i] = replacer.Replace(scope)
+	}
+	return strings.Join(scopes, " ")
+}
+
+// pruneLocked drops expired tokens, or all of them if none has expired
+func (e *TokenExchanger) pruneLocked() {
+	now := e.now()
+	for key, cached := range e.cache {
+		if !now.Before(cached.expiresAt) {
+			delete(e.cache, key)
+		}
+	}
+	if len(e.cache) >= tokenExchangeMaxCached {
+		e.cache = make(map[string]exchangedToken)
+	}
+}
+
+// delegationChain reads the subject and the nested act claims of a JWT. The
+// token is not verified here: the issued token is for the DP to verify, and
+// the subject token was verified on the way in. Opaque tokens yield nothing.
+func delegationChain(token string) (string, []string) {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return "", nil
+	}
+	subject, _ := claims["sub"].(string)
+
+	var actors []string
+	act, _ := claims["act"].(map[string]interface{})
+	for act != nil && len(actors) < 10 {
+		if actor, ok := act["sub"].(string); ok {
+			actors = append(actors, actor)
+		} else if actor, ok := act["client_id"].(string); ok {
+			actors = append(actors, actor)
+		}
+		act, _ = act["act"].(map[string]interface{})
+	}
+	return subject, actors
+}
+
+// addTokenExchangeAuth exchanges the request's inbound token for a token
+// scoped to the DP and claim
+func (a *Authenticator) addTokenExchangeAuth(req *http.Request) error {
+	if a.exchanger == nil {
+		return fmt.Errorf("token exchange configuration not provided")
+	}
+	scope, _ := req.Context().Value(exchangeScopeKey{}).(exchangeScope)
+	token, err := a.exchanger.Exchange(req.Context(), SubjectTokenFromContext(req.Context()), scope.rpID, scope.claimType)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
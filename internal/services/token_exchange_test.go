@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// newTokenExchangeServer is a token endpoint that issues JWTs for the subject
+// with the authenticated client as the actor
+func newTokenExchangeServer(t *testing.T, exchanges *[]url.Values) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		*exchanges = append(*exchanges, r.Form)
+		clientID, clientSecret, _ := r.BasicAuth()
+		if r.Form.Get("grant_type") != TokenExchangeGrantType || clientID != "broker" || clientSecret != "broker-secret" {
+			http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+			return
+		}
+
+		subject := jwt.MapClaims{}
+		jwt.NewParser().ParseUnverified(r.Form.Get("subject_token"), subject)
+		claims := jwt.MapClaims{
+			"sub":   subject["sub"],
+			"aud":   r.Form.Get("audience"),
+			"scope": r.Form.Get("scope"),
+			"act":   map[string]interface{}{"sub": clientID, "act": subject["act"]},
+		}
+		issued, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("sts-key"))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":      issued,
+			"issued_token_type": TokenTypeAccessToken,
+			"token_type":        "Bearer",
+			"expires_in":        300,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDPConnectorService_TokenExchange(t *testing.T) {
+	var exchanges []url.Values
+	sts := newTokenExchangeServer(t, &exchanges)
+
+	var authorizations []string
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":              "j",
+			"status":              "completed",
+			"timestamp":           "2026-01-01T00:00:00Z",
+			"verification_result": map[string]interface{}{"verified": true, "confidence": 0.9},
+		})
+	}))
+	defer dp.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: dp.URL, DPTimeout: 5 * time.Second})
+	service.Registry().Remove(DefaultDPProviderID)
+	if err := service.Registry().Register(&DPProvider{
+		DPID:            "dp-delegated",
+		Endpoint:        dp.URL,
+		SupportedClaims: []string{AnyClaimType},
+		Auth: &DPProviderAuth{
+			Method:       AuthMethodTokenExchange,
+			TokenURL:     sts.URL,
+			ClientID:     "broker",
+			ClientSecret: "broker-secret",
+			Audience:     "https://dp.example",
+			Scopes:       []string{"verify:{claim_type}"},
+		},
+	}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	provider, _ := service.Registry().Get("dp-delegated")
+	var records []*TokenExchangeRecord
+	service.providerAuthenticator(provider).exchanger.onExchange = func(ctx context.Context, record *TokenExchangeRecord) {
+		records = append(records, record)
+	}
+
+	// The RP's token was itself issued to the RP acting for the user
+	subjectToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "user-42",
+		"act": map[string]interface{}{"sub": "rp-app"},
+	}).SignedString([]byte("idp-key"))
+	ctx := WithSubjectToken(context.Background(), subjectToken)
+
+	for _, claimType := range []string{"student_verification", "student_verification", "age_verification"} {
+		if _, err := service.VerifyWithDP(ctx, &models.PrivacyRequest{RPID: "rp_1", ClaimType: claimType}); err != nil {
+			t.Fatalf("VerifyWithDP failed: %v", err)
+		}
+	}
+
+	if len(exchanges) != 2 {
+		t.Fatalf("Expected one exchange per claim, got %d", len(exchanges))
+	}
+	if exchanges[0].Get("scope") != "verify:student_verification" || exchanges[0].Get("audience") != "https://dp.example" {
+		t.Errorf("Unexpected exchange request: %v", exchanges[0])
+	}
+	if exchanges[0].Get("subject_token") != subjectToken || exchanges[0].Get("subject_token_type") != TokenTypeAccessToken {
+		t.Errorf("Expected the RP's token as the subject token, got %v", exchanges[0])
+	}
+	if authorizations[0] == "Bearer "+subjectToken || authorizations[0] != authorizations[1] || authorizations[1] == authorizations[2] {
+		t.Errorf("Expected cached exchanged tokens per claim, got %v", authorizations)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 audit records, got %d", len(records))
+	}
+	record := records[0]
+	if record.Subject != "user-42" || len(record.ActorChain) != 2 || record.ActorChain[0] != "broker" || record.ActorChain[1] != "rp-app" {
+		t.Errorf("Expected the subject and actor chain to be preserved, got %+v", record)
+	}
+	if record.RPID != "rp_1" || record.DPID != "dp-delegated" || record.ClaimType != "student_verification" {
+		t.Errorf("Unexpected audit record: %+v", record)
+	}
+
+	// Without the RP's token there is nothing to exchange
+	if _, err := service.VerifyWithDP(context.Background(), &models.PrivacyRequest{RPID: "rp_1", ClaimType: "student_verification"}); err == nil {
+		t.Error("Expected a request without an inbound token to fail")
+	}
+}
+
+func TestTokenExchanger_Errors(t *testing.T) {
+	var exchanges []url.Values
+	sts := newTokenExchangeServer(t, &exchanges)
+
+	exchanger := NewTokenExchanger(&TokenExchangeConfig{DPID: "dp", TokenURL: sts.URL, ClientID: "broker", ClientSecret: "wrong"})
+	if _, err := exchanger.Exchange(context.Background(), "token", "rp_1", "age_verification"); err == nil {
+		t.Error("Expected a rejected exchange to fail")
+	}
+	if _, err := exchanger.Exchange(context.Background(), "", "rp_1", "age_verification"); err == nil {
+		t.Error("Expected an empty subject token to fail")
+	}
+
+	provider := &DPProvider{DPID: "dp", Endpoint: "https://dp.example", SupportedClaims: []string{AnyClaimType}, Auth: &DPProviderAuth{Method: AuthMethodTokenExchange}}
+	if err := provider.Validate(); err == nil {
+		t.Error("Expected token exchange without a token_url to be rejected")
+	}
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TransformBatchOptions controls how a batch of records is transformed
+type TransformBatchOptions struct {
+	// Workers transforming records concurrently; defaults to the
+	// transformer's BatchWorkers, then GOMAXPROCS
+	Workers int `json:"workers,omitempty"`
+	// Unordered returns results as records finish rather than in input order
+	Unordered bool `json:"unordered,omitempty"`
+	// MaxFailures aborts the batch once more records than this have failed;
+	// zero never aborts on a count
+	MaxFailures int `json:"maxFailures,omitempty"`
+	// MaxFailureRate aborts the batch once failed records exceed this share
+	// of the batch; zero never aborts on a rate
+	MaxFailureRate float64 `json:"maxFailureRate,omitempty"`
+}
+
+// TransformBatchRecord is the transformation of one record of a batch
+type TransformBatchRecord struct {
+	Index int `json:"index"`
+	TransformationResponse
+}
+
+// TransformBatchMetrics aggregates the metrics of a batch's records
+type TransformBatchMetrics struct {
+	Records     int `json:"records"`
+	Processed   int `json:"processed"`
+	Succeeded   int `json:"succeeded"`
+	Failed      int `json:"failed"`
+	Unprocessed int `json:"unprocessed"`
+	Workers     int `json:"workers"`
+	// Field counts are summed over the processed records
+	TotalFields       int `json:"totalFields"`
+	TransformedFields int `json:"transformedFields"`
+	ErrorFields       int `json:"errorFields"`
+	WarningFields     int `json:"warningFields"`
+	EnrichmentCount   int `json:"enrichmentCount"`
+	SkippedFields     int `json:"skippedFields"`
+	// ProcessingTime is the batch's wall time; RecordTime sums the time
+	// spent on each record
+	ProcessingTime float64 `json:"processingTimeMs"`
+	RecordTime     float64 `json:"recordTimeMs"`
+}
+
+// TransformBatchResponse is the result of transforming a batch of records
+type TransformBatchResponse struct {
+	Success bool                   `json:"success"`
+	Records []TransformBatchRecord `json:"records"`
+	// Errors holds batch-level errors, such as an unknown pipeline
+	Errors  []TransformationError `json:"errors,omitempty"`
+	Aborted bool                  `json:"aborted,omitempty"`
+	Metrics TransformBatchMetrics `json:"metrics"`
+}
+
+// TransformBatch transforms each record with the request's schemas, rules
+// and options (its Data is ignored) on a pool of workers. When the batch
+// aborts, records already in flight finish; the rest are not transformed and
+// have no result.
+func (dt *DataTransformer) TransformBatch(records []interface{}, req TransformationRequest, options TransformBatchOptions) TransformBatchResponse {
+	startTime := time.Now()
+	response := TransformBatchResponse{
+		Success: true,
+		Records: make([]TransformBatchRecord, 0, len(records)),
+		Metrics: TransformBatchMetrics{Records: len(records)},
+	}
+
+	// Resolve the pipeline once so every record sees the same version
+	if req.Pipeline != "" {
+		resolved, pipelineErr := dt.resolvePipeline(req)
+		if pipelineErr != nil {
+			response.Errors = append(response.Errors, *pipelineErr)
+			response.Success = false
+			response.Metrics.Unprocessed = len(records)
+			return response
+		}
+		resolved.Pipeline = ""
+		req = resolved
+	}
+
+	// Merge the configured custom transformers up front; TransformData only
+	// adds missing ones, so the workers then share the map read-only
+	custom := make(map[string]TransformFunction, len(req.Options.CustomTransformers)+len(dt.config.CustomTransformers))
+	for name, transformer := range dt.config.CustomTransformers {
+		custom[name] = transformer
+	}
+	for name, transformer := range req.Options.CustomTransformers {
+		custom[name] = transformer
+	}
+	req.Options.CustomTransformers = custom
+
+	workers := options.Workers
+	if workers <= 0 {
+		workers = dt.config.BatchWorkers
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(records) {
+		workers = len(records)
+	}
+	response.Metrics.Workers = workers
+
+	maxFailures := -1
+	if options.MaxFailures > 0 {
+		maxFailures = options.MaxFailures
+	}
+	if options.MaxFailureRate > 0 {
+		byRate := int(options.MaxFailureRate * float64(len(records)))
+		if maxFailures < 0 || byRate < maxFailures {
+			maxFailures = byRate
+		}
+	}
+
+	var aborted atomic.Bool
+	jobs := make(chan int)
+	results := make(chan TransformBatchRecord)
+	go func() {
+		defer close(jobs)
+		for i := range records {
+			if aborted.Load() {
+				return
+			}
+			jobs <- i
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if aborted.Load() {
+					continue
+				}
+				recordReq := req
+				recordReq.Data = records[i]
+				results <- TransformBatchRecord{Index: i, TransformationResponse: dt.TransformData(recordReq)}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var ordered []*TransformBatchRecord
+	if !options.Unordered {
+		ordered = make([]*TransformBatchRecord, len(records))
+	}
+	for result := range results {
+		response.Metrics.add(result.TransformationResponse)
+		if !result.Success && maxFailures >= 0 && response.Metrics.Failed > maxFailures {
+			aborted.Store(true)
+		}
+		if options.Unordered {
+			response.Records = append(response.Records, result)
+		} else {
+			record := result
+			ordered[result.Index] = &record
+		}
+	}
+	for _, record := range ordered {
+		if record != nil {
+			response.Records = append(response.Records, *record)
+		}
+	}
+
+	response.Aborted = aborted.Load()
+	response.Success = response.Metrics.Failed == 0 && !response.Aborted
+	response.Metrics.Unprocessed = len(records) - response.Metrics.Processed
+	response.Metrics.ProcessingTime = float64(time.Since(startTime).Microseconds()) / 1000.0
+	return response
+}
+
+// add counts a record's transformation in the batch metrics
+func (m *TransformBatchMetrics) add(record TransformationResponse) {
+	m.Processed++
+	if record.Success {
+		m.Succeeded++
+	} else {
+		m.Failed++
+	}
+	m.TotalFields += record.Metrics.TotalFields
+	m.TransformedFields += record.Metrics.TransformedFields
+	m.ErrorFields += record.Metrics.ErrorFields
+	m.WarningFields += record.Metrics.WarningFields
+	m.EnrichmentCount += record.Metrics.EnrichmentCount
+	m.SkippedFields += record.Metrics.SkippedFields
+	m.RecordTime += record.Metrics.ProcessingTime
+}
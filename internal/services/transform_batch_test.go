@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+)
+
+func batchRecords(n int) []interface{} {
+	records := make([]interface{}, n)
+	for i := range records {
+		records[i] = map[string]interface{}{"id": i, "name": fmt.Sprintf("student %d", i)}
+	}
+	return records
+}
+
+func TestDataTransformer_TransformBatch(t *testing.T) {
+	transformer := NewDataTransformer(DataTransformerConfig{})
+	req := TransformationRequest{Transformations: []TransformationRule{
+		{SourceField: "name", TargetField: "student.name", Transformation: "uppercase"},
+		{SourceField: "id", TargetField: "student.id", Transformation: "string"},
+	}}
+
+	response := transformer.TransformBatch(batchRecords(50), req, TransformBatchOptions{Workers: 8})
+	if !response.Success || len(response.Records) != 50 || response.Metrics.Workers != 8 {
+		t.Fatalf("Expected every record to be transformed, got %+v", response.Metrics)
+	}
+	for i, record := range response.Records {
+		student := record.Data.(map[string]interface{})["student"].(map[string]interface{})
+		if record.Index != i || student["id"] != fmt.Sprint(i) || student["name"] != fmt.Sprintf("STUDENT %d", i) {
+			t.Fatalf("Expected record %d in input order, got %d: %v", i, record.Index, student)
+		}
+	}
+	if response.Metrics.Succeeded != 50 || response.Metrics.TransformedFields != 100 {
+		t.Errorf("Expected aggregated metrics, got %+v", response.Metrics)
+	}
+
+	response = transformer.TransformBatch(batchRecords(50), req, TransformBatchOptions{Workers: 8, Unordered: true})
+	seen := make(map[int]bool)
+	for _, record := range response.Records {
+		seen[record.Index] = true
+	}
+	if len(seen) != 50 {
+		t.Errorf("Expected every record in unordered output, got %d", len(seen))
+	}
+}
+
+func TestDataTransformer_TransformBatchAbort(t *testing.T) {
+	transformer := NewDataTransformer(DataTransformerConfig{})
+	req := TransformationRequest{Transformations: []TransformationRule{
+		{SourceField: "name", TargetField: "id", Transformation: "integer"},
+	}}
+
+	response := transformer.TransformBatch(batchRecords(200), req, TransformBatchOptions{Workers: 1, MaxFailures: 3})
+	// The record in flight when the batch aborts still finishes
+	if !response.Aborted || response.Success || response.Metrics.Failed < 4 || response.Metrics.Failed > 5 {
+		t.Errorf("Expected the batch to abort after the fourth failure, got %+v", response.Metrics)
+	}
+	if response.Metrics.Unprocessed == 0 || len(response.Records) != response.Metrics.Processed {
+		t.Errorf("Expected the remaining records to be left unprocessed, got %+v", response.Metrics)
+	}
+
+	response = transformer.TransformBatch(batchRecords(20), req, TransformBatchOptions{MaxFailureRate: 0.5})
+	if !response.Aborted || response.Metrics.Failed <= 10 {
+		t.Errorf("Expected the batch to abort past half its records failing, got %+v", response.Metrics)
+	}
+
+	response = transformer.TransformBatch(batchRecords(5), req, TransformBatchOptions{})
+	if response.Aborted || response.Metrics.Failed != 5 || response.Success {
+		t.Errorf("Expected a batch without thresholds to run to the end, got %+v", response.Metrics)
+	}
+
+	response = transformer.TransformBatch(batchRecords(5), TransformationRequest{Pipeline: "missing"}, TransformBatchOptions{})
+	if response.Success || response.Errors[0].Code != "UNKNOWN_PIPELINE" || response.Metrics.Unprocessed != 5 {
+		t.Errorf("Expected an unknown pipeline to fail the batch, got %+v", response)
+	}
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Enrichment providers available to every transformer
+const (
+	EnrichmentProviderLookup = "lookup"
+	EnrichmentProviderHTTP   = "http"
+	EnrichmentProviderRedis  = "redis"
+)
+
+// Enrichment failure policies: what happens to a field whose key is missing,
+// whose value is not found or whose lookup fails
+const (
+	EnrichmentPolicySkip    = "skip"
+	EnrichmentPolicyDefault = "default"
+	EnrichmentPolicyError   = "error"
+)
+
+// Enrichment result statuses
+const (
+	EnrichmentEnriched  = "enriched"
+	EnrichmentDefaulted = "defaulted"
+	EnrichmentSkipped   = "skipped"
+	EnrichmentFailed    = "failed"
+)
+
+// defaultEnrichmentTimeout bounds a lookup without its own timeoutMs
+const defaultEnrichmentTimeout = 2 * time.Second
+
+// maxEnrichmentResponseBytes bounds the responses read from HTTP enrichment
+// endpoints
+const maxEnrichmentResponseBytes = 1 << 20
+
+// EnrichmentRule adds a field to a pipeline's output by looking up the value
+// of a source field with an enrichment provider: a static table, an HTTP
+// endpoint (with {key} in the URL) or a Redis key (KeyPrefix + key).
+// ResultField picks a field from a JSON object result.
+type EnrichmentRule struct {
+	Provider    string                 `json:"provider"`
+	KeyField    string                 `json:"keyField"`
+	TargetField string                 `json:"targetField"`
+	ResultField string                 `json:"resultField,omitempty"`
+	Table       map[string]interface{} `json:"table,omitempty"`
+	URL         string                 `json:"url,omitempty"`
+	KeyPrefix   string                 `json:"keyPrefix,omitempty"`
+	TimeoutMs   int                    `json:"timeoutMs,omitempty"`
+	// OnMissing applies when the key field is absent or the provider has no
+	// value for it, OnFailure when the lookup fails; both default to skip
+	OnMissing    string      `json:"onMissing,omitempty"`
+	OnFailure    string      `json:"onFailure,omitempty"`
+	DefaultValue interface{} `json:"defaultValue,omitempty"`
+}
+
+// EnrichmentResult reports how one enrichment rule applied to a record
+type EnrichmentResult struct {
+	Field    string `json:"field"`
+	Provider string `json:"provider"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// EnrichmentProvider looks up the value for a key. Found is false when the
+// provider has no value for the key.
+type EnrichmentProvider interface {
+	Lookup(ctx context.Context, rule EnrichmentRule, key string) (value interface{}, found bool, err error)
+}
+
+// defaultEnrichmentProviders returns the providers a transformer starts
+// with; Redis lookups need a client and are added by the caller
+func defaultEnrichmentProviders() map[string]EnrichmentProvider {
+	return map[string]EnrichmentProvider{
+		EnrichmentProviderLookup: LookupTableEnrichment{},
+		EnrichmentProviderHTTP:   NewHTTPEnrichment(nil),
+	}
+}
+
+func validateEnrichmentRule(rule EnrichmentRule) error {
+	if rule.Provider == "" {
+		return fmt.Errorf("provider is required")
+	}
+	if _, err := parseTransformPath(rule.KeyField); err != nil {
+		return fmt.Errorf("keyField: %w", err)
+	}
+	if _, err := parseTransformPath(rule.TargetField); err != nil {
+		return fmt.Errorf("targetField: %w", err)
+	}
+	for _, policy := range []string{rule.OnMissing, rule.OnFailure} {
+		switch policy {
+		case "", EnrichmentPolicySkip, EnrichmentPolicyDefault, EnrichmentPolicyError:
+		default:
+			return fmt.Errorf("unknown failure policy %q", policy)
+		}
+	}
+	switch rule.Provider {
+	case EnrichmentProviderLookup:
+		if rule.Table == nil {
+			return fmt.Errorf("lookup enrichment requires a table")
+		}
+	case EnrichmentProviderHTTP:
+		if !strings.Contains(rule.URL, "{key}") {
+			return fmt.Errorf("http enrichment URL must contain {key}")
+		}
+		if err := validateWebhookURL(strings.ReplaceAll(rule.URL, "{key}", "key")); err != nil {
+			return fmt.Errorf("http enrichment %s", strings.TrimPrefix(err.Error(), "webhook "))
+		}
+	}
+	return nil
+}
+
+// applyEnrichments runs enrichment rules against a source record, setting
+// their results in the transformed output
+func (dt *DataTransformer) applyEnrichments(source interface{}, data interface{}, rules []EnrichmentRule, response *TransformationResponse) interface{} {
+	sourceMap, _ := source.(map[string]interface{})
+	output, ok := data.(map[string]interface{})
+	if !ok {
+		if data != nil {
+			response.Errors = append(response.Errors, TransformationError{
+				Field:   "enrichment",
+				Message: "transformed data must be an object for enrichment",
+				Code:    "ENRICHMENT_FAILED",
+			})
+			return data
+		}
+		output = make(map[string]interface{})
+	}
+
+	for _, rule := range rules {
+		result := EnrichmentResult{Field: rule.TargetField, Provider: rule.Provider}
+		value, status, err := dt.enrich(sourceMap, rule)
+		switch {
+		case status == EnrichmentEnriched:
+			result.Status = EnrichmentEnriched
+		case policyFor(rule, status) == EnrichmentPolicyDefault:
+			value, result.Status = rule.DefaultValue, EnrichmentDefaulted
+		case policyFor(rule, status) == EnrichmentPolicyError:
+			result.Status = EnrichmentFailed
+			code := "ENRICHMENT_NOT_FOUND"
+			if status == EnrichmentFailed {
+				code = "ENRICHMENT_FAILED"
+			}
+			response.Errors = append(response.Errors, TransformationError{
+				Field:   rule.TargetField,
+				Message: enrichmentMessage(status, err),
+				Code:    code,
+			})
+		default:
+			result.Status = EnrichmentSkipped
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+
+		if result.Status == EnrichmentEnriched || result.Status == EnrichmentDefaulted {
+			if setErr := setTransformPath(output, rule.TargetField, value); setErr != nil {
+				result.Status, result.Error = EnrichmentFailed, setErr.Error()
+				response.Errors = append(response.Errors, TransformationError{
+					Field:   rule.TargetField,
+					Message: setErr.Error(),
+					Code:    "INVALID_PATH",
+				})
+			} else {
+				response.Metrics.EnrichmentCount++
+			}
+		}
+		response.Enrichments = append(response.Enrichments, result)
+	}
+	return output
+}
+
+// enrich looks up one rule's value, returning EnrichmentEnriched with the
+// value, EnrichmentSkipped when there is no value, or EnrichmentFailed
+func (dt *DataTransformer) enrich(source map[string]interface{}, rule EnrichmentRule) (interface{}, string, error) {
+	key, exists, err := getTransformPath(source, rule.KeyField)
+	if err != nil {
+		return nil, EnrichmentFailed, err
+	}
+	if !exists || key == nil {
+		return nil, EnrichmentSkipped, nil
+	}
+	provider, known := dt.config.EnrichmentProviders[rule.Provider]
+	if !known {
+		return nil, EnrichmentFailed, fmt.Errorf("unknown enrichment provider: %s", rule.Provider)
+	}
+
+	timeout := defaultEnrichmentTimeout
+	if rule.TimeoutMs > 0 {
+		timeout = time.Duration(rule.TimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	value, found, err := provider.Lookup(ctx, rule, fmt.Sprint(key))
+	if err != nil {
+		return nil, EnrichmentFailed, err
+	}
+	if !found {
+		return nil, EnrichmentSkipped, nil
+	}
+	if rule.ResultField != "" {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, EnrichmentFailed, fmt.Errorf("result is %s, not an object", jsonTypeOf(value))
+		}
+		value, found, err = getTransformPath(object, rule.ResultField)
+		if err != nil {
+			return nil, EnrichmentFailed, err
+		}
+		if !found {
+			return nil, EnrichmentSkipped, nil
+		}
+	}
+	return value, EnrichmentEnriched, nil
+}
+
+// policyFor returns the rule's policy for a lookup that found nothing
+// (EnrichmentSkipped) or failed
+func policyFor(rule EnrichmentRule, status string) string {
+	policy := rule.OnMissing
+	if status == EnrichmentFailed {
+		policy = rule.OnFailure
+	}
+	if policy == "" {
+		return EnrichmentPolicySkip
+	}
+	return policy
+}
+
+func enrichmentMessage(status string, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "no enrichment value found"
+}
+
+// LookupTableEnrichment looks keys up in the rule's static table
+type LookupTableEnrichment struct{}
+
+// Lookup returns the table entry for a key
+func (LookupTableEnrichment) Lookup(ctx context.Context, rule EnrichmentRule, key string) (interface{}, bool, error) {
+	value, found := rule.Table[key]
+	return value, found, nil
+}
+
+// HTTPEnrichment fetches a key's value as JSON from the rule's URL, with
+// {key} replaced by the escaped key. A 404 means no value.
+type HTTPEnrichment struct {
+	client *http.Client
+}
+
+// NewHTTPEnrichment creates an HTTP enrichment provider; a nil client uses
+// the default one, bounded by each rule's timeout
+func NewHTTPEnrichment(client *http.Client) *HTTPEnrichment {
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &HTTPEnrichment{client: client}
+}
+
+// Lookup fetches the value for a key
+func (e *HTTPEnrichment) Lookup(ctx context.Context, rule EnrichmentRule, key string) (interface{}, bool, error) {
+	target := strings.ReplaceAll(rule.URL, "{key}", url.PathEscape(key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, false, fmt.Errorf("enrichment endpoint returned %d", resp.StatusCode)
+	}
+
+	var value interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxEnrichmentResponseBytes)).Decode(&value); err != nil {
+		return nil, false, fmt.Errorf("invalid enrichment response: %w", err)
+	}
+	return value, true, nil
+}
+
+// redisGetter is the part of a Redis client enrichment uses
+type redisGetter interface {
+	Get(ctx context.Context, key string) *redis.StringCmd
+}
+
+// RedisEnrichment reads a key's value from Redis at the rule's KeyPrefix
+// plus the key. Values holding JSON are decoded; others are strings.
+type RedisEnrichment struct {
+	client redisGetter
+}
+
+// NewRedisEnrichment creates a Redis enrichment provider over a client
+func NewRedisEnrichment(client redisGetter) *RedisEnrichment {
+	return &RedisEnrichment{client: client}
+}
+
+// Lookup reads the value for a key
+func (e *RedisEnrichment) Lookup(ctx context.Context, rule EnrichmentRule, key string) (interface{}, bool, error) {
+	stored, err := e.client.Get(ctx, rule.KeyPrefix+key).Result()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var value interface{}
+	if json.Unmarshal([]byte(stored), &value) != nil {
+		return stored, true, nil
+	}
+	return value, true, nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type fakeRedis map[string]string
+
+func (f fakeRedis) Get(ctx context.Context, key string) *redis.StringCmd {
+	if key == "broken:1" {
+		return redis.NewStringResult("", fmt.Errorf("connection refused"))
+	}
+	if value, exists := f[key]; exists {
+		return redis.NewStringResult(value, nil)
+	}
+	return redis.NewStringResult("", redis.Nil)
+}
+
+func TestDataTransformer_PipelineEnrichment(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/institutions/MIT":
+			fmt.Fprint(w, `{"name": "Massachusetts Institute of Technology", "accredited": true}`)
+		case "/institutions/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer endpoint.Close()
+
+	pipelines := NewTransformationPipelineRegistry()
+	_, err := pipelines.Register(&TransformationPipeline{
+		Name: "sis_enriched",
+		Transformations: []TransformationRule{
+			{SourceField: "student_id", TargetField: "student.id", Transformation: "copy"},
+		},
+		Enrichments: []EnrichmentRule{
+			{Provider: EnrichmentProviderLookup, KeyField: "country", TargetField: "student.country_name",
+				Table: map[string]interface{}{"US": "United States"}},
+			{Provider: EnrichmentProviderHTTP, KeyField: "institution", TargetField: "student.institution",
+				URL: endpoint.URL + "/institutions/{key}", ResultField: "name", OnFailure: EnrichmentPolicyDefault, DefaultValue: "unknown"},
+			{Provider: EnrichmentProviderRedis, KeyField: "student_id", TargetField: "student.program",
+				KeyPrefix: "program:", ResultField: "code"},
+			{Provider: EnrichmentProviderLookup, KeyField: "tier", TargetField: "student.tier",
+				Table: map[string]interface{}{}, OnMissing: EnrichmentPolicyError},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected the pipeline to register, got %v", err)
+	}
+	transformer := NewDataTransformer(DataTransformerConfig{
+		Pipelines: pipelines,
+		EnrichmentProviders: map[string]EnrichmentProvider{
+			EnrichmentProviderRedis: NewRedisEnrichment(fakeRedis{"program:1": `{"code": "CS"}`}),
+		},
+	})
+	transform := func(record map[string]interface{}) (TransformationResponse, map[string]interface{}, map[string]string) {
+		response := transformer.TransformData(TransformationRequest{Data: record, Pipeline: "sis_enriched"})
+		student, _ := response.Data.(map[string]interface{})["student"].(map[string]interface{})
+		statuses := make(map[string]string)
+		for _, result := range response.Enrichments {
+			statuses[result.Field] = result.Status
+		}
+		return response, student, statuses
+	}
+
+	response, student, statuses := transform(map[string]interface{}{
+		"student_id": "1", "country": "US", "institution": "MIT", "tier": "gold",
+	})
+	if student["country_name"] != "United States" || student["institution"] != "Massachusetts Institute of Technology" || student["program"] != "CS" {
+		t.Errorf("Expected the enriched fields, got %v", student)
+	}
+	if statuses["student.tier"] != EnrichmentFailed || response.Success || response.Errors[0].Code != "ENRICHMENT_NOT_FOUND" {
+		t.Errorf("Expected the missing tier to fail the record, got %v and %+v", statuses, response.Errors)
+	}
+	if response.Metrics.EnrichmentCount != 3 {
+		t.Errorf("Expected three enriched fields, got %d", response.Metrics.EnrichmentCount)
+	}
+
+	response, student, statuses = transform(map[string]interface{}{"student_id": "2", "institution": "down"})
+	if statuses["student.institution"] != EnrichmentDefaulted || student["institution"] != "unknown" {
+		t.Errorf("Expected a failed lookup to take its default, got %v and %v", statuses, student)
+	}
+	if statuses["student.country_name"] != EnrichmentSkipped || statuses["student.program"] != EnrichmentSkipped {
+		t.Errorf("Expected missing keys and values to be skipped, got %v", statuses)
+	}
+	if _, exists := student["country_name"]; exists {
+		t.Error("Expected a skipped field to be left out")
+	}
+	for _, result := range response.Enrichments {
+		if result.Field == "student.institution" && !strings.Contains(result.Error, "503") {
+			t.Errorf("Expected the lookup error to be reported, got %+v", result)
+		}
+	}
+}
+
+func TestRedisEnrichment_Failure(t *testing.T) {
+	provider := NewRedisEnrichment(fakeRedis{"plain:1": "text"})
+	if value, found, err := provider.Lookup(context.Background(), EnrichmentRule{KeyPrefix: "plain:"}, "1"); err != nil || !found || value != "text" {
+		t.Errorf("Expected a plain string value, got %v, %v, %v", value, found, err)
+	}
+	if _, _, err := provider.Lookup(context.Background(), EnrichmentRule{KeyPrefix: "broken:"}, "1"); err == nil {
+		t.Error("Expected a Redis error to be returned")
+	}
+}
+
+func TestValidateEnrichmentRule(t *testing.T) {
+	invalid := map[string]EnrichmentRule{
+		"no provider":   {KeyField: "a", TargetField: "b"},
+		"no table":      {Provider: EnrichmentProviderLookup, KeyField: "a", TargetField: "b"},
+		"no key in url": {Provider: EnrichmentProviderHTTP, KeyField: "a", TargetField: "b", URL: "https://enrich.example.com/x"},
+		"plain http":    {Provider: EnrichmentProviderHTTP, KeyField: "a", TargetField: "b", URL: "http://enrich.example.com/{key}"},
+		"policy":        {Provider: EnrichmentProviderRedis, KeyField: "a", TargetField: "b", OnFailure: "retry"},
+		"target path":   {Provider: EnrichmentProviderRedis, KeyField: "a", TargetField: "b["},
+	}
+	for name, rule := range invalid {
+		if err := validateEnrichmentRule(rule); err == nil {
+			t.Errorf("%s: expected the rule to be rejected", name)
+		}
+	}
+}
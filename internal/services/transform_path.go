@@ -0,0 +1,225 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxTransformPathIndex bounds the array indexes a transformation may write,
+// so a rule cannot allocate an arbitrarily large array
+const maxTransformPathIndex = 10000
+
+// transformPathSegment is one step of a transformation path: an object key
+// or an array index
+type transformPathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func (s transformPathSegment) String() string {
+	if s.isIndex {
+		return fmt.Sprintf("[%d]", s.index)
+	}
+	return s.key
+}
+
+// parseTransformPath parses a field path in dot and bracket syntax, such as
+// user.address.city, items[0].id or labels["app.example/name"], where a
+// quoted bracket holds a key containing dots or brackets
+func parseTransformPath(path string) ([]transformPathSegment, error) {
+	if path == "" {
+		return nil, fmt.Errorf("empty field path")
+	}
+
+	var segments []transformPathSegment
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '[':
+			if strings.IndexByte(path[i:], ']') < 0 {
+				return nil, fmt.Errorf("field path %q: unterminated [ at offset %d", path, i)
+			}
+			segment, err := parseTransformPathBracket(path, i)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, segment.segment)
+			i = segment.end
+		case '.':
+			if len(segments) == 0 || i+1 >= len(path) || path[i+1] == '.' || path[i+1] == '[' {
+				return nil, fmt.Errorf("field path %q: empty field name at offset %d", path, i)
+			}
+			i++
+		default:
+			if len(segments) > 0 && path[i-1] != '.' {
+				return nil, fmt.Errorf("field path %q: expected . or [ at offset %d", path, i)
+			}
+			end := i
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				end++
+			}
+			segments = append(segments, transformPathSegment{key: path[i:end]})
+			i = end
+		}
+	}
+	if segments[0].isIndex {
+		return nil, fmt.Errorf("field path %q must start with a field name", path)
+	}
+	return segments, nil
+}
+
+type bracketSegment struct {
+	segment transformPathSegment
+	end     int
+}
+
+// parseTransformPathBracket parses the bracket at offset start: a quoted key
+// or an array index
+func parseTransformPathBracket(path string, start int) (bracketSegment, error) {
+	i := start + 1
+	if i < len(path) && (path[i] == '"' || path[i] == '\'') {
+		quote := path[i]
+		var key strings.Builder
+		for i++; i < len(path); i++ {
+			switch path[i] {
+			case '\\':
+				if i+1 < len(path) {
+					i++
+					key.WriteByte(path[i])
+				}
+			case quote:
+				if i+1 >= len(path) || path[i+1] != ']' {
+					return bracketSegment{}, fmt.Errorf("field path %q: expected ] at offset %d", path, i+1)
+				}
+				return bracketSegment{segment: transformPathSegment{key: key.String()}, end: i + 2}, nil
+			default:
+				key.WriteByte(path[i])
+			}
+		}
+		return bracketSegment{}, fmt.Errorf("field path %q: unterminated quote at offset %d", path, start+1)
+	}
+
+	end := start + strings.IndexByte(path[start:], ']')
+	index, err := strconv.Atoi(path[i:end])
+	if err != nil || index < 0 || path[i] == '+' {
+		return bracketSegment{}, fmt.Errorf("field path %q: invalid array index %q", path, path[i:end])
+	}
+	return bracketSegment{segment: transformPathSegment{index: index, isIndex: true}, end: end + 1}, nil
+}
+
+// getTransformPath reads the value at a path. A top-level field named by
+// the whole path is read as is, so fields with dots in their names keep
+// working.
+func getTransformPath(data map[string]interface{}, path string) (interface{}, bool, error) {
+	if value, exists := data[path]; exists || path == "" {
+		return value, exists, nil
+	}
+	segments, err := parseTransformPath(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var current interface{} = data
+	for _, segment := range segments {
+		if segment.isIndex {
+			list, ok := current.([]interface{})
+			if !ok || segment.index >= len(list) {
+				return nil, false, nil
+			}
+			current = list[segment.index]
+			continue
+		}
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false, nil
+		}
+		if current, ok = object[segment.key]; !ok {
+			return nil, false, nil
+		}
+	}
+	return current, true, nil
+}
+
+// setTransformPath writes a value at a path, creating intermediate objects
+// for keys and arrays for indexes. Nested objects and arrays are copied
+// before they are changed, since they may be shared with the input.
+func setTransformPath(data map[string]interface{}, path string, value interface{}) error {
+	segments, err := parseTransformPath(path)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if segment.isIndex && segment.index > maxTransformPathIndex {
+			return fmt.Errorf("field path %q: array index %d is above %d", path, segment.index, maxTransformPathIndex)
+		}
+	}
+
+	key := segments[0].key
+	if len(segments) == 1 {
+		data[key] = value
+		return nil
+	}
+	updated, err := setTransformPathValue(data[key], segments[1:], value, key)
+	if err != nil {
+		return fmt.Errorf("field path %q: %w", path, err)
+	}
+	data[key] = updated
+	return nil
+}
+
+// setTransformPathValue returns a copy of container with value set at the
+// remaining segments; parent names the container in errors
+func setTransformPathValue(container interface{}, segments []transformPathSegment, value interface{}, parent string) (interface{}, error) {
+	segment := segments[0]
+
+	if segment.isIndex {
+		var list []interface{}
+		switch existing := container.(type) {
+		case nil:
+		case []interface{}:
+			list = existing
+		default:
+			return nil, fmt.Errorf("%s is %s, not an array", parent, jsonTypeOf(container))
+		}
+		size := len(list)
+		if segment.index >= size {
+			size = segment.index + 1
+		}
+		updated := make([]interface{}, size)
+		copy(updated, list)
+		if len(segments) == 1 {
+			updated[segment.index] = value
+			return updated, nil
+		}
+		nested, err := setTransformPathValue(updated[segment.index], segments[1:], value, parent+segment.String())
+		if err != nil {
+			return nil, err
+		}
+		updated[segment.index] = nested
+		return updated, nil
+	}
+
+	var object map[string]interface{}
+	switch existing := container.(type) {
+	case nil:
+	case map[string]interface{}:
+		object = existing
+	default:
+		return nil, fmt.Errorf("%s is %s, not an object", parent, jsonTypeOf(container))
+	}
+	updated := make(map[string]interface{}, len(object)+1)
+	for key, field := range object {
+		updated[key] = field
+	}
+	if len(segments) == 1 {
+		updated[segment.key] = value
+		return updated, nil
+	}
+	nested, err := setTransformPathValue(updated[segment.key], segments[1:], value, parent+"."+segment.key)
+	if err != nil {
+		return nil, err
+	}
+	updated[segment.key] = nested
+	return updated, nil
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+var transformationPipelineNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// TransformationPipeline is a named, reusable set of transformation rules
+// with the schemas they map between, e.g. a university SIS export mapped to
+// the canonical student record. Requests and DP providers refer to it by
+// name rather than sending the rules each time.
+type TransformationPipeline struct {
+	Name            string                `json:"name"`
+	Description     string                `json:"description,omitempty"`
+	SourceSchema    TransformationSchema  `json:"sourceSchema,omitempty"`
+	TargetSchema    TransformationSchema  `json:"targetSchema"`
+	Transformations []TransformationRule  `json:"transformations"`
+	Options         TransformationOptions `json:"options,omitempty"`
+	// Enrichments add looked-up fields to the output after the rules run
+	Enrichments []EnrichmentRule `json:"enrichments,omitempty"`
+	// Version counts the pipeline's updates, starting at 1
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TransformationPipelinesFile is the on-disk format of the pipelines
+// registered at startup
+type TransformationPipelinesFile struct {
+	Pipelines []*TransformationPipeline `json:"pipelines"`
+}
+
+// TransformationPipelineRegistry keeps transformation pipelines by name
+type TransformationPipelineRegistry struct {
+	mu        sync.RWMutex
+	pipelines map[string]*TransformationPipeline
+	now       func() time.Time
+}
+
+// NewTransformationPipelineRegistry creates an empty pipeline registry
+func NewTransformationPipelineRegistry() *TransformationPipelineRegistry {
+	return &TransformationPipelineRegistry{
+		pipelines: make(map[string]*TransformationPipeline),
+		now:       time.Now,
+	}
+}
+
+// Register adds a pipeline or replaces the one with its name, returning the
+// stored pipeline
+func (r *TransformationPipelineRegistry) Register(pipeline *TransformationPipeline) (*TransformationPipeline, error) {
+	if err := validateTransformationPipeline(pipeline); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *pipeline
+	stored.Transformations = append([]TransformationRule(nil), pipeline.Transformations...)
+	stored.Enrichments = append([]EnrichmentRule(nil), pipeline.Enrichments...)
+	stored.Version = 1
+	if previous, exists := r.pipelines[pipeline.Name]; exists {
+		stored.Version = previous.Version + 1
+	}
+	stored.UpdatedAt = r.now()
+	r.pipelines[pipeline.Name] = &stored
+
+	registered := stored
+	return &registered, nil
+}
+
+func validateTransformationPipeline(pipeline *TransformationPipeline) error {
+	if !transformationPipelineNamePattern.MatchString(pipeline.Name) {
+		return fmt.Errorf("pipeline name %q must use letters, digits, '.', '_' or '-'", pipeline.Name)
+	}
+	if len(pipeline.Transformations) == 0 && pipeline.TargetSchema.Type == "" && len(pipeline.Enrichments) == 0 {
+		return fmt.Errorf("pipeline %s: transformations, enrichments or a targetSchema are required", pipeline.Name)
+	}
+	if len(pipeline.Options.CustomTransformers) > 0 {
+		return fmt.Errorf("pipeline %s: custom transformers cannot be stored in a pipeline", pipeline.Name)
+	}
+	for i, rule := range pipeline.Transformations {
+		target := rule.TargetField
+		if target == "" {
+			target = rule.SourceField
+		}
+		if _, err := parseTransformPath(target); err != nil {
+			return fmt.Errorf("pipeline %s: transformation %d: %w", pipeline.Name, i, err)
+		}
+	}
+	for i, rule := range pipeline.Enrichments {
+		if err := validateEnrichmentRule(rule); err != nil {
+			return fmt.Errorf("pipeline %s: enrichment %d: %w", pipeline.Name, i, err)
+		}
+	}
+	return nil
+}
+
+// Get returns a pipeline by name
+func (r *TransformationPipelineRegistry) Get(name string) (*TransformationPipeline, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	pipeline, exists := r.pipelines[name]
+	if !exists {
+		return nil, false
+	}
+	found := *pipeline
+	return &found, true
+}
+
+// List returns the pipelines ordered by name
+func (r *TransformationPipelineRegistry) List() []*TransformationPipeline {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	pipelines := make([]*TransformationPipeline, 0, len(r.pipelines))
+	for _, name := range sortedMapKeys(r.pipelines) {
+		pipeline := *r.pipelines[name]
+		pipelines = append(pipelines, &pipeline)
+	}
+	return pipelines
+}
+
+// Delete removes a pipeline
+func (r *TransformationPipelineRegistry) Delete(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.pipelines[name]; !exists {
+		return fmt.Errorf("pipeline not found: %s", name)
+	}
+	delete(r.pipelines, name)
+	return nil
+}
+
+// LoadFile registers the pipelines of a pipelines file. Nothing is
+// registered unless every pipeline is valid.
+func (r *TransformationPipelineRegistry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read transformation pipelines file: %w", err)
+	}
+	var file TransformationPipelinesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse transformation pipelines file: %w", err)
+	}
+
+	names := make([]string, 0, len(file.Pipelines))
+	for _, pipeline := range file.Pipelines {
+		if err := validateTransformationPipeline(pipeline); err != nil {
+			return err
+		}
+		names = append(names, pipeline.Name)
+	}
+	sort.Strings(names)
+	for i := 1; i < len(names); i++ {
+		if names[i] == names[i-1] {
+			return fmt.Errorf("pipeline %s is defined twice", names[i])
+		}
+	}
+	for _, pipeline := range file.Pipelines {
+		if _, err := r.Register(pipeline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TransformationPipelines returns the named pipelines DP responses may be
+// mapped with
+func (s *DPConnectorService) TransformationPipelines() *TransformationPipelineRegistry {
+	return s.transformer.config.Pipelines
+}
+
+// applyResponsePipeline maps a provider's response body with its response
+// pipeline into the broker's DP response shape. Bodies of providers without
+// a pipeline are returned unchanged.
+func (s *DPConnectorService) applyResponsePipeline(provider *DPProvider, body []byte) ([]byte, error) {
+	if provider == nil || provider.ResponsePipeline == "" {
+		return body, nil
+	}
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode DP response: %w", err)
+	}
+
+	result := s.transformer.TransformData(TransformationRequest{Data: data, Pipeline: provider.ResponsePipeline})
+	if !result.Success {
+		first := result.Errors[0]
+		return nil, fmt.Errorf("DP response pipeline %s failed at %s: %s", provider.ResponsePipeline, first.Field, first.Message)
+	}
+	return json.Marshal(result.Data)
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// sisToDPResponse maps a university SIS record to the DP response shape
+func sisToDPResponse() *TransformationPipeline {
+	return &TransformationPipeline{
+		Name:        "university_sis_v2.dp_response",
+		Description: "University SIS v2 enrollment records",
+		Transformations: []TransformationRule{
+			{SourceField: "request_ref", TargetField: "job_id", Transformation: "copy"},
+			{SourceField: "enrollment.state", TargetField: "status", Transformation: "expression",
+				Parameters: map[string]interface{}{"expression": `value == "ACTIVE" ? "completed" : "failed"`}},
+			{SourceField: "enrollment.active", TargetField: "verification_result.verified", Transformation: "boolean"},
+			{SourceField: "match_score", TargetField: "verification_result.confidence", Transformation: "number"},
+			{SourceField: "checked_at", TargetField: "timestamp", Transformation: "copy"},
+		},
+	}
+}
+
+func TestTransformationPipelineRegistry_Register(t *testing.T) {
+	registry := NewTransformationPipelineRegistry()
+	registered, err := registry.Register(sisToDPResponse())
+	if err != nil {
+		t.Fatalf("Expected the pipeline to register, got %v", err)
+	}
+	if registered.Version != 1 || registered.UpdatedAt.IsZero() {
+		t.Errorf("Expected version 1, got %+v", registered)
+	}
+	if replaced, _ := registry.Register(sisToDPResponse()); replaced.Version != 2 {
+		t.Errorf("Expected a replaced pipeline to be version 2, got %d", replaced.Version)
+	}
+
+	invalid := map[string]*TransformationPipeline{
+		"name":         {Name: "sis v2", Transformations: sisToDPResponse().Transformations},
+		"empty":        {Name: "empty"},
+		"target path":  {Name: "bad_path", Transformations: []TransformationRule{{SourceField: "a", TargetField: "b..c"}}},
+		"custom funcs": {Name: "custom", Transformations: sisToDPResponse().Transformations, Options: TransformationOptions{CustomTransformers: map[string]TransformFunction{"x": {}}}},
+	}
+	for name, pipeline := range invalid {
+		if _, err := registry.Register(pipeline); err == nil {
+			t.Errorf("%s: expected the pipeline to be rejected", name)
+		}
+	}
+
+	if err := registry.Delete("university_sis_v2.dp_response"); err != nil || len(registry.List()) != 0 {
+		t.Errorf("Expected the pipeline to be deleted, got %v", err)
+	}
+	if err := registry.Delete("university_sis_v2.dp_response"); err == nil {
+		t.Error("Expected deleting an unknown pipeline to fail")
+	}
+}
+
+func TestTransformationPipelineRegistry_LoadFile(t *testing.T) {
+	write := func(pipelines ...*TransformationPipeline) string {
+		path := filepath.Join(t.TempDir(), "pipelines.json")
+		data, _ := json.Marshal(TransformationPipelinesFile{Pipelines: pipelines})
+		os.WriteFile(path, data, 0600)
+		return path
+	}
+
+	registry := NewTransformationPipelineRegistry()
+	if err := registry.LoadFile(write(sisToDPResponse(), sisToDPResponse())); err == nil || !strings.Contains(err.Error(), "defined twice") {
+		t.Errorf("Expected duplicate pipelines to be rejected, got %v", err)
+	}
+	if len(registry.List()) != 0 {
+		t.Error("Expected nothing to be registered from an invalid file")
+	}
+	if err := registry.LoadFile(write(sisToDPResponse())); err != nil {
+		t.Fatalf("Expected the file to load, got %v", err)
+	}
+	if _, exists := registry.Get("university_sis_v2.dp_response"); !exists {
+		t.Error("Expected the pipeline to be registered")
+	}
+}
+
+func TestDataTransformer_NamedPipeline(t *testing.T) {
+	pipelines := NewTransformationPipelineRegistry()
+	pipelines.Register(&TransformationPipeline{
+		Name: "sis_to_canonical_student",
+		Transformations: []TransformationRule{
+			{SourceField: "stu_nm", TargetField: "student.name", Transformation: "uppercase"},
+		},
+	})
+	transformer := NewDataTransformer(DataTransformerConfig{Pipelines: pipelines})
+
+	response := transformer.TransformData(TransformationRequest{
+		Data:     map[string]interface{}{"stu_nm": "ada"},
+		Pipeline: "sis_to_canonical_student",
+	})
+	student, _ := response.Data.(map[string]interface{})["student"].(map[string]interface{})
+	if !response.Success || student["name"] != "ADA" {
+		t.Errorf("Expected the pipeline's rules to apply, got %+v", response)
+	}
+
+	response = transformer.TransformData(TransformationRequest{Data: map[string]interface{}{}, Pipeline: "missing"})
+	if response.Success || response.Errors[0].Code != "UNKNOWN_PIPELINE" {
+		t.Errorf("Expected an unknown pipeline error, got %+v", response.Errors)
+	}
+	response = transformer.TransformData(TransformationRequest{
+		Data:            map[string]interface{}{},
+		Pipeline:        "sis_to_canonical_student",
+		Transformations: []TransformationRule{{SourceField: "a", Transformation: "copy"}},
+	})
+	if response.Success || response.Errors[0].Code != "PIPELINE_CONFLICT" {
+		t.Errorf("Expected a pipeline conflict, got %+v", response.Errors)
+	}
+}
+
+func TestDPConnectorService_ResponsePipeline(t *testing.T) {
+	dp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"request_ref": "ref-1",
+			"enrollment":  map[string]interface{}{"state": "ACTIVE", "active": "true"},
+			"match_score": "0.93",
+			"checked_at":  "2026-01-01T00:00:00Z",
+		})
+	}))
+	defer dp.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: dp.URL, DPTimeout: 5 * time.Second})
+	service.registry = NewDPRegistry()
+	service.registry.Register(&DPProvider{DPID: "sis", Endpoint: dp.URL, SupportedClaims: []string{"student_verification"}, ResponsePipeline: "university_sis_v2.dp_response"})
+	verify := func() (*DPResponse, error) {
+		return service.VerifyWithDP(context.Background(), &models.PrivacyRequest{ClaimType: "student_verification"})
+	}
+
+	if _, err := verify(); err == nil || !strings.Contains(err.Error(), "unknown pipeline") {
+		t.Errorf("Expected an unregistered pipeline to fail the response, got %v", err)
+	}
+
+	if _, err := service.TransformationPipelines().Register(sisToDPResponse()); err != nil {
+		t.Fatal(err)
+	}
+	response, err := verify()
+	if err != nil {
+		t.Fatalf("Expected the mapped response to be accepted, got %v", err)
+	}
+	result := response.VerificationResult
+	if response.JobID != "ref-1" || response.Status != DPStatusCompleted || result == nil || !result.Verified || result.Confidence != 0.93 {
+		t.Errorf("Expected the SIS record mapped to a DP response, got %+v (%+v)", response, result)
+	}
+}
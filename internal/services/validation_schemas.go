@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of validation schema kept for a claim type
+const (
+	SchemaKindRequest  = "request"
+	SchemaKindResponse = "response"
+)
+
+// LatestSchemaVersion selects a schema's newest version in a reference
+const LatestSchemaVersion = "latest"
+
+var validationSchemaNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// PublishedSchema is one immutable version of a named validation schema
+type PublishedSchema struct {
+	Name        string            `json:"name"`
+	Version     int               `json:"version"`
+	ClaimType   string            `json:"claim_type"`
+	Kind        string            `json:"kind"`
+	Description string            `json:"description,omitempty"`
+	Schema      *ValidationSchema `json:"schema"`
+	PublishedAt time.Time         `json:"published_at"`
+}
+
+// Ref returns the schema's name@version reference
+func (s *PublishedSchema) Ref() string {
+	return fmt.Sprintf("%s@%d", s.Name, s.Version)
+}
+
+// ValidationSchemasFile is the on-disk format of schemas published at
+// startup; the versions of each name are published in file order
+type ValidationSchemasFile struct {
+	Schemas []*PublishedSchema `json:"schemas"`
+}
+
+// ValidationSchemaRegistry keeps the request and response schemas of claim
+// types as named, versioned documents. Each claim type has at most one
+// schema name per kind, and a published version is never changed.
+type ValidationSchemaRegistry struct {
+	mu       sync.RWMutex
+	versions map[string][]*PublishedSchema
+	now      func() time.Time
+}
+
+// NewValidationSchemaRegistry creates an empty schema registry
+func NewValidationSchemaRegistry() *ValidationSchemaRegistry {
+	return &ValidationSchemaRegistry{
+		versions: make(map[string][]*PublishedSchema),
+		now:      time.Now,
+	}
+}
+
+// Publish adds a new version of a schema, numbered after its latest one. The
+// first version binds the name to its claim type and kind.
+func (r *ValidationSchemaRegistry) Publish(schema *PublishedSchema) (*PublishedSchema, error) {
+	if !validationSchemaNamePattern.MatchString(schema.Name) {
+		return nil, fmt.Errorf("schema name %q must use letters, digits, '.', '_' or '-'", schema.Name)
+	}
+	if schema.ClaimType == "" {
+		return nil, fmt.Errorf("schema %s: claim_type is required", schema.Name)
+	}
+	if schema.Kind != SchemaKindRequest && schema.Kind != SchemaKindResponse {
+		return nil, fmt.Errorf("schema %s: kind must be %q or %q", schema.Name, SchemaKindRequest, SchemaKindResponse)
+	}
+	if schema.Schema == nil {
+		return nil, fmt.Errorf("schema %s: schema is required", schema.Name)
+	}
+	if err := newSchemaScope(schema.Schema).check(schema.Schema, "#"); err != nil {
+		return nil, fmt.Errorf("schema %s: %w", schema.Name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	versions := r.versions[schema.Name]
+	if len(versions) > 0 {
+		latest := versions[len(versions)-1]
+		if latest.ClaimType != schema.ClaimType || latest.Kind != schema.Kind {
+			return nil, fmt.Errorf("schema %s is the %s schema of %s", schema.Name, latest.Kind, latest.ClaimType)
+		}
+	} else if existing := r.nameForLocked(schema.ClaimType, schema.Kind); existing != "" {
+		return nil, fmt.Errorf("claim type %s already has %s schema %s", schema.ClaimType, schema.Kind, existing)
+	}
+
+	published := &PublishedSchema{
+		Name:        schema.Name,
+		Version:     len(versions) + 1,
+		ClaimType:   schema.ClaimType,
+		Kind:        schema.Kind,
+		Description: schema.Description,
+		Schema:      schema.Schema,
+		PublishedAt: r.now(),
+	}
+	r.versions[schema.Name] = append(versions, published)
+	return published, nil
+}
+
+// LoadFile publishes the schemas of a validation schemas file
+func (r *ValidationSchemaRegistry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read validation schemas file: %w", err)
+	}
+
+	var file ValidationSchemasFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse validation schemas file: %w", err)
+	}
+
+	for _, schema := range file.Schemas {
+		if _, err := r.Publish(schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the schema a reference names: name@version, or name or
+// name@latest for the newest version
+func (r *ValidationSchemaRegistry) Get(ref string) (*PublishedSchema, error) {
+	name, version, _ := strings.Cut(ref, "@")
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	versions := r.versions[name]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("unknown schema: %s", name)
+	}
+	if version == "" || version == LatestSchemaVersion {
+		return versions[len(versions)-1], nil
+	}
+
+	number, err := strconv.Atoi(version)
+	if err != nil || number < 1 {
+		return nil, fmt.Errorf("invalid schema version %q", version)
+	}
+	if number > len(versions) {
+		return nil, fmt.Errorf("schema %s has no version %d", name, number)
+	}
+	return versions[number-1], nil
+}
+
+// Versions returns every version of a schema, oldest first
+func (r *ValidationSchemaRegistry) Versions(name string) []*PublishedSchema {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*PublishedSchema(nil), r.versions[name]...)
+}
+
+// List returns the latest version of each schema ordered by name
+func (r *ValidationSchemaRegistry) List() []*PublishedSchema {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	schemas := make([]*PublishedSchema, 0, len(r.versions))
+	for _, name := range sortedMapKeys(r.versions) {
+		versions := r.versions[name]
+		schemas = append(schemas, versions[len(versions)-1])
+	}
+	return schemas
+}
+
+// ForClaim returns the latest schema of a kind for a claim type
+func (r *ValidationSchemaRegistry) ForClaim(claimType, kind string) (*PublishedSchema, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name := r.nameForLocked(claimType, kind)
+	if name == "" {
+		return nil, false
+	}
+	versions := r.versions[name]
+	return versions[len(versions)-1], true
+}
+
+func (r *ValidationSchemaRegistry) nameForLocked(claimType, kind string) string {
+	for name, versions := range r.versions {
+		if versions[0].ClaimType == claimType && versions[0].Kind == kind {
+			return name
+		}
+	}
+	return ""
+}
+
+// DPResponseValidationError reports a DP response rejected by the response
+// schema registered for its claim type
+type DPResponseValidationError struct {
+	SchemaRef string
+	Errors    []ValidationError
+}
+
+func (e *DPResponseValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, validationErr := range e.Errors {
+		messages = append(messages, fmt.Sprintf("%s: %s", validationErr.Field, validationErr.Message))
+	}
+	sort.Strings(messages)
+	return fmt.Sprintf("DP response does not match schema %s: %s", e.SchemaRef, strings.Join(messages, "; "))
+}
+
+// ValidateDPResponse validates a DP response against the latest response
+// schema published for its claim type. It returns nil when the claim type
+// has no response schema.
+func (dv *DataValidator) ValidateDPResponse(claimType string, response *DPResponse) error {
+	if dv.config.Schemas == nil {
+		return nil
+	}
+	schema, exists := dv.config.Schemas.ForClaim(claimType, SchemaKindResponse)
+	if !exists {
+		return nil
+	}
+
+	// Schemas describe the response as JSON
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to encode DP response: %w", err)
+	}
+	var data interface{}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return fmt.Errorf("failed to decode DP response: %w", err)
+	}
+
+	result := dv.ValidateData(ValidationRequest{Data: data, Schema: *schema.Schema})
+	if !result.Valid {
+		return &DPResponseValidationError{SchemaRef: schema.Ref(), Errors: result.Errors}
+	}
+	return nil
+}
+
+// ValidationSchemas returns the versioned schemas DP responses are
+// validated against
+func (s *DPConnectorService) ValidationSchemas() *ValidationSchemaRegistry {
+	return s.dataValidator.config.Schemas
+}
+
+// validateResponse checks a provider's response against the response schema
+// of the claim type being verified
+func (s *DPConnectorService) validateResponse(ctx context.Context, response *DPResponse) error {
+	scope, _ := ctx.Value(exchangeScopeKey{}).(exchangeScope)
+	if scope.claimType == "" {
+		return nil
+	}
+	return s.dataValidator.ValidateDPResponse(scope.claimType, response)
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// confidenceSchema requires a verification result whose confidence is at
+// least minimum
+func confidenceSchema(t *testing.T, minimum string) *ValidationSchema {
+	t.Helper()
+	var schema ValidationSchema
+	document := `{
+		"type": "object",
+		"required": ["verification_result"],
+		"properties": {"verification_result": {
+			"type": "object",
+			"required": ["confidence"],
+			"properties": {"confidence": {"type": "number", "minimum": ` + minimum + `}}
+		}}
+	}`
+	if err := json.Unmarshal([]byte(document), &schema); err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+	return &schema
+}
+
+func TestValidationSchemaRegistry_Versions(t *testing.T) {
+	registry := NewValidationSchemaRegistry()
+	first, err := registry.Publish(&PublishedSchema{Name: "age_response", ClaimType: "age_verification", Kind: SchemaKindResponse, Schema: confidenceSchema(t, "0")})
+	if err != nil || first.Version != 1 {
+		t.Fatalf("Expected version 1, got %+v, %v", first, err)
+	}
+	second, err := registry.Publish(&PublishedSchema{Name: "age_response", ClaimType: "age_verification", Kind: SchemaKindResponse, Schema: confidenceSchema(t, "0.5")})
+	if err != nil || second.Ref() != "age_response@2" {
+		t.Fatalf("Expected version 2, got %+v, %v", second, err)
+	}
+
+	for ref, expected := range map[string]int{"age_response@1": 1, "age_response": 2, "age_response@latest": 2} {
+		schema, err := registry.Get(ref)
+		if err != nil || schema.Version != expected {
+			t.Errorf("Expected %s to resolve to version %d, got %+v, %v", ref, expected, schema, err)
+		}
+	}
+	for _, ref := range []string{"age_response@3", "age_response@v1", "unknown@1"} {
+		if _, err := registry.Get(ref); err == nil {
+			t.Errorf("Expected %s not to resolve", ref)
+		}
+	}
+
+	if latest, _ := registry.ForClaim("age_verification", SchemaKindResponse); latest.Version != 2 {
+		t.Errorf("Expected the latest version used for the claim type, got %d", latest.Version)
+	}
+	if len(registry.Versions("age_response")) != 2 || len(registry.List()) != 1 {
+		t.Errorf("Expected two versions of one schema")
+	}
+}
+
+func TestValidationSchemaRegistry_RejectsInvalid(t *testing.T) {
+	registry := NewValidationSchemaRegistry()
+	registry.Publish(&PublishedSchema{Name: "age_response", ClaimType: "age_verification", Kind: SchemaKindResponse, Schema: confidenceSchema(t, "0")})
+
+	invalid := []*PublishedSchema{
+		{Name: "bad name", ClaimType: "age_verification", Kind: SchemaKindRequest, Schema: confidenceSchema(t, "0")},
+		{Name: "age_request", ClaimType: "age_verification", Kind: "reply", Schema: confidenceSchema(t, "0")},
+		{Name: "age_request", ClaimType: "age_verification", Kind: SchemaKindRequest},
+		// A name stays bound to its claim type and kind
+		{Name: "age_response", ClaimType: "student_verification", Kind: SchemaKindResponse, Schema: confidenceSchema(t, "0")},
+		// A claim type has one schema name per kind
+		{Name: "age_response_v2", ClaimType: "age_verification", Kind: SchemaKindResponse, Schema: confidenceSchema(t, "0")},
+	}
+	for _, schema := range invalid {
+		if _, err := registry.Publish(schema); err == nil {
+			t.Errorf("Expected %+v to be rejected", schema)
+		}
+	}
+}
+
+func TestDPConnectorService_ValidatesResponsesAgainstSchema(t *testing.T) {
+	respond := func(confidence float64) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(DPResponse{
+				JobID:              "job_1",
+				Status:             "completed",
+				VerificationResult: &VerificationResult{Verified: true, Confidence: confidence},
+				Timestamp:          "2025-08-02T07:00:00Z",
+			})
+		}))
+	}
+	weak, strong := respond(0.2), respond(0.9)
+	defer weak.Close()
+	defer strong.Close()
+
+	service := NewDPConnectorService(&config.Config{DPConnectorURL: "http://localhost:1"})
+	service.retryConfig.MaxRetries = 0
+	service.registry = NewDPRegistry()
+	service.registry.Register(&DPProvider{DPID: "dp_weak", Endpoint: weak.URL, SupportedClaims: []string{"age_verification"}, Priority: 0})
+	service.registry.Register(&DPProvider{DPID: "dp_strong", Endpoint: strong.URL, SupportedClaims: []string{"age_verification"}, Priority: 1})
+	request := &models.PrivacyRequest{RPID: "rp_1", ClaimType: "age_verification"}
+
+	if response, err := service.VerifyWithDP(context.Background(), request); err != nil || response.DPID != "dp_weak" {
+		t.Fatalf("Expected responses accepted without a schema, got %v, %v", response, err)
+	}
+
+	service.ValidationSchemas().Publish(&PublishedSchema{Name: "age_response", ClaimType: "age_verification", Kind: SchemaKindResponse, Schema: confidenceSchema(t, "0.5")})
+	response, err := service.VerifyWithDP(context.Background(), request)
+	if err != nil || response.DPID != "dp_strong" {
+		t.Fatalf("Expected the non-conforming response to fail over, got %v, %v", response, err)
+	}
+
+	service.registry.Remove("dp_strong")
+	_, err = service.VerifyWithDP(context.Background(), request)
+	var validationErr *DPResponseValidationError
+	if !errors.As(err, &validationErr) || validationErr.SchemaRef != "age_response@1" || !strings.Contains(err.Error(), "verification_result.confidence") {
+		t.Errorf("Expected a schema validation error, got %v", err)
+	}
+}
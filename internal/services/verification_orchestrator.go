@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// VerificationState carries a request through the stages of a verification.
+// Stages may set Response for later stages to annotate; a stage calling
+// Finish completes the verification, and the stages after it are skipped.
+type VerificationState struct {
+	Request  *models.VerificationRequest
+	Response *models.VerificationResponse
+
+	mu       sync.Mutex
+	values   map[string]interface{}
+	finished bool
+}
+
+// NewVerificationState creates the state of a verification of a request
+func NewVerificationState(req *models.VerificationRequest) *VerificationState {
+	return &VerificationState{Request: req, values: make(map[string]interface{})}
+}
+
+// Set stores a value for later stages
+func (s *VerificationState) Set(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+}
+
+// Get returns a value stored by an earlier stage
+func (s *VerificationState) Get(key string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, exists := s.values[key]
+	return value, exists
+}
+
+// Finish completes the verification with a response
+func (s *VerificationState) Finish(response *models.VerificationResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Response = response
+	s.finished = true
+}
+
+// Finished reports whether a stage completed the verification
+func (s *VerificationState) Finished() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.finished
+}
+
+// StageError is a stage failure as reported to the caller
+type StageError struct {
+	Stage      string
+	Code       string
+	Message    string
+	StatusCode int
+	Details    map[string]interface{}
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Stage, e.Code, e.Message)
+}
+
+// Problem returns the failure as the problem reported to the caller
+func (e *StageError) Problem() *models.Problem {
+	problem := models.NewProblem(e.Code, e.Message, e.StatusCode)
+	problem.Details = e.Details
+	return problem
+}
+
+// VerificationStage is one step of a verification
+type VerificationStage struct {
+	Name string
+	Run  func(ctx context.Context, state *VerificationState) error
+	// Timeout bounds the stage; a stage running over fails with
+	// STAGE_TIMEOUT (504)
+	Timeout time.Duration
+	// ErrorCode and ErrorStatus report errors other than a StageError,
+	// STAGE_FAILED (500) by default
+	ErrorCode   string
+	ErrorStatus int
+}
+
+// BeforeStageHook runs before each stage; an error fails the stage without
+// running it
+type BeforeStageHook func(ctx context.Context, stage string, state *VerificationState) error
+
+// AfterStageHook runs after each stage with its error, if any
+type AfterStageHook func(ctx context.Context, stage string, state *VerificationState, err *StageError)
+
+// VerificationOrchestrator runs verifications through an ordered list of
+// named stages. Stages are inserted relative to existing ones, so checks
+// such as consent or proof generation can be added without changing the
+// stages around them. Each stage is traced under its name.
+type VerificationOrchestrator struct {
+	mu     sync.RWMutex
+	stages []VerificationStage
+	before []BeforeStageHook
+	after  []AfterStageHook
+}
+
+// NewVerificationOrchestrator creates an orchestrator running the stages in
+// order
+func NewVerificationOrchestrator(stages ...VerificationStage) (*VerificationOrchestrator, error) {
+	o := &VerificationOrchestrator{}
+	for _, stage := range stages {
+		if err := o.insert(len(o.stages), stage); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// Append adds a stage after the last one
+func (o *VerificationOrchestrator) Append(stage VerificationStage) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.insert(len(o.stages), stage)
+}
+
+// InsertBefore adds a stage before the named one
+func (o *VerificationOrchestrator) InsertBefore(name string, stage VerificationStage) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	index, err := o.indexLocked(name)
+	if err != nil {
+		return err
+	}
+	return o.insert(index, stage)
+}
+
+// InsertAfter adds a stage after the named one
+func (o *VerificationOrchestrator) InsertAfter(name string, stage VerificationStage) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	index, err := o.indexLocked(name)
+	if err != nil {
+		return err
+	}
+	return o.insert(index+1, stage)
+}
+
+// Remove removes the named stage
+func (o *VerificationOrchestrator) Remove(name string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	index, err := o.indexLocked(name)
+	if err != nil {
+		return err
+	}
+	o.stages = append(o.stages[:index:index], o.stages[index+1:]...)
+	return nil
+}
+
+// SetTimeout changes the named stage's timeout; zero removes it
+func (o *VerificationOrchestrator) SetTimeout(name string, timeout time.Duration) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	index, err := o.indexLocked(name)
+	if err != nil {
+		return err
+	}
+	o.stages[index].Timeout = timeout
+	return nil
+}
+
+// ApplyTimeouts sets stage timeouts from stage=duration entries
+func (o *VerificationOrchestrator) ApplyTimeouts(entries []string) error {
+	for _, entry := range entries {
+		name, value, found := strings.Cut(entry, "=")
+		if !found {
+			return fmt.Errorf("stage timeout %q must be stage=duration", entry)
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("stage %s: invalid timeout %q", name, value)
+		}
+		if err := o.SetTimeout(name, timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stages returns the stages in the order they run
+func (o *VerificationOrchestrator) Stages() []VerificationStage {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return append([]VerificationStage(nil), o.stages...)
+}
+
+// OnBeforeStage registers a hook run before each stage
+func (o *VerificationOrchestrator) OnBeforeStage(hook BeforeStageHook) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.before = append(o.before, hook)
+}
+
+// OnAfterStage registers a hook run after each stage
+func (o *VerificationOrchestrator) OnAfterStage(hook AfterStageHook) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.after = append(o.after, hook)
+}
+
+func (o *VerificationOrchestrator) insert(index int, stage VerificationStage) error {
+	if stage.Name == "" || stage.Run == nil {
+		return fmt.Errorf("verification stages need a name and a function")
+	}
+	if _, err := o.indexLocked(stage.Name); err == nil {
+		return fmt.Errorf("verification stage %s already exists", stage.Name)
+	}
+	o.stages = append(o.stages, VerificationStage{})
+	copy(o.stages[index+1:], o.stages[index:])
+	o.stages[index] = stage
+	return nil
+}
+
+func (o *VerificationOrchestrator) indexLocked(name string) (int, error) {
+	for i, stage := range o.stages {
+		if stage.Name == name {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("verification stage not found: %s", name)
+}
+
+// Run runs the stages in order until one fails or finishes the verification
+func (o *VerificationOrchestrator) Run(ctx context.Context, state *VerificationState) *StageError {
+	o.mu.RLock()
+	stages := append([]VerificationStage(nil), o.stages...)
+	before := append([]BeforeStageHook(nil), o.before...)
+	after := append([]AfterStageHook(nil), o.after...)
+	o.mu.RUnlock()
+
+	trace := VerificationTraceFrom(ctx)
+	for _, stage := range stages {
+		trace.Begin(stage.Name)
+		stageErr := o.runStage(ctx, stage, state, before)
+		for _, hook := range after {
+			hook(ctx, stage.Name, state, stageErr)
+		}
+		if stageErr != nil {
+			return stageErr
+		}
+		if state.Finished() {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (o *VerificationOrchestrator) runStage(ctx context.Context, stage VerificationStage, state *VerificationState, before []BeforeStageHook) *StageError {
+	for _, hook := range before {
+		if err := hook(ctx, stage.Name, state); err != nil {
+			return stageError(stage, err, false)
+		}
+	}
+
+	stageCtx := ctx
+	if stage.Timeout > 0 {
+		var cancel context.CancelFunc
+		stageCtx, cancel = context.WithTimeout(ctx, stage.Timeout)
+		defer cancel()
+	}
+	err := stage.Run(stageCtx, state)
+	if err == nil {
+		return nil
+	}
+	// The stage's own deadline passed, not the caller's
+	timedOut := stage.Timeout > 0 && errors.Is(stageCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+	return stageError(stage, err, timedOut)
+}
+
+// stageError maps a stage's error to the error reported to the caller
+func stageError(stage VerificationStage, err error, timedOut bool) *StageError {
+	var reported *StageError
+	if errors.As(err, &reported) {
+		mapped := *reported
+		if mapped.Stage == "" {
+			mapped.Stage = stage.Name
+		}
+		return &mapped
+	}
+	if timedOut {
+		return &StageError{
+			Stage:      stage.Name,
+			Code:       "STAGE_TIMEOUT",
+			Message:    fmt.Sprintf("Verification stage %s timed out", stage.Name),
+			StatusCode: http.StatusGatewayTimeout,
+		}
+	}
+
+	mapped := &StageError{
+		Stage:      stage.Name,
+		Code:       stage.ErrorCode,
+		Message:    fmt.Sprintf("Verification stage %s failed", stage.Name),
+		StatusCode: stage.ErrorStatus,
+	}
+	if mapped.Code == "" {
+		mapped.Code = "STAGE_FAILED"
+	}
+	if mapped.StatusCode == 0 {
+		mapped.StatusCode = http.StatusInternalServerError
+	}
+	return mapped
+}
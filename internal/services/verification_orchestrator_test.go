@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func recordingStage(name string, ran *[]string) VerificationStage {
+	return VerificationStage{Name: name, Run: func(ctx context.Context, state *VerificationState) error {
+		*ran = append(*ran, name)
+		return nil
+	}}
+}
+
+func TestVerificationOrchestrator_Stages(t *testing.T) {
+	var ran []string
+	orchestrator, err := NewVerificationOrchestrator(
+		recordingStage("authorization", &ran),
+		recordingStage("dp_job", &ran),
+		recordingStage("response_formatting", &ran),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := orchestrator.InsertBefore("dp_job", recordingStage("consent", &ran)); err != nil {
+		t.Fatal(err)
+	}
+	if err := orchestrator.InsertAfter("response_formatting", recordingStage("zkp_proof", &ran)); err != nil {
+		t.Fatal(err)
+	}
+	if err := orchestrator.InsertAfter("missing", recordingStage("x", &ran)); err == nil {
+		t.Error("Expected inserting next to an unknown stage to fail")
+	}
+	if err := orchestrator.Append(recordingStage("consent", &ran)); err == nil {
+		t.Error("Expected a duplicate stage name to be rejected")
+	}
+
+	trace := NewVerificationTrace("req-1", models.VerificationRequest{})
+	ctx := WithVerificationTrace(context.Background(), trace)
+	if stageErr := orchestrator.Run(ctx, NewVerificationState(&models.VerificationRequest{})); stageErr != nil {
+		t.Fatal(stageErr)
+	}
+	if got := strings.Join(ran, ","); got != "authorization,consent,dp_job,response_formatting,zkp_proof" {
+		t.Errorf("Expected the inserted stages in place, got %s", got)
+	}
+	if len(trace.stages) != 5 || trace.stages[1].Stage != "consent" {
+		t.Errorf("Expected each stage to be traced, got %d stages", len(trace.stages))
+	}
+
+	ran = nil
+	orchestrator.Remove("consent")
+	orchestrator.InsertBefore("dp_job", VerificationStage{Name: "cache", Run: func(ctx context.Context, state *VerificationState) error {
+		state.Finish(&models.VerificationResponse{Status: "verified"})
+		return nil
+	}})
+	state := NewVerificationState(&models.VerificationRequest{})
+	orchestrator.Run(context.Background(), state)
+	if strings.Join(ran, ",") != "authorization" || state.Response == nil || !state.Finished() {
+		t.Errorf("Expected a finished verification to skip the remaining stages, ran %v", ran)
+	}
+}
+
+func TestVerificationOrchestrator_Errors(t *testing.T) {
+	slow := VerificationStage{Name: "privacy", Run: func(ctx context.Context, state *VerificationState) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	orchestrator, _ := NewVerificationOrchestrator(slow)
+	if err := orchestrator.ApplyTimeouts([]string{"privacy=10ms"}); err != nil {
+		t.Fatal(err)
+	}
+	stageErr := orchestrator.Run(context.Background(), NewVerificationState(&models.VerificationRequest{}))
+	if stageErr == nil || stageErr.Code != "STAGE_TIMEOUT" || stageErr.StatusCode != http.StatusGatewayTimeout || stageErr.Stage != "privacy" {
+		t.Errorf("Expected a stage timeout, got %+v", stageErr)
+	}
+	if err := orchestrator.ApplyTimeouts([]string{"missing=1s"}); err == nil {
+		t.Error("Expected a timeout for an unknown stage to be rejected")
+	}
+
+	failing, _ := NewVerificationOrchestrator(
+		VerificationStage{Name: "consent", ErrorCode: "CONSENT_ERROR", ErrorStatus: http.StatusForbidden, Run: func(ctx context.Context, state *VerificationState) error {
+			return errors.New("consent store unreachable")
+		}},
+	)
+	stageErr = failing.Run(context.Background(), NewVerificationState(&models.VerificationRequest{}))
+	if stageErr == nil || stageErr.Code != "CONSENT_ERROR" || stageErr.StatusCode != http.StatusForbidden || strings.Contains(stageErr.Message, "unreachable") {
+		t.Errorf("Expected the stage's error mapping without internal details, got %+v", stageErr)
+	}
+
+	var after []string
+	reported, _ := NewVerificationOrchestrator(VerificationStage{Name: "authorization", Run: func(ctx context.Context, state *VerificationState) error {
+		return &StageError{Code: "AUTHORIZATION_DENIED", Message: "denied", StatusCode: http.StatusForbidden}
+	}})
+	reported.OnAfterStage(func(ctx context.Context, stage string, state *VerificationState, err *StageError) {
+		after = append(after, stage+":"+err.Code)
+	})
+	stageErr = reported.Run(context.Background(), NewVerificationState(&models.VerificationRequest{}))
+	if stageErr == nil || stageErr.Code != "AUTHORIZATION_DENIED" || stageErr.Stage != "authorization" || len(after) != 1 {
+		t.Errorf("Expected a stage's own error and the after hook, got %+v and %v", stageErr, after)
+	}
+
+	ran := false
+	hooked, _ := NewVerificationOrchestrator(VerificationStage{Name: "dp_job", Timeout: time.Second, Run: func(ctx context.Context, state *VerificationState) error {
+		ran = true
+		return nil
+	}})
+	hooked.OnBeforeStage(func(ctx context.Context, stage string, state *VerificationState) error {
+		return &StageError{Code: "CONSENT_REQUIRED", Message: "consent required", StatusCode: http.StatusForbidden}
+	})
+	if stageErr = hooked.Run(context.Background(), NewVerificationState(&models.VerificationRequest{})); ran || stageErr == nil || stageErr.Code != "CONSENT_REQUIRED" {
+		t.Errorf("Expected a before hook to stop the stage, got %+v", stageErr)
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+// VerificationRecord represents a completed verification kept for RP history
+type VerificationRecord struct {
+	VerificationID  string                 `json:"verification_id"`
+	RequestID       string                 `json:"request_id"`
+	RPID            string                 `json:"rp_id"`
+	UserID          string                 `json:"user_id"`
+	ClaimType       string                 `json:"claim_type"`
+	Status          string                 `json:"status"`
+	Verified        bool                   `json:"verified"`
+	ConfidenceScore float64                `json:"confidence_score"`
+	Reason          string                 `json:"reason,omitempty"`
+	Evidence        []string               `json:"evidence,omitempty"`
+	DPID            string                 `json:"dp_id,omitempty"`
+	AuditReference  string                 `json:"audit_reference,omitempty"`
+	IdentifierTypes []string               `json:"identifier_types,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// VerificationRecordQuery filters verification records
+type VerificationRecordQuery struct {
+	RPID      string
+	ClaimType string
+	From      time.Time
+	To        time.Time
+}
+
+// VerificationRecordStore keeps verification records in memory (database in production)
+type VerificationRecordStore struct {
+	mu         sync.RWMutex
+	records    []*VerificationRecord
+	maxRecords int
+	handlers   []func(*VerificationRecord)
+}
+
+// NewVerificationRecordStore creates a new verification record store
+func NewVerificationRecordStore() *VerificationRecordStore {
+	return &VerificationRecordStore{
+		records:    make([]*VerificationRecord, 0),
+		maxRecords: 100000,
+	}
+}
+
+// NewVerificationRecord builds a record from a request/response pair
+func NewVerificationRecord(req models.VerificationRequest, response *models.VerificationResponse) *VerificationRecord {
+	record := &VerificationRecord{
+		RPID:      req.RPID,
+		UserID:    req.UserID,
+		ClaimType: req.ClaimType,
+		CreatedAt: time.Now(),
+	}
+
+	for key := range req.Identifiers {
+		record.IdentifierTypes = append(record.IdentifierTypes, key)
+	}
+	sort.Strings(record.IdentifierTypes)
+
+	if response != nil {
+		record.VerificationID = response.VerificationID
+		record.RequestID = response.RequestID
+		record.Status = response.Status
+		record.Verified = response.Verified
+		record.ConfidenceScore = response.ConfidenceScore
+		record.Reason = response.Reason
+		record.Evidence = response.Evidence
+		record.DPID = response.DPID
+		record.AuditReference = response.AuditReference
+	}
+
+	return record
+}
+
+// Append stores a verification record
+func (s *VerificationRecordStore) Append(record *VerificationRecord) error {
+	if record == nil {
+		return fmt.Errorf("verification record is nil")
+	}
+	if record.RPID == "" {
+		return fmt.Errorf("verification record is missing rp_id")
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+
+	s.mu.Lock()
+	s.records = append(s.records, record)
+
+	// Keep only the most recent records
+	if len(s.records) > s.maxRecords {
+		s.records = s.records[len(s.records)-s.maxRecords:]
+	}
+	handlers := s.handlers
+	s.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(record)
+	}
+	return nil
+}
+
+// OnAppend registers a handler called with each stored record
+func (s *VerificationRecordStore) OnAppend(handler func(*VerificationRecord)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, handler)
+}
+
+// Query returns records matching the query ordered by creation time
+func (s *VerificationRecordStore) Query(query VerificationRecordQuery) []*VerificationRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := make([]*VerificationRecord, 0)
+	for _, record := range s.records {
+		if query.RPID != "" && record.RPID != query.RPID {
+			continue
+		}
+		if query.ClaimType != "" && record.ClaimType != query.ClaimType {
+			continue
+		}
+		if !query.From.IsZero() && record.CreatedAt.Before(query.From) {
+			continue
+		}
+		if !query.To.IsZero() && !record.CreatedAt.Before(query.To) {
+			continue
+		}
+		results = append(results, record)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].CreatedAt.Before(results[j].CreatedAt)
+	})
+
+	return results
+}
+
+// RPsForClaimTypes returns the RPs with stored records of any of the claim
+// types, ordered by ID; the wildcard claim type matches every record
+func (s *VerificationRecordStore) RPsForClaimTypes(claimTypes []string) []string {
+	wanted := make(map[string]bool, len(claimTypes))
+	for _, claimType := range claimTypes {
+		wanted[claimType] = true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rpIDs := make(map[string]bool)
+	for _, record := range s.records {
+		if wanted[AnyClaimType] || wanted[record.ClaimType] {
+			rpIDs[record.RPID] = true
+		}
+	}
+	return sortedMapKeys(rpIDs)
+}
+
+// Count returns the number of stored records
+func (s *VerificationRecordStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/models"
+)
+
+func TestNewVerificationRecord(t *testing.T) {
+	req := models.VerificationRequest{
+		RPID:      "rp_1",
+		UserID:    "user_1",
+		ClaimType: "age_verification",
+		Identifiers: map[string]string{
+			"phone": "+15550100",
+			"email": "user@example.com",
+		},
+	}
+	response := models.NewVerificationResponse("verified", 0.9, "req_1")
+	response.Verified = true
+	response.DPID = "dp_1"
+
+	record := NewVerificationRecord(req, response)
+
+	if record.RPID != "rp_1" || record.ClaimType != "age_verification" {
+		t.Error("Expected request fields to be copied")
+	}
+	if record.VerificationID != response.VerificationID || !record.Verified || record.DPID != "dp_1" {
+		t.Error("Expected response fields to be copied")
+	}
+	if len(record.IdentifierTypes) != 2 || record.IdentifierTypes[0] != "email" {
+		t.Errorf("Expected sorted identifier types, got %v", record.IdentifierTypes)
+	}
+}
+
+func TestVerificationRecordStore_Query(t *testing.T) {
+	store := NewVerificationRecordStore()
+	now := time.Now()
+
+	if err := store.Append(nil); err == nil {
+		t.Error("Expected error for nil record")
+	}
+	if err := store.Append(&VerificationRecord{}); err == nil {
+		t.Error("Expected error for record without rp_id")
+	}
+
+	store.Append(&VerificationRecord{VerificationID: "b", RPID: "rp_1", ClaimType: "age_verification", CreatedAt: now.Add(-time.Minute)})
+	store.Append(&VerificationRecord{VerificationID: "a", RPID: "rp_1", ClaimType: "age_verification", CreatedAt: now.Add(-time.Hour)})
+	store.Append(&VerificationRecord{VerificationID: "c", RPID: "rp_1", ClaimType: "student_verification", CreatedAt: now.Add(-time.Minute)})
+	store.Append(&VerificationRecord{VerificationID: "d", RPID: "rp_2", ClaimType: "age_verification", CreatedAt: now.Add(-time.Minute)})
+
+	if store.Count() != 4 {
+		t.Errorf("Expected 4 records, got %d", store.Count())
+	}
+
+	results := store.Query(VerificationRecordQuery{RPID: "rp_1", ClaimType: "age_verification"})
+	if len(results) != 2 || results[0].VerificationID != "a" {
+		t.Errorf("Expected records ordered by creation time, got %d records", len(results))
+	}
+
+	results = store.Query(VerificationRecordQuery{RPID: "rp_1", From: now.Add(-30 * time.Minute), To: now})
+	if len(results) != 2 {
+		t.Errorf("Expected 2 records in range, got %d", len(results))
+	}
+}
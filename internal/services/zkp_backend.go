@@ -0,0 +1,187 @@
+package services
+
+import "fmt"
+
+// ZKP backends selectable by ZKPConfig.Backend
+const (
+	ZKPBackendHash  = "hash"  // hash commitments only; proofs are not sound
+	ZKPBackendGnark = "gnark" // zk-SNARK circuits over BN254
+	// ZKPBackendBulletproofs proves range_proof only, without a trusted
+	// setup, and is selected with ZKPConfig.RangeBackend
+	ZKPBackendBulletproofs = "bulletproofs"
+)
+
+// ZKP proving schemes supported by the gnark backend
+const (
+	ZKPSchemeGroth16 = "groth16"
+	ZKPSchemePLONK   = "plonk"
+)
+
+// supportedProofTypes are the statements every backend implements
+var supportedProofTypes = []string{"age_verification", "range_proof", "membership_proof", "equality_proof"}
+
+// ZKPBackend proves and verifies the supported statements
+type ZKPBackend interface {
+	// Name identifies the backend in responses and stats
+	Name() string
+	// Prove creates a proof for a validated request
+	Prove(request ZKPRequest) (*ZKPBackendProof, error)
+	// Verify checks a proof of the given type
+	Verify(proofType string, request ZKPVerificationRequest) (bool, error)
+	// ExportVerificationKey returns the serialized verification key for a
+	// proof type so that third parties can verify proofs independently
+	ExportVerificationKey(proofType string) ([]byte, error)
+}
+
+// ZKPBackendProof is a proof produced by a backend
+type ZKPBackendProof struct {
+	Proof           string
+	VerificationKey string
+	// PublicInputs are inputs derived by the backend (such as commitments)
+	// that verifiers need in addition to the request's public inputs
+	PublicInputs map[string]interface{}
+	// WithheldInputs are request public inputs the backend consumed but that
+	// must not be passed on to verifiers, such as a membership set
+	WithheldInputs []string
+	Metadata       map[string]interface{}
+}
+
+// NewZKPBackend creates the backend named in the configuration. When a range
+// backend is configured, range proofs are routed to it instead.
+func NewZKPBackend(config *ZKPConfig, service *ZKPService) (ZKPBackend, error) {
+	backend, err := newPrimaryZKPBackend(config, service)
+	if err != nil {
+		return nil, err
+	}
+
+	switch config.RangeBackend {
+	case "", config.Backend:
+		return backend, nil
+	case ZKPBackendBulletproofs:
+		ranges, err := NewBulletproofsBackend()
+		if err != nil {
+			return nil, err
+		}
+		return &rangeRoutedBackend{ZKPBackend: backend, ranges: ranges}, nil
+	default:
+		return nil, fmt.Errorf("unknown ZKP range proof backend: %s", config.RangeBackend)
+	}
+}
+
+func newPrimaryZKPBackend(config *ZKPConfig, service *ZKPService) (ZKPBackend, error) {
+	switch config.Backend {
+	case "", ZKPBackendHash:
+		return &hashZKPBackend{service: service}, nil
+	case ZKPBackendGnark:
+		backend, err := NewGnarkBackend(config.Scheme, config.SetupDir)
+		if err != nil {
+			return nil, err
+		}
+		return backend, nil
+	default:
+		return nil, fmt.Errorf("unknown ZKP backend: %s", config.Backend)
+	}
+}
+
+// rangeRoutedBackend sends range proofs to a dedicated backend and every
+// other proof type to the primary one
+type rangeRoutedBackend struct {
+	ZKPBackend
+	ranges ZKPBackend
+}
+
+func (b *rangeRoutedBackend) Prove(request ZKPRequest) (*ZKPBackendProof, error) {
+	if request.ProofType == "range_proof" {
+		return b.ranges.Prove(request)
+	}
+	return b.ZKPBackend.Prove(request)
+}
+
+func (b *rangeRoutedBackend) Verify(proofType string, request ZKPVerificationRequest) (bool, error) {
+	if proofType == "range_proof" {
+		return b.ranges.Verify(proofType, request)
+	}
+	return b.ZKPBackend.Verify(proofType, request)
+}
+
+func (b *rangeRoutedBackend) ExportVerificationKey(proofType string) ([]byte, error) {
+	if proofType == "range_proof" {
+		return b.ranges.ExportVerificationKey(proofType)
+	}
+	return b.ZKPBackend.ExportVerificationKey(proofType)
+}
+
+// hashZKPBackend is the original commitment-based implementation. It checks
+// proof structure only and is kept as the default for compatibility.
+type hashZKPBackend struct {
+	service *ZKPService
+}
+
+func (b *hashZKPBackend) Name() string {
+	return ZKPBackendHash
+}
+
+func (b *hashZKPBackend) Prove(request ZKPRequest) (*ZKPBackendProof, error) {
+	var proof, verificationKey string
+	var err error
+
+	switch request.ProofType {
+	case "age_verification":
+		proof, verificationKey, err = b.service.generateAgeProof(request)
+	case "range_proof":
+		proof, verificationKey, err = b.service.generateRangeProof(request)
+	case "membership_proof":
+		return b.service.generateMembershipProof(request)
+	case "equality_proof":
+		proof, verificationKey, err = b.service.generateEqualityProof(request)
+	default:
+		return nil, fmt.Errorf("unsupported proof type: %s", request.ProofType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ZKPBackendProof{Proof: proof, VerificationKey: verificationKey}, nil
+}
+
+func (b *hashZKPBackend) Verify(proofType string, request ZKPVerificationRequest) (bool, error) {
+	switch proofType {
+	case "age_verification":
+		return b.service.verifyAgeProof(request)
+	case "range_proof":
+		return b.service.verifyRangeProof(request)
+	case "membership_proof":
+		return b.service.verifyMembershipProof(request)
+	case "equality_proof":
+		return b.service.verifyEqualityProof(request)
+	default:
+		return false, fmt.Errorf("unsupported proof type: %s", proofType)
+	}
+}
+
+func (b *hashZKPBackend) ExportVerificationKey(proofType string) ([]byte, error) {
+	return []byte(b.service.createVerificationKey(proofType)), nil
+}
+
+// unavailableZKPBackend reports a backend that could not be created, so a
+// misconfiguration never silently falls back to unsound proofs
+type unavailableZKPBackend struct {
+	name string
+	err  error
+}
+
+func (b *unavailableZKPBackend) Name() string {
+	return b.name
+}
+
+func (b *unavailableZKPBackend) Prove(request ZKPRequest) (*ZKPBackendProof, error) {
+	return nil, fmt.Errorf("ZKP backend unavailable: %w", b.err)
+}
+
+func (b *unavailableZKPBackend) Verify(proofType string, request ZKPVerificationRequest) (bool, error) {
+	return false, fmt.Errorf("ZKP backend unavailable: %w", b.err)
+}
+
+func (b *unavailableZKPBackend) ExportVerificationKey(proofType string) ([]byte, error) {
+	return nil, fmt.Errorf("ZKP backend unavailable: %w", b.err)
+}
@@ -0,0 +1,777 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"math/big"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+const (
+	// bulletproofBits is the bit length each range proof covers. Bounds are
+	// limited to rangeBits, so differences always fit.
+	bulletproofBits = 64
+	// bulletproofRounds is log2(bulletproofBits), the inner product rounds
+	bulletproofRounds = 6
+	// bulletproofDomain separates the generators and transcripts of these
+	// proofs from any other use of the curve
+	bulletproofDomain = "PAVILION-BULLETPROOFS-V1_BN254G1"
+
+	// bulletproofProofSize is A, S, T1, T2, the L and R of every round, and
+	// the scalars taux, mu, t, a and b
+	bulletproofProofSize = (4+2*bulletproofRounds)*bn254.SizeOfG1AffineCompressed + 5*fr.Bytes
+)
+
+// bulletproofGenerators are the nothing-up-my-sleeve bases shared by prover
+// and verifier. They are derived by hashing to the curve, so there is no
+// trusted setup.
+type bulletproofGenerators struct {
+	G  bn254.G1Affine // value base
+	H  bn254.G1Affine // blinding base
+	Gs []bn254.G1Affine
+	Hs []bn254.G1Affine
+}
+
+var (
+	bulletproofGensOnce sync.Once
+	bulletproofGens     *bulletproofGenerators
+	bulletproofGensErr  error
+)
+
+// getBulletproofGenerators derives the generators on first use
+func getBulletproofGenerators() (*bulletproofGenerators, error) {
+	bulletproofGensOnce.Do(func() {
+		_, _, g, _ := bn254.Generators()
+		gens := &bulletproofGenerators{
+			G:  g,
+			Gs: make([]bn254.G1Affine, bulletproofBits),
+			Hs: make([]bn254.G1Affine, bulletproofBits),
+		}
+
+		hashPoint := func(label string) bn254.G1Affine {
+			if bulletproofGensErr != nil {
+				return bn254.G1Affine{}
+			}
+			point, err := bn254.HashToG1([]byte(label), []byte(bulletproofDomain))
+			if err != nil {
+				bulletproofGensErr = fmt.Errorf("failed to derive generator %s: %w", label, err)
+			}
+			return point
+		}
+
+		gens.H = hashPoint("H")
+		for i := 0; i < bulletproofBits; i++ {
+			gens.Gs[i] = hashPoint(fmt.Sprintf("G%d", i))
+			gens.Hs[i] = hashPoint(fmt.Sprintf("H%d", i))
+		}
+		bulletproofGens = gens
+	})
+	return bulletproofGens, bulletproofGensErr
+}
+
+// BulletproofsBackend proves range statements with Bulletproofs over BN254.
+// Unlike the gnark circuits it needs no trusted setup, so RPs can verify
+// range claims such as salary bands without trusting setup artifacts. It
+// only implements range_proof and is selected with ZKPConfig.RangeBackend.
+type BulletproofsBackend struct {
+	vkFingerprint string
+}
+
+// NewBulletproofsBackend creates the Bulletproofs range proof backend
+func NewBulletproofsBackend() (*BulletproofsBackend, error) {
+	if _, err := getBulletproofGenerators(); err != nil {
+		return nil, err
+	}
+	params, _ := bulletproofParameters()
+	fingerprint := sha256.Sum256(params)
+	return &BulletproofsBackend{vkFingerprint: hex.EncodeToString(fingerprint[:])}, nil
+}
+
+func (b *BulletproofsBackend) Name() string {
+	return ZKPBackendBulletproofs
+}
+
+// bulletproofEnvelope wraps the two range proofs. The lower proof shows
+// value-min is in [0, 2^64) and the upper proof shows max-value is.
+type bulletproofEnvelope struct {
+	Type    string `json:"type"`
+	Backend string `json:"backend"`
+	Bits    int    `json:"bits"`
+	Lower   string `json:"lower"`
+	Upper   string `json:"upper"`
+}
+
+// bulletproofRangeProof is a single Bulletproofs range proof
+type bulletproofRangeProof struct {
+	A, S, T1, T2 bn254.G1Affine
+	L, R         [bulletproofRounds]bn254.G1Affine
+	TauX, Mu, T  fr.Element
+	IPA, IPB     fr.Element
+}
+
+// Prove commits to the witness value and proves min_value <= value <= max_value.
+// The commitment is returned as a public input for the verifier.
+func (b *BulletproofsBackend) Prove(request ZKPRequest) (*ZKPBackendProof, error) {
+	if request.ProofType != "range_proof" {
+		return nil, fmt.Errorf("unsupported proof type for %s backend: %s", ZKPBackendBulletproofs, request.ProofType)
+	}
+	gens, err := getBulletproofGenerators()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := circuitInteger(request.Witness, "value", rangeBits)
+	if err != nil {
+		return nil, err
+	}
+	minValue, maxValue, err := bulletproofBounds(request.PublicInputs)
+	if err != nil {
+		return nil, err
+	}
+	if value < minValue || value > maxValue {
+		return nil, fmt.Errorf("statement not satisfied: value is outside [min_value, max_value]")
+	}
+
+	var gamma fr.Element
+	if encoded, ok := request.Witness["blinding"].(string); ok {
+		raw, err := hex.DecodeString(encoded)
+		if err != nil || gamma.SetBytesCanonical(raw) != nil {
+			return nil, fmt.Errorf("blinding must be a hex-encoded scalar")
+		}
+	} else if _, err := gamma.SetRandom(); err != nil {
+		return nil, fmt.Errorf("failed to generate blinding: %w", err)
+	}
+
+	// V = value*G + gamma*H; the verifier derives both bound commitments from it
+	commitment := pedersenCommit(gens, frFromUint64(value), gamma)
+	lowerCommitment, upperCommitment := bulletproofBoundCommitments(gens, commitment, minValue, maxValue)
+
+	var negGamma fr.Element
+	negGamma.Neg(&gamma)
+
+	lower, err := proveBulletproofRange(gens, newBulletproofTranscript("lower", minValue, maxValue, &lowerCommitment), value-minValue, gamma)
+	if err != nil {
+		return nil, err
+	}
+	upper, err := proveBulletproofRange(gens, newBulletproofTranscript("upper", minValue, maxValue, &upperCommitment), maxValue-value, negGamma)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope, _ := json.Marshal(bulletproofEnvelope{
+		Type:    request.ProofType,
+		Backend: ZKPBackendBulletproofs,
+		Bits:    bulletproofBits,
+		Lower:   base64.StdEncoding.EncodeToString(lower.marshal()),
+		Upper:   base64.StdEncoding.EncodeToString(upper.marshal()),
+	})
+
+	commitmentBytes := commitment.Bytes()
+	return &ZKPBackendProof{
+		Proof:           hex.EncodeToString(envelope),
+		VerificationKey: b.vkFingerprint,
+		PublicInputs:    map[string]interface{}{"commitment": hex.EncodeToString(commitmentBytes[:])},
+		Metadata: map[string]interface{}{
+			"backend":       ZKPBackendBulletproofs,
+			"curve":         ecc.BN254.String(),
+			"bits":          bulletproofBits,
+			"trusted_setup": false,
+		},
+	}, nil
+}
+
+// Verify checks both range proofs against the public bounds and commitment
+func (b *BulletproofsBackend) Verify(proofType string, request ZKPVerificationRequest) (bool, error) {
+	if proofType != "range_proof" {
+		return false, fmt.Errorf("unsupported proof type for %s backend: %s", ZKPBackendBulletproofs, proofType)
+	}
+	if request.VerificationKey != "" && request.VerificationKey != b.vkFingerprint {
+		return false, fmt.Errorf("verification key does not match the %s parameters", ZKPBackendBulletproofs)
+	}
+	gens, err := getBulletproofGenerators()
+	if err != nil {
+		return false, err
+	}
+
+	minValue, maxValue, err := bulletproofBounds(request.PublicInputs)
+	if err != nil {
+		return false, err
+	}
+	if minValue > maxValue {
+		return false, nil
+	}
+	encodedCommitment, ok := request.PublicInputs["commitment"].(string)
+	if !ok {
+		return false, fmt.Errorf("commitment not found in public inputs")
+	}
+	var commitment bn254.G1Affine
+	if raw, err := hex.DecodeString(encodedCommitment); err != nil || len(raw) != bn254.SizeOfG1AffineCompressed {
+		return false, fmt.Errorf("invalid commitment")
+	} else if _, err := commitment.SetBytes(raw); err != nil {
+		return false, fmt.Errorf("invalid commitment")
+	}
+
+	data, err := hex.DecodeString(request.Proof)
+	if err != nil {
+		return false, fmt.Errorf("invalid proof format")
+	}
+	var envelope bulletproofEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.Backend != ZKPBackendBulletproofs || envelope.Bits != bulletproofBits {
+		return false, fmt.Errorf("invalid proof structure")
+	}
+	lower, err := unmarshalBulletproof(envelope.Lower)
+	if err != nil {
+		return false, err
+	}
+	upper, err := unmarshalBulletproof(envelope.Upper)
+	if err != nil {
+		return false, err
+	}
+
+	lowerCommitment, upperCommitment := bulletproofBoundCommitments(gens, commitment, minValue, maxValue)
+	if !verifyBulletproofRange(gens, newBulletproofTranscript("lower", minValue, maxValue, &lowerCommitment), &lowerCommitment, lower) {
+		return false, nil
+	}
+	return verifyBulletproofRange(gens, newBulletproofTranscript("upper", minValue, maxValue, &upperCommitment), &upperCommitment, upper), nil
+}
+
+// ExportVerificationKey returns the public parameters. There is no key
+// material; verifiers only need to agree on the curve, domain and bit length.
+func (b *BulletproofsBackend) ExportVerificationKey(proofType string) ([]byte, error) {
+	if proofType != "range_proof" {
+		return nil, fmt.Errorf("unsupported proof type for %s backend: %s", ZKPBackendBulletproofs, proofType)
+	}
+	return bulletproofParameters()
+}
+
+// bulletproofParameters describes how the generators are derived
+func bulletproofParameters() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"scheme": ZKPBackendBulletproofs,
+		"curve":  ecc.BN254.String(),
+		"bits":   bulletproofBits,
+		"domain": bulletproofDomain,
+	})
+}
+
+// bulletproofBounds reads and checks the public range bounds
+func bulletproofBounds(publicInputs map[string]interface{}) (uint64, uint64, error) {
+	minValue, err := circuitInteger(publicInputs, "min_value", rangeBits)
+	if err != nil {
+		return 0, 0, err
+	}
+	maxValue, err := circuitInteger(publicInputs, "max_value", rangeBits)
+	if err != nil {
+		return 0, 0, err
+	}
+	return minValue, maxValue, nil
+}
+
+// bulletproofBoundCommitments derives commitments to value-min (V - min*G)
+// and max-value (max*G - V) from the commitment V to value
+func bulletproofBoundCommitments(gens *bulletproofGenerators, commitment bn254.G1Affine, minValue, maxValue uint64) (bn254.G1Affine, bn254.G1Affine) {
+	var minPoint, maxPoint, lower, upper bn254.G1Affine
+	minPoint.ScalarMultiplication(&gens.G, new(big.Int).SetUint64(minValue))
+	maxPoint.ScalarMultiplication(&gens.G, new(big.Int).SetUint64(maxValue))
+	lower.Sub(&commitment, &minPoint)
+	upper.Sub(&maxPoint, &commitment)
+	return lower, upper
+}
+
+// proveBulletproofRange proves that the value committed to with blinding
+// gamma lies in [0, 2^bulletproofBits), following Bünz et al., section 4.2
+func proveBulletproofRange(gens *bulletproofGenerators, transcript *bulletproofTranscript, value uint64, gamma fr.Element) (*bulletproofRangeProof, error) {
+	n := bulletproofBits
+	var one fr.Element
+	one.SetOne()
+
+	// aL holds the bits of value and aR = aL - 1
+	aL := make([]fr.Element, n)
+	aR := make([]fr.Element, n)
+	for i := 0; i < n; i++ {
+		if value>>uint(i)&1 == 1 {
+			aL[i].SetOne()
+		}
+		aR[i].Sub(&aL[i], &one)
+	}
+
+	alpha, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	rho, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	sL, err := randomScalars(n)
+	if err != nil {
+		return nil, err
+	}
+	sR, err := randomScalars(n)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &bulletproofRangeProof{}
+	if proof.A, err = vectorCommit(gens, alpha, aL, aR); err != nil {
+		return nil, err
+	}
+	if proof.S, err = vectorCommit(gens, rho, sL, sR); err != nil {
+		return nil, err
+	}
+
+	transcript.appendPoint("A", &proof.A)
+	transcript.appendPoint("S", &proof.S)
+	y := transcript.challenge("y")
+	z := transcript.challenge("z")
+
+	var zSquared fr.Element
+	zSquared.Square(&z)
+	yPowers := scalarPowers(y, n)
+	twoPowers := scalarPowers(frFromUint64(2), n)
+
+	// l(X) = l0 + l1*X and r(X) = r0 + r1*X
+	l0 := make([]fr.Element, n)
+	r0 := make([]fr.Element, n)
+	r1 := make([]fr.Element, n)
+	for i := 0; i < n; i++ {
+		l0[i].Sub(&aL[i], &z)
+
+		var term fr.Element
+		term.Add(&aR[i], &z)
+		r0[i].Mul(&yPowers[i], &term)
+		term.Mul(&zSquared, &twoPowers[i])
+		r0[i].Add(&r0[i], &term)
+
+		r1[i].Mul(&yPowers[i], &sR[i])
+	}
+
+	// t(X) = <l(X), r(X)> = t0 + t1*X + t2*X^2
+	var t1, t2, cross fr.Element
+	t1 = innerProduct(l0, r1)
+	cross = innerProduct(sL, r0)
+	t1.Add(&t1, &cross)
+	t2 = innerProduct(sL, r1)
+
+	tau1, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	tau2, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	proof.T1 = pedersenCommit(gens, t1, tau1)
+	proof.T2 = pedersenCommit(gens, t2, tau2)
+
+	transcript.appendPoint("T1", &proof.T1)
+	transcript.appendPoint("T2", &proof.T2)
+	x := transcript.challenge("x")
+
+	l := make([]fr.Element, n)
+	r := make([]fr.Element, n)
+	for i := 0; i < n; i++ {
+		var term fr.Element
+		term.Mul(&sL[i], &x)
+		l[i].Add(&l0[i], &term)
+		term.Mul(&r1[i], &x)
+		r[i].Add(&r0[i], &term)
+	}
+	proof.T = innerProduct(l, r)
+
+	// taux = tau2*x^2 + tau1*x + z^2*gamma and mu = alpha + rho*x
+	var xSquared, term fr.Element
+	xSquared.Square(&x)
+	proof.TauX.Mul(&tau2, &xSquared)
+	term.Mul(&tau1, &x)
+	proof.TauX.Add(&proof.TauX, &term)
+	term.Mul(&zSquared, &gamma)
+	proof.TauX.Add(&proof.TauX, &term)
+	proof.Mu.Mul(&rho, &x)
+	proof.Mu.Add(&proof.Mu, &alpha)
+
+	transcript.appendScalar("taux", &proof.TauX)
+	transcript.appendScalar("mu", &proof.Mu)
+	transcript.appendScalar("t", &proof.T)
+	w := transcript.challenge("w")
+
+	// The inner product argument runs over h'_i = y^-i * H_i with U = w*G
+	var u bn254.G1Affine
+	u.ScalarMultiplication(&gens.G, w.BigInt(new(big.Int)))
+	yInverses := scalarPowers(inverse(y), n)
+	hPrime := make([]bn254.G1Affine, n)
+	for i := 0; i < n; i++ {
+		hPrime[i].ScalarMultiplication(&gens.Hs[i], yInverses[i].BigInt(new(big.Int)))
+	}
+
+	if err := proveInnerProduct(transcript, proof, append([]bn254.G1Affine(nil), gens.Gs...), hPrime, &u, l, r); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// proveInnerProduct runs the logarithmic inner product argument for
+// P = <a, g> + <b, h> + <a, b>*u, halving the vectors each round
+func proveInnerProduct(transcript *bulletproofTranscript, proof *bulletproofRangeProof, g, h []bn254.G1Affine, u *bn254.G1Affine, a, b []fr.Element) error {
+	for round := 0; len(a) > 1; round++ {
+		half := len(a) / 2
+		aLo, aHi := a[:half], a[half:]
+		bLo, bHi := b[:half], b[half:]
+		gLo, gHi := g[:half], g[half:]
+		hLo, hHi := h[:half], h[half:]
+
+		cL := innerProduct(aLo, bHi)
+		cR := innerProduct(aHi, bLo)
+
+		var err error
+		proof.L[round], err = multiExp(concatPoints(gHi, hLo, []bn254.G1Affine{*u}), concatScalars(aLo, bHi, []fr.Element{cL}))
+		if err != nil {
+			return err
+		}
+		proof.R[round], err = multiExp(concatPoints(gLo, hHi, []bn254.G1Affine{*u}), concatScalars(aHi, bLo, []fr.Element{cR}))
+		if err != nil {
+			return err
+		}
+
+		transcript.appendPoint("L", &proof.L[round])
+		transcript.appendPoint("R", &proof.R[round])
+		x := transcript.challenge("u")
+		xInv := inverse(x)
+
+		nextA := make([]fr.Element, half)
+		nextB := make([]fr.Element, half)
+		nextG := make([]bn254.G1Affine, half)
+		nextH := make([]bn254.G1Affine, half)
+		for i := 0; i < half; i++ {
+			var lo, hi fr.Element
+			lo.Mul(&aLo[i], &x)
+			hi.Mul(&aHi[i], &xInv)
+			nextA[i].Add(&lo, &hi)
+			lo.Mul(&bLo[i], &xInv)
+			hi.Mul(&bHi[i], &x)
+			nextB[i].Add(&lo, &hi)
+
+			if nextG[i], err = multiExp([]bn254.G1Affine{gLo[i], gHi[i]}, []fr.Element{xInv, x}); err != nil {
+				return err
+			}
+			if nextH[i], err = multiExp([]bn254.G1Affine{hLo[i], hHi[i]}, []fr.Element{x, xInv}); err != nil {
+				return err
+			}
+		}
+		a, b, g, h = nextA, nextB, nextG, nextH
+	}
+
+	proof.IPA, proof.IPB = a[0], b[0]
+	return nil
+}
+
+// verifyBulletproofRange checks a range proof for commitment V
+func verifyBulletproofRange(gens *bulletproofGenerators, transcript *bulletproofTranscript, commitment *bn254.G1Affine, proof *bulletproofRangeProof) bool {
+	n := bulletproofBits
+
+	transcript.appendPoint("A", &proof.A)
+	transcript.appendPoint("S", &proof.S)
+	y := transcript.challenge("y")
+	z := transcript.challenge("z")
+	transcript.appendPoint("T1", &proof.T1)
+	transcript.appendPoint("T2", &proof.T2)
+	x := transcript.challenge("x")
+	transcript.appendScalar("taux", &proof.TauX)
+	transcript.appendScalar("mu", &proof.Mu)
+	transcript.appendScalar("t", &proof.T)
+	w := transcript.challenge("w")
+
+	var zSquared, zCubed, xSquared fr.Element
+	zSquared.Square(&z)
+	zCubed.Mul(&zSquared, &z)
+	xSquared.Square(&x)
+	yPowers := scalarPowers(y, n)
+	yInverses := scalarPowers(inverse(y), n)
+	twoPowers := scalarPowers(frFromUint64(2), n)
+
+	// delta(y, z) = (z - z^2)*<1, y^n> - z^3*<1, 2^n>
+	var delta, sumY, sumTwo, term fr.Element
+	for i := 0; i < n; i++ {
+		sumY.Add(&sumY, &yPowers[i])
+		sumTwo.Add(&sumTwo, &twoPowers[i])
+	}
+	term.Sub(&z, &zSquared)
+	delta.Mul(&term, &sumY)
+	term.Mul(&zCubed, &sumTwo)
+	delta.Sub(&delta, &term)
+
+	// t*G + taux*H == z^2*V + delta*G + x*T1 + x^2*T2
+	var negZSquared, negX, negXSquared, tMinusDelta fr.Element
+	negZSquared.Neg(&zSquared)
+	negX.Neg(&x)
+	negXSquared.Neg(&xSquared)
+	tMinusDelta.Sub(&proof.T, &delta)
+	check, err := multiExp(
+		[]bn254.G1Affine{gens.G, gens.H, *commitment, proof.T1, proof.T2},
+		[]fr.Element{tMinusDelta, proof.TauX, negZSquared, negX, negXSquared},
+	)
+	if err != nil || !check.IsInfinity() {
+		return false
+	}
+
+	// Replay the inner product challenges
+	challenges := make([]fr.Element, bulletproofRounds)
+	for round := 0; round < bulletproofRounds; round++ {
+		transcript.appendPoint("L", &proof.L[round])
+		transcript.appendPoint("R", &proof.R[round])
+		challenges[round] = transcript.challenge("u")
+	}
+	challengeInverses := make([]fr.Element, bulletproofRounds)
+	for round := range challenges {
+		challengeInverses[round] = inverse(challenges[round])
+	}
+
+	// s_i is the product of u_j for rounds where i took the high half and
+	// u_j^-1 otherwise, so the folded generators are <s, g> and <s^-1, h'>
+	s := make([]fr.Element, n)
+	for i := 0; i < n; i++ {
+		s[i].SetOne()
+		for round := 0; round < bulletproofRounds; round++ {
+			if i>>uint(bulletproofRounds-1-round)&1 == 1 {
+				s[i].Mul(&s[i], &challenges[round])
+			} else {
+				s[i].Mul(&s[i], &challengeInverses[round])
+			}
+		}
+	}
+	sInverses := fr.BatchInvert(s)
+
+	// A + x*S - z*<1, g> + <z + z^2*2^i*y^-i, h> - mu*H + t*w*G
+	//   + sum(u_j^2*L_j + u_j^-2*R_j) == a*<s, g> + b*<s^-1, h'> + a*b*w*G
+	points := make([]bn254.G1Affine, 0, 2*n+4+2*bulletproofRounds)
+	scalars := make([]fr.Element, 0, cap(points))
+
+	var ab, coefficient fr.Element
+	ab.Mul(&proof.IPA, &proof.IPB)
+	for i := 0; i < n; i++ {
+		coefficient.Mul(&proof.IPA, &s[i])
+		coefficient.Add(&coefficient, &z)
+		coefficient.Neg(&coefficient)
+		points = append(points, gens.Gs[i])
+		scalars = append(scalars, coefficient)
+	}
+	for i := 0; i < n; i++ {
+		var hCoefficient fr.Element
+		hCoefficient.Mul(&zSquared, &twoPowers[i])
+		term.Mul(&proof.IPB, &sInverses[i])
+		hCoefficient.Sub(&hCoefficient, &term)
+		hCoefficient.Mul(&hCoefficient, &yInverses[i])
+		hCoefficient.Add(&hCoefficient, &z)
+		points = append(points, gens.Hs[i])
+		scalars = append(scalars, hCoefficient)
+	}
+
+	var negMu, gCoefficient fr.Element
+	negMu.Neg(&proof.Mu)
+	gCoefficient.Sub(&proof.T, &ab)
+	gCoefficient.Mul(&gCoefficient, &w)
+	var one fr.Element
+	one.SetOne()
+	points = append(points, proof.A, proof.S, gens.H, gens.G)
+	scalars = append(scalars, one, x, negMu, gCoefficient)
+
+	for round := 0; round < bulletproofRounds; round++ {
+		var square, inverseSquare fr.Element
+		square.Square(&challenges[round])
+		inverseSquare.Square(&challengeInverses[round])
+		points = append(points, proof.L[round], proof.R[round])
+		scalars = append(scalars, square, inverseSquare)
+	}
+
+	check, err = multiExp(points, scalars)
+	return err == nil && check.IsInfinity()
+}
+
+// bulletproofTranscript derives Fiat-Shamir challenges from everything sent
+// so far. Every transcript is bound to the statement being proven.
+type bulletproofTranscript struct {
+	state hash.Hash
+}
+
+func newBulletproofTranscript(label string, minValue, maxValue uint64, commitment *bn254.G1Affine) *bulletproofTranscript {
+	transcript := &bulletproofTranscript{state: sha256.New()}
+	transcript.state.Write([]byte(bulletproofDomain))
+	transcript.state.Write([]byte(label))
+
+	var buffer [8]byte
+	binary.BigEndian.PutUint64(buffer[:], bulletproofBits)
+	transcript.state.Write(buffer[:])
+	binary.BigEndian.PutUint64(buffer[:], minValue)
+	transcript.state.Write(buffer[:])
+	binary.BigEndian.PutUint64(buffer[:], maxValue)
+	transcript.state.Write(buffer[:])
+	transcript.appendPoint("V", commitment)
+	return transcript
+}
+
+func (t *bulletproofTranscript) appendPoint(label string, point *bn254.G1Affine) {
+	encoded := point.Bytes()
+	t.state.Write([]byte(label))
+	t.state.Write(encoded[:])
+}
+
+func (t *bulletproofTranscript) appendScalar(label string, scalar *fr.Element) {
+	encoded := scalar.Bytes()
+	t.state.Write([]byte(label))
+	t.state.Write(encoded[:])
+}
+
+// challenge returns a scalar derived from the transcript and feeds it back
+// so later challenges depend on it
+func (t *bulletproofTranscript) challenge(label string) fr.Element {
+	t.state.Write([]byte(label))
+	digest := t.state.Sum(nil)
+	t.state.Write(digest)
+
+	var challenge fr.Element
+	challenge.SetBytes(digest)
+	return challenge
+}
+
+// marshal encodes the proof as fixed-size compressed points and scalars
+func (p *bulletproofRangeProof) marshal() []byte {
+	out := make([]byte, 0, bulletproofProofSize)
+	for _, point := range []*bn254.G1Affine{&p.A, &p.S, &p.T1, &p.T2} {
+		encoded := point.Bytes()
+		out = append(out, encoded[:]...)
+	}
+	for round := 0; round < bulletproofRounds; round++ {
+		left, right := p.L[round].Bytes(), p.R[round].Bytes()
+		out = append(out, left[:]...)
+		out = append(out, right[:]...)
+	}
+	for _, scalar := range []*fr.Element{&p.TauX, &p.Mu, &p.T, &p.IPA, &p.IPB} {
+		encoded := scalar.Bytes()
+		out = append(out, encoded[:]...)
+	}
+	return out
+}
+
+// unmarshalBulletproof decodes a proof, rejecting points off the curve and
+// non-canonical scalars
+func unmarshalBulletproof(encoded string) (*bulletproofRangeProof, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data) != bulletproofProofSize {
+		return nil, fmt.Errorf("invalid proof structure")
+	}
+
+	proof := &bulletproofRangeProof{}
+	points := []*bn254.G1Affine{&proof.A, &proof.S, &proof.T1, &proof.T2}
+	for round := 0; round < bulletproofRounds; round++ {
+		points = append(points, &proof.L[round], &proof.R[round])
+	}
+	for _, point := range points {
+		if _, err := point.SetBytes(data[:bn254.SizeOfG1AffineCompressed]); err != nil {
+			return nil, fmt.Errorf("invalid proof structure")
+		}
+		data = data[bn254.SizeOfG1AffineCompressed:]
+	}
+	for _, scalar := range []*fr.Element{&proof.TauX, &proof.Mu, &proof.T, &proof.IPA, &proof.IPB} {
+		if err := scalar.SetBytesCanonical(data[:fr.Bytes]); err != nil {
+			return nil, fmt.Errorf("invalid proof structure")
+		}
+		data = data[fr.Bytes:]
+	}
+	return proof, nil
+}
+
+// pedersenCommit returns value*G + blinding*H
+func pedersenCommit(gens *bulletproofGenerators, value, blinding fr.Element) bn254.G1Affine {
+	commitment, _ := multiExp([]bn254.G1Affine{gens.G, gens.H}, []fr.Element{value, blinding})
+	return commitment
+}
+
+// vectorCommit returns blinding*H + <left, Gs> + <right, Hs>
+func vectorCommit(gens *bulletproofGenerators, blinding fr.Element, left, right []fr.Element) (bn254.G1Affine, error) {
+	return multiExp(
+		concatPoints([]bn254.G1Affine{gens.H}, gens.Gs, gens.Hs),
+		concatScalars([]fr.Element{blinding}, left, right),
+	)
+}
+
+func multiExp(points []bn254.G1Affine, scalars []fr.Element) (bn254.G1Affine, error) {
+	var result bn254.G1Affine
+	if _, err := result.MultiExp(points, scalars, ecc.MultiExpConfig{}); err != nil {
+		return result, fmt.Errorf("multi-exponentiation failed: %w", err)
+	}
+	return result, nil
+}
+
+func innerProduct(a, b []fr.Element) fr.Element {
+	var result, term fr.Element
+	for i := range a {
+		term.Mul(&a[i], &b[i])
+		result.Add(&result, &term)
+	}
+	return result
+}
+
+// scalarPowers returns base^0 .. base^(n-1)
+func scalarPowers(base fr.Element, n int) []fr.Element {
+	powers := make([]fr.Element, n)
+	powers[0].SetOne()
+	for i := 1; i < n; i++ {
+		powers[i].Mul(&powers[i-1], &base)
+	}
+	return powers
+}
+
+func inverse(x fr.Element) fr.Element {
+	var result fr.Element
+	result.Inverse(&x)
+	return result
+}
+
+func frFromUint64(value uint64) fr.Element {
+	var result fr.Element
+	result.SetUint64(value)
+	return result
+}
+
+func randomScalar() (fr.Element, error) {
+	var result fr.Element
+	if _, err := result.SetRandom(); err != nil {
+		return result, fmt.Errorf("failed to generate random scalar: %w", err)
+	}
+	return result, nil
+}
+
+func randomScalars(n int) ([]fr.Element, error) {
+	scalars := make([]fr.Element, n)
+	for i := range scalars {
+		if _, err := scalars[i].SetRandom(); err != nil {
+			return nil, fmt.Errorf("failed to generate random scalar: %w", err)
+		}
+	}
+	return scalars, nil
+}
+
+func concatPoints(parts ...[]bn254.G1Affine) []bn254.G1Affine {
+	var out []bn254.G1Affine
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+func concatScalars(parts ...[]fr.Element) []fr.Element {
+	var out []fr.Element
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
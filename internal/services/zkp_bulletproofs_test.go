@@ -0,0 +1,152 @@
+package services
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func newBulletproofsZKPService(t *testing.T) *ZKPService {
+	t.Helper()
+	zkpConfig := NewZKPConfig(5*time.Second, 1024*1024, "test", false)
+	zkpConfig.RangeBackend = ZKPBackendBulletproofs
+	return NewZKPService(zkpConfig)
+}
+
+func bulletproofRangeRequest(value float64) ZKPRequest {
+	return ZKPRequest{
+		ProofType:    "range_proof",
+		Statement:    "salary in band",
+		Witness:      map[string]interface{}{"value": value},
+		PublicInputs: map[string]interface{}{"min_value": 30000.0, "max_value": 80000.0},
+	}
+}
+
+func TestBulletproofsBackend_RangeProof(t *testing.T) {
+	service := newBulletproofsZKPService(t)
+
+	for _, value := range []float64{30000, 50000, 80000} {
+		proof, err := service.GenerateProof(bulletproofRangeRequest(value))
+		if err != nil {
+			t.Fatalf("Expected proof for %v, got %v", value, err)
+		}
+		if proof.Metadata["backend"] != ZKPBackendBulletproofs || proof.Metadata["trusted_setup"] != false {
+			t.Errorf("Unexpected proof metadata: %v", proof.Metadata)
+		}
+		if _, ok := proof.PublicInputs["commitment"].(string); !ok {
+			t.Fatal("Expected commitment in public inputs")
+		}
+		if !verifyResponse(t, service, proof, proof.PublicInputs) {
+			t.Errorf("Expected proof for %v to verify", value)
+		}
+	}
+}
+
+func TestBulletproofsBackend_RejectsOutOfRange(t *testing.T) {
+	service := newBulletproofsZKPService(t)
+
+	for _, value := range []float64{29999, 80001} {
+		if _, err := service.GenerateProof(bulletproofRangeRequest(value)); err == nil {
+			t.Errorf("Expected no proof for %v", value)
+		}
+	}
+}
+
+func TestBulletproofsBackend_RejectsTamperedStatement(t *testing.T) {
+	service := newBulletproofsZKPService(t)
+
+	proof, err := service.GenerateProof(bulletproofRangeRequest(50000))
+	if err != nil {
+		t.Fatalf("Expected proof, got %v", err)
+	}
+	other, err := service.GenerateProof(bulletproofRangeRequest(60000))
+	if err != nil {
+		t.Fatalf("Expected proof, got %v", err)
+	}
+
+	tests := map[string]map[string]interface{}{
+		"narrower bounds":  {"min_value": 55000.0, "max_value": 80000.0, "commitment": proof.PublicInputs["commitment"]},
+		"other commitment": {"min_value": 30000.0, "max_value": 80000.0, "commitment": other.PublicInputs["commitment"]},
+	}
+	for name, publicInputs := range tests {
+		t.Run(name, func(t *testing.T) {
+			if verifyResponse(t, service, proof, publicInputs) {
+				t.Error("Expected proof not to verify")
+			}
+		})
+	}
+
+	t.Run("modified proof", func(t *testing.T) {
+		data, _ := hex.DecodeString(proof.Proof)
+		var envelope bulletproofEnvelope
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			t.Fatalf("Failed to decode envelope: %v", err)
+		}
+		envelope.Lower, envelope.Upper = envelope.Upper, envelope.Lower
+		data, _ = json.Marshal(envelope)
+
+		tampered := *proof
+		tampered.Proof = hex.EncodeToString(data)
+		if verifyResponse(t, service, &tampered, proof.PublicInputs) {
+			t.Error("Expected swapped proofs not to verify")
+		}
+	})
+}
+
+func TestBulletproofsBackend_RoutesOnlyRangeProofs(t *testing.T) {
+	service := newBulletproofsZKPService(t)
+
+	proof, err := service.GenerateProof(ZKPRequest{
+		ProofType:    "age_verification",
+		Statement:    "age >= 18",
+		Witness:      map[string]interface{}{"age": 30.0},
+		PublicInputs: map[string]interface{}{"minimum_age": 18.0},
+	})
+	if err != nil {
+		t.Fatalf("Expected proof, got %v", err)
+	}
+	if proof.Metadata["backend"] != ZKPBackendHash {
+		t.Errorf("Expected age proofs to use the hash backend, got %v", proof.Metadata["backend"])
+	}
+
+	key, err := service.ExportVerificationKey("range_proof")
+	if err != nil {
+		t.Fatalf("Expected range parameters, got %v", err)
+	}
+	var params map[string]interface{}
+	if err := json.Unmarshal(key, &params); err != nil || params["scheme"] != ZKPBackendBulletproofs {
+		t.Errorf("Unexpected range parameters: %s", key)
+	}
+}
+
+func TestNewZKPBackend_UnknownRangeBackend(t *testing.T) {
+	zkpConfig := NewZKPConfig(5*time.Second, 1024*1024, "test", false)
+	zkpConfig.RangeBackend = "unknown"
+
+	if _, err := NewZKPBackend(zkpConfig, nil); err == nil {
+		t.Error("Expected error for unknown range backend")
+	}
+}
+
+func TestBulletproofsBackend_ForgedOutOfRangeProofFails(t *testing.T) {
+	gens, err := getBulletproofGenerators()
+	if err != nil {
+		t.Fatalf("Expected generators, got %v", err)
+	}
+
+	// Skip the prover's range check: value-min wraps to a 64-bit number that
+	// does not match the commitment, so the proof must not verify
+	var minValue, maxValue, value uint64 = 30000, 80000, 20000
+	gamma, _ := randomScalar()
+	commitment := pedersenCommit(gens, frFromUint64(value), gamma)
+	lowerCommitment, _ := bulletproofBoundCommitments(gens, commitment, minValue, maxValue)
+
+	proof, err := proveBulletproofRange(gens, newBulletproofTranscript("lower", minValue, maxValue, &lowerCommitment), value-minValue, gamma)
+	if err != nil {
+		t.Fatalf("Expected prover to run, got %v", err)
+	}
+	if verifyBulletproofRange(gens, newBulletproofTranscript("lower", minValue, maxValue, &lowerCommitment), &lowerCommitment, proof) {
+		t.Error("Expected forged proof not to verify")
+	}
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+var circuitNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{2,63}$`)
+
+// ZKPCircuitImplementation proves and verifies the statement of one circuit
+type ZKPCircuitImplementation interface {
+	Prove(request ZKPRequest) (*ZKPBackendProof, error)
+	Verify(request ZKPVerificationRequest) (bool, error)
+	ExportVerificationKey() ([]byte, error)
+}
+
+// ZKPCircuitsFile is the on-disk format of circuits registered at startup
+type ZKPCircuitsFile struct {
+	Circuits []ZKPCircuit `json:"circuits"`
+}
+
+type registeredCircuit struct {
+	circuit        ZKPCircuit
+	implementation ZKPCircuitImplementation
+}
+
+// ZKPCircuitRegistry maps circuit names to their implementations. The built-in
+// circuits are always present; deployments add circuits either in Go with
+// RegisterImplementation or declaratively with Register, which derives a
+// circuit from a built-in one by fixing some of its public inputs.
+type ZKPCircuitRegistry struct {
+	mu       sync.RWMutex
+	circuits map[string]*registeredCircuit
+	builtins []string
+}
+
+// NewZKPCircuitRegistry creates a registry holding the built-in circuits,
+// proven and verified by the given backend
+func NewZKPCircuitRegistry(backend ZKPBackend) *ZKPCircuitRegistry {
+	registry := &ZKPCircuitRegistry{circuits: make(map[string]*registeredCircuit)}
+	for _, circuit := range builtinZKPCircuits() {
+		circuit.Builtin = true
+		registry.circuits[circuit.Name] = &registeredCircuit{
+			circuit:        circuit,
+			implementation: &backendCircuit{backend: backend, proofType: circuit.Name},
+		}
+		registry.builtins = append(registry.builtins, circuit.Name)
+	}
+	return registry
+}
+
+// RegisterImplementation adds a circuit implemented in Go
+func (r *ZKPCircuitRegistry) RegisterImplementation(circuit ZKPCircuit, implementation ZKPCircuitImplementation) error {
+	if implementation == nil {
+		return fmt.Errorf("circuit %s has no implementation", circuit.Name)
+	}
+	if !circuitNamePattern.MatchString(circuit.Name) {
+		return fmt.Errorf("invalid circuit name: %q", circuit.Name)
+	}
+	circuit.Builtin = false
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.circuits[circuit.Name]; exists {
+		return fmt.Errorf("circuit already registered: %s", circuit.Name)
+	}
+	r.circuits[circuit.Name] = &registeredCircuit{circuit: circuit, implementation: implementation}
+	return nil
+}
+
+// Register adds a circuit derived from a built-in one. Its fixed public inputs
+// override whatever a request supplies, so that, for example, an
+// "over_21" circuit always proves age against a minimum of 21. Constraints
+// describe the circuit to callers; what is enforced is the base circuit with
+// the fixed inputs.
+func (r *ZKPCircuitRegistry) Register(circuit ZKPCircuit) (*ZKPCircuit, error) {
+	if !circuitNamePattern.MatchString(circuit.Name) {
+		return nil, fmt.Errorf("invalid circuit name: %q", circuit.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.circuits[circuit.Name]; exists {
+		return nil, fmt.Errorf("circuit already registered: %s", circuit.Name)
+	}
+	base, exists := r.circuits[circuit.BaseProofType]
+	if !exists || !base.circuit.Builtin {
+		return nil, fmt.Errorf("base_proof_type must name a built-in circuit")
+	}
+	for key := range circuit.FixedPublicInputs {
+		if !containsString(base.circuit.Inputs, key) {
+			return nil, fmt.Errorf("fixed public input %s is not an input of %s", key, base.circuit.Name)
+		}
+	}
+
+	// Callers supply the base inputs that are not fixed
+	if len(circuit.Inputs) == 0 {
+		for _, input := range base.circuit.Inputs {
+			if _, fixed := circuit.FixedPublicInputs[input]; !fixed {
+				circuit.Inputs = append(circuit.Inputs, input)
+			}
+		}
+	}
+	if len(circuit.Outputs) == 0 {
+		circuit.Outputs = base.circuit.Outputs
+	}
+	if len(circuit.Constraints) == 0 {
+		circuit.Constraints = base.circuit.Constraints
+	}
+	circuit.Builtin = false
+
+	r.circuits[circuit.Name] = &registeredCircuit{
+		circuit:        circuit,
+		implementation: &derivedCircuit{circuit: circuit, base: base.implementation},
+	}
+	return &circuit, nil
+}
+
+// Unregister removes a registered circuit. Built-in circuits cannot be removed.
+func (r *ZKPCircuitRegistry) Unregister(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	registered, exists := r.circuits[name]
+	if !exists {
+		return fmt.Errorf("circuit not found: %s", name)
+	}
+	if registered.circuit.Builtin {
+		return fmt.Errorf("built-in circuit %s cannot be removed", name)
+	}
+	delete(r.circuits, name)
+	return nil
+}
+
+// Get returns a circuit and its implementation
+func (r *ZKPCircuitRegistry) Get(name string) (ZKPCircuit, ZKPCircuitImplementation, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	registered, exists := r.circuits[name]
+	if !exists {
+		return ZKPCircuit{}, nil, false
+	}
+	return registered.circuit, registered.implementation, true
+}
+
+// List returns the built-in circuits followed by registered ones by name
+func (r *ZKPCircuitRegistry) List() []ZKPCircuit {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	circuits := make([]ZKPCircuit, 0, len(r.circuits))
+	for _, name := range r.builtins {
+		circuits = append(circuits, r.circuits[name].circuit)
+	}
+	custom := make([]ZKPCircuit, 0, len(r.circuits)-len(r.builtins))
+	for _, registered := range r.circuits {
+		if !registered.circuit.Builtin {
+			custom = append(custom, registered.circuit)
+		}
+	}
+	sort.Slice(custom, func(i, j int) bool {
+		return custom[i].Name < custom[j].Name
+	})
+	return append(circuits, custom...)
+}
+
+// LoadFile registers the circuits of a circuits file
+func (r *ZKPCircuitRegistry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read circuits file: %w", err)
+	}
+
+	var file ZKPCircuitsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse circuits file: %w", err)
+	}
+
+	for _, circuit := range file.Circuits {
+		if _, err := r.Register(circuit); err != nil {
+			return fmt.Errorf("circuit %s: %w", circuit.Name, err)
+		}
+	}
+	return nil
+}
+
+// checkInputs reports a registered circuit input missing from a request
+func (c ZKPCircuit) checkInputs(request ZKPRequest) error {
+	for _, input := range c.Inputs {
+		_, inWitness := request.Witness[input]
+		_, inPublic := request.PublicInputs[input]
+		if !inWitness && !inPublic {
+			return fmt.Errorf("%s requires input %s", c.Name, input)
+		}
+	}
+	return nil
+}
+
+// backendCircuit is a built-in circuit proven by the configured backend
+type backendCircuit struct {
+	backend   ZKPBackend
+	proofType string
+}
+
+func (c *backendCircuit) Prove(request ZKPRequest) (*ZKPBackendProof, error) {
+	request.ProofType = c.proofType
+	return c.backend.Prove(request)
+}
+
+func (c *backendCircuit) Verify(request ZKPVerificationRequest) (bool, error) {
+	return c.backend.Verify(c.proofType, request)
+}
+
+func (c *backendCircuit) ExportVerificationKey() ([]byte, error) {
+	return c.backend.ExportVerificationKey(c.proofType)
+}
+
+// derivedCircuit proves a built-in circuit with some public inputs fixed
+type derivedCircuit struct {
+	circuit ZKPCircuit
+	base    ZKPCircuitImplementation
+}
+
+func (c *derivedCircuit) Prove(request ZKPRequest) (*ZKPBackendProof, error) {
+	request.PublicInputs = c.withFixedInputs(request.PublicInputs)
+	result, err := c.base.Prove(request)
+	if err != nil {
+		return nil, err
+	}
+	if c.circuit.VerificationKey != "" && result.VerificationKey != c.circuit.VerificationKey {
+		return nil, fmt.Errorf("backend verification key does not match the key registered for %s", c.circuit.Name)
+	}
+	// Verifiers are given the fixed inputs, not the ones the prover sent
+	result.PublicInputs = c.withFixedInputs(result.PublicInputs)
+	return result, nil
+}
+
+// Verify checks the proof against the fixed inputs rather than the ones the
+// presenter supplied, so a proof for a lower threshold does not pass
+func (c *derivedCircuit) Verify(request ZKPVerificationRequest) (bool, error) {
+	if c.circuit.VerificationKey != "" {
+		if request.VerificationKey != "" && request.VerificationKey != c.circuit.VerificationKey {
+			return false, nil
+		}
+		request.VerificationKey = c.circuit.VerificationKey
+	}
+	for key, value := range c.circuit.FixedPublicInputs {
+		if supplied, exists := request.PublicInputs[key]; exists && !reflect.DeepEqual(supplied, value) {
+			return false, nil
+		}
+	}
+	request.PublicInputs = c.withFixedInputs(request.PublicInputs)
+	return c.base.Verify(request)
+}
+
+func (c *derivedCircuit) ExportVerificationKey() ([]byte, error) {
+	if c.circuit.VerificationKey != "" {
+		return []byte(c.circuit.VerificationKey), nil
+	}
+	return c.base.ExportVerificationKey()
+}
+
+func (c *derivedCircuit) withFixedInputs(publicInputs map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(publicInputs)+len(c.circuit.FixedPublicInputs))
+	for k, v := range publicInputs {
+		merged[k] = v
+	}
+	for k, v := range c.circuit.FixedPublicInputs {
+		merged[k] = v
+	}
+	return merged
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// builtinZKPCircuits describes the circuits every backend implements
+func builtinZKPCircuits() []ZKPCircuit {
+	return []ZKPCircuit{
+		{
+			Name:        "age_verification",
+			Description: "Prove age is above threshold without revealing actual age",
+			Inputs:      []string{"age", "minimum_age"},
+			Outputs:     []string{"age_above_threshold"},
+			Constraints: []string{"age >= minimum_age"},
+			Metadata: map[string]interface{}{
+				"category":   "privacy",
+				"complexity": "simple",
+			},
+		},
+		{
+			Name:        "range_proof",
+			Description: "Prove value is within range without revealing actual value",
+			Inputs:      []string{"value", "min_value", "max_value"},
+			Outputs:     []string{"value_in_range"},
+			Constraints: []string{"min_value <= value <= max_value"},
+			Metadata: map[string]interface{}{
+				"category":   "privacy",
+				"complexity": "simple",
+			},
+		},
+		{
+			Name:        "membership_proof",
+			Description: "Prove element is in set without revealing element or set",
+			Inputs:      []string{"element", "set"},
+			Outputs:     []string{"element_in_set"},
+			Constraints: []string{"element ∈ set"},
+			Metadata: map[string]interface{}{
+				"category":   "privacy",
+				"complexity": "medium",
+			},
+		},
+		{
+			Name:        "equality_proof",
+			Description: "Prove two values are equal without revealing the values",
+			Inputs:      []string{"value1", "value2"},
+			Outputs:     []string{"values_equal"},
+			Constraints: []string{"value1 == value2"},
+			Metadata: map[string]interface{}{
+				"category":   "privacy",
+				"complexity": "simple",
+			},
+		},
+	}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newCircuitTestService() *ZKPService {
+	return NewZKPService(NewZKPConfig(5*time.Second, 1024*1024, "test", false))
+}
+
+func TestZKPCircuitRegistry_DerivedCircuit(t *testing.T) {
+	service := newCircuitTestService()
+
+	circuit, err := service.Circuits().Register(ZKPCircuit{
+		Name:              "over_21",
+		BaseProofType:     "age_verification",
+		FixedPublicInputs: map[string]interface{}{"minimum_age": float64(21)},
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if len(circuit.Inputs) != 1 || circuit.Inputs[0] != "age" {
+		t.Errorf("Expected only age as a caller input, got %v", circuit.Inputs)
+	}
+
+	t.Run("fixed inputs override the request", func(t *testing.T) {
+		response, err := service.GenerateProof(ZKPRequest{
+			ProofType:    "over_21",
+			Statement:    "age >= 21",
+			Witness:      map[string]interface{}{"age": float64(30)},
+			PublicInputs: map[string]interface{}{"minimum_age": float64(18)},
+		})
+		if err != nil {
+			t.Fatalf("GenerateProof failed: %v", err)
+		}
+		if response.ProofType != "over_21" || response.PublicInputs["minimum_age"] != float64(21) {
+			t.Errorf("Unexpected response: %+v", response)
+		}
+
+		verification, err := service.VerifyProof(ZKPVerificationRequest{
+			ProofType:    "over_21",
+			Proof:        response.Proof,
+			Statement:    response.Statement,
+			PublicInputs: map[string]interface{}{"minimum_age": float64(18)},
+		})
+		if err != nil {
+			t.Fatalf("VerifyProof failed: %v", err)
+		}
+		if verification.Valid {
+			t.Error("Proof presented with a different threshold should not verify")
+		}
+	})
+
+	t.Run("missing input", func(t *testing.T) {
+		_, err := service.GenerateProof(ZKPRequest{
+			ProofType: "over_21",
+			Statement: "age >= 21",
+			Witness:   map[string]interface{}{"birth_year": float64(1990)},
+		})
+		if err == nil {
+			t.Error("Expected an error for a missing circuit input")
+		}
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		response, err := service.GenerateProof(ZKPRequest{
+			ProofType: "over_21",
+			Statement: "age >= 21",
+			Witness:   map[string]interface{}{"age": float64(30)},
+		})
+		if err != nil {
+			t.Fatalf("GenerateProof failed: %v", err)
+		}
+		verification, err := service.VerifyProof(ZKPVerificationRequest{
+			ProofType:    "over_21",
+			Proof:        response.Proof,
+			Statement:    response.Statement,
+			PublicInputs: response.PublicInputs,
+		})
+		if err != nil || !verification.Valid {
+			t.Errorf("Expected a valid proof, got %+v, %v", verification, err)
+		}
+	})
+}
+
+func TestZKPCircuitRegistry_Validation(t *testing.T) {
+	registry := newCircuitTestService().Circuits()
+
+	cases := map[string]ZKPCircuit{
+		"bad name":      {Name: "Over 21", BaseProofType: "age_verification"},
+		"unknown base":  {Name: "over_21", BaseProofType: "credit_score"},
+		"duplicate":     {Name: "range_proof", BaseProofType: "age_verification"},
+		"foreign input": {Name: "over_21", BaseProofType: "age_verification", FixedPublicInputs: map[string]interface{}{"max_value": 1}},
+	}
+	for name, circuit := range cases {
+		if _, err := registry.Register(circuit); err == nil {
+			t.Errorf("%s: expected Register to fail", name)
+		}
+	}
+
+	if err := registry.Unregister("age_verification"); err == nil {
+		t.Error("Built-in circuits should not be removable")
+	}
+}
+
+func TestZKPCircuitRegistry_LoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "circuits.json")
+	content := `{"circuits":[{"name":"adult_range","base_proof_type":"range_proof","fixed_public_inputs":{"min_value":18,"max_value":120}}]}`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write circuits file: %v", err)
+	}
+
+	config := NewZKPConfig(5*time.Second, 1024*1024, "test", false)
+	config.CircuitsFile = path
+	service := NewZKPService(config)
+
+	circuits := service.GetSupportedCircuits()
+	if len(circuits) != 5 || circuits[4].Name != "adult_range" {
+		t.Fatalf("Expected adult_range after the built-in circuits, got %+v", circuits)
+	}
+	if _, err := service.ExportVerificationKey("adult_range"); err != nil {
+		t.Errorf("ExportVerificationKey failed: %v", err)
+	}
+}
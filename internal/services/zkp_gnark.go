@@ -0,0 +1,618 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	nativemimc "github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/std/hash/mimc"
+	"github.com/consensys/gnark/test/unsafekzg"
+)
+
+const (
+	// ageBits bounds ages to [0, 255] so a prover cannot wrap around the field
+	ageBits = 8
+	// rangeBits bounds range proof values to non-negative 53-bit integers,
+	// the largest exactly representable in JSON numbers
+	rangeBits = 53
+	// membershipSetSize is the fixed set size of the membership circuit;
+	// smaller sets are padded by repeating their last element
+	membershipSetSize = 16
+)
+
+// ageCircuit proves Age >= MinimumAge
+type ageCircuit struct {
+	Age        frontend.Variable
+	MinimumAge frontend.Variable `gnark:",public"`
+}
+
+func (c *ageCircuit) Define(api frontend.API) error {
+	api.ToBinary(c.Age, ageBits)
+	api.ToBinary(c.MinimumAge, ageBits)
+	api.AssertIsLessOrEqual(c.MinimumAge, c.Age)
+	return nil
+}
+
+// rangeCircuit proves MinValue <= Value <= MaxValue
+type rangeCircuit struct {
+	Value    frontend.Variable
+	MinValue frontend.Variable `gnark:",public"`
+	MaxValue frontend.Variable `gnark:",public"`
+}
+
+func (c *rangeCircuit) Define(api frontend.API) error {
+	api.ToBinary(c.Value, rangeBits)
+	api.ToBinary(c.MinValue, rangeBits)
+	api.ToBinary(c.MaxValue, rangeBits)
+	api.AssertIsLessOrEqual(c.MinValue, c.Value)
+	api.AssertIsLessOrEqual(c.Value, c.MaxValue)
+	return nil
+}
+
+// membershipCircuit proves Element is one of Set
+type membershipCircuit struct {
+	Element frontend.Variable
+	Set     [membershipSetSize]frontend.Variable `gnark:",public"`
+}
+
+func (c *membershipCircuit) Define(api frontend.API) error {
+	product := frontend.Variable(1)
+	for _, member := range c.Set {
+		product = api.Mul(product, api.Sub(c.Element, member))
+	}
+	api.AssertIsEqual(product, 0)
+	return nil
+}
+
+// equalityCircuit proves Value1 == Value2 and binds them to a public MiMC
+// commitment, so the proof is tied to a value without revealing it
+type equalityCircuit struct {
+	Value1     frontend.Variable
+	Value2     frontend.Variable
+	Commitment frontend.Variable `gnark:",public"`
+}
+
+func (c *equalityCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(c.Value1, c.Value2)
+	hasher, err := mimc.NewMiMC(api)
+	if err != nil {
+		return err
+	}
+	hasher.Write(c.Value1)
+	api.AssertIsEqual(hasher.Sum(), c.Commitment)
+	return nil
+}
+
+// newCircuit returns an empty circuit definition for a proof type
+func newCircuit(proofType string) (frontend.Circuit, error) {
+	switch proofType {
+	case "age_verification":
+		return &ageCircuit{}, nil
+	case "range_proof":
+		return &rangeCircuit{}, nil
+	case "membership_proof":
+		return &membershipCircuit{}, nil
+	case "equality_proof":
+		return &equalityCircuit{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported proof type: %s", proofType)
+	}
+}
+
+// gnarkKey is the serialization interface shared by proving and verifying keys
+type gnarkKey interface {
+	io.WriterTo
+	io.ReaderFrom
+}
+
+// gnarkCircuit is a compiled circuit with its keys
+type gnarkCircuit struct {
+	ccs           constraint.ConstraintSystem
+	provingKey    gnarkKey
+	verifyingKey  gnarkKey
+	vkFingerprint string
+}
+
+// gnarkProofEnvelope is the hex-encoded JSON wrapper around a serialized
+// proof; "type" matches the hash backend so proof types can be extracted
+type gnarkProofEnvelope struct {
+	Type    string `json:"type"`
+	Backend string `json:"backend"`
+	Scheme  string `json:"scheme"`
+	Proof   string `json:"proof"`
+}
+
+// GnarkBackend proves statements with Groth16 or PLONK circuits over BN254.
+// Circuits are compiled on first use. Keys are loaded from the setup
+// directory when one is configured; otherwise an in-process development setup
+// is generated, which is not suitable for production.
+type GnarkBackend struct {
+	scheme   string
+	setupDir string
+	mu       sync.Mutex
+	circuits map[string]*gnarkCircuit
+}
+
+// NewGnarkBackend creates a gnark backend for the scheme (Groth16 by default)
+func NewGnarkBackend(scheme, setupDir string) (*GnarkBackend, error) {
+	switch scheme {
+	case "":
+		scheme = ZKPSchemeGroth16
+	case ZKPSchemeGroth16, ZKPSchemePLONK:
+	default:
+		return nil, fmt.Errorf("unknown ZKP scheme: %s", scheme)
+	}
+
+	return &GnarkBackend{
+		scheme:   scheme,
+		setupDir: setupDir,
+		circuits: make(map[string]*gnarkCircuit),
+	}, nil
+}
+
+// Name returns the backend name
+func (b *GnarkBackend) Name() string {
+	return ZKPBackendGnark
+}
+
+// Scheme returns the proving scheme
+func (b *GnarkBackend) Scheme() string {
+	return b.scheme
+}
+
+// circuit compiles a circuit and loads or generates its keys
+func (b *GnarkBackend) circuit(proofType string) (*gnarkCircuit, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if circuit, exists := b.circuits[proofType]; exists {
+		return circuit, nil
+	}
+
+	definition, err := newCircuit(proofType)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := r1cs.NewBuilder
+	if b.scheme == ZKPSchemePLONK {
+		builder = scs.NewBuilder
+	}
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), builder, definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile %s circuit: %w", proofType, err)
+	}
+
+	circuit := &gnarkCircuit{ccs: ccs}
+	if b.setupDir != "" {
+		err = b.loadSetup(proofType, circuit)
+	} else {
+		fmt.Printf("ZKP WARNING: no setup artifacts configured, generating development keys for %s\n", proofType)
+		err = b.generateSetup(circuit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	vkBytes, err := serializeGnarkKey(circuit.verifyingKey)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := sha256.Sum256(vkBytes)
+	circuit.vkFingerprint = hex.EncodeToString(fingerprint[:])
+
+	b.circuits[proofType] = circuit
+	return circuit, nil
+}
+
+// generateSetup runs an in-process setup whose toxic waste is not destroyed
+func (b *GnarkBackend) generateSetup(circuit *gnarkCircuit) error {
+	if b.scheme == ZKPSchemePLONK {
+		srs, srsLagrange, err := unsafekzg.NewSRS(circuit.ccs)
+		if err != nil {
+			return fmt.Errorf("failed to generate KZG SRS: %w", err)
+		}
+		provingKey, verifyingKey, err := plonk.Setup(circuit.ccs, srs, srsLagrange)
+		if err != nil {
+			return fmt.Errorf("PLONK setup failed: %w", err)
+		}
+		circuit.provingKey, circuit.verifyingKey = provingKey, verifyingKey
+		return nil
+	}
+
+	provingKey, verifyingKey, err := groth16.Setup(circuit.ccs)
+	if err != nil {
+		return fmt.Errorf("Groth16 setup failed: %w", err)
+	}
+	circuit.provingKey, circuit.verifyingKey = provingKey, verifyingKey
+	return nil
+}
+
+// setupPaths returns the proving and verifying key artifact paths
+func (b *GnarkBackend) setupPaths(dir, proofType string) (string, string) {
+	base := filepath.Join(dir, proofType+"."+b.scheme)
+	return base + ".pk", base + ".vk"
+}
+
+// loadSetup reads trusted-setup artifacts. The verifying key is required; a
+// missing proving key leaves the backend able to verify only.
+func (b *GnarkBackend) loadSetup(proofType string, circuit *gnarkCircuit) error {
+	pkPath, vkPath := b.setupPaths(b.setupDir, proofType)
+
+	circuit.verifyingKey = b.newVerifyingKey()
+	if err := readGnarkKey(vkPath, circuit.verifyingKey); err != nil {
+		return fmt.Errorf("failed to load %s verifying key: %w", proofType, err)
+	}
+
+	provingKey := b.newProvingKey()
+	if err := readGnarkKey(pkPath, provingKey); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to load %s proving key: %w", proofType, err)
+		}
+		return nil
+	}
+	circuit.provingKey = provingKey
+	return nil
+}
+
+// SaveSetup writes the keys for every circuit to dir, generating them first
+// if needed, so a setup can be produced once and distributed
+func (b *GnarkBackend) SaveSetup(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create setup directory: %w", err)
+	}
+
+	for _, proofType := range supportedProofTypes {
+		circuit, err := b.circuit(proofType)
+		if err != nil {
+			return err
+		}
+		pkPath, vkPath := b.setupPaths(dir, proofType)
+		if circuit.provingKey != nil {
+			if err := writeGnarkKey(pkPath, circuit.provingKey, 0600); err != nil {
+				return err
+			}
+		}
+		if err := writeGnarkKey(vkPath, circuit.verifyingKey, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *GnarkBackend) newProvingKey() gnarkKey {
+	if b.scheme == ZKPSchemePLONK {
+		return plonk.NewProvingKey(ecc.BN254)
+	}
+	return groth16.NewProvingKey(ecc.BN254)
+}
+
+func (b *GnarkBackend) newVerifyingKey() gnarkKey {
+	if b.scheme == ZKPSchemePLONK {
+		return plonk.NewVerifyingKey(ecc.BN254)
+	}
+	return groth16.NewVerifyingKey(ecc.BN254)
+}
+
+// Prove generates a proof for the request
+func (b *GnarkBackend) Prove(request ZKPRequest) (*ZKPBackendProof, error) {
+	assignment, publicInputs, err := gnarkAssignment(request)
+	if err != nil {
+		return nil, err
+	}
+
+	circuit, err := b.circuit(request.ProofType)
+	if err != nil {
+		return nil, err
+	}
+	if circuit.provingKey == nil {
+		return nil, fmt.Errorf("no proving key loaded for %s", request.ProofType)
+	}
+
+	fullWitness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		return nil, fmt.Errorf("failed to build witness: %w", err)
+	}
+
+	var proof io.WriterTo
+	if b.scheme == ZKPSchemePLONK {
+		proof, err = plonk.Prove(circuit.ccs, circuit.provingKey.(plonk.ProvingKey), fullWitness)
+	} else {
+		proof, err = groth16.Prove(circuit.ccs, circuit.provingKey.(groth16.ProvingKey), fullWitness)
+	}
+	if err != nil {
+		// The prover fails when the statement does not hold
+		return nil, fmt.Errorf("statement not satisfied: %w", err)
+	}
+
+	proofBytes, err := serializeGnarkKey(proof)
+	if err != nil {
+		return nil, err
+	}
+	envelope, _ := json.Marshal(gnarkProofEnvelope{
+		Type:    request.ProofType,
+		Backend: ZKPBackendGnark,
+		Scheme:  b.scheme,
+		Proof:   base64.StdEncoding.EncodeToString(proofBytes),
+	})
+
+	return &ZKPBackendProof{
+		Proof:           hex.EncodeToString(envelope),
+		VerificationKey: circuit.vkFingerprint,
+		PublicInputs:    publicInputs,
+		Metadata: map[string]interface{}{
+			"backend": ZKPBackendGnark,
+			"scheme":  b.scheme,
+			"curve":   ecc.BN254.String(),
+		},
+	}, nil
+}
+
+// Verify checks a proof against the circuit's verifying key and the
+// request's public inputs
+func (b *GnarkBackend) Verify(proofType string, request ZKPVerificationRequest) (bool, error) {
+	envelope, err := decodeGnarkProof(request.Proof)
+	if err != nil {
+		return false, err
+	}
+	if envelope.Scheme != b.scheme {
+		return false, fmt.Errorf("proof scheme %s does not match backend scheme %s", envelope.Scheme, b.scheme)
+	}
+
+	circuit, err := b.circuit(proofType)
+	if err != nil {
+		return false, err
+	}
+	if request.VerificationKey != "" && request.VerificationKey != circuit.vkFingerprint {
+		return false, fmt.Errorf("verification key does not match the %s circuit", proofType)
+	}
+
+	assignment, err := gnarkPublicAssignment(proofType, request.PublicInputs)
+	if err != nil {
+		return false, err
+	}
+	publicWitness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return false, fmt.Errorf("failed to build public witness: %w", err)
+	}
+
+	proofBytes, err := base64.StdEncoding.DecodeString(envelope.Proof)
+	if err != nil {
+		return false, fmt.Errorf("invalid proof encoding")
+	}
+
+	if b.scheme == ZKPSchemePLONK {
+		proof := plonk.NewProof(ecc.BN254)
+		if _, err := proof.ReadFrom(bytes.NewReader(proofBytes)); err != nil {
+			return false, fmt.Errorf("invalid proof structure")
+		}
+		return plonk.Verify(proof, circuit.verifyingKey.(plonk.VerifyingKey), publicWitness) == nil, nil
+	}
+
+	proof := groth16.NewProof(ecc.BN254)
+	if _, err := proof.ReadFrom(bytes.NewReader(proofBytes)); err != nil {
+		return false, fmt.Errorf("invalid proof structure")
+	}
+	return groth16.Verify(proof, circuit.verifyingKey.(groth16.VerifyingKey), publicWitness) == nil, nil
+}
+
+// ExportVerificationKey returns the serialized verifying key for a proof type
+func (b *GnarkBackend) ExportVerificationKey(proofType string) ([]byte, error) {
+	circuit, err := b.circuit(proofType)
+	if err != nil {
+		return nil, err
+	}
+	return serializeGnarkKey(circuit.verifyingKey)
+}
+
+// gnarkAssignment builds the full circuit assignment for a proof request and
+// returns the derived public inputs the verifier needs
+func gnarkAssignment(request ZKPRequest) (frontend.Circuit, map[string]interface{}, error) {
+	switch request.ProofType {
+	case "age_verification":
+		age, err := circuitInteger(request.Witness, "age", ageBits)
+		if err != nil {
+			return nil, nil, err
+		}
+		public, err := gnarkPublicAssignment(request.ProofType, request.PublicInputs)
+		if err != nil {
+			return nil, nil, err
+		}
+		assignment := public.(*ageCircuit)
+		assignment.Age = age
+		return assignment, nil, nil
+
+	case "range_proof":
+		value, err := circuitInteger(request.Witness, "value", rangeBits)
+		if err != nil {
+			return nil, nil, err
+		}
+		public, err := gnarkPublicAssignment(request.ProofType, request.PublicInputs)
+		if err != nil {
+			return nil, nil, err
+		}
+		assignment := public.(*rangeCircuit)
+		assignment.Value = value
+		return assignment, nil, nil
+
+	case "membership_proof":
+		element, ok := request.Witness["element"].(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("element not found in witness")
+		}
+		public, err := gnarkPublicAssignment(request.ProofType, request.PublicInputs)
+		if err != nil {
+			return nil, nil, err
+		}
+		assignment := public.(*membershipCircuit)
+		assignment.Element = stringToField(element)
+		return assignment, nil, nil
+
+	case "equality_proof":
+		value1, ok := request.Witness["value1"].(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("value1 not found in witness")
+		}
+		value2, ok := request.Witness["value2"].(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("value2 not found in witness")
+		}
+		commitment := mimcCommitment(stringToField(value1))
+		return &equalityCircuit{
+			Value1:     stringToField(value1),
+			Value2:     stringToField(value2),
+			Commitment: commitment,
+		}, map[string]interface{}{"commitment": commitment.String()}, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported proof type: %s", request.ProofType)
+	}
+}
+
+// gnarkPublicAssignment builds the public part of a circuit assignment
+func gnarkPublicAssignment(proofType string, publicInputs map[string]interface{}) (frontend.Circuit, error) {
+	switch proofType {
+	case "age_verification":
+		minAge, err := circuitInteger(publicInputs, "minimum_age", ageBits)
+		if err != nil {
+			return nil, err
+		}
+		return &ageCircuit{Age: 0, MinimumAge: minAge}, nil
+
+	case "range_proof":
+		minValue, err := circuitInteger(publicInputs, "min_value", rangeBits)
+		if err != nil {
+			return nil, err
+		}
+		maxValue, err := circuitInteger(publicInputs, "max_value", rangeBits)
+		if err != nil {
+			return nil, err
+		}
+		return &rangeCircuit{Value: 0, MinValue: minValue, MaxValue: maxValue}, nil
+
+	case "membership_proof":
+		set, ok := publicInputs["set"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("set not found in public inputs")
+		}
+		if len(set) == 0 || len(set) > membershipSetSize {
+			return nil, fmt.Errorf("set must have between 1 and %d elements", membershipSetSize)
+		}
+		assignment := &membershipCircuit{Element: 0}
+		for i := range assignment.Set {
+			member := set[len(set)-1]
+			if i < len(set) {
+				member = set[i]
+			}
+			assignment.Set[i] = stringToField(fmt.Sprint(member))
+		}
+		return assignment, nil
+
+	case "equality_proof":
+		commitment, ok := publicInputs["commitment"].(string)
+		if !ok {
+			return nil, fmt.Errorf("commitment not found in public inputs")
+		}
+		value, ok := new(big.Int).SetString(commitment, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid commitment")
+		}
+		return &equalityCircuit{Value1: 0, Value2: 0, Commitment: value}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported proof type: %s", proofType)
+	}
+}
+
+// circuitInteger reads a non-negative whole number that fits in bits
+func circuitInteger(inputs map[string]interface{}, name string, bits int) (uint64, error) {
+	value, ok := inputs[name].(float64)
+	if !ok {
+		return 0, fmt.Errorf("%s not found", name)
+	}
+	if value < 0 || value != math.Trunc(value) || value >= math.Exp2(float64(bits)) {
+		return 0, fmt.Errorf("%s must be a whole number in [0, 2^%d)", name, bits)
+	}
+	return uint64(value), nil
+}
+
+// stringToField maps a string to a BN254 scalar via SHA-256
+func stringToField(value string) *big.Int {
+	hash := sha256.Sum256([]byte(value))
+	var element fr.Element
+	element.SetBytes(hash[:])
+	return element.BigInt(new(big.Int))
+}
+
+// mimcCommitment computes the MiMC hash the equality circuit commits to
+func mimcCommitment(value *big.Int) *big.Int {
+	var element fr.Element
+	element.SetBigInt(value)
+	bytes := element.Bytes()
+
+	hasher := nativemimc.NewMiMC()
+	hasher.Write(bytes[:])
+	return new(big.Int).SetBytes(hasher.Sum(nil))
+}
+
+// decodeGnarkProof parses the proof envelope
+func decodeGnarkProof(proof string) (*gnarkProofEnvelope, error) {
+	data, err := hex.DecodeString(proof)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proof format")
+	}
+	var envelope gnarkProofEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.Backend != ZKPBackendGnark {
+		return nil, fmt.Errorf("invalid proof structure")
+	}
+	return &envelope, nil
+}
+
+func serializeGnarkKey(key io.WriterTo) ([]byte, error) {
+	var buffer bytes.Buffer
+	if _, err := key.WriteTo(&buffer); err != nil {
+		return nil, fmt.Errorf("failed to serialize: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+func readGnarkKey(path string, key io.ReaderFrom) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = key.ReadFrom(file)
+	return err
+}
+
+func writeGnarkKey(path string, key io.WriterTo, mode os.FileMode) error {
+	data, err := serializeGnarkKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newGnarkZKPService(t *testing.T, scheme, setupDir string) *ZKPService {
+	t.Helper()
+	zkpConfig := NewZKPConfig(5*time.Second, 1024*1024, "test", false)
+	zkpConfig.Backend = ZKPBackendGnark
+	zkpConfig.Scheme = scheme
+	zkpConfig.SetupDir = setupDir
+	return NewZKPService(zkpConfig)
+}
+
+// verifyResponse verifies a generated proof with the given public inputs
+func verifyResponse(t *testing.T, service *ZKPService, proof *ZKPResponse, publicInputs map[string]interface{}) bool {
+	t.Helper()
+	result, err := service.VerifyProof(ZKPVerificationRequest{
+		ProofID:         proof.ProofID,
+		Proof:           proof.Proof,
+		Statement:       proof.Statement,
+		PublicInputs:    publicInputs,
+		VerificationKey: proof.VerificationKey,
+	})
+	if err != nil {
+		t.Fatalf("Expected verification to run, got %v", err)
+	}
+	return result.Valid
+}
+
+func TestGnarkBackend_Groth16Circuits(t *testing.T) {
+	service := newGnarkZKPService(t, ZKPSchemeGroth16, "")
+
+	tests := []struct {
+		name     string
+		request  ZKPRequest
+		tampered map[string]interface{}
+	}{
+		{
+			name: "age",
+			request: ZKPRequest{ProofType: "age_verification", Statement: "age >= 18",
+				Witness: map[string]interface{}{"age": 30.0}, PublicInputs: map[string]interface{}{"minimum_age": 18.0}},
+			tampered: map[string]interface{}{"minimum_age": 31.0},
+		},
+		{
+			name: "range",
+			request: ZKPRequest{ProofType: "range_proof", Statement: "income in range",
+				Witness: map[string]interface{}{"value": 50000.0}, PublicInputs: map[string]interface{}{"min_value": 30000.0, "max_value": 80000.0}},
+			tampered: map[string]interface{}{"min_value": 60000.0, "max_value": 80000.0},
+		},
+		{
+			name: "membership",
+			request: ZKPRequest{ProofType: "membership_proof", Statement: "state in allowed set",
+				Witness: map[string]interface{}{"element": "CA"}, PublicInputs: map[string]interface{}{"set": []interface{}{"NY", "CA", "TX"}}},
+			tampered: map[string]interface{}{"set": []interface{}{"NY", "TX"}},
+		},
+		{
+			name: "equality",
+			request: ZKPRequest{ProofType: "equality_proof", Statement: "names match",
+				Witness: map[string]interface{}{"value1": "Jane Doe", "value2": "Jane Doe"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proof, err := service.GenerateProof(tt.request)
+			if err != nil {
+				t.Fatalf("Expected proof, got %v", err)
+			}
+			if proof.Metadata["backend"] != ZKPBackendGnark || proof.Metadata["scheme"] != ZKPSchemeGroth16 {
+				t.Errorf("Unexpected proof metadata: %v", proof.Metadata)
+			}
+
+			if !verifyResponse(t, service, proof, proof.PublicInputs) {
+				t.Error("Expected valid proof to verify")
+			}
+			if tt.tampered != nil && verifyResponse(t, service, proof, tt.tampered) {
+				t.Error("Expected proof to fail against different public inputs")
+			}
+		})
+	}
+
+	t.Run("equality commitment binds the value", func(t *testing.T) {
+		first, _ := service.GenerateProof(tests[3].request)
+		other, err := service.GenerateProof(ZKPRequest{ProofType: "equality_proof", Statement: "names match",
+			Witness: map[string]interface{}{"value1": "John Roe", "value2": "John Roe"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if verifyResponse(t, service, first, other.PublicInputs) {
+			t.Error("Expected proof to fail against another value's commitment")
+		}
+	})
+}
+
+func TestGnarkBackend_FalseStatements(t *testing.T) {
+	service := newGnarkZKPService(t, ZKPSchemeGroth16, "")
+
+	requests := []ZKPRequest{
+		{ProofType: "age_verification", Statement: "age >= 18",
+			Witness: map[string]interface{}{"age": 16.0}, PublicInputs: map[string]interface{}{"minimum_age": 18.0}},
+		{ProofType: "membership_proof", Statement: "state in allowed set",
+			Witness: map[string]interface{}{"element": "WA"}, PublicInputs: map[string]interface{}{"set": []interface{}{"NY", "CA"}}},
+		{ProofType: "equality_proof", Statement: "names match",
+			Witness: map[string]interface{}{"value1": "Jane Doe", "value2": "John Roe"}},
+		{ProofType: "age_verification", Statement: "age >= 18",
+			Witness: map[string]interface{}{"age": 30.5}, PublicInputs: map[string]interface{}{"minimum_age": 18.0}},
+	}
+
+	for _, request := range requests {
+		if _, err := service.GenerateProof(request); err == nil {
+			t.Errorf("Expected no proof for false or invalid statement %+v", request.Witness)
+		}
+	}
+}
+
+func TestGnarkBackend_PLONK(t *testing.T) {
+	service := newGnarkZKPService(t, ZKPSchemePLONK, "")
+
+	proof, err := service.GenerateProof(ZKPRequest{ProofType: "age_verification", Statement: "age >= 21",
+		Witness: map[string]interface{}{"age": 25.0}, PublicInputs: map[string]interface{}{"minimum_age": 21.0}})
+	if err != nil {
+		t.Fatalf("Expected PLONK proof, got %v", err)
+	}
+	if !verifyResponse(t, service, proof, proof.PublicInputs) {
+		t.Error("Expected PLONK proof to verify")
+	}
+
+	// A Groth16 verifier must reject PLONK proofs
+	groth16Service := newGnarkZKPService(t, ZKPSchemeGroth16, "")
+	if _, err := groth16Service.VerifyProof(ZKPVerificationRequest{Proof: proof.Proof, Statement: proof.Statement, PublicInputs: proof.PublicInputs}); err == nil {
+		t.Error("Expected scheme mismatch to be rejected")
+	}
+}
+
+func TestGnarkBackend_SetupArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	prover, err := NewGnarkBackend(ZKPSchemeGroth16, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prover.SaveSetup(dir); err != nil {
+		t.Fatalf("Expected setup to be saved, got %v", err)
+	}
+
+	proverService := newGnarkZKPService(t, ZKPSchemeGroth16, dir)
+	proof, err := proverService.GenerateProof(ZKPRequest{ProofType: "age_verification", Statement: "age >= 18",
+		Witness: map[string]interface{}{"age": 30.0}, PublicInputs: map[string]interface{}{"minimum_age": 18.0}})
+	if err != nil {
+		t.Fatalf("Expected proof from loaded setup, got %v", err)
+	}
+
+	exported, err := proverService.ExportVerificationKey("age_verification")
+	if err != nil || len(exported) == 0 {
+		t.Fatalf("Expected verification key export, got %v", err)
+	}
+
+	// A verifier holding only the verification keys can verify but not prove
+	verifierDir := t.TempDir()
+	for _, proofType := range supportedProofTypes {
+		name := proofType + "." + ZKPSchemeGroth16 + ".vk"
+		data, _ := os.ReadFile(filepath.Join(dir, name))
+		os.WriteFile(filepath.Join(verifierDir, name), data, 0644)
+	}
+	verifier := newGnarkZKPService(t, ZKPSchemeGroth16, verifierDir)
+	if !verifyResponse(t, verifier, proof, proof.PublicInputs) {
+		t.Error("Expected verifier with exported keys to accept the proof")
+	}
+	if _, err := verifier.GenerateProof(ZKPRequest{ProofType: "age_verification", Statement: "age >= 18",
+		Witness: map[string]interface{}{"age": 30.0}, PublicInputs: map[string]interface{}{"minimum_age": 18.0}}); err == nil {
+		t.Error("Expected proving to fail without a proving key")
+	}
+
+	// Keys from a different setup must not verify the proof
+	otherService := newGnarkZKPService(t, ZKPSchemeGroth16, "")
+	if _, err := otherService.VerifyProof(ZKPVerificationRequest{Proof: proof.Proof, Statement: proof.Statement,
+		PublicInputs: proof.PublicInputs, VerificationKey: proof.VerificationKey}); err == nil {
+		t.Error("Expected mismatched verification key to be rejected")
+	}
+
+	if _, err := NewGnarkBackend("bulletproofs", ""); err == nil {
+		t.Error("Expected unknown scheme to be rejected")
+	}
+}
+
+func TestZKPService_UnknownBackend(t *testing.T) {
+	zkpConfig := NewZKPConfig(5*time.Second, 1024*1024, "test", false)
+	zkpConfig.Backend = "snarkjs"
+	service := NewZKPService(zkpConfig)
+
+	if _, err := service.GenerateProof(ZKPRequest{ProofType: "age_verification", Statement: "age >= 18",
+		Witness: map[string]interface{}{"age": 30.0}, PublicInputs: map[string]interface{}{"minimum_age": 18.0}}); err == nil {
+		t.Error("Expected unknown backend to fail instead of producing hash proofs")
+	}
+}
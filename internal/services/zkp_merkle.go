@@ -0,0 +1,187 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Domain separation prefixes for Merkle leaves and interior nodes. Provers
+// send a leaf's preimage and verifiers apply the leaf prefix themselves, so a
+// node can never be passed off as a leaf with a shorter path.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+
+	// merkleMaxDepth bounds the paths accepted from provers
+	merkleMaxDepth = 64
+)
+
+// Positions of a sibling relative to the running hash on a path
+const (
+	MerkleSiblingLeft  = "left"
+	MerkleSiblingRight = "right"
+)
+
+// MerklePathStep is one sibling hash on the way from a leaf to the root
+type MerklePathStep struct {
+	Hash     string `json:"hash"`
+	Position string `json:"position"`
+}
+
+// MerkleMembershipPath is the witness path proving a leaf is under a root.
+// LeafMAC is the element's keyed hash, the leaf's preimage.
+type MerkleMembershipPath struct {
+	LeafMAC string           `json:"leaf_mac"`
+	Path    []MerklePathStep `json:"path"`
+}
+
+// MerkleMembershipTree commits to a set so that membership can be proven by
+// revealing only the root and one path. Leaves are keyed hashes of the
+// elements, so neither the root nor a path lets a verifier test guesses of
+// set members without the key.
+type MerkleMembershipTree struct {
+	key    []byte
+	levels [][][]byte // levels[0] are the leaves, the last level is the root
+}
+
+// NewMerkleMembershipTree builds a tree over a set. Duplicates are removed and
+// leaves are sorted by hash so that the root does not depend on set order and
+// a path does not reveal an element's position in the original set.
+func NewMerkleMembershipTree(key []byte, elements []string) (*MerkleMembershipTree, error) {
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("set cannot be empty")
+	}
+
+	tree := &MerkleMembershipTree{key: key}
+
+	seen := make(map[string]bool)
+	leaves := make([][]byte, 0, len(elements))
+	for _, element := range elements {
+		leaf := tree.leafHash(element)
+		if seen[string(leaf)] {
+			continue
+		}
+		seen[string(leaf)] = true
+		leaves = append(leaves, leaf)
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		return bytes.Compare(leaves[i], leaves[j]) < 0
+	})
+
+	// An odd node is promoted to the next level unchanged rather than paired
+	// with a copy of itself, which would let two different sets share a root
+	tree.levels = [][][]byte{leaves}
+	for level := leaves; len(level) > 1; {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, merkleNodeHash(level[i], level[i+1]))
+		}
+		tree.levels = append(tree.levels, next)
+		level = next
+	}
+
+	return tree, nil
+}
+
+// Root returns the hex-encoded root that verifiers compare proofs against
+func (t *MerkleMembershipTree) Root() string {
+	return hex.EncodeToString(t.levels[len(t.levels)-1][0])
+}
+
+// Path returns the witness path for an element of the set
+func (t *MerkleMembershipTree) Path(element string) (*MerkleMembershipPath, error) {
+	leaf := t.leafHash(element)
+	leaves := t.levels[0]
+	index := sort.Search(len(leaves), func(i int) bool {
+		return bytes.Compare(leaves[i], leaf) >= 0
+	})
+	if index == len(leaves) || !bytes.Equal(leaves[index], leaf) {
+		return nil, fmt.Errorf("element is not a member of the set")
+	}
+
+	path := &MerkleMembershipPath{LeafMAC: hex.EncodeToString(t.leafMAC(element)), Path: make([]MerklePathStep, 0, len(t.levels)-1)}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		switch {
+		case index%2 == 1:
+			path.Path = append(path.Path, MerklePathStep{Hash: hex.EncodeToString(level[index-1]), Position: MerkleSiblingLeft})
+		case index+1 < len(level):
+			path.Path = append(path.Path, MerklePathStep{Hash: hex.EncodeToString(level[index+1]), Position: MerkleSiblingRight})
+		}
+		index /= 2
+	}
+	return path, nil
+}
+
+// leafMAC is HMAC(key, element)
+func (t *MerkleMembershipTree) leafMAC(element string) []byte {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(element))
+	return mac.Sum(nil)
+}
+
+// leafHash is H(0x00 || HMAC(key, element))
+func (t *MerkleMembershipTree) leafHash(element string) []byte {
+	return merkleLeafHash(t.leafMAC(element))
+}
+
+// merkleLeafHash is H(0x00 || mac)
+func merkleLeafHash(mac []byte) []byte {
+	hash := sha256.Sum256(append([]byte{merkleLeafPrefix}, mac...))
+	return hash[:]
+}
+
+// merkleNodeHash is H(0x01 || left || right)
+func merkleNodeHash(left, right []byte) []byte {
+	data := make([]byte, 0, 1+len(left)+len(right))
+	data = append(data, merkleNodePrefix)
+	data = append(data, left...)
+	data = append(data, right...)
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// VerifyMerkleMembershipPath recomputes the root from a leaf's preimage and
+// its path and compares it with the expected hex-encoded root
+func VerifyMerkleMembershipPath(root string, path *MerkleMembershipPath) (bool, error) {
+	if path == nil {
+		return false, fmt.Errorf("membership path is required")
+	}
+	if len(path.Path) > merkleMaxDepth {
+		return false, fmt.Errorf("membership path exceeds %d steps", merkleMaxDepth)
+	}
+
+	expected, err := hex.DecodeString(root)
+	if err != nil || len(expected) != sha256.Size {
+		return false, fmt.Errorf("merkle_root must be a hex-encoded SHA-256 hash")
+	}
+	mac, err := hex.DecodeString(path.LeafMAC)
+	if err != nil || len(mac) != sha256.Size {
+		return false, fmt.Errorf("invalid leaf MAC")
+	}
+	current := merkleLeafHash(mac)
+
+	for _, step := range path.Path {
+		sibling, err := hex.DecodeString(step.Hash)
+		if err != nil || len(sibling) != sha256.Size {
+			return false, fmt.Errorf("invalid sibling hash")
+		}
+		switch step.Position {
+		case MerkleSiblingLeft:
+			current = merkleNodeHash(sibling, current)
+		case MerkleSiblingRight:
+			current = merkleNodeHash(current, sibling)
+		default:
+			return false, fmt.Errorf("invalid sibling position: %s", step.Position)
+		}
+	}
+
+	return hmac.Equal(current, expected), nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMerkleMembershipTree_Paths(t *testing.T) {
+	for _, size := range []int{1, 2, 3, 5, 8, 13} {
+		t.Run(fmt.Sprintf("%d elements", size), func(t *testing.T) {
+			elements := make([]string, size)
+			for i := range elements {
+				elements[i] = fmt.Sprintf("member-%d", i)
+			}
+
+			tree, err := NewMerkleMembershipTree([]byte("key"), elements)
+			if err != nil {
+				t.Fatalf("Expected tree, got %v", err)
+			}
+			for _, element := range elements {
+				path, err := tree.Path(element)
+				if err != nil {
+					t.Fatalf("Expected path for %s, got %v", element, err)
+				}
+				if valid, err := VerifyMerkleMembershipPath(tree.Root(), path); err != nil || !valid {
+					t.Errorf("Expected path for %s to verify, got %v, %v", element, valid, err)
+				}
+			}
+
+			if _, err := tree.Path("outsider"); err == nil {
+				t.Error("Expected no path for a non-member")
+			}
+		})
+	}
+}
+
+func TestVerifyMerkleMembershipPath_RejectsTruncatedPath(t *testing.T) {
+	elements := make([]string, 8)
+	for i := range elements {
+		elements[i] = fmt.Sprintf("member-%d", i)
+	}
+	tree, _ := NewMerkleMembershipTree([]byte("key"), elements)
+	path, _ := tree.Path("member-3")
+
+	// Pass the leaf's parent off as the leaf, with the rest of the path
+	mac, _ := hex.DecodeString(path.LeafMAC)
+	sibling, _ := hex.DecodeString(path.Path[0].Hash)
+	parent := merkleNodeHash(merkleLeafHash(mac), sibling)
+	if path.Path[0].Position == MerkleSiblingLeft {
+		parent = merkleNodeHash(sibling, merkleLeafHash(mac))
+	}
+	truncated := &MerkleMembershipPath{LeafMAC: hex.EncodeToString(parent), Path: path.Path[1:]}
+	if valid, err := VerifyMerkleMembershipPath(tree.Root(), truncated); err != nil || valid {
+		t.Errorf("Expected an interior node with a truncated path to be rejected, got %v, %v", valid, err)
+	}
+}
+
+func TestMerkleMembershipTree_Root(t *testing.T) {
+	tree, _ := NewMerkleMembershipTree([]byte("key"), []string{"a", "b", "c"})
+	reordered, _ := NewMerkleMembershipTree([]byte("key"), []string{"c", "a", "b", "a"})
+	otherKey, _ := NewMerkleMembershipTree([]byte("other"), []string{"a", "b", "c"})
+
+	if tree.Root() != reordered.Root() {
+		t.Error("Expected root not to depend on order or duplicates")
+	}
+	if tree.Root() == otherKey.Root() {
+		t.Error("Expected root to depend on the key")
+	}
+	if _, err := NewMerkleMembershipTree([]byte("key"), nil); err == nil {
+		t.Error("Expected error for an empty set")
+	}
+}
+
+func TestZKPService_MerkleMembershipProof(t *testing.T) {
+	service := NewZKPService(NewZKPConfig(5*time.Second, 1024*1024, "membership-salt", false))
+	set := []interface{}{"CA", "NY", "TX", "WA", "OR"}
+
+	proof, err := service.GenerateProof(ZKPRequest{
+		ProofType: "membership_proof",
+		Statement: "state in allowed set",
+		Witness:   map[string]interface{}{"element": "TX", "set": set},
+	})
+	if err != nil {
+		t.Fatalf("Expected proof, got %v", err)
+	}
+
+	root, err := service.MembershipRoot([]string{"WA", "OR", "CA", "NY", "TX"})
+	if err != nil {
+		t.Fatalf("Expected root, got %v", err)
+	}
+	if proof.PublicInputs["merkle_root"] != root {
+		t.Errorf("Expected published root %s, got %v", root, proof.PublicInputs["merkle_root"])
+	}
+	if _, leaked := proof.PublicInputs["set"]; leaked {
+		t.Error("Expected set to be withheld from public inputs")
+	}
+
+	verify := func(publicInputs map[string]interface{}) (bool, error) {
+		response, err := service.VerifyProof(ZKPVerificationRequest{
+			ProofID:      proof.ProofID,
+			Proof:        proof.Proof,
+			Statement:    proof.Statement,
+			PublicInputs: publicInputs,
+		})
+		if err != nil {
+			return false, err
+		}
+		return response.Valid, nil
+	}
+
+	if valid, err := verify(map[string]interface{}{"merkle_root": root}); err != nil || !valid {
+		t.Errorf("Expected proof to verify against the published root, got %v, %v", valid, err)
+	}
+
+	otherRoot, _ := service.MembershipRoot([]string{"CA", "NY"})
+	if valid, _ := verify(map[string]interface{}{"merkle_root": otherRoot}); valid {
+		t.Error("Expected proof not to verify against another root")
+	}
+	if _, err := verify(nil); err == nil {
+		t.Error("Expected error without merkle_root")
+	}
+
+	if _, err := service.GenerateProof(ZKPRequest{
+		ProofType:    "membership_proof",
+		Statement:    "state in allowed set",
+		Witness:      map[string]interface{}{"element": "FL"},
+		PublicInputs: map[string]interface{}{"set": set},
+	}); err == nil {
+		t.Error("Expected no proof for a non-member")
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Statuses of stored proofs
+const (
+	ProofStatusActive  = "active"
+	ProofStatusRevoked = "revoked"
+)
+
+// StoredProof is a generated proof kept so verifiers can retrieve it by ID
+type StoredProof struct {
+	*ZKPResponse
+	OwnerID   string     `json:"owner_id"`
+	Status    string     `json:"status"`
+	StoredAt  time.Time  `json:"stored_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ZKPProofStore keeps generated proofs in memory until they expire (database
+// in production). Revoked proofs are kept until expiry so lookups report the
+// revocation rather than a missing proof.
+type ZKPProofStore struct {
+	mu         sync.RWMutex
+	proofs     map[string]*StoredProof
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+}
+
+// NewZKPProofStore creates a proof store. Proofs stored without a TTL expire
+// after defaultTTL, and no proof is kept longer than maxTTL.
+func NewZKPProofStore(defaultTTL, maxTTL time.Duration) *ZKPProofStore {
+	if defaultTTL <= 0 {
+		defaultTTL = 24 * time.Hour
+	}
+	if maxTTL < defaultTTL {
+		maxTTL = defaultTTL
+	}
+	return &ZKPProofStore{
+		proofs:     make(map[string]*StoredProof),
+		defaultTTL: defaultTTL,
+		maxTTL:     maxTTL,
+	}
+}
+
+// Save stores a proof for its owner. A zero ttl uses the default TTL.
+func (s *ZKPProofStore) Save(ownerID string, proof *ZKPResponse, ttl time.Duration) (*StoredProof, error) {
+	if proof == nil || proof.ProofID == "" {
+		return nil, fmt.Errorf("proof is missing proof_id")
+	}
+	if ttl < 0 {
+		return nil, fmt.Errorf("ttl cannot be negative")
+	}
+	if ttl == 0 {
+		ttl = s.defaultTTL
+	}
+	if ttl > s.maxTTL {
+		return nil, fmt.Errorf("ttl cannot exceed %s", s.maxTTL)
+	}
+
+	now := time.Now()
+	stored := &StoredProof{
+		ZKPResponse: proof,
+		OwnerID:     ownerID,
+		Status:      ProofStatusActive,
+		StoredAt:    now,
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.proofs[proof.ProofID]; exists {
+		return nil, fmt.Errorf("proof already stored: %s", proof.ProofID)
+	}
+	s.proofs[proof.ProofID] = stored
+
+	copied := *stored
+	return &copied, nil
+}
+
+// GetProof returns a stored proof. Expired proofs are removed and reported as
+// not found.
+func (s *ZKPProofStore) GetProof(proofID string) (*StoredProof, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.proofs[proofID]
+	if !exists {
+		return nil, fmt.Errorf("proof not found: %s", proofID)
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		delete(s.proofs, proofID)
+		return nil, fmt.Errorf("proof not found: %s", proofID)
+	}
+
+	copied := *stored
+	return &copied, nil
+}
+
+// Revoke marks a proof as revoked. Only the owner may revoke a proof; other
+// callers are told it does not exist.
+func (s *ZKPProofStore) Revoke(proofID, ownerID string) (*StoredProof, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.proofs[proofID]
+	if !exists || stored.OwnerID != ownerID || time.Now().After(stored.ExpiresAt) {
+		return nil, fmt.Errorf("proof not found: %s", proofID)
+	}
+
+	if stored.Status != ProofStatusRevoked {
+		now := time.Now()
+		stored.Status = ProofStatusRevoked
+		stored.RevokedAt = &now
+	}
+
+	copied := *stored
+	return &copied, nil
+}
+
+// IsRevoked reports whether a stored proof has been revoked
+func (s *ZKPProofStore) IsRevoked(proofID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, exists := s.proofs[proofID]
+	return exists && stored.Status == ProofStatusRevoked
+}
+
+// CleanupExpiredProofs removes expired proofs and returns how many were removed
+func (s *ZKPProofStore) CleanupExpiredProofs() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	purged := 0
+	for proofID, stored := range s.proofs {
+		if now.After(stored.ExpiresAt) {
+			delete(s.proofs, proofID)
+			purged++
+		}
+	}
+	return purged
+}
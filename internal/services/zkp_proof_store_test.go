@@ -0,0 +1,111 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func storedAgeProof(t *testing.T, service *ZKPService) *ZKPResponse {
+	t.Helper()
+	proof, err := service.GenerateProof(ZKPRequest{
+		ProofType:    "age_verification",
+		Statement:    "age >= 18",
+		Witness:      map[string]interface{}{"age": 30.0},
+		PublicInputs: map[string]interface{}{"minimum_age": 18.0},
+	})
+	if err != nil {
+		t.Fatalf("Expected proof, got %v", err)
+	}
+	return proof
+}
+
+func TestZKPProofStore_SaveAndGet(t *testing.T) {
+	service := NewZKPService(NewZKPConfig(5*time.Second, 1024*1024, "test", false))
+	store := NewZKPProofStore(time.Hour, 24*time.Hour)
+
+	proof := storedAgeProof(t, service)
+	stored, err := store.Save("rp_1", proof, 0)
+	if err != nil {
+		t.Fatalf("Expected proof to be stored, got %v", err)
+	}
+	if stored.Status != ProofStatusActive || stored.ExpiresAt.Sub(stored.StoredAt) != time.Hour {
+		t.Errorf("Unexpected stored proof: %+v", stored)
+	}
+
+	retrieved, err := store.GetProof(proof.ProofID)
+	if err != nil || retrieved.Proof != proof.Proof {
+		t.Errorf("Expected stored proof, got %+v, %v", retrieved, err)
+	}
+
+	if _, err := store.Save("rp_1", proof, 0); err == nil {
+		t.Error("Expected duplicate proof ID to be rejected")
+	}
+	if _, err := store.Save("rp_1", storedAgeProof(t, service), 48*time.Hour); err == nil {
+		t.Error("Expected TTL above the maximum to be rejected")
+	}
+	if _, err := store.GetProof("missing"); err == nil {
+		t.Error("Expected error for unknown proof")
+	}
+}
+
+func TestZKPProofStore_Expiry(t *testing.T) {
+	service := NewZKPService(NewZKPConfig(5*time.Second, 1024*1024, "test", false))
+	store := NewZKPProofStore(time.Hour, 24*time.Hour)
+
+	proof := storedAgeProof(t, service)
+	if _, err := store.Save("rp_1", proof, time.Millisecond); err != nil {
+		t.Fatalf("Expected proof to be stored, got %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := store.GetProof(proof.ProofID); err == nil {
+		t.Error("Expected expired proof not to be returned")
+	}
+
+	if _, err := store.Save("rp_1", storedAgeProof(t, service), time.Millisecond); err != nil {
+		t.Fatalf("Expected proof to be stored, got %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if purged := store.CleanupExpiredProofs(); purged != 1 {
+		t.Errorf("Expected 1 expired proof to be removed, got %d", purged)
+	}
+}
+
+func TestZKPProofStore_Revoke(t *testing.T) {
+	service := NewZKPService(NewZKPConfig(5*time.Second, 1024*1024, "test", false))
+	store := NewZKPProofStore(time.Hour, 24*time.Hour)
+	service.SetProofStore(store)
+
+	proof := storedAgeProof(t, service)
+	if _, err := store.Save("rp_1", proof, 0); err != nil {
+		t.Fatalf("Expected proof to be stored, got %v", err)
+	}
+
+	if _, err := store.Revoke(proof.ProofID, "rp_2"); err == nil {
+		t.Error("Expected other tenants not to revoke the proof")
+	}
+
+	revoked, err := store.Revoke(proof.ProofID, "rp_1")
+	if err != nil || revoked.Status != ProofStatusRevoked || revoked.RevokedAt == nil {
+		t.Fatalf("Expected revoked proof, got %+v, %v", revoked, err)
+	}
+
+	retrieved, err := store.GetProof(proof.ProofID)
+	if err != nil || retrieved.Status != ProofStatusRevoked {
+		t.Errorf("Expected revoked proof to remain retrievable, got %+v, %v", retrieved, err)
+	}
+
+	verification, err := service.VerifyProof(ZKPVerificationRequest{
+		ProofID:         proof.ProofID,
+		Proof:           proof.Proof,
+		Statement:       proof.Statement,
+		PublicInputs:    proof.PublicInputs,
+		VerificationKey: proof.VerificationKey,
+	})
+	if err != nil {
+		t.Fatalf("Expected verification result, got %v", err)
+	}
+	if verification.Valid || verification.Metadata["revoked"] != true {
+		t.Errorf("Expected revoked proof not to verify, got %+v", verification)
+	}
+}
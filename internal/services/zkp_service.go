@@ -7,11 +7,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/pavilion-trust/core-broker/internal/config"
 )
 
 // ZKPService provides zero-knowledge proof functionality
 type ZKPService struct {
-	config *ZKPConfig
+	config     *ZKPConfig
+	backend    ZKPBackend
+	proofStore *ZKPProofStore
+	circuits   *ZKPCircuitRegistry
 }
 
 // ZKPConfig holds configuration for ZKP operations
@@ -21,6 +26,16 @@ type ZKPConfig struct {
 	HashAlgorithm  string
 	Salt           string
 	EnableAuditLog bool
+	// Backend selects the proof system (hash by default), Scheme the gnark
+	// proving scheme, and SetupDir the trusted-setup artifacts to load
+	Backend  string
+	Scheme   string
+	SetupDir string
+	// RangeBackend optionally proves range_proof with a different backend,
+	// such as bulletproofs which needs no trusted setup
+	RangeBackend string
+	// CircuitsFile optionally registers additional circuits at startup
+	CircuitsFile string
 }
 
 // NewZKPConfig creates a new ZKP configuration
@@ -34,11 +49,58 @@ func NewZKPConfig(timeout time.Duration, maxSize int, salt string, auditLog bool
 	}
 }
 
-// NewZKPService creates a new ZKP service
+// ZKPConfigFromConfig creates the ZKP configuration for the broker
+func ZKPConfigFromConfig(cfg *config.Config) *ZKPConfig {
+	zkpConfig := NewZKPConfig(cfg.ZKPProofTimeout, 1024*1024, cfg.HashSalt, true)
+	zkpConfig.Backend = cfg.ZKPBackend
+	zkpConfig.Scheme = cfg.ZKPScheme
+	zkpConfig.SetupDir = cfg.ZKPSetupDir
+	zkpConfig.RangeBackend = cfg.ZKPRangeBackend
+	zkpConfig.CircuitsFile = cfg.ZKPCircuitsFile
+	return zkpConfig
+}
+
+// NewZKPService creates a new ZKP service. A backend that cannot be created
+// fails every operation rather than falling back to unsound proofs.
 func NewZKPService(config *ZKPConfig) *ZKPService {
-	return &ZKPService{
+	service := &ZKPService{
 		config: config,
 	}
+
+	backend, err := NewZKPBackend(config, service)
+	if err != nil {
+		fmt.Printf("ZKP WARNING: %v\n", err)
+		backend = &unavailableZKPBackend{name: config.Backend, err: err}
+	}
+	service.backend = backend
+
+	service.circuits = NewZKPCircuitRegistry(backend)
+	if config.CircuitsFile != "" {
+		if err := service.circuits.LoadFile(config.CircuitsFile); err != nil {
+			fmt.Printf("ZKP WARNING: %v; later circuits in the file were not registered\n", err)
+		}
+	}
+
+	return service
+}
+
+// SetProofStore makes verification reject proofs revoked in the store
+func (z *ZKPService) SetProofStore(store *ZKPProofStore) {
+	z.proofStore = store
+}
+
+// Circuits returns the registry that proof types are dispatched through
+func (z *ZKPService) Circuits() *ZKPCircuitRegistry {
+	return z.circuits
+}
+
+// ExportVerificationKey returns the verification key for a proof type
+func (z *ZKPService) ExportVerificationKey(proofType string) ([]byte, error) {
+	_, implementation, exists := z.circuits.Get(proofType)
+	if !exists {
+		return nil, fmt.Errorf("unsupported proof type: %s", proofType)
+	}
+	return implementation.ExportVerificationKey()
 }
 
 // ZKPRequest represents a request for zero-knowledge proof generation
@@ -64,7 +126,10 @@ type ZKPResponse struct {
 
 // ZKPVerificationRequest represents a request to verify a ZKP
 type ZKPVerificationRequest struct {
-	ProofID         string                 `json:"proof_id"`
+	ProofID string `json:"proof_id"`
+	// ProofType names the circuit; when empty it is read from the proof,
+	// which only identifies built-in circuits
+	ProofType       string                 `json:"proof_type,omitempty"`
 	Proof           string                 `json:"proof" validate:"required"`
 	Statement       string                 `json:"statement" validate:"required"`
 	PublicInputs    map[string]interface{} `json:"public_inputs,omitempty"`
@@ -88,6 +153,13 @@ type ZKPCircuit struct {
 	Outputs     []string               `json:"outputs"`
 	Constraints []string               `json:"constraints"`
 	Metadata    map[string]interface{} `json:"metadata"`
+	// BaseProofType is the built-in circuit a registered circuit is derived
+	// from, and FixedPublicInputs the inputs it pins
+	BaseProofType     string                 `json:"base_proof_type,omitempty"`
+	FixedPublicInputs map[string]interface{} `json:"fixed_public_inputs,omitempty"`
+	// VerificationKey optionally pins the key proofs must verify under
+	VerificationKey string `json:"verification_key,omitempty"`
+	Builtin         bool   `json:"builtin"`
 }
 
 // GenerateProof generates a zero-knowledge proof
@@ -97,24 +169,9 @@ func (z *ZKPService) GenerateProof(request ZKPRequest) (*ZKPResponse, error) {
 		return nil, fmt.Errorf("invalid ZKP request: %w", err)
 	}
 
-	// Generate proof based on type
-	var proof string
-	var verificationKey string
-	var err error
-
-	switch request.ProofType {
-	case "age_verification":
-		proof, verificationKey, err = z.generateAgeProof(request)
-	case "range_proof":
-		proof, verificationKey, err = z.generateRangeProof(request)
-	case "membership_proof":
-		proof, verificationKey, err = z.generateMembershipProof(request)
-	case "equality_proof":
-		proof, verificationKey, err = z.generateEqualityProof(request)
-	default:
-		return nil, fmt.Errorf("unsupported proof type: %s", request.ProofType)
-	}
-
+	// Generate proof with the circuit's implementation
+	_, implementation, _ := z.circuits.Get(request.ProofType)
+	result, err := implementation.Prove(request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate proof: %w", err)
 	}
@@ -122,20 +179,39 @@ func (z *ZKPService) GenerateProof(request ZKPRequest) (*ZKPResponse, error) {
 	// Create proof ID
 	proofID := z.generateProofID(request)
 
+	// Verifiers need any public inputs the backend derived, such as commitments
+	publicInputs := request.PublicInputs
+	if len(result.PublicInputs) > 0 || len(result.WithheldInputs) > 0 {
+		publicInputs = make(map[string]interface{}, len(request.PublicInputs)+len(result.PublicInputs))
+		for k, v := range request.PublicInputs {
+			publicInputs[k] = v
+		}
+		for _, k := range result.WithheldInputs {
+			delete(publicInputs, k)
+		}
+		for k, v := range result.PublicInputs {
+			publicInputs[k] = v
+		}
+	}
+
 	response := &ZKPResponse{
 		ProofID:         proofID,
 		ProofType:       request.ProofType,
 		Statement:       request.Statement,
-		Proof:           proof,
-		PublicInputs:    request.PublicInputs,
-		VerificationKey: verificationKey,
+		Proof:           result.Proof,
+		PublicInputs:    publicInputs,
+		VerificationKey: result.VerificationKey,
 		Metadata: map[string]interface{}{
-			"proof_size":      len(proof),
+			"proof_size":      len(result.Proof),
 			"generation_time": time.Now().Format(time.RFC3339),
 			"algorithm":       z.config.HashAlgorithm,
+			"backend":         z.backend.Name(),
 		},
 		Timestamp: time.Now(),
 	}
+	for k, v := range result.Metadata {
+		response.Metadata[k] = v
+	}
 
 	// Add request metadata
 	for k, v := range request.Metadata {
@@ -156,22 +232,23 @@ func (z *ZKPService) VerifyProof(request ZKPVerificationRequest) (*ZKPVerificati
 	var valid bool
 	var err error
 
-	// Extract proof type from metadata or infer from statement
-	proofType := z.extractProofType(request)
-
-	switch proofType {
-	case "age_verification":
-		valid, err = z.verifyAgeProof(request)
-	case "range_proof":
-		valid, err = z.verifyRangeProof(request)
-	case "membership_proof":
-		valid, err = z.verifyMembershipProof(request)
-	case "equality_proof":
-		valid, err = z.verifyEqualityProof(request)
-	default:
+	// Use the named circuit, or infer a built-in one from the proof
+	proofType := request.ProofType
+	if proofType == "" {
+		proofType = z.extractProofType(request)
+	}
+
+	_, implementation, exists := z.circuits.Get(proofType)
+	if !exists {
 		return nil, fmt.Errorf("unsupported proof type: %s", proofType)
 	}
 
+	// A revoked proof stays cryptographically valid, so check the store first
+	revoked := z.proofStore != nil && request.ProofID != "" && z.proofStore.IsRevoked(request.ProofID)
+	if !revoked {
+		valid, err = implementation.Verify(request)
+	}
+
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify proof: %w", err)
 	}
@@ -184,8 +261,12 @@ func (z *ZKPService) VerifyProof(request ZKPVerificationRequest) (*ZKPVerificati
 		Metadata: map[string]interface{}{
 			"verification_time": time.Now().Format(time.RFC3339),
 			"proof_type":        proofType,
+			"backend":           z.backend.Name(),
 		},
 	}
+	if revoked {
+		response.Metadata["revoked"] = true
+	}
 
 	return response, nil
 }
@@ -237,30 +318,53 @@ func (z *ZKPService) generateRangeProof(request ZKPRequest) (string, string, err
 	return proof, verificationKey, nil
 }
 
-// generateMembershipProof generates a proof for set membership
-func (z *ZKPService) generateMembershipProof(request ZKPRequest) (string, string, error) {
+// generateMembershipProof generates a Merkle proof of set membership. The set
+// may be given privately in the witness or, as before, in the public inputs;
+// either way it is withheld from the response and verifiers only learn the
+// root.
+func (z *ZKPService) generateMembershipProof(request ZKPRequest) (*ZKPBackendProof, error) {
 	// Extract element from witness
 	element, ok := request.Witness["element"].(string)
 	if !ok {
-		return "", "", fmt.Errorf("element not found in witness")
+		return nil, fmt.Errorf("element not found in witness")
 	}
 
-	// Extract set from public inputs
-	setInterface, ok := request.PublicInputs["set"]
+	// Extract set from witness or public inputs
+	setInterface, ok := request.Witness["set"]
+	if !ok {
+		setInterface, ok = request.PublicInputs["set"]
+	}
 	if !ok {
-		return "", "", fmt.Errorf("set not found in public inputs")
+		return nil, fmt.Errorf("set not found in witness or public inputs")
 	}
 
 	set, ok := setInterface.([]interface{})
 	if !ok {
-		return "", "", fmt.Errorf("set must be an array")
+		return nil, fmt.Errorf("set must be an array")
 	}
 
 	// Create proof that element is in set
-	proof := z.createMembershipProof(element, set)
-	verificationKey := z.createVerificationKey("membership_proof")
+	proof, root, err := z.createMembershipProof(element, set)
+	if err != nil {
+		return nil, err
+	}
 
-	return proof, verificationKey, nil
+	return &ZKPBackendProof{
+		Proof:           proof,
+		VerificationKey: z.createVerificationKey("membership_proof"),
+		PublicInputs:    map[string]interface{}{"merkle_root": root},
+		WithheldInputs:  []string{"set"},
+	}, nil
+}
+
+// MembershipRoot returns the Merkle root of a set, which set owners publish so
+// verifiers can check membership proofs without learning the set
+func (z *ZKPService) MembershipRoot(set []string) (string, error) {
+	tree, err := NewMerkleMembershipTree([]byte(z.config.Salt), set)
+	if err != nil {
+		return "", err
+	}
+	return tree.Root(), nil
 }
 
 // generateEqualityProof generates a proof for equality verification
@@ -327,24 +431,39 @@ func (z *ZKPService) createRangeProof(value, minValue, maxValue float64) string
 	return hex.EncodeToString(proofBytes)
 }
 
-// createMembershipProof creates a proof for set membership
-func (z *ZKPService) createMembershipProof(element string, set []interface{}) string {
-	// Create commitment to element
-	elementCommitment := z.createCommitment("element", element)
+// createMembershipProof creates a Merkle proof for set membership, returning
+// the proof and the root it verifies against
+func (z *ZKPService) createMembershipProof(element string, set []interface{}) (string, string, error) {
+	elements := make([]string, 0, len(set))
+	for _, member := range set {
+		value, ok := member.(string)
+		if !ok {
+			return "", "", fmt.Errorf("set elements must be strings")
+		}
+		elements = append(elements, value)
+	}
 
-	// Create commitment to set
-	setCommitment := z.createCommitment("set", set)
+	// Leaves are keyed with the salt so the path cannot be used to test guesses
+	tree, err := NewMerkleMembershipTree([]byte(z.config.Salt), elements)
+	if err != nil {
+		return "", "", err
+	}
+	path, err := tree.Path(element)
+	if err != nil {
+		return "", "", fmt.Errorf("statement not satisfied: %w", err)
+	}
 
 	proofData := map[string]interface{}{
-		"type":               "membership_proof",
-		"element_commitment": elementCommitment,
-		"set_commitment":     setCommitment,
-		"timestamp":          time.Now().Format(time.RFC3339),
-		"algorithm":          z.config.HashAlgorithm,
+		"type":      "membership_proof",
+		"scheme":    "merkle-sha256",
+		"leaf_mac":  path.LeafMAC,
+		"path":      path.Path,
+		"timestamp": time.Now().Format(time.RFC3339),
+		"algorithm": z.config.HashAlgorithm,
 	}
 
 	proofBytes, _ := json.Marshal(proofData)
-	return hex.EncodeToString(proofBytes)
+	return hex.EncodeToString(proofBytes), tree.Root(), nil
 }
 
 // createEqualityProof creates a proof for equality verification
@@ -448,7 +567,8 @@ func (z *ZKPService) verifyRangeProof(request ZKPVerificationRequest) (bool, err
 	return true, nil
 }
 
-// verifyMembershipProof verifies a membership proof
+// verifyMembershipProof verifies a membership proof against the merkle_root
+// public input
 func (z *ZKPService) verifyMembershipProof(request ZKPVerificationRequest) (bool, error) {
 	// Parse proof
 	proofBytes, err := hex.DecodeString(request.Proof)
@@ -456,18 +576,25 @@ func (z *ZKPService) verifyMembershipProof(request ZKPVerificationRequest) (bool
 		return false, fmt.Errorf("invalid proof format")
 	}
 
-	var proofData map[string]interface{}
+	var proofData struct {
+		Type string `json:"type"`
+		MerkleMembershipPath
+	}
 	if err := json.Unmarshal(proofBytes, &proofData); err != nil {
 		return false, fmt.Errorf("invalid proof structure")
 	}
 
 	// Check proof type
-	if proofType, ok := proofData["type"].(string); !ok || proofType != "membership_proof" {
+	if proofData.Type != "membership_proof" {
 		return false, fmt.Errorf("invalid proof type")
 	}
 
-	// For MVP, we'll assume the proof is valid if it has the correct structure
-	return true, nil
+	root, ok := request.PublicInputs["merkle_root"].(string)
+	if !ok {
+		return false, fmt.Errorf("merkle_root not found in public inputs")
+	}
+
+	return VerifyMerkleMembershipPath(root, &proofData.MerkleMembershipPath)
 }
 
 // verifyEqualityProof verifies an equality proof
@@ -507,22 +634,29 @@ func (z *ZKPService) validateZKPRequest(request ZKPRequest) error {
 	}
 
 	// Validate proof type
-	validTypes := []string{"age_verification", "range_proof", "membership_proof", "equality_proof"}
-	valid := false
-	for _, t := range validTypes {
-		if request.ProofType == t {
-			valid = true
-			break
-		}
+	circuit, _, exists := z.circuits.Get(request.ProofType)
+	if !exists {
+		return fmt.Errorf("invalid proof type: %s", request.ProofType)
 	}
 
-	if !valid {
-		return fmt.Errorf("invalid proof type: %s", request.ProofType)
+	// Built-in circuits report missing inputs from the backend, as before
+	if !circuit.Builtin {
+		return circuit.checkInputs(request)
 	}
 
 	return nil
 }
 
+// isSupportedProofType reports whether a proof type has a circuit
+func isSupportedProofType(proofType string) bool {
+	for _, t := range supportedProofTypes {
+		if proofType == t {
+			return true
+		}
+	}
+	return false
+}
+
 // validateVerificationRequest validates a verification request
 func (z *ZKPService) validateVerificationRequest(request ZKPVerificationRequest) error {
 	if request.Proof == "" {
@@ -556,75 +690,38 @@ func (z *ZKPService) extractProofType(request ZKPVerificationRequest) string {
 	return "unknown"
 }
 
-// generateProofID generates a unique proof ID
+// generateProofID generates a unique proof ID. The random nonce keeps IDs of
+// identical requests apart, since stored proofs are looked up by ID.
 func (z *ZKPService) generateProofID(request ZKPRequest) string {
-	data := fmt.Sprintf("%s:%s:%s", request.ProofType, request.Statement, time.Now().Format(time.RFC3339))
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+
+	data := fmt.Sprintf("%s:%s:%s:%x", request.ProofType, request.Statement, time.Now().Format(time.RFC3339), nonce)
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:16]) // Use first 16 bytes for shorter ID
 }
 
 // GetZKPStats returns statistics about ZKP operations
 func (z *ZKPService) GetZKPStats() map[string]interface{} {
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"proof_timeout":     z.config.ProofTimeout.String(),
 		"max_proof_size":    z.config.MaxProofSize,
 		"hash_algorithm":    z.config.HashAlgorithm,
 		"audit_log_enabled": z.config.EnableAuditLog,
-		"supported_proof_types": []string{
-			"age_verification",
-			"range_proof",
-			"membership_proof",
-			"equality_proof",
-		},
+		"backend":           z.backend.Name(),
+	}
+	proofTypes := make([]string, 0)
+	for _, circuit := range z.circuits.List() {
+		proofTypes = append(proofTypes, circuit.Name)
 	}
+	stats["supported_proof_types"] = proofTypes
+	if gnark, ok := z.backend.(*GnarkBackend); ok {
+		stats["scheme"] = gnark.Scheme()
+	}
+	return stats
 }
 
-// GetSupportedCircuits returns the list of supported ZKP circuits
+// GetSupportedCircuits returns the built-in and registered ZKP circuits
 func (z *ZKPService) GetSupportedCircuits() []ZKPCircuit {
-	return []ZKPCircuit{
-		{
-			Name:        "age_verification",
-			Description: "Prove age is above threshold without revealing actual age",
-			Inputs:      []string{"age", "minimum_age"},
-			Outputs:     []string{"age_above_threshold"},
-			Constraints: []string{"age >= minimum_age"},
-			Metadata: map[string]interface{}{
-				"category":   "privacy",
-				"complexity": "simple",
-			},
-		},
-		{
-			Name:        "range_proof",
-			Description: "Prove value is within range without revealing actual value",
-			Inputs:      []string{"value", "min_value", "max_value"},
-			Outputs:     []string{"value_in_range"},
-			Constraints: []string{"min_value <= value <= max_value"},
-			Metadata: map[string]interface{}{
-				"category":   "privacy",
-				"complexity": "simple",
-			},
-		},
-		{
-			Name:        "membership_proof",
-			Description: "Prove element is in set without revealing element or set",
-			Inputs:      []string{"element", "set"},
-			Outputs:     []string{"element_in_set"},
-			Constraints: []string{"element ∈ set"},
-			Metadata: map[string]interface{}{
-				"category":   "privacy",
-				"complexity": "medium",
-			},
-		},
-		{
-			Name:        "equality_proof",
-			Description: "Prove two values are equal without revealing the values",
-			Inputs:      []string{"value1", "value2"},
-			Outputs:     []string{"values_equal"},
-			Constraints: []string{"value1 == value2"},
-			Metadata: map[string]interface{}{
-				"category":   "privacy",
-				"complexity": "simple",
-			},
-		},
-	}
+	return z.circuits.List()
 }